@@ -0,0 +1,90 @@
+package httpipfs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	e "github.com/pkg/errors"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// HasBlock checks if the block `hash` is stored locally.
+func (nd *Node) HasBlock(hash h.Hash) (bool, error) {
+	// IsCached only looks at the root block already.
+	return nd.IsCached(hash)
+}
+
+// BlockLinks returns the direct children of the local block `hash`.
+func (nd *Node) BlockLinks(hash h.Hash) ([]h.Hash, error) {
+	ctx := context.Background()
+	req := nd.sh.Request("refs", hash.B58String())
+	req.Option("offline", "true")
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Close()
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	links := []h.Hash{}
+	dec := json.NewDecoder(resp.Output)
+	for dec.More() {
+		ref := struct {
+			Ref string
+			Err string
+		}{}
+
+		if err := dec.Decode(&ref); err != nil {
+			return nil, e.Wrapf(err, "refs decode")
+		}
+
+		if ref.Err != "" {
+			return nil, e.Errorf("refs: %s", ref.Err)
+		}
+
+		link, err := h.FromB58String(ref.Ref)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+// GetBlock returns the raw data of the local block `hash`.
+// It does not try to fetch the block from the network.
+func (nd *Node) GetBlock(hash h.Hash) ([]byte, error) {
+	ctx := context.Background()
+	req := nd.sh.Request("block/get", hash.B58String())
+	req.Option("offline", "true")
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Close()
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	return ioutil.ReadAll(resp.Output)
+}
+
+// PutBlock stores a single block and returns its hash.
+func (nd *Node) PutBlock(data []byte) (h.Hash, error) {
+	// Blocks of added files are protobuf nodes hashed with sha2-256.
+	hs, err := nd.sh.BlockPut(data, "v0", "sha2-256", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.FromB58String(hs)
+}
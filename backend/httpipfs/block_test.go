@@ -0,0 +1,39 @@
+package httpipfs
+
+import (
+	"bytes"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockRoundtrip(t *testing.T) {
+	WithIpfs(t, 1, func(t *testing.T, ipfsPath string) {
+		nd, err := NewNode(ipfsPath, "")
+		require.Nil(t, err)
+
+		// Big enough to be split into several blocks:
+		data := testutil.CreateDummyBuf(4096 * 1024)
+		hash, err := nd.Add(bytes.NewReader(data))
+		require.Nil(t, err)
+
+		links, err := nd.BlockLinks(hash)
+		require.Nil(t, err)
+		require.True(t, len(links) > 1)
+
+		for _, link := range append([]h.Hash{hash}, links...) {
+			has, err := nd.HasBlock(link)
+			require.Nil(t, err)
+			require.True(t, has)
+
+			block, err := nd.GetBlock(link)
+			require.Nil(t, err)
+
+			stored, err := nd.PutBlock(block)
+			require.Nil(t, err)
+			require.Equal(t, link, stored)
+		}
+	})
+}
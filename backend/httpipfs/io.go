@@ -48,11 +48,11 @@ func (sw *streamWrapper) WriteTo(w io.Writer) (int64, error) {
 	return io.Copy(w, sw)
 }
 
-func (sw *streamWrapper) cachedSize() (int64, error) {
+func statSize(sh *shell.Shell, hash h.Hash) (int64, error) {
 	ctx := context.Background()
-	resp, err := sw.nd.sh.Request(
+	resp, err := sh.Request(
 		"files/stat",
-		"/ipfs/"+sw.hash.B58String(),
+		"/ipfs/"+hash.B58String(),
 	).Send(ctx)
 
 	if err != nil {
@@ -76,6 +76,10 @@ func (sw *streamWrapper) cachedSize() (int64, error) {
 	return raw.Size, nil
 }
 
+func (sw *streamWrapper) cachedSize() (int64, error) {
+	return statSize(sw.nd.sh, sw.hash)
+}
+
 func (sw *streamWrapper) getAbsOffset(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
@@ -142,3 +146,13 @@ func (nd *Node) Add(r io.Reader) (h.Hash, error) {
 
 	return h.FromB58String(hs)
 }
+
+// StoredSize returns the size of the (encoded) object at `hash` in ipfs.
+func (nd *Node) StoredSize(hash h.Hash) (uint64, error) {
+	size, err := statSize(nd.sh, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(size), nil
+}
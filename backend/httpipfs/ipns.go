@@ -0,0 +1,62 @@
+package httpipfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// headRecordLifetime is how long a published head stays valid in the
+	// DHT. Republishing happens way more often, but we want peers to be
+	// able to see it while we are offline for a while.
+	headRecordLifetime = 7 * 24 * time.Hour
+
+	// maxHeadSize is the most we read of a resolved head record.
+	maxHeadSize = 64 * 1024
+)
+
+// PublishHead adds `data` to ipfs and points our IPNS name to it.
+func (nd *Node) PublishHead(data []byte) error {
+	if !nd.isOnline() {
+		return ErrOffline
+	}
+
+	hash, err := nd.sh.Add(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := nd.sh.PublishWithDetails("/ipfs/"+hash, "", headRecordLifetime, 0, false)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("published head record %s under /ipns/%s", resp.Value, resp.Name)
+	return nil
+}
+
+// ResolveHead resolves the IPNS name of `peerAddr` and returns
+// the record it points to. The record is not checked in any way.
+func (nd *Node) ResolveHead(ctx context.Context, peerAddr string) ([]byte, error) {
+	if !nd.isOnline() {
+		return nil, ErrOffline
+	}
+
+	var out struct{ Path string }
+	if err := nd.sh.Request("name/resolve", "/ipns/"+peerAddr).Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	stream, err := nd.sh.Cat(out.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stream.Close()
+	return ioutil.ReadAll(io.LimitReader(stream, maxHeadSize))
+}
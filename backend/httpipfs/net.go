@@ -10,11 +10,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	netBackend "github.com/sahib/brig/net/backend"
-	"github.com/sahib/brig/util"
+	"github.com/sahib/brig/util/dialer"
+	"github.com/sahib/brig/util/server"
 	shell "github.com/sahib/go-ipfs-api"
 	log "github.com/sirupsen/logrus"
 )
@@ -66,24 +68,34 @@ func (nd *Node) Dial(peerHash, fingerprint, protocol string) (net.Conn, error) {
 		// other brig instances. Since we cannot dial over ipfs
 		// we simply have the port written to /tmp where
 		// we can pick it up on Dial()
-		addr, err := readLocalAddr(peerHash, fingerprint)
+		addrs, err := readLocalAddrs(peerHash, fingerprint)
 		if err != nil {
 			return nil, err
 		}
 
-		return net.Dial("tcp", addr)
+		// The other instance listens on every loopback address it has;
+		// take whichever answers first.
+		conn, _, err := dialer.DialParallel(context.Background(), "tcp", addrs, dialer.DefaultDelay)
+		return conn, err
 	}
 
 	protocol = path.Join(protocol, peerHash)
 
-	port := util.FindFreePort()
-	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
+	tcpAddr, err := freeLoopbackAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := toMultiaddr(tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := forward(nd.sh, protocol, addr, peerHash); err != nil {
 		return nil, err
 	}
 
-	tcpAddr := fmt.Sprintf("127.0.0.1:%d", port)
-	log.Debugf("dial to »%s« over port %d", peerHash, port)
+	log.Debugf("dial to »%s« over %s", peerHash, tcpAddr)
 	conn, err := net.Dial("tcp", tcpAddr)
 	if err != nil {
 		return nil, err
@@ -216,14 +228,15 @@ func buildLocalAddrPath(id, fingerprint string) string {
 	return filepath.Join(os.TempDir(), fmt.Sprintf("brig-%s:%s.addr", id, fingerprint))
 }
 
-func readLocalAddr(id, fingerprint string) (string, error) {
+// readLocalAddrs returns the addresses written by writeLocalAddrs.
+func readLocalAddrs(id, fingerprint string) ([]string, error) {
 	path := buildLocalAddrPath(id, fingerprint)
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(data), nil
+	return strings.Fields(string(data)), nil
 }
 
 func deleteLocalAddr(id, fingerprint string) error {
@@ -231,9 +244,63 @@ func deleteLocalAddr(id, fingerprint string) error {
 	return os.RemoveAll(path)
 }
 
-func writeLocalAddr(id, fingerprint, addr string) error {
+func writeLocalAddrs(id, fingerprint string, addrs []string) error {
 	path := buildLocalAddrPath(id, fingerprint)
-	return ioutil.WriteFile(path, []byte(addr), 0644)
+	return ioutil.WriteFile(path, []byte(strings.Join(addrs, "\n")), 0644)
+}
+
+// loopbackHosts are the loopback addresses we try to use, in this order.
+// Hosts on IPv6-only networks might not have 127.0.0.1 and vice versa.
+var loopbackHosts = []string{"127.0.0.1", "::1"}
+
+// listenLoopback listens on a free port of every loopback address we have.
+func listenLoopback() ([]net.Listener, error) {
+	lsts := []net.Listener{}
+	var listenErr error
+
+	for _, host := range loopbackHosts {
+		lst, err := net.Listen("tcp", dialer.JoinHostPort(host, 0))
+		if err != nil {
+			listenErr = err
+			continue
+		}
+
+		lsts = append(lsts, lst)
+	}
+
+	if len(lsts) == 0 {
+		return nil, fmt.Errorf("no usable loopback address: %v", listenErr)
+	}
+
+	return lsts, nil
+}
+
+// freeLoopbackAddr returns a loopback address with a port that is free right now.
+func freeLoopbackAddr() (string, error) {
+	lsts, err := listenLoopback()
+	if err != nil {
+		return "", err
+	}
+
+	for _, lst := range lsts {
+		lst.Close()
+	}
+
+	return lsts[0].Addr().String(), nil
+}
+
+// toMultiaddr converts a "host:port" tcp address to the form IPFS expects.
+func toMultiaddr(addr string) (string, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	if ip4 := tcpAddr.IP.To4(); ip4 != nil {
+		return fmt.Sprintf("/ip4/%s/tcp/%d", ip4, tcpAddr.Port), nil
+	}
+
+	return fmt.Sprintf("/ip6/%s/tcp/%d", tcpAddr.IP, tcpAddr.Port), nil
 }
 
 // Listen will listen to the protocol
@@ -252,27 +319,49 @@ func (nd *Node) Listen(protocol string) (net.Listener, error) {
 	// Append the id to the protocol:
 	protocol = path.Join(protocol, self.Addr)
 
-	port := util.FindFreePort()
-	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
+	lsts, err := listenLoopback()
+	if err != nil {
+		return nil, err
+	}
+
+	closeAll := func() {
+		for _, lst := range lsts {
+			lst.Close()
+		}
+	}
+
+	localAddrs := []string{}
+	for _, lst := range lsts {
+		localAddrs = append(localAddrs, lst.Addr().String())
+	}
+
+	// IPFS forwards to only one address; the others are for local dials.
+	addr, err := toMultiaddr(localAddrs[0])
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
 
 	// Prevent errors by closing any previously opened listeners:
 	if err := closeStream(nd.sh, protocol, "", ""); err != nil {
+		closeAll()
 		return nil, err
 	}
 
-	log.Debugf("backend: listening for %s over port %d", protocol, port)
+	log.Debugf("backend: listening for %s over %s", protocol, strings.Join(localAddrs, ", "))
 	if err := openListener(nd.sh, protocol, addr); err != nil {
+		closeAll()
 		return nil, err
 	}
 
-	localAddr := fmt.Sprintf("127.0.0.1:%d", port)
-	lst, err := net.Listen("tcp", localAddr)
-	if err != nil {
+	if err := writeLocalAddrs(self.Addr, nd.fingerprint, localAddrs); err != nil {
+		closeAll()
 		return nil, err
 	}
 
-	if err := writeLocalAddr(self.Addr, nd.fingerprint, localAddr); err != nil {
-		return nil, err
+	var lst net.Listener = lsts[0]
+	if len(lsts) > 1 {
+		lst = server.NewMultiListener(lsts...)
 	}
 
 	return &listenerWrapper{
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -24,6 +25,7 @@ import (
 var Aliases = map[string]interface{}{
 	"b": Build.Binary,
 	"g": Build.Generate,
+	"u": Build.UI,
 	"t": Build.Test,
 	"l": Dev.Lint,
 	"c": Dev.Capnp,
@@ -87,7 +89,37 @@ var Default = Build.Binary
 
 type Build mg.Namespace
 
+// UI compiles the elm sources of the gateway to gateway/static/js/app.js,
+// if they changed since the last build. Needs elm, uglifyjs and brotli.
+func (Build) UI() error {
+	modified, err := target.Dir(
+		// Reference file:
+		"gateway/static/js/app.js",
+		// Paths to be checked for their age:
+		"gateway/elm/src",
+		"gateway/elm/elm.json",
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if !modified {
+		speak("ignoring ui; elm sources did not change.")
+		return nil
+	}
+
+	if _, err := exec.LookPath("elm"); err != nil {
+		speak("WARNING: gateway/elm changed, but elm is not installed; app.js is outdated.")
+		return nil
+	}
+
+	return sh.RunV("make", "-C", "gateway/elm")
+}
+
 func (Build) Generate() error {
+	mg.Deps(Build.UI)
+
 	// Check if we really need to to do the rather
 	// expensive "go generate" step.
 	modified, err := target.Dir(
@@ -0,0 +1,365 @@
+package catfs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/db"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// ActivityChange is a single file that changed in a commit.
+type ActivityChange struct {
+	Path string `json:"path"`
+	// Change is either "added", "removed" or "modified".
+	// Moves show up as a removal and an addition.
+	Change string `json:"change"`
+}
+
+// Activity is everything that happened in a single commit below a path.
+type Activity struct {
+	Commit  *Commit
+	Changes []ActivityChange
+}
+
+// activityEntry is what is stored per indexed commit.
+type activityEntry struct {
+	Hash    string           `json:"hash"`
+	Changes []ActivityChange `json:"changes"`
+}
+
+// The activity index lives in the metadata store under these keys:
+//
+//	activity.last            -> index of the newest indexed commit.
+//	activity.cmt.<index>     -> json encoded activityEntry.
+//	activity.dir.<hex(path)> -> json list of commit indices that changed
+//	                            something below path, newest first.
+//
+// Paths are hex encoded, since they may contain the key separator.
+func activityDirKey(dir string) []string {
+	return []string{"activity", "dir", hex.EncodeToString([]byte(dir))}
+}
+
+func activityCommitKey(index int64) []string {
+	return []string{"activity", "cmt", strconv.FormatInt(index, 10)}
+}
+
+// isGone returns true if `nd` does not count as existing.
+func isGone(nd n.Node) bool {
+	return nd == nil || nd.Type() == n.NodeTypeGhost
+}
+
+func walkFiles(lkr *c.Linker, nd n.Node, change string, fn func(ActivityChange)) error {
+	return n.Walk(lkr, nd, false, func(child n.Node) error {
+		if child.Type() == n.NodeTypeFile {
+			fn(ActivityChange{Path: child.Path(), Change: change})
+		}
+
+		return nil
+	})
+}
+
+// diffTrees calls `fn` for every file that differs between `oldNd` and `newNd`.
+// Subtrees with the same tree hash are skipped, so this is cheap for
+// commits that only touched a few directories.
+func diffTrees(lkr *c.Linker, oldNd, newNd n.Node, fn func(ActivityChange)) error {
+	switch {
+	case isGone(oldNd) && isGone(newNd):
+		return nil
+	case isGone(oldNd):
+		return walkFiles(lkr, newNd, "added", fn)
+	case isGone(newNd):
+		return walkFiles(lkr, oldNd, "removed", fn)
+	case oldNd.Type() != newNd.Type():
+		if err := walkFiles(lkr, oldNd, "removed", fn); err != nil {
+			return err
+		}
+
+		return walkFiles(lkr, newNd, "added", fn)
+	case newNd.Type() == n.NodeTypeFile:
+		if !oldNd.ContentHash().Equal(newNd.ContentHash()) {
+			fn(ActivityChange{Path: newNd.Path(), Change: "modified"})
+		}
+
+		return nil
+	}
+
+	if oldNd.TreeHash().Equal(newNd.TreeHash()) {
+		return nil
+	}
+
+	oldDir, ok1 := oldNd.(*n.Directory)
+	newDir, ok2 := newNd.(*n.Directory)
+	if !ok1 || !ok2 {
+		return e.Errorf("bad node types in diff: %v %v", oldNd, newNd)
+	}
+
+	names := make(map[string]bool)
+	for _, name := range oldDir.ChildNames() {
+		names[name] = true
+	}
+
+	for _, name := range newDir.ChildNames() {
+		names[name] = true
+	}
+
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		oldChild, err := oldDir.Child(lkr, name)
+		if err != nil {
+			return err
+		}
+
+		newChild, err := newDir.Child(lkr, name)
+		if err != nil {
+			return err
+		}
+
+		if err := diffTrees(lkr, oldChild, newChild, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commitChanges returns all files that were changed by `cmt`.
+func commitChanges(lkr *c.Linker, cmt *n.Commit) ([]ActivityChange, error) {
+	newRoot, err := lkr.DirectoryByHash(cmt.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	var oldRoot n.Node
+	parent, err := cmt.Parent(lkr)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentCmt, ok := parent.(*n.Commit); ok && parentCmt != nil {
+		oldRoot, err = lkr.DirectoryByHash(parentCmt.Root())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []ActivityChange{}
+	err = diffTrees(lkr, oldRoot, newRoot, func(change ActivityChange) {
+		changes = append(changes, change)
+	})
+
+	return changes, err
+}
+
+func getJSON(kv db.Database, dst interface{}, key ...string) (bool, error) {
+	data, err := kv.Get(key...)
+	if err == db.ErrNoSuchKey {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, dst)
+}
+
+// updateActivityIndex adds all commits that were made since the last call.
+// If the history was rewritten in the meantime, the index is rebuilt.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) updateActivityIndex() error {
+	head, err := fs.lkr.Head()
+	if err != nil {
+		return err
+	}
+
+	last := int64(-1)
+	if _, err := getJSON(fs.kv, &last, "activity", "last"); err != nil {
+		return err
+	}
+
+	batch := fs.kv.Batch()
+	if last >= 0 {
+		entry := activityEntry{}
+		found, err := getJSON(fs.kv, &entry, activityCommitKey(last)...)
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+
+		cmt, err := fs.lkr.CommitByIndex(last)
+		if err != nil || !found || cmt == nil || cmt.TreeHash().B58String() != entry.Hash {
+			// History changed under our feet (e.g. after an import).
+			if err := batch.Clear("activity"); err != nil {
+				batch.Rollback()
+				return err
+			}
+
+			last = -1
+		}
+	}
+
+	if head.Index() <= last {
+		batch.Rollback()
+		return nil
+	}
+
+	// Collect the new commits, oldest first:
+	newCmts := []*n.Commit{}
+	err = c.Log(fs.lkr, head, func(cmt *n.Commit) error {
+		if cmt.Index() <= last {
+			return errStopLog
+		}
+
+		newCmts = append([]*n.Commit{cmt}, newCmts...)
+		return nil
+	})
+
+	if err != nil && err != errStopLog {
+		batch.Rollback()
+		return err
+	}
+
+	dirs := make(map[string][]int64)
+	for _, cmt := range newCmts {
+		changes, err := commitChanges(fs.lkr, cmt)
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+
+		entry := activityEntry{Hash: cmt.TreeHash().B58String(), Changes: changes}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+
+		batch.Put(data, activityCommitKey(cmt.Index())...)
+
+		seen := make(map[string]bool)
+		for _, change := range changes {
+			for dir := path.Dir(change.Path); ; dir = path.Dir(dir) {
+				if !seen[dir] {
+					seen[dir] = true
+					dirs[dir] = append(dirs[dir], cmt.Index())
+				}
+
+				if dir == "/" {
+					break
+				}
+			}
+
+			seen[change.Path] = true
+			dirs[change.Path] = append(dirs[change.Path], cmt.Index())
+		}
+	}
+
+	for dir, indices := range dirs {
+		existing := []int64{}
+		if last >= 0 {
+			if _, err := getJSON(fs.kv, &existing, activityDirKey(dir)...); err != nil {
+				batch.Rollback()
+				return err
+			}
+		}
+
+		// Keep the newest first:
+		for lo, hi := 0, len(indices)-1; lo < hi; lo, hi = lo+1, hi-1 {
+			indices[lo], indices[hi] = indices[hi], indices[lo]
+		}
+
+		data, err := json.Marshal(append(indices, existing...))
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+
+		batch.Put(data, activityDirKey(dir)...)
+	}
+
+	data, err := json.Marshal(head.Index())
+	if err != nil {
+		batch.Rollback()
+		return err
+	}
+
+	batch.Put(data, "activity", "last")
+	return batch.Flush()
+}
+
+// Activity returns the commits that changed something below `root`,
+// newest first. Only the changes below `root` are included.
+// `offset` commits are skipped and at most `limit` are returned;
+// a negative limit returns all of them.
+func (fs *FS) Activity(root string, offset, limit int) ([]Activity, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root = fs.normPath(root)
+	if err := fs.updateActivityIndex(); err != nil {
+		return nil, err
+	}
+
+	indices := []int64{}
+	if _, err := getJSON(fs.kv, &indices, activityDirKey(root)...); err != nil {
+		return nil, err
+	}
+
+	if offset >= len(indices) {
+		return []Activity{}, nil
+	}
+
+	indices = indices[offset:]
+	if limit >= 0 && limit < len(indices) {
+		indices = indices[:limit]
+	}
+
+	hashToRef, err := fs.buildCommitHashToRefTable()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Activity{}
+	for _, index := range indices {
+		entry := activityEntry{}
+		if _, err := getJSON(fs.kv, &entry, activityCommitKey(index)...); err != nil {
+			return nil, err
+		}
+
+		cmt, err := fs.lkr.CommitByIndex(index)
+		if err != nil {
+			return nil, err
+		}
+
+		changes := []ActivityChange{}
+		for _, change := range entry.Changes {
+			if change.Path == root || root == "/" || isPrefixDir(root, change.Path) {
+				changes = append(changes, change)
+			}
+		}
+
+		result = append(result, Activity{
+			Commit:  commitToExternal(cmt, hashToRef),
+			Changes: changes,
+		})
+	}
+
+	return result, nil
+}
+
+// isPrefixDir returns true if `p` is somewhere below `dir`.
+func isPrefixDir(dir, p string) bool {
+	return len(p) > len(dir) && p[:len(dir)] == dir && p[len(dir)] == '/'
+}
@@ -0,0 +1,51 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivity(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/docs/a.txt", bytes.NewReader([]byte("a"))))
+		require.Nil(t, fs.Stage("/photos/b.png", bytes.NewReader([]byte("b"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Nil(t, fs.Stage("/docs/a.txt", bytes.NewReader([]byte("A"))))
+		require.Nil(t, fs.MakeCommit("second"))
+
+		acts, err := fs.Activity("/docs", 0, -1)
+		require.Nil(t, err)
+		require.Len(t, acts, 2)
+		require.Equal(t, "second", acts[0].Commit.Msg)
+		require.Equal(t, []ActivityChange{{Path: "/docs/a.txt", Change: "modified"}}, acts[0].Changes)
+		require.Equal(t, "first", acts[1].Commit.Msg)
+		require.Equal(t, []ActivityChange{{Path: "/docs/a.txt", Change: "added"}}, acts[1].Changes)
+
+		acts, err = fs.Activity("/photos", 0, -1)
+		require.Nil(t, err)
+		require.Len(t, acts, 1)
+		require.Equal(t, []ActivityChange{{Path: "/photos/b.png", Change: "added"}}, acts[0].Changes)
+
+		// Commits made later are indexed on the next call:
+		require.Nil(t, fs.Move("/photos/b.png", "/docs/b.png"))
+		require.Nil(t, fs.MakeCommit("third"))
+
+		acts, err = fs.Activity("/docs", 0, 1)
+		require.Nil(t, err)
+		require.Len(t, acts, 1)
+		require.Equal(t, "third", acts[0].Commit.Msg)
+		require.Equal(t, []ActivityChange{{Path: "/docs/b.png", Change: "added"}}, acts[0].Changes)
+
+		acts, err = fs.Activity("/", 1, 10)
+		require.Nil(t, err)
+		require.Len(t, acts, 2)
+		require.Equal(t, "second", acts[0].Commit.Msg)
+
+		acts, err = fs.Activity("/nothing", 0, -1)
+		require.Nil(t, err)
+		require.Empty(t, acts)
+	})
+}
@@ -0,0 +1,187 @@
+package catfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+const (
+	// ArchiveTar produces an uncompressed tar archive.
+	ArchiveTar = "tar"
+
+	// ArchiveTarGz produces a gzip compressed tar archive.
+	ArchiveTarGz = "tar.gz"
+
+	// ArchiveZip produces a zip archive with deflated entries.
+	ArchiveZip = "zip"
+)
+
+// archiveEntry is a file that goes into the archive. The stream is only
+// opened when the file is written, so big archives do not need to keep
+// one stream per file open.
+type archiveEntry struct {
+	path        string
+	size        uint64
+	modTime     time.Time
+	backendHash h.Hash
+	key         []byte
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) archiveEntries(rev, root string, filter func(node *StatInfo) bool) ([]archiveEntry, string, error) {
+	root = fs.normPath(root)
+	rootNd, err := fs.lookupNodeAt(rev, root)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := []archiveEntry{}
+	err = n.Walk(fs.lkr, rootNd, false, func(child n.Node) error {
+		if filter != nil && rootNd.Path() != child.Path() {
+			if !filter(fs.nodeToStat(child)) {
+				return n.ErrSkipChild
+			}
+		}
+
+		if child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		file, ok := child.(*n.File)
+		if !ok {
+			return ie.ErrBadNode
+		}
+
+		key := make([]byte, len(file.Key()))
+		copy(key, file.Key())
+
+		entries = append(entries, archiveEntry{
+			path:        file.Path(),
+			size:        file.Size(),
+			modTime:     file.ModTime(),
+			backendHash: file.BackendHash().Clone(),
+			key:         key,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+
+	// Archives of a single file should only contain the file name:
+	prefix := root
+	if rootNd.Type() != n.NodeTypeDirectory {
+		prefix = path.Dir(root)
+	}
+
+	return entries, prefix, nil
+}
+
+// Archive writes the file or directory at `root`, as it was in the commit
+// `rev`, as archive in `format` (ArchiveTar, ArchiveTarGz or ArchiveZip) to `w`.
+// File contents are streamed one after another from the backend.
+// `filter` may be nil; if given, it decides which nodes go into the archive.
+func (fs *FS) Archive(rev, root, format string, w io.Writer, filter func(node *StatInfo) bool) error {
+	if format != ArchiveTar && format != ArchiveTarGz && format != ArchiveZip {
+		return e.Errorf("unsupported archive format: %s", format)
+	}
+
+	fs.mu.Lock()
+	entries, prefix, err := fs.archiveEntries(rev, root, filter)
+	fs.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	var (
+		tw *tar.Writer
+		gw *gzip.Writer
+		zw *zip.Writer
+	)
+
+	switch format {
+	case ArchiveTar:
+		tw = tar.NewWriter(w)
+	case ArchiveTarGz:
+		gw = gzip.NewWriter(w)
+		tw = tar.NewWriter(gw)
+	default:
+		zw = zip.NewWriter(w)
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.path[len(prefix):], "/")
+
+		var dst io.Writer
+		if tw != nil {
+			hdr := &tar.Header{
+				Name:    name,
+				Mode:    0600,
+				Size:    int64(entry.size),
+				ModTime: entry.modTime,
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			dst = tw
+		} else {
+			hdr := &zip.FileHeader{
+				Name:     name,
+				Method:   zip.Deflate,
+				Modified: entry.modTime,
+			}
+
+			hdr.SetMode(0600)
+			dst, err = zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+		}
+
+		stream, err := fs.catHash(entry.backendHash, entry.key, entry.size)
+		if err != nil {
+			return e.Wrapf(err, "failed to open stream for %s", entry.path)
+		}
+
+		_, err = io.Copy(dst, stream)
+		stream.Close()
+
+		if err != nil {
+			return e.Wrapf(err, "failed to archive %s", entry.path)
+		}
+	}
+
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return err
+		}
+
+		if gw != nil {
+			return gw.Close()
+		}
+
+		return nil
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,94 @@
+package catfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveTarAtRev(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/dir/a", bytes.NewReader([]byte("old"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Nil(t, fs.Stage("/dir/a", bytes.NewReader([]byte("new"))))
+		require.Nil(t, fs.Stage("/dir/b", bytes.NewReader([]byte("added"))))
+		require.Nil(t, fs.MakeCommit("second"))
+
+		buf := &bytes.Buffer{}
+		require.Nil(t, fs.Archive("head^", "/dir", ArchiveTar, buf, nil))
+
+		r := tar.NewReader(buf)
+		hdr, err := r.Next()
+		require.Nil(t, err)
+		require.Equal(t, "a", hdr.Name)
+
+		data, err := ioutil.ReadAll(r)
+		require.Nil(t, err)
+		require.Equal(t, []byte("old"), data)
+
+		_, err = r.Next()
+		require.Equal(t, io.EOF, err)
+	})
+}
+
+func TestArchiveTarGz(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/a", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		buf := &bytes.Buffer{}
+		require.Nil(t, fs.Archive("head", "/", ArchiveTarGz, buf, nil))
+
+		gr, err := gzip.NewReader(buf)
+		require.Nil(t, err)
+
+		r := tar.NewReader(gr)
+		hdr, err := r.Next()
+		require.Nil(t, err)
+		require.Equal(t, "a", hdr.Name)
+
+		data, err := ioutil.ReadAll(r)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), data)
+
+		_, err = r.Next()
+		require.Equal(t, io.EOF, err)
+	})
+}
+
+func TestArchiveZip(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x/a", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.Stage("/x/sub/b", bytes.NewReader([]byte("world"))))
+		require.Nil(t, fs.Stage("/y/c", bytes.NewReader([]byte("!"))))
+
+		buf := &bytes.Buffer{}
+		require.Nil(t, fs.Archive("curr", "/", ArchiveZip, buf, func(info *StatInfo) bool {
+			return info.Path != "/y"
+		}))
+
+		r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.Nil(t, err)
+		require.Len(t, r.File, 2)
+
+		expect := map[string]string{"x/a": "hello", "x/sub/b": "world"}
+		for _, file := range r.File {
+			fd, err := file.Open()
+			require.Nil(t, err)
+
+			data, err := ioutil.ReadAll(fd)
+			require.Nil(t, err)
+			require.Nil(t, fd.Close())
+			require.Equal(t, expect[file.Name], string(data))
+		}
+
+		require.NotNil(t, fs.Archive("curr", "/", "rar", buf, nil))
+	})
+}
@@ -0,0 +1,127 @@
+package catfs
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// stagedCounts tells how many files were changed since the last commit.
+type stagedCounts struct {
+	Added    int
+	Modified int
+	Removed  int
+}
+
+// stagedChanges counts the files that differ between HEAD and the staging area.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) stagedChanges() (stagedCounts, error) {
+	counts := stagedCounts{}
+	stageRoot, err := fs.lkr.Root()
+	if err != nil {
+		return counts, err
+	}
+
+	var headRoot n.Node
+	head, err := fs.lkr.Head()
+	if err != nil && !ie.IsErrNoSuchRef(err) {
+		return counts, err
+	}
+
+	if head != nil {
+		if headRoot, err = fs.lkr.DirectoryByHash(head.Root()); err != nil {
+			return counts, err
+		}
+	}
+
+	err = diffTrees(fs.lkr, headRoot, stageRoot, func(change ActivityChange) {
+		switch change.Change {
+		case "added":
+			counts.Added++
+		case "modified":
+			counts.Modified++
+		case "removed":
+			counts.Removed++
+		}
+	})
+
+	return counts, err
+}
+
+// expandCommitTemplate replaces the placeholders in `tmpl`.
+// Unknown placeholders are kept as they are.
+func expandCommitTemplate(tmpl string, counts stagedCounts, owner string, now time.Time) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return strings.NewReplacer(
+		"{num_files}", strconv.Itoa(counts.Added+counts.Modified+counts.Removed),
+		"{added}", strconv.Itoa(counts.Added),
+		"{modified}", strconv.Itoa(counts.Modified),
+		"{removed}", strconv.Itoa(counts.Removed),
+		"{hostname}", hostname,
+		"{owner}", owner,
+		"{time}", now.Format(time.RFC822),
+		"{date}", now.Format("2006-01-02"),
+	).Replace(tmpl)
+}
+
+// autoCommitMessage builds the message of an auto commit from the
+// »fs.autocommit.message« template.
+func (fs *FS) autoCommitMessage() (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	counts, err := fs.stagedChanges()
+	if err != nil {
+		return "", err
+	}
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return "", err
+	}
+
+	tmpl := fs.cfg.String("autocommit.message")
+	return expandCommitTemplate(tmpl, counts, owner, time.Now()), nil
+}
+
+// stageHash returns the tree hash of the staging area.
+func (fs *FS) stageHash() (h.Hash, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	return root.TreeHash().Clone(), nil
+}
+
+// autoCommit commits all staged changes, if there are any.
+func (fs *FS) autoCommit() error {
+	haveStaged, err := fs.HaveStagedChanges()
+	if err != nil || !haveStaged {
+		return err
+	}
+
+	msg, err := fs.autoCommitMessage()
+	if err != nil {
+		return err
+	}
+
+	meta := CommitMeta{Origin: CommitOriginAuto}
+	if err := fs.MakeCommitWithMeta(msg, meta); err != nil && err != ie.ErrNoChange {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package catfs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCommitTemplate(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.Nil(t, err)
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	counts := stagedCounts{Added: 2, Modified: 1, Removed: 3}
+
+	require.Equal(
+		t,
+		"auto: 6 files changed on "+hostname+" (+2 ~1 -3) by alice at 2020-01-02 {nope}",
+		expandCommitTemplate(
+			"auto: {num_files} files changed on {hostname} (+{added} ~{modified} -{removed}) by {owner} at {date} {nope}",
+			counts, "alice", now,
+		),
+	)
+}
+
+func TestAutoCommit(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		fs.cfg.SetString("autocommit.message", "auto: {num_files} files ({added} added, {modified} modified, {removed} removed)")
+
+		require.Nil(t, fs.MakeCommit("init"))
+		before, err := fs.Head()
+		require.Nil(t, err)
+
+		// Nothing staged; no commit:
+		require.Nil(t, fs.autoCommit())
+		after, err := fs.Head()
+		require.Nil(t, err)
+		require.Equal(t, before, after)
+
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("x"))))
+		require.Nil(t, fs.Stage("/dir/y", bytes.NewReader([]byte("y"))))
+		require.Nil(t, fs.autoCommit())
+
+		log := []*Commit{}
+		require.Nil(t, fs.Log("HEAD", func(c *Commit) error {
+			log = append(log, c)
+			return nil
+		}))
+
+		require.Equal(t, "auto: 2 files (2 added, 0 modified, 0 removed)", log[0].Msg)
+		require.Equal(t, CommitOriginAuto, log[0].Origin)
+
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("xx"))))
+		require.Nil(t, fs.Remove("/dir/y"))
+		require.Nil(t, fs.autoCommit())
+
+		log = log[:0]
+		require.Nil(t, fs.Log("HEAD", func(c *Commit) error {
+			log = append(log, c)
+			return nil
+		}))
+
+		require.Equal(t, "auto: 2 files (0 added, 1 modified, 1 removed)", log[0].Msg)
+	})
+}
@@ -48,6 +48,32 @@ type FsBackend interface {
 	IsCached(hash h.Hash) (bool, error)
 }
 
+// SizeBackend may be implemented by backends that can tell how much space
+// an object takes up in storage. Since brig stores the data compressed and
+// encrypted, this usually differs from the size of the file.
+type SizeBackend interface {
+	// StoredSize returns the size of the object at `hash` in bytes.
+	StoredSize(hash h.Hash) (uint64, error)
+}
+
+// BlockBackend may be implemented by backends that store objects as a tree
+// of blocks and can hand out single blocks. This allows fetching the blocks
+// of an object from several peers at the same time.
+type BlockBackend interface {
+	// HasBlock checks if the block `hash` is stored locally.
+	// It should never ask the network.
+	HasBlock(hash h.Hash) (bool, error)
+
+	// BlockLinks returns the hashes of the blocks that `hash` links to.
+	BlockLinks(hash h.Hash) ([]h.Hash, error)
+
+	// GetBlock returns the raw data of a locally stored block.
+	GetBlock(hash h.Hash) ([]byte, error)
+
+	// PutBlock stores a single block and returns its hash.
+	PutBlock(data []byte) (h.Hash, error)
+}
+
 // MemFsBackend is a mock structure that implements FsBackend.
 type MemFsBackend struct {
 	data map[string][]byte
@@ -112,3 +138,46 @@ func (mb *MemFsBackend) IsCached(hash h.Hash) (bool, error) {
 	_, ok := mb.data[hash.B58String()]
 	return ok, nil
 }
+
+// StoredSize implements SizeBackend.StoredSize by checking the data length.
+func (mb *MemFsBackend) StoredSize(hash h.Hash) (uint64, error) {
+	data, ok := mb.data[hash.B58String()]
+	if !ok {
+		return 0, ErrNoSuchHash{hash}
+	}
+
+	return uint64(len(data)), nil
+}
+
+// HasBlock implements BlockBackend.HasBlock.
+// Objects are stored as a single block in memory.
+func (mb *MemFsBackend) HasBlock(hash h.Hash) (bool, error) {
+	return mb.IsCached(hash)
+}
+
+// BlockLinks implements BlockBackend.BlockLinks.
+// Blocks in memory never link to other blocks.
+func (mb *MemFsBackend) BlockLinks(hash h.Hash) ([]h.Hash, error) {
+	if _, ok := mb.data[hash.B58String()]; !ok {
+		return nil, ErrNoSuchHash{hash}
+	}
+
+	return nil, nil
+}
+
+// GetBlock implements BlockBackend.GetBlock by returning the object.
+func (mb *MemFsBackend) GetBlock(hash h.Hash) ([]byte, error) {
+	data, ok := mb.data[hash.B58String()]
+	if !ok {
+		return nil, ErrNoSuchHash{hash}
+	}
+
+	return data, nil
+}
+
+// PutBlock implements BlockBackend.PutBlock by storing it as object.
+func (mb *MemFsBackend) PutBlock(data []byte) (h.Hash, error) {
+	hash := h.SumWithBackendHash(data)
+	mb.data[hash.B58String()] = data
+	return hash, nil
+}
@@ -0,0 +1,243 @@
+// Package blockcache keeps blocks of content that was read from remotes
+// on the local disk. Files that are not pinned are fetched over the network
+// every time they are opened; with the cache, only blocks that were not read
+// recently have to be fetched again.
+//
+// The blocks are stored as they come from the backend, i.e. encrypted, so the
+// cache does not leak any more than the backend's own storage. Since content
+// is addressed by its hash, cached blocks never become stale. When the cache
+// grows over its maximum size, the least recently used blocks are removed.
+package blockcache
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlockSize is the size of a single cached block.
+// The last block of an object may be shorter.
+const BlockSize = 256 * 1024
+
+// Stats tells how well the cache works.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Size    int64
+	MaxSize int64
+	Blocks  int
+}
+
+// Cache is a size limited block cache on disk.
+// It is safe to use from several goroutines.
+type Cache struct {
+	mu sync.Mutex
+
+	dir     string
+	maxSize int64
+	size    int64
+
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits, misses uint64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+func blockKey(hash h.Hash, index int64) string {
+	return fmt.Sprintf("%s-%d", hash.B58String(), index)
+}
+
+func sizeKey(hash h.Hash) string {
+	return hash.B58String() + "-size"
+}
+
+// Open opens the cache in `dir`, creating it if necessary.
+// Blocks from earlier runs are kept, as long as they fit into `maxSize`.
+func Open(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Oldest first, so the newest ends up at the front:
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+
+		c.entries[info.Name()] = c.lru.PushFront(&cacheEntry{
+			key:  info.Name(),
+			size: info.Size(),
+		})
+
+		c.size += info.Size()
+	}
+
+	c.evict()
+	return c, nil
+}
+
+// evict removes the least recently used entries until the cache fits.
+// NOTE: This method assumes that c.mu is locked.
+func (c *Cache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// NOTE: This method assumes that c.mu is locked.
+func (c *Cache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+
+	if err := os.Remove(filepath.Join(c.dir, entry.key)); err != nil && !os.IsNotExist(err) {
+		log.Debugf("blockcache: failed to remove %s: %v", entry.key, err)
+	}
+}
+
+// get returns the entry for `key`. Only lookups with `count`
+// set show up in the statistics.
+func (c *Cache) get(key string, count bool) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		if count {
+			c.misses++
+		}
+
+		return nil, false
+	}
+
+	entryPath := filepath.Join(c.dir, key)
+	data, err := ioutil.ReadFile(entryPath)
+	if err != nil {
+		log.Debugf("blockcache: failed to read %s: %v", key, err)
+		c.remove(elem)
+		if count {
+			c.misses++
+		}
+
+		return nil, false
+	}
+
+	if count {
+		c.hits++
+	}
+
+	c.lru.MoveToFront(elem)
+
+	// Remember the order for the next start:
+	now := time.Now()
+	os.Chtimes(entryPath, now, now)
+	return data, true
+}
+
+func (c *Cache) put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(data))
+	if size > c.maxSize {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		// Content does not change; it's the same data.
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	// Write to a temporary file first, so a crash does
+	// not leave a half written block behind.
+	tmpPath := filepath.Join(c.dir, "."+key)
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Debugf("blockcache: failed to write %s: %v", key, err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(c.dir, key)); err != nil {
+		log.Debugf("blockcache: failed to store %s: %v", key, err)
+		os.Remove(tmpPath)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+	c.size += size
+	c.evict()
+}
+
+// Block returns the block with `index` of the object `hash`, if cached.
+func (c *Cache) Block(hash h.Hash, index int64) ([]byte, bool) {
+	return c.get(blockKey(hash, index), true)
+}
+
+// PutBlock stores the block with `index` of the object `hash`.
+// Errors are only logged; the cache is best effort.
+func (c *Cache) PutBlock(hash h.Hash, index int64, data []byte) {
+	c.put(blockKey(hash, index), data)
+}
+
+// Size returns the size of the object `hash`, if cached.
+func (c *Cache) Size(hash h.Hash) (int64, bool) {
+	data, ok := c.get(sizeKey(hash), false)
+	if !ok || len(data) != 8 {
+		return 0, false
+	}
+
+	return int64(binary.LittleEndian.Uint64(data)), true
+}
+
+// PutSize remembers the size of the object `hash`.
+func (c *Cache) PutSize(hash h.Hash, size int64) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, uint64(size))
+	c.put(sizeKey(hash), data)
+}
+
+// Stats returns the current statistics of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Size:    c.size,
+		MaxSize: c.maxSize,
+		Blocks:  c.lru.Len(),
+	}
+}
@@ -0,0 +1,107 @@
+package blockcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sahib/brig/catfs/mio"
+	"github.com/sahib/brig/catfs/mio/chunkbuf"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func withCache(t *testing.T, maxSize int64, fn func(dir string, c *Cache)) {
+	dir, err := ioutil.TempDir("", "brig-blockcache-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(dir, maxSize)
+	require.Nil(t, err)
+	fn(dir, c)
+}
+
+func TestStreamReadsThroughCache(t *testing.T) {
+	withCache(t, 10*BlockSize, func(dir string, c *Cache) {
+		data := testutil.CreateDummyBuf(2*BlockSize + 100)
+		hash := h.TestDummy(t, 1)
+
+		opens := 0
+		open := func() (mio.Stream, error) {
+			opens++
+			return chunkbuf.NewChunkBuffer(data), nil
+		}
+
+		read := func() []byte {
+			stream := NewStream(c, hash, open)
+			defer stream.Close()
+
+			out := &bytes.Buffer{}
+			_, err := stream.WriteTo(out)
+			require.Nil(t, err)
+			return out.Bytes()
+		}
+
+		require.Equal(t, data, read())
+		require.Equal(t, 1, opens)
+
+		stats := c.Stats()
+		require.Equal(t, uint64(3), stats.Misses)
+		require.Equal(t, uint64(0), stats.Hits)
+
+		// The second time, everything comes from the cache:
+		require.Equal(t, data, read())
+		require.Equal(t, 1, opens)
+		require.Equal(t, uint64(3), c.Stats().Hits)
+
+		// Seeking relative to the end works without the backend too:
+		stream := NewStream(c, hash, open)
+		pos, err := stream.Seek(-10, io.SeekEnd)
+		require.Nil(t, err)
+		require.Equal(t, int64(len(data)-10), pos)
+
+		tail, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, data[len(data)-10:], tail)
+		require.Equal(t, 1, opens)
+
+		// The cache survives a restart:
+		reopened, err := Open(dir, 10*BlockSize)
+		require.Nil(t, err)
+		require.Equal(t, c.Stats().Size, reopened.Stats().Size)
+	})
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	withCache(t, 2*BlockSize, func(dir string, c *Cache) {
+		block := testutil.CreateDummyBuf(BlockSize)
+		a, b, d := h.TestDummy(t, 1), h.TestDummy(t, 2), h.TestDummy(t, 3)
+
+		c.PutBlock(a, 0, block)
+		c.PutBlock(b, 0, block)
+
+		// Use a, so b is the oldest:
+		_, ok := c.Block(a, 0)
+		require.True(t, ok)
+
+		c.PutBlock(d, 0, block)
+		_, ok = c.Block(b, 0)
+		require.False(t, ok)
+		_, ok = c.Block(a, 0)
+		require.True(t, ok)
+		_, ok = c.Block(d, 0)
+		require.True(t, ok)
+
+		stats := c.Stats()
+		require.Equal(t, 2, stats.Blocks)
+		require.Equal(t, int64(2*BlockSize), stats.Size)
+
+		// Blocks that are bigger than the whole cache are not stored:
+		c.PutBlock(a, 1, testutil.CreateDummyBuf(3*BlockSize))
+		_, ok = c.Block(a, 1)
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,174 @@
+package blockcache
+
+import (
+	"errors"
+	"io"
+
+	"github.com/sahib/brig/catfs/mio"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// stream reads the object `hash` block by block, taking the blocks from
+// the cache if possible. The stream of the backend is only opened once
+// a block is missing.
+type stream struct {
+	cache *Cache
+	hash  h.Hash
+	open  func() (mio.Stream, error)
+
+	under mio.Stream
+	pos   int64
+
+	// size is -1 until known.
+	size int64
+
+	curr      []byte
+	currIndex int64
+}
+
+// NewStream returns a stream of the object `hash` that reads through `cache`.
+// `open` is called to open the object in the backend when a block is not
+// cached; it is called at most once.
+func NewStream(cache *Cache, hash h.Hash, open func() (mio.Stream, error)) mio.Stream {
+	size, ok := cache.Size(hash)
+	if !ok {
+		size = -1
+	}
+
+	return &stream{
+		cache:     cache,
+		hash:      hash,
+		open:      open,
+		size:      size,
+		currIndex: -1,
+	}
+}
+
+func (s *stream) backend() (mio.Stream, error) {
+	if s.under != nil {
+		return s.under, nil
+	}
+
+	under, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	s.under = under
+	return under, nil
+}
+
+func (s *stream) block(index int64) ([]byte, error) {
+	if index == s.currIndex {
+		return s.curr, nil
+	}
+
+	data, ok := s.cache.Block(s.hash, index)
+	if !ok {
+		under, err := s.backend()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := under.Seek(index*BlockSize, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		data = make([]byte, BlockSize)
+		n, err := io.ReadFull(under, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		data = data[:n]
+		if n < BlockSize {
+			s.setSize(index*BlockSize + int64(n))
+		}
+
+		if n > 0 {
+			s.cache.PutBlock(s.hash, index, data)
+		}
+	}
+
+	s.curr, s.currIndex = data, index
+	return data, nil
+}
+
+func (s *stream) setSize(size int64) {
+	if s.size < 0 {
+		s.size = size
+		s.cache.PutSize(s.hash, size)
+	}
+}
+
+func (s *stream) Read(buf []byte) (int, error) {
+	if s.size >= 0 && s.pos >= s.size {
+		return 0, io.EOF
+	}
+
+	index := s.pos / BlockSize
+	data, err := s.block(index)
+	if err != nil {
+		return 0, err
+	}
+
+	off := s.pos - index*BlockSize
+	if off >= int64(len(data)) {
+		// A short or empty block marks the end.
+		return 0, io.EOF
+	}
+
+	n := copy(buf, data[off:])
+	s.pos += int64(n)
+	return n, nil
+}
+
+func (s *stream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		if s.size < 0 {
+			under, err := s.backend()
+			if err != nil {
+				return 0, err
+			}
+
+			size, err := under.Seek(0, io.SeekEnd)
+			if err != nil {
+				return 0, err
+			}
+
+			s.setSize(size)
+		}
+
+		offset += s.size
+	default:
+		return 0, errors.New("blockcache: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("blockcache: negative position")
+	}
+
+	s.pos = offset
+	return s.pos, nil
+}
+
+// readOnly hides WriteTo of the stream, so io.Copy does not call it again.
+type readOnly struct {
+	io.Reader
+}
+
+func (s *stream) WriteTo(w io.Writer) (int64, error) {
+	return io.CopyBuffer(w, readOnly{s}, make([]byte, BlockSize))
+}
+
+func (s *stream) Close() error {
+	if s.under == nil {
+		return nil
+	}
+
+	return s.under.Close()
+}
@@ -0,0 +1,214 @@
+package catfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+const (
+	// DiffBlockSize is the size of the blocks compared by BlockDiff.
+	DiffBlockSize = 64 * 1024
+
+	// blockSumSize is how many bytes of each block's checksum are kept.
+	// Collisions are unlikely enough for telling apart versions of a file.
+	blockSumSize = 16
+)
+
+// ByteRange is a continuous range of bytes in a file.
+type ByteRange struct {
+	Offset uint64
+	Length uint64
+}
+
+// BlockDiff describes which parts of a file changed between two versions.
+// Blocks of the new version that can be found anywhere in the old one
+// (for example because something was inserted before them) count as
+// unchanged, since they do not need to be fetched again.
+type BlockDiff struct {
+	OldPath   string
+	NewPath   string
+	OldSize   uint64
+	NewSize   uint64
+	BlockSize uint64
+
+	// Changed are the ranges of the new version that are not part of
+	// the old version. Neighbouring blocks are merged into one range.
+	Changed []ByteRange
+
+	ChangedBytes   uint64
+	UnchangedBytes uint64
+}
+
+// UnchangedRatio returns how much of the new version (between 0 and 1)
+// was already part of the old one.
+func (bd *BlockDiff) UnchangedRatio() float64 {
+	if bd.NewSize == 0 {
+		return 1
+	}
+
+	return float64(bd.UnchangedBytes) / float64(bd.NewSize)
+}
+
+type blockDiffSide struct {
+	path        string
+	size        uint64
+	contentHash h.Hash
+	backendHash h.Hash
+	key         []byte
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) blockDiffSide(rev, path string) (*blockDiffSide, error) {
+	path = fs.normPath(path)
+	nd, err := fs.lookupNodeAt(rev, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := nd.(*n.File)
+	if !ok {
+		return nil, ie.ErrBadNode
+	}
+
+	key := make([]byte, len(file.Key()))
+	copy(key, file.Key())
+
+	return &blockDiffSide{
+		path:        file.Path(),
+		size:        file.Size(),
+		contentHash: file.ContentHash().Clone(),
+		backendHash: file.BackendHash().Clone(),
+		key:         key,
+	}, nil
+}
+
+// blockSums returns the checksums of all blocks of `side`.
+// They are computed once per content and cached in the metadata store.
+// NOTE: This method can be called without locking fs.mu!
+func (fs *FS) blockSums(side *blockDiffSide) ([]byte, error) {
+	cacheKey := []string{"blocksums", fmt.Sprintf("%d", DiffBlockSize), side.contentHash.B58String()}
+	sums, err := fs.kv.Get(cacheKey...)
+	if err == nil {
+		return sums, nil
+	}
+
+	if err != db.ErrNoSuchKey {
+		return nil, err
+	}
+
+	stream, err := fs.catHash(side.backendHash, side.key, side.size)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stream.Close()
+
+	sums = []byte{}
+	buf := make([]byte, DiffBlockSize)
+	for {
+		n, err := io.ReadFull(stream, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			sums = append(sums, sum[:blockSumSize]...)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	batch := fs.kv.Batch()
+	batch.Put(sums, cacheKey...)
+	return sums, batch.Flush()
+}
+
+// BlockDiff compares the version of `oldPath` at `oldRev` with the version
+// of `newPath` at `newRev` block by block. The paths are usually the same,
+// but may differ if the file was moved in between.
+func (fs *FS) BlockDiff(oldRev, oldPath, newRev, newPath string) (*BlockDiff, error) {
+	fs.mu.Lock()
+	oldSide, err := fs.blockDiffSide(oldRev, oldPath)
+	if err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+
+	newSide, err := fs.blockDiffSide(newRev, newPath)
+	fs.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BlockDiff{
+		OldPath:   oldSide.path,
+		NewPath:   newSide.path,
+		OldSize:   oldSide.size,
+		NewSize:   newSide.size,
+		BlockSize: DiffBlockSize,
+		Changed:   []ByteRange{},
+	}
+
+	// No need to read anything if nothing changed:
+	if oldSide.contentHash.Equal(newSide.contentHash) {
+		diff.UnchangedBytes = newSide.size
+		return diff, nil
+	}
+
+	oldSums, err := fs.blockSums(oldSide)
+	if err != nil {
+		return nil, err
+	}
+
+	newSums, err := fs.blockSums(newSide)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for off := 0; off+blockSumSize <= len(oldSums); off += blockSumSize {
+		known[string(oldSums[off:off+blockSumSize])] = true
+	}
+
+	for idx := 0; (idx+1)*blockSumSize <= len(newSums); idx++ {
+		sum := newSums[idx*blockSumSize : (idx+1)*blockSumSize]
+
+		offset := uint64(idx) * DiffBlockSize
+		length := uint64(DiffBlockSize)
+		if offset+length > newSide.size {
+			length = newSide.size - offset
+		}
+
+		// Blocks at the same place are the common case; check them first.
+		samePlace := (idx+1)*blockSumSize <= len(oldSums) &&
+			bytes.Equal(sum, oldSums[idx*blockSumSize:(idx+1)*blockSumSize])
+
+		if samePlace || known[string(sum)] {
+			diff.UnchangedBytes += length
+			continue
+		}
+
+		diff.ChangedBytes += length
+		if last := len(diff.Changed) - 1; last >= 0 && diff.Changed[last].Offset+diff.Changed[last].Length == offset {
+			diff.Changed[last].Length += length
+			continue
+		}
+
+		diff.Changed = append(diff.Changed, ByteRange{Offset: offset, Length: length})
+	}
+
+	return diff, nil
+}
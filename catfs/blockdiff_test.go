@@ -0,0 +1,52 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockDiff(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		oldData := testutil.CreateRandomDummyBuf(4*DiffBlockSize, 23)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader(oldData)))
+		require.Nil(t, fs.MakeCommit("old"))
+
+		// Change a single byte in the third block and append half a block:
+		newData := append([]byte{}, oldData...)
+		newData[2*DiffBlockSize+10]++
+		newData = append(newData, make([]byte, DiffBlockSize/2)...)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader(newData)))
+		require.Nil(t, fs.MakeCommit("new"))
+
+		diff, err := fs.BlockDiff("head^", "/x", "head", "/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(len(oldData)), diff.OldSize)
+		require.Equal(t, uint64(len(newData)), diff.NewSize)
+		require.Equal(t, []ByteRange{
+			{Offset: 2 * DiffBlockSize, Length: DiffBlockSize},
+			{Offset: 4 * DiffBlockSize, Length: DiffBlockSize / 2},
+		}, diff.Changed)
+		require.Equal(t, uint64(3*DiffBlockSize), diff.UnchangedBytes)
+		require.Equal(t, uint64(DiffBlockSize+DiffBlockSize/2), diff.ChangedBytes)
+		require.InDelta(t, 3.0/4.5, diff.UnchangedRatio(), 0.001)
+
+		// Comparing a version with itself should not find anything:
+		diff, err = fs.BlockDiff("head", "/x", "head", "/x")
+		require.Nil(t, err)
+		require.Empty(t, diff.Changed)
+		require.Equal(t, 1.0, diff.UnchangedRatio())
+
+		// Blocks that only moved are not changed:
+		moved := append(append([]byte{}, oldData[DiffBlockSize:2*DiffBlockSize]...), oldData[:DiffBlockSize]...)
+		require.Nil(t, fs.Stage("/y", bytes.NewReader(moved)))
+		diff, err = fs.BlockDiff("head^", "/x", "curr", "/y")
+		require.Nil(t, err)
+		require.Empty(t, diff.Changed)
+
+		_, err = fs.BlockDiff("head", "/", "head", "/x")
+		require.NotNil(t, err)
+	})
+}
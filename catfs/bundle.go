@@ -0,0 +1,394 @@
+package catfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	e "github.com/pkg/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/sahib/brig/catfs/vcs"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// A bundle carries the changes between two commits together with the
+// content they need, so they can be moved without a network connection
+// (e.g. via mail or an usb stick) and applied like a patch from a sync.
+//
+// The file format is kept simple: after the magic and the version follow
+// length prefixed fields (see Marshal). The signature covers everything
+// before it. Content is stored as the raw blocks of the backend, so the
+// receiving side can store them under the very same hash.
+
+// bundleMagic is the first bytes of every bundle.
+const bundleMagic = "brig-bundle\n"
+
+// bundleVersion is the version of the format written by Marshal.
+const bundleVersion = 1
+
+var (
+	// ErrBadBundle is returned when the bundle could not be parsed.
+	ErrBadBundle = errors.New("not a valid bundle")
+
+	// ErrUnsignedBundle is returned by Verify when there is no signature.
+	ErrUnsignedBundle = errors.New("bundle is not signed")
+)
+
+// ErrBundleGap is returned by ApplyBundle when the bundle starts after the
+// last change we know of. Applying it would lose the changes in between.
+type ErrBundleGap struct {
+	FromIndex int64
+	LastIndex int64
+}
+
+func (eg ErrBundleGap) Error() string {
+	return fmt.Sprintf(
+		"bundle starts at commit[%d], but only changes up to commit[%d] are known; create one starting earlier",
+		eg.FromIndex,
+		eg.LastIndex,
+	)
+}
+
+// Bundle is a patch together with the content blocks of all files
+// that were added or modified by it.
+type Bundle struct {
+	// Owner is the name of the user that created the bundle.
+	Owner string
+
+	// FromIndex and ToIndex are the commit indices the bundle spans.
+	FromIndex int64
+	ToIndex   int64
+
+	// Patch is the binary patch as produced by MakePatch.
+	Patch []byte
+
+	// Blocks are the raw backend blocks of the content.
+	Blocks [][]byte
+
+	// Signature is made over all of the above by Sign.
+	Signature []byte
+}
+
+func appendBundleField(buf []byte, data []byte) []byte {
+	buf = appendBundleUint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendBundleUint(buf []byte, val uint64) []byte {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	return append(buf, tmp[:binary.PutUvarint(tmp, val)]...)
+}
+
+func appendBundleInt(buf []byte, val int64) []byte {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	return append(buf, tmp[:binary.PutVarint(tmp, val)]...)
+}
+
+// payload is the part of the bundle that is signed.
+func (b *Bundle) payload() []byte {
+	buf := []byte(bundleMagic)
+	buf = appendBundleUint(buf, bundleVersion)
+	buf = appendBundleField(buf, []byte(b.Owner))
+	buf = appendBundleInt(buf, b.FromIndex)
+	buf = appendBundleInt(buf, b.ToIndex)
+	buf = appendBundleField(buf, b.Patch)
+	buf = appendBundleUint(buf, uint64(len(b.Blocks)))
+	for _, block := range b.Blocks {
+		buf = appendBundleField(buf, block)
+	}
+
+	return buf
+}
+
+// Sign signs the bundle with `sign`, which gets the data to sign.
+func (b *Bundle) Sign(sign func(data []byte) ([]byte, error)) error {
+	sig, err := sign(b.payload())
+	if err != nil {
+		return err
+	}
+
+	b.Signature = sig
+	return nil
+}
+
+// Verify checks the signature of the bundle with `verify`.
+func (b *Bundle) Verify(verify func(data, sig []byte) error) error {
+	if len(b.Signature) == 0 {
+		return ErrUnsignedBundle
+	}
+
+	return verify(b.payload(), b.Signature)
+}
+
+// Marshal returns the binary representation of the bundle.
+func (b *Bundle) Marshal() []byte {
+	return appendBundleField(b.payload(), b.Signature)
+}
+
+// bundleReader reads the fields written by Marshal.
+type bundleReader struct {
+	r *bytes.Reader
+}
+
+func (br *bundleReader) uint() (uint64, error) {
+	val, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		return 0, ErrBadBundle
+	}
+
+	return val, nil
+}
+
+func (br *bundleReader) int() (int64, error) {
+	val, err := binary.ReadVarint(br.r)
+	if err != nil {
+		return 0, ErrBadBundle
+	}
+
+	return val, nil
+}
+
+func (br *bundleReader) field() ([]byte, error) {
+	size, err := br.uint()
+	if err != nil {
+		return nil, err
+	}
+
+	if size > uint64(br.r.Len()) {
+		return nil, ErrBadBundle
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br.r, data); err != nil {
+		return nil, ErrBadBundle
+	}
+
+	return data, nil
+}
+
+// UnmarshalBundle parses a bundle written by Marshal.
+// The signature is not checked, use Verify for that.
+func UnmarshalBundle(data []byte) (*Bundle, error) {
+	if !bytes.HasPrefix(data, []byte(bundleMagic)) {
+		return nil, ErrBadBundle
+	}
+
+	br := &bundleReader{r: bytes.NewReader(data[len(bundleMagic):])}
+	version, err := br.uint()
+	if err != nil {
+		return nil, err
+	}
+
+	if version != bundleVersion {
+		return nil, e.Errorf("unsupported bundle version: %d", version)
+	}
+
+	b := &Bundle{}
+	owner, err := br.field()
+	if err != nil {
+		return nil, err
+	}
+
+	b.Owner = string(owner)
+	if b.FromIndex, err = br.int(); err != nil {
+		return nil, err
+	}
+
+	if b.ToIndex, err = br.int(); err != nil {
+		return nil, err
+	}
+
+	if b.Patch, err = br.field(); err != nil {
+		return nil, err
+	}
+
+	nBlocks, err := br.uint()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := uint64(0); idx < nBlocks; idx++ {
+		block, err := br.field()
+		if err != nil {
+			return nil, err
+		}
+
+		b.Blocks = append(b.Blocks, block)
+	}
+
+	if b.Signature, err = br.field(); err != nil {
+		return nil, err
+	}
+
+	if br.r.Len() != 0 {
+		return nil, ErrBadBundle
+	}
+
+	return b, nil
+}
+
+// collectBlocks appends the blocks of `hash` and all blocks it links to.
+// Blocks that were already seen are skipped.
+func collectBlocks(bbk BlockBackend, hash h.Hash, seen map[string]bool, blocks [][]byte) ([][]byte, error) {
+	if seen[hash.B58String()] {
+		return blocks, nil
+	}
+
+	seen[hash.B58String()] = true
+
+	isLocal, err := bbk.HasBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isLocal {
+		return nil, e.Errorf("block %s is not available locally", hash.B58String())
+	}
+
+	block, err := bbk.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks = append(blocks, block)
+
+	links, err := bbk.BlockLinks(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		if blocks, err = collectBlocks(bbk, link, seen, blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	return blocks, nil
+}
+
+// MakeBundle creates a bundle with all changes between `fromRev` and `toRev`.
+// The content of all added or modified files needs to be available locally.
+// The bundle is not signed yet.
+func (fs *FS) MakeBundle(fromRev, toRev string) (*Bundle, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	bbk, ok := fs.bk.(BlockBackend)
+	if !ok {
+		return nil, e.New("backend does not support exporting blocks")
+	}
+
+	from, err := parseRev(fs.lkr, fromRev)
+	if err != nil {
+		return nil, e.Wrapf(err, "from")
+	}
+
+	to, err := parseRev(fs.lkr, toRev)
+	if err != nil {
+		return nil, e.Wrapf(err, "to")
+	}
+
+	patch, err := vcs.MakePatchRange(fs.lkr, from, to, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	blocks := [][]byte{}
+	for _, change := range patch.Changes {
+		if change.Mask&(vcs.ChangeTypeAdd|vcs.ChangeTypeModify) == 0 {
+			continue
+		}
+
+		file, ok := change.Curr.(*n.File)
+		if !ok {
+			continue
+		}
+
+		blocks, err = collectBlocks(bbk, file.BackendHash(), seen, blocks)
+		if err != nil {
+			return nil, e.Wrapf(err, "content of %s", file.Path())
+		}
+	}
+
+	if patch.Dicts, err = fs.dicts.all(); err != nil {
+		return nil, err
+	}
+
+	msg, err := patch.ToCapnp()
+	if err != nil {
+		return nil, err
+	}
+
+	patchData, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Owner:     owner,
+		FromIndex: from.Index(),
+		ToIndex:   to.Index(),
+		Patch:     patchData,
+		Blocks:    blocks,
+	}, nil
+}
+
+// ApplyBundle stores the content of `bundle` in the backend and applies
+// its changes, just like ApplyPatch does. It should be called on the
+// filesystem of the bundle's owner. The signature is not checked here.
+func (fs *FS) ApplyBundle(bundle *Bundle) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	if owner != bundle.Owner {
+		return e.Errorf("bundle was made by %s, not by %s", bundle.Owner, owner)
+	}
+
+	lastIndex, err := fs.lastPatchIndex()
+	if err != nil {
+		return err
+	}
+
+	// Nothing new in there; applying it again would only reset the index.
+	if bundle.ToIndex <= lastIndex {
+		log.Debugf("bundle up to commit[%d] was already applied", bundle.ToIndex)
+		return nil
+	}
+
+	if bundle.FromIndex > lastIndex {
+		return ErrBundleGap{FromIndex: bundle.FromIndex, LastIndex: lastIndex}
+	}
+
+	// Check that the patch is readable before storing anything:
+	if _, err := capnp.Unmarshal(bundle.Patch); err != nil {
+		return ErrBadBundle
+	}
+
+	bbk, ok := fs.bk.(BlockBackend)
+	if !ok {
+		return e.New("backend does not support importing blocks")
+	}
+
+	for _, block := range bundle.Blocks {
+		if _, err := bbk.PutBlock(block); err != nil {
+			return err
+		}
+	}
+
+	return fs.applyPatch(bundle.Patch)
+}
@@ -0,0 +1,80 @@
+package catfs
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleRoundtrip(t *testing.T) {
+	withDummyFS(t, func(srcFs *FS) {
+		withDummyFS(t, func(dstFs *FS) {
+			require.Nil(t, srcFs.MakeCommit("init"))
+			require.Nil(t, srcFs.Stage("/x", bytes.NewReader([]byte("hello"))))
+			require.Nil(t, srcFs.MakeCommit("added x"))
+			require.Nil(t, srcFs.Stage("/y", bytes.NewReader([]byte("world"))))
+			require.Nil(t, srcFs.MakeCommit("added y"))
+
+			// Only the first change should be in there:
+			bundle, err := srcFs.MakeBundle("commit[0]", "HEAD^")
+			require.Nil(t, err)
+			require.Len(t, bundle.Blocks, 1)
+
+			sign := func(data []byte) ([]byte, error) {
+				return append([]byte("sig:"), data[:8]...), nil
+			}
+
+			verify := func(data, sig []byte) error {
+				if !bytes.Equal(sig, append([]byte("sig:"), data[:8]...)) {
+					return errors.New("bad signature")
+				}
+
+				return nil
+			}
+
+			require.Equal(t, ErrUnsignedBundle, bundle.Verify(verify))
+			require.Nil(t, bundle.Sign(sign))
+
+			parsed, err := UnmarshalBundle(bundle.Marshal())
+			require.Nil(t, err)
+			require.Nil(t, parsed.Verify(verify))
+			require.Equal(t, bundle, parsed)
+
+			_, err = UnmarshalBundle(bundle.Marshal()[:100])
+			require.Equal(t, ErrBadBundle, err)
+
+			require.Nil(t, dstFs.ApplyBundle(parsed))
+
+			stream, err := dstFs.Cat("/x")
+			require.Nil(t, err)
+
+			data, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, []byte("hello"), data)
+
+			_, err = dstFs.Stat("/y")
+			require.NotNil(t, err)
+
+			// The next bundle starts after a gap and may not be applied:
+			bundle, err = srcFs.MakeBundle("HEAD", "HEAD")
+			require.Nil(t, err)
+			bundle.ToIndex++
+			require.IsType(t, ErrBundleGap{}, dstFs.ApplyBundle(bundle))
+
+			// Continuing where the last one stopped works:
+			bundle, err = srcFs.MakeBundle("HEAD^", "HEAD")
+			require.Nil(t, err)
+			require.Nil(t, dstFs.ApplyBundle(bundle))
+
+			dstY, err := dstFs.Stat("/y")
+			require.Nil(t, err)
+
+			srcY, err := srcFs.Stat("/y")
+			require.Nil(t, err)
+			require.Equal(t, srcY.BackendHash, dstY.BackendHash)
+		})
+	})
+}
@@ -141,6 +141,16 @@ func Remove(lkr *Linker, nd n.ModNode, createGhost, force bool) (parentDir *n.Di
 	}
 
 	err = lkr.Atomic(func() (bool, error) {
+		// The ghost has to be made while `nd` still knows its parent,
+		// since RemoveChild() unsets it and the ghost would lose its path.
+		var newGhost *n.Ghost
+		if createGhost {
+			var err error
+			if newGhost, err = n.MakeGhost(nd, lkr.NextInode()); err != nil {
+				return true, err
+			}
+		}
+
 		if err := parentDir.RemoveChild(lkr, nd); err != nil {
 			return true, fmt.Errorf("failed to remove child: %v", err)
 		}
@@ -152,11 +162,6 @@ func Remove(lkr *Linker, nd n.ModNode, createGhost, force bool) (parentDir *n.Di
 		}
 
 		if createGhost {
-			newGhost, err := n.MakeGhost(nd, lkr.NextInode())
-			if err != nil {
-				return true, err
-			}
-
 			if err := parentDir.Add(lkr, newGhost); err != nil {
 				return true, err
 			}
@@ -177,6 +182,12 @@ func Remove(lkr *Linker, nd n.ModNode, createGhost, force bool) (parentDir *n.Di
 
 // prepareParent tries to figure out the correct parent directory when attempting
 // to move `nd` to `dstPath`. It also removes any nodes that are "in the way" if possible.
+// isInsideDir returns true if `p` is `dir` or somewhere below it.
+// A plain prefix check would also match siblings like /b and /backup.
+func isInsideDir(p, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
 func prepareParent(lkr *Linker, nd n.ModNode, dstPath string) (*n.Directory, error) {
 	// Check if the destination already exists:
 	destNode, err := lkr.LookupModNode(dstPath)
@@ -256,7 +267,7 @@ func Copy(lkr *Linker, nd n.ModNode, dstPath string) (newNode n.ModNode, err err
 		return
 	}
 
-	if strings.HasPrefix(path.Dir(dstPath), nd.Path()) {
+	if isInsideDir(path.Dir(dstPath), nd.Path()) {
 		err = fmt.Errorf(
 			"cannot copy `%s` into it's own subdir `%s`",
 			nd.Path(),
@@ -307,7 +318,7 @@ func Move(lkr *Linker, nd n.ModNode, dstPath string) error {
 		return fmt.Errorf("Source and Dest are the same file: %v", dstPath)
 	}
 
-	if strings.HasPrefix(path.Dir(dstPath), nd.Path()) {
+	if isInsideDir(path.Dir(dstPath), nd.Path()) {
 		return fmt.Errorf(
 			"Cannot move `%s` into it's own subdir `%s`",
 			nd.Path(),
@@ -356,11 +367,16 @@ func Move(lkr *Linker, nd n.ModNode, dstPath string) error {
 
 // StageFromFileNode is a convinience helper that will call Stage() with all necessary params from `f`.
 func StageFromFileNode(lkr *Linker, f *n.File) (*n.File, error) {
-	return Stage(lkr, f.Path(), f.ContentHash(), f.BackendHash(), f.Size(), f.Key())
+	return StageWithMimeType(lkr, f.Path(), f.ContentHash(), f.BackendHash(), f.Size(), f.Key(), f.MimeType())
+}
+
+// Stage adds a file to brigs DAG. The type of its content is not known.
+func Stage(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size uint64, key []byte) (*n.File, error) {
+	return StageWithMimeType(lkr, repoPath, contentHash, backendHash, size, key, "")
 }
 
-// Stage adds a file to brigs DAG.
-func Stage(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size uint64, key []byte) (file *n.File, err error) {
+// StageWithMimeType is like Stage, but also records the type of the content.
+func StageWithMimeType(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size uint64, key []byte, mimeType string) (file *n.File, err error) {
 	node, lerr := lkr.LookupNode(repoPath)
 	if lerr != nil && !ie.IsNoSuchFileError(lerr) {
 		err = lerr
@@ -439,6 +455,7 @@ func Stage(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size u
 		file.SetContent(lkr, contentHash)
 		file.SetBackend(lkr, backendHash)
 		file.SetKey(key)
+		file.SetMimeType(mimeType)
 		file.SetUser(lkr.owner)
 
 		// Add it again when the hash was changed.
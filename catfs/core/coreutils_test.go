@@ -432,6 +432,42 @@ func TestMoveDirectory(t *testing.T) {
 	})
 }
 
+func TestMoveNestedDirectory(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		MustMkdir(t, lkr, "/a")
+		MustMkdir(t, lkr, "/a/src")
+		MustMkdir(t, lkr, "/a/src/sub")
+		MustMkdir(t, lkr, "/b")
+		MustTouch(t, lkr, "/a/src/sub/x", 23)
+		MustTouch(t, lkr, "/a/src/y", 42)
+
+		// Moving a directory that is not directly below the root
+		// used to derive the wrong paths for its children.
+		srcDir := MustLookupDirectory(t, lkr, "/a/src")
+		MustMove(t, lkr, srcDir, "/b/dst")
+		lkr.MemIndexClear()
+
+		for _, nodePath := range []string{"/b/dst", "/b/dst/sub", "/b/dst/sub/x", "/b/dst/y"} {
+			nd, err := lkr.LookupNode(nodePath)
+			require.Nil(t, err, nodePath)
+			require.Equal(t, nodePath, nd.Path())
+		}
+
+		// Both parents should have their size updated:
+		require.Equal(t, uint64(0), MustLookupDirectory(t, lkr, "/a").Size())
+		require.Equal(t, srcDir.Size(), MustLookupDirectory(t, lkr, "/b").Size())
+
+		// Moving it again should work from the new place:
+		subDir := MustLookupDirectory(t, lkr, "/b/dst/sub")
+		MustMove(t, lkr, subDir, "/sub")
+		lkr.MemIndexClear()
+
+		nd, err := lkr.LookupNode("/sub/x")
+		require.Nil(t, err)
+		require.Equal(t, "/sub/x", nd.Path())
+	})
+}
+
 func TestMoveDirectoryWithGhosts(t *testing.T) {
 	WithDummyLinker(t, func(lkr *Linker) {
 		srcDir := MustMkdir(t, lkr, "/src")
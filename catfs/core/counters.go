@@ -0,0 +1,154 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/sahib/brig/catfs/db"
+)
+
+// Counter is a grow-only counter (a G-Counter in CRDT terms).
+// Every replica only increments its own slot, so two counters
+// can always be merged by taking the maximum of every slot,
+// no matter in which order or how often peers sync.
+type Counter map[string]uint64
+
+// Value returns the total count over all replicas.
+func (c Counter) Value() uint64 {
+	sum := uint64(0)
+	for _, count := range c {
+		sum += count
+	}
+
+	return sum
+}
+
+// Merge adds the counts of `other` to `c`.
+// It returns true if `c` was changed.
+func (c Counter) Merge(other Counter) bool {
+	changed := false
+	for replica, count := range other {
+		if count > c[replica] {
+			c[replica] = count
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Counters maps the name of a counter (e.g. "downloads") to its counter.
+type Counters map[string]Counter
+
+// counterKey returns the key where the counters of `nodePath` are stored.
+// The path is encoded, since the stores split keys on dots or slashes.
+func counterKey(nodePath string) []string {
+	return []string{"counters", base64.RawURLEncoding.EncodeToString([]byte(nodePath))}
+}
+
+// Counters returns all counters of the node at `nodePath`.
+// Counters are stored by path, not as part of the node, so counting
+// does not change the node or create anything that needs to be committed.
+func (lkr *Linker) Counters(nodePath string) (Counters, error) {
+	counters := Counters{}
+	data, err := lkr.kv.Get(counterKey(nodePath)...)
+	if err == db.ErrNoSuchKey {
+		return counters, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, err
+	}
+
+	return counters, nil
+}
+
+// CounterAdd increments the counter `name` of `nodePath` by `delta`
+// in the slot of our own owner.
+func (lkr *Linker) CounterAdd(nodePath, name string, delta uint64) error {
+	owner, err := lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		counters, err := lkr.Counters(nodePath)
+		if err != nil {
+			return true, err
+		}
+
+		if counters[name] == nil {
+			counters[name] = Counter{}
+		}
+
+		counters[name][owner] += delta
+		return lkr.putCounters(batch, nodePath, counters)
+	})
+}
+
+func (lkr *Linker) putCounters(batch db.Batch, nodePath string, counters Counters) (bool, error) {
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return true, err
+	}
+
+	batch.Put(data, counterKey(nodePath)...)
+	return false, nil
+}
+
+// MergeCounters merges all counters of `remote` into the ones of `lkr`.
+func (lkr *Linker) MergeCounters(remote *Linker) error {
+	keys, err := remote.kv.Keys("counters")
+	if err != nil {
+		return err
+	}
+
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		for _, key := range keys {
+			if len(key) != 2 {
+				continue
+			}
+
+			rawPath, err := base64.RawURLEncoding.DecodeString(key[1])
+			if err != nil {
+				continue
+			}
+
+			nodePath := string(rawPath)
+			remoteCounters, err := remote.Counters(nodePath)
+			if err != nil {
+				return true, err
+			}
+
+			ownCounters, err := lkr.Counters(nodePath)
+			if err != nil {
+				return true, err
+			}
+
+			changed := false
+			for name, counter := range remoteCounters {
+				if ownCounters[name] == nil {
+					ownCounters[name] = Counter{}
+				}
+
+				if ownCounters[name].Merge(counter) {
+					changed = true
+				}
+			}
+
+			if !changed {
+				continue
+			}
+
+			if hasError, err := lkr.putCounters(batch, nodePath, ownCounters); err != nil {
+				return hasError, err
+			}
+		}
+
+		return false, nil
+	})
+}
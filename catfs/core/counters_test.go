@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterMerge(t *testing.T) {
+	a := Counter{"ali": 3, "bob": 1}
+	b := Counter{"bob": 2, "cem": 1}
+
+	require.True(t, a.Merge(b))
+	require.Equal(t, Counter{"ali": 3, "bob": 2, "cem": 1}, a)
+	require.Equal(t, uint64(6), a.Value())
+
+	// Merging again (or merging older state) changes nothing:
+	require.False(t, a.Merge(b))
+	require.False(t, a.Merge(Counter{"ali": 1}))
+	require.Equal(t, uint64(6), a.Value())
+}
+
+func TestLinkerCounters(t *testing.T) {
+	WithLinkerPair(t, func(lkrSrc, lkrDst *Linker) {
+		require.Nil(t, lkrSrc.CounterAdd("/x.png", "views", 1))
+		require.Nil(t, lkrSrc.CounterAdd("/x.png", "views", 1))
+		require.Nil(t, lkrDst.CounterAdd("/x.png", "views", 1))
+		require.Nil(t, lkrDst.CounterAdd("/x.png", "downloads", 1))
+
+		require.Nil(t, lkrDst.MergeCounters(lkrSrc))
+		require.Nil(t, lkrSrc.MergeCounters(lkrDst))
+
+		for _, lkr := range []*Linker{lkrSrc, lkrDst} {
+			counters, err := lkr.Counters("/x.png")
+			require.Nil(t, err)
+			require.Equal(t, uint64(3), counters["views"].Value())
+			require.Equal(t, uint64(1), counters["downloads"].Value())
+		}
+
+		// Syncing twice must not count anything twice:
+		require.Nil(t, lkrDst.MergeCounters(lkrSrc))
+		counters, err := lkrDst.Counters("/x.png")
+		require.Nil(t, err)
+		require.Equal(t, uint64(3), counters["views"].Value())
+
+		counters, err = lkrDst.Counters("/nothing")
+		require.Nil(t, err)
+		require.Empty(t, counters)
+	})
+}
@@ -16,6 +16,7 @@ type GarbageCollector struct {
 	kv       db.Database
 	notifier func(nd n.Node) bool
 	markMap  map[string]struct{}
+	shardMap map[string]struct{}
 }
 
 // NewGarbageCollector will return a new GC, operating on `lkr` and `kv`.
@@ -46,13 +47,24 @@ func (gc *GarbageCollector) markMoveMap(key []string) error {
 		}
 
 		if node != nil {
-			gc.markMap[node.TreeHash().B58String()] = struct{}{}
+			gc.markNode(node)
 		}
 	}
 
 	return nil
 }
 
+func (gc *GarbageCollector) markNode(nd n.Node) {
+	gc.markMap[nd.TreeHash().B58String()] = struct{}{}
+	gc.markShards(nd)
+}
+
+func (gc *GarbageCollector) markShards(nd n.Node) {
+	for _, shard := range n.ShardHashes(nd) {
+		gc.shardMap[shard.B58String()] = struct{}{}
+	}
+}
+
 func (gc *GarbageCollector) mark(cmt *n.Commit, recursive bool) error {
 	if cmt == nil {
 		return nil
@@ -65,7 +77,7 @@ func (gc *GarbageCollector) mark(cmt *n.Commit, recursive bool) error {
 
 	gc.markMap[cmt.TreeHash().B58String()] = struct{}{}
 	err = n.Walk(gc.lkr, root, true, func(child n.Node) error {
-		gc.markMap[child.TreeHash().B58String()] = struct{}{}
+		gc.markNode(child)
 		return nil
 	})
 
@@ -122,12 +134,22 @@ func (gc *GarbageCollector) sweep(prefix []string) (int, error) {
 			// Allow the gc caller to check if he really
 			// wants to delete this node.
 			if gc.notifier != nil && !gc.notifier(node) {
+				gc.markShards(node)
 				continue
 			}
 
 			// Actually get rid of the node:
 			gc.lkr.MemIndexPurge(node)
 
+			scope := contentRefsObjects
+			if prefix[0] == "stage" {
+				scope = contentRefsStage
+			}
+
+			if err := gc.lkr.removeContentRef(batch, scope, node); err != nil {
+				return hintRollback(err)
+			}
+
 			batch.Erase(key...)
 			removed++
 		}
@@ -136,6 +158,31 @@ func (gc *GarbageCollector) sweep(prefix []string) (int, error) {
 	})
 }
 
+// sweepShards removes all directory shards that were not marked.
+// This is only safe after marking all objects, since shards are shared.
+func (gc *GarbageCollector) sweepShards() (int, error) {
+	removed := 0
+	err := gc.lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		keys, err := gc.kv.Keys("shards")
+		if err != nil {
+			return hintRollback(err)
+		}
+
+		for _, key := range keys {
+			if _, ok := gc.shardMap[key[len(key)-1]]; ok {
+				continue
+			}
+
+			batch.Erase(key...)
+			removed++
+		}
+
+		return false, nil
+	})
+
+	return removed, err
+}
+
 func (gc *GarbageCollector) findAllMoveLocations(head *n.Commit) ([][]string, error) {
 	locations := [][]string{
 		{"stage", "moves"},
@@ -169,6 +216,7 @@ func (gc *GarbageCollector) findAllMoveLocations(head *n.Commit) ([][]string, er
 // all objects in the key value store.
 func (gc *GarbageCollector) Run(allObjects bool) error {
 	gc.markMap = make(map[string]struct{})
+	gc.shardMap = make(map[string]struct{})
 	head, err := gc.lkr.Status()
 	if err != nil {
 		return err
@@ -213,6 +261,13 @@ func (gc *GarbageCollector) Run(allObjects bool) error {
 			log.Warningf("removed %d unreachable permanent objects.", removed)
 			log.Warningf("this might indiciate a bug in catfs somewhere.")
 		}
+
+		removed, err = gc.sweepShards()
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("removed %d unused directory shards.", removed)
 	}
 
 	return nil
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/sahib/brig/catfs/db"
+)
+
+// Hold keeps a node (and everything below it, if it is a directory) from
+// being removed, either by the user or by a sync, and its content from
+// being unpinned until the hold is removed again. Holds are local to a
+// repository and are not exchanged with other peers.
+type Hold struct {
+	// Path of the held node.
+	Path string
+	// Created is when the hold was put in place.
+	Created time.Time
+	// Owner is who put the hold in place.
+	Owner string
+	// Reason is a free form note why the node is held.
+	Reason string
+}
+
+type holdJSON struct {
+	Path    string    `json:"path"`
+	Created time.Time `json:"created"`
+	Owner   string    `json:"owner"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+func holdKey(nodePath string) []string {
+	return []string{"holds", base64.RawURLEncoding.EncodeToString([]byte(nodePath))}
+}
+
+// Hold returns the hold for `nodePath` or nil if there is none.
+func (lkr *Linker) Hold(nodePath string) (*Hold, error) {
+	data, err := lkr.kv.Get(holdKey(nodePath)...)
+	if err == db.ErrNoSuchKey {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	hj := holdJSON{}
+	if err := json.Unmarshal(data, &hj); err != nil {
+		return nil, err
+	}
+
+	return &Hold{
+		Path:    hj.Path,
+		Created: hj.Created,
+		Owner:   hj.Owner,
+		Reason:  hj.Reason,
+	}, nil
+}
+
+// Holds returns all holds of `lkr`.
+func (lkr *Linker) Holds() ([]*Hold, error) {
+	keys, err := lkr.kv.Keys("holds")
+	if err != nil {
+		return nil, err
+	}
+
+	holds := []*Hold{}
+	for _, key := range keys {
+		if len(key) != 2 {
+			continue
+		}
+
+		rawPath, err := base64.RawURLEncoding.DecodeString(key[1])
+		if err != nil {
+			continue
+		}
+
+		hold, err := lkr.Hold(string(rawPath))
+		if err != nil {
+			return nil, err
+		}
+
+		if hold != nil {
+			holds = append(holds, hold)
+		}
+	}
+
+	return holds, nil
+}
+
+// PutHold stores `hold`, replacing any older hold for the same path.
+func (lkr *Linker) PutHold(hold *Hold) error {
+	data, err := json.Marshal(holdJSON{
+		Path:    hold.Path,
+		Created: hold.Created,
+		Owner:   hold.Owner,
+		Reason:  hold.Reason,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		batch.Put(data, holdKey(hold.Path)...)
+		return false, nil
+	})
+}
+
+// RemoveHold lifts the hold of `nodePath`, if any.
+func (lkr *Linker) RemoveHold(nodePath string) error {
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		key := holdKey(nodePath)
+		if _, err := lkr.kv.Get(key...); err != nil {
+			if err == db.ErrNoSuchKey {
+				return false, nil
+			}
+
+			return hintRollback(err)
+		}
+
+		batch.Erase(key...)
+		return false, nil
+	})
+}
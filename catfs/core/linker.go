@@ -16,8 +16,15 @@ package core
 // stage/moves/overlay/<INODE>           => MOVE_INFO
 //
 // stats/max-inode                       => UINT64
+// stats/content-refs                    => (set once content refs were built)
 // refs/<REFNAME>                        => NODE_HASH
 //
+// content-refs/objects/<CONTENT>-<NODE> => (empty, see refs.go)
+// content-refs/stage/<CONTENT>-<NODE>   => (empty, see refs.go)
+//
+// counters/<BASE64_NODE_PATH>           => COUNTERS (json, see counters.go)
+// tombstones/<BASE64_NODE_PATH>         => TOMBSTONE (json, see tombstones.go)
+//
 // Defined by caller:
 //
 // metadata/                             => BYTES (Caller defined data)
@@ -68,38 +75,117 @@ type Linker struct {
 	// root of the filesystem
 	root *n.Directory
 
-	// Path lookup trie
+	// Path lookup trie for nodes that were not written yet.
+	// Others are found via the path index in the database.
 	ptrie *trie.Node
 
 	// B58Hash to node
-	index map[string]n.Node
+	index *nodeCache
 
-	// UID to node
-	inodeIndex map[uint64]n.Node
+	// Maximum number of unchanged nodes in index.
+	nodeCacheSize int
 
 	// Cache for the linker owner.
 	owner string
+
+	// Signs the hash of new commits, if set.
+	commitSigner CommitSigner
+
+	// Directories with more entries are stored in shards.
+	shardThreshold int
+
+	// Name of this device, stored in new commits.
+	deviceName string
+}
+
+// CommitSigner returns a signature of a commit hash.
+// If it returns a nil signature, the commit stays unsigned.
+type CommitSigner func(hash []byte) ([]byte, error)
+
+// SetShardThreshold sets the number of entries above which directories
+// are stored in several shards, so that changing them stays cheap.
+// A value <= 0 disables sharding for newly stored directories.
+func (lkr *Linker) SetShardThreshold(threshold int) {
+	lkr.shardThreshold = threshold
+}
+
+// SetCommitSigner sets the function used to sign new commits.
+func (lkr *Linker) SetCommitSigner(signer CommitSigner) {
+	lkr.commitSigner = signer
+}
+
+// SetDeviceName sets the device name that is stored in new commits,
+// unless they already carry one.
+func (lkr *Linker) SetDeviceName(name string) {
+	lkr.deviceName = name
 }
 
 // NewLinker returns a new lkr, ready to use. It assumes the key value store
 // is working and does no check on this.
 func NewLinker(kv db.Database) *Linker {
-	lkr := &Linker{kv: kv}
+	lkr := &Linker{
+		kv:             kv,
+		shardThreshold: n.DefaultShardThreshold,
+		nodeCacheSize:  DefaultNodeCacheSize,
+	}
+
 	lkr.MemIndexClear()
 	return lkr
 }
 
-// MemIndexAdd adds `nd` to the in memory index.
+// SetNodeCacheSize sets how many unchanged nodes are kept in memory.
+// Others are loaded from the database again when needed.
+// A value <= 0 keeps all of them.
+func (lkr *Linker) SetNodeCacheSize(size int) {
+	lkr.nodeCacheSize = size
+	lkr.index.SetSize(size)
+}
+
+// NodeCacheStats returns statistics about the in memory index.
+func (lkr *Linker) NodeCacheStats() NodeCacheStats {
+	return lkr.index.Stats()
+}
+
+func memIndexPath(nd n.Node) string {
+	if nd.Type() == n.NodeTypeDirectory {
+		return appendDot(nd.Path())
+	}
+
+	return nd.Path()
+}
+
+// MemIndexAdd adds `nd` to the in memory index. `updatePathIndex` should be
+// true if `nd` was modified, since it can not be loaded again then.
 func (lkr *Linker) MemIndexAdd(nd n.Node, updatePathIndex bool) {
-	lkr.index[nd.TreeHash().B58String()] = nd
-	lkr.inodeIndex[nd.Inode()] = nd
+	b58Hash := nd.TreeHash().B58String()
+	if !updatePathIndex {
+		lkr.index.Add(b58Hash, nd)
+		return
+	}
+
+	lkr.index.AddDirty(b58Hash, nd)
+	lkr.ptrie.InsertWithData(memIndexPath(nd), nd)
+}
+
+// memIndexForget drops the path entry of a node that was written.
+// It is resolved via the path index in the database afterwards.
+func (lkr *Linker) memIndexForget(nd n.Node) {
+	trieNode := lkr.ptrie.Lookup(memIndexPath(nd))
+	if trieNode == nil || trieNode.Data != nd {
+		return
+	}
+
+	// Parents created by the same insert carry `nd` as well:
+	for trieNode.Parent != nil {
+		if trieNode.Data == nd {
+			trieNode.Data = nil
+		}
 
-	if updatePathIndex {
-		path := nd.Path()
-		if nd.Type() == n.NodeTypeDirectory {
-			path = appendDot(path)
+		if trieNode.Data != nil || len(trieNode.Children) > 0 {
+			break
 		}
-		lkr.ptrie.InsertWithData(path, nd)
+
+		trieNode = trieNode.Remove()
 	}
 }
 
@@ -110,7 +196,7 @@ func (lkr *Linker) MemIndexAdd(nd n.Node, updatePathIndex bool) {
 // You should not need to call this function, except when implementing own Nodes.
 func (lkr *Linker) MemIndexSwap(nd n.Node, oldHash h.Hash, updatePathIndex bool) {
 	if oldHash != nil {
-		delete(lkr.index, oldHash.B58String())
+		lkr.index.Remove(oldHash.B58String())
 	}
 
 	lkr.MemIndexAdd(nd, updatePathIndex)
@@ -131,8 +217,7 @@ func (lkr *Linker) MemSetRoot(root *n.Directory) {
 
 // MemIndexPurge removes `nd` from the memory index.
 func (lkr *Linker) MemIndexPurge(nd n.Node) {
-	delete(lkr.inodeIndex, nd.Inode())
-	delete(lkr.index, nd.TreeHash().B58String())
+	lkr.index.Remove(nd.TreeHash().B58String())
 	lkr.ptrie.Lookup(nd.Path()).Remove()
 }
 
@@ -141,8 +226,11 @@ func (lkr *Linker) MemIndexPurge(nd n.Node) {
 // but should be okay to call between atomic operations.
 func (lkr *Linker) MemIndexClear() {
 	lkr.ptrie = trie.NewNode()
-	lkr.index = make(map[string]n.Node)
-	lkr.inodeIndex = make(map[uint64]n.Node)
+	lkr.index = newNodeCache(lkr.nodeCacheSize)
+	lkr.index.keep = func(nd n.Node) bool {
+		return nd == lkr.root
+	}
+
 	lkr.root = nil
 }
 
@@ -180,45 +268,25 @@ func (lkr *Linker) NextInode() uint64 {
 }
 
 // FilesByContents checks what files are associated with the content hashes in
-// `contents`. It returns a map of content hash b58 to file. This method is
-// quite heavy and should not be used in loops. There is room for optimizations.
+// `contents`. It returns a map of content hash b58 to file. If several files
+// share the same content, any of them is returned.
 func (lkr *Linker) FilesByContents(contents []h.Hash) (map[string]*n.File, error) {
-	keys, err := lkr.kv.Keys()
-	if err != nil {
-		return nil, err
-	}
-
 	result := make(map[string]*n.File)
-	for _, key := range keys {
-		// Filter non-node storage:
-		fullKey := strings.Join(key, "/")
-		if !strings.HasPrefix(fullKey, "objects") &&
-			!strings.HasPrefix(fullKey, "stage/objects") {
-			continue
-		}
-
-		data, err := lkr.kv.Get(key...)
+	for _, content := range contents {
+		refs, err := lkr.ContentRefs(content)
 		if err != nil {
 			return nil, err
 		}
 
-		nd, err := n.UnmarshalNode(data)
-		if err != nil {
-			return nil, err
-		}
-
-		if nd.Type() != n.NodeTypeFile {
-			continue
-		}
-
-		file, ok := nd.(*n.File)
-		if !ok {
-			return nil, ie.ErrBadNode
-		}
+		for _, ref := range refs {
+			file, err := lkr.FileByHash(ref)
+			if err != nil {
+				return nil, err
+			}
 
-		for _, content := range contents {
-			if content.Equal(file.BackendHash()) {
+			if file != nil {
 				result[content.B58String()] = file
+				break
 			}
 		}
 	}
@@ -247,7 +315,12 @@ func (lkr *Linker) loadNode(hash h.Hash) (n.Node, error) {
 		}
 
 		if data != nil {
-			return n.UnmarshalNode(data)
+			nd, err := n.UnmarshalNode(data)
+			if err != nil {
+				return nil, err
+			}
+
+			return nd, n.LoadShards(nd, lkr.loadShard)
 		}
 	}
 
@@ -255,12 +328,36 @@ func (lkr *Linker) loadNode(hash h.Hash) (n.Node, error) {
 	return nil, nil
 }
 
+func (lkr *Linker) loadShard(hash h.Hash) ([]byte, error) {
+	data, err := lkr.kv.Get("shards", hash.B58String())
+	if err == db.ErrNoSuchKey {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// marshalNode serializes `nd` and puts the shards that changed into `batch`.
+func (lkr *Linker) marshalNode(batch db.Batch, nd n.Node) ([]byte, error) {
+	data, shards, err := n.MarshalNodeSharded(nd, lkr.shardThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shards are content addressed and shared by all versions of a directory:
+	for b58Hash, shardData := range shards {
+		batch.Put(shardData, "shards", b58Hash)
+	}
+
+	return data, nil
+}
+
 // NodeByHash returns the node identified by hash.
 // If no such hash could be found, nil is returned.
 func (lkr *Linker) NodeByHash(hash h.Hash) (n.Node, error) {
 	// Check if we have this this node in the memory cache already:
 	b58Hash := hash.B58String()
-	if cachedNode, ok := lkr.index[b58Hash]; ok {
+	if cachedNode, ok := lkr.index.Get(b58Hash); ok {
 		return cachedNode, nil
 	}
 
@@ -294,7 +391,7 @@ func appendDot(path string) string {
 // It does not matter if the node was deleted in the meantime. If so,
 // a Ghost node is returned which stores the last known state.
 func (lkr *Linker) ResolveNode(nodePath string) (n.Node, error) {
-	// Check if it's cached already:
+	// Check if it was changed in memory:
 	trieNode := lkr.ptrie.Lookup(nodePath)
 	if trieNode != nil && trieNode.Data != nil {
 		return trieNode.Data.(n.Node), nil
@@ -339,6 +436,13 @@ func (lkr *Linker) StageNode(nd n.Node) error {
 			return true, e.Wrapf(err, "recursive stage")
 		}
 
+		// A node that exists (again) at this path is not deleted anymore:
+		if nd.Type() != n.NodeTypeGhost {
+			if err := lkr.eraseTombstone(batch, nd.Path()); err != nil {
+				return true, err
+			}
+		}
+
 		// Update the staging commit's root hash:
 		status, err := lkr.Status()
 		if err != nil {
@@ -398,17 +502,38 @@ func (lkr *Linker) CommitByIndex(index int64) (*n.Commit, error) {
 // NodeByInode resolves a node by it's unique ID.
 // It will return nil if no corresponding node was found.
 func (lkr *Linker) NodeByInode(uid uint64) (n.Node, error) {
-	b58Hash, err := lkr.kv.Get("inode", strconv.FormatUint(uid, 10))
-	if err != nil && err != db.ErrNoSuchKey {
-		return nil, err
+	b58Hash, ok := lkr.index.ByInode(uid)
+	if !ok {
+		data, err := lkr.kv.Get("inode", strconv.FormatUint(uid, 10))
+		if err != nil && err != db.ErrNoSuchKey {
+			return nil, err
+		}
+
+		b58Hash = string(data)
 	}
 
-	hash, err := h.FromB58String(string(b58Hash))
+	hash, err := h.FromB58String(b58Hash)
 	if err != nil {
 		return nil, err
 	}
 
-	return lkr.NodeByHash(hash)
+	nd, err := lkr.NodeByHash(hash)
+	if err != nil || nd == nil {
+		return nil, err
+	}
+
+	if !ok {
+		lkr.index.SetInode(uid, b58Hash)
+	}
+
+	return nd, nil
+}
+
+// putInode points the inode of `nd` to its current hash.
+func (lkr *Linker) putInode(batch db.Batch, nd n.Node) {
+	b58Hash := nd.TreeHash().B58String()
+	batch.Put([]byte(b58Hash), "inode", strconv.FormatUint(nd.Inode(), 10))
+	lkr.index.SetInode(nd.Inode(), b58Hash)
 }
 
 func (lkr *Linker) stageNodeRecursive(batch db.Batch, nd n.Node) error {
@@ -416,16 +541,18 @@ func (lkr *Linker) stageNodeRecursive(batch db.Batch, nd n.Node) error {
 		return fmt.Errorf("bug: commits cannot be staged; use MakeCommit()")
 	}
 
-	data, err := n.MarshalNode(nd)
+	data, err := lkr.marshalNode(batch, nd)
 	if err != nil {
 		return e.Wrapf(err, "marshal")
 	}
 
 	b58Hash := nd.TreeHash().B58String()
 	batch.Put(data, "stage", "objects", b58Hash)
+	if err := lkr.addContentRef(batch, contentRefsStage, nd); err != nil {
+		return err
+	}
 
-	uidKey := strconv.FormatUint(nd.Inode(), 10)
-	batch.Put([]byte(nd.TreeHash().B58String()), "inode", uidKey)
+	lkr.putInode(batch, nd)
 
 	hashPath := []string{"stage", "tree", nd.Path()}
 	if nd.Type() == n.NodeTypeDirectory {
@@ -436,6 +563,7 @@ func (lkr *Linker) stageNodeRecursive(batch db.Batch, nd n.Node) error {
 
 	// Remember/Update this node in the cache if it's not yet there:
 	lkr.MemIndexAdd(nd, true)
+	lkr.index.MarkStaged(b58Hash)
 
 	// We need to save parent directories too, in case the hash changed:
 	// Note that this will create many pointless directories in staging.
@@ -491,8 +619,13 @@ func (lkr *Linker) SetMergeMarker(with string, remoteHead h.Hash) error {
 // If nothing changed since the last call to MakeCommit, it will
 // return ErrNoChange, which can be reacted upon.
 func (lkr *Linker) MakeCommit(author string, message string) error {
+	return lkr.MakeCommitWithMeta(author, message, n.CommitMeta{})
+}
+
+// MakeCommitWithMeta is like MakeCommit, but also stores `meta` in the commit.
+func (lkr *Linker) MakeCommitWithMeta(author, message string, meta n.CommitMeta) error {
 	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
-		switch err := lkr.makeCommit(batch, author, message); err {
+		switch err := lkr.makeCommit(batch, author, message, meta); err {
 		case ie.ErrNoChange:
 			return false, err
 		case nil:
@@ -506,13 +639,17 @@ func (lkr *Linker) MakeCommit(author string, message string) error {
 func (lkr *Linker) makeCommitPutCurrToPersistent(batch db.Batch, rootDir *n.Directory) (map[uint64]bool, error) {
 	exportedInodes := make(map[uint64]bool)
 	return exportedInodes, n.Walk(lkr, rootDir, true, func(child n.Node) error {
-		data, err := n.MarshalNode(child)
+		data, err := lkr.marshalNode(batch, child)
 		if err != nil {
 			return err
 		}
 
 		b58Hash := child.TreeHash().B58String()
 		batch.Put(data, "objects", b58Hash)
+		if err := lkr.addContentRef(batch, contentRefsObjects, child); err != nil {
+			return err
+		}
+
 		exportedInodes[child.Inode()] = true
 
 		childPath := child.Path()
@@ -525,7 +662,7 @@ func (lkr *Linker) makeCommitPutCurrToPersistent(batch db.Batch, rootDir *n.Dire
 	})
 }
 
-func (lkr *Linker) makeCommit(batch db.Batch, author string, message string) error {
+func (lkr *Linker) makeCommit(batch db.Batch, author string, message string, meta n.CommitMeta) error {
 	head, err := lkr.Head()
 	if err != nil && !ie.IsErrNoSuchRef(err) {
 		return err
@@ -564,10 +701,24 @@ func (lkr *Linker) makeCommit(batch db.Batch, author string, message string) err
 		}
 	}
 
+	if meta.Device == "" {
+		meta.Device = lkr.deviceName
+	}
+
+	status.SetMeta(meta)
 	if err := status.BoxCommit(author, message); err != nil {
 		return err
 	}
 
+	if lkr.commitSigner != nil {
+		sig, err := lkr.commitSigner(status.TreeHash().Bytes())
+		if err != nil {
+			return e.Wrap(err, "failed to sign commit")
+		}
+
+		status.SetSignature(sig)
+	}
+
 	statusData, err := n.MarshalNode(status)
 	if err != nil {
 		return err
@@ -624,6 +775,7 @@ func (lkr *Linker) clearStage(batch db.Batch) error {
 		{"stage", "objects"},
 		{"stage", "tree"},
 		{"stage", "moves"},
+		{contentRefsKey, contentRefsStage},
 	}
 
 	for _, key := range toClear {
@@ -979,9 +1131,8 @@ func (lkr *Linker) saveStatus(cmt *n.Commit) error {
 			return hintRollback(err)
 		}
 
-		inode := strconv.FormatUint(cmt.Inode(), 10)
 		batch.Put(data, "stage", "STATUS")
-		batch.Put([]byte(cmt.TreeHash().B58String()), "inode", inode)
+		lkr.putInode(batch, cmt)
 		return hintRollback(lkr.SaveRef("CURR", cmt))
 	})
 }
@@ -1767,6 +1918,10 @@ func (lkr *Linker) AtomicWithBatch(fn func(batch db.Batch) (bool, error)) (err e
 	if flushErr := batch.Flush(); flushErr != nil {
 		lkr.MemIndexClear()
 		log.Warningf("flush to db failed, resetting mem index: %v", flushErr)
+	} else {
+		for _, nd := range lkr.index.Flushed() {
+			lkr.memIndexForget(nd)
+		}
 	}
 
 	return err
@@ -204,10 +204,26 @@ func TestLinkerNested(t *testing.T) {
 		}
 
 		// Index shall only contain the nodes with their most current hash values.
-		if len(lkr.index) != 3 {
+		if lkr.index.Len() != 3 {
 			t.Fatalf("Index does not contain the expected 3 elements.")
 		}
 
+		// Written nodes are found via the path index in the database,
+		// but still resolve to the same instance:
+		for _, nodePath := range []string{"/sub/pub", "/sub/pub/."} {
+			if trieNode := lkr.ptrie.Lookup(nodePath); trieNode != nil && trieNode.Data != nil {
+				t.Fatalf("Path of a written node is still in the memory index: %s", nodePath)
+			}
+		}
+
+		if resolved, err := lkr.ResolveDirectory("/sub/pub"); err != nil || resolved != subpub {
+			t.Fatalf("Resolving /sub/pub gave another instance: %v", err)
+		}
+
+		if resolved, err := lkr.NodeByInode(subpub.Inode()); err != nil || resolved != subpub {
+			t.Fatalf("Resolving the inode of /sub/pub gave another instance: %v", err)
+		}
+
 		gc := NewGarbageCollector(lkr, kv, nil)
 		if err := gc.Run(true); err != nil {
 			t.Fatalf("Garbage collector failed to run: %v", err)
@@ -620,3 +636,57 @@ func TestLookupNodeAt(t *testing.T) {
 		require.Nil(t, last)
 	})
 }
+
+func TestShardedDirectory(t *testing.T) {
+	WithDummyKv(t, func(kv db.Database) {
+		lkr := NewLinker(kv)
+		lkr.SetShardThreshold(10)
+		require.Nil(t, lkr.SetOwner("alice"))
+		MustCommit(t, lkr, "init")
+
+		MustMkdir(t, lkr, "/big")
+		for idx := 0; idx < 50; idx++ {
+			MustTouch(t, lkr, fmt.Sprintf("/big/%d", idx), byte(idx))
+		}
+
+		MustCommit(t, lkr, "fill big")
+
+		shardKeys, err := kv.Keys("shards")
+		require.Nil(t, err)
+		require.NotEmpty(t, shardKeys)
+
+		// Load everything freshly from the database:
+		lkr.MemIndexClear()
+		big := MustLookupDirectory(t, lkr, "/big")
+		require.NotEmpty(t, n.ShardHashes(big))
+		require.Equal(t, 50, big.NChildren())
+
+		for idx := 0; idx < 50; idx++ {
+			nd, err := lkr.LookupNode(fmt.Sprintf("/big/%d", idx))
+			require.Nil(t, err)
+			require.Equal(t, n.NodeTypeFile, nd.Type())
+		}
+
+		// Shards still in use must survive a full gc run:
+		MustTouch(t, lkr, "/big/50", 50)
+		MustCommit(t, lkr, "one more")
+
+		gc := NewGarbageCollector(lkr, kv, nil)
+		require.Nil(t, gc.Run(true))
+
+		lkr.MemIndexClear()
+		big = MustLookupDirectory(t, lkr, "/big")
+		require.Equal(t, 51, big.NChildren())
+
+		// Shrinking the threshold to zero stores it like before:
+		lkr.SetShardThreshold(0)
+		MustTouch(t, lkr, "/big/51", 51)
+		MustCommit(t, lkr, "unsharded")
+		require.Nil(t, gc.Run(true))
+
+		lkr.MemIndexClear()
+		big = MustLookupDirectory(t, lkr, "/big")
+		require.Empty(t, n.ShardHashes(big))
+		require.Equal(t, 52, big.NChildren())
+	})
+}
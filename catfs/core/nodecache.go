@@ -0,0 +1,214 @@
+package core
+
+import (
+	"container/list"
+
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// DefaultNodeCacheSize is the number of unchanged nodes kept in memory.
+const DefaultNodeCacheSize = 100000
+
+// nodeCache maps the b58 hash of nodes to their in-memory instance.
+//
+// Nodes are only loaded from the database when they are needed, so it does
+// not matter for startup how big the repository is. Unchanged nodes are
+// evicted least recently used first whenever a new one is added, which keeps
+// the memory usage bounded without ever walking the whole cache. Nodes that
+// were changed in memory can not be loaded again before they were written,
+// so they are pinned until the batch staging them was flushed.
+//
+// Callers hold on to node instances during an operation and expect to get
+// the same instance when resolving them again. This holds as long as one
+// operation does not touch more nodes than the cache may keep, therefore the
+// size should be well above the number of entries in a directory.
+type nodeCache struct {
+	size int
+	lru  *list.List
+
+	// clean nodes were loaded from the database or already written to it.
+	clean map[string]*list.Element
+
+	// dirty nodes only exist in memory.
+	dirty map[string]n.Node
+
+	// staged nodes were put into a batch that was not flushed yet.
+	staged map[string]bool
+
+	// inodes maps the inode of cached nodes to the key
+	// that the inode bucket of the database has for it.
+	inodes map[uint64]string
+
+	// keep tells if a node may not be evicted, if set.
+	keep func(nd n.Node) bool
+
+	hits, misses uint64
+}
+
+type nodeCacheEntry struct {
+	key string
+	nd  n.Node
+}
+
+// NodeCacheStats tells how well the node cache works.
+type NodeCacheStats struct {
+	Size   int
+	Clean  int
+	Dirty  int
+	Hits   uint64
+	Misses uint64
+}
+
+// newNodeCache returns an empty cache. `size` <= 0 means no limit.
+func newNodeCache(size int) *nodeCache {
+	return &nodeCache{
+		size:   size,
+		lru:    list.New(),
+		clean:  make(map[string]*list.Element),
+		dirty:  make(map[string]n.Node),
+		staged: make(map[string]bool),
+		inodes: make(map[uint64]string),
+	}
+}
+
+// Get returns the node for `key`, if cached.
+func (nc *nodeCache) Get(key string) (n.Node, bool) {
+	if nd, ok := nc.dirty[key]; ok {
+		nc.hits++
+		return nd, true
+	}
+
+	if elem, ok := nc.clean[key]; ok {
+		nc.hits++
+		nc.lru.MoveToFront(elem)
+		return elem.Value.(*nodeCacheEntry).nd, true
+	}
+
+	nc.misses++
+	return nil, false
+}
+
+// Add remembers a node that is the same as in the database.
+func (nc *nodeCache) Add(key string, nd n.Node) {
+	if _, ok := nc.dirty[key]; ok {
+		nc.dirty[key] = nd
+		return
+	}
+
+	if elem, ok := nc.clean[key]; ok {
+		elem.Value.(*nodeCacheEntry).nd = nd
+		nc.lru.MoveToFront(elem)
+		return
+	}
+
+	nc.clean[key] = nc.lru.PushFront(&nodeCacheEntry{key: key, nd: nd})
+	nc.evict()
+}
+
+// AddDirty remembers a node that was changed in memory.
+func (nc *nodeCache) AddDirty(key string, nd n.Node) {
+	nc.removeClean(key)
+	nc.dirty[key] = nd
+}
+
+// MarkStaged notes that the node of `key` was put into the current batch.
+func (nc *nodeCache) MarkStaged(key string) {
+	if _, ok := nc.dirty[key]; ok {
+		nc.staged[key] = true
+	}
+}
+
+// Flushed is called after a batch was written;
+// the nodes staged in it may be evicted from now on.
+// It returns those nodes.
+func (nc *nodeCache) Flushed() []n.Node {
+	flushed := make([]n.Node, 0, len(nc.staged))
+	for key := range nc.staged {
+		if nd, ok := nc.dirty[key]; ok {
+			delete(nc.dirty, key)
+			nc.clean[key] = nc.lru.PushFront(&nodeCacheEntry{key: key, nd: nd})
+			flushed = append(flushed, nd)
+		}
+	}
+
+	nc.staged = make(map[string]bool)
+	nc.evict()
+	return flushed
+}
+
+// Remove forgets about `key`.
+func (nc *nodeCache) Remove(key string) {
+	if nd, ok := nc.dirty[key]; ok {
+		nc.forgetInode(key, nd)
+		delete(nc.dirty, key)
+	}
+
+	delete(nc.staged, key)
+	nc.removeClean(key)
+}
+
+// SetInode remembers that the inode bucket maps `uid` to `key` now.
+func (nc *nodeCache) SetInode(uid uint64, key string) {
+	nc.inodes[uid] = key
+}
+
+// ByInode returns the key that `uid` was last mapped to, if known.
+func (nc *nodeCache) ByInode(uid uint64) (string, bool) {
+	key, ok := nc.inodes[uid]
+	return key, ok
+}
+
+// Len returns the number of cached nodes.
+func (nc *nodeCache) Len() int {
+	return len(nc.clean) + len(nc.dirty)
+}
+
+// SetSize changes the number of clean nodes that are kept.
+func (nc *nodeCache) SetSize(size int) {
+	nc.size = size
+	nc.evict()
+}
+
+// Stats returns the current statistics of the cache.
+func (nc *nodeCache) Stats() NodeCacheStats {
+	return NodeCacheStats{
+		Size:   nc.size,
+		Clean:  len(nc.clean),
+		Dirty:  len(nc.dirty),
+		Hits:   nc.hits,
+		Misses: nc.misses,
+	}
+}
+
+func (nc *nodeCache) removeClean(key string) {
+	if elem, ok := nc.clean[key]; ok {
+		nc.forgetInode(key, elem.Value.(*nodeCacheEntry).nd)
+		nc.lru.Remove(elem)
+		delete(nc.clean, key)
+	}
+}
+
+func (nc *nodeCache) forgetInode(key string, nd n.Node) {
+	if nc.inodes[nd.Inode()] == key {
+		delete(nc.inodes, nd.Inode())
+	}
+}
+
+// evict drops the least recently used clean nodes
+// until at most `size` are left.
+func (nc *nodeCache) evict() {
+	if nc.size <= 0 {
+		return
+	}
+
+	elem := nc.lru.Back()
+	for elem != nil && len(nc.clean) > nc.size {
+		prev := elem.Prev()
+		entry := elem.Value.(*nodeCacheEntry)
+		if nc.keep == nil || !nc.keep(entry.nd) {
+			nc.removeClean(entry.key)
+		}
+
+		elem = prev
+	}
+}
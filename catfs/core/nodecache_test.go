@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeCache(t *testing.T) {
+	root, err := n.NewEmptyDirectory(nil, nil, "", "alice", 1)
+	require.Nil(t, err)
+
+	nodes := []n.Node{}
+	for idx := 0; idx < 4; idx++ {
+		nodes = append(nodes, n.NewEmptyFile(root, "x", "alice", uint64(idx+2)))
+	}
+
+	nc := newNodeCache(2)
+	nc.keep = func(nd n.Node) bool { return nd == root }
+
+	nc.Add("a", nodes[0])
+	nc.Add("b", nodes[1])
+
+	// "a" was used last, so "b" goes first:
+	nd, ok := nc.Get("a")
+	require.True(t, ok)
+	require.Equal(t, nodes[0], nd)
+
+	nc.Add("c", nodes[2])
+	require.Equal(t, 2, nc.Len())
+
+	_, ok = nc.Get("b")
+	require.False(t, ok)
+
+	// Dirty nodes stay until their batch was flushed:
+	nc.AddDirty("d", nodes[3])
+	nc.SetInode(nodes[3].Inode(), "d")
+	nc.SetSize(1)
+	_, ok = nc.Get("d")
+	require.True(t, ok)
+	_, ok = nc.Get("a")
+	require.False(t, ok)
+
+	nc.MarkStaged("d")
+	require.Equal(t, []n.Node{nodes[3]}, nc.Flushed())
+
+	key, ok := nc.ByInode(nodes[3].Inode())
+	require.True(t, ok)
+	require.Equal(t, "d", key)
+
+	// The root is never evicted:
+	nc.Add("r", root)
+	nc.Add("e", nodes[0])
+	_, ok = nc.Get("r")
+	require.True(t, ok)
+
+	// Evicted nodes lose their inode entry too:
+	_, ok = nc.Get("d")
+	require.False(t, ok)
+	_, ok = nc.ByInode(nodes[3].Inode())
+	require.False(t, ok)
+
+	stats := nc.Stats()
+	require.Equal(t, 1, stats.Clean)
+	require.Equal(t, 0, stats.Dirty)
+}
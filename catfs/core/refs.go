@@ -0,0 +1,173 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// Content references map a content hash to all file nodes that use it.
+// They are kept in two scopes, one for the staging area and one for the
+// permanent objects, and are updated in the same batch as the objects:
+//
+// content-refs/stage/<CONTENT_HASH>-<NODE_HASH>   => (empty)
+// content-refs/objects/<CONTENT_HASH>-<NODE_HASH> => (empty)
+//
+// This way, finding out if (and by whom) some content is still used
+// is a single prefix lookup instead of a walk over the whole history.
+
+const (
+	contentRefsKey     = "content-refs"
+	contentRefsStage   = "stage"
+	contentRefsObjects = "objects"
+)
+
+func contentRefKey(scope string, content, node h.Hash) []string {
+	return []string{
+		contentRefsKey,
+		scope,
+		content.B58String() + "-" + node.B58String(),
+	}
+}
+
+// fileOf returns `nd` as file or nil if it is no file.
+func fileOf(nd n.Node) (*n.File, error) {
+	if nd.Type() != n.NodeTypeFile {
+		return nil, nil
+	}
+
+	file, ok := nd.(*n.File)
+	if !ok {
+		return nil, ie.ErrBadNode
+	}
+
+	return file, nil
+}
+
+func (lkr *Linker) addContentRef(batch db.Batch, scope string, nd n.Node) error {
+	file, err := fileOf(nd)
+	if err != nil || file == nil {
+		return err
+	}
+
+	batch.Put([]byte{}, contentRefKey(scope, file.BackendHash(), file.TreeHash())...)
+	return nil
+}
+
+func (lkr *Linker) removeContentRef(batch db.Batch, scope string, nd n.Node) error {
+	file, err := fileOf(nd)
+	if err != nil || file == nil {
+		return err
+	}
+
+	batch.Erase(contentRefKey(scope, file.BackendHash(), file.TreeHash())...)
+	return nil
+}
+
+// ContentRefs returns the hashes of all file nodes (staged or committed)
+// that reference `content`. Every node is only returned once.
+func (lkr *Linker) ContentRefs(content h.Hash) ([]h.Hash, error) {
+	seen := make(map[string]bool)
+	refs := []h.Hash{}
+
+	prefix := content.B58String() + "-"
+	for _, scope := range []string{contentRefsObjects, contentRefsStage} {
+		keys, err := lkr.kv.Glob([]string{contentRefsKey, scope, prefix})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			b58Node := strings.TrimPrefix(key[len(key)-1], prefix)
+			if seen[b58Node] {
+				continue
+			}
+
+			node, err := h.FromB58String(b58Node)
+			if err != nil {
+				return nil, err
+			}
+
+			seen[b58Node] = true
+			refs = append(refs, node)
+		}
+	}
+
+	return refs, nil
+}
+
+// ContentRefCount returns how many file nodes reference `content`.
+func (lkr *Linker) ContentRefCount(content h.Hash) (int, error) {
+	refs, err := lkr.ContentRefs(content)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(refs), nil
+}
+
+// HaveContentRefs tells if the content references were built already.
+// Repositories created before they existed need RebuildContentRefs().
+func (lkr *Linker) HaveContentRefs() (bool, error) {
+	_, err := lkr.kv.Get("stats", "content-refs")
+	if err == db.ErrNoSuchKey {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// RebuildContentRefs throws away all content references and creates
+// them again from the objects in the store.
+func (lkr *Linker) RebuildContentRefs() error {
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		if err := batch.Clear(contentRefsKey); err != nil {
+			return hintRollback(err)
+		}
+
+		scopes := map[string][]string{
+			contentRefsObjects: {"objects"},
+			contentRefsStage:   {"stage", "objects"},
+		}
+
+		for scope, prefix := range scopes {
+			keys, err := lkr.kv.Keys(prefix...)
+			if err != nil {
+				return hintRollback(err)
+			}
+
+			for _, key := range keys {
+				// Keys() matches by prefix, so objects/ would
+				// also match stage/objects/ in some backends.
+				if len(key) != len(prefix)+1 {
+					continue
+				}
+
+				data, err := lkr.kv.Get(key...)
+				if err != nil {
+					return hintRollback(err)
+				}
+
+				if len(data) == 0 {
+					// Left over by some imports; not a node.
+					continue
+				}
+
+				nd, err := n.UnmarshalNode(data)
+				if err != nil {
+					return hintRollback(err)
+				}
+
+				if err := lkr.addContentRef(batch, scope, nd); err != nil {
+					return hintRollback(err)
+				}
+			}
+		}
+
+		batch.Put([]byte{1}, "stats", "content-refs")
+		return false, nil
+	})
+}
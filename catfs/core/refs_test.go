@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func mustRefCount(t *testing.T, lkr *Linker, content h.Hash) int {
+	count, err := lkr.ContentRefCount(content)
+	require.Nil(t, err)
+	return count
+}
+
+func TestContentRefs(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		// Two paths with the same content:
+		x := MustTouch(t, lkr, "/x", 1)
+		MustTouch(t, lkr, "/y", 1)
+		require.Equal(t, 2, mustRefCount(t, lkr, x.BackendHash()))
+
+		// Committing keeps the same nodes, just in another scope:
+		MustCommit(t, lkr, "first")
+		require.Equal(t, 2, mustRefCount(t, lkr, x.BackendHash()))
+
+		// A new version of /x that is never committed:
+		MustTouch(t, lkr, "/x", 2)
+		require.Equal(t, 1, mustRefCount(t, lkr, h.TestDummy(t, 2)))
+
+		// ...vanishes together with the staging area:
+		MustTouch(t, lkr, "/x", 3)
+		gc := NewGarbageCollector(lkr, lkr.kv, nil)
+		require.Nil(t, gc.Run(false))
+		require.Equal(t, 0, mustRefCount(t, lkr, h.TestDummy(t, 2)))
+		require.Equal(t, 1, mustRefCount(t, lkr, h.TestDummy(t, 3)))
+
+		// The old version of /x is still part of the history:
+		require.Equal(t, 2, mustRefCount(t, lkr, x.BackendHash()))
+
+		// Rebuilding should yield the same result:
+		require.Nil(t, lkr.RebuildContentRefs())
+		require.Equal(t, 2, mustRefCount(t, lkr, x.BackendHash()))
+		require.Equal(t, 0, mustRefCount(t, lkr, h.TestDummy(t, 2)))
+		require.Equal(t, 1, mustRefCount(t, lkr, h.TestDummy(t, 3)))
+
+		haveRefs, err := lkr.HaveContentRefs()
+		require.Nil(t, err)
+		require.True(t, haveRefs)
+	})
+}
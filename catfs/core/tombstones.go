@@ -0,0 +1,164 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/sahib/brig/catfs/db"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// Tombstone records that a node was deleted on purpose. Other than ghosts,
+// tombstones are not part of the tree: they are exchanged with the metadata
+// and survive a sync with a peer that never saw the node's ghost. They stop
+// stale peers from bringing back deleted files until they are purged.
+type Tombstone struct {
+	// Path of the deleted node.
+	Path string
+	// ContentHash of the deleted node. Only a node with exactly this content
+	// is considered deleted; a modified version is not.
+	ContentHash h.Hash
+	// Removed is when the node was deleted first.
+	Removed time.Time
+	// Owner is who deleted the node first.
+	Owner string
+	// IsDir is true if a whole directory was deleted.
+	IsDir bool
+}
+
+type tombstoneJSON struct {
+	Path        string    `json:"path"`
+	ContentHash string    `json:"content_hash"`
+	Removed     time.Time `json:"removed"`
+	Owner       string    `json:"owner"`
+	IsDir       bool      `json:"is_dir"`
+}
+
+// Matches returns true if `nd` is the node this tombstone was made for.
+func (ts *Tombstone) Matches(nd n.Node) bool {
+	return nd.Path() == ts.Path && nd.ContentHash().Equal(ts.ContentHash)
+}
+
+func tombstoneKey(nodePath string) []string {
+	return []string{"tombstones", base64.RawURLEncoding.EncodeToString([]byte(nodePath))}
+}
+
+// Tombstone returns the tombstone for `nodePath` or nil if there is none.
+func (lkr *Linker) Tombstone(nodePath string) (*Tombstone, error) {
+	data, err := lkr.kv.Get(tombstoneKey(nodePath)...)
+	if err == db.ErrNoSuchKey {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tsj := tombstoneJSON{}
+	if err := json.Unmarshal(data, &tsj); err != nil {
+		return nil, err
+	}
+
+	contentHash, err := h.FromB58String(tsj.ContentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tombstone{
+		Path:        tsj.Path,
+		ContentHash: contentHash,
+		Removed:     tsj.Removed,
+		Owner:       tsj.Owner,
+		IsDir:       tsj.IsDir,
+	}, nil
+}
+
+// Tombstones returns all tombstones of `lkr`.
+func (lkr *Linker) Tombstones() ([]*Tombstone, error) {
+	keys, err := lkr.kv.Keys("tombstones")
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones := []*Tombstone{}
+	for _, key := range keys {
+		if len(key) != 2 {
+			continue
+		}
+
+		rawPath, err := base64.RawURLEncoding.DecodeString(key[1])
+		if err != nil {
+			continue
+		}
+
+		ts, err := lkr.Tombstone(string(rawPath))
+		if err != nil {
+			return nil, err
+		}
+
+		if ts != nil {
+			tombstones = append(tombstones, ts)
+		}
+	}
+
+	return tombstones, nil
+}
+
+// PutTombstone stores `ts`, replacing any older tombstone for the same path.
+func (lkr *Linker) PutTombstone(ts *Tombstone) error {
+	data, err := json.Marshal(tombstoneJSON{
+		Path:        ts.Path,
+		ContentHash: ts.ContentHash.B58String(),
+		Removed:     ts.Removed,
+		Owner:       ts.Owner,
+		IsDir:       ts.IsDir,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		batch.Put(data, tombstoneKey(ts.Path)...)
+		return false, nil
+	})
+}
+
+// AddTombstone remembers that `nd` was deleted by us just now.
+func (lkr *Linker) AddTombstone(nd n.Node) error {
+	owner, err := lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	return lkr.PutTombstone(&Tombstone{
+		Path:        nd.Path(),
+		ContentHash: nd.ContentHash().Clone(),
+		Removed:     time.Now(),
+		Owner:       owner,
+		IsDir:       nd.Type() == n.NodeTypeDirectory,
+	})
+}
+
+// RemoveTombstone forgets the tombstone of `nodePath`, if any.
+func (lkr *Linker) RemoveTombstone(nodePath string) error {
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		return hintRollback(lkr.eraseTombstone(batch, nodePath))
+	})
+}
+
+func (lkr *Linker) eraseTombstone(batch db.Batch, nodePath string) error {
+	key := tombstoneKey(nodePath)
+	if _, err := lkr.kv.Get(key...); err != nil {
+		if err == db.ErrNoSuchKey {
+			return nil
+		}
+
+		return err
+	}
+
+	batch.Erase(key...)
+	return nil
+}
@@ -0,0 +1,58 @@
+package catfs
+
+import (
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// Names of the counters maintained by brig itself.
+const (
+	// CounterDownloads counts how often a node was downloaded.
+	CounterDownloads = "downloads"
+	// CounterViews counts how often a file was displayed inline.
+	CounterViews = "views"
+)
+
+// CounterAdd increments the counter `name` of the node at `path` by one.
+// Counters are kept per peer and merged on sync without conflicts,
+// so every peer sees the sum of everyone's counts eventually.
+// Incrementing does not create anything that needs to be committed.
+func (fs *FS) CounterAdd(path, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.frozen {
+		return ErrFrozen
+	}
+
+	path = fs.normPath(path)
+	nd, err := fs.lkr.LookupNode(path)
+	if err != nil {
+		return err
+	}
+
+	if nd.Type() == n.NodeTypeGhost {
+		return ie.NoSuchFile(path)
+	}
+
+	return fs.lkr.CounterAdd(path, name, 1)
+}
+
+// Counters returns the total value of all counters of the node at `path`.
+// Counters that were never incremented are not included.
+func (fs *FS) Counters(path string) (map[string]uint64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	counters, err := fs.lkr.Counters(fs.normPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]uint64)
+	for name, counter := range counters {
+		totals[name] = counter.Value()
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// BackendBadger stores the metadata in a BadgerDB.
+	// It writes in batches and is the default.
+	BackendBadger = "badger"
+
+	// BackendDisk stores every key as a single file.
+	// It is slow, but easy to inspect with normal tools.
+	BackendDisk = "disk"
+)
+
+// Backends lists the names of all database backends that can store metadata.
+var Backends = []string{BackendBadger, BackendDisk}
+
+// Open opens the database backend called `name` at `path`.
+func Open(name, path string) (Database, error) {
+	switch name {
+	case BackendBadger:
+		return NewBadgerDatabase(path)
+	case BackendDisk:
+		return NewDiskDatabase(path)
+	default:
+		return nil, fmt.Errorf("no such database backend: %s", name)
+	}
+}
+
+// DetectBackend returns the name of the backend that stored its data
+// at `path`. If there is no data at `path` yet, it returns "".
+func DetectBackend(path string) (string, error) {
+	children, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if len(children) == 0 {
+		return "", nil
+	}
+
+	// Badger always keeps a manifest of its tables.
+	if _, err := os.Stat(filepath.Join(path, "MANIFEST")); err == nil {
+		return BackendBadger, nil
+	}
+
+	return BackendDisk, nil
+}
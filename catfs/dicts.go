@@ -0,0 +1,334 @@
+package catfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/db"
+	"github.com/sahib/brig/catfs/mio/compress"
+	n "github.com/sahib/brig/catfs/nodes"
+	log "github.com/sirupsen/logrus"
+)
+
+// Small files compress badly, since there is not much to learn from.
+// If many of them are similar (think source code or logs) it helps a lot
+// to compress them with a dictionary that was trained on files of the
+// same type. The dictionaries are stored in the metadata and travel with
+// patches, so peers can read the content too.
+
+const (
+	// Files bigger than this do not use a dictionary.
+	// They have enough content to compress well on their own.
+	dictMaxFileSize = 128 * 1024
+
+	// How many bytes of a single file are used for training.
+	dictMaxSampleSize = 16 * 1024
+
+	// Training is only done with this many samples per class.
+	dictMaxSamples = 512
+
+	// Classes with fewer files are not worth a dictionary.
+	dictMinSamples = 8
+)
+
+// CompressDict describes a dictionary trained on files of one class.
+type CompressDict struct {
+	// Class is the mime type of the files the dictionary is used for.
+	Class string `json:"class"`
+	// ID is referenced by every zstd frame compressed with it.
+	ID uint32 `json:"id"`
+	// Size of the dictionary in bytes.
+	Size int `json:"size"`
+	// Samples is the number of files it was trained on.
+	Samples int `json:"samples"`
+}
+
+// dictClass returns the class used to look up a dictionary for files
+// of type `mimeType` or an empty string if they do not compress at all.
+func dictClass(mimeType string) string {
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	if compress.AlgorithmForMime(mimeType) == compress.AlgoNone {
+		return ""
+	}
+
+	return mimeType
+}
+
+func dictKey(id uint32) string {
+	return fmt.Sprintf("fs.dict.%08x", id)
+}
+
+// dictStore implements compress.DictStore on top of the metadata.
+// It may be used without holding fs.mu, since streams are read unlocked.
+type dictStore struct {
+	mu    sync.Mutex
+	lkr   *c.Linker
+	cache map[uint32][]byte
+}
+
+func newDictStore(lkr *c.Linker) *dictStore {
+	return &dictStore{
+		lkr:   lkr,
+		cache: make(map[uint32][]byte),
+	}
+}
+
+// Dict implements compress.DictStore.
+func (ds *dictStore) Dict(id uint32) ([]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if dict, ok := ds.cache[id]; ok {
+		return dict, nil
+	}
+
+	dict, err := ds.lkr.MetadataGet(dictKey(id))
+	if err == db.ErrNoSuchKey {
+		return nil, compress.ErrNoSuchDict{ID: id}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ds.cache[id] = dict
+	return dict, nil
+}
+
+// add stores `dict`, so streams that reference it can be read.
+func (ds *dictStore) add(dict []byte) (uint32, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	id := compress.DictID(dict)
+	if _, err := ds.lkr.MetadataGet(dictKey(id)); err == nil {
+		ds.cache[id] = dict
+		return id, nil
+	}
+
+	ids, err := ds.ids()
+	if err != nil {
+		return 0, err
+	}
+
+	idsData, err := json.Marshal(append(ids, id))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := ds.lkr.MetadataPut(dictKey(id), dict); err != nil {
+		return 0, err
+	}
+
+	if err := ds.lkr.MetadataPut("fs.dict-ids", idsData); err != nil {
+		return 0, err
+	}
+
+	ds.cache[id] = dict
+	return id, nil
+}
+
+// ids returns the ids of all stored dictionaries,
+// including old ones and the ones of other peers.
+func (ds *dictStore) ids() ([]uint32, error) {
+	data, err := ds.lkr.MetadataGet("fs.dict-ids")
+	if err == db.ErrNoSuchKey {
+		return []uint32{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint32{}
+	return ids, json.Unmarshal(data, &ids)
+}
+
+// trained returns the dictionaries we trained ourselves.
+func (ds *dictStore) trained() ([]CompressDict, error) {
+	data, err := ds.lkr.MetadataGet("fs.dicts")
+	if err == db.ErrNoSuchKey {
+		return []CompressDict{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	dicts := []CompressDict{}
+	return dicts, json.Unmarshal(data, &dicts)
+}
+
+// forClass returns the dictionary for `class` or nil if there is none.
+func (ds *dictStore) forClass(class string) ([]byte, error) {
+	if class == "" {
+		return nil, nil
+	}
+
+	dicts, err := ds.trained()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dict := range dicts {
+		if dict.Class == class {
+			return ds.Dict(dict.ID)
+		}
+	}
+
+	return nil, nil
+}
+
+// all returns the data of all stored dictionaries.
+// Peers need them to read our content.
+func (ds *dictStore) all() ([][]byte, error) {
+	ds.mu.Lock()
+	ids, err := ds.ids()
+	ds.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	datas := [][]byte{}
+	for _, id := range ids {
+		data, err := ds.Dict(id)
+		if err != nil {
+			return nil, err
+		}
+
+		datas = append(datas, data)
+	}
+
+	return datas, nil
+}
+
+// addAll stores all of `dicts`. Broken ones are skipped with a warning.
+func (ds *dictStore) addAll(dicts [][]byte) error {
+	for _, dict := range dicts {
+		if len(dict) == 0 || len(dict) > compress.MaxDictSize {
+			log.Warningf("ignoring compression dictionary with bad size %d", len(dict))
+			continue
+		}
+
+		if _, err := ds.add(dict); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dicts returns all dictionaries that are used for staging, sorted by class.
+func (fs *FS) Dicts() ([]CompressDict, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.dicts.trained()
+}
+
+// TrainDicts trains a compression dictionary for every class of compressible
+// files with enough small files in it, each at most `size` bytes big.
+// Dictionaries of earlier runs are replaced, but kept to be able to read
+// the files compressed with them. Only newly staged files use the new ones,
+// and only if fs.compress.dictionaries is enabled.
+func (fs *FS) TrainDicts(size int) ([]CompressDict, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if size <= 0 || size > compress.MaxDictSize {
+		return nil, e.Errorf("dictionary size has to be between 1 and %d", compress.MaxDictSize)
+	}
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	byClass := make(map[string][]*n.File)
+	err = n.Walk(fs.lkr, root, false, func(child n.Node) error {
+		file, ok := child.(*n.File)
+		if !ok || file.Size() == 0 || file.Size() > dictMaxFileSize {
+			return nil
+		}
+
+		class := dictClass(file.MimeType())
+		if class != "" && len(byClass[class]) < dictMaxSamples {
+			byClass[class] = append(byClass[class], file)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	trained := []CompressDict{}
+	for class, files := range byClass {
+		if len(files) < dictMinSamples {
+			continue
+		}
+
+		samples := [][]byte{}
+		for _, file := range files {
+			stream, err := fs.catHash(file.BackendHash(), file.Key(), file.Size())
+			if err != nil {
+				log.Warningf("dicts: skipping %s: %v", file.Path(), err)
+				continue
+			}
+
+			sample, err := ioutil.ReadAll(stream)
+			stream.Close()
+			if err != nil {
+				log.Warningf("dicts: skipping %s: %v", file.Path(), err)
+				continue
+			}
+
+			if len(sample) > dictMaxSampleSize {
+				sample = sample[:dictMaxSampleSize]
+			}
+
+			samples = append(samples, sample)
+		}
+
+		dict := compress.TrainDict(samples, size)
+		if dict == nil {
+			log.Debugf("dicts: files of type %s have nothing in common", class)
+			continue
+		}
+
+		id, err := fs.dicts.add(dict)
+		if err != nil {
+			return nil, err
+		}
+
+		trained = append(trained, CompressDict{
+			Class:   class,
+			ID:      id,
+			Size:    len(dict),
+			Samples: len(samples),
+		})
+	}
+
+	sort.Slice(trained, func(i, j int) bool {
+		return trained[i].Class < trained[j].Class
+	})
+
+	data, err := json.Marshal(trained)
+	if err != nil {
+		return nil, err
+	}
+
+	return trained, fs.lkr.MetadataPut("fs.dicts", data)
+}
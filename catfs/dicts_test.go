@@ -0,0 +1,78 @@
+package catfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sahib/brig/catfs/mio"
+	"github.com/sahib/brig/catfs/mio/compress"
+	"github.com/stretchr/testify/require"
+)
+
+func logSample(idx int) []byte {
+	buf := &bytes.Buffer{}
+	for line := 0; line < 20; line++ {
+		fmt.Fprintf(buf, "2019-03-%02d 12:00:%02d INFO request from 10.0.0.%d handled in %dms\n", idx%28+1, line, idx, line*7)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDictCompression(t *testing.T) {
+	withDummyFS(t, func(srcFs *FS) {
+		withDummyFS(t, func(dstFs *FS) {
+			require.Nil(t, srcFs.MakeCommit("init"))
+
+			for idx := 0; idx < dictMinSamples; idx++ {
+				path := fmt.Sprintf("/logs/%d.txt", idx)
+				require.Nil(t, srcFs.Stage(path, bytes.NewReader(logSample(idx))))
+			}
+
+			dicts, err := srcFs.TrainDicts(4096)
+			require.Nil(t, err)
+			require.Len(t, dicts, 1)
+			require.Equal(t, "text/plain", dicts[0].Class)
+			require.Equal(t, dictMinSamples, dicts[0].Samples)
+
+			listed, err := srcFs.Dicts()
+			require.Nil(t, err)
+			require.Equal(t, dicts, listed)
+
+			// Dictionaries are only used when enabled:
+			require.Nil(t, srcFs.cfg.SetBool("compress.dictionaries", true))
+			data := logSample(100)
+			require.Nil(t, srcFs.Stage("/logs/new.txt", bytes.NewReader(data)))
+
+			file, err := srcFs.lkr.LookupFile("/logs/new.txt")
+			require.Nil(t, err)
+
+			raw, err := srcFs.bk.Cat(file.BackendHash())
+			require.Nil(t, err)
+
+			// Without the dictionary, the file can not be read:
+			noDictStream, err := mio.NewOutStream(raw, file.Key())
+			require.Nil(t, err)
+
+			_, err = ioutil.ReadAll(noDictStream)
+			require.Equal(t, compress.ErrNoSuchDict{ID: dicts[0].ID}, err)
+
+			stream, err := srcFs.Cat("/logs/new.txt")
+			require.Nil(t, err)
+
+			readData, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, data, readData)
+			require.Nil(t, srcFs.MakeCommit("added logs"))
+
+			// The dictionary travels with the patch:
+			patch, err := srcFs.MakePatch("commit[0]", nil, "")
+			require.Nil(t, err)
+			require.Nil(t, dstFs.ApplyPatch(patch))
+
+			_, err = dstFs.dicts.Dict(dicts[0].ID)
+			require.Nil(t, err)
+		})
+	})
+}
@@ -3,23 +3,28 @@ package catfs
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/text/unicode/norm"
 	capnp "zombiezen.com/go/capnproto2"
 
 	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs/blockcache"
 	c "github.com/sahib/brig/catfs/core"
 	"github.com/sahib/brig/catfs/db"
 	ie "github.com/sahib/brig/catfs/errors"
@@ -28,6 +33,7 @@ import (
 	n "github.com/sahib/brig/catfs/nodes"
 	"github.com/sahib/brig/catfs/vcs"
 	"github.com/sahib/brig/util"
+	"github.com/sahib/brig/util/errkind"
 	h "github.com/sahib/brig/util/hashlib"
 )
 
@@ -64,6 +70,19 @@ type FS struct {
 	// channel to schedule repins and quit the loop
 	repinControl chan string
 
+	// channel to quit the snapshot loop
+	snapshotControl chan bool
+
+	// channel to quit the scrub loop
+	scrubControl chan bool
+
+	// channel to quit the tombstone purge loop
+	tombstoneControl chan bool
+
+	// status of the current or last scrub
+	scrubMu     sync.Mutex
+	scrubStatus ScrubStatus
+
 	// Actual storage backend (e.g. ipfs or memory)
 	bk FsBackend
 
@@ -76,12 +95,61 @@ type FS struct {
 	// wether this fs is read only and cannot be changed.
 	// It can be change by applying patches though.
 	readOnly bool
+
+	// wether the whole repository is in read-only mode.
+	// Other than readOnly this also forbids changes to the history,
+	// i.e. commits, tags, patches and imports.
+	frozen bool
+
+	// called after every successful MakeCommit()
+	commitHook func(msg string)
+
+	// used to get content before asking the backend for it
+	contentFetcher func(hash h.Hash) error
+
+	// keeps blocks of content that is not stored locally
+	blockCache *blockcache.Cache
+
+	// used to fetch content again that the scrubber found to be corrupt
+	contentRepairer func(hash h.Hash) error
+
+	// decides if content may be fetched from the network right now
+	transferGate func() bool
+
+	// hashes whose pre-caching was deferred by the transfer gate
+	deferredPreCache map[string]h.Hash
+
+	// number of pre-cachings running right now; only use with sync/atomic
+	preCacheRunning int64
+
+	// compression dictionaries for small files
+	dicts *dictStore
+
+	// set once Close() was called
+	closed bool
 }
 
 // ErrReadOnly is returned when a file system was created in read only mode
 // and a modifying operation was called on it.
 var ErrReadOnly = errors.New("fs is read only")
 
+// ErrFrozen is returned for modifying operations while the
+// repository is in read-only mode (see SetFrozen).
+var ErrFrozen = errors.New("repository is in read-only mode")
+
+// ErrClosed is returned by Ping after the filesystem was closed.
+var ErrClosed = errors.New("fs is closed")
+
+// ErrUnsignedCommit is returned by VerifyCommit for commits without signature.
+var ErrUnsignedCommit = errors.New("commit is not signed")
+
+// ErrStageConflict is returned by StageIfUnchanged when the node
+// was modified by somebody else in the meantime.
+var ErrStageConflict = errors.New("node was changed in the meantime")
+
+// ErrReservedTag is returned by Tag for names reserved for snapshots.
+var ErrReservedTag = errors.New("tag names starting with »snapshot-« are reserved")
+
 // StatInfo describes the metadata of a single node.
 // The concept is comparable to the POSIX stat() call.
 type StatInfo struct {
@@ -114,6 +182,24 @@ type StatInfo struct {
 	IsPinned bool
 	// IsExplicit is true when the user pinned this node on purpose
 	IsExplicit bool
+
+	// HasMode is true if Mode was set explicitly.
+	HasMode bool
+	// Mode are the POSIX permission bits of the node.
+	// os.ModeSymlink is set for symbolic links.
+	Mode os.FileMode
+	// UID is the numeric owner of the node (if HasMode is set)
+	UID uint32
+	// GID is the numeric group of the node (if HasMode is set)
+	GID uint32
+
+	// MimeType is the detected type of a file's content.
+	// It is empty for directories and for files staged by older versions.
+	MimeType string
+	// Kind is a rough category of MimeType (see ContentKind)
+	Kind string
+	// Labels are user-defined tags of the node, sorted by name.
+	Labels []string
 }
 
 // DiffPair is a pair of nodes.
@@ -147,6 +233,10 @@ type Diff struct {
 
 	// Conflict is a list of nodes that cannot be merged automatically
 	Conflict []DiffPair `json:"conflict"`
+
+	// TransferSize is the size in bytes of the content that is new to us
+	// (added files and the remote side of merged files).
+	TransferSize uint64 `json:"transfer_size"`
 }
 
 // Commit gives information about a single commit.
@@ -162,8 +252,43 @@ type Commit struct {
 	Date time.Time
 	// Index is the index of the commit:
 	Index int64
+	// Device is the name of the device that made the commit.
+	Device string
+	// Origin is the subsystem that made the commit (cli, gateway, sync...)
+	Origin string
+	// Labels is a user defined list of labels.
+	Labels []string
+}
+
+// HasLabel returns true if the commit was labeled with `label`.
+func (c *Commit) HasLabel(label string) bool {
+	for _, l := range c.Labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CommitMeta can be passed to MakeCommitWithMeta to describe
+// where a commit came from.
+type CommitMeta struct {
+	// Origin is one of the CommitOrigin* constants.
+	Origin string
+	// Labels is a free-form list of labels.
+	Labels []string
 }
 
+// Subsystems that can create a commit.
+const (
+	CommitOriginCLI     = n.CommitOriginCLI
+	CommitOriginFuse    = n.CommitOriginFuse
+	CommitOriginGateway = n.CommitOriginGateway
+	CommitOriginSync    = n.CommitOriginSync
+	CommitOriginAuto    = n.CommitOriginAuto
+)
+
 // Change describes a single change to a node between two versions
 type Change struct {
 	// Path is the node that was changed
@@ -220,6 +345,12 @@ func (fs *FS) nodeToStat(nd n.Node) *StatInfo {
 		}
 	}
 
+	mimeType := ""
+	if file, ok := nd.(*n.File); ok {
+		mimeType = file.MimeType()
+	}
+
+	uid, gid := nd.Owner()
 	return &StatInfo{
 		Path:        nd.Path(),
 		User:        nd.User(),
@@ -233,6 +364,13 @@ func (fs *FS) nodeToStat(nd n.Node) *StatInfo {
 		ContentHash: nd.ContentHash().Clone(),
 		BackendHash: nd.BackendHash().Clone(),
 		TreeHash:    nd.TreeHash().Clone(),
+		HasMode:     nd.HasMode(),
+		Mode:        nd.Mode(),
+		UID:         uid,
+		GID:         gid,
+		MimeType:    mimeType,
+		Kind:        ContentKind(mimeType),
+		Labels:      nd.Labels(),
 	}
 }
 
@@ -265,6 +403,24 @@ func (fs *FS) handleGcEvent(nd n.Node) bool {
 	}
 
 	content := file.BackendHash()
+
+	if fs.isHeld(file.Path()) {
+		return true
+	}
+
+	// Other nodes (other paths, older versions) might still use it:
+	refs, err := fs.lkr.ContentRefs(content)
+	if err != nil {
+		log.Warningf("failed to get refs of %v: %v", content.B58String(), err)
+		return true
+	}
+
+	for _, ref := range refs {
+		if !ref.Equal(file.TreeHash()) {
+			return true
+		}
+	}
+
 	log.Infof("unpinning gc'd node %v", content.B58String())
 
 	// This node will not be reachable anymore by brig.
@@ -297,10 +453,33 @@ func (fs *FS) doGcRun() {
 	}
 }
 
+// NodeCacheStats returns statistics about the nodes kept in memory.
+func (fs *FS) NodeCacheStats() c.NodeCacheStats {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.lkr.NodeCacheStats()
+}
+
+// deviceName returns the configured device name or the hostname.
+func deviceName(fsCfg *config.Config) string {
+	if name := fsCfg.String("device_name"); name != "" {
+		return name
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Debugf("failed to get hostname: %v", err)
+		return ""
+	}
+
+	return hostname
+}
+
 // NewFilesystem creates a new CATFS filesystem.
 // This filesystem stores all its data in a Merkle DAG and is fully versioned.
 func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool, fsCfg *config.Config) (*FS, error) {
-	kv, err := db.NewBadgerDatabase(dbPath)
+	kv, err := openMetadataDatabase(dbPath, fsCfg.String("metadata_backend"))
 	if err != nil {
 		return nil, err
 	}
@@ -310,11 +489,28 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 		return nil, err
 	}
 
+	lkr.SetShardThreshold(int(fsCfg.Int("dir_shard_threshold")))
+	lkr.SetNodeCacheSize(int(fsCfg.Int("node_cache_size")))
+	lkr.SetDeviceName(deviceName(fsCfg))
+
 	// NOTE: This is the place to start migrations in the future.
 	if err := lkr.SetABIVersion(abiVersion); err != nil {
 		return nil, err
 	}
 
+	// Older repositories do not have content references yet:
+	haveRefs, err := lkr.HaveContentRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	if !haveRefs {
+		log.Infof("building content references for %s", owner)
+		if err := lkr.RebuildContentRefs(); err != nil {
+			return nil, err
+		}
+	}
+
 	pinCache, err := NewPinner(lkr, backend)
 	if err != nil {
 		return nil, err
@@ -333,7 +529,11 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 		gcControl:         make(chan bool, 1),
 		autoCommitControl: make(chan bool, 1),
 		repinControl:      make(chan string, 1),
+		snapshotControl:   make(chan bool, 1),
+		scrubControl:      make(chan bool, 1),
+		tombstoneControl:  make(chan bool, 1),
 		pinner:            pinCache,
+		dicts:             newDictStore(lkr),
 	}
 
 	// Start the garbage collection background task.
@@ -344,6 +544,9 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 	go fs.gcLoop()
 	go fs.autoCommitLoop()
 	go fs.repinLoop()
+	go fs.snapshotLoop()
+	go fs.scrubLoop()
+	go fs.tombstoneLoop()
 
 	return fs, nil
 }
@@ -367,11 +570,16 @@ func (fs *FS) gcLoop() {
 	}
 }
 
+// autoCommitLoop commits staged changes in a fixed interval and, if
+// »fs.autocommit.idle« is set, once the staging area stopped changing.
 func (fs *FS) autoCommitLoop() {
 	lastCheck := time.Now()
 	checkTicker := time.NewTicker(1 * time.Second)
 	defer checkTicker.Stop()
 
+	var lastStage h.Hash
+	lastStageChange := time.Now()
+
 	for {
 		select {
 		case <-fs.autoCommitControl:
@@ -379,16 +587,33 @@ func (fs *FS) autoCommitLoop() {
 			return
 		case <-checkTicker.C:
 			isEnabled := fs.cfg.Bool("autocommit.enabled")
-			if !isEnabled {
+			if !isEnabled || fs.IsFrozen() {
 				continue
 			}
 
-			if time.Since(lastCheck) >= fs.cfg.Duration("autocommit.interval") {
-				lastCheck = time.Now()
-				msg := fmt.Sprintf("auto commit at »%s«", time.Now().Format(time.RFC822))
-				if err := fs.MakeCommit(msg); err != nil && err != ie.ErrNoChange {
-					log.Warningf("failed to create auto commit: %v", err)
+			isDue := time.Since(lastCheck) >= fs.cfg.Duration("autocommit.interval")
+			if idle := fs.cfg.Duration("autocommit.idle"); idle > 0 {
+				stage, err := fs.stageHash()
+				if err != nil {
+					log.Warningf("auto commit: %v", err)
+					continue
 				}
+
+				if !stage.Equal(lastStage) {
+					lastStage = stage
+					lastStageChange = time.Now()
+				} else if time.Since(lastStageChange) >= idle {
+					isDue = true
+				}
+			}
+
+			if !isDue {
+				continue
+			}
+
+			lastCheck = time.Now()
+			if err := fs.autoCommit(); err != nil {
+				log.Warningf("failed to create auto commit: %v", err)
 			}
 		}
 	}
@@ -443,6 +668,11 @@ func (fs *FS) Close() error {
 	go func() { fs.gcControl <- false }()
 	go func() { fs.autoCommitControl <- false }()
 	go func() { fs.repinControl <- "" }()
+	go func() { fs.snapshotControl <- false }()
+	go func() { fs.scrubControl <- false }()
+	go func() { fs.tombstoneControl <- false }()
+
+	fs.closed = true
 
 	if err := fs.pinner.Close(); err != nil {
 		log.Warnf("Failed to close pin cache: %v", err)
@@ -451,6 +681,23 @@ func (fs *FS) Close() error {
 	return fs.kv.Close()
 }
 
+// Ping checks if the metadata store is still open and readable.
+func (fs *FS) Ping() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrClosed
+	}
+
+	// A fresh filesystem might not have a HEAD yet; that's fine.
+	if _, err := fs.lkr.Head(); err != nil && !ie.IsErrNoSuchRef(err) {
+		return err
+	}
+
+	return nil
+}
+
 // Export will export a serialized version of the filesystem to `w`.
 func (fs *FS) Export(w io.Writer) error {
 	fs.mu.Lock()
@@ -464,27 +711,76 @@ func (fs *FS) Import(r io.Reader) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	if fs.frozen {
+		return ErrFrozen
+	}
+
 	if err := fs.kv.Import(r); err != nil {
 		return err
 	}
 
 	// disk (probably) changed, delete memcache:
 	fs.lkr.MemIndexClear()
-	return nil
+
+	// The import might come from a store with other or no content refs:
+	return fs.lkr.RebuildContentRefs()
 }
 
 /////////////////////
 // CORE OPERATIONS //
 /////////////////////
 
+// SetFrozen switches the read-only mode of the repository on or off.
+// While frozen, nothing may modify the fs or its history; this includes
+// operations that are allowed on fs that are only readOnly, like
+// applying patches of a remote.
+func (fs *FS) SetFrozen(frozen bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.frozen = frozen
+}
+
+// IsFrozen returns true if the repository is in read-only mode.
+func (fs *FS) IsFrozen() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.frozen
+}
+
+// checkWritable returns an error if the fs may not be modified by the user.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) checkWritable() error {
+	if fs.frozen {
+		return ErrFrozen
+	}
+
+	if fs.readOnly {
+		return ErrReadOnly
+	}
+
+	return nil
+}
+
 // Move will move the file or directory at `src` to `dst`.
 // If it does not exist, an error will be returned.
 func (fs *FS) Move(src, dst string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	src = fs.normPath(src)
+
+	// Renaming a file to a different case of its own name
+	// should stay possible, even if case does not matter otherwise:
+	if resolved := fs.normPath(dst); resolved != src {
+		dst = resolved
+	} else if fs.cfg.String("paths.unicode_normalization") == "nfc" {
+		dst = norm.NFC.String(prefixSlash(dst))
+	}
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	srcNd, err := lookupFileOrDir(fs.lkr, src)
@@ -492,6 +788,11 @@ func (fs *FS) Move(src, dst string) error {
 		return err
 	}
 
+	// Holds are bound to the path; moving would lose them.
+	if err := fs.checkNotHeld(srcNd.Path()); err != nil {
+		return err
+	}
+
 	return c.Move(fs.lkr, srcNd, dst)
 }
 
@@ -501,8 +802,11 @@ func (fs *FS) Copy(src, dst string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	src = fs.normPath(src)
+	dst = fs.normPath(dst)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	srcNd, err := lookupFileOrDir(fs.lkr, src)
@@ -520,12 +824,15 @@ func (fs *FS) Mkdir(dir string, createParents bool) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	// "brig mkdir ." somehow is able to overwrite everything:
 	dir = strings.TrimLeft(path.Clean(dir), ".")
+	if dir != "" {
+		dir = fs.normPath(dir)
+	}
 	_, err := c.Mkdir(fs.lkr, dir, createParents)
 	return err
 }
@@ -535,8 +842,10 @@ func (fs *FS) Remove(path string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	path = fs.normPath(path)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	nd, err := lookupFileOrDir(fs.lkr, path)
@@ -544,9 +853,17 @@ func (fs *FS) Remove(path string) error {
 		return err
 	}
 
-	// TODO: What should remove do with the pin state?
-	_, _, err = c.Remove(fs.lkr, nd, true, true)
-	return err
+	if err := fs.checkNotHeld(nd.Path()); err != nil {
+		return err
+	}
+
+	// The content stays pinned until the tombstone expires,
+	// so the node can be undeleted until then.
+	if _, _, err := c.Remove(fs.lkr, nd, true, true); err != nil {
+		return err
+	}
+
+	return fs.lkr.AddTombstone(nd)
 }
 
 // Stat delivers detailed information about the node at `path`.
@@ -554,6 +871,8 @@ func (fs *FS) Stat(path string) (*StatInfo, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	nd, err := fs.lkr.LookupNode(path)
 	if err != nil {
 		return nil, err
@@ -566,12 +885,49 @@ func (fs *FS) Stat(path string) (*StatInfo, error) {
 	return fs.nodeToStat(nd), nil
 }
 
+// StatAt is like Stat, but returns the info of `path` at the commit `rev`.
+// `rev` may also be a timestamp; see OpenAt for details.
+func (fs *FS) StatAt(rev, path string) (*StatInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path = fs.normPath(path)
+
+	nd, err := fs.lookupNodeAt(rev, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.nodeToStat(nd), nil
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) lookupNodeAt(rev, path string) (n.Node, error) {
+	cmt, err := parseRevOrTime(fs.lkr, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := fs.lkr.LookupNodeAt(cmt, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if nd == nil || nd.Type() == n.NodeTypeGhost {
+		return nil, ie.NoSuchFile(path)
+	}
+
+	return nd, nil
+}
+
 // Filter implements a quick and easy way to search over all files
 // by using a query that checks if it is part of the path.
 func (fs *FS) Filter(root, query string) ([]*StatInfo, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	root = fs.normPath(root)
+
 	rootNd, err := fs.lkr.LookupNode(root)
 	if err != nil {
 		return nil, err
@@ -630,6 +986,8 @@ func (fs *FS) List(root string, maxDepth int) ([]*StatInfo, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	root = fs.normPath(root)
+
 	// NOTE: This method is highly inefficient:
 	//       - iterates over all nodes even if maxDepth is >= 0
 	//
@@ -692,6 +1050,17 @@ func (fs *FS) List(root string, maxDepth int) ([]*StatInfo, error) {
 // preCache makes the backend fetch the data already from the network,
 // even though it might not be needed yet.
 func (fs *FS) preCache(hash h.Hash) error {
+	fs.mu.Lock()
+	fetcher := fs.contentFetcher
+	fs.mu.Unlock()
+
+	if fetcher != nil {
+		// The backend still gets what the fetcher could not get:
+		if err := fetcher(hash); err != nil {
+			log.Debugf("content fetcher failed for `%s`: %v", hash, err)
+		}
+	}
+
 	stream, err := fs.bk.Cat(hash)
 	if err != nil {
 		return err
@@ -701,12 +1070,25 @@ func (fs *FS) preCache(hash h.Hash) error {
 	return err
 }
 
+// preCacheInBackground must be called with fs.mu held.
 func (fs *FS) preCacheInBackground(hash h.Hash) {
 	if !fs.cfg.Bool("pre_cache.enabled") {
 		return
 	}
 
+	if fs.transferGate != nil && !fs.transferGate() {
+		if fs.deferredPreCache == nil {
+			fs.deferredPreCache = make(map[string]h.Hash)
+		}
+
+		log.Debugf("deferring pre-cache of `%s`", hash)
+		fs.deferredPreCache[hash.B58String()] = hash
+		return
+	}
+
+	atomic.AddInt64(&fs.preCacheRunning, 1)
 	go func() {
+		defer atomic.AddInt64(&fs.preCacheRunning, -1)
 		if err := fs.preCache(hash); err != nil {
 			log.Debugf("failed to pre-cache `%s`: %v", hash, err)
 		}
@@ -720,13 +1102,21 @@ func (fs *FS) Pin(path, rev string, explicit bool) error {
 
 // Unpin will unpin the file or directory at `path` explicitly.
 func (fs *FS) Unpin(path, rev string, explicit bool) error {
-	return fs.doPin(path, rev, fs.pinner.UnpinNode, explicit)
+	return fs.doPin(path, rev, func(nd n.Node, explicit bool) error {
+		if err := fs.checkNotHeld(nd.Path()); err != nil {
+			return err
+		}
+
+		return fs.pinner.UnpinNode(nd, explicit)
+	}, explicit)
 }
 
 func (fs *FS) doPin(path, rev string, op func(nd n.Node, explicit bool) error, explicit bool) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	cmt, err := parseRev(fs.lkr, rev)
 	if err != nil {
 		return err
@@ -765,6 +1155,8 @@ func (fs *FS) IsPinned(path string) (bool, bool, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	nd, err := lookupFileOrDir(fs.lkr, path)
 	if err != nil {
 		return false, false, err
@@ -790,9 +1182,11 @@ func prefixSlash(s string) string {
 func (fs *FS) Touch(path string) error {
 	fs.mu.Lock()
 
-	if fs.readOnly {
+	path = fs.normPath(path)
+
+	if err := fs.checkWritable(); err != nil {
 		fs.mu.Unlock()
-		return ErrReadOnly
+		return err
 	}
 
 	nd, err := fs.lkr.LookupNode(path)
@@ -832,8 +1226,10 @@ func (fs *FS) Truncate(path string, size uint64) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	path = fs.normPath(path)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	nd, err := fs.lkr.LookupModNode(path)
@@ -849,95 +1245,353 @@ func (fs *FS) Truncate(path string, size uint64) error {
 	return fs.lkr.StageNode(nd)
 }
 
-func (fs *FS) computePreconditions(path string, rs io.ReadSeeker) (h.Hash, uint64, compress.AlgorithmType, error) {
-	// Save a little header of the things we read,
-	// but avoid reading it twice.
-	headerBuf, pr, err := util.PeekHeader(rs, 4*1024)
-	if err != nil {
-		return nil, 0, compress.AlgoNone, err
-	}
+// Chmod sets the POSIX permission bits of the node at `path`.
+// Only the permission bits of `mode` are used; the node type is kept.
+func (fs *FS) Chmod(path string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 
-	hashWriter := h.NewHashWriter()
-	hashReader := io.TeeReader(pr, hashWriter)
+	if err := fs.checkWritable(); err != nil {
+		return err
+	}
 
-	sizeAcc := &util.SizeAccumulator{}
-	sizeReader := io.TeeReader(hashReader, sizeAcc)
+	return fs.changePermissions(path, func(nd n.ModNode) {
+		nd.SetMode(nd.Mode()&os.ModeSymlink | mode&^os.ModeType)
+	})
+}
 
-	if _, err := io.Copy(ioutil.Discard, sizeReader); err != nil {
-		return nil, 0, compress.AlgoNone, err
-	}
+// Chown sets the numeric owner and group of the node at `path`.
+func (fs *FS) Chown(path string, uid, gid uint32) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 
-	// Go back to the beginning of the file:
-	if _, err := rs.Seek(0, io.SeekStart); err != nil {
-		return nil, 0, compress.AlgoNone, err
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
-	algo, err := compress.GuessAlgorithm(path, headerBuf)
-	if err != nil {
-		// Use the default algorithm set in the config:
-		algo, err = compress.AlgoFromString(fs.cfg.String("compress.default_algo"))
-		if err != nil {
-			return nil, 0, compress.AlgoNone, err
-		}
+	return fs.changePermissions(path, func(nd n.ModNode) {
+		nd.SetOwner(uid, gid)
+	})
+}
 
-		log.Warningf("failed to guess suitable zip algo for %s: %v", path, err)
-	}
+// SetLabels replaces the user-defined labels of the node at `path`.
+// Like the mode, labels are versioned and synchronized with the node.
+func (fs *FS) SetLabels(path string, labels []string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 
-	if algo != compress.AlgoNone {
-		log.Debugf("Using '%s' compression for file %s", algo, path)
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
-	contentHash := hashWriter.Finalize()
-	size := sizeAcc.Size()
-	return contentHash, size, algo, nil
+	return fs.changePermissions(path, func(nd n.ModNode) {
+		nd.SetLabels(labels)
+	})
 }
 
-func deriveKeyFromContent(content h.Hash, size uint64) []byte {
-	salt := make([]byte, 8)
-	binary.LittleEndian.PutUint64(salt, size)
-	return util.DeriveKey(content, salt, 32)
-}
+// LabelCounts returns all labels used at or below `root`,
+// together with the number of nodes that carry them.
+func (fs *FS) LabelCounts(root string) (map[string]int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
 
-func (fs *FS) renewPins(oldFile, newFile *n.File) error {
-	pinExplicit := false
+	rootNd, err := lookupFileOrDir(fs.lkr, fs.normPath(root))
+	if err != nil {
+		return nil, err
+	}
 
-	if oldFile != nil {
-		oldBackendHash := oldFile.BackendHash()
-		if oldBackendHash.Equal(newFile.BackendHash()) {
-			// Nothing changed, nothing to do...
+	counts := make(map[string]int)
+	err = n.Walk(fs.lkr, rootNd, false, func(child n.Node) error {
+		if child.Type() == n.NodeTypeGhost {
 			return nil
 		}
 
-		_, isExplicit, err := fs.pinner.IsNodePinned(oldFile)
-		if err != nil {
-			return err
+		for _, label := range child.Labels() {
+			counts[label]++
 		}
 
-		// If the old file was pinned explicitly, we should also pin
-		// the new file explicitly to carry over that info.
-		pinExplicit = isExplicit
-
-		if !isExplicit {
-			if err := fs.pinner.UnpinNode(oldFile, pinExplicit); err != nil {
-				return err
-			}
-		}
-	}
+		return nil
+	})
 
-	return fs.pinner.PinNode(newFile, pinExplicit)
+	return counts, err
 }
 
-// Stage reads all data from `r` and stores as content of the node at `path`.
+// changePermissions calls `fn` to modify mode, owner or labels of `path`.
+// All are part of the tree hash, so the parent has to be updated too.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) changePermissions(path string, fn func(nd n.ModNode)) error {
+	path = fs.normPath(path)
+	nd, err := lookupFileOrDir(fs.lkr, path)
+	if err != nil {
+		return err
+	}
+
+	par, err := n.ParentDirectory(fs.lkr, nd)
+	if err != nil {
+		return err
+	}
+
+	fn(nd)
+	if err := nd.NotifyPermChange(fs.lkr); err != nil {
+		return err
+	}
+
+	if par != nil {
+		// Re-add the node, so the parents see the new hash:
+		if err := par.RemoveChild(fs.lkr, nd); err != nil {
+			return err
+		}
+
+		if err := par.Add(fs.lkr, nd); err != nil {
+			return err
+		}
+	}
+
+	return fs.lkr.StageNode(nd)
+}
+
+// Symlink creates a symbolic link at `path` that points to `target`.
+// Like in git, the target is stored as content of the file,
+// so links are versioned and synchronized like any other file.
+func (fs *FS) Symlink(path, target string) error {
+	if _, err := fs.Stat(path); err == nil {
+		return ie.ErrExists
+	}
+
+	if err := fs.Stage(path, strings.NewReader(target)); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.changePermissions(path, func(nd n.ModNode) {
+		nd.SetMode(os.ModeSymlink | 0777)
+	})
+}
+
+// Readlink returns the target of the symbolic link at `path`.
+func (fs *FS) Readlink(path string) (string, error) {
+	info, err := fs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Mode&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("`%s` is not a symbolic link", path)
+	}
+
+	stream, err := fs.Cat(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer stream.Close()
+
+	target, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return "", err
+	}
+
+	return string(target), nil
+}
+
+// stagePreconditions is what we need to know about a stream before staging it.
+type stagePreconditions struct {
+	contentHash  h.Hash
+	size         uint64
+	mimeType     string
+	compressAlgo compress.AlgorithmType
+	compressDict []byte
+}
+
+func (fs *FS) computePreconditions(path string, rs io.ReadSeeker, hashAlgo string) (*stagePreconditions, error) {
+	// Save a little header of the things we read,
+	// but avoid reading it twice.
+	headerBuf, pr, err := util.PeekHeader(rs, 4*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	hashWriter, err := h.NewHashWriterWithAlgo(hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+
+	hashReader := io.TeeReader(pr, hashWriter)
+
+	sizeAcc := &util.SizeAccumulator{}
+	sizeReader := io.TeeReader(hashReader, sizeAcc)
+
+	if _, err := io.Copy(ioutil.Discard, sizeReader); err != nil {
+		return nil, err
+	}
+
+	// Go back to the beginning of the file:
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// The mime type decides about the compression,
+	// but very small files are not worth it.
+	mimeType := compress.GuessMime(path, headerBuf)
+	algo := compress.AlgorithmForMime(mimeType)
+	if len(headerBuf) < compress.HeaderSizeThreshold {
+		algo = compress.AlgoNone
+	}
+
+	// Small files compress a lot better with a dictionary,
+	// if we trained one for this kind of files:
+	var dict []byte
+	// Dictionaries differ between repositories. Identical content would not
+	// result in identical blocks with them, which convergent encryption is about.
+	useDicts := fs.cfg.Bool("compress.dictionaries") && !fs.cfg.Bool("convergent_encryption")
+	if useDicts && sizeAcc.Size() <= dictMaxFileSize {
+		if dict, err = fs.dicts.forClass(dictClass(mimeType)); err != nil {
+			return nil, err
+		}
+
+		if dict != nil {
+			algo = compress.AlgoZstd
+		}
+	}
+
+	if algo != compress.AlgoNone {
+		log.Debugf("Using '%s' compression for file %s (%s)", algo, path, mimeType)
+	}
+
+	return &stagePreconditions{
+		contentHash:  hashWriter.Finalize(),
+		size:         sizeAcc.Size(),
+		mimeType:     mimeType,
+		compressAlgo: algo,
+		compressDict: dict,
+	}, nil
+}
+
+// deriveKeyFromContent derives a file key from the content hash and the size.
+// `secret` is mixed into the salt, unless it is nil. Without secret the key is
+// convergent: everyone with the same content ends up with the same key.
+func deriveKeyFromContent(content h.Hash, size uint64, secret []byte) []byte {
+	salt := make([]byte, 8, 8+len(secret))
+	binary.LittleEndian.PutUint64(salt, size)
+	salt = append(salt, secret...)
+	return util.DeriveKey(content, salt, 32)
+}
+
+// keySecret returns the secret that is mixed into the keys of new files.
+// It is random and created on first use, so the keys of two repositories
+// differ, even for the same content. It is nil with convergent encryption.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) keySecret() ([]byte, error) {
+	if fs.cfg.Bool("convergent_encryption") {
+		return nil, nil
+	}
+
+	secret, err := fs.lkr.MetadataGet("fs.key-secret")
+	if err == nil {
+		return secret, nil
+	}
+
+	if err != db.ErrNoSuchKey {
+		return nil, err
+	}
+
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, fs.lkr.MetadataPut("fs.key-secret", secret)
+}
+
+func (fs *FS) renewPins(oldFile, newFile *n.File) error {
+	pinExplicit := false
+
+	if oldFile != nil {
+		oldBackendHash := oldFile.BackendHash()
+		if oldBackendHash.Equal(newFile.BackendHash()) {
+			// Nothing changed, nothing to do...
+			return nil
+		}
+
+		_, isExplicit, err := fs.pinner.IsNodePinned(oldFile)
+		if err != nil {
+			return err
+		}
+
+		// If the old file was pinned explicitly, we should also pin
+		// the new file explicitly to carry over that info.
+		pinExplicit = isExplicit
+
+		if !isExplicit {
+			if err := fs.pinner.UnpinNode(oldFile, pinExplicit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fs.pinner.PinNode(newFile, pinExplicit)
+}
+
+// Stage reads all data from `r` and stores as content of the node at `path`.
 // If `path` already exists, it will be updated.
 func (fs *FS) Stage(path string, r io.ReadSeeker) error {
+	return fs.stage(path, r, nil)
+}
+
+// StageIfUnchanged works like Stage, but only if the tree hash of the node
+// at `path` is still `expected`. If `expected` is nil, there must not be
+// a node at `path` yet. Otherwise ErrStageConflict is returned.
+// The check is done under the same lock as the modification.
+func (fs *FS) StageIfUnchanged(path string, r io.ReadSeeker, expected h.Hash) error {
+	return fs.stage(path, r, func(path string) error {
+		return fs.checkUnchanged(path, expected)
+	})
+}
+
+// checkUnchanged returns ErrStageConflict if the node at `path`
+// does not have the tree hash `expected` (or exists when it's nil).
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) checkUnchanged(path string, expected h.Hash) error {
+	nd, err := fs.lkr.LookupNode(path)
+	if err != nil && !ie.IsNoSuchFileError(err) {
+		return err
+	}
+
+	if err != nil || nd.Type() == n.NodeTypeGhost {
+		if expected != nil {
+			return ErrStageConflict
+		}
+
+		return nil
+	}
+
+	if expected == nil || !nd.TreeHash().Equal(expected) {
+		return ErrStageConflict
+	}
+
+	return nil
+}
+
+// stage implements Stage. If `check` is not nil, it is called with the
+// final path and fs.mu locked before anything is done and again right
+// before the metadata is modified; an error aborts the staging.
+func (fs *FS) stage(path string, r io.ReadSeeker, check func(path string) error) error {
 	fs.mu.Lock()
 
-	if fs.readOnly {
+	if err := fs.checkWritable(); err != nil {
 		fs.mu.Unlock()
-		return ErrReadOnly
+		return err
 	}
 
-	path = prefixSlash(path)
+	path = fs.normPath(path)
+	if check != nil {
+		if err := check(path); err != nil {
+			fs.mu.Unlock()
+			return err
+		}
+	}
 
 	// See if we already have such a file.
 	// If not we gonna need to generate new key for it
@@ -977,31 +1631,46 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 		oldFileCopy = oldFile.Copy(oldFile.Inode()).(*n.File)
 	}
 
+	secret, err := fs.keySecret()
+	if err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+
 	// Unlock the fs lock while adding the stream to the backend.
 	// This is not required for the data integrity of the fs.
 	fs.mu.Unlock()
 
-	contentHash, size, compressAlgo, err := fs.computePreconditions(path, r)
+	// Modified files keep their hash algorithm. Otherwise the same
+	// content would look different to peers that did not re-stage it.
+	hashAlgo := fs.cfg.String("hash_algo")
+	if oldFileCopy != nil {
+		if algo := oldFileCopy.ContentHash().Algorithm(); algo != "" {
+			hashAlgo = algo
+		}
+	}
+
+	pre, err := fs.computePreconditions(path, r, hashAlgo)
 	if err != nil {
 		return err
 	}
 
+	if oldFileCopy != nil && pre.contentHash.Equal(oldFileCopy.ContentHash()) {
+		log.Infof("content of %s did not change; not modifying", path)
+		return nil
+	}
+
 	var key []byte
-	if oldFileCopy == nil {
-		// only create a new key for new files.
-		// The key depends on the content hash and the size.
-		key = deriveKeyFromContent(contentHash, size)
+	if oldFileCopy == nil || secret == nil {
+		// Only create a new key for new files. Convergent keys depend on
+		// nothing but the content though, so every generation gets a new one.
+		key = deriveKeyFromContent(pre.contentHash, pre.size, secret)
 	} else {
-		if contentHash.Equal(oldFileCopy.ContentHash()) {
-			log.Infof("content of %s did not change; not modifying", path)
-			return nil
-		}
-
 		// Next generations of the same file get the same key.
 		key = oldFileCopy.Key()
 	}
 
-	stream, err := mio.NewInStream(r, key, compressAlgo)
+	stream, err := mio.NewInStreamWithDict(r, key, pre.compressAlgo, pre.compressDict)
 	if err != nil {
 		return err
 	}
@@ -1015,7 +1684,23 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	newFile, err := c.Stage(fs.lkr, path, contentHash, backendHash, size, key)
+	// The node might have changed while the lock was not held:
+	if check != nil {
+		if err := check(path); err != nil {
+			return err
+		}
+	}
+
+	newFile, err := c.StageWithMimeType(
+		fs.lkr,
+		path,
+		pre.contentHash,
+		backendHash,
+		pre.size,
+		key,
+		pre.mimeType,
+	)
+
 	if err != nil {
 		return err
 	}
@@ -1037,6 +1722,8 @@ func (fs *FS) getTarableEntries(root string, filter func(node *StatInfo) bool) (
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	root = fs.normPath(root)
+
 	rootNd, err := fs.lkr.LookupNode(root)
 	if err != nil {
 		return nil, "", err
@@ -1144,6 +1831,8 @@ func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) er
 func (fs *FS) Cat(path string) (mio.Stream, error) {
 	fs.mu.Lock()
 
+	path = fs.normPath(path)
+
 	file, err := fs.lkr.LookupFile(path)
 	if err == ie.ErrBadNode {
 		fs.mu.Unlock()
@@ -1166,14 +1855,60 @@ func (fs *FS) Cat(path string) (mio.Stream, error) {
 	return fs.catHash(backendHash, key, size)
 }
 
+// CatAt is like Cat, but returns the content of `path` at the commit `rev`.
+// `rev` may also be a timestamp; see OpenAt for details.
+func (fs *FS) CatAt(rev, path string) (mio.Stream, error) {
+	fs.mu.Lock()
+
+	path = fs.normPath(path)
+
+	nd, err := fs.lookupNodeAt(rev, path)
+	if err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+
+	file, ok := nd.(*n.File)
+	if !ok {
+		fs.mu.Unlock()
+		return nil, ie.ErrBadNode
+	}
+
+	// Copy all attributes, since accessing them beyond the lock might be racy.
+	size := file.Size()
+	backendHash := file.BackendHash().Clone()
+	key := make([]byte, len(file.Key()))
+	copy(key, file.Key())
+
+	fs.mu.Unlock()
+
+	return fs.catHash(backendHash, key, size)
+}
+
 // NOTE: This method can be called without locking fs.mu!
+// rawCat returns the stream of `backendHash` as stored in the backend.
+// Content that is not stored locally is read through the block cache, if any.
+func (fs *FS) rawCat(backendHash h.Hash) (mio.Stream, error) {
+	if fs.blockCache == nil {
+		return fs.bk.Cat(backendHash)
+	}
+
+	if isCached, err := fs.bk.IsCached(backendHash); err == nil && isCached {
+		return fs.bk.Cat(backendHash)
+	}
+
+	return blockcache.NewStream(fs.blockCache, backendHash, func() (mio.Stream, error) {
+		return fs.bk.Cat(backendHash)
+	}), nil
+}
+
 func (fs *FS) catHash(backendHash h.Hash, key []byte, size uint64) (mio.Stream, error) {
-	rawStream, err := fs.bk.Cat(backendHash)
+	rawStream, err := fs.rawCat(backendHash)
 	if err != nil {
 		return nil, err
 	}
 
-	stream, err := mio.NewOutStream(rawStream, key)
+	stream, err := mio.NewOutStreamWithDicts(rawStream, key, fs.dicts)
 	if err != nil {
 		return nil, err
 	}
@@ -1190,6 +1925,8 @@ func (fs *FS) Open(path string) (*Handle, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	nd, err := fs.lkr.LookupNode(path)
 	if err != nil {
 		return nil, err
@@ -1200,7 +1937,31 @@ func (fs *FS) Open(path string) (*Handle, error) {
 		return nil, fmt.Errorf("Can only open files: %v", path)
 	}
 
-	return newHandle(fs, file, fs.readOnly), nil
+	return newHandle(fs, file, fs.readOnly || fs.frozen), nil
+}
+
+// OpenAt is like Open, but opens `path` as it was at `at`.
+// `at` can be anything a rev accepts (HEAD, a hash, commit[2], ...)
+// or a timestamp like "2023-01-01" or "2023-01-01T12:00:00Z". A
+// timestamp resolves to the last commit made at or before that time.
+// The returned handle is always read-only.
+func (fs *FS) OpenAt(path, at string) (*Handle, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path = fs.normPath(path)
+
+	nd, err := fs.lookupNodeAt(at, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := nd.(*n.File)
+	if !ok {
+		return nil, fmt.Errorf("Can only open files: %v", path)
+	}
+
+	return newHandle(fs, file, true), nil
 }
 
 ////////////////////
@@ -1211,15 +1972,139 @@ func (fs *FS) Open(path string) (*Handle, error) {
 // If no changes were made since the last call to MakeCommit() ErrNoConflict
 // is returned.
 func (fs *FS) MakeCommit(msg string) error {
+	return fs.MakeCommitWithMeta(msg, CommitMeta{})
+}
+
+// MakeCommitWithMeta is like MakeCommit, but stores `meta` in the new commit.
+func (fs *FS) MakeCommitWithMeta(msg string, meta CommitMeta) error {
 	fs.mu.Lock()
-	defer fs.mu.Unlock()
+
+	if fs.frozen {
+		fs.mu.Unlock()
+		return ErrFrozen
+	}
 
 	owner, err := fs.lkr.Owner()
+	if err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+
+	nmeta := n.CommitMeta{Origin: meta.Origin, Labels: meta.Labels}
+	if err := fs.lkr.MakeCommitWithMeta(owner, msg, nmeta); err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+
+	hook := fs.commitHook
+	fs.mu.Unlock()
+
+	// Call the hook without the lock, so it may use the fs.
+	if hook != nil {
+		hook(msg)
+	}
+
+	return nil
+}
+
+// SetCommitHook sets a function that is called after every commit
+// made by MakeCommit(). Only one hook can be set; nil removes it.
+func (fs *FS) SetCommitHook(hook func(msg string)) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.commitHook = hook
+}
+
+// SetBlockCache sets the cache for content that is read from remotes.
+// The cache may be shared between several filesystems. nil disables it.
+func (fs *FS) SetBlockCache(cache *blockcache.Cache) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.blockCache = cache
+}
+
+// SetContentFetcher sets a function that is asked to get the content
+// `hash` into the backend before it is pre-cached. nil removes it.
+func (fs *FS) SetContentFetcher(fetcher func(hash h.Hash) error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.contentFetcher = fetcher
+}
+
+// SetTransferGate sets a function that decides if content may be fetched
+// from the network right now. Pre-caching that is not allowed is deferred
+// until RunDeferredPreCache() is called. nil allows everything.
+func (fs *FS) SetTransferGate(gate func() bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.transferGate = gate
+}
+
+// PreCacheQueue returns how many pre-cachings are running right now
+// and how many are deferred by the transfer gate.
+func (fs *FS) PreCacheQueue() (running, deferred int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return int(atomic.LoadInt64(&fs.preCacheRunning)), len(fs.deferredPreCache)
+}
+
+// RunDeferredPreCache starts all pre-caching that was deferred by the
+// transfer gate and returns how many were started. Nothing is done
+// while the gate still says no.
+func (fs *FS) RunDeferredPreCache() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if len(fs.deferredPreCache) == 0 {
+		return 0
+	}
+
+	if fs.transferGate != nil && !fs.transferGate() {
+		return 0
+	}
+
+	deferred := fs.deferredPreCache
+	fs.deferredPreCache = nil
+	for _, hash := range deferred {
+		fs.preCacheInBackground(hash)
+	}
+
+	return len(deferred)
+}
+
+// SetCommitSigner sets a function that signs the hash of every new commit.
+// If it returns a nil signature, the commit stays unsigned.
+func (fs *FS) SetCommitSigner(signer func(hash []byte) ([]byte, error)) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.lkr.SetCommitSigner(signer)
+}
+
+// VerifyCommit checks the signature of the commit at `rev` with `verify`.
+// Since every commit hash covers the hash of its parent, a valid signature
+// of HEAD also vouches for the history before it.
+// If the commit has no signature, ErrUnsignedCommit is returned.
+func (fs *FS) VerifyCommit(rev string, verify func(hash, sig []byte) error) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	cmt, err := parseRev(fs.lkr, rev)
 	if err != nil {
 		return err
 	}
 
-	return fs.lkr.MakeCommit(owner, msg)
+	sig := cmt.Signature()
+	if len(sig) == 0 {
+		return ErrUnsignedCommit
+	}
+
+	return verify(cmt.TreeHash().Bytes(), sig)
 }
 
 func (fs *FS) isMove(nd n.ModNode) (bool, error) {
@@ -1253,6 +2138,8 @@ func (fs *FS) DeletedNodes(root string) ([]*StatInfo, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	root = fs.normPath(root)
+
 	rootNd, err := fs.lkr.LookupNode(root)
 	if err != nil {
 		return nil, err
@@ -1295,14 +2182,20 @@ func (fs *FS) Undelete(root string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	root = fs.normPath(root)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	if err := vcs.Undelete(fs.lkr, root); err != nil {
 		return err
 	}
 
+	if err := fs.lkr.RemoveTombstone(root); err != nil {
+		return err
+	}
+
 	nd, err := fs.lkr.LookupModNode(root)
 	if err != nil {
 		return err
@@ -1343,12 +2236,16 @@ func commitToExternal(cmt *n.Commit, hashToRef map[string][]string) *Commit {
 		tags = hashToRef[cmt.TreeHash().B58String()]
 	}
 
+	meta := cmt.Meta()
 	return &Commit{
-		Hash:  cmt.TreeHash().Clone(),
-		Msg:   cmt.Message(),
-		Tags:  tags,
-		Date:  cmt.ModTime(),
-		Index: cmt.Index(),
+		Hash:   cmt.TreeHash().Clone(),
+		Msg:    cmt.Message(),
+		Tags:   tags,
+		Date:   cmt.ModTime(),
+		Index:  cmt.Index(),
+		Device: meta.Device,
+		Origin: meta.Origin,
+		Labels: meta.Labels,
 	}
 }
 
@@ -1357,6 +2254,8 @@ func (fs *FS) History(path string) ([]Change, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	nd, err := fs.lkr.LookupModNode(path)
 	if err != nil {
 		return nil, err
@@ -1432,7 +2331,7 @@ func (fs *FS) buildSyncCfg() (*vcs.SyncOptions, error) {
 	)
 
 	if conflictStrategy == vcs.ConflictStragetyUnknown {
-		return nil, fmt.Errorf("unknown conflict strategy: %v", conflictStrategy)
+		return nil, errkind.Wrap(errkind.Invalid, fmt.Errorf("unknown conflict strategy: %v", conflictStrategy))
 	}
 
 	return &vcs.SyncOptions{
@@ -1444,6 +2343,11 @@ func (fs *FS) buildSyncCfg() (*vcs.SyncOptions, error) {
 			return true
 		},
 		OnRemove: func(oldNd n.ModNode) bool {
+			if err := fs.checkNotHeld(oldNd.Path()); err != nil {
+				log.Infof("not removing %s on sync: %v", oldNd.Path(), err)
+				return false
+			}
+
 			doPinOrUnpin(false, true, oldNd)
 			return true
 		},
@@ -1496,6 +2400,71 @@ func SyncOptConflictStrategy(strategy string) SyncOption {
 	}
 }
 
+// SyncOptIgnore overwrites fs.sync.ignore_removed and fs.sync.ignore_moved.
+func SyncOptIgnore(removed, moved bool) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		cfg.IgnoreDeletes = removed
+		cfg.IgnoreMoves = moved
+	}
+}
+
+// SyncConflict describes a node that was changed on both sides of a sync.
+type SyncConflict struct {
+	// Path is the path of the node on our side.
+	Path string
+
+	// Strategy is the conflict strategy that was applied
+	// ("marker", "ignore" or "embrace").
+	Strategy string
+
+	OwnHash       h.Hash
+	RemoteHash    h.Hash
+	OwnModTime    time.Time
+	RemoteModTime time.Time
+}
+
+func newSyncConflict(src, dst n.ModNode, cs vcs.ConflictStrategy) SyncConflict {
+	return SyncConflict{
+		Path:          dst.Path(),
+		Strategy:      cs.String(),
+		OwnHash:       dst.ContentHash().Clone(),
+		RemoteHash:    src.ContentHash().Clone(),
+		OwnModTime:    dst.ModTime(),
+		RemoteModTime: src.ModTime(),
+	}
+}
+
+// SyncOptOnConflict calls `fn` for every conflict of the sync,
+// after its strategy was decided and before it is applied.
+// NOTE: `fn` is called with fs.mu locked and may not use the fs.
+func SyncOptOnConflict(fn func(conflict SyncConflict)) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		prev := cfg.OnConflictResolved
+		cfg.OnConflictResolved = func(src, dst n.ModNode, cs vcs.ConflictStrategy) {
+			if prev != nil {
+				prev(src, dst, cs)
+			}
+
+			fn(newSyncConflict(src, dst, cs))
+		}
+	}
+}
+
+// SyncOptConflictResolver lets `fn` decide the strategy of every conflict.
+// The Strategy field of the conflict it gets is the one that would be
+// used otherwise. If `fn` returns an empty or unknown strategy, this one
+// is used.
+// NOTE: `fn` is called with fs.mu locked and may not use the fs.
+func SyncOptConflictResolver(fn func(conflict SyncConflict) string) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		cfg.ResolveConflict = func(src, dst n.ModNode) vcs.ConflictStrategy {
+			// Tell what the configured strategies would do:
+			fallback := cfg.StrategyFor(dst.Path())
+			return vcs.ConflictStrategyFromString(fn(newSyncConflict(src, dst, fallback)))
+		}
+	}
+}
+
 // SyncOptReadOnlyFolders allows you to set a set of folders
 // that will be protected from modifications by the sync.
 func SyncOptReadOnlyFolders(folders []string) SyncOption {
@@ -1537,8 +2506,8 @@ func (fs *FS) Sync(remote *FS, options ...SyncOption) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	// build default config from the defaults/base config:
@@ -1551,7 +2520,22 @@ func (fs *FS) Sync(remote *FS, options ...SyncOption) error {
 		option(syncCfg)
 	}
 
-	return vcs.Sync(remote.lkr, fs.lkr, syncCfg)
+	if err := vcs.Sync(remote.lkr, fs.lkr, syncCfg); err != nil {
+		return err
+	}
+
+	// Counters are no part of the tree, so they are merged separately:
+	if err := fs.lkr.MergeCounters(remote.lkr); err != nil {
+		return err
+	}
+
+	// The synced files might be compressed with their dictionaries:
+	remoteDicts, err := remote.dicts.all()
+	if err != nil {
+		return err
+	}
+
+	return fs.dicts.addAll(remoteDicts)
 }
 
 // MakeDiff will return a diff between `headRevOwn` and `headRevRemote`.
@@ -1580,6 +2564,36 @@ func (fs *FS) MakeDiff(remote *FS, headRevOwn, headRevRemote string) (*Diff, err
 		return nil, e.Wrapf(err, "make diff")
 	}
 
+	return fs.convertDiff(realDiff), nil
+}
+
+// SyncPreview tells what Sync() with the same `options` would do
+// to the current state, without changing anything.
+func (fs *FS) SyncPreview(remote *FS, options ...SyncOption) (*Diff, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	syncCfg, err := fs.buildSyncCfg()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		option(syncCfg)
+	}
+
+	// Like Sync(), compare their HEAD with our CURR:
+	realDiff, err := vcs.MakeDiff(remote.lkr, fs.lkr, nil, nil, syncCfg)
+	if err != nil {
+		return nil, e.Wrapf(err, "make diff")
+	}
+
+	return fs.convertDiff(realDiff), nil
+}
+
+// convertDiff converts the internal diff to the one we give to the outside.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) convertDiff(realDiff *vcs.Diff) *Diff {
 	// "fake" is the diff that we give to the outside.
 	// Internally we have a bit more knowledge.
 	fakeDiff := &Diff{}
@@ -1623,7 +2637,39 @@ func (fs *FS) MakeDiff(remote *FS, headRevOwn, headRevRemote string) (*Diff, err
 		})
 	}
 
-	return fakeDiff, nil
+	// Content that is new to us: added nodes and the remote side of merges.
+	// The size of a directory already includes all of its children.
+	addedDirs := make(map[string]bool)
+	for _, info := range fakeDiff.Added {
+		if info.IsDir {
+			addedDirs[info.Path] = true
+		}
+	}
+
+	for _, info := range fakeDiff.Added {
+		if !hasAddedParent(addedDirs, info.Path) {
+			fakeDiff.TransferSize += info.Size
+		}
+	}
+
+	for _, pair := range fakeDiff.Merged {
+		if !pair.Src.IsDir && !pair.Src.ContentHash.Equal(pair.Dst.ContentHash) {
+			fakeDiff.TransferSize += pair.Src.Size
+		}
+	}
+
+	return fakeDiff
+}
+
+func hasAddedParent(addedDirs map[string]bool, nodePath string) bool {
+	for nodePath != "/" && nodePath != "." {
+		nodePath = path.Dir(nodePath)
+		if addedDirs[nodePath] {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (fs *FS) buildCommitHashToRefTable() (map[string][]string, error) {
@@ -1688,8 +2734,10 @@ func (fs *FS) Reset(path, rev string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.readOnly {
-		return ErrReadOnly
+	path = fs.normPath(path)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
 	}
 
 	if path == "/" || path == "" {
@@ -1726,12 +2774,99 @@ func (fs *FS) Reset(path, rev string) error {
 	return fs.pinner.PinNode(newNode, false)
 }
 
+// SavedNode is a copy of a node as it was in the staging area,
+// including changes that were not committed yet.
+type SavedNode struct {
+	path string
+	data []byte
+}
+
+// SaveNode remembers the node at `path`, so it can be put back with
+// RestoreNode later. If there is no node at `path`, RestoreNode will
+// remove whatever is there by then. Directories can not be saved.
+func (fs *FS) SaveNode(path string) (*SavedNode, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path = fs.normPath(path)
+	nd, err := fs.lkr.LookupModNode(path)
+	if ie.IsNoSuchFileError(err) {
+		return &SavedNode{path: path}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nd.Type() == n.NodeTypeDirectory {
+		return nil, fmt.Errorf("cannot save directory: %s", path)
+	}
+
+	data, err := n.MarshalNode(nd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SavedNode{path: path, data: data}, nil
+}
+
+// RestoreNode puts back the node that was saved by SaveNode.
+func (fs *FS) RestoreNode(saved *SavedNode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.checkWritable(); err != nil {
+		return err
+	}
+
+	var oldNode n.ModNode
+	if saved.data != nil {
+		nd, err := n.UnmarshalNode(saved.data)
+		if err != nil {
+			return err
+		}
+
+		modNd, ok := nd.(n.ModNode)
+		if !ok {
+			return ie.ErrBadNode
+		}
+
+		oldNode = modNd
+	}
+
+	currNode, err := fs.lkr.LookupNode(saved.path)
+	if err != nil && !ie.IsNoSuchFileError(err) {
+		return err
+	}
+
+	// Whatever took the place of the saved node is not needed anymore:
+	if currNode != nil && currNode.Type() != n.NodeTypeGhost {
+		if err := fs.pinner.UnpinNode(currNode, false); err != nil {
+			return err
+		}
+	}
+
+	if err := vcs.ReplaceNode(fs.lkr, saved.path, oldNode); err != nil {
+		return err
+	}
+
+	if oldNode != nil && oldNode.Type() != n.NodeTypeGhost {
+		return fs.pinner.PinNode(oldNode, false)
+	}
+
+	return nil
+}
+
 // Checkout reverts all state to the commit referenced by `rev`.
 // If `force` is true a non-empty staging area will be overwritten.
 func (fs *FS) Checkout(rev string, force bool) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	if fs.frozen {
+		return ErrFrozen
+	}
+
 	return fs.checkout(rev, force)
 }
 
@@ -1756,6 +2891,14 @@ func (fs *FS) Tag(rev, name string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	if fs.frozen {
+		return ErrFrozen
+	}
+
+	if isSnapshotTag(name) {
+		return ErrReservedTag
+	}
+
 	cmt, err := parseRev(fs.lkr, rev)
 	if err != nil {
 		return e.Wrap(err, "parse ref")
@@ -1769,9 +2912,22 @@ func (fs *FS) RemoveTag(name string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	if fs.frozen {
+		return ErrFrozen
+	}
+
 	return fs.lkr.RemoveRef(name)
 }
 
+// ContentRefCount returns how many file nodes (in the staging area or in
+// any commit) reference the content hash `content`.
+func (fs *FS) ContentRefCount(content h.Hash) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.lkr.ContentRefCount(content)
+}
+
 // FilesByContent returns all stat info for the content hashes referenced in
 // `contents`.  The return value is a map with the content hash as key and a
 // StatInfo describing the exact file content.
@@ -1838,7 +2994,8 @@ func (fs *FS) MakePatch(fromRev string, folders []string, remoteName string) ([]
 		}
 
 		msg := fmt.Sprintf("»%s« merged with you", remoteName)
-		if err := fs.lkr.MakeCommit(owner, msg); err != nil {
+		meta := n.CommitMeta{Origin: n.CommitOriginSync}
+		if err := fs.lkr.MakeCommitWithMeta(owner, msg, meta); err != nil {
 			return nil, err
 		}
 	}
@@ -1853,6 +3010,10 @@ func (fs *FS) MakePatch(fromRev string, folders []string, remoteName string) ([]
 		return nil, err
 	}
 
+	if patch.Dicts, err = fs.dicts.all(); err != nil {
+		return nil, err
+	}
+
 	msg, err := patch.ToCapnp()
 	if err != nil {
 		return nil, err
@@ -1866,6 +3027,15 @@ func (fs *FS) ApplyPatch(data []byte) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	return fs.applyPatch(data)
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) applyPatch(data []byte) error {
+	if fs.frozen {
+		return ErrFrozen
+	}
+
 	msg, err := capnp.Unmarshal(data)
 	if err != nil {
 		return err
@@ -1876,6 +3046,10 @@ func (fs *FS) ApplyPatch(data []byte) error {
 		return err
 	}
 
+	if err := fs.dicts.addAll(patch.Dicts); err != nil {
+		return err
+	}
+
 	if err := vcs.ApplyPatch(fs.lkr, patch); err != nil {
 		return err
 	}
@@ -1894,7 +3068,8 @@ func (fs *FS) ApplyPatch(data []byte) error {
 	}
 
 	cmtMsg := fmt.Sprintf("apply patch with %d changes", len(patch.Changes))
-	if err := fs.lkr.MakeCommit(owner, cmtMsg); err != nil {
+	meta := n.CommitMeta{Origin: n.CommitOriginSync}
+	if err := fs.lkr.MakeCommitWithMeta(owner, cmtMsg, meta); err != nil {
 		// An empty patch is perfectly valid (though unusual):
 		if err == ie.ErrNoChange {
 			return nil
@@ -1912,6 +3087,11 @@ func (fs *FS) LastPatchIndex() (int64, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	return fs.lastPatchIndex()
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) lastPatchIndex() (int64, error) {
 	fromIndexData, err := fs.lkr.MetadataGet("fs.last-merge-index")
 	if err != nil && err != db.ErrNoSuchKey {
 		return -1, err
@@ -1957,6 +3137,8 @@ func (fs *FS) IsCached(path string) (bool, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	path = fs.normPath(path)
+
 	nd, err := fs.lkr.LookupNode(path)
 	if err != nil {
 		return false, err
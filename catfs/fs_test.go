@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"path"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/sahib/brig/catfs/blockcache"
 	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/db"
 	ie "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/catfs/mio"
 	"github.com/sahib/brig/catfs/mio/chunkbuf"
@@ -185,6 +190,258 @@ func TestCat(t *testing.T) {
 	})
 }
 
+func TestStatAtCatAt(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.MakeCommit("1"))
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{2, 3})))
+		require.Nil(t, fs.MakeCommit("2"))
+
+		info, err := fs.StatAt("HEAD^", "/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), info.Size)
+
+		stream, err := fs.CatAt("HEAD^", "/x")
+		require.Nil(t, err)
+
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Nil(t, stream.Close())
+		require.Equal(t, []byte{1}, data)
+
+		stream, err = fs.CatAt("HEAD", "/x")
+		require.Nil(t, err)
+
+		data, err = ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Nil(t, stream.Close())
+		require.Equal(t, []byte{2, 3}, data)
+
+		_, err = fs.StatAt("HEAD^", "/y")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		require.Nil(t, fs.Mkdir("/dir", false))
+		require.Nil(t, fs.MakeCommit("3"))
+		_, err = fs.CatAt("HEAD", "/dir")
+		require.Equal(t, ie.ErrBadNode, err)
+	})
+}
+
+func TestOpenAt(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.MakeCommit("1"))
+		between := time.Now()
+
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{2, 3})))
+		require.Nil(t, fs.MakeCommit("2"))
+
+		for _, at := range []string{"HEAD^", between.Format(time.RFC3339Nano)} {
+			hdl, err := fs.OpenAt("/x", at)
+			require.Nil(t, err)
+
+			data, err := ioutil.ReadAll(hdl)
+			require.Nil(t, err)
+			require.Equal(t, []byte{1}, data)
+
+			_, err = hdl.Write([]byte{4})
+			require.Equal(t, ErrReadOnly, err)
+			require.Nil(t, hdl.Close())
+		}
+
+		stream, err := fs.CatAt(time.Now().Format(time.RFC3339Nano), "/x")
+		require.Nil(t, err)
+
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Nil(t, stream.Close())
+		require.Equal(t, []byte{2, 3}, data)
+
+		_, err = fs.OpenAt("/x", "1990-01-01")
+		require.True(t, ie.IsErrNoSuchRef(err))
+	})
+}
+
+func TestConvergentEncryption(t *testing.T) {
+	t.Parallel()
+
+	backendHashes := func(convergent bool) (h.Hash, h.Hash) {
+		var aHash, bHash h.Hash
+		withDummyFS(t, func(aFs *FS) {
+			withDummyFS(t, func(bFs *FS) {
+				require.Nil(t, aFs.cfg.SetBool("convergent_encryption", convergent))
+				require.Nil(t, bFs.cfg.SetBool("convergent_encryption", convergent))
+
+				// Modify an existing file on one side, to make sure
+				// that later generations are convergent too:
+				require.Nil(t, aFs.Stage("/x", bytes.NewReader([]byte("old"))))
+				require.Nil(t, aFs.Stage("/x", bytes.NewReader([]byte("same"))))
+				require.Nil(t, bFs.Stage("/y", bytes.NewReader([]byte("same"))))
+
+				aInfo, err := aFs.Stat("/x")
+				require.Nil(t, err)
+				bInfo, err := bFs.Stat("/y")
+				require.Nil(t, err)
+
+				aHash, bHash = aInfo.BackendHash, bInfo.BackendHash
+			})
+		})
+
+		return aHash, bHash
+	}
+
+	aHash, bHash := backendHashes(false)
+	require.False(t, aHash.Equal(bHash))
+
+	aHash, bHash = backendHashes(true)
+	require.True(t, aHash.Equal(bHash))
+}
+
+func TestKeySecretByDefault(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		// Convergent encryption has to be enabled explicitly:
+		require.False(t, fs.cfg.Bool("convergent_encryption"))
+
+		_, err := fs.lkr.MetadataGet("fs.key-secret")
+		require.Equal(t, db.ErrNoSuchKey, err)
+
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+
+		secret, err := fs.lkr.MetadataGet("fs.key-secret")
+		require.Nil(t, err)
+		require.Len(t, secret, 32)
+
+		file, err := fs.lkr.LookupFile("/x")
+		require.Nil(t, err)
+		require.Equal(t, deriveKeyFromContent(file.ContentHash(), file.Size(), secret), file.Key())
+		require.NotEqual(t, deriveKeyFromContent(file.ContentHash(), file.Size(), nil), file.Key())
+
+		// The secret is created once and then kept:
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("world"))))
+		again, err := fs.lkr.MetadataGet("fs.key-secret")
+		require.Nil(t, err)
+		require.Equal(t, secret, again)
+	})
+}
+
+func TestSymlinkAndPermissions(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Symlink("/link", "x"))
+		require.Equal(t, ie.ErrExists, fs.Symlink("/link", "y"))
+
+		target, err := fs.Readlink("/link")
+		require.Nil(t, err)
+		require.Equal(t, "x", target)
+
+		_, err = fs.Readlink("/x")
+		require.NotNil(t, err)
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+		require.Equal(t, os.FileMode(0), info.Mode)
+
+		require.Nil(t, fs.Chmod("/x", 0640))
+		require.Nil(t, fs.Chown("/x", 1000, 100))
+
+		// Changing the content should keep the metadata:
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{2})))
+		info, err = fs.Stat("/x")
+		require.Nil(t, err)
+		require.Equal(t, os.FileMode(0640), info.Mode)
+		require.Equal(t, uint32(1000), info.UID)
+		require.Equal(t, uint32(100), info.GID)
+
+		// chmod on a link should not make it a regular file:
+		require.Nil(t, fs.Chmod("/link", 0700))
+		info, err = fs.Stat("/link")
+		require.Nil(t, err)
+		require.Equal(t, os.ModeSymlink|0700, info.Mode)
+	})
+}
+
+func TestPermissionsAreVersioned(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.MakeCommit("add x"))
+
+		info, err := fs.Stat("/dir/x")
+		require.Nil(t, err)
+		require.False(t, info.HasMode)
+
+		// A chmod alone is a change worth a commit:
+		require.Nil(t, fs.Chmod("/dir/x", 0600))
+		require.Nil(t, fs.MakeCommit("chmod x"))
+
+		require.Nil(t, fs.Chown("/dir", 1000, 100))
+		require.Nil(t, fs.Chmod("/dir", 0700))
+		require.Nil(t, fs.MakeCommit("chmod dir"))
+
+		// A mode of zero is a valid mode, not "unset":
+		require.Nil(t, fs.Chmod("/dir/x", 0))
+		require.Nil(t, fs.MakeCommit("chmod x to 0"))
+
+		info, err = fs.Stat("/dir/x")
+		require.Nil(t, err)
+		require.True(t, info.HasMode)
+		require.Equal(t, os.FileMode(0), info.Mode)
+
+		// Setting the same mode again changes nothing:
+		require.Nil(t, fs.Chmod("/dir/x", 0))
+		require.Equal(t, ie.ErrNoChange, fs.MakeCommit("nothing"))
+	})
+}
+
+func TestLabels(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Stage("/dir/y", bytes.NewReader([]byte{2})))
+		require.Nil(t, fs.MakeCommit("add x and y"))
+
+		require.Nil(t, fs.SetLabels("/dir/x", []string{"work", " urgent ", "", "work"}))
+		require.Nil(t, fs.SetLabels("/dir/y", []string{"work"}))
+		require.Nil(t, fs.MakeCommit("label x and y"))
+
+		info, err := fs.Stat("/dir/x")
+		require.Nil(t, err)
+		require.Equal(t, []string{"urgent", "work"}, info.Labels)
+
+		// Labels stay with the node on content changes and moves:
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte{3})))
+		require.Nil(t, fs.Move("/dir/x", "/z"))
+		info, err = fs.Stat("/z")
+		require.Nil(t, err)
+		require.Equal(t, []string{"urgent", "work"}, info.Labels)
+
+		counts, err := fs.LabelCounts("/")
+		require.Nil(t, err)
+		require.Equal(t, map[string]int{"urgent": 1, "work": 2}, counts)
+
+		counts, err = fs.LabelCounts("/dir")
+		require.Nil(t, err)
+		require.Equal(t, map[string]int{"work": 1}, counts)
+
+		// Setting the same labels again changes nothing:
+		require.Nil(t, fs.MakeCommit("move"))
+		require.Nil(t, fs.SetLabels("/z", []string{"work", "urgent"}))
+		require.Equal(t, ie.ErrNoChange, fs.MakeCommit("nothing"))
+
+		require.Nil(t, fs.SetLabels("/z", nil))
+		require.Nil(t, fs.MakeCommit("unlabel z"))
+		info, err = fs.Stat("/z")
+		require.Nil(t, err)
+		require.Empty(t, info.Labels)
+	})
+}
+
 func TestStage(t *testing.T) {
 	t.Parallel()
 
@@ -236,6 +493,38 @@ func TestStage(t *testing.T) {
 	}
 }
 
+func TestStageMimeType(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0}
+		require.Nil(t, fs.Stage("/pic", bytes.NewReader(png)))
+		require.Nil(t, fs.Stage("/notes.txt", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.Mkdir("/dir", false))
+
+		info, err := fs.Stat("/pic")
+		require.Nil(t, err)
+		require.Equal(t, "image/png", info.MimeType)
+		require.Equal(t, KindImage, info.Kind)
+
+		info, err = fs.Stat("/notes.txt")
+		require.Nil(t, err)
+		require.Equal(t, "text/plain; charset=utf-8", info.MimeType)
+		require.Equal(t, KindText, info.Kind)
+
+		info, err = fs.Stat("/dir")
+		require.Nil(t, err)
+		require.Equal(t, "", info.MimeType)
+
+		// The type has to survive a commit and a copy:
+		require.Nil(t, fs.MakeCommit("add"))
+		require.Nil(t, fs.Copy("/pic", "/pic-copy"))
+		info, err = fs.Stat("/pic-copy")
+		require.Nil(t, err)
+		require.Equal(t, "image/png", info.MimeType)
+	})
+}
+
 func TestHistory(t *testing.T) {
 	t.Parallel()
 
@@ -330,6 +619,50 @@ func TestReset(t *testing.T) {
 	})
 }
 
+func TestSaveRestoreNode(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Stage("/gone", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.MakeCommit("1"))
+
+		// Uncommitted changes are saved as well:
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{2})))
+		require.Nil(t, fs.Remove("/gone"))
+
+		savedX, err := fs.SaveNode("/x")
+		require.Nil(t, err)
+		savedGone, err := fs.SaveNode("/gone")
+		require.Nil(t, err)
+		savedNew, err := fs.SaveNode("/dir")
+		require.Nil(t, err)
+
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{3})))
+		require.Nil(t, fs.Stage("/gone", bytes.NewReader([]byte{3})))
+		require.Nil(t, fs.Stage("/dir/y", bytes.NewReader([]byte{3})))
+
+		require.Nil(t, fs.RestoreNode(savedNew))
+		require.Nil(t, fs.RestoreNode(savedGone))
+		require.Nil(t, fs.RestoreNode(savedX))
+
+		require.Equal(t, []byte{2}, mustReadPath(t, fs, "/x"))
+
+		_, err = fs.Stat("/dir")
+		require.True(t, ie.IsNoSuchFileError(err))
+		_, err = fs.Stat("/gone")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		deleted, err := fs.DeletedNodes("/")
+		require.Nil(t, err)
+		require.Len(t, deleted, 1)
+		require.Equal(t, "/gone", deleted[0].Path)
+
+		_, err = fs.SaveNode("/")
+		require.NotNil(t, err)
+	})
+}
+
 func TestCheckout(t *testing.T) {
 	t.Parallel()
 
@@ -442,6 +775,38 @@ func TestSync(t *testing.T) {
 	})
 }
 
+func TestSyncPreview(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fsa *FS) {
+		require.Nil(t, fsa.MakeCommit("hello a"))
+		withDummyFS(t, func(fsb *FS) {
+			require.Nil(t, fsb.MakeCommit("hello b"))
+			require.Nil(t, fsa.Sync(fsb))
+
+			require.Nil(t, fsb.Mkdir("/sub", false))
+			require.Nil(t, fsb.Stage("/sub/x", bytes.NewReader(make([]byte, 10))))
+			require.Nil(t, fsb.Stage("/sub/y", bytes.NewReader(make([]byte, 20))))
+			require.Nil(t, fsb.Stage("/z", bytes.NewReader(make([]byte, 30))))
+
+			diff, err := fsa.SyncPreview(fsb)
+			require.Nil(t, err)
+			require.NotEmpty(t, diff.Added)
+			require.Equal(t, uint64(60), diff.TransferSize)
+
+			// Nothing should have been changed by the preview:
+			_, err = fsa.Stat("/z")
+			require.True(t, ie.IsNoSuchFileError(err))
+
+			require.Nil(t, fsa.Sync(fsb))
+			diff, err = fsa.SyncPreview(fsb)
+			require.Nil(t, err)
+			require.Empty(t, diff.Added)
+			require.Equal(t, uint64(0), diff.TransferSize)
+		})
+	})
+}
+
 func TestMakeDiff(t *testing.T) {
 	t.Parallel()
 
@@ -578,6 +943,185 @@ func TestMove(t *testing.T) {
 	})
 }
 
+func TestMoveAcrossDirectories(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.MakeCommit("init"))
+		require.Nil(t, fs.Mkdir("/a/sub", true))
+		require.Nil(t, fs.Mkdir("/b", true))
+		require.Nil(t, fs.Stage("/a/sub/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		require.Nil(t, fs.Move("/a/sub/x", "/b/y"))
+		require.Nil(t, fs.MakeCommit("move file"))
+
+		sizes := func(expect map[string]uint64) {
+			for dirPath, size := range expect {
+				info, err := fs.Stat(dirPath)
+				require.Nil(t, err)
+				require.Equal(t, size, info.Size, dirPath)
+			}
+		}
+
+		sizes(map[string]uint64{"/": 5, "/a": 0, "/a/sub": 0, "/b": 5})
+
+		// Move a directory with a subdirectory to another parent:
+		require.Nil(t, fs.Move("/b", "/a/sub/b"))
+		require.Nil(t, fs.MakeCommit("move dir"))
+		sizes(map[string]uint64{"/": 5, "/a": 5, "/a/sub": 5, "/a/sub/b": 5})
+
+		require.Nil(t, fs.Move("/a/sub", "/c"))
+		require.Nil(t, fs.MakeCommit("move dir again"))
+		sizes(map[string]uint64{"/": 5, "/a": 0, "/c": 5, "/c/b": 5})
+
+		stream, err := fs.Cat("/c/b/y")
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), data)
+		require.Nil(t, stream.Close())
+
+		// The history should lead back to where the file was added:
+		hist, err := fs.History("/c/b/y")
+		require.Nil(t, err)
+
+		trail := []string{}
+		for _, change := range hist {
+			if change.WasPreviouslyAt != "" {
+				trail = append(trail, change.Path)
+			}
+		}
+
+		require.Equal(t, []string{"/c/b/y", "/a/sub/b/y", "/b/y"}, trail)
+		require.Equal(t, "/a/sub/x", hist[len(hist)-1].Path)
+		require.Equal(t, "added", hist[len(hist)-1].Change)
+	})
+}
+
+// TestConcurrentMoves moves files between directories from several
+// goroutines and checks that the tree is consistent afterwards.
+func TestConcurrentMoves(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nDirs    = 4
+		nFiles   = 8
+		nWorkers = 4
+		nMoves   = 25
+	)
+
+	withDummyFS(t, func(fs *FS) {
+		// Each file has a unique name, so that moves never overwrite.
+		contents := make(map[string][]byte)
+		for idx := 0; idx < nFiles; idx++ {
+			filePath := fmt.Sprintf("/d0/file-%d", idx)
+			contents[path.Base(filePath)] = bytes.Repeat([]byte{byte(idx + 1)}, idx+1)
+			require.Nil(t, fs.Stage(filePath, bytes.NewReader(contents[path.Base(filePath)])))
+		}
+
+		for idx := 1; idx < nDirs; idx++ {
+			require.Nil(t, fs.Mkdir(fmt.Sprintf("/d%d/sub", idx), true))
+		}
+
+		require.Nil(t, fs.MakeCommit("init"))
+
+		rootInfo, err := fs.Stat("/")
+		require.Nil(t, err)
+
+		wg := &sync.WaitGroup{}
+		for worker := 0; worker < nWorkers; worker++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+
+				rng := rand.New(rand.NewSource(seed))
+				for move := 0; move < nMoves; move++ {
+					entries, err := fs.List("/", -1)
+					if err != nil {
+						t.Errorf("list failed: %v", err)
+						return
+					}
+
+					files := []string{}
+					dirs := []string{}
+					for _, entry := range entries {
+						if entry.IsDir {
+							dirs = append(dirs, entry.Path)
+						} else {
+							files = append(files, entry.Path)
+						}
+					}
+
+					src := files[rng.Intn(len(files))]
+					dst := path.Join(dirs[rng.Intn(len(dirs))], path.Base(src))
+
+					// Another worker might have moved `src` away already.
+					if err := fs.Move(src, dst); err != nil && !ie.IsNoSuchFileError(err) {
+						if dst != src {
+							t.Errorf("move %s -> %s failed: %v", src, dst, err)
+						}
+					}
+
+					if move%10 == 0 {
+						if err := fs.MakeCommit(fmt.Sprintf("moves %d/%d", seed, move)); err != nil && err != ie.ErrNoChange {
+							t.Errorf("commit failed: %v", err)
+						}
+					}
+				}
+			}(int64(worker))
+		}
+
+		wg.Wait()
+
+		entries, err := fs.List("/", -1)
+		require.Nil(t, err)
+
+		nFound := 0
+		dirSizes := make(map[string]uint64)
+		for _, entry := range entries {
+			if entry.IsDir {
+				continue
+			}
+
+			nFound++
+
+			// Every file should still have its content:
+			stream, err := fs.Cat(entry.Path)
+			require.Nil(t, err)
+			data, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Nil(t, stream.Close())
+			require.Equal(t, contents[path.Base(entry.Path)], data, entry.Path)
+
+			// ...and add to the size of every parent:
+			for dir := path.Dir(entry.Path); ; dir = path.Dir(dir) {
+				dirSizes[dir] += entry.Size
+				if dir == "/" {
+					break
+				}
+			}
+
+			// The history should lead back to where it was staged:
+			hist, err := fs.History(entry.Path)
+			require.Nil(t, err)
+			require.Equal(t, "/d0/"+path.Base(entry.Path), hist[len(hist)-1].Path)
+		}
+
+		require.Equal(t, nFiles, nFound)
+
+		for _, entry := range entries {
+			if entry.IsDir {
+				require.Equal(t, dirSizes[entry.Path], entry.Size, entry.Path)
+			}
+		}
+
+		newRootInfo, err := fs.Stat("/")
+		require.Nil(t, err)
+		require.Equal(t, rootInfo.Size, newRootInfo.Size)
+	})
+}
+
 func TestTouch(t *testing.T) {
 	t.Parallel()
 
@@ -703,6 +1247,36 @@ func TestStageUnmodified(t *testing.T) {
 	})
 }
 
+func TestStageHashAlgo(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/old", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.cfg.SetString("hash_algo", h.AlgoBlake3))
+		require.Nil(t, fs.Stage("/new", bytes.NewReader([]byte{1})))
+
+		oldInfo, err := fs.Stat("/old")
+		require.Nil(t, err)
+		require.Equal(t, h.AlgoBlake2s, oldInfo.ContentHash.Algorithm())
+
+		newInfo, err := fs.Stat("/new")
+		require.Nil(t, err)
+		require.Equal(t, h.AlgoBlake3, newInfo.ContentHash.Algorithm())
+
+		// Modifying a file should not change its algorithm:
+		require.Nil(t, fs.Stage("/old", bytes.NewReader([]byte{2})))
+		oldInfo, err = fs.Stat("/old")
+		require.Nil(t, err)
+		require.Equal(t, h.AlgoBlake2s, oldInfo.ContentHash.Algorithm())
+
+		data, err := fs.Cat("/new")
+		require.Nil(t, err)
+		content, err := ioutil.ReadAll(data)
+		require.Nil(t, err)
+		require.Equal(t, []byte{1}, content)
+	})
+}
+
 func TestTruncate(t *testing.T) {
 	t.Parallel()
 
@@ -840,6 +1414,41 @@ func TestReadOnly(t *testing.T) {
 	})
 }
 
+func TestFrozen(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		patch, err := fs.MakePatch("init", nil, "")
+		require.Nil(t, err)
+
+		fs.SetFrozen(true)
+		require.True(t, fs.IsFrozen())
+
+		require.Equal(t, ErrFrozen, fs.Stage("/y", bytes.NewReader([]byte{1})))
+		require.Equal(t, ErrFrozen, fs.Remove("/x"))
+		require.Equal(t, ErrFrozen, fs.Move("/x", "/z"))
+		require.Equal(t, ErrFrozen, fs.Touch("/x"))
+		require.Equal(t, ErrFrozen, fs.MakeCommit("second"))
+		require.Equal(t, ErrFrozen, fs.Tag("head", "v1"))
+		require.Equal(t, ErrFrozen, fs.Checkout("init", true))
+		require.Equal(t, ErrFrozen, fs.ApplyPatch(patch))
+
+		hdl, err := fs.Open("/x")
+		require.Nil(t, err)
+		_, err = hdl.Write([]byte{4})
+		require.Equal(t, ErrReadOnly, err)
+		require.Nil(t, hdl.Close())
+
+		// Reading still works:
+		_, err = fs.Stat("/x")
+		require.Nil(t, err)
+
+		fs.SetFrozen(false)
+		require.Nil(t, fs.Tag("head", "v1"))
+	})
+}
+
 func TestDeletedNodesDirectory(t *testing.T) {
 	withDummyFS(t, func(fs *FS) {
 		require.Nil(t, fs.Mkdir("/dir_a", true))
@@ -941,3 +1550,222 @@ func TestUndeleteDirectory(t *testing.T) {
 		}, paths)
 	})
 }
+
+func TestCommitSignature(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		verify := func(hash, sig []byte) error {
+			if !bytes.Equal(sig, append([]byte("sig:"), hash...)) {
+				return fmt.Errorf("bad signature")
+			}
+
+			return nil
+		}
+
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommit("unsigned"))
+		require.Equal(t, ErrUnsignedCommit, fs.VerifyCommit("HEAD", verify))
+
+		fs.SetCommitSigner(func(hash []byte) ([]byte, error) {
+			return append([]byte("sig:"), hash...), nil
+		})
+
+		require.Nil(t, fs.Touch("/y"))
+		require.Nil(t, fs.MakeCommit("signed"))
+		require.Nil(t, fs.VerifyCommit("HEAD", verify))
+
+		// The signature survives a roundtrip through the database:
+		fs.lkr.MemIndexClear()
+		require.Nil(t, fs.VerifyCommit("HEAD", verify))
+		require.Equal(t, ErrUnsignedCommit, fs.VerifyCommit("HEAD^", verify))
+	})
+}
+
+func TestCommitMeta(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommitWithMeta("labeled", CommitMeta{
+			Origin: CommitOriginCLI,
+			Labels: []string{"backup"},
+		}))
+
+		require.Nil(t, fs.Touch("/y"))
+		require.Nil(t, fs.MakeCommit("plain"))
+
+		labeled, err := fs.CommitInfo("HEAD^")
+		require.Nil(t, err)
+		require.Equal(t, CommitOriginCLI, labeled.Origin)
+		require.Equal(t, []string{"backup"}, labeled.Labels)
+		require.True(t, labeled.HasLabel("backup"))
+		require.Equal(t, deviceName(fs.cfg), labeled.Device)
+
+		plain, err := fs.CommitInfo("HEAD")
+		require.Nil(t, err)
+		require.Equal(t, "", plain.Origin)
+		require.False(t, plain.HasLabel("backup"))
+	})
+}
+
+func TestStageIfUnchanged(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		// nil means that the file may not exist yet:
+		require.Nil(t, fs.StageIfUnchanged("/x", bytes.NewReader([]byte{1}), nil))
+		require.Equal(t, ErrStageConflict, fs.StageIfUnchanged("/x", bytes.NewReader([]byte{2}), nil))
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+
+		require.Nil(t, fs.StageIfUnchanged("/x", bytes.NewReader([]byte{2}), info.TreeHash))
+
+		// The old hash is outdated now:
+		err = fs.StageIfUnchanged("/x", bytes.NewReader([]byte{3}), info.TreeHash)
+		require.Equal(t, ErrStageConflict, err)
+
+		stream, err := fs.Cat("/x")
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, []byte{2}, data)
+	})
+}
+
+func TestTransferGateDefersPreCache(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.cfg.SetBool("pre_cache.enabled", true))
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+
+		fetched := make(chan h.Hash, 1)
+		fs.SetContentFetcher(func(hash h.Hash) error {
+			fetched <- hash
+			return nil
+		})
+
+		allowed := false
+		fs.SetTransferGate(func() bool { return allowed })
+		require.Nil(t, fs.Pin("/x", "curr", true))
+		require.Equal(t, 0, fs.RunDeferredPreCache())
+
+		select {
+		case <-fetched:
+			t.Fatalf("pre-caching was not deferred")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		allowed = true
+		require.Equal(t, 1, fs.RunDeferredPreCache())
+		require.Equal(t, 0, fs.RunDeferredPreCache())
+
+		select {
+		case <-fetched:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("deferred pre-caching did not run")
+		}
+	})
+}
+
+func TestNodeCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		fs.lkr.SetNodeCacheSize(5)
+
+		readFile := func(path string) []byte {
+			stream, err := fs.Cat(path)
+			require.Nil(t, err)
+
+			data, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Nil(t, stream.Close())
+			return data
+		}
+
+		for idx := 0; idx < 30; idx++ {
+			path := fmt.Sprintf("/dir%d/file%d", idx%3, idx)
+			require.Nil(t, fs.Stage(path, bytes.NewReader([]byte(path))))
+		}
+
+		require.Nil(t, fs.MakeCommit("added files"))
+		require.Equal(t, 5, fs.NodeCacheStats().Clean)
+
+		// Evicted nodes are loaded again when needed:
+		entries, err := fs.List("/", -1)
+		require.Nil(t, err)
+		require.Len(t, entries, 34)
+
+		for idx := 0; idx < 30; idx++ {
+			path := fmt.Sprintf("/dir%d/file%d", idx%3, idx)
+			require.Equal(t, []byte(path), readFile(path))
+		}
+
+		// Changes to evicted nodes end up in the right place:
+		require.Nil(t, fs.Stage("/dir1/file1", bytes.NewReader([]byte("changed"))))
+		require.Nil(t, fs.Move("/dir2/file2", "/dir0/moved"))
+		require.Nil(t, fs.MakeCommit("changed files"))
+		require.True(t, fs.NodeCacheStats().Clean <= 5)
+
+		require.Equal(t, []byte("changed"), readFile("/dir1/file1"))
+		require.Equal(t, []byte("/dir2/file2"), readFile("/dir0/moved"))
+
+		info, err := fs.Stat("/")
+		require.Nil(t, err)
+
+		size := len("changed") - len("/dir1/file1")
+		for idx := 0; idx < 30; idx++ {
+			size += len(fmt.Sprintf("/dir%d/file%d", idx%3, idx))
+		}
+
+		require.Equal(t, uint64(size), info.Size)
+
+		history, err := fs.History("/dir0/moved")
+		require.Nil(t, err)
+		require.Equal(t, "/dir2/file2", history[len(history)-1].Path)
+	})
+}
+
+// remoteBackend pretends that nothing is stored locally.
+type remoteBackend struct {
+	*MemFsBackend
+	cats int
+}
+
+func (rb *remoteBackend) Cat(hash h.Hash) (mio.Stream, error) {
+	rb.cats++
+	return rb.MemFsBackend.Cat(hash)
+}
+
+func (rb *remoteBackend) IsCached(hash h.Hash) (bool, error) {
+	return false, nil
+}
+
+func TestBlockCache(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		data := testutil.CreateDummyBuf(3 * blockcache.BlockSize)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader(data)))
+
+		cacheDir, err := ioutil.TempDir("", "brig-fs-blockcache")
+		require.Nil(t, err)
+		defer os.RemoveAll(cacheDir)
+
+		cache, err := blockcache.Open(cacheDir, 64*1024*1024)
+		require.Nil(t, err)
+
+		bk := &remoteBackend{MemFsBackend: fs.bk.(*MemFsBackend)}
+		fs.bk = bk
+		fs.SetBlockCache(cache)
+
+		for idx := 0; idx < 3; idx++ {
+			stream, err := fs.Cat("/x")
+			require.Nil(t, err)
+
+			out, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, data, out)
+			require.Nil(t, stream.Close())
+		}
+
+		// Only the first read went to the backend:
+		require.Equal(t, 1, bk.cats)
+		stats := cache.Stats()
+		require.True(t, stats.Hits > 0)
+		require.True(t, stats.Misses > 0)
+	})
+}
@@ -0,0 +1,137 @@
+package catfs
+
+import (
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// FsckOptions tell Fsck() what to check and how to deal with problems.
+type FsckOptions struct {
+	// Content enables checking the content of all pinned files.
+	Content bool
+	// Refetch asks the content repairer to fetch broken content again.
+	Refetch bool
+	// Unpin unpins files whose content is still broken afterwards.
+	Unpin bool
+}
+
+// FsckProblem is a pinned file whose content is missing or corrupt.
+type FsckProblem struct {
+	// Path of the file in the current tree.
+	Path string
+	// BackendHash is where the content is stored in the backend.
+	BackendHash h.Hash
+	// Missing is true if the content is not available locally at all.
+	Missing bool
+	// Err describes why the content is considered broken.
+	Err string
+	// Repaired is true if the content was fetched again and verified.
+	Repaired bool
+	// Unpinned is true if the file was unpinned because of the problem.
+	Unpinned bool
+}
+
+// FsckReport is the outcome of Fsck().
+type FsckReport struct {
+	// Nodes is the number of nodes in the current tree that could be loaded.
+	Nodes int
+	// Checked is the number of pinned files whose content was checked.
+	Checked int
+	// Bytes is the number of stored (encrypted) bytes that were read.
+	Bytes uint64
+	// Problems lists all files with missing or corrupt content.
+	Problems []FsckProblem
+}
+
+// checkLocalContent makes sure that the content of `item` is stored
+// locally and verifies it. Content that is not stored locally is never
+// fetched; it would be fetched from the network otherwise.
+func (fs *FS) checkLocalContent(item scrubItem) (uint64, bool, error) {
+	isCached, err := fs.bk.IsCached(item.backendHash)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !isCached {
+		return 0, true, nil
+	}
+
+	size, err := fs.verifyContent(item, 0)
+	return size, false, err
+}
+
+// Fsck loads every node of the current tree to check the metadata.
+// If `opts.Content` is set, the content of all pinned files is read
+// and verified too. Unlike Scrub(), this runs right away and unpinned
+// content is not touched at all.
+func (fs *FS) Fsck(opts FsckOptions) (*FsckReport, error) {
+	report := &FsckReport{Problems: []FsckProblem{}}
+
+	fs.mu.Lock()
+	root, err := fs.lkr.Root()
+	if err == nil {
+		err = n.Walk(fs.lkr, root, true, func(child n.Node) error {
+			report.Nodes++
+			return nil
+		})
+	}
+
+	repairer := fs.contentRepairer
+	fs.mu.Unlock()
+
+	if err != nil || !opts.Content {
+		return report, err
+	}
+
+	items, err := fs.pinnedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		size, missing, err := fs.checkLocalContent(item)
+		report.Checked++
+		report.Bytes += size
+
+		if !missing && err == nil {
+			continue
+		}
+
+		problem := FsckProblem{
+			Path:        item.path,
+			BackendHash: item.backendHash,
+			Missing:     missing,
+		}
+
+		if missing {
+			problem.Err = "content is not available locally"
+		} else {
+			problem.Err = err.Error()
+		}
+
+		log.Warningf("fsck: content of %s (%s) is broken: %s", item.path, item.backendHash, problem.Err)
+
+		if opts.Refetch && repairer != nil {
+			if err := repairer(item.backendHash); err != nil {
+				log.Warningf("fsck: failed to fetch %s again: %v", item.path, err)
+			} else if _, missing, err := fs.checkLocalContent(item); missing || err != nil {
+				log.Warningf("fsck: %s is still broken after fetching it again", item.path)
+			} else {
+				problem.Repaired = true
+			}
+		}
+
+		if opts.Unpin && !problem.Repaired {
+			if err := fs.Unpin(item.path, "curr", true); err != nil {
+				log.Warningf("fsck: failed to unpin %s: %v", item.path, err)
+			} else {
+				problem.Unpinned = true
+			}
+		}
+
+		report.Problems = append(report.Problems, problem)
+	}
+
+	return report, nil
+}
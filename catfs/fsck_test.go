@@ -0,0 +1,77 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsck(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		data := testutil.CreateDummyBuf(16 * 1024)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader(data)))
+		require.Nil(t, fs.Stage("/dir/y", bytes.NewReader([]byte("hello"))))
+
+		report, err := fs.Fsck(FsckOptions{})
+		require.Nil(t, err)
+		require.Equal(t, 4, report.Nodes)
+		require.Equal(t, 0, report.Checked)
+
+		report, err = fs.Fsck(FsckOptions{Content: true})
+		require.Nil(t, err)
+		require.Equal(t, 2, report.Checked)
+		require.Equal(t, storedSize(t, fs, "/x", "/dir/y"), report.Bytes)
+		require.Empty(t, report.Problems)
+
+		xInfo, err := fs.Stat("/x")
+		require.Nil(t, err)
+
+		yInfo, err := fs.Stat("/dir/y")
+		require.Nil(t, err)
+
+		// Corrupt /x and lose /dir/y:
+		mb := fs.bk.(*MemFsBackend)
+		xKey := xInfo.BackendHash.B58String()
+		good := append([]byte{}, mb.data[xKey]...)
+		mb.data[xKey][len(good)/2] ^= 0xFF
+		delete(mb.data, yInfo.BackendHash.B58String())
+
+		report, err = fs.Fsck(FsckOptions{Content: true})
+		require.Nil(t, err)
+		require.Len(t, report.Problems, 2)
+		require.Equal(t, "/dir/y", report.Problems[0].Path)
+		require.True(t, report.Problems[0].Missing)
+		require.Equal(t, "/x", report.Problems[1].Path)
+		require.False(t, report.Problems[1].Missing)
+		require.False(t, report.Problems[1].Repaired)
+
+		// Only /x can be fetched again; /dir/y gets unpinned:
+		fs.SetContentRepairer(func(hash h.Hash) error {
+			if hash.Equal(xInfo.BackendHash) {
+				mb.data[xKey] = good
+			}
+
+			return nil
+		})
+
+		report, err = fs.Fsck(FsckOptions{Content: true, Refetch: true, Unpin: true})
+		require.Nil(t, err)
+		require.Len(t, report.Problems, 2)
+		require.False(t, report.Problems[0].Repaired)
+		require.True(t, report.Problems[0].Unpinned)
+		require.True(t, report.Problems[1].Repaired)
+		require.False(t, report.Problems[1].Unpinned)
+
+		isPinned, _, err := fs.IsPinned("/dir/y")
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		report, err = fs.Fsck(FsckOptions{Content: true})
+		require.Nil(t, err)
+		require.Equal(t, 1, report.Checked)
+		require.Empty(t, report.Problems)
+	})
+}
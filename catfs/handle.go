@@ -45,13 +45,13 @@ func (hdl *Handle) initStreamIfNeeded() error {
 	}
 
 	// Initialize the stream lazily to avoid I/O on open()
-	rawStream, err := hdl.fs.bk.Cat(hdl.file.BackendHash())
+	rawStream, err := hdl.fs.rawCat(hdl.file.BackendHash())
 	if err != nil {
 		return err
 	}
 
 	// Stack the mio stack on top:
-	hdl.stream, err = mio.NewOutStream(rawStream, hdl.file.Key())
+	hdl.stream, err = mio.NewOutStreamWithDicts(rawStream, hdl.file.Key(), hdl.fs.dicts)
 	if err != nil {
 		return err
 	}
@@ -203,14 +203,6 @@ func (hdl *Handle) flush() error {
 		return nil
 	}
 
-	// Make sure that hdl.layer is unset in any case.
-	// but only do that in case of real writes.
-	defer func() {
-		hdl.layer = nil
-		hdl.stream = nil
-		hdl.wasModified = false
-	}()
-
 	// Jump back to the beginning of the file, since fs.Stage()
 	// should read all content starting from there.
 	n, err := hdl.layer.Seek(0, io.SeekStart)
@@ -227,7 +219,13 @@ func (hdl *Handle) flush() error {
 		return err
 	}
 
-	return hdl.layer.Close()
+	// Unset the layer only after staging worked. On errors the writes
+	// are kept, so that the flush can be retried.
+	layer := hdl.layer
+	hdl.layer = nil
+	hdl.stream = nil
+	hdl.wasModified = false
+	return layer.Close()
 }
 
 // Flush makes sure to write the current state to the backend.
@@ -261,6 +259,18 @@ func (hdl *Handle) Close() error {
 	return hdl.flush()
 }
 
+// Size returns the current size of the file,
+// including writes that were not flushed yet.
+func (hdl *Handle) Size() uint64 {
+	hdl.lock.Lock()
+	defer hdl.lock.Unlock()
+
+	hdl.fs.mu.Lock()
+	defer hdl.fs.mu.Unlock()
+
+	return hdl.file.Size()
+}
+
 // Path returns the absolute path of the file.
 func (hdl *Handle) Path() string {
 	hdl.lock.Lock()
@@ -0,0 +1,190 @@
+package catfs
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+var (
+	// ErrHeld is returned when a node should be removed, moved or unpinned
+	// while it (or a directory above or below it) is under a retention hold.
+	ErrHeld = errors.New("node is under a retention hold")
+
+	// ErrNoSuchHold is returned by RemoveHold for paths that are not held.
+	ErrNoSuchHold = errors.New("no such hold")
+)
+
+// Hold keeps a node from being removed and its content from being
+// unpinned, by the user, by a sync or by the repinner, until it is lifted.
+// See core.Hold for the details.
+type Hold struct {
+	Path    string
+	Created time.Time
+	Owner   string
+	Reason  string
+}
+
+// isBelow checks if `nodePath` is `root` or lies below it.
+func isBelow(nodePath, root string) bool {
+	return root == "/" || nodePath == root || strings.HasPrefix(nodePath, root+"/")
+}
+
+// heldPaths returns the paths of all holds.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) heldPaths() ([]string, error) {
+	holds, err := fs.lkr.Holds()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, hold := range holds {
+		paths = append(paths, hold.Path)
+	}
+
+	return paths, nil
+}
+
+// holdCovering returns the held path that covers `nodePath`, i.e. the hold
+// of `nodePath` itself or of a directory above it. It is "" if there is none.
+func holdCovering(held []string, nodePath string) string {
+	for _, heldPath := range held {
+		if isBelow(nodePath, heldPath) {
+			return heldPath
+		}
+	}
+
+	return ""
+}
+
+// checkNotHeld returns ErrHeld if removing `nodePath` would remove a held
+// node: either it is held itself, lies in a held directory or a held node
+// lies below it.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) checkNotHeld(nodePath string) error {
+	held, err := fs.heldPaths()
+	if err != nil {
+		return err
+	}
+
+	for _, heldPath := range held {
+		if isBelow(nodePath, heldPath) || isBelow(heldPath, nodePath) {
+			return e.Wrapf(ErrHeld, "%s", heldPath)
+		}
+	}
+
+	return nil
+}
+
+// isHeld checks if `nodePath` is held by itself or by a directory above it.
+// Errors are treated as if the node was held, since this is used
+// to decide if content may be given up.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) isHeld(nodePath string) bool {
+	held, err := fs.heldPaths()
+	if err != nil {
+		return true
+	}
+
+	return holdCovering(held, nodePath) != ""
+}
+
+// AddHold puts the node at `path` under a retention hold. Until the hold is
+// lifted, it can not be removed, moved or unpinned, deletions of remotes
+// are not applied to it and the repinner keeps all of its versions.
+// Its content is pinned right away.
+func (fs *FS) AddHold(path, reason string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	path = fs.normPath(path)
+
+	if err := fs.checkWritable(); err != nil {
+		return err
+	}
+
+	nd, err := fs.lkr.LookupNode(path)
+	if err != nil {
+		return err
+	}
+
+	if nd.Type() == n.NodeTypeGhost {
+		return ie.NoSuchFile(path)
+	}
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	if err := fs.pinner.PinNode(nd, false); err != nil {
+		return err
+	}
+
+	return fs.lkr.PutHold(&c.Hold{
+		Path:    nd.Path(),
+		Created: time.Now(),
+		Owner:   owner,
+		Reason:  reason,
+	})
+}
+
+// RemoveHold lifts the hold of `holdPath`. Holds of directories above or
+// below `holdPath` are not touched.
+func (fs *FS) RemoveHold(holdPath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.checkWritable(); err != nil {
+		return err
+	}
+
+	// The node might not exist anymore in the current tree,
+	// so the path policy is not applied here.
+	holdPath = path.Clean(prefixSlash(holdPath))
+	hold, err := fs.lkr.Hold(holdPath)
+	if err != nil {
+		return err
+	}
+
+	if hold == nil {
+		return e.Wrapf(ErrNoSuchHold, "%s", holdPath)
+	}
+
+	return fs.lkr.RemoveHold(holdPath)
+}
+
+// Holds lists all holds, sorted by path.
+func (fs *FS) Holds() ([]Hold, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	holds, err := fs.lkr.Holds()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Hold{}
+	for _, hold := range holds {
+		result = append(result, Hold{
+			Path:    hold.Path,
+			Created: hold.Created,
+			Owner:   hold.Owner,
+			Reason:  hold.Reason,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package catfs
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	e "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldPreventsRemove(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/docs/x", bytes.NewReader([]byte("x"))))
+		require.Nil(t, fs.Stage("/docs/y", bytes.NewReader([]byte("y"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		require.Nil(t, fs.AddHold("/docs/x", "lawsuit"))
+
+		holds, err := fs.Holds()
+		require.Nil(t, err)
+		require.Len(t, holds, 1)
+		require.Equal(t, "/docs/x", holds[0].Path)
+		require.Equal(t, "lawsuit", holds[0].Reason)
+		require.Equal(t, "alice", holds[0].Owner)
+
+		// The held file, its parents and moving it are refused;
+		// its siblings are not affected.
+		require.Equal(t, ErrHeld, e.Cause(fs.Remove("/docs/x")))
+		require.Equal(t, ErrHeld, e.Cause(fs.Remove("/docs")))
+		require.Equal(t, ErrHeld, e.Cause(fs.Move("/docs/x", "/z")))
+		require.Equal(t, ErrHeld, e.Cause(fs.Unpin("/docs/x", "curr", true)))
+		require.Nil(t, fs.Remove("/docs/y"))
+
+		require.Equal(t, ErrNoSuchHold, e.Cause(fs.RemoveHold("/docs")))
+		require.Nil(t, fs.RemoveHold("/docs/x"))
+		require.Nil(t, fs.Remove("/docs/x"))
+
+		holds, err = fs.Holds()
+		require.Nil(t, err)
+		require.Len(t, holds, 0)
+	})
+}
+
+func TestHoldKeepsVersionsOnRepin(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		fs.cfg.SetBool("repin.enabled", true)
+		fs.cfg.SetString("repin.quota", "100G")
+		fs.cfg.SetInt("repin.min_depth", 1)
+		fs.cfg.SetInt("repin.max_depth", 1)
+
+		for idx := 0; idx < 5; idx++ {
+			require.Nil(t, fs.Stage("/dir/a", bytes.NewReader([]byte{byte(idx)})))
+			require.Nil(t, fs.MakeCommit(fmt.Sprintf("state: %d", idx)))
+		}
+
+		require.Nil(t, fs.AddHold("/dir", ""))
+		require.Nil(t, fs.repin("/"))
+
+		history, err := fs.History("/dir/a")
+		require.Nil(t, err)
+
+		for _, change := range history {
+			if change.Change == "none" {
+				continue
+			}
+
+			require.True(t, change.IsPinned, change.Head.Msg)
+		}
+	})
+}
+
+func TestHoldSyncKeepsRemoteDeletes(t *testing.T) {
+	withDummyFS(t, func(fsa *FS) {
+		withDummyFS(t, func(fsb *FS) {
+			require.Nil(t, fsb.Stage("/x", bytes.NewReader([]byte{1})))
+			require.Nil(t, fsb.MakeCommit("add x"))
+			require.Nil(t, fsa.Sync(fsb))
+
+			require.Nil(t, fsa.AddHold("/x", ""))
+
+			require.Nil(t, fsb.Remove("/x"))
+			require.Nil(t, fsb.MakeCommit("remove x"))
+			require.Nil(t, fsa.Sync(fsb))
+
+			_, err := fsa.Stat("/x")
+			require.Nil(t, err)
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package catfs
+
+import "strings"
+
+// Kinds of content, as returned by ContentKind.
+const (
+	KindUnknown  = ""
+	KindText     = "text"
+	KindImage    = "image"
+	KindAudio    = "audio"
+	KindVideo    = "video"
+	KindArchive  = "archive"
+	KindDocument = "document"
+	KindBinary   = "binary"
+)
+
+var mimeKinds = map[string]string{
+	"application/json":             KindText,
+	"application/xml":              KindText,
+	"application/javascript":       KindText,
+	"application/x-sh":             KindText,
+	"application/pdf":              KindDocument,
+	"application/msword":           KindDocument,
+	"application/rtf":              KindDocument,
+	"application/epub+zip":         KindDocument,
+	"application/zip":              KindArchive,
+	"application/gzip":             KindArchive,
+	"application/x-gzip":           KindArchive,
+	"application/x-tar":            KindArchive,
+	"application/x-bzip2":          KindArchive,
+	"application/x-xz":             KindArchive,
+	"application/x-7z-compressed":  KindArchive,
+	"application/x-rar-compressed": KindArchive,
+	"application/ogg":              KindAudio,
+}
+
+// ContentKind sorts a mime type into a rough category like "image" or
+// "text", which is easier to work with for things like icons or previews.
+func ContentKind(mimeType string) string {
+	if mimeType == "" {
+		return KindUnknown
+	}
+
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	if kind, ok := mimeKinds[mimeType]; ok {
+		return kind
+	}
+
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return KindText
+	case strings.HasPrefix(mimeType, "image/"):
+		return KindImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return KindAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return KindVideo
+	case strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument."):
+		return KindDocument
+	}
+
+	return KindBinary
+}
@@ -0,0 +1,188 @@
+package catfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sahib/brig/catfs/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// openMetadataDatabase opens the metadata at `dbPath` with the configured
+// backend. Metadata written by another backend is refused, since both
+// would not see each other's keys; it has to be migrated first.
+func openMetadataDatabase(dbPath, backend string) (db.Database, error) {
+	existing, err := db.DetectBackend(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != "" && existing != backend {
+		return nil, fmt.Errorf(
+			"metadata in %s is stored with %s, but fs.metadata_backend is %s (see »brig migrate-metadata«)",
+			dbPath, existing, backend,
+		)
+	}
+
+	return db.Open(backend, dbPath)
+}
+
+func isValidBackend(backend string) bool {
+	for _, name := range db.Backends {
+		if name == backend {
+			return true
+		}
+	}
+
+	return false
+}
+
+// freeFormKeyDepth returns how many leading parts of `key` are fixed
+// names. The rest is a path or a dotted name that the database backends
+// split up differently, so it needs to be joined again when migrating.
+// It returns -1 if all parts of `key` are fixed names.
+func freeFormKeyDepth(key []string) int {
+	switch {
+	case len(key) > 1 && key[0] == "tree":
+		return 1
+	case len(key) > 1 && key[0] == "metadata":
+		return 1
+	case len(key) > 2 && key[0] == "stage" && key[1] == "tree":
+		return 2
+	default:
+		return -1
+	}
+}
+
+// canonicalKey converts `key` as returned by Keys() of `backend`
+// to the parts it was originally stored with.
+func canonicalKey(backend string, key []string) []string {
+	depth := freeFormKeyDepth(key)
+	if depth < 0 {
+		return key
+	}
+
+	rest := strings.Join(key[depth:], ".")
+	if backend == db.BackendDisk {
+		// The disk backend stores paths as directories.
+		rest = strings.Join(key[depth:], "/")
+		if key[0] != "metadata" {
+			rest = "/" + rest
+		}
+	}
+
+	prefix := append([]string{}, key[:depth]...)
+	if key[0] != "stage" {
+		return append(prefix, rest)
+	}
+
+	// Staged directories are stored as their path and a "." part.
+	// The badger backend joins both into "<path>..", the disk
+	// backend turns it into "<path>/.".
+	dirSuffix := ".."
+	if backend == db.BackendDisk {
+		dirSuffix = "/."
+	}
+
+	if strings.HasSuffix(rest, dirSuffix) {
+		dirPath := strings.TrimSuffix(rest, dirSuffix)
+		if dirPath == "" {
+			dirPath = "/"
+		}
+
+		return append(prefix, dirPath, ".")
+	}
+
+	return append(prefix, rest)
+}
+
+func copyMetadata(dst db.Database, src db.Database, srcBackend string) error {
+	keys, err := src.Keys()
+	if err != nil {
+		return err
+	}
+
+	batch := dst.Batch()
+	for _, key := range keys {
+		data, err := src.Get(key...)
+		if err != nil {
+			batch.Rollback()
+			return err
+		}
+
+		batch.Put(data, canonicalKey(srcBackend, key)...)
+	}
+
+	return batch.Flush()
+}
+
+// MigrateMetadata converts the metadata at `dbPath` to be stored with
+// `backend`. The filesystem at `dbPath` may not be open while doing this.
+// The old metadata is only removed once the new one was written completely.
+func MigrateMetadata(dbPath, backend string) error {
+	if !isValidBackend(backend) {
+		return fmt.Errorf("no such metadata backend: %s", backend)
+	}
+
+	existing, err := db.DetectBackend(dbPath)
+	if err != nil {
+		return err
+	}
+
+	if existing == "" || existing == backend {
+		return nil
+	}
+
+	srcDb, err := db.Open(existing, dbPath)
+	if err != nil {
+		return err
+	}
+
+	newPath := dbPath + ".migrate"
+	if err := os.RemoveAll(newPath); err != nil {
+		srcDb.Close()
+		return err
+	}
+
+	if err := os.MkdirAll(newPath, 0700); err != nil {
+		srcDb.Close()
+		return err
+	}
+
+	dstDb, err := db.Open(backend, newPath)
+	if err != nil {
+		srcDb.Close()
+		return err
+	}
+
+	copyErr := copyMetadata(dstDb, srcDb, existing)
+	if err := dstDb.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	if err := srcDb.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	if copyErr != nil {
+		os.RemoveAll(newPath)
+		return copyErr
+	}
+
+	oldPath := dbPath + ".old"
+	if err := os.Rename(dbPath, oldPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(newPath, dbPath); err != nil {
+		// Try to get back to where we were:
+		if err := os.Rename(oldPath, dbPath); err != nil {
+			log.Warningf("failed to restore old metadata from %s: %v", oldPath, err)
+		}
+
+		return err
+	}
+
+	return os.RemoveAll(oldPath)
+}
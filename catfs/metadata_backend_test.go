@@ -0,0 +1,84 @@
+package catfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateMetadata(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "brig-fs-migrate-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dbPath)
+
+	cfg, err := config.Open(nil, defaults.Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	fsCfg := cfg.Section("fs")
+	backend := NewMemFsBackend()
+
+	openFs := func(metadataBackend string) (*FS, error) {
+		require.Nil(t, fsCfg.SetString("metadata_backend", metadataBackend))
+		return NewFilesystem(backend, dbPath, "alice", false, fsCfg)
+	}
+
+	checkFs := func(fs *FS) {
+		stream, err := fs.Cat("/sub/dir/file.txt")
+		require.Nil(t, err)
+
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), data)
+		require.Nil(t, stream.Close())
+
+		entries, err := fs.List("/", -1)
+		require.Nil(t, err)
+		require.Len(t, entries, 6)
+
+		history, err := fs.History("/sub/dir/file.txt")
+		require.Nil(t, err)
+		require.Len(t, history, 2)
+
+		status, err := fs.Stat("/staged.txt")
+		require.Nil(t, err)
+		require.Equal(t, uint64(2), status.Size)
+
+		status, err = fs.Stat("/staged.dir")
+		require.Nil(t, err)
+		require.True(t, status.IsDir)
+	}
+
+	fs, err := openFs("badger")
+	require.Nil(t, err)
+
+	require.Nil(t, fs.Stage("/sub/dir/file.txt", bytes.NewReader([]byte("hello"))))
+	require.Nil(t, fs.MakeCommit("first"))
+	require.Nil(t, fs.Stage("/staged.txt", bytes.NewReader([]byte("hi"))))
+	require.Nil(t, fs.Mkdir("/staged.dir", false))
+	checkFs(fs)
+	require.Nil(t, fs.Close())
+
+	// The metadata was not migrated yet:
+	_, err = openFs("disk")
+	require.NotNil(t, err)
+
+	require.Nil(t, MigrateMetadata(dbPath, "disk"))
+	fs, err = openFs("disk")
+	require.Nil(t, err)
+	checkFs(fs)
+	require.Nil(t, fs.Close())
+
+	// ...and back again:
+	require.Nil(t, MigrateMetadata(dbPath, "badger"))
+	fs, err = openFs("badger")
+	require.Nil(t, err)
+	checkFs(fs)
+	require.Nil(t, fs.Close())
+}
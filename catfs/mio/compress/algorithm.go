@@ -2,9 +2,11 @@ package compress
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/bkaradzic/go-lz4"
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -22,24 +24,36 @@ type noneAlgo struct{}
 type snappyAlgo struct{}
 type lz4Algo struct{}
 
+// zstdAlgo is the only algorithm that can make use of a dictionary.
+// Small chunks compress a lot better when their typical content
+// is already known by both sides. The id of the dictionary is stored
+// in every zstd frame, so the decoder can look it up in `dicts`.
+type zstdAlgo struct {
+	dict  []byte
+	dicts DictStore
+}
+
 var (
 	// AlgoMap is a map of available algorithms.
 	AlgoMap = map[AlgorithmType]Algorithm{
 		AlgoNone:   noneAlgo{},
 		AlgoSnappy: snappyAlgo{},
 		AlgoLZ4:    lz4Algo{},
+		AlgoZstd:   zstdAlgo{},
 	}
 
 	algoToString = map[AlgorithmType]string{
 		AlgoNone:   "none",
 		AlgoSnappy: "snappy",
 		AlgoLZ4:    "lz4",
+		AlgoZstd:   "zstd",
 	}
 
 	stringToAlgo = map[string]AlgorithmType{
 		"none":   AlgoNone,
 		"snappy": AlgoSnappy,
 		"lz4":    AlgoLZ4,
+		"zstd":   AlgoZstd,
 	}
 )
 
@@ -71,6 +85,122 @@ func (a lz4Algo) Decode(src []byte) ([]byte, error) {
 	return lz4.Decode(nil, src)
 }
 
+// AlgoZstd
+func (a zstdAlgo) Encode(src []byte) ([]byte, error) {
+	var dictID uint32
+	if a.dict != nil {
+		dictID = DictID(a.dict)
+	}
+
+	enc, err := zstdCoders.encoder(dictID, a.dict)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.EncodeAll(src, nil), nil
+}
+
+func (a zstdAlgo) Decode(src []byte) ([]byte, error) {
+	hdr := zstd.Header{}
+	if err := hdr.Decode(src); err != nil {
+		return nil, err
+	}
+
+	var dict []byte
+	if hdr.DictionaryID != 0 {
+		if a.dicts == nil {
+			return nil, ErrNoSuchDict{ID: hdr.DictionaryID}
+		}
+
+		var err error
+		if dict, err = a.dicts.Dict(hdr.DictionaryID); err != nil {
+			return nil, err
+		}
+	}
+
+	dec, err := zstdCoders.decoder(hdr.DictionaryID, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	return dec.DecodeAll(src, nil)
+}
+
+// zstdCache keeps one encoder and decoder per dictionary id around.
+// Creating them is expensive and they can be used concurrently.
+// Dictionary ids are derived from their content, so an id
+// always stands for the same dictionary.
+type zstdCache struct {
+	mu       sync.Mutex
+	encoders map[uint32]*zstd.Encoder
+	decoders map[uint32]*zstd.Decoder
+}
+
+var zstdCoders = &zstdCache{
+	encoders: make(map[uint32]*zstd.Encoder),
+	decoders: make(map[uint32]*zstd.Decoder),
+}
+
+func (zc *zstdCache) encoder(id uint32, dict []byte) (*zstd.Encoder, error) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+
+	if enc, ok := zc.encoders[id]; ok {
+		return enc, nil
+	}
+
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if id != 0 {
+		zdict, err := zstdDict(id, dict)
+		if err != nil {
+			return nil, err
+		}
+
+		// The default level finds only few matches in the
+		// dictionary for inputs as small as ours.
+		opts = append(
+			opts,
+			zstd.WithEncoderDict(zdict),
+			zstd.WithEncoderLevel(zstd.SpeedBetterCompression),
+		)
+	}
+
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	zc.encoders[id] = enc
+	return enc, nil
+}
+
+func (zc *zstdCache) decoder(id uint32, dict []byte) (*zstd.Decoder, error) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+
+	if dec, ok := zc.decoders[id]; ok {
+		return dec, nil
+	}
+
+	opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	if id != 0 {
+		zdict, err := zstdDict(id, dict)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, zstd.WithDecoderDicts(zdict))
+	}
+
+	dec, err := zstd.NewReader(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	zc.decoders[id] = dec
+	return dec, nil
+}
+
 // AlgorithmFromType returns a interface to the given AlgorithmType.
 func AlgorithmFromType(a AlgorithmType) (Algorithm, error) {
 	if algo, ok := AlgoMap[a]; ok {
@@ -25,6 +25,10 @@ var (
 	// ErrUnsupportedVersion is returned when we don't have a reader that
 	// understands that format.
 	ErrUnsupportedVersion = errors.New("Version of this format is not supported")
+
+	// ErrNoDictSupport is returned when a dictionary should be used
+	// with an algorithm that can not make use of it.
+	ErrNoDictSupport = errors.New("Algorithm does not support dictionaries")
 )
 
 const (
@@ -46,6 +50,10 @@ const (
 	//AlgoLZ4 represents the lz4 compression algorithm:
 	// https://en.wikipedia.org/wiki/LZ4_(compression_algorithm)
 	AlgoLZ4
+
+	// AlgoZstd represents the zstd compression algorithm,
+	// optionally with a dictionary: https://facebook.github.io/zstd
+	AlgoZstd
 )
 
 // AlgorithmType user defined type to store the algorithm type.
@@ -54,7 +62,7 @@ type AlgorithmType byte
 // IsValid returns true if `at` is a valid algorithm type.
 func (at AlgorithmType) IsValid() bool {
 	switch at {
-	case AlgoNone, AlgoSnappy, AlgoLZ4:
+	case AlgoNone, AlgoSnappy, AlgoLZ4, AlgoZstd:
 		return true
 	}
 
@@ -0,0 +1,347 @@
+package compress
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/huff0"
+)
+
+// MaxDictSize is the biggest dictionary that can be used.
+// Chunks are at most 64 KiB big, bigger dictionaries would
+// mostly cost memory in the encoder and decoder.
+const MaxDictSize = 128 * 1024
+
+// ErrBadDict is returned for too small or too big dictionaries.
+var ErrBadDict = errors.New("Dictionary is empty or too big")
+
+// ErrNoSuchDict is returned when a stream needs a dictionary we do not have.
+type ErrNoSuchDict struct {
+	ID uint32
+}
+
+func (ed ErrNoSuchDict) Error() string {
+	return fmt.Sprintf("No such compression dictionary: %08x", ed.ID)
+}
+
+// DictStore gives access to dictionaries by their id.
+type DictStore interface {
+	// Dict returns the dictionary with `id` or ErrNoSuchDict.
+	Dict(id uint32) ([]byte, error)
+}
+
+// DictID returns the id of `dict`, as it is stored in every zstd frame.
+func DictID(dict []byte) uint32 {
+	sum := sha256.Sum256(dict)
+	id := binary.LittleEndian.Uint32(sum[:4])
+	if id == 0 {
+		// 0 means "no dictionary" for zstd.
+		id = 1
+	}
+
+	return id
+}
+
+// zstd dictionaries start with entropy tables that are used for the first
+// block. We only have raw content, so the predefined distributions of the
+// zstd format are used for the sequences, and the literals are described
+// by a huffman table built from the content itself. See also:
+// https://github.com/facebook/zstd/blob/dev/doc/zstd_compression_format.md#dictionary-format
+var (
+	zstdDictMagic = []byte{0x37, 0xa4, 0x30, 0xec}
+
+	zstdOffsetNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	zstdMatchLengthNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+
+	zstdLiteralLengthNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+)
+
+// zstdDict wraps `content` into the dictionary format of zstd.
+func zstdDict(id uint32, content []byte) ([]byte, error) {
+	// The three repeat offsets below need to point into the content.
+	if len(content) < 8 || len(content) > MaxDictSize {
+		return nil, ErrBadDict
+	}
+
+	litTable, err := zstdLiteralTable(content)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := append([]byte{}, zstdDictMagic...)
+	dict = append(dict, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(dict[4:], id)
+	dict = append(dict, litTable...)
+	dict = appendNormCount(dict, zstdOffsetNorm, 5)
+	dict = appendNormCount(dict, zstdMatchLengthNorm, 6)
+	dict = appendNormCount(dict, zstdLiteralLengthNorm, 6)
+
+	for _, offset := range []uint32{1, 4, 8} {
+		offsetField := make([]byte, 4)
+		binary.LittleEndian.PutUint32(offsetField, offset)
+		dict = append(dict, offsetField...)
+	}
+
+	return append(dict, content...), nil
+}
+
+// zstdLiteralTable returns the description of a huffman table
+// that can encode every byte and fits the bytes in `content`.
+func zstdLiteralTable(content []byte) ([]byte, error) {
+	sample := make([]byte, 0, len(content)+256)
+	for sym := 0; sym < 256; sym++ {
+		sample = append(sample, byte(sym))
+	}
+
+	// If the content is too uniform to build a table from,
+	// take one that prefers spaces, which is never wrong.
+	fallback := append(append([]byte{}, sample...), bytes.Repeat([]byte{' '}, 256)...)
+	sample = append(sample, content...)
+
+	var err error
+	for _, in := range [][]byte{sample, fallback} {
+		scratch := &huff0.Scratch{}
+		if _, _, err = huff0.Compress1X(in, scratch); err == nil {
+			return scratch.OutTable, nil
+		}
+	}
+
+	return nil, err
+}
+
+// appendNormCount appends the description of a FSE table with the
+// normalized symbol counts `norm` to `out`. This is the same as
+// FSE_writeNCount in the reference implementation.
+func appendNormCount(out []byte, norm []int16, tableLog uint) []byte {
+	var (
+		tableSize = int16(1) << tableLog
+		remaining = tableSize + 1
+		threshold = tableSize
+		nbBits    = tableLog + 1
+		bitStream = uint32(tableLog - 5)
+		bitCount  = uint(4)
+		previous0 = false
+		symbol    = 0
+	)
+
+	flush := func() {
+		if bitCount > 16 {
+			out = append(out, byte(bitStream), byte(bitStream>>8))
+			bitStream >>= 16
+			bitCount -= 16
+		}
+	}
+
+	for remaining > 1 {
+		if previous0 {
+			start := symbol
+			for norm[symbol] == 0 {
+				symbol++
+			}
+
+			for symbol >= start+24 {
+				start += 24
+				bitStream += uint32(0xFFFF) << bitCount
+				out = append(out, byte(bitStream), byte(bitStream>>8))
+				bitStream >>= 16
+			}
+
+			for symbol >= start+3 {
+				start += 3
+				bitStream += 3 << bitCount
+				bitCount += 2
+			}
+
+			bitStream += uint32(symbol-start) << bitCount
+			bitCount += 2
+			flush()
+		}
+
+		count := norm[symbol]
+		symbol++
+
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+
+		// +1 for extra accuracy:
+		count++
+		if count >= threshold {
+			count += max
+		}
+
+		bitStream += uint32(count) << bitCount
+		bitCount += nbBits
+		if count < max {
+			bitCount--
+		}
+
+		previous0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+
+		flush()
+	}
+
+	out = append(out, byte(bitStream), byte(bitStream>>8))
+	return out[:len(out)-2+int((bitCount+7)/8)]
+}
+
+const (
+	// Size of the substrings whose frequency is counted.
+	trainGramSize = 8
+
+	// Size of the pieces the dictionary is assembled from.
+	trainSegmentSize = 64
+)
+
+type trainSegment struct {
+	data  []byte
+	score int
+}
+
+type segmentHeap []*trainSegment
+
+func (sh segmentHeap) Len() int            { return len(sh) }
+func (sh segmentHeap) Less(i, j int) bool  { return sh[i].score > sh[j].score }
+func (sh segmentHeap) Swap(i, j int)       { sh[i], sh[j] = sh[j], sh[i] }
+func (sh *segmentHeap) Push(x interface{}) { *sh = append(*sh, x.(*trainSegment)) }
+func (sh *segmentHeap) Pop() interface{} {
+	old := *sh
+	seg := old[len(old)-1]
+	*sh = old[:len(old)-1]
+	return seg
+}
+
+func forEachGram(data []byte, fn func(gram uint64)) {
+	for idx := 0; idx+trainGramSize <= len(data); idx++ {
+		fn(binary.LittleEndian.Uint64(data[idx : idx+trainGramSize]))
+	}
+}
+
+// TrainDict builds a dictionary of at most `size` bytes from `samples`.
+//
+// This is a simplified version of the COVER algorithm used by zstd: the
+// samples are cut into small segments and the ones that share the most
+// substrings with other samples are picked, each substring only counting
+// once. The best segments go to the end of the dictionary, since zstd
+// encodes near matches shorter. If the samples have nothing in common,
+// nil is returned.
+func TrainDict(samples [][]byte, size int) []byte {
+	if size <= 0 || size > MaxDictSize {
+		size = MaxDictSize
+	}
+
+	// Count in how many samples every substring appears.
+	// Substrings of a single sample do not help others.
+	freqs := make(map[uint64]int)
+	for _, sample := range samples {
+		seen := make(map[uint64]bool)
+		forEachGram(sample, func(gram uint64) {
+			if !seen[gram] {
+				seen[gram] = true
+				freqs[gram]++
+			}
+		})
+	}
+
+	minFreq := 2
+	if len(samples) == 1 {
+		minFreq = 1
+	}
+
+	used := make(map[uint64]bool)
+	scoreOf := func(data []byte) int {
+		score := 0
+		seen := make(map[uint64]bool)
+		forEachGram(data, func(gram uint64) {
+			if seen[gram] || used[gram] || freqs[gram] < minFreq {
+				return
+			}
+
+			seen[gram] = true
+			score += freqs[gram]
+		})
+
+		return score
+	}
+
+	segments := &segmentHeap{}
+	for _, sample := range samples {
+		for off := 0; off < len(sample); off += trainSegmentSize / 2 {
+			end := off + trainSegmentSize
+			if end > len(sample) {
+				end = len(sample)
+			}
+
+			seg := &trainSegment{data: sample[off:end]}
+			if seg.score = scoreOf(seg.data); seg.score > 0 {
+				*segments = append(*segments, seg)
+			}
+		}
+	}
+
+	heap.Init(segments)
+
+	// Scores only get lower when substrings are used,
+	// so a segment is picked if it is still the best after an update.
+	picked := [][]byte{}
+	total := 0
+	for segments.Len() > 0 && total < size {
+		seg := heap.Pop(segments).(*trainSegment)
+		seg.score = scoreOf(seg.data)
+		if seg.score == 0 {
+			continue
+		}
+
+		if segments.Len() > 0 && seg.score < (*segments)[0].score {
+			heap.Push(segments, seg)
+			continue
+		}
+
+		forEachGram(seg.data, func(gram uint64) {
+			used[gram] = true
+		})
+
+		picked = append(picked, seg.data)
+		total += len(seg.data)
+	}
+
+	if len(picked) == 0 {
+		return nil
+	}
+
+	dict := make([]byte, 0, total)
+	for idx := len(picked) - 1; idx >= 0; idx-- {
+		dict = append(dict, picked[idx]...)
+	}
+
+	// Cut the least useful part, if needed:
+	if len(dict) > size {
+		dict = dict[len(dict)-size:]
+	}
+
+	return dict
+}
@@ -0,0 +1,109 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type mapDictStore map[uint32][]byte
+
+func (ms mapDictStore) Dict(id uint32) ([]byte, error) {
+	dict, ok := ms[id]
+	if !ok {
+		return nil, ErrNoSuchDict{ID: id}
+	}
+
+	return dict, nil
+}
+
+// similarSample returns a small file that looks a lot like the others.
+func similarSample(idx int) []byte {
+	return []byte(fmt.Sprintf(`package sample%d
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Greet%d returns a friendly greeting for the person called name.
+func Greet%d(name string) string {
+	return fmt.Sprintf("hello %%s, you are number %d", strings.TrimSpace(name))
+}
+`, idx, idx, idx, idx))
+}
+
+func packWithDict(t *testing.T, data, dict []byte) []byte {
+	buf := &bytes.Buffer{}
+	w, err := NewWriterWithDict(buf, AlgoZstd, dict)
+	require.Nil(t, err)
+
+	_, err = w.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDictRoundtrip(t *testing.T) {
+	samples := [][]byte{}
+	for idx := 0; idx < 20; idx++ {
+		samples = append(samples, similarSample(idx))
+	}
+
+	dict := TrainDict(samples, 4096)
+	require.NotEmpty(t, dict)
+	require.True(t, len(dict) <= 4096)
+
+	data := similarSample(100)
+	zipped := packWithDict(t, data, dict)
+
+	plain, err := Pack(data, AlgoZstd)
+	require.Nil(t, err)
+	require.True(t, len(zipped) < len(plain), "%d >= %d", len(zipped), len(plain))
+
+	// Without the dictionary, the stream can not be read:
+	_, err = Unpack(zipped)
+	require.Equal(t, ErrNoSuchDict{ID: DictID(dict)}, err)
+
+	buf := &bytes.Buffer{}
+	r := NewReaderWithDicts(bytes.NewReader(zipped), mapDictStore{DictID(dict): dict})
+	_, err = r.WriteTo(buf)
+	require.Nil(t, err)
+	require.Equal(t, data, buf.Bytes())
+
+	_, err = r.Seek(8, 0)
+	require.Nil(t, err)
+
+	part := make([]byte, 7)
+	_, err = r.Read(part)
+	require.Nil(t, err)
+	require.Equal(t, data[8:15], part)
+
+	_, err = NewWriterWithDict(buf, AlgoSnappy, dict)
+	require.Equal(t, ErrNoDictSupport, err)
+}
+
+func TestTrainDictNothingInCommon(t *testing.T) {
+	require.Nil(t, TrainDict([][]byte{[]byte("abcdefghijkl"), []byte("mnopqrstuvwx")}, 1024))
+}
+
+func TestDictWithoutStructure(t *testing.T) {
+	// Dictionaries that do not compress themselves need the fallback
+	// huffman table, but have to work nevertheless:
+	for _, dict := range [][]byte{
+		testutil.CreateRandomDummyBuf(4096, 23),
+		bytes.Repeat([]byte{'x'}, 4096),
+	} {
+		data := append(append([]byte{}, dict[100:200]...), "some more"...)
+		zipped := packWithDict(t, data, dict)
+
+		buf := &bytes.Buffer{}
+		r := NewReaderWithDicts(bytes.NewReader(zipped), mapDictStore{DictID(dict): dict})
+		_, err := r.WriteTo(buf)
+		require.Nil(t, err)
+		require.Equal(t, data, buf.Bytes())
+	}
+}
@@ -25,7 +25,9 @@ const (
 	HeaderSizeThreshold = 2048
 )
 
-func guessMime(path string, buf []byte) string {
+// GuessMime guesses the mime type of a file by the first few bytes of it
+// and its path. If nothing matches, "application/octet-stream" is returned.
+func GuessMime(path string, buf []byte) string {
 	httpMatch := http.DetectContentType(buf)
 	if httpMatch != "application/octet-stream" {
 		return httpMatch
@@ -43,6 +45,10 @@ func guessMime(path string, buf []byte) string {
 		return "text/plain"
 	}
 
+	if match == "" {
+		return "application/octet-stream"
+	}
+
 	return match
 }
 
@@ -61,18 +67,23 @@ func GuessAlgorithm(path string, header []byte) (AlgorithmType, error) {
 		return AlgoNone, nil
 	}
 
-	mime := guessMime(path, header)
-	compressible := isCompressible(mime)
+	return AlgorithmForMime(GuessMime(path, header)), nil
+}
 
-	if !compressible {
-		return AlgoNone, nil
+// AlgorithmForMime returns a suitable compression algorithm
+// for content of the type `mime`.
+func AlgorithmForMime(mime string) AlgorithmType {
+	// Parameters like "; charset=utf-8" do not matter here:
+	mime = strings.TrimSpace(strings.SplitN(mime, ";", 2)[0])
+	if !isCompressible(mime) {
+		return AlgoNone
 	}
 
 	// text like files probably deserve some thorough compression:
 	if strings.HasPrefix(mime, "text/") {
-		return AlgoLZ4, nil
+		return AlgoLZ4
 	}
 
 	// fallback to snappy for generic files:
-	return AlgoSnappy, nil
+	return AlgoSnappy
 }
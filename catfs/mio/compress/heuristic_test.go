@@ -58,3 +58,21 @@ func TestChooseCompressAlgo(t *testing.T) {
 		})
 	}
 }
+
+func TestGuessMime(t *testing.T) {
+	cases := map[string][]byte{
+		"text/plain; charset=utf-8": []byte("hello world"),
+		"image/png":                 {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'},
+		"application/octet-stream":  {0x00, 0x01, 0x02},
+	}
+
+	for expected, header := range cases {
+		if mime := GuessMime("file", header); mime != expected {
+			t.Errorf("expected %s, got %s", expected, mime)
+		}
+	}
+
+	if algo := AlgorithmForMime("text/html; charset=utf-8"); algo != AlgoLZ4 {
+		t.Errorf("expected lz4 for html, got %s", algoToString[algo])
+	}
+}
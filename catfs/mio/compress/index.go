@@ -0,0 +1,217 @@
+package compress
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// maxIndexMem is the number of index bytes that are kept in memory.
+	// Bigger indices are spooled to a temporary file by the writer
+	// and read in pages by the reader. 64KB of index cover 4GB of data.
+	maxIndexMem = 64 * 1024
+
+	// indexPageRecords is the number of records in a single index page.
+	indexPageRecords = 1024
+
+	// indexCachedPages is the number of index pages the reader keeps.
+	indexCachedPages = 4
+)
+
+// indexSpool collects index records while writing.
+// It keeps at most maxIndexMem bytes in memory and moves
+// everything else to a temporary file.
+type indexSpool struct {
+	buf  []byte
+	fd   *os.File
+	size int64
+}
+
+func (is *indexSpool) add(rc record) error {
+	var data [indexChunkSize]byte
+	rc.marshal(data[:])
+
+	is.buf = append(is.buf, data[:]...)
+	is.size += indexChunkSize
+	if len(is.buf) < maxIndexMem {
+		return nil
+	}
+
+	if is.fd == nil {
+		fd, err := ioutil.TempFile("", "brig-compress-index-")
+		if err != nil {
+			return err
+		}
+
+		is.fd = fd
+	}
+
+	if _, err := is.fd.Write(is.buf); err != nil {
+		return err
+	}
+
+	is.buf = is.buf[:0]
+	return nil
+}
+
+// writeTo copies the whole index to `w`.
+func (is *indexSpool) writeTo(w io.Writer) error {
+	if is.fd != nil {
+		if _, err := is.fd.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		// Not io.Copy: `w` might implement io.ReaderFrom,
+		// which does not always mix with Write().
+		buf := make([]byte, maxIndexMem)
+		for {
+			n, err := is.fd.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return werr
+				}
+			}
+
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := w.Write(is.buf)
+	return err
+}
+
+// close removes the temporary file, if any.
+func (is *indexSpool) close() error {
+	if is.fd == nil {
+		return nil
+	}
+
+	fd := is.fd
+	is.fd = nil
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(fd.Name())
+}
+
+// indexPage is a range of records starting at record `first`.
+type indexPage struct {
+	first   int64
+	records []record
+}
+
+// pagedIndex gives access to the index at the end of a compressed stream.
+// Small indices are read at once, big ones are loaded in pages on demand,
+// so the memory used does not depend on the size of the stream.
+type pagedIndex struct {
+	rawR   io.ReadSeeker
+	offset int64
+	count  int64
+
+	// Most recently used page is at the front.
+	pages []*indexPage
+}
+
+func newPagedIndex(rawR io.ReadSeeker, offset int64, size uint64) (*pagedIndex, error) {
+	if size%indexChunkSize != 0 {
+		return nil, ErrBadIndex
+	}
+
+	pi := &pagedIndex{
+		rawR:   rawR,
+		offset: offset,
+		count:  int64(size / indexChunkSize),
+	}
+
+	if pi.count == 0 {
+		return nil, ErrBadIndex
+	}
+
+	if size <= maxIndexMem {
+		// Load everything now, so broken indices are noticed early:
+		page, err := pi.loadPage(0, pi.count)
+		if err != nil {
+			return nil, err
+		}
+
+		pi.pages = []*indexPage{page}
+	}
+
+	return pi, nil
+}
+
+// Len returns the number of records in the index.
+func (pi *pagedIndex) Len() int {
+	return int(pi.count)
+}
+
+func (pi *pagedIndex) loadPage(first, n int64) (*indexPage, error) {
+	if first+n > pi.count {
+		n = pi.count - first
+	}
+
+	if _, err := pi.rawR.Seek(pi.offset+first*indexChunkSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, n*indexChunkSize)
+	if _, err := io.ReadFull(pi.rawR, buf); err != nil {
+		return nil, err
+	}
+
+	page := &indexPage{
+		first:   first,
+		records: make([]record, n),
+	}
+
+	// Records have to be strictly ascending, at least inside a page:
+	prev := record{-1, -1}
+	for idx := range page.records {
+		curr := &page.records[idx]
+		curr.unmarshal(buf[idx*indexChunkSize:])
+		if prev.rawOff >= curr.rawOff || prev.zipOff >= curr.zipOff {
+			return nil, ErrBadIndex
+		}
+
+		prev = *curr
+	}
+
+	return page, nil
+}
+
+// At returns the record with the number `idx`.
+func (pi *pagedIndex) At(idx int) (record, error) {
+	for pos, page := range pi.pages {
+		rel := int64(idx) - page.first
+		if rel < 0 || rel >= int64(len(page.records)) {
+			continue
+		}
+
+		// Move it to the front:
+		copy(pi.pages[1:pos+1], pi.pages[:pos])
+		pi.pages[0] = page
+		return page.records[rel], nil
+	}
+
+	first := int64(idx) / indexPageRecords * indexPageRecords
+	page, err := pi.loadPage(first, indexPageRecords)
+	if err != nil {
+		return record{}, err
+	}
+
+	if len(pi.pages) < indexCachedPages {
+		pi.pages = append(pi.pages, nil)
+	}
+
+	copy(pi.pages[1:], pi.pages[:len(pi.pages)-1])
+	pi.pages[0] = page
+	return page.records[int64(idx)-first], nil
+}
@@ -15,7 +15,7 @@ type Reader struct {
 	rawR io.ReadSeeker
 
 	// Index with records which contain chunk offsets.
-	index []record
+	index *pagedIndex
 
 	// Buffer holds currently read data; maxChunkSize.
 	chunkBuf *chunkbuf.ChunkBuffer
@@ -35,6 +35,9 @@ type Reader struct {
 	// Holds algorithm interface.
 	algo Algorithm
 
+	// Used to look up the dictionaries zstd chunks reference.
+	dicts DictStore
+
 	decodeBuf *bytes.Buffer
 }
 
@@ -50,7 +53,12 @@ func (r *Reader) Seek(destOff int64, whence int) (int64, error) {
 			return 0, err
 		}
 
-		return r.Seek(r.index[len(r.index)-1].rawOff+destOff, io.SeekStart)
+		last, err := r.index.At(r.index.Len() - 1)
+		if err != nil {
+			return 0, err
+		}
+
+		return r.Seek(last.rawOff+destOff, io.SeekStart)
 	case io.SeekCurrent:
 		return r.Seek(r.zipSeekOffset+destOff, io.SeekStart)
 	}
@@ -63,8 +71,15 @@ func (r *Reader) Seek(destOff int64, whence int) (int64, error) {
 		return 0, io.EOF
 	}
 
-	destRecord, _ := r.chunkLookup(destOff, true)
-	currRecord, _ := r.chunkLookup(r.zipSeekOffset, true)
+	destRecord, _, err := r.chunkLookup(destOff, true)
+	if err != nil {
+		return 0, err
+	}
+
+	currRecord, _, err := r.chunkLookup(r.zipSeekOffset, true)
+	if err != nil {
+		return 0, err
+	}
 
 	r.rawSeekOffset = destRecord.zipOff
 	r.zipSeekOffset = destOff
@@ -88,25 +103,51 @@ func (r *Reader) Seek(destOff int64, whence int) (int64, error) {
 // in. If currOff is 0, the first and second record is returned. If currOff is
 // at the end of file the end record (currRecord) is returned twice.  The offset
 // difference (chunksize) between prevRecord and currRecord is then equal to 0.
-func (r *Reader) chunkLookup(currOff int64, isRawOff bool) (*record, *record) {
+func (r *Reader) chunkLookup(currOff int64, isRawOff bool) (record, record, error) {
 	// Get smallest index that is before given currOff.
-	i := sort.Search(len(r.index), func(i int) bool {
+	var lookupErr error
+	i := sort.Search(r.index.Len(), func(i int) bool {
+		rc, err := r.index.At(i)
+		if err != nil {
+			lookupErr = err
+			return true
+		}
+
 		if isRawOff {
-			return r.index[i].rawOff > currOff
+			return rc.rawOff > currOff
 		}
-		return r.index[i].zipOff > currOff
+		return rc.zipOff > currOff
 	})
 
-	// Beginning of the file, first chunk: prev offset is 0, curr offset is 1.
-	if i == 0 {
-		return &r.index[i], &r.index[i+1]
+	if lookupErr != nil {
+		return record{}, record{}, lookupErr
 	}
 
-	// End of the file, last chunk: prev and curr offset is the last index.
-	if i == len(r.index) {
-		return &r.index[i-1], &r.index[i-1]
+	prevIdx, currIdx := i-1, i
+	switch {
+	case i == 0:
+		// Beginning of the file, first chunk: prev offset is 0, curr offset is 1.
+		prevIdx, currIdx = 0, 1
+	case i == r.index.Len():
+		// End of the file, last chunk: prev and curr offset is the last index.
+		currIdx = i - 1
+	}
+
+	if currIdx >= r.index.Len() {
+		return record{}, record{}, ErrBadIndex
+	}
+
+	prevRecord, err := r.index.At(prevIdx)
+	if err != nil {
+		return record{}, record{}, err
 	}
-	return &r.index[i-1], &r.index[i]
+
+	currRecord, err := r.index.At(currIdx)
+	if err != nil {
+		return record{}, record{}, err
+	}
+
+	return prevRecord, currRecord, nil
 }
 
 func (r *Reader) parseTrailerIfNeeded() error {
@@ -149,35 +190,25 @@ func (r *Reader) parseTrailerIfNeeded() error {
 	}
 	r.algo = algo
 
-	// Seek and read index into buffer.
-	seekIdx := -(int64(r.trailer.indexSize) + trailerSize)
-	if _, err := r.rawR.Seek(seekIdx, io.SeekEnd); err != nil {
-		return err
+	// Every zstd frame says which dictionary it needs,
+	// they are only looked up when a chunk is decoded.
+	if header.algo == AlgoZstd {
+		r.algo = zstdAlgo{dicts: r.dicts}
 	}
 
-	indexBuf := make([]byte, r.trailer.indexSize)
-	if _, err := r.rawR.Read(indexBuf); err != nil {
+	// The index is read lazily, only its position is remembered.
+	indexOff, err := r.rawR.Seek(-(int64(r.trailer.indexSize) + trailerSize), io.SeekEnd)
+	if err != nil {
 		return err
 	}
 
-	// Build index with records. A record encapsulates a raw offset and the
-	// compressed offset it is mapped to.
-	prevRecord := record{-1, -1}
-	for i := uint64(0); i < (r.trailer.indexSize / indexChunkSize); i++ {
-		currRecord := record{}
-		currRecord.unmarshal(indexBuf)
-
-		if prevRecord.rawOff >= currRecord.rawOff {
-			return ErrBadIndex
-		}
-
-		if prevRecord.zipOff >= currRecord.zipOff {
-			return ErrBadIndex
-		}
-		r.index = append(r.index, currRecord)
-		indexBuf = indexBuf[indexChunkSize:]
+	index, err := newPagedIndex(r.rawR, indexOff, r.trailer.indexSize)
+	if err != nil {
+		return err
 	}
 
+	r.index = index
+
 	// Set Reader to beginning of file
 	if _, err := r.rawR.Seek(headerSize, io.SeekStart); err != nil {
 		return err
@@ -230,9 +261,11 @@ func (r *Reader) Read(p []byte) (int, error) {
 	read := 0
 	for {
 		if r.chunkBuf.Len() != 0 {
+			// A drained chunk buffer returns io.EOF; that only
+			// means that the next chunk has to be read.
 			n, err := r.chunkBuf.Read(p)
-			if err != nil {
-				return n, err
+			if err != nil && err != io.EOF {
+				return read + n, err
 			}
 
 			r.zipSeekOffset += int64(n)
@@ -254,9 +287,9 @@ func (r *Reader) Read(p []byte) (int, error) {
 
 func (r *Reader) fixZipChunk() (int64, error) {
 	// Get the start and end record of the chunk currOff is located in.
-	prevRecord, currRecord := r.chunkLookup(r.rawSeekOffset, false)
-	if currRecord == nil || prevRecord == nil {
-		return 0, ErrBadIndex
+	prevRecord, currRecord, err := r.chunkLookup(r.rawSeekOffset, false)
+	if err != nil {
+		return 0, err
 	}
 
 	// Determinate uncompressed chunksize; should only be 0 on empty file or at the end of file.
@@ -303,8 +336,15 @@ func (r *Reader) readZipChunk() ([]byte, error) {
 // is the purpose of this layer, a ReadSeeker is required as parameter. The used
 // compression algorithm is chosen based on trailer information.
 func NewReader(r io.ReadSeeker) *Reader {
+	return NewReaderWithDicts(r, nil)
+}
+
+// NewReaderWithDicts is like NewReader, but can also read streams that
+// were written by NewWriterWithDict. Their dictionaries are taken from `dicts`.
+func NewReaderWithDicts(r io.ReadSeeker, dicts DictStore) *Reader {
 	return &Reader{
 		rawR:      r,
+		dicts:     dicts,
 		decodeBuf: &bytes.Buffer{},
 		chunkBuf:  chunkbuf.NewChunkBuffer([]byte{}),
 	}
@@ -16,7 +16,7 @@ type Writer struct {
 	chunkBuf *bytes.Buffer
 
 	// Index with records which contain chunk offsets.
-	index []record
+	index *indexSpool
 
 	// Accumulator representing uncompressed offset.
 	rawOff int64
@@ -37,8 +37,8 @@ type Writer struct {
 	headerWritten bool
 }
 
-func (w *Writer) addRecordToIndex() {
-	w.index = append(w.index, record{w.rawOff, w.zipOff})
+func (w *Writer) addRecordToIndex() error {
+	return w.index.add(record{w.rawOff, w.zipOff})
 }
 
 func (w *Writer) flushBuffer(data []byte) error {
@@ -47,7 +47,9 @@ func (w *Writer) flushBuffer(data []byte) error {
 	}
 
 	// Add record with start offset of the current chunk.
-	w.addRecordToIndex()
+	if err := w.addRecordToIndex(); err != nil {
+		return err
+	}
 
 	// Compress and flush the current chunk.
 	encData, err := w.algo.Encode(data)
@@ -92,8 +94,13 @@ func (w *Writer) ReadFrom(r io.Reader) (n int64, err error) {
 	}
 
 	for {
-		n, rerr := r.Read(buf[:])
+		// Always fill whole chunks, short reads would bloat the index:
+		n, rerr := io.ReadFull(r, buf[:])
 		read += n
+		if rerr == io.ErrUnexpectedEOF {
+			rerr = io.EOF
+		}
+
 		if rerr != nil && rerr != io.EOF {
 			return int64(read), rerr
 		}
@@ -141,13 +148,38 @@ func NewWriter(w io.Writer, algoType AlgorithmType) (*Writer, error) {
 		algo:     algo,
 		algoType: algoType,
 		chunkBuf: &bytes.Buffer{},
+		index:    &indexSpool{},
 		trailer:  &trailer{},
 	}, nil
 }
 
+// NewWriterWithDict is like NewWriter, but compresses every chunk with
+// the help of `dict`. Every chunk references the dictionary by its id,
+// so the reader needs to be able to look it up (see NewReaderWithDicts).
+// Only AlgoZstd supports dictionaries.
+func NewWriterWithDict(w io.Writer, algoType AlgorithmType, dict []byte) (*Writer, error) {
+	if algoType != AlgoZstd {
+		return nil, ErrNoDictSupport
+	}
+
+	if len(dict) == 0 || len(dict) > MaxDictSize {
+		return nil, ErrBadDict
+	}
+
+	zipW, err := NewWriter(w, algoType)
+	if err != nil {
+		return nil, err
+	}
+
+	zipW.algo = zstdAlgo{dict: dict}
+	return zipW, nil
+}
+
 // Close cleans up internal resources.
 // Make sure to call close always since it might write data.
 func (w *Writer) Close() error {
+	defer w.index.close()
+
 	if err := w.writeHeaderIfNeeded(); err != nil {
 		return err
 	}
@@ -156,19 +188,15 @@ func (w *Writer) Close() error {
 	if err := w.flushBuffer(w.chunkBuf.Bytes()); err != nil {
 		return err
 	}
-	w.addRecordToIndex()
 
-	// Handle trailer of uncompressed file.
-	// Write compression index trailer and close stream.
-	w.trailer.indexSize = uint64(indexChunkSize * len(w.index))
-	indexBuf := make([]byte, w.trailer.indexSize)
-	indexBufStartOff := indexBuf
-	for _, record := range w.index {
-		record.marshal(indexBuf)
-		indexBuf = indexBuf[indexChunkSize:]
+	if err := w.addRecordToIndex(); err != nil {
+		return err
 	}
 
-	if n, err := w.rawW.Write(indexBufStartOff); err != nil || uint64(n) != w.trailer.indexSize {
+	// Handle trailer of uncompressed file.
+	// Write compression index trailer and close stream.
+	w.trailer.indexSize = uint64(w.index.size)
+	if err := w.index.writeTo(w.rawW); err != nil {
 		return err
 	}
 
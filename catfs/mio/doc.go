@@ -7,4 +7,16 @@
 //
 // This package itself contains utils that stack those on top of each of other
 // in an already usable fashion.
+//
+// None of the layers buffers a whole file, so the memory used by a stream
+// does not depend on the size of the file. Per open stream, encrypt holds one
+// block of plain and one of cipher text (2 x 64KB); compress holds one chunk
+// plus its compressed form (2 x 64KB) and up to 64KB of the chunk index.
+// Bigger indices are spooled to a temporary file while writing and read in
+// pages of 16KB (at most 4 cached) while reading. NewInStream adds a 64KB
+// copy buffer on top of both layers.
+//
+// This sums up to well below 1MB per stream. The exception is the overlay:
+// it keeps every write in memory until it is staged, so its size depends
+// on the amount of modified data, but not on the size of the file below.
 package mio
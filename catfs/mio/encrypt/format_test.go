@@ -455,3 +455,52 @@ func TestEncryptedByteSwaps(t *testing.T) {
 	require.True(t, bytes.Equal(encData1[0:m], encData3[0:m]))
 	require.False(t, bytes.Equal(encData1[m:s], encData3[m:s]))
 }
+
+func TestVerify(t *testing.T) {
+	for _, size := range []int{0, 1, defaultMaxBlockSize, 2*defaultMaxBlockSize + 1} {
+		data := testutil.CreateDummyBuf(int64(size))
+		encBuf := &bytes.Buffer{}
+		_, err := Encrypt(TestKey, bytes.NewReader(data), encBuf)
+		require.Nil(t, err)
+
+		encData := encBuf.Bytes()
+		n, err := Verify(bytes.NewReader(encData), TestKey)
+		require.Nil(t, err, "size %d", size)
+		require.Equal(t, int64(size), n)
+
+		if size == 0 {
+			continue
+		}
+
+		// A flipped bit in the last block:
+		flipped := append([]byte{}, encData...)
+		flipped[len(flipped)-1] ^= 0x01
+		_, err = Verify(bytes.NewReader(flipped), TestKey)
+		require.NotNil(t, err)
+
+		// A block cut in the middle:
+		_, err = Verify(bytes.NewReader(encData[:len(encData)-5]), TestKey)
+		require.NotNil(t, err)
+
+		// The wrong key:
+		badKey := append([]byte{}, TestKey...)
+		badKey[0]++
+		_, err = Verify(bytes.NewReader(encData), badKey)
+		require.NotNil(t, err)
+	}
+
+	// Blocks that were swapped:
+	data := testutil.CreateDummyBuf(3 * defaultMaxBlockSize)
+	encBuf := &bytes.Buffer{}
+	_, err := Encrypt(TestKey, bytes.NewReader(data), encBuf)
+	require.Nil(t, err)
+
+	encData := encBuf.Bytes()
+	blockSize := (len(encData) - headerSize) / 3
+	swapped := append([]byte{}, encData[:headerSize]...)
+	swapped = append(swapped, encData[headerSize+blockSize:headerSize+2*blockSize]...)
+	swapped = append(swapped, encData[headerSize:headerSize+blockSize]...)
+	swapped = append(swapped, encData[headerSize+2*blockSize:]...)
+	_, err = Verify(bytes.NewReader(swapped), TestKey)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,87 @@
+package encrypt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Verify checks the header and the MAC of every block of the encrypted
+// stream in `r`, without handing out any plaintext. Each block is opened
+// into a scratch buffer that is reused and thrown away, so this is cheaper
+// than Decrypt when only the integrity of stored data is of interest.
+//
+// Besides the MACs, the structure is checked: the block numbers have to be
+// consecutive and only the last block may be smaller than the block size.
+// Note that cutting the stream at a block boundary can not be detected this
+// way; the caller has to know the expected size for that.
+//
+// It returns the number of plaintext bytes that were authenticated.
+func Verify(r io.Reader, key []byte) (int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, fmt.Errorf("No valid header found, damaged file? (%v)", err)
+	}
+
+	info, err := ParseHeader(header, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if info.Version != version {
+		return 0, fmt.Errorf("This implementation does not support versions != %d", version)
+	}
+
+	if uint32(len(key)) != info.Keylen {
+		return 0, fmt.Errorf("Key length differs: file=%d, user=%d", info.Keylen, len(key))
+	}
+
+	c := aeadCommon{}
+	if err := c.initAeadCommon(key, info.Cipher, int64(info.Blocklen)); err != nil {
+		return 0, err
+	}
+
+	var (
+		size     int64
+		blockNum uint64
+		decBuf   = make([]byte, 0, info.Blocklen)
+		encSize  = int(info.Blocklen) + c.aead.Overhead()
+		encBuf   = c.encBuf[:encSize]
+		isLast   = false
+		overhead = c.aead.Overhead()
+	)
+
+	for ; ; blockNum++ {
+		if n, err := io.ReadFull(r, c.nonce); err == io.EOF {
+			return size, nil
+		} else if err != nil {
+			return size, fmt.Errorf("block %d: truncated nonce (%d bytes): %v", blockNum, n, err)
+		}
+
+		if isLast {
+			return size, fmt.Errorf("block %d: follows a short block", blockNum)
+		}
+
+		if readNum := binary.LittleEndian.Uint64(c.nonce); readNum != blockNum {
+			return size, fmt.Errorf("bad block number; as %d, should be %d", readNum, blockNum)
+		}
+
+		n, err := io.ReadFull(r, encBuf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			isLast = true
+		} else if err != nil {
+			return size, err
+		}
+
+		if n <= overhead {
+			return size, fmt.Errorf("block %d: too short (%d bytes)", blockNum, n)
+		}
+
+		decBuf, err = c.aead.Open(decBuf[:0], c.nonce, encBuf[:n], nil)
+		if err != nil {
+			return size, fmt.Errorf("block %d: %v", blockNum, err)
+		}
+
+		size += int64(len(decBuf))
+	}
+}
@@ -68,19 +68,44 @@ func (w *Writer) Write(p []byte) (int, error) {
 		return 0, err
 	}
 
-	for int64(w.rbuf.Len()) >= w.maxBlockSize {
+	written := len(p)
+
+	// Complete the block that a previous write left over:
+	if w.rbuf.Len() > 0 {
+		missing := int(w.maxBlockSize) - w.rbuf.Len()
+		if missing > len(p) {
+			missing = len(p)
+		}
+
+		w.rbuf.Write(p[:missing])
+		p = p[missing:]
+
+		if int64(w.rbuf.Len()) < w.maxBlockSize {
+			return written, nil
+		}
+
 		if _, err := w.flushPack(w.rbuf.Next(int(w.maxBlockSize))); err != nil {
 			return 0, err
 		}
 	}
 
+	// Encrypt full blocks directly from `p`, so we never
+	// buffer more than a single block, no matter how big `p` is:
+	for int64(len(p)) >= w.maxBlockSize {
+		if _, err := w.flushPack(p[:w.maxBlockSize]); err != nil {
+			return 0, err
+		}
+
+		p = p[w.maxBlockSize:]
+	}
+
 	// Remember left-overs for next write:
 	if _, err := w.rbuf.Write(p); err != nil {
 		return 0, nil
 	}
 
 	// Fake the amount of data we've written:
-	return len(p), nil
+	return written, nil
 }
 
 func (w *Writer) flushPack(pack []byte) (int, error) {
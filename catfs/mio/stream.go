@@ -22,12 +22,18 @@ type Stream interface {
 // `key` is used to decrypt the data. The compression algorithm is read
 // from the stream header.
 func NewOutStream(r io.ReadSeeker, key []byte) (Stream, error) {
+	return NewOutStreamWithDicts(r, key, nil)
+}
+
+// NewOutStreamWithDicts is like NewOutStream, but takes
+// the compression dictionary a stream might need from `dicts`.
+func NewOutStreamWithDicts(r io.ReadSeeker, key []byte, dicts compress.DictStore) (Stream, error) {
 	rEnc, err := encrypt.NewReader(r, key)
 	if err != nil {
 		return nil, err
 	}
 
-	rZip := compress.NewReader(rEnc)
+	rZip := compress.NewReaderWithDicts(rEnc, dicts)
 	return struct {
 		io.Reader
 		io.Seeker
@@ -44,6 +50,12 @@ func NewOutStream(r io.ReadSeeker, key []byte) (Stream, error) {
 // NewInStream creates a new stream that pipes data into ipfs.
 // The data is read from `r`, encrypted with `key` and compressed with `algo`.
 func NewInStream(r io.Reader, key []byte, algo compress.AlgorithmType) (io.Reader, error) {
+	return NewInStreamWithDict(r, key, algo, nil)
+}
+
+// NewInStreamWithDict is like NewInStream, but compresses with the help
+// of the dictionary `dict`, if it is not nil.
+func NewInStreamWithDict(r io.Reader, key []byte, algo compress.AlgorithmType, dict []byte) (io.Reader, error) {
 	pr, pw := io.Pipe()
 
 	// Setup the writer part:
@@ -52,7 +64,14 @@ func NewInStream(r io.Reader, key []byte, algo compress.AlgorithmType) (io.Reade
 		return nil, encErr
 	}
 
-	wZip, zipErr := compress.NewWriter(wEnc, algo)
+	var wZip *compress.Writer
+	var zipErr error
+	if dict != nil {
+		wZip, zipErr = compress.NewWriterWithDict(wEnc, algo, dict)
+	} else {
+		wZip, zipErr = compress.NewWriter(wEnc, algo)
+	}
+
 	if zipErr != nil {
 		return nil, zipErr
 	}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/sahib/brig/catfs/mio/compress"
 	"github.com/sahib/brig/catfs/mio/encrypt"
@@ -434,3 +438,129 @@ func TestStreamSizeBySeek(t *testing.T) {
 	require.Equal(t, int64(len(data)), n)
 	require.Equal(t, outBuf.Bytes(), data)
 }
+
+// zeroChecker counts the bytes written to it and fails if any is not zero.
+type zeroChecker struct {
+	n       int64
+	nonZero bool
+}
+
+func (zc *zeroChecker) Write(buf []byte) (int, error) {
+	for _, b := range buf {
+		if b != 0 {
+			zc.nonZero = true
+			break
+		}
+	}
+
+	zc.n += int64(len(buf))
+	return len(buf), nil
+}
+
+// watchHeap samples the heap until the returned function is called,
+// which returns the maximum heap size seen.
+func watchHeap() func() uint64 {
+	stop := make(chan bool)
+	result := make(chan uint64)
+
+	go func() {
+		maxHeap := uint64(0)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			stats := runtime.MemStats{}
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > maxHeap {
+				maxHeap = stats.HeapInuse
+			}
+
+			select {
+			case <-stop:
+				result <- maxHeap
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		stop <- true
+		return <-result
+	}
+}
+
+func testConstantMemory(t *testing.T, size int64) {
+	tmpDir, err := ioutil.TempDir("", "brig-mio-stress")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// A sparse file reads as zeros without using any disk space:
+	srcFd, err := os.Create(filepath.Join(tmpDir, "src"))
+	require.Nil(t, err)
+	defer srcFd.Close()
+	require.Nil(t, srcFd.Truncate(size))
+
+	runtime.GC()
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	baseHeap := stats.HeapInuse
+	stopWatch := watchHeap()
+
+	encStream, err := NewInStream(srcFd, TestKey, compress.AlgoLZ4)
+	require.Nil(t, err)
+
+	dstFd, err := os.Create(filepath.Join(tmpDir, "dst"))
+	require.Nil(t, err)
+	defer dstFd.Close()
+
+	_, err = io.Copy(dstFd, encStream)
+	require.Nil(t, err)
+
+	_, err = dstFd.Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	decStream, err := NewOutStream(dstFd, TestKey)
+	require.Nil(t, err)
+
+	// Jump around a bit to load different parts of the index:
+	end, err := decStream.Seek(0, io.SeekEnd)
+	require.Nil(t, err)
+	require.Equal(t, size, end)
+
+	_, err = decStream.Seek(size/2, io.SeekStart)
+	require.Nil(t, err)
+
+	_, err = decStream.Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	checker := &zeroChecker{}
+	_, err = decStream.WriteTo(checker)
+	require.Nil(t, err)
+	require.Equal(t, size, checker.n)
+	require.False(t, checker.nonZero)
+
+	maxHeap := stopWatch()
+	if maxHeap > baseHeap {
+		// See the package documentation for the budget of a single stream.
+		require.True(
+			t,
+			maxHeap-baseHeap < 32*1024*1024,
+			"streaming used too much memory: %d bytes",
+			maxHeap-baseHeap,
+		)
+	}
+}
+
+func TestStreamConstantMemory(t *testing.T) {
+	// Big enough to spool and page the compression index:
+	testConstantMemory(t, 512*1024*1024)
+}
+
+func TestStreamConstantMemoryStress(t *testing.T) {
+	if os.Getenv("BRIG_STRESS_TEST") == "" {
+		t.Skip("set BRIG_STRESS_TEST=1 to stream a 100GB file")
+	}
+
+	testConstantMemory(t, 100*1024*1024*1024)
+}
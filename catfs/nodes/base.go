@@ -2,6 +2,8 @@ package nodes
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -38,6 +40,18 @@ type Base struct {
 
 	// Unique identifier for this node
 	inode uint64
+
+	// POSIX permission bits (and os.ModeSymlink); only valid if modeSet.
+	mode os.FileMode
+
+	// modeSet is true once mode was set explicitly (even to zero).
+	modeSet bool
+
+	// Numeric owner of this node; only meaningful if mode is set.
+	uid, gid uint32
+
+	// User-defined labels; sorted and without duplicates.
+	labels []string
 }
 
 // copyBase will copy all attributes from the base.
@@ -51,6 +65,11 @@ func (b *Base) copyBase(inode uint64) Base {
 		modTime:  b.modTime,
 		nodeType: b.nodeType,
 		inode:    inode,
+		mode:     b.mode,
+		modeSet:  b.modeSet,
+		uid:      b.uid,
+		gid:      b.gid,
+		labels:   append([]string(nil), b.labels...),
 	}
 }
 
@@ -96,8 +115,82 @@ func (b *Base) Inode() uint64 {
 	return b.inode
 }
 
+// Mode returns the permission bits of this node and os.ModeSymlink
+// for symbolic links. It is only meaningful if HasMode() is true.
+func (b *Base) Mode() os.FileMode {
+	return b.mode
+}
+
+// HasMode returns true if the mode of this node was set explicitly.
+func (b *Base) HasMode() bool {
+	return b.modeSet
+}
+
+// SetMode sets the permission bits of this node.
+// Only os.ModePerm, the sticky/setuid/setgid bits and os.ModeSymlink are kept.
+func (b *Base) SetMode(mode os.FileMode) {
+	b.mode = mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky | os.ModeSymlink)
+	b.modeSet = true
+}
+
+// Owner returns the numeric user and group id of this node.
+func (b *Base) Owner() (uint32, uint32) {
+	return b.uid, b.gid
+}
+
+// SetOwner sets the numeric user and group id of this node.
+func (b *Base) SetOwner(uid, gid uint32) {
+	b.uid = uid
+	b.gid = gid
+}
+
+// Labels returns the user-defined labels of this node, sorted by name.
+func (b *Base) Labels() []string {
+	return append([]string{}, b.labels...)
+}
+
+// SetLabels replaces the labels of this node. Empty and duplicate
+// labels are dropped, surrounding whitespace is ignored.
+func (b *Base) SetLabels(labels []string) {
+	set := make(map[string]bool)
+	b.labels = nil
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || set[label] {
+			continue
+		}
+
+		set[label] = true
+		b.labels = append(b.labels, label)
+	}
+
+	sort.Strings(b.labels)
+}
+
+// IsSymlink returns true if the node is a symbolic link.
+// The content of a symbolic link is its target.
+func (b *Base) IsSymlink() bool {
+	return b.mode&os.ModeSymlink != 0
+}
+
 /////// UTILS /////////
 
+// permHashSuffix returns what mode, owner and labels add to the tree hash.
+// Nodes without an explicit mode or labels hash like before both existed.
+func (b *Base) permHashSuffix() string {
+	suffix := ""
+	if b.modeSet {
+		suffix = fmt.Sprintf("|%o|%d|%d", uint32(b.mode), b.uid, b.gid)
+	}
+
+	if len(b.labels) > 0 {
+		// Labels may contain anything but a zero byte:
+		suffix += "|labels:" + strings.Join(b.labels, "\x00")
+	}
+
+	return suffix
+}
+
 func (b *Base) setBaseAttrsToNode(capnode capnp_model.Node) error {
 	modTimeBin, err := b.modTime.MarshalBinary()
 	if err != nil {
@@ -124,6 +217,26 @@ func (b *Base) setBaseAttrsToNode(capnode capnp_model.Node) error {
 	}
 
 	capnode.SetInode(b.inode)
+	capnode.SetMode(uint32(b.mode))
+	capnode.SetUid(b.uid)
+	capnode.SetGid(b.gid)
+	capnode.SetModeSet(b.modeSet)
+
+	if len(b.labels) == 0 {
+		return nil
+	}
+
+	labels, err := capnode.NewLabels(int32(len(b.labels)))
+	if err != nil {
+		return err
+	}
+
+	for idx, label := range b.labels {
+		if err := labels.Set(idx, label); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -178,6 +291,26 @@ func (b *Base) parseBaseAttrsFromNode(capnode capnp_model.Node) error {
 	}
 
 	b.inode = capnode.Inode()
+	b.mode = os.FileMode(capnode.Mode())
+	b.uid = capnode.Uid()
+	b.gid = capnode.Gid()
+	b.modeSet = capnode.ModeSet() || b.mode != 0
+
+	labels, err := capnode.Labels()
+	if err != nil {
+		return err
+	}
+
+	b.labels = nil
+	for idx := 0; idx < labels.Len(); idx++ {
+		label, err := labels.At(idx)
+		if err != nil {
+			return err
+		}
+
+		b.labels = append(b.labels, label)
+	}
+
 	return nil
 }
 
@@ -17,12 +17,12 @@ type Commit_merge Commit
 const Commit_TypeID = 0x8da013c66e545daf
 
 func NewCommit(s *capnp.Segment) (Commit, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 10})
 	return Commit{st}, err
 }
 
 func NewRootCommit(s *capnp.Segment) (Commit, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 10})
 	return Commit{st}, err
 }
 
@@ -145,12 +145,89 @@ func (s Commit_merge) SetHead(v []byte) error {
 	return s.Struct.SetData(5, v)
 }
 
+func (s Commit) Signature() ([]byte, error) {
+	p, err := s.Struct.Ptr(6)
+	return []byte(p.Data()), err
+}
+
+func (s Commit) HasSignature() bool {
+	p, err := s.Struct.Ptr(6)
+	return p.IsValid() || err != nil
+}
+
+func (s Commit) SetSignature(v []byte) error {
+	return s.Struct.SetData(6, v)
+}
+
+func (s Commit) Device() (string, error) {
+	p, err := s.Struct.Ptr(7)
+	return p.Text(), err
+}
+
+func (s Commit) HasDevice() bool {
+	p, err := s.Struct.Ptr(7)
+	return p.IsValid() || err != nil
+}
+
+func (s Commit) DeviceBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(7)
+	return p.TextBytes(), err
+}
+
+func (s Commit) SetDevice(v string) error {
+	return s.Struct.SetText(7, v)
+}
+
+func (s Commit) Origin() (string, error) {
+	p, err := s.Struct.Ptr(8)
+	return p.Text(), err
+}
+
+func (s Commit) HasOrigin() bool {
+	p, err := s.Struct.Ptr(8)
+	return p.IsValid() || err != nil
+}
+
+func (s Commit) OriginBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(8)
+	return p.TextBytes(), err
+}
+
+func (s Commit) SetOrigin(v string) error {
+	return s.Struct.SetText(8, v)
+}
+
+func (s Commit) Labels() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(9)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s Commit) HasLabels() bool {
+	p, err := s.Struct.Ptr(9)
+	return p.IsValid() || err != nil
+}
+
+func (s Commit) SetLabels(v capnp.TextList) error {
+	return s.Struct.SetPtr(9, v.List.ToPtr())
+}
+
+// NewLabels sets the labels field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s Commit) NewLabels(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(9, l.List.ToPtr())
+	return l, err
+}
+
 // Commit_List is a list of Commit.
 type Commit_List struct{ capnp.List }
 
 // NewCommit creates a new list of Commit.
 func NewCommit_List(s *capnp.Segment, sz int32) (Commit_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 10}, sz)
 	return Commit_List{l}, err
 }
 
@@ -266,6 +343,108 @@ func (p DirEntry_Promise) Struct() (DirEntry, error) {
 	return DirEntry{s}, err
 }
 
+// DirShard holds a part of the entries of a big directory
+type DirShard struct{ capnp.Struct }
+
+// DirShard_TypeID is the unique identifier for the type DirShard.
+const DirShard_TypeID = 0x8c6c48f65f5b54e4
+
+func NewDirShard(s *capnp.Segment) (DirShard, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return DirShard{st}, err
+}
+
+func NewRootDirShard(s *capnp.Segment) (DirShard, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return DirShard{st}, err
+}
+
+func ReadRootDirShard(msg *capnp.Message) (DirShard, error) {
+	root, err := msg.RootPtr()
+	return DirShard{root.Struct()}, err
+}
+
+func (s DirShard) String() string {
+	str, _ := text.Marshal(0x8c6c48f65f5b54e4, s.Struct)
+	return str
+}
+
+func (s DirShard) Children() (DirEntry_List, error) {
+	p, err := s.Struct.Ptr(0)
+	return DirEntry_List{List: p.List()}, err
+}
+
+func (s DirShard) HasChildren() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s DirShard) SetChildren(v DirEntry_List) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewChildren sets the children field to a newly
+// allocated DirEntry_List, preferring placement in s's segment.
+func (s DirShard) NewChildren(n int32) (DirEntry_List, error) {
+	l, err := NewDirEntry_List(s.Struct.Segment(), n)
+	if err != nil {
+		return DirEntry_List{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+func (s DirShard) Contents() (DirEntry_List, error) {
+	p, err := s.Struct.Ptr(1)
+	return DirEntry_List{List: p.List()}, err
+}
+
+func (s DirShard) HasContents() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s DirShard) SetContents(v DirEntry_List) error {
+	return s.Struct.SetPtr(1, v.List.ToPtr())
+}
+
+// NewContents sets the contents field to a newly
+// allocated DirEntry_List, preferring placement in s's segment.
+func (s DirShard) NewContents(n int32) (DirEntry_List, error) {
+	l, err := NewDirEntry_List(s.Struct.Segment(), n)
+	if err != nil {
+		return DirEntry_List{}, err
+	}
+	err = s.Struct.SetPtr(1, l.List.ToPtr())
+	return l, err
+}
+
+// DirShard_List is a list of DirShard.
+type DirShard_List struct{ capnp.List }
+
+// NewDirShard creates a new list of DirShard.
+func NewDirShard_List(s *capnp.Segment, sz int32) (DirShard_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2}, sz)
+	return DirShard_List{l}, err
+}
+
+func (s DirShard_List) At(i int) DirShard { return DirShard{s.List.Struct(i)} }
+
+func (s DirShard_List) Set(i int, v DirShard) error { return s.List.SetStruct(i, v.Struct) }
+
+func (s DirShard_List) String() string {
+	str, _ := text.MarshalList(0x8c6c48f65f5b54e4, s.List)
+	return str
+}
+
+// DirShard_Promise is a wrapper for a DirShard promised by a client call.
+type DirShard_Promise struct{ *capnp.Pipeline }
+
+func (p DirShard_Promise) Struct() (DirShard, error) {
+	s, err := p.Pipeline.Struct()
+	return DirShard{s}, err
+}
+
 // Directory contains one or more directories or files
 type Directory struct{ capnp.Struct }
 
@@ -273,12 +452,12 @@ type Directory struct{ capnp.Struct }
 const Directory_TypeID = 0xe24c59306c829c01
 
 func NewDirectory(s *capnp.Segment) (Directory, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 4})
 	return Directory{st}, err
 }
 
 func NewRootDirectory(s *capnp.Segment) (Directory, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 4})
 	return Directory{st}, err
 }
 
@@ -369,12 +548,37 @@ func (s Directory) NewContents(n int32) (DirEntry_List, error) {
 	return l, err
 }
 
+func (s Directory) Shards() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(3)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Directory) HasShards() bool {
+	p, err := s.Struct.Ptr(3)
+	return p.IsValid() || err != nil
+}
+
+func (s Directory) SetShards(v capnp.DataList) error {
+	return s.Struct.SetPtr(3, v.List.ToPtr())
+}
+
+// NewShards sets the shards field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Directory) NewShards(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(3, l.List.ToPtr())
+	return l, err
+}
+
 // Directory_List is a list of Directory.
 type Directory_List struct{ capnp.List }
 
 // NewDirectory creates a new list of Directory.
 func NewDirectory_List(s *capnp.Segment, sz int32) (Directory_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 4}, sz)
 	return Directory_List{l}, err
 }
 
@@ -402,12 +606,12 @@ type File struct{ capnp.Struct }
 const File_TypeID = 0x8ea7393d37893155
 
 func NewFile(s *capnp.Segment) (File, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3})
 	return File{st}, err
 }
 
 func NewRootFile(s *capnp.Segment) (File, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3})
 	return File{st}, err
 }
 
@@ -462,12 +666,31 @@ func (s File) SetKey(v []byte) error {
 	return s.Struct.SetData(1, v)
 }
 
+func (s File) MimeType() (string, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.Text(), err
+}
+
+func (s File) HasMimeType() bool {
+	p, err := s.Struct.Ptr(2)
+	return p.IsValid() || err != nil
+}
+
+func (s File) MimeTypeBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.TextBytes(), err
+}
+
+func (s File) SetMimeType(v string) error {
+	return s.Struct.SetText(2, v)
+}
+
 // File_List is a list of File.
 type File_List struct{ capnp.List }
 
 // NewFile creates a new list of File.
 func NewFile_List(s *capnp.Segment, sz int32) (File_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 3}, sz)
 	return File_List{l}, err
 }
 
@@ -733,12 +956,12 @@ func (w Node_Which) String() string {
 const Node_TypeID = 0xa629eb7f7066fae3
 
 func NewNode(s *capnp.Segment) (Node, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 7})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 8})
 	return Node{st}, err
 }
 
 func NewRootNode(s *capnp.Segment) (Node, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 7})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 8})
 	return Node{st}, err
 }
 
@@ -994,12 +1217,69 @@ func (s Node) SetBackendHash(v []byte) error {
 	return s.Struct.SetData(6, v)
 }
 
+func (s Node) Mode() uint32 {
+	return s.Struct.Uint32(12)
+}
+
+func (s Node) SetMode(v uint32) {
+	s.Struct.SetUint32(12, v)
+}
+
+func (s Node) Uid() uint32 {
+	return s.Struct.Uint32(16)
+}
+
+func (s Node) SetUid(v uint32) {
+	s.Struct.SetUint32(16, v)
+}
+
+func (s Node) Gid() uint32 {
+	return s.Struct.Uint32(20)
+}
+
+func (s Node) SetGid(v uint32) {
+	s.Struct.SetUint32(20, v)
+}
+
+func (s Node) ModeSet() bool {
+	return s.Struct.Bit(80)
+}
+
+func (s Node) SetModeSet(v bool) {
+	s.Struct.SetBit(80, v)
+}
+
+func (s Node) Labels() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(7)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s Node) HasLabels() bool {
+	p, err := s.Struct.Ptr(7)
+	return p.IsValid() || err != nil
+}
+
+func (s Node) SetLabels(v capnp.TextList) error {
+	return s.Struct.SetPtr(7, v.List.ToPtr())
+}
+
+// NewLabels sets the labels field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s Node) NewLabels(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(7, l.List.ToPtr())
+	return l, err
+}
+
 // Node_List is a list of Node.
 type Node_List struct{ capnp.List }
 
 // NewNode creates a new list of Node.
 func NewNode_List(s *capnp.Segment, sz int32) (Node_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 7}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 24, PointerCount: 8}, sz)
 	return Node_List{l}, err
 }
 
@@ -1036,92 +1316,112 @@ func (p Node_Promise) Ghost() Ghost_Promise {
 	return Ghost_Promise{Pipeline: p.Pipeline.GetPipeline(5)}
 }
 
-const schema_9195d073cb5c5953 = "x\xda\xb4V_l\x14\xd5\x17>\xdf\xbd3;,)" +
-	"\xbf\xdd\xfdMI\x08\xf9\x95\xbd!\x90_!\x15Z\x8a" +
-	"\x916\x18(\xb4\xd2b!\xbdl\x89@\xd08\xec\xde" +
-	"\xeeL\xd8\x9d)3\x83\xb5\xc6\x064\x98\xa0\x06\x03\x11" +
-	"\x13IJ\x04S\xff%\x1a|\xf1\xd1\x98\x98h\x8c\xbe" +
-	"\x18\x1f4\xf1QM4\x9a\xf8,F\x18s\xf7oi" +
-	"*\xf0\xe2[\xf7;\xe7\xde\xfb\x9d\xef|\xe7L{9" +
-	"\xdf\xcd\xfa\xcc\xff\x1bD\xb2\xd7L%\xbf\xfew\xfe\x97" +
-	"\xef\xba\xbf8Kr\x1dXR8z\xfc\xab\xe8\xeb\xd7" +
-	".\xd1\x08\xb38\x8c\xfe\x9bX\x0f;\xcd,;\xcd\xf2" +
-	"\xfd#,\x0fBr5\xff\xe8\xccS\xbf\xaf~\x99r" +
-	"\xeb\xd0>`2\x8b\xa8\xdf\xe1\x83\xb0Oq\xcb>\xc5" +
-	"\xf3\xf6U>CHn<>\xe9\x7fn_\xbb\xa0\x1f" +
-	"X\x9c\x9f\xd2\xf97\xf9f\xd8i\xc3\xb2\xd3F\xbe\x7f" +
-	"\xc0xL\xdf\x7f\xb8\xef\xc5\x87\x1e\x1ex\xe7\x95\xa5\x07" +
-	"j\x0fx\xe6Z\xd8\xb3\xa6e\xcf\x9ay\xfb\xbay\x83" +
-	"\x90\xfc\xf8\xe7\xd4\xf4\x99\xdf6\xbd\xbd\xb4\x02\xcb2`" +
-	"\xf4\x0f\xa4\xd6\xc2\x1eKY\xf6X*\xdf?\x97\x0a\x18" +
-	"!Y\xf8i\xfc\xfb\xcc\xc2\x1f\x9f\x90\xdc\x88E\x04W" +
-	"\xa7,\x10\xf5\x9b\xe9c \xd8\xb9\xb4f\x8f\xf9\xe7+" +
-	"\xbdG\xc7\x7fXJ\x86k2\xd5\xf4\x1e\xd8si\xcb" +
-	"\x9eK\xe7\xed\x8f\xd2?\xd3\x8e\xa4\xe8\xc4S\xd1V?" +
-	"\xe0%\x15m-:\xd3\xfe\xf4V?(\xa9hK\xed" +
-	"\xef\xc1}\xae\x15D\xf1\x04 \x0d\xb0\xe4\x89W\xdf\x90" +
-	"\x1f\x7f\xfb\xd2g$\x0d\x86\xa1\x1e\xa0\x83\xa8\x0f\xdf " +
-	"\xd9\xe7\x06Q,<?U\xf2\x8aN\xac\"\x11\xbbN" +
-	",\x1cQTa\xecx\xbe\xd0W\x8a\x19'\x12N," +
-	"b\xd7\x8b\xc4\xb4\x13\xbb\"\xf0\x8bPD\xb2\x93\x1bD" +
-	"\x06\x88rs\xc7\x88\xe4\xb3\x1c\xf2<\x03\xd0\x09\x8d\xbd" +
-	"p\x88H\x9e\xe3\x90\x17\x19\xbaX\x92\xa0\x13\x8c(w" +
-	"a\x90H\x9e\xe7\x90\x97\x19\xba\xf8m\x0ds\xa2\xdc%" +
-	"\x9d}\x91C\xce3t\x19\xb74l\x10\xe5\xael&" +
-	"\x92\x979\xe45\x86\xa4\xac\xd9\x8e\xf9\x01\xf1\x92B\x9a" +
-	"\x18\xd2\xd4\x00'\x9c\x98\xe0\xa2\x83\x18:\x08\xbb\x8aA" +
-	"\xb5\xea\xc5\xc8\xb6%' KHJ^\xa8\x8aq\x10" +
-	"\x12f\x91mk^\x8ff\xa6\xbc\x8aB\xb6\xed\x8b\xc6" +
-	"\xa1{H=\xec\xed\x0aG\xfc8\x9c]^\xed\xff\xd5" +
-	"\xd4\xce\xe1\xcbdHD\x9e_\xae(&\x9a4f\x85" +
-	"\xd2\x07\x09rEK\xcaM\xba\xe2\x0d\x1c\xb2\x97!\xd7" +
-	"\xd4\xf2\x01\x0dvs\xc8\xed\x0c\x19\xdf\xa9\xaaf\xa9\x19" +
-	"\xd7\x89\\\xac\"\x86U\xf7f\xba7\xc8h]\x96\xe7" +
-	")\x1a\xaeX\x8fdoM>\xe1\xf1H8\"R\xb1" +
-	"\x08\xa6D\xd1u\xfc\xb26H \xfc\xc0*\xa9\x88H" +
-	"\xaei\x91\xbe\xb2\xa7\xdd\xa6\x16\xe9\xab\xba\xd3\xafs\xc8" +
-	"\x05\x86\x1cc\xf5\xf6_\xd7\xe0<\x87|\x97!\xc7y" +
-	"\xbd\xf9o\xe9\xf2\xaeq\xc8\xf7\x19`\xd4;\xff\xde6" +
-	"\"\xb9\xc0!?d\x80\x89E\xc3\x94\xfb`\x1b\xb13" +
-	"U\x15EN\xb9%\xc4.\xe7t\xec\x06a\xeb\xe7\xb4" +
-	"\x13*?n*\x93\x09\x83\xa0\xf5#\xef\xf9%\xf54" +
-	"Lb0\x09\xf9\xaa\x0a\xcb\xea^\xd2=\xe2\xf1\x8aZ" +
-	"^\xb85\x8d\x06\x7f\x9a\x0c\x89\x8ar\xa6\x84\xcf\xf4\xd4" +
-	"x\xbe\x88]%\x0e\x0c\x0f\xed#\"\xd9\xd1\xd2jD" +
-	"\x17\xbb\x9bC\x8e\xb7geL\xab2\xcc!'\xb4T" +
-	"\x8dI9\xb0\x9eH\x8er\xc8I\x86L\xe4=\xd3\xf2" +
-	"|\xb3\xb8F\xad\xd6I5{\xbf\x168\xa8\x03\xcb\xd7" +
-	"\xb1\xa1a\x80\xfdH\x0e\xd6\x0a\x88\x84\xe1\xd47@\xa3" +
-	"\x96\xaa\x0aOV\x94(9e\xed\x88\x13\xa1W&\xc8" +
-	"\x9efa\xf6Fl&*\x08p\x14z\xd0\xf6\x81\xbd" +
-	"\x09\xfb\x89\x0a\xdd\x1a\xdf\x8e\xb6\x15\xec>\xec!*\xf4" +
-	"h|\x07\x18P7\x83\xfd \xb6\x11\x15z5\xbcS" +
-	"\xa7\x1b\xbcf\x08{\x00'\x88\x0a;4>\xacq\xd3" +
-	"\xe8\x84Id\x0f\xd5\x9e\xdd\xa9\xf1Q0t\xa5\x92\xc4" +
-	"\xecD\x8a\xc8\x1e\xc1 Qa\xb7\x8e\x8c\xeb\x88u[" +
-	"G,\"{\x0c\x87\x88\x0a\xa3:2\xa9#+n\xe9" +
-	"\xc8\x0a\"[\xd6n\x1b\xd7\x91#:\x92\xfeKG\xd2" +
-	"D\xf6\xe1\x1a\xaf\x09\x1d9\xae\xdf_\x99\xea\xc4J\"" +
-	"\xfbh\x8d\xd7\x11\x8d\x97\xb0d<\x938Tj\xd4\x89" +
-	"\\\"j\xb6\xe8L5(Mz\xed\x9c\xbc\xa75n" +
-	"\xed\xb3b\xe0\xc7\xca\x8fG\xc9Z4\xd9\x99\xd3\x91\x0a" +
-	"\xff\x9d\xf5\x96\xaf-Pd\xdb\x1f\xe8\xc6e'\x9c\xe2" +
-	"I\xe5\x97\xee$\xd2\xf2\x97\xf1O+FS\xdbRU" +
-	"!/+\xbd\xd5\xb2\xf5.-Yk\xf5\x06\xdd\xb9\xd6" +
-	"f\xbc\xd8m\xaf5\xe5\x94\xee\xf7\xcd\xe1\xe66\xa5\xe5" +
-	"\x8d\xdd\xdd0\xf6\x9bH\x9a\xa9\xe6\xac\xd0:;\x9e\x1f" +
-	"\x89\xc0W\"\x08E5\x08Uk1{*\xd2\xd8\x94" +
-	"gUj\x9b.\xdb\x9a^GS>\xce!\xdd\xf6\xf4" +
-	"*=\xbdOr\xc8\xca\xa2\xe9\xf5\xf6\x13I\x97C\x9e" +
-	"\xd3\x8b\x8e\xd5\x17\xdds\x1a<[\xff\xca\xddm\xa4\x93" +
-	"\xa2\xebUJ\xa1\xf2\xb5o\xfeC\x98\xe0@\xb6\xfd\x0f" +
-	"\x11A\x83M\xabDwK\xfa;\x00\x00\xff\xffL\xce" +
-	"@$"
+const schema_9195d073cb5c5953 = "x\xda\xb4Vm\x88\x1dW\x19~\x9fsfv\xee\xa6" +
+	"ws\xef\xf5\xdc@-\xdd\xdeCh \x0dm\x93t" +
+	"\x1b\xaa\x0b\xb2\xd9651\xd6\x92\xc9\x8d?Z\xe3\xc7" +
+	"\xec\x9d\xb3w\x86\xdc;\xb3\xce\xcc6Y\xb1D\xa5\xc1" +
+	"V\x8d\xb4X\x7f\x08Y\x0cR\xf4\x8f\xa0\x82\x85\x08\x16" +
+	"\xb5\xb4\x12\xb5B\x95\xaaE\x14\xfcH1\xf8E\x0b\x8d" +
+	"Di:\xf2\xce\xfd\xcc\xbaM\xf2\xc3\xfe\x9by\xce{" +
+	"f\x9e\xe7=\xcf\xfb\xbegG.w\x8b\x9d\xf6q\x8b" +
+	"\xc8\xbd\xd3\x9e\xc8\xff\xfa\x8eS\xe7\x7f\xb3\xf5\xec\xa7\xc8" +
+	"\xbd\x09\"o>p\xf8g\xe9\x8b_~\x82\xee\x15\x8e" +
+	"\x845\xb3El\x86\xda%\x1c\xb5K4f>.\x1a" +
+	" \xe4\xab\x8d\xf7\x1f}\xe8\x9f\x9b>O\xb5\x9b0\xda" +
+	"`\x0b\x87h\xe6\xa4\x9c\x85Z\x95\x8eZ\x95\x0d\xf5\x13" +
+	"y\x94\x90\x9f;\xf4\xa1\x8f\xfek_\xe7\x0b\xeb\x85o" +
+	"\xb1f\xa1vY\x8e\xdae5T\xd7\xe2\xf0o}\xf8" +
+	"P\xf4cu\xfa$\xf3\x19\x8f\xdf\xc0\xf1\xafY\xdb\xa0" +
+	"`;\x0avcf\xa7}\x97 \xe4\x1f\xdc\xf9\xd8]" +
+	"\xefy\xf77\xbe\xb8v\x83\xe4\x0d\xc6\xb9\x01j\xd9q" +
+	"\xd4\xb2\xd3P\xab\xce_\x08\xf9\x9f\xff\xb3\xb8t\xfco" +
+	"\xb7|\x9d\xe3\xe5\x98\xe0\x92c\xc1\x9aY.\xdd\x00u" +
+	"\xa2\xe4\xa8\x13\xa5\xc6\xcc\xf7J\x9f\x95\x84\xfc\xa9s\xf7" +
+	"\xfd\xb6\xf2\xd4\xc5\x1f\x90\xbb\x05c\x047mp@4" +
+	"\xf3p\xf9A\x10\xd4cef\x8fS\x9f\xe9\xecx\xe0" +
+	"\xbe?\xad%c1\x99W\xcawC](;\xeaB" +
+	"\xb91s\xdbT\x03\xf4\xae\xbc\xe5e\x8b\xe9\xf6(\x96" +
+	"\xbeI\xb7\xb7\xbc\xa5hi{\x14\xfb&\xbd\xbdx\x9e" +
+	"\xdd\x1b8q\x9a\x1d\x00\\\x0b\"\xff\xc8\x97\xbe\xea>" +
+	"\xf3\xeb\xcf=O\xae%0\x7f+P&\xda\x89_\"" +
+	"\xdf\x1b\xc4i\xa6\xc3h\xc2\x0f[^fR\x9d\x05^" +
+	"\xa6=\xdd2I\xe6\x85\x91\xe6O\xea\xa3^\xaa\xbdL" +
+	"gA\x98\xea%/\x0bt\x1c\xb5`\x88\xdc\xba\xb4\x88" +
+	",\x10\xd5\x1e~\x90\xc8\xfd\xa4\x84\xfb\xa8\x00P\x07c" +
+	"'\x0e\x12\xb9\x8fH\xb8\x8f\x0bL\x8b<G\x1d\x82\xa8" +
+	"vr\x96\xc8}T\xc2}R`Z\xbe\xc9\xb0$\xaa" +
+	"=\xc1\xd1\x8fK\xb8\xa7\x04\xa6\xadK\x0c[D\xb5\xaf" +
+	"l#r\x9f\x94pO\x0b\xe4mf\xfb\xbe(&\xe9" +
+	"\x1bL\x92\xc0$\xf5\xc1\x03^F\x08P&\x812a" +
+	"\xae\x15w\xbba\x86\xea(\xe7\x04T\x09\xb9\x1f&\xa6" +
+	"\x95\xc5\x09a\x05\xd5Q\xd2{\xab\x95\xc5\xb0cP\x1d" +
+	"\x19\xa3\xbf\xe9*\xa9\xde\x13\xce%\xf7FY\xb2\xb2~" +
+	"\xb6o,\xb2]\xc3O\xf3y\x9d\x86Q\xbbc\x84\x1e" +
+	"\xd0X\xd1\x867\x12\xdc\xd20\x95\xb7\xb0\xe2\x9b%\xdc" +
+	"\x1d\x02\xb5A.ocp\xab\x84{\xa7@%\xf2\xba" +
+	"f \xb5\x12xi\x80)\x12\x98\xba6\xa6\xcd\xc0K" +
+	"\xfc\xf5\x99n\xed\xfb\xe2Y\xe4{\xc2^\xe0\x84\x0e\xe2" +
+	"\x8e\x9fjO/yI\xa6\xe3E\x9d\x05\xa6 \x1d\x9a" +
+	"\x94_=\xbd\x10\xb6Gz\x88h\\\xca\xfe>\xeb=" +
+	"cR\xe6\x19\xdc-\xe1\x1e\x16\xc8[A\xd8\xf1\x13\x13" +
+	"\x11\x116\x12\x0eH\xa0:\xea\x13\x04\x06\xf3V\x1ce" +
+	"&\xca\xd2+\x07]Y\xfa=q\x85-\xb1\xbep\xdd" +
+	"\x17\xbe\x19\xf9=\x85st(Ysj\x0a\xc9\xad\xc0" +
+	"\x8b\xda\\\x1b\xb1\x8eb\xc77i\xa1\xaa/RM\xe2" +
+	"n\xa2\xa6\x05\x89f\x15#\x9dj\x0a\xb3D\xcd\x12\xe3" +
+	"u\xc6\x85(\xfc\xafj\x05^f\xfcz\xc6\xa5,\x0a" +
+	"@m\xc26\xa2f\x95\xf1\x1b!\x00\xab(\x00\xf5N" +
+	"\xdcA\xd4\xac3\xac\x19\xb61\xd6W\xd44\xee Q" +
+	"\x9b\x98\xa8\xc3!R6\x0e^F\xc5q\xea(\xadC" +
+	"\xa5T\xaacr\x1d*\x93\x93ul(\xa8\xcc\x0e\xa8" +
+	"\xdc\x0a\x81\xe3]\x93\xa6^{\xe8\xb99o9\x0b\xe2" +
+	"d\xf8\xba\xe4%&\xca\x06&\xac$q<|i\x84" +
+	"\x91o\x8e\xc1&\x01\x9b\xd0\xe8\x9a\xa4m\xf24lG" +
+	"^\xb6\x9c\x10\xcc n\xce7\x0f\x85\xad\xd1\x1f\xe2$" +
+	"l\x87\xd1\xf0\xb5\xe3-\x98N:8|F\xaf\xe1\xc8" +
+	"\xdf\x1b\xca\x8eY\xff\xc0\xaf\xef\xd7\xe4\xb3\xf9\xbc\xee\x18" +
+	"oQG\x82\x1b]\x18\x15\xf6\xfe\xc0\x9e\xf9\xbdD\xe4" +
+	"V\x87F\xf6\xb8\xfc\x0eK\xb8\xc1\xa8\xbd\x19\xeec\x1f" +
+	"\x93p;|\xb8\xfd\xe6\x16n&r}\x09w\x89O" +
+	"V\xf4Z[\x97\x1d\xdf\x91p\x8f\x09T\xd2\xf0\x13\xc3" +
+	"\xde5\xc8\\_\xa6s\xc4\xac\x0cK\xb9\x1bv\xcd\xa1" +
+	"\x95%CD\x83\xf5\xab\x09\xbe\x9f\x17\xd6\x17|s\xdf" +
+	"\xe1\xfb\x91\xdf_(M\xb5\xe5\xe9hLt\xd7$G" +
+	":F\xfb^\x9b-\xbf\x90\x84m\x82\xbb{\xe8\xf2\xef" +
+	"\x14\xee\xfc&[\xe2\xcc\xb8\xcb\x9f\xc6~\xa2\xe6w\x19" +
+	"\xff\xe1\xb8\xcb\x9f)\xaa\xe2\x0c\xe3\xcfA\x00}\x93\xff" +
+	"\xa8p\xf3\xf7\x19>\xcb\xe1\x96\xec\xb9\xfcy,\x105" +
+	"\x9fc\xfcE\xc6m\xab\x0e\x9bH\xfd\xbc\xf8\xedY\xc6" +
+	"_\x82\xc0\xf4D\x9e\xdbuL\x10\xa9_\x14\x1e}\x81" +
+	"W^\xe6\x15\xe7\xcd\xdc\xeeU\xc1\xaf\x8a*x\x89W" +
+	"\xfe\xc0+\xa5K\xb9\xdd\xab\x83\xdf\x17_{\x99W\xce" +
+	"\xf1\xca\xe4\x1b\xb9\xdd\xab\x84?\x16\xbc~\xc7+\xe7\xf9" +
+	"\xff\x1b&z\x95\xf0J\xc1\xeb\x1c\xe3\xaf2~\x9d\xac" +
+	"\xe3:\"\xf5\x8f\xe2K\xe7\x19\x7f\x9d\xf1\xb2U\xe7\x04" +
+	"\xab\xd7\xb0\x99\xa8\xf9w\xc6/2>e\xd71E\xa4" +
+	".\x14\xf8\xab\x8c\xbf\xc1\xf8\xc6\x03ul$R\xff." +
+	"\xd2\xf4:$\x0e\x0a\x81Z\xc5\xa9\xa3B\xa4.\x15\xe2" +
+	".\x16\x05+\xd6t\xfc<K\x8c\xd9\xe7\xa5\x01\x11\x0d" +
+	"\xdcr\xbc\x1b\xfb\x87\xc2QL#\xe4\xa3\x1d\x8e\xc8~" +
+	"\x03\xddG\xce\xd8\xb0\xa8,\xa7&y{&f\xa3\x98" +
+	"\xc9\xa8\x8e\xee\x88\xfd\x8f-x\xad#&\xf2\xd7\x10\xe9" +
+	"2\xd7\x12\x09\x94\x08\xcer\xe8\x0f\x9f\xdb\xa3gVh" +
+	"\x9a&\x03H\x00Wm\x09\xd6[M\x01Vy{\xd7" +
+	"$\xb2mxPU{>[3t{\x16\xbb|\xe8" +
+	"\x1e\x0d\xb3`4t\x8d\xe7\xff\xcf\xd0\xb5\xdej\xe8\x0e" +
+	"f\xe3\x95\xa7\xee\xd7\x90\x0fB\xed\x15\xcdG\xe6\x85Q" +
+	"\xaa\xe3\xc8\xe88\xd1\xdd81\xc31[\xcc\xdeD/" +
+	"\x86N\xc7\xa4\x97\xdf\xc3\x98\xf21\x09\xf7\x91Q\xa3\xfa" +
+	"\xf4\xec\xe8n6lT'\xf6\xf7/g\xa7\xc7\x1a\xd5" +
+	"*\x83\xa7$\xdc3\xa3\xd2\xac=\xcd\xdb\xbf-\xe1\xbe" +
+	"p\xe5\xee\xf5\xff\x9b\xe5s)_=\x86g;\xd5;" +
+	"\xdb\xff\x0e\x00\x0e\x97\xdfJ"
 
 func init() {
 	schemas.Register(schema_9195d073cb5c5953,
 		0x80c828d7e89c12ea,
 		0x8b15ee76774b1f9d,
+		0x8c6c48f65f5b54e4,
 		0x8da013c66e545daf,
 		0x8ea7393d37893155,
 		0xa629eb7f7066fae3,
@@ -44,6 +44,43 @@ type Commit struct {
 		// the remote side.
 		head h.Hash
 	}
+
+	// signature of the tree hash, made by the author (might be nil)
+	signature []byte
+
+	// meta tells where the commit came from (not part of the hash)
+	meta CommitMeta
+}
+
+// Subsystems that can create a commit.
+const (
+	CommitOriginCLI     = "cli"
+	CommitOriginFuse    = "fuse"
+	CommitOriginGateway = "gateway"
+	CommitOriginSync    = "sync"
+	CommitOriginAuto    = "auto"
+)
+
+// CommitMeta is structured information about a commit.
+// It is not part of the commit hash.
+type CommitMeta struct {
+	// Device is the name of the device that created the commit.
+	Device string
+	// Origin is the subsystem that created it (see CommitOrigin*)
+	Origin string
+	// Labels are free-form user defined labels.
+	Labels []string
+}
+
+// HasLabel returns true if `label` is one of the labels.
+func (cm CommitMeta) HasLabel(label string) bool {
+	for _, l := range cm.Labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
 }
 
 // NewEmptyCommit creates a new commit after the commit referenced by `parent`.
@@ -121,6 +158,29 @@ func (c *Commit) setCommitAttrs(seg *capnp.Segment) (*capnp_model.Commit, error)
 		return nil, err
 	}
 
+	if err := capCmt.SetSignature(c.signature); err != nil {
+		return nil, err
+	}
+
+	if err := capCmt.SetDevice(c.meta.Device); err != nil {
+		return nil, err
+	}
+
+	if err := capCmt.SetOrigin(c.meta.Origin); err != nil {
+		return nil, err
+	}
+
+	capLabels, err := capCmt.NewLabels(int32(len(c.meta.Labels)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, label := range c.meta.Labels {
+		if err := capLabels.Set(idx, label); err != nil {
+			return nil, err
+		}
+	}
+
 	return &capCmt, nil
 }
 
@@ -182,7 +242,41 @@ func (c *Commit) readCommitAttrs(capCmt capnp_model.Commit) error {
 	}
 
 	c.merge.with, err = capMerge.With()
-	return err
+	if err != nil {
+		return err
+	}
+
+	c.signature, err = capCmt.Signature()
+	if err != nil {
+		return err
+	}
+
+	c.meta.Device, err = capCmt.Device()
+	if err != nil {
+		return err
+	}
+
+	c.meta.Origin, err = capCmt.Origin()
+	if err != nil {
+		return err
+	}
+
+	capLabels, err := capCmt.Labels()
+	if err != nil {
+		return err
+	}
+
+	c.meta.Labels = nil
+	for idx := 0; idx < capLabels.Len(); idx++ {
+		label, err := capLabels.At(idx)
+		if err != nil {
+			return err
+		}
+
+		c.meta.Labels = append(c.meta.Labels, label)
+	}
+
+	return nil
 }
 
 // IsBoxed will return True if the ommit was already boxed
@@ -257,6 +351,28 @@ func (c *Commit) SetMergeMarker(with string, remoteHead h.Hash) {
 	c.merge.head = remoteHead.Clone()
 }
 
+// SetSignature sets the signature of the commit's hash.
+// The signature is not part of the hash itself.
+func (c *Commit) SetSignature(sig []byte) {
+	c.signature = sig
+}
+
+// Signature returns the signature of the commit's hash or nil if unsigned.
+func (c *Commit) Signature() []byte {
+	return c.signature
+}
+
+// SetMeta sets the metadata of the commit.
+// The metadata is not part of the hash itself.
+func (c *Commit) SetMeta(meta CommitMeta) {
+	c.meta = meta
+}
+
+// Meta returns the metadata of the commit.
+func (c *Commit) Meta() CommitMeta {
+	return c.meta
+}
+
 // MergeMarker returns the merge info for this commit, if any.
 func (c *Commit) MergeMarker() (string, h.Hash) {
 	return c.merge.with, c.merge.head
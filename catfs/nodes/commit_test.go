@@ -76,3 +76,38 @@ func TestCommit(t *testing.T) {
 	empty.modTime = cmt.modTime
 	require.Equal(t, empty, cmt)
 }
+
+func TestCommitMeta(t *testing.T) {
+	boxed := func(meta CommitMeta) *Commit {
+		cmt, err := NewEmptyCommit(0, 1)
+		require.Nil(t, err)
+
+		cmt.root = h.EmptyBackendHash
+		cmt.SetMeta(meta)
+		require.Nil(t, cmt.BoxCommit(AuthorOfStage, "Hello"))
+		return cmt
+	}
+
+	meta := CommitMeta{
+		Device: "laptop",
+		Origin: CommitOriginGateway,
+		Labels: []string{"backup", "weekly"},
+	}
+
+	cmt := boxed(meta)
+
+	// The metadata should not change the hash:
+	require.Equal(t, boxed(CommitMeta{}).TreeHash(), cmt.TreeHash())
+
+	data, err := MarshalNode(cmt)
+	require.Nil(t, err)
+
+	loaded, err := UnmarshalNode(data)
+	require.Nil(t, err)
+
+	loadedCmt, ok := loaded.(*Commit)
+	require.True(t, ok)
+	require.Equal(t, meta, loadedCmt.Meta())
+	require.True(t, loadedCmt.Meta().HasLabel("weekly"))
+	require.False(t, loadedCmt.Meta().HasLabel("daily"))
+}
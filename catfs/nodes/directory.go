@@ -24,6 +24,11 @@ type Directory struct {
 	children   map[string]h.Hash
 	contents   map[string]h.Hash
 	order      []string
+
+	// shards are the hashes of the shards this directory was
+	// last stored in or loaded from. Empty if it is not sharded.
+	shards      []h.Hash
+	dirtyShards map[int]bool
 }
 
 // NewEmptyDirectory creates a new empty directory that does not exist yet.
@@ -86,7 +91,7 @@ func (d *Directory) ToCapnpNode(seg *capnp.Segment, capNd capnp_model.Node) erro
 		return err
 	}
 
-	capDir, err := d.setDirectoryAttrs(seg)
+	capDir, err := d.setDirectoryAttrs(seg, nil)
 	if err != nil {
 		return err
 	}
@@ -94,66 +99,97 @@ func (d *Directory) ToCapnpNode(seg *capnp.Segment, capNd capnp_model.Node) erro
 	return capNd.SetDirectory(*capDir)
 }
 
-func (d *Directory) setDirectoryAttrs(seg *capnp.Segment) (*capnp_model.Directory, error) {
-	capDir, err := capnp_model.NewDirectory(seg)
+func newDirEntryList(seg *capnp.Segment, entries map[string]h.Hash) (capnp_model.DirEntry_List, error) {
+	list, err := capnp_model.NewDirEntry_List(seg, int32(len(entries)))
 	if err != nil {
-		return nil, err
-	}
-
-	children, err := capnp_model.NewDirEntry_List(seg, int32(len(d.children)))
-	if err != nil {
-		return nil, err
+		return list, err
 	}
 
 	entryIdx := 0
-	for name, hash := range d.children {
+	for name, hash := range entries {
 		entry, err := capnp_model.NewDirEntry(seg)
 		if err != nil {
-			return nil, err
+			return list, err
 		}
 
 		if err := entry.SetName(name); err != nil {
-			return nil, err
+			return list, err
 		}
 		if err := entry.SetHash(hash); err != nil {
-			return nil, err
+			return list, err
 		}
-		if err := children.Set(entryIdx, entry); err != nil {
-			return nil, err
+		if err := list.Set(entryIdx, entry); err != nil {
+			return list, err
 		}
+
 		entryIdx++
 	}
 
-	if err := capDir.SetChildren(children); err != nil {
-		return nil, err
+	return list, nil
+}
+
+func readDirEntryList(list capnp_model.DirEntry_List, fn func(name string, hash h.Hash)) error {
+	for i := 0; i < list.Len(); i++ {
+		entry := list.At(i)
+		name, err := entry.Name()
+		if err != nil {
+			return err
+		}
+
+		hash, err := entry.Hash()
+		if err != nil {
+			return err
+		}
+
+		fn(name, hash)
 	}
 
-	contents, err := capnp_model.NewDirEntry_List(seg, int32(len(d.contents)))
+	return nil
+}
+
+// setDirectoryAttrs fills a capnp directory. If `shards` is not nil,
+// they are stored instead of the entries.
+func (d *Directory) setDirectoryAttrs(seg *capnp.Segment, shards []h.Hash) (*capnp_model.Directory, error) {
+	capDir, err := capnp_model.NewDirectory(seg)
 	if err != nil {
 		return nil, err
 	}
 
-	entryIdx = 0
-	for name, hash := range d.contents {
-		entry, err := capnp_model.NewDirEntry(seg)
+	children, contents := d.children, d.contents
+	if shards != nil {
+		children, contents = nil, nil
+
+		capShards, err := capnp.NewDataList(seg, int32(len(shards)))
 		if err != nil {
 			return nil, err
 		}
 
-		if err := entry.SetName(name); err != nil {
-			return nil, err
-		}
-		if err := entry.SetHash(hash); err != nil {
-			return nil, err
+		for idx, shard := range shards {
+			if err := capShards.Set(idx, shard); err != nil {
+				return nil, err
+			}
 		}
-		if err := contents.Set(entryIdx, entry); err != nil {
+
+		if err := capDir.SetShards(capShards); err != nil {
 			return nil, err
 		}
+	}
 
-		entryIdx++
+	childList, err := newDirEntryList(seg, children)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := capDir.SetContents(contents); err != nil {
+	if err := capDir.SetChildren(childList); err != nil {
+		return nil, err
+	}
+
+	contentList, err := newDirEntryList(seg, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capDir.SetContents(contentList); err != nil {
 		return nil, err
 	}
 
@@ -198,50 +234,28 @@ func (d *Directory) readDirectoryAttr(capDir capnp_model.Directory) error {
 		return err
 	}
 
-	childList, err := capDir.Children()
-	if err != nil {
-		return err
-	}
-
 	d.children = make(map[string]h.Hash)
-	for i := 0; i < childList.Len(); i++ {
-		entry := childList.At(i)
-		name, err := entry.Name()
-		if err != nil {
-			return err
-		}
-
-		hash, err := entry.Hash()
-		if err != nil {
-			return err
-		}
-
-		d.children[name] = hash
-		d.order = append(d.order, name)
+	d.contents = make(map[string]h.Hash)
+	if err := d.readEntries(capDir.Children, capDir.Contents); err != nil {
+		return err
 	}
 
-	contentList, err := capDir.Contents()
+	// Entries of sharded directories are filled in by LoadShards():
+	d.shards = nil
+	capShards, err := capDir.Shards()
 	if err != nil {
 		return err
 	}
 
-	d.contents = make(map[string]h.Hash)
-	for i := 0; i < contentList.Len(); i++ {
-		entry := contentList.At(i)
-		name, err := entry.Name()
+	for i := 0; i < capShards.Len(); i++ {
+		shard, err := capShards.At(i)
 		if err != nil {
 			return err
 		}
 
-		hash, err := entry.Hash()
-		if err != nil {
-			return err
-		}
-
-		d.contents[name] = hash
+		d.shards = append(d.shards, h.Hash(shard))
 	}
 
-	sort.Strings(d.order)
 	d.nodeType = NodeTypeDirectory
 	return nil
 }
@@ -328,6 +342,14 @@ func (d *Directory) VisitChildren(lkr Linker, fn func(nd Node) error) error {
 	return nil
 }
 
+// ChildNames returns the names of all direct children in lexical order.
+// Unlike ChildrenSorted, no child node has to be loaded for this.
+func (d *Directory) ChildNames() []string {
+	names := make([]string, len(d.order))
+	copy(names, d.order)
+	return names
+}
+
 // ChildrenSorted returns a list of children node objects, sorted lexically by
 // their path. Use this whenever you want to have a defined order of nodes,
 // but do not really care what order.
@@ -534,18 +556,30 @@ func (d *Directory) Copy(inode uint64) ModNode {
 	order := make([]string, len(d.order))
 	copy(order, d.order)
 
+	shards := []h.Hash{}
+	for _, shard := range d.shards {
+		shards = append(shards, shard.Clone())
+	}
+
+	dirtyShards := make(map[int]bool)
+	for idx := range d.dirtyShards {
+		dirtyShards[idx] = true
+	}
+
 	return &Directory{
-		Base:       d.Base.copyBase(inode),
-		size:       d.size,
-		parentName: d.parentName,
-		children:   children,
-		contents:   contents,
-		order:      order,
+		Base:        d.Base.copyBase(inode),
+		size:        d.size,
+		parentName:  d.parentName,
+		children:    children,
+		contents:    contents,
+		order:       order,
+		shards:      shards,
+		dirtyShards: dirtyShards,
 	}
 }
 
 func (d *Directory) rehash(lkr Linker, updateContentHash bool) error {
-	newTreeHash := h.Sum([]byte(path.Join(d.parentName, d.name)))
+	newTreeHash := h.Sum([]byte(path.Join(d.parentName, d.name) + d.permHashSuffix()))
 	newContentHash := h.EmptyInternalHash.Clone()
 	for _, name := range d.order {
 		newTreeHash = newTreeHash.Mix(d.children[name])
@@ -587,6 +621,8 @@ func (d *Directory) Add(lkr Linker, nd Node) error {
 		d.contents[nd.Name()] = nodeContent
 	}
 
+	d.markShardDirty(nd.Name())
+
 	nameIdx := sort.SearchStrings(d.order, nd.Name())
 	suffix := append([]string{nd.Name()}, d.order[nameIdx:]...)
 	d.order = append(d.order[:nameIdx], suffix...)
@@ -607,6 +643,8 @@ func (d *Directory) Add(lkr Linker, nd Node) error {
 			if nd.Type() != NodeTypeGhost {
 				parent.contents[lastNd.Name()] = lastNd.ContentHash()
 			}
+
+			parent.markShardDirty(lastNd.Name())
 		}
 
 		if err := parent.rehash(lkr, true); err != nil {
@@ -625,6 +663,11 @@ func (d *Directory) Add(lkr Linker, nd Node) error {
 	return nd.SetParent(lkr, d)
 }
 
+// NotifyPermChange updates the tree hash after a mode, owner or label change.
+func (d *Directory) NotifyPermChange(lkr Linker) error {
+	return d.rehash(lkr, false)
+}
+
 // RemoveChild removes the child named `name` from it's children.
 // There is no way to remove the root node.
 func (d *Directory) RemoveChild(lkr Linker, nd Node) error {
@@ -642,6 +685,7 @@ func (d *Directory) RemoveChild(lkr Linker, nd Node) error {
 	// This assumes that it definitely was part of orders before.
 	delete(d.children, name)
 	delete(d.contents, name)
+	d.markShardDirty(name)
 
 	nameIdx := sort.SearchStrings(d.order, name)
 	d.order = append(d.order[:nameIdx], d.order[nameIdx+1:]...)
@@ -659,6 +703,8 @@ func (d *Directory) RemoveChild(lkr Linker, nd Node) error {
 			if nd.Type() != NodeTypeGhost {
 				parent.contents[lastNd.Name()] = lastNd.ContentHash()
 			}
+
+			parent.markShardDirty(lastNd.Name())
 		}
 
 		if err := parent.rehash(lkr, true); err != nil {
@@ -678,15 +724,21 @@ func (d *Directory) rebuildOrderCache() {
 	sort.Strings(d.order)
 }
 
-// NotifyMove should be called whenever a node is being moved.
-func (d *Directory) NotifyMove(lkr Linker, newParent *Directory, newPath string) error {
-	visited := map[string]Node{}
-	oldRootPath := d.Path()
+// moveChildren tells every child of `dir` that it is now below `newDirPath`.
+// The new paths are passed down instead of being derived from the old ones,
+// since the moved directory was already unlinked from its old parent.
+func moveChildren(lkr Linker, dir *Directory, newDirPath string) error {
+	for _, name := range dir.order {
+		child, err := lkr.NodeByHash(dir.children[name])
+		if err != nil {
+			return err
+		}
 
-	err := Walk(lkr, d, true, func(child Node) error {
-		oldChildPath := child.Path()
-		newChildPath := path.Join(newPath, oldChildPath[len(oldRootPath):])
-		visited[newChildPath] = child
+		if child == nil {
+			return fmt.Errorf("move: could not resolve %s (%s)", name, dir.children[name].B58String())
+		}
+
+		newChildPath := path.Join(newDirPath, name)
 
 		switch child.Type() {
 		case NodeTypeDirectory:
@@ -695,19 +747,15 @@ func (d *Directory) NotifyMove(lkr Linker, newParent *Directory, newPath string)
 				return ie.ErrBadNode
 			}
 
-			for name := range childDir.children {
-				movedChildPath := path.Join(newChildPath, name)
-				childDir.children[name] = visited[movedChildPath].TreeHash()
+			if err := moveChildren(lkr, childDir, newChildPath); err != nil {
+				return err
 			}
 
+			// The tree hash includes the path, so set it before rehashing:
+			childDir.parentName = newDirPath
 			if err := childDir.rehash(lkr, false); err != nil {
 				return err
 			}
-
-			dirname, basename := path.Split(newChildPath)
-			childDir.parentName = dirname
-			childDir.SetName(basename)
-			return nil
 		case NodeTypeFile:
 			childFile, ok := child.(*File)
 			if !ok {
@@ -728,20 +776,24 @@ func (d *Directory) NotifyMove(lkr Linker, newParent *Directory, newPath string)
 			return fmt.Errorf("bad node type in NotifyMove(): %d", child.Type())
 		}
 
-		return nil
-	})
+		dir.children[name] = child.TreeHash()
+		dir.markShardDirty(name)
+	}
 
-	if err != nil {
+	return nil
+}
+
+// NotifyMove should be called whenever a node is being moved.
+func (d *Directory) NotifyMove(lkr Linker, newParent *Directory, newPath string) error {
+	if err := moveChildren(lkr, d, newPath); err != nil {
 		return err
 	}
 
-	// Fixup the links from the parents to the children:
-	for nodePath, node := range visited {
-		if parent, ok := visited[path.Dir(nodePath)]; ok {
-			parentDir := parent.(*Directory)
-			parentDir.children[path.Base(nodePath)] = node.TreeHash()
-			parentDir.rebuildOrderCache()
-		}
+	dirname, basename := path.Split(newPath)
+	d.parentName = dirname
+	d.SetName(basename)
+	if err := d.rehash(lkr, false); err != nil {
+		return err
 	}
 
 	if err := newParent.Add(lkr, d); err != nil {
@@ -15,9 +15,10 @@ import (
 type File struct {
 	Base
 
-	size   uint64
-	parent string
-	key    []byte
+	size     uint64
+	parent   string
+	key      []byte
+	mimeType string
 }
 
 // NewEmptyFile returns a newly created file under `parent`, named `name`.
@@ -77,6 +78,10 @@ func (f *File) setFileAttrs(seg *capnp.Segment) (*capnp_model.File, error) {
 		return nil, err
 	}
 
+	if err := capFile.SetMimeType(f.mimeType); err != nil {
+		return nil, err
+	}
+
 	capFile.SetSize(f.size)
 	return &capFile, nil
 }
@@ -115,6 +120,11 @@ func (f *File) readFileAttrs(capFile capnp_model.File) error {
 
 	f.nodeType = NodeTypeFile
 	f.size = capFile.Size()
+	f.mimeType, err = capFile.MimeType()
+	if err != nil {
+		return err
+	}
+
 	f.key, err = capFile.Key()
 	return err
 }
@@ -124,6 +134,10 @@ func (f *File) readFileAttrs(capFile capnp_model.File) error {
 // Size returns the number of bytes in the file's content.
 func (f *File) Size() uint64 { return f.size }
 
+// MimeType returns the type of the content as detected when it was staged.
+// It is empty for files staged before types were detected.
+func (f *File) MimeType() string { return f.mimeType }
+
 ////////////////// ATTRIBUTE SETTERS //////////////////
 
 // SetModTime udates the mod time of the file (i.e. "touch"es it)
@@ -137,6 +151,9 @@ func (f *File) SetName(n string) { f.name = n }
 // SetKey updates the key to a new value, taking ownership of the value.
 func (f *File) SetKey(k []byte) { f.key = k }
 
+// SetMimeType sets the type of the content.
+func (f *File) SetMimeType(m string) { f.mimeType = m }
+
 // SetSize will update the size of the file and update it's mod time.
 func (f *File) SetSize(s uint64) {
 	f.size = s
@@ -156,10 +173,11 @@ func (f *File) Copy(inode uint64) ModNode {
 	}
 
 	return &File{
-		Base:   f.Base.copyBase(inode),
-		size:   f.size,
-		parent: f.parent,
-		key:    copyKey,
+		Base:     f.Base.copyBase(inode),
+		size:     f.size,
+		parent:   f.parent,
+		key:      copyKey,
+		mimeType: f.mimeType,
 	}
 }
 
@@ -172,7 +190,7 @@ func (f *File) rehash(lkr Linker, newPath string) {
 		contentHash = h.EmptyInternalHash.Clone()
 	}
 
-	f.tree = h.Sum([]byte(fmt.Sprintf("%s|%s", newPath, contentHash)))
+	f.tree = h.Sum([]byte(fmt.Sprintf("%s|%s%s", newPath, contentHash, f.permHashSuffix())))
 	lkr.MemIndexSwap(f, oldHash, true)
 }
 
@@ -194,6 +212,12 @@ func (f *File) NotifyMove(lkr Linker, newParent *Directory, newPath string) erro
 	return nil
 }
 
+// NotifyPermChange updates the tree hash after a mode, owner or label change.
+func (f *File) NotifyPermChange(lkr Linker) error {
+	f.rehash(lkr, f.Path())
+	return nil
+}
+
 // SetContent will update the hash of the file (and also the mod time)
 func (f *File) SetContent(lkr Linker, content h.Hash) {
 	f.Base.content = content
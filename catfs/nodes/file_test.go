@@ -2,6 +2,7 @@ package nodes
 
 import (
 	"bytes"
+	"os"
 	"testing"
 	"time"
 
@@ -30,6 +31,8 @@ func TestFile(t *testing.T) {
 	file.SetSize(42)
 	file.SetContent(lkr, []byte{4, 5, 6})
 	file.SetBackend(lkr, []byte{7, 8, 9})
+	file.SetMode(os.ModeSymlink | 0640)
+	file.SetOwner(1000, 100)
 	hashBeforeUnmarshal := file.TreeHash().Clone()
 
 	now := time.Now()
@@ -79,6 +82,14 @@ func TestFile(t *testing.T) {
 		t.Fatalf("backend hash differs after unmarshal: %v", empty.BackendHash())
 	}
 
+	if empty.Mode() != os.ModeSymlink|0640 || !empty.IsSymlink() {
+		t.Fatalf("mode differs after unmarshal: %v", empty.Mode())
+	}
+
+	if uid, gid := empty.Owner(); uid != 1000 || gid != 100 {
+		t.Fatalf("owner differs after unmarshal: %d:%d", uid, gid)
+	}
+
 	if !bytes.Equal(empty.ContentHash(), []byte{4, 5, 6}) {
 		t.Fatalf("content hash differs after unmarshal: %v", empty.ContentHash())
 	}
@@ -144,7 +144,7 @@ func (g *Ghost) ToCapnpNode(seg *capnp.Segment, capNd capnp_model.Node) error {
 			return ie.ErrBadNode
 		}
 
-		capdir, err := dir.setDirectoryAttrs(seg)
+		capdir, err := dir.setDirectoryAttrs(seg, nil)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package nodes
 
 import (
+	"os"
 	"time"
 
 	capnp_model "github.com/sahib/brig/catfs/nodes/capnp"
@@ -79,6 +80,20 @@ type Metadatable interface {
 	// can be read from the backend.
 	// It is valid to return nil if the file is empty.
 	BackendHash() h.Hash
+
+	// Mode returns the POSIX permission bits of the node.
+	// It is only meaningful if HasMode() returns true.
+	Mode() os.FileMode
+
+	// HasMode returns true if the mode was set explicitly.
+	// Otherwise the defaults should be used.
+	HasMode() bool
+
+	// Owner returns the numeric uid and gid of the node.
+	Owner() (uint32, uint32)
+
+	// Labels returns the user-defined labels of the node.
+	Labels() []string
 }
 
 // Serializable is a thing that can be converted to a capnproto message.
@@ -139,11 +154,25 @@ type ModNode interface {
 	// SetUser sets the user that last modified the file
 	SetUser(user string)
 
+	// SetMode sets the POSIX permission bits of the node.
+	SetMode(mode os.FileMode)
+
+	// SetOwner sets the numeric uid and gid of the node.
+	SetOwner(uid, gid uint32)
+
+	// SetLabels replaces the user-defined labels of the node.
+	SetLabels(labels []string)
+
 	// NotifyMove tells the node that it was moved.
 	// It should be called whenever the path of the node changed.
 	// (i.e. not only the name, but parts of the parent path)
 	NotifyMove(lkr Linker, parent *Directory, newPath string) error
 
+	// NotifyPermChange tells the node that its mode, owner or labels changed.
+	// All are part of the tree hash, so it needs to be updated.
+	// The parent directory has to be updated by the caller.
+	NotifyPermChange(lkr Linker) error
+
 	// Copy creates a copy of this node with the inode `inode`.
 	Copy(inode uint64) ModNode
 }
@@ -0,0 +1,267 @@
+package nodes
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	capnp_model "github.com/sahib/brig/catfs/nodes/capnp"
+	h "github.com/sahib/brig/util/hashlib"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+const (
+	// DefaultShardThreshold is the number of entries above which
+	// a directory is stored in several shards.
+	DefaultShardThreshold = 10000
+
+	// shardEntries is the number of entries we aim for in a single shard.
+	shardEntries = 1024
+)
+
+// shardCount returns the number of shards for `nEntries`.
+// It is always a power of two, so growing a directory
+// splits each shard into two instead of shuffling everything.
+func shardCount(nEntries int) int {
+	count := 2
+	for count*shardEntries < nEntries {
+		count *= 2
+	}
+
+	return count
+}
+
+// shardIndex returns the shard an entry called `name` is stored in.
+func shardIndex(name string, count int) int {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(name))
+	return int(hasher.Sum32() & uint32(count-1))
+}
+
+// markShardDirty remembers that the shard of `name` needs to be written again.
+func (d *Directory) markShardDirty(name string) {
+	if len(d.shards) == 0 {
+		return
+	}
+
+	if d.dirtyShards == nil {
+		d.dirtyShards = make(map[int]bool)
+	}
+
+	d.dirtyShards[shardIndex(name, len(d.shards))] = true
+}
+
+// readEntries adds the entries of capnp lists to the directory.
+func (d *Directory) readEntries(
+	childrenFn, contentsFn func() (capnp_model.DirEntry_List, error),
+) error {
+	childList, err := childrenFn()
+	if err != nil {
+		return err
+	}
+
+	err = readDirEntryList(childList, func(name string, hash h.Hash) {
+		d.children[name] = hash
+	})
+
+	if err != nil {
+		return err
+	}
+
+	contentList, err := contentsFn()
+	if err != nil {
+		return err
+	}
+
+	err = readDirEntryList(contentList, func(name string, hash h.Hash) {
+		d.contents[name] = hash
+	})
+
+	if err != nil {
+		return err
+	}
+
+	d.rebuildOrderCache()
+	return nil
+}
+
+// ShardHashes returns the hashes of the shards `nd` was stored in.
+// It returns nil for all nodes except big directories.
+func ShardHashes(nd Node) []h.Hash {
+	dir, ok := nd.(*Directory)
+	if !ok {
+		return nil
+	}
+
+	return dir.shards
+}
+
+// buildShards serializes all shards that changed since the last call.
+// The returned map is keyed by the b58 hash of the shard.
+func (d *Directory) buildShards(count int) (map[string][]byte, error) {
+	if len(d.shards) != count {
+		// The number of shards changed, so every entry might move:
+		d.shards = make([]h.Hash, count)
+		d.dirtyShards = make(map[int]bool)
+		for idx := 0; idx < count; idx++ {
+			d.dirtyShards[idx] = true
+		}
+	}
+
+	type bucket struct {
+		children map[string]h.Hash
+		contents map[string]h.Hash
+	}
+
+	buckets := make(map[int]*bucket)
+	for idx := range d.dirtyShards {
+		buckets[idx] = &bucket{
+			children: make(map[string]h.Hash),
+			contents: make(map[string]h.Hash),
+		}
+	}
+
+	for name, hash := range d.children {
+		if bkt, ok := buckets[shardIndex(name, count)]; ok {
+			bkt.children[name] = hash
+			if content, ok := d.contents[name]; ok {
+				bkt.contents[name] = content
+			}
+		}
+	}
+
+	written := make(map[string][]byte)
+	for idx, bkt := range buckets {
+		data, err := marshalShard(bkt.children, bkt.contents)
+		if err != nil {
+			return nil, err
+		}
+
+		hash := h.Sum(data)
+		d.shards[idx] = hash
+		written[hash.B58String()] = data
+	}
+
+	d.dirtyShards = make(map[int]bool)
+	return written, nil
+}
+
+func marshalShard(children, contents map[string]h.Hash) ([]byte, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	capShard, err := capnp_model.NewRootDirShard(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	childList, err := newDirEntryList(seg, children)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capShard.SetChildren(childList); err != nil {
+		return nil, err
+	}
+
+	contentList, err := newDirEntryList(seg, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capShard.SetContents(contentList); err != nil {
+		return nil, err
+	}
+
+	return msg.Marshal()
+}
+
+// MarshalNodeSharded works like MarshalNode, but directories with more than
+// `threshold` entries store their entries in separate shards. Only the
+// shards that changed since the directory was loaded or marshaled the last
+// time are returned, keyed by their b58 hash. They need to be stored, so
+// LoadShards can find them again. A `threshold` <= 0 disables sharding.
+func MarshalNodeSharded(nd Node, threshold int) ([]byte, map[string][]byte, error) {
+	dir, ok := nd.(*Directory)
+	if !ok {
+		data, err := MarshalNode(nd)
+		return data, nil, err
+	}
+
+	if threshold <= 0 || len(dir.children) <= threshold {
+		// Small directories are stored like before:
+		dir.shards = nil
+		dir.dirtyShards = nil
+		data, err := MarshalNode(nd)
+		return data, nil, err
+	}
+
+	shards, err := dir.buildShards(shardCount(len(dir.children)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	capNd, err := capnp_model.NewRootNode(seg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := dir.setBaseAttrsToNode(capNd); err != nil {
+		return nil, nil, err
+	}
+
+	capDir, err := dir.setDirectoryAttrs(seg, dir.shards)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := capNd.SetDirectory(*capDir); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := msg.Marshal()
+	return data, shards, err
+}
+
+// LoadShards fills in the entries of `nd` if it is a sharded directory.
+// `load` is called with the hash of each shard and should return its data.
+func LoadShards(nd Node, load func(hash h.Hash) ([]byte, error)) error {
+	dir, ok := nd.(*Directory)
+	if !ok || len(dir.shards) == 0 {
+		return nil
+	}
+
+	for _, shard := range dir.shards {
+		data, err := load(shard)
+		if err != nil {
+			return err
+		}
+
+		if data == nil {
+			return fmt.Errorf("missing shard %s of %s", shard.B58String(), dir.Path())
+		}
+
+		msg, err := capnp.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+
+		capShard, err := capnp_model.ReadRootDirShard(msg)
+		if err != nil {
+			return err
+		}
+
+		if err := dir.readEntries(capShard.Children, capShard.Contents); err != nil {
+			return err
+		}
+	}
+
+	dir.dirtyShards = make(map[int]bool)
+	return nil
+}
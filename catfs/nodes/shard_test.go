@@ -0,0 +1,86 @@
+package nodes
+
+import (
+	"fmt"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardIndexIsStable(t *testing.T) {
+	require.Equal(t, 2, shardCount(0))
+	require.Equal(t, 16, shardCount(10001))
+
+	// Doubling the count splits a shard in two:
+	for idx := 0; idx < 100; idx++ {
+		name := fmt.Sprintf("file-%d", idx)
+		require.Equal(t, shardIndex(name, 8), shardIndex(name, 16)%8)
+	}
+}
+
+func TestShardedDirectoryRoundtrip(t *testing.T) {
+	lkr := NewMockLinker()
+	root, err := NewEmptyDirectory(lkr, nil, "", "a", 1)
+	require.Nil(t, err)
+	lkr.MemSetRoot(root)
+	lkr.AddNode(root, true)
+
+	for idx := 0; idx < 100; idx++ {
+		file := NewEmptyFile(root, fmt.Sprintf("file-%d", idx), "a", uint64(idx+2))
+		file.SetContent(lkr, h.TestDummy(t, byte(idx)))
+		require.Nil(t, root.Add(lkr, file))
+	}
+
+	store := make(map[string][]byte)
+	data, shards, err := MarshalNodeSharded(root, 10)
+	require.Nil(t, err)
+	require.Equal(t, shardCount(100), len(shards))
+	for b58, shard := range shards {
+		store[b58] = shard
+	}
+
+	// Below the threshold nothing changes:
+	plainData, plainShards, err := MarshalNodeSharded(root, 1000)
+	require.Nil(t, err)
+	require.Nil(t, plainShards)
+	require.Len(t, ShardHashes(root), 0)
+
+	plainNd, err := UnmarshalNode(plainData)
+	require.Nil(t, err)
+	require.Len(t, ShardHashes(plainNd), 0)
+
+	nd, err := UnmarshalNode(data)
+	require.Nil(t, err)
+	require.Equal(t, shardCount(100), len(ShardHashes(nd)))
+
+	loaded := nd.(*Directory)
+	require.Equal(t, 0, loaded.NChildren())
+	require.Nil(t, LoadShards(nd, func(hash h.Hash) ([]byte, error) {
+		return store[hash.B58String()], nil
+	}))
+
+	require.Equal(t, root.children, loaded.children)
+	require.Equal(t, root.contents, loaded.contents)
+	require.Equal(t, root.order, loaded.order)
+	require.Equal(t, root.TreeHash(), loaded.TreeHash())
+
+	// Changing a single entry should only write a single shard again:
+	file := NewEmptyFile(loaded, "new-file", "a", 1000)
+	require.Nil(t, loaded.Add(lkr, file))
+
+	_, shards, err = MarshalNodeSharded(loaded, 10)
+	require.Nil(t, err)
+	require.Len(t, shards, 1)
+
+	_, shards, err = MarshalNodeSharded(loaded, 10)
+	require.Nil(t, err)
+	require.Len(t, shards, 0)
+
+	// Missing shards are an error:
+	nd, err = UnmarshalNode(data)
+	require.Nil(t, err)
+	require.NotNil(t, LoadShards(nd, func(hash h.Hash) ([]byte, error) {
+		return nil, nil
+	}))
+}
@@ -0,0 +1,106 @@
+package catfs
+
+import (
+	"path"
+	"strings"
+
+	n "github.com/sahib/brig/catfs/nodes"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Different systems store the same looking name differently: macOS
+// decomposes umlauts (NFD), Linux takes what it gets (usually NFC) and
+// some filesystems do not care about case at all. To avoid that the same
+// file ends up twice after a sync, all paths that are passed to FS are run
+// through the path policy configured in fs.paths:
+//
+// If fs.paths.unicode_normalization is "nfc", paths are normalized to NFC.
+// If fs.paths.case_insensitive is true, a path that only differs in case
+// from an existing one is mapped to the existing one. Names that were
+// stored before the policy was enabled are found as well, but not renamed.
+
+// pathKey returns the form of `name` that is compared when looking for
+// existing nodes that only differ by normalization or case.
+func (fs *FS) pathKey(name string) string {
+	if fs.cfg.String("paths.unicode_normalization") == "nfc" {
+		name = norm.NFC.String(name)
+	}
+
+	if fs.cfg.Bool("paths.case_insensitive") {
+		name = strings.ToLower(name)
+	}
+
+	return name
+}
+
+// normPath applies the path policy to `repoPath` and returns the path
+// that should be used to look it up or to create it.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) normPath(repoPath string) string {
+	repoPath = prefixSlash(repoPath)
+	if fs.cfg.String("paths.unicode_normalization") == "nfc" {
+		repoPath = norm.NFC.String(repoPath)
+	}
+
+	// Fast path: the node exists just like this.
+	if nd, err := fs.lkr.LookupNode(repoPath); err == nil && nd != nil {
+		return repoPath
+	}
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return repoPath
+	}
+
+	elems := strings.Split(strings.Trim(path.Clean(repoPath), "/"), "/")
+	resolved := "/"
+
+	var curr n.Node = root
+	for idx, elem := range elems {
+		dir, ok := curr.(*n.Directory)
+		if !ok || elem == "" {
+			return path.Join(append([]string{resolved}, elems[idx:]...)...)
+		}
+
+		child, err := dir.Child(fs.lkr, elem)
+		if err != nil {
+			return repoPath
+		}
+
+		if child == nil {
+			// Look for a sibling that only looks different:
+			key := fs.pathKey(elem)
+			for _, name := range dir.ChildNames() {
+				if fs.pathKey(name) != key {
+					continue
+				}
+
+				if child, err = dir.Child(fs.lkr, name); err != nil {
+					return repoPath
+				}
+
+				elem = name
+				break
+			}
+		}
+
+		if child == nil || child.Type() == n.NodeTypeGhost {
+			// Rest of the path does not exist yet; keep it as given.
+			return path.Join(append([]string{resolved}, elems[idx:]...)...)
+		}
+
+		resolved = path.Join(resolved, elem)
+		curr = child
+	}
+
+	return resolved
+}
+
+// ResolvePath returns the path `repoPath` refers to after applying the
+// path policy. If no matching node exists, the normalized path is returned.
+func (fs *FS) ResolvePath(repoPath string) string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.normPath(repoPath)
+}
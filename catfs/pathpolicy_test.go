@@ -0,0 +1,73 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	nameNFC = "/b\u00e4r.txt"  // »ä« as single code point
+	nameNFD = "/ba\u0308r.txt" // »a« + combining diaeresis
+)
+
+func TestPathPolicyNFC(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage(nameNFD, bytes.NewReader([]byte("mac"))))
+		require.Nil(t, fs.Stage(nameNFC, bytes.NewReader([]byte("linux"))))
+
+		entries, err := fs.List("/", -1)
+		require.Nil(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, nameNFC, entries[1].Path)
+
+		// Both spellings refer to the same file:
+		info, err := fs.Stat(nameNFD)
+		require.Nil(t, err)
+		require.Equal(t, nameNFC, info.Path)
+		require.Equal(t, uint64(len("linux")), info.Size)
+
+		fs.cfg.SetString("paths.unicode_normalization", "none")
+		require.Nil(t, fs.Stage(nameNFD, bytes.NewReader([]byte("mac"))))
+
+		entries, err = fs.List("/", -1)
+		require.Nil(t, err)
+		require.Len(t, entries, 3)
+	})
+}
+
+func TestPathPolicyCaseInsensitive(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/Dir/Notes.txt", bytes.NewReader([]byte("a"))))
+
+		// Case matters by default:
+		_, err := fs.Stat("/dir/notes.txt")
+		require.NotNil(t, err)
+
+		fs.cfg.SetBool("paths.case_insensitive", true)
+
+		info, err := fs.Stat("/dir/notes.txt")
+		require.Nil(t, err)
+		require.Equal(t, "/Dir/Notes.txt", info.Path)
+
+		// Staging a different case updates the existing file:
+		require.Nil(t, fs.Stage("/DIR/NOTES.TXT", bytes.NewReader([]byte("bb"))))
+		require.Nil(t, fs.Stage("/dir/new.txt", bytes.NewReader([]byte("c"))))
+
+		entries, err := fs.List("/Dir", -1)
+		require.Nil(t, err)
+		require.Len(t, entries, 3)
+		require.Equal(t, "/Dir/Notes.txt", entries[1].Path)
+		require.Equal(t, uint64(2), entries[1].Size)
+		require.Equal(t, "/Dir/new.txt", entries[2].Path)
+
+		// Renaming to another case of the same name still works:
+		require.Nil(t, fs.Move("/dir/notes.txt", "/Dir/NOTES.txt"))
+		info, err = fs.Stat("/dir/notes.txt")
+		require.Nil(t, err)
+		require.Equal(t, "/Dir/NOTES.txt", info.Path)
+
+		require.Equal(t, "/Dir/NOTES.txt/x", fs.ResolvePath("/dir/notes.TXT/x"))
+	})
+}
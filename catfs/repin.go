@@ -222,6 +222,11 @@ func (fs *FS) repin(root string) error {
 
 	log.Infof("repin started (min=%d max=%d quota=%s)", minDepth, maxDepth, quotaSrc)
 
+	held, err := fs.heldPaths()
+	if err != nil {
+		return err
+	}
+
 	err = n.Walk(fs.lkr, rootNd, true, func(child n.Node) error {
 		if child.Type() == n.NodeTypeDirectory {
 			return nil
@@ -237,6 +242,17 @@ func (fs *FS) repin(root string) error {
 			return err
 		}
 
+		if holdCovering(held, child.Path()) != "" {
+			// Held files keep all of their versions and do not count
+			// towards the quota, so nothing else gets unpinned for them.
+			all := append(part.ShouldPin, part.QuotaCandidates...)
+			if _, err := fs.ensurePin(append(all, part.DepthCandidates...)); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
 		pinBytes, err := fs.ensurePin(part.ShouldPin)
 		if err != nil {
 			return err
@@ -0,0 +1,75 @@
+package catfs
+
+import (
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// PinContent pins the content with the backend hash `content`, if it is
+// used by a file in our history. Remotes use this to make sure that a file
+// is stored on enough peers. The pin is explicit, so that the repinner
+// does not give the content up again. It returns false if no file of
+// ours uses `content`; arbitrary hashes are never pinned.
+func (fs *FS) PinContent(content h.Hash) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	refs, err := fs.lkr.ContentRefs(content)
+	if err != nil {
+		return false, err
+	}
+
+	if len(refs) == 0 {
+		return false, nil
+	}
+
+	for _, ref := range refs {
+		nd, err := fs.lkr.NodeByHash(ref)
+		if err != nil {
+			return false, err
+		}
+
+		if nd == nil {
+			continue
+		}
+
+		if err := fs.pinner.PinNode(nd, true); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// IsContentPinned checks if the content with the backend hash `content`
+// is pinned by any file of ours.
+func (fs *FS) IsContentPinned(content h.Hash) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	refs, err := fs.lkr.ContentRefs(content)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ref := range refs {
+		nd, err := fs.lkr.NodeByHash(ref)
+		if err != nil {
+			return false, err
+		}
+
+		if nd == nil {
+			continue
+		}
+
+		isPinned, _, err := fs.pinner.IsNodePinned(nd)
+		if err != nil {
+			return false, err
+		}
+
+		if isPinned {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
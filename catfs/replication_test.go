@@ -0,0 +1,37 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinContent(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("x"))))
+		require.Nil(t, fs.MakeCommit("add"))
+		require.Nil(t, fs.Unpin("/x", "curr", true))
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+
+		isPinned, err := fs.IsContentPinned(info.BackendHash)
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		found, err := fs.PinContent(info.BackendHash)
+		require.Nil(t, err)
+		require.True(t, found)
+
+		isPinned, err = fs.IsContentPinned(info.BackendHash)
+		require.Nil(t, err)
+		require.True(t, isPinned)
+
+		// Content that none of our files use is never pinned:
+		found, err = fs.PinContent(h.TestDummy(t, 42))
+		require.Nil(t, err)
+		require.False(t, found)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	e "github.com/pkg/errors"
@@ -15,13 +16,23 @@ import (
 
 var (
 	indexCommitPattern = regexp.MustCompile(`^commit\[([-\+]{0,1}[0-9]+)\]$`)
+
+	// timeRevLayouts are the formats accepted by parseRevOrTime.
+	// Layouts without a zone are interpreted in local time.
+	timeRevLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
 )
 
 // validateRev check is a rev spec looks like it's valid
 // from a syntactic point of view.
 //
-// A valid ref may contain only letters or numbers, but might end with an
-// arbitrary number of '^' at the end. Unicode is allowed.
+// A valid ref may contain only letters, numbers, '-' or '_', but might end
+// with an arbitrary number of '^' at the end. Unicode is allowed.
 // As special case it might also match indexCommitPattern.
 //
 // If any violation is dected, an error is returned.
@@ -32,7 +43,7 @@ func validateRev(rev string) error {
 
 	foundUp := false
 	for _, c := range rev {
-		if unicode.IsLetter(c) || unicode.IsNumber(c) {
+		if unicode.IsLetter(c) || unicode.IsNumber(c) || c == '-' || c == '_' {
 			if foundUp {
 				return fmt.Errorf("normal character after ^")
 			}
@@ -99,3 +110,53 @@ func parseRev(lkr *c.Linker, rev string) (*n.Commit, error) {
 
 	return cmt, nil
 }
+
+// parseTimeRev tries to read `rev` as a point in time.
+// The second return value is false if `rev` does not look like one.
+func parseTimeRev(rev string) (time.Time, bool) {
+	for _, layout := range timeRevLayouts {
+		t, err := time.ParseInLocation(layout, rev, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseRevOrTime works like parseRev, but also accepts a timestamp
+// (e.g. "2023-01-01" or "2023-01-01T12:00:00+01:00"). In this case
+// the last commit that was made at or before this time is returned.
+// Staged changes are never considered.
+func parseRevOrTime(lkr *c.Linker, rev string) (*n.Commit, error) {
+	at, ok := parseTimeRev(rev)
+	if !ok {
+		return parseRev(lkr, rev)
+	}
+
+	head, err := lkr.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *n.Commit
+	errFound := fmt.Errorf("found")
+	err = c.Log(lkr, head, func(cmt *n.Commit) error {
+		if cmt.ModTime().After(at) {
+			return nil
+		}
+
+		found = cmt
+		return errFound
+	})
+
+	if err != nil && err != errFound {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, ie.ErrNoSuchRef(rev)
+	}
+
+	return found, nil
+}
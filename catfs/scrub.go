@@ -0,0 +1,307 @@
+package catfs
+
+import (
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sahib/brig/catfs/db"
+	"github.com/sahib/brig/catfs/mio/encrypt"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScrubCorruption describes a pinned file whose content did not verify.
+type ScrubCorruption struct {
+	// Path of the file in the current tree.
+	Path string
+	// BackendHash is where the content is stored in the backend.
+	BackendHash h.Hash
+	// Err is the reason why the content is considered corrupt.
+	Err string
+	// Repaired is true if the content could be fetched again
+	// and verified successfully afterwards.
+	Repaired bool
+}
+
+// ScrubStatus describes the current or last run of the scrubber.
+type ScrubStatus struct {
+	// Running is true while a scrub is in progress.
+	Running bool
+	// LastStart is when the last scrub was started (zero if never).
+	LastStart time.Time
+	// LastEnd is when the last scrub was done (zero if never or still running).
+	LastEnd time.Time
+	// Checked is the number of files that were verified.
+	Checked int
+	// Bytes is the number of stored (encrypted) bytes that were read.
+	Bytes uint64
+	// Corrupt lists all files that failed verification.
+	Corrupt []ScrubCorruption
+	// Err is set if the scrub failed as a whole.
+	Err string
+}
+
+// scrubItem is a copy of the file attributes needed for verification,
+// so we do not need to hold the fs lock while reading the content.
+type scrubItem struct {
+	path        string
+	key         []byte
+	backendHash h.Hash
+}
+
+// throttledReader delays reads so that no more than `rate` bytes per
+// second are read on average. A rate of 0 means no limit.
+type throttledReader struct {
+	r     io.Reader
+	rate  uint64
+	start time.Time
+	read  uint64
+}
+
+func (tr *throttledReader) Read(buf []byte) (int, error) {
+	size, err := tr.r.Read(buf)
+	tr.read += uint64(size)
+
+	if tr.rate > 0 {
+		expected := time.Duration(float64(tr.read) / float64(tr.rate) * float64(time.Second))
+		if wait := expected - time.Since(tr.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	return size, err
+}
+
+// SetContentRepairer sets a function that is asked to fetch the content
+// at `hash` again after the scrubber found it to be corrupt. nil removes it.
+func (fs *FS) SetContentRepairer(repairer func(hash h.Hash) error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.contentRepairer = repairer
+}
+
+// ScrubStatus returns the status of the current or last scrub.
+func (fs *FS) ScrubStatus() ScrubStatus {
+	fs.scrubMu.Lock()
+	defer fs.scrubMu.Unlock()
+
+	status := fs.scrubStatus
+	status.Corrupt = append([]ScrubCorruption{}, fs.scrubStatus.Corrupt...)
+	return status
+}
+
+func (fs *FS) pinnedFiles() ([]scrubItem, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []scrubItem{}
+	err = n.Walk(fs.lkr, root, true, func(child n.Node) error {
+		file, ok := child.(*n.File)
+		if !ok {
+			return nil
+		}
+
+		isPinned, _, err := fs.pinner.IsNodePinned(file)
+		if err != nil {
+			return err
+		}
+
+		if !isPinned {
+			return nil
+		}
+
+		key := make([]byte, len(file.Key()))
+		copy(key, file.Key())
+
+		items = append(items, scrubItem{
+			path:        file.Path(),
+			key:         key,
+			backendHash: file.BackendHash().Clone(),
+		})
+
+		return nil
+	})
+
+	return items, err
+}
+
+// verifyContent reads the stored content of `item` and checks the MAC of
+// every block with encrypt.Verify. The MACs authenticate the data with the
+// key of the file, so there is no need to decompress and hash it again.
+// Returns the number of stored bytes that were read.
+func (fs *FS) verifyContent(item scrubItem, rate uint64) (uint64, error) {
+	stream, err := fs.rawCat(item.backendHash)
+	if err != nil {
+		return 0, err
+	}
+
+	defer stream.Close()
+
+	tr := &throttledReader{r: stream, rate: rate, start: time.Now()}
+	_, err = encrypt.Verify(tr, item.key)
+	return tr.read, err
+}
+
+// Scrub reads the content of all pinned files in the current tree and
+// verifies it against the stored hashes. Corrupt content is handed to
+// the content repairer (if any) and verified again. Only one scrub runs
+// at a time; calling Scrub while another one runs returns its status.
+func (fs *FS) Scrub() (ScrubStatus, error) {
+	fs.scrubMu.Lock()
+	if fs.scrubStatus.Running {
+		fs.scrubMu.Unlock()
+		return fs.ScrubStatus(), nil
+	}
+
+	fs.scrubStatus = ScrubStatus{Running: true, LastStart: time.Now()}
+	fs.scrubMu.Unlock()
+
+	err := fs.scrub()
+
+	fs.scrubMu.Lock()
+	fs.scrubStatus.Running = false
+	fs.scrubStatus.LastEnd = time.Now()
+	if err != nil {
+		fs.scrubStatus.Err = err.Error()
+	}
+	fs.scrubMu.Unlock()
+
+	// Remember when we scrubbed, so a restart does not trigger a new run:
+	fs.mu.Lock()
+	lastStart := []byte(fs.ScrubStatus().LastStart.Format(time.RFC3339))
+	if err := fs.lkr.MetadataPut("fs.last-scrub", lastStart); err != nil {
+		log.Warningf("failed to store scrub time: %v", err)
+	}
+	fs.mu.Unlock()
+
+	return fs.ScrubStatus(), err
+}
+
+// lastScrub returns when the last scrub was started,
+// even if it was before the last restart.
+func (fs *FS) lastScrub() (time.Time, error) {
+	if lastStart := fs.ScrubStatus().LastStart; !lastStart.IsZero() {
+		return lastStart, nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, err := fs.lkr.MetadataGet("fs.last-scrub")
+	if err == db.ErrNoSuchKey {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, string(data))
+}
+
+func (fs *FS) scrub() error {
+	rate, err := humanize.ParseBytes(fs.cfg.String("scrub.max_rate"))
+	if err != nil {
+		return err
+	}
+
+	items, err := fs.pinnedFiles()
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	repairer := fs.contentRepairer
+	fs.mu.Unlock()
+
+	for _, item := range items {
+		size, verr := fs.verifyContent(item, rate)
+
+		fs.scrubMu.Lock()
+		fs.scrubStatus.Checked++
+		fs.scrubStatus.Bytes += size
+		fs.scrubMu.Unlock()
+
+		if verr == nil {
+			continue
+		}
+
+		log.Warningf("scrub: content of %s (%s) is corrupt: %v", item.path, item.backendHash, verr)
+		corruption := ScrubCorruption{
+			Path:        item.path,
+			BackendHash: item.backendHash,
+			Err:         verr.Error(),
+		}
+
+		if repairer != nil {
+			if err := repairer(item.backendHash); err != nil {
+				log.Warningf("scrub: failed to fetch %s again: %v", item.path, err)
+			} else if _, err := fs.verifyContent(item, rate); err != nil {
+				log.Warningf("scrub: %s is still corrupt after fetching it again: %v", item.path, err)
+			} else {
+				log.Infof("scrub: repaired %s", item.path)
+				corruption.Repaired = true
+			}
+		}
+
+		fs.scrubMu.Lock()
+		fs.scrubStatus.Corrupt = append(fs.scrubStatus.Corrupt, corruption)
+		fs.scrubMu.Unlock()
+	}
+
+	return nil
+}
+
+func (fs *FS) scrubLoop() {
+	if fs.readOnly {
+		return
+	}
+
+	checkTicker := time.NewTicker(1 * time.Minute)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-fs.scrubControl:
+			log.Debugf("quitting the scrub loop")
+			return
+		case <-checkTicker.C:
+			if !fs.cfg.Bool("scrub.enabled") {
+				continue
+			}
+
+			// Check the time since the last start, so that a scrub that
+			// takes longer than the interval does not start right away.
+			lastStart, err := fs.lastScrub()
+			if err != nil {
+				log.Warningf("scrub: %v", err)
+				continue
+			}
+
+			if time.Since(lastStart) < fs.cfg.Duration("scrub.interval") {
+				continue
+			}
+
+			status, err := fs.Scrub()
+			if err != nil {
+				log.Warningf("scrub failed: %v", err)
+				continue
+			}
+
+			log.Infof(
+				"scrub: checked %d files (%s), %d corrupt",
+				status.Checked,
+				humanize.Bytes(status.Bytes),
+				len(status.Corrupt),
+			)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// storedSize returns how many bytes the content of `paths` takes in the backend.
+func storedSize(t *testing.T, fs *FS, paths ...string) uint64 {
+	mb := fs.bk.(*MemFsBackend)
+	size := uint64(0)
+	for _, path := range paths {
+		info, err := fs.Stat(path)
+		require.Nil(t, err)
+		size += uint64(len(mb.data[info.BackendHash.B58String()]))
+	}
+
+	return size
+}
+
+func TestScrub(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		fs.cfg.SetString("scrub.max_rate", "0")
+		data := testutil.CreateDummyBuf(16 * 1024)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader(data)))
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("hello"))))
+
+		status, err := fs.Scrub()
+		require.Nil(t, err)
+		require.Equal(t, 2, status.Checked)
+		require.Equal(t, storedSize(t, fs, "/x", "/y"), status.Bytes)
+		require.Empty(t, status.Corrupt)
+		require.False(t, status.LastEnd.IsZero())
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+
+		// Flip a byte in the stored (encrypted) content:
+		mb := fs.bk.(*MemFsBackend)
+		key := info.BackendHash.B58String()
+		good := append([]byte{}, mb.data[key]...)
+		mb.data[key][len(good)/2] ^= 0xFF
+
+		status, err = fs.Scrub()
+		require.Nil(t, err)
+		require.Len(t, status.Corrupt, 1)
+		require.Equal(t, "/x", status.Corrupt[0].Path)
+		require.False(t, status.Corrupt[0].Repaired)
+
+		fs.SetContentRepairer(func(hash h.Hash) error {
+			mb.data[hash.B58String()] = good
+			return nil
+		})
+
+		status, err = fs.Scrub()
+		require.Nil(t, err)
+		require.Len(t, status.Corrupt, 1)
+		require.True(t, status.Corrupt[0].Repaired)
+
+		status, err = fs.Scrub()
+		require.Nil(t, err)
+		require.Empty(t, status.Corrupt)
+
+		lastScrub, err := fs.lastScrub()
+		require.Nil(t, err)
+		require.False(t, lastScrub.IsZero())
+	})
+}
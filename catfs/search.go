@@ -0,0 +1,168 @@
+package catfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// SearchQuery describes what Search should look for.
+type SearchQuery struct {
+	// Root is the directory to search in.
+	Root string
+	// Text is a list of words separated by whitespace.
+	// Every word has to occur somewhere in the path of a result.
+	Text string
+	// Type limits the results to an extension ("pdf" or ".pdf"),
+	// a kind ("image") or a mime type ("image/png", "image/*").
+	Type string
+	// Offset is the number of results to skip.
+	Offset int
+	// Limit is the maximum number of results. Zero means no limit.
+	Limit int
+}
+
+// SearchResult is a single file found by Search.
+type SearchResult struct {
+	Info *StatInfo
+	// Score tells how well the file matched; higher is better.
+	Score float64
+}
+
+// searchScore rates how well the path `nodePath` matches `words`.
+// Words in the base name count more than words in a parent directory
+// and whole words count more than parts of words. It returns false
+// if one of the words does not occur at all.
+func searchScore(nodePath string, words []string) (float64, bool) {
+	nodePath = strings.ToLower(nodePath)
+	base := path.Base(nodePath)
+	baseWords := strings.FieldsFunc(base, isSearchSeparator)
+
+	score := 0.0
+	for _, word := range words {
+		switch {
+		case containsWord(baseWords, word):
+			score += 4
+		case strings.Contains(base, word):
+			score += 2
+		case strings.Contains(nodePath, word):
+			score++
+		default:
+			return 0, false
+		}
+	}
+
+	// Prefer files that are not buried deep below the root:
+	score -= float64(strings.Count(nodePath, "/")) * 0.01
+	return score, true
+}
+
+func isSearchSeparator(r rune) bool {
+	return strings.ContainsRune(" ._-/", r)
+}
+
+func containsWord(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesSearchType checks if `info` is of type `typ` (see SearchQuery.Type).
+func matchesSearchType(info *StatInfo, typ string) bool {
+	typ = strings.ToLower(typ)
+	if typ == "" {
+		return true
+	}
+
+	mimeType := strings.ToLower(strings.TrimSpace(strings.SplitN(info.MimeType, ";", 2)[0]))
+	switch {
+	case strings.HasSuffix(typ, "/*"):
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(typ, "*"))
+	case strings.Contains(typ, "/"):
+		return mimeType == typ
+	case typ == info.Kind:
+		return true
+	}
+
+	return strings.ToLower(path.Ext(info.Path)) == "."+strings.TrimPrefix(typ, ".")
+}
+
+// Search finds all files below `query.Root` whose path contains all words
+// of `query.Text`, best matches first. It also returns the number of
+// matches before paging was applied. There is no persistent index;
+// the tree is walked on every call, which is fast enough since only
+// the metadata has to be looked at.
+func (fs *FS) Search(query SearchQuery) ([]SearchResult, int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root := fs.normPath(query.Root)
+	rootNd, err := fs.lkr.LookupNode(root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if rootNd.Type() == n.NodeTypeGhost {
+		return nil, 0, ie.NoSuchFile(root)
+	}
+
+	words := strings.Fields(strings.ToLower(query.Text))
+	results := []SearchResult{}
+	err = n.Walk(fs.lkr, rootNd, false, func(child n.Node) error {
+		if child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		relPath := strings.TrimPrefix(child.Path(), root)
+		score, ok := searchScore(relPath, words)
+		if !ok {
+			return nil
+		}
+
+		info := fs.nodeToStat(child)
+		if !matchesSearchType(info, query.Type) {
+			return nil
+		}
+
+		results = append(results, SearchResult{Info: info, Score: score})
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		if !results[i].Info.ModTime.Equal(results[j].Info.ModTime) {
+			return results[i].Info.ModTime.After(results[j].Info.ModTime)
+		}
+
+		return results[i].Info.Path < results[j].Info.Path
+	})
+
+	total := len(results)
+	if query.Offset > 0 {
+		if query.Offset >= len(results) {
+			return []SearchResult{}, total, nil
+		}
+
+		results = results[query.Offset:]
+	}
+
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,57 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		pdf := []byte("%PDF-1.4 hello")
+		require.Nil(t, fs.Stage("/docs/invoice-2023.pdf", bytes.NewReader(pdf)))
+		require.Nil(t, fs.Stage("/docs/2023/invoices-march.pdf", bytes.NewReader(pdf)))
+		require.Nil(t, fs.Stage("/docs/invoice/2023.txt", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.Stage("/photos/invoice-2022.png", bytes.NewReader([]byte("x"))))
+
+		paths := func(results []SearchResult) []string {
+			found := []string{}
+			for _, result := range results {
+				found = append(found, result.Info.Path)
+			}
+			return found
+		}
+
+		results, total, err := fs.Search(SearchQuery{Root: "/", Text: "Invoice 2023"})
+		require.Nil(t, err)
+		require.Equal(t, 3, total)
+		require.Equal(t, []string{
+			"/docs/invoice-2023.pdf",
+			"/docs/invoice/2023.txt",
+			"/docs/2023/invoices-march.pdf",
+		}, paths(results))
+		require.True(t, results[0].Score > results[1].Score)
+
+		results, total, err = fs.Search(SearchQuery{Root: "/docs", Text: "invoice", Type: "pdf"})
+		require.Nil(t, err)
+		require.Equal(t, 2, total)
+		require.Equal(t, []string{"/docs/invoice-2023.pdf", "/docs/2023/invoices-march.pdf"}, paths(results))
+
+		results, _, err = fs.Search(SearchQuery{Root: "/docs", Text: "invoice", Type: "application/pdf"})
+		require.Nil(t, err)
+		require.Len(t, results, 2)
+
+		results, total, err = fs.Search(SearchQuery{Root: "/", Text: "invoice", Offset: 1, Limit: 2})
+		require.Nil(t, err)
+		require.Equal(t, 4, total)
+		require.Len(t, results, 2)
+
+		results, _, err = fs.Search(SearchQuery{Root: "/", Text: "invoice", Offset: 10})
+		require.Nil(t, err)
+		require.Len(t, results, 0)
+
+		_, _, err = fs.Search(SearchQuery{Root: "/nope", Text: "invoice"})
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,263 @@
+package catfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+var errStopLog = e.New("stop log")
+
+// Selector describes a set of file versions,
+// for example to pin or unpin them in bulk.
+// Empty fields do not restrict the selection.
+type Selector struct {
+	// Root is the directory to select files below. Defaults to "/".
+	Root string
+
+	// Globs are shell patterns; files match if either their full path
+	// or their base name matches one of them.
+	Globs []string
+
+	// OlderThan selects only files modified before this time.
+	OlderThan time.Time
+
+	// LargerThan selects only files with more bytes than this.
+	LargerThan uint64
+
+	// Commits is a range like "A..B" that selects all versions of the
+	// commits reachable from B, but not from A. A single revision
+	// selects only the versions in this commit. If empty, only the
+	// versions in the staging commit are selected.
+	Commits string
+}
+
+// SelectedVersion is a single version of a file that matched a Selector.
+type SelectedVersion struct {
+	Path        string
+	Commit      h.Hash
+	Size        uint64
+	ModTime     time.Time
+	ContentHash h.Hash
+	BackendHash h.Hash
+	IsPinned    bool
+	IsExplicit  bool
+}
+
+func (sel *Selector) matches(file *n.File) (bool, error) {
+	if sel.LargerThan > 0 && file.Size() <= sel.LargerThan {
+		return false, nil
+	}
+
+	if !sel.OlderThan.IsZero() && !file.ModTime().Before(sel.OlderThan) {
+		return false, nil
+	}
+
+	if len(sel.Globs) == 0 {
+		return true, nil
+	}
+
+	for _, glob := range sel.Globs {
+		for _, candidate := range []string{file.Path(), file.Name()} {
+			ok, err := path.Match(glob, candidate)
+			if err != nil {
+				return false, e.Wrapf(err, "bad glob `%s`", glob)
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// selectCommits resolves the commit range of `sel`.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) selectCommits(commits string) ([]*n.Commit, error) {
+	if commits == "" {
+		status, err := fs.lkr.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		return []*n.Commit{status}, nil
+	}
+
+	split := strings.SplitN(commits, "..", 2)
+	if len(split) == 1 {
+		cmt, err := parseRev(fs.lkr, commits)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*n.Commit{cmt}, nil
+	}
+
+	toRev := split[1]
+	if toRev == "" {
+		toRev = "curr"
+	}
+
+	toCmt, err := parseRev(fs.lkr, toRev)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromHash h.Hash
+	if split[0] != "" {
+		fromCmt, err := parseRev(fs.lkr, split[0])
+		if err != nil {
+			return nil, err
+		}
+
+		fromHash = fromCmt.TreeHash()
+	}
+
+	found := false
+	result := []*n.Commit{}
+	err = c.Log(fs.lkr, toCmt, func(cmt *n.Commit) error {
+		if fromHash != nil && cmt.TreeHash().Equal(fromHash) {
+			found = true
+			return errStopLog
+		}
+
+		result = append(result, cmt)
+		return nil
+	})
+
+	if err != nil && err != errStopLog {
+		return nil, err
+	}
+
+	if fromHash != nil && !found {
+		return nil, e.Errorf("`%s` is not an ancestor of `%s`", split[0], toRev)
+	}
+
+	return result, nil
+}
+
+// Select returns all file versions matching `sel`, sorted by path.
+// Versions that share the same path and content are only listed once.
+func (fs *FS) Select(sel Selector) ([]*SelectedVersion, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.selectVersions(sel, nil)
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) selectVersions(sel Selector, fn func(file *n.File) error) ([]*SelectedVersion, error) {
+	root := fs.normPath(sel.Root)
+
+	cmts, err := fs.selectCommits(sel.Commits)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	result := []*SelectedVersion{}
+
+	for _, cmt := range cmts {
+		rootNd, err := fs.lkr.LookupNodeAt(cmt, root)
+		if err != nil && !ie.IsNoSuchFileError(err) {
+			return nil, err
+		}
+
+		if rootNd == nil || rootNd.Type() == n.NodeTypeGhost {
+			continue
+		}
+
+		err = n.Walk(fs.lkr, rootNd, false, func(child n.Node) error {
+			if child.Type() != n.NodeTypeFile {
+				return nil
+			}
+
+			file, ok := child.(*n.File)
+			if !ok {
+				return ie.ErrBadNode
+			}
+
+			key := file.Path() + "|" + file.ContentHash().B58String()
+			if seen[key] {
+				return nil
+			}
+
+			seen[key] = true
+
+			ok, err := sel.matches(file)
+			if err != nil || !ok {
+				return err
+			}
+
+			isPinned, isExplicit, err := fs.pinner.IsNodePinned(file)
+			if err != nil {
+				return err
+			}
+
+			result = append(result, &SelectedVersion{
+				Path:        file.Path(),
+				Commit:      cmt.TreeHash().Clone(),
+				Size:        file.Size(),
+				ModTime:     file.ModTime(),
+				ContentHash: file.ContentHash().Clone(),
+				BackendHash: file.BackendHash().Clone(),
+				IsPinned:    isPinned,
+				IsExplicit:  isExplicit,
+			})
+
+			if fn != nil {
+				return fn(file)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+// PinSelection pins (or unpins if `pin` is false) all versions matching
+// `sel` explicitly. The versions are returned with the pin state they had
+// before. If `dryRun` is true, nothing is changed.
+func (fs *FS) PinSelection(sel Selector, pin, dryRun bool) ([]*SelectedVersion, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if dryRun {
+		return fs.selectVersions(sel, nil)
+	}
+
+	op := fs.pinner.UnpinNode
+	if pin {
+		op = fs.pinner.PinNode
+	}
+
+	return fs.selectVersions(sel, func(file *n.File) error {
+		if err := op(file, true); err != nil {
+			return err
+		}
+
+		if pin {
+			fs.preCacheInBackground(file.BackendHash())
+		}
+
+		return nil
+	})
+}
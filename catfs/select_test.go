@@ -0,0 +1,111 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func selectedPaths(versions []*SelectedVersion) []string {
+	paths := []string{}
+	for _, version := range versions {
+		paths = append(paths, version.Path)
+	}
+
+	return paths
+}
+
+func TestSelect(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		big := bytes.Repeat([]byte("x"), 1024)
+		require.Nil(t, fs.Stage("/photos/a.jpg", bytes.NewReader(big)))
+		require.Nil(t, fs.Stage("/photos/b.png", bytes.NewReader([]byte("b"))))
+		require.Nil(t, fs.Stage("/doc.txt", bytes.NewReader([]byte("old"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Nil(t, fs.Stage("/doc.txt", bytes.NewReader([]byte("new"))))
+		require.Nil(t, fs.MakeCommit("second"))
+
+		versions, err := fs.Select(Selector{})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/doc.txt", "/photos/a.jpg", "/photos/b.png"}, selectedPaths(versions))
+
+		versions, err = fs.Select(Selector{Globs: []string{"*.jpg", "/doc.*"}})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/doc.txt", "/photos/a.jpg"}, selectedPaths(versions))
+
+		versions, err = fs.Select(Selector{LargerThan: 512})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/photos/a.jpg"}, selectedPaths(versions))
+
+		versions, err = fs.Select(Selector{Root: "/photos"})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/photos/a.jpg", "/photos/b.png"}, selectedPaths(versions))
+
+		versions, err = fs.Select(Selector{OlderThan: time.Now().Add(-time.Hour)})
+		require.Nil(t, err)
+		require.Empty(t, versions)
+
+		versions, err = fs.Select(Selector{OlderThan: time.Now().Add(time.Hour)})
+		require.Nil(t, err)
+		require.Len(t, versions, 3)
+
+		// Both versions of /doc.txt are part of the whole history:
+		versions, err = fs.Select(Selector{Globs: []string{"doc.txt"}, Commits: "..head"})
+		require.Nil(t, err)
+		require.Len(t, versions, 2)
+		require.False(t, versions[0].ContentHash.Equal(versions[1].ContentHash))
+
+		// Only the new one was introduced after the first commit:
+		versions, err = fs.Select(Selector{Globs: []string{"doc.txt"}, Commits: "head^..head"})
+		require.Nil(t, err)
+		require.Len(t, versions, 1)
+		require.Equal(t, uint64(3), versions[0].Size)
+
+		_, err = fs.Select(Selector{Globs: []string{"["}})
+		require.NotNil(t, err)
+
+		_, err = fs.Select(Selector{Commits: "head..head^"})
+		require.NotNil(t, err)
+	})
+}
+
+func TestPinSelection(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/a.txt", bytes.NewReader([]byte("a"))))
+		require.Nil(t, fs.Stage("/b.bin", bytes.NewReader([]byte("b"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		sel := Selector{Globs: []string{"*.bin"}}
+		versions, err := fs.PinSelection(sel, false, true)
+		require.Nil(t, err)
+		require.Len(t, versions, 1)
+		require.True(t, versions[0].IsPinned)
+
+		// Dry run should not change anything:
+		isPinned, _, err := fs.IsPinned("/b.bin")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+
+		_, err = fs.PinSelection(sel, false, false)
+		require.Nil(t, err)
+
+		isPinned, _, err = fs.IsPinned("/b.bin")
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		isPinned, _, err = fs.IsPinned("/a.txt")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+
+		_, err = fs.PinSelection(sel, true, false)
+		require.Nil(t, err)
+
+		isPinned, isExplicit, err := fs.IsPinned("/b.bin")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+		require.True(t, isExplicit)
+	})
+}
@@ -0,0 +1,212 @@
+package catfs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Snapshots are tags of HEAD that are created automatically in several
+// tiers (hourly, daily, ...). Every tier keeps a configurable number of
+// its most recent snapshots; older ones are removed again.
+// The tag name contains the tier and the period it was taken in,
+// e.g. »snapshot-daily-2019-01-31«. Users can not create tags with
+// the »snapshot-« prefix, so the retention never removes their tags.
+
+const snapshotTagPrefix = "snapshot-"
+
+type snapshotTier struct {
+	name string
+
+	// pattern matches the period part of the tag name.
+	pattern *regexp.Regexp
+
+	// period returns a sortable name for the period `t` is in.
+	period func(t time.Time) string
+}
+
+var snapshotTiers = []snapshotTier{
+	{
+		name:    "hourly",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-\d{2}$`),
+		period:  func(t time.Time) string { return t.Format("2006-01-02-15") },
+	}, {
+		name:    "daily",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`),
+		period:  func(t time.Time) string { return t.Format("2006-01-02") },
+	}, {
+		name:    "weekly",
+		pattern: regexp.MustCompile(`^\d{4}-w\d{2}$`),
+		period: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-w%02d", year, week)
+		},
+	}, {
+		name:    "monthly",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}$`),
+		period:  func(t time.Time) string { return t.Format("2006-01") },
+	},
+}
+
+// Snapshot is a single automatically created tag.
+type Snapshot struct {
+	// Tier is the name of the tier (hourly, daily, weekly or monthly).
+	Tier string
+	// Tag is the name of the tag that can be used as revision.
+	Tag string
+	// Commit is the hash of the tagged commit.
+	Commit string
+}
+
+func snapshotTierPrefix(tier string) string {
+	return snapshotTagPrefix + tier + "-"
+}
+
+// isSnapshotTag tells if `name` is reserved for snapshots.
+func isSnapshotTag(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), snapshotTagPrefix)
+}
+
+// snapshotsOfTier returns all snapshot tags of `tier`, oldest first.
+// Must be called with fs.mu held.
+func (fs *FS) snapshotsOfTier(tier snapshotTier) ([]string, error) {
+	refs, err := fs.lkr.ListRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only take tags that look exactly like ours, in case somebody
+	// created a similar tag before the prefix was reserved:
+	prefix := snapshotTierPrefix(tier.name)
+	tags := []string{}
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+
+		if tier.pattern.MatchString(strings.TrimPrefix(ref, prefix)) {
+			tags = append(tags, ref)
+		}
+	}
+
+	// The period format sorts chronologically:
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// Snapshot tags HEAD for every enabled tier that has no snapshot for the
+// period of `now` yet and removes snapshots exceeding the retention.
+// It returns the names of the created and removed tags.
+func (fs *FS) Snapshot(now time.Time) ([]string, []string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+
+	head, err := fs.lkr.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, removed := []string{}, []string{}
+	for _, tier := range snapshotTiers {
+		keep := int(fs.cfg.Int("snapshots.keep_" + tier.name))
+
+		tags, err := fs.snapshotsOfTier(tier)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if keep > 0 {
+			tag := snapshotTierPrefix(tier.name) + tier.period(now)
+			idx := sort.SearchStrings(tags, tag)
+			if idx >= len(tags) || tags[idx] != tag {
+				if err := fs.lkr.SaveRef(tag, head); err != nil {
+					return nil, nil, err
+				}
+
+				created = append(created, tag)
+				tags = append(tags, tag)
+				sort.Strings(tags)
+			}
+		}
+
+		// Drop everything but the `keep` most recent ones:
+		for len(tags) > keep {
+			if err := fs.lkr.RemoveRef(tags[0]); err != nil {
+				return nil, nil, err
+			}
+
+			removed = append(removed, tags[0])
+			tags = tags[1:]
+		}
+	}
+
+	return created, removed, nil
+}
+
+// Snapshots lists all existing snapshots, oldest first per tier.
+func (fs *FS) Snapshots() ([]Snapshot, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	snapshots := []Snapshot{}
+	for _, tier := range snapshotTiers {
+		tags, err := fs.snapshotsOfTier(tier)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			nd, err := fs.lkr.ResolveRef(tag)
+			if err != nil {
+				return nil, err
+			}
+
+			snapshots = append(snapshots, Snapshot{
+				Tier:   tier.name,
+				Tag:    tag,
+				Commit: nd.TreeHash().B58String(),
+			})
+		}
+	}
+
+	return snapshots, nil
+}
+
+func (fs *FS) snapshotLoop() {
+	if fs.readOnly {
+		return
+	}
+
+	checkTicker := time.NewTicker(1 * time.Minute)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-fs.snapshotControl:
+			log.Debugf("quitting the snapshot loop")
+			return
+		case <-checkTicker.C:
+			if !fs.cfg.Bool("snapshots.enabled") || fs.IsFrozen() {
+				continue
+			}
+
+			created, removed, err := fs.Snapshot(time.Now())
+			if err != nil {
+				log.Warningf("failed to create snapshots: %v", err)
+				continue
+			}
+
+			if len(created)+len(removed) > 0 {
+				log.Infof("snapshots: created %v, removed %v", created, removed)
+			}
+		}
+	}
+}
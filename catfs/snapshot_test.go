@@ -0,0 +1,79 @@
+package catfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRetention(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		fs.cfg.SetInt("snapshots.keep_hourly", 2)
+		fs.cfg.SetInt("snapshots.keep_daily", 1)
+		fs.cfg.SetInt("snapshots.keep_weekly", 0)
+		fs.cfg.SetInt("snapshots.keep_monthly", 0)
+
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		base := time.Date(2019, 1, 31, 10, 30, 0, 0, time.UTC)
+		created, removed, err := fs.Snapshot(base)
+		require.Nil(t, err)
+		require.Equal(t, []string{"snapshot-hourly-2019-01-31-10", "snapshot-daily-2019-01-31"}, created)
+		require.Empty(t, removed)
+
+		// Same period, nothing should happen:
+		created, removed, err = fs.Snapshot(base.Add(10 * time.Minute))
+		require.Nil(t, err)
+		require.Empty(t, created)
+		require.Empty(t, removed)
+
+		_, _, err = fs.Snapshot(base.Add(time.Hour))
+		require.Nil(t, err)
+
+		created, removed, err = fs.Snapshot(base.Add(14 * time.Hour))
+		require.Nil(t, err)
+		require.Equal(t, []string{"snapshot-hourly-2019-02-01-00", "snapshot-daily-2019-02-01"}, created)
+		require.Equal(t, []string{"snapshot-hourly-2019-01-31-10", "snapshot-daily-2019-01-31"}, removed)
+
+		snapshots, err := fs.Snapshots()
+		require.Nil(t, err)
+		require.Len(t, snapshots, 3)
+
+		head, err := fs.Head()
+		require.Nil(t, err)
+		for _, snapshot := range snapshots {
+			require.Equal(t, head, snapshot.Commit)
+		}
+
+		// Snapshots can be used like any other tag:
+		info, err := fs.StatAt("snapshot-daily-2019-02-01", "/x")
+		require.Nil(t, err)
+		require.Equal(t, "/x", info.Path)
+	})
+}
+
+func TestSnapshotUserTags(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		fs.cfg.SetInt("snapshots.keep_daily", 0)
+
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Equal(t, ErrReservedTag, fs.Tag("HEAD", "snapshot-daily-mine"))
+		require.Equal(t, ErrReservedTag, fs.Tag("HEAD", "Snapshot-Daily-mine"))
+
+		// Tags from before the prefix was reserved must survive the retention:
+		head, err := fs.lkr.Head()
+		require.Nil(t, err)
+		require.Nil(t, fs.lkr.SaveRef("snapshot-daily-mine", head))
+
+		_, removed, err := fs.Snapshot(time.Now())
+		require.Nil(t, err)
+		require.NotContains(t, removed, "snapshot-daily-mine")
+
+		_, err = fs.lkr.ResolveRef("snapshot-daily-mine")
+		require.Nil(t, err)
+	})
+}
@@ -0,0 +1,166 @@
+package catfs
+
+import (
+	"time"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tombstone tells that a node was deleted on purpose.
+// See core.Tombstone for the details.
+type Tombstone struct {
+	Path    string
+	Removed time.Time
+	Owner   string
+	IsDir   bool
+	// Expires is when the tombstone and the content of the node
+	// are purged. Zero if they are kept forever.
+	Expires time.Time
+}
+
+// Tombstones lists all deleted nodes that are still remembered.
+// Until they expire, they can be undeleted and are not brought
+// back by syncing with a peer that did not delete them yet.
+func (fs *FS) Tombstones() ([]Tombstone, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	tombstones, err := fs.lkr.Tombstones()
+	if err != nil {
+		return nil, err
+	}
+
+	retention := fs.cfg.Duration("tombstones.retention")
+
+	result := []Tombstone{}
+	for _, ts := range tombstones {
+		expires := time.Time{}
+		if retention > 0 {
+			expires = ts.Removed.Add(retention)
+		}
+
+		result = append(result, Tombstone{
+			Path:    ts.Path,
+			Removed: ts.Removed,
+			Owner:   ts.Owner,
+			IsDir:   ts.IsDir,
+			Expires: expires,
+		})
+	}
+
+	return result, nil
+}
+
+// liveBackendHashes returns the backend hashes of all files in the current tree.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) liveBackendHashes() (map[string]bool, error) {
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]bool)
+	err = n.Walk(fs.lkr, root, true, func(child n.Node) error {
+		if child.Type() == n.NodeTypeFile {
+			hashes[child.BackendHash().B58String()] = true
+		}
+
+		return nil
+	})
+
+	return hashes, err
+}
+
+// PurgeTombstones forgets all tombstones that are older than the configured
+// retention and unpins the content of their nodes, so it can be garbage
+// collected. Content that is still used by another file stays pinned.
+// It returns the number of purged tombstones.
+func (fs *FS) PurgeTombstones() (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	retention := fs.cfg.Duration("tombstones.retention")
+	if retention <= 0 {
+		return 0, nil
+	}
+
+	tombstones, err := fs.lkr.Tombstones()
+	if err != nil {
+		return 0, err
+	}
+
+	var liveHashes map[string]bool
+	purged := 0
+
+	for _, ts := range tombstones {
+		if time.Since(ts.Removed) < retention || fs.isHeld(ts.Path) {
+			continue
+		}
+
+		if liveHashes == nil {
+			if liveHashes, err = fs.liveBackendHashes(); err != nil {
+				return purged, err
+			}
+		}
+
+		nd, err := fs.lkr.LookupModNode(ts.Path)
+		if err != nil && !ie.IsNoSuchFileError(err) {
+			return purged, err
+		}
+
+		if ghost, ok := nd.(*n.Ghost); ok && ghost.OldNode().ContentHash().Equal(ts.ContentHash) {
+			err := n.Walk(fs.lkr, ghost.OldNode(), true, func(child n.Node) error {
+				if child.Type() != n.NodeTypeFile || liveHashes[child.BackendHash().B58String()] {
+					return nil
+				}
+
+				return fs.pinner.Unpin(child.Inode(), child.BackendHash(), true)
+			})
+
+			if err != nil {
+				return purged, err
+			}
+		}
+
+		if err := fs.lkr.RemoveTombstone(ts.Path); err != nil {
+			return purged, err
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (fs *FS) tombstoneLoop() {
+	if fs.readOnly {
+		return
+	}
+
+	checkTicker := time.NewTicker(1 * time.Hour)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-fs.tombstoneControl:
+			log.Debugf("quitting the tombstone loop")
+			return
+		case <-checkTicker.C:
+			purged, err := fs.PurgeTombstones()
+			if err != nil {
+				log.Warningf("failed to purge tombstones: %v", err)
+				continue
+			}
+
+			if purged > 0 {
+				log.Infof("purged %d expired tombstones", purged)
+			}
+		}
+	}
+}
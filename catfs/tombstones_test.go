@@ -0,0 +1,74 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTombstonesUndelete(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.MakeCommit("add x"))
+		require.Nil(t, fs.Remove("/x"))
+		require.Nil(t, fs.MakeCommit("remove x"))
+
+		tombstones, err := fs.Tombstones()
+		require.Nil(t, err)
+		require.Len(t, tombstones, 1)
+		require.Equal(t, "/x", tombstones[0].Path)
+		require.False(t, tombstones[0].IsDir)
+		require.True(t, tombstones[0].Expires.After(tombstones[0].Removed))
+
+		require.Nil(t, fs.Undelete("/x"))
+		tombstones, err = fs.Tombstones()
+		require.Nil(t, err)
+		require.Len(t, tombstones, 0)
+	})
+}
+
+func TestTombstonesPurge(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("world"))))
+		require.Nil(t, fs.Copy("/y", "/y-copy"))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		xInfo, err := fs.Stat("/x")
+		require.Nil(t, err)
+
+		yInfo, err := fs.Stat("/y")
+		require.Nil(t, err)
+
+		require.Nil(t, fs.Remove("/x"))
+		require.Nil(t, fs.Remove("/y"))
+		require.Nil(t, fs.MakeCommit("remove"))
+
+		// Nothing is old enough yet:
+		purged, err := fs.PurgeTombstones()
+		require.Nil(t, err)
+		require.Equal(t, 0, purged)
+
+		require.Nil(t, fs.cfg.SetString("tombstones.retention", "1ns"))
+		time.Sleep(time.Millisecond)
+
+		purged, err = fs.PurgeTombstones()
+		require.Nil(t, err)
+		require.Equal(t, 2, purged)
+
+		tombstones, err := fs.Tombstones()
+		require.Nil(t, err)
+		require.Len(t, tombstones, 0)
+
+		// The content of x is gone, y is still used by its copy:
+		isPinned, _, err := fs.pinner.IsPinned(xInfo.Inode, xInfo.BackendHash)
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		isPinned, _, err = fs.pinner.IsPinned(yInfo.Inode, yInfo.BackendHash)
+		require.Nil(t, err)
+		require.True(t, isPinned)
+	})
+}
@@ -0,0 +1,238 @@
+package catfs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// hasGlobMeta returns true if `pattern` contains any of the
+// special characters understood by path.Match.
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// Glob returns all paths matching the shell pattern `pattern`, sorted.
+// Every path element may be a pattern (see path.Match for the syntax),
+// a pattern without special characters only matches itself.
+// Ghosts never match. If nothing matches, an empty list is returned.
+func (fs *FS) Glob(pattern string) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.glob(pattern)
+}
+
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) glob(pattern string) ([]string, error) {
+	pattern = path.Clean(prefixSlash(pattern))
+	if !hasGlobMeta(pattern) {
+		if _, err := lookupFileOrDir(fs.lkr, fs.normPath(pattern)); err != nil {
+			if ie.IsNoSuchFileError(err) {
+				return []string{}, nil
+			}
+
+			return nil, err
+		}
+
+		return []string{fs.normPath(pattern)}, nil
+	}
+
+	// Validate the pattern once, path.Match only complains on a match attempt:
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, e.Wrapf(err, "bad pattern: %s", pattern)
+	}
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []n.Node{root}
+	for _, elem := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		next := []n.Node{}
+		for _, nd := range matches {
+			dir, ok := nd.(*n.Directory)
+			if !ok {
+				continue
+			}
+
+			for _, name := range dir.ChildNames() {
+				if ok, _ := path.Match(elem, name); !ok && name != elem {
+					continue
+				}
+
+				child, err := dir.Child(fs.lkr, name)
+				if err != nil {
+					return nil, err
+				}
+
+				if !isGone(child) {
+					next = append(next, child)
+				}
+			}
+		}
+
+		matches = next
+	}
+
+	paths := []string{}
+	for _, nd := range matches {
+		paths = append(paths, nd.Path())
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// TransferOptions control how MoveMany and CopyMany behave.
+type TransferOptions struct {
+	// Recursive allows copying directories.
+	// Directories are always moved with their contents.
+	Recursive bool
+}
+
+// MoveMany moves all paths matching `sources` (which may be globs) to `dst`.
+// See transfer for details.
+func (fs *FS) MoveMany(sources []string, dst string, opts TransferOptions) ([]string, error) {
+	return fs.transfer(sources, dst, false, opts)
+}
+
+// CopyMany copies all paths matching `sources` (which may be globs) to `dst`.
+// See transfer for details.
+func (fs *FS) CopyMany(sources []string, dst string, opts TransferOptions) ([]string, error) {
+	return fs.transfer(sources, dst, true, opts)
+}
+
+// transfer moves or copies all paths matching `sources` to `dst`.
+// If more than one path matches or `dst` ends with a slash, `dst` has to be
+// a directory and everything is put inside it. A trailing slash creates
+// `dst` if it does not exist yet. Either all paths are transferred or
+// none at all. The new paths are returned in the order of the sources.
+func (fs *FS) transfer(sources []string, dst string, copy bool, opts TransferOptions) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	srcPaths := []string{}
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		matches, err := fs.glob(source)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, ie.NoSuchFile(source)
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				srcPaths = append(srcPaths, match)
+			}
+		}
+	}
+
+	intoDir := strings.HasSuffix(dst, "/") || len(srcPaths) > 1
+	dst = fs.normPath(path.Clean(prefixSlash(dst)))
+
+	dstNd, err := fs.lkr.LookupNode(dst)
+	if err != nil && !ie.IsNoSuchFileError(err) {
+		return nil, err
+	}
+
+	dstExists := err == nil && !isGone(dstNd)
+	if dstExists && dstNd.Type() == n.NodeTypeDirectory {
+		intoDir = true
+	} else if dstExists && intoDir {
+		return nil, fmt.Errorf("%s is not a directory", dst)
+	}
+
+	newPaths := []string{}
+	err = fs.lkr.Atomic(func() (bool, error) {
+		if intoDir && !dstExists {
+			if _, err := c.Mkdir(fs.lkr, dst, true); err != nil {
+				return true, err
+			}
+		}
+
+		for _, src := range srcPaths {
+			srcNd, err := lookupFileOrDir(fs.lkr, src)
+			if err != nil {
+				return true, err
+			}
+
+			if copy && !opts.Recursive && srcNd.Type() == n.NodeTypeDirectory {
+				return true, fmt.Errorf("%s is a directory (use recursive mode)", src)
+			}
+
+			target := dst
+			if intoDir {
+				target = path.Join(dst, path.Base(src))
+			}
+
+			if copy {
+				if err := copyTree(fs.lkr, srcNd, target); err != nil {
+					return true, e.Wrapf(err, "copy %s", src)
+				}
+			} else {
+				if err := c.Move(fs.lkr, srcNd, target); err != nil {
+					return true, e.Wrapf(err, "move %s", src)
+				}
+			}
+
+			newPaths = append(newPaths, target)
+		}
+
+		return false, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaths, nil
+}
+
+// copyTree copies `nd` to `dst`. Directories are recreated at the new place
+// and filled with copies of the files, so the source nodes stay untouched.
+func copyTree(lkr *c.Linker, nd n.ModNode, dst string) error {
+	if nd.Type() != n.NodeTypeDirectory {
+		_, err := c.Copy(lkr, nd, dst)
+		return err
+	}
+
+	if strings.HasPrefix(dst+"/", nd.Path()+"/") {
+		return fmt.Errorf("cannot copy %s into itself", nd.Path())
+	}
+
+	srcRoot := nd.Path()
+	return n.Walk(lkr, nd, false, func(child n.Node) error {
+		childDst := path.Join(dst, strings.TrimPrefix(child.Path(), srcRoot))
+		switch child.Type() {
+		case n.NodeTypeDirectory:
+			_, err := c.Mkdir(lkr, childDst, true)
+			return err
+		case n.NodeTypeFile:
+			modChild, ok := child.(n.ModNode)
+			if !ok {
+				return ie.ErrBadNode
+			}
+
+			_, err := c.Copy(lkr, modChild, childDst)
+			return err
+		default:
+			return nil
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		for _, path := range []string{"/a.jpg", "/b.jpg", "/c.txt", "/sub/d.jpg", "/sub/e.txt"} {
+			require.Nil(t, fs.Stage(path, bytes.NewReader([]byte(path))))
+		}
+
+		matches, err := fs.Glob("*.jpg")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/a.jpg", "/b.jpg"}, matches)
+
+		matches, err = fs.Glob("/*/*.txt")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/sub/e.txt"}, matches)
+
+		matches, err = fs.Glob("/c.txt")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/c.txt"}, matches)
+
+		matches, err = fs.Glob("/nope*")
+		require.Nil(t, err)
+		require.Empty(t, matches)
+
+		_, err = fs.Glob("[")
+		require.NotNil(t, err)
+	})
+}
+
+func TestMoveMany(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		for _, path := range []string{"/a.jpg", "/b.jpg", "/c.txt"} {
+			require.Nil(t, fs.Stage(path, bytes.NewReader([]byte(path))))
+		}
+
+		// Several sources need a directory, which is created:
+		newPaths, err := fs.MoveMany([]string{"*.jpg", "/c.txt"}, "/photos", TransferOptions{})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/photos/a.jpg", "/photos/b.jpg", "/photos/c.txt"}, newPaths)
+
+		_, err = fs.Stat("/a.jpg")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		info, err := fs.Stat("/photos/b.jpg")
+		require.Nil(t, err)
+		require.Equal(t, "/photos/b.jpg", info.Path)
+
+		// A missing source should not move anything:
+		_, err = fs.MoveMany([]string{"/photos/a.jpg", "/missing"}, "/other/", TransferOptions{})
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		_, err = fs.Stat("/photos/a.jpg")
+		require.Nil(t, err)
+	})
+}
+
+func TestCopyMany(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/dir/a", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Stage("/b", bytes.NewReader([]byte{2})))
+
+		// Directories are only copied in recursive mode:
+		_, err := fs.CopyMany([]string{"/dir", "/b"}, "/backup/", TransferOptions{})
+		require.NotNil(t, err)
+
+		_, err = fs.Stat("/backup")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		newPaths, err := fs.CopyMany([]string{"/dir", "/b"}, "/backup/", TransferOptions{Recursive: true})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/backup/dir", "/backup/b"}, newPaths)
+
+		for _, path := range []string{"/dir/a", "/b", "/backup/dir/a", "/backup/b"} {
+			_, err := fs.Stat(path)
+			require.Nil(t, err, path)
+		}
+
+		// A single file to a new name works like Copy:
+		newPaths, err = fs.CopyMany([]string{"/b"}, "/c", TransferOptions{})
+		require.Nil(t, err)
+		require.Equal(t, []string{"/c"}, newPaths)
+	})
+}
@@ -0,0 +1,346 @@
+package catfs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+)
+
+// UndoAction tells what undoing does to a single path.
+type UndoAction string
+
+const (
+	// UndoRestore brings back a path that was removed.
+	UndoRestore = UndoAction("restore")
+	// UndoRemove removes a path that was added.
+	UndoRemove = UndoAction("remove")
+	// UndoRevert sets a path back to its earlier content.
+	UndoRevert = UndoAction("revert")
+)
+
+var (
+	// ErrHeadMoved is returned by Undo() when a commit was made since
+	// the changes were previewed.
+	ErrHeadMoved = errors.New("HEAD changed since the undo was previewed")
+)
+
+// UndoChange is a single path that is changed by undoing commits.
+// Directories that are restored or removed as a whole are listed
+// once; their children are not listed separately.
+type UndoChange struct {
+	Path   string
+	Action UndoAction
+	IsDir  bool
+	Size   uint64
+}
+
+// undoChange is an UndoChange with the node it was computed from.
+type undoChange struct {
+	UndoChange
+	nd n.Node
+}
+
+// lookupLive returns the node at `nodePath` below `root` or nil
+// if it does not exist there or is only a ghost.
+func lookupLive(lkr *c.Linker, root *n.Directory, nodePath string) (n.Node, error) {
+	nd, err := root.Lookup(lkr, nodePath)
+	if ie.IsNoSuchFileError(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nd.Type() == n.NodeTypeGhost {
+		return nil, nil
+	}
+
+	return nd, nil
+}
+
+func isDirNode(nd n.Node) bool {
+	return nd.Type() == n.NodeTypeDirectory
+}
+
+// undoChanges lists what changes when going from the tree at `currRoot`
+// back to the tree at `prevRoot`.
+func undoChanges(lkr *c.Linker, currRoot, prevRoot *n.Directory) ([]undoChange, error) {
+	changes := []undoChange{}
+	addChange := func(nd n.Node, action UndoAction) {
+		changes = append(changes, undoChange{
+			UndoChange: UndoChange{
+				Path:   nd.Path(),
+				Action: action,
+				IsDir:  isDirNode(nd),
+				Size:   nd.Size(),
+			},
+			nd: nd,
+		})
+	}
+
+	err := n.Walk(lkr, prevRoot, false, func(prevNd n.Node) error {
+		if prevNd.Type() == n.NodeTypeGhost || prevNd.Path() == "/" {
+			return nil
+		}
+
+		currNd, err := lookupLive(lkr, currRoot, prevNd.Path())
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case currNd == nil:
+			addChange(prevNd, UndoRestore)
+			return n.ErrSkipChild
+		case isDirNode(currNd) != isDirNode(prevNd):
+			addChange(prevNd, UndoRevert)
+			return n.ErrSkipChild
+		case !isDirNode(prevNd) && !currNd.ContentHash().Equal(prevNd.ContentHash()):
+			addChange(prevNd, UndoRevert)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = n.Walk(lkr, currRoot, false, func(currNd n.Node) error {
+		if currNd.Type() == n.NodeTypeGhost || currNd.Path() == "/" {
+			return nil
+		}
+
+		prevNd, err := lookupLive(lkr, prevRoot, currNd.Path())
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case prevNd == nil:
+			addChange(currNd, UndoRemove)
+			return n.ErrSkipChild
+		case isDirNode(currNd) != isDirNode(prevNd):
+			// Already listed as revert above.
+			return n.ErrSkipChild
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// undoTarget returns the HEAD commit and the commit `count` commits before it.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) undoTarget(count int) (*n.Commit, *n.Commit, error) {
+	if count < 1 {
+		return nil, nil, fmt.Errorf("need to undo at least one commit")
+	}
+
+	haveStaged, err := fs.lkr.HaveStagedChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if haveStaged {
+		return nil, nil, ie.ErrStageNotEmpty
+	}
+
+	head, err := fs.lkr.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := head
+	for idx := 0; idx < count; idx++ {
+		parent, err := target.Parent(fs.lkr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if parent == nil {
+			return nil, nil, fmt.Errorf("there are only %d commits that can be undone", idx)
+		}
+
+		parentCmt, ok := parent.(*n.Commit)
+		if !ok {
+			return nil, nil, ie.ErrBadNode
+		}
+
+		target = parentCmt
+	}
+
+	return head, target, nil
+}
+
+// undoChangesFor lists what undoing the last `count` commits would change.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) undoChangesFor(count int) (*n.Commit, *n.Commit, []undoChange, error) {
+	head, target, err := fs.undoTarget(count)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	currRoot, err := fs.lkr.DirectoryByHash(head.Root())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	prevRoot, err := fs.lkr.DirectoryByHash(target.Root())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	changes, err := undoChanges(fs.lkr, currRoot, prevRoot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return head, target, changes, nil
+}
+
+func publicUndoChanges(changes []undoChange) []UndoChange {
+	result := []UndoChange{}
+	for _, change := range changes {
+		result = append(result, change.UndoChange)
+	}
+
+	return result
+}
+
+// UndoPreview lists what Undo() would change when undoing the last `count`
+// commits. It also returns the hash of HEAD, which should be passed to Undo()
+// to make sure that nothing was committed in the meantime.
+func (fs *FS) UndoPreview(count int) ([]UndoChange, string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	head, _, changes, err := fs.undoChangesFor(count)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return publicUndoChanges(changes), head.TreeHash().B58String(), nil
+}
+
+func undoMessage(head *n.Commit, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("undo »%s«", head.Message())
+	}
+
+	return fmt.Sprintf("undo last %d commits", count)
+}
+
+// Undo reverts the last `count` commits by making a new commit that sets
+// the tree back to how it was before them. The history is not changed,
+// so the undo itself can be undone again. The staging area must be empty.
+// If `expectHead` is not empty, it has to match the current HEAD.
+func (fs *FS) Undo(count int, expectHead string, meta CommitMeta) ([]UndoChange, error) {
+	fs.mu.Lock()
+
+	if err := fs.checkWritable(); err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+
+	head, target, changes, err := fs.undoChangesFor(count)
+	if err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+
+	if expectHead != "" && head.TreeHash().B58String() != expectHead {
+		fs.mu.Unlock()
+		return nil, ErrHeadMoved
+	}
+
+	if len(changes) == 0 {
+		fs.mu.Unlock()
+		return nil, ie.ErrNoChange
+	}
+
+	msg := undoMessage(head, count)
+	if err := fs.undo(target, changes, msg, meta); err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+
+	hook := fs.commitHook
+	fs.mu.Unlock()
+
+	// Call the hook without the lock, so it may use the fs.
+	if hook != nil {
+		hook(msg)
+	}
+
+	return publicUndoChanges(changes), nil
+}
+
+// undo checks out `target` and commits it. All of it happens in one
+// transaction, so a failure on the way leaves the staging area as it was.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) undo(target *n.Commit, changes []undoChange, msg string, meta CommitMeta) error {
+	for _, change := range changes {
+		if change.Action == UndoRemove {
+			if err := fs.checkNotHeld(change.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	return fs.lkr.Atomic(func() (bool, error) {
+		if err := fs.lkr.CheckoutCommit(target, false); err != nil {
+			return true, err
+		}
+
+		// Restored paths should not be deleted again by the next sync,
+		// removed paths should be deleted on our peers too.
+		for _, change := range changes {
+			switch change.Action {
+			case UndoRestore:
+				if err := fs.lkr.RemoveTombstone(change.Path); err != nil {
+					return true, err
+				}
+
+				nd, err := fs.lkr.LookupModNode(change.Path)
+				if err != nil {
+					return true, err
+				}
+
+				if err := fs.pinner.PinNode(nd, false); err != nil {
+					return true, err
+				}
+			case UndoRemove:
+				if err := fs.lkr.AddTombstone(change.nd); err != nil {
+					return true, err
+				}
+			}
+		}
+
+		nmeta := n.CommitMeta{Origin: meta.Origin, Labels: meta.Labels}
+		if err := fs.lkr.MakeCommitWithMeta(owner, msg, nmeta); err != nil {
+			return true, err
+		}
+
+		return false, nil
+	})
+}
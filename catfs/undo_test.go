@@ -0,0 +1,137 @@
+package catfs
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoBulkDelete(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Mkdir("/photos", true))
+		require.Nil(t, fs.Stage("/photos/a.png", bytes.NewReader([]byte("a"))))
+		require.Nil(t, fs.Stage("/photos/b.png", bytes.NewReader([]byte("b"))))
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("x"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		require.Nil(t, fs.Remove("/photos"))
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("new x"))))
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("y"))))
+		require.Nil(t, fs.MakeCommit("oops"))
+
+		changes, head, err := fs.UndoPreview(1)
+		require.Nil(t, err)
+		require.Equal(t, []UndoChange{
+			{Path: "/photos", Action: UndoRestore, IsDir: true, Size: 2},
+			{Path: "/x", Action: UndoRevert, Size: 1},
+			{Path: "/y", Action: UndoRemove, Size: 1},
+		}, changes)
+
+		// Nothing was changed by the preview:
+		_, err = fs.Stat("/photos")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		_, err = fs.Undo(1, "not-the-head", CommitMeta{})
+		require.Equal(t, ErrHeadMoved, err)
+
+		_, err = fs.Undo(1, head, CommitMeta{Origin: CommitOriginCLI})
+		require.Nil(t, err)
+
+		info, err := fs.Stat("/photos/b.png")
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), info.Size)
+
+		_, err = fs.Stat("/y")
+		require.True(t, ie.IsNoSuchFileError(err))
+
+		stream, err := fs.Cat("/x")
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, "x", string(data))
+
+		tombstones, err := fs.Tombstones()
+		require.Nil(t, err)
+		require.Len(t, tombstones, 1)
+		require.Equal(t, "/y", tombstones[0].Path)
+
+		// The undo is a normal commit:
+		msgs := []string{}
+		require.Nil(t, fs.Log("head", func(cmt *Commit) error {
+			msgs = append(msgs, cmt.Msg)
+			return nil
+		}))
+		require.Equal(t, "undo »oops«", msgs[0])
+	})
+}
+
+func TestUndoErrors(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("x"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		_, _, err := fs.UndoPreview(0)
+		require.NotNil(t, err)
+
+		_, _, err = fs.UndoPreview(3)
+		require.NotNil(t, err)
+
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("y"))))
+		_, _, err = fs.UndoPreview(1)
+		require.Equal(t, ie.ErrStageNotEmpty, err)
+	})
+}
+
+func TestUndoFailureRollsBack(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Mkdir("/photos", true))
+		require.Nil(t, fs.Stage("/photos/a.png", bytes.NewReader([]byte("a"))))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		require.Nil(t, fs.Remove("/photos"))
+		require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte("y"))))
+		require.Nil(t, fs.MakeCommit("oops"))
+
+		headBefore, err := fs.Head()
+		require.Nil(t, err)
+
+		// Let the very last step, the commit, fail:
+		fs.SetCommitSigner(func(hash []byte) ([]byte, error) {
+			return nil, errors.New("no key")
+		})
+
+		_, err = fs.Undo(1, "", CommitMeta{})
+		require.NotNil(t, err)
+
+		// Nothing of the undo may be left over:
+		headAfter, err := fs.Head()
+		require.Nil(t, err)
+		require.Equal(t, headBefore, headAfter)
+
+		haveStaged, err := fs.HaveStagedChanges()
+		require.Nil(t, err)
+		require.False(t, haveStaged)
+
+		_, err = fs.Stat("/photos")
+		require.True(t, ie.IsNoSuchFileError(err))
+		_, err = fs.Stat("/y")
+		require.Nil(t, err)
+
+		tombstones, err := fs.Tombstones()
+		require.Nil(t, err)
+		require.Len(t, tombstones, 1)
+		require.Equal(t, "/photos", tombstones[0].Path)
+
+		// Once the commit works again, so does the undo:
+		fs.SetCommitSigner(nil)
+		_, err = fs.Undo(1, "", CommitMeta{})
+		require.Nil(t, err)
+
+		_, err = fs.Stat("/photos/a.png")
+		require.Nil(t, err)
+	})
+}
@@ -0,0 +1,420 @@
+package catfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// DirUsage is the space used by a single directory.
+type DirUsage struct {
+	// Path of the directory.
+	Path string
+	// Files is the number of files below the directory.
+	Files int
+	// LogicalSize is the sum of all file sizes below the directory.
+	LogicalSize uint64
+	// UniqueSize is like LogicalSize, but counts every content only once.
+	UniqueSize uint64
+}
+
+// SpaceUsage describes how much space a part of the filesystem uses.
+type SpaceUsage struct {
+	// Root is the path the usage was computed for.
+	Root string
+
+	// Files is the number of files below root.
+	Files int
+	// UniqueFiles is the number of distinct contents below root.
+	UniqueFiles int
+	// LogicalSize is the sum of all file sizes below root.
+	LogicalSize uint64
+	// UniqueSize is like LogicalSize, but counts every content only once.
+	// The difference to LogicalSize is what deduplication saves.
+	UniqueSize uint64
+
+	// StoredSize is how much the locally cached contents take up in the
+	// backend (i.e. after compression and encryption).
+	StoredSize uint64
+	// StoredLogicalSize is the unique size of the contents StoredSize
+	// was measured for. Contents that are not cached are not measured.
+	StoredLogicalSize uint64
+
+	// HistoryFiles is the number of distinct contents that are only
+	// referenced by old versions and not by the current tree.
+	HistoryFiles int
+	// HistorySize is the size of those contents; it is roughly what would
+	// be freed by pruning old versions.
+	HistorySize uint64
+
+	// Dirs is the usage of each direct sub directory of root.
+	Dirs []DirUsage
+}
+
+// CompressionRatio returns the ratio of stored size to original size.
+// A value of 0.5 means that the contents take up half the space.
+// If nothing could be measured, 1 is returned.
+func (su *SpaceUsage) CompressionRatio() float64 {
+	if su.StoredLogicalSize == 0 {
+		return 1
+	}
+
+	return float64(su.StoredSize) / float64(su.StoredLogicalSize)
+}
+
+// contentRef is an entry of the content reference table.
+type contentRef struct {
+	size    uint64
+	backend h.Hash
+	refs    int
+}
+
+// contentRefs counts how often every content is referenced by the files
+// below `root`. If `seen` is not nil, directories with a tree hash in it
+// are skipped and the visited directories are added.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) contentRefs(root n.Node, seen map[string]bool, fn func(file *n.File)) (map[string]*contentRef, error) {
+	refs := make(map[string]*contentRef)
+	err := n.Walk(fs.lkr, root, false, func(child n.Node) error {
+		switch child.Type() {
+		case n.NodeTypeDirectory:
+			if seen == nil {
+				return nil
+			}
+
+			treeHash := child.TreeHash().B58String()
+			if seen[treeHash] {
+				return n.ErrSkipChild
+			}
+
+			seen[treeHash] = true
+		case n.NodeTypeFile:
+			file, ok := child.(*n.File)
+			if !ok {
+				return ie.ErrBadNode
+			}
+
+			key := file.ContentHash().B58String()
+			ref, ok := refs[key]
+			if !ok {
+				ref = &contentRef{
+					size:    file.Size(),
+					backend: file.BackendHash(),
+				}
+
+				refs[key] = ref
+			}
+
+			ref.refs++
+			if fn != nil {
+				fn(file)
+			}
+		}
+
+		return nil
+	})
+
+	return refs, err
+}
+
+// SpaceUsage computes how much space is used below `root`.
+// Contents are identified by their content hash; files with the same
+// content are only counted once in the unique sizes.
+func (fs *FS) SpaceUsage(root string) (*SpaceUsage, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root = fs.normPath(root)
+
+	root = prefixSlash(root)
+	rootNd, err := lookupFileOrDir(fs.lkr, root)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &SpaceUsage{Root: root}
+	dirs := make(map[string]*DirUsage)
+	dirContents := make(map[string]map[string]bool)
+
+	current, err := fs.contentRefs(rootNd, nil, func(file *n.File) {
+		usage.Files++
+		usage.LogicalSize += file.Size()
+
+		rel := strings.TrimPrefix(file.Path(), rootNd.Path())
+		rel = strings.TrimPrefix(rel, "/")
+		idx := strings.Index(rel, "/")
+		if idx < 0 {
+			// Files directly in root are not part of a sub directory.
+			return
+		}
+
+		dirPath := path.Join(rootNd.Path(), rel[:idx])
+		dir, ok := dirs[dirPath]
+		if !ok {
+			dir = &DirUsage{Path: dirPath}
+			dirs[dirPath] = dir
+			dirContents[dirPath] = make(map[string]bool)
+		}
+
+		dir.Files++
+		dir.LogicalSize += file.Size()
+
+		key := file.ContentHash().B58String()
+		if !dirContents[dirPath][key] {
+			dirContents[dirPath][key] = true
+			dir.UniqueSize += file.Size()
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range current {
+		usage.UniqueFiles++
+		usage.UniqueSize += ref.size
+
+		storedSize, ok, err := fs.storedSize(ref.backend)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			usage.StoredSize += storedSize
+			usage.StoredLogicalSize += ref.size
+		}
+	}
+
+	for _, dir := range dirs {
+		usage.Dirs = append(usage.Dirs, *dir)
+	}
+
+	sort.Slice(usage.Dirs, func(i, j int) bool {
+		return usage.Dirs[i].Path < usage.Dirs[j].Path
+	})
+
+	if err := fs.historyUsage(root, current, usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// historyUsage adds up all contents below `root` that are referenced by
+// older commits, but not in `current`.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) historyUsage(root string, current map[string]*contentRef, usage *SpaceUsage) error {
+	head, err := fs.lkr.Head()
+	if err != nil {
+		return err
+	}
+
+	// Most of the tree stays the same between commits;
+	// every directory version needs to be visited only once.
+	seen := make(map[string]bool)
+	history := make(map[string]*contentRef)
+
+	for cmt := head; cmt != nil; {
+		nd, err := fs.lkr.LookupNodeAt(cmt, root)
+		if err != nil && !ie.IsNoSuchFileError(err) {
+			return err
+		}
+
+		if nd != nil && nd.Type() != n.NodeTypeGhost {
+			refs, err := fs.contentRefs(nd, seen, nil)
+			if err != nil {
+				return err
+			}
+
+			for key, ref := range refs {
+				if _, ok := current[key]; !ok {
+					history[key] = ref
+				}
+			}
+		}
+
+		parent, err := cmt.Parent(fs.lkr)
+		if err != nil {
+			return err
+		}
+
+		if parent == nil {
+			break
+		}
+
+		parentCmt, ok := parent.(*n.Commit)
+		if !ok {
+			return ie.ErrBadNode
+		}
+
+		cmt = parentCmt
+	}
+
+	for _, ref := range history {
+		usage.HistoryFiles++
+		usage.HistorySize += ref.size
+	}
+
+	return nil
+}
+
+// storedSize returns how many bytes `backendHash` takes up in the backend.
+// It only asks for contents that are cached; the bool is false otherwise
+// or if the backend cannot tell.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) storedSize(backendHash h.Hash) (uint64, bool, error) {
+	sizer, ok := fs.bk.(SizeBackend)
+	if !ok {
+		return 0, false, nil
+	}
+
+	isCached, err := fs.bk.IsCached(backendHash)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !isCached {
+		return 0, false, nil
+	}
+
+	size, err := sizer.StoredSize(backendHash)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return size, true, nil
+}
+
+// ChildUsage is the space used by a direct child of a directory.
+type ChildUsage struct {
+	// Path of the child.
+	Path string
+	// IsDir is true if the child is a directory.
+	IsDir bool
+	// Files is the number of files below the child (1 for files).
+	Files int
+	// LogicalSize is the sum of all file sizes below the child.
+	// It is the size stored in the directory node and not recomputed.
+	LogicalSize uint64
+	// UniqueSize is the size of the contents that are referenced by
+	// no file outside of the child, not even by older versions.
+	// It is roughly what removing the child and pruning the history frees.
+	UniqueSize uint64
+	// PinnedSize is the size of the pinned contents below the child.
+	// Every content is only counted once.
+	PinnedSize uint64
+}
+
+// childUsage computes the usage of a single node.
+// `refCounts` caches the result of ContentRefCount() between calls.
+// NOTE: This method assumes that fs.mu is locked.
+func (fs *FS) childUsage(nd n.Node, refCounts map[string]int) (*ChildUsage, error) {
+	usage := &ChildUsage{
+		Path:        nd.Path(),
+		IsDir:       nd.Type() == n.NodeTypeDirectory,
+		LogicalSize: nd.Size(),
+	}
+
+	// Distinct file nodes that reference a content, by backend hash:
+	localRefs := make(map[string]map[string]bool)
+	sizes := make(map[string]uint64)
+	pinned := make(map[string]bool)
+
+	err := n.Walk(fs.lkr, nd, false, func(child n.Node) error {
+		file, ok := child.(*n.File)
+		if !ok || child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		usage.Files++
+
+		key := file.BackendHash().B58String()
+		if _, ok := localRefs[key]; !ok {
+			localRefs[key] = make(map[string]bool)
+			sizes[key] = file.Size()
+		}
+
+		localRefs[key][file.TreeHash().B58String()] = true
+
+		isPinned, _, err := fs.pinner.IsPinned(file.Inode(), file.BackendHash())
+		if err != nil {
+			return err
+		}
+
+		if isPinned && !pinned[key] {
+			pinned[key] = true
+			usage.PinnedSize += file.Size()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, refs := range localRefs {
+		refCount, ok := refCounts[key]
+		if !ok {
+			backendHash, err := h.FromB58String(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if refCount, err = fs.lkr.ContentRefCount(backendHash); err != nil {
+				return nil, err
+			}
+
+			refCounts[key] = refCount
+		}
+
+		if len(refs) >= refCount {
+			usage.UniqueSize += sizes[key]
+		}
+	}
+
+	return usage, nil
+}
+
+// DiskUsage returns the usage of every direct child of `root`, like `du`.
+// Unlike SpaceUsage it does not walk the history or ask the backend,
+// but uses the directory sizes and the content references instead.
+// If `root` is a file, only the usage of the file is returned.
+func (fs *FS) DiskUsage(root string) ([]ChildUsage, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root = prefixSlash(fs.normPath(root))
+	rootNd, err := lookupFileOrDir(fs.lkr, root)
+	if err != nil {
+		return nil, err
+	}
+
+	children := []n.Node{rootNd}
+	if dir, ok := rootNd.(*n.Directory); ok {
+		if children, err = dir.ChildrenSorted(fs.lkr); err != nil {
+			return nil, err
+		}
+	}
+
+	refCounts := make(map[string]int)
+	usages := []ChildUsage{}
+	for _, child := range children {
+		if child.Type() == n.NodeTypeGhost {
+			continue
+		}
+
+		usage, err := fs.childUsage(child, refCounts)
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, *usage)
+	}
+
+	return usages, nil
+}
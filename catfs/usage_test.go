@@ -0,0 +1,98 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpaceUsage(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		dup := bytes.Repeat([]byte("hello world"), 100)
+		require.Nil(t, fs.Stage("/a/x", bytes.NewReader(dup)))
+		require.Nil(t, fs.Stage("/a/y", bytes.NewReader(dup)))
+		require.Nil(t, fs.Stage("/b/z", bytes.NewReader([]byte("old"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Nil(t, fs.Stage("/b/z", bytes.NewReader([]byte("new!"))))
+		require.Nil(t, fs.Stage("/top", bytes.NewReader([]byte("top"))))
+		require.Nil(t, fs.MakeCommit("second"))
+
+		usage, err := fs.SpaceUsage("/")
+		require.Nil(t, err)
+		require.Equal(t, "/", usage.Root)
+		require.Equal(t, 4, usage.Files)
+		require.Equal(t, 3, usage.UniqueFiles)
+		require.Equal(t, uint64(2*len(dup)+4+3), usage.LogicalSize)
+		require.Equal(t, uint64(len(dup)+4+3), usage.UniqueSize)
+
+		// Everything is cached in the mem backend:
+		require.Equal(t, usage.UniqueSize, usage.StoredLogicalSize)
+		require.True(t, usage.StoredSize > 0)
+
+		// Only the old version of /b/z is not referenced anymore:
+		require.Equal(t, 1, usage.HistoryFiles)
+		require.Equal(t, uint64(3), usage.HistorySize)
+
+		require.Equal(t, []DirUsage{
+			{Path: "/a", Files: 2, LogicalSize: uint64(2 * len(dup)), UniqueSize: uint64(len(dup))},
+			{Path: "/b", Files: 1, LogicalSize: 4, UniqueSize: 4},
+		}, usage.Dirs)
+
+		usage, err = fs.SpaceUsage("/b")
+		require.Nil(t, err)
+		require.Equal(t, 1, usage.Files)
+		require.Equal(t, uint64(3), usage.HistorySize)
+		require.Empty(t, usage.Dirs)
+	})
+}
+
+func TestDiskUsage(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		dup := bytes.Repeat([]byte("hello world"), 100)
+		require.Nil(t, fs.Stage("/a/x", bytes.NewReader(dup)))
+		require.Nil(t, fs.Stage("/a/y", bytes.NewReader(dup)))
+		require.Nil(t, fs.Stage("/b/z", bytes.NewReader([]byte("old"))))
+		require.Nil(t, fs.MakeCommit("first"))
+
+		require.Nil(t, fs.Stage("/b/z", bytes.NewReader([]byte("new!"))))
+		require.Nil(t, fs.Stage("/top", bytes.NewReader([]byte("top"))))
+		require.Nil(t, fs.Copy("/top", "/b/top"))
+		require.Nil(t, fs.Unpin("/b/z", "curr", true))
+		require.Nil(t, fs.MakeCommit("second"))
+
+		usages, err := fs.DiskUsage("/")
+		require.Nil(t, err)
+		require.Equal(t, []ChildUsage{
+			{
+				Path:        "/a",
+				IsDir:       true,
+				Files:       2,
+				LogicalSize: uint64(2 * len(dup)),
+				UniqueSize:  uint64(len(dup)),
+				PinnedSize:  uint64(len(dup)),
+			}, {
+				Path:        "/b",
+				IsDir:       true,
+				Files:       2,
+				LogicalSize: 7,
+				UniqueSize:  4,
+				// /b/z was unpinned and the copy was never pinned:
+				PinnedSize: 0,
+			}, {
+				// Shared with /b/top:
+				Path:        "/top",
+				Files:       1,
+				LogicalSize: 3,
+				PinnedSize:  3,
+			},
+		}, usages)
+
+		usages, err = fs.DiskUsage("/b/z")
+		require.Nil(t, err)
+		require.Equal(t, []ChildUsage{
+			{Path: "/b/z", Files: 1, LogicalSize: 4, UniqueSize: 4},
+		}, usages)
+	})
+}
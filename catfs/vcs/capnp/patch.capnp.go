@@ -201,12 +201,12 @@ type Patch struct{ capnp.Struct }
 const Patch_TypeID = 0x927c7336e3054805
 
 func NewPatch(s *capnp.Segment) (Patch, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2})
 	return Patch{st}, err
 }
 
 func NewRootPatch(s *capnp.Segment) (Patch, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2})
 	return Patch{st}, err
 }
 
@@ -261,12 +261,37 @@ func (s Patch) NewChanges(n int32) (Change_List, error) {
 	return l, err
 }
 
+func (s Patch) Dicts() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(1)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Patch) HasDicts() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s Patch) SetDicts(v capnp.DataList) error {
+	return s.Struct.SetPtr(1, v.List.ToPtr())
+}
+
+// NewDicts sets the dicts field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Patch) NewDicts(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(1, l.List.ToPtr())
+	return l, err
+}
+
 // Patch_List is a list of Patch.
 type Patch_List struct{ capnp.List }
 
 // NewPatch creates a new list of Patch.
 func NewPatch_List(s *capnp.Segment, sz int32) (Patch_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2}, sz)
 	return Patch_List{l}, err
 }
 
@@ -287,37 +312,38 @@ func (p Patch_Promise) Struct() (Patch, error) {
 	return Patch{s}, err
 }
 
-const schema_b943b54bf1683782 = "x\xda|\x90Ak\x13]\x18\x85\xcfy\xef\xcc\x17\xfa" +
-	"\x11M\xc6d!R\xe8luaZ\x0a\x0a\"h\x8d" +
-	"\x82\xa2\xc2\\QtU\x18'\x93L0\x99\x84\xcc4" +
-	"6\xa0\x04\xba\x11\x85\x82T\x05\x05\xc5\x0aU,T\xea" +
-	"F\xb0\x0b\x97\xfe\x05\xff@\x97\xae\x04W\xdd\x8c\xdc\xd4" +
-	"j\xd1\xe2\xee\xf2\xdc\x03/\xcf39\xcb\xd32eW" +
-	"l@\x9f\xb4\xff\xcb\xec\xf3\xf6\xe6\xb1\xe4\xce\x12\xf48" +
-	"%[8\x1e}\xbb\xf8\xa1\xba\x01\x9b9`\xfa\xae\x1c" +
-	"`iQr\xa5E\x99(m\xc8:\x98\xad\xdf\xbf\xf7" +
-	"}\xdf\xe4\xd2\x13\xb3\xe7\xae\xbdm\xf6Mu\x88\xa5\x81" +
-	"\xca\x95\x06jbzU]'\x1ef\x81\x9f\xd6\x93J" +
-	"?PI%\xf0\xbbq\xb7\xd2\xf5\xd3 ::z\x9f" +
-	"\xf0\xfc4`\xe4\x91\xda\xa2d\xb3\x8f^\xeaO_\x1e" +
-	"|\x86\xb6\x843\xe3d\x1ep\xb8\x95\x99U\xe4\x06\x1d" +
-	"\x89S\xbf\x19'\xae\xef&\xcd\xb8\xd1\x0a\xddSA\xe4" +
-	"\xc7\x8d\x10\xd0ye\x01\x16\x01\xe7\xdc\x15@\x9fU\xd4" +
-	"\x9e\xd0!\xcb4\xf0\xb2\x81\x97\x14\xf5\x0d!\xa5L\x01" +
-	"\x9ckg\x00\xed)\xea\x960\xab\xf7:\xed\x0bq-" +
-	"\x04\xe7iCh\x83Y0\xd7\xeb\xfd\xc1\x86\xdb\x07\x13" +
-	"\xee\x07=E\x16\x7f\xf7\x00\x0d\xfc\xb7n5\xf2c\xd5" +
-	"\x08\xf7\xf6uG\xbeS\xfc\x9fYut\xc5\xad\xa90" +
-	"\x09z\xcd\x9b\xe1.\xe5\x9f\xc6\xd4\x07\x7f\x19?;\x02" +
-	"\xe8\xc7\x8azY\xb8#\xfc\xc2\xb0\xa7\x8azE\xe8\x08" +
-	"\xb7\x8d_\x19\xf8\\Q\xbf\x15:J\xcaT\x80\xf3\xda" +
-	"\xc0eE\xbd&t,U\xa6\x058\xab\xa6\xcd\x8a\xa2" +
-	"~/tl\xabL\x1bp\xde-\x00zMQ\x7f\x14" +
-	"\x16\xda~r\x8bc\x10\x8e\x81\x85(\xf4k,f\x9b" +
-	"[\xf5\xee\xf0\xeb\xe17\xa6E\x11,\xc4\xe1|\xba\x07" +
-	"6]\xff\xc6\xc3v\xa7\x1f\xd6\xaev\x98\x870\x0ff" +
-	"\xb7\xfd\xc4\xeb\x85\xfd&;sIk0\x93b\xe7\xe7" +
-	"G\x00\x00\x00\xff\xff\x0b\xcf\xb1-"
+const schema_b943b54bf1683782 = "x\xda|\xd0?k\x14]\x1c\xc5\xf1s~w\xe6\x09" +
+	"yH\xcc\x8e\xbb\x85H \xd3j\xe1&\x06\x14D\xd0" +
+	"\x98F\xb1\xd9+\x82\x9dx\x9d\x9dd\x067\xb3\x9b\x9d" +
+	"\xc9\x1aAY\x09\x88\x7f  QA!b\x84(\x0a" +
+	"\x11m\x02\xa6\xb0\xdc\xb7\xe0\x1bHaa%h\x93f" +
+	"\xe4\xee\x9a\x184\xd8]\xbe\xbf\x033|FgyZ" +
+	"\xc6\xdc\xb2\x0b\xe8\x93\xee\x7f\xb9{\xd6\xdd<\x96\xde\\" +
+	"\x82\x1e\xa6\xe4\x0b\xc7\xa3o\xe7\xd7'7\xe0J\x1f0" +
+	"~K\xf6\xb3\xb8(}\xc5E\x19)n\xc8\x170\x7f" +
+	"\x7f\xff\xee\xf7\xc1\xd1\xa5'v\xcf]{\xd7\xee\xd7\xd5" +
+	"A\x16;\xaa\xaf\xd8Q#\xe3?\xd4%\xe2a\x1e\x98" +
+	"l*-\xb7\x02\x95\x96\x03\xd3H\x1a\xe5\x86\xc9\x82\xe8" +
+	"H\xf7}\xa2b\xb2\x80Q\x85\xd4\x0e%\xbf\xfc\xe8\x85" +
+	"\xfe\xf4\xf9A\x07\xda\x11N\x0c\x93\x03\x80\xc7\xad\xdc\xae" +
+	"\"?\xa8K\x92\x998I}\xe3\xa7q2]\x0b\xfd" +
+	"SAd\x92\xe9\x10\xd0\x05\xe5\x00\x0e\x01\xcf\\\x00\xf4" +
+	"\x15E]\x13zd\x896\xc66F\x8a:\x13RJ" +
+	"\x14\xc0\x9b=\x03\xe8\x9a\xa2\xbe'\xf4\x14KT\x80w" +
+	"\xe7(\xa0o+\xeaea>\xd5\xac\xcf\x9cK\xaa!" +
+	"8O\x17B\x17\xcc\x83\xb9f\xf3\x8f\xd6\xee\xfdE\xca" +
+	"}`E\x91\x85\xdfH\xa0\x8d#\xd58\xc8v\xce\x83" +
+	"\x10\xfb\xfc7\xccdd\x125\x1d\xee-\xe3we\xc6" +
+	"\xf8?\xf3\xc9\xee\xa7\xfd\xaa\x0a\xd3\xa0\x19_\x0dw\xe1" +
+	"\xfc\xb2\xa1>\xb0c\xf3\xec0\xa0\x1f+\xea\x15\xe16" +
+	"\xcds\xdb\x9e*\xeaU\xa1'\xec\xd9\xbc\xb4qYQ" +
+	"\xbf\xb16\xd2\xb3ye\xe3\x8a\xa2^\x13z\x8e*\xd1" +
+	"\x01\xbc\xb7VqUQ\x7f\x10z\xaeS\xa2\x0bx\xef" +
+	"\x16\x00\xbd\xa6\xa8?\x0a\x87fLz\x8d\xfd\x10\xf6\x83" +
+	"CQh\xaa,\xe4\x9b[S\x8d\xf6\xd7C\xaf\x01\xb2" +
+	"\x00\x0e%\xe1|\xb6G\xb6\xd8\x7f\xe7\xf6L\xbd\x15V" +
+	"/\xd69\x00\xe1\x00\x98_7i\xa5\x19\xb6b\xd6\xe7" +
+	"\xd2\xda\x8d\x89\x0c\xdb\x97\x9f\x03\x00\xadK\xb9\x1d"
 
 func init() {
 	schemas.Register(schema_b943b54bf1683782,
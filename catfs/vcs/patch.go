@@ -20,6 +20,10 @@ type Patch struct {
 	FromIndex int64
 	CurrIndex int64
 	Changes   []*Change
+
+	// Dicts are compression dictionaries that might be needed
+	// to read the content of the changed files.
+	Dicts [][]byte
 }
 
 // Len returns the number of changes in the patch.
@@ -107,6 +111,21 @@ func (p *Patch) ToCapnp() (*capnp.Message, error) {
 		}
 	}
 
+	capDicts, err := capnp.NewDataList(seg, int32(len(p.Dicts)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, dict := range p.Dicts {
+		if err := capDicts.Set(idx, dict); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := capPatch.SetDicts(capDicts); err != nil {
+		return nil, err
+	}
+
 	return msg, nil
 }
 
@@ -134,6 +153,20 @@ func (p *Patch) FromCapnp(msg *capnp.Message) error {
 		p.Changes = append(p.Changes, ch)
 	}
 
+	capDicts, err := capPatch.Dicts()
+	if err != nil {
+		return err
+	}
+
+	for idx := 0; idx < capDicts.Len(); idx++ {
+		dict, err := capDicts.At(idx)
+		if err != nil {
+			return err
+		}
+
+		p.Dicts = append(p.Dicts, dict)
+	}
+
 	return nil
 }
 
@@ -211,14 +244,33 @@ func MakePatch(lkr *c.Linker, from *n.Commit, prefixes []string) (*Patch, error)
 		return nil, err
 	}
 
+	return makePatch(lkr, root, from, status, prefixes)
+}
+
+// MakePatchRange is like MakePatch, but the patch ends with `to`
+// instead of the current state.
+func MakePatchRange(lkr *c.Linker, from, to *n.Commit, prefixes []string) (*Patch, error) {
+	if from.Index() > to.Index() {
+		return nil, e.Errorf("make-patch: %s is older than %s", to, from)
+	}
+
+	root, err := lkr.DirectoryByHash(to.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	return makePatch(lkr, root, from, to, prefixes)
+}
+
+func makePatch(lkr *c.Linker, root *n.Directory, from, to *n.Commit, prefixes []string) (*Patch, error) {
 	patch := &Patch{
 		FromIndex: from.Index(),
-		CurrIndex: status.Index(),
+		CurrIndex: to.Index(),
 	}
 
 	// Shortcut: The patch CURR..CURR would be empty.
 	// No need for further computations.
-	if from.TreeHash().Equal(status.TreeHash()) {
+	if from.TreeHash().Equal(to.TreeHash()) {
 		return patch, nil
 	}
 
@@ -229,20 +281,20 @@ func MakePatch(lkr *c.Linker, from *n.Commit, prefixes []string) (*Patch, error)
 	}
 	prefixTrie := buildPrefixTrie(prefixes)
 
-	err = n.Walk(lkr, root, false, func(child n.Node) error {
+	err := n.Walk(lkr, root, false, func(child n.Node) error {
 		childParentPath := path.Dir(child.Path())
 		if len(prefixes) != 0 && !hasValidPrefix(prefixTrie, childParentPath) {
 			log.Debugf("Ignoring invalid prefix: %s", childParentPath)
 			return nil
 		}
 
-		// Get all changes between status and `from`.
+		// Get all changes between `to` and `from`.
 		childModNode, ok := child.(n.ModNode)
 		if !ok {
 			return e.Wrapf(ie.ErrBadNode, "make-patch: walk")
 		}
 
-		changes, err := History(lkr, childModNode, status, from)
+		changes, err := History(lkr, childModNode, to, from)
 		if err != nil {
 			return err
 		}
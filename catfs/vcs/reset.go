@@ -121,34 +121,50 @@ func ResetNode(lkr *c.Linker, cmt *n.Commit, currPath string) (n.Node, error) {
 		return nil, err
 	}
 
+	oldModNode, ok := oldNode.(n.ModNode)
+	if oldNode != nil && !ok {
+		return nil, e.Wrapf(ie.ErrBadNode, "reset file")
+	}
+
+	return oldNode, ReplaceNode(lkr, currPath, oldModNode)
+}
+
+// ReplaceNode puts `nd` at `currPath`, whatever was there before.
+// If `nd` is nil, the node at `currPath` is only removed.
+func ReplaceNode(lkr *c.Linker, currPath string, nd n.ModNode) error {
 	// Make sure that all write related action happen in one go:
-	return oldNode, lkr.Atomic(func() (bool, error) {
+	return lkr.Atomic(func() (bool, error) {
 		// Remove the node that is present at the current path:
 		par, err := clearPath(lkr, currPath)
 		if err != nil {
 			return true, err
 		}
 
-		// old Node might not have yet existed back then.
-		// If so, simply do not re-add it.
-		if oldNode != nil {
-			oldModNode, ok := oldNode.(n.ModNode)
-			if !ok {
-				return true, e.Wrapf(ie.ErrBadNode, "reset file")
+		// Ghosts are added like core.Remove() does it; moving them
+		// would bring back the node they were made from.
+		if nd != nil && nd.Type() == n.NodeTypeGhost {
+			if err := par.Add(lkr, nd); err != nil {
+				return true, err
 			}
 
+			return false, lkr.StageNode(nd)
+		}
+
+		// old Node might not have yet existed back then.
+		// If so, simply do not re-add it.
+		if nd != nil {
 			// If the old node was at a different location,
 			// we need to modify its path.
-			oldModNode.SetName(path.Base(currPath))
-			if err := oldModNode.SetParent(lkr, par); err != nil {
+			nd.SetName(path.Base(currPath))
+			if err := nd.SetParent(lkr, par); err != nil {
 				return true, err
 			}
 
-			if err := oldModNode.NotifyMove(lkr, par, oldModNode.Path()); err != nil {
+			if err := nd.NotifyMove(lkr, par, nd.Path()); err != nil {
 				return true, err
 			}
 
-			if err := lkr.StageNode(oldNode); err != nil {
+			if err := lkr.StageNode(nd); err != nil {
 				return true, err
 			}
 		}
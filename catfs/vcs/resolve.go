@@ -345,6 +345,17 @@ func (rv *resolver) decide(pair MapPair) error {
 	}
 
 	if pair.Dst == nil {
+		// Do not bring back what was deleted on purpose:
+		ts, err := rv.lkrDst.Tombstone(pair.Src.Path())
+		if err != nil {
+			return err
+		}
+
+		if ts != nil && ts.Matches(pair.Src) {
+			debugf("%s was deleted here; not adding it again", pair.Src.Path())
+			return nil
+		}
+
 		return rv.exec.handleAdd(pair.Src)
 	}
 
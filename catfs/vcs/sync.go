@@ -70,6 +70,14 @@ type SyncOptions struct {
 	OnRemove   func(oldNd n.ModNode) bool
 	OnMerge    func(src, dst n.ModNode) bool
 	OnConflict func(src, dst n.ModNode) bool
+
+	// ResolveConflict may decide the strategy of a single conflict.
+	// If it returns ConflictStragetyUnknown, the configured strategies are used.
+	ResolveConflict func(src, dst n.ModNode) ConflictStrategy
+
+	// OnConflictResolved is called for every conflict with the strategy
+	// that is applied to it, no matter which one it is.
+	OnConflictResolved func(src, dst n.ModNode, cs ConflictStrategy)
 }
 
 var (
@@ -82,6 +90,21 @@ type syncer struct {
 	lkrDst *c.Linker
 }
 
+// removeGhost removes the ghost called `name` in `parentDir` (if any),
+// so a node with that name can be added again.
+func (sy *syncer) removeGhost(parentDir *n.Directory, name string) error {
+	child, err := parentDir.Child(sy.lkrDst, name)
+	if err != nil {
+		return err
+	}
+
+	if child == nil || child.Type() != n.NodeTypeGhost {
+		return nil
+	}
+
+	return parentDir.RemoveChild(sy.lkrDst, child)
+}
+
 func (sy *syncer) add(src n.ModNode, srcParent, srcName string) error {
 	var newDstNode n.ModNode
 	var err error
@@ -91,6 +114,10 @@ func (sy *syncer) add(src n.ModNode, srcParent, srcName string) error {
 		return err
 	}
 
+	if err := sy.removeGhost(parentDir, srcName); err != nil {
+		return err
+	}
+
 	switch src.Type() {
 	case n.NodeTypeDirectory:
 		newDstNode, err = n.NewEmptyDirectory(
@@ -145,6 +172,7 @@ func (sy *syncer) add(src n.ModNode, srcParent, srcName string) error {
 			newDstFile.SetBackend(sy.lkrDst, srcFile.BackendHash())
 			newDstFile.SetSize(srcFile.Size())
 			newDstFile.SetKey(srcFile.Key())
+			newDstFile.SetMimeType(srcFile.MimeType())
 		}
 
 		if err := parentDir.Add(sy.lkrDst, newDstFile); err != nil {
@@ -229,29 +257,100 @@ func (sy *syncer) handleRemove(dst n.ModNode) error {
 
 	log.Debugf("handling remove: %s", dst.Path())
 
+	ts, err := sy.lkrSrc.Tombstone(dst.Path())
+	if err != nil {
+		return err
+	}
+
+	return sy.remove(dst, ts)
+}
+
+// remove deletes `dst` and keeps a tombstone for it. If `ts` is not nil,
+// it is the tombstone of the remote and is copied, so the time and owner
+// of the original deletion are kept.
+func (sy *syncer) remove(dst n.ModNode, ts *c.Tombstone) error {
 	if sy.cfg.OnRemove != nil {
 		if !sy.cfg.OnRemove(dst) {
 			return nil
 		}
 	}
 
-	_, _, err := c.Remove(sy.lkrDst, dst, true, true)
-	return err
+	if _, _, err := c.Remove(sy.lkrDst, dst, true, true); err != nil {
+		return err
+	}
+
+	if ts == nil || !ts.Matches(dst) {
+		return sy.lkrDst.AddTombstone(dst)
+	}
+
+	return sy.lkrDst.PutTombstone(ts)
 }
 
-func (sy *syncer) getConflictStrategy(nd n.ModNode) ConflictStrategy {
-	curr := nd.Path()
+// applyTombstones deletes nodes that the remote knows to be deleted,
+// even if the remote never had the ghost of it (e.g. because it heard of
+// the deletion from a third peer). Tombstones are copied along, so
+// they reach peers that still have the node.
+func (sy *syncer) applyTombstones() error {
+	tombstones, err := sy.lkrSrc.Tombstones()
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range tombstones {
+		ownTs, err := sy.lkrDst.Tombstone(ts.Path)
+		if err != nil {
+			return err
+		}
+
+		if ownTs != nil && ownTs.ContentHash.Equal(ts.ContentHash) {
+			// We know already.
+			continue
+		}
+
+		dst, err := sy.lkrDst.LookupModNode(ts.Path)
+		if err != nil && !ie.IsNoSuchFileError(err) {
+			return err
+		}
 
+		if dst == nil || dst.Type() == n.NodeTypeGhost {
+			// Nothing to delete, but remember it for others:
+			if err := sy.lkrDst.PutTombstone(ts); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !ts.Matches(dst) {
+			// It was modified after the deletion; the modification wins.
+			continue
+		}
+
+		if sy.cfg.IgnoreDeletes || isReadOnly(sy.cfg.ReadOnlyFolders, dst.Path()) {
+			continue
+		}
+
+		log.Debugf("applying tombstone of %s", dst.Path())
+		if err := sy.remove(dst, ts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StrategyFor returns the configured conflict strategy for `nodePath`.
+// Strategies of folders take precedence over the general one.
+func (cfg *SyncOptions) StrategyFor(nodePath string) ConflictStrategy {
 	// Shortcurt: If the per-folder feature is not used,
 	// we can skip this whole loop below.
-	if len(sy.cfg.ConflictStrategyPerFolder) == 0 {
-		return sy.cfg.ConflictStrategy
+	if len(cfg.ConflictStrategyPerFolder) == 0 {
+		return cfg.ConflictStrategy
 	}
 
-	log.Debugf("*** MAP %v", sy.cfg.ConflictStrategyPerFolder)
-
+	curr := nodePath
 	for {
-		cs, ok := sy.cfg.ConflictStrategyPerFolder[curr]
+		cs, ok := cfg.ConflictStrategyPerFolder[curr]
 		if ok {
 			return cs
 		}
@@ -265,11 +364,27 @@ func (sy *syncer) getConflictStrategy(nd n.ModNode) ConflictStrategy {
 	}
 
 	// No special strategy found for this folder
-	return sy.cfg.ConflictStrategy
+	return cfg.ConflictStrategy
 }
 
 func (sy *syncer) handleConflict(src, dst n.ModNode, srcMask, dstMask ChangeType) error {
-	cs := sy.getConflictStrategy(dst)
+	cs := ConflictStrategy(ConflictStragetyUnknown)
+	if sy.cfg.ResolveConflict != nil {
+		cs = sy.cfg.ResolveConflict(src, dst)
+	}
+
+	if cs == ConflictStragetyUnknown {
+		cs = sy.cfg.StrategyFor(dst.Path())
+	}
+
+	if isReadOnly(sy.cfg.ReadOnlyFolders, src.Path(), dst.Path()) {
+		// Nothing may change there, whatever the strategy says.
+		cs = ConflictStragetyIgnore
+	}
+
+	if sy.cfg.OnConflictResolved != nil {
+		sy.cfg.OnConflictResolved(src, dst, cs)
+	}
 
 	if cs == ConflictStragetyIgnore {
 		return nil
@@ -362,6 +477,7 @@ func (sy *syncer) handleMerge(src, dst n.ModNode, srcMask, dstMask ChangeType) e
 	dstFile.SetBackend(sy.lkrDst, srcFile.BackendHash())
 	dstFile.SetSize(srcFile.Size())
 	dstFile.SetKey(srcFile.Key())
+	dstFile.SetMimeType(srcFile.MimeType())
 
 	if err := dstParent.Add(sy.lkrDst, dstFile); err != nil {
 		return err
@@ -417,6 +533,10 @@ func Sync(lkrSrc, lkrDst *c.Linker, cfg *SyncOptions) error {
 			return true, err
 		}
 
+		if err := syncer.applyTombstones(); err != nil {
+			return true, err
+		}
+
 		wasModified, err := lkrDst.HaveStagedChanges()
 		if err != nil {
 			return true, err
@@ -446,7 +566,8 @@ func Sync(lkrSrc, lkrDst *c.Linker, cfg *SyncOptions) error {
 				message = fmt.Sprintf("merge with »%s«", srcOwner)
 			}
 
-			if err := lkrDst.MakeCommit(srcOwner, message); err != nil {
+			meta := n.CommitMeta{Origin: n.CommitOriginSync}
+			if err := lkrDst.MakeCommitWithMeta(srcOwner, message, meta); err != nil {
 				return true, err
 			}
 		}
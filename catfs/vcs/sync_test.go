@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	c "github.com/sahib/brig/catfs/core"
+	n "github.com/sahib/brig/catfs/nodes"
 	h "github.com/sahib/brig/util/hashlib"
 	"github.com/stretchr/testify/require"
 )
@@ -344,6 +345,51 @@ func TestSyncConflictStrategyEmbrace(t *testing.T) {
 	})
 }
 
+func TestSyncConflictResolver(t *testing.T) {
+	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
+		c.MustTouchAndCommit(t, lkrSrc, "/x.png", 1)
+		c.MustTouchAndCommit(t, lkrDst, "/x.png", 2)
+
+		resolved := []ConflictStrategy{}
+		cfg := &SyncOptions{
+			ConflictStrategy: ConflictStragetyMarker,
+			ResolveConflict: func(src, dst n.ModNode) ConflictStrategy {
+				require.Equal(t, "/x.png", dst.Path())
+				return ConflictStragetyEmbrace
+			},
+			OnConflictResolved: func(src, dst n.ModNode, cs ConflictStrategy) {
+				resolved = append(resolved, cs)
+			},
+		}
+
+		require.Nil(t, Sync(lkrSrc, lkrDst, cfg))
+		require.Equal(t, []ConflictStrategy{ConflictStragetyEmbrace}, resolved)
+
+		// The resolver won over the marker strategy:
+		srcX, err := lkrSrc.LookupFile("/x.png")
+		require.Nil(t, err)
+		dstX, err := lkrDst.LookupFile("/x.png")
+		require.Nil(t, err)
+		require.Equal(t, srcX.ContentHash(), dstX.ContentHash())
+
+		_, err = lkrDst.LookupFile("/x.png.conflict.0")
+		require.NotNil(t, err)
+	})
+}
+
+func TestSyncStrategyFor(t *testing.T) {
+	cfg := &SyncOptions{
+		ConflictStrategy: ConflictStragetyMarker,
+		ConflictStrategyPerFolder: map[string]ConflictStrategy{
+			"/sub": ConflictStragetyIgnore,
+		},
+	}
+
+	require.Equal(t, ConflictStrategy(ConflictStragetyMarker), cfg.StrategyFor("/x"))
+	require.Equal(t, ConflictStrategy(ConflictStragetyIgnore), cfg.StrategyFor("/sub/x"))
+	require.Equal(t, ConflictStrategy(ConflictStragetyIgnore), cfg.StrategyFor("/sub"))
+}
+
 func TestSyncReadOnlyFolders(t *testing.T) {
 	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
 		// Create a file on alice' side:
@@ -380,3 +426,77 @@ func TestSyncReadOnlyFolders(t *testing.T) {
 		require.Equal(t, srcX.ContentHash(), h.TestDummy(t, byte(1)))
 	})
 }
+
+func TestSyncTombstones(t *testing.T) {
+	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
+		c.MustTouch(t, lkrSrc, "/x.png", 1)
+		c.MustCommit(t, lkrSrc, "add x")
+		require.Nil(t, Sync(lkrSrc, lkrDst, nil))
+
+		xFile, err := lkrDst.LookupFile("/x.png")
+		require.Nil(t, err)
+
+		// Delete it on our side; src still has it:
+		c.MustRemove(t, lkrDst, xFile)
+		require.Nil(t, lkrDst.AddTombstone(xFile))
+		c.MustCommit(t, lkrDst, "remove x")
+
+		require.Nil(t, Sync(lkrSrc, lkrDst, nil))
+		_, err = lkrDst.LookupFile("/x.png")
+		require.NotNil(t, err)
+
+		// A modified version on src should come back though:
+		srcFile, err := lkrSrc.LookupFile("/x.png")
+		require.Nil(t, err)
+		c.MustModify(t, lkrSrc, srcFile, 2)
+		c.MustCommit(t, lkrSrc, "modify x")
+
+		require.Nil(t, Sync(lkrSrc, lkrDst, nil))
+		_, err = lkrDst.LookupFile("/x.png")
+		require.Nil(t, err)
+
+		ts, err := lkrDst.Tombstone("/x.png")
+		require.Nil(t, err)
+		require.Nil(t, ts)
+	})
+}
+
+func TestSyncTombstonesPropagate(t *testing.T) {
+	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
+		c.WithDummyLinker(t, func(lkrThird *c.Linker) {
+			require.Nil(t, lkrThird.SetOwner("third"))
+
+			// src and third have the same file, dst never had it:
+			xFile := c.MustTouch(t, lkrSrc, "/x.png", 1)
+			c.MustCommit(t, lkrSrc, "add x")
+			c.MustTouch(t, lkrThird, "/x.png", 1)
+			c.MustCommit(t, lkrThird, "add x")
+
+			c.MustRemove(t, lkrSrc, xFile)
+			require.Nil(t, lkrSrc.AddTombstone(xFile))
+			c.MustCommit(t, lkrSrc, "remove x")
+
+			// dst only learns about the deletion:
+			require.Nil(t, Sync(lkrSrc, lkrDst, nil))
+			ts, err := lkrDst.Tombstone("/x.png")
+			require.Nil(t, err)
+			require.NotNil(t, ts)
+			require.Equal(t, "src", ts.Owner)
+
+			// ...and passes it on to third, which still has the file:
+			require.Nil(t, Sync(lkrDst, lkrThird, nil))
+			_, err = lkrThird.LookupFile("/x.png")
+			require.NotNil(t, err)
+
+			ts, err = lkrThird.Tombstone("/x.png")
+			require.Nil(t, err)
+			require.NotNil(t, ts)
+			require.Equal(t, "src", ts.Owner)
+
+			// third must not give it back to dst:
+			require.Nil(t, Sync(lkrThird, lkrDst, nil))
+			_, err = lkrDst.LookupFile("/x.png")
+			require.NotNil(t, err)
+		})
+	})
+}
@@ -2,10 +2,12 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 
 	"github.com/sahib/brig/server/capnp"
+	"github.com/sahib/brig/util/server"
 	"zombiezen.com/go/capnproto2/rpc"
 )
 
@@ -22,15 +24,53 @@ type Client struct {
 
 // Dial will attempt to connect to brigd under the specified port
 func Dial(ctx context.Context, port int) (*Client, error) {
-	addr := fmt.Sprintf("localhost:%d", port)
-	tcpConn, err := net.Dial("tcp", addr)
+	return DialWithOptions(ctx, DialOptions{
+		Network: "tcp",
+		Addr:    fmt.Sprintf("localhost:%d", port),
+	})
+}
+
+// DialOptions describe how to reach a daemon that does not
+// listen on the usual local tcp port.
+type DialOptions struct {
+	// Network is either "tcp" or "unix".
+	Network string
+
+	// Addr is host:port for tcp or the socket path for unix.
+	Addr string
+
+	// TLSConfig enables TLS if it is not nil.
+	TLSConfig *tls.Config
+
+	// Secret is sent to the daemon first if it is not empty.
+	Secret string
+}
+
+// DialWithOptions connects to brigd like described by `opts`.
+func DialWithOptions(ctx context.Context, opts DialOptions) (*Client, error) {
+	var tcpConn net.Conn
+	var err error
+
+	if opts.TLSConfig != nil {
+		tcpConn, err = tls.Dial(opts.Network, opts.Addr, opts.TLSConfig)
+	} else {
+		tcpConn, err = net.Dial(opts.Network, opts.Addr)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Secret != "" {
+		if err := server.ClientHandshake(tcpConn, opts.Secret); err != nil {
+			tcpConn.Close()
+			return nil, err
+		}
+	}
+
 	transport := rpc.StreamTransport(tcpConn)
 	clientConn := rpc.NewConn(transport, rpc.ConnLog(nil))
-	api := capnp.API{Client: clientConn.Bootstrap(ctx)}
+	api := capnp.API{Client: kindClient{clientConn.Bootstrap(ctx)}}
 
 	return &Client{
 		ctx:     ctx,
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/sahib/brig/util/errkind"
+	capnplib "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+)
+
+// kindClient wraps the bootstrap client of the daemon and gives every
+// error coming from it back the kind the daemon put in front of it.
+// Callers can then look at it with errkind.Of.
+type kindClient struct {
+	capnplib.Client
+}
+
+func (kc kindClient) Call(call *capnplib.Call) capnplib.Answer {
+	return kindAnswer{kc.Client.Call(call)}
+}
+
+// kindAnswer does the same as kindClient for a single answer.
+type kindAnswer struct {
+	capnplib.Answer
+}
+
+func (ka kindAnswer) Struct() (capnplib.Struct, error) {
+	st, err := ka.Answer.Struct()
+	return st, decodeErrorKind(err)
+}
+
+func (ka kindAnswer) PipelineCall(transform []capnplib.PipelineOp, call *capnplib.Call) capnplib.Answer {
+	return kindAnswer{ka.Answer.PipelineCall(transform, call)}
+}
+
+// decodeErrorKind takes the kind off the message of an error that was
+// returned by the daemon. See server/errors.go for the other side.
+func decodeErrorKind(err error) error {
+	merr, ok := err.(*capnplib.MethodError)
+	if !ok {
+		return err
+	}
+
+	exc, ok := merr.Err.(rpc.Exception)
+	if !ok {
+		return err
+	}
+
+	reason, rerr := exc.Reason()
+	if rerr != nil {
+		return err
+	}
+
+	kind, msg := errkind.Decode(reason)
+	if kind == errkind.Unknown {
+		return err
+	}
+
+	return errkind.Wrap(kind, &capnplib.MethodError{
+		Method: merr.Method,
+		Err:    errors.New("rpc exception: " + msg),
+	})
+}
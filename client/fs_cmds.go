@@ -27,6 +27,8 @@ type StatInfo struct {
 	TreeHash    h.Hash
 	ContentHash h.Hash
 	BackendHash h.Hash
+	MimeType    string
+	Kind        string
 }
 
 func convertHash(hashBytes []byte, err error) (h.Hash, error) {
@@ -74,6 +76,14 @@ func convertCapStatInfo(capInfo *capnp.StatInfo) (*StatInfo, error) {
 		return nil, err
 	}
 
+	if info.MimeType, err = capInfo.MimeType(); err != nil {
+		return nil, err
+	}
+
+	if info.Kind, err = capInfo.Kind(); err != nil {
+		return nil, err
+	}
+
 	info.Path = path
 	info.User = user
 	info.Size = capInfo.Size()
@@ -153,8 +163,20 @@ func (cl *Client) StageFromReader(repoPath string, r io.Reader) error {
 // Cat outputs the contents of the node at `path`.
 // The node must be a file.
 func (cl *Client) Cat(path string, offline bool) (io.ReadCloser, error) {
+	return cl.CatAt(path, "", offline)
+}
+
+// CatAt outputs the contents of `path` as it was at `at`, which
+// may be a rev or a timestamp like "2023-01-01". If `path` was a
+// directory back then, a tar archive is returned. An empty `at`
+// is the same as calling Cat.
+func (cl *Client) CatAt(path, at string, offline bool) (io.ReadCloser, error) {
 	call := cl.api.Cat(cl.ctx, func(p capnp.FS_cat_Params) error {
 		p.SetOffline(offline)
+		if err := p.SetAt(at); err != nil {
+			return err
+		}
+
 		return p.SetPath(path)
 	})
 
@@ -194,6 +216,35 @@ func (cl *Client) Tar(path string, offline bool) (io.ReadCloser, error) {
 	return conn, nil
 }
 
+// Archive outputs an archive in `format` ("tar" or "zip") with the
+// contents of `path` as they were in the commit `rev`.
+func (cl *Client) Archive(path, rev, format string) (io.ReadCloser, error) {
+	call := cl.api.Archive(cl.ctx, func(p capnp.FS_archive_Params) error {
+		if err := p.SetRev(rev); err != nil {
+			return err
+		}
+
+		if err := p.SetFormat(format); err != nil {
+			return err
+		}
+
+		return p.SetPath(path)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	port := result.Port()
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
 // Mkdir creates a new empty directory at `path`, possibly creating
 // intermediate directories if `createParents` is set.
 func (cl *Client) Mkdir(path string, createParents bool) error {
@@ -245,6 +296,127 @@ func (cl *Client) Copy(srcPath, dstPath string) error {
 	return err
 }
 
+// TransferOptions control Client.Transfer.
+type TransferOptions struct {
+	// Copy copies instead of moving.
+	Copy bool
+	// Recursive allows copying directories.
+	Recursive bool
+	// CommitMsg makes a commit with this message after the transfer if not empty.
+	CommitMsg string
+}
+
+// Transfer moves or copies everything matching `sources` (which may be globs)
+// to `dstPath`. If more than one path matches or `dstPath` ends with a slash,
+// everything is put into the directory `dstPath`. Either all or none of the
+// paths are transferred. The new paths are returned.
+func (cl *Client) Transfer(sources []string, dstPath string, opts TransferOptions) ([]string, error) {
+	call := cl.api.Transfer(cl.ctx, func(p capnp.FS_transfer_Params) error {
+		capSources, err := p.NewSources(int32(len(sources)))
+		if err != nil {
+			return err
+		}
+
+		for idx, source := range sources {
+			if err := capSources.Set(idx, source); err != nil {
+				return err
+			}
+		}
+
+		p.SetCopy(opts.Copy)
+		p.SetRecursive(opts.Recursive)
+		if err := p.SetCommitMsg(opts.CommitMsg); err != nil {
+			return err
+		}
+
+		return p.SetDstPath(dstPath)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capPaths, err := result.Paths()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for idx := 0; idx < capPaths.Len(); idx++ {
+		path, err := capPaths.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// SearchQuery describes what Search should look for.
+// See catfs.SearchQuery for the meaning of the fields.
+type SearchQuery struct {
+	Root   string
+	Text   string
+	Type   string
+	Offset int
+	Limit  int
+}
+
+// SearchResult is a single file found by Search.
+type SearchResult struct {
+	Info  StatInfo
+	Score float64
+}
+
+// Search finds files whose path contains all words of `query.Text`,
+// best matches first. It also returns the number of matches without paging.
+func (cl *Client) Search(query SearchQuery) ([]SearchResult, int, error) {
+	call := cl.api.Search(cl.ctx, func(p capnp.FS_search_Params) error {
+		p.SetOffset(int32(query.Offset))
+		p.SetLimit(int32(query.Limit))
+		if err := p.SetRoot(query.Root); err != nil {
+			return err
+		}
+
+		if err := p.SetType(query.Type); err != nil {
+			return err
+		}
+
+		return p.SetQuery(query.Text)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	capResults, err := result.Results()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := []SearchResult{}
+	for idx := 0; idx < capResults.Len(); idx++ {
+		capResult := capResults.At(idx)
+		capInfo, err := capResult.Info()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		info, err := convertCapStatInfo(&capInfo)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		results = append(results, SearchResult{Info: *info, Score: capResult.Score()})
+	}
+
+	return results, int(result.Total()), nil
+}
+
 // Pin sets an explicit pin on the node at `path`.
 func (cl *Client) Pin(path string) error {
 	call := cl.api.Pin(cl.ctx, func(p capnp.FS_pin_Params) error {
@@ -370,3 +542,349 @@ func (cl *Client) IsCached(path string) (bool, error) {
 
 	return result.IsCached(), nil
 }
+
+// DirUsage is the space used by a single directory.
+type DirUsage struct {
+	Path        string
+	Files       int64
+	LogicalSize uint64
+	UniqueSize  uint64
+}
+
+// SpaceUsage describes how much space is used below a directory.
+// See catfs.SpaceUsage for the meaning of the individual fields.
+type SpaceUsage struct {
+	Root              string
+	Files             int64
+	UniqueFiles       int64
+	LogicalSize       uint64
+	UniqueSize        uint64
+	StoredSize        uint64
+	StoredLogicalSize uint64
+	HistoryFiles      int64
+	HistorySize       uint64
+	Dirs              []DirUsage
+}
+
+// CompressionRatio returns the ratio of stored size to original size.
+func (su *SpaceUsage) CompressionRatio() float64 {
+	if su.StoredLogicalSize == 0 {
+		return 1
+	}
+
+	return float64(su.StoredSize) / float64(su.StoredLogicalSize)
+}
+
+// SpaceUsage returns space usage and deduplication statistics for `root`.
+func (cl *Client) SpaceUsage(root string) (*SpaceUsage, error) {
+	call := cl.api.SpaceUsage(cl.ctx, func(p capnp.FS_spaceUsage_Params) error {
+		return p.SetRoot(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capUsage, err := result.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	usageRoot, err := capUsage.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &SpaceUsage{
+		Root:              usageRoot,
+		Files:             capUsage.Files(),
+		UniqueFiles:       capUsage.UniqueFiles(),
+		LogicalSize:       capUsage.LogicalSize(),
+		UniqueSize:        capUsage.UniqueSize(),
+		StoredSize:        capUsage.StoredSize(),
+		StoredLogicalSize: capUsage.StoredLogicalSize(),
+		HistoryFiles:      capUsage.HistoryFiles(),
+		HistorySize:       capUsage.HistorySize(),
+	}
+
+	capDirs, err := capUsage.Dirs()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capDirs.Len(); idx++ {
+		capDir := capDirs.At(idx)
+		dirPath, err := capDir.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		usage.Dirs = append(usage.Dirs, DirUsage{
+			Path:        dirPath,
+			Files:       capDir.Files(),
+			LogicalSize: capDir.LogicalSize(),
+			UniqueSize:  capDir.UniqueSize(),
+		})
+	}
+
+	return usage, nil
+}
+
+// ChildUsage is the space used by a direct child of a directory.
+// See catfs.ChildUsage for the meaning of the individual fields.
+type ChildUsage struct {
+	Path        string
+	IsDir       bool
+	Files       int64
+	LogicalSize uint64
+	UniqueSize  uint64
+	PinnedSize  uint64
+}
+
+// DiskUsage returns the space used by every direct child of `root`.
+func (cl *Client) DiskUsage(root string) ([]ChildUsage, error) {
+	call := cl.api.DiskUsage(cl.ctx, func(p capnp.FS_diskUsage_Params) error {
+		return p.SetRoot(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capChildren, err := result.Children()
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []ChildUsage{}
+	for idx := 0; idx < capChildren.Len(); idx++ {
+		capChild := capChildren.At(idx)
+		childPath, err := capChild.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, ChildUsage{
+			Path:        childPath,
+			IsDir:       capChild.IsDir(),
+			Files:       capChild.Files(),
+			LogicalSize: capChild.LogicalSize(),
+			UniqueSize:  capChild.UniqueSize(),
+			PinnedSize:  capChild.PinnedSize(),
+		})
+	}
+
+	return usages, nil
+}
+
+// Selector selects file versions for PinSelection.
+// See catfs.Selector for the meaning of the individual fields.
+type Selector struct {
+	Root       string
+	Globs      []string
+	OlderThan  time.Time
+	LargerThan uint64
+	Commits    string
+}
+
+// SelectedVersion is a single file version that matched a Selector.
+// IsPinned and IsExplicit describe the state before the operation.
+type SelectedVersion struct {
+	Path        string
+	Commit      h.Hash
+	Size        uint64
+	ModTime     time.Time
+	Content     h.Hash
+	BackendHash h.Hash
+	IsPinned    bool
+	IsExplicit  bool
+}
+
+func selectorToCapnp(sel Selector, capSel capnp.Selector) error {
+	if err := capSel.SetRoot(sel.Root); err != nil {
+		return err
+	}
+
+	if err := capSel.SetCommits(sel.Commits); err != nil {
+		return err
+	}
+
+	if !sel.OlderThan.IsZero() {
+		if err := capSel.SetOlderThan(sel.OlderThan.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+
+	capSel.SetLargerThan(sel.LargerThan)
+
+	capGlobs, err := capSel.NewGlobs(int32(len(sel.Globs)))
+	if err != nil {
+		return err
+	}
+
+	for idx, glob := range sel.Globs {
+		if err := capGlobs.Set(idx, glob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func capnpToSelectedVersion(capVersion capnp.SelectedVersion) (*SelectedVersion, error) {
+	path, err := capVersion.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	modTimeStr, err := capVersion.ModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	modTime, err := time.Parse(time.RFC3339, modTimeStr)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &SelectedVersion{
+		Path:       path,
+		Size:       capVersion.Size(),
+		ModTime:    modTime,
+		IsPinned:   capVersion.IsPinned(),
+		IsExplicit: capVersion.IsExplicit(),
+	}
+
+	if version.Commit, err = convertHash(capVersion.Commit()); err != nil {
+		return nil, err
+	}
+
+	if version.Content, err = convertHash(capVersion.Content()); err != nil {
+		return nil, err
+	}
+
+	if version.BackendHash, err = convertHash(capVersion.BackendHash()); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// PinSelection pins (or unpins if `pin` is false) all file versions
+// matching `sel`. If `dryRun` is true, the versions are only returned.
+func (cl *Client) PinSelection(sel Selector, pin, dryRun bool) ([]*SelectedVersion, error) {
+	call := cl.api.PinSelection(cl.ctx, func(p capnp.FS_pinSelection_Params) error {
+		capSel, err := p.NewSelector()
+		if err != nil {
+			return err
+		}
+
+		p.SetPin(pin)
+		p.SetDryRun(dryRun)
+		return selectorToCapnp(sel, capSel)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capVersions, err := result.Versions()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []*SelectedVersion{}
+	for idx := 0; idx < capVersions.Len(); idx++ {
+		version, err := capnpToSelectedVersion(capVersions.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// Hold is a node that is kept from being removed or unpinned.
+type Hold struct {
+	Path    string
+	Created time.Time
+	Owner   string
+	Reason  string
+}
+
+// HoldAdd puts `path` under a retention hold.
+func (cl *Client) HoldAdd(path, reason string) error {
+	call := cl.api.HoldAdd(cl.ctx, func(p capnp.FS_holdAdd_Params) error {
+		if err := p.SetPath(path); err != nil {
+			return err
+		}
+
+		return p.SetReason(reason)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// HoldRemove lifts the retention hold of `path`.
+func (cl *Client) HoldRemove(path string) error {
+	call := cl.api.HoldRemove(cl.ctx, func(p capnp.FS_holdRemove_Params) error {
+		return p.SetPath(path)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// HoldList lists all retention holds.
+func (cl *Client) HoldList() ([]Hold, error) {
+	call := cl.api.HoldList(cl.ctx, func(p capnp.FS_holdList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capHolds, err := result.Holds()
+	if err != nil {
+		return nil, err
+	}
+
+	holds := []Hold{}
+	for idx := 0; idx < capHolds.Len(); idx++ {
+		capHold := capHolds.At(idx)
+		hold := Hold{}
+
+		if hold.Path, err = capHold.Path(); err != nil {
+			return nil, err
+		}
+
+		created, err := capHold.Created()
+		if err != nil {
+			return nil, err
+		}
+
+		if hold.Created, err = parseOptionalTime(created); err != nil {
+			return nil, err
+		}
+
+		if hold.Owner, err = capHold.Owner(); err != nil {
+			return nil, err
+		}
+
+		if hold.Reason, err = capHold.Reason(); err != nil {
+			return nil, err
+		}
+
+		holds = append(holds, hold)
+	}
+
+	return holds, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/sahib/brig/server/capnp"
+	h "github.com/sahib/brig/util/hashlib"
 	capnplib "zombiezen.com/go/capnproto2"
 )
 
@@ -28,6 +29,7 @@ type Remote struct {
 	AutoUpdate       bool           `yaml:"AutoUpdate"`
 	ConflictStrategy string         `yaml:"ConflictStrategy"`
 	AcceptPush       bool           `yaml:"AcceptPush"`
+	Trust            string         `yaml:"Trust"`
 }
 
 func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
@@ -51,6 +53,11 @@ func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
 		return nil, err
 	}
 
+	trust, err := capRemote.Trust()
+	if err != nil {
+		return nil, err
+	}
+
 	folders := []RemoteFolder{}
 	for idx := 0; idx < remoteFolders.Len(); idx++ {
 		folder := remoteFolders.At(idx)
@@ -78,6 +85,7 @@ func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
 		AutoUpdate:       capRemote.AcceptAutoUpdates(),
 		AcceptPush:       capRemote.AcceptPush(),
 		ConflictStrategy: conflictStrategy,
+		Trust:            trust,
 	}, nil
 }
 
@@ -99,6 +107,10 @@ func remoteToCapRemote(remote Remote, seg *capnplib.Segment) (*capnp.Remote, err
 		return nil, err
 	}
 
+	if err := capRemote.SetTrust(remote.Trust); err != nil {
+		return nil, err
+	}
+
 	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(remote.Folders)))
 	if err != nil {
 		return nil, err
@@ -418,6 +430,21 @@ func (cl *Client) Whoami() (*Whoami, error) {
 	return whoami, nil
 }
 
+// FingerprintRecord returns the signed record that can be published
+// to let others verify our fingerprint.
+func (cl *Client) FingerprintRecord() (string, error) {
+	call := cl.api.FingerprintRecord(cl.ctx, func(p capnp.Net_fingerprintRecord_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return "", err
+	}
+
+	return result.Record()
+}
+
 // NetConnect connects to the ipfs network.
 func (cl *Client) NetConnect() error {
 	_, err := cl.api.Connect(cl.ctx, func(p capnp.Net_connect_Params) error {
@@ -442,6 +469,56 @@ type RemoteStatus struct {
 	Roundtrip     time.Duration
 	Err           error
 	Authenticated bool
+	Queued        []QueuedOp
+}
+
+// QueuedOp is an operation that waits until the remote is online again.
+type QueuedOp struct {
+	Kind      string
+	Added     time.Time
+	Attempts  int
+	NextTry   time.Time
+	LastError string
+}
+
+func capQueuedOpToQueuedOp(capOp capnp.QueuedOp) (*QueuedOp, error) {
+	kind, err := capOp.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	lastError, err := capOp.LastError()
+	if err != nil {
+		return nil, err
+	}
+
+	op := &QueuedOp{
+		Kind:      kind,
+		Attempts:  int(capOp.Attempts()),
+		LastError: lastError,
+	}
+
+	added, err := capOp.Added()
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Added, err = time.Parse(time.RFC3339, added); err != nil {
+		return nil, err
+	}
+
+	nextTry, err := capOp.NextTry()
+	if err != nil {
+		return nil, err
+	}
+
+	if nextTry != "" {
+		if op.NextTry, err = time.Parse(time.RFC3339, nextTry); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
 }
 
 func capRemoteStatusToRemoteStatus(capStatus capnp.RemoteStatus) (*RemoteStatus, error) {
@@ -478,6 +555,21 @@ func capRemoteStatusToRemoteStatus(capStatus capnp.RemoteStatus) (*RemoteStatus,
 		pingErr = nil
 	}
 
+	capOps, err := capStatus.Queued()
+	if err != nil {
+		return nil, err
+	}
+
+	queued := []QueuedOp{}
+	for idx := 0; idx < capOps.Len(); idx++ {
+		op, err := capQueuedOpToQueuedOp(capOps.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		queued = append(queued, *op)
+	}
+
 	roundtripMs := time.Duration(capStatus.RoundtripMs()) * time.Millisecond
 	return &RemoteStatus{
 		Remote:        *remote,
@@ -485,6 +577,7 @@ func capRemoteStatusToRemoteStatus(capStatus capnp.RemoteStatus) (*RemoteStatus,
 		Roundtrip:     roundtripMs,
 		Err:           pingErr,
 		Authenticated: capStatus.Authenticated(),
+		Queued:        queued,
 	}, nil
 }
 
@@ -530,3 +623,629 @@ func (cl *Client) Push(remoteName string, dryRun bool) error {
 	_, err := call.Struct()
 	return err
 }
+
+// DiscoveredPeer is a daemon that was found on the local network.
+type DiscoveredPeer struct {
+	Owner       string
+	Fingerprint string
+	Addr        string
+	IP          string
+	LastSeen    time.Time
+	// RemoteName is the name of the remote with the same addr, if any.
+	RemoteName string
+}
+
+func capDiscoveredPeerToDiscoveredPeer(capPeer capnp.DiscoveredPeer) (*DiscoveredPeer, error) {
+	owner, err := capPeer.Owner()
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := capPeer.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := capPeer.Addr()
+	if err != nil {
+		return nil, err
+	}
+
+	ip, err := capPeer.Ip()
+	if err != nil {
+		return nil, err
+	}
+
+	lastSeenStamp, err := capPeer.LastSeen()
+	if err != nil {
+		return nil, err
+	}
+
+	lastSeen, err := time.Parse(time.RFC3339, lastSeenStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteName, err := capPeer.RemoteName()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiscoveredPeer{
+		Owner:       owner,
+		Fingerprint: fingerprint,
+		Addr:        addr,
+		IP:          ip,
+		LastSeen:    lastSeen,
+		RemoteName:  remoteName,
+	}, nil
+}
+
+// RemoteDiscover asks the local network for other daemons
+// and waits `timeout` for their answers.
+func (cl *Client) RemoteDiscover(timeout time.Duration) ([]DiscoveredPeer, error) {
+	call := cl.api.RemoteDiscover(cl.ctx, func(p capnp.Net_remoteDiscover_Params) error {
+		p.SetTimeoutMs(int64(timeout / time.Millisecond))
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capPeers, err := result.Peers()
+	if err != nil {
+		return nil, err
+	}
+
+	peers := []DiscoveredPeer{}
+	for idx := 0; idx < capPeers.Len(); idx++ {
+		peer, err := capDiscoveredPeerToDiscoveredPeer(capPeers.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		peers = append(peers, *peer)
+	}
+
+	return peers, nil
+}
+
+// RemoteHead is the latest commit a remote advertised,
+// along with the latest commit of it we know.
+type RemoteHead struct {
+	Name       string
+	Hash       string
+	Index      int64
+	Published  time.Time
+	KnownHash  string
+	KnownIndex int64
+}
+
+// IsNew returns true if the remote has commits we did not fetch yet.
+func (rh *RemoteHead) IsNew() bool {
+	return rh.Index > rh.KnownIndex ||
+		(rh.Index == rh.KnownIndex && rh.Hash != rh.KnownHash)
+}
+
+// RemoteHead fetches the head that `who` advertised and verifies its signature.
+// This works even if `who` is currently offline.
+func (cl *Client) RemoteHead(who string) (*RemoteHead, error) {
+	call := cl.api.RemoteHead(cl.ctx, func(p capnp.Net_remoteHead_Params) error {
+		return p.SetWho(who)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capHead, err := result.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := capHead.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := capHead.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	publishedStamp, err := capHead.Published()
+	if err != nil {
+		return nil, err
+	}
+
+	published, err := time.Parse(time.RFC3339, publishedStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	knownHash, err := capHead.KnownHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteHead{
+		Name:       name,
+		Hash:       hash,
+		Index:      capHead.Index(),
+		Published:  published,
+		KnownHash:  knownHash,
+		KnownIndex: capHead.KnownIndex(),
+	}, nil
+}
+
+// RemoteConfigEntry is a config key that can be overridden per remote.
+type RemoteConfigEntry struct {
+	Key   string
+	Value string
+	// Global is the value used for remotes without override.
+	Global     string
+	Overridden bool
+}
+
+// RemoteConfigSet overrides the config `key` with `value` for the remote `name`.
+// An empty `value` removes the override.
+func (cl *Client) RemoteConfigSet(name, key, value string) error {
+	call := cl.api.RemoteConfigSet(cl.ctx, func(p capnp.Net_remoteConfigSet_Params) error {
+		if err := p.SetName(name); err != nil {
+			return err
+		}
+
+		if err := p.SetKey(key); err != nil {
+			return err
+		}
+
+		return p.SetValue(value)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// RemoteConfigList returns all keys that can be overridden for the remote `name`.
+func (cl *Client) RemoteConfigList(name string) ([]RemoteConfigEntry, error) {
+	call := cl.api.RemoteConfigLs(cl.ctx, func(p capnp.Net_remoteConfigLs_Params) error {
+		return p.SetName(name)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capEntries, err := result.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []RemoteConfigEntry{}
+	for idx := 0; idx < capEntries.Len(); idx++ {
+		capEntry := capEntries.At(idx)
+
+		key, err := capEntry.Key()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := capEntry.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		global, err := capEntry.Global()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, RemoteConfigEntry{
+			Key:        key,
+			Value:      value,
+			Global:     global,
+			Overridden: capEntry.Overridden(),
+		})
+	}
+
+	return entries, nil
+}
+
+// RemoteBrowseEntry is a node of a remote's tree that it shares with us.
+type RemoteBrowseEntry struct {
+	Path        string
+	Size        uint64
+	IsDir       bool
+	ModTime     time.Time
+	ContentHash h.Hash
+	// Versions is the number of commits that modified a file.
+	Versions int
+	// Partial is set for directories that only lead to a shared folder.
+	Partial bool
+}
+
+// RemoteBrowse lists the directory `root` of the remote `name`.
+// The remote decides what we may see; this needs it to be online.
+func (cl *Client) RemoteBrowse(name, root string) ([]RemoteBrowseEntry, error) {
+	call := cl.api.RemoteBrowse(cl.ctx, func(p capnp.Net_remoteBrowse_Params) error {
+		if err := p.SetName(name); err != nil {
+			return err
+		}
+
+		return p.SetPath(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capEntries, err := result.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []RemoteBrowseEntry{}
+	for idx := 0; idx < capEntries.Len(); idx++ {
+		capEntry := capEntries.At(idx)
+
+		path, err := capEntry.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		modTimeStamp, err := capEntry.ModTime()
+		if err != nil {
+			return nil, err
+		}
+
+		modTime, err := time.Parse(time.RFC3339, modTimeStamp)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := RemoteBrowseEntry{
+			Path:     path,
+			Size:     capEntry.Size(),
+			IsDir:    capEntry.IsDir(),
+			ModTime:  modTime,
+			Versions: int(capEntry.Versions()),
+			Partial:  capEntry.Partial(),
+		}
+
+		// Directories that only lead to a shared folder have no hash.
+		if !entry.Partial {
+			entry.ContentHash, err = convertHash(capEntry.ContentHash())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ReplFile is a file of ours and the members of a remote group that store it.
+type ReplFile struct {
+	Path string
+	// Target is the replication target, like »/photos=2@backup«.
+	Target  string
+	Want    int
+	Holders []string
+}
+
+// ReplStatus tells how well our files are replicated.
+type ReplStatus struct {
+	// Files are the files that are stored by too few members,
+	// or all files under a target if that was requested.
+	Files []ReplFile
+	// Total is the number of files under any target.
+	Total int64
+	// Unreachable are members that could not be asked.
+	Unreachable []string
+}
+
+func stringsFromTextList(list capnplib.TextList) ([]string, error) {
+	result := []string{}
+	for idx := 0; idx < list.Len(); idx++ {
+		item, err := list.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// ReplStatus asks all members of our replication groups which of our files
+// they store. Only under-replicated files are returned, unless `all` is true.
+func (cl *Client) ReplStatus(all bool) (*ReplStatus, error) {
+	call := cl.api.ReplStatus(cl.ctx, func(p capnp.Net_replStatus_Params) error {
+		p.SetAll(all)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capStatus, err := result.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	capFiles, err := capStatus.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ReplStatus{Total: capStatus.Total()}
+	for idx := 0; idx < capFiles.Len(); idx++ {
+		capFile := capFiles.At(idx)
+
+		path, err := capFile.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		target, err := capFile.Target()
+		if err != nil {
+			return nil, err
+		}
+
+		capHolders, err := capFile.Holders()
+		if err != nil {
+			return nil, err
+		}
+
+		holders, err := stringsFromTextList(capHolders)
+		if err != nil {
+			return nil, err
+		}
+
+		status.Files = append(status.Files, ReplFile{
+			Path:    path,
+			Target:  target,
+			Want:    int(capFile.Want()),
+			Holders: holders,
+		})
+	}
+
+	capUnreachable, err := capStatus.Unreachable()
+	if err != nil {
+		return nil, err
+	}
+
+	status.Unreachable, err = stringsFromTextList(capUnreachable)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// SyncSummary sums up the past syncs with a single remote.
+type SyncSummary struct {
+	Remote    string
+	Syncs     int64
+	Failed    int64
+	Files     int64
+	Conflicts int64
+	Bytes     uint64
+	Duration  time.Duration
+	LastSync  time.Time
+}
+
+// ConflictRate is the share of changed files that were conflicts.
+func (ss SyncSummary) ConflictRate() float64 {
+	if ss.Files == 0 {
+		return 0
+	}
+
+	return float64(ss.Conflicts) / float64(ss.Files)
+}
+
+// AvgDuration is the average time a sync took.
+func (ss SyncSummary) AvgDuration() time.Duration {
+	if ss.Syncs == 0 {
+		return 0
+	}
+
+	return ss.Duration / time.Duration(ss.Syncs)
+}
+
+// SyncStats returns statistics about the syncs with `remote` (or all
+// remotes if empty) that were started after `since`. A zero `since`
+// means all remembered syncs.
+func (cl *Client) SyncStats(remote string, since time.Time) ([]SyncSummary, error) {
+	call := cl.api.SyncStats(cl.ctx, func(p capnp.Net_syncStats_Params) error {
+		if !since.IsZero() {
+			if err := p.SetSince(since.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+
+		return p.SetRemote(remote)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capSums, err := result.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	sums := []SyncSummary{}
+	for idx := 0; idx < capSums.Len(); idx++ {
+		capSum := capSums.At(idx)
+		sum := SyncSummary{
+			Syncs:     capSum.Syncs(),
+			Failed:    capSum.Failed(),
+			Files:     capSum.Files(),
+			Conflicts: capSum.Conflicts(),
+			Bytes:     capSum.Bytes(),
+			Duration:  time.Duration(capSum.DurationMs()) * time.Millisecond,
+		}
+
+		if sum.Remote, err = capSum.Remote(); err != nil {
+			return nil, err
+		}
+
+		lastSync, err := capSum.LastSync()
+		if err != nil {
+			return nil, err
+		}
+
+		if sum.LastSync, err = time.Parse(time.RFC3339, lastSync); err != nil {
+			return nil, err
+		}
+
+		sums = append(sums, sum)
+	}
+
+	return sums, nil
+}
+
+// Delegation allows `Holder` to read `Folder` of everyone that trusts
+// `Issuer` with it. See repo.Delegation.
+type Delegation struct {
+	Issuer  string
+	Holder  string
+	Folder  string
+	Issued  time.Time
+	Expires time.Time
+}
+
+func convertCapDelegation(capDg capnp.Delegation) (*Delegation, error) {
+	dg := &Delegation{}
+
+	var err error
+	if dg.Issuer, err = capDg.Issuer(); err != nil {
+		return nil, err
+	}
+
+	if dg.Holder, err = capDg.Holder(); err != nil {
+		return nil, err
+	}
+
+	if dg.Folder, err = capDg.Folder(); err != nil {
+		return nil, err
+	}
+
+	issued, err := capDg.Issued()
+	if err != nil {
+		return nil, err
+	}
+
+	if dg.Issued, err = time.Parse(time.RFC3339, issued); err != nil {
+		return nil, err
+	}
+
+	expires, err := capDg.Expires()
+	if err != nil {
+		return nil, err
+	}
+
+	if dg.Expires, err = time.Parse(time.RFC3339, expires); err != nil {
+		return nil, err
+	}
+
+	return dg, nil
+}
+
+// DelegationIssue creates a certificate that allows `holder` (a remote
+// name or a fingerprint) to read `folder` for `lifetime`.
+func (cl *Client) DelegationIssue(holder, folder string, lifetime time.Duration) ([]byte, error) {
+	call := cl.api.DelegationIssue(cl.ctx, func(p capnp.Net_delegationIssue_Params) error {
+		if err := p.SetHolder(holder); err != nil {
+			return err
+		}
+
+		if err := p.SetFolder(folder); err != nil {
+			return err
+		}
+
+		return p.SetLifetime(lifetime.String())
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Cert()
+}
+
+// DelegationAdd stores a certificate that was issued to us.
+func (cl *Client) DelegationAdd(cert []byte) (*Delegation, error) {
+	call := cl.api.DelegationAdd(cl.ctx, func(p capnp.Net_delegationAdd_Params) error {
+		return p.SetCert(cert)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDg, err := result.Delegation()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDelegation(capDg)
+}
+
+// DelegationList returns all certificates that were issued to us.
+func (cl *Client) DelegationList() ([]Delegation, error) {
+	call := cl.api.DelegationList(cl.ctx, func(p capnp.Net_delegationList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDgs, err := result.Delegations()
+	if err != nil {
+		return nil, err
+	}
+
+	dgs := []Delegation{}
+	for idx := 0; idx < capDgs.Len(); idx++ {
+		dg, err := convertCapDelegation(capDgs.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		dgs = append(dgs, *dg)
+	}
+
+	return dgs, nil
+}
+
+// DelegationRemove forgets the certificates of `issuer` for `folder`.
+func (cl *Client) DelegationRemove(issuer, folder string) error {
+	call := cl.api.DelegationRemove(cl.ctx, func(p capnp.Net_delegationRemove_Params) error {
+		if err := p.SetIssuer(issuer); err != nil {
+			return err
+		}
+
+		return p.SetFolder(folder)
+	})
+
+	_, err := call.Struct()
+	return err
+}
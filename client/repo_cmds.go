@@ -1,6 +1,10 @@
 package client
 
 import (
+	"fmt"
+	"net"
+	"time"
+
 	gwdb "github.com/sahib/brig/gateway/db"
 	"github.com/sahib/brig/server/capnp"
 	h "github.com/sahib/brig/util/hashlib"
@@ -118,6 +122,7 @@ type ConfigEntry struct {
 	Doc          string
 	Default      string
 	NeedsRestart bool
+	Type         string
 }
 
 func configEntryFromCapnp(capEntry capnp.ConfigEntry) (*ConfigEntry, error) {
@@ -141,12 +146,18 @@ func configEntryFromCapnp(capEntry capnp.ConfigEntry) (*ConfigEntry, error) {
 		return nil, err
 	}
 
+	typ, err := capEntry.Type()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ConfigEntry{
 		Default:      def,
 		Key:          key,
 		Val:          val,
 		Doc:          doc,
 		NeedsRestart: capEntry.NeedsRestart(),
+		Type:         typ,
 	}, nil
 }
 
@@ -441,17 +452,21 @@ func (ctl *Client) Become(who string) error {
 
 // GatewayUser is a user that has access to the gateway.
 type GatewayUser struct {
-	Name         string
-	PasswordHash string
-	Salt         string
-	Folders      []string
-	Rights       []string
+	Name          string
+	PasswordHash  string
+	Salt          string
+	Folders       []string
+	Rights        []string
+	Groups        []string
+	HashAlgorithm string
+	HashParams    string
 }
 
 // GatewayUserAdd adds a new user to the user database.
 // `folders` is a list of directories he may access. It might be empty,
 // in which case he can access everything (same as []string{"/"})
-func (ctl *Client) GatewayUserAdd(name, password string, folders, rights []string) error {
+// The user also gets all rights of `groups`.
+func (ctl *Client) GatewayUserAdd(name, password string, folders, rights, groups []string) error {
 	call := ctl.api.GatewayUserAdd(ctl.ctx, func(p capnp.Repo_gatewayUserAdd_Params) error {
 		if err := p.SetName(name); err != nil {
 			return err
@@ -492,7 +507,22 @@ func (ctl *Client) GatewayUserAdd(name, password string, folders, rights []strin
 			}
 		}
 
-		return p.SetRights(capRights)
+		if err := p.SetRights(capRights); err != nil {
+			return err
+		}
+
+		capGroups, err := capnplib.NewTextList(seg, int32(len(groups)))
+		if err != nil {
+			return err
+		}
+
+		for idx, group := range groups {
+			if err := capGroups.Set(idx, group); err != nil {
+				return err
+			}
+		}
+
+		return p.SetGroups(capGroups)
 	})
 
 	_, err := call.Struct()
@@ -512,7 +542,18 @@ func (ctl *Client) GatewayUserRemove(name string) error {
 
 // GatewayUserList lists all currently existing users.
 func (ctl *Client) GatewayUserList() ([]GatewayUser, error) {
+	return ctl.gatewayUserList(false)
+}
+
+// GatewayUserOutdated lists all users whose password hash is outdated.
+// They get a new hash on their next login.
+func (ctl *Client) GatewayUserOutdated() ([]GatewayUser, error) {
+	return ctl.gatewayUserList(true)
+}
+
+func (ctl *Client) gatewayUserList(outdatedOnly bool) ([]GatewayUser, error) {
 	call := ctl.api.GatewayUserList(ctl.ctx, func(p capnp.Repo_gatewayUserList_Params) error {
+		p.SetOutdatedOnly(outdatedOnly)
 		return nil
 	})
 
@@ -534,18 +575,113 @@ func (ctl *Client) GatewayUserList() ([]GatewayUser, error) {
 			return nil, err
 		}
 
+		hashParams := ""
+		if gwuser.HashAlgorithm() == gwdb.HashAlgoArgon2id {
+			hashParams = gwuser.HashParams().String()
+		}
+
 		users = append(users, GatewayUser{
-			Name:         gwuser.Name,
-			Salt:         gwuser.Salt,
-			PasswordHash: gwuser.PasswordHash,
-			Folders:      gwuser.Folders,
-			Rights:       gwuser.Rights,
+			Name:          gwuser.Name,
+			Salt:          gwuser.Salt,
+			PasswordHash:  gwuser.PasswordHash,
+			Folders:       gwuser.Folders,
+			Rights:        gwuser.Rights,
+			Groups:        gwuser.Groups,
+			HashAlgorithm: gwuser.HashAlgorithm(),
+			HashParams:    hashParams,
 		})
 	}
 
 	return users, err
 }
 
+// GatewayGroup is a named set of rights for gateway users.
+type GatewayGroup struct {
+	Name   string
+	Rights []string
+}
+
+// GatewayGroupAdd adds or replaces the group `name` with `rights`.
+func (ctl *Client) GatewayGroupAdd(name string, rights []string) error {
+	call := ctl.api.GatewayGroupAdd(ctl.ctx, func(p capnp.Repo_gatewayGroupAdd_Params) error {
+		if err := p.SetName(name); err != nil {
+			return err
+		}
+
+		capRights, err := capnplib.NewTextList(p.Segment(), int32(len(rights)))
+		if err != nil {
+			return err
+		}
+
+		for idx, right := range rights {
+			if err := capRights.Set(idx, right); err != nil {
+				return err
+			}
+		}
+
+		return p.SetRights(capRights)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// GatewayGroupRemove removes an existing group.
+// Its members lose the rights they got from it.
+func (ctl *Client) GatewayGroupRemove(name string) error {
+	call := ctl.api.GatewayGroupRm(ctl.ctx, func(p capnp.Repo_gatewayGroupRm_Params) error {
+		return p.SetName(name)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// GatewayGroupList lists all groups.
+func (ctl *Client) GatewayGroupList() ([]GatewayGroup, error) {
+	call := ctl.api.GatewayGroupList(ctl.ctx, func(p capnp.Repo_gatewayGroupList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capGroups, err := result.Groups()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []GatewayGroup{}
+	for idx := 0; idx < capGroups.Len(); idx++ {
+		capGroup := capGroups.At(idx)
+		name, err := capGroup.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		capRights, err := capGroup.Rights()
+		if err != nil {
+			return nil, err
+		}
+
+		rights := []string{}
+		for rightIdx := 0; rightIdx < capRights.Len(); rightIdx++ {
+			right, err := capRights.At(rightIdx)
+			if err != nil {
+				return nil, err
+			}
+
+			rights = append(rights, right)
+		}
+
+		groups = append(groups, GatewayGroup{Name: name, Rights: rights})
+	}
+
+	return groups, nil
+}
+
 // DebugProfilePort will get the port of pprof server in the backend.
 // The port changes during daemon restarts.
 func (ctl *Client) DebugProfilePort() (int, error) {
@@ -560,3 +696,837 @@ func (ctl *Client) DebugProfilePort() (int, error) {
 
 	return int(result.Port()), nil
 }
+
+// DebugProfile captures a profile of `kind` in the daemon and returns it.
+// Timed profiles like "cpu" or "trace" run for `seconds`.
+func (ctl *Client) DebugProfile(kind string, seconds int) ([]byte, error) {
+	call := ctl.api.DebugProfile(ctl.ctx, func(p capnp.Repo_debugProfile_Params) error {
+		p.SetSeconds(int32(seconds))
+		return p.SetKind(kind)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data()
+}
+
+// Subkey is a rotating key that is certified by the identity key.
+type Subkey struct {
+	ID      string
+	Usage   string
+	Created time.Time
+	Expires time.Time
+	Current bool
+}
+
+func capSubkeyToSubkey(capSubkey capnp.Subkey) (*Subkey, error) {
+	id, err := capSubkey.Id()
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := capSubkey.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	createdStamp, err := capSubkey.Created()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := time.Parse(time.RFC3339, createdStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresStamp, err := capSubkey.Expires()
+	if err != nil {
+		return nil, err
+	}
+
+	expires, err := time.Parse(time.RFC3339, expiresStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subkey{
+		ID:      id,
+		Usage:   usage,
+		Created: created,
+		Expires: expires,
+		Current: capSubkey.Current(),
+	}, nil
+}
+
+// SubkeyList lists all subkeys of the repository, including expired ones.
+func (ctl *Client) SubkeyList() ([]Subkey, error) {
+	call := ctl.api.SubkeyList(ctl.ctx, func(p capnp.Repo_subkeyList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capSubkeys, err := result.Subkeys()
+	if err != nil {
+		return nil, err
+	}
+
+	subkeys := []Subkey{}
+	for idx := 0; idx < capSubkeys.Len(); idx++ {
+		subkey, err := capSubkeyToSubkey(capSubkeys.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		subkeys = append(subkeys, *subkey)
+	}
+
+	return subkeys, nil
+}
+
+// SubkeyRotate creates a new subkey for `usage` ("session" or "signing").
+// If `lifetime` is empty, the configured lifetime is used.
+func (ctl *Client) SubkeyRotate(usage, lifetime string) (*Subkey, error) {
+	call := ctl.api.SubkeyRotate(ctl.ctx, func(p capnp.Repo_subkeyRotate_Params) error {
+		if err := p.SetUsage(usage); err != nil {
+			return err
+		}
+
+		return p.SetLifetime(lifetime)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capSubkey, err := result.Subkey()
+	if err != nil {
+		return nil, err
+	}
+
+	return capSubkeyToSubkey(capSubkey)
+}
+
+// BusEvent is an event of the local event bus of the daemon.
+type BusEvent struct {
+	Seq     uint64    `json:"seq"`
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Path    string    `json:"path,omitempty"`
+	Remote  string    `json:"remote,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Done    int64     `json:"done,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+}
+
+func capBusEventToBusEvent(capEv capnp.BusEvent) (*BusEvent, error) {
+	kind, err := capEv.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	stamp, err := capEv.Time()
+	if err != nil {
+		return nil, err
+	}
+
+	evTime, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := capEv.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := capEv.Remote()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := capEv.Message()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BusEvent{
+		Seq:     capEv.Seq(),
+		Kind:    kind,
+		Time:    evTime,
+		Path:    path,
+		Remote:  remote,
+		Message: msg,
+		Done:    capEv.Done(),
+		Total:   capEv.Total(),
+	}, nil
+}
+
+// EventsWait returns events of the kinds in `kinds` (all if empty) that
+// happened after `since`. If there are none yet, it waits up to `timeout`.
+// If `since` is zero, the daemon continues after the last event that
+// `consumer` acknowledged (or after the current event for new consumers).
+// The returned sequence number should be passed as `since` on the next call.
+func (ctl *Client) EventsWait(consumer string, since uint64, timeout time.Duration, kinds []string) ([]BusEvent, uint64, error) {
+	call := ctl.api.EventsWait(ctl.ctx, func(p capnp.Repo_eventsWait_Params) error {
+		p.SetSince(since)
+		p.SetTimeoutMs(int64(timeout / time.Millisecond))
+
+		capKinds, err := capnplib.NewTextList(p.Segment(), int32(len(kinds)))
+		if err != nil {
+			return err
+		}
+
+		for idx, kind := range kinds {
+			if err := capKinds.Set(idx, kind); err != nil {
+				return err
+			}
+		}
+
+		if err := p.SetKinds(capKinds); err != nil {
+			return err
+		}
+
+		return p.SetConsumer(consumer)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	capEvs, err := result.Events()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	evs := []BusEvent{}
+	for idx := 0; idx < capEvs.Len(); idx++ {
+		ev, err := capBusEventToBusEvent(capEvs.At(idx))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		evs = append(evs, *ev)
+	}
+
+	return evs, result.Seq(), nil
+}
+
+// EventsAck tells the daemon that `consumer` handled all events up to `seq`.
+func (ctl *Client) EventsAck(consumer string, seq uint64) error {
+	call := ctl.api.EventsAck(ctl.ctx, func(p capnp.Repo_eventsAck_Params) error {
+		p.SetSeq(seq)
+		return p.SetConsumer(consumer)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// ScrubCorruption is a pinned file whose content did not verify.
+type ScrubCorruption struct {
+	Path        string
+	BackendHash h.Hash
+	Err         string
+	Repaired    bool
+}
+
+// ScrubStatus describes the current or last run of the scrubber.
+type ScrubStatus struct {
+	Running   bool
+	LastStart time.Time
+	LastEnd   time.Time
+	Checked   int64
+	Bytes     uint64
+	Corrupt   []ScrubCorruption
+	Err       string
+}
+
+// DaemonStatus is the state of the background jobs of the daemon.
+type DaemonStatus struct {
+	Scrub ScrubStatus
+}
+
+func parseOptionalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func convertCapScrubStatus(capStatus capnp.ScrubStatus) (*ScrubStatus, error) {
+	status := &ScrubStatus{
+		Running: capStatus.Running(),
+		Checked: capStatus.Checked(),
+		Bytes:   capStatus.Bytes(),
+	}
+
+	lastStart, err := capStatus.LastStart()
+	if err != nil {
+		return nil, err
+	}
+
+	if status.LastStart, err = parseOptionalTime(lastStart); err != nil {
+		return nil, err
+	}
+
+	lastEnd, err := capStatus.LastEnd()
+	if err != nil {
+		return nil, err
+	}
+
+	if status.LastEnd, err = parseOptionalTime(lastEnd); err != nil {
+		return nil, err
+	}
+
+	status.Err, err = capStatus.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	capCorrupt, err := capStatus.Corrupt()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capCorrupt.Len(); idx++ {
+		capCorruption := capCorrupt.At(idx)
+		corruption := ScrubCorruption{Repaired: capCorruption.Repaired()}
+
+		corruption.Path, err = capCorruption.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		corruption.BackendHash, err = capCorruption.BackendHash()
+		if err != nil {
+			return nil, err
+		}
+
+		corruption.Err, err = capCorruption.Error()
+		if err != nil {
+			return nil, err
+		}
+
+		status.Corrupt = append(status.Corrupt, corruption)
+	}
+
+	return status, nil
+}
+
+// DaemonStatus returns the state of the background jobs of the daemon.
+func (ctl *Client) DaemonStatus() (*DaemonStatus, error) {
+	call := ctl.api.DaemonStatus(ctl.ctx, func(p capnp.Repo_daemonStatus_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capStatus, err := result.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	capScrub, err := capStatus.Scrub()
+	if err != nil {
+		return nil, err
+	}
+
+	scrub, err := convertCapScrubStatus(capScrub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DaemonStatus{Scrub: *scrub}, nil
+}
+
+// FsckProblem is a pinned file whose content is missing or corrupt.
+type FsckProblem struct {
+	Path        string
+	BackendHash h.Hash
+	Missing     bool
+	Err         string
+	Repaired    bool
+	Unpinned    bool
+}
+
+// FsckReport is the outcome of an integrity check.
+type FsckReport struct {
+	Nodes    int64
+	Checked  int64
+	Bytes    uint64
+	Problems []FsckProblem
+}
+
+// Fsck checks the metadata of the current tree and, if `content` is set,
+// the content of all pinned files. Broken content is fetched again if
+// `refetch` is set; if that does not help, it is unpinned if `unpin` is set.
+func (ctl *Client) Fsck(content, refetch, unpin bool) (*FsckReport, error) {
+	call := ctl.api.Fsck(ctl.ctx, func(p capnp.Repo_fsck_Params) error {
+		p.SetContent(content)
+		p.SetRefetch(refetch)
+		p.SetUnpin(unpin)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capReport, err := result.Report()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FsckReport{
+		Nodes:    capReport.Nodes(),
+		Checked:  capReport.Checked(),
+		Bytes:    capReport.Bytes(),
+		Problems: []FsckProblem{},
+	}
+
+	capProblems, err := capReport.Problems()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capProblems.Len(); idx++ {
+		capProblem := capProblems.At(idx)
+		problem := FsckProblem{
+			Missing:  capProblem.Missing(),
+			Repaired: capProblem.Repaired(),
+			Unpinned: capProblem.Unpinned(),
+		}
+
+		problem.Path, err = capProblem.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		problem.BackendHash, err = capProblem.BackendHash()
+		if err != nil {
+			return nil, err
+		}
+
+		problem.Err, err = capProblem.Error()
+		if err != nil {
+			return nil, err
+		}
+
+		report.Problems = append(report.Problems, problem)
+	}
+
+	return report, nil
+}
+
+// OpStats tells how often the daemon served a certain call.
+type OpStats struct {
+	Name   string `json:"name"`
+	Calls  uint64 `json:"calls"`
+	Errors uint64 `json:"errors"`
+}
+
+// RemoteStats tells what was synced with a certain remote.
+type RemoteStats struct {
+	Name  string `json:"name"`
+	Syncs uint64 `json:"syncs"`
+	Bytes int64  `json:"bytes"`
+}
+
+// DaemonStats are local usage statistics of the daemon.
+type DaemonStats struct {
+	Started     time.Time     `json:"started"`
+	Since       time.Time     `json:"since"`
+	Ops         []OpStats     `json:"ops"`
+	Remotes     []RemoteStats `json:"remotes"`
+	CacheHits   uint64        `json:"cache_hits"`
+	CacheMisses uint64        `json:"cache_misses"`
+
+	BlockCacheHits    uint64 `json:"block_cache_hits"`
+	BlockCacheMisses  uint64 `json:"block_cache_misses"`
+	BlockCacheSize    int64  `json:"block_cache_size"`
+	BlockCacheMaxSize int64  `json:"block_cache_max_size"`
+}
+
+func convertCapDaemonStats(capStats capnp.DaemonStats) (*DaemonStats, error) {
+	stats := &DaemonStats{
+		Ops:         []OpStats{},
+		Remotes:     []RemoteStats{},
+		CacheHits:   capStats.CacheHits(),
+		CacheMisses: capStats.CacheMisses(),
+
+		BlockCacheHits:    capStats.BlockCacheHits(),
+		BlockCacheMisses:  capStats.BlockCacheMisses(),
+		BlockCacheSize:    capStats.BlockCacheSize(),
+		BlockCacheMaxSize: capStats.BlockCacheMaxSize(),
+	}
+
+	started, err := capStats.Started()
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Started, err = time.Parse(time.RFC3339, started); err != nil {
+		return nil, err
+	}
+
+	since, err := capStats.Since()
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Since, err = time.Parse(time.RFC3339, since); err != nil {
+		return nil, err
+	}
+
+	capOps, err := capStats.Ops()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capOps.Len(); idx++ {
+		capOp := capOps.At(idx)
+		name, err := capOp.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Ops = append(stats.Ops, OpStats{
+			Name:   name,
+			Calls:  capOp.Calls(),
+			Errors: capOp.Errors(),
+		})
+	}
+
+	capRemotes, err := capStats.Remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capRemotes.Len(); idx++ {
+		capRemote := capRemotes.At(idx)
+		name, err := capRemote.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Remotes = append(stats.Remotes, RemoteStats{
+			Name:  name,
+			Syncs: capRemote.Syncs(),
+			Bytes: capRemote.Bytes(),
+		})
+	}
+
+	return stats, nil
+}
+
+// DaemonStats returns the usage statistics of the daemon. If `reset` is true,
+// the counters are set back to zero after returning their current values.
+func (ctl *Client) DaemonStats(reset bool) (*DaemonStats, error) {
+	call := ctl.api.DaemonStats(ctl.ctx, func(p capnp.Repo_daemonStats_Params) error {
+		p.SetReset(reset)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capStats, err := result.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDaemonStats(capStats)
+}
+
+// CompressDict is a dictionary used to compress small files of one type.
+type CompressDict struct {
+	Class   string
+	ID      uint32
+	Size    int64
+	Samples int64
+}
+
+func convertCapCompressDicts(capDicts capnp.CompressDict_List) ([]CompressDict, error) {
+	dicts := []CompressDict{}
+	for idx := 0; idx < capDicts.Len(); idx++ {
+		capDict := capDicts.At(idx)
+		class, err := capDict.Class()
+		if err != nil {
+			return nil, err
+		}
+
+		dicts = append(dicts, CompressDict{
+			Class:   class,
+			ID:      capDict.Id(),
+			Size:    capDict.Size(),
+			Samples: capDict.Samples(),
+		})
+	}
+
+	return dicts, nil
+}
+
+// CompressTrain trains new compression dictionaries on the current files.
+func (ctl *Client) CompressTrain() ([]CompressDict, error) {
+	call := ctl.api.CompressTrain(ctl.ctx, func(p capnp.Repo_compressTrain_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDicts, err := result.Dicts()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapCompressDicts(capDicts)
+}
+
+// CompressDicts lists the dictionaries used for compressing new files.
+func (ctl *Client) CompressDicts() ([]CompressDict, error) {
+	call := ctl.api.CompressDicts(ctl.ctx, func(p capnp.Repo_compressDicts_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDicts, err := result.Dicts()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapCompressDicts(capDicts)
+}
+
+// ActiveTransfer is a transfer with a remote that is running right now.
+type ActiveTransfer struct {
+	Kind    string    `json:"kind"`
+	Remote  string    `json:"remote"`
+	What    string    `json:"what"`
+	Bytes   int64     `json:"bytes"`
+	Started time.Time `json:"started"`
+}
+
+// MountActivity tells what is going on in a single FUSE mount.
+type MountActivity struct {
+	Dir           string `json:"dir"`
+	OpenHandles   int64  `json:"open_handles"`
+	PendingWrites int    `json:"pending_writes"`
+}
+
+// QueueDepth is the number of items waiting in one of the queues of the daemon.
+type QueueDepth struct {
+	Name  string `json:"name"`
+	Depth int    `json:"depth"`
+}
+
+// RemoteTraffic is the number of bytes exchanged with a remote
+// since the daemon was started.
+type RemoteTraffic struct {
+	Name     string `json:"name"`
+	Received int64  `json:"received"`
+	Sent     int64  `json:"sent"`
+}
+
+// DaemonActivity is what the daemon was doing at `Taken`.
+type DaemonActivity struct {
+	Taken     time.Time        `json:"taken"`
+	Transfers []ActiveTransfer `json:"transfers"`
+	// Calls maps the name of a call to the number of them being served.
+	Calls   map[string]int  `json:"calls"`
+	Mounts  []MountActivity `json:"mounts"`
+	Queues  []QueueDepth    `json:"queues"`
+	Traffic []RemoteTraffic `json:"traffic"`
+}
+
+func convertCapDaemonActivity(capAct capnp.DaemonActivity) (*DaemonActivity, error) {
+	act := &DaemonActivity{
+		Transfers: []ActiveTransfer{},
+		Calls:     make(map[string]int),
+		Mounts:    []MountActivity{},
+		Queues:    []QueueDepth{},
+		Traffic:   []RemoteTraffic{},
+	}
+
+	taken, err := capAct.Taken()
+	if err != nil {
+		return nil, err
+	}
+
+	if act.Taken, err = time.Parse(time.RFC3339Nano, taken); err != nil {
+		return nil, err
+	}
+
+	capTransfers, err := capAct.Transfers()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capTransfers.Len(); idx++ {
+		capTransfer := capTransfers.At(idx)
+		transfer := ActiveTransfer{Bytes: capTransfer.Bytes()}
+		if transfer.Kind, err = capTransfer.Kind(); err != nil {
+			return nil, err
+		}
+
+		if transfer.Remote, err = capTransfer.Remote(); err != nil {
+			return nil, err
+		}
+
+		if transfer.What, err = capTransfer.What(); err != nil {
+			return nil, err
+		}
+
+		started, err := capTransfer.Started()
+		if err != nil {
+			return nil, err
+		}
+
+		if transfer.Started, err = time.Parse(time.RFC3339, started); err != nil {
+			return nil, err
+		}
+
+		act.Transfers = append(act.Transfers, transfer)
+	}
+
+	capCalls, err := capAct.Calls()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capCalls.Len(); idx++ {
+		capCall := capCalls.At(idx)
+		name, err := capCall.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		act.Calls[name] = int(capCall.Count())
+	}
+
+	capMounts, err := capAct.Mounts()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capMounts.Len(); idx++ {
+		capMount := capMounts.At(idx)
+		dir, err := capMount.Dir()
+		if err != nil {
+			return nil, err
+		}
+
+		act.Mounts = append(act.Mounts, MountActivity{
+			Dir:           dir,
+			OpenHandles:   capMount.OpenHandles(),
+			PendingWrites: int(capMount.PendingWrites()),
+		})
+	}
+
+	capQueues, err := capAct.Queues()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capQueues.Len(); idx++ {
+		capQueue := capQueues.At(idx)
+		name, err := capQueue.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		act.Queues = append(act.Queues, QueueDepth{
+			Name:  name,
+			Depth: int(capQueue.Depth()),
+		})
+	}
+
+	capTraffic, err := capAct.Traffic()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capTraffic.Len(); idx++ {
+		capRemote := capTraffic.At(idx)
+		name, err := capRemote.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		act.Traffic = append(act.Traffic, RemoteTraffic{
+			Name:     name,
+			Received: capRemote.Received(),
+			Sent:     capRemote.Sent(),
+		})
+	}
+
+	return act, nil
+}
+
+// ActivityStream delivers what the daemon is doing, once per interval.
+type ActivityStream struct {
+	conn net.Conn
+	dec  *capnplib.Decoder
+}
+
+// Next blocks until the daemon sent the next activity.
+func (as *ActivityStream) Next() (*DaemonActivity, error) {
+	msg, err := as.dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	capAct, err := capnp.ReadRootDaemonActivity(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDaemonActivity(capAct)
+}
+
+// Close stops the stream.
+func (as *ActivityStream) Close() error {
+	return as.conn.Close()
+}
+
+// ActivityStream asks the daemon to send what it is doing every `interval`.
+func (ctl *Client) ActivityStream(interval time.Duration) (*ActivityStream, error) {
+	call := ctl.api.ActivityStream(ctl.ctx, func(p capnp.Repo_activityStream_Params) error {
+		return p.SetInterval(interval.String())
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", result.Port()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ActivityStream{conn: conn, dec: capnplib.NewDecoder(conn)}, nil
+}
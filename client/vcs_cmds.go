@@ -9,9 +9,20 @@ import (
 )
 
 // MakeCommit creates a new commit from the current staging area.
-// The commit will have the message `msg`.
-func (ctl *Client) MakeCommit(msg string) error {
+// The commit will have the message `msg` and the (optional) `labels`.
+func (ctl *Client) MakeCommit(msg string, labels []string) error {
 	call := ctl.api.Commit(ctl.ctx, func(p capnp.VCS_commit_Params) error {
+		capLabels, err := p.NewLabels(int32(len(labels)))
+		if err != nil {
+			return err
+		}
+
+		for idx, label := range labels {
+			if err := capLabels.Set(idx, label); err != nil {
+				return err
+			}
+		}
+
 		return p.SetMsg(msg)
 	})
 
@@ -25,6 +36,13 @@ type Commit struct {
 	Msg  string
 	Tags []string
 	Date time.Time
+
+	// Device is the name of the device that made the commit.
+	Device string
+	// Origin is the subsystem that made it (cli, fuse, gateway, sync, auto)
+	Origin string
+	// Labels are the labels given to the commit.
+	Labels []string
 }
 
 func convertCapCommit(capEntry *capnp.Commit) (*Commit, error) {
@@ -64,13 +82,41 @@ func convertCapCommit(capEntry *capnp.Commit) (*Commit, error) {
 	}
 
 	result.Tags = tags
+
+	result.Device, err = capEntry.Device()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Origin, err = capEntry.Origin()
+	if err != nil {
+		return nil, err
+	}
+
+	labelList, err := capEntry.Labels()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []string{}
+	for idx := 0; idx < labelList.Len(); idx++ {
+		label, err := labelList.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		labels = append(labels, label)
+	}
+
+	result.Labels = labels
 	return &result, nil
 }
 
 // Log lists all commits, starting with the newest one.
-func (ctl *Client) Log() ([]Commit, error) {
+// If `label` is not empty, only commits with this label are listed.
+func (ctl *Client) Log(label string) ([]Commit, error) {
 	call := ctl.api.Log(ctl.ctx, func(p capnp.VCS_log_Params) error {
-		return nil
+		return p.SetLabel(label)
 	})
 
 	results := []Commit{}
@@ -228,6 +274,95 @@ func (ctl *Client) History(path string) ([]*Change, error) {
 	return results, nil
 }
 
+// ByteRange is a continuous range of bytes in a file.
+type ByteRange struct {
+	Offset uint64
+	Length uint64
+}
+
+// BlockDiff describes which parts of a file changed between two versions.
+type BlockDiff struct {
+	OldPath        string
+	NewPath        string
+	OldSize        uint64
+	NewSize        uint64
+	BlockSize      uint64
+	Changed        []ByteRange
+	ChangedBytes   uint64
+	UnchangedBytes uint64
+}
+
+// UnchangedRatio returns how much of the new version (between 0 and 1)
+// was already part of the old one.
+func (bd *BlockDiff) UnchangedRatio() float64 {
+	if bd.NewSize == 0 {
+		return 1
+	}
+
+	return float64(bd.UnchangedBytes) / float64(bd.NewSize)
+}
+
+// BlockDiff compares `oldPath` at `oldRev` with `newPath` at `newRev`
+// and returns which byte ranges of the new version changed.
+func (ctl *Client) BlockDiff(oldRev, oldPath, newRev, newPath string) (*BlockDiff, error) {
+	call := ctl.api.BlockDiff(ctl.ctx, func(p capnp.VCS_blockDiff_Params) error {
+		if err := p.SetOldRev(oldRev); err != nil {
+			return err
+		}
+
+		if err := p.SetOldPath(oldPath); err != nil {
+			return err
+		}
+
+		if err := p.SetNewRev(newRev); err != nil {
+			return err
+		}
+
+		return p.SetNewPath(newPath)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDiff, err := result.Diff()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BlockDiff{
+		OldSize:        capDiff.OldSize(),
+		NewSize:        capDiff.NewSize(),
+		BlockSize:      capDiff.BlockSize(),
+		ChangedBytes:   capDiff.ChangedBytes(),
+		UnchangedBytes: capDiff.UnchangedBytes(),
+	}
+
+	if diff.OldPath, err = capDiff.OldPath(); err != nil {
+		return nil, err
+	}
+
+	if diff.NewPath, err = capDiff.NewPath(); err != nil {
+		return nil, err
+	}
+
+	capRanges, err := capDiff.Changed()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capRanges.Len(); idx++ {
+		capRange := capRanges.At(idx)
+		diff.Changed = append(diff.Changed, ByteRange{
+			Offset: capRange.Offset(),
+			Length: capRange.Length(),
+		})
+	}
+
+	return diff, nil
+}
+
 // DiffPair is a pair of nodes that were changed in some way.
 type DiffPair struct {
 	Src StatInfo
@@ -244,6 +379,9 @@ type Diff struct {
 	Moved    []DiffPair
 	Merged   []DiffPair
 	Conflict []DiffPair
+
+	// TransferSize is the size of the content that is new to us.
+	TransferSize uint64
 }
 
 // IsEmpty reports if a diff is completely empty (i.e. nothing changed)
@@ -380,6 +518,7 @@ func convertCapDiffToDiff(capDiff capnp.Diff) (*Diff, error) {
 		return nil, err
 	}
 
+	diff.TransferSize = capDiff.TransferSize()
 	return diff, nil
 }
 
@@ -447,6 +586,71 @@ func (ctl *Client) Sync(remote string, needFetch bool) (*Diff, error) {
 	return convertCapDiffToDiff(capDiff)
 }
 
+// BundleCreate returns a signed bundle with our changes between
+// `fromRev` and `toRev`, including the content of the changed files.
+func (ctl *Client) BundleCreate(fromRev, toRev string) ([]byte, error) {
+	call := ctl.api.BundleCreate(ctl.ctx, func(p capnp.VCS_bundleCreate_Params) error {
+		if err := p.SetFromRev(fromRev); err != nil {
+			return err
+		}
+
+		return p.SetToRev(toRev)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data()
+}
+
+// BundleApply applies a bundle created by BundleCreate of another remote
+// and syncs with it. It returns the name of the remote and what changed.
+func (ctl *Client) BundleApply(data []byte) (string, *Diff, error) {
+	call := ctl.api.BundleApply(ctl.ctx, func(p capnp.VCS_bundleApply_Params) error {
+		return p.SetData(data)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return "", nil, err
+	}
+
+	owner, err := result.Owner()
+	if err != nil {
+		return "", nil, err
+	}
+
+	capDiff, err := result.Diff()
+	if err != nil {
+		return "", nil, err
+	}
+
+	diff, err := convertCapDiffToDiff(capDiff)
+	return owner, diff, err
+}
+
+// SyncPreview tells what Sync() would change, without changing anything.
+func (ctl *Client) SyncPreview(remote string, needFetch bool) (*Diff, error) {
+	call := ctl.api.SyncPreview(ctl.ctx, func(p capnp.VCS_syncPreview_Params) error {
+		p.SetNeedFetch(needFetch)
+		return p.SetWithWhom(remote)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDiff, err := result.Diff()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDiffToDiff(capDiff)
+}
+
 // CommitInfo is like a stat(2) for commits.
 func (ctl *Client) CommitInfo(rev string) (bool, *Commit, error) {
 	call := ctl.api.CommitInfo(ctl.ctx, func(p capnp.VCS_commitInfo_Params) error {
@@ -474,3 +678,134 @@ func (ctl *Client) CommitInfo(rev string) (bool, *Commit, error) {
 
 	return true, cmt, nil
 }
+
+// Snapshot is a tag of HEAD that was created automatically.
+type Snapshot struct {
+	Tier   string
+	Tag    string
+	Commit string
+}
+
+// Snapshots lists all automatically created snapshots, oldest first per tier.
+func (ctl *Client) Snapshots() ([]Snapshot, error) {
+	call := ctl.api.Snapshots(ctl.ctx, func(p capnp.VCS_snapshots_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capSnapshots, err := result.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for idx := 0; idx < capSnapshots.Len(); idx++ {
+		capSnapshot := capSnapshots.At(idx)
+		snapshot := Snapshot{}
+
+		if snapshot.Tier, err = capSnapshot.Tier(); err != nil {
+			return nil, err
+		}
+
+		if snapshot.Tag, err = capSnapshot.Tag(); err != nil {
+			return nil, err
+		}
+
+		if snapshot.Commit, err = capSnapshot.Commit(); err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// UndoChange is a path that is changed by undoing commits.
+// Action is one of "restore", "remove" or "revert".
+type UndoChange struct {
+	Path   string
+	Action string
+	IsDir  bool
+	Size   uint64
+}
+
+func convertCapUndoChanges(capChanges capnp.UndoChange_List) ([]UndoChange, error) {
+	changes := []UndoChange{}
+	for idx := 0; idx < capChanges.Len(); idx++ {
+		capChange := capChanges.At(idx)
+		change := UndoChange{
+			IsDir: capChange.IsDir(),
+			Size:  capChange.Size(),
+		}
+
+		var err error
+		if change.Path, err = capChange.Path(); err != nil {
+			return nil, err
+		}
+
+		if change.Action, err = capChange.Action(); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// UndoPreview lists what undoing the last `count` commits would change.
+// The returned HEAD should be passed to Undo().
+func (ctl *Client) UndoPreview(count int) ([]UndoChange, string, error) {
+	call := ctl.api.UndoPreview(ctl.ctx, func(p capnp.VCS_undoPreview_Params) error {
+		p.SetCount(int32(count))
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, "", err
+	}
+
+	capChanges, err := result.Changes()
+	if err != nil {
+		return nil, "", err
+	}
+
+	changes, err := convertCapUndoChanges(capChanges)
+	if err != nil {
+		return nil, "", err
+	}
+
+	head, err := result.Head()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return changes, head, nil
+}
+
+// Undo reverts the last `count` commits with a new commit.
+// If `expectHead` is not empty, it fails if HEAD is not `expectHead` anymore.
+func (ctl *Client) Undo(count int, expectHead string) ([]UndoChange, error) {
+	call := ctl.api.Undo(ctl.ctx, func(p capnp.VCS_undo_Params) error {
+		p.SetCount(int32(count))
+		return p.SetExpectHead(expectHead)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capChanges, err := result.Changes()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapUndoChanges(capChanges)
+}
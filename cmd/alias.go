@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/sahib/brig/defaults"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// splitWords splits `line` into words like a shell would.
+// Single and double quotes group words, a backslash escapes the next rune.
+func splitWords(line string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in »%s«", line)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// parseExpansions reads entries like "st=status --short"
+// into a mapping from name to the words it expands to.
+func parseExpansions(key string, entries []string) map[string][]string {
+	expansions := make(map[string][]string)
+	for _, entry := range entries {
+		name, line, _ := strings.Cut(entry, "=")
+		words, err := splitWords(line)
+		if err != nil {
+			log.Warningf("ignoring bad entry in %s: %v", key, err)
+			continue
+		}
+
+		expansions[strings.TrimSpace(name)] = words
+	}
+
+	return expansions
+}
+
+// findCommandIndex returns the index of the command name in `args`
+// (or -1 if there is none) and the value of --repo, if given.
+// It has to skip the global flags and their values for that.
+func findCommandIndex(app *cli.App, args []string) (int, string) {
+	takesValue := make(map[string]bool)
+	for _, flag := range app.Flags {
+		_, isBool := flag.(cli.BoolFlag)
+		for _, name := range strings.Split(flag.GetName(), ",") {
+			takesValue[strings.TrimSpace(name)] = !isBool
+		}
+	}
+
+	repoPath := ""
+	for idx := 1; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "--" {
+			return -1, repoPath
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			return idx, repoPath
+		}
+
+		name, val, hasVal := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !hasVal && takesValue[name] && idx+1 < len(args) {
+			idx++
+			val = args[idx]
+		}
+
+		if name == "repo" {
+			repoPath = val
+		}
+	}
+
+	return -1, repoPath
+}
+
+// readExpansions reads the aliases and default flags from the
+// config of the repository. The daemon does not need to run for that.
+func readExpansions(repoPath string) (map[string][]string, map[string][]string) {
+	if repoPath == "" {
+		repoPath = os.Getenv("BRIG_PATH")
+	}
+
+	if repoPath == "" {
+		dir, err := homedir.Expand("~/.brig")
+		if err != nil {
+			return nil, nil
+		}
+
+		repoPath = dir
+	}
+
+	repoPath, err := filepath.Abs(resolveRepoPath(repoPath))
+	if err != nil {
+		return nil, nil
+	}
+
+	// No repository (yet); there is nothing to expand then.
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(repoPath, "config.yml"))
+	if err != nil {
+		return nil, nil
+	}
+
+	aliases := parseExpansions("cli.aliases", cfg.Strings("cli.aliases"))
+	defaultFlags := parseExpansions("cli.default_flags", cfg.Strings("cli.default_flags"))
+	return aliases, defaultFlags
+}
+
+// expandArgs replaces a user defined alias in `args` by what it stands
+// for and inserts the configured default flags after the command name.
+// Builtin commands always win over aliases of the same name.
+func expandArgs(app *cli.App, args []string) []string {
+	cmdIdx, repoPath := findCommandIndex(app, args)
+	if cmdIdx < 0 {
+		return args
+	}
+
+	aliases, defaultFlags := readExpansions(repoPath)
+
+	expanded := append([]string{}, args[:cmdIdx]...)
+	rest := args[cmdIdx+1:]
+
+	words := []string{args[cmdIdx]}
+	if app.Command(args[cmdIdx]) == nil {
+		if aliasWords, ok := aliases[args[cmdIdx]]; ok && len(aliasWords) > 0 {
+			words = aliasWords
+		}
+	}
+
+	cmd := app.Command(words[0])
+	if cmd == nil {
+		// Let the usual "command not found" handling take over.
+		return args
+	}
+
+	expanded = append(expanded, words[0])
+	for name, flags := range defaultFlags {
+		if flagCmd := app.Command(name); flagCmd != nil && flagCmd.Name == cmd.Name {
+			expanded = append(expanded, flags...)
+		}
+	}
+
+	expanded = append(expanded, words[1:]...)
+	return append(expanded, rest...)
+}
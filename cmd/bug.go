@@ -54,6 +54,8 @@ Please include anything else you think is helpful. Thanks!
 
 ### Did you check if a similar bug report was already opened?
 
+### Is it about performance? Please attach a profile (see »brig debug profile --help«).
+
 ### System details:`)
 
 	fmt.Fprintf(buf, "go version:     ``%s``\n", cmdOutput("go", "version"))
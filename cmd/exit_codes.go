@@ -1,5 +1,13 @@
 package cmd
 
+import (
+	"errors"
+
+	"github.com/sahib/brig/util/errkind"
+)
+
+// The exit codes are meant to be used by scripts, so the numbers are
+// stable: new codes are only ever appended. They are listed in »brig --help«.
 const (
 	// Success is the same as EXIT_SUCCESS in C
 	Success = iota
@@ -16,4 +24,47 @@ const (
 
 	// UnknownError is an uncategorized error, probably our fault.
 	UnknownError
+
+	// NotFound means that a path, remote, commit or repository does not exist.
+	NotFound
+
+	// Conflict means that something exists already or changed in the meantime.
+	Conflict
+
+	// Offline means that we or a remote could not be reached over the network.
+	Offline
+
+	// Unauthorized means that a remote refused us or is blocked.
+	Unauthorized
 )
+
+// kindToExitCode maps the kind of an error to the exit code used for it.
+// The daemon tells the client the kind of every error it returns,
+// commands that wrap an error have to use %w to keep it.
+var kindToExitCode = map[errkind.Kind]int{
+	errkind.Invalid:      BadArgs,
+	errkind.NotFound:     NotFound,
+	errkind.Conflict:     Conflict,
+	errkind.Offline:      Offline,
+	errkind.Unauthorized: Unauthorized,
+}
+
+// exitCodeFor returns the exit code that should be used for `err`.
+// Codes that were chosen explicitly by a command are kept,
+// otherwise the kind of the error decides.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return Success
+	}
+
+	var cerr ExitCode
+	if errors.As(err, &cerr) && cerr.Code != UnknownError {
+		return cerr.Code
+	}
+
+	if code, ok := kindToExitCode[errkind.Of(err)]; ok {
+		return code
+	}
+
+	return UnknownError
+}
@@ -1,16 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sahib/brig/cmd/pwd"
 	"github.com/sahib/brig/cmd/tabwriter"
 	"github.com/sahib/brig/util"
 
@@ -23,6 +27,7 @@ import (
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 	terminal "github.com/wayneashleyberry/terminal-dimensions"
+	"golang.org/x/crypto/openpgp"
 )
 
 func handleStage(ctx *cli.Context, ctl *client.Client) error {
@@ -57,7 +62,7 @@ func handleStage(ctx *cli.Context, ctl *client.Client) error {
 
 	absLocalPath, err := filepath.Abs(localPath)
 	if err != nil {
-		return fmt.Errorf("Failed to retrieve absolute path: %v", err)
+		return fmt.Errorf("Failed to retrieve absolute path: %w", err)
 	}
 
 	info, err := os.Stat(absLocalPath)
@@ -106,7 +111,7 @@ func handleStageDirectory(ctx *cli.Context, ctl *client.Client, root, repoRoot s
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to create sub directories: %v", err)
+		return fmt.Errorf("failed to create sub directories: %w", err)
 	}
 
 	width, err := terminal.Width()
@@ -178,36 +183,40 @@ func handleStageDirectory(ctx *cli.Context, ctl *client.Client, root, repoRoot s
 	return nil
 }
 
-func handleCat(ctx *cli.Context, ctl *client.Client) error {
-	path := "/"
-	if len(ctx.Args()) >= 1 {
-		path = ctx.Args().First()
+// openCatStream returns the stream `brig cat` should output for `path`.
+func openCatStream(ctx *cli.Context, ctl *client.Client, path string) (io.ReadCloser, error) {
+	doOffline := ctx.Bool("offline")
+	if at := ctx.String("at"); at != "" {
+		// The daemon knows if `path` was a directory back then:
+		return ctl.CatAt(path, at, doOffline)
 	}
 
 	info, err := ctl.Stat(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	doOffline := ctx.Bool("offline")
-
-	var stream io.ReadCloser
 	if info.IsDir {
-		stream, err = ctl.Tar(path, doOffline)
-	} else {
-		stream, err = ctl.Cat(path, doOffline)
+		return ctl.Tar(path, doOffline)
+	}
+
+	return ctl.Cat(path, doOffline)
+}
+
+func handleCat(ctx *cli.Context, ctl *client.Client) error {
+	path := "/"
+	if len(ctx.Args()) >= 1 {
+		path = ctx.Args().First()
 	}
 
+	stream, err := openCatStream(ctx, ctl, path)
 	if err != nil {
 		return err
 	}
 	defer util.Closer(stream)
 
 	if _, err := io.Copy(os.Stdout, stream); err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("cat: %v", err),
-		}
+		return fmt.Errorf("cat: %w", err)
 	}
 
 	return nil
@@ -217,9 +226,29 @@ func handleRm(ctx *cli.Context, ctl *client.Client) error {
 	path := ctx.Args().First()
 
 	if err := ctl.Remove(path); err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("rm: %v", err),
+		return fmt.Errorf("rm: %w", err)
+	}
+
+	return nil
+}
+
+func handleTransfer(ctx *cli.Context, ctl *client.Client, copy bool) error {
+	args := ctx.Args()
+	sources, dstPath := args[:len(args)-1], args[len(args)-1]
+
+	newPaths, err := ctl.Transfer(sources, dstPath, client.TransferOptions{
+		Copy:      copy,
+		Recursive: copy && ctx.Bool("recursive"),
+		CommitMsg: ctx.String("into-commit"),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("verbose") {
+		for _, newPath := range newPaths {
+			fmt.Println(newPath)
 		}
 	}
 
@@ -229,13 +258,18 @@ func handleRm(ctx *cli.Context, ctl *client.Client) error {
 func handleMv(ctx *cli.Context, ctl *client.Client) error {
 	srcPath := ctx.Args().Get(0)
 	dstPath := ctx.Args().Get(1)
-	return ctl.Move(srcPath, dstPath)
+
+	// The plain version can also change the case of a name:
+	isPlain := ctx.NArg() == 2 && !strings.ContainsAny(srcPath, `*?[\`) && !strings.HasSuffix(dstPath, "/")
+	if isPlain && ctx.String("into-commit") == "" && !ctx.Bool("verbose") {
+		return ctl.Move(srcPath, dstPath)
+	}
+
+	return handleTransfer(ctx, ctl, false)
 }
 
 func handleCp(ctx *cli.Context, ctl *client.Client) error {
-	srcPath := ctx.Args().Get(0)
-	dstPath := ctx.Args().Get(1)
-	return ctl.Copy(srcPath, dstPath)
+	return handleTransfer(ctx, ctl, true)
 }
 
 func colorForSize(size uint64) func(f string, a ...interface{}) string {
@@ -249,9 +283,7 @@ func colorForSize(size uint64) func(f string, a ...interface{}) string {
 	case size >= 1024<<30:
 		return color.MagentaString
 	default:
-		return func(f string, a ...interface{}) string {
-			return f
-		}
+		return fmt.Sprintf
 	}
 }
 
@@ -288,87 +320,6 @@ func userPrefixMap(users []string) map[string]string {
 	return m
 }
 
-func handleList(ctx *cli.Context, ctl *client.Client) error {
-	maxDepth := ctx.Int("depth")
-	if ctx.Bool("recursive") {
-		maxDepth = -1
-	}
-
-	root := "/"
-	if ctx.Args().Present() {
-		root = ctx.Args().First()
-	}
-
-	entries, err := ctl.List(root, maxDepth)
-	if err != nil {
-		return err
-	}
-
-	tabW := tabwriter.NewWriter(
-		os.Stdout, 0, 0, 2, ' ',
-		tabwriter.StripEscape,
-	)
-
-	tmpl, err := readFormatTemplate(ctx)
-	if err != nil {
-		return err
-	}
-
-	if tmpl != nil {
-		for _, entry := range entries {
-			if err := tmpl.Execute(os.Stdout, entry); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	users := []string{}
-	for _, entry := range entries {
-		users = append(users, entry.User)
-	}
-
-	userMap := userPrefixMap(users)
-
-	if len(entries) != 0 {
-		userColumn := ""
-		if len(userMap) > 1 {
-			userColumn = "USER\t"
-		}
-
-		fmt.Fprintf(tabW, "SIZE\tMODTIME\t%sPATH\tPIN\t\n", userColumn)
-	}
-
-	for _, entry := range entries {
-		pinState := " " + pinStateToSymbol(entry.IsPinned, entry.IsExplicit)
-
-		var coloredPath string
-		if entry.IsDir {
-			coloredPath = color.GreenString(entry.Path)
-		} else {
-			coloredPath = color.WhiteString(entry.Path)
-		}
-
-		userEntry := ""
-		if len(userMap) > 1 {
-			userEntry = color.GreenString(userMap[entry.User]) + "\t"
-		}
-
-		fmt.Fprintf(
-			tabW,
-			"%s\t%s\t%s%s\t%s\t\n",
-			colorForSize(entry.Size)(humanize.Bytes(entry.Size)),
-			entry.ModTime.Format(time.UnixDate),
-			userEntry,
-			coloredPath,
-			pinState,
-		)
-	}
-
-	return tabW.Flush()
-}
-
 func handleTree(ctx *cli.Context, ctl *client.Client) error {
 	root := "/"
 	if ctx.NArg() > 0 {
@@ -377,10 +328,7 @@ func handleTree(ctx *cli.Context, ctl *client.Client) error {
 
 	entries, err := ctl.List(root, -1)
 	if err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("tree: %v", err),
-		}
+		return fmt.Errorf("tree: %w", err)
 	}
 
 	showTree(entries, &treeCfg{
@@ -394,7 +342,7 @@ func handleMkdir(ctx *cli.Context, ctl *client.Client) error {
 	createParents := ctx.Bool("parents")
 
 	if err := ctl.Mkdir(path, createParents); err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("mkdir: %v", err)}
+		return fmt.Errorf("mkdir: %w", err)
 	}
 
 	return nil
@@ -433,6 +381,9 @@ func handleShowCommit(ctx *cli.Context, ctl *client.Client, cmt *client.Commit)
 	printPair("Tags", strings.Join(cmt.Tags, ", "))
 	printPair("ModTime", cmt.Date.Format(time.RFC3339))
 	printPair("Message", cmt.Msg)
+	printPair("Device", cmt.Device)
+	printPair("Origin", cmt.Origin)
+	printPair("Labels", strings.Join(cmt.Labels, ", "))
 	tabW.Flush()
 
 	self, err := ctl.Whoami()
@@ -523,6 +474,353 @@ func handleShowFileOrDir(ctx *cli.Context, ctl *client.Client, path string) erro
 		printPair("Backend Hash", "-")
 	}
 
+	if info.MimeType != "" {
+		printPair("Mime Type", fmt.Sprintf("%s (%s)", info.MimeType, info.Kind))
+	}
+
+	return tabW.Flush()
+}
+
+// nodeStatCommit is a commit that changed the node shown by »brig stat«.
+type nodeStatCommit struct {
+	Hash   string    `json:"hash"`
+	Date   time.Time `json:"date"`
+	Msg    string    `json:"msg"`
+	Change string    `json:"change"`
+}
+
+// nodeStat is everything »brig stat« knows about a node.
+type nodeStat struct {
+	Path        string           `json:"path"`
+	Type        string           `json:"type"`
+	User        string           `json:"user"`
+	Size        uint64           `json:"size"`
+	ModTime     time.Time        `json:"mod_time"`
+	TreeHash    string           `json:"tree_hash"`
+	ContentHash string           `json:"content_hash"`
+	BackendHash string           `json:"backend_hash,omitempty"`
+	IsPinned    bool             `json:"is_pinned"`
+	IsExplicit  bool             `json:"is_explicit"`
+	IsCached    bool             `json:"is_cached"`
+	MimeType    string           `json:"mime_type,omitempty"`
+	Kind        string           `json:"kind,omitempty"`
+	Versions    int              `json:"versions"`
+	Commits     []nodeStatCommit `json:"commits"`
+}
+
+func makeNodeStat(ctl *client.Client, path string) (*nodeStat, error) {
+	info, err := ctl.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	isCached, err := ctl.IsCached(path)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := ctl.History(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := &nodeStat{
+		Path:        info.Path,
+		Type:        "file",
+		User:        info.User,
+		Size:        info.Size,
+		ModTime:     info.ModTime,
+		TreeHash:    info.TreeHash.B58String(),
+		ContentHash: info.ContentHash.B58String(),
+		IsPinned:    info.IsPinned,
+		IsExplicit:  info.IsExplicit,
+		IsCached:    isCached,
+		MimeType:    info.MimeType,
+		Kind:        info.Kind,
+		Commits:     []nodeStatCommit{},
+	}
+
+	if info.IsDir {
+		stat.Type = "directory"
+	} else {
+		stat.BackendHash = info.BackendHash.B58String()
+	}
+
+	for _, entry := range history {
+		change := strings.Join(entry.Mask, "|")
+		if change == "none" {
+			continue
+		}
+
+		for _, detail := range entry.Mask {
+			if detail == "added" || detail == "modified" {
+				stat.Versions++
+				break
+			}
+		}
+
+		stat.Commits = append(stat.Commits, nodeStatCommit{
+			Hash:   entry.Head.Hash.B58String(),
+			Date:   entry.Head.Date,
+			Msg:    entry.Head.Msg,
+			Change: change,
+		})
+	}
+
+	return stat, nil
+}
+
+func handleNodeStat(ctx *cli.Context, ctl *client.Client, path string) error {
+	stat, err := makeNodeStat(ctl, path)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stat)
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		return tmpl.Execute(os.Stdout, stat)
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	printPair := func(name string, val interface{}) {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%v\t\n",
+			color.WhiteString(name),
+			val,
+		)
+	}
+
+	pinState := yesify(stat.IsPinned)
+	if stat.IsExplicit {
+		pinState += " (explicit)"
+	}
+
+	printPair("Path", stat.Path)
+	printPair("Type", stat.Type)
+	printPair("Size", fmt.Sprintf("%s (%d bytes)", humanize.Bytes(stat.Size), stat.Size))
+	printPair("Modified by", stat.User)
+	printPair("ModTime", stat.ModTime.Format(time.RFC3339))
+	printPair("Tree Hash", stat.TreeHash)
+	printPair("Content Hash", stat.ContentHash)
+
+	if stat.BackendHash != "" {
+		printPair("Backend Hash", stat.BackendHash)
+	} else {
+		printPair("Backend Hash", "-")
+	}
+
+	if stat.MimeType != "" {
+		printPair("Mime Type", fmt.Sprintf("%s (%s)", stat.MimeType, stat.Kind))
+	} else {
+		printPair("Mime Type", "-")
+	}
+
+	printPair("Pinned", pinState)
+	printPair("Cached", yesify(stat.IsCached))
+	printPair("Versions", stat.Versions)
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if len(stat.Commits) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("Commits that touched it:")
+	for _, cmt := range stat.Commits {
+		hash := cmt.Hash
+		if len(hash) > 10 {
+			hash = hash[:10]
+		}
+
+		fmt.Printf(
+			"  %s %s %-10s %s\n",
+			color.GreenString(hash),
+			color.YellowString(cmt.Date.Format(time.RFC3339)),
+			cmt.Change,
+			cmt.Msg,
+		)
+	}
+
+	return nil
+}
+
+func handleStat(ctx *cli.Context, ctl *client.Client) error {
+	root := "/"
+	if ctx.NArg() > 0 {
+		root = ctx.Args().First()
+	}
+
+	if !ctx.Bool("usage") {
+		return handleNodeStat(ctx, ctl, root)
+	}
+
+	usage, err := ctl.SpaceUsage(root)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		return tmpl.Execute(os.Stdout, usage)
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	printPair := func(name string, val interface{}) {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%v\t\n",
+			color.WhiteString(name),
+			val,
+		)
+	}
+
+	saved := usage.LogicalSize - usage.UniqueSize
+	printPair("Root", usage.Root)
+	printPair("Files", fmt.Sprintf("%d (%d unique)", usage.Files, usage.UniqueFiles))
+	printPair("Logical size", humanize.Bytes(usage.LogicalSize))
+	printPair("Unique size", humanize.Bytes(usage.UniqueSize))
+	printPair("Deduplicated", color.GreenString(humanize.Bytes(saved)))
+
+	if usage.StoredLogicalSize > 0 {
+		printPair("Stored size", fmt.Sprintf(
+			"%s for %s cached (ratio %.2f)",
+			humanize.Bytes(usage.StoredSize),
+			humanize.Bytes(usage.StoredLogicalSize),
+			usage.CompressionRatio(),
+		))
+	} else {
+		printPair("Stored size", "-")
+	}
+
+	printPair("Old versions", fmt.Sprintf(
+		"%s in %d contents",
+		color.YellowString(humanize.Bytes(usage.HistorySize)),
+		usage.HistoryFiles,
+	))
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if len(usage.Dirs) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Fprintln(tabW, "DIRECTORY\tFILES\tSIZE\tUNIQUE\t")
+	for _, dir := range usage.Dirs {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%d\t%s\t%s\t\n",
+			color.WhiteString(dir.Path),
+			dir.Files,
+			colorForSize(dir.LogicalSize)(humanize.Bytes(dir.LogicalSize)),
+			humanize.Bytes(dir.UniqueSize),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleDiskUsage(ctx *cli.Context, ctl *client.Client) error {
+	root := "/"
+	if ctx.NArg() > 0 {
+		root = ctx.Args().First()
+	}
+
+	usages, err := ctl.DiskUsage(root)
+	if err != nil {
+		return fmt.Errorf("du: %w", err)
+	}
+
+	if ctx.Bool("sort") {
+		sort.SliceStable(usages, func(i, j int) bool {
+			return usages[i].LogicalSize > usages[j].LogicalSize
+		})
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, usage := range usages {
+			if err := tmpl.Execute(os.Stdout, usage); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	total := client.ChildUsage{}
+	fmt.Fprintln(tabW, "SIZE\tUNIQUE\tPINNED\tFILES\tPATH\t")
+	for _, usage := range usages {
+		childPath := usage.Path
+		if usage.IsDir {
+			childPath = color.GreenString(childPath + "/")
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%d\t%s\t\n",
+			colorForSize(usage.LogicalSize)(humanize.Bytes(usage.LogicalSize)),
+			humanize.Bytes(usage.UniqueSize),
+			humanize.Bytes(usage.PinnedSize),
+			usage.Files,
+			childPath,
+		)
+
+		total.Files += usage.Files
+		total.LogicalSize += usage.LogicalSize
+		total.UniqueSize += usage.UniqueSize
+		total.PinnedSize += usage.PinnedSize
+	}
+
+	if len(usages) > 1 {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%d\t%s\t\n",
+			humanize.Bytes(total.LogicalSize),
+			humanize.Bytes(total.UniqueSize),
+			humanize.Bytes(total.PinnedSize),
+			total.Files,
+			color.WhiteString("total"),
+		)
+	}
+
 	return tabW.Flush()
 }
 
@@ -589,3 +887,203 @@ func handleTrashList(ctx *cli.Context, ctl *client.Client) error {
 func handleTrashRemove(ctx *cli.Context, ctl *client.Client) error {
 	return ctl.Undelete(ctx.Args().First())
 }
+
+func handleHoldAdd(ctx *cli.Context, ctl *client.Client) error {
+	for _, path := range ctx.Args() {
+		if err := ctl.HoldAdd(path, ctx.String("reason")); err != nil {
+			return fmt.Errorf("hold: %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func handleHoldRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, path := range ctx.Args() {
+		if err := ctl.HoldRemove(path); err != nil {
+			return fmt.Errorf("hold: %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func handleHoldList(ctx *cli.Context, ctl *client.Client) error {
+	holds, err := ctl.HoldList()
+	if err != nil {
+		return fmt.Errorf("hold: %w", err)
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, hold := range holds {
+			if err := tmpl.Execute(os.Stdout, hold); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(holds) == 0 {
+		fmt.Println("No holds.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "PATH\tSINCE\tOWNER\tREASON\t")
+	for _, hold := range holds {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t\n",
+			color.CyanString(hold.Path),
+			hold.Created.Format(time.RFC3339),
+			hold.Owner,
+			hold.Reason,
+		)
+	}
+
+	return tabW.Flush()
+}
+
+// archiveFormatFromName guesses the archive format from a file name
+// like »photos.zip«, »photos.tar.gz« or »photos.tar.gpg«.
+func archiveFormatFromName(name string) string {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gpg"), ".pgp")
+	if strings.HasSuffix(name, ".zip") {
+		return "zip"
+	}
+
+	if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		return "tar.gz"
+	}
+
+	return "tar"
+}
+
+func readRecipientKeys(paths []string) (openpgp.EntityList, error) {
+	recipients := openpgp.EntityList{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path) // #nosec
+		if err != nil {
+			return nil, err
+		}
+
+		ents, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			// Might be a binary key:
+			ents, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key from %s: %w", path, err)
+			}
+		}
+
+		recipients = append(recipients, ents...)
+	}
+
+	return recipients, nil
+}
+
+// wrapArchiveEncryption returns a writer that encrypts everything written
+// to it for the keys in --encrypt-to or with a passphrase (--symmetric).
+// If no encryption was requested, `w` is returned as it is.
+func wrapArchiveEncryption(ctx *cli.Context, w io.Writer, name string) (io.WriteCloser, error) {
+	hints := &openpgp.FileHints{IsBinary: true, FileName: name}
+
+	if keyPaths := ctx.StringSlice("encrypt-to"); len(keyPaths) > 0 {
+		recipients, err := readRecipientKeys(keyPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		return openpgp.Encrypt(w, recipients, nil, hints, nil)
+	}
+
+	if ctx.Bool("symmetric") {
+		fmt.Fprintln(os.Stderr, "Please enter a passphrase for the archive.")
+		passphrase, err := pwd.PromptNewPassword(20)
+		if err != nil {
+			return nil, ExitCode{BadPassword, fmt.Sprintf("failed to read passphrase: %v", err)}
+		}
+
+		return openpgp.SymmetricallyEncrypt(w, passphrase, hints, nil)
+	}
+
+	return util.NopWriteCloser(w), nil
+}
+
+func handleArchive(ctx *cli.Context, ctl *client.Client) error {
+	root := "/"
+	if len(ctx.Args()) >= 1 {
+		root = ctx.Args().First()
+	}
+
+	output := ctx.String("output")
+	toStdout := output == "" || output == "-"
+	if toStdout && ctx.Bool("symmetric") {
+		// The passphrase prompt would end up in the archive.
+		return ExitCode{BadArgs, "--symmetric needs --output"}
+	}
+
+	format := ctx.String("format")
+	if format == "" {
+		format = archiveFormatFromName(output)
+	}
+
+	if format != "tar" && format != "tar.gz" && format != "zip" {
+		return ExitCode{BadArgs, fmt.Sprintf("unknown archive format: %s", format)}
+	}
+
+	if len(ctx.StringSlice("encrypt-to")) > 0 && ctx.Bool("symmetric") {
+		return ExitCode{BadArgs, "use either --encrypt-to or --symmetric"}
+	}
+
+	var dst io.Writer = os.Stdout
+	if !toStdout {
+		fd, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+
+		defer util.Closer(fd)
+		dst = fd
+	}
+
+	fail := func(err error) error {
+		if !toStdout {
+			os.Remove(output)
+		}
+
+		return fmt.Errorf("archive: %w", err)
+	}
+
+	encW, err := wrapArchiveEncryption(ctx, dst, path.Base(root)+"."+format)
+	if err != nil {
+		return fail(err)
+	}
+
+	stream, err := ctl.Archive(root, ctx.String("rev"), format)
+	if err != nil {
+		return fail(err)
+	}
+
+	defer util.Closer(stream)
+
+	if _, err := io.Copy(encW, stream); err != nil {
+		return fail(err)
+	}
+
+	if err := encW.Close(); err != nil {
+		return fail(err)
+	}
+
+	return nil
+}
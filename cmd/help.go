@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/toqueteos/webbrowser"
 	"github.com/urfave/cli"
@@ -22,26 +23,87 @@ func die(msg string) {
 	panic(msg)
 }
 
+// initFlags are shared by »init« and »clone«.
+var initFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "backend,b",
+		Value: "httpipfs",
+		Usage: "What data backend to use for the new repo. One of  `mock`, `httpipfs`. This cannot be changed later!",
+	},
+	cli.StringFlag{
+		Name:  "w,pw-helper",
+		Value: "",
+		Usage: "Password helper command. The stdout of this command is used as password.",
+	},
+	cli.BoolFlag{
+		Name:  "no-password,x",
+		Usage: "Use a static password. Not recommended besides testing.",
+	},
+	cli.StringFlag{
+		Name:  "ipfs-path,P",
+		Usage: "Specify an explicit path to an IPFS repository. Useful if you have more than one.",
+		Value: "",
+	},
+	cli.BoolFlag{
+		Name:  "no-ipfs-setup",
+		Usage: "Do not try to install and setup IPFS.",
+	},
+	cli.BoolFlag{
+		Name:  "no-ipfs-config",
+		Usage: "Do no changes in the IPFS config that are necessary for brig. Use only when you know what you're doing.",
+	},
+	cli.BoolFlag{
+		Name:  "no-ipfs-optimization,o",
+		Usage: "Do no changes in the IPFS config that will improve the performance of brig, but are not necessary to work.",
+	},
+	cli.StringFlag{
+		Name:  "template,t",
+		Usage: "Preconfigure the repository for a use case. One of `photos`, `backup`, `shared-docs`.",
+	},
+	cli.BoolFlag{
+		Name:  "wizard",
+		Usage: "Ask a few questions to set up the repository. Recommended for first-time users.",
+	},
+	cli.StringFlag{
+		Name:  "hash-algo",
+		Usage: "Hash algorithm for the content of new files. One of `blake2s-256` (default), `blake3`.",
+	},
+	cli.StringFlag{
+		Name:  "metadata-backend",
+		Usage: "Database to store the metadata in. One of `badger` (default), `disk`. See »brig migrate-metadata«.",
+	},
+}
+
+// selectorFlags are shared by all commands that (un)pin files in bulk.
+var selectorFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "older-than",
+		Usage: "Only select files modified longer ago than this (like »90d«, »2w« or »36h«).",
+	},
+	cli.StringFlag{
+		Name:  "larger-than",
+		Usage: "Only select files bigger than this size (like »1G« or »500MB«).",
+	},
+	cli.StringSliceFlag{
+		Name:  "glob,g",
+		Usage: "Only select files whose path or name matches this pattern. Can be given more than once.",
+	},
+	cli.StringFlag{
+		Name:  "commits,c",
+		Usage: "Select the versions of a commit (»HEAD^«) or a commit range (»A..B«) instead of the current ones.",
+	},
+	cli.BoolFlag{
+		Name:  "dry-run,n",
+		Usage: "Only list the affected files and the reclaimable space; change nothing.",
+	},
+}
+
 var helpTexts = map[string]helpEntry{
 	"init": {
 		Usage:     "Initialize a new repository.",
 		ArgsUsage: "<username>",
 		Complete:  completeArgsUsage,
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:  "backend,b",
-				Value: "httpipfs",
-				Usage: "What data backend to use for the new repo. One of  `mock`, `httpipfs`. This cannot be changed later!",
-			},
-			cli.StringFlag{
-				Name:  "w,pw-helper",
-				Value: "",
-				Usage: "Password helper command. The stdout of this command is used as password.",
-			},
-			cli.BoolFlag{
-				Name:  "no-password,x",
-				Usage: "Use a static password. Not recommended besides testing.",
-			},
+		Flags: append([]cli.Flag{
 			cli.BoolFlag{
 				Name:  "empty,e",
 				Usage: "Do not create an initial README and no initial commit.",
@@ -50,24 +112,7 @@ var helpTexts = map[string]helpEntry{
 				Name:  "no-logo,n",
 				Usage: "Do not display the super pretty logo on init.",
 			},
-			cli.StringFlag{
-				Name:  "ipfs-path,P",
-				Usage: "Specify an explicit path to an IPFS repository. Useful if you have more than one.",
-				Value: "",
-			},
-			cli.BoolFlag{
-				Name:  "no-ipfs-setup",
-				Usage: "Do not try to install and setup IPFS.",
-			},
-			cli.BoolFlag{
-				Name:  "no-ipfs-config",
-				Usage: "Do no changes in the IPFS config that are necessary for brig. Use only when you know what you're doing.",
-			},
-			cli.BoolFlag{
-				Name:  "no-ipfs-optimization,o",
-				Usage: "Do no changes in the IPFS config that will improve the performance of brig, but are not necessary to work.",
-			},
-		},
+		}, initFlags...),
 		Description: `Initialize a new repository with a certain backend.
 
    If BRIG_PATH or --repo is set, the new repository will be created at this
@@ -89,11 +134,73 @@ var helpTexts = map[string]helpEntry{
    password. For testing you could use »-w "echo mypass"«, while for serious
    use, you should use something like »pass brig/desktop/password«.
 
+   With --template the repository is preconfigured for a certain use case:
+
+	photos       No compression, pre-caching, few kept versions, gateway enabled.
+	backup       Many kept versions, hourly commits, snapshots, no automatic syncing.
+	shared-docs  Frequent commits, automatic syncing, gateway and /public website.
+
+   Each template also creates a fitting top-level folder. The config can of
+   course be changed later with »brig cfg«. If you are unsure, use --wizard
+   instead. It asks a few questions, including the user name if not given.
+
 EXAMPLES:
 
 	# Easiest way to create a repository at ~/.brig
 	$ brig init ali@wonderland.org/rabbithole
 
+	# Create a repository for storing your photo collection:
+	$ brig init ali@wonderland.org/rabbithole --template photos
+
+	# Let brig guide you through the setup:
+	$ brig init --wizard
+
+`,
+	},
+	"clone": {
+		Usage:     "Initialize a new repository and fill it with the files of a remote.",
+		ArgsUsage: "<username> <remote-name> [<fingerprint>] [<folder>]",
+		Complete:  completeArgsUsage,
+		Flags: append([]cli.Flag{
+			cli.BoolFlag{
+				Name:  "verify,v",
+				Usage: "Get the fingerprint from the record published under the remote's domain.",
+			},
+			cli.BoolFlag{
+				Name:  "auto-update,a",
+				Usage: "Take automatic updates from the remote.",
+			},
+			cli.StringFlag{
+				Name:  "conflict-strategy,c",
+				Usage: "Which conflict strategy to apply (either »marker«, »ignore« or »embrace«)",
+				Value: "",
+			},
+			cli.StringSliceFlag{
+				Name:  "pin",
+				Usage: "Pin this folder after syncing, so its content is fetched. Can be given more than once.",
+			},
+		}, initFlags...),
+		Description: `Do »brig init«, »brig remote add« and »brig sync« in one step.
+
+   This is the quickest way to join a group of people that already share
+   files. A new repository is created like »brig init« would do, the remote
+   is added with the fingerprint they gave you and their files are synced
+   into your repository. The fingerprint may be left out when »--verify« is
+   given (see »brig remote add --help«).
+
+   Note that the remote has to add your fingerprint too before you can sync.
+   The sync is done directly after the daemon started, so tell them your
+   fingerprint (»brig whoami«) once the repository exists and retry with
+   »brig sync« if it failed.
+
+   Syncing only fetches the metadata. Use »--pin« to fetch the content of
+   some (or with »--pin /« of all) folders right away.
+
+EXAMPLES:
+
+	# Join alice and get all files of the photos folder:
+	$ brig clone bob@wonderland.org alice QmVA5j2JHPkDTHgZ[...]:SEfXUDeJA1toVnP[...] --pin /photos
+
 `,
 	},
 	"whoami": {
@@ -116,6 +223,10 @@ EXAMPLES:
 				Name:  "key,k",
 				Usage: "Only print the key portion of the fingerprint",
 			},
+			cli.BoolFlag{
+				Name:  "record,r",
+				Usage: "Print the record that can be published to let others verify your fingerprint",
+			},
 		},
 		Description: `This command prints your name, fingerprint and what store
    you are looking at. When you initialized your repository, you chose
@@ -126,6 +237,46 @@ EXAMPLES:
    # Show the fingerprint only:
    $ brig whoami -f
    QmUYz9dbqnYPyHCLUi7ghtiwFbdU93MQKFH4qg8iXHWcPV:W1q4vzbvLPUVwDUUXxjQfnuYJxq2CYqbeqXPSv7pUr5NcP
+`,
+	},
+	"key": {
+		Usage:    "Manage the rotating subkeys of your identity.",
+		Complete: completeArgsUsage,
+		Description: `Your identity is defined by the key pair that was created during »brig init«.
+   Its hash is part of your fingerprint and should therefore never change.
+
+   For daily use, short-lived subkeys are derived from it. They are signed by the
+   identity key and have an expiry date. A »session« subkey is used to
+   authenticate connections to other remotes, a »signing« subkey is used to sign
+   your commits. When fetching the complete store of a remote, the signature of
+   its latest commit is checked; set »repo.subkeys.require_signed_commits« to
+   refuse unsigned ones. Subkeys are rotated automatically by the daemon, see
+   the »repo.subkeys.*« config keys.
+
+   Without a subcommand, all subkeys are listed.
+`,
+	},
+	"key.list": {
+		Usage:       "List all subkeys and their expiry date.",
+		Complete:    completeArgsUsage,
+		Description: "The subkeys that are currently in use are marked in the CURRENT column.",
+	},
+	"key.rotate": {
+		Usage:     "Create a new subkey for »session« or »signing«.",
+		ArgsUsage: "<session|signing>",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "lifetime,l",
+				Usage: "How long the new subkey is valid (e.g. »48h«). Defaults to »repo.subkeys.lifetime«.",
+			},
+		},
+		Description: `Create a new subkey now, e.g. if you suspect that the old one was leaked.
+   The new key is used from now on, expired keys are deleted.
+
+EXAMPLES:
+
+   $ brig key rotate session --lifetime 24h
 `,
 	},
 	"remote": {
@@ -151,11 +302,31 @@ EXAMPLES:
 `,
 	},
 	"remote.add": {
-		Usage:       "Add/Update a remote under a handy name with their fingerprint.",
-		ArgsUsage:   "<name> <fingerprint>",
-		Complete:    completeArgsUsage,
-		Description: "",
+		Usage:     "Add/Update a remote under a handy name with their fingerprint.",
+		ArgsUsage: "<name> [<fingerprint>]",
+		Complete:  completeArgsUsage,
+		Description: `Add a remote with the name and fingerprint they gave you.
+
+   Remotes added without »--verify« are only trusted on first use (see »brig
+   remote trust«). If you only know the address of the remote, you can pass
+   it instead of the fingerprint; its key is then pinned on the first connection.
+
+   The fingerprint may be left out when »--verify« is given. In this case the
+   fingerprint published under the domain of the remote is used, but only if
+   it was fetched via https. Records in DNS are not authenticated and are
+   only used to check a fingerprint you passed explicitly. See »brig remote
+   verify --help« on how to publish a fingerprint.
+
+EXAMPLES:
+
+   # Compare the fingerprint with the one published on wonderland.org:
+   $ brig remote add --verify alice@wonderland.org QmVA5j2JHPkDTHgZ[...]:SEfXUDeJA1toVnP[...]
+`,
 		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "verify,v",
+				Usage: "Verify the fingerprint with the record published under the remote's domain.",
+			},
 			cli.BoolFlag{
 				Name:  "auto-update,a",
 				Usage: "Take automatic updates from this node.",
@@ -182,8 +353,9 @@ EXAMPLES:
 		Description: "Remove a remote by name.",
 	},
 	"remote.list": {
-		Usage:    "List all remotes and their online status",
-		Complete: completeArgsUsage,
+		Usage:     "List all remotes and their online status",
+		ArgsUsage: "[<remote> [<path>]]",
+		Complete:  completeArgsUsage,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "offline,o",
@@ -198,6 +370,10 @@ EXAMPLES:
    This goes over every entry in your remote list and prints by default
    the remote name, fingerprint, rountrip, last seen timestamp and settings.
 
+   The QUEUED column shows syncs, fetches and pushes that were requested
+   while the remote was offline. They are done once it is seen again
+   (see »net.offline_queue« in »brig cfg«).
+
    You can format the output by using »--format« with one the following attributes:
 
 	   * .Name
@@ -212,9 +388,15 @@ EXAMPLES:
    if we did not authenticate him yet. If you do not want this, you should use
    »--offline«.
 
+   If the name of a remote is given, its tree is listed instead, starting at
+   »path« (or »/«). This lets you see what you would get before syncing. The
+   remote only shows the folders it shares with you; directories that just
+   lead to a shared folder are shown without a size. The remote has to be online.
+
 EXAMPLES:
 
    $ brig rmt ls -f '{{ .Name }}'  # Show each remote name, line by line.
+   $ brig rmt ls alice /photos     # Show what alice shares with you in /photos.
 `,
 	},
 	"remote.clear": {
@@ -237,6 +419,179 @@ EXAMPLES:
    $ brig rmt ping
 `,
 	},
+	"remote.verify": {
+		Usage:     "Verify the fingerprint of remotes with the record published under their domain.",
+		ArgsUsage: "<name> [<name> ...]",
+		Complete:  completeArgsUsage,
+		Description: `Check that the fingerprint we have for a remote is the one that
+   was published by the owner of its domain. This saves you from comparing
+   fingerprints manually, if your organization publishes them.
+
+   For a remote called »alice@wonderland.org/laptop« the following places are
+   checked; if both exist, they have to agree:
+
+   * The TXT record of »_brig.wonderland.org«.
+   * The file »https://wonderland.org/.well-known/brig/fingerprints«.
+
+   Every record is a single line, the file may contain several of them.
+   The line for your own repository is printed by »brig whoami --record«:
+
+   v=brig1 name=alice@wonderland.org/laptop fp=QmVA5j2JHPkDTHgZ[...]:SEfXUDeJA1toVnP[...] key=[...] sig=[...]
+
+   The record contains your public key and is signed with it. Records with a
+   missing or wrong signature are ignored. The signature only proves that the
+   owner of the key claimed the name; that the name is theirs is only as
+   trustworthy as the domain it was published on. Records fetched via https
+   are authenticated by the certificate of the domain. DNS records are not,
+   so they are only used to confirm fingerprints you already know and never
+   to adopt a new one (see »brig remote add --verify«).
+
+EXAMPLES:
+
+   $ brig remote verify alice@wonderland.org/laptop
+`,
+	},
+	"remote.discover": {
+		Usage:    "Find other brig daemons on the local network.",
+		Complete: completeArgsUsage,
+		Description: `Ask the local network for other brig daemons and list them.
+
+   Every daemon announces its owner, address and the first part of its
+   fingerprint via mDNS over IPv4 and IPv6, unless »net.discovery.enabled«
+   is switched off. The IP column lists every address a daemon answered from.
+   Remotes you already know are shown with their name. To pair with a
+   daemon, add it with its address; its key is pinned on the first
+   connection. mDNS is not authenticated, so compare the fingerprint with
+   the one shown by »brig whoami« on the other machine before trusting it.
+
+EXAMPLES:
+
+   $ brig remote discover
+   OWNER  ADDR              FINGERPRINT                        IP            REMOTE
+   bob    QmUr4XK1c4Pt[...] QmUr4XK1c4Pt[...]:W1ZG8XQAi9ox2jUq 192.168.1.23  -
+   $ brig remote add bob QmUr4XK1c4Pt[...]
+`,
+		Flags: []cli.Flag{
+			cli.DurationFlag{
+				Name:  "timeout,t",
+				Value: 2 * time.Second,
+				Usage: "How long to wait for answers.",
+			},
+		},
+	},
+	"remote.head": {
+		Usage:     "Show the latest commit remotes advertised.",
+		ArgsUsage: "[<name> ...]",
+		Complete:  completeArgsUsage,
+		Description: `Every daemon publishes its latest commit under its backend key (IPNS),
+   unless »net.head_advertisement.enabled« is switched off. This command
+   fetches those records and tells if a remote has commits you did not
+   fetch yet, even if it is not online right now. Without arguments, all
+   remotes are checked.
+
+   The records are signed with the key of the remote; records with a bad
+   signature are reported as error. The record only tells that something
+   changed; the metadata itself can only be fetched while the remote is
+   online.
+
+EXAMPLES:
+
+   $ brig remote head bob
+   NAME  HEAD               INDEX  PUBLISHED             STATUS
+   bob   W1gX8NMQ9m8S[...]  12     2020-01-01T12:00:00Z  new commits
+`,
+	},
+	"remote.stats": {
+		Usage:     "Show how syncs with remotes went",
+		ArgsUsage: "[<name>]",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "last,l",
+				Usage: "Only count syncs in this time span (like »30d« or »12h«)",
+			},
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output according to a template",
+			},
+		},
+		Description: `Every sync is recorded with the number of files that changed,
+   the number of conflicts that had to be resolved, the size of the content
+   that was new to us and how long it took. This command sums those records
+   up per remote (or only for <name>), which helps to find remotes that
+   often end up in conflicts or whose syncs fail.
+
+   The rate is the share of changed files that were conflicts. The last
+   »net.sync_stats.max_records« syncs per remote are kept; the records of a
+   remote are removed together with it.
+
+EXAMPLES:
+
+   $ brig remote stats alice --last 30d
+   NAME   SYNCS  FAILED  FILES  CONFLICTS  RATE  BYTES   AVG TIME  LAST SYNC
+   alice  42     1       310    12         3.9%  1.2 GB  1.52s     2020-01-01T12:00:00Z
+`,
+	},
+	"remote.delegate": {
+		Usage:     "Let a peer read a folder of everyone that trusts you with it",
+		ArgsUsage: "<remote-or-fingerprint> <folder>",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "lifetime,l",
+				Value: "720h",
+				Usage: "How long the certificate is valid",
+			},
+		},
+		Description: `Print a signed certificate that allows the peer with the given key
+   to read <folder>. The peer does not need to be a remote of the ones
+   storing the folder (friend-of-friend sharing). Send the certificate
+   to the peer; it adds it with »brig remote delegation add«.
+
+   The peer shows the certificate to every remote it connects to. A remote
+   accepts it if »net.delegation.accept« is enabled there, it knows you and
+   you may read <folder> there yourself. The peer can then sync the folder
+   and fetch its content from that remote, but can not push to it.
+
+   The peer is given by a remote name or by its fingerprint
+   (see »brig whoami --fingerprint«).
+
+EXAMPLES:
+
+   $ brig remote delegate carol /photos --lifetime 24h > carol.cert
+   $ brig remote delegate QmAddr:W1KeyID /public
+`,
+	},
+	"remote.delegation": {
+		Usage:    "Manage the certificates other users issued to you",
+		Complete: completeSubcommands,
+		Description: `Certificates made with »brig remote delegate« let you read a folder
+   of everyone that trusts the issuer with it. They are shown to every
+   remote you connect to and forgotten once they expire.
+
+   If you do not specify any subcommand, this is a shortcut for »brig rmt dg ls«`,
+	},
+	"remote.delegation.add": {
+		Usage:     "Store a certificate issued to you",
+		ArgsUsage: "<certificate|file|->",
+		Complete:  completeArgsUsage,
+		Description: `The certificate can be given directly, as file or via stdin (»-«).
+
+EXAMPLES:
+
+   $ brig remote delegation add carol.cert
+   $ cat carol.cert | brig remote delegation add -
+`,
+	},
+	"remote.delegation.list": {
+		Usage:    "List the certificates issued to you",
+		Complete: completeArgsUsage,
+	},
+	"remote.delegation.remove": {
+		Usage:     "Forget the certificates of an issuer for a folder",
+		ArgsUsage: "<issuer> <folder>",
+		Complete:  completeArgsUsage,
+	},
 	"remote.edit": {
 		Usage:    "Edit the current list.",
 		Complete: completeArgsUsage,
@@ -250,6 +605,70 @@ EXAMPLES:
 		Description: `
    Edit the current list using $EDITOR as YAML file.
    It will be updated once you exit your editor.`,
+	},
+	"remote.export": {
+		Usage:    "Export all remotes to move them to another machine",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Value: "yaml",
+				Usage: "Output format (yaml or json)",
+			},
+			cli.StringFlag{
+				Name:  "output,o",
+				Value: "",
+				Usage: "Write to this file instead of stdout",
+			},
+		},
+		Description: `Write the remote list with names, fingerprints, folders, settings
+   and per-remote config overrides as one document. It can be read again by
+   »brig remote import«, which makes setting up another device easier.
+
+EXAMPLES:
+
+	# Export all remotes as YAML:
+	$ brig remote export -o remotes.yml
+
+	# Or as JSON, directly into another daemon:
+	$ brig remote export -f json | brig --repo /other/repo remote import -
+`,
+	},
+	"remote.import": {
+		Usage:     "Import remotes written by »brig remote export«",
+		ArgsUsage: "<file or ->",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "on-conflict,c",
+				Value: "skip",
+				Usage: "What to do with remotes that exist already (skip, overwrite, merge or fail)",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run,d",
+				Usage: "Only show what would be done",
+			},
+		},
+		Description: `Add all remotes of an export to the remote list. Both YAML and JSON are read.
+
+   Remotes whose fingerprint is known already under another name and the entry
+   for this repository itself are always skipped. For remotes with a name that
+   exists already, --on-conflict decides:
+
+   * skip: Leave the existing remote alone (default).
+   * overwrite: Replace it with the imported one.
+   * merge: Add missing folders and config overrides, but keep all local settings.
+     Fails if the fingerprints differ, since that is a different peer.
+   * fail: Change nothing if there is any conflict.
+
+EXAMPLES:
+
+	# See what would happen first:
+	$ brig remote import --dry-run remotes.yml
+
+	# Add the folders of the export to existing remotes:
+	$ brig remote import --on-conflict merge remotes.yml
+`,
 	},
 	"remote.auto-update": {
 		Usage:    "Enable auto-updating for this remote",
@@ -286,6 +705,39 @@ EXAMPLES:
    brig rmt ap e bob charlie
 `,
 	},
+	"remote.trust": {
+		Usage:     "Review and change how much a remote is trusted.",
+		ArgsUsage: "[verified|tofu|blocked <name>...]",
+		Complete:  completeArgsUsage,
+		Description: `Every remote has one of three trust levels:
+
+   - verified: The fingerprint was compared with its owner or checked via »--verify«.
+   - tofu:     Trust on first use. The fingerprint was taken as it was given,
+               or (if only the address was given) as it was seen on the first connection.
+   - blocked:  No connections from or to this remote are allowed.
+
+   Without arguments, all remotes that are only trusted on first use are listed.
+   With »--check« a remote is only marked as verified if the fingerprint
+   matches the one published under its domain (see »brig remote verify«).
+
+EXAMPLES:
+
+   # Show remotes whose fingerprint was never checked:
+   $ brig remote trust
+
+   # After comparing the fingerprint with bob on the phone:
+   $ brig remote trust verified bob
+
+   # Stop talking to charlie:
+   $ brig remote trust blocked charlie
+`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "check,c",
+				Usage: "Check the fingerprint with the record published under the remote's domain before marking it as verified.",
+			},
+		},
+	},
 	"remote.conflict-strategy": {
 		Usage:    "Change what conflict resolution strategy is used on conflicts.",
 		Complete: completeArgsUsage,
@@ -306,6 +758,29 @@ EXAMPLES:
 
    # or shorter to prevent you from RSI:
    brig rmt cs embrace bob charlie
+`,
+	},
+	"remote.config": {
+		Usage:     "Override config keys for a single remote.",
+		ArgsUsage: "<name> [list | get <key> | set <key> <value> | unset <key>]",
+		Complete:  completeArgsUsage,
+		Description: `Some config keys can be set differently for every remote,
+   e.g. the conflict strategy or conflict hooks of a specific peer.
+   Overrides are stored in the remote list and applied whenever brig talks
+   to this remote; all other remotes still use the normal config.
+   The "fs." prefix of the keys can be left out.
+
+   Without action, all keys that can be overridden are listed along with
+   their value for this remote and the global value.
+
+EXAMPLES:
+
+   $ brig remote config bob set sync.conflict_strategy embrace
+   $ brig remote config bob
+   KEY                             VALUE    GLOBAL
+   fs.sync.conflict_strategy       embrace  marker
+   [...]
+   $ brig remote config bob unset sync.conflict_strategy
 `,
 	},
 	"remote.folder": {
@@ -383,6 +858,7 @@ EXAMPLES:
 		Usage:     "Commands to handle the pin state.",
 		ArgsUsage: "<file>",
 		Complete:  completeBrigPath(true, true),
+		Flags:     selectorFlags,
 		Description: `Pinning a file to keep it in local storage.
 
    When you retrieve a file from a remote machine, the file will be cached (or
@@ -397,22 +873,36 @@ EXAMPLES:
    This command contains the subcommand 'add', but for usability reasons, »brig
    pin add <path>« is the same as »brig pin <path>«.
 
+   Instead of a single path, many files can be selected at once by their age
+   (»--older-than«), their size (»--larger-than«), their name (»--glob«) or
+   the commits they were part of (»--commits«). The path is then optional and
+   restricts the selection to this directory. Use »--dry-run« to see what
+   would be selected first.
+
    See also the »gc« command as counterpart of pinning.
+
+EXAMPLES:
+
+   $ brig pin --glob '*.jpg' /photos          # Pin all jpgs below /photos.
+   $ brig pin rm --older-than 90d --dry-run   # Show what an unpin would free.
+   $ brig pin rm --commits 'INIT..HEAD^'       # Unpin versions of older commits.
 `,
 	},
 	"pin.add": {
 		Usage:     "Pin a file or directory to local storage",
 		ArgsUsage: "<file>",
 		Complete:  completeBrigPath(true, true),
+		Flags:     selectorFlags,
 		Description: `A node that is pinned to local storage will not be
-   deleted by the garbage collector.`,
+   deleted by the garbage collector. See »brig pin --help« for the selectors.`,
 	},
 	"pin.remove": {
 		Usage:     "Remove a pin",
 		ArgsUsage: "<file>",
 		Complete:  completeBrigPath(true, true),
+		Flags:     selectorFlags,
 		Description: `A node that is pinned to local storage will not be
-   deleted by the garbage collector.`,
+   deleted by the garbage collector. See »brig pin --help« for the selectors.`,
 	},
 	"pin.repin": {
 		Usage:     "Recaculate pinning based on fs.repin.{quota,min_depth,max_depth}",
@@ -589,12 +1079,39 @@ EXAMPLES:
    name or anything else).  The circumflex can be used more than once to go
    back further.
 
+   If »fs.snapshots.enabled« is set, brig tags HEAD automatically every hour,
+   day, week and month. Those tags are called like »snapshot-daily-2019-01-31«
+   and only a configurable number of them is kept per tier (see the
+   »fs.snapshots.keep_*« config keys). They can be used like any other tag,
+   e.g. to reset a file to an earlier state. »brig snapshots« lists them.
+   Names starting with »snapshot-« are reserved for them.
+
 EXAMPLES:
 
    $ brig tag SEfXUAH6AR my-tag-name   # Name the commit SEfXUAH6AR 'my-tag-name'.
    $ brig tag -d my-tag-name           # Delete the tag name again.
    $ brig tag HEAD^ previous-head      # Tag the commit before the current HEAD with "previous-head".
    $ brig tag 'commit[1]' second       # Tag the commit directly after init with "second".
+`,
+	},
+	"snapshots": {
+		Usage:    "List the snapshots that were taken automatically",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output according to a template",
+			},
+		},
+		Description: `List all existing snapshots, oldest first per tier.
+
+   Snapshots are tags of HEAD that brig creates every hour, day, week and
+   month if »fs.snapshots.enabled« is set. See »brig help tag« for details.
+
+EXAMPLES:
+
+   $ brig snapshots                       # Show all snapshots.
+   $ brig snapshots --format '{{ .Tag }}' # Only print the tag names.
 `,
 	},
 	"log": {
@@ -605,12 +1122,23 @@ EXAMPLES:
 				Name:  "format,f",
 				Usage: "Format the output according to a template",
 			},
+			cli.StringFlag{
+				Name:  "label,l",
+				Usage: "Only show commits with this label",
+			},
 		},
 		Description: `Show a list of commits from a start (--from) up to and end (--to).
    If omitted »--from INIT --to CURR« will be assumed.
 
    The output will show one commit per line, each including the (short) hash of the commit,
-   the date it was committed and the (optional) commit message.
+   the date it was committed, the (optional) commit message and its labels.
+   Each commit also knows the device and the part of brig (cli, fuse, gateway,
+   sync or auto) that made it; use »--format« or »brig show <commit>« to see those.
+
+EXAMPLES:
+
+   $ brig log --label backup
+   $ brig log --format '{{ .Hash.ShortB58 }} {{ .Device }} {{ .Origin }}'
 `,
 	},
 	"fetch": {
@@ -639,12 +1167,17 @@ EXAMPLES:
 				Name:  "quiet,q",
 				Usage: "Do not print what changed.",
 			},
+			cli.BoolFlag{
+				Name:  "dry-run,d",
+				Usage: "Only print what would change, without syncing.",
+			},
 		},
 		Description: `Sync and merge all metadata of another peer with our metadata.
    After this operation you might see new files in your folder.
    Those files were not downloaded yet and will be only on the first access.
 
-   It is recommended that your first check what will be synced with »brig diff«.
+   It is recommended that your first check what will be synced with »brig diff«
+   or »brig sync --dry-run«, which also tells how much data would be transferred.
 
    When passing no arguments, 'sync' will synchronize with all online remotes.
    When passing a single argument, it will be used as the remote name to sync with.
@@ -663,19 +1196,99 @@ EXAMPLES:
 	Files from other remotes are not pinned automatically.
 `,
 	},
-	"push": {
-		Usage:    "Ask a remote to sync with us.",
-		Complete: completeArgsUsage,
-		Flags: []cli.Flag{
-			cli.BoolFlag{
-				Name:  "dry-run,d",
-				Usage: "Do not the actual push, but check if we may push.",
-			},
-		},
-		Description: ``,
+	"compress": {
+		Usage: "Manage dictionaries for compressing small files",
+		Description: `Small files compress badly on their own. If you have many small files of the
+   same type (like source code or logs), brig can learn what they have in common
+   and use that as dictionary when compressing them. This often makes them a lot
+   smaller. Without subcommand, the current dictionaries are listed.
+
+   Dictionaries are only used if »fs.compress.dictionaries« is enabled.
+   They are sent to other remotes on sync, so they can read the files too.
+`,
 	},
-	"commit": {
-		Usage:    "Create a new commit",
+	"compress.train": {
+		Usage: "Train a dictionary for every type of small files",
+		Description: `Take a sample of the small files in the repository and train a dictionary
+   for every file type with enough files. The size of the dictionaries can be set
+   with »fs.compress.dictionary_size«. Old dictionaries are kept, so files
+   compressed with them can still be read, but new files use the new ones.
+
+   Training reads the content of the sampled files, so it might take a while
+   if they are not cached locally.
+
+EXAMPLES:
+
+	$ brig cfg set fs.compress.dictionaries true
+	$ brig compress train
+`,
+	},
+	"compress.list": {
+		Usage: "List the dictionaries used for new files",
+	},
+	"bundle": {
+		Usage: "Move changes to other remotes without a network connection",
+		Description: `A bundle is a signed file with your changes between two commits,
+   including the content of all added or modified files. It can be sent via mail
+   or carried on a usb stick to remotes that cannot reach you over the network.
+   Applying it there works like a sync with you.
+
+   The receiving side needs to know you as a remote already (see »brig remote add«),
+   since the signature of the bundle is checked against your fingerprint.
+`,
+	},
+	"bundle.create": {
+		Usage:     "Create a bundle with all changes in a range of commits",
+		ArgsUsage: "<from>..<to>",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output,o",
+				Usage: "Write the bundle to this file instead of stdout",
+			},
+		},
+		Description: `Create a bundle with the changes after <from> up to and including <to>.
+   Both are revisions as understood by »brig log«. If <to> is left out, HEAD is used.
+
+   The remote that applies the bundle needs to have all changes up to <from>,
+   either by an earlier bundle or a sync. Otherwise it refuses to apply it.
+   The content of the changed files needs to be available locally.
+
+EXAMPLES:
+
+	# Everything since the first commit:
+	$ brig bundle create commit[0]..HEAD -o changes.bundle
+	# Only the last commit, written to stdout:
+	$ brig bundle create HEAD^..HEAD > last.bundle
+`,
+	},
+	"bundle.apply": {
+		Usage:     "Apply a bundle created by another remote",
+		ArgsUsage: "<path>",
+		Complete:  completeArgsUsage,
+		Description: `Check the signature of the bundle, apply it to our copy of the remote
+   that created it and sync with it. Use »-« as path to read from stdin.
+
+   Applying a bundle that contains nothing new does nothing.
+
+EXAMPLES:
+
+	$ brig bundle apply /media/usb/changes.bundle
+`,
+	},
+	"push": {
+		Usage:    "Ask a remote to sync with us.",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run,d",
+				Usage: "Do not the actual push, but check if we may push.",
+			},
+		},
+		Description: ``,
+	},
+	"commit": {
+		Usage:    "Create a new commit",
 		Complete: completeArgsUsage,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -683,6 +1296,10 @@ EXAMPLES:
 				Value: "",
 				Usage: "Provide a meaningful commit message.",
 			},
+			cli.StringSliceFlag{
+				Name:  "label,l",
+				Usage: "Attach a label to the commit (can be given several times).",
+			},
 		},
 		Description: `Create a new commit.
 
@@ -697,9 +1314,19 @@ EXAMPLES:
 
    You normally do not need to issue this command manually, since there is a
    loop inside of brig that will auto-commit every 5 minute (default; see the
-   "fs.autocommit.interval" config key). Sync operations will also create
-   commits implicitly and every change from the gateway side will also result
-   in a commit.
+   "fs.autocommit.interval" config key). With "fs.autocommit.idle" set, it
+   also commits once your changes settled down for that long. The message of
+   those commits is built from "fs.autocommit.message", which may contain
+   placeholders like {num_files} or {hostname} (see »brig config doc«).
+   Sync operations will also create commits implicitly and every change from
+   the gateway side will also result in a commit.
+
+   Commits can carry free-form labels (»--label«) which can be used to find
+   them again with »brig log --label«.
+
+EXAMPLES:
+
+   $ brig commit -m 'before cleanup' --label backup
 `,
 	},
 	"reset": {
@@ -725,6 +1352,36 @@ EXAMPLES:
    the previous state. In other words: the reset operation of brig is not
    destructive. If you notice that you do not like the state you've reseted to,
    »brig reset head« will bring you back to the last known good state.
+`,
+	},
+	"undo": {
+		Usage:     "Revert the last commit(s) with a new commit.",
+		ArgsUsage: "[<number of commits>]",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "yes,y",
+				Usage: "Do not ask for confirmation",
+			},
+		},
+		Description: `Revert what the last commit (or the last »<number of commits>«)
+   did, for example after deleting a whole directory by accident. brig lists
+   exactly which files would be restored, removed or reverted and asks before
+   changing anything.
+
+   In contrast to »reset«, »undo« does not only change the staging area; it
+   makes a new commit right away. The history stays as it is, so running
+   »brig undo« once more brings you back again. Removed files are also deleted
+   on remotes during the next sync, restored files are not deleted again.
+
+   The staging area has to be empty. Commit or reset your changes before.
+
+EXAMPLES:
+
+   $ brig rm /photos
+   $ brig commit -m 'cleanup'
+   $ brig undo              # Bring back /photos.
+   $ brig undo 3 --yes      # Revert the last three commits without asking.
 `,
 	},
 	"become": {
@@ -757,6 +1414,10 @@ EXAMPLES:
 				Name:  "empty,e",
 				Usage: "Also show commits where nothing happens",
 			},
+			cli.BoolFlag{
+				Name:  "blocks,b",
+				Usage: "Show how much of each modified version was unchanged",
+			},
 		},
 		Description: `Show a list of all changes that were made to this path.
 
@@ -779,6 +1440,14 @@ EXAMPLES:
    - moved & modified: The file was moved and modified.
    - add & modified: The file was removed before and now re-added with different content.
    - moved & removed: The file was moved to another location.
+
+   With »--blocks«, modifications of files are compared block by block with the
+   version before. The »CHANGED« column then shows how much of the file is new.
+   Only those parts need to be fetched when syncing the new version.
+
+EXAMPLES:
+
+   $ brig history --blocks /photos/album.db
 `,
 	},
 	"stage": {
@@ -827,6 +1496,11 @@ EXAMPLES:
 				Name:  "offline,o",
 				Usage: "Only output the file if it is cached locally.",
 			},
+			cli.StringFlag{
+				Name:  "at,a",
+				Value: "",
+				Usage: "Output the content as it was at this commit or time.",
+			},
 		},
 		Description: `Decrypt and decompress the stream from IPFS and write it to standard output.
 
@@ -836,6 +1510,11 @@ EXAMPLES:
 
    When no path is specified, »/« is assumed and all contents are outputted as tar.
 
+   With »--at« an older version of the path is shown. It accepts anything
+   that names a commit (see »brig log«) or a timestamp like »2023-01-01«,
+   »2023-01-01 12:00« or »2023-01-01T12:00:00+01:00«. A timestamp selects the
+   last commit that was made at or before it. Staged changes are never shown.
+
 EXAMPLES:
 
    # Output a single file:
@@ -844,6 +1523,54 @@ EXAMPLES:
    $ brig cat | tar xfv -
    # Create .tar.gz out of of the /photos directory.
    $ brig cat photos | gzip -f > photos.tar.gz
+   # Output the file as it was at new year:
+   $ brig cat --at '2023-01-01' photo.png
+`,
+	},
+	"archive": {
+		Usage:     "Export a file or directory as tar or zip archive",
+		ArgsUsage: "[<path>]",
+		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "rev,r",
+				Value: "curr",
+				Usage: "Export the state of this commit.",
+			},
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "One of »tar«, »tar.gz« or »zip«. Guessed from --output if not given (default: tar).",
+			},
+			cli.StringFlag{
+				Name:  "output,o",
+				Usage: "Write the archive to this file instead of standard output.",
+			},
+			cli.StringSliceFlag{
+				Name:  "encrypt-to,e",
+				Usage: "Encrypt the archive for the OpenPGP public key in this file. Can be given several times.",
+			},
+			cli.BoolFlag{
+				Name:  "symmetric,s",
+				Usage: "Encrypt the archive with a passphrase. Needs --output.",
+			},
+		},
+		Description: `Write the contents of <path> (»/« if not given) as archive, for handing
+   data to people who do not use brig. The content is streamed file by file,
+   so even big directories do not need to fit into memory or a temporary
+   directory. With --rev, any older state of the path can be exported.
+
+   The archive can be encrypted with OpenPGP, either for the public keys of
+   the receivers (--encrypt-to) or with a passphrase (--symmetric). Both can
+   be decrypted with »gpg --decrypt«.
+
+EXAMPLES:
+
+   # Hand the photos directory to somebody as zip file:
+   $ brig archive photos -o photos.zip
+   # Export the state three commits ago, encrypted for bob:
+   $ brig archive --rev HEAD^^^ -e bob.asc -o docs.tar.gpg /docs
+   # Unpack the current state into the current directory:
+   $ brig archive | tar xfv -
 `,
 	},
 	"show": {
@@ -870,6 +1597,11 @@ EXAMPLES:
    ContentHash: Content hash of the file before encryption.
    BackendHash: Hash of the node in ipfs (ipfs cat <this hash>)
    TreeHash: Hash that is unique to this node.
+   MimeType: Type of the content as detected when the file was staged.
+   Kind: Rough category of the type (»text«, »image«, »audio«, »video«,
+         »archive«, »document« or »binary«).
+
+   Files staged with older versions of brig have no type.
 `,
 	},
 	"rm": {
@@ -904,12 +1636,51 @@ EXAMPLES:
 				Name:  "format,f",
 				Usage: "Format the output according to a template",
 			},
+			cli.StringFlag{
+				Name:  "sort,s",
+				Usage: "Sort by »name«, »size« (largest first) or »mtime« (newest first)",
+				Value: "name",
+			},
+			cli.BoolFlag{
+				Name:  "reverse,r",
+				Usage: "Reverse the sort order",
+			},
+			cli.BoolFlag{
+				Name:  "tree,t",
+				Usage: "Show the entries as tree (recursive unless --depth is given)",
+			},
+			cli.StringFlag{
+				Name:  "filter,F",
+				Usage: "Only show entries whose name matches this glob (e.g. '*.jpg')",
+			},
+			cli.BoolFlag{
+				Name:  "versions",
+				Usage: "Show how many versions of each file exist (slower)",
+			},
 		},
 		Description: `List files an directories starting with »path«.
    If no »<path>« is given, the root directory is assumed. Every line of »ls«
    shows a human readable size of each entry, the last modified time stamp, the
    user that last modified the entry (if there's more than one) and if the
    entry if pinned.
+
+   When printing to a terminal, paths that are too long are shortened to fit
+   the terminal width. When the output is piped, colors and the header are left
+   out, so every line is an entry.
+
+   With »--filter« only entries whose name matches the glob are shown. In
+   »--tree« mode, directories are always shown.
+
+EXAMPLES:
+
+	# Show the smallest files first:
+	$ brig ls --sort size --reverse
+
+	# Show all jpgs below /photos:
+	$ brig ls -R --filter '*.jpg' /photos
+
+	# Show the first two levels as tree:
+	$ brig ls --tree --depth 2
 `,
 	},
 	"tree": {
@@ -924,6 +1695,139 @@ EXAMPLES:
 			},
 		},
 		Description: `Show entries in a tree(1)-like fashion.
+`,
+	},
+	"du": {
+		Usage:     "Show the space used by each entry of a directory",
+		ArgsUsage: "[<path>]",
+		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format each entry according to a template",
+			},
+			cli.BoolFlag{
+				Name:  "sort,s",
+				Usage: "Show the largest entries first",
+			},
+		},
+		Description: `Show how much space each direct child of »path« (or / if not given) uses.
+
+   For every child the following sizes are shown:
+
+   - SIZE: The sum of all file sizes below the child.
+   - UNIQUE: The size of contents that no file outside of the child uses,
+     not even an older version. This is roughly what removing the child
+     and pruning the history would free.
+   - PINNED: The size of the pinned contents below the child. Every content
+     is only counted once.
+
+   Unlike »brig stat --usage« this does not walk the history and is cheap
+   enough to be used on large trees. If »path« is a file, only the file is shown.
+
+   The fields available in »--format« are: .Path, .IsDir, .Files,
+   .LogicalSize, .UniqueSize and .PinnedSize.
+
+EXAMPLES:
+
+   $ brig du
+   $ brig du --sort /photos
+   $ brig du --format '{{.LogicalSize}} {{.Path}}' /
+`,
+	},
+	"stat": {
+		Usage:     "Show all details of a node or the space usage below it",
+		ArgsUsage: "[<path>]",
+		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output according to a template",
+			},
+			cli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Print the details as json",
+			},
+			cli.BoolFlag{
+				Name:  "usage,u",
+				Usage: "Show space usage and deduplication statistics instead",
+			},
+		},
+		Description: `Show everything that is known about the node at »path« (or / if not given).
+
+   Next to the attributes also shown by »brig show« this includes how many
+   versions of the node exist and which commits touched it. With »--json«
+   the same details are printed in a machine readable way.
+
+   With »--usage« it shows how much space the files below »path« take up
+   instead. Files with the same content are stored only once. The report
+   shows the logical size (sum of all file sizes), the unique size (every
+   content counted once) and how much deduplication saved. For contents that
+   are cached locally it also shows how much space they take up in the backend
+   after compression and encryption. The ratio is stored size divided by
+   original size.
+
+   »Old versions« is the size of contents that are only referenced by older
+   commits. This is roughly what could be freed by pruning the history.
+
+   Below that, the usage of every direct sub directory is listed.
+
+EXAMPLES:
+
+   $ brig stat /photos/me.png
+   $ brig stat --json /photos/me.png
+   $ brig stat --format '{{ .Versions }}' /photos/me.png
+   $ brig stat --usage /photos
+   $ brig stat --usage --format '{{ .UniqueSize }}'
+`,
+	},
+	"search": {
+		Usage:     "Find files by name, path or type",
+		ArgsUsage: "<words...>",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "path,p",
+				Value: "/",
+				Usage: "Only search below this directory",
+			},
+			cli.StringFlag{
+				Name:  "type,t",
+				Usage: "Only show files of this extension, kind or mime type",
+			},
+			cli.IntFlag{
+				Name:  "offset,o",
+				Usage: "Skip this many results",
+			},
+			cli.IntFlag{
+				Name:  "limit,l",
+				Value: 20,
+				Usage: "Show at most this many results (0 for all)",
+			},
+			cli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Print the results as json",
+			},
+		},
+		Description: `Search for files whose path contains all of the given words.
+
+   Case does not matter. The best matches are shown first: words that match
+   the whole file name or a part of it that is separated by dots, dashes or
+   underscores count most, followed by matches inside of the file name and
+   matches in one of the parent directories. Equally good matches are sorted
+   by modification time, newest first.
+
+   »--type« takes an extension (»pdf«), a kind as shown by »brig stat«
+   (»image«, »document«, ...) or a mime type (»image/png«, »image/*«).
+
+   Results are shown in pages of »--limit« entries; use »--offset« to
+   see the next page. »--json« prints the results and the total number
+   of matches in a machine readable way.
+
+EXAMPLES:
+
+   $ brig search --path /docs --type pdf invoice 2023
+   $ brig search --type image --limit 50 holiday
+   $ brig search --offset 20 --json report
 `,
 	},
 	"mkdir": {
@@ -942,26 +1846,69 @@ EXAMPLES:
 `,
 	},
 	"mv": {
-		Usage:     "Move a file or directory from »src« to »dst«",
-		ArgsUsage: "<src> <dst>",
+		Usage:     "Move files or directories from »src« to »dst«",
+		ArgsUsage: "<src> [<src> ...] <dst>",
 		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "into-commit,c",
+				Usage: "Make a commit with this message right after moving",
+			},
+			cli.BoolFlag{
+				Name:  "verbose,v",
+				Usage: "Print the new path of every moved file or directory",
+			},
+		},
 		Description: `Move a file or directory from »src« to »dst.«
 
    If »dst« already exists and is a file, it gets overwritten with »src«.
    If »dst« already exists and is a directory, »basename(src)« is created inside,
    (if the file inside does not exist yet)
 
+   Several sources can be given, each of them may be a glob pattern like »*.jpg«
+   (quote them, so your shell does not expand them). If more than one path matches
+   or »dst« ends with a slash, everything is moved into the directory »dst«,
+   which is created if needed. Either all paths are moved or none at all.
+
    It's not allowed to move a directory into itself.
    This includes moving the root directory.
+
+   With »--into-commit« a commit is made right after the move. Note that
+   this commit also includes all other changes that were not committed yet.
+
+EXAMPLES:
+
+   $ brig mv /photos/'*.jpg' /raw/'*.cr2' /archive/2019/
+   $ brig mv -c "rename report" /report.txt /report-2019.txt
 `,
 	},
 	"cp": {
-		Usage:     "Copy a file or directory from »src« to »dst«",
-		ArgsUsage: "<src> <dst>",
+		Usage:     "Copy files or directories from »src« to »dst«",
+		ArgsUsage: "<src> [<src> ...] <dst>",
 		Complete:  completeBrigPath(true, true),
-		Description: `Copy a file or directory from »src« to »dst«.
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "recursive,r",
+				Usage: "Also copy directories with all their contents",
+			},
+			cli.StringFlag{
+				Name:  "into-commit,c",
+				Usage: "Make a commit with this message right after copying",
+			},
+			cli.BoolFlag{
+				Name:  "verbose,v",
+				Usage: "Print the new path of every copied file or directory",
+			},
+		},
+		Description: `Copy files or directories from »src« to »dst«.
 
    The semantics are the same as for »brig mv«, except that »cp« does not remove »src«.
+   Directories are only copied with »--recursive«.
+
+EXAMPLES:
+
+   $ brig cp -r /docs /backup/
+   $ brig cp '/*.txt' /notes/
 `,
 	},
 	"edit": {
@@ -1004,11 +1951,15 @@ EXAMPLES:
 		},
 		Description: `Start the dameon process in the foreground.
 
+Sending SIGUSR2 to a running daemon restarts it gracefully: A new daemon
+(e.g. from an upgraded binary) takes over the sockets, while the old one
+finishes its running requests (see daemon.restart_drain_timeout).
 
 EXAMPLES:
 
    $ brig daemon quit        # Shut down any previous daemon.
    $ brig daemon launch -s   # Start in foreground and log to stdout.
+   $ pkill -USR2 -f 'brig daemon launch'  # Restart without dropping connections.
 `,
 	},
 	"daemon.quit": {
@@ -1033,6 +1984,86 @@ EXAMPLES:
 			},
 		},
 	},
+	"daemon.status": {
+		Usage:    "Show the state of the daemon's background jobs",
+		Complete: completeArgsUsage,
+		Description: `Show the state of the daemon's background jobs.
+
+   Currently this shows the integrity scrubber, which re-reads pinned content
+   from time to time and checks the MAC of every encrypted block (see the
+   »fs.scrub.*« config keys). Files with corrupt content are listed, together with the
+   info if they could be fetched again from a remote.
+
+EXAMPLES:
+
+   $ brig daemon status
+`,
+	},
+	"stats": {
+		Usage:    "Show local usage statistics of the daemon",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "reset,r",
+				Usage: "Set all counters back to zero after showing them",
+			},
+			cli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Print the statistics as json",
+			},
+		},
+		Description: `Show what the daemon did since it was started or since the last reset.
+
+   The statistics contain the uptime, how often each operation was called
+   (and how often it failed), how many syncs were done with each remote and
+   how much metadata was fetched from it, and the hit rate of the in-memory
+   node cache (see »fs.node_cache_size«).
+
+   The counters are only kept in memory and are lost when the daemon quits.
+   They never leave this machine. »--reset« shows the counters one last time
+   and starts counting from zero again; the uptime is not affected.
+
+EXAMPLES:
+
+   $ brig stats
+   $ brig stats --json | jq '.remotes'
+   $ brig stats --reset
+`,
+	},
+	"top": {
+		Usage:    "Show live what the daemon is doing",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "interval,i",
+				Value: "1s",
+				Usage: "How often the view is refreshed",
+			},
+			cli.BoolFlag{
+				Name:  "once,o",
+				Usage: "Print the view once and exit",
+			},
+		},
+		Description: `Show a view of the daemon that is refreshed every »--interval«.
+
+   Unlike »brig stats«, which counts what the daemon did, this shows what it
+   is doing right now:
+
+   - The transfers with remotes that are running, and how much they moved.
+   - The calls that are being served, grouped by their name.
+   - The open file handles and pending writes of each FUSE mount.
+   - The depth of the offline, pre-cache and writeback queues.
+   - The bandwidth used with each remote, and the total since the daemon started.
+
+   Press Ctrl-C to quit.
+
+EXAMPLES:
+
+   $ brig top
+   $ brig top --interval 200ms
+   $ brig top --once
+`,
+	},
 	"config": {
 		Usage:    "View and modify config options.",
 		Complete: completeSubcommands,
@@ -1043,8 +2074,16 @@ EXAMPLES:
    to many other programs the config is applied immediately after setting it (where possible).
    Furthermore, each config key will describe itself and tell you if it needs a restart.
 
+   Each key has a type and values that do not fit it are rejected. You can also
+   edit »config.yml« in the repository directly: the daemon reloads it when it
+   changes and applies all keys that do not need a restart.
+
    For more details on each config value, type 'brig config ls'.
 
+   Aliases for commands and flags that are always passed to a command can be
+   configured with »cli.aliases« and »cli.default_flags«. They are read from
+   »config.yml« before any command runs, even if no daemon is running.
+
    Without further arguments »brig cfg« is a shortcut for »brig cfg ls«.
 `,
 	},
@@ -1060,8 +2099,8 @@ EXAMPLES:
 		ArgsUsage: "<key>",
 		Description: `For each config key a few metadata entries are assigned.
 
-This includes a string describing the usage, the default value and an indicator
-if the service needs a restart when setting the value.
+This includes a string describing the usage, the type, the default value and an
+indicator if the service needs a restart when setting the value.
 
 `,
 	},
@@ -1123,6 +2162,56 @@ if the service needs a restart when setting the value.
 			},
 		},
 	},
+	"watch": {
+		Usage:    "Print or react on events of the daemon as they happen",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "name,n",
+				Usage: "Remember what was seen under this name and continue there next time.",
+			},
+			cli.StringSliceFlag{
+				Name:  "kind,k",
+				Usage: "Only show events of this kind (commit, fs, sync, remote-seen, conflict, pairing, transfer).",
+			},
+			cli.StringFlag{
+				Name:  "exec,e",
+				Usage: "Run this shell command for every event instead of printing it.",
+			},
+			cli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Print every event as one line of json.",
+			},
+		},
+		Description: `Watch the event bus of the daemon.
+
+   The daemon publishes an event when a commit was made (»commit«), when the
+   filesystem was modified (»fs«), when a sync finished (»sync«), when a remote
+   was seen online (»remote-seen«), when a sync created a conflict
+   (»conflict«), when an unknown peer tried to connect (»pairing«) and for the
+   progress of transfers (»transfer«). This command prints those events until
+   it is interrupted.
+
+   If »--name« is given, the daemon remembers the last event that was handled
+   under this name, even across restarts. Running the command again with the
+   same name will first print everything that was missed in between (as long
+   as it is still in the buffer; see the »events.bus_history« config key). If
+   events were lost, an event of kind »lost« is shown.
+
+   With »--exec« the given command is run for every event, which can be used as
+   hook. Details of the event are passed as environment variables:
+   BRIG_EVENT_SEQ, BRIG_EVENT_KIND, BRIG_EVENT_TIME, BRIG_EVENT_PATH,
+   BRIG_EVENT_REMOTE and BRIG_EVENT_MESSAGE. If the command fails, »watch«
+   stops and the event is shown again next time.
+
+EXAMPLES:
+
+   # Show everything that happens:
+   $ brig watch
+   # Run a backup script after every sync and remember where we were:
+   $ brig watch --name backup --kind sync --exec './backup.sh'
+`,
+	},
 	"mount": {
 		Usage:     "Mount the contents of brig as FUSE filesystem to »mount_path«.",
 		ArgsUsage: "<mount_path>",
@@ -1144,6 +2233,9 @@ CAVEATS
    At this time, the filesystem also not very robust to files that timeout or
    error out otherwise. Consider this feature to be experimental while this has
    not been worked upon.
+
+   Mounting works on Linux, FreeBSD and macOS (with osxfuse installed).
+   It is not supported on Windows yet; brig for Windows refuses to mount.
    `,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -1174,7 +2266,8 @@ CAVEATS
    In case the daemon crashed or failed to unmount, you can manually
    use this command to reclaim the mount point:
 
-   $ fusermount -u -z /path/to/mount
+   $ fusermount -u -z /path/to/mount   # linux
+   $ umount -f /path/to/mount          # macOS
 `,
 	},
 	"version": {
@@ -1194,15 +2287,57 @@ CAVEATS
    Please include this information when reporting a bug.
 `,
 	},
-	"gc": {
-		Usage:    "Trigger the garbage collector",
+	"fsck": {
+		Usage:    "Check the integrity of the metadata and of pinned content",
 		Complete: completeArgsUsage,
 		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "content,c",
+				Usage: "Also read and verify the content of all pinned files",
+			},
+			cli.BoolFlag{
+				Name:  "refetch,r",
+				Usage: "Fetch missing or corrupt content again from a remote",
+			},
+			cli.BoolFlag{
+				Name:  "unpin,u",
+				Usage: "Unpin files whose content is still missing or corrupt",
+			},
+		},
+		Description: `Check the integrity of the repository.
+
+   Without options, every node of the current tree is loaded to make sure
+   that the metadata is readable.
+
+   With »--content«, the content of every pinned file is checked as well:
+   It has to be stored locally and the MAC of every encrypted block has to
+   match. The content is not decompressed for this. Content that is
+   not stored locally is reported as missing and is not fetched. This reads
+   all pinned content, which might take a while; unlike the scrubber (see
+   »brig daemon status«), the read rate is not limited.
+
+   Broken content can be fetched again from a remote with »--refetch«. If
+   this fails (or is not requested), »--unpin« unpins the affected files, so
+   that brig stops assuming it has them.
+
+   The exit code is non-zero if a file is left with broken content.
+
+EXAMPLES:
+
+   $ brig fsck --content
+   $ brig fsck --content --refetch --unpin
+`,
+	},
+	"gc": {
+		Usage:     "Trigger the garbage collector",
+		ArgsUsage: "[<root>]",
+		Complete:  completeArgsUsage,
+		Flags: append([]cli.Flag{
 			cli.BoolFlag{
 				Name:  "aggressive,a",
 				Usage: "Also run the garbage collector on all file systems immediately",
 			},
-		},
+		}, selectorFlags...),
 		Description: `Manually trigger the garbage collector.
 
    Strictly speaking there are two garbage collectors in the system.  The
@@ -1213,6 +2348,226 @@ CAVEATS
    The other garbage collector is not very important to the user and cleans up
    unused references inside of the metadata store. It is only run if you pass
    »--aggressive«.
+
+   When selectors are given (see »brig pin --help«), the selected files are
+   unpinned first, so their space can be reclaimed right away. The optional
+   root restricts the selection to this directory. With »--dry-run« only the
+   affected files and the space that would be freed are shown.
+
+EXAMPLES:
+
+   $ brig gc --older-than 90d --larger-than 1G --dry-run
+   $ brig gc --glob '*.iso' /downloads
+`,
+	},
+	"repo": {
+		Usage:    "Manage the registry of named repositories.",
+		Complete: completeSubcommands,
+		Description: `Give your repositories names, so you can switch between them easily.
+
+   The names are stored in »~/.config/brig/repos.yml« (or below
+   $XDG_CONFIG_HOME, if set). Every registered name can be passed to
+   »--repo« instead of a path. This is useful if you have more than one
+   identity on the same machine.
+
+   Without further arguments »brig repo« is a shortcut for »brig repo ls«.
+
+EXAMPLES:
+
+   $ brig repo add work ~/work/.brig
+   $ brig --repo work ls
+`,
+	},
+	"repo.add": {
+		Usage:     "Register a repository under a name.",
+		ArgsUsage: "<name> [<path>]",
+		Complete:  completeArgsUsage,
+		Description: `Register the repository at »path« (or the one given by --repo) as »name«.
+
+   An existing entry with the same name is overwritten.`,
+	},
+	"repo.remove": {
+		Usage:       "Remove a name from the registry.",
+		ArgsUsage:   "<name>",
+		Complete:    completeArgsUsage,
+		Description: `Remove »name« from the registry. The repository itself is not touched.`,
+	},
+	"repo.list": {
+		Usage:       "List all registered repositories.",
+		Complete:    completeArgsUsage,
+		Description: `List all registered repositories and if a daemon is running for them.`,
+	},
+	"repo.upgrade": {
+		Usage:    "Upgrade the repository to the formats of this brig version.",
+		Complete: completeArgsUsage,
+		Description: `Every repository remembers which formats it uses (hash algorithms,
+   chunker, ciphers and how large directories are stored) together with a
+   version number. This command migrates this record to the newest version
+   and prints it. The daemon must not run while upgrading.
+
+   A daemon refuses to start on a repository that uses formats it does not
+   know, for example after a newer brig upgraded it. Repositories created
+   before the record existed still work, but you should upgrade them.
+   Running it on an up to date repository does nothing.
+
+EXAMPLES:
+
+   $ brig repo upgrade
+   Upgraded repository from version 0 to 1.
+   FEATURE       VALUE
+   chunker       fixed-64k
+   cipher        aes-gcm,chacha20-poly1305
+   dir_sharding  v1
+   hash_algo     blake2s-256,blake3
+`,
+	},
+	"rename-owner": {
+		Usage:     "Rename the owner of the repository.",
+		ArgsUsage: "<new-name>",
+		Complete:  completeArgsUsage,
+		Description: `Change the name you are known by, e.g. after moving to a new domain.
+
+   The daemon must not run while renaming. Your metadata is kept and the new
+   name is published the next time the daemon starts. Remotes only know you
+   by name, so a migration record is signed with your identity key and sent
+   to every remote once it is online. Remotes check the record against the
+   key they stored for you and rename you on their side, unless they disabled
+   »net.identity_migration.accept«. Remotes that refused the record have to
+   remove and add you again under the new name.
+
+   Your fingerprint does not change, neither does the password.
+
+EXAMPLES:
+
+   $ brig daemon quit
+   $ brig rename-owner alice@new-domain.org/laptop
+`,
+	},
+	"migrate-metadata": {
+		Usage:     "Store the metadata in another database backend.",
+		ArgsUsage: "<badger|disk>",
+		Complete:  completeArgsUsage,
+		Description: `Convert the metadata of all filesystems (yours and the ones of your remotes)
+   to another database backend and set »fs.metadata_backend« accordingly.
+
+   »badger« is the default and is fast for big trees. »disk« stores every key
+   as a single file, which is slow, but easy to inspect and to back up with
+   normal tools. The daemon must not run while migrating. The old metadata is
+   only removed once the new one was written completely.
+
+EXAMPLES:
+
+   $ brig daemon quit
+   $ brig migrate-metadata disk
+`,
+	},
+	"keychain": {
+		Usage: "Store the repository password in the keychain of the OS.",
+		Description: `Let the daemon start unattended ("machine mode"), e.g. when it is started on boot.
+
+   The password is stored in the keychain of the operating system and read from
+   there instead of asking for it. On Linux the Secret Service is used (GNOME
+   Keyring, KWallet, KeePassXC, ...; needs »secret-tool«), on macOS the login
+   keychain. Other platforms are not supported yet.
+
+   Everyone who can use your keychain can open the repository then. The password
+   is stored for the path of the repository; enable it again after moving it.`,
+	},
+	"keychain.enable": {
+		Usage:    "Store the password in the keychain.",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "provider,p",
+				Usage: "What keychain to use. One of `secret-service`, `macos-keychain`. Defaults to the one of this platform.",
+			},
+		},
+		Description: `Ask for the password, check it and store it in the keychain.
+   »repo.keychain« is set to the used keychain afterwards. »brig passwd« updates
+   the stored password.
+
+EXAMPLES:
+
+   $ brig keychain enable
+`,
+	},
+	"keychain.disable": {
+		Usage:       "Remove the password from the keychain.",
+		Complete:    completeArgsUsage,
+		Description: `Remove the password from the keychain and ask for it again from now on.`,
+	},
+	"passwd": {
+		Usage:    "Change the password of the repository.",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "recovery-file,r",
+				Usage: "Where to write the recovery file during the change. Defaults to a file inside the repository.",
+			},
+			cli.BoolFlag{
+				Name:  "recover",
+				Usage: "Finish or roll back a password change that was interrupted.",
+			},
+		},
+		Description: `Change the password that is used to lock the repository.
+
+   Only the locked files in the repository (keys, metadata, remotes) are
+   re-encrypted with a key derived from the new password. The content that is
+   stored in the backend is not touched, so this is quick even for big
+   repositories. The daemon must not run while the password is changed.
+
+   All files are first re-encrypted into temporary files. Afterwards a
+   recovery file is written and the temporary files are moved into place.
+   If the process is interrupted, run »brig passwd --recover« (with the same
+   »--recovery-file«, if one was given) to complete the change. If the
+   recovery file does not exist yet, the old password stays valid.
+
+   If you use a password helper, remember to update it afterwards.
+
+EXAMPLES:
+
+   $ brig daemon quit
+   $ brig passwd
+   $ brig passwd --recovery-file /mnt/usb/brig.journal
+`,
+	},
+	"backup": {
+		Usage: "Backup and restore the identity of a repository.",
+		Description: `Create or restore a passphrase-encrypted backup of the repository.
+
+   A backup contains your keys, your remotes, the config and the metadata of
+   all known users, but not the content of your files. It can be used to
+   recover your identity on a new machine. The content can be fetched again
+   from other peers (or from IPFS) after restoring.`,
+	},
+	"backup.create": {
+		Usage:     "Write a backup of the repository to a file.",
+		ArgsUsage: "<path>",
+		Complete:  completeArgsUsage,
+		Description: `Write a backup of the repository to »path«, which must not exist yet.
+
+   You will be asked for your repository password and for a passphrase that
+   is used to encrypt the backup. The daemon must not run while doing this.
+
+EXAMPLES:
+
+   $ brig daemon quit
+   $ brig backup create /mnt/usb/brig.backup
+`,
+	},
+	"backup.restore": {
+		Usage:     "Restore a backup into a new repository.",
+		ArgsUsage: "<path> [<repo-folder>]",
+		Complete:  completeArgsUsage,
+		Description: `Restore the backup at »path« into a new repository.
+
+   The repository is created at »repo-folder«, --repo or at "~/.brig"; it
+   must not exist or has to be empty. You will be asked for the passphrase of
+   the backup and a (possibly different) password for the new repository.
+
+EXAMPLES:
+
+   $ brig backup restore /mnt/usb/brig.backup ~/.brig
 `,
 	},
 	"docs": {
@@ -1232,6 +2587,89 @@ CAVEATS
 	"trash.undelete": {
 		Usage: "Restore a path from the trashbin.",
 	},
+	"repl": {
+		Usage:    "Show how many remotes store our files (replication).",
+		Complete: completeSubcommands,
+		Description: `Remotes can be put into groups that store files for each other.
+   A target says how many members of a group should have pinned each file
+   below a folder; our own copy does not count. Both are configured:
+
+     $ brig cfg set net.replication.groups "backup=bob charlie"
+     $ brig cfg set net.replication.targets "/photos=2@backup"
+
+   The daemon regularly asks members to pin files that are stored by too few
+   of them (see »net.replication.interval«). A member only does so if we are in
+   one of its own groups and it already synced the file from us.
+   Without a subcommand, »brig repl status« is run.
+`,
+	},
+	"repl.status": {
+		Usage: "List files that are stored on too few members of their group.",
+		Description: `All members of the configured groups are asked which of our files they
+   store. Members that can not be reached are listed and count as not storing anything.
+
+EXAMPLES:
+
+   $ brig repl status
+   $ brig repl status --all
+`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all,a",
+				Usage: "Also list files that are stored often enough",
+			},
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output according to a template",
+			},
+		},
+	},
+	"hold": {
+		Usage: "Keep files from being removed (retention holds).",
+		Description: `A hold keeps a file or directory exactly as it is until the hold
+   is lifted again, for example for legal reasons. While a path is held:
+
+   - »brig rm« and »brig mv« refuse to touch it, its parents or anything below it.
+   - It can not be unpinned. Its content is pinned when the hold is added.
+   - Deletions by remotes are not applied to it during sync.
+   - The repinner keeps all versions of it and the garbage collector leaves it alone.
+
+   Files below a held directory can still be modified; all versions are kept.
+   Holds are local to this repository and are not synced. Without a
+   subcommand, all holds are listed.
+
+EXAMPLES:
+
+   $ brig hold add /contracts --reason "audit 2024"
+   $ brig hold ls
+   $ brig hold rm /contracts
+`,
+	},
+	"hold.add": {
+		Usage:     "Put one or more paths under a hold.",
+		ArgsUsage: "<path> [<path>...]",
+		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "reason,r",
+				Usage: "Note why the path is held",
+			},
+		},
+	},
+	"hold.remove": {
+		Usage:     "Lift the hold of one or more paths.",
+		ArgsUsage: "<path> [<path>...]",
+		Complete:  completeArgsUsage,
+	},
+	"hold.list": {
+		Usage: "List all holds.",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output according to a template",
+			},
+		},
+	},
 	"gateway": {
 		Usage: "Control the HTTP/S gateway service.",
 		Description: `The gateway serves a UI and download endpoints over a browser.
@@ -1291,15 +2729,15 @@ EXAMPLES:
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "role-admin,a",
-				Usage: "Add this user as admin (short for »-r 'fs.view,fs.edit,fs.download,remotes.view,remotes.edit'«)",
+				Usage: "Add this user as admin (short for »-r '*'«)",
 			},
 			cli.BoolFlag{
 				Name:  "role-editor,b",
-				Usage: "Add this user as collaborator (short for »-r 'fs.view,fs.edit,fs.download,remotes.view'«)",
+				Usage: "Add this user as editor (short for »-r 'fs.*,remotes.view'«)",
 			},
 			cli.BoolFlag{
 				Name:  "role-collaborator,c",
-				Usage: "Add this user as collaborator (short for »-r 'fs.view,fs.edit,fs.download'«)",
+				Usage: "Add this user as collaborator (short for »-r 'fs.view,fs.edit,fs.share,fs.download'«)",
 			},
 			cli.BoolFlag{
 				Name:  "role-viewer,d",
@@ -1313,20 +2751,61 @@ EXAMPLES:
 				Name:  "rights,r",
 				Usage: "Comma separated list of rights of this user.",
 			},
+			cli.StringFlag{
+				Name:  "groups,g",
+				Usage: "Comma separated list of groups this user is member of.",
+			},
 		},
 		Description: `
    The rights are as follows:
 
    fs.view: View and list all files.
-   fs.edit: Edit and create new files.
+   fs.edit: Edit and create new files (includes fs.view).
+   fs.share: Create drop links to let others upload (includes fs.view).
    fs.download: Download file content.
    remotes.view: View the remotes tab.
-   remotes.edit: Edit the remotes tab.
+   remotes.edit: Edit the remotes tab (includes remotes.view).
+   users.view: View information about other users.
+   admin.users: Administrate other users, e.g. see outdated password hashes
+                (includes users.view).
+
+   All rights of a kind can be given with a wildcard like »fs.*«; »*« gives
+   all rights, including ones added in later versions. A user also gets all
+   rights of the groups it is member of (see »brig gateway group«).
 
    If the folder list is empty, this user can access all files.
    If it is non-empty, the user can only access the files including and below all folders.
+   A folder prefixed with »!« (like »!/public/private«) does not inherit the access
+   given for one of its parents.
+`,
+	},
+	"gateway.group": {
+		Usage: "Manage groups of gateway users.",
+		Description: `
+   A group is a named list of rights. Users that are member of a group
+   (see »brig gateway user add --groups«) get all of its rights.
+   Changing the rights of a group changes them for all its members.
+`,
+	},
+	"gateway.group.add": {
+		Usage:     "Add a new group or change the rights of an existing one.",
+		ArgsUsage: "<name> <rights...>",
+		Description: `
+   The rights are the same as for »brig gateway user add«, including wildcards.
+
+EXAMPLES:
+
+	# All members may view and download everything:
+	$ brig gateway group add readers fs.view fs.download
 `,
 	},
+	"gateway.group.remove": {
+		Usage:     "Remove groups by their name. Members lose the group's rights.",
+		ArgsUsage: "<name...>",
+	},
+	"gateway.group.list": {
+		Usage: "List all groups of gateway users.",
+	},
 	"gateway.user.remove": {
 		Usage: "Remove a gateway user by its name.",
 	},
@@ -1335,12 +2814,21 @@ EXAMPLES:
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "format,f",
-				Usage: "Format the output by a template.",
+				Usage: "Format the output according to a template",
+			},
+			cli.BoolFlag{
+				Name:  "outdated,o",
+				Usage: "Only list users whose password hash is outdated.",
 			},
 		},
 		Description: `
    List all gateway users.
 
+   Passwords are hashed with argon2id, using the parameters in
+   »gateway.auth.hash_*«. Users created by older versions of brig, or before
+   the parameters were raised, keep their old hash until they log in the next
+   time. »--outdated« lists the users that did not log in since then.
+
    The keys accepted by »--format« are:
 
    - Name: Name of the user.
@@ -1348,6 +2836,8 @@ EXAMPLES:
    - Salt: Salt of the password.
    - Folders: A list of folders this users may access (might be empty).
    - Rights: A list of rights this users has (might be empty).
+   - HashAlgorithm: Either »argon2id« or »legacy«.
+   - HashParams: The argon2id parameters of the hash.
 `,
 	},
 	"debug": {
@@ -1363,6 +2853,65 @@ EXAMPLES:
 
    # Show a graph with a cpu profile of the last 30s:
    go tool pprof -web "http://localhost:$(brig d p)/debug/pprof/profile?seconds=30"
+`,
+	},
+	"debug.profile": {
+		Usage: "Capture a profile or trace of the daemon into a file.",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "cpu",
+				Usage: "Record where cpu time is spent for this long",
+			},
+			cli.StringFlag{
+				Name:  "trace",
+				Usage: "Record an execution trace for this long",
+			},
+			cli.StringFlag{
+				Name:  "block",
+				Usage: "Record where goroutines block for this long",
+			},
+			cli.StringFlag{
+				Name:  "mutex",
+				Usage: "Record contended mutexes for this long",
+			},
+			cli.BoolFlag{
+				Name:  "heap",
+				Usage: "Take a snapshot of the memory in use",
+			},
+			cli.BoolFlag{
+				Name:  "allocs",
+				Usage: "Take a snapshot of all memory allocations so far",
+			},
+			cli.BoolFlag{
+				Name:  "goroutine",
+				Usage: "Take a snapshot of the stacks of all goroutines",
+			},
+			cli.BoolFlag{
+				Name:  "threadcreate",
+				Usage: "Take a snapshot of where threads were created",
+			},
+			cli.StringFlag{
+				Name:  "output,o",
+				Value: "",
+				Usage: "Where to write the result (default: brig-<kind>-<time>.<ext>)",
+			},
+		},
+		Description: `Ask the daemon to capture a profile and write it to a file, which can be
+   attached to bug reports about performance. Exactly one kind has to be given.
+   Timed kinds take a duration (at most 5m); the others are a snapshot.
+
+   This needs daemon.enable_profiling to be set, since profiling slows the
+   daemon down. Other than »brig debug pprof-port« no extra port is opened.
+
+EXAMPLES:
+
+	# Capture 30 seconds of cpu usage while the daemon is busy:
+	$ brig config set daemon.enable_profiling true
+	$ brig debug profile --cpu 30s
+
+	# Show what the daemon is doing right now:
+	$ brig debug profile --goroutine -o stacks.pprof
+	$ go tool pprof -top stacks.pprof
 `,
 	},
 	"bug": {
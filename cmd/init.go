@@ -54,6 +54,15 @@ func Init(ctx *cli.Context, basePath, owner, password, backendName, ipfsPath str
 		return err
 	}
 
+	// The metadata is created when the daemon starts the first time,
+	// so the backend can not be set later on like the other config.
+	if metadataBackend := ctx.String("metadata-backend"); metadataBackend != "" {
+		err = repo.OverwriteConfigKey(basePath, "fs.metadata_backend", metadataBackend)
+		if err != nil {
+			return err
+		}
+	}
+
 	backendPath := filepath.Join(basePath, "data", backendName)
 	if err := backend.InitByName(backendName, backendPath, ipfsPort); err != nil {
 		return e.Wrapf(err, "backend-init")
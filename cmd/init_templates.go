@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sahib/brig/client"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/urfave/cli"
+)
+
+// configPair is a single config value that a template sets.
+type configPair struct {
+	key, val string
+}
+
+// initTemplate is a preset for a new repository.
+type initTemplate struct {
+	description string
+	config      []configPair
+	folders     []string
+}
+
+var initTemplates = map[string]initTemplate{
+	"photos": {
+		description: "Big, already compressed files that are viewed via the gateway.",
+		config: []configPair{
+			// jpeg & co. do not get any smaller:
+			{"fs.compress.default_algo", "none"},
+			{"fs.pre_cache.enabled", "true"},
+			{"fs.repin.quota", "50GB"},
+			{"fs.repin.max_depth", "2"},
+			{"gateway.enabled", "true"},
+		},
+		folders: []string{"/photos"},
+	},
+	"backup": {
+		description: "Many versions of files that are rarely read and never synced automatically.",
+		config: []configPair{
+			{"fs.compress.default_algo", "lz4"},
+			{"fs.pre_cache.enabled", "true"},
+			{"fs.repin.quota", "100GB"},
+			{"fs.repin.min_depth", "3"},
+			{"fs.repin.max_depth", "30"},
+			{"fs.autocommit.interval", "1h"},
+			{"fs.snapshots.enabled", "true"},
+			{"events.enabled", "false"},
+		},
+		folders: []string{"/backup"},
+	},
+	"shared-docs": {
+		description: "Small documents that are edited by several people at once.",
+		config: []configPair{
+			{"fs.compress.default_algo", "snappy"},
+			{"fs.autocommit.interval", "1m"},
+			{"fs.sync.conflict_strategy", "marker"},
+			{"events.enabled", "true"},
+			{"gateway.enabled", "true"},
+			{"gateway.site.enabled", "true"},
+		},
+		folders: []string{"/docs", "/public"},
+	},
+}
+
+// value returns what the template sets `key` to, or `def` if it does not.
+func (tmpl *initTemplate) value(key, def string) string {
+	for idx := len(tmpl.config) - 1; idx >= 0; idx-- {
+		if tmpl.config[idx].key == key {
+			return tmpl.config[idx].val
+		}
+	}
+
+	return def
+}
+
+func initTemplateNames() []string {
+	names := []string{}
+	for name := range initTemplates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// initTemplateFromArgs returns the template chosen by --template or by the
+// answers of --wizard. It returns nil if neither was given.
+// `owner` is asked for in the wizard if it is empty.
+func initTemplateFromArgs(ctx *cli.Context, owner *string) (*initTemplate, error) {
+	tmpl, err := selectInitTemplate(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	hashAlgo := ctx.String("hash-algo")
+	if hashAlgo == "" {
+		return tmpl, nil
+	}
+
+	if !h.IsValidAlgorithm(hashAlgo) {
+		return nil, fmt.Errorf(
+			"no such hash algorithm: %s (choose one of %s, %s)",
+			hashAlgo, h.AlgoBlake2s, h.AlgoBlake3,
+		)
+	}
+
+	withAlgo := initTemplate{}
+	if tmpl != nil {
+		withAlgo = *tmpl
+	}
+
+	// Do not append to the config of the (shared) template itself:
+	withAlgo.config = append(
+		append([]configPair{}, withAlgo.config...),
+		configPair{"fs.hash_algo", hashAlgo},
+	)
+
+	return &withAlgo, nil
+}
+
+func selectInitTemplate(ctx *cli.Context, owner *string) (*initTemplate, error) {
+	if ctx.Bool("wizard") {
+		return runInitWizard(bufio.NewReader(os.Stdin), os.Stdout, owner)
+	}
+
+	name := ctx.String("template")
+	if name == "" {
+		return nil, nil
+	}
+
+	tmpl, ok := initTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf(
+			"no such template: %s (choose one of %s)",
+			name, strings.Join(initTemplateNames(), ", "),
+		)
+	}
+
+	return &tmpl, nil
+}
+
+func askInitQuestion(rd *bufio.Reader, w io.Writer, question, def string) (string, error) {
+	fmt.Fprintf(w, "%s [%s]: ", question, def)
+	answer, err := rd.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+
+	return answer, nil
+}
+
+func askInitYesNo(rd *bufio.Reader, w io.Writer, question string, def bool) (bool, error) {
+	defAnswer := "y/N"
+	if def {
+		defAnswer = "Y/n"
+	}
+
+	answer, err := askInitQuestion(rd, w, question, defAnswer)
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return def, nil
+	}
+}
+
+// runInitWizard asks a few questions and builds a template out of the answers.
+func runInitWizard(rd *bufio.Reader, w io.Writer, owner *string) (*initTemplate, error) {
+	fmt.Fprintln(w, "-- Answer a few questions to set up your repository (press enter for the default).")
+
+	if *owner == "" {
+		name, err := askInitQuestion(rd, w, "Your user name (e.g. ali@wonderland.org/laptop)", "")
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("a user name is required")
+		}
+
+		*owner = name
+	}
+
+	fmt.Fprintln(w, "-- Available templates:")
+	for _, name := range initTemplateNames() {
+		fmt.Fprintf(w, "   %-12s %s\n", name, initTemplates[name].description)
+	}
+
+	tmpl := initTemplate{}
+	for {
+		name, err := askInitQuestion(rd, w, "Template to start from", "none")
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "none" {
+			break
+		}
+
+		if chosen, ok := initTemplates[name]; ok {
+			tmpl.description = chosen.description
+			tmpl.folders = chosen.folders
+			tmpl.config = append([]configPair{}, chosen.config...)
+			break
+		}
+
+		fmt.Fprintf(w, "-- No such template: %s\n", name)
+	}
+
+	// The template decides what is suggested:
+	compress, err := askInitYesNo(
+		rd, w, "Compress new files",
+		tmpl.value("fs.compress.default_algo", "snappy") != "none",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	autoSync, err := askInitYesNo(
+		rd, w, "Sync automatically with remotes that allow it",
+		tmpl.value("events.enabled", "true") == "true",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway, err := askInitYesNo(
+		rd, w, "Start the gateway (web interface)",
+		tmpl.value("gateway.enabled", "false") == "true",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Later pairs win, so the answers override the template:
+	algo := tmpl.value("fs.compress.default_algo", "snappy")
+	if !compress {
+		tmpl.config = append(tmpl.config, configPair{"fs.compress.default_algo", "none"})
+	} else if algo == "none" {
+		tmpl.config = append(tmpl.config, configPair{"fs.compress.default_algo", "snappy"})
+	}
+
+	tmpl.config = append(
+		tmpl.config,
+		configPair{"events.enabled", fmt.Sprintf("%t", autoSync)},
+		configPair{"gateway.enabled", fmt.Sprintf("%t", gateway)},
+	)
+
+	return &tmpl, nil
+}
+
+// applyInitTemplate sets the config and creates the folders of `tmpl`.
+func applyInitTemplate(ctl *client.Client, tmpl *initTemplate) error {
+	if tmpl == nil {
+		return nil
+	}
+
+	for _, pair := range tmpl.config {
+		if err := ctl.ConfigSet(pair.key, pair.val); err != nil {
+			return fmt.Errorf("template: failed to set %s: %w", pair.key, err)
+		}
+	}
+
+	for _, folder := range tmpl.folders {
+		if err := ctl.Mkdir(folder, true); err != nil {
+			return fmt.Errorf("template: failed to create %s: %w", folder, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/sahib/brig/cmd/pwd"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/keychain"
+	"github.com/urfave/cli"
+)
+
+// configuredKeychain returns the keychain provider set in the config
+// of the repository at `repoPath` or "" if there is none.
+func configuredKeychain(repoPath string) string {
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(repoPath, "config.yml"))
+	if err != nil {
+		return ""
+	}
+
+	return cfg.String("repo.keychain")
+}
+
+// readPasswordFromKeychain returns the password stored in the configured
+// keychain or "" if there is none.
+func readPasswordFromKeychain(ctx *cli.Context, repoPath string) string {
+	provider := configuredKeychain(repoPath)
+	if provider == "" {
+		return ""
+	}
+
+	password, err := repo.ReadPasswordFromKeychain(repoPath, provider)
+	if err != nil {
+		logVerbose(ctx, "failed to read password from the %s keychain: %v", provider, err)
+		return ""
+	}
+
+	return password
+}
+
+func handleKeychainEnable(ctx *cli.Context) error {
+	provider := ctx.String("provider")
+	if provider == "" {
+		provider = keychain.DefaultProvider
+	}
+
+	if provider == "" {
+		return ExitCode{BadArgs, "there is no keychain support for this platform yet"}
+	}
+
+	folder := guessRepoFolder(ctx)
+	password := readPasswordFromArgs(folder, ctx)
+	if password == "" {
+		var err error
+		password, err = pwd.PromptPassword()
+		if err != nil {
+			return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+		}
+	}
+
+	if err := repo.StorePasswordInKeychain(folder, provider, password); err != nil {
+		if err == repo.ErrBadPassword {
+			return ExitCode{BadPassword, err.Error()}
+		}
+
+		return fmt.Errorf("keychain: %w", err)
+	}
+
+	if err := repo.OverwriteConfigKey(folder, "repo.keychain", provider); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+
+	fmt.Printf("The password is now stored in the %s keychain.\n", color.GreenString(provider))
+	fmt.Println("The daemon will start without asking for it from now on.")
+	return nil
+}
+
+func handleKeychainDisable(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	provider := configuredKeychain(folder)
+	if provider == "" {
+		fmt.Println("The password is not stored in a keychain.")
+		return nil
+	}
+
+	if err := repo.RemovePasswordFromKeychain(folder, provider); err != nil {
+		return fmt.Errorf("keychain: %w", err)
+	}
+
+	if err := repo.OverwriteConfigKey(folder, "repo.keychain", ""); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+
+	fmt.Printf("The password was removed from the %s keychain.\n", color.YellowString(provider))
+	return nil
+}
@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	isatty "github.com/mattn/go-isatty"
+	"github.com/sahib/brig/client"
+	"github.com/urfave/cli"
+	terminal "github.com/wayneashleyberry/terminal-dimensions"
+)
+
+// lsCell is a single cell of the ls output.
+// The width is computed from `text`, the color is only applied on output.
+type lsCell struct {
+	text  string
+	color func(format string, a ...interface{}) string
+}
+
+func plainCell(text string) lsCell {
+	return lsCell{text: text}
+}
+
+func (c lsCell) render() string {
+	if c.color == nil {
+		return c.text
+	}
+
+	return c.color("%s", c.text)
+}
+
+// lsLess returns a function that orders entries by `sortBy`.
+// Sizes and modification times are sorted largest and newest first,
+// like ls(1) does with -S and -t.
+func lsLess(sortBy string, reverse bool) (func(a, b client.StatInfo) bool, error) {
+	var less func(a, b client.StatInfo) bool
+
+	byName := func(a, b client.StatInfo) bool {
+		return strings.ToLower(a.Path) < strings.ToLower(b.Path)
+	}
+
+	switch sortBy {
+	case "", "name":
+		less = byName
+	case "size":
+		less = func(a, b client.StatInfo) bool {
+			if a.Size == b.Size {
+				return byName(a, b)
+			}
+
+			return a.Size > b.Size
+		}
+	case "mtime":
+		less = func(a, b client.StatInfo) bool {
+			if a.ModTime.Equal(b.ModTime) {
+				return byName(a, b)
+			}
+
+			return a.ModTime.After(b.ModTime)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort key: %s (use size, mtime or name)", sortBy)
+	}
+
+	if reverse {
+		return func(a, b client.StatInfo) bool {
+			return less(b, a)
+		}, nil
+	}
+
+	return less, nil
+}
+
+// lsFilter drops all entries whose name does not match the glob `pattern`.
+// Directories are kept if `keepDirs` is true, so the tree stays connected.
+func lsFilter(entries []client.StatInfo, pattern string, keepDirs bool) ([]client.StatInfo, error) {
+	if pattern == "" {
+		return entries, nil
+	}
+
+	// Check the pattern once, so we do not need to care later:
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad filter pattern: %s", pattern)
+	}
+
+	filtered := []client.StatInfo{}
+	for _, entry := range entries {
+		if keepDirs && entry.IsDir {
+			filtered = append(filtered, entry)
+			continue
+		}
+
+		if matched, _ := path.Match(pattern, path.Base(entry.Path)); matched {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	return filtered, nil
+}
+
+// ellipsizeLeft shortens `s` to `width` runes by cutting from the left.
+// The end of a path is usually the more interesting part.
+func ellipsizeLeft(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	if width == 1 {
+		return "…"
+	}
+
+	runes := []rune(s)
+	return "…" + string(runes[len(runes)-width+1:])
+}
+
+// printColumns prints `rows` with aligned columns, two spaces apart.
+// If `maxWidth` is positive, the cells of column `shrinkCol` are shortened
+// until a row fits into `maxWidth`.
+func printColumns(rows [][]lsCell, shrinkCol, maxWidth int) {
+	if len(rows) == 0 {
+		return
+	}
+
+	const padding = 2
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for idx, cell := range row {
+			if w := utf8.RuneCountInString(cell.text); w > widths[idx] {
+				widths[idx] = w
+			}
+		}
+	}
+
+	if maxWidth > 0 {
+		total := 0
+		for _, width := range widths {
+			total += width + padding
+		}
+
+		if over := total - padding - maxWidth; over > 0 && widths[shrinkCol]-over >= 10 {
+			widths[shrinkCol] -= over
+			for _, row := range rows {
+				row[shrinkCol].text = ellipsizeLeft(row[shrinkCol].text, widths[shrinkCol])
+			}
+		}
+	}
+
+	for _, row := range rows {
+		line := &strings.Builder{}
+		for idx, cell := range row {
+			line.WriteString(cell.render())
+			if idx == len(row)-1 {
+				break
+			}
+
+			fill := widths[idx] - utf8.RuneCountInString(cell.text) + padding
+			line.WriteString(strings.Repeat(" ", fill))
+		}
+
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+}
+
+// versionCount returns how many versions of `entry` are in the history.
+func versionCount(ctl *client.Client, entry client.StatInfo) string {
+	if entry.IsDir {
+		return "-"
+	}
+
+	history, err := ctl.History(entry.Path)
+	if err != nil {
+		return "?"
+	}
+
+	return strconv.Itoa(len(history))
+}
+
+func handleList(ctx *cli.Context, ctl *client.Client) error {
+	maxDepth := ctx.Int("depth")
+	if ctx.Bool("recursive") || (ctx.Bool("tree") && !ctx.IsSet("depth")) {
+		maxDepth = -1
+	}
+
+	root := "/"
+	if ctx.Args().Present() {
+		root = ctx.Args().First()
+	}
+
+	less, err := lsLess(ctx.String("sort"), ctx.Bool("reverse"))
+	if err != nil {
+		return ExitCode{BadArgs, err.Error()}
+	}
+
+	entries, err := ctl.List(root, maxDepth)
+	if err != nil {
+		return err
+	}
+
+	entries, err = lsFilter(entries, ctx.String("filter"), ctx.Bool("tree"))
+	if err != nil {
+		return ExitCode{BadArgs, err.Error()}
+	}
+
+	if ctx.Bool("tree") {
+		showTree(entries, &treeCfg{
+			showPin: true,
+			less:    less,
+		})
+		return nil
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, entry := range entries {
+			if err := tmpl.Execute(os.Stdout, entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	users := []string{}
+	for _, entry := range entries {
+		users = append(users, entry.User)
+	}
+
+	userMap := userPrefixMap(users)
+	showUsers := len(userMap) > 1
+	showVersions := ctx.Bool("versions")
+
+	// The header and the shortening of paths only make sense for humans.
+	// When piped, every line is a complete entry.
+	isTerminal := isatty.IsTerminal(os.Stdout.Fd())
+
+	rows := [][]lsCell{}
+	if len(entries) != 0 && isTerminal {
+		header := []lsCell{plainCell("SIZE"), plainCell("MODTIME")}
+		if showUsers {
+			header = append(header, plainCell("USER"))
+		}
+
+		header = append(header, plainCell("PATH"), plainCell("PIN"))
+		if showVersions {
+			header = append(header, plainCell("VERSIONS"))
+		}
+
+		rows = append(rows, header)
+	}
+
+	pathCol := 2
+	if showUsers {
+		pathCol = 3
+	}
+
+	for _, entry := range entries {
+		pathCell := lsCell{text: entry.Path, color: color.WhiteString}
+		if entry.IsDir {
+			pathCell.color = color.GreenString
+		}
+
+		pinCell := plainCell("")
+		if entry.IsPinned {
+			pinCell.text = "✔"
+			pinCell.color = color.CyanString
+			if entry.IsExplicit {
+				pinCell.color = color.MagentaString
+			}
+		}
+
+		row := []lsCell{
+			{text: humanize.Bytes(entry.Size), color: colorForSize(entry.Size)},
+			plainCell(entry.ModTime.Format(time.UnixDate)),
+		}
+
+		if showUsers {
+			row = append(row, lsCell{text: userMap[entry.User], color: color.GreenString})
+		}
+
+		row = append(row, pathCell, pinCell)
+		if showVersions {
+			row = append(row, plainCell(versionCount(ctl, entry)))
+		}
+
+		rows = append(rows, row)
+	}
+
+	maxWidth := 0
+	if isTerminal {
+		if width, err := terminal.Width(); err == nil {
+			maxWidth = int(width)
+		}
+	}
+
+	printColumns(rows, pathCol, maxWidth)
+	return nil
+}
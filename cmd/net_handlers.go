@@ -2,15 +2,19 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/sahib/brig/cmd/tabwriter"
 
 	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/net/peer"
 	"github.com/urfave/cli"
 	yml "gopkg.in/yaml.v2"
 )
@@ -39,6 +43,10 @@ func handleIsOnline(ctx *cli.Context, ctl *client.Client) error {
 }
 
 func handleRemoteList(ctx *cli.Context, ctl *client.Client) error {
+	if ctx.NArg() > 0 {
+		return handleRemoteBrowse(ctx, ctl)
+	}
+
 	if ctx.Bool("offline") {
 		return handleRemoteListOffline(ctx, ctl)
 	}
@@ -46,6 +54,59 @@ func handleRemoteList(ctx *cli.Context, ctl *client.Client) error {
 	return handleRemoteListOnline(ctx, ctl)
 }
 
+// handleRemoteBrowse shows what a remote shares with us in a directory.
+func handleRemoteBrowse(ctx *cli.Context, ctl *client.Client) error {
+	name := ctx.Args().First()
+	root := "/"
+	if ctx.NArg() > 1 {
+		root = ctx.Args().Get(1)
+	}
+
+	entries, err := ctl.RemoteBrowse(name, root)
+	if err != nil {
+		return fmt.Errorf("remote ls: %w", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Nothing shared with you here.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "SIZE\tMODTIME\tVERSIONS\tPATH\t")
+	for _, entry := range entries {
+		size := colorForSize(entry.Size)(humanize.Bytes(entry.Size))
+		versions := "-"
+		path := color.WhiteString(entry.Path)
+
+		switch {
+		case entry.Partial:
+			// Only leads to something shared; the size would be misleading.
+			size = "-"
+			path = color.YellowString(entry.Path)
+		case entry.IsDir:
+			path = color.GreenString(entry.Path)
+		default:
+			versions = fmt.Sprintf("%d", entry.Versions)
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t\n",
+			size,
+			entry.ModTime.Format(time.Stamp),
+			versions,
+			path,
+		)
+	}
+
+	return tabW.Flush()
+}
+
 func nFoldersToIcon(nFolders int) string {
 	if nFolders == 0 {
 		return color.GreenString("*")
@@ -54,10 +115,45 @@ func nFoldersToIcon(nFolders int) string {
 	return color.YellowString(fmt.Sprintf("%d", nFolders))
 }
 
+func trustToColor(trust string) string {
+	switch trust {
+	case "verified":
+		return color.GreenString(trust)
+	case "blocked":
+		return color.RedString(trust)
+	default:
+		return color.YellowString(trust)
+	}
+}
+
+// formatQueuedOps shows the operations that wait for a remote,
+// along with the time of the next retry if one failed already.
+func formatQueuedOps(ops []client.QueuedOp) string {
+	if len(ops) == 0 {
+		return "-"
+	}
+
+	parts := []string{}
+	for _, op := range ops {
+		part := op.Kind
+		if op.Attempts > 0 {
+			part += fmt.Sprintf(
+				" (%d tries, next in %s)",
+				op.Attempts,
+				time.Until(op.NextTry).Round(time.Second),
+			)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return color.YellowString(strings.Join(parts, ", "))
+}
+
 func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 	remotes, err := ctl.RemoteLs()
 	if err != nil {
-		return fmt.Errorf("remote ls: %v", err)
+		return fmt.Errorf("remote ls: %w", err)
 	}
 
 	if ctx.IsSet("format") {
@@ -85,7 +181,7 @@ func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 		tabwriter.StripEscape,
 	)
 
-	fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\t")
+	fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tTRUST\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\t")
 
 	for _, remote := range remotes {
 		cs := remote.ConflictStrategy
@@ -95,9 +191,10 @@ func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			remote.Name,
 			remote.Fingerprint,
+			trustToColor(remote.Trust),
 			yesOrNo(remote.AutoUpdate),
 			yesOrNo(remote.AcceptPush),
 			cs,
@@ -125,7 +222,7 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if !ctx.IsSet("format") {
-		fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tROUNDTRIP\tONLINE\tAUTHENTICATED\tLASTSEEN\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\t")
+		fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tROUNDTRIP\tONLINE\tAUTHENTICATED\tLASTSEEN\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\tQUEUED\t")
 	}
 
 	tmpl, err := readFormatTemplate(ctx)
@@ -189,7 +286,7 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
 			status.Remote.Name,
 			shortFp,
 			roundtrip,
@@ -200,6 +297,7 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 			yesOrNo(status.Remote.AcceptPush),
 			cs,
 			nFoldersToIcon(len(status.Remote.Folders)),
+			formatQueuedOps(status.Queued),
 		)
 	}
 
@@ -232,13 +330,70 @@ func ymlToRemoteList(data []byte) ([]client.Remote, error) {
 	return remotes, nil
 }
 
+// verifyFingerprint checks `fingerprint` against the record published under
+// the domain of `name`. If `fingerprint` is empty, the published one is returned,
+// but only if it was fetched from an authenticated source.
+func verifyFingerprint(name, fingerprint string) (string, error) {
+	castName, err := peer.CastName(name)
+	if err != nil {
+		return "", err
+	}
+
+	verifier := peer.NewVerifier()
+	if fingerprint == "" {
+		record, err := verifier.Resolve(castName)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Printf("Using fingerprint published at %s\n", record.Source)
+		return string(record.Fingerprint), nil
+	}
+
+	castFp, err := peer.CastFingerprint(fingerprint)
+	if err != nil {
+		return "", err
+	}
+
+	record, err := verifier.Verify(castName, castFp)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Fingerprint verified via %s\n", record.Source)
+	return fingerprint, nil
+}
+
 func handleRemoteAdd(ctx *cli.Context, ctl *client.Client) error {
+	name := ctx.Args().Get(0)
+	fingerprint := ctx.Args().Get(1)
+
+	if ctx.Bool("verify") {
+		var err error
+		if fingerprint, err = verifyFingerprint(name, fingerprint); err != nil {
+			return fmt.Errorf("remote add: verification failed: %w", err)
+		}
+	} else if fingerprint == "" {
+		return fmt.Errorf("remote add: need a fingerprint or --verify")
+	}
+
+	trust := "tofu"
+	if ctx.Bool("verify") {
+		trust = "verified"
+	}
+
+	if !strings.Contains(fingerprint, ":") {
+		// Only an addr was given; the key is pinned on the first connection.
+		fingerprint += ":"
+	}
+
 	remote := client.Remote{
-		Name:             ctx.Args().Get(0),
-		Fingerprint:      ctx.Args().Get(1),
+		Name:             name,
+		Fingerprint:      fingerprint,
 		AutoUpdate:       ctx.Bool("auto-update"),
 		ConflictStrategy: ctx.String("conflict-strategy"),
 		AcceptPush:       ctx.Bool("accept-push"),
+		Trust:            trust,
 	}
 
 	for _, folder := range ctx.StringSlice("folder") {
@@ -255,7 +410,7 @@ func handleRemoteAdd(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.RemoteAddOrUpdate(remote); err != nil {
-		return fmt.Errorf("remote add: %v", err)
+		return fmt.Errorf("remote add: %w", err)
 	}
 
 	return nil
@@ -281,7 +436,7 @@ func handleRemoteAutoUpdate(ctx *cli.Context, ctl *client.Client) error {
 
 		rmt.AutoUpdate = enable
 		if err := ctl.RemoteAddOrUpdate(rmt); err != nil {
-			return fmt.Errorf("remote update: %v", err)
+			return fmt.Errorf("remote update: %w", err)
 		}
 
 		if !ctx.Bool("no-initial-sync") {
@@ -314,13 +469,92 @@ func handleRemoteAcceptPush(ctx *cli.Context, ctl *client.Client) error {
 
 		rmt.AcceptPush = enable
 		if err := ctl.RemoteAddOrUpdate(rmt); err != nil {
-			return fmt.Errorf("remote update: %v", err)
+			return fmt.Errorf("remote update: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func handleRemoteTrust(ctx *cli.Context, ctl *client.Client) error {
+	if ctx.NArg() == 0 {
+		return handleRemoteTrustReview(ctl)
+	}
+
+	level := ctx.Args().First()
+	switch level {
+	case "verified", "tofu", "blocked":
+	default:
+		return fmt.Errorf("please specify 'verified', 'tofu' or 'blocked' as first argument")
+	}
+
+	for _, remoteName := range ctx.Args()[1:] {
+		rmt, err := ctl.RemoteByName(remoteName)
+		if err != nil {
+			return err
+		}
+
+		if level == "verified" && ctx.Bool("check") {
+			if _, err := verifyFingerprint(rmt.Name, rmt.Fingerprint); err != nil {
+				return fmt.Errorf("remote trust: verification of %s failed: %w", rmt.Name, err)
+			}
+		}
+
+		rmt.Trust = level
+		if err := ctl.RemoteAddOrUpdate(rmt); err != nil {
+			return fmt.Errorf("remote update: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// handleRemoteTrustReview lists the remotes whose fingerprint nobody checked.
+func handleRemoteTrustReview(ctl *client.Client) error {
+	remotes, err := ctl.RemoteLs()
+	if err != nil {
+		return fmt.Errorf("remote ls: %w", err)
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	nTOFU := 0
+	for _, remote := range remotes {
+		if remote.Trust != "tofu" {
+			continue
+		}
+
+		if nTOFU == 0 {
+			fmt.Fprintln(tabW, "NAME\tFINGERPRINT\t")
+		}
+
+		fp := remote.Fingerprint
+		if strings.HasSuffix(fp, ":") {
+			fp += color.YellowString("(pinned on first connect)")
+		}
+
+		fmt.Fprintf(tabW, "%s\t%s\t\n", remote.Name, fp)
+		nTOFU++
+	}
+
+	if nTOFU == 0 {
+		fmt.Println("All remotes are either verified or blocked.")
+		return nil
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Compare those fingerprints with their owners and use")
+	fmt.Println("`brig remote trust verified <name>` or `brig remote trust blocked <name>`.")
+	return nil
+}
+
 func handleRemoteConflictStrategy(ctx *cli.Context, ctl *client.Client) error {
 	for _, remoteName := range ctx.Args()[1:] {
 		rmt, err := ctl.RemoteByName(remoteName)
@@ -330,7 +564,7 @@ func handleRemoteConflictStrategy(ctx *cli.Context, ctl *client.Client) error {
 
 		rmt.ConflictStrategy = ctx.Args().First()
 		if err := ctl.RemoteAddOrUpdate(rmt); err != nil {
-			return fmt.Errorf("remote update: %v", err)
+			return fmt.Errorf("remote update: %w", err)
 		}
 	}
 
@@ -340,7 +574,7 @@ func handleRemoteConflictStrategy(ctx *cli.Context, ctl *client.Client) error {
 func handleRemoteRemove(ctx *cli.Context, ctl *client.Client) error {
 	name := ctx.Args().First()
 	if err := ctl.RemoteRm(name); err != nil {
-		return fmt.Errorf("remote rm: %v", err)
+		return fmt.Errorf("remote rm: %w", err)
 	}
 
 	return nil
@@ -353,18 +587,18 @@ func handleRemoteClear(ctx *cli.Context, ctl *client.Client) error {
 func handleRemoteEdit(ctx *cli.Context, ctl *client.Client) error {
 	remotes, err := ctl.RemoteLs()
 	if err != nil {
-		return fmt.Errorf("remote ls: %v", err)
+		return fmt.Errorf("remote ls: %w", err)
 	}
 
 	data, err := remoteListToYml(remotes)
 	if err != nil {
-		return fmt.Errorf("Failed to convert to yml: %v", err)
+		return fmt.Errorf("Failed to convert to yml: %w", err)
 	}
 
 	// Launch an editor on the received data:
 	newData, err := edit(data, "yml")
 	if err != nil {
-		return fmt.Errorf("Failed to launch editor: %v", err)
+		return fmt.Errorf("Failed to launch editor: %w", err)
 	}
 
 	// Save a few network roundtrips if nothing was changed:
@@ -379,7 +613,7 @@ func handleRemoteEdit(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.RemoteSave(newRemotes); err != nil {
-		return fmt.Errorf("Saving back remotes failed: %v", err)
+		return fmt.Errorf("Saving back remotes failed: %w", err)
 	}
 
 	return nil
@@ -566,7 +800,7 @@ func handleNetLocate(ctx *cli.Context, ctl *client.Client) error {
 
 	candidateCh, err := ctl.NetLocate(who, ctx.String("mask"), timeoutSec)
 	if err != nil {
-		return fmt.Errorf("Failed to locate peers: %v", err)
+		return fmt.Errorf("Failed to locate peers: %w", err)
 	}
 
 	somethingFound := false
@@ -620,16 +854,223 @@ func handleRemotePing(ctx *cli.Context, ctl *client.Client) error {
 	return nil
 }
 
+func handleRemoteVerify(ctx *cli.Context, ctl *client.Client) error {
+	failed := false
+	for _, name := range ctx.Args() {
+		rmt, err := ctl.RemoteByName(name)
+		if err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf("%s: ", color.MagentaString(name))
+		if _, err := verifyFingerprint(rmt.Name, rmt.Fingerprint); err != nil {
+			msg += color.RedString("✘")
+			msg += fmt.Sprintf(" (%v)", err)
+			failed = true
+		} else {
+			msg += color.GreenString("✔")
+		}
+
+		fmt.Println(msg)
+	}
+
+	if failed {
+		return fmt.Errorf("some fingerprints could not be verified")
+	}
+
+	return nil
+}
+
+func handleRemoteDiscover(ctx *cli.Context, ctl *client.Client) error {
+	peers, err := ctl.RemoteDiscover(ctx.Duration("timeout"))
+	if err != nil {
+		return fmt.Errorf("remote discover: %w", err)
+	}
+
+	if len(peers) == 0 {
+		fmt.Println("No other daemons found on the local network.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "OWNER\tADDR\tFINGERPRINT\tIP\tREMOTE\t")
+	for _, peer := range peers {
+		remoteName := peer.RemoteName
+		if remoteName == "" {
+			remoteName = color.YellowString("-")
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			color.MagentaString(peer.Owner),
+			peer.Addr,
+			peer.Fingerprint,
+			peer.IP,
+			remoteName,
+		)
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Use `brig remote add »owner« »addr«` to add one of them.")
+	fmt.Println("Compare the fingerprint with the one shown by `brig whoami` on the other side.")
+	return nil
+}
+
+func handleRemoteHead(ctx *cli.Context, ctl *client.Client) error {
+	names := ctx.Args()
+	if len(names) == 0 {
+		remotes, err := ctl.RemoteLs()
+		if err != nil {
+			return fmt.Errorf("remote ls: %w", err)
+		}
+
+		for _, remote := range remotes {
+			names = append(names, remote.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		fmt.Println("You do not have any remotes yet.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tHEAD\tINDEX\tPUBLISHED\tSTATUS\t")
+	for _, name := range names {
+		head, err := ctl.RemoteHead(name)
+		if err != nil {
+			fmt.Fprintf(
+				tabW,
+				"%s\t-\t-\t-\t%s\t\n",
+				color.MagentaString(name),
+				color.RedString(err.Error()),
+			)
+			continue
+		}
+
+		status := color.GreenString("up to date")
+		if head.IsNew() {
+			status = color.YellowString("new commits")
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%d\t%s\t%s\t\n",
+			color.MagentaString(name),
+			head.Hash,
+			head.Index,
+			head.Published.Format(time.RFC3339),
+			status,
+		)
+	}
+
+	return tabW.Flush()
+}
+
 func handlePin(ctx *cli.Context, ctl *client.Client) error {
+	if hasSelector(ctx) || ctx.Bool("dry-run") {
+		_, err := pinSelection(ctx, ctl, true)
+		return err
+	}
+
 	path := ctx.Args().First()
 	return ctl.Pin(path)
 }
 
 func handleUnpin(ctx *cli.Context, ctl *client.Client) error {
+	if hasSelector(ctx) || ctx.Bool("dry-run") {
+		_, err := pinSelection(ctx, ctl, false)
+		return err
+	}
+
 	path := ctx.Args().First()
 	return ctl.Unpin(path)
 }
 
+// pinSelection (un)pins all versions selected by the selectorFlags
+// and prints them. It returns the number of affected versions.
+func pinSelection(ctx *cli.Context, ctl *client.Client, pin bool) (int, error) {
+	sel, err := selectorFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dryRun := ctx.Bool("dry-run")
+	versions, err := ctl.PinSelection(sel, pin, dryRun)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("No files selected.")
+		return 0, nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "PATH\tSIZE\tMODIFIED\tCOMMIT\tPIN\t")
+
+	// Contents are only counted once, even if several versions share them:
+	var total uint64
+	seen := make(map[string]bool)
+
+	for _, version := range versions {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			color.WhiteString(version.Path),
+			colorForSize(version.Size)(humanize.Bytes(version.Size)),
+			version.ModTime.Format(time.Stamp),
+			color.RedString(version.Commit.ShortB58()),
+			pinStateToSymbol(version.IsPinned, version.IsExplicit),
+		)
+
+		key := version.BackendHash.B58String()
+		if version.IsPinned != pin && !seen[key] {
+			seen[key] = true
+			total += version.Size
+		}
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return 0, err
+	}
+
+	verb := "Pinned"
+	if dryRun {
+		verb = "Would pin"
+	}
+
+	summary := "%s %d versions; %s need to be fetched.\n"
+	if !pin {
+		verb = "Unpinned"
+		if dryRun {
+			verb = "Would unpin"
+		}
+
+		summary = "%s %d versions; %s can be reclaimed by the gc.\n"
+	}
+
+	fmt.Printf(summary, verb, len(versions), humanize.Bytes(total))
+	return len(versions), nil
+}
+
 func handleRepin(ctx *cli.Context, ctl *client.Client) error {
 	root := "/"
 	if len(ctx.Args()) > 0 {
@@ -652,6 +1093,16 @@ func handleWhoami(ctx *cli.Context, ctl *client.Client) error {
 	printAddr := ctx.Bool("addr")
 	printKey := ctx.Bool("key")
 
+	if ctx.Bool("record") {
+		record, err := ctl.FingerprintRecord()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(record)
+		return nil
+	}
+
 	userName := color.YellowString(self.CurrentUser)
 	ownerName := color.GreenString(self.Owner)
 
@@ -704,7 +1155,375 @@ func handleWhoami(ctx *cli.Context, ctl *client.Client) error {
 	return nil
 }
 
+func handleKeyList(ctx *cli.Context, ctl *client.Client) error {
+	subkeys, err := ctl.SubkeyList()
+	if err != nil {
+		return err
+	}
+
+	if len(subkeys) == 0 {
+		fmt.Println("No subkeys yet. Create some with »brig key rotate«.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "ID\tUSAGE\tCREATED\tEXPIRES\tCURRENT\t")
+	for _, subkey := range subkeys {
+		expires := subkey.Expires.Format(time.RFC3339)
+		if subkey.Expires.Before(time.Now()) {
+			expires = color.RedString(expires)
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			subkey.ID,
+			subkey.Usage,
+			subkey.Created.Format(time.RFC3339),
+			expires,
+			yesOrNo(subkey.Current),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleKeyRotate(ctx *cli.Context, ctl *client.Client) error {
+	subkey, err := ctl.SubkeyRotate(ctx.Args().First(), ctx.String("lifetime"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Created %s subkey %s (expires %s)\n",
+		subkey.Usage,
+		subkey.ID,
+		subkey.Expires.Format(time.RFC3339),
+	)
+
+	return nil
+}
+
 func handlePush(ctx *cli.Context, ctl *client.Client) error {
 	remoteName := ctx.Args().First()
 	return ctl.Push(remoteName, ctx.Bool("dry-run"))
 }
+
+func handleRemoteConfig(ctx *cli.Context, ctl *client.Client) error {
+	args := ctx.Args()
+	name := args.First()
+
+	action := "list"
+	if len(args) > 1 {
+		action = args[1]
+	}
+
+	switch action {
+	case "list", "ls":
+		return printRemoteConfig(ctl, name)
+	case "get":
+		if len(args) < 3 {
+			return ExitCode{BadArgs, "usage: brig remote config <name> get <key>"}
+		}
+
+		entries, err := ctl.RemoteConfigList(name)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.Key == args[2] || entry.Key == "fs."+args[2] {
+				fmt.Println(entry.Value)
+				return nil
+			}
+		}
+
+		return ExitCode{BadArgs, fmt.Sprintf("%s can not be set per remote", args[2])}
+	case "set":
+		if len(args) < 4 {
+			return ExitCode{BadArgs, "usage: brig remote config <name> set <key> <value>"}
+		}
+
+		return ctl.RemoteConfigSet(name, args[2], args[3])
+	case "unset", "rm":
+		if len(args) < 3 {
+			return ExitCode{BadArgs, "usage: brig remote config <name> unset <key>"}
+		}
+
+		return ctl.RemoteConfigSet(name, args[2], "")
+	default:
+		return ExitCode{BadArgs, fmt.Sprintf("unknown action: %s", action)}
+	}
+}
+
+func printRemoteConfig(ctl *client.Client, name string) error {
+	entries, err := ctl.RemoteConfigList(name)
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "KEY\tVALUE\tGLOBAL\t")
+	for _, entry := range entries {
+		value := entry.Value
+		if entry.Overridden {
+			value = color.GreenString(value)
+		}
+
+		fmt.Fprintf(tabW, "%s\t%s\t%s\t\n", entry.Key, value, entry.Global)
+	}
+
+	return tabW.Flush()
+}
+
+func handleReplStatus(ctx *cli.Context, ctl *client.Client) error {
+	status, err := ctl.ReplStatus(ctx.Bool("all"))
+	if err != nil {
+		return fmt.Errorf("repl: %w", err)
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, file := range status.Files {
+			if err := tmpl.Execute(os.Stdout, file); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, name := range status.Unreachable {
+		fmt.Printf("%s could not be reached; its copies are not counted.\n", color.YellowString(name))
+	}
+
+	if status.Total == 0 {
+		fmt.Println("No files fall under a replication target (see »net.replication.targets«).")
+		return nil
+	}
+
+	under := 0
+	for _, file := range status.Files {
+		if len(file.Holders) < file.Want {
+			under++
+		}
+	}
+
+	if !ctx.Bool("all") && under == 0 {
+		fmt.Printf("All %d files are stored on enough members.\n", status.Total)
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "PATH\tHAVE\tWANT\tTARGET\tSTORED BY\t")
+	for _, file := range status.Files {
+		have := fmt.Sprintf("%d", len(file.Holders))
+		if len(file.Holders) < file.Want {
+			have = color.RedString(have)
+		} else {
+			have = color.GreenString(have)
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%d\t%s\t%s\t\n",
+			color.CyanString(file.Path),
+			have,
+			file.Want,
+			file.Target,
+			strings.Join(file.Holders, ", "),
+		)
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d files are stored on too few members.\n", under, status.Total)
+	return nil
+}
+
+func handleRemoteStats(ctx *cli.Context, ctl *client.Client) error {
+	since := time.Time{}
+	if last := ctx.String("last"); last != "" {
+		age, err := parseAge(last)
+		if err != nil {
+			return ExitCode{BadArgs, fmt.Sprintf("bad --last: %v", err)}
+		}
+
+		since = time.Now().Add(-age)
+	}
+
+	sums, err := ctl.SyncStats(ctx.Args().First(), since)
+	if err != nil {
+		return fmt.Errorf("remote stats: %w", err)
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, sum := range sums {
+			if err := tmpl.Execute(os.Stdout, sum); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(sums) == 0 {
+		fmt.Println("No syncs were recorded yet.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tSYNCS\tFAILED\tFILES\tCONFLICTS\tRATE\tBYTES\tAVG TIME\tLAST SYNC\t")
+	for _, sum := range sums {
+		failed := fmt.Sprintf("%d", sum.Failed)
+		if sum.Failed > 0 {
+			failed = color.RedString(failed)
+		}
+
+		rate := fmt.Sprintf("%.1f%%", 100*sum.ConflictRate())
+		if sum.Conflicts > 0 {
+			rate = color.YellowString(rate)
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n",
+			color.MagentaString(sum.Remote),
+			sum.Syncs,
+			failed,
+			sum.Files,
+			sum.Conflicts,
+			rate,
+			humanize.Bytes(sum.Bytes),
+			sum.AvgDuration().Round(time.Millisecond),
+			sum.LastSync.Format(time.RFC3339),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleRemoteDelegate(ctx *cli.Context, ctl *client.Client) error {
+	holder, folder := ctx.Args().Get(0), ctx.Args().Get(1)
+	lifetime, err := time.ParseDuration(ctx.String("lifetime"))
+	if err != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("bad --lifetime: %v", err)}
+	}
+
+	cert, err := ctl.DelegationIssue(holder, folder, lifetime)
+	if err != nil {
+		return fmt.Errorf("delegate: %w", err)
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(cert))
+	return nil
+}
+
+// readDelegationCert reads a certificate printed by »brig remote delegate«
+// from `arg`, which is the certificate itself, a file or - for stdin.
+func readDelegationCert(arg string) ([]byte, error) {
+	var data []byte
+	var err error
+
+	if arg == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else if _, statErr := os.Stat(arg); statErr == nil {
+		data, err = ioutil.ReadFile(arg) // #nosec
+	} else {
+		data = []byte(arg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+}
+
+func handleRemoteDelegationAdd(ctx *cli.Context, ctl *client.Client) error {
+	cert, err := readDelegationCert(ctx.Args().First())
+	if err != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("bad certificate: %v", err)}
+	}
+
+	dg, err := ctl.DelegationAdd(cert)
+	if err != nil {
+		return fmt.Errorf("delegation add: %w", err)
+	}
+
+	fmt.Printf(
+		"You may read %s of everyone trusting %s until %s.\n",
+		color.GreenString(dg.Folder),
+		color.MagentaString(dg.Issuer),
+		dg.Expires.Format(time.Stamp),
+	)
+
+	return nil
+}
+
+func handleRemoteDelegationList(ctx *cli.Context, ctl *client.Client) error {
+	dgs, err := ctl.DelegationList()
+	if err != nil {
+		return fmt.Errorf("delegation list: %w", err)
+	}
+
+	if len(dgs) == 0 {
+		fmt.Println("No delegations were issued to you.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "ISSUER\tFOLDER\tISSUED\tEXPIRES\t")
+	for _, dg := range dgs {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t\n",
+			color.MagentaString(dg.Issuer),
+			color.GreenString(dg.Folder),
+			dg.Issued.Format(time.Stamp),
+			humanize.Time(dg.Expires),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleRemoteDelegationRemove(ctx *cli.Context, ctl *client.Client) error {
+	issuer, folder := ctx.Args().Get(0), ctx.Args().Get(1)
+	if err := ctl.DelegationRemove(issuer, folder); err != nil {
+		return fmt.Errorf("delegation remove: %w", err)
+	}
+
+	return nil
+}
@@ -38,6 +38,20 @@ func init() {
 	})
 }
 
+// onUsageError makes sure that bad flags end with BadArgs.
+func onUsageError(ctx *cli.Context, err error, isSubcommand bool) error {
+	return ExitCode{BadArgs, fmt.Sprintf("incorrect usage: %v (see --help)", err)}
+}
+
+// setOnUsageError sets onUsageError for `cmds` and all of their subcommands,
+// since cli does not inherit it from the app.
+func setOnUsageError(cmds []cli.Command) {
+	for idx := range cmds {
+		cmds[idx].OnUsageError = onUsageError
+		setOnUsageError(cmds[idx].Subcommands)
+	}
+}
+
 func formatGroup(category string) string {
 	return "\n" + strings.ToUpper(category) + " COMMANDS"
 }
@@ -108,8 +122,27 @@ func RunCmdline(args []string) int {
 		version.String(),
 		version.BuildTime,
 	)
-	app.CommandNotFound = commandNotFound
-	app.Description = "brig can be used to securely store, version and synchronize files between many peers."
+
+	var notFoundErr error
+	app.CommandNotFound = func(ctx *cli.Context, cmdName string) {
+		notFoundErr = commandNotFound(ctx, cmdName)
+	}
+
+	app.Description = `brig can be used to securely store, version and synchronize files between many peers.
+
+EXIT CODES:
+
+   0  success
+   1  bad arguments
+   2  bad password
+   3  daemon not reachable
+   4  unknown error
+   5  not found (path, remote, commit, ...)
+   6  conflict (exists already or changed in the meantime)
+   7  offline (we or a remote could not be reached)
+   8  unauthorized (a remote refused us or is blocked)
+
+   Use »--quiet« to print nothing and only look at the exit code.`
 
 	// Set global options here:
 	app.Before = func(ctx *cli.Context) error {
@@ -117,6 +150,12 @@ func RunCmdline(args []string) int {
 			color.NoColor = true
 		}
 
+		if ctx.Bool("quiet") {
+			if err := beQuiet(ctx); err != nil {
+				return fmt.Errorf("failed to be quiet: %w", err)
+			}
+		}
+
 		return nil
 	}
 
@@ -142,7 +181,7 @@ func RunCmdline(args []string) int {
 		},
 		cli.StringFlag{
 			Name:   "repo",
-			Usage:  "Path to the repository. Only has effect for new daemons.",
+			Usage:  "Path or registered name of the repository. Only has effect for new daemons.",
 			Value:  "",
 			EnvVar: "BRIG_PATH",
 		},
@@ -152,7 +191,7 @@ func RunCmdline(args []string) int {
 		},
 		cli.StringFlag{
 			Name:   "bind",
-			Usage:  "To what host to bind to. Do not expose to the outside. Seriously.",
+			Usage:  "To what host to bind to (IPv6 addresses like »::1« work too). Do not expose to the outside. Seriously.",
 			Value:  "localhost",
 			EnvVar: "BRIG_BIND",
 		},
@@ -170,6 +209,35 @@ func RunCmdline(args []string) int {
 			Name:  "no-color",
 			Usage: "Forbid the usage of colors.",
 		},
+		cli.BoolFlag{
+			Name:  "quiet,q",
+			Usage: "Print nothing; only the exit code tells what happened.",
+		},
+		cli.StringFlag{
+			Name:   "daemon-url",
+			Usage:  "Talk to the daemon at unix:///path/to/socket or tls://host:port instead of --port.",
+			EnvVar: "BRIG_DAEMON_URL",
+		},
+		cli.StringFlag{
+			Name:   "daemon-secret",
+			Usage:  "Secret to authenticate with the daemon given by --daemon-url.",
+			EnvVar: "BRIG_DAEMON_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "daemon-ca",
+			Usage:  "CA certificate to verify the daemon given by --daemon-url.",
+			EnvVar: "BRIG_DAEMON_CA",
+		},
+		cli.StringFlag{
+			Name:   "daemon-cert",
+			Usage:  "Client certificate to authenticate with the daemon given by --daemon-url.",
+			EnvVar: "BRIG_DAEMON_CERT",
+		},
+		cli.StringFlag{
+			Name:   "daemon-key",
+			Usage:  "Private key of --daemon-cert.",
+			EnvVar: "BRIG_DAEMON_KEY",
+		},
 	}
 
 	app.Commands = TranslateHelp([]cli.Command{
@@ -177,11 +245,29 @@ func RunCmdline(args []string) int {
 			Name:     "init",
 			Category: repoGroup,
 			Action:   handleInit,
+		}, {
+			Name:     "clone",
+			Category: repoGroup,
+			Action:   withArgCheck(needAtLeast(2), handleClone),
 		}, {
 			Name:     "whoami",
 			Aliases:  []string{"id"},
 			Category: netwGroup,
 			Action:   withDaemon(handleWhoami, true),
+		}, {
+			Name:     "key",
+			Category: netwGroup,
+			Action:   withDaemon(handleKeyList, true),
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  withDaemon(handleKeyList, true),
+				}, {
+					Name:   "rotate",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleKeyRotate, true)),
+				},
+			},
 		}, {
 			Name:     "remote",
 			Aliases:  []string{"rmt", "r"},
@@ -190,7 +276,7 @@ func RunCmdline(args []string) int {
 				{
 					Name:    "add",
 					Aliases: []string{"a", "set"},
-					Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteAdd, true)),
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteAdd, true)),
 				}, {
 					Name:    "remove",
 					Aliases: []string{"rm"},
@@ -205,9 +291,49 @@ func RunCmdline(args []string) int {
 				}, {
 					Name:   "edit",
 					Action: withDaemon(handleRemoteEdit, true),
+				}, {
+					Name:   "export",
+					Action: withDaemon(handleRemoteExport, true),
+				}, {
+					Name:   "import",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleRemoteImport, true)),
 				}, {
 					Name:   "ping",
 					Action: withArgCheck(needAtLeast(1), withDaemon(handleRemotePing, true)),
+				}, {
+					Name:   "verify",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleRemoteVerify, true)),
+				}, {
+					Name:   "discover",
+					Action: withDaemon(handleRemoteDiscover, true),
+				}, {
+					Name:   "head",
+					Action: withDaemon(handleRemoteHead, true),
+				}, {
+					Name:   "stats",
+					Action: withDaemon(handleRemoteStats, true),
+				}, {
+					Name:   "delegate",
+					Action: withArgCheck(needAtLeast(2), withDaemon(handleRemoteDelegate, true)),
+				}, {
+					Name:    "delegation",
+					Aliases: []string{"dg"},
+					Action:  withDaemon(handleRemoteDelegationList, true),
+					Subcommands: []cli.Command{
+						{
+							Name:    "add",
+							Aliases: []string{"a"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteDelegationAdd, true)),
+						}, {
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withDaemon(handleRemoteDelegationList, true),
+						}, {
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteDelegationRemove, true)),
+						},
+					},
 				}, {
 					Name:    "auto-update",
 					Aliases: []string{"au"},
@@ -216,10 +342,17 @@ func RunCmdline(args []string) int {
 					Name:    "accept-push",
 					Aliases: []string{"ap"},
 					Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteAcceptPush, true)),
+				}, {
+					Name:    "trust",
+					Aliases: []string{"t"},
+					Action:  withDaemon(handleRemoteTrust, true),
 				}, {
 					Name:    "conflict-strategy",
 					Aliases: []string{"cs"},
 					Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteConflictStrategy, true)),
+				}, {
+					Name:   "config",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleRemoteConfig, true)),
 				}, {
 					Name:    "folder",
 					Aliases: []string{"fld", "f"},
@@ -251,18 +384,18 @@ func RunCmdline(args []string) int {
 		}, {
 			Name:     "pin",
 			Category: vcscGroup,
-			Action:   withArgCheck(needAtLeast(1), withDaemon(handlePin, true)),
+			Action:   withArgCheck(needAtLeastOrSelector(1), withDaemon(handlePin, true)),
 			Subcommands: []cli.Command{
 				{
 					Name:   "add",
-					Action: withArgCheck(needAtLeast(1), withDaemon(handlePin, true)),
+					Action: withArgCheck(needAtLeastOrSelector(1), withDaemon(handlePin, true)),
 				}, {
 					Name:   "repin",
 					Action: withDaemon(handleRepin, true),
 				}, {
 					Name:    "remove",
 					Aliases: []string{"rm"},
-					Action:  withArgCheck(needAtLeast(1), withDaemon(handleUnpin, true)),
+					Action:  withArgCheck(needAtLeastOrSelector(1), withDaemon(handleUnpin, true)),
 				},
 			},
 		}, {
@@ -283,6 +416,16 @@ func RunCmdline(args []string) int {
 					Action: withArgCheck(needAtLeast(1), withDaemon(handleNetLocate, true)),
 				},
 			},
+		}, {
+			Name:     "repl",
+			Category: netwGroup,
+			Action:   withDaemon(handleReplStatus, true),
+			Subcommands: []cli.Command{
+				{
+					Name:   "status",
+					Action: withDaemon(handleReplStatus, true),
+				},
+			},
 		}, {
 			Name:     "status",
 			Aliases:  []string{"st"},
@@ -296,6 +439,10 @@ func RunCmdline(args []string) int {
 			Name:     "tag",
 			Category: vcscGroup,
 			Action:   withArgCheck(needAtLeast(1), withDaemon(handleTag, true)),
+		}, {
+			Name:     "snapshots",
+			Category: vcscGroup,
+			Action:   withDaemon(handleSnapshots, true),
 		}, {
 			Name:     "log",
 			Category: vcscGroup,
@@ -312,6 +459,18 @@ func RunCmdline(args []string) int {
 			Name:     "push",
 			Category: vcscGroup,
 			Action:   withArgCheck(needAtLeast(1), withDaemon(handlePush, true)),
+		}, {
+			Name:     "bundle",
+			Category: vcscGroup,
+			Subcommands: []cli.Command{
+				{
+					Name:   "create",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleBundleCreate, true)),
+				}, {
+					Name:   "apply",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleBundleApply, true)),
+				},
+			},
 		}, {
 			Name:     "commit",
 			Aliases:  []string{"cmt"},
@@ -322,6 +481,10 @@ func RunCmdline(args []string) int {
 			Aliases:  []string{"re"},
 			Category: vcscGroup,
 			Action:   withArgCheck(needAtLeast(1), withDaemon(handleReset, true)),
+		}, {
+			Name:     "undo",
+			Category: vcscGroup,
+			Action:   withDaemon(handleUndo, true),
 		}, {
 			Name:     "become",
 			Aliases:  []string{"be"},
@@ -346,6 +509,10 @@ func RunCmdline(args []string) int {
 			Name:     "cat",
 			Category: wdirGroup,
 			Action:   withDaemon(handleCat, true),
+		}, {
+			Name:     "archive",
+			Category: wdirGroup,
+			Action:   withDaemon(handleArchive, true),
 		}, {
 			Name:     "show",
 			Aliases:  []string{"s", "info"},
@@ -364,6 +531,18 @@ func RunCmdline(args []string) int {
 			Name:     "tree",
 			Category: wdirGroup,
 			Action:   withDaemon(handleTree, true),
+		}, {
+			Name:     "stat",
+			Category: wdirGroup,
+			Action:   withDaemon(handleStat, true),
+		}, {
+			Name:     "du",
+			Category: wdirGroup,
+			Action:   withDaemon(handleDiskUsage, true),
+		}, {
+			Name:     "search",
+			Category: wdirGroup,
+			Action:   withArgCheck(needAtLeast(1), withDaemon(handleSearch, true)),
 		}, {
 			Name:     "mkdir",
 			Category: wdirGroup,
@@ -393,8 +572,19 @@ func RunCmdline(args []string) int {
 				}, {
 					Name:   "ping",
 					Action: withDaemon(handleDaemonPing, false),
+				}, {
+					Name:   "status",
+					Action: withDaemon(handleDaemonStatus, true),
 				},
 			},
+		}, {
+			Name:     "stats",
+			Category: repoGroup,
+			Action:   withDaemon(handleStats, true),
+		}, {
+			Name:     "top",
+			Category: repoGroup,
+			Action:   withDaemon(handleTop, true),
 		}, {
 			Name:     "config",
 			Aliases:  []string{"cfg"},
@@ -437,6 +627,20 @@ func RunCmdline(args []string) int {
 					Action:  withDaemon(handleFstabList, true),
 				},
 			},
+		}, {
+			Name:     "compress",
+			Category: repoGroup,
+			Action:   withDaemon(handleCompressList, true),
+			Subcommands: []cli.Command{
+				{
+					Name:   "train",
+					Action: withDaemon(handleCompressTrain, true),
+				}, {
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  withDaemon(handleCompressList, true),
+				},
+			},
 		}, {
 			Name:     "trash",
 			Aliases:  []string{"tr"},
@@ -454,6 +658,26 @@ func RunCmdline(args []string) int {
 					Action:  withArgCheck(needAtLeast(1), withDaemon(handleTrashRemove, true)),
 				},
 			},
+		}, {
+			Name:     "hold",
+			Category: repoGroup,
+			Action:   withDaemon(handleHoldList, true),
+			Subcommands: []cli.Command{
+				{
+					Name:   "add",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleHoldAdd, true)),
+				},
+				{
+					Name:    "remove",
+					Aliases: []string{"rm"},
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleHoldRemove, true)),
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  withDaemon(handleHoldList, true),
+				},
+			},
 		}, {
 			Name:     "gateway",
 			Aliases:  []string{"gw"},
@@ -500,6 +724,27 @@ func RunCmdline(args []string) int {
 						},
 					},
 				},
+				{
+					Name:    "group",
+					Aliases: []string{"g"},
+					Subcommands: []cli.Command{
+						{
+							Name:    "add",
+							Aliases: []string{"a"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleGatewayGroupAdd, true)),
+						},
+						{
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleGatewayGroupRemove, true)),
+						},
+						{
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withDaemon(handleGatewayGroupList, true),
+						},
+					},
+				},
 			},
 		}, {
 			Name:     "debug",
@@ -510,8 +755,15 @@ func RunCmdline(args []string) int {
 					Name:    "pprof-port",
 					Aliases: []string{"p"},
 					Action:  withDaemon(handleDebugPprofPort, true),
+				}, {
+					Name:   "profile",
+					Action: withDaemon(handleDebugProfile, true),
 				},
 			},
+		}, {
+			Name:     "watch",
+			Category: repoGroup,
+			Action:   withDaemon(handleWatch, true),
 		}, {
 			Name:     "mount",
 			Category: repoGroup,
@@ -524,10 +776,71 @@ func RunCmdline(args []string) int {
 			Name:     "version",
 			Category: repoGroup,
 			Action:   withDaemon(handleVersion, false),
+		}, {
+			Name:     "repo",
+			Category: repoGroup,
+			Action:   handleRepoList,
+			Subcommands: []cli.Command{
+				{
+					Name:   "add",
+					Action: withArgCheck(needAtLeast(1), handleRepoAdd),
+				}, {
+					Name:    "remove",
+					Aliases: []string{"rm"},
+					Action:  withArgCheck(needAtLeast(1), handleRepoRemove),
+				}, {
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  handleRepoList,
+				}, {
+					Name:   "upgrade",
+					Action: handleRepoUpgrade,
+				},
+			},
+		}, {
+			Name:     "passwd",
+			Category: repoGroup,
+			Action:   handlePasswd,
+		}, {
+			Name:     "keychain",
+			Category: repoGroup,
+			Subcommands: []cli.Command{
+				{
+					Name:   "enable",
+					Action: handleKeychainEnable,
+				}, {
+					Name:   "disable",
+					Action: handleKeychainDisable,
+				},
+			},
+		}, {
+			Name:     "rename-owner",
+			Category: repoGroup,
+			Action:   withArgCheck(needAtLeast(1), handleRenameOwner),
+		}, {
+			Name:     "migrate-metadata",
+			Category: repoGroup,
+			Action:   withArgCheck(needAtLeast(1), handleMigrateMetadata),
+		}, {
+			Name:     "backup",
+			Category: repoGroup,
+			Subcommands: []cli.Command{
+				{
+					Name:   "create",
+					Action: withArgCheck(needAtLeast(1), handleBackupCreate),
+				}, {
+					Name:   "restore",
+					Action: withArgCheck(needAtLeast(1), handleBackupRestore),
+				},
+			},
 		}, {
 			Name:     "gc",
 			Category: repoGroup,
 			Action:   withDaemon(handleGc, true),
+		}, {
+			Name:     "fsck",
+			Category: repoGroup,
+			Action:   withDaemon(handleFsck, true),
 		}, {
 			Name:   "docs",
 			Action: handleOpenHelp,
@@ -538,15 +851,23 @@ func RunCmdline(args []string) int {
 		},
 	})
 
+	app.OnUsageError = onUsageError
+	setOnUsageError(app.Commands)
+
 	exitCode := Success
-	if err := app.Run(args); err != nil {
-		log.Error(prettyPrintError(err))
-		cerr, ok := err.(ExitCode)
-		if !ok {
-			exitCode = UnknownError
+	err := app.Run(expandArgs(app, args))
+	if err == nil {
+		// cli does not let commandNotFound return an error itself:
+		err = notFoundErr
+	}
+
+	if err != nil {
+		// Errors without message were already explained to the user.
+		if msg := prettyPrintError(err); msg != "" {
+			log.Error(msg)
 		}
 
-		exitCode = cerr.Code
+		exitCode = exitCodeFor(err)
 	}
 
 	return exitCode
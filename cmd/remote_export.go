@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/cmd/tabwriter"
+	"github.com/urfave/cli"
+	yml "gopkg.in/yaml.v2"
+)
+
+// remoteExportVersion is increased when the document format changes
+// in a way that older versions can not read anymore.
+const remoteExportVersion = 1
+
+// exportedRemote is a remote with its per-remote config overrides.
+type exportedRemote struct {
+	client.Remote `yaml:",inline"`
+	Config        map[string]string `yaml:"Config,omitempty" json:",omitempty"`
+}
+
+// remoteExport is the document written by `brig remote export`.
+// JSON and YAML use the same field names, so either can be imported.
+type remoteExport struct {
+	Version int              `yaml:"Version"`
+	Remotes []exportedRemote `yaml:"Remotes"`
+}
+
+func handleRemoteExport(ctx *cli.Context, ctl *client.Client) error {
+	format := ctx.String("format")
+	if format != "yaml" && format != "yml" && format != "json" {
+		return ExitCode{BadArgs, fmt.Sprintf("unknown format: %s (use yaml or json)", format)}
+	}
+
+	remotes, err := ctl.RemoteLs()
+	if err != nil {
+		return fmt.Errorf("remote ls: %w", err)
+	}
+
+	doc := remoteExport{Version: remoteExportVersion, Remotes: []exportedRemote{}}
+	for _, remote := range remotes {
+		entries, err := ctl.RemoteConfigList(remote.Name)
+		if err != nil {
+			return fmt.Errorf("remote config of %s: %w", remote.Name, err)
+		}
+
+		exported := exportedRemote{Remote: remote}
+		for _, entry := range entries {
+			if !entry.Overridden {
+				continue
+			}
+
+			if exported.Config == nil {
+				exported.Config = make(map[string]string)
+			}
+
+			exported.Config[entry.Key] = entry.Value
+		}
+
+		doc.Remotes = append(doc.Remotes, exported)
+	}
+
+	var data []byte
+	if format == "json" {
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yml.Marshal(doc)
+	}
+
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	outPath := ctx.String("output")
+	if outPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := ioutil.WriteFile(outPath, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d remotes to %s.\n", len(doc.Remotes), outPath)
+	return nil
+}
+
+// remoteImportAction is what happens to a single imported remote.
+type remoteImportAction struct {
+	Name   string
+	Action string
+	Reason string
+
+	// Remote is what gets saved; nil if nothing is saved.
+	Remote *exportedRemote
+}
+
+func mergeRemoteFolders(local, imported []client.RemoteFolder) []client.RemoteFolder {
+	seen := make(map[string]bool)
+	merged := []client.RemoteFolder{}
+	for _, folder := range local {
+		seen[folder.Folder] = true
+		merged = append(merged, folder)
+	}
+
+	for _, folder := range imported {
+		if !seen[folder.Folder] {
+			merged = append(merged, folder)
+		}
+	}
+
+	return merged
+}
+
+// planRemoteImport decides what to do with every remote in `doc`,
+// without changing anything. `self` is our own fingerprint; a remote
+// list exported on another device of ours might include us.
+func planRemoteImport(doc remoteExport, local []client.Remote, self, onConflict string) ([]remoteImportAction, error) {
+	byName := make(map[string]client.Remote)
+	byFingerprint := make(map[string]client.Remote)
+	for _, remote := range local {
+		byName[remote.Name] = remote
+		byFingerprint[remote.Fingerprint] = remote
+	}
+
+	plan := []remoteImportAction{}
+	for idx := range doc.Remotes {
+		imported := doc.Remotes[idx]
+		action := remoteImportAction{Name: imported.Name}
+
+		if imported.Name == "" || imported.Fingerprint == "" {
+			return nil, fmt.Errorf("remote #%d has no name or fingerprint", idx+1)
+		}
+
+		existing, nameTaken := byName[imported.Name]
+		other, fpTaken := byFingerprint[imported.Fingerprint]
+
+		switch {
+		case imported.Fingerprint == self:
+			action.Action = "skip"
+			action.Reason = "this is us"
+		case !nameTaken && fpTaken:
+			action.Action = "skip"
+			action.Reason = fmt.Sprintf("already known as %s", other.Name)
+		case !nameTaken:
+			action.Action = "add"
+			action.Remote = &imported
+		case onConflict == "fail":
+			return nil, fmt.Errorf("remote %s exists already", imported.Name)
+		case onConflict == "skip":
+			action.Action = "skip"
+			action.Reason = "exists already"
+		case onConflict == "overwrite":
+			action.Action = "overwrite"
+			action.Remote = &imported
+		case existing.Fingerprint != imported.Fingerprint:
+			// merge: never mix up two different peers.
+			return nil, fmt.Errorf(
+				"remote %s exists with a different fingerprint; use --on-conflict overwrite or skip",
+				imported.Name,
+			)
+		default:
+			// merge: keep our settings, but add missing folders and overrides.
+			merged := exportedRemote{Remote: existing, Config: imported.Config}
+			merged.Folders = mergeRemoteFolders(existing.Folders, imported.Folders)
+			action.Action = "merge"
+			action.Remote = &merged
+		}
+
+		plan = append(plan, action)
+	}
+
+	return plan, nil
+}
+
+// withoutLocalOverrides removes all keys from `config` that
+// are already overridden locally for the remote `name`.
+func withoutLocalOverrides(ctl *client.Client, name string, config map[string]string) map[string]string {
+	entries, err := ctl.RemoteConfigList(name)
+	if err != nil {
+		return config
+	}
+
+	filtered := make(map[string]string)
+	for key, value := range config {
+		filtered[key] = value
+	}
+
+	for _, entry := range entries {
+		if entry.Overridden {
+			delete(filtered, entry.Key)
+		}
+	}
+
+	return filtered
+}
+
+func handleRemoteImport(ctx *cli.Context, ctl *client.Client) error {
+	onConflict := ctx.String("on-conflict")
+	switch onConflict {
+	case "skip", "overwrite", "merge", "fail":
+	default:
+		return ExitCode{BadArgs, fmt.Sprintf("unknown conflict handling: %s", onConflict)}
+	}
+
+	inPath := ctx.Args().First()
+
+	var data []byte
+	var err error
+
+	if inPath == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(inPath) // #nosec
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// YAML is a superset of JSON, so this reads both formats:
+	doc := remoteExport{}
+	if err := yml.Unmarshal(data, &doc); err != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("failed to parse %s: %v", inPath, err)}
+	}
+
+	if doc.Version > remoteExportVersion {
+		return ExitCode{BadArgs, fmt.Sprintf("export was made by a newer brig (version %d)", doc.Version)}
+	}
+
+	local, err := ctl.RemoteLs()
+	if err != nil {
+		return fmt.Errorf("remote ls: %w", err)
+	}
+
+	self, err := ctl.Whoami()
+	if err != nil {
+		return fmt.Errorf("whoami: %w", err)
+	}
+
+	plan, err := planRemoteImport(doc, local, self.Fingerprint, onConflict)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Name < plan[j].Name
+	})
+
+	dryRun := ctx.Bool("dry-run")
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tACTION\t")
+	for _, action := range plan {
+		desc := action.Action
+		if action.Reason != "" {
+			desc += " (" + action.Reason + ")"
+		}
+
+		fmt.Fprintf(tabW, "%s\t%s\t\n", action.Name, desc)
+		if dryRun || action.Remote == nil {
+			continue
+		}
+
+		if err := ctl.RemoteAddOrUpdate(action.Remote.Remote); err != nil {
+			tabW.Flush()
+			return fmt.Errorf("save %s: %w", action.Name, err)
+		}
+
+		config := action.Remote.Config
+		if action.Action == "merge" {
+			config = withoutLocalOverrides(ctl, action.Name, config)
+		}
+
+		for key, value := range config {
+			if err := ctl.RemoteConfigSet(action.Name, key, value); err != nil {
+				fmt.Fprintf(tabW, "\t  warning: %s: %v\t\n", key, err)
+			}
+		}
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Println("Dry run; nothing was changed.")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -9,18 +10,23 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime/trace"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	e "github.com/pkg/errors"
 	"github.com/sahib/brig/client"
 	"github.com/sahib/brig/cmd/pwd"
 	"github.com/sahib/brig/cmd/tabwriter"
+	"github.com/sahib/brig/defaults"
 	"github.com/sahib/brig/gateway"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/repo/setup"
 	"github.com/sahib/brig/server"
 	"github.com/sahib/brig/util"
+	"github.com/sahib/brig/util/profile"
 	"github.com/sahib/brig/util/pwutil"
 	"github.com/sahib/brig/version"
 	log "github.com/sirupsen/logrus"
@@ -99,29 +105,52 @@ Have a nice day.
 		return err
 	}
 
-	return ctl.MakeCommit("added initial README.md")
+	return ctl.MakeCommit("added initial README.md", nil)
 }
 
 func handleInit(ctx *cli.Context) error {
-	if len(ctx.Args()) == 0 {
+	if len(ctx.Args()) == 0 && !ctx.Bool("wizard") {
 		return fmt.Errorf("init needs to be passed the name of the repository")
 	}
 
+	if ctx.NArg() > 2 {
+		return fmt.Errorf("too many arguments")
+	}
+
 	owner := ctx.Args().First()
+	tmpl, err := initTemplateFromArgs(ctx, &owner)
+	if err != nil {
+		return err
+	}
+
+	ctl, folder, err := initAndStartDaemon(ctx, owner, ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	// Run the actual handler:
+	defer ctl.Close()
+
+	if err := applyInitTemplate(ctl, tmpl); err != nil {
+		return err
+	}
+
+	return handleInitPost(ctx, ctl, folder)
+}
+
+// initAndStartDaemon creates a new repository for `owner` at `folderArg`
+// (or the default location if empty) and starts a daemon for it.
+func initAndStartDaemon(ctx *cli.Context, owner, folderArg string) (*client.Client, string, error) {
 	backend := ctx.String("backend")
-	folder := ctx.GlobalString("repo")
-	if ctx.NArg() == 2 {
+	folder := resolveRepoPath(ctx.GlobalString("repo"))
+	if folderArg != "" {
 		var err error
-		folder, err = filepath.Abs(ctx.Args().Get(1))
+		folder, err = filepath.Abs(folderArg)
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path for %s: %v", folder, err)
+			return nil, "", fmt.Errorf("failed to get absolute path for %s: %w", folder, err)
 		}
 	}
 
-	if ctx.NArg() > 2 {
-		return fmt.Errorf("too many arguments")
-	}
-
 	if folder == "" {
 		folder = guessRepoFolder(ctx)
 		fmt.Printf("-- Guessed folder for init: %s\n", folder)
@@ -131,11 +160,11 @@ func handleInit(ctx *cli.Context) error {
 	// doing init twice can easily break things.
 	isInitialized, err := repoIsInitialized(folder)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	if isInitialized {
-		return fmt.Errorf("`%s` already exists and is not empty; refusing to do init", folder)
+		return nil, "", fmt.Errorf("`%s` already exists and is not empty; refusing to do init", folder)
 	}
 
 	ipfsPath := ctx.String("ipfs-path")
@@ -147,7 +176,7 @@ func handleInit(ctx *cli.Context) error {
 		var err error
 		ipfsPath, err = setup.IPFS(os.Stdout, doIpfsSetup, doIpfsConfig, doExtraIpfsConfig, ipfsPath)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 	}
 
@@ -157,7 +186,7 @@ func handleInit(ctx *cli.Context) error {
 		var err error
 		password, err = pwutil.ReadPasswordFromHelper(folder, pwHelper)
 		if err != nil {
-			return fmt.Errorf("failed to read password from helper: %s", err)
+			return nil, "", fmt.Errorf("failed to read password from helper: %w", err)
 		}
 	}
 
@@ -166,7 +195,7 @@ func handleInit(ctx *cli.Context) error {
 		if err != nil {
 			msg := fmt.Sprintf("Failed to read password: %v", err)
 			fmt.Println(msg)
-			return ExitCode{UnknownError, msg}
+			return nil, "", ExitCode{UnknownError, msg}
 		}
 
 		password = string(pwdBytes)
@@ -174,26 +203,23 @@ func handleInit(ctx *cli.Context) error {
 
 	port, err := guessNextFreePort(ctx)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	if err := Init(ctx, folder, owner, password, backend, ipfsPath, port); err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("init failed: %v", err)}
+		return nil, "", fmt.Errorf("init failed: %w", err)
 	}
 
 	// Start the daemon on the freshly initialized repo:
 	ctl, err := startDaemon(ctx, folder, port)
 	if err != nil {
-		return ExitCode{
+		return nil, "", ExitCode{
 			DaemonNotResponding,
 			fmt.Sprintf("Unable to start daemon: %v", err),
 		}
 	}
 
-	// Run the actual handler:
-	defer ctl.Close()
-
-	return handleInitPost(ctx, ctl, folder)
+	return ctl, folder, nil
 }
 
 func handleInitPost(ctx *cli.Context, ctl *client.Client, folder string) error {
@@ -211,6 +237,11 @@ func handleInitPost(ctx *cli.Context, ctl *client.Client, folder string) error {
 		}
 	}
 
+	return setPasswordCommand(ctx, ctl)
+}
+
+// setPasswordCommand remembers how the password was passed on init.
+func setPasswordCommand(ctx *cli.Context, ctl *client.Client) error {
 	if ctx.Bool("no-password") {
 		// Set a command in the config that simply echoes a static password:
 		staticPasswordHelper := "echo no-password"
@@ -228,6 +259,71 @@ func handleInitPost(ctx *cli.Context, ctl *client.Client, folder string) error {
 	return nil
 }
 
+func handleClone(ctx *cli.Context) error {
+	if ctx.NArg() > 4 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	owner := ctx.Args().Get(0)
+	remoteName := ctx.Args().Get(1)
+	fingerprint := ctx.Args().Get(2)
+
+	if ctx.Bool("verify") {
+		var err error
+		if fingerprint, err = verifyFingerprint(remoteName, fingerprint); err != nil {
+			return fmt.Errorf("clone: verification failed: %w", err)
+		}
+	} else if fingerprint == "" {
+		return fmt.Errorf("clone: need a fingerprint or --verify")
+	}
+
+	tmpl, err := initTemplateFromArgs(ctx, &owner)
+	if err != nil {
+		return err
+	}
+
+	ctl, folder, err := initAndStartDaemon(ctx, owner, ctx.Args().Get(3))
+	if err != nil {
+		return err
+	}
+
+	defer ctl.Close()
+
+	if err := applyInitTemplate(ctl, tmpl); err != nil {
+		return err
+	}
+
+	if err := setPasswordCommand(ctx, ctl); err != nil {
+		return err
+	}
+
+	remote := client.Remote{
+		Name:             remoteName,
+		Fingerprint:      fingerprint,
+		AutoUpdate:       ctx.Bool("auto-update"),
+		ConflictStrategy: ctx.String("conflict-strategy"),
+	}
+
+	if err := ctl.RemoteAddOrUpdate(remote); err != nil {
+		return fmt.Errorf("clone: remote add: %w", err)
+	}
+
+	fmt.Printf("-- Fetching metadata of »%s«...\n", remoteName)
+	if _, err := ctl.Sync(remoteName, true); err != nil {
+		return fmt.Errorf("clone: repository at %s was created, but the sync failed: %w", folder, err)
+	}
+
+	for _, path := range ctx.StringSlice("pin") {
+		fmt.Printf("-- Pinning %s...\n", path)
+		if err := ctl.Pin(path); err != nil {
+			return fmt.Errorf("clone: pin %s: %w", path, err)
+		}
+	}
+
+	fmt.Printf("-- Cloned »%s« into %s\n", remoteName, folder)
+	return nil
+}
+
 func printConfigDocEntry(entry client.ConfigEntry) {
 	val := entry.Val
 	if val == "" {
@@ -247,6 +343,7 @@ func printConfigDocEntry(entry client.ConfigEntry) {
 		defaultVal = color.YellowString("(empty)")
 	}
 
+	fmt.Printf("  Type:          %v\n", entry.Type)
 	fmt.Printf("  Default:       %v\n", defaultVal)
 	fmt.Printf("  Documentation: %v\n", entry.Doc)
 	fmt.Printf("  Needs restart: %v\n", needsRestart)
@@ -255,7 +352,7 @@ func printConfigDocEntry(entry client.ConfigEntry) {
 func handleConfigList(cli *cli.Context, ctl *client.Client) error {
 	all, err := ctl.ConfigAll()
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config list: %v", err)}
+		return fmt.Errorf("config list: %w", err)
 	}
 
 	for _, entry := range all {
@@ -269,7 +366,7 @@ func handleConfigGet(ctx *cli.Context, ctl *client.Client) error {
 	key := ctx.Args().Get(0)
 	val, err := ctl.ConfigGet(key)
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config get: %v", err)}
+		return fmt.Errorf("config get: %w", err)
 	}
 
 	for _, elem := range strings.Split(val, " ;; ") {
@@ -287,12 +384,12 @@ func handleConfigSet(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.ConfigSet(key, val); err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config set: %v", err)}
+		return fmt.Errorf("config set: %w", err)
 	}
 
 	entry, err := ctl.ConfigDoc(key)
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config doc: %v", err)}
+		return fmt.Errorf("config doc: %w", err)
 	}
 
 	if entry.NeedsRestart {
@@ -306,7 +403,7 @@ func handleConfigDoc(ctx *cli.Context, ctl *client.Client) error {
 	key := ctx.Args().Get(0)
 	entry, err := ctl.ConfigDoc(key)
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config get: %v", err)}
+		return fmt.Errorf("config get: %w", err)
 	}
 
 	printConfigDocEntry(entry)
@@ -349,6 +446,119 @@ func handleDaemonQuit(ctx *cli.Context, ctl *client.Client) error {
 	return nil
 }
 
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func handleDaemonStatus(ctx *cli.Context, ctl *client.Client) error {
+	status, err := ctl.DaemonStatus()
+	if err != nil {
+		return fmt.Errorf("daemon status: %w", err)
+	}
+
+	scrub := status.Scrub
+	state := color.GreenString("idle")
+	if scrub.Running {
+		state = color.YellowString("running")
+	}
+
+	fmt.Println("Scrubber:")
+	fmt.Printf("  State:      %s\n", state)
+	fmt.Printf("  Last start: %s\n", formatOptionalTime(scrub.LastStart))
+	fmt.Printf("  Last end:   %s\n", formatOptionalTime(scrub.LastEnd))
+	fmt.Printf("  Checked:    %d files (%s)\n", scrub.Checked, humanize.Bytes(scrub.Bytes))
+
+	if scrub.Err != "" {
+		fmt.Printf("  Error:      %s\n", color.RedString(scrub.Err))
+	}
+
+	if len(scrub.Corrupt) == 0 {
+		fmt.Printf("  Corrupt:    %s\n", color.GreenString("none"))
+		return nil
+	}
+
+	fmt.Printf("  Corrupt:    %s\n", color.RedString("%d", len(scrub.Corrupt)))
+	for _, corruption := range scrub.Corrupt {
+		repaired := color.RedString("not repaired")
+		if corruption.Repaired {
+			repaired = color.GreenString("repaired")
+		}
+
+		fmt.Printf(
+			"    %s (%s): %s [%s]\n",
+			corruption.Path,
+			corruption.BackendHash.ShortB58(),
+			corruption.Err,
+			repaired,
+		)
+	}
+
+	return nil
+}
+
+func handleFsck(ctx *cli.Context, ctl *client.Client) error {
+	content := ctx.Bool("content")
+	refetch, unpin := ctx.Bool("refetch"), ctx.Bool("unpin")
+	if !content && (refetch || unpin) {
+		return ExitCode{BadArgs, "--refetch and --unpin need --content"}
+	}
+
+	report, err := ctl.Fsck(content, refetch, unpin)
+	if err != nil {
+		return fmt.Errorf("fsck: %w", err)
+	}
+
+	fmt.Printf("Metadata: %d nodes loaded\n", report.Nodes)
+	if !content {
+		return nil
+	}
+
+	fmt.Printf("Content:  %d pinned files checked (%s)\n", report.Checked, humanize.Bytes(report.Bytes))
+	if len(report.Problems) == 0 {
+		fmt.Printf("Problems: %s\n", color.GreenString("none"))
+		return nil
+	}
+
+	fmt.Printf("Problems: %s\n", color.RedString("%d", len(report.Problems)))
+
+	broken := 0
+	for _, problem := range report.Problems {
+		kind := "corrupt"
+		if problem.Missing {
+			kind = "missing"
+		}
+
+		outcome := color.RedString("broken")
+		switch {
+		case problem.Repaired:
+			outcome = color.GreenString("fetched again")
+		case problem.Unpinned:
+			outcome = color.YellowString("unpinned")
+		default:
+			broken++
+		}
+
+		fmt.Printf(
+			"  %s (%s): %s: %s [%s]\n",
+			problem.Path,
+			problem.BackendHash.ShortB58(),
+			kind,
+			problem.Err,
+			outcome,
+		)
+	}
+
+	if broken > 0 {
+		return ExitCode{UnknownError, fmt.Sprintf("%d files have broken content", broken)}
+	}
+
+	return nil
+}
+
 func handleDaemonLaunch(ctx *cli.Context) error {
 	// Enable tracing (for profiling) if required.
 	if ctx.Bool("trace") {
@@ -379,6 +589,13 @@ func handleDaemonLaunch(ctx *cli.Context) error {
 		return err
 	}
 
+	// Tell about a too new repository before asking for a password:
+	if isInitialized {
+		if err := repo.CheckFeatures(brigPath); err != nil {
+			return err
+		}
+	}
+
 	port := guessPort(ctx, true)
 	bindHost := ctx.GlobalString("bind")
 
@@ -416,10 +633,7 @@ func handleDaemonLaunch(ctx *cli.Context) error {
 
 		password, err = readPassword(ctx, brigPath)
 		if err != nil {
-			return "", ExitCode{
-				UnknownError,
-				fmt.Sprintf("Failed to read password: %v", err),
-			}
+			return "", fmt.Errorf("Failed to read password: %w", err)
 		}
 
 		return password, nil
@@ -432,19 +646,13 @@ func handleDaemonLaunch(ctx *cli.Context) error {
 
 	server, err := server.BootServer(brigPath, passwordFn, bindHost, port, logToStdout)
 	if err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("failed to boot brigd: %v", err),
-		}
+		return fmt.Errorf("failed to boot brigd: %w", err)
 	}
 
 	defer util.Closer(server)
 
 	if err := server.Serve(); err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("failed to serve: %v", err),
-		}
+		return fmt.Errorf("failed to serve: %w", err)
 	}
 
 	return nil
@@ -476,10 +684,7 @@ func handleMount(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.Mount(absMountPath, options); err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("Failed to mount: %v", err),
-		}
+		return fmt.Errorf("Failed to mount: %w", err)
 	}
 
 	return nil
@@ -493,10 +698,7 @@ func handleUnmount(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.Unmount(absMountPath); err != nil {
-		return ExitCode{
-			UnknownError,
-			fmt.Sprintf("Failed to unmount: %v", err),
-		}
+		return fmt.Errorf("Failed to unmount: %w", err)
 	}
 
 	return nil
@@ -524,6 +726,20 @@ func handleVersion(ctx *cli.Context, ctl *client.Client) error {
 }
 
 func handleGc(ctx *cli.Context, ctl *client.Client) error {
+	if ctx.Bool("dry-run") && !hasSelector(ctx) {
+		return fmt.Errorf("--dry-run needs at least one selector")
+	}
+
+	if hasSelector(ctx) {
+		if _, err := pinSelection(ctx, ctl, false); err != nil {
+			return err
+		}
+
+		if ctx.Bool("dry-run") {
+			return nil
+		}
+	}
+
 	aggressive := ctx.Bool("aggressive")
 	freed, err := ctl.GarbageCollect(aggressive)
 	if err != nil {
@@ -588,7 +804,7 @@ func handleFstabUnmounetAll(ctx *cli.Context, ctl *client.Client) error {
 func handleFstabList(ctx *cli.Context, ctl *client.Client) error {
 	mounts, err := ctl.FsTabList()
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("config list: %v", err)}
+		return fmt.Errorf("config list: %w", err)
 	}
 
 	if len(mounts) == 0 {
@@ -986,40 +1202,30 @@ func handleGatewayUserAdd(ctx *cli.Context, ctl *client.Client) error {
 		folders = ctx.Args()[2:]
 	}
 
-	allRights := []string{
-		"fs.download",
-		"fs.view",
-		"fs.edit",
-		"remotes.view",
-		"remotes.edit",
-	}
-
 	rights := []string{}
-	if ctx.Bool("role-admin") {
-		rights = allRights
-	}
-
-	if ctx.Bool("role-editor") {
-		rights = allRights[:len(allRights)-1]
-	}
-
-	if ctx.Bool("role-collaborator") {
-		rights = allRights[:len(allRights)-2]
-	}
-
-	if ctx.Bool("role-viewer") {
-		rights = allRights[:len(allRights)-3]
-	}
-
-	if ctx.Bool("role-link-only") {
-		rights = allRights[:len(allRights)-4]
+	switch {
+	case ctx.Bool("role-admin"):
+		rights = []string{"*"}
+	case ctx.Bool("role-editor"):
+		rights = []string{"fs.*", "remotes.view"}
+	case ctx.Bool("role-collaborator"):
+		rights = []string{"fs.view", "fs.edit", "fs.share", "fs.download"}
+	case ctx.Bool("role-viewer"):
+		rights = []string{"fs.view", "fs.download"}
+	case ctx.Bool("role-link-only"):
+		rights = []string{"fs.download"}
 	}
 
 	if r := ctx.String("rights"); r != "" {
 		rights = strings.Split(r, ",")
 	}
 
-	return ctl.GatewayUserAdd(name, password, folders, rights)
+	groups := []string{}
+	if g := ctx.String("groups"); g != "" {
+		groups = strings.Split(g, ",")
+	}
+
+	return ctl.GatewayUserAdd(name, password, folders, rights, groups)
 }
 
 func handleGatewayUserRemove(ctx *cli.Context, ctl *client.Client) error {
@@ -1033,7 +1239,12 @@ func handleGatewayUserRemove(ctx *cli.Context, ctl *client.Client) error {
 }
 
 func handleGatewayUserList(ctx *cli.Context, ctl *client.Client) error {
-	users, err := ctl.GatewayUserList()
+	listFn := ctl.GatewayUserList
+	if ctx.Bool("outdated") {
+		listFn = ctl.GatewayUserOutdated
+	}
+
+	users, err := listFn()
 	if err != nil {
 		return err
 	}
@@ -1049,10 +1260,12 @@ func handleGatewayUserList(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if tmpl == nil {
-		if len(users) == 0 {
+		if len(users) == 0 && ctx.Bool("outdated") {
+			fmt.Println("All password hashes are up to date.")
+		} else if len(users) == 0 {
 			fmt.Println("No users. Add some with »brig gw user add <name> <pass> <folders...>«")
 		} else {
-			fmt.Fprintln(tabW, "NAME\tFOLDERS\tRIGHTS\t")
+			fmt.Fprintln(tabW, "NAME\tFOLDERS\tRIGHTS\tGROUPS\tHASH\t")
 		}
 	}
 
@@ -1065,18 +1278,118 @@ func handleGatewayUserList(ctx *cli.Context, ctl *client.Client) error {
 			continue
 		}
 
+		hash := user.HashAlgorithm
+		if user.HashParams != "" {
+			hash += " (" + user.HashParams + ")"
+		}
+
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t\n",
+			"%s\t%s\t%s\t%s\t%s\t\n",
 			user.Name,
 			strings.Join(user.Folders, ","),
 			strings.Join(user.Rights, ","),
+			strings.Join(user.Groups, ","),
+			hash,
 		)
 	}
 
 	return tabW.Flush()
 }
 
+func handleGatewayGroupAdd(ctx *cli.Context, ctl *client.Client) error {
+	name := ctx.Args().First()
+	rights := []string{}
+	for _, arg := range ctx.Args().Tail() {
+		rights = append(rights, strings.Split(arg, ",")...)
+	}
+
+	return ctl.GatewayGroupAdd(name, rights)
+}
+
+func handleGatewayGroupRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, name := range ctx.Args() {
+		if err := ctl.GatewayGroupRemove(name); err != nil {
+			fmt.Printf("Failed to remove »%s«: %v\n", name, err)
+		}
+	}
+
+	return nil
+}
+
+func handleGatewayGroupList(ctx *cli.Context, ctl *client.Client) error {
+	groups, err := ctl.GatewayGroupList()
+	if err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No groups. Add some with »brig gw group add <name> <rights...>«")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tRIGHTS\t")
+	for _, group := range groups {
+		fmt.Fprintf(tabW, "%s\t%s\t\n", group.Name, strings.Join(group.Rights, ","))
+	}
+
+	return tabW.Flush()
+}
+
+func printCompressDicts(dicts []client.CompressDict) error {
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "TYPE\tID\tSIZE\tSAMPLES\t")
+	for _, dict := range dicts {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%08x\t%s\t%d\t\n",
+			dict.Class,
+			dict.ID,
+			humanize.Bytes(uint64(dict.Size)),
+			dict.Samples,
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleCompressTrain(ctx *cli.Context, ctl *client.Client) error {
+	dicts, err := ctl.CompressTrain()
+	if err != nil {
+		return err
+	}
+
+	if len(dicts) == 0 {
+		fmt.Println("Not enough similar small files to train a dictionary.")
+		return nil
+	}
+
+	return printCompressDicts(dicts)
+}
+
+func handleCompressList(ctx *cli.Context, ctl *client.Client) error {
+	dicts, err := ctl.CompressDicts()
+	if err != nil {
+		return err
+	}
+
+	if len(dicts) == 0 {
+		fmt.Println("No dictionaries. Create some with »brig compress train«")
+		return nil
+	}
+
+	return printCompressDicts(dicts)
+}
+
 func handleDebugPprofPort(ctx *cli.Context, ctl *client.Client) error {
 	port, err := ctl.DebugProfilePort()
 	if err != nil {
@@ -1092,3 +1405,519 @@ func handleDebugPprofPort(ctx *cli.Context, ctl *client.Client) error {
 
 	return nil
 }
+
+func handleDebugProfile(ctx *cli.Context, ctl *client.Client) error {
+	var kind profile.Kind
+	var duration time.Duration
+
+	for _, name := range profile.Names() {
+		if !ctx.IsSet(name) {
+			continue
+		}
+
+		if kind.Name != "" {
+			return ExitCode{BadArgs, "only one profile can be captured at a time"}
+		}
+
+		var err error
+		if kind, err = profile.KindByName(name); err != nil {
+			return ExitCode{BadArgs, err.Error()}
+		}
+
+		if kind.Timed {
+			if duration, err = time.ParseDuration(ctx.String(name)); err != nil {
+				return ExitCode{BadArgs, fmt.Sprintf("bad duration for --%s: %v", name, err)}
+			}
+		}
+	}
+
+	if kind.Name == "" {
+		return ExitCode{BadArgs, "please name a profile, e.g. --cpu 30s or --heap"}
+	}
+
+	outPath := ctx.String("output")
+	if outPath == "" {
+		outPath = fmt.Sprintf("brig-%s-%s.%s", kind.Name, time.Now().Format("20060102-150405"), kind.Ext)
+	}
+
+	if kind.Timed {
+		fmt.Printf("Capturing %s profile for %s...\n", kind.Name, duration)
+	}
+
+	data, err := ctl.DebugProfile(kind.Name, int(duration/time.Second))
+	if err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+
+	if err := ioutil.WriteFile(outPath, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s profile to %s. Look at it with:\n\n    %s %s\n", kind.Name, outPath, kind.Tool, outPath)
+	return nil
+}
+
+func handlePasswd(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	journalPath := ctx.String("recovery-file")
+
+	// The daemon would lock the repository again with the old password.
+	if err := checkDaemonNotRunning(ctx); err != nil {
+		return err
+	}
+
+	if ctx.Bool("recover") {
+		if err := repo.RecoverPasswordChange(folder, journalPath); err != nil {
+			return fmt.Errorf("recover failed: %w", err)
+		}
+
+		fmt.Println("Recovered from interrupted password change.")
+		return nil
+	}
+
+	oldPassword := readPasswordFromArgs(folder, ctx)
+	if oldPassword == "" {
+		fmt.Println("Please enter your current password.")
+		var err error
+		oldPassword, err = pwd.PromptPassword()
+		if err != nil {
+			return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+		}
+	}
+
+	if err := repo.CheckPassword(folder, oldPassword); err != nil {
+		return ExitCode{BadPassword, err.Error()}
+	}
+
+	fmt.Println("Please enter your new password.")
+	newPassword, err := pwd.PromptNewPassword(20)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.ChangePassword(folder, oldPassword, string(newPassword), journalPath); err != nil {
+		if err == repo.ErrPasswordChangePending {
+			return fmt.Errorf("%w (brig passwd --recover)", err)
+		}
+
+		return fmt.Errorf("passwd: %w", err)
+	}
+
+	fmt.Println("The password was changed successfully.")
+
+	if provider := configuredKeychain(folder); provider != "" {
+		if err := repo.StorePasswordInKeychain(folder, provider, string(newPassword)); err != nil {
+			fmt.Println(color.YellowString(
+				"Note: failed to update the password in the %s keychain: %v", provider, err,
+			))
+		}
+	}
+
+	cfg, err := openConfigOneshot(folder)
+	if err == nil && cfg.String("repo.password_command") != "" {
+		fmt.Println(color.YellowString(
+			"Note: a password command is configured; make sure it delivers the new password.",
+		))
+	}
+
+	return nil
+}
+
+func handleRenameOwner(ctx *cli.Context) error {
+	// The daemon publishes our name and would lock with the old state.
+	if err := checkDaemonNotRunning(ctx); err != nil {
+		return err
+	}
+
+	folder := guessRepoFolder(ctx)
+	password, err := readPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	rp, err := repo.Open(folder, password)
+	if err != nil {
+		return ExitCode{BadPassword, err.Error()}
+	}
+
+	oldOwner := rp.Owner
+	newOwner := ctx.Args().First()
+	renameErr := rp.RenameOwner(newOwner)
+
+	if err := rp.Close(password); err != nil {
+		return fmt.Errorf("failed to lock repository: %w", err)
+	}
+
+	if renameErr != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("rename-owner: %v", renameErr)}
+	}
+
+	fmt.Printf(
+		"%s is now called %s.\n",
+		color.YellowString(oldOwner),
+		color.GreenString(newOwner),
+	)
+	fmt.Println("Remotes are told about the new name once the daemon is started again.")
+	return nil
+}
+
+func handleMigrateMetadata(ctx *cli.Context) error {
+	// The daemon keeps the metadata open.
+	if err := checkDaemonNotRunning(ctx); err != nil {
+		return err
+	}
+
+	folder := guessRepoFolder(ctx)
+	password, err := readPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	rp, err := repo.Open(folder, password)
+	if err != nil {
+		return ExitCode{BadPassword, err.Error()}
+	}
+
+	backend := ctx.Args().First()
+	migrateErr := rp.MigrateMetadata(backend)
+
+	if err := rp.Close(password); err != nil {
+		return fmt.Errorf("failed to lock repository: %w", err)
+	}
+
+	if migrateErr != nil {
+		return fmt.Errorf("migrate-metadata: %w", migrateErr)
+	}
+
+	fmt.Printf("The metadata is now stored with %s.\n", color.GreenString(backend))
+	return nil
+}
+
+func handleRepoUpgrade(ctx *cli.Context) error {
+	// The daemon checked the features when it started.
+	if err := checkDaemonNotRunning(ctx); err != nil {
+		return err
+	}
+
+	folder := guessRepoFolder(ctx)
+	oldVersion, err := repo.UpgradeFeatures(folder)
+	if err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+
+	ft, err := repo.ReadFeatures(folder)
+	if err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+
+	if oldVersion == ft.Version {
+		fmt.Printf("Repository is up to date (version %d).\n", ft.Version)
+	} else {
+		fmt.Printf(
+			"Upgraded repository from version %s to %s.\n",
+			color.YellowString("%d", oldVersion),
+			color.GreenString("%d", ft.Version),
+		)
+	}
+
+	names := []string{}
+	for name := range ft.Flags {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "FEATURE\tVALUE\t")
+	for _, name := range names {
+		fmt.Fprintf(tabW, "%s\t%s\t\n", name, ft.Flags[name])
+	}
+
+	return tabW.Flush()
+}
+
+func checkDaemonNotRunning(ctx *cli.Context) error {
+	port := guessPort(ctx, true)
+	ctl, err := client.Dial(context.Background(), port)
+	if err != nil {
+		return nil
+	}
+
+	ctl.Close()
+	return ExitCode{
+		UnknownError,
+		"a daemon is running on this repository; please stop it first (brig daemon quit)",
+	}
+}
+
+func handleBackupCreate(ctx *cli.Context) error {
+	if err := checkDaemonNotRunning(ctx); err != nil {
+		return err
+	}
+
+	folder := guessRepoFolder(ctx)
+	password, err := readPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	fmt.Println("Please enter a passphrase for the backup.")
+	passphrase, err := pwd.PromptNewPassword(20)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read passphrase: %v", err)}
+	}
+
+	path := ctx.Args().First()
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.CreateBackup(folder, password, string(passphrase), fd); err != nil {
+		fd.Close()
+		os.Remove(path)
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("A backup was written to %s.\n", path)
+	return nil
+}
+
+func handleBackupRestore(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	if ctx.NArg() >= 2 {
+		folder = mustAbsPath(ctx.Args().Get(1))
+	}
+
+	isInitialized, err := repoIsInitialized(folder)
+	if err != nil {
+		return err
+	}
+
+	if isInitialized {
+		return fmt.Errorf("`%s` already exists and is not empty; refusing to restore", folder)
+	}
+
+	fd, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+
+	fmt.Println("Please enter the passphrase of the backup.")
+	passphrase, err := pwd.PromptPassword()
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read passphrase: %v", err)}
+	}
+
+	fmt.Println("Please enter a password for the restored repository.")
+	password, err := pwd.PromptNewPassword(20)
+	if err != nil {
+		return ExitCode{BadPassword, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.RestoreBackup(fd, folder, passphrase, string(password)); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	fmt.Printf("The backup was restored to %s.\n", folder)
+	fmt.Println("Check daemon.port and daemon.ipfs_path with »brig config« before syncing.")
+	return nil
+}
+
+func handleRepoAdd(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	folder := guessRepoFolder(ctx)
+	if ctx.NArg() >= 2 {
+		folder = mustAbsPath(ctx.Args().Get(1))
+	}
+
+	isInitialized, err := repoIsInitialized(folder)
+	if err != nil {
+		return err
+	}
+
+	if !isInitialized {
+		log.Warningf("there is no repository at %s (yet)", folder)
+	}
+
+	reg, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	if err := reg.Add(name, folder); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added »%s« for %s. Use it with »brig --repo %s«.\n", name, folder, name)
+	return nil
+}
+
+func handleRepoRemove(ctx *cli.Context) error {
+	reg, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	return reg.Remove(ctx.Args().First())
+}
+
+// repoStatus returns the owner, port and daemon state of the repo at `folder`.
+func repoStatus(folder string) (string, string, string) {
+	owner, err := ioutil.ReadFile(filepath.Join(folder, "OWNER")) // #nosec
+	if err != nil {
+		return "", "", color.RedString("missing")
+	}
+
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(folder, "config.yml"))
+	if err != nil {
+		return string(owner), "", color.RedString("broken config")
+	}
+
+	port := cfg.Int("daemon.port")
+	state := color.YellowString("stopped")
+	if ctl, err := client.Dial(context.Background(), int(port)); err == nil {
+		if whoami, err := ctl.Whoami(); err == nil && whoami.Owner == string(owner) {
+			state = color.GreenString("running")
+		} else {
+			state = color.RedString("port in use")
+		}
+
+		ctl.Close()
+	}
+
+	return string(owner), fmt.Sprintf("%d", port), state
+}
+
+func handleRepoList(ctx *cli.Context) error {
+	reg, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	entries := reg.List()
+	if len(entries) == 0 {
+		fmt.Println("No repositories registered yet. Add some with »brig repo add <name> <path>«.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tPATH\tOWNER\tPORT\tDAEMON\t")
+	for _, entry := range entries {
+		owner, port, state := repoStatus(entry.Path)
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			entry.Name,
+			entry.Path,
+			owner,
+			port,
+			state,
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleWatch(ctx *cli.Context, ctl *client.Client) error {
+	consumer := ctx.String("name")
+	kinds := ctx.StringSlice("kind")
+	hook := ctx.String("exec")
+	asJSON := ctx.Bool("json")
+
+	since := uint64(0)
+	for {
+		evs, seq, err := ctl.EventsWait(consumer, since, 30*time.Second, kinds)
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range evs {
+			if err := printOrRunEvent(ev, hook, asJSON); err != nil {
+				return err
+			}
+
+			// Hooks might have side effects; do not run them twice.
+			if consumer != "" && hook != "" {
+				if err := ctl.EventsAck(consumer, ev.Seq); err != nil {
+					return err
+				}
+			}
+		}
+
+		if consumer != "" && seq > since {
+			if err := ctl.EventsAck(consumer, seq); err != nil {
+				return err
+			}
+		}
+
+		since = seq
+	}
+}
+
+func printOrRunEvent(ev client.BusEvent, hook string, asJSON bool) error {
+	if hook != "" {
+		cmd := exec.Command("/bin/sh", "-c", hook) // #nosec
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(
+			os.Environ(),
+			fmt.Sprintf("BRIG_EVENT_SEQ=%d", ev.Seq),
+			fmt.Sprintf("BRIG_EVENT_KIND=%s", ev.Kind),
+			fmt.Sprintf("BRIG_EVENT_TIME=%s", ev.Time.Format(time.RFC3339)),
+			fmt.Sprintf("BRIG_EVENT_PATH=%s", ev.Path),
+			fmt.Sprintf("BRIG_EVENT_REMOTE=%s", ev.Remote),
+			fmt.Sprintf("BRIG_EVENT_MESSAGE=%s", ev.Message),
+		)
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook failed for event %d: %w", ev.Seq, err)
+		}
+
+		return nil
+	}
+
+	if asJSON {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	}
+
+	details := []string{}
+	for _, detail := range []string{ev.Path, ev.Remote, ev.Message} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	if ev.Total > 0 {
+		details = append(details, fmt.Sprintf("%d/%d", ev.Done, ev.Total))
+	}
+
+	fmt.Printf(
+		"%s %s %s\n",
+		color.BlueString(ev.Time.Format(time.Stamp)),
+		color.GreenString(ev.Kind),
+		strings.Join(details, " "),
+	)
+
+	return nil
+}
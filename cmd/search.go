@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/cmd/tabwriter"
+	"github.com/urfave/cli"
+)
+
+// searchJSONResult is how a single result is printed with --json.
+type searchJSONResult struct {
+	Path     string    `json:"path"`
+	Score    float64   `json:"score"`
+	Size     uint64    `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	MimeType string    `json:"mime_type"`
+	Kind     string    `json:"kind"`
+}
+
+type searchJSONOutput struct {
+	Total   int                `json:"total"`
+	Offset  int                `json:"offset"`
+	Results []searchJSONResult `json:"results"`
+}
+
+func handleSearch(ctx *cli.Context, ctl *client.Client) error {
+	if ctx.Int("offset") < 0 || ctx.Int("limit") < 0 {
+		return ExitCode{BadArgs, "offset and limit may not be negative"}
+	}
+
+	query := client.SearchQuery{
+		Root:   ctx.String("path"),
+		Text:   strings.Join(ctx.Args(), " "),
+		Type:   ctx.String("type"),
+		Offset: ctx.Int("offset"),
+		Limit:  ctx.Int("limit"),
+	}
+
+	results, total, err := ctl.Search(query)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+
+	if ctx.Bool("json") {
+		out := searchJSONOutput{
+			Total:   total,
+			Offset:  query.Offset,
+			Results: []searchJSONResult{},
+		}
+
+		for _, result := range results {
+			out.Results = append(out.Results, searchJSONResult{
+				Path:     result.Info.Path,
+				Score:    result.Score,
+				Size:     result.Info.Size,
+				ModTime:  result.Info.ModTime,
+				MimeType: result.Info.MimeType,
+				Kind:     result.Info.Kind,
+			})
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No results (%d in total).\n", total)
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "SCORE\tSIZE\tMODTIME\tPATH\t")
+	for _, result := range results {
+		fmt.Fprintf(
+			tabW,
+			"%.2f\t%s\t%s\t%s\t\n",
+			result.Score,
+			humanize.Bytes(result.Info.Size),
+			result.Info.ModTime.Format(time.UnixDate),
+			color.WhiteString(result.Info.Path),
+		)
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	first := query.Offset + 1
+	last := query.Offset + len(results)
+	fmt.Printf("\nShowing %d-%d of %d results.\n", first, last, total)
+	if last < total {
+		fmt.Printf("Use »--offset %d« to see more.\n", last)
+	}
+
+	return nil
+}
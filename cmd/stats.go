@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/cmd/tabwriter"
+	"github.com/urfave/cli"
+)
+
+func handleStats(ctx *cli.Context, ctl *client.Client) error {
+	stats, err := ctl.DaemonStats(ctx.Bool("reset"))
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	fmt.Printf("Uptime:     %s\n", time.Since(stats.Started).Round(time.Second))
+	fmt.Printf("Counted:    since %s\n", stats.Since.Format(time.Stamp))
+
+	hitRate := "n/a"
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		hitRate = fmt.Sprintf("%.1f%%", 100*float64(stats.CacheHits)/float64(lookups))
+	}
+
+	fmt.Printf(
+		"Node cache: %s hit rate (%d hits, %d misses)\n",
+		hitRate,
+		stats.CacheHits,
+		stats.CacheMisses,
+	)
+
+	if stats.BlockCacheMaxSize > 0 {
+		blockHitRate := "n/a"
+		if lookups := stats.BlockCacheHits + stats.BlockCacheMisses; lookups > 0 {
+			blockHitRate = fmt.Sprintf("%.1f%%", 100*float64(stats.BlockCacheHits)/float64(lookups))
+		}
+
+		fmt.Printf(
+			"Block cache: %s hit rate (%d hits, %d misses), %s of %s used\n",
+			blockHitRate,
+			stats.BlockCacheHits,
+			stats.BlockCacheMisses,
+			humanize.Bytes(uint64(stats.BlockCacheSize)),
+			humanize.Bytes(uint64(stats.BlockCacheMaxSize)),
+		)
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	if len(stats.Ops) > 0 {
+		fmt.Fprintln(tabW, "\nOPERATION\tCALLS\tERRORS\t")
+		for _, op := range stats.Ops {
+			failed := fmt.Sprintf("%d", op.Errors)
+			if op.Errors > 0 {
+				failed = color.RedString(failed)
+			}
+
+			fmt.Fprintf(tabW, "%s\t%d\t%s\t\n", op.Name, op.Calls, failed)
+		}
+	}
+
+	if len(stats.Remotes) > 0 {
+		fmt.Fprintln(tabW, "\nREMOTE\tSYNCS\tFETCHED\t")
+		for _, remote := range stats.Remotes {
+			fmt.Fprintf(
+				tabW,
+				"%s\t%d\t%s\t\n",
+				remote.Name,
+				remote.Syncs,
+				humanize.Bytes(uint64(remote.Bytes)),
+			)
+		}
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if ctx.Bool("reset") {
+		fmt.Println("\nAll counters were reset.")
+	}
+
+	return nil
+}
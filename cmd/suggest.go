@@ -226,7 +226,9 @@ func completeSubcommands(ctx *cli.Context) {
 	}
 }
 
-func commandNotFound(ctx *cli.Context, cmdName string) {
+// commandNotFound tells the user about a bad command and suggests
+// similar ones. The returned error has no message, it was printed already.
+func commandNotFound(ctx *cli.Context, cmdName string) error {
 	// Try to find the commands we need to look at for a suggestion.
 	// We only want to show the user the relevant subcommands.
 	cmdPath, lastGoodCmds := findLastGoodCommands(ctx)
@@ -258,4 +260,6 @@ func commandNotFound(ctx *cli.Context, cmdName string) {
 			fmt.Printf("  * %s\n", color.GreenString(similar.name))
 		}
 	}
+
+	return ExitCode{BadArgs, ""}
 }
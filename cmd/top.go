@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/cmd/tabwriter"
+	"github.com/urfave/cli"
+)
+
+// topRate returns how many bytes per second changed between two samples.
+func topRate(prev, curr int64, elapsed time.Duration) string {
+	if elapsed <= 0 || curr < prev {
+		return "-"
+	}
+
+	perSec := float64(curr-prev) / elapsed.Seconds()
+	return humanize.Bytes(uint64(perSec)) + "/s"
+}
+
+func printTop(w io.Writer, prev, curr *client.DaemonActivity) {
+	tabW := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.StripEscape)
+
+	fmt.Fprintf(tabW, "brig top - %s\n", curr.Taken.Format(time.Stamp))
+
+	fmt.Fprintln(tabW, "\nTRANSFER\tREMOTE\tWHAT\tBYTES\tRUNNING\t")
+	if len(curr.Transfers) == 0 {
+		fmt.Fprintln(tabW, "-\t\t\t\t\t")
+	}
+
+	for _, transfer := range curr.Transfers {
+		remote := transfer.Remote
+		if remote == "" {
+			remote = "*"
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			transfer.Kind,
+			remote,
+			transfer.What,
+			humanize.Bytes(uint64(transfer.Bytes)),
+			curr.Taken.Sub(transfer.Started).Round(time.Second),
+		)
+	}
+
+	fmt.Fprintln(tabW, "\nCALL\tACTIVE\t")
+	if len(curr.Calls) == 0 {
+		fmt.Fprintln(tabW, "-\t\t")
+	}
+
+	names := []string{}
+	for name := range curr.Calls {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(tabW, "%s\t%d\t\n", name, curr.Calls[name])
+	}
+
+	if len(curr.Mounts) > 0 {
+		fmt.Fprintln(tabW, "\nMOUNT\tOPEN HANDLES\tPENDING WRITES\t")
+		for _, mount := range curr.Mounts {
+			fmt.Fprintf(tabW, "%s\t%d\t%d\t\n", mount.Dir, mount.OpenHandles, mount.PendingWrites)
+		}
+	}
+
+	fmt.Fprintln(tabW, "\nQUEUE\tDEPTH\t")
+	for _, queue := range curr.Queues {
+		fmt.Fprintf(tabW, "%s\t%d\t\n", queue.Name, queue.Depth)
+	}
+
+	if len(curr.Traffic) > 0 {
+		prevTraffic := make(map[string]client.RemoteTraffic)
+		elapsed := time.Duration(0)
+		if prev != nil {
+			elapsed = curr.Taken.Sub(prev.Taken)
+			for _, traffic := range prev.Traffic {
+				prevTraffic[traffic.Name] = traffic
+			}
+		}
+
+		fmt.Fprintln(tabW, "\nREMOTE\tRECV\tSENT\tRECV TOTAL\tSENT TOTAL\t")
+		for _, traffic := range curr.Traffic {
+			before := prevTraffic[traffic.Name]
+			fmt.Fprintf(
+				tabW,
+				"%s\t%s\t%s\t%s\t%s\t\n",
+				traffic.Name,
+				topRate(before.Received, traffic.Received, elapsed),
+				topRate(before.Sent, traffic.Sent, elapsed),
+				humanize.Bytes(uint64(traffic.Received)),
+				humanize.Bytes(uint64(traffic.Sent)),
+			)
+		}
+	}
+
+	tabW.Flush()
+}
+
+func handleTop(ctx *cli.Context, ctl *client.Client) error {
+	interval, err := time.ParseDuration(ctx.String("interval"))
+	if err != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("bad interval: %v", err)}
+	}
+
+	stream, err := ctl.ActivityStream(interval)
+	if err != nil {
+		return fmt.Errorf("top: %w", err)
+	}
+
+	defer stream.Close()
+
+	var prev *client.DaemonActivity
+	for {
+		curr, err := stream.Next()
+		if err != nil {
+			return fmt.Errorf("top: %w", err)
+		}
+
+		if !ctx.Bool("once") {
+			// Move the cursor home and clear the screen:
+			fmt.Print("\033[H\033[2J")
+		}
+
+		printTop(os.Stdout, prev, curr)
+		if ctx.Bool("once") {
+			return nil
+		}
+
+		prev = curr
+	}
+}
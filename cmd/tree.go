@@ -30,6 +30,10 @@ type treeNode struct {
 type treeCfg struct {
 	showPin bool
 	format  func(n *treeNode) string
+
+	// less orders the children of a directory.
+	// If nil, they are sorted by name.
+	less func(a, b client.StatInfo) bool
 }
 
 // This is a very stripped down version of util.Trie.Insert()
@@ -121,7 +125,17 @@ func (n *treeNode) Print(cfg *treeCfg) {
 	parents := make([]*treeNode, n.depth)
 	curr := n
 
-	sort.Sort(n)
+	if cfg.less != nil {
+		sort.SliceStable(n.order, func(i, j int) bool {
+			return cfg.less(n.order[i].entry, n.order[j].entry)
+		})
+
+		for idx, child := range n.order {
+			child.isLast = idx == len(n.order)-1
+		}
+	} else {
+		sort.Sort(n)
+	}
 
 	// You could do probably go upwards and print to
 	// a string buffer for performance, but this is probably
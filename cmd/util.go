@@ -3,11 +3,14 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,11 +20,13 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/sahib/brig/client"
 	"github.com/sahib/brig/cmd/pwd"
 	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/util/pwutil"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
@@ -45,6 +50,27 @@ func (err ExitCode) Error() string {
 	return err.Message
 }
 
+// beQuiet makes sure nothing is printed anymore, neither the output of
+// the command nor errors or log messages. Used by --quiet for scripts
+// that only look at the exit code. Some writers were set up before we
+// knew about --quiet, so they have to be replaced as well.
+func beQuiet(ctx *cli.Context) error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout = devNull
+	os.Stderr = devNull
+	ctx.App.Writer = ioutil.Discard
+	ctx.App.ErrWriter = ioutil.Discard
+	cli.ErrWriter = ioutil.Discard
+	color.Output = ioutil.Discard
+	color.Error = ioutil.Discard
+	log.SetOutput(ioutil.Discard)
+	return nil
+}
+
 func mustAbsPath(path string) string {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -71,12 +97,42 @@ func checkmarkify(val bool) string {
 	return ""
 }
 
+func openRegistry() (*repo.Registry, error) {
+	registryPath, err := repo.DefaultRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.OpenRegistry(registryPath)
+}
+
+// resolveRepoPath checks if `nameOrPath` is the name of a repository
+// in the registry. If so, its path is returned, otherwise `nameOrPath`.
+func resolveRepoPath(nameOrPath string) string {
+	if !repo.IsValidRepoName(nameOrPath) {
+		return nameOrPath
+	}
+
+	reg, err := openRegistry()
+	if err != nil {
+		log.Warningf("failed to open repository registry: %v", err)
+		return nameOrPath
+	}
+
+	entry, err := reg.Entry(nameOrPath)
+	if err != nil {
+		return nameOrPath
+	}
+
+	return entry.Path
+}
+
 // guessRepoFolder tries to find the repository path
 // by using a number of sources.
 // This helper may call exit when it fails to get the path.
 func guessRepoFolder(ctx *cli.Context) string {
 	if argPath := ctx.GlobalString("repo"); argPath != "" {
-		return mustAbsPath(argPath)
+		return mustAbsPath(resolveRepoPath(argPath))
 	}
 
 	dir, err := homedir.Expand("~/.brig")
@@ -123,7 +179,7 @@ func openConfigOneshot(folder string) (*config.Config, error) {
 		fmt.Fprintf(os.Stderr, "where the repository is to find out the right port number.\n")
 		fmt.Fprintf(os.Stderr, "I will continue by assuming the default port: 6666\n\n")
 		fmt.Fprintf(os.Stderr, "--------------------------------------------------\n\n")
-		return nil, fmt.Errorf("could not find config: %v", err)
+		return nil, fmt.Errorf("could not find config: %w", err)
 	}
 
 	return cfg, nil
@@ -192,6 +248,10 @@ func readPassword(ctx *cli.Context, repoPath string) (string, error) {
 		return password, nil
 	}
 
+	if password := readPasswordFromKeychain(ctx, repoPath); password != "" {
+		return password, nil
+	}
+
 	// Read the password from stdin:
 	password, err := pwd.PromptPassword()
 	if err != nil {
@@ -245,7 +305,7 @@ func startDaemon(ctx *cli.Context, repoPath string, port int) (*client.Client, e
 	if err != nil {
 		logVerbose(ctx, "failed to open config for guessing password method: %v", err)
 	} else {
-		if cfg.String("repo.password_command") != "" {
+		if cfg.String("repo.password_command") != "" || cfg.String("repo.keychain") != "" {
 			askPassword = false
 		}
 	}
@@ -342,8 +402,84 @@ func guessNextRepoFolder(ctx *cli.Context) string {
 	return folder
 }
 
+// remoteDialOptions tells how to reach a daemon given by --daemon-url.
+// It returns nil if no url was given.
+func remoteDialOptions(ctx *cli.Context) (*client.DialOptions, error) {
+	rawURL := ctx.GlobalString("daemon-url")
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &client.DialOptions{
+		Network: "tcp",
+		Addr:    u.Host,
+		Secret:  ctx.GlobalString("daemon-secret"),
+	}
+
+	switch u.Scheme {
+	case "unix":
+		opts.Network = "unix"
+		opts.Addr = u.Path
+	case "tcp":
+	case "tls":
+		tlsCfg := &tls.Config{
+			ServerName: u.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}
+
+		if caPath := ctx.GlobalString("daemon-ca"); caPath != "" {
+			caData, err := ioutil.ReadFile(caPath) // #nosec
+			if err != nil {
+				return nil, err
+			}
+
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(caData) {
+				return nil, fmt.Errorf("no certificates found in %s", caPath)
+			}
+		}
+
+		if certPath := ctx.GlobalString("daemon-cert"); certPath != "" {
+			cert, err := tls.LoadX509KeyPair(certPath, ctx.GlobalString("daemon-key"))
+			if err != nil {
+				return nil, err
+			}
+
+			tlsCfg.Certificates = []tls.Certificate{cert}
+		}
+
+		opts.TLSConfig = tlsCfg
+	default:
+		return nil, fmt.Errorf("unsupported daemon url scheme: %s", u.Scheme)
+	}
+
+	return opts, nil
+}
+
 func withDaemon(handler cmdHandlerWithClient, startNew bool) cli.ActionFunc {
 	return func(ctx *cli.Context) error {
+		remoteOpts, err := remoteDialOptions(ctx)
+		if err != nil {
+			return ExitCode{BadArgs, fmt.Sprintf("bad --daemon-url: %v", err)}
+		}
+
+		if remoteOpts != nil {
+			// A daemon somewhere else can not be started by us.
+			logVerbose(ctx, "connecting to daemon at %s", remoteOpts.Addr)
+			ctl, err := client.DialWithOptions(context.Background(), *remoteOpts)
+			if err != nil {
+				return ExitCode{DaemonNotResponding, fmt.Sprintf("Daemon not reachable: %v", err)}
+			}
+
+			defer ctl.Close()
+			return handler(ctx, ctl)
+		}
+
 		port := guessPort(ctx, true)
 		if startNew {
 			logVerbose(ctx, "using port %d to check for running daemon.", port)
@@ -386,7 +522,8 @@ type checkFunc func(ctx *cli.Context) int
 func withArgCheck(checker checkFunc, handler cli.ActionFunc) cli.ActionFunc {
 	return func(ctx *cli.Context) error {
 		if checker(ctx) != Success {
-			os.Exit(BadArgs)
+			// The checker already told the user what's wrong.
+			return ExitCode{BadArgs, ""}
 		}
 
 		return handler(ctx)
@@ -417,6 +554,18 @@ func needAtLeast(min int) checkFunc {
 	}
 }
 
+// needAtLeastOrSelector is like needAtLeast,
+// but is also happy if selectors were given instead of arguments.
+func needAtLeastOrSelector(min int) checkFunc {
+	return func(ctx *cli.Context) int {
+		if hasSelector(ctx) {
+			return Success
+		}
+
+		return needAtLeast(min)(ctx)
+	}
+}
+
 func repoIsInitialized(dir string) (bool, error) {
 	fd, err := os.Open(dir) // #nosec
 	if err != nil && os.IsNotExist(err) {
@@ -499,7 +648,7 @@ func editToPath(data []byte, suffix string) (string, error) {
 
 	if err := cmd.Run(); err != nil {
 		doDelete = true
-		return "", fmt.Errorf("Running $EDITOR (%s) failed: %v", editor, err)
+		return "", fmt.Errorf("Running $EDITOR (%s) failed: %w", editor, err)
 	}
 
 	if _, err := fd.Seek(0, io.SeekStart); err != nil {
@@ -550,6 +699,74 @@ func parseDuration(s string) (float64, error) {
 	return float64(dur) / float64(time.Second), nil
 }
 
+// parseAge works like time.ParseDuration(), but also knows
+// about days (»d«), weeks (»w«) and years (»y«) as units.
+func parseAge(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	return time.ParseDuration(s)
+}
+
+// hasSelector returns true if any of the selectorFlags was given.
+func hasSelector(ctx *cli.Context) bool {
+	for _, name := range []string{"older-than", "larger-than", "glob", "commits"} {
+		if ctx.IsSet(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectorFromContext builds a selector out of the selectorFlags.
+// The first argument, if any, is used as root directory.
+func selectorFromContext(ctx *cli.Context) (client.Selector, error) {
+	sel := client.Selector{
+		Root:    "/",
+		Globs:   ctx.StringSlice("glob"),
+		Commits: ctx.String("commits"),
+	}
+
+	if ctx.NArg() > 0 {
+		sel.Root = ctx.Args().First()
+	}
+
+	if ctx.IsSet("older-than") {
+		age, err := parseAge(ctx.String("older-than"))
+		if err != nil {
+			return sel, err
+		}
+
+		sel.OlderThan = time.Now().Add(-age)
+	}
+
+	if ctx.IsSet("larger-than") {
+		size, err := humanize.ParseBytes(ctx.String("larger-than"))
+		if err != nil {
+			return sel, err
+		}
+
+		sel.LargerThan = size
+	}
+
+	return sel, nil
+}
+
 func readFormatTemplate(ctx *cli.Context) (*template.Template, error) {
 	if ctx.IsSet("format") {
 		source := ctx.String("format") + "\n"
@@ -1,17 +1,22 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sahib/brig/cmd/tabwriter"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
+	isatty "github.com/mattn/go-isatty"
 	"github.com/sahib/brig/client"
 	"github.com/urfave/cli"
 )
@@ -26,12 +31,87 @@ func handleReset(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if err := ctl.Reset(path, rev, force); err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("reset: %v", err)}
+		return fmt.Errorf("reset: %w", err)
 	}
 
 	return nil
 }
 
+func printUndoChanges(changes []client.UndoChange) {
+	for _, change := range changes {
+		var action string
+		switch change.Action {
+		case "restore":
+			action = color.GreenString("restore")
+		case "remove":
+			action = color.RedString("remove ")
+		default:
+			action = color.YellowString("revert ")
+		}
+
+		suffix := ""
+		if change.IsDir {
+			suffix = "/"
+		}
+
+		fmt.Printf(
+			"  %s %s%s (%s)\n",
+			action, change.Path, suffix, humanize.Bytes(change.Size),
+		)
+	}
+}
+
+func handleUndo(ctx *cli.Context, ctl *client.Client) error {
+	count := 1
+	if ctx.NArg() > 0 {
+		var err error
+		count, err = strconv.Atoi(ctx.Args().First())
+		if err != nil || count < 1 {
+			return ExitCode{BadArgs, fmt.Sprintf("not a valid number of commits: %s", ctx.Args().First())}
+		}
+	}
+
+	changes, head, err := ctl.UndoPreview(count)
+	if err != nil {
+		return fmt.Errorf("undo: %w", err)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Nothing to undo; the last commits did not change any files.")
+		return nil
+	}
+
+	fmt.Printf("Undoing the last %d commit(s) will change this:\n\n", count)
+	printUndoChanges(changes)
+	fmt.Println()
+
+	if !ctx.Bool("yes") {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return ExitCode{BadArgs, "undo: refusing to continue without a terminal; pass --yes"}
+		}
+
+		fmt.Print("Continue? [y/N]: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			fmt.Println("Nothing was changed.")
+			return nil
+		}
+	}
+
+	if _, err := ctl.Undo(count, head); err != nil {
+		return fmt.Errorf("undo: %w", err)
+	}
+
+	fmt.Println("Done. Use »brig undo« again to get back to where you were.")
+	return nil
+}
+
 func commitName(cmt *client.Commit) string {
 	if cmt == nil {
 		return ""
@@ -44,12 +124,47 @@ func commitName(cmt *client.Commit) string {
 	return cmt.Hash.ShortB58()
 }
 
+// blockDiffSummary describes how much of a modified file changed
+// compared to the version before `entry`.
+func blockDiffSummary(ctl *client.Client, entry *client.Change) string {
+	isModified := false
+	for _, detail := range entry.Mask {
+		if detail == "modified" {
+			isModified = true
+		}
+	}
+
+	if !isModified || entry.Next == nil {
+		return ""
+	}
+
+	oldPath := entry.Path
+	if entry.WasPreviouslyAt != "" {
+		oldPath = entry.WasPreviouslyAt
+	}
+
+	diff, err := ctl.BlockDiff(
+		entry.Next.Hash.B58String(), oldPath,
+		entry.Head.Hash.B58String(), entry.Path,
+	)
+
+	if err != nil {
+		return color.RedString("?")
+	}
+
+	return fmt.Sprintf(
+		"%s (%.0f%% unchanged)",
+		humanize.Bytes(diff.ChangedBytes),
+		100*diff.UnchangedRatio(),
+	)
+}
+
 func handleHistory(ctx *cli.Context, ctl *client.Client) error {
 	path := ctx.Args().First()
 
 	history, err := ctl.History(path)
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("history: %v", err)}
+		return fmt.Errorf("history: %w", err)
 	}
 
 	if _, err := ctl.Stat(path); err != nil {
@@ -80,12 +195,18 @@ func handleHistory(ctx *cli.Context, ctl *client.Client) error {
 		}
 	}
 
+	showBlocks := ctx.Bool("blocks")
 	if len(history) != 0 {
+		header := "CHANGE\tFROM\tTO\t\tWHEN\tPIN\t"
 		if containsMoves {
-			fmt.Fprintf(tabW, "CHANGE\tFROM\tTO\tHOW\tWHEN\tPIN\t\n")
-		} else {
-			fmt.Fprintf(tabW, "CHANGE\tFROM\tTO\t\tWHEN\tPIN\t\n")
+			header = "CHANGE\tFROM\tTO\tHOW\tWHEN\tPIN\t"
 		}
+
+		if showBlocks {
+			header += "CHANGED\t"
+		}
+
+		fmt.Fprintln(tabW, header)
 	}
 
 	for _, entry := range history {
@@ -122,7 +243,7 @@ func handleHistory(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\t",
 			changeDesc,
 			color.CyanString(commitName(entry.Next)),
 			color.GreenString(commitName(entry.Head)),
@@ -130,9 +251,40 @@ func handleHistory(ctx *cli.Context, ctl *client.Client) error {
 			when,
 			pinStateToSymbol(entry.IsPinned, entry.IsExplicit),
 		)
+
+		if showBlocks {
+			fmt.Fprintf(tabW, "%s\t", blockDiffSummary(ctl, entry))
+		}
+
+		fmt.Fprintln(tabW)
 	}
 
-	return tabW.Flush()
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if containsMoves {
+		trail := historyPathTrail(history)
+		fmt.Printf("\nPath trail: %s\n", strings.Join(trail, " → "))
+	}
+
+	return nil
+}
+
+// historyPathTrail returns all paths the node had, oldest first.
+// `history` is sorted newest first, like returned by ctl.History().
+func historyPathTrail(history []*client.Change) []string {
+	trail := []string{}
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		entry := history[idx]
+		for _, nodePath := range []string{entry.WasPreviouslyAt, entry.Path, entry.MovedTo} {
+			if nodePath != "" && (len(trail) == 0 || trail[len(trail)-1] != nodePath) {
+				trail = append(trail, nodePath)
+			}
+		}
+	}
+
+	return trail
 }
 
 // makePathAbbrev tries to abbreviate the `dst` path if
@@ -426,7 +578,7 @@ func handleDiff(ctx *cli.Context, ctl *client.Client) error {
 	needFetch := !ctx.Bool("offline")
 	diff, err := ctl.MakeDiff(localName, remoteName, localRev, remoteRev, needFetch)
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("diff: %v", err)}
+		return fmt.Errorf("diff: %w", err)
 	}
 
 	printMissing := ctx.Bool("missing")
@@ -476,6 +628,10 @@ func handleSyncSingle(ctx *cli.Context, ctl *client.Client, remoteName string) e
 		needFetch = false
 	}
 
+	if ctx.Bool("dry-run") {
+		return handleSyncPreview(ctl, remoteName, needFetch)
+	}
+
 	if ctx.Bool("quiet") {
 		return nil
 	}
@@ -494,6 +650,101 @@ func handleSyncSingle(ctx *cli.Context, ctl *client.Client, remoteName string) e
 	return nil
 }
 
+// parseBundleRange splits "<from>..<to>" into its parts.
+// Without "..", the range ends with HEAD.
+func parseBundleRange(spec string) (string, string, error) {
+	if !strings.Contains(spec, "..") {
+		return spec, "HEAD", nil
+	}
+
+	split := strings.SplitN(spec, "..", 2)
+	if split[0] == "" {
+		return "", "", fmt.Errorf("bundle range needs a start: %s", spec)
+	}
+
+	if split[1] == "" {
+		split[1] = "HEAD"
+	}
+
+	return split[0], split[1], nil
+}
+
+func handleBundleCreate(ctx *cli.Context, ctl *client.Client) error {
+	fromRev, toRev, err := parseBundleRange(ctx.Args().First())
+	if err != nil {
+		return ExitCode{BadArgs, err.Error()}
+	}
+
+	outPath := ctx.String("output")
+	if outPath == "" && isatty.IsTerminal(os.Stdout.Fd()) {
+		return ExitCode{BadArgs, "refusing to write a bundle to the terminal; use --output"}
+	}
+
+	data, err := ctl.BundleCreate(fromRev, toRev)
+	if err != nil {
+		return fmt.Errorf("bundle: %w", err)
+	}
+
+	if outPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := ioutil.WriteFile(outPath, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote bundle with %s to %s.\n", humanize.Bytes(uint64(len(data))), outPath)
+	return nil
+}
+
+func handleBundleApply(ctx *cli.Context, ctl *client.Client) error {
+	inPath := ctx.Args().First()
+
+	var data []byte
+	var err error
+
+	if inPath == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(inPath) // #nosec
+	}
+
+	if err != nil {
+		return err
+	}
+
+	owner, diff, err := ctl.BundleApply(data)
+	if err != nil {
+		return fmt.Errorf("bundle: %w", err)
+	}
+
+	if isEmptyDiff(diff) {
+		fmt.Printf("Applied bundle of %s, nothing changed.\n", owner)
+		return nil
+	}
+
+	fmt.Printf("Applied bundle of %s:\n\n", owner)
+	printDiff(diff, false)
+	return nil
+}
+
+func handleSyncPreview(ctl *client.Client, remoteName string, needFetch bool) error {
+	diff, err := ctl.SyncPreview(remoteName, needFetch)
+	if err != nil {
+		return err
+	}
+
+	if isEmptyDiff(diff) {
+		fmt.Println("Nothing would change.")
+		return nil
+	}
+
+	printDiff(diff, false)
+	fmt.Printf("\nWould transfer %s.\n", humanize.Bytes(diff.TransferSize))
+	return nil
+}
+
 func handleStatus(ctx *cli.Context, ctl *client.Client) error {
 	self, err := ctl.Whoami()
 	if err != nil {
@@ -565,8 +816,8 @@ func handleCommit(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	// Send the commit:
-	if err := ctl.MakeCommit(msg); err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("commit: %v", err)}
+	if err := ctl.MakeCommit(msg, ctx.StringSlice("label")); err != nil {
+		return fmt.Errorf("commit: %w", err)
 	}
 
 	return nil
@@ -577,10 +828,7 @@ func handleTag(ctx *cli.Context, ctl *client.Client) error {
 		name := ctx.Args().Get(0)
 
 		if err := ctl.Untag(name); err != nil {
-			return ExitCode{
-				UnknownError,
-				fmt.Sprintf("untag: %v", err),
-			}
+			return fmt.Errorf("untag: %w", err)
 		}
 	} else {
 		if len(ctx.Args()) < 2 {
@@ -591,20 +839,62 @@ func handleTag(ctx *cli.Context, ctl *client.Client) error {
 		name := ctx.Args().Get(1)
 
 		if err := ctl.Tag(rev, name); err != nil {
-			return ExitCode{
-				UnknownError,
-				fmt.Sprintf("tag: %v", err),
-			}
+			return fmt.Errorf("tag: %w", err)
 		}
 	}
 
 	return nil
 }
 
+func handleSnapshots(ctx *cli.Context, ctl *client.Client) error {
+	snapshots, err := ctl.Snapshots()
+	if err != nil {
+		return fmt.Errorf("snapshots: %w", err)
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		for _, snapshot := range snapshots {
+			if err := tmpl.Execute(os.Stdout, snapshot); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots yet.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "TIER\tTAG\tCOMMIT\t")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t\n",
+			color.CyanString(snapshot.Tier),
+			snapshot.Tag,
+			color.RedString(snapshot.Commit),
+		)
+	}
+
+	return tabW.Flush()
+}
+
 func handleLog(ctx *cli.Context, ctl *client.Client) error {
-	entries, err := ctl.Log()
+	entries, err := ctl.Log(ctx.String("label"))
 	if err != nil {
-		return ExitCode{UnknownError, fmt.Sprintf("commit: %v", err)}
+		return fmt.Errorf("commit: %w", err)
 	}
 
 	tmpl, err := readFormatTemplate(ctx)
@@ -646,12 +936,18 @@ func handleLog(ctx *cli.Context, ctl *client.Client) error {
 			commitHash = "      -     "
 		}
 
+		labels := ""
+		if len(entry.Labels) > 0 {
+			labels = fmt.Sprintf(" [%s]", strings.Join(entry.Labels, ", "))
+		}
+
 		fmt.Printf(
-			"%s %s %s%s\n",
+			"%s %s %s%s%s\n",
 			color.GreenString(commitHash),
 			color.YellowString(entry.Date.Format(time.UnixDate)),
 			msg,
 			color.CyanString(tags),
+			color.MagentaString(labels),
 		)
 	}
 
@@ -0,0 +1,65 @@
+package defaults
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sahib/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultsAreValid(t *testing.T) {
+	cfg, err := config.Open(nil, Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	for _, key := range cfg.Keys() {
+		entry := cfg.GetDefault(key)
+		require.NotEmpty(t, entry.Docs, "no docs for %s", key)
+
+		if entry.Validator != nil {
+			require.Nil(t, entry.Validator(cfg.Get(key)), "default of %s is invalid", key)
+		}
+	}
+}
+
+func TestDefaultsRejectInvalid(t *testing.T) {
+	cfg, err := config.Open(nil, Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	require.NotNil(t, cfg.SetInt("daemon.port", 0))
+	require.NotNil(t, cfg.SetInt("daemon.port", 65536))
+	require.Nil(t, cfg.SetInt("daemon.port", 6667))
+
+	require.NotNil(t, cfg.SetString("fs.repin.quota", "a lot"))
+	require.Nil(t, cfg.SetString("fs.repin.quota", "10G"))
+
+	require.NotNil(t, cfg.SetString("events.recv_interval", "soon"))
+	require.NotNil(t, cfg.SetFloat("events.send_max_events_per_second", -1))
+	require.NotNil(t, cfg.SetString("fs.sync.conflict_strategy", "panic"))
+
+	require.NotNil(t, cfg.SetStrings("cli.aliases", []string{"st"}))
+	require.NotNil(t, cfg.SetStrings("cli.aliases", []string{"my st=status"}))
+	require.NotNil(t, cfg.SetStrings("cli.default_flags", []string{"ls="}))
+	require.Nil(t, cfg.SetStrings("cli.aliases", []string{"st=status --short"}))
+}
+
+func TestTypeName(t *testing.T) {
+	cfg, err := config.Open(nil, Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	require.Equal(t, "int", TypeName(cfg.Get("daemon.port")))
+	require.Equal(t, "bool", TypeName(cfg.Get("daemon.enable_pprof")))
+	require.Equal(t, "float", TypeName(cfg.Get("events.recv_max_events_per_second")))
+	require.Equal(t, "string", TypeName(cfg.Get("fs.repin.quota")))
+}
+
+func TestDefaultsRoundtrip(t *testing.T) {
+	cfg, err := config.Open(nil, Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, cfg.Save(config.NewYamlEncoder(buf)))
+
+	_, err = config.Open(config.NewYamlDecoder(buf), Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+}
@@ -1,6 +1,8 @@
 package defaults
 
 import (
+	"math"
+
 	"github.com/sahib/config"
 )
 
@@ -11,7 +13,7 @@ var DefaultsV0 = config.DefaultMapping{
 			Default:      6666,
 			NeedsRestart: true,
 			Docs:         "Port of the daemon process.",
-			Validator:    config.IntRangeValidator(1, 655356),
+			Validator:    portValidator(),
 		},
 		"ipfs_path": config.DefaultEntry{
 			Default:      "",
@@ -23,6 +25,99 @@ var DefaultsV0 = config.DefaultMapping{
 			NeedsRestart: true,
 			Docs:         "Enable a ppropf profile server on startup (see »brig d p --help«)",
 		},
+		"enable_profiling": config.DefaultEntry{
+			Default:      false,
+			NeedsRestart: false,
+			Docs:         "Allow capturing cpu/heap profiles and execution traces over the control API (see »brig debug profile --help«)",
+		},
+		"unix_socket": config.DefaultEntry{
+			Default:      "",
+			NeedsRestart: true,
+			Docs: `Also listen on this unix socket (relative to the repository if not absolute).
+Only the user running the daemon may connect to it.`,
+		},
+		"restart_drain_timeout": config.DefaultEntry{
+			Default:      "1m",
+			NeedsRestart: false,
+			Docs: `How long an old daemon waits for running requests after a graceful restart.
+
+Sending SIGUSR2 to the daemon starts a new one that takes over its sockets.`,
+			Validator: config.DurationValidator(),
+		},
+		"notifications": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Wether to show desktop notifications for things that happen in the background.",
+			},
+			"notifier": config.DefaultEntry{
+				Default:      "auto",
+				NeedsRestart: true,
+				Docs: `How to show notifications. »auto« uses D-Bus on Linux and logs elsewhere,
+»command« runs »daemon.notifications.command« and »log« only writes to the log.`,
+				Validator: config.EnumValidator("auto", "dbus", "command", "log"),
+			},
+			"command": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Shell command used by the »command« notifier. Gets $BRIG_NOTIFY_TITLE and $BRIG_NOTIFY_BODY.",
+			},
+			"on_sync": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Notify when a sync with a remote finished.",
+			},
+			"on_conflict": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Notify when a sync created a conflict file.",
+			},
+			"on_pairing": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Notify when an unknown peer tried to connect, so you can add them as remote.",
+			},
+		},
+		"tls": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs: `Also listen on a tcp port secured by TLS for remote administration.
+Needs »daemon.tls.secret« or »daemon.tls.client_ca_file« to be set.
+Note that »brig cat« and »brig tar« do not work over this endpoint.`,
+			},
+			"host": config.DefaultEntry{
+				Default:      "0.0.0.0",
+				NeedsRestart: true,
+				Docs:         "Host to bind the TLS endpoint to.",
+			},
+			"port": config.DefaultEntry{
+				Default:      6667,
+				NeedsRestart: true,
+				Docs:         "Port of the TLS endpoint.",
+				Validator:    portValidator(),
+			},
+			"cert_file": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Path to the certificate of the TLS endpoint.",
+			},
+			"key_file": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Path to the private key of the TLS endpoint.",
+			},
+			"client_ca_file": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "If set, clients need a certificate signed by this CA.",
+			},
+			"secret": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "If set, clients need to send this secret before any other request.",
+			},
+		},
 	},
 	"events": config.DefaultMapping{
 		"enabled": config.DefaultEntry{
@@ -34,21 +129,160 @@ var DefaultsV0 = config.DefaultMapping{
 			Default:      "100ms",
 			NeedsRestart: false,
 			Docs:         "Time window in which events are buffered before handling them.",
+			Validator:    config.DurationValidator(),
 		},
 		"recv_max_events_per_second": config.DefaultEntry{
 			Default:      0.5,
 			NeedsRestart: false,
 			Docs:         "How many incoming events per second to process at max.",
+			Validator:    positiveFloatValidator(),
 		},
 		"send_interval": config.DefaultEntry{
 			Default:      "200ms",
 			NeedsRestart: false,
 			Docs:         "Time window in which events are buffered before sending them.",
+			Validator:    config.DurationValidator(),
 		},
 		"send_max_events_per_second": config.DefaultEntry{
 			Default:      5.0,
 			NeedsRestart: false,
 			Docs:         "How many outgoing events per second to send out at max",
+			Validator:    positiveFloatValidator(),
+		},
+		"bus_history": config.DefaultEntry{
+			Default:      1024,
+			NeedsRestart: true,
+			Docs:         "How many local events (commits, syncs, ...) are kept for consumers like `brig watch`.",
+			Validator:    positiveIntValidator(),
+		},
+	},
+	"net": config.DefaultMapping{
+		"discovery": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: true,
+				Docs:         "Wether to announce this daemon via mDNS, so it can be found with `brig remote discover`.",
+			},
+		},
+		"head_advertisement": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Publish our latest commit under our backend key (IPNS), so remotes see new commits while we are offline.",
+			},
+			"interval": config.DefaultEntry{
+				Default:      "1h",
+				NeedsRestart: false,
+				Docs:         "How often the head is published again, even if nothing changed. Records expire after a week.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"gossip": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Tell remotes about new commits of other remotes we share with them.
+This way two devices learn about each other via a third one that is always online.`,
+			},
+			"max_ttl": config.DefaultEntry{
+				Default:      3,
+				NeedsRestart: false,
+				Docs:         "How often a commit announcement may be passed on, at most.",
+				Validator:    config.IntRangeValidator(1, 16),
+			},
+			"interval": config.DefaultEntry{
+				Default:      "10m",
+				NeedsRestart: false,
+				Docs:         "How often we exchange announcements with online remotes, besides after commits.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"identity_migration": config.DefaultMapping{
+			"accept": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Rename a remote when it tells us it renamed its owner (»brig whoami --rename«).
+The rename has to be signed with the key we know the remote by.`,
+			},
+		},
+		"delegation": config.DefaultMapping{
+			"accept": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Let peers we do not know read a folder if they show a certificate (»brig remote delegate«)
+issued by a remote that may read this folder itself. Such peers can only fetch, never push.`,
+			},
+		},
+		"offline_queue": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Queue syncs, fetches and pushes for remotes that can not be reached.
+They are done once the remote is seen online again. See »brig remote list«.`,
+			},
+			"max_backoff": config.DefaultEntry{
+				Default:      "1h",
+				NeedsRestart: true,
+				Docs:         "Maximum time between two retries of a queued operation that failed.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"sync_stats": config.DefaultMapping{
+			"max_records": config.DefaultEntry{
+				Default:      1000,
+				NeedsRestart: true,
+				Docs:         "How many past syncs to remember per remote for »brig remote stats«.",
+				Validator:    positiveIntValidator(),
+			},
+		},
+		"replication": config.DefaultMapping{
+			"groups": config.DefaultEntry{
+				Default:      []string{},
+				NeedsRestart: false,
+				Docs: `Groups of remotes that store files for each other, like »backup=bob charlie«.
+Only remotes in one of our groups may ask us to pin files.`,
+				Validator: expansionsValidator(),
+			},
+			"targets": config.DefaultEntry{
+				Default:      []string{},
+				NeedsRestart: false,
+				Docs: `How many members of a group should store each file below a folder,
+like »/photos=2@backup«. Our own copy does not count. See »brig repl status«.`,
+				Validator: replTargetsValidator(),
+			},
+			"interval": config.DefaultEntry{
+				Default:      "1h",
+				NeedsRestart: false,
+				Docs:         "How often to ask members of a group to pin files that are stored on too few of them.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"schedule": config.DefaultMapping{
+			"windows": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: false,
+				Docs: `When file content may be fetched from remotes (pre-caching, repairs).
+Windows are separated by »;«, like »mon-fri 22:00-07:00; sat,sun 00:00-24:00«.
+Empty means always. Metadata syncs and reading files are never restricted.`,
+				Validator: transferWindowsValidator(),
+			},
+			"unmetered_only": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs:         "Only fetch file content from remotes while the network is not metered.",
+			},
+			"metered_detector": config.DefaultEntry{
+				Default:      "networkmanager",
+				NeedsRestart: false,
+				Docs: `How to find out if the network is metered. »networkmanager« asks NetworkManager
+over D-Bus, »command« runs »net.schedule.metered_command« and »none« assumes it never is.`,
+				Validator: config.EnumValidator("networkmanager", "command", "none"),
+			},
+			"metered_command": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: false,
+				Docs:         "Shell command used by the »command« detector. Should exit with 0 if the network is metered and 1 if not.",
+			},
 		},
 	},
 	"gateway": config.DefaultMapping{
@@ -61,6 +295,13 @@ var DefaultsV0 = config.DefaultMapping{
 			Default:      6001,
 			NeedsRestart: false,
 			Docs:         "On what port the gateway runs on.",
+			Validator:    portValidator(),
+		},
+		"shutdown_timeout": config.DefaultEntry{
+			Default:      "10s",
+			NeedsRestart: false,
+			Docs:         "How long to wait for running requests (e.g. downloads) when stopping or restarting.",
+			Validator:    config.DurationValidator(),
 		},
 		"ui": config.DefaultMapping{
 			"enabled": config.DefaultEntry{
@@ -73,6 +314,11 @@ var DefaultsV0 = config.DefaultMapping{
 				NeedsRestart: false,
 				Docs:         "Enable debug mode (load resources from filesystem).",
 			},
+			"http2_push": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Push the static files along with the UI when using HTTP/2 (needs TLS).",
+			},
 		},
 		"cert": config.DefaultMapping{
 			"certfile": config.DefaultEntry{
@@ -103,6 +349,7 @@ var DefaultsV0 = config.DefaultMapping{
 					Default:      6002,
 					NeedsRestart: false,
 					Docs:         "What port the http redirect server should run on.",
+					Validator:    portValidator(),
 				},
 			},
 		},
@@ -132,9 +379,294 @@ var DefaultsV0 = config.DefaultMapping{
 				NeedsRestart: true,
 				Docs:         "Key used for CSRF protection. Generated if empty.",
 			},
+			"url-signing-key": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Key used to sign temporary download urls. Generated if empty.",
+			},
+			"feed-key": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Key used to sign feed urls. Generated if empty. Clearing it invalidates all feed urls.",
+			},
+			"hash_iterations": config.DefaultEntry{
+				Default:      3,
+				NeedsRestart: true,
+				Docs: `Number of argon2id passes when hashing gateway passwords.
+Existing users are re-hashed on their next login when this is raised.`,
+				Validator: config.IntRangeValidator(1, math.MaxUint32),
+			},
+			"hash_memory": config.DefaultEntry{
+				Default:      "64MB",
+				NeedsRestart: true,
+				Docs: `Memory used by argon2id when hashing gateway passwords.
+More memory makes brute-forcing harder, but every login needs that much.`,
+				Validator: sizeValidator(),
+			},
+			"hash_threads": config.DefaultEntry{
+				Default:      4,
+				NeedsRestart: true,
+				Docs:         "Number of threads used by argon2id when hashing gateway passwords.",
+				Validator:    config.IntRangeValidator(1, math.MaxUint8),
+			},
+		},
+		"link_previews": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Serve deep links under /link with metadata for previews in chat apps.
+Name, size and thumbnail are only shown for files the anonymous user may download.`,
+			},
+		},
+		"signed_urls": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Allow users to create temporary download urls over /api/v0/sign.
+Those urls work without login, so other services can fetch files for a user.`,
+			},
+			"max_expiry": config.DefaultEntry{
+				Default:      "24h",
+				NeedsRestart: false,
+				Docs:         "How long a signed download url may be valid at most.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"feeds": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Serve Atom and RSS feeds of new and modified files per folder under /feed.
+Their urls are made over /api/v0/feed/url and contain a token instead of a login.`,
+			},
+			"max_items": config.DefaultEntry{
+				Default:      50,
+				NeedsRestart: false,
+				Docs:         "How many changes a feed shows at most.",
+				Validator:    config.IntRangeValidator(1, 1000),
+			},
+		},
+		"maintenance": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Make the gateway read-only, e.g. during migrations or pruning runs.
+Uploads and other changes to files and remotes are refused with 503 then.`,
+			},
+			"message": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: false,
+				Docs: `An announcement that is shown in the UI and sent as X-Brig-Announcement
+header with every response. Leave empty to show nothing.`,
+			},
+		},
+		"site": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs:         "Serve site.folder read-only and without login under /site.",
+			},
+			"folder": config.DefaultEntry{
+				Default:      "/public",
+				NeedsRestart: false,
+				Docs:         "The folder in the repository that is served under /site.",
+			},
+			"index_html": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Serve the index.html of a directory instead of the directory itself.",
+			},
+			"listing": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Show a list of files for directories without index.html.",
+			},
+		},
+		"upload": config.DefaultMapping{
+			"max_size": config.DefaultEntry{
+				Default:      "0",
+				NeedsRestart: false,
+				Docs: `Maximum size of a single uploaded file; 0 means no limit.
+This applies to all folders without an upload policy of their own.`,
+				Validator: sizeValidator(),
+			},
+			"allowed_types": config.DefaultEntry{
+				Default:      []string{},
+				NeedsRestart: false,
+				Docs: `Extensions (".pdf") or mime types ("image/*") that may be uploaded.
+If empty, all types that are not blocked are allowed.`,
+			},
+			"blocked_types": config.DefaultEntry{
+				Default:      []string{},
+				NeedsRestart: false,
+				Docs:         "Extensions or mime types that may never be uploaded.",
+			},
+			"extract_max_size": config.DefaultEntry{
+				Default:      "1G",
+				NeedsRestart: false,
+				Docs: `Maximum size of all files in a ZIP archive that is extracted on upload.
+The size of each file is checked against the upload policies as usual.`,
+				Validator: sizeValidator(),
+			},
+			"extract_max_files": config.DefaultEntry{
+				Default:      10000,
+				NeedsRestart: false,
+				Docs:         "Maximum number of files and directories in a ZIP archive that is extracted on upload.",
+				Validator:    positiveIntValidator(),
+			},
+		},
+		"drop": config.DefaultMapping{
+			"max_request_size": config.DefaultEntry{
+				Default:      "1G",
+				NeedsRestart: false,
+				Docs: `Maximum size of a single upload over a drop link (all files together).
+This also limits links that have no size limit of their own.`,
+				Validator: sizeValidator(),
+			},
+		},
+		"scan": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Scan uploaded files (including drop links) for viruses before staging them.
+Infected uploads are rejected as a whole. Every result is logged.`,
+			},
+			"url": config.DefaultEntry{
+				Default:      "tcp://localhost:3310",
+				NeedsRestart: false,
+				Docs: `Where the scanner runs. Use tcp://host:port or unix:///path/to/socket
+for clamd and icap://host:port/service for an ICAP server.`,
+			},
+			"timeout": config.DefaultEntry{
+				Default:      "1m",
+				NeedsRestart: false,
+				Docs:         "How long scanning a single file may take.",
+				Validator:    config.DurationValidator(),
+			},
+			"on_error": config.DefaultEntry{
+				Default:      "reject",
+				NeedsRestart: false,
+				Docs: `What to do with an upload if the scanner can not be reached or fails.
+"reject" refuses the upload, "accept" stages it without a scan.`,
+				Validator: config.EnumValidator("reject", "accept"),
+			},
+			"quarantine_dir": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: false,
+				Docs: `If set, infected uploads are stored in this local directory (outside of brig)
+for later inspection. They are never staged.`,
+			},
 		},
 	},
 	"fs": config.DefaultMapping{
+		"metadata_backend": config.DefaultEntry{
+			Default:      "badger",
+			NeedsRestart: true,
+			Docs: `How the metadata is stored on disk. »badger« is fast and the default,
+»disk« stores every key as a single file (slow, but easy to inspect).
+Use »brig migrate-metadata« to switch an existing repository; the daemon
+refuses to start if only this key was changed.`,
+			Validator: config.EnumValidator("badger", "disk"),
+		},
+		"dir_shard_threshold": config.DefaultEntry{
+			Default:      10000,
+			NeedsRestart: true,
+			Docs: `Directories with more entries are stored in several shards,
+so changing a single entry does not rewrite the whole directory. 0 disables it.`,
+			Validator: positiveIntValidator(),
+		},
+		"node_cache_size": config.DefaultEntry{
+			Default:      100000,
+			NeedsRestart: true,
+			Docs: `How many unchanged nodes (files and directories) are kept in memory.
+Other nodes are loaded from the metadata store when needed. 0 keeps all of them.`,
+			Validator: positiveIntValidator(),
+		},
+		"hash_algo": config.DefaultEntry{
+			Default:      "blake2s-256",
+			NeedsRestart: false,
+			Docs: `Hash algorithm used for the content of newly staged files.
+blake3 is a lot faster on large files. Existing files keep their algorithm
+when modified, so old and new hashes can be mixed in one repository.
+Peers need a brig version that knows the algorithm to sync such files.`,
+			Validator: config.EnumValidator("blake2s-256", "blake3"),
+		},
+		"convergent_encryption": config.DefaultEntry{
+			Default:      false,
+			NeedsRestart: false,
+			Docs: `Derive the key of a file only from its content (convergent encryption).
+Identical files then result in identical encrypted blocks, so peers that
+enable this deduplicate their storage in IPFS. Compression dictionaries
+are not used for new files in this mode.
+
+By default (false) a random secret is mixed into the key of every new file.
+It is created when the first file is staged and is stored in the metadata
+of the repository, so the keys of two repositories differ even for the same
+content. Older brig versions did not do this; their files keep their keys.
+
+WARNING: Anyone who has (or guesses) a file can check if you store it
+by encrypting it themselves and looking for the blocks. This is known as
+confirmation-of-file attack and can also be used to guess small secrets
+in otherwise known files. Only enable this if you trust everyone that
+can see your blocks. It only affects files staged from now on.`,
+		},
+		"device_name": config.DefaultEntry{
+			Default:      "",
+			NeedsRestart: true,
+			Docs: `Name of this device, stored in every commit made here.
+If empty, the hostname is used.`,
+		},
+		"fuse": config.DefaultMapping{
+			"writeback_delay": config.DefaultEntry{
+				Default:      "1s",
+				NeedsRestart: false,
+				Docs: `How long written files are held back after they were closed or flushed.
+Many small writes are coalesced into one staged version this way.
+Set to 0s to stage on every flush. Only applies to new mounts.`,
+				Validator: config.DurationValidator(),
+			},
+			"cache_ttl": config.DefaultEntry{
+				Default:      "2s",
+				NeedsRestart: false,
+				Docs: `How long the metadata of files and directories is cached in a mount.
+The cache is dropped on every change and after syncing, so this mostly
+bounds how long changes done by other means take to show up.
+Set to 0s to disable caching. Only applies to new mounts.`,
+				Validator: config.DurationValidator(),
+			},
+			"read_cache_size": config.DefaultEntry{
+				Default:      "4MB",
+				NeedsRestart: false,
+				Docs: `How much of the recently read content every open file keeps in memory.
+This avoids seeking the decryption stream over and over, which makes media
+players and databases work well on a mount. Files opened with O_DIRECT
+never use it. Set to 0 to disable. Only applies to new mounts.`,
+				Validator: sizeValidator(),
+			},
+		},
+		"paths": config.DefaultMapping{
+			"unicode_normalization": config.DefaultEntry{
+				Default:      "nfc",
+				NeedsRestart: false,
+				Docs: `How names are normalized before they are stored or looked up.
+macOS stores umlauts and other accented characters decomposed (NFD), most
+other systems composed (NFC). Without normalization the same name can
+exist twice after syncing between those systems.
+
+  * nfc: Convert all paths to NFC.
+  * none: Use paths exactly as given.
+`,
+				Validator: config.EnumValidator("nfc", "none"),
+			},
+			"case_insensitive": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Treat names that only differ in case as the same name.
+Enable this on all machines when syncing with case-insensitive filesystems
+(the default on macOS and Windows), so »Notes.txt« and »notes.txt« do not end
+up as two different files.`,
+			},
+		},
 		"sync": config.DefaultMapping{
 			"ignore_removed": config.DefaultEntry{
 				Default:      false,
@@ -159,6 +691,32 @@ var DefaultsV0 = config.DefaultMapping{
   * embrace: Take the remote version and replace ours with it.
 `,
 			},
+			"conflict_hook": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: false,
+				Docs: `Shell command to run for every conflict of a sync.
+
+The conflict is passed as json on stdin. The remote, path and
+strategy are also set in BRIG_CONFLICT_REMOTE, BRIG_CONFLICT_PATH
+and BRIG_CONFLICT_STRATEGY. An empty string disables the hook.
+`,
+			},
+			"conflict_hook_resolves": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Let the conflict hook decide how to resolve a conflict.
+
+The hook is then called during the sync and its first line of output
+(marker, ignore or embrace) overrides fs.sync.conflict_strategy.
+Empty or unknown output keeps the configured strategy.
+`,
+			},
+			"conflict_hook_timeout": config.DefaultEntry{
+				Default:      "10s",
+				NeedsRestart: false,
+				Docs:         "How long the conflict hook may run before it is killed.",
+				Validator:    config.DurationValidator(),
+			},
 		},
 		"compress": config.DefaultMapping{
 			"default_algo": config.DefaultEntry{
@@ -166,9 +724,24 @@ var DefaultsV0 = config.DefaultMapping{
 				NeedsRestart: false,
 				Docs:         "What compression algorithm to use by default.",
 				Validator: config.EnumValidator(
-					"snappy", "lz4", "none",
+					"snappy", "lz4", "zstd", "none",
 				),
 			},
+			"dictionaries": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Compress small files with a dictionary trained on similar files.
+
+This helps a lot with many small files of the same type, like source code
+or logs. The dictionaries are created by »brig compress train«; files
+without a dictionary for their type are compressed as usual.`,
+			},
+			"dictionary_size": config.DefaultEntry{
+				Default:      16 * 1024,
+				NeedsRestart: false,
+				Docs:         "Size in bytes of the dictionaries created by »brig compress train«.",
+				Validator:    config.IntRangeValidator(1024, 128*1024),
+			},
 		},
 		"pre_cache": config.DefaultMapping{
 			"enabled": config.DefaultEntry{
@@ -176,6 +749,33 @@ var DefaultsV0 = config.DefaultMapping{
 				NeedsRestart: false,
 				Docs:         "pre-cache files up-on pinning.",
 			},
+			"parallel_fetch": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "When pre-caching, fetch blocks from all remotes that have the file at once.",
+			},
+			"in_flight": config.DefaultEntry{
+				Default:      4,
+				NeedsRestart: false,
+				Docs:         "How many blocks to request from a single remote at once when fetching in parallel.",
+				Validator:    positiveIntValidator(),
+			},
+		},
+		"block_cache": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: true,
+				Docs: `Keep blocks of unpinned files that were read from remotes on disk.
+
+Without it, files that are not pinned are fetched over the network every
+time they are read. The blocks are stored encrypted, like in the backend.`,
+			},
+			"max_size": config.DefaultEntry{
+				Default:      "512MB",
+				NeedsRestart: true,
+				Docs:         "How much disk space the block cache may use. The least recently used blocks are removed first.",
+				Validator:    sizeValidator(),
+			},
 		},
 		"repin": config.DefaultMapping{
 			"enabled": config.DefaultEntry{
@@ -197,16 +797,51 @@ var DefaultsV0 = config.DefaultMapping{
   If the quota limit is hit, old versions of a file are unpinned first on the
   next repin. Biggest file first.
 `,
+				Validator: sizeValidator(),
 			},
 			"min_depth": config.DefaultEntry{
 				Default:      1,
 				NeedsRestart: false,
 				Docs:         `Keep at least »n« versions of a pinned file, even if this would exceed the quota.`,
+				Validator:    positiveIntValidator(),
 			},
 			"max_depth": config.DefaultEntry{
 				Default:      10,
 				NeedsRestart: false,
 				Docs:         `Keep at max »n« versions of a pinned file and remove it even if it does not exceed quota.`,
+				Validator:    positiveIntValidator(),
+			},
+		},
+		"scrub": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Re-read pinned content in the background and check the MACs of its blocks.
+Corrupt content is reported (see »brig daemon status«) and fetched again
+from remotes that have it, if possible.`,
+			},
+			"interval": config.DefaultEntry{
+				Default:      "168h",
+				NeedsRestart: false,
+				Docs:         "How much time has to pass between two scrub runs.",
+				Validator:    config.DurationValidator(),
+			},
+			"max_rate": config.DefaultEntry{
+				Default:      "4MB",
+				NeedsRestart: false,
+				Docs: `How many bytes per second the scrubber may read at most.
+This keeps the scrubber from slowing down everything else. 0 means no limit.`,
+				Validator: sizeValidator(),
+			},
+		},
+		"tombstones": config.DefaultMapping{
+			"retention": config.DefaultEntry{
+				Default:      "720h",
+				NeedsRestart: false,
+				Docs: `How long deleted files are remembered. Until then, they can be undeleted
+and syncing with a peer that still has them does not bring them back.
+Afterwards their content is unpinned. 0 keeps them forever.`,
+				Validator: config.DurationValidator(),
 			},
 		},
 		"autocommit": config.DefaultMapping{
@@ -221,6 +856,77 @@ var DefaultsV0 = config.DefaultMapping{
 				Docs:         "In what interval to make automatic commits.",
 				Validator:    config.DurationValidator(),
 			},
+			"idle": config.DefaultEntry{
+				Default:      "0s",
+				NeedsRestart: false,
+				Docs: `Also commit once the staged changes did not change for this long.
+
+This captures a batch of edits (e.g. over FUSE) soon after it is done,
+without waiting for the next interval. 0 disables it.`,
+				Validator: config.DurationValidator(),
+			},
+			"message": config.DefaultEntry{
+				Default:      "auto commit at »{time}«",
+				NeedsRestart: false,
+				Docs: `Template for the message of automatic commits.
+
+The following placeholders are replaced:
+
+  {num_files}: Number of changed files.
+  {added}, {modified}, {removed}: Number of files changed that way.
+  {hostname}: Name of the machine the daemon runs on.
+  {owner}: Name of the repository owner.
+  {time}, {date}: Time and date of the commit.
+
+Example: "auto: {num_files} files changed on {hostname}"`,
+			},
+		},
+		"snapshots": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs:         "Wether to tag HEAD regularly as snapshot (like »snapshot-daily-2019-01-31«).",
+			},
+			"keep_hourly": config.DefaultEntry{
+				Default:      24,
+				NeedsRestart: false,
+				Docs:         "How many hourly snapshots to keep (0 disables this tier).",
+				Validator:    positiveIntValidator(),
+			},
+			"keep_daily": config.DefaultEntry{
+				Default:      30,
+				NeedsRestart: false,
+				Docs:         "How many daily snapshots to keep (0 disables this tier).",
+				Validator:    positiveIntValidator(),
+			},
+			"keep_weekly": config.DefaultEntry{
+				Default:      0,
+				NeedsRestart: false,
+				Docs:         "How many weekly snapshots to keep (0 disables this tier).",
+				Validator:    positiveIntValidator(),
+			},
+			"keep_monthly": config.DefaultEntry{
+				Default:      12,
+				NeedsRestart: false,
+				Docs:         "How many monthly snapshots to keep (0 disables this tier).",
+				Validator:    positiveIntValidator(),
+			},
+		},
+	},
+	"cli": config.DefaultMapping{
+		"aliases": config.DefaultEntry{
+			Default:      []string{},
+			NeedsRestart: false,
+			Docs: `Own command names in the form »name=command args«, e.g. »st=status --tree«.
+Aliases can not replace builtin commands and are expanded by the command line client.`,
+			Validator: expansionsValidator(),
+		},
+		"default_flags": config.DefaultEntry{
+			Default:      []string{},
+			NeedsRestart: false,
+			Docs: `Flags that are always passed to a command in the form »command=flags«, e.g. »ls=--recursive«.
+Flags given on the command line come after them.`,
+			Validator: expansionsValidator(),
 		},
 	},
 	"repo": config.DefaultMapping{
@@ -234,18 +940,66 @@ var DefaultsV0 = config.DefaultMapping{
 			NeedsRestart: false,
 			Docs:         "If set, the repo password is taken from stdout of this command.",
 		},
+		"keychain": config.DefaultEntry{
+			Default:      "",
+			NeedsRestart: false,
+			Docs: `If set, the repo password is read from this OS keychain, so the daemon can start
+unattended (»secret-service« on Linux, »macos-keychain« on macOS). Use »brig keychain enable« to set it.`,
+			Validator: config.EnumValidator("", "secret-service", "macos-keychain"),
+		},
+		"read_only": config.DefaultEntry{
+			Default:      false,
+			NeedsRestart: false,
+			Docs:         "Forbid all changes to files and history, including commits and fetching from remotes.",
+		},
 		"autogc": config.DefaultMapping{
 			"enabled": config.DefaultEntry{
 				Default:      true,
 				NeedsRestart: false,
-				Docs:         "Wether to make automatic commits in a fixed interval.",
+				Docs:         "Wether to run the garbage collector in a fixed interval.",
 			},
 			"interval": config.DefaultEntry{
 				Default:      "60m",
 				NeedsRestart: false,
-				Docs:         "In what interval to make automatic commits.",
+				Docs:         "In what interval to run the garbage collector.",
+				Validator:    config.DurationValidator(),
+			},
+		},
+		"identity": config.DefaultMapping{
+			"source": config.DefaultEntry{
+				Default:      "gpg",
+				NeedsRestart: true,
+				Docs: `Where the identity key of this repository comes from.
+"gpg" uses the key that was created by "brig init", "ssh-agent" signs with an
+ed25519 key held by the ssh-agent at $SSH_AUTH_SOCK and "ssh-key" reads an
+unencrypted ed25519 key file. Changing this changes the fingerprint of the repo.`,
+				Validator: config.EnumValidator("gpg", "ssh-agent", "ssh-key"),
+			},
+			"ssh_key": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs: `Path of the ssh key to use. For "ssh-key" this is the private key file;
+for "ssh-agent" it is the public key (.pub) that selects the key in the agent.
+If empty with "ssh-agent", the first ed25519 key in the agent is used.`,
+			},
+		},
+		"subkeys": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Wether to use rotating subkeys for authentication and signing.",
+			},
+			"lifetime": config.DefaultEntry{
+				Default:      "720h",
+				NeedsRestart: false,
+				Docs:         "How long a subkey is valid. Subkeys are rotated when a quarter of this is left.",
 				Validator:    config.DurationValidator(),
 			},
+			"require_signed_commits": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs:         "Refuse to sync with remotes whose fetched commits are not signed. Bad signatures are always refused.",
+			},
 		},
 	},
 	"mounts": config.DefaultMapping{
@@ -270,6 +1024,7 @@ var DefaultsV0 = config.DefaultMapping{
 				Default:      "/",
 				NeedsRestart: true,
 				Docs:         "The virtual root of the mount.",
+				Validator:    absPathValidator(),
 			},
 		},
 	},
@@ -0,0 +1,143 @@
+package defaults
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sahib/brig/net/replication"
+	"github.com/sahib/brig/net/schedule"
+	"github.com/sahib/config"
+)
+
+func portValidator() func(val interface{}) error {
+	return config.IntRangeValidator(1, 65535)
+}
+
+func positiveIntValidator() func(val interface{}) error {
+	return config.IntRangeValidator(0, math.MaxInt64)
+}
+
+// positiveFloatValidator checks that the value is a float >= 0.
+// Floats without fraction are read back from yaml as ints, so accept those too.
+func positiveFloatValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		switch v := val.(type) {
+		case int64:
+			val = float64(v)
+		case int:
+			val = float64(v)
+		}
+
+		return config.FloatRangeValidator(0, math.MaxFloat64)(val)
+	}
+}
+
+// sizeValidator checks that the value is a size like "5GB" or "100M".
+func sizeValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("size is not a string: %v", val)
+		}
+
+		if _, err := humanize.ParseBytes(s); err != nil {
+			return fmt.Errorf("not a valid size: %s (example: 5GB)", s)
+		}
+
+		return nil
+	}
+}
+
+// absPathValidator checks that the value is an absolute path.
+func absPathValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("path is not a string: %v", val)
+		}
+
+		if !strings.HasPrefix(s, "/") {
+			return fmt.Errorf("not an absolute path: %s", s)
+		}
+
+		return nil
+	}
+}
+
+// transferWindowsValidator checks that the value is a list of
+// transfer windows like "mon-fri 22:00-07:00; sat 10:00-12:00".
+func transferWindowsValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		s, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("windows are not a string: %v", val)
+		}
+
+		_, err := schedule.ParseWindows(s)
+		return err
+	}
+}
+
+// TypeName returns a human readable name of the type of `val`.
+// `val` is expected to be a value from a config.
+func TypeName(val interface{}) string {
+	switch val.(type) {
+	case int, int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []string:
+		return "list of strings"
+	case []int64:
+		return "list of ints"
+	case []float64:
+		return "list of floats"
+	case []bool:
+		return "list of bools"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
+
+// expansionsValidator checks that the value is a list
+// of entries like "name=some words" as used by the cli section.
+func expansionsValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		entries, ok := val.([]string)
+		if !ok {
+			return fmt.Errorf("value is not a list of strings: %v", val)
+		}
+
+		for _, entry := range entries {
+			name, words, found := strings.Cut(entry, "=")
+			name = strings.TrimSpace(name)
+			if !found || name == "" || strings.ContainsAny(name, " \t") {
+				return fmt.Errorf("not of the form »name=args«: %s", entry)
+			}
+
+			if strings.TrimSpace(words) == "" {
+				return fmt.Errorf("nothing to expand for »%s«", name)
+			}
+		}
+
+		return nil
+	}
+}
+
+func replTargetsValidator() func(val interface{}) error {
+	return func(val interface{}) error {
+		entries, ok := val.([]string)
+		if !ok {
+			return fmt.Errorf("value is not a list of strings: %v", val)
+		}
+
+		_, err := replication.ParseTargets(entries)
+		return err
+	}
+}
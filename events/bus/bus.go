@@ -0,0 +1,490 @@
+// Package bus is the local event bus of brig.
+//
+// Other than the events package (which talks to other remotes),
+// events on the bus never leave the daemon. Parts of brig publish
+// things that happened (a commit was made, a sync finished, ...)
+// and other parts (gateway websockets, fuse mounts, `brig watch`)
+// subscribe to them.
+//
+// The bus keeps the last events in a ring buffer. Every event has a
+// sequence number and consumers can remember (ack) the last event they
+// handled by name. Those cursors are persisted, so a consumer that comes
+// back later (e.g. after a restart of the daemon) will be sent everything
+// it missed, as long as it is still in the buffer.
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Kind is the type of an event.
+type Kind string
+
+const (
+	// KindCommit is published after a commit was created.
+	KindCommit = Kind("commit")
+	// KindFsChange is published when the filesystem was modified.
+	KindFsChange = Kind("fs")
+	// KindSync is published when a sync with a remote finished.
+	KindSync = Kind("sync")
+	// KindRemoteSeen is published when a remote was seen online.
+	KindRemoteSeen = Kind("remote-seen")
+	// KindConflict is published for every conflict of a sync,
+	// no matter how it was resolved. See Event.Conflict for details.
+	KindConflict = Kind("conflict")
+	// KindPairing is published when an unknown peer tried to connect.
+	// The message contains its fingerprint.
+	KindPairing = Kind("pairing")
+	// KindTransfer is published to report progress of transfers.
+	// Those events are not persisted, since they are only useful live.
+	KindTransfer = Kind("transfer")
+	// KindLost is sent to a subscriber if it missed events,
+	// because they were already dropped from the buffer.
+	KindLost = Kind("lost")
+)
+
+// AllKinds is a list of all kinds that can be published.
+var AllKinds = []Kind{
+	KindCommit,
+	KindFsChange,
+	KindSync,
+	KindRemoteSeen,
+	KindConflict,
+	KindPairing,
+	KindTransfer,
+}
+
+const (
+	// DefaultHistorySize is the number of events kept in the buffer.
+	DefaultHistorySize = 1024
+
+	// subscriptionBuffer is the size of a subscription's channel.
+	subscriptionBuffer = 64
+
+	// flushInterval is how often changed cursors are written to disk.
+	flushInterval = 2 * time.Second
+)
+
+// Event is a single thing that happened.
+type Event struct {
+	// Seq is a unique and increasing number, set by Publish.
+	Seq uint64 `json:"seq"`
+	// Kind tells what happened.
+	Kind Kind `json:"kind"`
+	// Time is when it happened, set by Publish if empty.
+	Time time.Time `json:"time"`
+	// Path is the affected path, if any.
+	Path string `json:"path,omitempty"`
+	// Remote is the name of the affected remote, if any.
+	Remote string `json:"remote,omitempty"`
+	// Source tells who published the event, if it matters.
+	Source string `json:"source,omitempty"`
+	// Message is a human readable description, if any.
+	Message string `json:"message,omitempty"`
+	// Done and Total describe the progress of a transfer.
+	// Total is zero if the size is not known yet.
+	Done  int64 `json:"done,omitempty"`
+	Total int64 `json:"total,omitempty"`
+
+	// Conflict describes both sides of a conflict (only for KindConflict).
+	Conflict *Conflict `json:"conflict,omitempty"`
+}
+
+// Conflict is a node that was changed on both sides of a sync.
+type Conflict struct {
+	// Strategy is how the conflict was resolved (marker, ignore or embrace).
+	Strategy      string    `json:"strategy"`
+	OwnHash       string    `json:"own_hash"`
+	RemoteHash    string    `json:"remote_hash"`
+	OwnModTime    time.Time `json:"own_mod_time"`
+	RemoteModTime time.Time `json:"remote_mod_time"`
+}
+
+// state is what gets persisted on disk.
+type state struct {
+	Seq     uint64            `json:"seq"`
+	Cursors map[string]uint64 `json:"cursors"`
+	Events  []Event           `json:"events"`
+}
+
+// Bus is the event bus. It's safe to use from several go routines.
+type Bus struct {
+	mu sync.Mutex
+
+	path    string
+	size    int
+	seq     uint64
+	events  []Event
+	cursors map[string]uint64
+	dirty   bool
+	closed  bool
+
+	// wake is closed (and replaced) whenever a new event was published.
+	wake chan struct{}
+
+	flushTicker *time.Ticker
+	quitCh      chan struct{}
+}
+
+// New returns a new bus that keeps the last `size` events.
+// If `path` is not empty, cursors and events are loaded from there
+// and written back regularly and on Close().
+func New(path string, size int) (*Bus, error) {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	b := &Bus{
+		path:    path,
+		size:    size,
+		cursors: make(map[string]uint64),
+		wake:    make(chan struct{}),
+		quitCh:  make(chan struct{}),
+	}
+
+	if path != "" {
+		if err := b.load(); err != nil {
+			return nil, err
+		}
+
+		b.flushTicker = time.NewTicker(flushInterval)
+		go b.flushLoop()
+	}
+
+	return b, nil
+}
+
+func (b *Bus) load() error {
+	data, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	st := state{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		// Losing old events is not fatal; start over.
+		log.Warningf("event bus: failed to load state from %s: %v", b.path, err)
+		return nil
+	}
+
+	b.seq = st.Seq
+	b.events = st.Events
+	if st.Cursors != nil {
+		b.cursors = st.Cursors
+	}
+
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+
+	return nil
+}
+
+func (b *Bus) flushLoop() {
+	for {
+		select {
+		case <-b.flushTicker.C:
+			if err := b.Flush(); err != nil {
+				log.Warningf("event bus: failed to write state: %v", err)
+			}
+		case <-b.quitCh:
+			return
+		}
+	}
+}
+
+// Flush writes the current state to disk, if anything changed.
+func (b *Bus) Flush() error {
+	b.mu.Lock()
+	if b.path == "" || !b.dirty {
+		b.mu.Unlock()
+		return nil
+	}
+
+	st := state{
+		Seq:     b.seq,
+		Cursors: make(map[string]uint64),
+		Events:  []Event{},
+	}
+
+	for consumer, seq := range b.cursors {
+		st.Cursors[consumer] = seq
+	}
+
+	for _, ev := range b.events {
+		if ev.Kind != KindTransfer {
+			st.Events = append(st.Events, ev)
+		}
+	}
+
+	b.dirty = false
+	b.mu.Unlock()
+
+	data, err := json.Marshal(&st)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first, so we never leave a half written state.
+	tmpPath := b.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, b.path)
+}
+
+// Close stops the bus, ends all subscriptions and writes the state to disk.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	close(b.wake)
+	b.mu.Unlock()
+
+	if b.flushTicker != nil {
+		b.flushTicker.Stop()
+		close(b.quitCh)
+	}
+
+	return b.Flush()
+}
+
+// Publish adds `ev` to the bus and returns it with sequence number set.
+// It never blocks on slow subscribers.
+func (b *Bus) Publish(ev Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ev
+	}
+
+	b.seq++
+	ev.Seq = b.seq
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.events = append(b.events, ev)
+	if len(b.events) > b.size {
+		// Copy, so the underlying array does not grow forever:
+		b.events = append([]Event{}, b.events[len(b.events)-b.size:]...)
+	}
+
+	if ev.Kind != KindTransfer {
+		b.dirty = true
+	}
+
+	close(b.wake)
+	b.wake = make(chan struct{})
+	return ev
+}
+
+// Seq returns the sequence number of the last published event.
+func (b *Bus) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seq
+}
+
+// Cursor returns the last sequence number `consumer` acknowledged.
+// If the consumer is not known, false is returned.
+func (b *Bus) Cursor(consumer string) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seq, ok := b.cursors[consumer]
+	return seq, ok
+}
+
+// Ack remembers that `consumer` handled all events up to `seq`.
+// Cursors never go backwards.
+func (b *Bus) Ack(consumer string, seq uint64) {
+	if consumer == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq > b.seq {
+		seq = b.seq
+	}
+
+	if curr, ok := b.cursors[consumer]; ok && curr >= seq {
+		return
+	}
+
+	b.cursors[consumer] = seq
+	b.dirty = true
+}
+
+// Forget removes the cursor of `consumer`.
+func (b *Bus) Forget(consumer string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.cursors[consumer]; ok {
+		delete(b.cursors, consumer)
+		b.dirty = true
+	}
+}
+
+// since returns all events after `pos`. If some of them were already
+// dropped, a KindLost event is put in front. Must be called with mu held.
+func (b *Bus) since(pos uint64) []Event {
+	if pos >= b.seq {
+		return nil
+	}
+
+	evs := []Event{}
+	if len(b.events) == 0 || b.events[0].Seq > pos+1 {
+		lostUntil := b.seq
+		if len(b.events) > 0 {
+			lostUntil = b.events[0].Seq - 1
+		}
+
+		evs = append(evs, Event{Seq: lostUntil, Kind: KindLost, Time: time.Now()})
+	}
+
+	for _, ev := range b.events {
+		if ev.Seq > pos {
+			evs = append(evs, ev)
+		}
+	}
+
+	return evs
+}
+
+// Wait returns all events after `pos`. If there are none yet,
+// it waits until there are some or until `ctx` is done.
+func (b *Bus) Wait(ctx context.Context, pos uint64) ([]Event, error) {
+	for {
+		b.mu.Lock()
+		evs, wake, closed := b.since(pos), b.wake, b.closed
+		b.mu.Unlock()
+
+		if len(evs) > 0 {
+			return evs, nil
+		}
+
+		if closed {
+			return nil, nil
+		}
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Subscription delivers events of the bus over a channel.
+type Subscription struct {
+	b        *Bus
+	consumer string
+	kinds    map[Kind]bool
+	ch       chan Event
+	cancel   context.CancelFunc
+	doneCh   chan struct{}
+}
+
+// Subscribe returns a new subscription for events of `kinds` (all if empty).
+// If `consumer` is not empty and the bus knows its cursor, all events after
+// the cursor are delivered first. Otherwise only new events are delivered.
+// The consumer should call Ack() on events it handled.
+func (b *Bus) Subscribe(consumer string, kinds ...Kind) *Subscription {
+	b.mu.Lock()
+	pos := b.seq
+	if cursor, ok := b.cursors[consumer]; ok && consumer != "" {
+		pos = cursor
+	} else if consumer != "" {
+		// Start tracking the consumer from now on.
+		b.cursors[consumer] = pos
+		b.dirty = true
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		b:        b,
+		consumer: consumer,
+		kinds:    make(map[Kind]bool),
+		ch:       make(chan Event, subscriptionBuffer),
+		cancel:   cancel,
+		doneCh:   make(chan struct{}),
+	}
+
+	for _, kind := range kinds {
+		sub.kinds[kind] = true
+	}
+
+	go sub.loop(ctx, pos)
+	return sub
+}
+
+func (s *Subscription) loop(ctx context.Context, pos uint64) {
+	defer close(s.doneCh)
+	defer close(s.ch)
+
+	for {
+		evs, err := s.b.Wait(ctx, pos)
+		if err != nil || len(evs) == 0 {
+			// Either the subscription or the bus was closed.
+			return
+		}
+
+		for _, ev := range evs {
+			pos = ev.Seq
+			if len(s.kinds) > 0 && !s.kinds[ev.Kind] && ev.Kind != KindLost {
+				continue
+			}
+
+			select {
+			case s.ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Events returns the channel where events are delivered.
+// It is closed when the subscription or the bus is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Ack tells the bus that the consumer handled everything up to `ev`.
+func (s *Subscription) Ack(ev Event) {
+	s.b.Ack(s.consumer, ev.Seq)
+}
+
+// Close ends the subscription. The cursor of the consumer stays.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.doneCh
+}
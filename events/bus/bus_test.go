@@ -0,0 +1,99 @@
+package bus
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	select {
+	case ev, ok := <-sub.Events():
+		require.True(t, ok)
+		return ev
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	b, err := New("", 10)
+	require.Nil(t, err)
+
+	sub := b.Subscribe("", KindCommit)
+	b.Publish(Event{Kind: KindFsChange, Path: "/x"})
+	b.Publish(Event{Kind: KindCommit, Message: "hello"})
+
+	ev := nextEvent(t, sub)
+	require.Equal(t, KindCommit, ev.Kind)
+	require.Equal(t, "hello", ev.Message)
+	require.Equal(t, uint64(2), ev.Seq)
+
+	sub.Close()
+	require.Nil(t, b.Close())
+}
+
+func TestPersistentCursor(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "brig-event-bus")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "events.json")
+	b, err := New(path, 10)
+	require.Nil(t, err)
+
+	sub := b.Subscribe("watcher")
+	b.Publish(Event{Kind: KindFsChange, Path: "/a"})
+	ev := nextEvent(t, sub)
+	sub.Ack(ev)
+	sub.Close()
+
+	// Those happen while the consumer is away:
+	b.Publish(Event{Kind: KindFsChange, Path: "/b"})
+	b.Publish(Event{Kind: KindTransfer, Done: 1, Total: 2})
+	require.Nil(t, b.Close())
+
+	b, err = New(path, 10)
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), b.Seq())
+
+	cursor, ok := b.Cursor("watcher")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), cursor)
+
+	// The transfer event was not persisted, so only /b comes:
+	sub = b.Subscribe("watcher")
+	ev = nextEvent(t, sub)
+	require.Equal(t, "/b", ev.Path)
+	sub.Close()
+	require.Nil(t, b.Close())
+}
+
+func TestLostEvents(t *testing.T) {
+	b, err := New("", 2)
+	require.Nil(t, err)
+
+	for idx := 0; idx < 5; idx++ {
+		b.Publish(Event{Kind: KindFsChange})
+	}
+
+	evs, err := b.Wait(context.Background(), 0)
+	require.Nil(t, err)
+	require.Len(t, evs, 3)
+	require.Equal(t, KindLost, evs[0].Kind)
+	require.Equal(t, uint64(3), evs[0].Seq)
+	require.Equal(t, uint64(4), evs[1].Seq)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = b.Wait(ctx, 5)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Nil(t, b.Close())
+}
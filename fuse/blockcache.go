@@ -0,0 +1,101 @@
+// +build !windows
+
+package fuse
+
+import (
+	"container/list"
+	"io"
+)
+
+// readCacheBlockSize is the size of the blocks kept by blockCache.
+// The kernel reads at most 128K at once, so a read touches one or two blocks.
+const readCacheBlockSize = 128 * 1024
+
+// blockCache keeps recently read blocks of a file in memory.
+//
+// Reading at a certain offset means seeking the decrypting and decompressing
+// stream below it, which is expensive when jumping around. Media players
+// (seeking forth and back) and databases (reading the same pages over and
+// over) would otherwise cause a seek for every single read.
+type blockCache struct {
+	maxBlocks int
+	lru       *list.List
+	blocks    map[int64]*list.Element
+}
+
+type cachedBlock struct {
+	index int64
+	// data is shorter than readCacheBlockSize for the last block of the file.
+	data []byte
+}
+
+// newBlockCache returns a cache that holds up to `size` bytes.
+// It returns nil if `size` is smaller than a single block.
+func newBlockCache(size int64) *blockCache {
+	maxBlocks := int(size / readCacheBlockSize)
+	if maxBlocks <= 0 {
+		return nil
+	}
+
+	return &blockCache{
+		maxBlocks: maxBlocks,
+		lru:       list.New(),
+		blocks:    make(map[int64]*list.Element),
+	}
+}
+
+// block returns the block with `index`, calling `fill` if it is not cached.
+func (bc *blockCache) block(index int64, fill func(buf []byte, off int64) (int, error)) ([]byte, error) {
+	if elem, ok := bc.blocks[index]; ok {
+		bc.lru.MoveToFront(elem)
+		return elem.Value.(*cachedBlock).data, nil
+	}
+
+	data := make([]byte, readCacheBlockSize)
+	n, err := fill(data, index*readCacheBlockSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	data = data[:n]
+	bc.blocks[index] = bc.lru.PushFront(&cachedBlock{index: index, data: data})
+
+	for len(bc.blocks) > bc.maxBlocks {
+		oldest := bc.lru.Back()
+		bc.lru.Remove(oldest)
+		delete(bc.blocks, oldest.Value.(*cachedBlock).index)
+	}
+
+	return data, nil
+}
+
+// readAt fills `buf` with the data at `off`, reading missing blocks
+// with `fill`. Like io.ReaderAt, it returns io.EOF if `buf` was not filled
+// because the end of the file was reached.
+func (bc *blockCache) readAt(buf []byte, off int64, fill func(buf []byte, off int64) (int, error)) (int, error) {
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		index := pos / readCacheBlockSize
+
+		data, err := bc.block(index, fill)
+		if err != nil {
+			return n, err
+		}
+
+		inner := int(pos - index*readCacheBlockSize)
+		if inner >= len(data) {
+			return n, io.EOF
+		}
+
+		n += copy(buf[n:], data[inner:])
+	}
+
+	return n, nil
+}
+
+// purge forgets all blocks. It has to be called when the file was modified.
+func (bc *blockCache) purge() {
+	bc.lru.Init()
+	bc.blocks = make(map[int64]*list.Element)
+}
@@ -0,0 +1,235 @@
+// +build !windows
+
+package fuse
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/sahib/brig/catfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Do not let the cache grow without bounds on huge trees.
+const (
+	maxCachedStats    = 64 * 1024
+	maxCachedListings = 4 * 1024
+)
+
+// statCache caches the metadata of nodes, so that tools that stat a lot
+// of files (`ls -R`, file indexers of IDEs) do not need to go through catfs
+// for every single call.
+//
+// Metadata and directory listings are keyed by the tree hash of the node.
+// A modified node gets a new hash, so those entries never get outdated.
+// Only the mapping from a path to the hash of the node at this path can
+// become stale; it is kept for `ttl` and dropped on every modification done
+// through the mount and when the filesystem was changed by other means
+// (e.g. after a sync).
+type statCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	paths    map[string]cachedPath
+	nodes    map[string]*catfs.StatInfo
+	listings map[string][]*catfs.StatInfo
+
+	// gen is incremented on every purge. Results that were fetched
+	// before a purge are not cached, since they might be outdated.
+	gen uint64
+}
+
+type cachedPath struct {
+	hash    string
+	expires time.Time
+}
+
+func newStatCache(ttl time.Duration) *statCache {
+	return &statCache{
+		ttl:      ttl,
+		paths:    make(map[string]cachedPath),
+		nodes:    make(map[string]*catfs.StatInfo),
+		listings: make(map[string][]*catfs.StatInfo),
+	}
+}
+
+// remember caches `info` under its hash and makes `info.Path` point to it.
+// NOTE: This method assumes that sc.mu is locked.
+func (sc *statCache) remember(info *catfs.StatInfo, expires time.Time) {
+	if len(sc.nodes) >= maxCachedStats {
+		sc.nodes = make(map[string]*catfs.StatInfo)
+	}
+
+	if len(sc.paths) >= maxCachedStats {
+		sc.paths = make(map[string]cachedPath)
+	}
+
+	hash := info.TreeHash.B58String()
+	sc.nodes[hash] = info
+	sc.paths[info.Path] = cachedPath{hash: hash, expires: expires}
+}
+
+// stat returns the (possibly cached) info about `path`.
+func (sc *statCache) stat(cfs *catfs.FS, path string) (*catfs.StatInfo, error) {
+	if sc.ttl <= 0 {
+		return cfs.Stat(path)
+	}
+
+	now := time.Now()
+
+	sc.mu.Lock()
+	cached, ok := sc.paths[path]
+	info := sc.nodes[cached.hash]
+	gen := sc.gen
+	sc.mu.Unlock()
+
+	if ok && info != nil && now.Before(cached.expires) {
+		return info, nil
+	}
+
+	info, err := cfs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if gen != sc.gen {
+		return info, nil
+	}
+
+	sc.remember(info, now.Add(sc.ttl))
+	return info, nil
+}
+
+// list returns the direct children of the directory described by `dirInfo`.
+func (sc *statCache) list(cfs *catfs.FS, dirInfo *catfs.StatInfo) ([]*catfs.StatInfo, error) {
+	if sc.ttl <= 0 {
+		return cfs.List(dirInfo.Path, 1)
+	}
+
+	key := dirInfo.TreeHash.B58String()
+
+	sc.mu.Lock()
+	entries, ok := sc.listings[key]
+	gen := sc.gen
+	sc.mu.Unlock()
+
+	if ok {
+		return entries, nil
+	}
+
+	entries, err := cfs.List(dirInfo.Path, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if gen != sc.gen {
+		return entries, nil
+	}
+
+	if len(sc.listings) >= maxCachedListings {
+		sc.listings = make(map[string][]*catfs.StatInfo)
+	}
+
+	sc.listings[key] = entries
+
+	// Most callers will stat every entry right after listing:
+	expires := time.Now().Add(sc.ttl)
+	for _, entry := range entries {
+		sc.remember(entry, expires)
+	}
+
+	return entries, nil
+}
+
+// purge forgets which node is at which path.
+// The metadata of the nodes itself stays valid, since it is keyed by hash.
+func (sc *statCache) purge() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.paths = make(map[string]cachedPath)
+	sc.gen++
+}
+
+// kernelNodes remembers the nodes that were handed out to the kernel.
+// The kernel caches attributes and directory entries of those nodes
+// (for MountOptions.CacheTTL); when the filesystem changes by other
+// means than the mount, those caches need to be invalidated explicitly.
+type kernelNodes struct {
+	mu    sync.Mutex
+	nodes map[string]fs.Node
+}
+
+func newKernelNodes() *kernelNodes {
+	return &kernelNodes{
+		nodes: make(map[string]fs.Node),
+	}
+}
+
+// get returns the node for `path` the kernel knows already, if it has
+// the right type. Otherwise `create` is called and the result is remembered.
+func (kn *kernelNodes) get(path string, isDir bool, create func() fs.Node) fs.Node {
+	kn.mu.Lock()
+	defer kn.mu.Unlock()
+
+	switch nd := kn.nodes[path].(type) {
+	case *Directory:
+		if isDir {
+			return nd
+		}
+	case *File:
+		if !isDir {
+			return nd
+		}
+	}
+
+	nd := create()
+	kn.nodes[path] = nd
+	return nd
+}
+
+// forget is called when the kernel does not use `nd` anymore.
+func (kn *kernelNodes) forget(path string, nd fs.Node) {
+	kn.mu.Lock()
+	defer kn.mu.Unlock()
+
+	if kn.nodes[path] == nd {
+		delete(kn.nodes, path)
+	}
+}
+
+// invalidate tells the kernel to drop the attributes, data and directory
+// entries it cached for all known nodes. It must not be called from within
+// a fuse request, since the kernel might wait for the request to finish.
+func (kn *kernelNodes) invalidate(srv *fs.Server) {
+	kn.mu.Lock()
+	nodes := make(map[string]fs.Node, len(kn.nodes))
+	for path, nd := range kn.nodes {
+		nodes[path] = nd
+	}
+	kn.mu.Unlock()
+
+	for nodePath, nd := range nodes {
+		if err := srv.InvalidateNodeData(nd); err != nil && err != fuse.ErrNotCached {
+			log.Debugf("fuse: failed to invalidate %s: %v", nodePath, err)
+		}
+
+		parent, ok := nodes[path.Dir(nodePath)]
+		if !ok || nodePath == "/" {
+			continue
+		}
+
+		err := srv.InvalidateEntry(parent, path.Base(nodePath))
+		if err != nil && err != fuse.ErrNotCached {
+			log.Debugf("fuse: failed to invalidate entry %s: %v", nodePath, err)
+		}
+	}
+}
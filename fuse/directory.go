@@ -11,6 +11,7 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	ie "github.com/sahib/brig/catfs/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -25,16 +26,14 @@ func (dir *Directory) Attr(ctx context.Context, attr *fuse.Attr) error {
 	defer logPanic("dir: attr")
 
 	debugLog("Exec dir attr: %v", dir.path)
-	info, err := dir.m.fs.Stat(dir.path)
+	info, err := dir.m.cache.stat(dir.m.fs, dir.path)
 	if err != nil {
 		return errorize("dir-attr", err)
 	}
 
-	// Act like the file is owned by the user of the brig process.
-	attr.Uid = uint32(os.Getuid())
-	attr.Gid = uint32(os.Getgid())
+	attr.Valid = dir.m.options.CacheTTL
 
-	attr.Mode = os.ModeDir | 0755
+	setModeAndOwner(attr, info, os.ModeDir|0755)
 	attr.Size = info.Size
 	attr.Mtime = info.ModTime
 	attr.Inode = info.Inode
@@ -54,21 +53,19 @@ func (dir *Directory) Lookup(ctx context.Context, name string) (fs.Node, error)
 		return &Directory{path: path.Dir(dir.path), m: dir.m}, nil
 	}
 
-	var result fs.Node
+	if isBrigDir(dir, name) {
+		return &brigDir{m: dir.m}, nil
+	}
+
 	childPath := path.Join(dir.path, name)
 
-	info, err := dir.m.fs.Stat(childPath)
+	info, err := dir.m.cache.stat(dir.m.fs, childPath)
 	if err != nil {
 		return nil, errorize("dir-lookup", err)
 	}
 
-	if info.IsDir {
-		result = &Directory{path: childPath, m: dir.m}
-	} else {
-		result = &File{path: childPath, m: dir.m}
-	}
-
-	return result, nil
+	// The path policy might map `name` to a differently spelled node:
+	return dir.m.node(info.Path, info.IsDir), nil
 }
 
 // Mkdir is called to create a new directory node inside the receiver.
@@ -76,19 +73,31 @@ func (dir *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Nod
 	defer logPanic("dir: mkdir")
 
 	debugLog("fuse-mkdir: %v", req.Name)
+	if err := dir.m.checkWritable(); err != nil {
+		return nil, err
+	}
 
-	childPath := path.Join(dir.path, req.Name)
+	childPath := dir.m.fs.ResolvePath(path.Join(dir.path, req.Name))
 	if err := dir.m.fs.Mkdir(childPath, false); err != nil {
 		log.WithFields(log.Fields{
 			"path":  childPath,
 			"error": err,
 		}).Warning("fuse-mkdir failed")
 
+		if isReadOnlyError(err) {
+			return nil, errReadOnly
+		}
+
 		return nil, fuse.EIO
 	}
 
+	mode := req.Mode &^ req.Umask
+	if err := applyPermissions(dir.m, childPath, mode, req.Uid, req.Gid); err != nil {
+		return nil, errorize("fuse-mkdir-perms", err)
+	}
+
 	notifyChange(dir.m, 100*time.Millisecond)
-	return &Directory{path: childPath, m: dir.m}, nil
+	return dir.m.node(childPath, true), nil
 }
 
 // Create is called to create an opened file or directory  as child of the receiver.
@@ -97,8 +106,15 @@ func (dir *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp
 
 	var err error
 	debugLog("fuse-create: %v", req.Name)
+	if err := dir.m.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+
+	childPath := dir.m.fs.ResolvePath(path.Join(dir.path, req.Name))
+	if err := dir.m.writeback.syncPath(childPath); err != nil {
+		return nil, nil, errorize("fuse-dir-create-sync", err)
+	}
 
-	childPath := path.Join(dir.path, req.Name)
 	switch {
 	case req.Mode&os.ModeDir != 0:
 		err = dir.m.fs.Mkdir(childPath, false)
@@ -111,26 +127,48 @@ func (dir *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp
 			"path":  childPath,
 			"error": err,
 		}).Warning("fuse-create failed")
+
+		if isReadOnlyError(err) {
+			return nil, nil, errReadOnly
+		}
+
 		return nil, nil, fuse.EIO
 	}
 
+	mode := req.Mode &^ req.Umask
+	if err := applyPermissions(dir.m, childPath, mode, req.Uid, req.Gid); err != nil {
+		return nil, nil, errorize("fuse-dir-create-perms", err)
+	}
+
 	fd, err := dir.m.fs.Open(childPath)
 	if err != nil {
 		return nil, nil, errorize("fuse-dir-create", err)
 	}
 
 	notifyChange(dir.m, 100*time.Millisecond)
-	file := &File{path: childPath, m: dir.m}
-	return file, &Handle{fd: fd, m: dir.m}, nil
+	return dir.m.node(childPath, false), newHandle(dir.m, fd, req.Flags, &resp.OpenResponse), nil
 }
 
 // Remove is called when a direct child in the directory needs to be removed.
 func (dir *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
 	defer logPanic("dir: remove")
+	if err := dir.m.checkWritable(); err != nil {
+		return err
+	}
 
 	path := path.Join(dir.path, req.Name)
+
+	// A delayed write would bring the file back otherwise:
+	if err := dir.m.writeback.syncPath(path); err != nil {
+		return errorize("dir-remove-sync", err)
+	}
+
 	if err := dir.m.fs.Remove(path); err != nil {
 		log.Errorf("fuse: dir-remove: `%s` failed: %v", path, err)
+		if isReadOnlyError(err) {
+			return errReadOnly
+		}
+
 		return fuse.ENOENT
 	}
 
@@ -138,19 +176,95 @@ func (dir *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error
 	return nil
 }
 
+// Setattr is called when the mode or ownership of the directory changes.
+func (dir *Directory) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	defer logPanic("dir: setattr")
+
+	debugLog("exec dir setattr: %v", dir.path)
+	if err := dir.m.checkWritable(); err != nil {
+		return err
+	}
+
+	defer dir.m.cache.purge()
+	if err := setattrPermissions(dir.m, dir.path, req, os.ModeDir|0755); err != nil {
+		return errorize("dir-setattr-perms", err)
+	}
+
+	return nil
+}
+
+// Symlink is called to create a symbolic link in the directory.
+func (dir *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	defer logPanic("dir: symlink")
+	if err := dir.m.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	childPath := path.Join(dir.path, req.NewName)
+	debugLog("exec dir symlink: %v -> %v", childPath, req.Target)
+
+	if err := dir.m.fs.Symlink(childPath, req.Target); err != nil {
+		if err == ie.ErrExists {
+			return nil, fuse.EEXIST
+		}
+
+		return nil, errorize("dir-symlink", err)
+	}
+
+	if err := dir.m.fs.Chown(childPath, req.Uid, req.Gid); err != nil {
+		return nil, errorize("dir-symlink-chown", err)
+	}
+
+	notifyChange(dir.m, 100*time.Millisecond)
+	return dir.m.node(childPath, false), nil
+}
+
+// Link is called to create a hard link to `old` in the directory.
+// Nodes in brig cannot share their metadata, so the link is a copy
+// that shares the content with the original, but not later changes.
+func (dir *Directory) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
+	defer logPanic("dir: link")
+	if err := dir.m.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	oldFile, ok := old.(*File)
+	if !ok {
+		return nil, fuse.EPERM
+	}
+
+	childPath := path.Join(dir.path, req.NewName)
+	debugLog("exec dir link: %v -> %v", childPath, oldFile.path)
+
+	if err := dir.m.writeback.syncPath(oldFile.path); err != nil {
+		return nil, errorize("dir-link-sync", err)
+	}
+
+	if _, err := dir.m.fs.Stat(childPath); err == nil {
+		return nil, fuse.EEXIST
+	}
+
+	if err := dir.m.fs.Copy(oldFile.path, childPath); err != nil {
+		return nil, errorize("dir-link", err)
+	}
+
+	notifyChange(dir.m, 100*time.Millisecond)
+	return dir.m.node(childPath, false), nil
+}
+
 // ReadDirAll is called to get a directory listing of the receiver.
 func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	defer logPanic("dir: readdirall")
 
 	debugLog("Exec read dir all")
-	selfInfo, err := dir.m.fs.Stat(dir.path)
+	selfInfo, err := dir.m.cache.stat(dir.m.fs, dir.path)
 	if err != nil {
 		log.Debugf("Failed to stat: %v", dir.path)
 		return nil, errorize("fuse-dir-ls-stat", err)
 	}
 
 	parentDir := path.Dir(dir.path)
-	parInfo, err := dir.m.fs.Stat(parentDir)
+	parInfo, err := dir.m.cache.stat(dir.m.fs, parentDir)
 	if err != nil {
 		log.Debugf("Failed to stat parent: %v", parentDir)
 		return nil, errorize("fuse-dir-ls-stat-par", err)
@@ -169,7 +283,7 @@ func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		},
 	}
 
-	entries, err := dir.m.fs.List(dir.path, 1)
+	entries, err := dir.m.cache.list(dir.m.fs, selfInfo)
 	if err != nil {
 		log.Warningf("Failed to list entries: %v", dir.path)
 		return nil, errorize("fuse-dir-readall", err)
@@ -177,8 +291,11 @@ func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 
 	for _, entry := range entries {
 		childType := fuse.DT_File
-		if entry.IsDir {
+		switch {
+		case entry.IsDir:
 			childType = fuse.DT_Dir
+		case entry.Mode&os.ModeSymlink != 0:
+			childType = fuse.DT_Link
 		}
 
 		// If we return the same path (or just "/") to fuse
@@ -187,6 +304,12 @@ func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 			continue
 		}
 
+		// A real `.brig` is shadowed by the virtual one; don't list it,
+		// since looking it up would not return it.
+		if isBrigDir(dir, path.Base(entry.Path)) {
+			continue
+		}
+
 		fuseEnts = append(fuseEnts, fuse.Dirent{
 			Inode: entry.Inode,
 			Type:  childType,
@@ -219,3 +342,8 @@ func (dir *Directory) Listxattr(ctx context.Context, req *fuse.ListxattrRequest,
 	resp.Xattr = listXattr(req.Size)
 	return nil
 }
+
+// Forget is called when the kernel does not reference the directory anymore.
+func (dir *Directory) Forget() {
+	dir.m.kernel.forget(dir.path, dir)
+}
@@ -4,7 +4,6 @@ package fuse
 
 import (
 	"errors"
-	"os"
 	"path"
 	"time"
 
@@ -29,27 +28,31 @@ type File struct {
 func (fi *File) Attr(ctx context.Context, attr *fuse.Attr) error {
 	defer logPanic("file: attr")
 
-	info, err := fi.m.fs.Stat(fi.path)
+	info, err := fi.m.cache.stat(fi.m.fs, fi.path)
 	if err != nil {
 		return err
 	}
 	debugLog("exec file attr: %v", fi.path)
 
-	attr.Mode = 0755
-	attr.Size = info.Size
+	// Writes that were not committed yet are not visible in the
+	// filesystem, but the size should reflect them already.
+	size := info.Size
+	if pendingSize, ok := fi.m.writeback.pendingSize(fi.path); ok {
+		size = pendingSize
+	}
+
+	attr.Valid = fi.m.options.CacheTTL
+	attr.Size = size
 	attr.Mtime = info.ModTime
 	attr.Inode = info.Inode
-
-	// Act like the file is owned by the user of the brig process.
-	attr.Uid = uint32(os.Getuid())
-	attr.Gid = uint32(os.Getgid())
+	setModeAndOwner(attr, info, 0755)
 
 	// tools like `du` rely on this for size calculation
 	// (assuming every fs block takes actual storage, but we only emulate this
 	// here for compatibility; see man 2 stat for the why for "512")
 	attr.BlockSize = 4096
-	attr.Blocks = info.Size / 512
-	if info.Size%uint64(512) > 0 {
+	attr.Blocks = size / 512
+	if size%uint64(512) > 0 {
 		attr.Blocks++
 	}
 
@@ -73,12 +76,23 @@ func (fi *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.Open
 	}
 
 	debugLog("fuse-open: %s", fi.path)
+	if !req.Flags.IsReadOnly() {
+		if err := fi.m.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure we see what other handles wrote before:
+	if err := fi.m.writeback.syncPath(fi.path); err != nil {
+		return nil, errorize("file-open-sync", err)
+	}
+
 	fd, err := fi.m.fs.Open(fi.path)
 	if err != nil {
 		return nil, errorize("file-open", err)
 	}
 
-	return &Handle{fd: fd, m: fi.m}, nil
+	return newHandle(fi.m, fd, req.Flags, resp), nil
 }
 
 // Setattr is called once an attribute of a file changes.
@@ -91,6 +105,19 @@ func (fi *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fus
 	// most importantly the file size. For example it is called when truncating
 	// the file to zero bytes with a size change of `0`.
 	debugLog("exec file setattr")
+	if err := fi.m.checkWritable(); err != nil {
+		return err
+	}
+
+	if err := fi.m.writeback.syncPath(fi.path); err != nil {
+		return errorize("file-setattr-sync", err)
+	}
+
+	defer fi.m.cache.purge()
+	if err := setattrPermissions(fi.m, fi.path, req, 0755); err != nil {
+		return errorize("file-setattr-perms", err)
+	}
+
 	switch {
 	case req.Valid&fuse.SetattrSize != 0:
 		if err := fi.m.fs.Truncate(fi.path, req.Size); err != nil {
@@ -106,12 +133,12 @@ func (fi *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fus
 }
 
 // Fsync is called when any open buffers need to be written to disk.
-// Currently, fsync is completely ignored.
+// This skips the write-back delay for this file.
 func (fi *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
 	defer logPanic("file: fsync")
 
 	debugLog("exec file fsync")
-	return nil
+	return errorize("file-fsync", fi.m.writeback.syncPath(fi.path))
 }
 
 // Getxattr is called to get a single xattr (extended attribute) of a file.
@@ -119,6 +146,10 @@ func (fi *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *f
 	defer logPanic("file: getxattr")
 
 	debugLog("exec file getxattr: %v: %v", fi.path, req.Name)
+	if err := fi.m.writeback.syncPath(fi.path); err != nil {
+		return errorize("file-getxattr-sync", err)
+	}
+
 	xattrs, err := getXattr(fi.m.fs, req.Name, fi.path, req.Size)
 	if err != nil {
 		return err
@@ -142,12 +173,20 @@ func (fi *File) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.N
 	defer logPanic("file: rename")
 
 	debugLog("exec file rename")
+	if err := fi.m.checkWritable(); err != nil {
+		return err
+	}
+
 	newParent, ok := newDir.(*Directory)
 	if !ok {
 		return fuse.EIO
 	}
 
 	newPath := path.Join(newParent.path, req.NewName)
+	if err := fi.m.writeback.syncPath(fi.path); err != nil {
+		return errorize("file-rename-sync", err)
+	}
+
 	if err := fi.m.fs.Move(fi.path, newPath); err != nil {
 		log.Warningf("fuse: file: mv: %v", err)
 		return err
@@ -157,23 +196,41 @@ func (fi *File) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.N
 	return nil
 }
 
+// Readlink is called to get the target of a symbolic link.
+func (fi *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	defer logPanic("file: readlink")
+
+	debugLog("exec file readlink: %v", fi.path)
+	target, err := fi.m.fs.Readlink(fi.path)
+	if err != nil {
+		return "", errorize("file-readlink", err)
+	}
+
+	return target, nil
+}
+
+// Forget is called when the kernel does not reference the file anymore.
+func (fi *File) Forget() {
+	fi.m.kernel.forget(fi.path, fi)
+}
+
 // Compile time checks to see which interfaces we implement:
 // Please update this list when modifying code here.
 var _ = fs.Node(&File{})
+var _ = fs.NodeForgetter(&File{})
 var _ = fs.NodeFsyncer(&File{})
 var _ = fs.NodeGetxattrer(&File{})
 var _ = fs.NodeListxattrer(&File{})
 var _ = fs.NodeOpener(&File{})
+var _ = fs.NodeReadlinker(&File{})
 var _ = fs.NodeSetattrer(&File{})
 
 // Other interfaces are available, but currently not needed or make sense:
 // var _ = fs.NodeRenamer(&File{})
-// var _ = fs.NodeReadlinker(&File{})
 // var _ = fs.NodeRemover(&File{})
 // var _ = fs.NodeRemovexattrer(&File{})
 // var _ = fs.NodeRequestLookuper(&File{})
 // var _ = fs.NodeAccesser(&File{})
-// var _ = fs.NodeForgetter(&File{})
 // var _ = fs.NodeGetattrer(&File{})
 // var _ = fs.NodeLinker(&File{})
 // var _ = fs.NodeMkdirer(&File{})
@@ -4,13 +4,16 @@ package fuse
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"bazil.org/fuse"
 	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/defaults"
 	"github.com/sahib/brig/util/testutil"
@@ -253,3 +256,241 @@ func TestWithRoot(t *testing.T) {
 		})
 	})
 }
+
+func TestWriteback(t *testing.T) {
+	opts := MountOptions{
+		WritebackDelay: 100 * time.Millisecond,
+	}
+
+	withMount(t, opts, func(mount *Mount) {
+		cfs := mount.filesys.m.fs
+		path := filepath.Join(mount.Dir, "log.txt")
+
+		// Many small writes with a flush each, like an editor would do:
+		fd, err := os.Create(path)
+		require.Nil(t, err)
+
+		expected := []byte{}
+		for idx := 0; idx < 100; idx++ {
+			line := []byte(fmt.Sprintf("line %d\n", idx))
+			expected = append(expected, line...)
+
+			_, err := fd.Write(line)
+			require.Nil(t, err)
+		}
+
+		require.Nil(t, fd.Close())
+
+		// Opening it again has to see the pending writes:
+		checkForCorrectFile(t, path, expected)
+
+		// Removing it should not bring it back later:
+		require.Nil(t, ioutil.WriteFile(path, []byte{1, 2, 3}, 0644))
+		require.Nil(t, os.Remove(path))
+
+		time.Sleep(2 * opts.WritebackDelay)
+		_, err = cfs.Stat("/log.txt")
+		require.NotNil(t, err)
+	})
+}
+
+func TestStatCache(t *testing.T) {
+	withDummyFS(t, func(fs *catfs.FS) {
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte{1})))
+
+		cache := newStatCache(time.Hour)
+		info, err := cache.stat(fs, "/dir/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), info.Size)
+
+		// Changes are not seen until the cache is purged:
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte{1, 2})))
+		info, err = cache.stat(fs, "/dir/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), info.Size)
+
+		cache.purge()
+		info, err = cache.stat(fs, "/dir/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(2), info.Size)
+
+		// A changed directory has a new hash and is not served from the cache:
+		dirInfo, err := fs.Stat("/dir")
+		require.Nil(t, err)
+		entries, err := cache.list(fs, dirInfo)
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+
+		require.Nil(t, fs.Touch("/dir/y"))
+		dirInfo, err = fs.Stat("/dir")
+		require.Nil(t, err)
+		entries, err = cache.list(fs, dirInfo)
+		require.Nil(t, err)
+		require.Len(t, entries, 2)
+	})
+}
+
+func TestWritebackPendingSize(t *testing.T) {
+	withDummyFS(t, func(cfs *catfs.FS) {
+		require.Nil(t, cfs.Touch("/x"))
+
+		m := &Mount{fs: cfs, cache: newStatCache(time.Hour)}
+		m.writeback = newWriteback(m, time.Hour)
+		file := &File{path: "/x", m: m}
+
+		attr := fuse.Attr{}
+		require.Nil(t, file.Attr(context.Background(), &attr))
+		require.Equal(t, uint64(0), attr.Size)
+
+		fd, err := cfs.Open("/x")
+		require.Nil(t, err)
+
+		hd := &Handle{fd: fd, m: m}
+		_, err = fd.Write([]byte{1, 2, 3})
+		require.Nil(t, err)
+		require.Nil(t, m.writeback.schedule(hd))
+
+		// The stat is still cached, but the pending write has to be seen:
+		require.Nil(t, file.Attr(context.Background(), &attr))
+		require.Equal(t, uint64(3), attr.Size)
+
+		require.Nil(t, m.writeback.syncPath("/x"))
+		info, err := cfs.Stat("/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(3), info.Size)
+
+		_, ok := m.writeback.pendingSize("/x")
+		require.False(t, ok)
+	})
+}
+
+func TestBrigDirIsHidden(t *testing.T) {
+	withDummyFS(t, func(cfs *catfs.FS) {
+		require.Nil(t, cfs.Touch("/x"))
+
+		m := &Mount{
+			fs:      cfs,
+			cache:   newStatCache(time.Hour),
+			options: MountOptions{Root: "/"},
+		}
+		m.writeback = newWriteback(m, 0)
+		root := &Directory{path: "/", m: m}
+
+		entries, err := root.ReadDirAll(context.Background())
+		require.Nil(t, err)
+
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+
+		require.Equal(t, []string{".", "..", "x"}, names)
+
+		// It can still be entered by name:
+		node, err := root.Lookup(context.Background(), brigDirName)
+		require.Nil(t, err)
+		require.IsType(t, &brigDir{}, node)
+	})
+}
+
+func TestKernelNodes(t *testing.T) {
+	withDummyFS(t, func(cfs *catfs.FS) {
+		require.Nil(t, cfs.Stage("/dir/x", bytes.NewReader([]byte{1})))
+
+		m := &Mount{
+			fs:      cfs,
+			cache:   newStatCache(time.Hour),
+			kernel:  newKernelNodes(),
+			options: MountOptions{Root: "/"},
+		}
+
+		root := m.node("/", true)
+		dir, err := root.(*Directory).Lookup(context.Background(), "dir")
+		require.Nil(t, err)
+
+		// The kernel has to get the same node for the same path,
+		// otherwise its caches could not be invalidated later:
+		again, err := root.(*Directory).Lookup(context.Background(), "dir")
+		require.Nil(t, err)
+		require.True(t, dir == again)
+
+		file, err := dir.(*Directory).Lookup(context.Background(), "x")
+		require.Nil(t, err)
+		require.IsType(t, &File{}, file)
+
+		// A forgotten node is created again:
+		file.(*File).Forget()
+		fresh, err := dir.(*Directory).Lookup(context.Background(), "x")
+		require.Nil(t, err)
+		require.False(t, file == fresh)
+
+		// The path changed its type; the old node must not be reused:
+		require.Nil(t, cfs.Remove("/dir/x"))
+		require.Nil(t, cfs.Mkdir("/dir/x", false))
+		m.invalidate()
+
+		changed, err := dir.(*Directory).Lookup(context.Background(), "x")
+		require.Nil(t, err)
+		require.IsType(t, &Directory{}, changed)
+	})
+}
+
+func TestHandleReadCache(t *testing.T) {
+	withDummyFS(t, func(cfs *catfs.FS) {
+		data := testutil.CreateDummyBuf(3*readCacheBlockSize + 17)
+		require.Nil(t, cfs.Stage("/x", bytes.NewReader(data)))
+
+		m := &Mount{
+			fs:      cfs,
+			cache:   newStatCache(time.Hour),
+			options: MountOptions{ReadCacheSize: 2 * readCacheBlockSize},
+		}
+		m.writeback = newWriteback(m, time.Hour)
+
+		open := func(flags fuse.OpenFlags) (*Handle, *fuse.OpenResponse) {
+			fd, err := cfs.Open("/x")
+			require.Nil(t, err)
+
+			resp := &fuse.OpenResponse{}
+			return newHandle(m, fd, flags, resp), resp
+		}
+
+		read := func(hd *Handle, off int64, size int) []byte {
+			resp := &fuse.ReadResponse{Data: make([]byte, size)}
+			req := &fuse.ReadRequest{Offset: off, Size: size}
+			require.Nil(t, hd.Read(context.Background(), req, resp))
+			return resp.Data
+		}
+
+		hd, resp := open(fuse.OpenReadOnly)
+		require.NotNil(t, hd.cache)
+		require.Equal(t, fuse.OpenResponseFlags(0), resp.Flags&fuse.OpenDirectIO)
+
+		// Random reads, across block borders and past the end:
+		for _, off := range []int64{0, readCacheBlockSize - 10, 3 * readCacheBlockSize, 5, 2*readCacheBlockSize + 1} {
+			end := off + 4096
+			if end > int64(len(data)) {
+				end = int64(len(data))
+			}
+
+			got := read(hd, off, 4096)
+			require.True(t, bytes.Equal(data[off:end], got), "offset %d: got %d bytes", off, len(got))
+		}
+
+		require.Len(t, hd.cache.blocks, 2)
+
+		// Writes have to be visible to reads of the same handle:
+		rw, _ := open(fuse.OpenReadWrite)
+		require.Equal(t, data[:10], read(rw, 0, 10))
+		writeResp := &fuse.WriteResponse{}
+		require.Nil(t, rw.Write(context.Background(), &fuse.WriteRequest{Offset: 2, Data: []byte("xx")}, writeResp))
+		require.Equal(t, []byte("xx"), read(rw, 2, 2))
+
+		if isDirectOpen(fuse.OpenFlags(0x4000)) {
+			direct, resp := open(fuse.OpenReadOnly | fuse.OpenFlags(0x4000))
+			require.Nil(t, direct.cache)
+			require.NotEqual(t, fuse.OpenResponseFlags(0), resp.Flags&fuse.OpenDirectIO)
+			require.Equal(t, data[100:200], read(direct, 100, 100))
+		}
+	})
+}
@@ -5,7 +5,7 @@ package fuse
 import (
 	"io"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"context"
 
@@ -20,6 +20,50 @@ type Handle struct {
 	mu sync.Mutex
 	fd *catfs.Handle
 	m  *Mount
+
+	// cache is nil for handles that were opened with O_DIRECT
+	// or when the read cache is disabled.
+	cache *blockCache
+}
+
+// newHandle wraps `fd`, which was opened with `flags`, and sets
+// the response flags the kernel should use for it.
+func newHandle(m *Mount, fd *catfs.Handle, flags fuse.OpenFlags, resp *fuse.OpenResponse) *Handle {
+	hd := &Handle{fd: fd, m: m}
+	atomic.AddInt64(&m.openHandles, 1)
+
+	if isDirectOpen(flags) {
+		// Databases use O_DIRECT to be sure every read sees the latest write.
+		// This only works if the page cache of the kernel is bypassed too.
+		// The kernel does not allow shared mmap()s of those handles then.
+		resp.Flags |= fuse.OpenDirectIO
+		return hd
+	}
+
+	// Without OpenDirectIO reads go through the page cache of the kernel,
+	// which is also what makes mmap() work.
+	hd.cache = newBlockCache(m.options.ReadCacheSize)
+	return hd
+}
+
+// readAt reads into `buf` from `off` directly from the file.
+// NOTE: This method assumes that hd.mu is locked.
+func (hd *Handle) readAt(buf []byte, off int64) (int, error) {
+	newOff, err := hd.fd.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, errorize("handle-read-seek", err)
+	}
+
+	if newOff != off {
+		log.Warningf("read/seek offset differs (want %d, got %d)", off, newOff)
+	}
+
+	n, err := hd.fd.Read(buf)
+	if err != nil && err != io.EOF {
+		return n, errorize("handle-read-io", err)
+	}
+
+	return n, err
 }
 
 // Read is called to read a block of data at a certain offset.
@@ -34,18 +78,16 @@ func (hd *Handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Re
 		"size":   req.Size,
 	}).Debugf("fuse: handle: read")
 
-	newOff, err := hd.fd.Seek(req.Offset, io.SeekStart)
-	if err != nil {
-		return errorize("handle-read-seek", err)
+	var n int
+	var err error
+	if hd.cache != nil {
+		n, err = hd.cache.readAt(resp.Data[:req.Size], req.Offset, hd.readAt)
+	} else {
+		n, err = hd.readAt(resp.Data[:req.Size], req.Offset)
 	}
 
-	if newOff != req.Offset {
-		log.Warningf("read/seek offset differs (want %d, got %d)", req.Offset, newOff)
-	}
-
-	n, err := hd.fd.Read(resp.Data[:req.Size])
 	if err != nil && err != io.EOF {
-		return errorize("handle-read-io", err)
+		return err
 	}
 
 	resp.Data = resp.Data[:n]
@@ -58,6 +100,10 @@ func (hd *Handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.
 	defer hd.mu.Unlock()
 	defer logPanic("handle: write")
 
+	if err := hd.m.checkWritable(); err != nil {
+		return err
+	}
+
 	log.Debugf(
 		"fuse-write: %s (off: %d size: %d)",
 		hd.fd.Path(),
@@ -65,6 +111,11 @@ func (hd *Handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.
 		len(req.Data),
 	)
 
+	// The cached blocks might be outdated or have the wrong size now.
+	if hd.cache != nil {
+		hd.cache.purge()
+	}
+
 	newOff, err := hd.fd.Seek(req.Offset, io.SeekStart)
 	if err != nil {
 		return errorize("handle-write-seek", err)
@@ -85,18 +136,17 @@ func (hd *Handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.
 }
 
 // Flush is called to make sure all written contents get synced to disk.
+// The actual staging is delayed by the mount's write-back cache.
 func (hd *Handle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
 	defer logPanic("handle: flush")
-	if err := hd.flush(); err != nil {
-		return err
-	}
-
-	notifyChange(hd.m, 500*time.Millisecond)
-	return nil
+	return hd.m.writeback.schedule(hd)
 }
 
 // flush does the actual adding to brig.
 func (hd *Handle) flush() error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
 	log.Debugf("fuse-flush: %v", hd.fd.Path())
 	return errorize("handle-flush", hd.fd.Flush())
 }
@@ -104,7 +154,8 @@ func (hd *Handle) flush() error {
 // Release is called to close this handle.
 func (hd *Handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 	defer logPanic("handle: release")
-	return hd.flush()
+	atomic.AddInt64(&hd.m.openHandles, -1)
+	return hd.m.writeback.schedule(hd)
 }
 
 // Compiler checks to see if we got all the interfaces right:
@@ -0,0 +1,309 @@
+// +build !windows
+
+package fuse
+
+import (
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"context"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/catfs/mio"
+	log "github.com/sirupsen/logrus"
+)
+
+// The mount root contains a virtual, read-only `.brig` directory:
+//
+//     .brig/history/<path>/<commit>
+//
+// <path> mirrors the directories of the mount, but every file is shown
+// as directory that contains one file per version of it. Those are named
+// after the (abbreviated) commit that changed the file.
+//
+// The `.brig` directory is not listed, it can only be entered by name.
+// Otherwise tools like rsync, du or find would walk the whole history.
+// A real file called `.brig` in the root of the mount is shadowed.
+
+const (
+	brigDirName    = ".brig"
+	historyDirName = "history"
+)
+
+func virtualAttr(attr *fuse.Attr, mode os.FileMode) {
+	attr.Uid = uint32(os.Getuid())
+	attr.Gid = uint32(os.Getgid())
+	attr.Mode = mode
+}
+
+// brigDir is the `.brig` directory in the root of the mount.
+type brigDir struct {
+	m *Mount
+}
+
+// Attr is called to retrieve stat-metadata about the directory.
+func (bd *brigDir) Attr(ctx context.Context, attr *fuse.Attr) error {
+	virtualAttr(attr, os.ModeDir|0555)
+	return nil
+}
+
+// Lookup is called to lookup a direct child of the directory.
+func (bd *brigDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	if name == historyDirName {
+		return &historyDir{path: bd.m.options.Root, m: bd.m}, nil
+	}
+
+	return nil, fuse.ENOENT
+}
+
+// ReadDirAll is called to get a directory listing of the receiver.
+func (bd *brigDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	return []fuse.Dirent{
+		{Type: fuse.DT_Dir, Name: historyDirName},
+	}, nil
+}
+
+// historyDir mirrors the directory at `path`.
+type historyDir struct {
+	path string
+	m    *Mount
+}
+
+// Attr is called to retrieve stat-metadata about the directory.
+func (hd *historyDir) Attr(ctx context.Context, attr *fuse.Attr) error {
+	defer logPanic("history-dir: attr")
+
+	info, err := hd.m.fs.Stat(hd.path)
+	if err != nil {
+		return errorize("history-dir-attr", err)
+	}
+
+	virtualAttr(attr, os.ModeDir|0555)
+	attr.Mtime = info.ModTime
+	return nil
+}
+
+// Lookup is called to lookup a direct child of the directory.
+func (hd *historyDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	defer logPanic("history-dir: lookup")
+
+	childPath := path.Join(hd.path, name)
+	info, err := hd.m.fs.Stat(childPath)
+	if err != nil {
+		return nil, errorize("history-dir-lookup", err)
+	}
+
+	if info.IsDir {
+		return &historyDir{path: childPath, m: hd.m}, nil
+	}
+
+	return &versionDir{path: childPath, m: hd.m}, nil
+}
+
+// ReadDirAll is called to get a directory listing of the receiver.
+func (hd *historyDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	defer logPanic("history-dir: readdirall")
+
+	entries, err := hd.m.fs.List(hd.path, 1)
+	if err != nil {
+		return nil, errorize("history-dir-readall", err)
+	}
+
+	fuseEnts := []fuse.Dirent{}
+	for _, entry := range entries {
+		if entry.Path == "/" || entry.Path == hd.path {
+			continue
+		}
+
+		// Files are shown as directories containing their versions.
+		fuseEnts = append(fuseEnts, fuse.Dirent{
+			Type: fuse.DT_Dir,
+			Name: path.Base(entry.Path),
+		})
+	}
+
+	return fuseEnts, nil
+}
+
+// versionDir contains all versions of the file at `path`.
+type versionDir struct {
+	path string
+	m    *Mount
+}
+
+// versions returns all readable versions of the file by their name.
+func (vd *versionDir) versions() (map[string]*versionFile, error) {
+	history, err := vd.m.fs.History(vd.path)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]*versionFile)
+	for _, change := range history {
+		name := change.Head.Hash.ShortB58()
+		if _, ok := versions[name]; ok {
+			continue
+		}
+
+		// The file might have been removed or moved away in this commit.
+		rev := change.Head.Hash.B58String()
+		info, err := vd.m.fs.StatAt(rev, change.Path)
+		if err != nil || info.IsDir {
+			continue
+		}
+
+		versions[name] = &versionFile{
+			path: change.Path,
+			rev:  rev,
+			info: info,
+			m:    vd.m,
+		}
+	}
+
+	return versions, nil
+}
+
+// Attr is called to retrieve stat-metadata about the directory.
+func (vd *versionDir) Attr(ctx context.Context, attr *fuse.Attr) error {
+	defer logPanic("version-dir: attr")
+
+	info, err := vd.m.fs.Stat(vd.path)
+	if err != nil {
+		return errorize("version-dir-attr", err)
+	}
+
+	virtualAttr(attr, os.ModeDir|0555)
+	attr.Mtime = info.ModTime
+	return nil
+}
+
+// Lookup is called to lookup a direct child of the directory.
+func (vd *versionDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	defer logPanic("version-dir: lookup")
+
+	versions, err := vd.versions()
+	if err != nil {
+		return nil, errorize("version-dir-lookup", err)
+	}
+
+	version, ok := versions[name]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+
+	return version, nil
+}
+
+// ReadDirAll is called to get a directory listing of the receiver.
+func (vd *versionDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	defer logPanic("version-dir: readdirall")
+
+	versions, err := vd.versions()
+	if err != nil {
+		return nil, errorize("version-dir-readall", err)
+	}
+
+	fuseEnts := []fuse.Dirent{}
+	for name := range versions {
+		fuseEnts = append(fuseEnts, fuse.Dirent{
+			Type: fuse.DT_File,
+			Name: name,
+		})
+	}
+
+	return fuseEnts, nil
+}
+
+// versionFile is the read-only content of `path` at the commit `rev`.
+type versionFile struct {
+	path string
+	rev  string
+	info *catfs.StatInfo
+	m    *Mount
+}
+
+// Attr is called to retrieve stat-metadata about the file.
+func (vf *versionFile) Attr(ctx context.Context, attr *fuse.Attr) error {
+	virtualAttr(attr, 0444)
+	attr.Size = vf.info.Size
+	attr.Mtime = vf.info.ModTime
+	return nil
+}
+
+// Open is called to open the old version for reading.
+func (vf *versionFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	defer logPanic("version-file: open")
+
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.EPERM
+	}
+
+	stream, err := vf.m.fs.CatAt(vf.rev, vf.path)
+	if err != nil {
+		return nil, errorize("version-file-open", err)
+	}
+
+	return &versionHandle{stream: stream}, nil
+}
+
+// versionHandle is an open versionFile.
+type versionHandle struct {
+	mu     sync.Mutex
+	stream mio.Stream
+}
+
+// Read is called to read a block of data at a certain offset.
+func (vh *versionHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	vh.mu.Lock()
+	defer vh.mu.Unlock()
+	defer logPanic("version-handle: read")
+
+	if _, err := vh.stream.Seek(req.Offset, io.SeekStart); err != nil {
+		if err == io.EOF {
+			resp.Data = resp.Data[:0]
+			return nil
+		}
+
+		return errorize("version-handle-seek", err)
+	}
+
+	n, err := io.ReadFull(vh.stream, resp.Data[:req.Size])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return errorize("version-handle-read", err)
+	}
+
+	resp.Data = resp.Data[:n]
+	return nil
+}
+
+// Release is called when the file is closed.
+func (vh *versionHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	vh.mu.Lock()
+	defer vh.mu.Unlock()
+
+	if err := vh.stream.Close(); err != nil {
+		log.Warningf("fuse: failed to close version stream: %v", err)
+	}
+
+	return nil
+}
+
+// isBrigDir tells if `name` in `dir` refers to the virtual `.brig` directory.
+func isBrigDir(dir *Directory, name string) bool {
+	return name == brigDirName && dir.path == dir.m.options.Root
+}
+
+// Compiler checks to see if we got all the interfaces right:
+var _ = fs.NodeStringLookuper(&brigDir{})
+var _ = fs.HandleReadDirAller(&brigDir{})
+var _ = fs.NodeStringLookuper(&historyDir{})
+var _ = fs.HandleReadDirAller(&historyDir{})
+var _ = fs.NodeStringLookuper(&versionDir{})
+var _ = fs.HandleReadDirAller(&versionDir{})
+var _ = fs.NodeOpener(&versionFile{})
+var _ = fs.HandleReader(&versionHandle{})
+var _ = fs.HandleReleaser(&versionHandle{})
@@ -10,7 +10,9 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"bazil.org/fuse"
@@ -38,6 +40,15 @@ type MountOptions struct {
 	// Offline tells the mount to error out on files that would need
 	// to be fetched from far.
 	Offline bool
+	// WritebackDelay is the time a written file is kept in memory
+	// after the last flush, before it is staged. Zero stages immediately.
+	WritebackDelay time.Duration
+	// CacheTTL is the time the metadata of a node is cached
+	// in the mount (and by the kernel). Zero disables caching.
+	CacheTTL time.Duration
+	// ReadCacheSize is the number of bytes every open file may keep
+	// in memory to speed up reads. Zero disables the cache.
+	ReadCacheSize int64
 }
 
 // This is very similar (and indeed mostly copied) code from:
@@ -50,15 +61,22 @@ type MountOptions struct {
 type Mount struct {
 	Dir string
 
-	filesys  *Filesystem
-	closed   bool
-	done     chan util.Empty
-	errors   chan error
-	conn     *fuse.Conn
-	server   *fs.Server
-	options  MountOptions
-	notifier Notifier
-	fs       *catfs.FS
+	filesys   *Filesystem
+	closed    bool
+	done      chan util.Empty
+	errors    chan error
+	conn      *fuse.Conn
+	server    *fs.Server
+	options   MountOptions
+	notifier  Notifier
+	fs        *catfs.FS
+	writeback *writeback
+	cache     *statCache
+	kernel    *kernelNodes
+
+	// openHandles is the number of handles that were not released yet.
+	// Only access with sync/atomic.
+	openHandles int64
 }
 
 // NewMount mounts a fuse endpoint at `mountpoint` retrieving data from `store`.
@@ -66,9 +84,10 @@ func NewMount(cfs *catfs.FS, mountpoint string, notifier Notifier, opts MountOpt
 	mountOptions := []fuse.MountOption{
 		fuse.FSName("brigfs"),
 		fuse.Subtype("brig"),
-		fuse.AllowNonEmptyMount(),
 	}
 
+	mountOptions = append(mountOptions, platformMountOptions(mountpoint)...)
+
 	if opts.ReadOnly {
 		mountOptions = append(mountOptions, fuse.ReadOnly())
 	}
@@ -104,6 +123,9 @@ func NewMount(cfs *catfs.FS, mountpoint string, notifier Notifier, opts MountOpt
 	}
 	filesys := &Filesystem{m: mnt, root: opts.Root}
 	mnt.filesys = filesys
+	mnt.writeback = newWriteback(mnt, opts.WritebackDelay)
+	mnt.cache = newStatCache(opts.CacheTTL)
+	mnt.kernel = newKernelNodes()
 
 	go func() {
 		defer close(mnt.done)
@@ -129,8 +151,10 @@ func NewMount(cfs *catfs.FS, mountpoint string, notifier Notifier, opts MountOpt
 	return mnt, nil
 }
 
-func lazyUnmount(dir string) error {
-	cmd := exec.Command("fusermount", "-u", "-z", dir) // #nosec
+// runUnmountCommand runs an external unmount helper.
+// The platform specific lazyUnmount() functions use it.
+func runUnmountCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...) // #nosec
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		if len(output) > 0 {
@@ -153,6 +177,28 @@ func (m *Mount) EqualOptions(opts MountOptions) bool {
 	return path.Clean(m.options.Root) == path.Clean(opts.Root)
 }
 
+// node returns the fuse node for `path`. The kernel identifies nodes by
+// their pointer, so the same node is returned as long as the kernel uses it.
+func (m *Mount) node(path string, isDir bool) fs.Node {
+	return m.kernel.get(path, isDir, func() fs.Node {
+		if isDir {
+			return &Directory{path: path, m: m}
+		}
+
+		return &File{path: path, m: m}
+	})
+}
+
+// invalidate drops the metadata cached by the mount and by the kernel.
+func (m *Mount) invalidate() {
+	m.cache.purge()
+	if m.server != nil {
+		// Invalidating blocks until the kernel processed it;
+		// do not hold up the caller (which might be a fuse request).
+		go m.kernel.invalidate(m.server)
+	}
+}
+
 // Close will wait until all I/O operations are done and unmount the fuse
 // mount again.
 func (m *Mount) Close() error {
@@ -161,6 +207,11 @@ func (m *Mount) Close() error {
 	}
 	m.closed = true
 
+	// Do not lose any writes that are still held back:
+	if err := m.writeback.syncAll(); err != nil {
+		log.Warningf("failed to write back pending files: %v", err)
+	}
+
 	log.Infof("unmounting fuse mount at %v (this might take a bit)", m.Dir)
 
 	couldUnmount := false
@@ -205,6 +256,11 @@ func (m *Mount) Close() error {
 		// success or blocking due to fuse freeze.
 	}
 
+	// Handles might have been released during unmounting:
+	if err := m.writeback.syncAll(); err != nil {
+		log.Warningf("failed to write back pending files: %v", err)
+	}
+
 	// If we could not unmount, schedule closing in the background.
 	// This might be leaky, since Close might not ever return.
 	// But usually we unmount on program exit anyways...
@@ -223,10 +279,13 @@ func (m *Mount) Close() error {
 // `Mount` struct. It's given as convenient way to maintain several mounts.
 // All operations on the table are safe to call from several goroutines.
 type MountTable struct {
-	mu       sync.Mutex
-	m        map[string]*Mount
-	fs       *catfs.FS
-	notifier Notifier
+	mu             sync.Mutex
+	m              map[string]*Mount
+	fs             *catfs.FS
+	notifier       Notifier
+	writebackDelay time.Duration
+	cacheTTL       time.Duration
+	readCacheSize  int64
 }
 
 // NewMountTable returns an empty mount table.
@@ -238,6 +297,45 @@ func NewMountTable(fs *catfs.FS, notifier Notifier) *MountTable {
 	}
 }
 
+// SetWritebackDelay sets the write-back delay (see MountOptions)
+// for all mounts that are added to the table afterwards.
+func (t *MountTable) SetWritebackDelay(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.writebackDelay = delay
+}
+
+// SetCacheTTL sets the metadata cache time (see MountOptions)
+// for all mounts that are added to the table afterwards.
+func (t *MountTable) SetCacheTTL(ttl time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cacheTTL = ttl
+}
+
+// SetReadCacheSize sets the read cache size of every open file
+// (see MountOptions) for all mounts that are added to the table afterwards.
+func (t *MountTable) SetReadCacheSize(size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.readCacheSize = size
+}
+
+// Invalidate drops the cached metadata of all mounts.
+// It should be called when the filesystem was changed by other means
+// than the mounts, e.g. after a sync.
+func (t *MountTable) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range t.m {
+		m.invalidate()
+	}
+}
+
 // AddMount calls NewMount and adds it to the table at `path`.
 func (t *MountTable) AddMount(path string, opts MountOptions) (*Mount, error) {
 	t.mu.Lock()
@@ -270,6 +368,9 @@ func (t *MountTable) addMount(path string, opts MountOptions) (*Mount, error) {
 		return m, nil
 	}
 
+	opts.WritebackDelay = t.writebackDelay
+	opts.CacheTTL = t.cacheTTL
+	opts.ReadCacheSize = t.readCacheSize
 	m, err := NewMount(t.fs, path, t.notifier, opts)
 	if err == nil {
 		t.m[path] = m
@@ -296,6 +397,40 @@ func (t *MountTable) unmount(path string) error {
 	return m.Close()
 }
 
+// MountStats tells what is going on in a single mount.
+type MountStats struct {
+	Dir string
+	// OpenHandles is the number of files that are currently open.
+	OpenHandles int64
+	// PendingWrites is the number of closed files that are not staged yet.
+	PendingWrites int
+}
+
+// Stats returns the statistics of all mounts, sorted by their directory.
+func (t *MountTable) Stats() []MountStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := []MountStats{}
+	for dir, mount := range t.m {
+		mount.writeback.mu.Lock()
+		pending := len(mount.writeback.pending)
+		mount.writeback.mu.Unlock()
+
+		stats = append(stats, MountStats{
+			Dir:           dir,
+			OpenHandles:   atomic.LoadInt64(&mount.openHandles),
+			PendingWrites: pending,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Dir < stats[j].Dir
+	})
+
+	return stats
+}
+
 // Close unmounts all leftover mounts and clears the table.
 func (t *MountTable) Close() error {
 	t.mu.Lock()
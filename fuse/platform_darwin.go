@@ -0,0 +1,38 @@
+// +build darwin
+
+package fuse
+
+import (
+	"path/filepath"
+
+	"bazil.org/fuse"
+	log "github.com/sirupsen/logrus"
+)
+
+// On macOS the mount needs osxfuse (or macFUSE) to be installed.
+// The "nonempty" option of linux is not known there.
+
+func platformMountOptions(mountpoint string) []fuse.MountOption {
+	return []fuse.MountOption{
+		// Name shown in the Finder sidebar:
+		fuse.VolumeName("brig: " + filepath.Base(mountpoint)),
+		// Do not let the Finder litter the repository with ._* and
+		// .DS_Store files, which would be synced to all other peers.
+		fuse.NoAppleDouble(),
+		fuse.NoAppleXattr(),
+	}
+}
+
+func lazyUnmount(dir string) error {
+	if err := runUnmountCommand("umount", "-f", dir); err != nil {
+		log.Debugf("umount -f failed, trying diskutil: %v", err)
+		return runUnmountCommand("diskutil", "unmount", "force", dir)
+	}
+
+	return nil
+}
+
+// macOS has no O_DIRECT; F_NOCACHE is set with fcntl() and never reaches us.
+func isDirectOpen(flags fuse.OpenFlags) bool {
+	return false
+}
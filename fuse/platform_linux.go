@@ -0,0 +1,25 @@
+// +build linux
+
+package fuse
+
+import (
+	"syscall"
+
+	"bazil.org/fuse"
+)
+
+func platformMountOptions(mountpoint string) []fuse.MountOption {
+	return []fuse.MountOption{
+		// We check ourselves that the mount point is empty.
+		// This is only needed when an old mount was not cleaned up.
+		fuse.AllowNonEmptyMount(),
+	}
+}
+
+func lazyUnmount(dir string) error {
+	return runUnmountCommand("fusermount", "-u", "-z", dir)
+}
+
+func isDirectOpen(flags fuse.OpenFlags) bool {
+	return flags&fuse.OpenFlags(syscall.O_DIRECT) != 0
+}
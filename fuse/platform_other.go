@@ -0,0 +1,17 @@
+// +build !linux,!darwin,!windows
+
+package fuse
+
+import "bazil.org/fuse"
+
+func platformMountOptions(mountpoint string) []fuse.MountOption {
+	return nil
+}
+
+func lazyUnmount(dir string) error {
+	return runUnmountCommand("umount", "-f", dir)
+}
+
+func isDirectOpen(flags fuse.OpenFlags) bool {
+	return false
+}
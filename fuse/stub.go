@@ -6,10 +6,13 @@
 
 // This package is intentend for platforms that do not offer fuse.
 // It rebuilds the same API as the rest of the package with stubs.
+// Mounting on Windows needs a port to WinFsp (e.g. via cgofuse), which is
+// not done yet; see the "Port to other platforms" item in docs/roadmap.rst.
 package fuse
 
 import (
 	"errors"
+	"time"
 
 	"github.com/sahib/brig/catfs"
 	"github.com/sahib/config"
@@ -19,18 +22,22 @@ type Notifier interface {
 	PublishEvent()
 }
 
-var ErrCompiledWithoutFuse = errors.New("brig was compiled without fuse support")
+var ErrCompiledWithoutFuse = errors.New("mounting is not supported on windows yet (brig was compiled without fuse support)")
 
 type MountOptions struct {
-	ReadOnly bool
-	Root     string
+	ReadOnly       bool
+	Root           string
+	Offline        bool
+	WritebackDelay time.Duration
+	CacheTTL       time.Duration
+	ReadCacheSize  int64
 }
 
 type Mount struct {
 	Dir string
 }
 
-func NewMount(cfs *catfs.FS, mountpoint string, opts MountOptions) (*Mount, error) {
+func NewMount(cfs *catfs.FS, mountpoint string, notifier Notifier, opts MountOptions) (*Mount, error) {
 	return nil, ErrCompiledWithoutFuse
 }
 
@@ -48,6 +55,14 @@ func NewMountTable(fs *catfs.FS, notifier Notifier) *MountTable {
 	return nil
 }
 
+func (t *MountTable) SetWritebackDelay(delay time.Duration) {}
+
+func (t *MountTable) SetCacheTTL(ttl time.Duration) {}
+
+func (t *MountTable) SetReadCacheSize(size int64) {}
+
+func (t *MountTable) Invalidate() {}
+
 func (t *MountTable) AddMount(path string, opts MountOptions) (*Mount, error) {
 	return nil, ErrCompiledWithoutFuse
 }
@@ -56,6 +71,16 @@ func (t *MountTable) Unmount(path string) error {
 	return ErrCompiledWithoutFuse
 }
 
+type MountStats struct {
+	Dir           string
+	OpenHandles   int64
+	PendingWrites int
+}
+
+func (t *MountTable) Stats() []MountStats {
+	return nil
+}
+
 func (t *MountTable) Close() error {
 	return ErrCompiledWithoutFuse
 }
@@ -3,6 +3,9 @@
 package fuse
 
 import (
+	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"bazil.org/fuse"
@@ -11,7 +14,30 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// errReadOnly is returned for modifying operations on read-only mounts
+// or while the repository is in read-only mode.
+var errReadOnly = fuse.Errno(syscall.EROFS)
+
+func isReadOnlyError(err error) bool {
+	return err == catfs.ErrReadOnly || err == catfs.ErrFrozen
+}
+
+// checkWritable returns EROFS for read-only mounts. The kernel already
+// refuses most writes on those, but we do not want to rely on that.
+func (m *Mount) checkWritable() error {
+	if m.options.ReadOnly {
+		return errReadOnly
+	}
+
+	return nil
+}
+
 func errorize(name string, err error) error {
+	if isReadOnlyError(err) {
+		log.Debugf("errorize: %s: read-only: %v", name, err)
+		return errReadOnly
+	}
+
 	if ie.IsNoSuchFileError(err) {
 		log.Infof("errorize: %s: No such file: %v", name, err)
 		return fuse.ENOENT
@@ -25,6 +51,61 @@ func errorize(name string, err error) error {
 	return nil
 }
 
+// setModeAndOwner fills the permission bits and the ownership of `attr`.
+// Nodes that never had their mode set act like they are owned
+// by the user of the brig process and use `defaultMode`.
+func setModeAndOwner(attr *fuse.Attr, info *catfs.StatInfo, defaultMode os.FileMode) {
+	if !info.HasMode {
+		attr.Mode = defaultMode
+		attr.Uid = uint32(os.Getuid())
+		attr.Gid = uint32(os.Getgid())
+		return
+	}
+
+	attr.Mode = defaultMode&os.ModeDir | info.Mode
+	attr.Uid = info.UID
+	attr.Gid = info.GID
+}
+
+// applyPermissions stores mode and ownership of `path` in its metadata.
+// Both are always set together, since the ownership is ignored without mode.
+func applyPermissions(m *Mount, path string, mode os.FileMode, uid, gid uint32) error {
+	if err := m.fs.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	return m.fs.Chown(path, uid, gid)
+}
+
+// setattrPermissions applies mode and ownership changes of `req` to `path`.
+func setattrPermissions(m *Mount, path string, req *fuse.SetattrRequest, defaultMode os.FileMode) error {
+	if req.Valid&(fuse.SetattrMode|fuse.SetattrUid|fuse.SetattrGid) == 0 {
+		return nil
+	}
+
+	info, err := m.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	attr := fuse.Attr{}
+	setModeAndOwner(&attr, info, defaultMode)
+
+	if req.Valid.Mode() {
+		attr.Mode = req.Mode
+	}
+
+	if req.Valid.Uid() {
+		attr.Uid = req.Uid
+	}
+
+	if req.Valid.Gid() {
+		attr.Gid = req.Gid
+	}
+
+	return applyPermissions(m, path, attr.Mode, attr.Uid, attr.Gid)
+}
+
 // logPanic logs any panics by being called in a defer.
 // A rather inconvinient behaviour of fuse is to not report panics.
 func logPanic(name string) {
@@ -38,6 +119,7 @@ func listXattr(size uint32) []byte {
 	resp = append(resp, "user.brig.hash\x00"...)
 	resp = append(resp, "user.brig.content\x00"...)
 	resp = append(resp, "user.brig.pinned\x00"...)
+	resp = append(resp, "user.brig.labels\x00"...)
 
 	if uint32(len(resp)) > size {
 		resp = resp[:size]
@@ -71,6 +153,8 @@ func getXattr(cfs *catfs.FS, name, path string, size uint32) ([]byte, error) {
 		} else {
 			resp = []byte("no")
 		}
+	case "user.brig.labels":
+		resp = []byte(strings.Join(info.Labels, ","))
 	default:
 		return nil, fuse.ErrNoXattr
 	}
@@ -83,7 +167,11 @@ func getXattr(cfs *catfs.FS, name, path string, size uint32) ([]byte, error) {
 	return resp, nil
 }
 
+// notifyChange drops cached metadata and tells others
+// (after `d`) that something in the mount changed.
 func notifyChange(m *Mount, d time.Duration) {
+	m.cache.purge()
+
 	if m.notifier == nil {
 		// this can happen in tests.
 		return
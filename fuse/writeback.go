@@ -0,0 +1,187 @@
+// +build !windows
+
+package fuse
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeback delays the staging of written files. Editors and compilers
+// tend to flush the same file many times in a short time span. Staging
+// a file is expensive (it's hashed, stored and a new version is created),
+// so flushes are coalesced until no flush happened for `delay`.
+//
+// Writes itself are kept in the overlay of the catfs handle until then.
+// If a delayed commit fails, the handle stays dirty and is retried later;
+// the error is reported by the next flush or fsync of the file.
+type writeback struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	pending map[*Handle]*time.Timer
+	failed  map[*Handle]error
+	m       *Mount
+}
+
+// maxRetryDelay is the longest time between two retries of a failed commit.
+const maxRetryDelay = time.Minute
+
+func newWriteback(m *Mount, delay time.Duration) *writeback {
+	return &writeback{
+		delay:   delay,
+		pending: make(map[*Handle]*time.Timer),
+		failed:  make(map[*Handle]error),
+		m:       m,
+	}
+}
+
+// schedule stages `hd` once no further schedule() call happened for `delay`.
+func (wb *writeback) schedule(hd *Handle) error {
+	if wb.delay <= 0 {
+		return wb.commit(hd)
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if timer, ok := wb.pending[hd]; ok {
+		timer.Stop()
+	}
+
+	wb.arm(hd, wb.delay)
+
+	// Report a failed delayed commit once; the retry is still pending.
+	err := wb.failed[hd]
+	delete(wb.failed, hd)
+	return err
+}
+
+// arm commits `hd` after `delay`. If that fails, it re-arms itself
+// with a longer delay, so the written data is not lost.
+// NOTE: This method assumes that wb.mu is locked.
+func (wb *writeback) arm(hd *Handle, delay time.Duration) {
+	wb.pending[hd] = time.AfterFunc(delay, func() {
+		wb.mu.Lock()
+		delete(wb.pending, hd)
+		wb.mu.Unlock()
+
+		err := wb.commit(hd)
+
+		wb.mu.Lock()
+		defer wb.mu.Unlock()
+
+		if err == nil {
+			delete(wb.failed, hd)
+			return
+		}
+
+		wb.failed[hd] = err
+		if _, ok := wb.pending[hd]; ok {
+			// Somebody scheduled the handle again in the meantime.
+			return
+		}
+
+		retry := 2 * delay
+		if retry > maxRetryDelay {
+			retry = maxRetryDelay
+		}
+
+		log.Warningf("fuse: delayed write of %s failed (retry in %v): %v", hd.fd.Path(), retry, err)
+		wb.arm(hd, retry)
+	})
+}
+
+// pendingSize returns the size of a not yet committed write to `path`.
+// The bool is false if nothing is pending for `path`.
+func (wb *writeback) pendingSize(path string) (uint64, bool) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	size, found := uint64(0), false
+	for hd := range wb.pending {
+		if hd.fd.Path() != path {
+			continue
+		}
+
+		if hdSize := hd.fd.Size(); !found || hdSize > size {
+			size = hdSize
+		}
+
+		found = true
+	}
+
+	return size, found
+}
+
+// commit does the actual (and expensive) staging.
+func (wb *writeback) commit(hd *Handle) error {
+	if err := hd.flush(); err != nil {
+		return err
+	}
+
+	notifyChange(wb.m, 500*time.Millisecond)
+	return nil
+}
+
+// take removes all pending handles matching `filter` and returns them.
+func (wb *writeback) take(filter func(hd *Handle) bool) []*Handle {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	handles := []*Handle{}
+	for hd, timer := range wb.pending {
+		if !filter(hd) {
+			continue
+		}
+
+		// The timer might have fired already and wait for the lock.
+		// Committing twice is cheap, since nothing changed in between.
+		timer.Stop()
+		handles = append(handles, hd)
+		delete(wb.pending, hd)
+	}
+
+	return handles
+}
+
+// commitAll commits `handles` right now. Handles that fail to commit
+// are scheduled again, so their writes are retried later.
+func (wb *writeback) commitAll(handles []*Handle) error {
+	errs := util.Errors{}
+	for _, hd := range handles {
+		err := wb.commit(hd)
+
+		wb.mu.Lock()
+		delete(wb.failed, hd)
+		if err != nil {
+			errs = append(errs, err)
+			if _, ok := wb.pending[hd]; !ok && wb.delay > 0 {
+				wb.arm(hd, wb.delay)
+			}
+		}
+		wb.mu.Unlock()
+	}
+
+	return errs.ToErr()
+}
+
+// syncPath commits all pending writes to `path` (or below it) right now.
+// This needs to be called before anything reads or modifies `path`
+// by other means than the handle that wrote to it.
+func (wb *writeback) syncPath(path string) error {
+	return wb.commitAll(wb.take(func(hd *Handle) bool {
+		hdPath := hd.fd.Path()
+		return hdPath == path || strings.HasPrefix(hdPath, strings.TrimSuffix(path, "/")+"/")
+	}))
+}
+
+// syncAll commits all pending writes right now.
+func (wb *writeback) syncAll() error {
+	return wb.commitAll(wb.take(func(hd *Handle) bool {
+		return true
+	}))
+}
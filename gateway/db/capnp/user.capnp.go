@@ -14,12 +14,12 @@ type User struct{ capnp.Struct }
 const User_TypeID = 0x861de4463c5a4a22
 
 func NewUser(s *capnp.Segment) (User, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6})
 	return User{st}, err
 }
 
 func NewRootUser(s *capnp.Segment) (User, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6})
 	return User{st}, err
 }
 
@@ -140,12 +140,37 @@ func (s User) NewRights(n int32) (capnp.TextList, error) {
 	return l, err
 }
 
+func (s User) Groups() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(5)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s User) HasGroups() bool {
+	p, err := s.Struct.Ptr(5)
+	return p.IsValid() || err != nil
+}
+
+func (s User) SetGroups(v capnp.TextList) error {
+	return s.Struct.SetPtr(5, v.List.ToPtr())
+}
+
+// NewGroups sets the groups field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s User) NewGroups(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(5, l.List.ToPtr())
+	return l, err
+}
+
 // User_List is a list of User.
 type User_List struct{ capnp.List }
 
 // NewUser creates a new list of User.
 func NewUser_List(s *capnp.Segment, sz int32) (User_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6}, sz)
 	return User_List{l}, err
 }
 
@@ -166,23 +191,24 @@ func (p User_Promise) Struct() (User, error) {
 	return User{s}, err
 }
 
-const schema_a0b1c18bd0f965c4 = "x\xda\\\xca\xb1J\x03A\x18\xc4\xf1\x99\xdd;\x05\x09" +
-	"\x89\x0b[*\x82\xa5\xa0!m\x10\x14\x0b\x11\xab|\x85" +
-	"\x8d\xddj\xd6D\x89\xc9q{\x12-D\x85 \x8a\x0a" +
-	"VV\x16\x0a\xbe\x80\x9d\x9d\x08\xdak\xe1\x1b\xf8\x12V" +
-	"'\x17Hc\xf7\x9f\x1f3y\xb7\xacj\xf1+\x01\xb1" +
-	"\xf1X>\xbb\xbe\xb9\xb8\xfa3}\x0e3\xc5\xfc\xdd\xff" +
-	"~^\xbd=? \x8e\xc7\x81\xda\xc7\x04\xcdw\x11_" +
-	"3\xc4|\xder\x99\xef\xbb\xa3\xaannU\xb7]\xd2" +
-	"M\xaa\x07\xc1\xa7\x0b\xc3\xaco\x04\x9f\x02\x0dR\xac\x8e" +
-	"\x80\x88\x809\x9e\x03\xe4PS\x06\x8a\x86\xb4,\xf0l" +
-	"\x0f\x90SM\xb9V4JY*\xc0\\\x16\xcf\x81\xa6" +
-	"\xdc*\x1a\xad-5`nV\x00\xb9\xd0\x94'E\x13" +
-	"E\x96\x11`\x1e\xeb\x80\xdck\xca\x8bb\xa5\xeb\xf6=" +
-	"KP,\x81y\xe2B\xe8\xf7\xd2&*k.\xb4G" +
-	"\\\x09\xae\x93\x8d\xc6\xc9N\xaf\xd3\xf4i`\x19lh" +
-	"\x0e\xb9\x0c.\xa5\xbb\xadv\xf6_\xff\x02\x00\x00\xff\xff" +
-	"\x89\xf1=\x11"
+const schema_a0b1c18bd0f965c4 = "x\xdad\xca\xa1KCQ\x1c\xc5\xf1s\xee}o\x82" +
+	"\x8c\xcd\x0b/\x18\xb4\x18\x05\x1d\xabCP\x0c\"\xa6\xfd" +
+	"\x82\x08\xb6\xab{n\xca\xdc\x1e\xf7n\x0c\x93\xcdb\xb5" +
+	"\x08\x0a\x0b\x0a\x0e,6\x8b\xa8\xa0\xcd\xe0@\xff\x03\x83" +
+	"\xff\x82\xe9\xc9\x1b\xach\xfb\x9e\x0fgj\xb0\xa2\xca\xe1" +
+	"#\x01\x89\xc2\\:\xb7\xb1\xbd\xb4\xf65{\x023\xc3" +
+	"\xf4%\xfey?}\xbe\xeb#\xccM\x00\xe5\xd7I\x9a" +
+	"\xcf,\x86[\xc4BZ\xb7\x9d\xb8g\x8fJ\xba\xb6S" +
+	"\xda\xb5I+)u}\xec\x16GY\xd9\xf4\xb1\x03\xaa" +
+	"\xa4L\xeb\x00\x08\x08\x98\xf3y@\xce4\xa5\xafh\xc8" +
+	"\x88\x19^\x1e\x00r\xa1)7\x8aF\xa9\x88\x0a0\xd7" +
+	"\xd9\xb3\xaf)\xb7\x8aF\xeb\x88\x1a0\x83U@\xae4" +
+	"\xe5I\xd1\x04A\xc4\x000\x0f\x15@\xee5\xe5C\xd1" +
+	"\x84a\xc4\x100\xc3\x0c\xdf4\xe5[\xb1\xd8\xb2\x871" +
+	"\xf3P\xcc\x83ib\xbd\xef\xb5]\x0d\xc5u\xeb\x1bc" +
+	".z\xdb\xec\x8c\xc7\xf1^\xbbY\x8b\x9dg\x01\xacj" +
+	"\x8e\xb8\x00.\xbb\xfdz\xa3\xf3O\xeb\xae\xddM\xfe\xea" +
+	"\xef\x00\xf7\x9bF\x15"
 
 func init() {
 	schemas.Register(schema_a0b1c18bd0f965c4,
@@ -1,9 +1,6 @@
 package db
 
 import (
-	"crypto/rand"
-	"crypto/subtle"
-	"encoding/base64"
 	"fmt"
 	"sync"
 	"time"
@@ -11,7 +8,6 @@ import (
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/badger/options"
 	capnp "github.com/sahib/brig/gateway/db/capnp"
-	"github.com/sahib/brig/util"
 	capnp_lib "zombiezen.com/go/capnproto2"
 )
 
@@ -28,6 +24,13 @@ const (
 	RightRemotesView = "remotes.view"
 	// RightRemotesEdit is the right to edit the remote list.
 	RightRemotesEdit = "remotes.edit"
+	// RightUsersView is the right to view information about other users.
+	RightUsersView = "users.view"
+	// RightFsShare is the right to create links to the filesystem,
+	// like drop links.
+	RightFsShare = "fs.share"
+	// RightAdminUsers is the right to administrate other users.
+	RightAdminUsers = "admin.users"
 )
 
 var (
@@ -40,25 +43,33 @@ var (
 		RightFsEdit,
 		RightRemotesView,
 		RightRemotesEdit,
+		RightUsersView,
+		RightFsShare,
+		RightAdminUsers,
 	}
 
 	// AllRights is a map that can be quickly used to check
-	// if a right is valid or not.
+	// if a right is valid or not. Wildcards are not part of it,
+	// use IsValidRight to check those.
 	AllRights = map[string]bool{
 		RightDownload:    true,
 		RightFsView:      true,
 		RightFsEdit:      true,
 		RightRemotesView: true,
 		RightRemotesEdit: true,
+		RightUsersView:   true,
+		RightFsShare:     true,
+		RightAdminUsers:  true,
 	}
 )
 
 // UserDatabase is a badger db that stores user information,
 // using the user name as unique key.
 type UserDatabase struct {
-	mu       sync.Mutex
-	db       *badger.DB
-	gcTicker *time.Ticker
+	mu         sync.Mutex
+	db         *badger.DB
+	gcTicker   *time.Ticker
+	hashParams HashParams
 }
 
 // NewUserDatabase creates a new UserDatabase at `path` or loads
@@ -90,7 +101,35 @@ func NewUserDatabase(path string) (*UserDatabase, error) {
 		}
 	}()
 
-	return &UserDatabase{db: db, gcTicker: gcTicker}, nil
+	ub := &UserDatabase{
+		db:         db,
+		gcTicker:   gcTicker,
+		hashParams: DefaultHashParams,
+	}
+
+	if err := ub.migrateRights(); err != nil {
+		ub.Close()
+		return nil, err
+	}
+
+	return ub, nil
+}
+
+// SetHashParams sets the parameters used for new password hashes.
+// Existing users get them on their next successful login.
+func (ub *UserDatabase) SetHashParams(params HashParams) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	ub.hashParams = params
+}
+
+// HashParams returns the parameters used for new password hashes.
+func (ub *UserDatabase) HashParams() HashParams {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.hashParams
 }
 
 // Close cleans up all the resources used by a badger db.
@@ -154,6 +193,21 @@ func UserFromCapnp(capUser capnp.User) (*User, error) {
 		rights = append(rights, right)
 	}
 
+	capGroups, err := capUser.Groups()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []string{}
+	for idx := 0; idx < capGroups.Len(); idx++ {
+		group, err := capGroups.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, group)
+	}
+
 	name, err := capUser.Name()
 	if err != nil {
 		return nil, err
@@ -175,6 +229,7 @@ func UserFromCapnp(capUser capnp.User) (*User, error) {
 		Salt:         salt,
 		Folders:      folders,
 		Rights:       rights,
+		Groups:       groups,
 	}, nil
 }
 
@@ -228,6 +283,21 @@ func UserToCapnp(user *User, seg *capnp_lib.Segment) (*capnp.User, error) {
 		return nil, err
 	}
 
+	capGroups, err := capnp_lib.NewTextList(seg, int32(len(user.Groups)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, group := range user.Groups {
+		if err := capGroups.Set(idx, group); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := capUser.SetGroups(capGroups); err != nil {
+		return nil, err
+	}
+
 	if err := capUser.SetName(user.Name); err != nil {
 		return nil, err
 	}
@@ -244,46 +314,18 @@ func UserToCapnp(user *User, seg *capnp_lib.Segment) (*capnp.User, error) {
 }
 
 // User is one user that is stored in the database.
-// The passwords are stored as scrypt hash with added salt.
+// The passwords are stored as argon2id hash with added salt.
+// See HashAlgorithm() for users that were created by older versions.
 type User struct {
 	Name         string
 	PasswordHash string
 	Salt         string
 	Folders      []string
-	Rights       []string
-}
-
-// CheckPassword checks if `password` matches the stored one.
-func (u User) CheckPassword(password string) (bool, error) {
-	salt, err := base64.StdEncoding.DecodeString(u.Salt)
-	if err != nil {
-		return false, err
-	}
-
-	oldHash, err := base64.StdEncoding.DecodeString(u.PasswordHash)
-	if err != nil {
-		return false, err
-	}
-
-	newHash := util.DeriveKey([]byte(password), salt, 32)
-	return subtle.ConstantTimeCompare(oldHash, newHash) == 1, nil
-}
-
-// HashPassword creates a new hash and salt from a password.
-func HashPassword(password string) (string, string, error) {
-	// Read a new salt from a random source.
-	// 8 bytes are considered enough by the scrypt documentation.
-	salt := make([]byte, 8)
-	if n, err := rand.Read(salt); err != nil {
-		return "", "", err
-	} else if n != 8 {
-		return "", "", fmt.Errorf("did not read enough random bytes")
-	}
-
-	// Derive the actual hash and encode it to base64.
-	hash := util.DeriveKey([]byte(password), salt, 32)
-	encode := base64.StdEncoding.EncodeToString
-	return encode(hash), encode(salt), nil
+	// Rights are the rights given to the user directly.
+	// Use UserDatabase.EffectiveRights to include the ones of Groups.
+	Rights []string
+	// Groups are the names of the groups the user is member of.
+	Groups []string
 }
 
 // Add adds a new user to the database.
@@ -292,6 +334,10 @@ func (ub *UserDatabase) Add(name, password string, folders []string, rights []st
 	ub.mu.Lock()
 	defer ub.mu.Unlock()
 
+	if isReservedKey([]byte(name)) {
+		return fmt.Errorf("invalid user name: %s", name)
+	}
+
 	if len(folders) == 0 {
 		folders = []string{"/"}
 	}
@@ -300,13 +346,11 @@ func (ub *UserDatabase) Add(name, password string, folders []string, rights []st
 		rights = DefaultRights
 	}
 
-	for _, right := range rights {
-		if !AllRights[right] {
-			return fmt.Errorf("invalid right: %s", right)
-		}
+	if err := validateRights(rights); err != nil {
+		return err
 	}
 
-	hashed, salt, err := HashPassword(password)
+	hashed, salt, err := HashPasswordWithParams(password, ub.hashParams)
 	if err != nil {
 		return err
 	}
@@ -329,6 +373,139 @@ func (ub *UserDatabase) Add(name, password string, folders []string, rights []st
 	})
 }
 
+// Rehash hashes the password of `name` again with the current parameters,
+// if the stored hash is outdated. `password` has to be checked before.
+// Returns true if the hash was updated.
+func (ub *UserDatabase) Rehash(name, password string) (bool, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	updated := false
+	err := ub.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		data, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		user, err := unmarshalUser(data)
+		if err != nil {
+			return err
+		}
+
+		if !user.NeedsRehash(ub.hashParams) {
+			return nil
+		}
+
+		user.PasswordHash, user.Salt, err = HashPasswordWithParams(password, ub.hashParams)
+		if err != nil {
+			return err
+		}
+
+		newData, err := marshalUser(user)
+		if err != nil {
+			return err
+		}
+
+		updated = true
+		return txn.Set([]byte(name), newData)
+	})
+
+	return updated, err
+}
+
+// SetUserGroups sets the groups the existing user `name` is member of.
+// The groups do not need to exist yet.
+func (ub *UserDatabase) SetUserGroups(name string, groups []string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		data, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		user, err := unmarshalUser(data)
+		if err != nil {
+			return err
+		}
+
+		user.Groups = groups
+		newData, err := marshalUser(user)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(name), newData)
+	})
+}
+
+// UpdateUsers calls `fn` for each user in `names` and stores all of them
+// in a single transaction. If one of the users does not exist or `fn`
+// returns an error, none of the users is changed.
+func (ub *UserDatabase) UpdateUsers(names []string, fn func(user *User) error) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		for _, name := range names {
+			item, err := txn.Get([]byte(name))
+			if err != nil {
+				return fmt.Errorf("user %s: %v", name, err)
+			}
+
+			data, err := item.Value()
+			if err != nil {
+				return err
+			}
+
+			user, err := unmarshalUser(data)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(user); err != nil {
+				return err
+			}
+
+			newData, err := marshalUser(user)
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set([]byte(name), newData); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Ping checks if the database is open and can be read.
+func (ub *UserDatabase) Ping() error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	if ub.db == nil {
+		return fmt.Errorf("user database is closed")
+	}
+
+	return ub.db.View(func(txn *badger.Txn) error {
+		return nil
+	})
+}
+
 // Get returns a User, if it exists. If it does not exist,
 // an error will be returned.
 func (ub *UserDatabase) Get(name string) (User, error) {
@@ -372,6 +549,25 @@ func (ub *UserDatabase) Remove(name string) error {
 	})
 }
 
+// Outdated returns all users whose password hash is outdated.
+// They are upgraded on their next successful login.
+func (ub *UserDatabase) Outdated() ([]User, error) {
+	users, err := ub.List()
+	if err != nil {
+		return nil, err
+	}
+
+	params := ub.HashParams()
+	outdated := []User{}
+	for _, user := range users {
+		if user.NeedsRehash(params) {
+			outdated = append(outdated, user)
+		}
+	}
+
+	return outdated, nil
+}
+
 // List returns all users currently in the database.
 func (ub *UserDatabase) List() ([]User, error) {
 	ub.mu.Lock()
@@ -383,6 +579,10 @@ func (ub *UserDatabase) List() ([]User, error) {
 		defer iter.Close()
 
 		for iter.Rewind(); iter.Valid(); iter.Next() {
+			if isReservedKey(iter.Item().Key()) {
+				continue
+			}
+
 			data, err := iter.Item().Value()
 			if err != nil {
 				return err
@@ -33,3 +33,99 @@ func TestAddGet(t *testing.T) {
 		require.Equal(t, []string{"fs.view"}, user.Rights)
 	})
 }
+
+func TestUpdateUsers(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("alice", "pw", []string{"/"}, nil))
+		require.Nil(t, db.Add("bob", "pw", []string{"/bob"}, nil))
+
+		addPublic := func(user *User) error {
+			user.Folders = append(user.Folders, "/public")
+			return nil
+		}
+
+		// One unknown user; nobody is changed:
+		require.NotNil(t, db.UpdateUsers([]string{"alice", "nobody"}, addPublic))
+		alice, err := db.Get("alice")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/"}, alice.Folders)
+
+		require.Nil(t, db.UpdateUsers([]string{"alice", "bob"}, addPublic))
+		bob, err := db.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/bob", "/public"}, bob.Folders)
+	})
+}
+
+func TestDropLinks(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("hello", "world", nil, nil))
+
+		link, err := db.AddDropLink("hello", "/inbox", 1024, []string{".pdf", "image/*"}, 0)
+		require.Nil(t, err)
+		require.NotEmpty(t, link.Token)
+		require.False(t, link.IsExpired())
+
+		require.True(t, link.AllowsType("a.PDF", "application/pdf"))
+		require.True(t, link.AllowsType("a.png", "image/png"))
+		require.False(t, link.AllowsType("a.exe", "application/octet-stream"))
+
+		loaded, err := db.GetDropLink(link.Token)
+		require.Nil(t, err)
+		require.Equal(t, "/inbox", loaded.Folder)
+		require.Equal(t, "hello", loaded.Owner)
+
+		// Drop links should not show up as users:
+		users, err := db.List()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+
+		links, err := db.ListDropLinks()
+		require.Nil(t, err)
+		require.Len(t, links, 1)
+
+		require.Nil(t, db.RemoveDropLink(link.Token))
+		_, err = db.GetDropLink(link.Token)
+		require.NotNil(t, err)
+	})
+}
+
+func TestUploadPolicies(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("hello", "world", nil, nil))
+		require.Nil(t, db.SetUploadPolicy(UploadPolicy{Folder: "/", Blocked: []string{".exe"}}))
+		require.Nil(t, db.SetUploadPolicy(UploadPolicy{
+			Folder:  "photos/",
+			MaxSize: 1024,
+			Allowed: []string{"image/*"},
+		}))
+		require.NotNil(t, db.SetUploadPolicy(UploadPolicy{Folder: "/x", MaxSize: -1}))
+
+		policies, err := db.UploadPolicies()
+		require.Nil(t, err)
+		require.Len(t, policies, 2)
+		require.Equal(t, "/photos", policies[1].Folder)
+
+		// Policies should not show up as users:
+		users, err := db.List()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+
+		require.Equal(t, "/", UploadPolicyFor(policies, "/photos.txt").Folder)
+		photos := UploadPolicyFor(policies, "/photos/a/b.png")
+		require.Equal(t, "/photos", photos.Folder)
+
+		require.Nil(t, photos.Check("b.png", "image/png", 1024))
+		require.Equal(t, ErrUploadTooBig, photos.Check("b.png", "image/png", 1025))
+		require.Equal(t, ErrUploadTypeForbidden, photos.Check("b.pdf", "application/pdf", 10))
+		require.Equal(t, ErrUploadTypeForbidden, policies[0].Check("a.EXE", "application/octet-stream", 10))
+
+		require.Nil(t, db.RemoveUploadPolicy("/photos"))
+		require.NotNil(t, db.RemoveUploadPolicy("/photos"))
+
+		policies, err = db.UploadPolicies()
+		require.Nil(t, err)
+		require.Len(t, policies, 1)
+		require.Nil(t, UploadPolicyFor(nil, "/a"))
+	})
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Drop links are stored in the same database as the users,
+// but their keys are prefixed by `dropKeyPrefix`.
+const dropKeyPrefix = "drop-link:"
+
+func isDropKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(dropKeyPrefix))
+}
+
+func dropKey(token string) []byte {
+	return []byte(dropKeyPrefix + token)
+}
+
+// DropLink is a link that allows anonymous users to upload files
+// into `Folder`, without being able to see anything of it.
+type DropLink struct {
+	// Token is the secret part of the link.
+	Token string `json:"token"`
+	// Folder is where the uploaded files will end up.
+	Folder string `json:"folder"`
+	// Owner is the name of the user that created the link.
+	Owner string `json:"owner"`
+	// MaxSize is the maximum size of a single file in bytes.
+	// Zero means no limit.
+	MaxSize int64 `json:"max_size"`
+	// Types is a list of allowed file types. Each entry is either
+	// an extension (".pdf") or a mime type ("image/png", "image/*").
+	// If empty, all types are allowed.
+	Types []string `json:"types"`
+	// CreatedAt is the time when the link was created.
+	CreatedAt time.Time `json:"created_at"`
+	// Expires is the time after which the link can not be used anymore.
+	// The zero time means that the link does not expire.
+	Expires time.Time `json:"expires"`
+}
+
+// IsExpired returns true if the link can not be used anymore.
+func (dl DropLink) IsExpired() bool {
+	return !dl.Expires.IsZero() && time.Now().After(dl.Expires)
+}
+
+// AllowsType checks if a file called `name` with the mime type `mimeType`
+// may be uploaded through this link.
+func (dl DropLink) AllowsType(name, mimeType string) bool {
+	if len(dl.Types) == 0 {
+		return true
+	}
+
+	return matchesFileType(dl.Types, name, mimeType)
+}
+
+// matchesFileType checks if a file called `name` with the mime type
+// `mimeType` matches one of `types`. See DropLink.Types for the format.
+func matchesFileType(types []string, name, mimeType string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if idx := strings.IndexByte(mimeType, ';'); idx >= 0 {
+		mimeType = mimeType[:idx]
+	}
+
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+
+	for _, typ := range types {
+		typ = strings.ToLower(typ)
+		switch {
+		case strings.HasPrefix(typ, "."):
+			if ext == typ {
+				return true
+			}
+		case strings.HasSuffix(typ, "/*"):
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(typ, "*")) {
+				return true
+			}
+		case typ == mimeType:
+			return true
+		}
+	}
+
+	return false
+}
+
+func newDropToken() (string, error) {
+	data := make([]byte, 16)
+	if n, err := rand.Read(data); err != nil {
+		return "", err
+	} else if n != len(data) {
+		return "", fmt.Errorf("did not read enough random bytes")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// AddDropLink creates a new drop link for `folder`, owned by `owner`.
+// A `ttl` of zero creates a link that does not expire.
+func (ub *UserDatabase) AddDropLink(owner, folder string, maxSize int64, types []string, ttl time.Duration) (*DropLink, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	token, err := newDropToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	link := &DropLink{
+		Token:     token,
+		Folder:    folder,
+		Owner:     owner,
+		MaxSize:   maxSize,
+		Types:     types,
+		CreatedAt: now,
+	}
+
+	if ttl > 0 {
+		link.Expires = now.Add(ttl)
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, ub.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(dropKey(token), data)
+	})
+}
+
+// GetDropLink returns the drop link with `token`.
+// An error is returned if there is no such link.
+func (ub *UserDatabase) GetDropLink(token string) (*DropLink, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	link := &DropLink{}
+	return link, ub.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(dropKey(token))
+		if err != nil {
+			return err
+		}
+
+		data, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(data, link)
+	})
+}
+
+// ListDropLinks returns all drop links in the database.
+func (ub *UserDatabase) ListDropLinks() ([]DropLink, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	links := []DropLink{}
+	return links, ub.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.IteratorOptions{})
+		defer iter.Close()
+
+		prefix := []byte(dropKeyPrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			data, err := iter.Item().Value()
+			if err != nil {
+				return err
+			}
+
+			link := DropLink{}
+			if err := json.Unmarshal(data, &link); err != nil {
+				return err
+			}
+
+			links = append(links, link)
+		}
+
+		return nil
+	})
+}
+
+// RemoveDropLink removes the drop link with `token`.
+func (ub *UserDatabase) RemoveDropLink(token string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		// Make sure to error out if the key did not exist:
+		if _, err := txn.Get(dropKey(token)); err != nil {
+			return err
+		}
+
+		return txn.Delete(dropKey(token))
+	})
+}
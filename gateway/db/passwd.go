@@ -0,0 +1,172 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/sahib/brig/util"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// HashAlgoLegacy is the hash of users created by older versions.
+	// It uses argon2id too, but with fixed and rather weak parameters.
+	HashAlgoLegacy = "legacy"
+
+	// HashAlgoArgon2id is the current hash algorithm.
+	HashAlgoArgon2id = "argon2id"
+)
+
+// HashParams are the argon2id parameters of a single password hash.
+// They are stored along with every hash, so they can be raised
+// later without breaking existing passwords.
+type HashParams struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the memory used in KiB.
+	Memory uint32
+	// Threads is the number of lanes used.
+	Threads uint8
+	// KeyLen is the length of the resulting hash in bytes.
+	KeyLen uint32
+}
+
+// DefaultHashParams are the parameters recommended by RFC 9106
+// for systems that cannot spend 2GB of memory per login.
+var DefaultHashParams = HashParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+	KeyLen:  32,
+}
+
+// String returns the parameters in the notation of the PHC string format.
+func (hp HashParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", hp.Memory, hp.Time, hp.Threads)
+}
+
+// Weaker returns true if any of the parameters is below the ones of `other`.
+func (hp HashParams) Weaker(other HashParams) bool {
+	return hp.Time < other.Time ||
+		hp.Memory < other.Memory ||
+		hp.Threads < other.Threads ||
+		hp.KeyLen < other.KeyLen
+}
+
+// parseHash splits a hash like "$argon2id$v=19$m=65536,t=3,p=4$<base64>".
+func parseHash(encoded string) (HashParams, []byte, error) {
+	params := HashParams{}
+	split := strings.Split(encoded, "$")
+	if len(split) != 5 || split[1] != HashAlgoArgon2id {
+		return params, nil, fmt.Errorf("unknown password hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(split[2], "v=%d", &version); err != nil {
+		return params, nil, err
+	}
+
+	if version != argon2.Version {
+		return params, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	_, err := fmt.Sscanf(split[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return params, nil, err
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(split[4])
+	if err != nil {
+		return params, nil, err
+	}
+
+	params.KeyLen = uint32(len(hash))
+	return params, hash, nil
+}
+
+// HashPassword creates a new hash and salt from a password
+// using the DefaultHashParams.
+func HashPassword(password string) (string, string, error) {
+	return HashPasswordWithParams(password, DefaultHashParams)
+}
+
+// HashPasswordWithParams creates a new hash and salt from a password.
+// Both are returned base64 encoded; the hash includes the parameters.
+func HashPasswordWithParams(password string, params HashParams) (string, string, error) {
+	salt := make([]byte, 16)
+	if n, err := rand.Read(salt); err != nil {
+		return "", "", err
+	} else if n != len(salt) {
+		return "", "", fmt.Errorf("did not read enough random bytes")
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	encoded := fmt.Sprintf(
+		"$%s$v=%d$%s$%s",
+		HashAlgoArgon2id,
+		argon2.Version,
+		params,
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
+
+	return encoded, base64.StdEncoding.EncodeToString(salt), nil
+}
+
+// HashAlgorithm returns the algorithm the password of `u` was hashed with.
+func (u User) HashAlgorithm() string {
+	if strings.HasPrefix(u.PasswordHash, "$") {
+		return HashAlgoArgon2id
+	}
+
+	return HashAlgoLegacy
+}
+
+// HashParams returns the parameters the password of `u` was hashed with.
+// Legacy hashes return zero parameters.
+func (u User) HashParams() HashParams {
+	if u.HashAlgorithm() != HashAlgoArgon2id {
+		return HashParams{}
+	}
+
+	params, _, err := parseHash(u.PasswordHash)
+	if err != nil {
+		return HashParams{}
+	}
+
+	return params
+}
+
+// NeedsRehash returns true if the password of `u` should be hashed again,
+// because it uses an old algorithm or weaker parameters than `params`.
+func (u User) NeedsRehash(params HashParams) bool {
+	return u.HashAlgorithm() != HashAlgoArgon2id || u.HashParams().Weaker(params)
+}
+
+// CheckPassword checks if `password` matches the stored one.
+func (u User) CheckPassword(password string) (bool, error) {
+	salt, err := base64.StdEncoding.DecodeString(u.Salt)
+	if err != nil {
+		return false, err
+	}
+
+	if u.HashAlgorithm() == HashAlgoLegacy {
+		oldHash, err := base64.StdEncoding.DecodeString(u.PasswordHash)
+		if err != nil {
+			return false, err
+		}
+
+		newHash := util.DeriveKey([]byte(password), salt, 32)
+		return subtle.ConstantTimeCompare(oldHash, newHash) == 1, nil
+	}
+
+	params, oldHash, err := parseHash(u.PasswordHash)
+	if err != nil {
+		return false, err
+	}
+
+	newHash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	return subtle.ConstantTimeCompare(oldHash, newHash) == 1, nil
+}
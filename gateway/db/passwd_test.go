@@ -0,0 +1,106 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sahib/brig/util"
+	"github.com/stretchr/testify/require"
+)
+
+// addLegacyUser stores a user like older versions did.
+func addLegacyUser(t *testing.T, db *UserDatabase, name, password string) {
+	salt := []byte("12345678")
+	hash := util.DeriveKey([]byte(password), salt, 32)
+
+	data, err := marshalUser(&User{
+		Name:         name,
+		PasswordHash: base64.StdEncoding.EncodeToString(hash),
+		Salt:         base64.StdEncoding.EncodeToString(salt),
+		Folders:      []string{"/"},
+		Rights:       DefaultRights,
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(name), data)
+	}))
+}
+
+func TestHashPassword(t *testing.T) {
+	params := HashParams{Time: 1, Memory: 1024, Threads: 1, KeyLen: 16}
+	hash, salt, err := HashPasswordWithParams("secret", params)
+	require.Nil(t, err)
+
+	user := User{Name: "ali", PasswordHash: hash, Salt: salt}
+	require.Equal(t, HashAlgoArgon2id, user.HashAlgorithm())
+	require.Equal(t, params, user.HashParams())
+	require.Equal(t, "m=1024,t=1,p=1", user.HashParams().String())
+
+	ok, err := user.CheckPassword("secret")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = user.CheckPassword("Secret")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.False(t, user.NeedsRehash(params))
+	require.True(t, user.NeedsRehash(DefaultHashParams))
+
+	user.PasswordHash = "$argon2id$v=1$m=1024,t=1,p=1$abc"
+	_, err = user.CheckPassword("secret")
+	require.NotNil(t, err)
+}
+
+func TestRehashLegacy(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		addLegacyUser(t, db, "old", "password")
+		require.Nil(t, db.Add("new", "password", nil, nil))
+
+		outdated, err := db.Outdated()
+		require.Nil(t, err)
+		require.Len(t, outdated, 1)
+		require.Equal(t, "old", outdated[0].Name)
+		require.Equal(t, HashAlgoLegacy, outdated[0].HashAlgorithm())
+
+		user, err := db.Get("old")
+		require.Nil(t, err)
+
+		ok, err := user.CheckPassword("password")
+		require.Nil(t, err)
+		require.True(t, ok)
+
+		updated, err := db.Rehash("old", "password")
+		require.Nil(t, err)
+		require.True(t, updated)
+
+		// Second time is a no-op:
+		updated, err = db.Rehash("old", "password")
+		require.Nil(t, err)
+		require.False(t, updated)
+
+		user, err = db.Get("old")
+		require.Nil(t, err)
+		require.Equal(t, HashAlgoArgon2id, user.HashAlgorithm())
+		require.Equal(t, DefaultHashParams, user.HashParams())
+
+		ok, err = user.CheckPassword("password")
+		require.Nil(t, err)
+		require.True(t, ok)
+
+		outdated, err = db.Outdated()
+		require.Nil(t, err)
+		require.Empty(t, outdated)
+
+		// Raising the parameters makes everyone outdated again:
+		stronger := DefaultHashParams
+		stronger.Time++
+		db.SetHashParams(stronger)
+
+		outdated, err = db.Outdated()
+		require.Nil(t, err)
+		require.Len(t, outdated, 2)
+	})
+}
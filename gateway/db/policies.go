@@ -0,0 +1,160 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Upload policies are stored next to the users, keyed by their folder.
+const uploadPolicyKeyPrefix = "upload-policy:"
+
+var (
+	// ErrUploadTooBig is returned by UploadPolicy.Check for files over the size limit.
+	ErrUploadTooBig = errors.New("is too big")
+	// ErrUploadTypeForbidden is returned by UploadPolicy.Check for files of a forbidden type.
+	ErrUploadTypeForbidden = errors.New("has a type that is not allowed")
+)
+
+func isUploadPolicyKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(uploadPolicyKeyPrefix))
+}
+
+func uploadPolicyKey(folder string) []byte {
+	return []byte(uploadPolicyKeyPrefix + folder)
+}
+
+// UploadPolicy restricts what may be uploaded into a folder and below.
+type UploadPolicy struct {
+	// Folder is the directory the policy applies to.
+	Folder string `json:"folder"`
+	// MaxSize is the maximum size of a single file in bytes.
+	// Zero means no limit.
+	MaxSize int64 `json:"max_size"`
+	// Allowed is a list of allowed file types, in the format of DropLink.Types.
+	// If empty, all types that are not blocked are allowed.
+	Allowed []string `json:"allowed"`
+	// Blocked is a list of file types that may never be uploaded.
+	// It wins over Allowed.
+	Blocked []string `json:"blocked"`
+}
+
+// Check tells if a file called `name` of `size` bytes and with the mime type
+// `mimeType` may be uploaded. It returns ErrUploadTooBig or ErrUploadTypeForbidden
+// if not.
+func (up UploadPolicy) Check(name, mimeType string, size int64) error {
+	if up.MaxSize > 0 && size > up.MaxSize {
+		return ErrUploadTooBig
+	}
+
+	if matchesFileType(up.Blocked, name, mimeType) {
+		return ErrUploadTypeForbidden
+	}
+
+	if len(up.Allowed) > 0 && !matchesFileType(up.Allowed, name, mimeType) {
+		return ErrUploadTypeForbidden
+	}
+
+	return nil
+}
+
+// IsEmpty returns true if the policy does not restrict anything.
+func (up UploadPolicy) IsEmpty() bool {
+	return up.MaxSize <= 0 && len(up.Allowed) == 0 && len(up.Blocked) == 0
+}
+
+// UploadPolicyFor returns the policy of the deepest folder in `policies`
+// that contains `path`, or nil if there is none.
+func UploadPolicyFor(policies []UploadPolicy, path string) *UploadPolicy {
+	var best *UploadPolicy
+	for idx := range policies {
+		folder := policies[idx].Folder
+		if folder != "/" && path != folder && !strings.HasPrefix(path, folder+"/") {
+			continue
+		}
+
+		if best == nil || len(folder) > len(best.Folder) {
+			best = &policies[idx]
+		}
+	}
+
+	return best
+}
+
+// SetUploadPolicy adds `policy` or replaces the one of the same folder.
+func (ub *UserDatabase) SetUploadPolicy(policy UploadPolicy) error {
+	if policy.MaxSize < 0 {
+		return fmt.Errorf("max size may not be negative")
+	}
+
+	policy.Folder = path.Clean("/" + policy.Folder)
+
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(uploadPolicyKey(policy.Folder), data)
+	})
+}
+
+// UploadPolicies returns all upload policies, sorted by folder.
+func (ub *UserDatabase) UploadPolicies() ([]UploadPolicy, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	policies := []UploadPolicy{}
+	err := ub.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.IteratorOptions{})
+		defer iter.Close()
+
+		prefix := []byte(uploadPolicyKeyPrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			data, err := iter.Item().Value()
+			if err != nil {
+				return err
+			}
+
+			policy := UploadPolicy{}
+			if err := json.Unmarshal(data, &policy); err != nil {
+				return err
+			}
+
+			policies = append(policies, policy)
+		}
+
+		return nil
+	})
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Folder < policies[j].Folder
+	})
+
+	return policies, err
+}
+
+// RemoveUploadPolicy removes the policy of `folder`.
+func (ub *UserDatabase) RemoveUploadPolicy(folder string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	key := uploadPolicyKey(path.Clean("/" + folder))
+	return ub.db.Update(func(txn *badger.Txn) error {
+		// Make sure to error out if the key did not exist:
+		if _, err := txn.Get(key); err != nil {
+			return err
+		}
+
+		return txn.Delete(key)
+	})
+}
@@ -0,0 +1,357 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Rights are hierarchical: every right is in a namespace (the part before
+// the dot) and may be granted via a wildcard like "fs.*" or "*". Some rights
+// also imply others (e.g. who can edit can also view, see impliedRights).
+// Before a right is checked, the granted rights are expanded by
+// ExpandRights, so the checks only have to deal with concrete rights.
+
+const (
+	// RightAll is the wildcard that grants every right.
+	RightAll = "*"
+
+	// rightsVersion is the version of the rights model.
+	// It is stored in the database and used to migrate old users.
+	rightsVersion = 1
+)
+
+// impliedRights maps a right to the rights it includes.
+var impliedRights = map[string][]string{
+	RightFsEdit:      {RightFsView},
+	RightFsShare:     {RightFsView},
+	RightRemotesEdit: {RightRemotesView},
+	RightAdminUsers:  {RightUsersView},
+}
+
+// Groups are stored in the same database as the users,
+// but their keys are prefixed by `groupKeyPrefix`.
+const groupKeyPrefix = "group:"
+
+// metaKeyPrefix is the prefix of keys with information about the database.
+const metaKeyPrefix = "meta:"
+
+func groupKey(name string) []byte {
+	return []byte(groupKeyPrefix + name)
+}
+
+// isReservedKey returns true for all keys that do not belong to users.
+func isReservedKey(key []byte) bool {
+	return isDropKey(key) ||
+		isUploadPolicyKey(key) ||
+		bytes.HasPrefix(key, []byte(groupKeyPrefix)) ||
+		bytes.HasPrefix(key, []byte(metaKeyPrefix))
+}
+
+// IsValidRight checks if `right` is a known right or a wildcard
+// that matches at least one known right.
+func IsValidRight(right string) bool {
+	if AllRights[right] || right == RightAll {
+		return true
+	}
+
+	if !strings.HasSuffix(right, ".*") {
+		return false
+	}
+
+	for known := range AllRights {
+		if rightMatches(right, known) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rightMatches checks if the granted right `granted` includes `right`,
+// either directly or by a wildcard.
+func rightMatches(granted, right string) bool {
+	switch {
+	case granted == right, granted == RightAll:
+		return true
+	case strings.HasSuffix(granted, ".*"):
+		return strings.HasPrefix(right, strings.TrimSuffix(granted, "*"))
+	default:
+		return false
+	}
+}
+
+// ExpandRights resolves wildcards and implied rights in `granted`.
+// The result is a sorted list of concrete rights (see AllRights).
+// Unknown rights are dropped.
+func ExpandRights(granted []string) []string {
+	expanded := make(map[string]bool)
+
+	var add func(right string)
+	add = func(right string) {
+		if expanded[right] {
+			return
+		}
+
+		expanded[right] = true
+		for _, implied := range impliedRights[right] {
+			add(implied)
+		}
+	}
+
+	for _, right := range granted {
+		for known := range AllRights {
+			if rightMatches(right, known) {
+				add(known)
+			}
+		}
+	}
+
+	result := []string{}
+	for right := range expanded {
+		result = append(result, right)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func validateRights(rights []string) error {
+	for _, right := range rights {
+		if !IsValidRight(right) {
+			return fmt.Errorf("invalid right: %s", right)
+		}
+	}
+
+	return nil
+}
+
+// Group is a named set of rights that can be given to many users.
+type Group struct {
+	Name   string   `json:"name"`
+	Rights []string `json:"rights"`
+}
+
+// AddGroup adds a new group or overwrites an existing one.
+func (ub *UserDatabase) AddGroup(name string, rights []string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	if name == "" || strings.ContainsAny(name, ", ") {
+		return fmt.Errorf("invalid group name: %q", name)
+	}
+
+	if err := validateRights(rights); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(Group{Name: name, Rights: rights})
+	if err != nil {
+		return err
+	}
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(groupKey(name), data)
+	})
+}
+
+// Group returns the group called `name`.
+func (ub *UserDatabase) Group(name string) (*Group, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.group(name)
+}
+
+func (ub *UserDatabase) group(name string) (*Group, error) {
+	group := &Group{}
+	return group, ub.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(groupKey(name))
+		if err != nil {
+			return err
+		}
+
+		data, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(data, group)
+	})
+}
+
+// Groups returns all groups.
+func (ub *UserDatabase) Groups() ([]Group, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	groups := []Group{}
+	return groups, ub.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.IteratorOptions{})
+		defer iter.Close()
+
+		prefix := []byte(groupKeyPrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			data, err := iter.Item().Value()
+			if err != nil {
+				return err
+			}
+
+			group := Group{}
+			if err := json.Unmarshal(data, &group); err != nil {
+				return err
+			}
+
+			groups = append(groups, group)
+		}
+
+		return nil
+	})
+}
+
+// RemoveGroup removes the group called `name`.
+// Users that are still in the group simply lose its rights.
+func (ub *UserDatabase) RemoveGroup(name string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(groupKey(name)); err != nil {
+			return err
+		}
+
+		return txn.Delete(groupKey(name))
+	})
+}
+
+// EffectiveRights returns the concrete rights of `user`, including
+// the ones of its groups. Groups that do not exist are ignored.
+func (ub *UserDatabase) EffectiveRights(user User) ([]string, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	granted := append([]string{}, user.Rights...)
+	for _, name := range user.Groups {
+		group, err := ub.group(name)
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		granted = append(granted, group.Rights...)
+	}
+
+	return ExpandRights(granted), nil
+}
+
+// HasRight checks if `user` was granted `right` in any way.
+func (ub *UserDatabase) HasRight(user User, right string) bool {
+	rights, err := ub.EffectiveRights(user)
+	if err != nil {
+		return false
+	}
+
+	for _, effective := range rights {
+		if effective == right {
+			return true
+		}
+	}
+
+	return false
+}
+
+// migrateRights updates the rights of users created with an older rights model.
+//
+// Version 1 added fs.share, which was part of fs.edit before, and admin.users,
+// which is given to users that had all rights before.
+func (ub *UserDatabase) migrateRights() error {
+	versionKey := []byte(metaKeyPrefix + "rights-version")
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		if item, err := txn.Get(versionKey); err == nil {
+			data, err := item.Value()
+			if err != nil {
+				return err
+			}
+
+			if string(data) == fmt.Sprintf("%d", rightsVersion) {
+				return nil
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		iter := txn.NewIterator(badger.IteratorOptions{})
+		updates := map[string][]byte{}
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			if isReservedKey(iter.Item().Key()) {
+				continue
+			}
+
+			data, err := iter.Item().Value()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			user, err := unmarshalUser(data)
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			if !migrateUserRights(user) {
+				continue
+			}
+
+			newData, err := marshalUser(user)
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			updates[string(iter.Item().Key())] = newData
+		}
+
+		iter.Close()
+
+		for key, data := range updates {
+			if err := txn.Set([]byte(key), data); err != nil {
+				return err
+			}
+		}
+
+		return txn.Set(versionKey, []byte(fmt.Sprintf("%d", rightsVersion)))
+	})
+}
+
+// migrateUserRights adds the rights `user` had implicitly before version 1.
+// It returns true if the user was changed.
+func migrateUserRights(user *User) bool {
+	has := make(map[string]bool)
+	for _, right := range user.Rights {
+		has[right] = true
+	}
+
+	added := false
+	if has[RightFsEdit] && !has[RightFsShare] {
+		user.Rights = append(user.Rights, RightFsShare)
+		added = true
+	}
+
+	wasAdmin := has[RightFsEdit] && has[RightRemotesEdit] && has[RightUsersView]
+	if wasAdmin && !has[RightAdminUsers] {
+		user.Rights = append(user.Rights, RightAdminUsers)
+		added = true
+	}
+
+	return added
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandRights(t *testing.T) {
+	require.Equal(t, []string{RightFsView}, ExpandRights([]string{RightFsView}))
+	require.Equal(t, []string{RightFsEdit, RightFsView}, ExpandRights([]string{RightFsEdit}))
+	require.Equal(
+		t,
+		[]string{RightDownload, RightFsEdit, RightFsShare, RightFsView},
+		ExpandRights([]string{"fs.*"}),
+	)
+
+	all := ExpandRights([]string{RightAll})
+	require.Len(t, all, len(AllRights))
+
+	require.Empty(t, ExpandRights([]string{"nope.*", "fs.nope"}))
+
+	require.True(t, IsValidRight("remotes.*"))
+	require.True(t, IsValidRight(RightAll))
+	require.False(t, IsValidRight("nope.*"))
+	require.False(t, IsValidRight("fs.nope"))
+}
+
+func TestGroups(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("ali", "ila", nil, []string{RightDownload}))
+		require.NotNil(t, db.Add("bob", "bob", nil, []string{"fs.nope"}))
+
+		require.Nil(t, db.AddGroup("editors", []string{"fs.*"}))
+		require.NotNil(t, db.AddGroup("bad", []string{"nope.*"}))
+
+		user, err := db.Get("ali")
+		require.Nil(t, err)
+		require.False(t, db.HasRight(user, RightFsEdit))
+
+		require.Nil(t, db.SetUserGroups("ali", []string{"editors", "missing"}))
+		user, err = db.Get("ali")
+		require.Nil(t, err)
+		require.Equal(t, []string{"editors", "missing"}, user.Groups)
+		require.True(t, db.HasRight(user, RightFsEdit))
+		require.True(t, db.HasRight(user, RightFsShare))
+		require.False(t, db.HasRight(user, RightRemotesView))
+
+		groups, err := db.Groups()
+		require.Nil(t, err)
+		require.Equal(t, []Group{{Name: "editors", Rights: []string{"fs.*"}}}, groups)
+
+		// Groups should not show up as users:
+		users, err := db.List()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+
+		require.Nil(t, db.RemoveGroup("editors"))
+		require.False(t, db.HasRight(user, RightFsEdit))
+		require.NotNil(t, db.RemoveGroup("editors"))
+	})
+}
+
+func TestMigrateRights(t *testing.T) {
+	tmpPath, err := ioutil.TempDir("", "brig-gw-userdb-migrate")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpPath)
+
+	userDb, err := NewUserDatabase(tmpPath)
+	require.Nil(t, err)
+
+	oldAdmin := []string{RightDownload, RightFsView, RightFsEdit, RightRemotesView, RightRemotesEdit, RightUsersView}
+	require.Nil(t, userDb.Add("admin", "admin", nil, oldAdmin))
+	require.Nil(t, userDb.Add("viewer", "viewer", nil, []string{RightFsView}))
+
+	// Pretend the database was written by a version before groups:
+	require.Nil(t, userDb.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(metaKeyPrefix + "rights-version"))
+	}))
+
+	require.Nil(t, userDb.Close())
+
+	userDb, err = NewUserDatabase(tmpPath)
+	require.Nil(t, err)
+	defer userDb.Close()
+
+	admin, err := userDb.Get("admin")
+	require.Nil(t, err)
+	require.Equal(t, append(oldAdmin, RightFsShare, RightAdminUsers), admin.Rights)
+
+	viewer, err := userDb.Get("viewer")
+	require.Nil(t, err)
+	require.Equal(t, []string{RightFsView}, viewer.Rights)
+}
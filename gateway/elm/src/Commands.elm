@@ -35,6 +35,7 @@ module Commands exposing
     , doUndelete
     , doUnpin
     , doUpload
+    , doUploadFolder
     , doWhoami
     , emptyRemote
     , emptySelf
@@ -372,6 +373,28 @@ doUpload toMsg destPath file =
 
 
 
+{-| Upload several files with their relative paths in a single request.
+The server creates all directories and makes one commit for all of them.
+-}
+doUploadFolder : (String -> Result Http.Error () -> msg) -> String -> String -> List ( String, File.File ) -> Cmd msg
+doUploadFolder toMsg destPath name files =
+    Http.request
+        { method = "POST"
+        , url = "/api/v0/upload?root=" ++ Url.percentEncode destPath
+        , headers = []
+        , body =
+            Http.multipartBody
+                (List.concatMap
+                    (\( path, file ) -> [ Http.stringPart "paths[]" path, Http.filePart "files[]" file ])
+                    files
+                )
+        , expect = Http.expectWhatever (toMsg name)
+        , timeout = Nothing
+        , tracker = Just ("upload-" ++ name)
+        }
+
+
+
 -- MKDIR
 
 
@@ -467,17 +490,21 @@ type alias WhoamiResponse =
     , isAnon : Bool
     , anonIsAllowed : Bool
     , rights : List String
+    , announcement : String
+    , maintenance : Bool
     }
 
 
 decodeWhoami : D.Decoder WhoamiResponse
 decodeWhoami =
-    D.map5 WhoamiResponse
+    D.map7 WhoamiResponse
         (D.field "user" D.string)
         (D.field "is_logged_in" D.bool)
         (D.field "is_anon" D.bool)
         (D.field "anon_is_allowed" D.bool)
         (D.field "rights" (D.list D.string))
+        (D.field "announcement" D.string)
+        (D.field "maintenance" D.bool)
 
 
 doWhoami : (Result Http.Error WhoamiResponse -> msg) -> Cmd msg
@@ -113,6 +113,8 @@ type alias Model =
     , url : Url.Url
     , loginState : LoginState
     , serverIsOnline : Bool
+    , announcement : String
+    , maintenance : Bool
     }
 
 
@@ -123,6 +125,8 @@ init _ url key =
       , url = url
       , loginState = LoginLimbo
       , serverIsOnline = True
+      , announcement = ""
+      , maintenance = False
       }
     , Cmd.batch
         [ Task.perform AdjustTimeZone Time.here
@@ -280,14 +284,18 @@ update msg model =
         GotWhoamiResp result ->
             case result of
                 Ok whoami ->
+                    let
+                        newModel =
+                            { model | announcement = whoami.announcement, maintenance = whoami.maintenance }
+                    in
                     -- Immediately hit off a list query, which will in turn populate
                     -- the list view. Take the path from the current URL.
                     case whoami.isLoggedIn of
                         True ->
-                            doInitAfterLogin model whoami.username whoami.rights whoami.isAnon whoami.anonIsAllowed
+                            doInitAfterLogin newModel whoami.username whoami.rights whoami.isAnon whoami.anonIsAllowed
 
                         False ->
-                            ( { model | loginState = LoginReady "" "" }, Cmd.none )
+                            ( { newModel | loginState = LoginReady "" "" }, Cmd.none )
 
                 Err _ ->
                     ( { model | loginState = LoginReady "" "" }, Cmd.none )
@@ -611,6 +619,26 @@ viewOfflineMarker =
         ]
 
 
+viewAnnouncement : Model -> Html Msg
+viewAnnouncement model =
+    if model.maintenance then
+        div [ class "alert alert-warning mt-3" ]
+            [ span [ class "fas fa-fw fa-tools" ] []
+            , text " The gateway is read-only during maintenance. "
+            , text model.announcement
+            ]
+
+    else if String.isEmpty model.announcement then
+        text ""
+
+    else
+        div [ class "alert alert-info mt-3" ]
+            [ span [ class "fas fa-fw fa-bullhorn" ] []
+            , text " "
+            , text model.announcement
+            ]
+
+
 viewMainContent : Model -> ViewState -> List (Html Msg)
 viewMainContent model viewState =
     [ div [ class "container-fluid" ]
@@ -629,7 +657,8 @@ viewMainContent model viewState =
                 ]
             , main_ [ class "col" ]
                 (if model.serverIsOnline then
-                    [ viewCurrentRoute model viewState
+                    [ viewAnnouncement model
+                    , viewCurrentRoute model viewState
                     , Html.map ListMsg (Ls.buildModals viewState.listState)
                     , Html.map RemotesMsg (Remotes.buildModals viewState.remoteState)
                     ]
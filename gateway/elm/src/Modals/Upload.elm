@@ -1,7 +1,8 @@
-module Modals.Upload exposing
+port module Modals.Upload exposing
     ( Model
     , Msg
     , buildButton
+    , buildFolderButton
     , newModel
     , subscriptions
     , update
@@ -42,6 +43,7 @@ type alias Model =
 
 type Msg
     = UploadSelectedFiles String (List File.File)
+    | UploadSelectedFolder String (List ( String, File.File ))
     | UploadProgress String Http.Progress
     | Uploaded String (Result Http.Error ())
     | UploadCancel String
@@ -86,6 +88,20 @@ update msg model =
             , Cmd.batch (List.map (Commands.doUpload Uploaded root) files)
             )
 
+        UploadSelectedFolder root files ->
+            case List.head files of
+                Nothing ->
+                    ( model, Cmd.none )
+
+                Just ( firstPath, _ ) ->
+                    let
+                        name =
+                            Maybe.withDefault firstPath <| List.head (String.split "/" firstPath)
+                    in
+                    ( { model | uploads = Dict.insert name 0 model.uploads }
+                    , Commands.doUploadFolder Uploaded root name files
+                    )
+
         UploadProgress path progress ->
             case progress of
                 Http.Sending p ->
@@ -139,6 +155,21 @@ filesDecoder =
     D.at [ "target", "files" ] (D.list File.decoder)
 
 
+{-| Browsers only give the relative path of a file when selecting folders.
+-}
+folderFilesDecoder : D.Decoder (List ( String, File.File ))
+folderFilesDecoder =
+    D.at [ "target", "files" ]
+        (D.list (D.map2 Tuple.pair (D.field "webkitRelativePath" D.string) File.decoder))
+
+
+{-| Files that were dropped on the page; see init.js for the traversal.
+-}
+droppedFilesDecoder : D.Decoder (List ( String, File.File ))
+droppedFilesDecoder =
+    D.list (D.map2 Tuple.pair (D.field "path" D.string) (D.field "file" File.decoder))
+
+
 buildButton : Model -> Bool -> String -> (Msg -> msg) -> Html msg
 buildButton model currIsFile currRoot toMsg =
     label
@@ -169,6 +200,37 @@ buildButton model currIsFile currRoot toMsg =
         ]
 
 
+buildFolderButton : Model -> Bool -> String -> (Msg -> msg) -> Html msg
+buildFolderButton model currIsFile currRoot toMsg =
+    label
+        [ class "btn btn-file btn-link btn-default text-left"
+        , id "action-btn"
+        , if currIsFile then
+            class "disabled"
+
+          else
+            class "btn-default"
+        ]
+        [ span [ class "fas fa-folder-plus" ] []
+        , span [ class "d-lg-inline d-none" ] [ text "\u{00A0}\u{00A0}Upload folder" ]
+        , input
+            [ type_ "file"
+            , attribute "webkitdirectory" ""
+            , multiple True
+            , on "change"
+                (D.map toMsg
+                    (D.map
+                        (UploadSelectedFolder currRoot)
+                        folderFilesDecoder
+                    )
+                )
+            , style "display" "none"
+            , disabled currIsFile
+            ]
+            []
+        ]
+
+
 clampText : String -> Int -> String
 clampText text length =
     if String.length text <= length then
@@ -254,10 +316,18 @@ viewUploadState model =
 -- SUBSCRIPTIONS
 
 
-subscriptions : Model -> Sub Msg
-subscriptions model =
+port droppedFiles : (D.Value -> msg) -> Sub msg
+
+
+subscriptions : String -> Model -> Sub Msg
+subscriptions root model =
     Sub.batch
-        [ Sub.batch
+        [ droppedFiles
+            (\value ->
+                UploadSelectedFolder root
+                    (Result.withDefault [] (D.decodeValue droppedFilesDecoder value))
+            )
+        , Sub.batch
             (List.map
                 (\p -> Http.track ("upload-" ++ p) (UploadProgress p))
                 (Dict.keys model.uploads)
@@ -243,6 +243,27 @@ viewSearchBox model =
         |> InputGroup.view
 
 
+viewArchiveButton : Model -> Commands.Commit -> Html Msg
+viewArchiveButton model commit =
+    Button.linkButton
+        [ Button.outlinePrimary
+        , Button.attrs
+            (if List.member "fs.download" model.rights then
+                [ href
+                    (Util.urlPrefixToString model.url
+                        ++ "api/v0/commit/archive?rev="
+                        ++ Url.percentEncode commit.hash
+                    )
+                , title "Download the state of this commit as .tar.gz"
+                ]
+
+             else
+                [ class "text-muted", style "opacity" "0.1" ]
+            )
+        ]
+        [ span [ class "fas fa-download" ] [] ]
+
+
 viewCommit : Model -> Commands.Commit -> ListGroup.Item Msg
 viewCommit model commit =
     ListGroup.li []
@@ -254,14 +275,16 @@ viewCommit model commit =
                 ]
                 [ span [ class "fas fa-lg fa-save text-xs-right" ] []
                 ]
-            , Grid.col [ Col.xs8, Col.textAlign Text.alignXsLeft ]
+            , Grid.col [ Col.xs7, Col.textAlign Text.alignXsLeft ]
                 [ text commit.msg
                 ]
             , Grid.col
-                [ Col.xs3
+                [ Col.xs4
                 , Col.textAlign Text.alignXsRight
                 ]
-                [ Button.button
+                [ viewArchiveButton model commit
+                , text " "
+                , Button.button
                     [ Button.outlineDanger
                     , Button.attrs
                         [ onClick <| CheckoutClicked commit.hash
@@ -1138,6 +1138,11 @@ viewActionList model =
                     (currIsFile model || not (List.member "fs.download" model.rights))
                     root
                     UploadMsg
+                , Upload.buildFolderButton
+                    model.uploadState
+                    (currIsFile model || not (List.member "fs.download" model.rights))
+                    root
+                    UploadMsg
                 , viewSidebarDownloadButton model
                 ]
             , div [ class "d-flex flex-column" ]
@@ -1200,7 +1205,8 @@ subscriptions model =
                 , Sub.map RenameMsg (Rename.subscriptions model.renameState)
                 , Sub.map MoveMsg (MoveCopy.subscriptions model.moveState)
                 , Sub.map CopyMsg (MoveCopy.subscriptions model.copyState)
-                , Sub.map UploadMsg (Upload.subscriptions model.uploadState)
+                , Sub.map UploadMsg
+                    (Upload.subscriptions (Maybe.withDefault "/" (currRoot model)) model.uploadState)
                 , Sub.map MkdirMsg (Mkdir.subscriptions model.url model.mkdirState)
                 , Sub.map RemoveMsg (Remove.subscriptions model.removeState)
                 , Sub.map ShareMsg (Share.subscriptions model.shareState)
@@ -0,0 +1,256 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// The folders of a user are its ACL: access to a folder is inherited by
+// everything below it, unless a folder is marked with noInheritPrefix.
+// This endpoint changes the ACLs of many users at once, so the UI can
+// offer a matrix of users and folders to edit permissions.
+//
+// Shares (drop links and signed urls) have no ACL of their own: they act
+// as the user that made them, and every use is checked against the current
+// folders of this user. Changing the folders here changes what the shares
+// of a user can reach, so they need no extra action.
+
+const (
+	aclActionGrant   = "grant"
+	aclActionRevoke  = "revoke"
+	aclActionInherit = "inherit"
+)
+
+// ACLChange is a single change of the folders a user may access.
+type ACLChange struct {
+	User string `json:"user"`
+	Path string `json:"path"`
+	// Action is either "grant", "revoke" or "inherit".
+	Action string `json:"action"`
+	// Inherit is only used by the "inherit" action. If false, `Path`
+	// does not inherit the access granted for one of its parents anymore.
+	Inherit bool `json:"inherit"`
+}
+
+// ACLApplyRequest is the request that can be sent to this endpoint as JSON.
+type ACLApplyRequest struct {
+	Changes []ACLChange `json:"changes"`
+	// DryRun only validates the changes and tells what they would do.
+	DryRun bool `json:"dry_run"`
+}
+
+// ACLUserResult tells how the folders of a single user changed.
+type ACLUserResult struct {
+	User    string   `json:"user"`
+	Before  []string `json:"before"`
+	After   []string `json:"after"`
+	Changed bool     `json:"changed"`
+}
+
+// ACLApplyResponse is the response sent back by this endpoint.
+type ACLApplyResponse struct {
+	Success bool            `json:"success"`
+	DryRun  bool            `json:"dry_run"`
+	Users   []ACLUserResult `json:"users"`
+}
+
+// ACLApplyHandler implements http.Handler.
+type ACLApplyHandler struct {
+	*State
+}
+
+// NewACLApplyHandler returns a new ACLApplyHandler.
+func NewACLApplyHandler(s *State) *ACLApplyHandler {
+	return &ACLApplyHandler{State: s}
+}
+
+// validateACLChanges cleans the paths of `changes` and checks them.
+// It returns the users in the order they were mentioned first.
+func validateACLChanges(changes []ACLChange) ([]string, int, string) {
+	users := []string{}
+	seenUsers := make(map[string]bool)
+	seenPaths := make(map[string]bool)
+
+	for idx := range changes {
+		change := &changes[idx]
+		if change.User == "" {
+			return nil, idx, "no user given"
+		}
+
+		if !strings.HasPrefix(change.Path, "/") {
+			return nil, idx, "path must be absolute"
+		}
+
+		change.Path = path.Clean(change.Path)
+
+		switch change.Action {
+		case aclActionGrant, aclActionRevoke:
+		case aclActionInherit:
+			if change.Path == "/" && !change.Inherit {
+				return nil, idx, "the root has nothing to inherit"
+			}
+		default:
+			return nil, idx, "unknown action: " + change.Action
+		}
+
+		// Several changes of the same folder would depend
+		// on the order they are applied in:
+		key := change.User + "\x00" + change.Path
+		if seenPaths[key] {
+			return nil, idx, "changes the same folder as an earlier change"
+		}
+
+		seenPaths[key] = true
+
+		if !seenUsers[change.User] {
+			seenUsers[change.User] = true
+			users = append(users, change.User)
+		}
+	}
+
+	return users, -1, ""
+}
+
+func removeFolder(folders []string, folder string) []string {
+	kept := []string{}
+	for _, other := range folders {
+		if other != folder {
+			kept = append(kept, other)
+		}
+	}
+
+	return kept
+}
+
+func addFolder(folders []string, folder string) []string {
+	for _, other := range folders {
+		if other == folder {
+			return folders
+		}
+	}
+
+	return append(folders, folder)
+}
+
+// applyACLChange returns the folders of a user after `change`.
+func applyACLChange(folders []string, change ACLChange) []string {
+	switch change.Action {
+	case aclActionGrant:
+		folders = removeFolder(folders, noInheritPrefix+change.Path)
+		return addFolder(folders, change.Path)
+	case aclActionRevoke:
+		return removeFolder(folders, change.Path)
+	case aclActionInherit:
+		if change.Inherit {
+			return removeFolder(folders, noInheritPrefix+change.Path)
+		}
+
+		folders = removeFolder(folders, change.Path)
+		return addFolder(folders, noInheritPrefix+change.Path)
+	}
+
+	return folders
+}
+
+// applyACLChanges applies all of `changes` that concern `user`
+// and returns what happened.
+func applyACLChanges(user *db.User, changes []ACLChange) ACLUserResult {
+	result := ACLUserResult{
+		User:   user.Name,
+		Before: append([]string{}, user.Folders...),
+	}
+
+	folders := append([]string{}, user.Folders...)
+	for _, change := range changes {
+		if change.User == user.Name {
+			folders = applyACLChange(folders, change)
+		}
+	}
+
+	result.After = folders
+	result.Changed = strings.Join(result.Before, "\x00") != strings.Join(result.After, "\x00")
+	return result
+}
+
+func auditACLChange(admin string, change ACLChange) {
+	log.WithFields(log.Fields{
+		"audit":   "acl",
+		"admin":   admin,
+		"user":    change.User,
+		"path":    change.Path,
+		"action":  change.Action,
+		"inherit": change.Inherit,
+	}).Info("gateway: acl change")
+}
+
+func (ah *ACLApplyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightAdminUsers) {
+		return
+	}
+
+	aclReq := ACLApplyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&aclReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if len(aclReq.Changes) == 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "no changes given")
+		return
+	}
+
+	users, badIdx, reason := validateACLChanges(aclReq.Changes)
+	if badIdx >= 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "change %d: %s", badIdx, reason)
+		return
+	}
+
+	for _, name := range users {
+		if _, err := ah.userDb.Get(name); err != nil {
+			jsonifyErrf(w, http.StatusNotFound, "no such user: %s", name)
+			return
+		}
+	}
+
+	results := []ACLUserResult{}
+	if aclReq.DryRun {
+		for _, name := range users {
+			user, err := ah.userDb.Get(name)
+			if err != nil {
+				jsonifyErrf(w, http.StatusInternalServerError, "failed to load user")
+				return
+			}
+
+			results = append(results, applyACLChanges(&user, aclReq.Changes))
+		}
+	} else {
+		err := ah.userDb.UpdateUsers(users, func(user *db.User) error {
+			result := applyACLChanges(user, aclReq.Changes)
+			user.Folders = result.After
+			results = append(results, result)
+			return nil
+		})
+
+		if err != nil {
+			log.Warningf("failed to apply acl changes: %v", err)
+			jsonifyErrf(w, http.StatusInternalServerError, "failed to apply changes")
+			return
+		}
+
+		admin := getUserName(ah.store, w, r)
+		for _, change := range aclReq.Changes {
+			auditACLChange(admin, change)
+		}
+	}
+
+	jsonify(w, http.StatusOK, &ACLApplyResponse{
+		Success: true,
+		DryRun:  aclReq.DryRun,
+		Users:   results,
+	})
+}
@@ -0,0 +1,131 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const aclURL = "http://localhost:5000/api/v0/acl/apply"
+
+func TestACLApply(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.userDb.Add("bob", "pw", []string{"/bob"}, nil))
+		require.Nil(t, s.userDb.Add("charlie", "pw", []string{"/"}, nil))
+
+		changes := []ACLChange{
+			{User: "bob", Path: "/public/", Action: "grant"},
+			{User: "bob", Path: "/bob", Action: "revoke"},
+			{User: "charlie", Path: "/private", Action: "inherit", Inherit: false},
+		}
+
+		// A dry run changes nothing:
+		resp := s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: changes,
+			DryRun:  true,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		aclResp := &ACLApplyResponse{}
+		mustDecodeBody(t, resp.Body, aclResp)
+		require.True(t, aclResp.DryRun)
+		require.Len(t, aclResp.Users, 2)
+		require.Equal(t, []string{"/bob"}, aclResp.Users[0].Before)
+		require.Equal(t, []string{"/public"}, aclResp.Users[0].After)
+		require.True(t, aclResp.Users[0].Changed)
+
+		bob, err := s.userDb.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/bob"}, bob.Folders)
+
+		resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: changes,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		bob, err = s.userDb.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/public"}, bob.Folders)
+
+		charlie, err := s.userDb.Get("charlie")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/", "!/private"}, charlie.Folders)
+		require.True(t, s.validatePathForUser("/other", charlie, nil, nil))
+		require.False(t, s.validatePathForUser("/private/x", charlie, nil, nil))
+
+		// Invalid changes are rejected as a whole:
+		for _, bad := range [][]ACLChange{
+			{{User: "bob", Path: "relative", Action: "grant"}},
+			{{User: "bob", Path: "/x", Action: "explode"}},
+			{{User: "bob", Path: "/", Action: "inherit"}},
+			{{User: "bob", Path: "/x", Action: "grant"}, {User: "bob", Path: "/x/", Action: "revoke"}},
+		} {
+			resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+				Changes: append([]ACLChange{{User: "charlie", Path: "/y", Action: "grant"}}, bad...),
+			})
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+
+		resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: []ACLChange{
+				{User: "charlie", Path: "/y", Action: "grant"},
+				{User: "nobody", Path: "/y", Action: "grant"},
+			},
+		})
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		charlie, err = s.userDb.Get("charlie")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/", "!/private"}, charlie.Folders)
+
+		// Inheriting again removes the mark:
+		resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: []ACLChange{{User: "charlie", Path: "/private", Action: "inherit", Inherit: true}},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		charlie, err = s.userDb.Get("charlie")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/"}, charlie.Folders)
+
+		// Only admins may edit ACLs:
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{"fs.view", "fs.edit"}))
+		resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: []ACLChange{{User: "ali", Path: "/", Action: "grant"}},
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestACLApplyRevokesShares(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/x/a.txt", bytes.NewReader([]byte("a"))))
+
+		resp := s.mustRun(t, NewSignHandler(s.State), "POST", "http://localhost:5000/api/v0/sign", &SignRequest{
+			Paths: []string{"/x/a.txt"},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		signResp := &SignResponse{}
+		mustDecodeBody(t, resp.Body, signResp)
+		require.Len(t, signResp.URLs, 1)
+
+		download := func() int {
+			rsw := httptest.NewRecorder()
+			NewGetHandler(s.State).ServeHTTP(rsw, httptest.NewRequest("GET", signResp.URLs[0].URL, nil))
+			return rsw.Result().StatusCode
+		}
+
+		require.Equal(t, http.StatusOK, download())
+
+		resp = s.mustRun(t, NewACLApplyHandler(s.State), "POST", aclURL, &ACLApplyRequest{
+			Changes: []ACLChange{{User: "ali", Path: "/x", Action: "inherit", Inherit: false}},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, http.StatusUnauthorized, download())
+	})
+}
@@ -0,0 +1,196 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultActivityLimit is used when the client did not give a limit.
+const defaultActivityLimit = 20
+
+// ActivityHandler implements http.Handler
+type ActivityHandler struct {
+	*State
+}
+
+// NewActivityHandler returns a new ActivityHandler
+func NewActivityHandler(s *State) *ActivityHandler {
+	return &ActivityHandler{State: s}
+}
+
+// ActivityRequest is the request sent to this endpoint.
+// On GET requests the same fields are read from the query string.
+type ActivityRequest struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Limit  int64  `json:"limit"`
+}
+
+// ActivityChange is a single file that changed in a commit.
+type ActivityChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// ActivityShare is a drop link that was created for a folder.
+// The token is left out on purpose.
+type ActivityShare struct {
+	Folder  string `json:"folder"`
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+// ActivityItem is either a commit (with the changes below the requested
+// path) or a share, depending on Kind.
+type ActivityItem struct {
+	Kind    string           `json:"kind"`
+	Date    int64            `json:"date"`
+	Commit  *Commit          `json:"commit,omitempty"`
+	Changes []ActivityChange `json:"changes,omitempty"`
+	Share   *ActivityShare   `json:"share,omitempty"`
+}
+
+// ActivityResponse is the data that is sent back to the client.
+type ActivityResponse struct {
+	Success bool           `json:"success"`
+	Items   []ActivityItem `json:"items"`
+}
+
+func parseActivityRequest(r *http.Request) (*ActivityRequest, error) {
+	actReq := &ActivityRequest{}
+	if r.Method != "GET" {
+		return actReq, json.NewDecoder(r.Body).Decode(actReq)
+	}
+
+	query := r.URL.Query()
+	actReq.Path = query.Get("path")
+
+	for _, param := range []struct {
+		name string
+		dst  *int64
+	}{{"offset", &actReq.Offset}, {"limit", &actReq.Limit}} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			*param.dst = parsed
+		}
+	}
+
+	return actReq, nil
+}
+
+func isBelow(root, path string) bool {
+	return root == "/" || path == root || strings.HasPrefix(path, root+"/")
+}
+
+func (ah *ActivityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	actReq, err := parseActivityRequest(r)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if actReq.Offset < 0 || actReq.Limit < 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "limits may not be negative")
+		return
+	}
+
+	if actReq.Limit == 0 {
+		actReq.Limit = defaultActivityLimit
+	}
+
+	root := prefixRoot(actReq.Path)
+	if !ah.validatePath(root, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	// Fetch everything up to the requested page, since shares
+	// are mixed in by date and shift the commits around.
+	acts, err := ah.fs.Activity(root, 0, int(actReq.Offset+actReq.Limit))
+	if err != nil {
+		log.Debugf("failed to query activity of %s: %v", root, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to query: %v", err)
+		return
+	}
+
+	items := []ActivityItem{}
+	for _, act := range acts {
+		items = append(items, activityToItem(act))
+	}
+
+	links, err := ah.userDb.ListDropLinks()
+	if err != nil {
+		log.Warningf("failed to list drop links: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to list drop links")
+		return
+	}
+
+	for _, link := range links {
+		if !isBelow(root, link.Folder) {
+			continue
+		}
+
+		share := &ActivityShare{Folder: link.Folder, Owner: link.Owner}
+		if !link.Expires.IsZero() {
+			share.Expires = link.Expires.Unix() * 1000
+		}
+
+		items = append(items, ActivityItem{
+			Kind:  "share",
+			Date:  link.CreatedAt.Unix() * 1000,
+			Share: share,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date > items[j].Date
+	})
+
+	if actReq.Offset >= int64(len(items)) {
+		items = []ActivityItem{}
+	} else {
+		items = items[actReq.Offset:]
+	}
+
+	if int64(len(items)) > actReq.Limit {
+		items = items[:actReq.Limit]
+	}
+
+	jsonify(w, http.StatusOK, &ActivityResponse{
+		Success: true,
+		Items:   items,
+	})
+}
+
+func activityToItem(act catfs.Activity) ActivityItem {
+	cmt := toExternalCommit(act.Commit)
+	changes := []ActivityChange{}
+	for _, change := range act.Changes {
+		changes = append(changes, ActivityChange{
+			Path:   change.Path,
+			Change: change.Change,
+		})
+	}
+
+	return ActivityItem{
+		Kind:    "commit",
+		Date:    cmt.Date,
+		Commit:  &cmt,
+		Changes: changes,
+	}
+}
@@ -0,0 +1,86 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityEndpointSuccess(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/docs/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/other/y", bytes.NewReader([]byte("world"))))
+		require.Nil(t, s.fs.MakeCommit("add"))
+		require.Nil(t, s.fs.Stage("/docs/x", bytes.NewReader([]byte("HELLO"))))
+		require.Nil(t, s.fs.MakeCommit("modify"))
+
+		_, err := s.userDb.AddDropLink("ali", "/docs", 0, nil, 0)
+		require.Nil(t, err)
+
+		resp := s.mustRun(
+			t,
+			NewActivityHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/activity",
+			&ActivityRequest{Path: "/docs"},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data := &ActivityResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.True(t, data.Success)
+		require.Len(t, data.Items, 3)
+
+		kinds := []string{}
+		for _, item := range data.Items {
+			kinds = append(kinds, item.Kind)
+		}
+
+		require.ElementsMatch(t, []string{"share", "commit", "commit"}, kinds)
+		for _, item := range data.Items {
+			switch item.Kind {
+			case "commit":
+				require.Equal(t, []ActivityChange{{Path: "/docs/x", Change: map[string]string{
+					"add":    "added",
+					"modify": "modified",
+				}[item.Commit.Msg]}}, item.Changes)
+			case "share":
+				require.Equal(t, "/docs", item.Share.Folder)
+			}
+		}
+
+		// The same with GET and pagination:
+		resp = s.mustRun(
+			t,
+			NewActivityHandler(s.State),
+			"GET",
+			"http://localhost:5000/api/v0/activity?path=/docs&offset=2&limit=5",
+			nil,
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data = &ActivityResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.Len(t, data.Items, 1)
+	})
+}
+
+func TestActivityEndpointForbidden(t *testing.T) {
+	withState(t, func(s *testState) {
+		s.mustChangeFolders(t, "/public")
+
+		resp := s.mustRun(
+			t,
+			NewActivityHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/activity",
+			&ActivityRequest{Path: "/docs"},
+		)
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -0,0 +1,249 @@
+package endpoints
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// assetHashLen is how many hex characters of the content hash
+	// are used for cache busting and as etag.
+	assetHashLen = 16
+
+	// Assets with a matching version never change, so they may be cached forever.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+var errNotAFile = errors.New("not a file")
+
+// UIPreloadAssets are the assets every page of the UI needs.
+// They are pushed along with index.html if HTTP/2 is used.
+var UIPreloadAssets = []string{
+	"/css/bootstrap.min.css",
+	"/css/fontawesome.css",
+	"/css/default.css",
+	"/js/jquery-3.3.1.slim.min.js",
+	"/js/popper.min.js",
+	"/js/bootstrap.min.js",
+	"/js/app.js",
+	"/js/smoothscroll.js",
+	"/js/init.js",
+}
+
+// asset is a single static file along with its compressed versions.
+type asset struct {
+	hash    string
+	modTime time.Time
+	data    []byte
+	gzip    []byte
+	brotli  []byte
+}
+
+// AssetHandler serves the static files of the web UI.
+//
+// Compressed versions are served if the client accepts them.
+// A brotli or gzip version can be bundled next to the file
+// (e.g. app.js.br and app.js.gz); otherwise gzip is done once in memory.
+// Files are addressed as /path?v=<hash>, which lets the browser cache them
+// forever since the url changes with the content.
+type AssetHandler struct {
+	fs    http.FileSystem
+	debug bool
+
+	mu    sync.Mutex
+	cache map[string]*asset
+}
+
+// NewAssetHandler returns a new AssetHandler that serves from `fs`.
+// In debug mode nothing is cached, so changes to the files are visible directly.
+func NewAssetHandler(fs http.FileSystem, debug bool) *AssetHandler {
+	return &AssetHandler{
+		fs:    fs,
+		debug: debug,
+		cache: make(map[string]*asset),
+	}
+}
+
+func (ah *AssetHandler) readFile(name string) ([]byte, time.Time, error) {
+	fd, err := ah.fs.Open(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if info.IsDir() {
+		return nil, time.Time{}, errNotAFile
+	}
+
+	data, err := ioutil.ReadAll(fd)
+	return data, info.ModTime(), err
+}
+
+func isPrecompressed(name string) bool {
+	switch path.Ext(name) {
+	case ".woff", ".woff2", ".png", ".jpg", ".gif", ".gz", ".br":
+		return true
+	}
+
+	return false
+}
+
+func (ah *AssetHandler) load(name string) (*asset, error) {
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+
+	if ast, ok := ah.cache[name]; ok && !ah.debug {
+		return ast, nil
+	}
+
+	data, modTime, err := ah.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	ast := &asset{
+		hash:    hex.EncodeToString(sum[:])[:assetHashLen],
+		modTime: modTime,
+		data:    data,
+	}
+
+	if !isPrecompressed(name) {
+		if brotli, _, err := ah.readFile(name + ".br"); err == nil {
+			ast.brotli = brotli
+		}
+
+		if gz, _, err := ah.readFile(name + ".gz"); err == nil {
+			ast.gzip = gz
+		} else {
+			buf := &bytes.Buffer{}
+			zw, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+			zw.Write(data)
+			zw.Close()
+
+			// Not worth it for tiny files:
+			if buf.Len() < len(data) {
+				ast.gzip = buf.Bytes()
+			}
+		}
+	}
+
+	ah.cache[name] = ast
+	return ast, nil
+}
+
+// URL returns the cache busting url of the asset at `name`.
+// If the asset does not exist, `name` is returned unchanged.
+func (ah *AssetHandler) URL(name string) string {
+	ast, err := ah.load(name)
+	if err != nil || ah.debug {
+		return name
+	}
+
+	return name + "?v=" + ast.hash
+}
+
+// acceptsEncoding checks if `encoding` is listed in the Accept-Encoding
+// header and was not explicitly forbidden with q=0.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		split := strings.Split(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(split[0]) != encoding {
+			continue
+		}
+
+		for _, param := range split[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func (ah *AssetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(name, "/") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	ast, err := ah.load(name)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set("Vary", "Accept-Encoding")
+
+	switch {
+	case ah.debug:
+		hdr.Set("Cache-Control", "no-store")
+	case r.URL.Query().Get("v") == ast.hash:
+		hdr.Set("Cache-Control", immutableCacheControl)
+	default:
+		hdr.Set("Cache-Control", "no-cache")
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(ast.data)
+	}
+
+	hdr.Set("Content-Type", mimeType)
+
+	// Every encoding needs its own etag, since the bytes differ:
+	data, etag := ast.data, ast.hash
+	switch {
+	case ast.brotli != nil && acceptsEncoding(r, "br"):
+		hdr.Set("Content-Encoding", "br")
+		data, etag = ast.brotli, etag+"-br"
+	case ast.gzip != nil && acceptsEncoding(r, "gzip"):
+		hdr.Set("Content-Encoding", "gzip")
+		data, etag = ast.gzip, etag+"-gz"
+	}
+
+	hdr.Set("ETag", `"`+etag+`"`)
+
+	// ServeContent also takes care of If-None-Match and If-Modified-Since:
+	http.ServeContent(w, r, name, ast.modTime, bytes.NewReader(data))
+}
+
+// pushAssets pushes `names` to the client if it speaks HTTP/2.
+// Errors are ignored, the browser will just fetch them later.
+func pushAssets(w http.ResponseWriter, assets *AssetHandler, names []string) {
+	pusher, ok := w.(http.Pusher)
+	if !ok || assets == nil {
+		return
+	}
+
+	for _, name := range names {
+		if err := pusher.Push(assets.URL(name), nil); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withAssets(t *testing.T, fn func(ah *AssetHandler, dir string)) {
+	dir, err := ioutil.TempDir("", "brig-assets-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	js := strings.Repeat("console.log('hello world');\n", 100)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte(js), 0644))
+	fn(NewAssetHandler(http.Dir(dir), false), dir)
+}
+
+func getAsset(ah *AssetHandler, url, encoding string) *http.Response {
+	req := httptest.NewRequest("GET", url, nil)
+	if encoding != "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+
+	rsw := httptest.NewRecorder()
+	ah.ServeHTTP(rsw, req)
+	return rsw.Result()
+}
+
+func TestAssetsCacheBusting(t *testing.T) {
+	withAssets(t, func(ah *AssetHandler, dir string) {
+		url := ah.URL("/app.js")
+		require.True(t, strings.HasPrefix(url, "/app.js?v="))
+		require.Equal(t, "/missing.js", ah.URL("/missing.js"))
+
+		resp := getAsset(ah, url, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, immutableCacheControl, resp.Header.Get("Cache-Control"))
+		require.Contains(t, resp.Header.Get("Content-Type"), "javascript")
+
+		resp = getAsset(ah, "/app.js", "")
+		require.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+		req := httptest.NewRequest("GET", "/app.js", nil)
+		req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+		rsw := httptest.NewRecorder()
+		ah.ServeHTTP(rsw, req)
+		require.Equal(t, http.StatusNotModified, rsw.Code)
+
+		require.Equal(t, http.StatusNotFound, getAsset(ah, "/missing.js", "").StatusCode)
+	})
+}
+
+func TestAssetsCompression(t *testing.T) {
+	withAssets(t, func(ah *AssetHandler, dir string) {
+		resp := getAsset(ah, "/app.js", "gzip, deflate")
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+
+		zr, err := gzip.NewReader(resp.Body)
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(zr)
+		require.Nil(t, err)
+		require.Contains(t, string(data), "hello world")
+
+		resp = getAsset(ah, "/app.js", "gzip;q=0")
+		require.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	})
+}
+
+func TestAssetsPrecompressedBrotli(t *testing.T) {
+	withAssets(t, func(ah *AssetHandler, dir string) {
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "app.js.br"), []byte("fake brotli"), 0644))
+
+		resp := getAsset(ah, "/app.js", "gzip, br")
+		require.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Equal(t, "fake brotli", string(data))
+	})
+}
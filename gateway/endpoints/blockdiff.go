@@ -0,0 +1,92 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlockDiffHandler implements http.Handler
+type BlockDiffHandler struct {
+	*State
+}
+
+// NewBlockDiffHandler returns a new BlockDiffHandler
+func NewBlockDiffHandler(s *State) *BlockDiffHandler {
+	return &BlockDiffHandler{State: s}
+}
+
+// BlockDiffRequest is the request sent to this endpoint.
+// If OldPath is empty, Path is used for both versions.
+type BlockDiffRequest struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path"`
+	OldRev  string `json:"old_rev"`
+	NewRev  string `json:"new_rev"`
+}
+
+// ByteRange is a range of changed bytes.
+type ByteRange struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+}
+
+// BlockDiffResponse is the data that is sent back to the client.
+type BlockDiffResponse struct {
+	Success        bool        `json:"success"`
+	OldSize        uint64      `json:"old_size"`
+	NewSize        uint64      `json:"new_size"`
+	BlockSize      uint64      `json:"block_size"`
+	Changed        []ByteRange `json:"changed"`
+	ChangedBytes   uint64      `json:"changed_bytes"`
+	UnchangedBytes uint64      `json:"unchanged_bytes"`
+	UnchangedRatio float64     `json:"unchanged_ratio"`
+}
+
+func (bh *BlockDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	diffReq := BlockDiffRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&diffReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if diffReq.OldPath == "" {
+		diffReq.OldPath = diffReq.Path
+	}
+
+	newPath := prefixRoot(diffReq.Path)
+	oldPath := prefixRoot(diffReq.OldPath)
+	if !bh.validatePath(newPath, w, r) || !bh.validatePath(oldPath, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	diff, err := bh.fs.BlockDiff(diffReq.OldRev, oldPath, diffReq.NewRev, newPath)
+	if err != nil {
+		log.Debugf("failed to block diff %s: %v", newPath, err)
+		jsonifyErrf(w, http.StatusBadRequest, "failed to diff")
+		return
+	}
+
+	changed := []ByteRange{}
+	for _, rng := range diff.Changed {
+		changed = append(changed, ByteRange{Offset: rng.Offset, Length: rng.Length})
+	}
+
+	jsonify(w, http.StatusOK, &BlockDiffResponse{
+		Success:        true,
+		OldSize:        diff.OldSize,
+		NewSize:        diff.NewSize,
+		BlockSize:      diff.BlockSize,
+		Changed:        changed,
+		ChangedBytes:   diff.ChangedBytes,
+		UnchangedBytes: diff.UnchangedBytes,
+		UnchangedRatio: diff.UnchangedRatio(),
+	})
+}
@@ -0,0 +1,64 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockDiffEndpointSuccess(t *testing.T) {
+	withState(t, func(s *testState) {
+		data := testutil.CreateRandomDummyBuf(2*catfs.DiffBlockSize, 42)
+		require.Nil(t, s.fs.Stage("/x", bytes.NewReader(data)))
+		require.Nil(t, s.fs.MakeCommit("first"))
+
+		data[0]++
+		require.Nil(t, s.fs.Stage("/x", bytes.NewReader(data)))
+		require.Nil(t, s.fs.MakeCommit("second"))
+
+		resp := s.mustRun(
+			t,
+			NewBlockDiffHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/blockdiff",
+			&BlockDiffRequest{
+				Path:   "/x",
+				OldRev: "head^",
+				NewRev: "head",
+			},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		diff := &BlockDiffResponse{}
+		mustDecodeBody(t, resp.Body, &diff)
+		require.True(t, diff.Success)
+		require.Equal(t, []ByteRange{{Offset: 0, Length: catfs.DiffBlockSize}}, diff.Changed)
+		require.Equal(t, 0.5, diff.UnchangedRatio)
+	})
+}
+
+func TestBlockDiffEndpointForbidden(t *testing.T) {
+	withState(t, func(s *testState) {
+		s.mustChangeFolders(t, "/public")
+
+		resp := s.mustRun(
+			t,
+			NewBlockDiffHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/blockdiff",
+			&BlockDiffRequest{
+				Path:    "/public/x",
+				OldPath: "/x",
+				OldRev:  "head^",
+				NewRev:  "head",
+			},
+		)
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -0,0 +1,85 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// CommitArchiveHandler implements http.Handler
+type CommitArchiveHandler struct {
+	*State
+}
+
+// NewCommitArchiveHandler returns a new CommitArchiveHandler
+func NewCommitArchiveHandler(s *State) *CommitArchiveHandler {
+	return &CommitArchiveHandler{State: s}
+}
+
+// leadsToFolder checks if one of `folders` lies below the directory `dirPath`.
+func leadsToFolder(dirPath string, folders []string) bool {
+	prefix := strings.TrimSuffix(dirPath, "/") + "/"
+	for folder, isValid := range buildFolderCache(folders) {
+		if isValid && strings.HasPrefix(folder, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ah *CommitArchiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView, db.RightDownload) {
+		return
+	}
+
+	user, ok := r.Context().Value(dbUserKey("brig.db_user")).(db.User)
+	if !ok {
+		jsonifyErrf(w, http.StatusInternalServerError, "could not cast user")
+		return
+	}
+
+	rev := r.URL.Query().Get("rev")
+	if rev == "" {
+		rev = "head"
+	}
+
+	cmt, err := ah.fs.CommitInfo(rev)
+	if err != nil {
+		log.Warningf("commit archive: failed to resolve %s: %v", rev, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to resolve commit")
+		return
+	}
+
+	if cmt == nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such commit")
+		return
+	}
+
+	// Only pack what the user could see in the UI. Directories on the
+	// way to one of the user's folders are entered, but not packed.
+	filter := func(info *catfs.StatInfo) bool {
+		if ah.validatePathForUser(info.Path, user, w, r) {
+			return true
+		}
+
+		return info.IsDir && leadsToFolder(info.Path, user.Folders)
+	}
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "application/gzip")
+	hdr.Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"brig-%s.tar.gz\"", cmt.Hash.ShortB58()),
+	)
+
+	// The archive is streamed; once the first bytes went out,
+	// there is no way to tell the client about an error anymore.
+	if err := ah.fs.Archive(cmt.Hash.B58String(), "/", catfs.ArchiveTarGz, w, filter); err != nil {
+		log.Errorf("commit archive: failed to stream %s: %v", rev, err)
+	}
+}
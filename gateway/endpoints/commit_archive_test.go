@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readTarGz(t *testing.T, body io.Reader) map[string]string {
+	gr, err := gzip.NewReader(body)
+	require.Nil(t, err)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(tr)
+		require.Nil(t, err)
+		files[hdr.Name] = string(data)
+	}
+
+	return files
+}
+
+func TestCommitArchiveEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		s.mustChangeFolders(t, "/docs")
+		require.Nil(t, s.fs.Stage("/docs/x", bytes.NewReader([]byte("old"))))
+		require.Nil(t, s.fs.Stage("/secret/y", bytes.NewReader([]byte("hidden"))))
+		require.Nil(t, s.fs.MakeCommit("first"))
+
+		require.Nil(t, s.fs.Stage("/docs/x", bytes.NewReader([]byte("new"))))
+		require.Nil(t, s.fs.MakeCommit("second"))
+
+		resp := s.mustRun(
+			t,
+			NewCommitArchiveHandler(s.State),
+			"GET",
+			"http://localhost:5000/api/v0/commit/archive?rev=head^",
+			nil,
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+		require.Equal(t, map[string]string{"docs/x": "old"}, readTarGz(t, resp.Body))
+
+		resp = s.mustRun(
+			t,
+			NewCommitArchiveHandler(s.State),
+			"GET",
+			"http://localhost:5000/api/v0/commit/archive?rev=nope",
+			nil,
+		)
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,63 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// CountersHandler implements http.Handler
+type CountersHandler struct {
+	*State
+}
+
+// NewCountersHandler returns a new CountersHandler
+func NewCountersHandler(s *State) *CountersHandler {
+	return &CountersHandler{State: s}
+}
+
+// CountersRequest is the request sent to this endpoint.
+type CountersRequest struct {
+	Path string `json:"path"`
+}
+
+// CountersResponse is the data that is sent back to the client.
+type CountersResponse struct {
+	Success   bool   `json:"success"`
+	Downloads uint64 `json:"downloads"`
+	Views     uint64 `json:"views"`
+}
+
+func (ch *CountersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	cntReq := CountersRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&cntReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	path := prefixRoot(cntReq.Path)
+	if !ch.validatePath(path, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	counters, err := ch.fs.Counters(path)
+	if err != nil {
+		log.Debugf("failed to read counters of %s: %v", path, err)
+		jsonifyErrf(w, http.StatusBadRequest, "failed to read counters")
+		return
+	}
+
+	jsonify(w, http.StatusOK, &CountersResponse{
+		Success:   true,
+		Downloads: counters[catfs.CounterDownloads],
+		Views:     counters[catfs.CounterViews],
+	})
+}
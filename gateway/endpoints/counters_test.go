@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountersEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/file", bytes.NewReader([]byte("HelloWorld"))))
+
+		for _, url := range []string{
+			"http://localhost:5000/get/file",
+			"http://localhost:5000/get/file?direct=yes",
+			"http://localhost:5000/get/file?direct=yes",
+		} {
+			resp := s.mustRun(t, NewGetHandler(s.State), "GET", url, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		resp := s.mustRun(
+			t,
+			NewCountersHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/counters",
+			&CountersRequest{
+				Path: "/file",
+			},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data := &CountersResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.True(t, data.Success)
+		require.Equal(t, uint64(1), data.Views)
+		require.Equal(t, uint64(2), data.Downloads)
+	})
+}
@@ -0,0 +1,508 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gorilla/csrf"
+	"github.com/sahib/brig/catfs"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// Drop links are upload-only links: everybody that knows the link can
+// upload files into the folder of the link, but nobody can see what's
+// in there. Existing files are never overwritten.
+
+const (
+	// dropMaxFiles is the maximum number of files per upload.
+	dropMaxFiles = 100
+
+	// dropEvent is sent over the events bus when something
+	// was uploaded over a drop link.
+	dropEvent = "drop"
+)
+
+// DropCreateHandler implements http.Handler.
+type DropCreateHandler struct {
+	*State
+}
+
+// NewDropCreateHandler returns a new DropCreateHandler.
+func NewDropCreateHandler(s *State) *DropCreateHandler {
+	return &DropCreateHandler{State: s}
+}
+
+// DropCreateRequest is the request that can be sent to this endpoint as JSON.
+type DropCreateRequest struct {
+	// Folder is the directory where uploads should go to.
+	Folder string `json:"folder"`
+	// MaxSize is the maximum size of a single file (0 for no limit).
+	MaxSize int64 `json:"max_size"`
+	// Types are the allowed extensions or mime types (empty for all).
+	Types []string `json:"types"`
+	// ExpiresIn is the number of seconds the link is valid (0 for ever).
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// DropCreateResponse is the response sent back by this endpoint.
+type DropCreateResponse struct {
+	Success bool        `json:"success"`
+	Link    db.DropLink `json:"link"`
+}
+
+func (dh *DropCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsShare) {
+		return
+	}
+
+	createReq := DropCreateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if createReq.MaxSize < 0 || createReq.ExpiresIn < 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "limits may not be negative")
+		return
+	}
+
+	folder := prefixRoot(path.Clean(createReq.Folder))
+	if !dh.validatePath(folder, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	info, err := dh.fs.Stat(folder)
+	if err != nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such directory")
+		return
+	}
+
+	if !info.IsDir {
+		jsonifyErrf(w, http.StatusBadRequest, "drop links need a directory")
+		return
+	}
+
+	link, err := dh.userDb.AddDropLink(
+		getUserName(dh.store, w, r),
+		folder,
+		createReq.MaxSize,
+		createReq.Types,
+		time.Duration(createReq.ExpiresIn)*time.Second,
+	)
+
+	if err != nil {
+		log.Warningf("failed to create drop link: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to create drop link")
+		return
+	}
+
+	jsonify(w, http.StatusOK, &DropCreateResponse{
+		Success: true,
+		Link:    *link,
+	})
+}
+
+// DropListHandler implements http.Handler.
+type DropListHandler struct {
+	*State
+}
+
+// NewDropListHandler returns a new DropListHandler.
+func NewDropListHandler(s *State) *DropListHandler {
+	return &DropListHandler{State: s}
+}
+
+// DropListResponse is the response sent back by this endpoint.
+type DropListResponse struct {
+	Success bool          `json:"success"`
+	Links   []db.DropLink `json:"links"`
+}
+
+func (dh *DropListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsShare) {
+		return
+	}
+
+	links, err := dh.userDb.ListDropLinks()
+	if err != nil {
+		log.Warningf("failed to list drop links: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to list drop links")
+		return
+	}
+
+	// Only show links for folders the user has access to:
+	visible := []db.DropLink{}
+	for _, link := range links {
+		if dh.validatePath(link.Folder, w, r) {
+			visible = append(visible, link)
+		}
+	}
+
+	jsonify(w, http.StatusOK, &DropListResponse{
+		Success: true,
+		Links:   visible,
+	})
+}
+
+// DropRemoveHandler implements http.Handler.
+type DropRemoveHandler struct {
+	*State
+}
+
+// NewDropRemoveHandler returns a new DropRemoveHandler.
+func NewDropRemoveHandler(s *State) *DropRemoveHandler {
+	return &DropRemoveHandler{State: s}
+}
+
+// DropRemoveRequest is the request that can be sent to this endpoint as JSON.
+type DropRemoveRequest struct {
+	Token string `json:"token"`
+}
+
+func (dh *DropRemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsShare) {
+		return
+	}
+
+	removeReq := DropRemoveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&removeReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	link, err := dh.userDb.GetDropLink(removeReq.Token)
+	if err != nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such drop link")
+		return
+	}
+
+	if !dh.validatePath(link.Folder, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	if err := dh.userDb.RemoveDropLink(link.Token); err != nil {
+		log.Warningf("failed to remove drop link: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to remove drop link")
+		return
+	}
+
+	jsonifySuccess(w)
+}
+
+// DropHandler implements http.Handler.
+// It serves the (anonymous) upload page of a drop link on GET
+// and takes the uploaded files on POST.
+type DropHandler struct {
+	*State
+}
+
+// NewDropHandler returns a new DropHandler.
+func NewDropHandler(s *State) *DropHandler {
+	return &DropHandler{State: s}
+}
+
+var dropTemplate = template.Must(template.New("drop").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>brig: upload files</title>
+  </head>
+  <body>
+    <h1>Upload files</h1>
+    {{ if .Message }}<p>{{ .Message }}</p>{{ end }}
+    {{ if .Link }}
+    <p>The files you upload here are sent to {{ .Link.Owner }}. You will not be able to see them afterwards.</p>
+    {{ if .Link.MaxSize }}<p>Maximum size per file: {{ .MaxSize }}</p>{{ end }}
+    {{ if .Link.Types }}<p>Allowed types: {{ range $idx, $typ := .Link.Types }}{{ if $idx }}, {{ end }}{{ $typ }}{{ end }}</p>{{ end }}
+    <form method="POST" enctype="multipart/form-data">
+      {{ .CSRFField }}
+      <input type="file" name="files[]" multiple>
+      <input type="submit" value="Upload">
+    </form>
+    {{ end }}
+  </body>
+</html>
+`))
+
+func (dh *DropHandler) render(w http.ResponseWriter, r *http.Request, status int, link *db.DropLink, msg string) {
+	data := map[string]interface{}{
+		"Message":   msg,
+		"CSRFField": csrf.TemplateField(r),
+	}
+
+	if link != nil {
+		data["Link"] = link
+		data["MaxSize"] = humanize.Bytes(uint64(link.MaxSize))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := dropTemplate.Execute(w, data); err != nil {
+		log.Warningf("failed to render drop page: %v", err)
+	}
+}
+
+// lookupLink returns the drop link the request refers to, if it is usable.
+func (dh *DropHandler) lookupLink(w http.ResponseWriter, r *http.Request) *db.DropLink {
+	token := path.Base(r.URL.Path)
+	link, err := dh.userDb.GetDropLink(token)
+	if err != nil {
+		dh.render(w, r, http.StatusNotFound, nil, "This link does not exist.")
+		return nil
+	}
+
+	if link.IsExpired() {
+		dh.render(w, r, http.StatusGone, nil, "This link has expired.")
+		return nil
+	}
+
+	// The link only works as long as its owner could upload there and share it:
+	owner, err := dh.userDb.Get(link.Owner)
+	if err != nil || !dh.ownerMayShare(owner) || !dh.validatePathForUser(link.Folder, owner, w, r) {
+		dh.render(w, r, http.StatusGone, nil, "This link is not valid anymore.")
+		return nil
+	}
+
+	return link
+}
+
+func (dh *DropHandler) ownerMayShare(owner db.User) bool {
+	return dh.userDb.HasRight(owner, db.RightFsEdit) && dh.userDb.HasRight(owner, db.RightFsShare)
+}
+
+func (dh *DropHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	link := dh.lookupLink(w, r)
+	if link == nil {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		dh.render(w, r, http.StatusOK, link, "")
+		return
+	}
+
+	// Stop reading early if somebody sends way too much data.
+	// Anybody can post here, so there is always a limit.
+	r.Body = http.MaxBytesReader(w, r.Body, dh.maxRequestSize(link))
+
+	if err := r.ParseMultipartForm(1 * 1024 * 1024); err != nil {
+		log.Debugf("drop: bad multipart form: %v", err)
+		dh.render(w, r, http.StatusBadRequest, link, "The upload could not be read or was too big.")
+		return
+	}
+
+	// Remove the cached files in /tmp
+	defer r.MultipartForm.RemoveAll()
+
+	headers := []*multipart.FileHeader{}
+	for _, fieldHeaders := range r.MultipartForm.File {
+		headers = append(headers, fieldHeaders...)
+	}
+
+	if len(headers) == 0 {
+		dh.render(w, r, http.StatusBadRequest, link, "No files were uploaded.")
+		return
+	}
+
+	if len(headers) > dropMaxFiles {
+		dh.render(w, r, http.StatusBadRequest, link, fmt.Sprintf("Only %d files can be uploaded at once.", dropMaxFiles))
+		return
+	}
+
+	// Check all files before staging anything:
+	for _, header := range headers {
+		if _, err := dropFileName(header.Filename); err != nil {
+			dh.render(w, r, http.StatusBadRequest, link, fmt.Sprintf("»%s« can not be stored.", header.Filename))
+			return
+		}
+
+		if link.MaxSize > 0 && header.Size > link.MaxSize {
+			dh.render(w, r, http.StatusRequestEntityTooLarge, link, fmt.Sprintf("»%s« is too big.", header.Filename))
+			return
+		}
+
+		mimeType, err := sniffMimeType(header)
+		if err != nil || !link.AllowsType(header.Filename, mimeType) {
+			dh.render(w, r, http.StatusUnsupportedMediaType, link, fmt.Sprintf("»%s« has a type that is not allowed.", header.Filename))
+			return
+		}
+	}
+
+	items := []scanItem{}
+	for _, header := range headers {
+		items = append(items, scanItem{name: path.Join(link.Folder, header.Filename), header: header})
+	}
+
+	if rejected, status, reason := dh.checkUploadPolicies(items); rejected != nil {
+		dh.render(w, r, status, link, fmt.Sprintf("»%s« %s.", rejected.header.Filename, reason))
+		return
+	}
+
+	if rejected, reason := dh.scanUploads(r, "drop:"+link.Owner, items); rejected != nil {
+		dh.render(w, r, http.StatusUnprocessableEntity, link, fmt.Sprintf("»%s« %s.", rejected.header.Filename, reason))
+		return
+	}
+
+	// If a file fails, the ones before it are still committed;
+	// the uploader is told which files did not make it.
+	status, failed := http.StatusOK, ""
+	paths := []string{}
+	for _, header := range headers {
+		dstPath, err := dh.stageFree(link.Folder, header)
+		if err != nil {
+			log.Warningf("drop: could not stage %s: %v", header.Filename, err)
+			status, failed = http.StatusInternalServerError, header.Filename
+			break
+		}
+
+		paths = append(paths, dstPath)
+	}
+
+	if len(paths) == 0 {
+		dh.render(w, r, status, link, fmt.Sprintf("»%s« could not be stored.", failed))
+		return
+	}
+
+	msg := fmt.Sprintf("gateway: drop link of »%s« received »%s«", link.Owner, paths[0])
+	if len(paths) > 1 {
+		msg += fmt.Sprintf(" and %d more", len(paths)-1)
+	}
+
+	if err := dh.fs.MakeCommitWithMeta(msg, gatewayCommitMeta); err != nil && err != ie.ErrNoChange {
+		log.Warningf("drop: could not commit: %v", err)
+		dh.render(w, r, http.StatusInternalServerError, link, "The upload could not be stored.")
+		return
+	}
+
+	log.Infof("drop link of %s received %d file(s) in %s", link.Owner, len(paths), link.Folder)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Tell the owner (and everybody else looking) about the new files:
+	dh.evHdl.Notify(ctx, "fs")
+	dh.evHdl.Notify(ctx, dropEvent)
+
+	if failed != "" {
+		dh.render(w, r, status, link, fmt.Sprintf(
+			"Uploaded %d file(s), but »%s« and the files after it could not be stored.",
+			len(paths), failed,
+		))
+		return
+	}
+
+	dh.render(w, r, http.StatusOK, link, fmt.Sprintf("Uploaded %d file(s). Thank you!", len(paths)))
+}
+
+// maxRequestSize returns how many bytes an upload over `link` may have.
+func (dh *DropHandler) maxRequestSize(link *db.DropLink) int64 {
+	limit, err := humanize.ParseBytes(dh.cfg.String("drop.max_request_size"))
+	if err != nil || limit > math.MaxInt64 {
+		// Should not happen thanks to the config validation.
+		limit = 1024 * 1024 * 1024
+	}
+
+	maxSize := int64(limit)
+	if link.MaxSize > 0 && link.MaxSize < maxSize/dropMaxFiles {
+		// Leave some space for the rest of the multipart form.
+		maxSize = dropMaxFiles*link.MaxSize + 1024*1024
+	}
+
+	return maxSize
+}
+
+// sniffMimeType guesses the mime type from the content of the upload.
+// The content type sent by the client is not trusted.
+func sniffMimeType(header *multipart.FileHeader) (string, error) {
+	fd, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer fd.Close()
+
+	buf := make([]byte, 512)
+	n, err := fd.Read(buf)
+	if err != nil && n == 0 && header.Size > 0 {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// dropFileName returns the base name that `name` is stored under.
+func dropFileName(name string) (string, error) {
+	name = path.Base(path.Clean("/" + name))
+	if name == "/" || name == "." {
+		return "", fmt.Errorf("bad file name")
+	}
+
+	return name, nil
+}
+
+// stageFree stages the upload in `header` in `folder` under a path that
+// does not exist yet and returns that path. Uploaders can not see the
+// folder, so they should never overwrite anything. Picking the name and
+// staging is one atomic step, so concurrent uploads can not collide.
+func (dh *DropHandler) stageFree(folder string, header *multipart.FileHeader) (string, error) {
+	name, err := dropFileName(header.Filename)
+	if err != nil {
+		return "", err
+	}
+
+	fd, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer fd.Close()
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for idx := 0; idx < 1000; idx++ {
+		candidate := path.Join(folder, name)
+		if idx > 0 {
+			candidate = path.Join(folder, fmt.Sprintf("%s (%d)%s", base, idx, ext))
+		}
+
+		// Cheap check first, so that not every existing name
+		// needs to read the whole upload:
+		if _, err := dh.fs.Stat(candidate); err == nil {
+			continue
+		} else if !ie.IsNoSuchFileError(err) {
+			return "", err
+		}
+
+		if _, err := fd.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+
+		switch err := dh.fs.StageIfUnchanged(candidate, fd, nil); err {
+		case nil:
+			return candidate, nil
+		case catfs.ErrStageConflict:
+			// Somebody was faster; try the next name.
+			continue
+		default:
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("too many files called %s", name)
+}
@@ -0,0 +1,128 @@
+package endpoints
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sahib/brig/gateway/db"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDrop(t *testing.T, s *testState, token string, files map[string][]byte) *http.Response {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, data := range files {
+		part, err := writer.CreateFormFile("files[]", name)
+		require.Nil(t, err)
+
+		_, err = part.Write(data)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, writer.Close())
+
+	// No session is set here; drop links are anonymous.
+	req := httptest.NewRequest("POST", "/drop/"+token, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rsw := httptest.NewRecorder()
+	NewDropHandler(s.State).ServeHTTP(rsw, req)
+	return rsw.Result()
+}
+
+func mustCreateDropLink(t *testing.T, s *testState, req *DropCreateRequest) db.DropLink {
+	resp := s.mustRun(t, NewDropCreateHandler(s.State), "POST", "http://localhost:5000/api/v0/drop/create", req)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	createResp := &DropCreateResponse{}
+	mustDecodeBody(t, resp.Body, createResp)
+	require.True(t, createResp.Success)
+	return createResp.Link
+}
+
+func TestDropUpload(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Mkdir("/inbox", true))
+		require.Nil(t, s.fs.Stage("/inbox/a.txt", bytes.NewReader([]byte("old"))))
+		require.Nil(t, s.fs.MakeCommit("init"))
+
+		link := mustCreateDropLink(t, s, &DropCreateRequest{
+			Folder:  "/inbox",
+			MaxSize: 10,
+			Types:   []string{".txt"},
+		})
+
+		// Existing files must not be overwritten:
+		resp := mustDrop(t, s, link.Token, map[string][]byte{"a.txt": []byte("new")})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		for path, expected := range map[string]string{
+			"/inbox/a.txt":     "old",
+			"/inbox/a (1).txt": "new",
+		} {
+			stream, err := s.fs.Cat(path)
+			require.Nil(t, err)
+			data, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, expected, string(data))
+		}
+
+		resp = mustDrop(t, s, link.Token, map[string][]byte{"b.txt": []byte("way too much data")})
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		resp = mustDrop(t, s, link.Token, map[string][]byte{"b.exe": []byte("x")})
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		resp = mustDrop(t, s, "does-not-exist", map[string][]byte{"b.txt": []byte("x")})
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		// After removing the link it should not be usable anymore:
+		resp = s.mustRun(t, NewDropRemoveHandler(s.State), "POST", "http://localhost:5000/api/v0/drop/remove", &DropRemoveRequest{
+			Token: link.Token,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = mustDrop(t, s, link.Token, map[string][]byte{"b.txt": []byte("x")})
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestDropListForbidden(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Mkdir("/inbox", true))
+		require.Nil(t, s.fs.Mkdir("/public", true))
+		mustCreateDropLink(t, s, &DropCreateRequest{Folder: "/inbox"})
+		s.mustChangeFolders(t, "/public")
+
+		resp := s.mustRun(t, NewDropListHandler(s.State), "POST", "http://localhost:5000/api/v0/drop/list", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		listResp := &DropListResponse{}
+		mustDecodeBody(t, resp.Body, listResp)
+		require.Len(t, listResp.Links, 0)
+
+		resp = s.mustRun(t, NewDropCreateHandler(s.State), "POST", "http://localhost:5000/api/v0/drop/create", &DropCreateRequest{
+			Folder: "/inbox",
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestDropUploadGlobalLimit(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Mkdir("/inbox", true))
+		require.Nil(t, s.cfg.SetString("drop.max_request_size", "1K"))
+
+		// No size limit on the link itself:
+		link := mustCreateDropLink(t, s, &DropCreateRequest{Folder: "/inbox"})
+
+		resp := mustDrop(t, s, link.Token, map[string][]byte{"a.txt": bytes.Repeat([]byte("x"), 4096)})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = mustDrop(t, s, link.Token, map[string][]byte{"a.txt": []byte("small")})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
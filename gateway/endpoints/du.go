@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sahib/brig/gateway/db"
+)
+
+// DiskUsageHandler implements http.Handler.
+// It returns the space used by every entry of a directory,
+// which is shown in the usage view of the UI.
+type DiskUsageHandler struct {
+	*State
+}
+
+// NewDiskUsageHandler returns a new DiskUsageHandler.
+func NewDiskUsageHandler(s *State) *DiskUsageHandler {
+	return &DiskUsageHandler{State: s}
+}
+
+// DiskUsageRequest is the data sent to this endpoint.
+type DiskUsageRequest struct {
+	Root string `json:"root"`
+}
+
+// ChildUsage is the space used by a single entry.
+// See catfs.ChildUsage for the meaning of the individual fields.
+type ChildUsage struct {
+	Path        string `json:"path"`
+	IsDir       bool   `json:"is_dir"`
+	Files       int    `json:"files"`
+	LogicalSize uint64 `json:"logical_size"`
+	UniqueSize  uint64 `json:"unique_size"`
+	PinnedSize  uint64 `json:"pinned_size"`
+}
+
+// DiskUsageResponse is the response sent back by this endpoint.
+type DiskUsageResponse struct {
+	Success  bool          `json:"success"`
+	Children []*ChildUsage `json:"children"`
+}
+
+func (dh *DiskUsageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	duReq := DiskUsageRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&duReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	root := prefixRoot(duReq.Root)
+	if !dh.pathIsVisible(root, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	usages, err := dh.fs.DiskUsage(root)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "failed to compute usage of %s: %v", root, err)
+		return
+	}
+
+	children := []*ChildUsage{}
+	for _, usage := range usages {
+		// A directory that only leads to an allowed folder would
+		// tell the size of the files that the user may not see.
+		if !dh.validatePath(usage.Path, w, r) {
+			continue
+		}
+
+		children = append(children, &ChildUsage{
+			Path:        usage.Path,
+			IsDir:       usage.IsDir,
+			Files:       usage.Files,
+			LogicalSize: usage.LogicalSize,
+			UniqueSize:  usage.UniqueSize,
+			PinnedSize:  usage.PinnedSize,
+		})
+	}
+
+	jsonify(w, http.StatusOK, &DiskUsageResponse{
+		Success:  true,
+		Children: children,
+	})
+}
@@ -0,0 +1,51 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const duURL = "http://localhost:5000/api/v0/du"
+
+func TestDiskUsage(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/a/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/a/y", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/b/z", bytes.NewReader([]byte("world!"))))
+		require.Nil(t, s.fs.MakeCommit("add"))
+
+		resp := s.mustRun(t, NewDiskUsageHandler(s.State), "POST", duURL, &DiskUsageRequest{
+			Root: "/",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		duResp := &DiskUsageResponse{}
+		mustDecodeBody(t, resp.Body, duResp)
+		require.True(t, duResp.Success)
+		require.Len(t, duResp.Children, 2)
+		require.Equal(t, "/a", duResp.Children[0].Path)
+		require.Equal(t, 2, duResp.Children[0].Files)
+		require.Equal(t, uint64(10), duResp.Children[0].LogicalSize)
+		require.Equal(t, uint64(5), duResp.Children[0].UniqueSize)
+
+		// Entries outside of the user's folders are not shown:
+		s.mustChangeFolders(t, "/b")
+		resp = s.mustRun(t, NewDiskUsageHandler(s.State), "POST", duURL, &DiskUsageRequest{
+			Root: "/",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		duResp = &DiskUsageResponse{}
+		mustDecodeBody(t, resp.Body, duResp)
+		require.Len(t, duResp.Children, 1)
+		require.Equal(t, "/b", duResp.Children[0].Path)
+
+		resp = s.mustRun(t, NewDiskUsageHandler(s.State), "POST", duURL, &DiskUsageRequest{
+			Root: "/a",
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
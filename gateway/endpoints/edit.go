@@ -0,0 +1,315 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sahib/brig/catfs"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/gateway/db"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxEditSize is the biggest file that can be edited in the browser.
+const MaxEditSize = 2 * 1024 * 1024
+
+// etagFromHash formats a node hash as (strong) ETag.
+func etagFromHash(hash string) string {
+	return fmt.Sprintf("\"%s\"", hash)
+}
+
+// EditLoadHandler implements http.Handler.
+type EditLoadHandler struct {
+	*State
+}
+
+// NewEditLoadHandler returns a new EditLoadHandler.
+func NewEditLoadHandler(s *State) *EditLoadHandler {
+	return &EditLoadHandler{State: s}
+}
+
+// EditLoadRequest is the request that can be sent to this endpoint as JSON.
+type EditLoadRequest struct {
+	// Path of the text file to load.
+	Path string `json:"path"`
+}
+
+// EditLoadResponse is the response sent back by this endpoint.
+type EditLoadResponse struct {
+	Success bool   `json:"success"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// ETag needs to be sent back when saving the file.
+	ETag string `json:"etag"`
+}
+
+func (eh *EditLoadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView, db.RightDownload) {
+		return
+	}
+
+	loadReq := EditLoadRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&loadReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	path := prefixRoot(loadReq.Path)
+	if !eh.validatePath(path, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	info, err := eh.fs.Stat(path)
+	if err != nil {
+		if ie.IsNoSuchFileError(err) {
+			jsonifyErrf(w, http.StatusNotFound, "no such file")
+			return
+		}
+
+		log.Debugf("failed to stat %s for editing: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to stat")
+		return
+	}
+
+	if info.IsDir {
+		jsonifyErrf(w, http.StatusBadRequest, "cannot edit a directory")
+		return
+	}
+
+	if info.Size > MaxEditSize {
+		jsonifyErrf(w, http.StatusRequestEntityTooLarge, "file is too big to edit")
+		return
+	}
+
+	stream, err := eh.fs.Cat(path)
+	if err != nil {
+		log.Debugf("failed to cat %s for editing: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	defer stream.Close()
+
+	content := &bytes.Buffer{}
+	if _, err := io.Copy(content, io.LimitReader(stream, MaxEditSize)); err != nil {
+		log.Debugf("failed to read %s for editing: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	if !isText(content.Bytes()) {
+		jsonifyErrf(w, http.StatusUnsupportedMediaType, "file is not a text file")
+		return
+	}
+
+	etag := etagFromHash(info.TreeHash.B58String())
+	w.Header().Set("ETag", etag)
+	jsonify(w, http.StatusOK, &EditLoadResponse{
+		Success: true,
+		Path:    path,
+		Content: content.String(),
+		ETag:    etag,
+	})
+}
+
+// isText is a heuristic that checks if `data` can be edited as text.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// EditSaveHandler implements http.Handler.
+type EditSaveHandler struct {
+	*State
+}
+
+// NewEditSaveHandler returns a new EditSaveHandler.
+func NewEditSaveHandler(s *State) *EditSaveHandler {
+	return &EditSaveHandler{State: s}
+}
+
+// EditSaveRequest is the request that can be sent to this endpoint as JSON.
+// The expected ETag can also be given as If-Match header.
+type EditSaveRequest struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// ETag is the one returned when loading the file.
+	// If empty, the file must not exist yet.
+	ETag string `json:"etag"`
+}
+
+// EditSaveResponse is the response sent back by this endpoint.
+type EditSaveResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	// ETag is the new ETag of the file (or the current one on conflict).
+	ETag string `json:"etag"`
+}
+
+func (eh *EditSaveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsEdit) {
+		return
+	}
+
+	saveReq := EditSaveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&saveReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	expected := saveReq.ETag
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		expected = ifMatch
+	}
+
+	path := prefixRoot(saveReq.Path)
+	if !eh.validatePath(path, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	if len(saveReq.Content) > MaxEditSize {
+		jsonifyErrf(w, http.StatusRequestEntityTooLarge, "content is too big")
+		return
+	}
+
+	current, ok := eh.currentETag(path, w)
+	if !ok {
+		return
+	}
+
+	// "*" matches any existing version of the file:
+	if expected == "*" && current != "" {
+		expected = current
+	}
+
+	// The check is repeated by StageIfUnchanged under the fs lock;
+	// checking here too saves reading the content for obvious conflicts.
+	expectedHash, err := hashFromETag(expected)
+	if err != nil || strings.TrimPrefix(expected, "W/") != current {
+		eh.conflict(w, current)
+		return
+	}
+
+	content := strings.NewReader(saveReq.Content)
+	if err := eh.fs.StageIfUnchanged(path, content, expectedHash); err != nil {
+		if err == catfs.ErrStageConflict {
+			current, _ = eh.currentETag(path, w)
+			eh.conflict(w, current)
+			return
+		}
+
+		log.Debugf("failed to stage %s: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to save file")
+		return
+	}
+
+	if !eh.commitChange(fmt.Sprintf("edited »%s«", path), w, r) {
+		return
+	}
+
+	info, err := eh.fs.Stat(path)
+	if err != nil {
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to stat")
+		return
+	}
+
+	etag := etagFromHash(info.TreeHash.B58String())
+	w.Header().Set("ETag", etag)
+	jsonify(w, http.StatusOK, &EditSaveResponse{
+		Success: true,
+		Message: "success",
+		ETag:    etag,
+	})
+}
+
+// currentETag returns the ETag of the file at `path` or "" if it does not
+// exist. If the bool is false, an error was already sent to the client.
+func (eh *EditSaveHandler) currentETag(path string, w http.ResponseWriter) (string, bool) {
+	info, err := eh.fs.Stat(path)
+	switch {
+	case err == nil && info.IsDir:
+		jsonifyErrf(w, http.StatusBadRequest, "cannot edit a directory")
+		return "", false
+	case err == nil:
+		return etagFromHash(info.TreeHash.B58String()), true
+	case ie.IsNoSuchFileError(err):
+		return "", true
+	default:
+		log.Debugf("failed to stat %s for saving: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to stat")
+		return "", false
+	}
+}
+
+// conflict tells the client that somebody else changed (or created)
+// the file in the meantime.
+func (eh *EditSaveHandler) conflict(w http.ResponseWriter, current string) {
+	w.Header().Set("ETag", current)
+	jsonify(w, http.StatusPreconditionFailed, &EditSaveResponse{
+		Success: false,
+		Message: "file was changed in the meantime",
+		ETag:    current,
+	})
+}
+
+// hashFromETag is the reverse of etagFromHash.
+// An empty ETag stands for a file that does not exist yet (nil hash).
+func hashFromETag(etag string) (h.Hash, error) {
+	if etag == "" {
+		return nil, nil
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	return h.FromB58String(strings.Trim(etag, "\""))
+}
+
+// EditPreviewHandler implements http.Handler.
+type EditPreviewHandler struct {
+	*State
+}
+
+// NewEditPreviewHandler returns a new EditPreviewHandler.
+func NewEditPreviewHandler(s *State) *EditPreviewHandler {
+	return &EditPreviewHandler{State: s}
+}
+
+// EditPreviewRequest is the request that can be sent to this endpoint as JSON.
+type EditPreviewRequest struct {
+	// Content is markdown text.
+	Content string `json:"content"`
+}
+
+// EditPreviewResponse is the response sent back by this endpoint.
+type EditPreviewResponse struct {
+	Success bool   `json:"success"`
+	HTML    string `json:"html"`
+}
+
+func (eh *EditPreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	previewReq := EditPreviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&previewReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if len(previewReq.Content) > MaxEditSize {
+		jsonifyErrf(w, http.StatusRequestEntityTooLarge, "content is too big")
+		return
+	}
+
+	jsonify(w, http.StatusOK, &EditPreviewResponse{
+		Success: true,
+		HTML:    renderMarkdown(previewReq.Content),
+	})
+}
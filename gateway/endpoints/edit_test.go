@@ -0,0 +1,110 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditLoadAndSave(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/notes.md", bytes.NewReader([]byte("# hello"))))
+
+		resp := s.mustRun(
+			t,
+			NewEditLoadHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/edit/load",
+			&EditLoadRequest{Path: "/notes.md"},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		loadResp := &EditLoadResponse{}
+		mustDecodeBody(t, resp.Body, loadResp)
+		require.Equal(t, "# hello", loadResp.Content)
+		require.Equal(t, loadResp.ETag, resp.Header.Get("ETag"))
+
+		saveHdl := NewEditSaveHandler(s.State)
+		resp = s.mustRun(t, saveHdl, "POST", "http://localhost:5000/api/v0/edit/save", &EditSaveRequest{
+			Path:    "/notes.md",
+			Content: "# hello world",
+			ETag:    loadResp.ETag,
+		})
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		saveResp := &EditSaveResponse{}
+		mustDecodeBody(t, resp.Body, saveResp)
+		require.NotEqual(t, loadResp.ETag, saveResp.ETag)
+
+		// Saving with the old etag again must fail, since the file changed:
+		resp = s.mustRun(t, saveHdl, "POST", "http://localhost:5000/api/v0/edit/save", &EditSaveRequest{
+			Path:    "/notes.md",
+			Content: "# overwritten",
+			ETag:    loadResp.ETag,
+		})
+
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		conflictResp := &EditSaveResponse{}
+		mustDecodeBody(t, resp.Body, conflictResp)
+		require.Equal(t, saveResp.ETag, conflictResp.ETag)
+
+		// Files can be created when no etag is given:
+		resp = s.mustRun(t, saveHdl, "POST", "http://localhost:5000/api/v0/edit/save", &EditSaveRequest{
+			Path:    "/new.md",
+			Content: "new",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// ...but not when they exist already:
+		resp = s.mustRun(t, saveHdl, "POST", "http://localhost:5000/api/v0/edit/save", &EditSaveRequest{
+			Path:    "/notes.md",
+			Content: "new",
+		})
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
+
+func TestEditLoadBinary(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/bin", bytes.NewReader([]byte{0, 1, 2})))
+
+		resp := s.mustRun(
+			t,
+			NewEditLoadHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/edit/load",
+			&EditLoadRequest{Path: "/bin"},
+		)
+
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tcs := []struct {
+		input, output string
+	}{
+		{"# Title", "<h1>Title</h1>\n"},
+		{"some *em* and **strong**", "<p>some <em>em</em> and <strong>strong</strong></p>\n"},
+		{"- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"1. a\n2. b", "<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>\n"},
+		{"use `a*b*c`", "<p>use <code>a*b*c</code></p>\n"},
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"[x](https://a.org)", "<p><a href=\"https://a.org\">x</a></p>\n"},
+		{"[x](javascript:alert(1))", "<p>x)</p>\n"},
+		// Emphasis must not be rendered inside of link targets:
+		{"[x](https://a.org/_a_b_)", "<p><a href=\"https://a.org/_a_b_\">x</a></p>\n"},
+		{"_[x](/**y**)_", "<p><em><a href=\"/**y**\">x</a></em></p>\n"},
+		{"[**x**](/a*b*c)", "<p><a href=\"/a*b*c\"><strong>x</strong></a></p>\n"},
+		{"a\x00" + "0\x00", "<p>a\uFFFD0\uFFFD</p>\n"},
+		{"> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"---", "<hr>\n"},
+	}
+
+	for _, tc := range tcs {
+		require.Equal(t, tc.output, renderMarkdown(tc.input), tc.input)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/sahib/brig/events"
+	"github.com/sahib/brig/events/bus"
 	"github.com/sahib/brig/gateway/db"
 	"github.com/sahib/brig/gateway/remotesapi"
 	log "github.com/sirupsen/logrus"
@@ -25,6 +26,8 @@ type EventsHandler struct {
 	chs        map[int]chan string
 	rapi       remotesapi.RemotesAPI
 	evListener *events.Listener
+	evBus      *bus.Bus
+	evSub      *bus.Subscription
 	changeOnce sync.Once
 
 	// only true while unit tests.
@@ -43,6 +46,11 @@ func NewEventsHandler(rapi remotesapi.RemotesAPI, ev *events.Listener) *EventsHa
 	if ev != nil {
 		// Incoming events from our own node:
 		ev.RegisterEventHandler(events.FsEvent, true, func(ev *events.Event) {
+			if hdl.hasEventBus() {
+				// Those are taken from the event bus then.
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 			defer cancel()
 
@@ -62,6 +70,48 @@ func NewEventsHandler(rapi remotesapi.RemotesAPI, ev *events.Listener) *EventsHa
 	return hdl
 }
 
+// EventBusSource is the source of events the gateway publishes on the event bus.
+const EventBusSource = "gateway"
+
+// SetEventBus makes the handler take local filesystem changes from `evBus`
+// and publish changes done over the gateway there.
+// A previously set bus is unsubscribed from; nil only does that.
+func (eh *EventsHandler) SetEventBus(evBus *bus.Bus) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	if eh.evSub != nil {
+		eh.evSub.Close()
+		eh.evSub = nil
+	}
+
+	eh.evBus = evBus
+	if evBus == nil {
+		return
+	}
+
+	sub := evBus.Subscribe("", bus.KindFsChange, bus.KindCommit, bus.KindSync)
+	eh.evSub = sub
+	go func() {
+		for ev := range sub.Events() {
+			if ev.Source == EventBusSource {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+			eh.notify(ctx, "fs", true, false)
+			cancel()
+		}
+	}()
+}
+
+func (eh *EventsHandler) hasEventBus() bool {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	return eh.evBus != nil
+}
+
 // Notify sends `msg` to all connected clients, but stops in case `ctx`
 // was canceled before sending it all.
 func (eh *EventsHandler) Notify(ctx context.Context, msg string) error {
@@ -74,8 +124,13 @@ func (eh *EventsHandler) notify(ctx context.Context, msg string, isOwnEvent, tri
 	for _, ch := range eh.chs {
 		chs = append(chs, ch)
 	}
+	evBus := eh.evBus
 	eh.mu.Unlock()
 
+	if isOwnEvent && triggerPublish && msg == "fs" && evBus != nil {
+		evBus.Publish(bus.Event{Kind: bus.KindFsChange, Source: EventBusSource})
+	}
+
 	for _, ch := range chs {
 		select {
 		case <-ctx.Done():
@@ -97,11 +152,16 @@ func (eh *EventsHandler) notify(ctx context.Context, msg string, isOwnEvent, tri
 	return nil
 }
 
-// Shutdown closes all open websockets.
+// Shutdown closes all open websockets and the event bus subscription.
 func (eh *EventsHandler) Shutdown() {
 	eh.mu.Lock()
 	defer eh.mu.Unlock()
 
+	if eh.evSub != nil {
+		eh.evSub.Close()
+		eh.evSub = nil
+	}
+
 	for _, ch := range eh.chs {
 		close(ch)
 	}
@@ -114,6 +174,21 @@ func (eh *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Register before the upgrade, so no message sent after
+	// the client saw the connection established gets lost.
+	eh.mu.Lock()
+	id := eh.id
+	eh.id++
+	ch := make(chan string, 20)
+	eh.chs[id] = ch
+	eh.mu.Unlock()
+
+	defer func() {
+		eh.mu.Lock()
+		delete(eh.chs, id)
+		eh.mu.Unlock()
+	}()
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Warningf("failed to upgrade to websocket: %v", err)
@@ -132,19 +207,6 @@ func (eh *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		})
 	})
 
-	eh.mu.Lock()
-	id := eh.id
-	eh.id++
-	ch := make(chan string, 20)
-	eh.chs[id] = ch
-	eh.mu.Unlock()
-
-	defer func() {
-		eh.mu.Lock()
-		delete(eh.chs, id)
-		eh.mu.Unlock()
-	}()
-
 	defer conn.Close()
 
 	for {
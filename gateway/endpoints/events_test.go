@@ -22,7 +22,7 @@ func TestEvents(t *testing.T) {
 		require.Nil(t, err)
 
 		if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
-			t.Fatalf("resp.StatusCode = %q, want %q", got, want)
+			t.Fatalf("resp.StatusCode = %d, want %d", got, want)
 		}
 
 		go func() {
@@ -0,0 +1,360 @@
+package endpoints
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// Feeds let users follow a folder in a feed reader. Feed readers can not
+// log in, so the url carries the user and a token that authenticates the
+// user for this folder. Unlike signed download urls, the token does not
+// expire; the rights of the user are checked on every request instead.
+// Clearing »gateway.auth.feed-key« invalidates all feed urls at once.
+
+const (
+	// FeedPrefix is where feeds are served, followed by the format and the folder.
+	FeedPrefix = "/feed"
+
+	feedFormatAtom = "atom"
+	feedFormatRSS  = "rss"
+)
+
+// FeedURLHandler implements http.Handler.
+type FeedURLHandler struct {
+	*State
+}
+
+// NewFeedURLHandler returns a new FeedURLHandler.
+func NewFeedURLHandler(s *State) *FeedURLHandler {
+	return &FeedURLHandler{State: s}
+}
+
+// FeedURLRequest is the request that can be sent to this endpoint as JSON.
+type FeedURLRequest struct {
+	// Path is the folder to follow.
+	Path string `json:"path"`
+	// Format is either "atom" (the default) or "rss".
+	Format string `json:"format"`
+}
+
+// FeedURLResponse is the response sent back by this endpoint.
+type FeedURLResponse struct {
+	Success bool   `json:"success"`
+	URL     string `json:"url"`
+}
+
+// feedToken authenticates that `user` may read the feed of `folder`.
+func (s *State) feedToken(user, folder string) string {
+	mac := hmac.New(sha256.New, s.feedKey)
+	fmt.Fprintf(mac, "%s\x00%s", user, folder)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// feedURL returns the path and query of the feed of `folder` for `user`.
+func (s *State) feedURL(user, folder, format string) string {
+	query := url.Values{}
+	query.Set("user", user)
+	query.Set("token", s.feedToken(user, folder))
+	return FeedPrefix + "/" + format + escapeNodePath(folder) + "?" + query.Encode()
+}
+
+func (fh *FeedURLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !fh.cfg.Bool("feeds.enabled") {
+		jsonifyErrf(w, http.StatusNotFound, "feeds are disabled")
+		return
+	}
+
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	feedReq := FeedURLRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&feedReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	switch feedReq.Format {
+	case "":
+		feedReq.Format = feedFormatAtom
+	case feedFormatAtom, feedFormatRSS:
+	default:
+		jsonifyErrf(w, http.StatusBadRequest, "unknown feed format: %s", feedReq.Format)
+		return
+	}
+
+	folder := prefixRoot(path.Clean(feedReq.Path))
+	if !fh.validatePath(folder, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	info, err := fh.fs.Stat(folder)
+	if err != nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such directory: %s", folder)
+		return
+	}
+
+	if !info.IsDir {
+		jsonifyErrf(w, http.StatusBadRequest, "feeds are only available for directories")
+		return
+	}
+
+	user := getUserName(fh.store, w, r)
+	jsonify(w, http.StatusOK, &FeedURLResponse{
+		Success: true,
+		URL:     baseURL(r) + fh.feedURL(user, folder, feedReq.Format),
+	})
+}
+
+// FeedHandler implements http.Handler.
+type FeedHandler struct {
+	*State
+}
+
+// NewFeedHandler returns a new FeedHandler.
+func NewFeedHandler(s *State) *FeedHandler {
+	return &FeedHandler{State: s}
+}
+
+// feedEntry is a single added or modified file.
+type feedEntry struct {
+	Path   string
+	Change string
+	Commit *catfs.Commit
+}
+
+func (e feedEntry) title() string {
+	return fmt.Sprintf("%s %s", e.Change, e.Path)
+}
+
+func (e feedEntry) id() string {
+	return fmt.Sprintf("urn:brig:%s:%s", e.Commit.Hash.B58String(), e.Path)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Summary string      `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// checkFeedToken checks the user and token in `r` for `folder`
+// and returns the user if it may still read the folder.
+func (fh *FeedHandler) checkFeedToken(folder string, w http.ResponseWriter, r *http.Request) (db.User, bool) {
+	query := r.URL.Query()
+	name := query.Get("user")
+	expected := fh.feedToken(name, folder)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("token"))) {
+		return db.User{}, false
+	}
+
+	user, err := fh.userDb.Get(name)
+	if err != nil {
+		return db.User{}, false
+	}
+
+	if !fh.userDb.HasRight(user, db.RightFsView) {
+		return db.User{}, false
+	}
+
+	return user, fh.validatePathForUser(folder, user, w, r)
+}
+
+// feedEntries returns the newest additions and modifications below `folder`.
+func (fh *FeedHandler) feedEntries(folder string) ([]feedEntry, error) {
+	maxItems := int(fh.cfg.Int("feeds.max_items"))
+	acts, err := fh.fs.Activity(folder, 0, maxItems)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []feedEntry{}
+	for _, act := range acts {
+		for _, change := range act.Changes {
+			if change.Change != "added" && change.Change != "modified" {
+				continue
+			}
+
+			entries = append(entries, feedEntry{
+				Path:   change.Path,
+				Change: change.Change,
+				Commit: act.Commit,
+			})
+
+			if len(entries) >= maxItems {
+				return entries, nil
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+func buildAtomFeed(base, folder, self string, entries []feedEntry) *atomFeed {
+	feed := &atomFeed{
+		Title:   "brig: " + folder,
+		ID:      base + LinkPrefix + escapeNodePath(folder),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "brig"},
+		Links: []atomLink{
+			{Href: self, Rel: "self"},
+			{Href: base + LinkPrefix + escapeNodePath(folder)},
+		},
+	}
+
+	if len(entries) > 0 {
+		feed.Updated = entries[0].Commit.Date.UTC().Format(time.RFC3339)
+	}
+
+	for _, entry := range entries {
+		atomEnt := atomEntry{
+			Title:   entry.title(),
+			ID:      entry.id(),
+			Updated: entry.Commit.Date.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: base + LinkPrefix + escapeNodePath(entry.Path)},
+			Summary: entry.Commit.Msg,
+		}
+
+		if entry.Commit.Device != "" {
+			atomEnt.Author = &atomAuthor{Name: entry.Commit.Device}
+		}
+
+		feed.Entries = append(feed.Entries, atomEnt)
+	}
+
+	return feed
+}
+
+func buildRSSFeed(base, folder string, entries []feedEntry) *rssFeed {
+	feed := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       "brig: " + folder,
+			Link:        base + LinkPrefix + escapeNodePath(folder),
+			Description: fmt.Sprintf("New and modified files in %s", folder),
+		},
+	}
+
+	for _, entry := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       entry.title(),
+			Link:        base + LinkPrefix + escapeNodePath(entry.Path),
+			GUID:        entry.id(),
+			PubDate:     entry.Commit.Date.Format(time.RFC1123Z),
+			Description: entry.Commit.Msg,
+		})
+	}
+
+	return feed
+}
+
+func (fh *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !fh.cfg.Bool("feeds.enabled") {
+		http.Error(w, "feeds are disabled", http.StatusNotFound)
+		return
+	}
+
+	// The url looks like /feed/<format>/<folder>:
+	rest := strings.TrimPrefix(r.URL.Path, FeedPrefix+"/")
+	format, folder, _ := strings.Cut(rest, "/")
+	folder = prefixRoot(path.Clean("/" + folder))
+
+	if format != feedFormatAtom && format != feedFormatRSS {
+		http.Error(w, "unknown feed format", http.StatusNotFound)
+		return
+	}
+
+	if _, ok := fh.checkFeedToken(folder, w, r); !ok {
+		http.Error(w, "invalid feed token", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := fh.fs.Stat(folder); err != nil {
+		http.Error(w, "no such directory", http.StatusNotFound)
+		return
+	}
+
+	entries, err := fh.feedEntries(folder)
+	if err != nil {
+		log.Debugf("failed to build feed of %s: %v", folder, err)
+		http.Error(w, "failed to build feed", http.StatusInternalServerError)
+		return
+	}
+
+	base := baseURL(r)
+
+	var doc interface{}
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == feedFormatRSS {
+		doc = buildRSSFeed(base, folder, entries)
+		contentType = "application/rss+xml; charset=utf-8"
+	} else {
+		doc = buildAtomFeed(base, folder, base+r.URL.RequestURI(), entries)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Debugf("failed to write feed: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeed(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/photos/a.png", bytes.NewReader([]byte("a"))))
+		require.Nil(t, s.fs.Stage("/docs/b.txt", bytes.NewReader([]byte("b"))))
+		require.Nil(t, s.fs.MakeCommit("first"))
+		require.Nil(t, s.fs.Stage("/photos/a.png", bytes.NewReader([]byte("aa"))))
+		require.Nil(t, s.fs.Stage("/photos/c.png", bytes.NewReader([]byte("c"))))
+		require.Nil(t, s.fs.Remove("/docs/b.txt"))
+		require.Nil(t, s.fs.MakeCommit("second"))
+
+		feedURL := func(folder, format string) (int, string) {
+			resp := s.mustRun(t, NewFeedURLHandler(s.State), "POST", "http://localhost:5000/api/v0/feed/url", &FeedURLRequest{
+				Path:   folder,
+				Format: format,
+			})
+
+			urlResp := &FeedURLResponse{}
+			mustDecodeBody(t, resp.Body, urlResp)
+			return resp.StatusCode, urlResp.URL
+		}
+
+		// No session is set; the token in the url is all that's needed:
+		fetch := func(url string) (int, string) {
+			rsw := httptest.NewRecorder()
+			NewFeedHandler(s.State).ServeHTTP(rsw, httptest.NewRequest("GET", url, nil))
+			data, err := ioutil.ReadAll(rsw.Result().Body)
+			require.Nil(t, err)
+			return rsw.Result().StatusCode, string(data)
+		}
+
+		code, url := feedURL("/photos", "")
+		require.Equal(t, http.StatusOK, code)
+		require.True(t, strings.HasPrefix(url, "http://localhost:5000/feed/atom/photos?"))
+
+		code, data := fetch(url)
+		require.Equal(t, http.StatusOK, code)
+
+		atom := &atomFeed{}
+		require.Nil(t, xml.Unmarshal([]byte(data), atom))
+		require.Equal(t, "brig: /photos", atom.Title)
+		require.Len(t, atom.Entries, 3)
+		require.Equal(t, "modified /photos/a.png", atom.Entries[0].Title)
+		require.Equal(t, "second", atom.Entries[0].Summary)
+		require.Equal(t, "added /photos/c.png", atom.Entries[1].Title)
+		require.Equal(t, "added /photos/a.png", atom.Entries[2].Title)
+
+		// Removals are not listed:
+		code, url = feedURL("/docs", "rss")
+		require.Equal(t, http.StatusOK, code)
+		code, data = fetch(url)
+		require.Equal(t, http.StatusOK, code)
+
+		rss := &rssFeed{}
+		require.Nil(t, xml.Unmarshal([]byte(data), rss))
+		require.Len(t, rss.Channel.Items, 1)
+		require.Equal(t, "added /docs/b.txt", rss.Channel.Items[0].Title)
+
+		// The token is only valid for this folder:
+		code, _ = fetch(strings.Replace(url, "/docs", "/photos", 1))
+		require.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = feedURL("/photos/a.png", "atom")
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = feedURL("/photos", "json")
+		require.Equal(t, http.StatusBadRequest, code)
+
+		// Losing access to the folder also invalidates the url:
+		s.mustChangeFolders(t, "/photos")
+		code, _ = fetch(url)
+		require.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = feedURL("/docs", "atom")
+		require.Equal(t, http.StatusUnauthorized, code)
+
+		require.Nil(t, s.cfg.SetBool("feeds.enabled", false))
+		code, _ = fetch(strings.Replace(url, "/docs", "/photos", 1))
+		require.Equal(t, http.StatusNotFound, code)
+	})
+}
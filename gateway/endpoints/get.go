@@ -59,32 +59,24 @@ func setContentDisposition(info *catfs.StatInfo, hdr http.Header, dispoType stri
 	)
 }
 
-func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *http.Request) bool {
+func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *http.Request) (db.User, bool) {
 	name, pass, ok := r.BasicAuth()
 
 	// No basic auth sent. If a browser send the request: ask him to
 	// show a user/password form that gives a chance to change that.
 	if !ok {
 		w.Header().Set("WWW-Authenticate", "Basic realm=\"brig gateway\"")
-		return false
+		return db.User{}, false
 	}
 
 	// Check is the basic auth credentials are valid.
 	user, err := gh.userDb.Get(name)
 	if err != nil {
-		return false
+		return db.User{}, false
 	}
 
-	hasRight := false
-	for _, right := range user.Rights {
-		if right == db.RightDownload {
-			hasRight = true
-			break
-		}
-	}
-
-	if !hasRight {
-		return false
+	if !gh.userDb.HasRight(user, db.RightDownload) {
+		return db.User{}, false
 	}
 
 	isValid, err := user.CheckPassword(pass)
@@ -93,35 +85,49 @@ func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *
 			log.Warningf("get: failed to check password: %v", err)
 		}
 
-		return false
+		return db.User{}, false
 	}
 
+	upgradePasswordHash(gh.userDb, user.Name, pass)
+
 	// Check again if this user has access to the path:
 	if !gh.validatePathForUser(nodePath, user, w, r) {
-		return false
+		return db.User{}, false
 	}
 
-	return true
+	return user, true
 }
 
-func (gh *GetHandler) checkDownloadRight(w http.ResponseWriter, r *http.Request) bool {
+// checkDownloadRight returns the logged in user, if they may download.
+func (gh *GetHandler) checkDownloadRight(w http.ResponseWriter, r *http.Request) (db.User, bool) {
 	name := getUserName(gh.store, w, r)
 	if name == "" {
-		return false
+		return db.User{}, false
 	}
 
 	user, err := gh.userDb.Get(name)
 	if err != nil {
-		return false
+		return db.User{}, false
 	}
 
-	for _, right := range user.Rights {
-		if right == db.RightDownload {
-			return true
-		}
+	return user, gh.userDb.HasRight(user, db.RightDownload)
+}
+
+// countAccess increments `counter` of the node at `nodePath`. Range requests
+// that do not start at the beginning are not counted, since video players
+// and download managers fetch the same file in many pieces.
+func (gh *GetHandler) countAccess(r *http.Request, nodePath, counter string) {
+	if r.Method != http.MethodGet {
+		return
+	}
+
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=0-") {
+		return
 	}
 
-	return false
+	if err := gh.fs.CounterAdd(nodePath, counter); err != nil {
+		log.Debugf("gateway: failed to count access to %s: %v", nodePath, err)
+	}
 }
 
 func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -138,7 +144,22 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !gh.cfg.Bool("auth.anon_allowed") {
+	// user is the one that authorized this request. Their folders
+	// decide what goes into the archive when downloading a directory.
+	var user db.User
+
+	if r.URL.Query().Get("sig") != "" {
+		// Signed urls need no login; they were made by a user that had one.
+		if !gh.checkSignature(prefixRoot(path.Clean(nodePath)), w, r) {
+			http.Error(w, "invalid or expired signature", http.StatusUnauthorized)
+			return
+		}
+
+		if user, err = gh.userDb.Get(r.URL.Query().Get("user")); err != nil {
+			http.Error(w, "invalid or expired signature", http.StatusUnauthorized)
+			return
+		}
+	} else if !gh.cfg.Bool("auth.anon_allowed") {
 		// validatePath will check if the user is actually logged in
 		// and may access the path in question. The login could come
 		// from a previous login to the UI (the /get endpoint could be used separately)
@@ -147,12 +168,14 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// we also accept basic auth for this endpoint.
 			// This way hyperlinks can be shared without having to login.
 			// Using HTTPS here is strongly recommended.
-			if !gh.checkBasicAuth(nodePath, w, r) {
+			var ok bool
+			if user, ok = gh.checkBasicAuth(nodePath, w, r); !ok {
 				http.Error(w, "not authorized", http.StatusUnauthorized)
 				return
 			}
 		} else {
-			if !gh.checkDownloadRight(w, r) {
+			var ok bool
+			if user, ok = gh.checkDownloadRight(w, r); !ok {
 				http.Error(w, "insufficient rights", http.StatusUnauthorized)
 				return
 			}
@@ -160,16 +183,35 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		// All good. Proceed with the content.
 	} else {
-		if !gh.checkDownloadRight(w, r) {
-			http.Error(w, "insufficient rights for anon", http.StatusUnauthorized)
-			return
+		// Requests without session (e.g. crawlers fetching a preview image)
+		// are treated as the anonymous user:
+		var ok bool
+		if user, ok = gh.checkDownloadRight(w, r); !ok {
+			if !gh.anonMayDownload(nodePath, w, r) {
+				http.Error(w, "insufficient rights for anon", http.StatusUnauthorized)
+				return
+			}
+
+			if user, err = gh.userDb.Get(gh.cfg.String("auth.anon_user")); err != nil {
+				http.Error(w, "insufficient rights for anon", http.StatusUnauthorized)
+				return
+			}
 		}
 	}
 
-	info, err := gh.fs.Stat(nodePath)
+	// An optional `at` serves the node as it was at a past commit or time:
+	at := r.URL.Query().Get("at")
+
+	var info *catfs.StatInfo
+	if at != "" {
+		info, err = gh.fs.StatAt(at, nodePath)
+	} else {
+		info, err = gh.fs.Stat(nodePath)
+	}
+
 	if err != nil {
 		// Handle a bad nodePath more explicit:
-		if ie.IsNoSuchFileError(err) {
+		if ie.IsNoSuchFileError(err) || ie.IsErrNoSuchRef(err) {
 			http.Error(w, "not found", http.StatusNotFound)
 			return
 		}
@@ -187,7 +229,14 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		params := r.URL.Query()
 		includes := params["include"]
 
+		// Subfolders that the user may not see (e.g. because of a "!"
+		// folder) are left out. Directories on the way to one of the
+		// user's folders are entered, but not packed.
 		filter := func(info *catfs.StatInfo) bool {
+			if !gh.validatePathForUser(info.Path, user, w, r) {
+				return info.IsDir && leadsToFolder(info.Path, user.Folders)
+			}
+
 			if len(includes) == 0 {
 				return true
 			}
@@ -202,13 +251,26 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		setContentDisposition(info, hdr, "attachment")
-		if err := gh.fs.Tar(nodePath, w, filter); err != nil {
+		gh.countAccess(r, nodePath, catfs.CounterDownloads)
+		if at != "" {
+			err = gh.fs.Archive(at, nodePath, catfs.ArchiveTar, w, filter)
+		} else {
+			err = gh.fs.Tar(nodePath, w, filter)
+		}
+
+		if err != nil {
 			log.Errorf("gateway: failed to stream %s: %v", nodePath, err)
 			http.Error(w, "failed to stream", http.StatusInternalServerError)
 			return
 		}
 	} else {
-		stream, err := gh.fs.Cat(nodePath)
+		var stream mio.Stream
+		if at != "" {
+			stream, err = gh.fs.CatAt(at, nodePath)
+		} else {
+			stream, err = gh.fs.Cat(nodePath)
+		}
+
 		if err != nil {
 			log.Errorf("gateway: failed to stream %s: %v", nodePath, err)
 			http.Error(w, "failed to stream", http.StatusInternalServerError)
@@ -216,6 +278,11 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		prefixStream, mimeType := mimeTypeFromStream(stream)
+		if info.MimeType != "" {
+			// Detected on stage with more than just the sniffing:
+			mimeType = info.MimeType
+		}
+
 		hdr.Set("Content-Type", mimeType)
 		hdr.Set("Content-Length", strconv.FormatUint(info.Size, 10))
 
@@ -224,8 +291,10 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Set the content disposition to inline if it looks like something viewable.
 		if mimeType == "application/octet-stream" || isDirectDownload {
 			setContentDisposition(info, hdr, "attachment")
+			gh.countAccess(r, nodePath, catfs.CounterDownloads)
 		} else {
 			setContentDisposition(info, hdr, "inline")
+			gh.countAccess(r, nodePath, catfs.CounterViews)
 		}
 
 		http.ServeContent(w, r, path.Base(info.Path), info.ModTime, prefixStream)
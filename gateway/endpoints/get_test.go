@@ -1,9 +1,12 @@
 package endpoints
 
 import (
+	"archive/tar"
 	"bytes"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -45,3 +48,79 @@ func TestGetEndpointDisallowed(t *testing.T) {
 		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 }
+
+func TestGetEndpointAt(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/file", bytes.NewReader([]byte("old"))))
+		require.Nil(t, s.fs.MakeCommit("old"))
+		require.Nil(t, s.fs.Stage("/file", bytes.NewReader([]byte("new"))))
+		require.Nil(t, s.fs.MakeCommit("new"))
+
+		resp := s.mustRun(
+			t,
+			NewGetHandler(s.State),
+			"GET",
+			"http://localhost:5000/get/file?at=HEAD^",
+			nil,
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Equal(t, []byte("old"), data)
+
+		resp = s.mustRun(
+			t,
+			NewGetHandler(s.State),
+			"GET",
+			"http://localhost:5000/get/file?at=1990-01-01",
+			nil,
+		)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func mustReadTarNames(t *testing.T, r io.Reader) []string {
+	names := []string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+
+		require.Nil(t, err)
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestGetEndpointDirNoInherit(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/pub/a.txt", bytes.NewReader([]byte("a"))))
+		require.Nil(t, s.fs.Stage("/pub/secret/b.txt", bytes.NewReader([]byte("b"))))
+		require.Nil(t, s.fs.Stage("/pub/secret/open/c.txt", bytes.NewReader([]byte("c"))))
+		s.mustChangeFolders(t, "/pub", "!/pub/secret", "/pub/secret/open")
+
+		resp := s.mustRun(t, NewGetHandler(s.State), "GET", "http://localhost:5000/get/pub", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The folder that is not inherited is left out,
+		// but what was granted below it again is packed:
+		expected := []string{"/a.txt", "/secret/open/c.txt"}
+		require.Equal(t, expected, mustReadTarNames(t, resp.Body))
+
+		// The same goes for signed urls made by this user:
+		resp = s.mustRun(t, NewSignHandler(s.State), "POST", "http://localhost:5000/api/v0/sign", &SignRequest{
+			Paths: []string{"/pub"},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		signResp := &SignResponse{}
+		mustDecodeBody(t, resp.Body, signResp)
+		require.Len(t, signResp.URLs, 1)
+
+		rsw := httptest.NewRecorder()
+		NewGetHandler(s.State).ServeHTTP(rsw, httptest.NewRequest("GET", signResp.URLs[0].URL, nil))
+		require.Equal(t, http.StatusOK, rsw.Result().StatusCode)
+		require.Equal(t, expected, mustReadTarNames(t, rsw.Result().Body))
+	})
+}
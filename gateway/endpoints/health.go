@@ -0,0 +1,79 @@
+package endpoints
+
+import (
+	"net/http"
+)
+
+// HealthzHandler implements http.Handler.
+// It only tells that the gateway process is alive.
+type HealthzHandler struct {
+	*State
+}
+
+// NewHealthzHandler returns a new HealthzHandler.
+func NewHealthzHandler(s *State) *HealthzHandler {
+	return &HealthzHandler{State: s}
+}
+
+// HealthzResponse is the response sent back by /healthz.
+type HealthzResponse struct {
+	Alive bool `json:"alive"`
+}
+
+func (hh *HealthzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	jsonify(w, http.StatusOK, HealthzResponse{Alive: true})
+}
+
+// ReadyzHandler implements http.Handler.
+// It checks if everything the gateway depends on is usable.
+type ReadyzHandler struct {
+	*State
+}
+
+// NewReadyzHandler returns a new ReadyzHandler.
+func NewReadyzHandler(s *State) *ReadyzHandler {
+	return &ReadyzHandler{State: s}
+}
+
+// ReadyzResponse is the response sent back by /readyz.
+// Checks maps the name of each check to "ok" or the reason it failed.
+type ReadyzResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func checkResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return "ok"
+}
+
+func (rh *ReadyzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"metadata": checkResult(rh.fs.Ping()),
+		"userdb":   checkResult(rh.userDb.Ping()),
+	}
+
+	if rh.backendOnline != nil {
+		checks["backend"] = "ok"
+		if !rh.backendOnline() {
+			checks["backend"] = "offline"
+		}
+	}
+
+	resp := ReadyzResponse{Ready: true, Checks: checks}
+	for _, result := range checks {
+		if result != "ok" {
+			resp.Ready = false
+		}
+	}
+
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	jsonify(w, status, resp)
+}
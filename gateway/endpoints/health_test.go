@@ -0,0 +1,65 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthzEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		resp := s.mustRun(
+			t,
+			NewHealthzHandler(s.State),
+			"GET",
+			"http://localhost:5000/healthz",
+			nil,
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data := &HealthzResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.True(t, data.Alive)
+	})
+}
+
+func TestReadyzEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		isOnline := true
+		s.SetBackendCheck(func() bool { return isOnline })
+
+		resp := s.mustRun(
+			t,
+			NewReadyzHandler(s.State),
+			"GET",
+			"http://localhost:5000/readyz",
+			nil,
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data := &ReadyzResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.True(t, data.Ready)
+		require.Equal(t, map[string]string{
+			"metadata": "ok",
+			"userdb":   "ok",
+			"backend":  "ok",
+		}, data.Checks)
+
+		isOnline = false
+		resp = s.mustRun(
+			t,
+			NewReadyzHandler(s.State),
+			"GET",
+			"http://localhost:5000/readyz",
+			nil,
+		)
+
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		data = &ReadyzResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.False(t, data.Ready)
+		require.Equal(t, "offline", data.Checks["backend"])
+	})
+}
@@ -27,11 +27,14 @@ type HistoryRequest struct {
 // Commit is the same as catfs.Commit, but JSON friendly
 // and with some omitted fields that are not used by the client.
 type Commit struct {
-	Date  int64    `json:"date"`
-	Msg   string   `json:"msg"`
-	Tags  []string `json:"tags"`
-	Hash  string   `json:"hash"`
-	Index int64    `json:"index"`
+	Date   int64    `json:"date"`
+	Msg    string   `json:"msg"`
+	Tags   []string `json:"tags"`
+	Hash   string   `json:"hash"`
+	Index  int64    `json:"index"`
+	Device string   `json:"device"`
+	Origin string   `json:"origin"`
+	Labels []string `json:"labels"`
 }
 
 // HistoryEntry is one entry in the response.
@@ -56,6 +59,9 @@ func toExternalCommit(cmt *catfs.Commit) Commit {
 	ext.Msg = cmt.Msg
 	ext.Tags = cmt.Tags
 	ext.Index = cmt.Index
+	ext.Device = cmt.Device
+	ext.Origin = cmt.Origin
+	ext.Labels = cmt.Labels
 
 	// Make sure we set an empty list,
 	// otherwise .Tags gets serialized as null
@@ -63,6 +69,10 @@ func toExternalCommit(cmt *catfs.Commit) Commit {
 	if ext.Tags == nil {
 		ext.Tags = []string{}
 	}
+
+	if ext.Labels == nil {
+		ext.Labels = []string{}
+	}
 	return ext
 }
 
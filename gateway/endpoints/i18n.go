@@ -0,0 +1,82 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sahib/brig/gateway/i18n"
+)
+
+type languageMiddleware struct {
+	SubHandler http.Handler
+}
+
+func (lm *languageMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hdr := w.Header()
+	hdr.Set("Content-Language", i18n.Negotiate(r.Header.Get("Accept-Language")))
+	hdr.Add("Vary", "Accept-Language")
+	lm.SubHandler.ServeHTTP(w, r)
+}
+
+// LanguageMiddleware picks the language of the response from the
+// Accept-Language header and stores it in the Content-Language header.
+func LanguageMiddleware() func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return &languageMiddleware{SubHandler: h}
+	}
+}
+
+// I18nHandler implements http.Handler.
+// It sends the message catalog, so the frontend can translate itself.
+type I18nHandler struct {
+	*State
+}
+
+// NewI18nHandler returns a new I18nHandler.
+func NewI18nHandler(s *State) *I18nHandler {
+	return &I18nHandler{State: s}
+}
+
+// I18nRequest is the request that can be sent to this endpoint.
+// If Lang is empty, the negotiated language is used.
+type I18nRequest struct {
+	Lang string `json:"lang"`
+}
+
+// I18nResponse is the response sent back by this endpoint.
+type I18nResponse struct {
+	Lang      string            `json:"lang"`
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
+
+func (ih *I18nHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lang := w.Header().Get("Content-Language")
+
+	req := I18nRequest{}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonifyErrf(w, http.StatusBadRequest, "bad json")
+			return
+		}
+	}
+
+	if req.Lang != "" {
+		if !i18n.IsSupported(req.Lang) {
+			jsonifyErrf(w, http.StatusNotFound, "no such language: %s", req.Lang)
+			return
+		}
+
+		lang = req.Lang
+	}
+
+	if lang == "" {
+		lang = i18n.DefaultLang
+	}
+
+	jsonify(w, http.StatusOK, I18nResponse{
+		Lang:      lang,
+		Languages: i18n.Languages(),
+		Messages:  i18n.Catalog(lang),
+	})
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestI18nEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		resp := s.mustRun(
+			t,
+			NewI18nHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/i18n",
+			I18nRequest{Lang: "de"},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		i18nResp := &I18nResponse{}
+		mustDecodeBody(t, resp.Body, &i18nResp)
+		require.Equal(t, "de", i18nResp.Lang)
+		require.Equal(t, []string{"de", "en"}, i18nResp.Languages)
+		require.Equal(t, "Anmelden", i18nResp.Messages["ui.login"])
+
+		resp = s.mustRun(
+			t,
+			NewI18nHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/i18n",
+			I18nRequest{Lang: "tlh"},
+		)
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestTranslatedErrors(t *testing.T) {
+	hdl := LanguageMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonifyErrf(w, http.StatusBadRequest, "failed to stat root %s: %v", "/photos", "boom")
+	}))
+
+	req := httptest.NewRequest("POST", "http://localhost:5000/api/v0/ls", nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	rsw := httptest.NewRecorder()
+	hdl.ServeHTTP(rsw, req)
+
+	resp := rsw.Result()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "de", resp.Header.Get("Content-Language"))
+
+	errResp := &ErrorResponse{}
+	mustDecodeBody(t, resp.Body, errResp)
+	require.False(t, errResp.Success)
+	require.Equal(t, "failed_to_stat_root", errResp.Code)
+	require.Equal(t, []string{"/photos", "boom"}, errResp.Params)
+	require.Equal(t, "Wurzel /photos konnte nicht gelesen werden: boom", errResp.Message)
+
+	// Messages that are not in the catalog get a code by their status:
+	hdl = LanguageMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonifyErrf(w, http.StatusNotFound, "something nobody translated")
+	}))
+
+	rsw = httptest.NewRecorder()
+	hdl.ServeHTTP(rsw, req)
+
+	errResp = &ErrorResponse{}
+	mustDecodeBody(t, rsw.Result().Body, errResp)
+	require.Equal(t, "not_found", errResp.Code)
+	require.Equal(t, "something nobody translated", errResp.Message)
+}
@@ -0,0 +1,187 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// Impersonation lets admins act as another user, e.g. to debug why
+// somebody can not see a folder. While impersonating, the session name
+// is the other user, so every endpoint sees exactly their rights and
+// folders. The admin is remembered as "impersonator" in the session;
+// every request made in this state is written to the audit log and
+// commits name the admin too.
+
+func getImpersonator(store *sessions.CookieStore, w http.ResponseWriter, r *http.Request) string {
+	sess, err := store.Get(r, "sess")
+	if err != nil {
+		return ""
+	}
+
+	impersonator, _ := sess.Values["impersonator"].(string)
+	return impersonator
+}
+
+// setImpersonation lets the session of `admin` act as `user`.
+// If `user` is empty, the session belongs to `admin` again.
+func setImpersonation(store *sessions.CookieStore, admin, user string, w http.ResponseWriter, r *http.Request) {
+	sess, _ := store.Get(r, "sess")
+	if user == "" {
+		delete(sess.Values, "impersonator")
+		setSession(store, admin, w, r)
+		return
+	}
+
+	sess.Values["impersonator"] = admin
+	setSession(store, user, w, r)
+}
+
+func auditImpersonation(admin, user, action string) {
+	log.WithFields(log.Fields{
+		"audit":  "impersonation",
+		"admin":  admin,
+		"user":   user,
+		"action": action,
+	}).Info("gateway: impersonation")
+}
+
+// mayImpersonate checks if `name` is (still) allowed to impersonate others.
+func (s *State) mayImpersonate(name string) bool {
+	user, err := s.userDb.Get(name)
+	if err != nil {
+		return false
+	}
+
+	return s.userDb.HasRight(user, db.RightAdminUsers)
+}
+
+// hasAllRightsOf checks if `admin` has every right of `target`,
+// so nobody gains rights by impersonating somebody else.
+func (s *State) hasAllRightsOf(admin string, target db.User) bool {
+	adminUser, err := s.userDb.Get(admin)
+	if err != nil {
+		return false
+	}
+
+	adminRights, err := s.userDb.EffectiveRights(adminUser)
+	if err != nil {
+		return false
+	}
+
+	targetRights, err := s.userDb.EffectiveRights(target)
+	if err != nil {
+		return false
+	}
+
+	has := make(map[string]bool)
+	for _, right := range adminRights {
+		has[right] = true
+	}
+
+	for _, right := range targetRights {
+		if !has[right] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkImpersonation returns the admin that impersonates the user of the
+// session, or an empty string if nobody does. If the admin lost the right
+// to do so or the user was removed, the session belongs to the admin again.
+func (s *State) checkImpersonation(w http.ResponseWriter, r *http.Request) string {
+	impersonator := getImpersonator(s.store, w, r)
+	if impersonator == "" {
+		return ""
+	}
+
+	name := getUserName(s.store, w, r)
+	if _, err := s.userDb.Get(name); err == nil && s.mayImpersonate(impersonator) {
+		return impersonator
+	}
+
+	auditImpersonation(impersonator, name, "ended")
+	setImpersonation(s.store, impersonator, "", w, r)
+	return ""
+}
+
+// ImpersonateHandler implements http.Handler.
+type ImpersonateHandler struct {
+	*State
+}
+
+// NewImpersonateHandler returns a new ImpersonateHandler.
+func NewImpersonateHandler(s *State) *ImpersonateHandler {
+	return &ImpersonateHandler{State: s}
+}
+
+// ImpersonateRequest is the request that can be sent to this endpoint as JSON.
+type ImpersonateRequest struct {
+	// User to act as. If empty, the admin acts as themselves again.
+	User string `json:"user"`
+}
+
+// ImpersonateResponse is the response sent back by this endpoint.
+type ImpersonateResponse struct {
+	Success      bool   `json:"success"`
+	User         string `json:"user"`
+	Impersonator string `json:"impersonator,omitempty"`
+}
+
+func (ih *ImpersonateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The rights of the impersonated user do not matter here,
+	// the admin has to be able to stop in any case:
+	impersonator := getImpersonator(ih.store, w, r)
+	admin := impersonator
+	if admin == "" {
+		admin = getUserName(ih.store, w, r)
+	}
+
+	if admin == "" || !ih.mayImpersonate(admin) {
+		jsonifyErrf(w, http.StatusUnauthorized, "insufficient rights")
+		return
+	}
+
+	impReq := ImpersonateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&impReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if impReq.User == "" || impReq.User == admin {
+		if impersonator != "" {
+			auditImpersonation(admin, getUserName(ih.store, w, r), "stop")
+		}
+
+		setImpersonation(ih.store, admin, "", w, r)
+		jsonify(w, http.StatusOK, &ImpersonateResponse{
+			Success: true,
+			User:    admin,
+		})
+		return
+	}
+
+	target, err := ih.userDb.Get(impReq.User)
+	if err != nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such user: %s", impReq.User)
+		return
+	}
+
+	if !ih.hasAllRightsOf(admin, target) {
+		jsonifyErrf(w, http.StatusUnauthorized, "insufficient rights")
+		return
+	}
+
+	auditImpersonation(admin, impReq.User, "start")
+	setImpersonation(ih.store, admin, impReq.User, w, r)
+	jsonify(w, http.StatusOK, &ImpersonateResponse{
+		Success:      true,
+		User:         impReq.User,
+		Impersonator: admin,
+	})
+}
@@ -0,0 +1,175 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sahib/brig/gateway/db"
+	"github.com/stretchr/testify/require"
+)
+
+// runWithSession runs `hdl` with the session in `cookie` and returns
+// the response together with the updated session cookie.
+func runWithSession(t *testing.T, hdl http.Handler, cookie *http.Cookie, body interface{}) (*http.Response, *http.Cookie) {
+	req := httptest.NewRequest("POST", "http://localhost:5000/api/v0/x", mustEncodeBody(t, body))
+	req.AddCookie(cookie)
+
+	rsw := httptest.NewRecorder()
+	hdl.ServeHTTP(rsw, req)
+	resp := rsw.Result()
+
+	for _, respCookie := range resp.Cookies() {
+		if respCookie.Name == "sess" {
+			cookie = respCookie
+		}
+	}
+
+	return resp, cookie
+}
+
+func TestImpersonate(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.userDb.Add("bob", "bob", []string{"/bob"}, []string{db.RightFsView}))
+
+		resp := s.mustRun(
+			t,
+			NewImpersonateHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/impersonate",
+			&ImpersonateRequest{User: "bob"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		impResp := ImpersonateResponse{}
+		mustDecodeBody(t, resp.Body, &impResp)
+		require.True(t, impResp.Success)
+		require.Equal(t, "bob", impResp.User)
+		require.Equal(t, "ali", impResp.Impersonator)
+
+		cookies := resp.Cookies()
+		cookie := cookies[len(cookies)-1]
+
+		resp, cookie = runWithSession(t, NewWhoamiHandler(s.State), cookie, nil)
+		whoamiResp := WhoamiResponse{}
+		mustDecodeBody(t, resp.Body, &whoamiResp)
+		require.Equal(t, "bob", whoamiResp.User)
+		require.True(t, whoamiResp.IsImpersonating)
+		require.Equal(t, "ali", whoamiResp.Impersonator)
+		require.Equal(t, []string{db.RightFsView}, whoamiResp.Rights)
+
+		// Requests are checked against the rights of bob:
+		needsAuth := AuthMiddleware(s.State)
+		resp, cookie = runWithSession(t, needsAuth(NewUsersOutdatedHandler(s.State)), cookie, nil)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		// ...but ali may still stop:
+		resp, cookie = runWithSession(t, needsAuth(NewImpersonateHandler(s.State)), cookie, &ImpersonateRequest{})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = runWithSession(t, NewWhoamiHandler(s.State), cookie, nil)
+		whoamiResp = WhoamiResponse{}
+		mustDecodeBody(t, resp.Body, &whoamiResp)
+		require.Equal(t, "ali", whoamiResp.User)
+		require.False(t, whoamiResp.IsImpersonating)
+	})
+}
+
+func TestImpersonateEndsWithoutRights(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.userDb.Add("bob", "bob", []string{"/bob"}, []string{db.RightFsView}))
+
+		resp := s.mustRun(
+			t,
+			NewImpersonateHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/impersonate",
+			&ImpersonateRequest{User: "bob"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		cookies := resp.Cookies()
+		cookie := cookies[len(cookies)-1]
+
+		// ali is no admin anymore; the session belongs to ali again:
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{db.RightFsView}))
+
+		resp, _ = runWithSession(t, NewWhoamiHandler(s.State), cookie, nil)
+		whoamiResp := WhoamiResponse{}
+		mustDecodeBody(t, resp.Body, &whoamiResp)
+		require.Equal(t, "ali", whoamiResp.User)
+		require.False(t, whoamiResp.IsImpersonating)
+
+		// Non-admins can not impersonate:
+		resp, _ = runWithSession(t, NewImpersonateHandler(s.State), cookie, &ImpersonateRequest{User: "bob"})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestImpersonateEndsOnLogin(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.userDb.Add("bob", "bob", []string{"/bob"}, []string{db.RightFsView}))
+
+		resp := s.mustRun(
+			t,
+			NewImpersonateHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/impersonate",
+			&ImpersonateRequest{User: "bob"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		cookies := resp.Cookies()
+		cookie := cookies[len(cookies)-1]
+
+		// bob logs in on the same browser and must not become ali:
+		resp, cookie = runWithSession(t, NewLoginHandler(s.State), cookie, &LoginRequest{
+			Username: "bob",
+			Password: "bob",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, cookie = runWithSession(t, NewWhoamiHandler(s.State), cookie, nil)
+		whoamiResp := WhoamiResponse{}
+		mustDecodeBody(t, resp.Body, &whoamiResp)
+		require.Equal(t, "bob", whoamiResp.User)
+		require.False(t, whoamiResp.IsImpersonating)
+
+		resp, cookie = runWithSession(t, NewImpersonateHandler(s.State), cookie, &ImpersonateRequest{})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, _ = runWithSession(t, NewWhoamiHandler(s.State), cookie, nil)
+		whoamiResp = WhoamiResponse{}
+		mustDecodeBody(t, resp.Body, &whoamiResp)
+		require.Equal(t, "bob", whoamiResp.User)
+	})
+}
+
+func TestImpersonateNeedsAllRights(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{db.RightAdminUsers, db.RightFsView}))
+		require.Nil(t, s.userDb.Add("bob", "bob", []string{"/"}, []string{db.RightFsView, db.RightFsEdit}))
+		require.Nil(t, s.userDb.Add("eve", "eve", []string{"/"}, []string{db.RightFsView}))
+
+		// bob may edit files, ali may not:
+		resp := s.mustRun(
+			t,
+			NewImpersonateHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/impersonate",
+			&ImpersonateRequest{User: "bob"},
+		)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = s.mustRun(
+			t,
+			NewImpersonateHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/impersonate",
+			&ImpersonateRequest{User: "eve"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
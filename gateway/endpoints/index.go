@@ -19,11 +19,13 @@ import (
 // It serves index.html from either file or memory.
 type IndexHandler struct {
 	*State
+	assets *AssetHandler
 }
 
 // NewIndexHandler returns a new IndexHandler.
-func NewIndexHandler(s *State) *IndexHandler {
-	return &IndexHandler{State: s}
+// Links to static files are resolved with `assets`.
+func NewIndexHandler(s *State, assets *AssetHandler) *IndexHandler {
+	return &IndexHandler{State: s, assets: assets}
 }
 
 func (ih *IndexHandler) loadTemplateData() (io.ReadCloser, error) {
@@ -50,7 +52,9 @@ func (ih *IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, err := template.New("index").Parse(string(data))
+	t, err := template.New("index").Funcs(template.FuncMap{
+		"asset": ih.assets.URL,
+	}).Parse(string(data))
 	if err != nil {
 		log.Errorf("could not parse template: %v", err)
 		jsonifyErrf(w, http.StatusInternalServerError, "template contains errors")
@@ -67,6 +71,12 @@ func (ih *IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		httpScheme = "https://"
 	}
 
+	if ih.cfg.Bool("ui.http2_push") {
+		pushAssets(w, ih.assets, UIPreloadAssets)
+	}
+
+	// index.html contains a csrf token and must never be cached:
+	w.Header().Set("Cache-Control", "no-store")
 	err = t.Execute(w, map[string]interface{}{
 		"csrfToken": csrf.Token(r),
 		"wsAddr":    wsScheme + r.Host + "/events",
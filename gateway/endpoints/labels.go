@@ -0,0 +1,184 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// LabelsHandler implements http.Handler.
+// It adds labels to a file or removes them, depending on `remove`.
+type LabelsHandler struct {
+	*State
+	remove bool
+}
+
+// NewLabelsAddHandler returns a handler that adds labels.
+func NewLabelsAddHandler(s *State) *LabelsHandler {
+	return &LabelsHandler{State: s}
+}
+
+// NewLabelsRemoveHandler returns a handler that removes labels.
+func NewLabelsRemoveHandler(s *State) *LabelsHandler {
+	return &LabelsHandler{State: s, remove: true}
+}
+
+// LabelsRequest is the request that can be sent to this endpoint as JSON.
+type LabelsRequest struct {
+	// Path of the file or directory to change.
+	Path string `json:"path"`
+	// Labels to add or to remove.
+	Labels []string `json:"labels"`
+}
+
+// LabelsResponse is the response sent back by this endpoint.
+type LabelsResponse struct {
+	Success bool `json:"success"`
+	// Labels are all labels of the file after the change.
+	Labels []string `json:"labels"`
+}
+
+func (lh *LabelsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsEdit) {
+		return
+	}
+
+	labelsReq := LabelsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&labelsReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	path := prefixRoot(labelsReq.Path)
+	if !lh.validatePath(path, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	info, err := lh.fs.Stat(path)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "failed to stat %s", path)
+		return
+	}
+
+	labels := changeLabels(info.Labels, labelsReq.Labels, lh.remove)
+	if err := lh.fs.SetLabels(path, labels); err != nil {
+		log.Debugf("failed to set labels of %s: %v", path, err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to set labels")
+		return
+	}
+
+	verb := "labeled"
+	if lh.remove {
+		verb = "unlabeled"
+	}
+
+	msg := fmt.Sprintf("%s »%s« (%s)", verb, path, strings.Join(labelsReq.Labels, ", "))
+	if !lh.commitChange(msg, w, r) {
+		return
+	}
+
+	info, err = lh.fs.Stat(path)
+	if err != nil {
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to stat %s", path)
+		return
+	}
+
+	jsonify(w, http.StatusOK, &LabelsResponse{
+		Success: true,
+		Labels:  info.Labels,
+	})
+}
+
+// changeLabels adds `change` to `labels` or removes it from there.
+func changeLabels(labels, change []string, remove bool) []string {
+	if !remove {
+		return append(labels, change...)
+	}
+
+	drop := make(map[string]bool)
+	for _, label := range change {
+		drop[strings.TrimSpace(label)] = true
+	}
+
+	kept := []string{}
+	for _, label := range labels {
+		if !drop[label] {
+			kept = append(kept, label)
+		}
+	}
+
+	return kept
+}
+
+// LabelsListHandler implements http.Handler.
+type LabelsListHandler struct {
+	*State
+}
+
+// NewLabelsListHandler returns a new LabelsListHandler.
+func NewLabelsListHandler(s *State) *LabelsListHandler {
+	return &LabelsListHandler{State: s}
+}
+
+// LabelsListRequest is the request that can be sent to this endpoint as JSON.
+type LabelsListRequest struct {
+	// Root is the directory to look for labels in.
+	Root string `json:"root"`
+}
+
+// LabelCount is a single label and the number of files that carry it.
+type LabelCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// LabelsListResponse is the response sent back by this endpoint.
+type LabelsListResponse struct {
+	Success bool `json:"success"`
+	// Labels is sorted by name.
+	Labels []LabelCount `json:"labels"`
+}
+
+func (lh *LabelsListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsView) {
+		return
+	}
+
+	listReq := LabelsListRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&listReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	root := prefixRoot(listReq.Root)
+	if !lh.validatePath(root, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	counts, err := lh.fs.LabelCounts(root)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "failed to list labels: %v", err)
+		return
+	}
+
+	labels := []LabelCount{}
+	for name, count := range counts {
+		labels = append(labels, LabelCount{Name: name, Count: count})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+
+	jsonify(w, http.StatusOK, &LabelsListResponse{
+		Success: true,
+		Labels:  labels,
+	})
+}
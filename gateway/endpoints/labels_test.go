@@ -0,0 +1,99 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelsEndpoints(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/docs/a.txt", bytes.NewReader([]byte("a"))))
+		require.Nil(t, s.fs.Stage("/docs/sub/b.txt", bytes.NewReader([]byte("b"))))
+		require.Nil(t, s.fs.Stage("/c.txt", bytes.NewReader([]byte("c"))))
+
+		resp := s.mustRun(
+			t,
+			NewLabelsAddHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/labels/add",
+			&LabelsRequest{Path: "/docs/a.txt", Labels: []string{"todo", "work"}},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		labelsResp := &LabelsResponse{}
+		mustDecodeBody(t, resp.Body, &labelsResp)
+		require.Equal(t, []string{"todo", "work"}, labelsResp.Labels)
+
+		resp = s.mustRun(
+			t,
+			NewLabelsAddHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/labels/add",
+			&LabelsRequest{Path: "/docs/sub/b.txt", Labels: []string{"work"}},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = s.mustRun(
+			t,
+			NewLabelsRemoveHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/labels/remove",
+			&LabelsRequest{Path: "/docs/a.txt", Labels: []string{"todo"}},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		labelsResp = &LabelsResponse{}
+		mustDecodeBody(t, resp.Body, &labelsResp)
+		require.Equal(t, []string{"work"}, labelsResp.Labels)
+
+		// Listing by label finds files in sub directories too:
+		resp = s.mustRun(
+			t,
+			NewLsHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/ls",
+			&LsRequest{Root: "/", Label: "work"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		lsResp := &LsResponse{}
+		mustDecodeBody(t, resp.Body, &lsResp)
+		require.True(t, lsResp.IsFiltered)
+		require.Len(t, lsResp.Files, 2)
+		require.Equal(t, "/docs/a.txt", lsResp.Files[0].Path)
+		require.Equal(t, []string{"work"}, lsResp.Files[0].Labels)
+		require.Equal(t, "/docs/sub/b.txt", lsResp.Files[1].Path)
+
+		resp = s.mustRun(
+			t,
+			NewLabelsListHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/labels/list",
+			&LabelsListRequest{Root: "/"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		listResp := &LabelsListResponse{}
+		mustDecodeBody(t, resp.Body, &listResp)
+		require.Equal(t, []LabelCount{{Name: "work", Count: 2}}, listResp.Labels)
+	})
+}
+
+func TestLabelsEndpointInvalidPath(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/c.txt", bytes.NewReader([]byte("c"))))
+		s.mustChangeFolders(t, "/something/else")
+
+		resp := s.mustRun(
+			t,
+			NewLabelsAddHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/labels/add",
+			&LabelsRequest{Path: "/c.txt", Labels: []string{"work"}},
+		)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -26,6 +26,8 @@ type LogRequest struct {
 	Offset int64  `json:"offset"`
 	Limit  int64  `json:"limit"`
 	Filter string `json:"filter"`
+	// Label only shows commits with this label, if not empty.
+	Label string `json:"label"`
 }
 
 // LogResponse is the response sent back to the client.
@@ -79,6 +81,10 @@ func (lh *LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return nil
 		}
 
+		if logReq.Label != "" && !cmt.HasLabel(logReq.Label) {
+			return nil
+		}
+
 		if logReq.Limit >= 0 && int64(len(commits)) >= logReq.Limit {
 			return errSkip
 		}
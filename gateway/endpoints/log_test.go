@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/sahib/brig/catfs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -49,3 +50,36 @@ func TestLogEndpointSuccess(t *testing.T) {
 		require.Equal(t, []string{"init"}, data.Commits[3].Tags)
 	})
 }
+
+func TestLogEndpointLabel(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.MakeCommitWithMeta("hello", catfs.CommitMeta{
+			Origin: catfs.CommitOriginCLI,
+			Labels: []string{"backup"},
+		}))
+		require.Nil(t, s.fs.Stage("/x", bytes.NewReader([]byte("world"))))
+		require.Nil(t, s.fs.MakeCommit("world"))
+
+		resp := s.mustRun(
+			t,
+			NewLogHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/log",
+			&LogRequest{
+				Offset: 0,
+				Limit:  -1,
+				Label:  "backup",
+			},
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data := &LogResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.Equal(t, 1, len(data.Commits))
+		require.Equal(t, "hello", data.Commits[0].Msg)
+		require.Equal(t, "cli", data.Commits[0].Origin)
+		require.Equal(t, []string{"backup"}, data.Commits[0].Labels)
+	})
+}
@@ -63,6 +63,9 @@ func clearSession(store *sessions.CookieStore, w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Do not leave anything behind that a later login could inherit:
+	delete(sess.Values, "name")
+	delete(sess.Values, "impersonator")
 	sess.Options.MaxAge = -1
 	if err := sess.Save(r, w); err != nil {
 		log.Warningf("clear: failed to save session: %v", err)
@@ -133,19 +136,44 @@ func (lih *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	upgradePasswordHash(lih.userDb, dbUser.Name, loginReq.Password)
+
 	anonIsAllowed := lih.cfg.Bool("auth.anon_allowed")
 	anonUserName := lih.cfg.String("auth.anon_user")
 
-	setSession(lih.store, dbUser.Name, w, r)
+	rights, err := lih.userDb.EffectiveRights(dbUser)
+	if err != nil {
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to get rights")
+		return
+	}
+
+	// A fresh login never continues an impersonation that is still
+	// stored in the session of this browser:
+	setImpersonation(lih.store, dbUser.Name, "", w, r)
 	jsonify(w, http.StatusOK, &LoginResponse{
 		Success:       true,
 		Username:      loginReq.Username,
-		Rights:        dbUser.Rights,
+		Rights:        rights,
 		IsAnon:        anonUserName == loginReq.Username,
 		AnonIsAllowed: anonIsAllowed,
 	})
 }
 
+// upgradePasswordHash re-hashes the password of `name` if the stored hash
+// is outdated. This is only possible right after a successful login,
+// since it is the only time we see the password.
+func upgradePasswordHash(userDb *db.UserDatabase, name, password string) {
+	updated, err := userDb.Rehash(name, password)
+	if err != nil {
+		log.Warningf("failed to upgrade password hash of %s: %v", name, err)
+		return
+	}
+
+	if updated {
+		log.Infof("upgraded password hash of gateway user %s", name)
+	}
+}
+
 ///////
 
 // LogoutHandler implements http.Handler
@@ -189,6 +217,16 @@ type WhoamiResponse struct {
 	AnonIsAllowed bool     `json:"anon_is_allowed"`
 	User          string   `json:"user"`
 	Rights        []string `json:"rights"`
+
+	// IsImpersonating is true if an admin acts as User.
+	// The UI should show this prominently.
+	IsImpersonating bool   `json:"is_impersonating"`
+	Impersonator    string `json:"impersonator,omitempty"`
+
+	// Announcement is a message of the admins that the UI should show.
+	Announcement string `json:"announcement"`
+	// Maintenance is true if the gateway is read-only right now.
+	Maintenance bool `json:"maintenance"`
 }
 
 func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -196,6 +234,7 @@ func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	isAnon := false
 
 	anonIsAllowed := wh.cfg.Bool("auth.anon_allowed")
+	impersonator := wh.checkImpersonation(w, r)
 	name := getUserName(wh.store, w, r)
 
 	if name == "" && anonIsAllowed {
@@ -207,8 +246,10 @@ func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		possiblyAnonUser, err := wh.userDb.Get(name)
 		if err != nil {
 			log.Warningf("could not get user »%s« : %v", name, err)
+		} else if rights, err = wh.userDb.EffectiveRights(possiblyAnonUser); err != nil {
+			log.Warningf("could not get rights of »%s« : %v", name, err)
+			rights = []string{}
 		} else {
-			rights = possiblyAnonUser.Rights
 			setSession(wh.store, name, w, r)
 		}
 	}
@@ -219,6 +260,12 @@ func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		AnonIsAllowed: anonIsAllowed,
 		User:          name,
 		Rights:        rights,
+
+		IsImpersonating: impersonator != "",
+		Impersonator:    impersonator,
+
+		Announcement: wh.announcement(),
+		Maintenance:  wh.inMaintenance(),
 	})
 }
 
@@ -233,6 +280,7 @@ type dbUserKey string
 
 func (am *authMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	anonIsAllowed := am.cfg.Bool("auth.anon_allowed")
+	impersonator := am.checkImpersonation(w, r)
 	name := getUserName(am.store, w, r)
 
 	if name == "" {
@@ -253,13 +301,26 @@ func (am *authMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r = r.WithContext(
-		context.WithValue(r.Context(), dbUserKey("brig.db_user"),
-			user,
-		),
-	)
+	// Resolve groups and wildcards once, so checkRights stays cheap:
+	rights, err := am.userDb.EffectiveRights(user)
+	if err != nil {
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to get rights")
+		return
+	}
+
+	if impersonator != "" {
+		log.WithFields(log.Fields{
+			"audit":  "impersonation",
+			"admin":  impersonator,
+			"user":   name,
+			"action": "request",
+			"url":    r.URL.Path,
+		}).Info("gateway: request of impersonated user")
+	}
 
-	am.SubHandler.ServeHTTP(w, r)
+	ctx := context.WithValue(r.Context(), dbUserKey("brig.db_user"), user)
+	ctx = context.WithValue(ctx, dbUserKey("brig.db_rights"), rights)
+	am.SubHandler.ServeHTTP(w, r.WithContext(ctx))
 }
 
 func checkRights(w http.ResponseWriter, r *http.Request, rights ...string) bool {
@@ -270,8 +331,14 @@ func checkRights(w http.ResponseWriter, r *http.Request, rights ...string) bool
 		return false
 	}
 
+	// Without groups, the rights can be expanded without the database:
+	userRights, ok := r.Context().Value(dbUserKey("brig.db_rights")).([]string)
+	if !ok {
+		userRights = db.ExpandRights(user.Rights)
+	}
+
 	rmap := make(map[string]bool)
-	for _, right := range user.Rights {
+	for _, right := range userRights {
 		rmap[right] = true
 	}
 
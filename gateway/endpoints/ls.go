@@ -24,21 +24,26 @@ func NewLsHandler(s *State) *LsHandler {
 type LsRequest struct {
 	Root   string `json:"root"`
 	Filter string `json:"filter,omitempty"`
+	// Label lists only files and directories below Root with this label.
+	Label string `json:"label,omitempty"`
 }
 
 // StatInfo is a single node in the list response.
 // It is the same as catfs.StatInfo, but is more JSON friendly
 // and omits some fields like hashes that are not useful to the client.
 type StatInfo struct {
-	Path       string `json:"path"`
-	User       string `json:"user"`
-	Size       uint64 `json:"size"`
-	Inode      uint64 `json:"inode"`
-	Depth      int    `json:"depth"`
-	ModTime    int64  `json:"last_modified_ms"`
-	IsDir      bool   `json:"is_dir"`
-	IsPinned   bool   `json:"is_pinned"`
-	IsExplicit bool   `json:"is_explicit"`
+	Path       string   `json:"path"`
+	User       string   `json:"user"`
+	Size       uint64   `json:"size"`
+	Inode      uint64   `json:"inode"`
+	Depth      int      `json:"depth"`
+	ModTime    int64    `json:"last_modified_ms"`
+	IsDir      bool     `json:"is_dir"`
+	IsPinned   bool     `json:"is_pinned"`
+	IsExplicit bool     `json:"is_explicit"`
+	MimeType   string   `json:"mime_type"`
+	Kind       string   `json:"kind"`
+	Labels     []string `json:"labels"`
 }
 
 func toExternalStatInfo(i *catfs.StatInfo) *StatInfo {
@@ -52,6 +57,9 @@ func toExternalStatInfo(i *catfs.StatInfo) *StatInfo {
 		IsDir:      i.IsDir,
 		IsPinned:   i.IsPinned,
 		IsExplicit: i.IsExplicit,
+		MimeType:   i.MimeType,
+		Kind:       i.Kind,
+		Labels:     append([]string{}, i.Labels...),
 	}
 }
 
@@ -63,12 +71,42 @@ type LsResponse struct {
 	IsFiltered bool        `json:"is_filtered"`
 }
 
-func doQuery(fs *catfs.FS, root, filter string) ([]*catfs.StatInfo, error) {
-	if filter == "" {
+func doQuery(fs *catfs.FS, root, filter, label string) ([]*catfs.StatInfo, error) {
+	var items []*catfs.StatInfo
+	var err error
+
+	switch {
+	case filter != "":
+		items, err = fs.Filter(root, filter)
+	case label != "":
+		// Labeled files might be anywhere below root:
+		items, err = fs.List(root, -1)
+	default:
 		return fs.List(root, 1)
 	}
 
-	return fs.Filter(root, filter)
+	if err != nil || label == "" {
+		return items, err
+	}
+
+	labeled := []*catfs.StatInfo{}
+	for _, item := range items {
+		if item.Path != root && hasLabel(item, label) {
+			labeled = append(labeled, item)
+		}
+	}
+
+	return labeled, nil
+}
+
+func hasLabel(info *catfs.StatInfo, label string) bool {
+	for _, l := range info.Labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (lh *LsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -89,7 +127,7 @@ func (lh *LsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, err := doQuery(lh.fs, root, lsReq.Filter)
+	items, err := doQuery(lh.fs, root, lsReq.Filter, lsReq.Label)
 	if err != nil {
 		jsonifyErrf(w, http.StatusBadRequest, "failed to query: %v", err)
 		return
@@ -116,7 +154,7 @@ func (lh *LsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	jsonify(w, http.StatusOK, &LsResponse{
 		Success:    true,
 		Files:      files,
-		IsFiltered: len(lsReq.Filter) > 0,
+		IsFiltered: len(lsReq.Filter) > 0 || len(lsReq.Label) > 0,
 		Self:       toExternalStatInfo(info),
 	})
 }
@@ -0,0 +1,140 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// Maintenance mode makes the gateway read-only, e.g. while data is
+// migrated or the repository is pruned. Endpoints that modify the
+// filesystem or the remote list are wrapped by MaintenanceMiddleware
+// and answer with 503 then. The announcement is independent of it and
+// is sent with every response, so the UI (and scripts) can show it.
+// Both are stored in the config, so they can also be set with
+// `brig cfg set gateway.maintenance.*` and survive restarts.
+
+// announcement returns the current announcement as single line,
+// so it can be used as header value.
+func (s *State) announcement() string {
+	return strings.Join(strings.Fields(s.cfg.String("maintenance.message")), " ")
+}
+
+// inMaintenance tells if the gateway is read-only right now.
+func (s *State) inMaintenance() bool {
+	return s.cfg.Bool("maintenance.enabled")
+}
+
+// MaintenanceHandler implements http.Handler.
+// It lets admins enable maintenance mode and set the announcement.
+type MaintenanceHandler struct {
+	*State
+}
+
+// NewMaintenanceHandler returns a new MaintenanceHandler.
+func NewMaintenanceHandler(s *State) *MaintenanceHandler {
+	return &MaintenanceHandler{State: s}
+}
+
+// MaintenanceRequest is the request that can be sent to this endpoint as JSON.
+// Fields that are not given are not changed.
+type MaintenanceRequest struct {
+	Enabled *bool   `json:"enabled"`
+	Message *string `json:"message"`
+}
+
+// MaintenanceResponse is the state after the request.
+type MaintenanceResponse struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+func (mh *MaintenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightAdminUsers) {
+		return
+	}
+
+	maintReq := MaintenanceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&maintReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	name := getUserName(mh.store, w, r)
+	if maintReq.Message != nil {
+		if err := mh.cfg.SetString("maintenance.message", *maintReq.Message); err != nil {
+			jsonifyErrf(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+
+		log.Infof("gateway: »%s« set the announcement to »%s«", name, mh.announcement())
+	}
+
+	if maintReq.Enabled != nil {
+		if err := mh.cfg.SetBool("maintenance.enabled", *maintReq.Enabled); err != nil {
+			jsonifyErrf(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+
+		log.Infof("gateway: »%s« set maintenance mode to %t", name, *maintReq.Enabled)
+	}
+
+	jsonify(w, http.StatusOK, MaintenanceResponse{
+		Enabled: mh.inMaintenance(),
+		Message: mh.announcement(),
+	})
+}
+
+///////
+
+type maintenanceMiddleware struct {
+	*State
+	SubHandler http.Handler
+}
+
+func (mm *maintenanceMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if mm.inMaintenance() {
+		w.Header().Set("Retry-After", "300")
+		jsonifyErrf(w, http.StatusServiceUnavailable, "the gateway is read-only during maintenance")
+		return
+	}
+
+	mm.SubHandler.ServeHTTP(w, r)
+}
+
+// MaintenanceMiddleware returns a new handler wrapper for endpoints
+// that modify something. They are refused in maintenance mode.
+func MaintenanceMiddleware(s *State) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return &maintenanceMiddleware{State: s, SubHandler: h}
+	}
+}
+
+type announcementMiddleware struct {
+	*State
+	SubHandler http.Handler
+}
+
+func (am *announcementMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hdr := w.Header()
+	if msg := am.announcement(); msg != "" {
+		hdr.Set("X-Brig-Announcement", msg)
+	}
+
+	if am.inMaintenance() {
+		hdr.Set("X-Brig-Maintenance", "true")
+	}
+
+	am.SubHandler.ServeHTTP(w, r)
+}
+
+// AnnouncementMiddleware adds the announcement and the maintenance state
+// as X-Brig-Announcement and X-Brig-Maintenance header to every response.
+func AnnouncementMiddleware(s *State) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return &announcementMiddleware{State: s, SubHandler: h}
+	}
+}
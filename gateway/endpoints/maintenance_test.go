@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const maintenanceURL = "http://localhost:5000/api/v0/maintenance"
+
+func TestMaintenance(t *testing.T) {
+	withState(t, func(s *testState) {
+		enabled, message := true, "Pruning\nuntil 5pm"
+		resp := s.mustRun(t, NewMaintenanceHandler(s.State), "POST", maintenanceURL, &MaintenanceRequest{
+			Enabled: &enabled,
+			Message: &message,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		maintResp := &MaintenanceResponse{}
+		mustDecodeBody(t, resp.Body, maintResp)
+		require.True(t, maintResp.Enabled)
+		require.Equal(t, "Pruning until 5pm", maintResp.Message)
+
+		// Changes are refused now, but reading still works:
+		mkdirHdl := AnnouncementMiddleware(s.State)(MaintenanceMiddleware(s.State)(NewMkdirHandler(s.State)))
+		resp = s.mustRun(t, mkdirHdl, "POST", "http://localhost:5000/api/v0/mkdir", &MkdirRequest{
+			Path: "/test",
+		})
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Equal(t, "Pruning until 5pm", resp.Header.Get("X-Brig-Announcement"))
+		require.Equal(t, "true", resp.Header.Get("X-Brig-Maintenance"))
+
+		_, err := s.fs.Stat("/test")
+		require.NotNil(t, err)
+
+		lsHdl := AnnouncementMiddleware(s.State)(NewLsHandler(s.State))
+		resp = s.mustRun(t, lsHdl, "POST", "http://localhost:5000/api/v0/ls", &LsRequest{
+			Root: "/",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "Pruning until 5pm", resp.Header.Get("X-Brig-Announcement"))
+
+		// Only switch it off again, the announcement stays:
+		enabled = false
+		resp = s.mustRun(t, NewMaintenanceHandler(s.State), "POST", maintenanceURL, &MaintenanceRequest{
+			Enabled: &enabled,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = s.mustRun(t, mkdirHdl, "POST", "http://localhost:5000/api/v0/mkdir", &MkdirRequest{
+			Path: "/test",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "Pruning until 5pm", resp.Header.Get("X-Brig-Announcement"))
+		require.Empty(t, resp.Header.Get("X-Brig-Maintenance"))
+
+		// Only admins may change it:
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{"fs.view", "fs.edit"}))
+		resp = s.mustRun(t, NewMaintenanceHandler(s.State), "POST", maintenanceURL, &MaintenanceRequest{
+			Enabled: &enabled,
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
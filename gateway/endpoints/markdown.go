@@ -0,0 +1,202 @@
+package endpoints
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This is a small markdown renderer for the preview of the editor.
+// It supports the commonly used subset (headings, paragraphs, lists,
+// quotes, code blocks, emphasis, inline code and links). All input is
+// escaped, so no raw html of the document makes it into the output.
+
+var (
+	mdHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRule       = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdUnordered  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	mdOrdered    = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	mdQuote      = regexp.MustCompile(`^\s*&gt;\s?(.*)$`)
+	mdFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	mdLink       = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]*)\)`)
+	mdStrong     = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdEmphasis   = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)?)[*_]([^\w*]|$)`)
+	mdSafeScheme = regexp.MustCompile(`^(?i:https?:|mailto:|/|\.|#|[^:]*$)`)
+	mdLinkMarker = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// renderEmphasis renders strong and emphasized text.
+func renderEmphasis(text string) string {
+	text = mdStrong.ReplaceAllString(text, "<strong>$2</strong>")
+	return mdEmphasis.ReplaceAllString(text, "$1<em>$2</em>$3")
+}
+
+// renderInline renders the span elements of an already escaped line.
+func renderInline(line string) string {
+	// Content of code spans must not be formatted further,
+	// so only every second part (outside of backticks) is formatted.
+	parts := strings.Split(line, "`")
+	if len(parts)%2 == 0 {
+		// Unbalanced backtick; treat the last one literally.
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	out := &strings.Builder{}
+	for idx, part := range parts {
+		if idx%2 == 1 {
+			out.WriteString("<code>" + part + "</code>")
+			continue
+		}
+
+		// Links are replaced by markers while emphasis is rendered,
+		// so that the link targets can not be modified by it.
+		links := []string{}
+		part = mdLink.ReplaceAllStringFunc(part, func(match string) string {
+			sub := mdLink.FindStringSubmatch(match)
+			text, url := renderEmphasis(sub[1]), html.UnescapeString(sub[2])
+			if mdSafeScheme.MatchString(url) {
+				text = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), text)
+			}
+
+			links = append(links, text)
+			return fmt.Sprintf("\x00%d\x00", len(links)-1)
+		})
+
+		part = renderEmphasis(part)
+		part = mdLinkMarker.ReplaceAllStringFunc(part, func(marker string) string {
+			idx, _ := strconv.Atoi(mdLinkMarker.FindStringSubmatch(marker)[1])
+			return links[idx]
+		})
+
+		out.WriteString(part)
+	}
+
+	return out.String()
+}
+
+type mdRenderer struct {
+	out       strings.Builder
+	paragraph []string
+	list      string
+	quote     []string
+}
+
+func (md *mdRenderer) flushParagraph() {
+	if len(md.paragraph) == 0 {
+		return
+	}
+
+	md.out.WriteString("<p>" + renderInline(strings.Join(md.paragraph, " ")) + "</p>\n")
+	md.paragraph = nil
+}
+
+func (md *mdRenderer) closeList() {
+	if md.list != "" {
+		md.out.WriteString("</" + md.list + ">\n")
+		md.list = ""
+	}
+}
+
+func (md *mdRenderer) flushQuote() {
+	if len(md.quote) == 0 {
+		return
+	}
+
+	md.out.WriteString("<blockquote>\n")
+	md.out.WriteString(renderMarkdown(html.UnescapeString(strings.Join(md.quote, "\n"))))
+	md.out.WriteString("</blockquote>\n")
+	md.quote = nil
+}
+
+func (md *mdRenderer) flush() {
+	md.flushParagraph()
+	md.closeList()
+	md.flushQuote()
+}
+
+func (md *mdRenderer) listItem(kind, content string) {
+	md.flushParagraph()
+	md.flushQuote()
+	if md.list != kind {
+		md.closeList()
+		md.out.WriteString("<" + kind + ">\n")
+		md.list = kind
+	}
+
+	md.out.WriteString("<li>" + renderInline(content) + "</li>\n")
+}
+
+// renderMarkdown converts the markdown in `src` to html.
+func renderMarkdown(src string) string {
+	md := &mdRenderer{}
+
+	// NUL bytes are used internally as link markers.
+	src = strings.Replace(src, "\x00", "\uFFFD", -1)
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+
+	for idx := 0; idx < len(lines); idx++ {
+		line := html.EscapeString(lines[idx])
+
+		if fence := mdFence.FindStringSubmatch(line); fence != nil {
+			md.flush()
+
+			code := []string{}
+			for idx++; idx < len(lines); idx++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[idx]), fence[1]) {
+					break
+				}
+
+				code = append(code, html.EscapeString(lines[idx]))
+			}
+
+			md.out.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>\n")
+			continue
+		}
+
+		if match := mdQuote.FindStringSubmatch(line); match != nil {
+			md.flushParagraph()
+			md.closeList()
+			md.quote = append(md.quote, match[1])
+			continue
+		}
+
+		md.flushQuote()
+
+		if strings.TrimSpace(line) == "" {
+			md.flush()
+			continue
+		}
+
+		if mdRule.MatchString(line) {
+			md.flush()
+			md.out.WriteString("<hr>\n")
+			continue
+		}
+
+		if match := mdHeading.FindStringSubmatch(line); match != nil {
+			md.flush()
+			level := len(match[1])
+			md.out.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, renderInline(match[2]), level))
+			continue
+		}
+
+		if match := mdUnordered.FindStringSubmatch(line); match != nil {
+			md.listItem("ul", match[1])
+			continue
+		}
+
+		if match := mdOrdered.FindStringSubmatch(line); match != nil {
+			md.listItem("ol", match[1])
+			continue
+		}
+
+		md.closeList()
+		md.paragraph = append(md.paragraph, strings.TrimSpace(line))
+	}
+
+	md.flush()
+	return md.out.String()
+}
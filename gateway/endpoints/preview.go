@@ -0,0 +1,256 @@
+package endpoints
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	// Needed to read the size of thumbnails:
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// LinkPrefix is where deep links to files are served.
+const LinkPrefix = "/link"
+
+// linkPreview is what is shown when a link is pasted into a chat app.
+// Everything except the title and the urls is only filled when the file
+// may be seen by the one asking, so crawlers learn nothing about private files.
+type linkPreview struct {
+	Title       string
+	Description string
+	URL         string
+	Target      string
+	OEmbedURL   string
+
+	// Image is only set for images that anonymous users may download,
+	// since the crawler will fetch it without any login.
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+}
+
+var linkTemplate = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{ .Title }}</title>
+    <meta property="og:site_name" content="brig">
+    <meta property="og:type" content="website">
+    <meta property="og:title" content="{{ .Title }}">
+    <meta property="og:url" content="{{ .URL }}">
+    {{ if .Description }}<meta property="og:description" content="{{ .Description }}">
+    <meta name="description" content="{{ .Description }}">{{ end }}
+    {{ if .Image }}<meta property="og:image" content="{{ .Image }}">
+    <meta property="og:image:width" content="{{ .ImageWidth }}">
+    <meta property="og:image:height" content="{{ .ImageHeight }}">
+    <meta name="twitter:card" content="summary_large_image">{{ else }}<meta name="twitter:card" content="summary">{{ end }}
+    <link rel="alternate" type="application/json+oembed" href="{{ .OEmbedURL }}" title="{{ .Title }}">
+    <meta http-equiv="refresh" content="0; url={{ .Target }}">
+  </head>
+  <body>
+    <p><a href="{{ .Target }}">{{ .Title }}</a></p>
+  </body>
+</html>
+`))
+
+// LinkHandler implements http.Handler.
+// It serves deep links to files with Open Graph metadata for previews
+// and sends browsers on to the UI (or the download, if there is no UI).
+type LinkHandler struct {
+	*State
+}
+
+// NewLinkHandler returns a new LinkHandler.
+func NewLinkHandler(s *State) *LinkHandler {
+	return &LinkHandler{State: s}
+}
+
+func baseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+
+	return "http://" + r.Host
+}
+
+// escapeNodePath escapes every element of `nodePath` for use in an url.
+func escapeNodePath(nodePath string) string {
+	return (&url.URL{Path: nodePath}).EscapedPath()
+}
+
+// anonMayDownload checks if `nodePath` can be downloaded without login.
+func (s *State) anonMayDownload(nodePath string, w http.ResponseWriter, r *http.Request) bool {
+	if !s.cfg.Bool("auth.anon_allowed") {
+		return false
+	}
+
+	anon, err := s.userDb.Get(s.cfg.String("auth.anon_user"))
+	if err != nil {
+		return false
+	}
+
+	return s.userDb.HasRight(anon, db.RightDownload) && s.validatePathForUser(nodePath, anon, w, r)
+}
+
+// imageSize returns the dimensions of the image at `nodePath`.
+// Only the header of the file is read.
+func (s *State) imageSize(nodePath string) (int, int, error) {
+	stream, err := s.fs.Cat(nodePath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer stream.Close()
+
+	cfg, _, err := image.DecodeConfig(stream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return cfg.Width, cfg.Height, nil
+}
+
+// buildLinkPreview returns the preview of `nodePath` as seen by the sender of `r`.
+// The second return value is false if the file may not be seen.
+func (s *State) buildLinkPreview(nodePath string, w http.ResponseWriter, r *http.Request) (*linkPreview, bool) {
+	base := baseURL(r)
+	escaped := escapeNodePath(nodePath)
+
+	target := "/get" + escaped
+	if s.cfg.Bool("ui.enabled") {
+		target = "/view" + escaped
+	}
+
+	linkURL := base + LinkPrefix + escaped
+	preview := &linkPreview{
+		Title:     "brig",
+		URL:       linkURL,
+		Target:    target,
+		OEmbedURL: base + "/oembed?format=json&url=" + url.QueryEscape(linkURL),
+	}
+
+	isPublic := s.anonMayDownload(nodePath, w, r)
+	if !isPublic && !s.validatePath(nodePath, w, r) {
+		return preview, false
+	}
+
+	info, err := s.fs.Stat(nodePath)
+	if err != nil {
+		return preview, false
+	}
+
+	preview.Title = path.Base(info.Path)
+	if info.Path == "/" {
+		preview.Title = "/"
+	}
+
+	if info.IsDir {
+		preview.Description = fmt.Sprintf("Folder · %s", humanize.Bytes(info.Size))
+	} else {
+		preview.Description = fmt.Sprintf("%s · %s", humanize.Bytes(info.Size), info.MimeType)
+	}
+
+	preview.Description += " · modified " + info.ModTime.Format("2006-01-02")
+
+	if isPublic && !info.IsDir && strings.HasPrefix(info.MimeType, "image/") {
+		width, height, err := s.imageSize(nodePath)
+		if err != nil {
+			log.Debugf("link preview: no image size for %s: %v", nodePath, err)
+		} else {
+			preview.Image = base + "/get" + escaped
+			preview.ImageWidth = width
+			preview.ImageHeight = height
+		}
+	}
+
+	return preview, true
+}
+
+func (lh *LinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !lh.cfg.Bool("link_previews.enabled") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	nodePath := prefixRoot(path.Clean("/" + strings.TrimPrefix(r.URL.Path, LinkPrefix)))
+	preview, _ := lh.buildLinkPreview(nodePath, w, r)
+
+	// The answer depends on the login; shared caches must not keep it.
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := linkTemplate.Execute(w, preview); err != nil {
+		log.Warningf("failed to render link preview: %v", err)
+	}
+}
+
+// OEmbedHandler implements http.Handler.
+// It answers oEmbed (https://oembed.com) requests for deep links.
+type OEmbedHandler struct {
+	*State
+}
+
+// NewOEmbedHandler returns a new OEmbedHandler.
+func NewOEmbedHandler(s *State) *OEmbedHandler {
+	return &OEmbedHandler{State: s}
+}
+
+// OEmbedResponse is the answer to an oEmbed request.
+type OEmbedResponse struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	ProviderName    string `json:"provider_name"`
+	Title           string `json:"title"`
+	Description     string `json:"description,omitempty"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+func (oh *OEmbedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !oh.cfg.Bool("link_previews.enabled") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	if format := params.Get("format"); format != "" && format != "json" {
+		http.Error(w, "only json is supported", http.StatusNotImplemented)
+		return
+	}
+
+	linkURL, err := url.Parse(params.Get("url"))
+	if err != nil || !strings.HasPrefix(linkURL.Path, LinkPrefix+"/") {
+		http.Error(w, "not a link of this gateway", http.StatusNotFound)
+		return
+	}
+
+	nodePath := prefixRoot(path.Clean(strings.TrimPrefix(linkURL.Path, LinkPrefix)))
+	preview, ok := oh.buildLinkPreview(nodePath, w, r)
+	if !ok {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("Content-Type", "application/json")
+	jsonify(w, http.StatusOK, OEmbedResponse{
+		Version:         "1.0",
+		Type:            "link",
+		ProviderName:    "brig",
+		Title:           preview.Title,
+		Description:     preview.Description,
+		ThumbnailURL:    preview.Image,
+		ThumbnailWidth:  preview.ImageWidth,
+		ThumbnailHeight: preview.ImageHeight,
+	})
+}
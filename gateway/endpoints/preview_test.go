@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sahib/brig/gateway/db"
+	"github.com/stretchr/testify/require"
+)
+
+func mustEncodePNG(t *testing.T, width, height int) []byte {
+	buf := &bytes.Buffer{}
+	require.Nil(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestLinkPreview(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/public/cat.png", bytes.NewReader(mustEncodePNG(t, 40, 30))))
+		require.Nil(t, s.fs.Stage("/secret.txt", bytes.NewReader([]byte("psst"))))
+
+		// Crawlers come without a session:
+		crawl := func(hdl http.Handler, url string) (*http.Response, string) {
+			rsw := httptest.NewRecorder()
+			hdl.ServeHTTP(rsw, httptest.NewRequest("GET", "http://localhost:5000"+url, nil))
+			resp := rsw.Result()
+			data, err := ioutil.ReadAll(resp.Body)
+			require.Nil(t, err)
+			return resp, string(data)
+		}
+
+		resp, body := crawl(NewLinkHandler(s.State), "/link/secret.txt")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, body, `<meta property="og:title" content="brig">`)
+		require.Contains(t, body, `url=/view/secret.txt`)
+		require.NotContains(t, body, "secret.txt · ")
+		require.NotContains(t, body, "og:description")
+
+		// Users that are logged in see the details:
+		resp = s.mustRun(t, NewLinkHandler(s.State), "GET", "http://localhost:5000/link/secret.txt", nil)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Contains(t, string(data), `<meta property="og:title" content="secret.txt">`)
+		require.Contains(t, string(data), `og:description`)
+		require.NotContains(t, string(data), `og:image`)
+
+		// Public files are shown to everyone, with thumbnail:
+		require.Nil(t, s.cfg.SetBool("auth.anon_allowed", true))
+		require.Nil(t, s.userDb.Add("anon", "anon", []string{"/public"}, []string{db.RightDownload}))
+
+		_, body = crawl(NewLinkHandler(s.State), "/link/public/cat.png")
+		require.Contains(t, body, `<meta property="og:title" content="cat.png">`)
+		require.Contains(t, body, `<meta property="og:image" content="http://localhost:5000/get/public/cat.png">`)
+		require.Contains(t, body, `<meta property="og:image:width" content="40">`)
+
+		_, body = crawl(NewLinkHandler(s.State), "/link/secret.txt")
+		require.NotContains(t, body, "og:description")
+
+		// The thumbnail can be fetched without login:
+		resp, _ = crawl(NewGetHandler(s.State), "/get/public/cat.png")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp, _ = crawl(NewGetHandler(s.State), "/get/secret.txt")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		oembed := func(link string) (*http.Response, string) {
+			return crawl(NewOEmbedHandler(s.State), "/oembed?url="+url.QueryEscape(link))
+		}
+
+		resp, body = oembed("http://localhost:5000/link/public/cat.png")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		oembedResp := OEmbedResponse{}
+		mustDecodeBody(t, bytes.NewReader([]byte(body)), &oembedResp)
+		require.Equal(t, "cat.png", oembedResp.Title)
+		require.Equal(t, 30, oembedResp.ThumbnailHeight)
+
+		resp, _ = oembed("http://localhost:5000/link/secret.txt")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp, _ = oembed("http://localhost:5000/get/secret.txt")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		require.Nil(t, s.cfg.SetBool("link_previews.enabled", false))
+		resp, _ = crawl(NewLinkHandler(s.State), "/link/public/cat.png")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -39,6 +39,9 @@ type Diff struct {
 	Conflict []DiffPair  `json:"conflict"`
 	Moved    []DiffPair  `json:"moved"`
 	Merged   []DiffPair  `json:"merged"`
+
+	// TransferSize is only set for sync previews.
+	TransferSize uint64 `json:"transfer_size,omitempty"`
 }
 
 // RemoteDiffResponse is the data being sent to this endpoint.
@@ -74,6 +77,24 @@ func (rh *RemotesDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	serveDiff(w, r, rh.rapi.MakeDiff)
+}
+
+func toExternalDiff(rawDiff *catfs.Diff) *Diff {
+	return &Diff{
+		Added:        convertSingles(rawDiff.Added),
+		Removed:      convertSingles(rawDiff.Removed),
+		Ignored:      convertSingles(rawDiff.Ignored),
+		Missing:      convertSingles(rawDiff.Missing),
+		Conflict:     convertPairs(rawDiff.Conflict),
+		Moved:        convertPairs(rawDiff.Moved),
+		Merged:       convertPairs(rawDiff.Merged),
+		TransferSize: rawDiff.TransferSize,
+	}
+}
+
+// serveDiff answers a RemoteDiffRequest with the diff produced by `makeDiff`.
+func serveDiff(w http.ResponseWriter, r *http.Request, makeDiff func(name string) (*catfs.Diff, error)) {
 	rmtDiffReq := RemoteDiffRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&rmtDiffReq); err != nil {
 		jsonifyErrf(w, http.StatusBadRequest, "bad json")
@@ -85,24 +106,14 @@ func (rh *RemotesDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	rawDiff, err := rh.rapi.MakeDiff(rmtDiffReq.Name)
+	rawDiff, err := makeDiff(rmtDiffReq.Name)
 	if err != nil {
 		jsonifyErrf(w, http.StatusBadRequest, "failed to diff")
 		return
 	}
 
-	diff := &Diff{
-		Added:    convertSingles(rawDiff.Added),
-		Removed:  convertSingles(rawDiff.Removed),
-		Ignored:  convertSingles(rawDiff.Ignored),
-		Missing:  convertSingles(rawDiff.Missing),
-		Conflict: convertPairs(rawDiff.Conflict),
-		Moved:    convertPairs(rawDiff.Moved),
-		Merged:   convertPairs(rawDiff.Merged),
-	}
-
 	jsonify(w, http.StatusOK, RemoteDiffResponse{
 		Success: true,
-		Diff:    diff,
+		Diff:    toExternalDiff(rawDiff),
 	})
 }
@@ -0,0 +1,26 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/sahib/brig/gateway/db"
+)
+
+// RemotesSyncPreviewHandler implements http.Handler.
+// It tells what a sync would change, without syncing.
+type RemotesSyncPreviewHandler struct {
+	*State
+}
+
+// NewRemotesSyncPreviewHandler returns a new RemotesSyncPreviewHandler
+func NewRemotesSyncPreviewHandler(s *State) *RemotesSyncPreviewHandler {
+	return &RemotesSyncPreviewHandler{State: s}
+}
+
+func (rh *RemotesSyncPreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightRemotesView) {
+		return
+	}
+
+	serveDiff(w, r, rh.rapi.SyncPreview)
+}
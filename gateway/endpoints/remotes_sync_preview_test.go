@@ -0,0 +1,49 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sahib/brig/gateway/remotesapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteSyncPreviewEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.State.rapi.Set(remotesapi.Remote{
+			Name:        "bob",
+			Fingerprint: "xxx",
+		}))
+
+		resp := s.mustRun(
+			t,
+			NewRemotesSyncPreviewHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/remotes/sync-preview",
+			RemoteDiffRequest{
+				Name: "bob",
+			},
+		)
+
+		data := &RemoteDiffResponse{}
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		mustDecodeBody(t, resp.Body, &data)
+		require.Equal(t, true, data.Success)
+		require.Equal(t, uint64(1024), data.Diff.TransferSize)
+		require.Equal(t, 2, len(data.Diff.Added))
+		require.Equal(t, 1, len(data.Diff.Conflict))
+
+		resp = s.mustRun(
+			t,
+			NewRemotesSyncPreviewHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/remotes/sync-preview",
+			RemoteDiffRequest{
+				Name: "alice",
+			},
+		)
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
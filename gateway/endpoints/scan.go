@@ -0,0 +1,125 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/sahib/brig/gateway/scan"
+	log "github.com/sirupsen/logrus"
+)
+
+// scanItem is a single uploaded file that should be scanned.
+type scanItem struct {
+	// name is where the file would be staged (or just its name).
+	name   string
+	header *multipart.FileHeader
+}
+
+// auditScan writes the result of a scan to the log, so it can be
+// traced later who uploaded what and what the scanner thought about it.
+func auditScan(user string, item scanItem, result string) {
+	log.WithFields(log.Fields{
+		"audit":  "upload-scan",
+		"user":   user,
+		"path":   item.name,
+		"size":   item.header.Size,
+		"result": result,
+	}).Info("gateway: scanned upload")
+}
+
+// quarantine copies an infected upload to the quarantine directory.
+func quarantine(dir string, item scanItem) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	src, err := item.header.Open()
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	name := fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000"), path.Base(item.name))
+	dst, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// scanUploads checks `items` with the configured scanner, if any.
+// It returns the first item that may not be staged along with
+// a reason that can be shown to the user, or nil if all are fine.
+func (s *State) scanUploads(r *http.Request, user string, items []scanItem) (*scanItem, string) {
+	if !s.cfg.Bool("scan.enabled") {
+		return nil, ""
+	}
+
+	timeout := s.cfg.Duration("scan.timeout")
+	rejectOnError := s.cfg.String("scan.on_error") == "reject"
+
+	scanner, err := scan.New(s.cfg.String("scan.url"), timeout)
+	if err != nil {
+		log.Warningf("gateway: bad scanner config: %v", err)
+		if rejectOnError {
+			return &items[0], "could not be scanned"
+		}
+
+		for _, item := range items {
+			auditScan(user, item, "not scanned: "+err.Error())
+		}
+
+		return nil, ""
+	}
+
+	for idx := range items {
+		item := &items[idx]
+		fd, err := item.header.Open()
+		if err != nil {
+			return item, "could not be read"
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		res, err := scanner.Scan(ctx, fd)
+		cancel()
+		fd.Close()
+
+		if err != nil {
+			auditScan(user, *item, "error: "+err.Error())
+			if rejectOnError {
+				return item, "could not be scanned"
+			}
+
+			continue
+		}
+
+		auditScan(user, *item, res.String())
+		if !res.Infected {
+			continue
+		}
+
+		if dir := s.cfg.String("scan.quarantine_dir"); dir != "" {
+			if err := quarantine(dir, *item); err != nil {
+				log.Warningf("gateway: failed to quarantine %s: %v", item.name, err)
+			}
+		}
+
+		return item, "is infected"
+	}
+
+	return nil, ""
+}
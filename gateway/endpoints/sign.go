@@ -0,0 +1,170 @@
+package endpoints
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/sahib/brig/gateway/db"
+)
+
+// Signed urls let another service download files on behalf of a user,
+// without a cookie or password. The url contains the path, the user and
+// the expiry date, authenticated with a key only the gateway knows.
+// The rights of the user are checked again on every download, so removing
+// a user or their access to a folder also invalidates the urls.
+
+const (
+	// signMaxPaths is the maximum number of paths per request.
+	signMaxPaths = 1000
+
+	// signDefaultExpiry is used when the request does not say.
+	signDefaultExpiry = 15 * time.Minute
+)
+
+// SignHandler implements http.Handler.
+type SignHandler struct {
+	*State
+}
+
+// NewSignHandler returns a new SignHandler.
+func NewSignHandler(s *State) *SignHandler {
+	return &SignHandler{State: s}
+}
+
+// SignRequest is the request that can be sent to this endpoint as JSON.
+type SignRequest struct {
+	// Paths are the files or directories to sign urls for.
+	Paths []string `json:"paths"`
+	// ExpiresIn is the number of seconds the urls are valid (0 for the default).
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// SignedURL is a temporary download url for a single path.
+type SignedURL struct {
+	Path    string `json:"path"`
+	URL     string `json:"url"`
+	Expires int64  `json:"expires"`
+}
+
+// SignResponse is the response sent back by this endpoint.
+type SignResponse struct {
+	Success bool        `json:"success"`
+	URLs    []SignedURL `json:"urls"`
+}
+
+// signature authenticates that `user` may get `nodePath` until `expires`.
+func (s *State) signature(user, nodePath string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signKey)
+	fmt.Fprintf(mac, "%s\x00%s\x00%d", user, nodePath, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signURL returns the path and query of a signed download url.
+func (s *State) signURL(user, nodePath string, expires int64) string {
+	query := url.Values{}
+	query.Set("user", user)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", s.signature(user, nodePath, expires))
+	return "/get" + escapeNodePath(nodePath) + "?" + query.Encode()
+}
+
+// checkSignature checks the signed url in `r` for `nodePath`.
+// The signing user needs to be still able to download the path.
+func (s *State) checkSignature(nodePath string, w http.ResponseWriter, r *http.Request) bool {
+	if !s.cfg.Bool("signed_urls.enabled") {
+		return false
+	}
+
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	name := query.Get("user")
+	expected := s.signature(name, nodePath, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return false
+	}
+
+	user, err := s.userDb.Get(name)
+	if err != nil {
+		return false
+	}
+
+	return s.userDb.HasRight(user, db.RightDownload) && s.validatePathForUser(nodePath, user, w, r)
+}
+
+func (sh *SignHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sh.cfg.Bool("signed_urls.enabled") {
+		jsonifyErrf(w, http.StatusNotFound, "signed urls are disabled")
+		return
+	}
+
+	if !checkRights(w, r, db.RightDownload) {
+		return
+	}
+
+	signReq := SignRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&signReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if len(signReq.Paths) == 0 || len(signReq.Paths) > signMaxPaths {
+		jsonifyErrf(w, http.StatusBadRequest, "need between 1 and %d paths", signMaxPaths)
+		return
+	}
+
+	expiry := signDefaultExpiry
+	if signReq.ExpiresIn < 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "expiry may not be negative")
+		return
+	}
+
+	if signReq.ExpiresIn > 0 {
+		expiry = time.Duration(signReq.ExpiresIn) * time.Second
+	}
+
+	if maxExpiry := sh.cfg.Duration("signed_urls.max_expiry"); expiry > maxExpiry {
+		jsonifyErrf(w, http.StatusBadRequest, "urls may be valid for %s at most", maxExpiry)
+		return
+	}
+
+	user := getUserName(sh.store, w, r)
+	expires := time.Now().Add(expiry).Unix()
+	base := baseURL(r)
+
+	urls := []SignedURL{}
+	for _, reqPath := range signReq.Paths {
+		nodePath := prefixRoot(path.Clean(reqPath))
+		if !sh.validatePath(nodePath, w, r) {
+			jsonifyErrf(w, http.StatusUnauthorized, "path forbidden: %s", reqPath)
+			return
+		}
+
+		if _, err := sh.fs.Stat(nodePath); err != nil {
+			jsonifyErrf(w, http.StatusNotFound, "no such file: %s", reqPath)
+			return
+		}
+
+		urls = append(urls, SignedURL{
+			Path:    nodePath,
+			URL:     base + sh.signURL(user, nodePath, expires),
+			Expires: expires,
+		})
+	}
+
+	jsonify(w, http.StatusOK, &SignResponse{
+		Success: true,
+		URLs:    urls,
+	})
+}
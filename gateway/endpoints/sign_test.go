@@ -0,0 +1,74 @@
+package endpoints
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedURLs(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/sub/a.txt", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/b.txt", bytes.NewReader([]byte("world"))))
+
+		resp := s.mustRun(t, NewSignHandler(s.State), "POST", "http://localhost:5000/api/v0/sign", &SignRequest{
+			Paths:     []string{"/sub/a.txt", "b.txt"},
+			ExpiresIn: 60,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		signResp := &SignResponse{}
+		mustDecodeBody(t, resp.Body, signResp)
+		require.True(t, signResp.Success)
+		require.Len(t, signResp.URLs, 2)
+		require.Equal(t, "/b.txt", signResp.URLs[1].Path)
+
+		// No session is set; the signature is all that's needed:
+		download := func(url string) (int, string) {
+			rsw := httptest.NewRecorder()
+			NewGetHandler(s.State).ServeHTTP(rsw, httptest.NewRequest("GET", url, nil))
+			data, err := ioutil.ReadAll(rsw.Result().Body)
+			require.Nil(t, err)
+			return rsw.Result().StatusCode, string(data)
+		}
+
+		code, data := download(signResp.URLs[0].URL)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "hello", data)
+
+		// The url is only valid for this path:
+		code, _ = download(strings.Replace(signResp.URLs[0].URL, "/sub/a.txt", "/b.txt", 1))
+		require.Equal(t, http.StatusUnauthorized, code)
+
+		// ...and may not be extended:
+		code, _ = download(strings.Replace(signResp.URLs[1].URL, "expires=", "expires=9", 1))
+		require.Equal(t, http.StatusUnauthorized, code)
+
+		// Losing access to the folder also invalidates the url:
+		s.mustChangeFolders(t, "/sub")
+		code, _ = download(signResp.URLs[1].URL)
+		require.Equal(t, http.StatusUnauthorized, code)
+		code, _ = download(signResp.URLs[0].URL)
+		require.Equal(t, http.StatusOK, code)
+
+		resp = s.mustRun(t, NewSignHandler(s.State), "POST", "http://localhost:5000/api/v0/sign", &SignRequest{
+			Paths: []string{"/b.txt"},
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = s.mustRun(t, NewSignHandler(s.State), "POST", "http://localhost:5000/api/v0/sign", &SignRequest{
+			Paths:     []string{"/sub/a.txt"},
+			ExpiresIn: 7 * 24 * 3600,
+		})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		require.Nil(t, s.cfg.SetBool("signed_urls.enabled", false))
+		code, _ = download(signResp.URLs[0].URL)
+		require.Equal(t, http.StatusUnauthorized, code)
+	})
+}
@@ -0,0 +1,169 @@
+package endpoints
+
+import (
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sahib/brig/catfs"
+	ie "github.com/sahib/brig/catfs/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SitePrefix is the URL prefix under which the website folder is served.
+const SitePrefix = "/site"
+
+var siteIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<ul>
+{{if ne .Path "/"}}<li><a href="../">../</a></li>
+{{end}}{{range .Entries}}<li><a href="{{.Link}}">{{.Name}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type siteIndexEntry struct {
+	Name string
+	Link string
+}
+
+// SiteHandler serves a folder of the repository without login,
+// so brig can host a simple static website.
+type SiteHandler struct {
+	*State
+}
+
+// NewSiteHandler returns a new SiteHandler
+func NewSiteHandler(s *State) *SiteHandler {
+	return &SiteHandler{State: s}
+}
+
+func (sh *SiteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sh.cfg.Bool("site.enabled") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	sitePath, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), SitePrefix))
+	if err != nil {
+		http.Error(w, "malformed url", http.StatusBadRequest)
+		return
+	}
+
+	// Join cleans the path, so ".." can not leave the site folder:
+	root := sh.cfg.String("site.folder")
+	nodePath := path.Join(root, path.Clean("/"+sitePath))
+
+	info, err := sh.fs.Stat(nodePath)
+	if err != nil {
+		if ie.IsNoSuchFileError(err) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("site: failed to stat %s: %v", nodePath, err)
+		http.Error(w, "failed to stat file", http.StatusInternalServerError)
+		return
+	}
+
+	if info.IsDir {
+		// Relative links only work if the directory url ends with a slash:
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		if sh.cfg.Bool("site.index_html") {
+			indexInfo, err := sh.fs.Stat(path.Join(nodePath, "index.html"))
+			if err == nil && !indexInfo.IsDir {
+				sh.serveFile(w, r, indexInfo)
+				return
+			}
+		}
+
+		if !sh.cfg.Bool("site.listing") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		listPath := "/" + strings.TrimPrefix(strings.TrimPrefix(nodePath, root), "/")
+		sh.serveListing(w, info, listPath)
+		return
+	}
+
+	sh.serveFile(w, r, info)
+}
+
+func (sh *SiteHandler) serveFile(w http.ResponseWriter, r *http.Request, info *catfs.StatInfo) {
+	stream, err := sh.fs.Cat(info.Path)
+	if err != nil {
+		log.Errorf("site: failed to stream %s: %v", info.Path, err)
+		http.Error(w, "failed to stream", http.StatusInternalServerError)
+		return
+	}
+
+	defer stream.Close()
+
+	hdr := w.Header()
+	hdr.Set("ETag", info.ContentHash.B58String())
+	hdr.Set("Content-Length", strconv.FormatUint(info.Size, 10))
+
+	// Sniffing can not tell css or javascript from plain text,
+	// so the extension is more reliable if it is known:
+	body, mimeType := mimeTypeFromStream(stream)
+	if extType := mime.TypeByExtension(path.Ext(info.Path)); extType != "" {
+		mimeType = extType
+	}
+
+	hdr.Set("Content-Type", mimeType)
+	http.ServeContent(w, r, path.Base(info.Path), info.ModTime, body)
+}
+
+func (sh *SiteHandler) serveListing(w http.ResponseWriter, info *catfs.StatInfo, sitePath string) {
+	children, err := sh.fs.List(info.Path, 1)
+	if err != nil {
+		log.Errorf("site: failed to list %s: %v", info.Path, err)
+		http.Error(w, "failed to list", http.StatusInternalServerError)
+		return
+	}
+
+	entries := []siteIndexEntry{}
+	for _, child := range children {
+		if child.Path == info.Path {
+			continue
+		}
+
+		name := path.Base(child.Path)
+		link := url.PathEscape(name)
+		if child.IsDir {
+			name += "/"
+			link += "/"
+		}
+
+		entries = append(entries, siteIndexEntry{Name: name, Link: link})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := struct {
+		Path    string
+		Entries []siteIndexEntry
+	}{
+		Path:    sitePath,
+		Entries: entries,
+	}
+
+	if err := siteIndexTemplate.Execute(w, data); err != nil {
+		log.Warningf("site: failed to render index of %s: %v", info.Path, err)
+	}
+}
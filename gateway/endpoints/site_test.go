@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/public/style.css", bytes.NewReader([]byte("body {}"))))
+		require.Nil(t, s.fs.Stage("/public/docs/a.txt", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/public/blog/index.html", bytes.NewReader([]byte("<html></html>"))))
+		require.Nil(t, s.fs.Stage("/secret", bytes.NewReader([]byte("psst"))))
+
+		run := func(url string) *http.Response {
+			return s.mustRun(t, NewSiteHandler(s.State), "GET", "http://localhost:5000"+url, nil)
+		}
+
+		// Disabled by default:
+		require.Equal(t, http.StatusNotFound, run("/site/style.css").StatusCode)
+
+		require.Nil(t, s.cfg.SetBool("site.enabled", true))
+
+		resp := run("/site/style.css")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/css; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		// index.html is served for directories:
+		resp = run("/site/blog/")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Equal(t, "<html></html>", string(data))
+
+		// Otherwise a listing:
+		resp = run("/site/docs/")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err = ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Contains(t, string(data), `<a href="a.txt">a.txt</a>`)
+
+		require.Nil(t, s.cfg.SetBool("site.listing", false))
+		require.Equal(t, http.StatusNotFound, run("/site/docs/").StatusCode)
+
+		// Nothing outside of the site folder:
+		require.Equal(t, http.StatusNotFound, run("/site/../secret").StatusCode)
+		require.Equal(t, http.StatusNotFound, run("/site/%2e%2e/secret").StatusCode)
+	})
+}
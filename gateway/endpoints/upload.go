@@ -2,9 +2,11 @@ package endpoints
 
 import (
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"path"
 
+	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/gateway/db"
 	log "github.com/sirupsen/logrus"
 )
@@ -41,32 +43,111 @@ func (uh *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Remove the cached files in /tmp
 	defer r.MultipartForm.RemoveAll()
 
-	paths := []string{}
+	uploads, err := uploadDestinations(root, r.MultipartForm)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "%v", err)
+		return
+	}
 
-	for _, headers := range r.MultipartForm.File {
-		for _, header := range headers {
-			path := path.Join(root, header.Filename)
-			fd, err := header.Open()
-			if err != nil {
-				log.Debugf("upload: bad header: %v", err)
-				jsonifyErrf(w, http.StatusBadRequest, "failed to open file: %v", header.Filename)
-				return
-			}
+	// With ?extract=true, ZIP archives are unpacked instead of stored as they are.
+	archives := []*zipUpload{}
+	if r.URL.Query().Get("extract") == "true" {
+		var status int
+		archives, uploads, status, err = uh.openZipUploads(uploads)
+		if err != nil {
+			jsonifyErrf(w, status, "%v", err)
+			return
+		}
 
-			if !uh.validatePath(path, w, r) {
+		defer closeZipUploads(archives)
+	}
+
+	// Check all destinations before staging anything,
+	// so a forbidden path does not leave a partial upload behind.
+	for _, upload := range uploads {
+		if !uh.validatePath(upload.path, w, r) {
+			jsonifyErrf(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+	}
+
+	for _, archive := range archives {
+		for _, entry := range archive.entries {
+			if !uh.validatePath(entry.path, w, r) {
 				jsonifyErrf(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
+		}
+	}
+
+	items := []scanItem{}
+	for _, upload := range uploads {
+		items = append(items, scanItem{name: upload.path, header: upload.header})
+	}
+
+	if rejected, status, reason := uh.checkUploadPolicies(items); rejected != nil {
+		jsonifyErrf(w, status, "%s %s", rejected.name, reason)
+		return
+	}
+
+	if rejected, status, reason := uh.checkZipPolicies(archives); rejected != "" {
+		jsonifyErrf(w, status, "%s %s", rejected, reason)
+		return
+	}
+
+	// Archives are scanned as a whole; scanners look into them by themselves.
+	for _, archive := range archives {
+		items = append(items, scanItem{name: archive.path, header: archive.header})
+	}
+
+	if len(items) > 0 {
+		user := getUserName(uh.store, w, r)
+		if rejected, reason := uh.scanUploads(r, user, items); rejected != nil {
+			jsonifyErrf(w, http.StatusUnprocessableEntity, "%s %s", rejected.name, reason)
+			return
+		}
+	}
 
-			if err := uh.fs.Stage(path, fd); err != nil {
-				log.Debugf("upload: could not stage: %v", err)
-				jsonifyErrf(w, http.StatusBadRequest, "failed to insert file: %v", path)
-				fd.Close()
+	// Staging can still fail halfway, e.g. on a damaged archive member.
+	// Everything staged up to then is taken back in that case.
+	undo := &stagingUndo{fs: uh.fs}
+	paths := []string{}
+	for _, upload := range uploads {
+		path, header := upload.path, upload.header
+		fd, err := header.Open()
+		if err != nil {
+			log.Debugf("upload: bad header: %v", err)
+			undo.rollback()
+			jsonifyErrf(w, http.StatusBadRequest, "failed to open file: %v", header.Filename)
+			return
+		}
+
+		undo.remember(path)
+		if err := uh.fs.Stage(path, fd); err != nil {
+			log.Debugf("upload: could not stage: %v", err)
+			undo.rollback()
+			jsonifyErrf(w, http.StatusBadRequest, "failed to insert file: %v", path)
+			fd.Close()
+			return
+		}
+
+		paths = append(paths, path)
+		fd.Close()
+	}
+
+	for _, archive := range archives {
+		for _, entry := range archive.entries {
+			undo.remember(entry.path)
+			if err := uh.extractZipEntry(entry); err != nil {
+				log.Debugf("upload: could not extract: %v", err)
+				undo.rollback()
+				jsonifyErrf(w, http.StatusBadRequest, "failed to extract: %v", entry.path)
 				return
 			}
 
-			paths = append(paths, path)
-			fd.Close()
+			if entry.file != nil {
+				paths = append(paths, entry.path)
+			}
 		}
 	}
 
@@ -83,3 +164,101 @@ func (uh *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	jsonifySuccess(w)
 }
+
+// uploadPathsField is the form field that carries the relative paths
+// of the files in `uploadFilesField`, in the same order. This is used
+// for folder uploads, since browsers only send the base name as filename.
+const (
+	uploadFilesField = "files[]"
+	uploadPathsField = "paths[]"
+)
+
+type uploadDestination struct {
+	path   string
+	header *multipart.FileHeader
+}
+
+// uploadDestinations figures out where each uploaded file should go.
+// Relative paths may contain directories, which are created on staging.
+func uploadDestinations(root string, form *multipart.Form) ([]uploadDestination, error) {
+	relPaths := form.Value[uploadPathsField]
+	if len(relPaths) > 0 && len(relPaths) != len(form.File[uploadFilesField]) {
+		return nil, fmt.Errorf(
+			"got %d paths for %d files",
+			len(relPaths),
+			len(form.File[uploadFilesField]),
+		)
+	}
+
+	uploads := []uploadDestination{}
+	for field, headers := range form.File {
+		for idx, header := range headers {
+			relPath := header.Filename
+			if field == uploadFilesField && len(relPaths) > 0 {
+				relPath = relPaths[idx]
+			}
+
+			// Cleaning with a leading slash makes sure that
+			// ".." can not be used to escape the root.
+			relPath = path.Clean("/" + relPath)
+			if relPath == "/" {
+				return nil, fmt.Errorf("bad upload path: %v", header.Filename)
+			}
+
+			uploads = append(uploads, uploadDestination{
+				path:   path.Join(root, relPath),
+				header: header,
+			})
+		}
+	}
+
+	return uploads, nil
+}
+
+// stagingUndo remembers what was at the paths an upload is about to stage,
+// so that a failed upload can be taken back without touching anything else.
+type stagingUndo struct {
+	fs    *catfs.FS
+	saved []*catfs.SavedNode
+}
+
+// remember needs to be called before `repoPath` is staged.
+// Existing directories are left alone, only their new children are undone.
+// Parent directories that do not exist yet are created by staging;
+// the topmost of them is remembered instead, since it covers the rest.
+func (su *stagingUndo) remember(repoPath string) {
+	if info, err := su.fs.Stat(repoPath); err == nil && info.IsDir {
+		return
+	}
+
+	top := repoPath
+	for dir := path.Dir(repoPath); dir != "/"; dir = path.Dir(dir) {
+		if _, err := su.fs.Stat(dir); err == nil {
+			break
+		}
+
+		top = dir
+	}
+
+	saved, err := su.fs.SaveNode(top)
+	if err != nil {
+		log.Debugf("upload: failed to save %s: %v", top, err)
+		return
+	}
+
+	su.saved = append(su.saved, saved)
+}
+
+// rollback puts back what was at the remembered paths before the upload,
+// including changes that were not committed yet. New files and
+// directories are removed by this, overwritten files get their old
+// content back.
+func (su *stagingUndo) rollback() {
+	for idx := len(su.saved) - 1; idx >= 0; idx-- {
+		if err := su.fs.RestoreNode(su.saved[idx]); err != nil {
+			log.Debugf("upload: failed to restore: %v", err)
+		}
+	}
+
+	su.saved = nil
+}
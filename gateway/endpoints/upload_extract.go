@@ -0,0 +1,261 @@
+package endpoints
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errArchiveTooBig       = errors.New("archive contents are too big")
+	errArchiveTooManyFiles = errors.New("archive contains too many files")
+)
+
+// zipEntry is a single file or directory of an uploaded ZIP archive.
+type zipEntry struct {
+	// path is where the entry is extracted to.
+	path string
+	// file is nil for directories.
+	file *zip.File
+}
+
+// zipUpload is an uploaded ZIP archive that should be extracted.
+type zipUpload struct {
+	uploadDestination
+	fd      multipart.File
+	entries []zipEntry
+}
+
+func (zu *zipUpload) Close() error {
+	return zu.fd.Close()
+}
+
+// isZipUpload checks if `header` looks like a ZIP archive.
+func isZipUpload(header *multipart.FileHeader) bool {
+	return strings.ToLower(path.Ext(header.Filename)) == ".zip"
+}
+
+// zipEntryPath returns where the archive member `name` goes below `root`.
+// Members with absolute paths or ".." in them are refused; they are
+// most likely an attempt to write outside of `root`.
+func zipEntryPath(root, name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("absolute path in archive: %s", name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("bad path in archive: %s", name)
+		}
+	}
+
+	cleanName := path.Clean("/" + name)
+	if cleanName == "/" {
+		return "", fmt.Errorf("bad path in archive: %s", name)
+	}
+
+	return path.Join(root, cleanName), nil
+}
+
+// zipEntries lists what `zr` would extract to `root`.
+// The archive is refused as a whole if it exceeds the given limits.
+func zipEntries(root string, zr *zip.Reader, maxSize uint64, maxFiles int) ([]zipEntry, error) {
+	if len(zr.File) > maxFiles {
+		return nil, errArchiveTooManyFiles
+	}
+
+	entries := []zipEntry{}
+	totalSize := uint64(0)
+	for _, file := range zr.File {
+		entryPath, err := zipEntryPath(root, file.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			entries = append(entries, zipEntry{path: entryPath})
+		case mode.IsRegular():
+			// The zip reader fails if a file turns out to be bigger than
+			// it claims here, so a zip bomb can not sneak past this check.
+			totalSize += file.UncompressedSize64
+			if totalSize > maxSize {
+				return nil, errArchiveTooBig
+			}
+
+			entries = append(entries, zipEntry{path: entryPath, file: file})
+		default:
+			return nil, fmt.Errorf("unsupported file type in archive: %s", file.Name)
+		}
+	}
+
+	return entries, nil
+}
+
+// openZipUploads opens each ZIP archive in `uploads` and lists its entries.
+// The archives are extracted next to where they would have been uploaded.
+// Uploads that are not ZIP archives are returned as they are.
+// The returned archives need to be closed after use.
+func (s *State) openZipUploads(uploads []uploadDestination) ([]*zipUpload, []uploadDestination, int, error) {
+	maxSize, err := humanize.ParseBytes(s.cfg.String("upload.extract_max_size"))
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	maxFiles := int(s.cfg.Int("upload.extract_max_files"))
+
+	archives := []*zipUpload{}
+	rest := []uploadDestination{}
+	for _, upload := range uploads {
+		if !isZipUpload(upload.header) {
+			rest = append(rest, upload)
+			continue
+		}
+
+		fd, err := upload.header.Open()
+		if err != nil {
+			closeZipUploads(archives)
+			return nil, nil, http.StatusBadRequest, err
+		}
+
+		archive := &zipUpload{uploadDestination: upload, fd: fd}
+		archives = append(archives, archive)
+
+		zr, err := zip.NewReader(fd, upload.header.Size)
+		if err != nil {
+			closeZipUploads(archives)
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("%s: %v", upload.header.Filename, err)
+		}
+
+		archive.entries, err = zipEntries(path.Dir(upload.path), zr, maxSize, maxFiles)
+		if err != nil {
+			closeZipUploads(archives)
+
+			status := http.StatusBadRequest
+			if err == errArchiveTooBig || err == errArchiveTooManyFiles {
+				status = http.StatusRequestEntityTooLarge
+			}
+
+			return nil, nil, status, fmt.Errorf("%s: %v", upload.header.Filename, err)
+		}
+	}
+
+	return archives, rest, http.StatusOK, nil
+}
+
+func closeZipUploads(archives []*zipUpload) {
+	for _, archive := range archives {
+		if err := archive.Close(); err != nil {
+			log.Debugf("upload: failed to close archive: %v", err)
+		}
+	}
+}
+
+func sniffZipEntry(file *zip.File) (string, error) {
+	fd, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer fd.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(fd, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// checkZipPolicies checks every file in `archives` against the upload
+// policy of the folder it is extracted to, like checkUploadPolicies.
+func (s *State) checkZipPolicies(archives []*zipUpload) (string, int, string) {
+	for _, archive := range archives {
+		for _, entry := range archive.entries {
+			if entry.file == nil {
+				continue
+			}
+
+			policy, err := s.uploadPolicyFor(entry.path)
+			if err != nil {
+				log.Warningf("upload: failed to load upload policy: %v", err)
+				return entry.path, http.StatusInternalServerError, "could not be checked"
+			}
+
+			if policy.IsEmpty() {
+				continue
+			}
+
+			mimeType, err := sniffZipEntry(entry.file)
+			if err != nil {
+				return entry.path, http.StatusBadRequest, "could not be read"
+			}
+
+			size := int64(entry.file.UncompressedSize64)
+			switch err := policy.Check(entry.path, mimeType, size); err {
+			case nil:
+				continue
+			case db.ErrUploadTooBig:
+				return entry.path, http.StatusRequestEntityTooLarge, fmt.Sprintf(
+					"%s (limit for %s is %s)",
+					err, policy.Folder, humanize.Bytes(uint64(policy.MaxSize)),
+				)
+			default:
+				return entry.path, http.StatusUnsupportedMediaType, fmt.Sprintf(
+					"%s in %s (%s)",
+					err, policy.Folder, mimeType,
+				)
+			}
+		}
+	}
+
+	return "", http.StatusOK, ""
+}
+
+// extractZipEntry stages a single entry of an archive.
+// Staging needs to seek, so the entry is unpacked to a temporary
+// file first. Only one entry is held on disk at a time.
+func (s *State) extractZipEntry(entry zipEntry) error {
+	if entry.file == nil {
+		return s.fs.Mkdir(entry.path, true)
+	}
+
+	src, err := entry.file.Open()
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	tmpFd, err := ioutil.TempFile("", "brig-gateway-extract-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmpFd.Name())
+	defer tmpFd.Close()
+
+	if _, err := io.Copy(tmpFd, src); err != nil {
+		return err
+	}
+
+	if _, err := tmpFd.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return s.fs.Stage(entry.path, tmpFd)
+}
@@ -0,0 +1,239 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sahib/brig/gateway/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// configUploadPolicy returns the policy from the upload section of the config.
+// It applies to every folder that has no policy of its own.
+func (s *State) configUploadPolicy() (db.UploadPolicy, error) {
+	maxSize, err := humanize.ParseBytes(s.cfg.String("upload.max_size"))
+	if err != nil {
+		return db.UploadPolicy{}, err
+	}
+
+	return db.UploadPolicy{
+		Folder:  "/",
+		MaxSize: int64(maxSize),
+		Allowed: s.cfg.Strings("upload.allowed_types"),
+		Blocked: s.cfg.Strings("upload.blocked_types"),
+	}, nil
+}
+
+// uploadPolicyFor returns the policy for files uploaded to `path`.
+// The policy of the deepest folder wins; folders without one use the config.
+func (s *State) uploadPolicyFor(path string) (*db.UploadPolicy, error) {
+	policies, err := s.userDb.UploadPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultPolicy, err := s.configUploadPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Policies from the database come first, so they win over the config for "/":
+	policies = append(policies, defaultPolicy)
+	return db.UploadPolicyFor(policies, path), nil
+}
+
+// checkUploadPolicies checks `items` against the policies of the folders they
+// go to. It returns the first item that may not be uploaded, together with
+// the http status and the reason to report, or nil if all are fine.
+func (s *State) checkUploadPolicies(items []scanItem) (*scanItem, int, string) {
+	for idx := range items {
+		item := &items[idx]
+		policy, err := s.uploadPolicyFor(item.name)
+		if err != nil {
+			log.Warningf("upload: failed to load upload policy: %v", err)
+			return item, http.StatusInternalServerError, "could not be checked"
+		}
+
+		if policy.IsEmpty() {
+			continue
+		}
+
+		mimeType, err := sniffMimeType(item.header)
+		if err != nil {
+			return item, http.StatusBadRequest, "could not be read"
+		}
+
+		switch err := policy.Check(item.header.Filename, mimeType, item.header.Size); err {
+		case nil:
+			continue
+		case db.ErrUploadTooBig:
+			return item, http.StatusRequestEntityTooLarge, fmt.Sprintf(
+				"%s (limit for %s is %s)",
+				err, policy.Folder, humanize.Bytes(uint64(policy.MaxSize)),
+			)
+		default:
+			return item, http.StatusUnsupportedMediaType, fmt.Sprintf(
+				"%s in %s (%s)",
+				err, policy.Folder, mimeType,
+			)
+		}
+	}
+
+	return nil, http.StatusOK, ""
+}
+
+// UploadPolicyListHandler implements http.Handler.
+type UploadPolicyListHandler struct {
+	*State
+}
+
+// NewUploadPolicyListHandler returns a new UploadPolicyListHandler.
+func NewUploadPolicyListHandler(s *State) *UploadPolicyListHandler {
+	return &UploadPolicyListHandler{State: s}
+}
+
+// UploadPolicyListResponse is the response sent back by this endpoint.
+type UploadPolicyListResponse struct {
+	Success bool `json:"success"`
+	// Default is the policy from the config.
+	Default db.UploadPolicy `json:"default"`
+	// Policies are the policies of single folders.
+	Policies []db.UploadPolicy `json:"policies"`
+}
+
+func (uh *UploadPolicyListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightFsEdit) {
+		return
+	}
+
+	defaultPolicy, err := uh.configUploadPolicy()
+	if err != nil {
+		log.Warningf("bad upload policy in config: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "bad upload policy in config")
+		return
+	}
+
+	policies, err := uh.userDb.UploadPolicies()
+	if err != nil {
+		log.Warningf("failed to list upload policies: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to list upload policies")
+		return
+	}
+
+	// Only show policies for folders the user has access to:
+	visible := []db.UploadPolicy{}
+	for _, policy := range policies {
+		if uh.validatePath(policy.Folder, w, r) {
+			visible = append(visible, policy)
+		}
+	}
+
+	jsonify(w, http.StatusOK, &UploadPolicyListResponse{
+		Success:  true,
+		Default:  defaultPolicy,
+		Policies: visible,
+	})
+}
+
+// UploadPolicySetHandler implements http.Handler.
+type UploadPolicySetHandler struct {
+	*State
+}
+
+// NewUploadPolicySetHandler returns a new UploadPolicySetHandler.
+func NewUploadPolicySetHandler(s *State) *UploadPolicySetHandler {
+	return &UploadPolicySetHandler{State: s}
+}
+
+// UploadPolicySetRequest is the request that can be sent to this endpoint as JSON.
+type UploadPolicySetRequest struct {
+	// Folder is the directory the policy applies to, including everything below.
+	Folder string `json:"folder"`
+	// MaxSize is the maximum size of a single file (0 for no limit).
+	MaxSize int64 `json:"max_size"`
+	// Allowed are the allowed extensions or mime types (empty for all).
+	Allowed []string `json:"allowed"`
+	// Blocked are extensions or mime types that may never be uploaded.
+	Blocked []string `json:"blocked"`
+}
+
+func (uh *UploadPolicySetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightAdminUsers) {
+		return
+	}
+
+	setReq := UploadPolicySetRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&setReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if setReq.MaxSize < 0 {
+		jsonifyErrf(w, http.StatusBadRequest, "limits may not be negative")
+		return
+	}
+
+	folder := prefixRoot(path.Clean(setReq.Folder))
+	if !uh.validatePath(folder, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	err := uh.userDb.SetUploadPolicy(db.UploadPolicy{
+		Folder:  folder,
+		MaxSize: setReq.MaxSize,
+		Allowed: setReq.Allowed,
+		Blocked: setReq.Blocked,
+	})
+
+	if err != nil {
+		log.Warningf("failed to set upload policy: %v", err)
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to set upload policy")
+		return
+	}
+
+	jsonifySuccess(w)
+}
+
+// UploadPolicyRemoveHandler implements http.Handler.
+type UploadPolicyRemoveHandler struct {
+	*State
+}
+
+// NewUploadPolicyRemoveHandler returns a new UploadPolicyRemoveHandler.
+func NewUploadPolicyRemoveHandler(s *State) *UploadPolicyRemoveHandler {
+	return &UploadPolicyRemoveHandler{State: s}
+}
+
+// UploadPolicyRemoveRequest is the request that can be sent to this endpoint as JSON.
+type UploadPolicyRemoveRequest struct {
+	Folder string `json:"folder"`
+}
+
+func (uh *UploadPolicyRemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightAdminUsers) {
+		return
+	}
+
+	removeReq := UploadPolicyRemoveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&removeReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	folder := prefixRoot(path.Clean(removeReq.Folder))
+	if !uh.validatePath(folder, w, r) {
+		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
+		return
+	}
+
+	if err := uh.userDb.RemoveUploadPolicy(folder); err != nil {
+		jsonifyErrf(w, http.StatusNotFound, "no such upload policy")
+		return
+	}
+
+	jsonifySuccess(w)
+}
@@ -0,0 +1,64 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPolicies(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.cfg.SetStrings("upload.blocked_types", []string{".exe"}))
+
+		resp := mustDoUpload(t, s, "/virus.exe", []byte("MZ"))
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		resp = s.mustRun(t, NewUploadPolicySetHandler(s.State), "POST", "http://localhost:5000/api/v0/upload-policy/set", &UploadPolicySetRequest{
+			Folder:  "/small",
+			MaxSize: 10,
+			Allowed: []string{"text/plain"},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = mustDoUpload(t, s, "/small/a.txt", []byte("hello world"))
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		resp = mustDoUpload(t, s, "/small/a.png", []byte("\x89PNG\r\n\x1a\n"))
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		// The folder policy replaces the one from the config:
+		resp = mustDoUpload(t, s, "/small/b.exe", []byte("hi"))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Other folders still use the config:
+		resp = mustDoUpload(t, s, "/other/a.txt", []byte("hello"))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = s.mustRun(t, NewUploadPolicyListHandler(s.State), "POST", "http://localhost:5000/api/v0/upload-policy/list", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		listResp := &UploadPolicyListResponse{}
+		mustDecodeBody(t, resp.Body, listResp)
+		require.Equal(t, []string{".exe"}, listResp.Default.Blocked)
+		require.Len(t, listResp.Policies, 1)
+		require.Equal(t, "/small", listResp.Policies[0].Folder)
+
+		resp = s.mustRun(t, NewUploadPolicyRemoveHandler(s.State), "POST", "http://localhost:5000/api/v0/upload-policy/remove", &UploadPolicyRemoveRequest{
+			Folder: "/small",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = mustDoUpload(t, s, "/small/a.txt", []byte("hello"))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Only admins may change policies:
+		s.mustChangeFolders(t, "/")
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{"fs.view", "fs.edit"}))
+		resp = s.mustRun(t, NewUploadPolicySetHandler(s.State), "POST", "http://localhost:5000/api/v0/upload-policy/set", &UploadPolicySetRequest{
+			Folder: "/",
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
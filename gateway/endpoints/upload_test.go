@@ -1,16 +1,23 @@
 package endpoints
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path"
 	"testing"
 
+	"github.com/sahib/brig/catfs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -67,3 +74,348 @@ func TestUploadForbidden(t *testing.T) {
 		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 }
+
+func mustDoUploadFolder(t *testing.T, s *testState, root string, files map[string][]byte) *http.Response {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for relPath, data := range files {
+		require.Nil(t, writer.WriteField("paths[]", relPath))
+		part, err := writer.CreateFormFile("files[]", path.Base(relPath))
+		require.Nil(t, err)
+
+		_, err = part.Write(data)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/v0/upload?root="+url.QueryEscape(root), body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	user, err := s.userDb.Get("ali")
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), dbUserKey("brig.db_user"), user))
+
+	rsw := httptest.NewRecorder()
+	setSession(s.store, "ali", rsw, req)
+	NewUploadHandler(s.State).ServeHTTP(rsw, req)
+	return rsw.Result()
+}
+
+func TestUploadFolder(t *testing.T) {
+	withState(t, func(s *testState) {
+		files := map[string][]byte{
+			"dir/a.txt":         []byte("a"),
+			"dir/sub/b.txt":     []byte("b"),
+			"dir/sub/sub/c.txt": []byte("c"),
+		}
+
+		require.Nil(t, s.fs.Touch("/init"))
+		require.Nil(t, s.fs.MakeCommit("init"))
+		headBefore, err := s.fs.Head()
+		require.Nil(t, err)
+
+		resp := mustDoUploadFolder(t, s, "/up", files)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		for relPath, data := range files {
+			stream, err := s.fs.Cat("/up/" + relPath)
+			require.Nil(t, err)
+
+			streamData, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, data, streamData)
+		}
+
+		// All files should be part of one commit:
+		headAfter, err := s.fs.Head()
+		require.Nil(t, err)
+
+		parent, err := s.fs.CommitInfo(headAfter + "^")
+		require.Nil(t, err)
+		require.Equal(t, headBefore, parent.Hash.B58String())
+	})
+}
+
+func TestUploadFolderPartlyForbidden(t *testing.T) {
+	withState(t, func(s *testState) {
+		s.mustChangeFolders(t, "/up/ok")
+
+		resp := mustDoUploadFolder(t, s, "/up", map[string][]byte{
+			"ok/a.txt":     []byte("a"),
+			"secret/b.txt": []byte("b"),
+		})
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		// Nothing may be staged, not even the allowed file:
+		_, err := s.fs.Stat("/up/ok/a.txt")
+		require.NotNil(t, err)
+	})
+}
+
+// fakeClamd answers every INSTREAM request on `lst` with `reply`.
+func fakeClamd(lst net.Listener, reply string) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			// Read until the terminating zero size chunk:
+			br := bufio.NewReader(conn)
+			if _, err := br.ReadString(0); err != nil {
+				return
+			}
+
+			for {
+				size := uint32(0)
+				if err := binary.Read(br, binary.BigEndian, &size); err != nil || size == 0 {
+					break
+				}
+
+				if _, err := io.CopyN(ioutil.Discard, br, int64(size)); err != nil {
+					return
+				}
+			}
+
+			conn.Write([]byte(reply + "\x00"))
+		}()
+	}
+}
+
+func TestUploadScan(t *testing.T) {
+	withState(t, func(s *testState) {
+		lst, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer lst.Close()
+
+		go fakeClamd(lst, "stream: Eicar-Signature FOUND")
+
+		quarantineDir, err := ioutil.TempDir("", "brig-quarantine-")
+		require.Nil(t, err)
+		defer os.RemoveAll(quarantineDir)
+
+		require.Nil(t, s.cfg.SetBool("scan.enabled", true))
+		require.Nil(t, s.cfg.SetString("scan.url", "tcp://"+lst.Addr().String()))
+		require.Nil(t, s.cfg.SetString("scan.quarantine_dir", quarantineDir))
+
+		resp := mustDoUpload(t, s, "/virus.exe", []byte("hello"))
+		require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		_, err = s.fs.Stat("/virus.exe")
+		require.NotNil(t, err)
+
+		quarantined, err := ioutil.ReadDir(quarantineDir)
+		require.Nil(t, err)
+		require.Len(t, quarantined, 1)
+
+		// A scanner that is not reachable rejects by default:
+		require.Nil(t, s.cfg.SetString("scan.url", "tcp://127.0.0.1:1"))
+		resp = mustDoUpload(t, s, "/file.txt", []byte("hello"))
+		require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		require.Nil(t, s.cfg.SetString("scan.on_error", "accept"))
+		resp = mustDoUpload(t, s, "/file.txt", []byte("hello"))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func mustBuildZip(t *testing.T, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range files {
+		fw, err := zw.Create(name)
+		require.Nil(t, err)
+
+		_, err = fw.Write(data)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, zw.Close())
+	return buf.Bytes()
+}
+
+func mustDoUploadExtract(t *testing.T, s *testState, name string, data []byte) *http.Response {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", path.Base(name))
+	require.Nil(t, err)
+
+	_, err = part.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, writer.Close())
+
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/upload?extract=true&root="+url.QueryEscape(path.Dir(name)),
+		body,
+	)
+	user, err := s.userDb.Get("ali")
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), dbUserKey("brig.db_user"), user))
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rsw := httptest.NewRecorder()
+	setSession(s.store, "ali", rsw, req)
+	NewUploadHandler(s.State).ServeHTTP(rsw, req)
+	return rsw.Result()
+}
+
+func countCommits(t *testing.T, s *testState) int {
+	count := 0
+	require.Nil(t, s.fs.Log("", func(c *catfs.Commit) error {
+		count++
+		return nil
+	}))
+
+	return count
+}
+
+func TestUploadExtractZip(t *testing.T) {
+	withState(t, func(s *testState) {
+		data := mustBuildZip(t, map[string][]byte{
+			"photos/":         nil,
+			"photos/a.png":    []byte("aaa"),
+			"docs/notes.txt":  []byte("notes"),
+			"docs/empty_dir/": nil,
+		})
+
+		nCommitsBefore := countCommits(t, s)
+		resp := mustDoUploadExtract(t, s, "/migrated/tree.zip", data)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		stream, err := s.fs.Cat("/migrated/docs/notes.txt")
+		require.Nil(t, err)
+
+		content, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, []byte("notes"), content)
+
+		info, err := s.fs.Stat("/migrated/docs/empty_dir")
+		require.Nil(t, err)
+		require.True(t, info.IsDir)
+
+		// The archive itself is not stored:
+		_, err = s.fs.Stat("/migrated/tree.zip")
+		require.NotNil(t, err)
+
+		// Everything went into one commit:
+		require.Equal(t, nCommitsBefore+1, countCommits(t, s))
+	})
+}
+
+func TestUploadExtractZipTraversal(t *testing.T) {
+	withState(t, func(s *testState) {
+		data := mustBuildZip(t, map[string][]byte{
+			"good.txt":       []byte("good"),
+			"../../evil.txt": []byte("evil"),
+		})
+
+		resp := mustDoUploadExtract(t, s, "/sub/evil.zip", data)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// Nothing should have been staged:
+		_, err := s.fs.Stat("/sub/good.txt")
+		require.NotNil(t, err)
+		_, err = s.fs.Stat("/evil.txt")
+		require.NotNil(t, err)
+	})
+}
+
+func TestUploadExtractZipDamaged(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/sub/a.txt", bytes.NewReader([]byte("old"))))
+		require.Nil(t, s.fs.Stage("/sub/gone.txt", bytes.NewReader([]byte("gone"))))
+		require.Nil(t, s.fs.MakeCommit("add a.txt"))
+
+		// Changes that were not committed before the upload have to survive it:
+		require.Nil(t, s.fs.Stage("/sub/a.txt", bytes.NewReader([]byte("staged"))))
+		require.Nil(t, s.fs.Stage("/sub/other.txt", bytes.NewReader([]byte("other"))))
+		require.Nil(t, s.fs.Remove("/sub/gone.txt"))
+
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		for _, name := range []string{"a.txt", "gone.txt", "new/b.txt"} {
+			fw, err := zw.Create(name)
+			require.Nil(t, err)
+
+			_, err = fw.Write([]byte(name))
+			require.Nil(t, err)
+		}
+
+		// The last member has a wrong checksum, which is only noticed on extraction:
+		fw, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "c.txt",
+			Method:             zip.Store,
+			CRC32:              42,
+			CompressedSize64:   3,
+			UncompressedSize64: 3,
+		})
+		require.Nil(t, err)
+
+		_, err = fw.Write([]byte("ccc"))
+		require.Nil(t, err)
+		require.Nil(t, zw.Close())
+
+		nCommitsBefore := countCommits(t, s)
+		resp := mustDoUploadExtract(t, s, "/sub/damaged.zip", buf.Bytes())
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// What was extracted before is taken back:
+		for path, expect := range map[string]string{
+			"/sub/a.txt":     "staged",
+			"/sub/other.txt": "other",
+		} {
+			stream, err := s.fs.Cat(path)
+			require.Nil(t, err)
+
+			content, err := ioutil.ReadAll(stream)
+			require.Nil(t, err)
+			require.Equal(t, []byte(expect), content)
+		}
+
+		for _, path := range []string{"/sub/gone.txt", "/sub/new", "/sub/c.txt"} {
+			_, err = s.fs.Stat(path)
+			require.NotNil(t, err, path)
+		}
+
+		deleted, err := s.fs.DeletedNodes("/sub")
+		require.Nil(t, err)
+		require.Len(t, deleted, 1)
+		require.Equal(t, "/sub/gone.txt", deleted[0].Path)
+
+		require.Equal(t, nCommitsBefore, countCommits(t, s))
+	})
+}
+
+func TestUploadExtractZipLimits(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.cfg.SetInt("upload.extract_max_files", 1))
+		data := mustBuildZip(t, map[string][]byte{
+			"a.txt": []byte("a"),
+			"b.txt": []byte("b"),
+		})
+
+		resp := mustDoUploadExtract(t, s, "/sub/many.zip", data)
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		require.Nil(t, s.cfg.SetInt("upload.extract_max_files", 10))
+		require.Nil(t, s.cfg.SetString("upload.extract_max_size", "1"))
+		resp = mustDoUploadExtract(t, s, "/sub/many.zip", data)
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
+
+func TestUploadZipWithoutExtract(t *testing.T) {
+	withState(t, func(s *testState) {
+		data := mustBuildZip(t, map[string][]byte{"a.txt": []byte("a")})
+		resp := mustDoUpload(t, s, "/sub/keep.zip", data)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, err := s.fs.Stat("/sub/keep.zip")
+		require.Nil(t, err)
+	})
+}
@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/sahib/brig/gateway/db"
+)
+
+// UsersOutdatedHandler implements http.Handler
+type UsersOutdatedHandler struct {
+	*State
+}
+
+// NewUsersOutdatedHandler returns a new UsersOutdatedHandler
+func NewUsersOutdatedHandler(s *State) *UsersOutdatedHandler {
+	return &UsersOutdatedHandler{State: s}
+}
+
+// OutdatedUser is a user whose password hash should be upgraded.
+type OutdatedUser struct {
+	Name          string `json:"name"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	HashParams    string `json:"hash_params"`
+}
+
+// UsersOutdatedResponse is the response given by this endpoint.
+type UsersOutdatedResponse struct {
+	Success bool           `json:"success"`
+	Current string         `json:"current"`
+	Users   []OutdatedUser `json:"users"`
+}
+
+func (uh *UsersOutdatedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightAdminUsers) {
+		return
+	}
+
+	users, err := uh.userDb.Outdated()
+	if err != nil {
+		jsonifyErrf(w, http.StatusInternalServerError, "failed to list")
+		return
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	outdated := []OutdatedUser{}
+	for _, user := range users {
+		params := ""
+		if user.HashAlgorithm() == db.HashAlgoArgon2id {
+			params = user.HashParams().String()
+		}
+
+		outdated = append(outdated, OutdatedUser{
+			Name:          user.Name,
+			HashAlgorithm: user.HashAlgorithm(),
+			HashParams:    params,
+		})
+	}
+
+	jsonify(w, http.StatusOK, &UsersOutdatedResponse{
+		Success: true,
+		Current: uh.userDb.HashParams().String(),
+		Users:   outdated,
+	})
+}
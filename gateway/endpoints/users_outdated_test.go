@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sahib/brig/gateway/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersOutdatedEndpoint(t *testing.T) {
+	withState(t, func(s *testState) {
+		stronger := db.DefaultHashParams
+		stronger.Time++
+		s.userDb.SetHashParams(stronger)
+
+		resp := s.mustRun(
+			t,
+			NewUsersOutdatedHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/users/outdated",
+			nil,
+		)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data := &UsersOutdatedResponse{}
+		mustDecodeBody(t, resp.Body, &data)
+		require.True(t, data.Success)
+		require.Equal(t, stronger.String(), data.Current)
+		require.Len(t, data.Users, 1)
+		require.Equal(t, "ali", data.Users[0].Name)
+		require.Equal(t, db.HashAlgoArgon2id, data.Users[0].HashAlgorithm)
+		require.Equal(t, db.DefaultHashParams.String(), data.Users[0].HashParams)
+
+		// A login upgrades the hash:
+		resp = s.mustRun(
+			t,
+			NewLoginHandler(s.State),
+			"POST",
+			"http://localhost:5000/api/v0/login",
+			&LoginRequest{Username: "ali", Password: "ila"},
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		outdated, err := s.userDb.Outdated()
+		require.Nil(t, err)
+		require.Empty(t, outdated)
+	})
+}
@@ -16,6 +16,7 @@ import (
 	ie "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/events"
 	"github.com/sahib/brig/gateway/db"
+	"github.com/sahib/brig/gateway/i18n"
 	"github.com/sahib/brig/gateway/remotesapi"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
@@ -31,6 +32,16 @@ type State struct {
 	evHdl  *EventsHandler
 	store  *sessions.CookieStore
 	userDb *db.UserDatabase
+
+	// signKey authenticates signed download urls.
+	signKey []byte
+
+	// feedKey authenticates feed urls.
+	feedKey []byte
+
+	// backendOnline reports if the backend can reach the network.
+	// It may be nil if the gateway is not run by a daemon.
+	backendOnline func() bool
 }
 
 func readOrInitKeyFromConfig(cfg *config.Config, keyName string, keyLen int) ([]byte, error) {
@@ -70,13 +81,25 @@ func NewState(
 		return nil, err
 	}
 
+	signKey, err := readOrInitKeyFromConfig(cfg, "auth.url-signing-key", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	feedKey, err := readOrInitKeyFromConfig(cfg, "auth.feed-key", 32)
+	if err != nil {
+		return nil, err
+	}
+
 	return &State{
-		fs:     fs,
-		rapi:   rapi,
-		cfg:    cfg,
-		evHdl:  evHdl,
-		store:  sessions.NewCookieStore(authKey, encKey),
-		userDb: userDb,
+		fs:      fs,
+		rapi:    rapi,
+		cfg:     cfg,
+		evHdl:   evHdl,
+		store:   sessions.NewCookieStore(authKey, encKey),
+		userDb:  userDb,
+		signKey: signKey,
+		feedKey: feedKey,
 	}, nil
 }
 
@@ -86,6 +109,12 @@ func (s *State) Close() error {
 	return nil
 }
 
+// SetBackendCheck sets a function that tells if the backend is online.
+// It is used by the readiness endpoint.
+func (s *State) SetBackendCheck(isOnline func() bool) {
+	s.backendOnline = isOnline
+}
+
 // UserDatabase returns the currently opened user database.
 func (s *State) UserDatabase() *db.UserDatabase {
 	return s.userDb
@@ -121,9 +150,21 @@ func prefixRoot(nodePath string) string {
 	return "/" + nodePath
 }
 
+// noInheritPrefix marks a folder that does not inherit the access
+// granted for one of its parents (e.g. "!/public/private").
+const noInheritPrefix = "!"
+
+// buildFolderCache maps every folder in `folders` to true if access to it
+// is granted and to false if it does not inherit access from its parents.
 func buildFolderCache(folders []string) map[string]bool {
 	folderCache := make(map[string]bool)
 	for _, folder := range folders {
+		if strings.HasPrefix(folder, noInheritPrefix) {
+			folder = strings.TrimPrefix(folder, noInheritPrefix)
+			folderCache[prefixRoot(path.Clean(folder))] = false
+			continue
+		}
+
 		folderCache[prefixRoot(path.Clean(folder))] = true
 	}
 
@@ -200,8 +241,10 @@ func (s *State) validatePathForUser(nodePath string, user db.User, w http.Respon
 	folderCache := buildFolderCache(user.Folders)
 
 	for curr != "" {
-		if folderCache[curr] {
-			return true
+		// The nearest folder decides; it either grants access
+		// or stops the access of its parents from being inherited.
+		if isValid, ok := folderCache[curr]; ok {
+			return isValid
 		}
 
 		next := path.Dir(curr)
@@ -233,6 +276,30 @@ func jsonify(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
+// genericCodes is used for messages that are not in the catalog.
+var genericCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// ErrorResponse is sent by all endpoints for errors and simple successes.
+type ErrorResponse struct {
+	Success bool `json:"success"`
+
+	// Message is translated to the language of the request.
+	Message string `json:"message"`
+
+	// Code identifies the message, so the frontend can translate it itself.
+	// Params are the values that were formatted into the message.
+	Code   string   `json:"code"`
+	Params []string `json:"params,omitempty"`
+}
+
 func jsonifyErrf(w http.ResponseWriter, statusCode int, format string, data ...interface{}) {
 	msg := fmt.Sprintf(format, data...)
 	success := false
@@ -243,12 +310,29 @@ func jsonifyErrf(w http.ResponseWriter, statusCode int, format string, data ...i
 		log.Debugf("failed to respond: %v", msg)
 	}
 
-	jsonify(w, statusCode, struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-	}{
+	// The language was chosen by the LanguageMiddleware:
+	lang := w.Header().Get("Content-Language")
+	if lang == "" {
+		lang = i18n.DefaultLang
+	}
+
+	code := i18n.Code(format)
+	if code != "" {
+		msg = i18n.Translate(lang, code, data...)
+	} else if code = genericCodes[statusCode]; code == "" {
+		code = "error"
+	}
+
+	params := []string{}
+	for _, arg := range data {
+		params = append(params, fmt.Sprintf("%v", arg))
+	}
+
+	jsonify(w, statusCode, ErrorResponse{
 		Success: success,
 		Message: msg,
+		Code:    code,
+		Params:  params,
 	})
 }
 
@@ -256,11 +340,17 @@ func jsonifySuccess(w http.ResponseWriter) {
 	jsonifyErrf(w, http.StatusOK, "success")
 }
 
+// gatewayCommitMeta marks commits that were made by the gateway.
+var gatewayCommitMeta = catfs.CommitMeta{Origin: catfs.CommitOriginGateway}
+
 func (s *State) commitChange(msg string, w http.ResponseWriter, r *http.Request) bool {
 	name := getUserName(s.store, w, r)
 	fullMsg := fmt.Sprintf("gateway: »%s« %s", name, msg)
+	if impersonator := getImpersonator(s.store, w, r); impersonator != "" {
+		fullMsg = fmt.Sprintf("gateway: »%s« (as »%s«) %s", impersonator, name, msg)
+	}
 
-	if err := s.fs.MakeCommit(fullMsg); err != nil {
+	if err := s.fs.MakeCommitWithMeta(fullMsg, gatewayCommitMeta); err != nil {
 		if err != ie.ErrNoChange {
 			log.Warningf("could not commit: %v", err)
 			jsonifyErrf(w, http.StatusInternalServerError, "could not commit")
@@ -0,0 +1,110 @@
+package i18n
+
+var catalogDE = map[string]string{
+	"success":                "erfolgreich",
+	"bad_request":            "ungültige Anfrage",
+	"unauthorized":           "nicht autorisiert",
+	"forbidden":              "verboten",
+	"not_found":              "nicht gefunden",
+	"too_large":              "Anfrage ist zu groß",
+	"unsupported_media_type": "nicht unterstützter Medientyp",
+	"internal_error":         "interner Fehler",
+	"error":                  "Fehler",
+
+	"bad_json":              "ungültiges JSON",
+	"bad_fingerprint":       "ungültiges Format des Fingerabdrucks",
+	"bad_multipart":         "Multipart-Formular konnte nicht gelesen werden: %v",
+	"empty_remote_name":     "leerer Name des Remotes",
+	"empty_credentials":     "leeres Passwort oder leerer Benutzername",
+	"negative_offset":       "negativer Offset",
+	"negative_offsets":      "negative Offsets werden nicht unterstützt",
+	"negative_limits":       "Limits dürfen nicht negativ sein",
+	"content_too_big":       "Inhalt ist zu groß",
+	"file_too_big_to_edit":  "Datei ist zu groß zum Bearbeiten",
+	"not_a_text_file":       "Datei ist keine Textdatei",
+	"cannot_edit_directory": "ein Verzeichnis kann nicht bearbeitet werden",
+	"drop_needs_directory":  "Drop-Links brauchen ein Verzeichnis",
+	"error_detail":          "%v",
+
+	"bad_credentials":       "ungültige Zugangsdaten",
+	"bad_user":              "ungültiger Benutzer",
+	"not_logged_in":         "nicht angemeldet",
+	"not_authorized":        "nicht berechtigt",
+	"insufficient_rights":   "unzureichende Rechte",
+	"path_forbidden":        "Zugriff auf den Pfad verboten",
+	"source_forbidden":      "Zugriff auf den Quellpfad verboten",
+	"destination_forbidden": "Zugriff auf den Zielpfad verboten",
+
+	"no_such_file":          "Datei existiert nicht",
+	"no_such_directory":     "Verzeichnis existiert nicht",
+	"no_such_drop_link":     "Drop-Link existiert nicht",
+	"no_such_language":      "Sprache existiert nicht: %s",
+	"no_index_html":         "keine index.html",
+	"remote_exists":         "Remote existiert bereits",
+	"remote_does_not_exist": "Remote existiert noch nicht",
+
+	"maintenance": "das Gateway ist während der Wartung schreibgeschützt",
+
+	"could_not_cast_user":        "Benutzer konnte nicht gelesen werden",
+	"could_not_commit":           "Commit fehlgeschlagen",
+	"could_not_get_status":       "Status konnte nicht abgefragt werden",
+	"could_not_load_template":    "Vorlage konnte nicht geladen werden: %v",
+	"could_not_execute_template": "Vorlage konnte nicht ausgeführt werden",
+	"template_errors":            "Vorlage enthält Fehler",
+	"failed_to_add":              "Hinzufügen fehlgeschlagen",
+	"failed_to_check_history":    "Historie konnte nicht geprüft werden",
+	"failed_to_check_staged":     "Stand konnte nicht geprüft werden: %v",
+	"failed_to_copy":             "Kopieren fehlgeschlagen",
+	"failed_to_create_drop_link": "Drop-Link konnte nicht erstellt werden",
+	"failed_to_diff":             "Vergleich fehlgeschlagen",
+	"failed_to_get_self":         "eigene Identität konnte nicht abgefragt werden",
+	"failed_to_insert_file":      "Datei konnte nicht eingefügt werden: %v",
+	"failed_to_list":             "Auflisten fehlgeschlagen",
+	"failed_to_list_drop_links":  "Drop-Links konnten nicht aufgelistet werden",
+	"failed_to_mkdir":            "Verzeichnis konnte nicht erstellt werden",
+	"failed_to_move":             "Verschieben fehlgeschlagen",
+	"failed_to_open_file":        "Datei konnte nicht geöffnet werden: %v",
+	"failed_to_pin":              "Pinnen fehlgeschlagen",
+	"failed_to_query":            "Abfrage fehlgeschlagen: %v",
+	"failed_to_query_log":        "Log konnte nicht abgefragt werden: %v",
+	"failed_to_read_file":        "Datei konnte nicht gelesen werden",
+	"failed_to_remove":           "Löschen fehlgeschlagen",
+	"failed_to_remove_drop_link": "Drop-Link konnte nicht gelöscht werden",
+	"failed_to_remove_remote":    "Remote konnte nicht gelöscht werden",
+	"failed_to_reset":            "Zurücksetzen fehlgeschlagen",
+	"failed_to_save_file":        "Datei konnte nicht gespeichert werden",
+	"failed_to_stat":             "Dateiinformationen konnten nicht gelesen werden",
+	"failed_to_stat_root":        "Wurzel %s konnte nicht gelesen werden: %v",
+	"failed_to_sync":             "Synchronisieren fehlgeschlagen",
+	"failed_to_undelete":         "Wiederherstellen fehlgeschlagen",
+	"failed_to_unpin":            "Entpinnen fehlgeschlagen",
+
+	"ui.login":             "Anmelden",
+	"ui.login_failed":      "Anmeldung fehlgeschlagen, bitte versuche es erneut.",
+	"ui.logout":            "Abmelden",
+	"ui.loading":           "Lädt...",
+	"ui.cancel":            "Abbrechen",
+	"ui.close":             "Schließen",
+	"ui.create":            "Erstellen",
+	"ui.remove":            "Löschen",
+	"ui.really_remove":     "Wirklich löschen?",
+	"ui.rename":            "Umbenennen",
+	"ui.name":              "Name",
+	"ui.size":              "Größe",
+	"ui.files":             "Dateien",
+	"ui.commits":           "Commits",
+	"ui.remotes":           "Remotes",
+	"ui.deleted_files":     "Gelöschte Dateien",
+	"ui.history":           "Historie",
+	"ui.changelog":         "Änderungen",
+	"ui.revert":            "Zurücksetzen",
+	"ui.checkout":          "Auschecken",
+	"ui.undelete":          "Wiederherstellen",
+	"ui.fingerprint":       "Fingerabdruck",
+	"ui.folders":           "Ordner",
+	"ui.online":            "Online",
+	"ui.auto_update":       "Automatisch aktualisieren",
+	"ui.conflict_strategy": "Konfliktstrategie",
+	"ui.no_differences":    "Es gibt keine Unterschiede!",
+	"ui.something_wrong":   "Oh, da ist etwas schiefgelaufen! :(",
+}
@@ -0,0 +1,119 @@
+package i18n
+
+// catalogEN is the reference catalog. The API messages have to match
+// the format strings used in the endpoints exactly.
+var catalogEN = map[string]string{
+	// Generic messages, used when an endpoint sends an unknown message:
+	"success":                "success",
+	"bad_request":            "bad request",
+	"unauthorized":           "unauthorized",
+	"forbidden":              "forbidden",
+	"not_found":              "not found",
+	"too_large":              "request entity too large",
+	"unsupported_media_type": "unsupported media type",
+	"internal_error":         "internal error",
+	"error":                  "error",
+
+	// Request errors:
+	"bad_json":              "bad json",
+	"bad_fingerprint":       "bad fingerprint format",
+	"bad_multipart":         "failed to parse mutlipart form: %v",
+	"empty_remote_name":     "empty remote name",
+	"empty_credentials":     "empty password or username",
+	"negative_offset":       "negative offset",
+	"negative_offsets":      "negative offsets are not supported",
+	"negative_limits":       "limits may not be negative",
+	"content_too_big":       "content is too big",
+	"file_too_big_to_edit":  "file is too big to edit",
+	"not_a_text_file":       "file is not a text file",
+	"cannot_edit_directory": "cannot edit a directory",
+	"drop_needs_directory":  "drop links need a directory",
+	"error_detail":          "%v",
+
+	// Authentication and rights:
+	"bad_credentials":       "bad credentials",
+	"bad_user":              "bad user",
+	"not_logged_in":         "not logged in",
+	"not_authorized":        "not authorized",
+	"insufficient_rights":   "insufficient rights",
+	"path_forbidden":        "path forbidden",
+	"source_forbidden":      "source path forbidden",
+	"destination_forbidden": "destination path forbidden",
+
+	// Things that do not exist (or do already):
+	"no_such_file":          "no such file",
+	"no_such_directory":     "no such directory",
+	"no_such_drop_link":     "no such drop link",
+	"no_such_language":      "no such language: %s",
+	"no_index_html":         "no index.html",
+	"remote_exists":         "remote does exist already",
+	"remote_does_not_exist": "remote does not exist yet",
+
+	// Maintenance mode:
+	"maintenance": "the gateway is read-only during maintenance",
+
+	// Failed operations:
+	"could_not_cast_user":        "could not cast user",
+	"could_not_commit":           "could not commit",
+	"could_not_get_status":       "could not get status",
+	"could_not_load_template":    "could not load template: %v",
+	"could_not_execute_template": "could not execute template",
+	"template_errors":            "template contains errors",
+	"failed_to_add":              "failed to add",
+	"failed_to_check_history":    "failed to check history",
+	"failed_to_check_staged":     "failed to check staged state: %v",
+	"failed_to_copy":             "failed to copy",
+	"failed_to_create_drop_link": "failed to create drop link",
+	"failed_to_diff":             "failed to diff",
+	"failed_to_get_self":         "failed to get self",
+	"failed_to_insert_file":      "failed to insert file: %v",
+	"failed_to_list":             "failed to list",
+	"failed_to_list_drop_links":  "failed to list drop links",
+	"failed_to_mkdir":            "failed to mkdir",
+	"failed_to_move":             "failed to move",
+	"failed_to_open_file":        "failed to open file: %v",
+	"failed_to_pin":              "failed to pin",
+	"failed_to_query":            "failed to query: %v",
+	"failed_to_query_log":        "failed to query log: %v",
+	"failed_to_read_file":        "failed to read file",
+	"failed_to_remove":           "failed to remove",
+	"failed_to_remove_drop_link": "failed to remove drop link",
+	"failed_to_remove_remote":    "failed to remove remote",
+	"failed_to_reset":            "failed to reset",
+	"failed_to_save_file":        "failed to save file",
+	"failed_to_stat":             "failed to stat",
+	"failed_to_stat_root":        "failed to stat root %s: %v",
+	"failed_to_sync":             "failed to sync",
+	"failed_to_undelete":         "failed to undelete",
+	"failed_to_unpin":            "failed to unpin",
+
+	// UI strings:
+	"ui.login":             "Login",
+	"ui.login_failed":      "Login failed, please try again.",
+	"ui.logout":            "Logout",
+	"ui.loading":           "Loading...",
+	"ui.cancel":            "Cancel",
+	"ui.close":             "Close",
+	"ui.create":            "Create",
+	"ui.remove":            "Remove",
+	"ui.really_remove":     "Really remove?",
+	"ui.rename":            "Rename",
+	"ui.name":              "Name",
+	"ui.size":              "Size",
+	"ui.files":             "Files",
+	"ui.commits":           "Commits",
+	"ui.remotes":           "Remotes",
+	"ui.deleted_files":     "Deleted files",
+	"ui.history":           "History",
+	"ui.changelog":         "Changelog",
+	"ui.revert":            "Revert",
+	"ui.checkout":          "Checkout",
+	"ui.undelete":          "Undelete",
+	"ui.fingerprint":       "Fingerprint",
+	"ui.folders":           "Folders",
+	"ui.online":            "Online",
+	"ui.auto_update":       "Auto Update",
+	"ui.conflict_strategy": "Conflict Strategy",
+	"ui.no_differences":    "There are no differences!",
+	"ui.something_wrong":   "Oh, something went wrong! :(",
+}
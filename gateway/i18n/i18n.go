@@ -0,0 +1,125 @@
+// Package i18n translates the messages and UI strings of the gateway.
+//
+// Every message has a stable code (like "bad_json") that is sent along with
+// the english text, so the frontend can also translate it by itself. The
+// catalogs are keyed by this code. API messages are looked up by their
+// english format string, UI strings use codes prefixed with "ui.".
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLang is used when the client does not want any language we know.
+const DefaultLang = "en"
+
+var catalogs = map[string]map[string]string{
+	"en": catalogEN,
+	"de": catalogDE,
+}
+
+// codeByFormat maps the english format strings back to their code.
+var codeByFormat = map[string]string{}
+
+func init() {
+	for code, format := range catalogEN {
+		if !strings.HasPrefix(code, "ui.") {
+			codeByFormat[format] = code
+		}
+	}
+}
+
+// Languages returns all languages we have a catalog for.
+func Languages() []string {
+	langs := []string{}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+
+	sort.Strings(langs)
+	return langs
+}
+
+// IsSupported returns true if we have a catalog for `lang`.
+func IsSupported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Negotiate picks the best language from an Accept-Language header.
+// Regional variants like »de-AT« are matched by their base language.
+func Negotiate(acceptLanguage string) string {
+	bestLang, bestQ := DefaultLang, -1.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = parsed
+			}
+		}
+
+		lang := strings.SplitN(tag, "-", 2)[0]
+		if lang == "*" {
+			lang = DefaultLang
+		}
+
+		// Earlier entries win on equal quality:
+		if q > 0 && q > bestQ && IsSupported(lang) {
+			bestLang, bestQ = lang, q
+		}
+	}
+
+	return bestLang
+}
+
+// Code returns the code of the english message `format`,
+// or an empty string if it is not in the catalog.
+func Code(format string) string {
+	return codeByFormat[format]
+}
+
+// Translate formats the message `code` in `lang` with `args`.
+// Missing translations fall back to english.
+func Translate(lang, code string, args ...interface{}) string {
+	format, ok := catalogs[lang][code]
+	if !ok {
+		if format, ok = catalogEN[code]; !ok {
+			return code
+		}
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// Catalog returns a copy of all messages of `lang`,
+// filled up with english ones where no translation exists.
+func Catalog(lang string) map[string]string {
+	catalog := make(map[string]string, len(catalogEN))
+	for code, format := range catalogEN {
+		catalog[code] = format
+	}
+
+	for code, format := range catalogs[lang] {
+		catalog[code] = format
+	}
+
+	return catalog
+}
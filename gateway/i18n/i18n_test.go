@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tcs := []struct {
+		header, lang string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-AT,de;q=0.9,en;q=0.8", "de"},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7", "en"},
+		{"fr, de;q=0.5, en;q=0.6", "en"},
+		{"en;q=0.1, DE", "de"},
+		{"de;q=0", "en"},
+		{"*", "en"},
+		{"fr", "en"},
+	}
+
+	for _, tc := range tcs {
+		require.Equal(t, tc.lang, Negotiate(tc.header), tc.header)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	code := Code("failed to stat root %s: %v")
+	require.Equal(t, "failed_to_stat_root", code)
+	require.Equal(
+		t,
+		"Wurzel /x konnte nicht gelesen werden: boom",
+		Translate("de", code, "/x", "boom"),
+	)
+
+	// Unknown languages fall back to english:
+	require.Equal(t, "bad json", Translate("fr", "bad_json"))
+	require.Equal(t, "", Code("not in the catalog"))
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	for _, lang := range Languages() {
+		for code, format := range catalogs[lang] {
+			enFormat, ok := catalogEN[code]
+			require.True(t, ok, "%s: %s is not in the english catalog", lang, code)
+
+			// Translations need to take the same arguments:
+			require.Equal(
+				t,
+				strings.Count(enFormat, "%"),
+				strings.Count(format, "%"),
+				"%s: %s", lang, code,
+			)
+		}
+
+		require.Equal(t, len(catalogEN), len(Catalog(lang)))
+	}
+}
@@ -4,6 +4,7 @@
 package remotesapi
 
 import (
+	"errors"
 	"time"
 
 	"github.com/sahib/brig/catfs"
@@ -26,6 +27,7 @@ type Remote struct {
 	IsAuthenticated   bool      `json:"is_authenticated"`
 	AcceptPush        bool      `json:"accept_push"`
 	ConflictStrategy  string    `json:"conflict_strategy"`
+	Trust             string    `json:"trust"`
 	LastSeen          time.Time `json:"last_seen"`
 }
 
@@ -35,6 +37,10 @@ type Identity struct {
 	Fingerprint string `json:"fingerprint"`
 }
 
+// ErrSyncInProgress is returned by TrySync when a sync with
+// the same remote is already running.
+var ErrSyncInProgress = errors.New("a sync with this remote is already in progress")
+
 // RemotesAPI provides a simpler interface to accessing remote information
 // from repo.Repository, net.PeerServer and events.EventListener.
 type RemotesAPI interface {
@@ -46,5 +52,9 @@ type RemotesAPI interface {
 	OnChange(fn func())
 
 	Sync(name string) error
+
+	// TrySync returns ErrSyncInProgress instead of waiting for another sync.
+	TrySync(name string) error
 	MakeDiff(name string) (*catfs.Diff, error)
+	SyncPreview(name string) (*catfs.Diff, error)
 }
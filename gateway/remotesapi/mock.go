@@ -105,6 +105,11 @@ func (m *Mock) Sync(name string) error {
 	return nil
 }
 
+// TrySync is like Sync; the mock never has a sync in progress.
+func (m *Mock) TrySync(name string) error {
+	return m.Sync(name)
+}
+
 func dummyNode(path, user string, isDir bool) catfs.StatInfo {
 	return catfs.StatInfo{
 		BackendHash: h.EmptyBackendHash.Clone(),
@@ -163,6 +168,17 @@ func (m *Mock) MakeDiff(name string) (*catfs.Diff, error) {
 	}, nil
 }
 
+// SyncPreview tells what syncing with `name` would change.
+func (m *Mock) SyncPreview(name string) (*catfs.Diff, error) {
+	diff, err := m.MakeDiff(name)
+	if err != nil {
+		return nil, err
+	}
+
+	diff.TransferSize = 1024
+	return diff, nil
+}
+
 func (m *Mock) notify() {
 	for _, fn := range m.callbacks {
 		fn()
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks sent to clamd.
+// It has to be below clamd's StreamMaxLength, which is much bigger.
+const clamdChunkSize = 64 * 1024
+
+type clamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+// parseClamdReply interprets the answer to INSTREAM, which is one of:
+//
+//	stream: OK
+//	stream: Eicar-Signature FOUND
+//	INSTREAM size limit exceeded. ERROR
+func parseClamdReply(reply string) (*Result, error) {
+	reply = strings.TrimRight(reply, "\x00\n")
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if idx := strings.Index(signature, ": "); idx >= 0 {
+			signature = signature[idx+2:]
+		}
+
+		return &Result{Infected: true, Signature: signature}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+func (cs *clamdScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	conn, err := dial(ctx, cs.network, cs.addr, cs.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	// The "z" prefix means that the command is terminated by a null byte.
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return nil, err
+	}
+
+	// Every chunk is prefixed with its size; a zero size ends the stream.
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, rerr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection if the stream is too big,
+				// but it still tells us why. Try to read that first.
+				if reply, _ := bufio.NewReader(conn).ReadString(0); reply != "" {
+					return parseClamdReply(reply)
+				}
+
+				return nil, err
+			}
+		}
+
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	return readClamdReply(conn)
+}
+
+func readClamdReply(r io.Reader) (*Result, error) {
+	reply, err := bufio.NewReader(r).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, err
+	}
+
+	return parseClamdReply(reply)
+}
@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icapDefaultPort = "1344"
+
+	// The HTTP response we pretend to have received. Scanners only
+	// look at the body, but RESPMOD requires some headers around it.
+	icapFakeResponse = "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n\r\n"
+)
+
+// Headers that ICAP servers use to tell what they found.
+var icapInfectionHeaders = []string{
+	"X-Infection-Found",
+	"X-Virus-Id",
+	"X-Violations-Found",
+}
+
+type icapScanner struct {
+	url     *url.URL
+	timeout time.Duration
+}
+
+func (is *icapScanner) addr() string {
+	if is.url.Port() == "" {
+		return net.JoinHostPort(is.url.Hostname(), icapDefaultPort)
+	}
+
+	return is.url.Host
+}
+
+// parseInfectionHeader extracts the name of what was found.
+// X-Infection-Found looks like "Type=0; Resolution=2; Threat=Eicar;",
+// the other headers usually only contain the name.
+func parseInfectionHeader(value string) string {
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "Threat=") {
+			return strings.TrimPrefix(part, "Threat=")
+		}
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// icapResult interprets the status and headers of an ICAP response.
+// Since we allow 204, the server only answers with 200 if it wanted
+// to change the content, i.e. if it found something.
+func icapResult(status int, hdr textproto.MIMEHeader) (*Result, error) {
+	switch status {
+	case 204:
+		return &Result{}, nil
+	case 200:
+		res := &Result{Infected: true}
+		for _, key := range icapInfectionHeaders {
+			if value := hdr.Get(key); value != "" {
+				res.Signature = parseInfectionHeader(value)
+				break
+			}
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("icap: unexpected status %d", status)
+	}
+}
+
+func (is *icapScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	conn, err := dial(ctx, "tcp", is.addr(), is.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	bw := bufio.NewWriter(conn)
+	fmt.Fprintf(bw, "RESPMOD %s ICAP/1.0\r\n", is.url.String())
+	fmt.Fprintf(bw, "Host: %s\r\n", is.url.Host)
+	fmt.Fprintf(bw, "Allow: 204\r\n")
+	fmt.Fprintf(bw, "Encapsulated: res-hdr=0, res-body=%d\r\n", len(icapFakeResponse))
+	fmt.Fprintf(bw, "\r\n%s", icapFakeResponse)
+
+	// The body is sent in chunked encoding:
+	buf := make([]byte, 64*1024)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			fmt.Fprintf(bw, "%x\r\n", n)
+			bw.Write(buf[:n])
+			bw.WriteString("\r\n")
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	bw.WriteString("0\r\n\r\n")
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	tr := textproto.NewReader(bufio.NewReader(conn))
+	statusLine, err := tr.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	split := strings.SplitN(statusLine, " ", 3)
+	if len(split) < 2 || !strings.HasPrefix(split[0], "ICAP/") {
+		return nil, fmt.Errorf("icap: bad status line: %s", statusLine)
+	}
+
+	status, err := strconv.Atoi(split[1])
+	if err != nil {
+		return nil, fmt.Errorf("icap: bad status line: %s", statusLine)
+	}
+
+	hdr, err := tr.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return icapResult(status, hdr)
+}
@@ -0,0 +1,93 @@
+// Package scan checks uploaded files for viruses before they are staged.
+//
+// Two kinds of services are supported: clamd (the daemon of ClamAV),
+// which is spoken to with its INSTREAM command, and ICAP servers (RFC 3507)
+// like c-icap or most commercial scanners, which are sent a RESPMOD request.
+// The service is selected by the scheme of its url:
+//
+//	tcp://localhost:3310           clamd over tcp
+//	unix:///run/clamav/clamd.ctl   clamd over a unix socket
+//	icap://localhost:1344/avscan   icap; the path is the service name
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Result is the verdict of a scan.
+type Result struct {
+	// Infected is true if the scanner found something.
+	Infected bool
+	// Signature is the name of what was found, if the scanner told us.
+	Signature string
+}
+
+func (res *Result) String() string {
+	if !res.Infected {
+		return "clean"
+	}
+
+	if res.Signature == "" {
+		return "infected"
+	}
+
+	return "infected: " + res.Signature
+}
+
+// Scanner checks a stream of data.
+type Scanner interface {
+	// Scan reads `r` until EOF and returns the verdict.
+	// An error means that the scan could not be done,
+	// not that anything was found.
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// New returns a Scanner for the service at `rawURL`.
+// Every scan may take at most `timeout`.
+func New(rawURL string, timeout time.Duration) (Scanner, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		return &clamdScanner{network: "tcp", addr: u.Host, timeout: timeout}, nil
+	case "unix":
+		return &clamdScanner{network: "unix", addr: u.Path, timeout: timeout}, nil
+	case "icap":
+		return &icapScanner{url: u, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scan service: %s (use tcp://, unix:// or icap://)", rawURL)
+	}
+}
+
+// dial connects to `addr` and makes sure the connection
+// does not outlive `ctx` or `timeout`.
+func dial(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
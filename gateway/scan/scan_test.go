@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = "EICAR-TEST"
+
+// fakeClamd reads one INSTREAM request and reports
+// everything that contains `eicar` as infected.
+func fakeClamd(t *testing.T, lst net.Listener) {
+	conn, err := lst.Accept()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	cmd, err := br.ReadString(0)
+	require.Nil(t, err)
+	require.Equal(t, "zINSTREAM\x00", cmd)
+
+	data := &bytes.Buffer{}
+	for {
+		size := uint32(0)
+		require.Nil(t, binary.Read(br, binary.BigEndian, &size))
+		if size == 0 {
+			break
+		}
+
+		_, err := io.CopyN(data, br, int64(size))
+		require.Nil(t, err)
+	}
+
+	if strings.Contains(data.String(), eicar) {
+		conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+	} else {
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func TestClamd(t *testing.T) {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer lst.Close()
+
+	scanner, err := New("tcp://"+lst.Addr().String(), 5*time.Second)
+	require.Nil(t, err)
+
+	go fakeClamd(t, lst)
+	res, err := scanner.Scan(context.Background(), bytes.NewReader(bytes.Repeat([]byte("x"), 200*1024)))
+	require.Nil(t, err)
+	require.False(t, res.Infected)
+
+	go fakeClamd(t, lst)
+	res, err = scanner.Scan(context.Background(), strings.NewReader("hello "+eicar))
+	require.Nil(t, err)
+	require.True(t, res.Infected)
+	require.Equal(t, "Eicar-Signature", res.Signature)
+}
+
+func TestClamdReply(t *testing.T) {
+	_, err := parseClamdReply("INSTREAM size limit exceeded. ERROR\x00")
+	require.NotNil(t, err)
+}
+
+// fakeICAP answers one RESPMOD request like fakeClamd.
+func fakeICAP(t *testing.T, lst net.Listener) {
+	conn, err := lst.Accept()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	tr := textproto.NewReader(br)
+	line, err := tr.ReadLine()
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(line, "RESPMOD icap://"))
+
+	hdr, err := tr.ReadMIMEHeader()
+	require.Nil(t, err)
+	require.Equal(t, "204", hdr.Get("Allow"))
+
+	// Skip the encapsulated http header:
+	_, err = tr.ReadLine()
+	require.Nil(t, err)
+
+	_, err = tr.ReadMIMEHeader()
+	require.Nil(t, err)
+
+	data := &bytes.Buffer{}
+	for {
+		sizeLine, err := tr.ReadLine()
+		require.Nil(t, err)
+
+		size, err := strconv.ParseInt(sizeLine, 16, 64)
+		require.Nil(t, err)
+
+		_, err = io.CopyN(data, br, size)
+		require.Nil(t, err)
+
+		_, err = tr.ReadLine()
+		require.Nil(t, err)
+
+		if size == 0 {
+			break
+		}
+	}
+
+	if strings.Contains(data.String(), eicar) {
+		conn.Write([]byte("ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Eicar-Test;\r\n\r\n"))
+	} else {
+		conn.Write([]byte("ICAP/1.0 204 No Content\r\n\r\n"))
+	}
+}
+
+func TestICAP(t *testing.T) {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer lst.Close()
+
+	scanner, err := New("icap://"+lst.Addr().String()+"/avscan", 5*time.Second)
+	require.Nil(t, err)
+
+	go fakeICAP(t, lst)
+	res, err := scanner.Scan(context.Background(), bytes.NewReader(bytes.Repeat([]byte("x"), 200*1024)))
+	require.Nil(t, err)
+	require.False(t, res.Infected)
+
+	go fakeICAP(t, lst)
+	res, err = scanner.Scan(context.Background(), strings.NewReader("hello "+eicar))
+	require.Nil(t, err)
+	require.True(t, res.Infected)
+	require.Equal(t, "Eicar-Test", res.Signature)
+}
+
+func TestICAPResult(t *testing.T) {
+	res, err := icapResult(204, textproto.MIMEHeader{})
+	require.Nil(t, err)
+	require.False(t, res.Infected)
+
+	hdr := textproto.MIMEHeader{}
+	hdr.Set("X-Virus-ID", "Eicar-Test")
+	res, err = icapResult(200, hdr)
+	require.Nil(t, err)
+	require.True(t, res.Infected)
+	require.Equal(t, "Eicar-Test", res.Signature)
+
+	_, err = icapResult(500, textproto.MIMEHeader{})
+	require.NotNil(t, err)
+}
+
+func TestBadURL(t *testing.T) {
+	_, err := New("http://localhost", time.Second)
+	require.NotNil(t, err)
+}
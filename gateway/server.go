@@ -7,14 +7,17 @@ import (
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/dustin/go-humanize"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/phogolabs/parcello"
 	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/events"
+	"github.com/sahib/brig/events/bus"
 	"github.com/sahib/brig/gateway/db"
 	"github.com/sahib/brig/gateway/endpoints"
 	"github.com/sahib/brig/gateway/remotesapi"
+	"github.com/sahib/brig/util/server"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
 	"github.com/ulule/limiter"
@@ -40,11 +43,27 @@ type Gateway struct {
 	isReloading bool
 	state       *endpoints.State
 	evHdl       *endpoints.EventsHandler
+	evBus       *bus.Bus
 
 	srv      *http.Server
 	redirSrv *http.Server
 }
 
+// hashParamsFromConfig reads the password hash parameters in gateway.auth.
+func hashParamsFromConfig(cfg *config.Config) (db.HashParams, error) {
+	memory, err := humanize.ParseBytes(cfg.String("auth.hash_memory"))
+	if err != nil {
+		return db.HashParams{}, err
+	}
+
+	return db.HashParams{
+		Time:    uint32(cfg.Int("auth.hash_iterations")),
+		Memory:  uint32(memory / 1024),
+		Threads: uint8(cfg.Int("auth.hash_threads")),
+		KeyLen:  db.DefaultHashParams.KeyLen,
+	}, nil
+}
+
 // NewGateway returns a newly built gateway.
 // This function does not yet start a server.
 func NewGateway(fs *catfs.FS, rapi remotesapi.RemotesAPI, cfg *config.Config, ev *events.Listener, dbPath string) (*Gateway, error) {
@@ -53,6 +72,13 @@ func NewGateway(fs *catfs.FS, rapi remotesapi.RemotesAPI, cfg *config.Config, ev
 		return nil, err
 	}
 
+	hashParams, err := hashParamsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	userDb.SetHashParams(hashParams)
+
 	evHdl := endpoints.NewEventsHandler(rapi, ev)
 	state, err := endpoints.NewState(fs, rapi, cfg, evHdl, ev, userDb)
 	if err != nil {
@@ -97,9 +123,26 @@ func NewGateway(fs *catfs.FS, rapi remotesapi.RemotesAPI, cfg *config.Config, ev
 	cfg.AddEvent("auth.session-encryption-key", reloader)
 	cfg.AddEvent("auth.session-authentication-key", reloader)
 	cfg.AddEvent("auth.session-csrf-key", reloader)
+	cfg.AddEvent("auth.url-signing-key", reloader)
 	return gw, nil
 }
 
+// SetEventBus connects the gateway to the local event bus.
+// Changes published there are pushed to the websockets of the ui.
+// The gateway only listens on the bus while it is running.
+func (gw *Gateway) SetEventBus(evBus *bus.Bus) {
+	gw.evBus = evBus
+	if !gw.isClosed {
+		gw.evHdl.SetEventBus(evBus)
+	}
+}
+
+// SetBackendCheck sets a function that tells if the backend is online.
+// If it is not set, the readiness check does not look at the backend.
+func (gw *Gateway) SetBackendCheck(isOnline func() bool) {
+	gw.state.SetBackendCheck(isOnline)
+}
+
 // Stop stops the gateway gracefully.
 func (gw *Gateway) Stop() error {
 	if gw.isClosed {
@@ -107,11 +150,12 @@ func (gw *Gateway) Stop() error {
 	}
 
 	gw.isClosed = true
+	gw.evHdl.SetEventBus(nil)
 	if err := gw.state.Close(); err != nil {
 		log.Warningf("failed to shutdown state object: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), gw.cfg.Duration("shutdown_timeout"))
 	defer cancel()
 
 	if gw.redirSrv != nil {
@@ -146,6 +190,9 @@ func (gw *Gateway) Start() {
 	}
 
 	gw.isClosed = false
+	if gw.evBus != nil {
+		gw.evHdl.SetEventBus(gw.evBus)
+	}
 
 	port := gw.cfg.Int("port")
 	addr := fmt.Sprintf(":%d", port)
@@ -189,7 +236,14 @@ func (gw *Gateway) Start() {
 	// This does not influence GET routes, only POST ones:
 	router := mux.NewRouter()
 	router.Use(endpoints.SecureMiddleware(gw.state))
+	router.Use(endpoints.LanguageMiddleware())
+	router.Use(endpoints.AnnouncementMiddleware(gw.state))
 	needsAuth := endpoints.AuthMiddleware(gw.state)
+	readOnly := endpoints.MaintenanceMiddleware(gw.state)
+
+	// Probes for load balancers and orchestrators; they need no login:
+	router.Handle("/healthz", endpoints.NewHealthzHandler(gw.state)).Methods("GET", "HEAD")
+	router.Handle("/readyz", endpoints.NewReadyzHandler(gw.state)).Methods("GET", "HEAD")
 
 	csrfOpts := []csrf.Option{
 		csrf.ErrorHandler(&csrfErrorHandler{}),
@@ -208,30 +262,60 @@ func (gw *Gateway) Start() {
 		apiRouter.Handle("/login", endpoints.NewLoginHandler(gw.state))
 		apiRouter.Handle("/whoami", endpoints.NewWhoamiHandler(gw.state))
 		apiRouter.Handle("/ping", endpoints.NewPingHandler(gw.state))
+		apiRouter.Handle("/i18n", endpoints.NewI18nHandler(gw.state))
 		apiRouter.Handle("/logout", needsAuth(endpoints.NewLogoutHandler(gw.state)))
 		apiRouter.Handle("/ls", needsAuth(endpoints.NewLsHandler(gw.state)))
-		apiRouter.Handle("/upload", needsAuth(endpoints.NewUploadHandler(gw.state)))
-		apiRouter.Handle("/move", needsAuth(endpoints.NewMoveHandler(gw.state)))
-		apiRouter.Handle("/mkdir", needsAuth(endpoints.NewMkdirHandler(gw.state)))
-		apiRouter.Handle("/copy", needsAuth(endpoints.NewCopyHandler(gw.state)))
-		apiRouter.Handle("/remove", needsAuth(endpoints.NewRemoveHandler(gw.state)))
+		apiRouter.Handle("/upload", needsAuth(readOnly(endpoints.NewUploadHandler(gw.state))))
+		apiRouter.Handle("/move", needsAuth(readOnly(endpoints.NewMoveHandler(gw.state))))
+		apiRouter.Handle("/mkdir", needsAuth(readOnly(endpoints.NewMkdirHandler(gw.state))))
+		apiRouter.Handle("/copy", needsAuth(readOnly(endpoints.NewCopyHandler(gw.state))))
+		apiRouter.Handle("/remove", needsAuth(readOnly(endpoints.NewRemoveHandler(gw.state))))
 		apiRouter.Handle("/history", needsAuth(endpoints.NewHistoryHandler(gw.state)))
-		apiRouter.Handle("/reset", needsAuth(endpoints.NewResetHandler(gw.state)))
+		apiRouter.Handle("/counters", needsAuth(endpoints.NewCountersHandler(gw.state)))
+		apiRouter.Handle("/blockdiff", needsAuth(endpoints.NewBlockDiffHandler(gw.state)))
+		apiRouter.Handle("/activity", needsAuth(endpoints.NewActivityHandler(gw.state)))
+		apiRouter.Handle("/feed/url", needsAuth(endpoints.NewFeedURLHandler(gw.state)))
+		apiRouter.Handle("/reset", needsAuth(readOnly(endpoints.NewResetHandler(gw.state))))
 		apiRouter.Handle("/all-dirs", needsAuth(endpoints.NewAllDirsHandler(gw.state)))
+		apiRouter.Handle("/du", needsAuth(endpoints.NewDiskUsageHandler(gw.state)))
 		apiRouter.Handle("/log", needsAuth(endpoints.NewLogHandler(gw.state)))
 		apiRouter.Handle("/deleted", needsAuth(endpoints.NewDeletedPathsHandler(gw.state)))
-		apiRouter.Handle("/undelete", needsAuth(endpoints.NewUndeleteHandler(gw.state)))
-		apiRouter.Handle("/pin", needsAuth(endpoints.NewPinHandler(gw.state)))
-		apiRouter.Handle("/unpin", needsAuth(endpoints.NewUnpinHandler(gw.state)))
+		apiRouter.Handle("/undelete", needsAuth(readOnly(endpoints.NewUndeleteHandler(gw.state))))
+		apiRouter.Handle("/pin", needsAuth(readOnly(endpoints.NewPinHandler(gw.state))))
+		apiRouter.Handle("/unpin", needsAuth(readOnly(endpoints.NewUnpinHandler(gw.state))))
+		apiRouter.Handle("/edit/load", needsAuth(endpoints.NewEditLoadHandler(gw.state)))
+		apiRouter.Handle("/edit/save", needsAuth(readOnly(endpoints.NewEditSaveHandler(gw.state))))
+		apiRouter.Handle("/edit/preview", needsAuth(endpoints.NewEditPreviewHandler(gw.state)))
+		apiRouter.Handle("/drop/create", needsAuth(endpoints.NewDropCreateHandler(gw.state)))
+		apiRouter.Handle("/drop/list", needsAuth(endpoints.NewDropListHandler(gw.state)))
+		apiRouter.Handle("/drop/remove", needsAuth(endpoints.NewDropRemoveHandler(gw.state)))
+		apiRouter.Handle("/upload-policy/list", needsAuth(endpoints.NewUploadPolicyListHandler(gw.state)))
+		apiRouter.Handle("/upload-policy/set", needsAuth(endpoints.NewUploadPolicySetHandler(gw.state)))
+		apiRouter.Handle("/upload-policy/remove", needsAuth(endpoints.NewUploadPolicyRemoveHandler(gw.state)))
+		apiRouter.Handle("/labels/add", needsAuth(readOnly(endpoints.NewLabelsAddHandler(gw.state))))
+		apiRouter.Handle("/labels/remove", needsAuth(readOnly(endpoints.NewLabelsRemoveHandler(gw.state))))
+		apiRouter.Handle("/labels/list", needsAuth(endpoints.NewLabelsListHandler(gw.state)))
+		apiRouter.Handle("/sign", needsAuth(endpoints.NewSignHandler(gw.state)))
+
+		// Drop links can be used without login, but only allow uploading:
+		router.Handle("/drop/{token}", endpoints.NewDropHandler(gw.state)).Methods("GET")
+		router.Handle("/drop/{token}", readOnly(endpoints.NewDropHandler(gw.state))).Methods("POST")
 
 		// Remote API:
 		apiRouter.Handle("/remotes/list", needsAuth(endpoints.NewRemotesListHandler(gw.state)))
-		apiRouter.Handle("/remotes/add", needsAuth(endpoints.NewRemotesAddHandler(gw.state)))
-		apiRouter.Handle("/remotes/modify", needsAuth(endpoints.NewRemotesModifyHandler(gw.state)))
-		apiRouter.Handle("/remotes/remove", needsAuth(endpoints.NewRemotesRemoveHandler(gw.state)))
+		apiRouter.Handle("/remotes/add", needsAuth(readOnly(endpoints.NewRemotesAddHandler(gw.state))))
+		apiRouter.Handle("/remotes/modify", needsAuth(readOnly(endpoints.NewRemotesModifyHandler(gw.state))))
+		apiRouter.Handle("/remotes/remove", needsAuth(readOnly(endpoints.NewRemotesRemoveHandler(gw.state))))
 		apiRouter.Handle("/remotes/self", needsAuth(endpoints.NewRemotesSelfHandler(gw.state)))
-		apiRouter.Handle("/remotes/sync", needsAuth(endpoints.NewRemotesSyncHandler(gw.state)))
+		apiRouter.Handle("/remotes/sync", needsAuth(readOnly(endpoints.NewRemotesSyncHandler(gw.state))))
 		apiRouter.Handle("/remotes/diff", needsAuth(endpoints.NewRemotesDiffHandler(gw.state)))
+		apiRouter.Handle("/remotes/sync-preview", needsAuth(endpoints.NewRemotesSyncPreviewHandler(gw.state)))
+
+		// User administration:
+		apiRouter.Handle("/users/outdated", needsAuth(endpoints.NewUsersOutdatedHandler(gw.state)))
+		apiRouter.Handle("/impersonate", needsAuth(endpoints.NewImpersonateHandler(gw.state)))
+		apiRouter.Handle("/acl/apply", needsAuth(endpoints.NewACLApplyHandler(gw.state)))
+		apiRouter.Handle("/maintenance", needsAuth(endpoints.NewMaintenanceHandler(gw.state)))
 	}
 
 	// Add the /get endpoint. Since it might contain any path, we have to
@@ -240,13 +324,35 @@ func (gw *Gateway) Start() {
 	// since it needs to be available if somebody is not using the UI.
 	router.PathPrefix("/get").Handler(endpoints.NewGetHandler(gw.state)).Methods("GET")
 
+	// Feeds authenticate with a token in the url, since feed readers can not log in:
+	router.PathPrefix(endpoints.FeedPrefix + "/").Handler(endpoints.NewFeedHandler(gw.state)).Methods("GET")
+
+	// Deep links render a preview for chat apps and redirect browsers.
+	// They only show details of files the requester may see.
+	router.PathPrefix(endpoints.LinkPrefix).Handler(endpoints.NewLinkHandler(gw.state)).Methods("GET")
+	router.Handle("/oembed", endpoints.NewOEmbedHandler(gw.state)).Methods("GET")
+
+	// The website folder is public and checks by itself if it is enabled.
+	router.PathPrefix(endpoints.SitePrefix).Handler(endpoints.NewSiteHandler(gw.state)).Methods("GET", "HEAD")
+
 	if uiEnabled {
+		// The activity feed can also be fetched with a plain GET:
+		router.Handle("/api/v0/activity", needsAuth(endpoints.NewActivityHandler(gw.state))).Methods("GET")
+
+		// Commit archives are downloaded by the browser directly:
+		router.Handle("/api/v0/commit/archive", needsAuth(endpoints.NewCommitArchiveHandler(gw.state))).Methods("GET")
+
 		// /events is a websocket that pushes events to the client.
 		// The client will probably call /ls then.
 		router.PathPrefix("/events").Handler(needsAuth(gw.evHdl)).Methods("GET")
 
 		// Special case: index.html gets a csrf token:
-		idxHdl := endpoints.NewIndexHandler(gw.state)
+		assets := endpoints.NewAssetHandler(parcello.ManagerAt("/"), false)
+		if gw.cfg.Bool("ui.debug_mode") {
+			assets = endpoints.NewAssetHandler(http.Dir("./gateway/static"), true)
+		}
+
+		idxHdl := endpoints.NewIndexHandler(gw.state, assets)
 		router.Handle("/", idxHdl).Methods("GET")
 		router.Handle("/index.html", idxHdl).Methods("GET")
 
@@ -263,15 +369,10 @@ func (gw *Gateway) Start() {
 			router.PathPrefix(route).Handler(idxHdl).Methods("GET")
 		}
 
-		// Serve all files in the static directory as-is.
+		// Serve all files in the static directory.
 		// This has to come last, since it's a wildcard for everything else.
 		// The static files are packed inside the binary (for now)
-
-		if gw.cfg.Bool("ui.debug_mode") {
-			router.PathPrefix("/").Handler(http.FileServer(http.Dir("./gateway/static")))
-		} else {
-			router.PathPrefix("/").Handler(http.FileServer(parcello.ManagerAt("/")))
-		}
+		router.PathPrefix("/").Handler(assets)
 	}
 
 	// Implement rate limiting:
@@ -293,11 +394,18 @@ func (gw *Gateway) Start() {
 		// WriteTimeout:      10 * time.Second,
 	}
 
+	// Listen via util/server, so the port survives a restart of the daemon:
+	lst, err := server.Listen("tcp", addr)
+	if err != nil {
+		log.Errorf("failed to listen on %s: %v", addr, err)
+		return
+	}
+
 	go func() {
 		if tlsConfig != nil {
-			err = gw.srv.ListenAndServeTLS("", "")
+			err = gw.srv.ServeTLS(lst, "", "")
 		} else {
-			err = gw.srv.ListenAndServe()
+			err = gw.srv.Serve(lst)
 		}
 
 		if err != nil && err != http.ErrServerClosed {
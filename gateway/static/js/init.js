@@ -153,6 +153,70 @@ app.ports.copyToClipboard.subscribe(function(text) {
     document.body.removeChild(textArea);
 });
 
+// Elm only sees the top-level files of a drop, so folders are traversed
+// here and every file is passed on together with its relative path.
+function readDroppedEntry(entry, prefix, files) {
+    return new Promise(function(resolve) {
+        if(entry.isFile) {
+            entry.file(function(file) {
+                files.push({path: prefix + file.name, file: file});
+                resolve();
+            }, function(err) {
+                console.log("failed to read dropped file: " + err);
+                resolve();
+            });
+            return;
+        }
+
+        var reader = entry.createReader();
+        var children = [];
+        var readBatch = function() {
+            // readEntries() only returns a limited number of entries per call.
+            reader.readEntries(function(entries) {
+                if(entries.length == 0) {
+                    Promise.all(children.map(function(child) {
+                        return readDroppedEntry(child, prefix + entry.name + "/", files);
+                    })).then(resolve);
+                    return;
+                }
+
+                children = children.concat(Array.prototype.slice.call(entries));
+                readBatch();
+            }, function(err) {
+                console.log("failed to read dropped folder: " + err);
+                resolve();
+            });
+        };
+
+        readBatch();
+    });
+}
+
+if(app.ports.droppedFiles) {
+    window.addEventListener('dragover', function(ev) {
+        ev.preventDefault();
+    });
+
+    window.addEventListener('drop', function(ev) {
+        ev.preventDefault();
+
+        // The entries need to be fetched before the event handler returns.
+        var items = Array.prototype.slice.call(ev.dataTransfer.items || []);
+        var entries = items.map(function(item) {
+            return item.webkitGetAsEntry ? item.webkitGetAsEntry() : null;
+        }).filter(Boolean);
+
+        var files = [];
+        Promise.all(entries.map(function(entry) {
+            return readDroppedEntry(entry, "", files);
+        })).then(function() {
+            if(files.length > 0) {
+                app.ports.droppedFiles.send(files);
+            }
+        });
+    });
+}
+
 pingServer(app);
 
 window.addEventListener('scroll', scrolledOrResized);
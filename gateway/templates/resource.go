@@ -7,75 +7,76 @@ import "github.com/phogolabs/parcello"
 
 func init() {
 	parcello.AddResource([]byte{
-		80, 75, 3, 4, 20, 0, 8, 0, 8, 0, 139, 174, 120, 78, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 0, 9, 0, 105, 110, 100,
-		101, 120, 46, 104, 116, 109, 108, 85, 84, 5, 0, 1, 22, 252,
-		151, 92, 172, 149, 95, 111, 218, 60, 20, 198, 239, 251, 41,
-		188, 220, 178, 36, 64, 40, 4, 137, 84, 162, 12, 86, 2, 20,
-		202, 255, 114, 231, 196, 7, 98, 112, 236, 196, 54, 164, 233,
-		180, 239, 254, 10, 208, 180, 174, 131, 105, 175, 186, 171,
-		216, 143, 143, 159, 243, 115, 142, 117, 220, 248, 68, 68,
-		168, 243, 4, 80, 164, 99, 118, 119, 211, 56, 126, 16, 195,
-		124, 227, 25, 192, 141, 187, 27, 132, 16, 106, 68, 128, 201,
-		121, 120, 154, 106, 170, 25, 220, 13, 48, 229, 13, 251, 60,
-		190, 249, 185, 248, 201, 52, 209, 189, 16, 90, 105, 137, 19,
-		20, 131, 198, 200, 52, 223, 108, 62, 41, 97, 132, 165, 2,
-		237, 25, 123, 189, 54, 93, 227, 253, 50, 199, 49, 120, 198,
-		129, 66, 150, 8, 169, 13, 20, 10, 174, 129, 107, 207, 200,
-		40, 209, 145, 71, 224, 64, 67, 48, 79, 147, 207, 136, 114,
-		170, 41, 102, 166, 10, 49, 3, 175, 244, 25, 169, 72, 82, 190,
-		51, 181, 48, 215, 84, 123, 92, 24, 239, 241, 90, 147, 113,
-		7, 77, 196, 94, 134, 112, 129, 237, 156, 124, 35, 36, 101,
-		12, 91, 161, 146, 107, 107, 42, 118, 192, 223, 96, 124, 251,
-		118, 210, 79, 242, 247, 239, 191, 249, 47, 32, 80, 34, 220,
-		129, 70, 4, 52, 166, 76, 93, 205, 18, 72, 186, 177, 178, 31,
-		225, 22, 38, 68, 254, 154, 38, 83, 77, 66, 228, 133, 28, 15,
-		211, 233, 232, 239, 236, 35, 173, 147, 11, 206, 71, 249, 138,
-		247, 68, 231, 12, 144, 138, 0, 244, 59, 111, 70, 249, 14,
-		73, 96, 158, 161, 142, 49, 167, 16, 3, 69, 18, 214, 158, 97,
-		135, 74, 217, 193, 143, 202, 91, 49, 229, 86, 168, 148, 129,
-		40, 215, 176, 145, 84, 231, 158, 161, 34, 236, 184, 21, 115,
-		208, 202, 234, 174, 61, 233, 240, 175, 109, 119, 237, 79,
-		157, 175, 203, 172, 61, 228, 27, 53, 175, 173, 116, 185, 246,
-		184, 236, 8, 44, 154, 73, 252, 28, 187, 37, 186, 95, 138,
-		209, 174, 51, 244, 51, 223, 109, 143, 201, 142, 247, 71, 131,
-		161, 129, 66, 41, 148, 18, 146, 110, 40, 247, 12, 204, 5,
-		207, 99, 177, 87, 198, 255, 33, 93, 11, 174, 113, 6, 74, 196,
-		112, 141, 115, 246, 48, 214, 171, 126, 183, 144, 4, 47, 250,
-		161, 181, 72, 74, 186, 86, 187, 167, 165, 126, 101, 165, 105,
-		42, 211, 47, 110, 177, 199, 43, 43, 247, 113, 58, 25, 231,
-		131, 102, 185, 67, 28, 135, 223, 146, 39, 151, 45, 102, 237,
-		98, 81, 217, 255, 132, 147, 192, 26, 239, 153, 62, 49, 254,
-		86, 168, 80, 210, 228, 125, 141, 212, 73, 68, 74, 134, 158,
-		97, 111, 149, 189, 77, 247, 32, 115, 211, 177, 28, 171, 100,
-		41, 70, 227, 83, 105, 182, 23, 79, 156, 186, 212, 94, 22,
-		234, 213, 219, 47, 175, 195, 162, 156, 214, 112, 208, 171,
-		148, 252, 137, 126, 234, 54, 211, 249, 102, 60, 127, 77, 130,
-		87, 113, 171, 226, 101, 47, 169, 60, 175, 199, 135, 135, 130,
-		139, 3, 61, 109, 151, 70, 180, 186, 165, 175, 226, 250, 137,
-		27, 246, 153, 235, 15, 160, 137, 72, 18, 144, 87, 233, 28,
-		115, 53, 24, 213, 228, 92, 56, 113, 55, 223, 205, 11, 229,
-		66, 221, 119, 102, 126, 165, 186, 189, 223, 21, 23, 125, 60,
-		107, 18, 94, 117, 235, 184, 149, 137, 52, 184, 247, 233, 132,
-		111, 155, 61, 155, 185, 139, 67, 107, 49, 234, 142, 226, 74,
-		253, 67, 116, 191, 222, 236, 203, 191, 175, 21, 173, 211,
-		244, 101, 191, 154, 181, 184, 63, 233, 57, 133, 193, 50, 30,
-		61, 118, 243, 118, 117, 21, 44, 162, 114, 119, 144, 182, 203,
-		149, 146, 124, 166, 169, 255, 146, 15, 232, 170, 58, 92, 216,
-		126, 188, 122, 186, 85, 58, 107, 207, 250, 211, 252, 67, 128,
-		56, 73, 142, 88, 127, 19, 170, 98, 33, 116, 164, 66, 41, 24,
-		187, 176, 167, 97, 255, 108, 248, 141, 64, 144, 252, 141,
-		21, 161, 7, 68, 137, 103, 0, 139, 141, 187, 105, 68, 21, 74,
-		240, 6, 144, 194, 132, 229, 136, 3, 16, 133, 124, 124, 192,
-		231, 155, 105, 161, 17, 3, 172, 0, 1, 199, 1, 3, 68, 53, 162,
-		28, 229, 98, 47, 81, 32, 69, 166, 64, 90, 13, 155, 208, 195,
-		31, 80, 143, 221, 253, 34, 226, 153, 171, 97, 159, 223, 173,
-		255, 2, 0, 0, 255, 255, 80, 75, 7, 8, 133, 202, 231, 38, 21,
-		3, 0, 0, 200, 6, 0, 0, 80, 75, 1, 2, 20, 3, 20, 0, 8, 0, 8,
-		0, 139, 174, 120, 78, 133, 202, 231, 38, 21, 3, 0, 0, 200,
-		6, 0, 0, 10, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0, 0, 164, 129, 0,
-		0, 0, 0, 105, 110, 100, 101, 120, 46, 104, 116, 109, 108,
-		85, 84, 5, 0, 1, 22, 252, 151, 92, 80, 75, 5, 6, 0, 0, 0,
-		0, 1, 0, 1, 0, 65, 0, 0, 0, 86, 3, 0, 0, 0, 0,
+		80, 75, 3, 4, 20, 0, 8, 0, 8, 0, 82, 150, 80, 93, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 0, 9, 0, 105, 110, 100, 101,
+		120, 46, 104, 116, 109, 108, 85, 84, 5, 0, 1, 252, 113, 210,
+		106, 172, 149, 95, 111, 226, 56, 20, 197, 223, 251, 41, 60,
+		121, 101, 146, 148, 134, 66, 42, 37, 149, 40, 3, 83, 2, 20,
+		202, 255, 242, 230, 196, 134, 24, 28, 59, 177, 47, 164, 233,
+		168, 223, 125, 5, 108, 119, 218, 110, 25, 117, 87, 245, 75,
+		156, 107, 231, 156, 159, 124, 110, 100, 239, 27, 145, 17,
+		20, 41, 69, 49, 36, 252, 250, 204, 219, 63, 16, 199, 98, 229,
+		27, 84, 24, 215, 103, 8, 33, 228, 197, 20, 147, 227, 116,
+		63, 60, 96, 192, 233, 117, 15, 51, 225, 217, 199, 249, 217,
+		239, 197, 111, 166, 137, 110, 164, 4, 13, 10, 167, 40, 161,
+		128, 145, 105, 190, 250, 248, 80, 137, 98, 172, 52, 5, 223,
+		216, 194, 210, 116, 255, 182, 217, 143, 227, 178, 192, 9,
+		245, 141, 29, 163, 121, 42, 21, 24, 40, 146, 2, 168, 0, 223,
+		200, 25, 129, 216, 39, 116, 199, 34, 106, 30, 94, 190, 35,
+		38, 24, 48, 204, 77, 29, 97, 78, 253, 242, 119, 164, 99, 197,
+		196, 198, 4, 105, 46, 25, 248, 66, 26, 239, 241, 26, 163,
+		97, 11, 141, 228, 86, 69, 244, 3, 182, 163, 249, 74, 42, 198,
+		57, 182, 34, 173, 150, 214, 88, 110, 168, 120, 133, 241, 235,
+		215, 161, 126, 40, 63, 63, 255, 75, 127, 70, 67, 45, 163,
+		13, 5, 68, 40, 96, 198, 245, 73, 151, 80, 177, 149, 149, 191,
+		108, 183, 48, 33, 234, 173, 77, 174, 235, 132, 168, 15, 60,
+		110, 199, 227, 193, 231, 228, 99, 128, 244, 3, 229, 125, 249,
+		132, 246, 8, 10, 78, 145, 142, 41, 133, 119, 232, 156, 137,
+		13, 82, 148, 251, 134, 222, 239, 57, 108, 49, 80, 172, 232,
+		114, 79, 139, 176, 214, 20, 144, 97, 71, 90, 219, 225, 75,
+		15, 88, 9, 19, 86, 164, 181, 129, 158, 159, 13, 196, 4, 208,
+		149, 98, 80, 248, 134, 142, 177, 227, 86, 204, 94, 35, 191,
+		114, 237, 81, 75, 252, 108, 186, 203, 96, 236, 252, 156, 231,
+		205, 190, 88, 233, 105, 109, 1, 23, 181, 187, 121, 75, 98,
+		89, 79, 147, 135, 196, 45, 179, 237, 92, 14, 54, 173, 126,
+		144, 7, 110, 115, 72, 54, 162, 59, 232, 245, 13, 20, 41, 169,
+		181, 84, 108, 197, 132, 111, 96, 33, 69, 145, 200, 173, 54,
+		254, 31, 247, 82, 10, 192, 57, 213, 50, 161, 127, 162, 158,
+		220, 14, 97, 209, 109, 151, 210, 240, 17, 110, 27, 179, 180,
+		12, 181, 218, 13, 43, 119, 43, 11, 96, 153, 202, 126, 184,
+		231, 29, 81, 89, 184, 119, 227, 209, 176, 232, 213, 47, 90,
+		196, 113, 196, 37, 185, 119, 249, 108, 210, 60, 63, 215, 246,
+		23, 83, 19, 186, 196, 91, 14, 255, 16, 191, 111, 201, 81,
+		164, 88, 250, 62, 77, 125, 40, 34, 173, 162, 55, 122, 107,
+		109, 175, 179, 45, 85, 133, 233, 88, 142, 85, 182, 52, 103,
+		201, 33, 196, 245, 201, 12, 51, 151, 217, 243, 210, 85, 245,
+		242, 199, 83, 255, 92, 141, 107, 56, 236, 84, 202, 193, 8,
+		238, 219, 245, 108, 186, 26, 78, 159, 210, 240, 73, 94, 234,
+		100, 222, 73, 43, 15, 203, 225, 238, 182, 228, 226, 16, 198,
+		205, 242, 128, 85, 215, 236, 73, 158, 62, 13, 207, 62, 82,
+		126, 10, 59, 149, 105, 74, 213, 31, 89, 29, 115, 209, 27,
+		212, 212, 84, 58, 73, 187, 216, 76, 75, 23, 165, 171, 192,
+		153, 4, 149, 234, 250, 102, 115, 62, 235, 226, 73, 157, 136,
+		170, 123, 133, 27, 185, 204, 194, 155, 128, 141, 196, 186,
+		222, 177, 185, 59, 219, 53, 102, 131, 246, 32, 169, 92, 125,
+		17, 235, 219, 255, 227, 244, 209, 54, 226, 101, 150, 61, 110,
+		23, 147, 134, 8, 70, 29, 167, 212, 155, 39, 131, 187, 118,
+		209, 172, 46, 194, 89, 124, 209, 238, 101, 205, 139, 74, 89,
+		61, 176, 44, 120, 44, 122, 108, 81, 237, 207, 236, 32, 89,
+		220, 95, 106, 200, 155, 147, 238, 184, 248, 34, 92, 156, 166,
+		47, 144, 255, 45, 19, 157, 72, 9, 177, 142, 148, 228, 252,
+		132, 130, 103, 255, 190, 96, 188, 80, 146, 226, 85, 216, 132,
+		237, 16, 35, 190, 65, 121, 98, 92, 143, 99, 166, 81, 138,
+		87, 20, 105, 76, 120, 129, 4, 165, 68, 163, 0, 239, 240, 177,
+		191, 45, 52, 224, 20, 107, 138, 168, 192, 33, 167, 136, 1,
+		98, 2, 21, 114, 171, 80, 168, 100, 174, 169, 178, 60, 155,
+		176, 221, 167, 154, 105, 127, 175, 156, 4, 62, 82, 122, 118,
+		12, 9, 191, 62, 251, 107, 0, 80, 75, 7, 8, 22, 104, 57, 20,
+		37, 3, 0, 0, 70, 7, 0, 0, 80, 75, 1, 2, 20, 3, 20, 0, 8, 0,
+		8, 0, 82, 150, 80, 93, 22, 104, 57, 20, 37, 3, 0, 0, 70, 7,
+		0, 0, 10, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0, 0, 180, 129, 0, 0,
+		0, 0, 105, 110, 100, 101, 120, 46, 104, 116, 109, 108, 85,
+		84, 5, 0, 1, 252, 113, 210, 106, 80, 75, 5, 6, 0, 0, 0, 0,
+		1, 0, 1, 0, 65, 0, 0, 0, 102, 3, 0, 0, 0, 0,
 	})
 }
@@ -26,6 +26,8 @@ require (
 	github.com/gorilla/websocket v1.4.0
 	github.com/ipfs/go-ipfs-util v0.0.1
 	github.com/kardianos/osext v0.0.0-20170510131534-ae77be60afb1 // indirect
+	github.com/klauspost/compress v1.15.9
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/magefile/mage v1.8.0
@@ -58,10 +60,11 @@ require (
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/net v0.0.0-20190301231341-16b79f2e4e95
 	golang.org/x/sync v0.0.0-20190227155943-e225da77a7e6 // indirect
-	golang.org/x/sys v0.0.0-20190309122539-980fc434d28e // indirect
+	golang.org/x/sys v0.0.0-20190309122539-980fc434d28e
 	golang.org/x/text v0.3.1-0.20180807135948-17ff2d5776d2
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v2 v2.2.2
+	lukechampine.com/blake3 v1.1.7
 	zombiezen.com/go/capnproto2 v2.17.0+incompatible
 )
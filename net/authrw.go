@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
 	"crypto/hmac"
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/sahib/brig/catfs/mio/compress"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/util"
+	"github.com/sahib/brig/util/sshkey"
 
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/sha3"
@@ -24,6 +29,20 @@ const (
 	// The limit is arbitrary and should avoid being spammed by huge messages.
 	// (Later on we could also implement a proper streaming protocol)
 	MaxMessageSize = 16 * 1024 * 1024
+
+	// capSubkeys is advertised by peers that exchange session subkeys.
+	capSubkeys = "subkeys"
+
+	// capSignature is advertised by peers that can authenticate by signing.
+	// This is needed for SSH identities, which cannot decrypt a challenge.
+	capSignature = "signature"
+
+	// signatureAuthContext is prepended to everything signed in the
+	// handshake, so the signature can not be used for anything else.
+	signatureAuthContext = "brig-auth-v1\x00"
+
+	// capSeparator separates the name from the capabilities.
+	capSeparator = "\x00"
 )
 
 // PrivDecrypter is anything that can decrypt a message
@@ -32,6 +51,23 @@ type PrivDecrypter interface {
 	Decrypt(data []byte) ([]byte, error)
 }
 
+// SessionKeyProvider can be implemented by a PrivDecrypter that also has
+// a session subkey. Its certificate is send to the remote, which will then
+// encrypt its challenge with the subkey instead of the identity key.
+type SessionKeyProvider interface {
+	// SessionSubkeyCert returns the certificate of the current session
+	// subkey or nil if there is none.
+	SessionSubkeyCert() ([]byte, error)
+}
+
+// IdentitySigner can be implemented by a PrivDecrypter that can also sign
+// with the identity key. Only then the signature based handshake is offered.
+type IdentitySigner interface {
+	// SignWithIdentity signs `data`, so that it can be checked with
+	// repo.VerifyIdentitySignature and our public key.
+	SignWithIdentity(data []byte) ([]byte, error)
+}
+
 // RemoteChecker is a function that is called once the public key
 // of the remote has been received. If an error is returned,
 // the authentication will fail. Use this to check the remote's public key
@@ -46,11 +82,18 @@ type RemoteChecker func(remotePubKey []byte) error
 //    are exchanged. The received public key is hashed and checked to
 //    be the same as the fingerprint we're storing from this person.
 //    (This should suffice as authentication of the remote user)
+//    Along with the name, every side advertises its capabilities.
+//    If both sides support it, the certificate of a session subkey is
+//    exchanged afterwards (or an empty message if there is none).
+//    It has to be signed by the remote's public key and may not be expired.
+//    Peers that do not advertise capabilities (older versions of brig)
+//    skip this step.
 //
 // 2) A random nonce of 62 bytes is generated and encrypted with the
-//    remote's public key. The resulting ciphertext is then send to the
-//    remote. On their side they decrypt the ciphertext (proving that
-//    they possess the respective private key).
+//    remote's session subkey (or their public key, if there is none).
+//    The resulting ciphertext is then send to the remote. On their side
+//    they decrypt the ciphertext (proving that they possess the respective
+//    private key).
 //
 // 3) The resulting nonce from the remote is then hashed with sha3
 //    and send back. Each sides check if the response matched the challenge.
@@ -58,6 +101,11 @@ type RemoteChecker func(remotePubKey []byte) error
 //    generate a symmetric key (using scrypt) which is then used to encrypt
 //    further communication and to authenticate messages.
 //
+//    If one of the identity keys is an SSH key (which can only sign),
+//    steps 2 and 3 are replaced: Both sides send an ephemeral X25519 key
+//    and a nonce, then sign their own and the remote's message with their
+//    identity key. The symmetric key is derived from the shared secret.
+//
 // 4) Further communication writes messages with a hmac, a 4 byte size header
 //    and the actual payload.
 type AuthReadWriter struct {
@@ -93,6 +141,9 @@ type AuthReadWriter struct {
 
 	// buffer to implement io.Reader's streaming properties
 	readBuf *bytes.Buffer
+
+	// capabilities we advertise to the remote
+	caps []string
 }
 
 // NewAuthReadWriter returns a new AuthReadWriter, adding an auth layer on top
@@ -107,6 +158,11 @@ func NewAuthReadWriter(
 	ownName string,
 	remoteChecker RemoteChecker,
 ) *AuthReadWriter {
+	caps := []string{capSubkeys}
+	if _, ok := privKey.(IdentitySigner); ok {
+		caps = append(caps, capSignature)
+	}
+
 	return &AuthReadWriter{
 		rwc:           rwc,
 		privKey:       privKey,
@@ -114,6 +170,7 @@ func NewAuthReadWriter(
 		ownName:       ownName,
 		readBuf:       &bytes.Buffer{},
 		remoteChecker: remoteChecker,
+		caps:          caps,
 	}
 }
 
@@ -222,9 +279,88 @@ func wrapEncryptedRW(iv, key []byte, rw io.ReadWriter) (io.ReadWriter, error) {
 	}, nil
 }
 
+// encodeName appends the capabilities to the name we advertise.
+// Older versions of brig do not know about capabilities and treat the
+// whole thing as (display-only) name, so this stays compatible.
+func encodeName(name string, caps []string) []byte {
+	if len(caps) == 0 {
+		return []byte(name)
+	}
+
+	return []byte(name + capSeparator + strings.Join(caps, ","))
+}
+
+// decodeName splits a name produced by encodeName.
+func decodeName(data []byte) (string, map[string]bool) {
+	caps := make(map[string]bool)
+	split := strings.SplitN(string(data), capSeparator, 2)
+	if len(split) < 2 {
+		return split[0], caps
+	}
+
+	for _, capability := range strings.Split(split[1], ",") {
+		caps[capability] = true
+	}
+
+	return split[0], caps
+}
+
+func (ath *AuthReadWriter) hasCap(capability string) bool {
+	for _, own := range ath.caps {
+		if own == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exchangeSubkeyCerts sends the certificate of our session subkey (or an
+// empty message if there is none) and reads the one of the remote.
+// It returns the key the remote's challenge should be encrypted with.
+func (ath *AuthReadWriter) exchangeSubkeyCerts() ([]byte, error) {
+	var ownSubkeyCert []byte
+	if provider, ok := ath.privKey.(SessionKeyProvider); ok {
+		cert, err := provider.SessionSubkeyCert()
+		if err != nil {
+			return nil, err
+		}
+
+		ownSubkeyCert = cert
+	}
+
+	if _, err := writeSizePack(ath.rwc, ownSubkeyCert); err != nil {
+		return nil, err
+	}
+
+	remoteSubkeyCert, err := readSizePack(ath.rwc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(remoteSubkeyCert) == 0 {
+		return ath.remotePubKey, nil
+	}
+
+	// If the remote offers a session subkey, it has to be certified by
+	// the public key we checked already. Otherwise it could be anyone's key.
+	subkey, err := repo.VerifySubkeyCert(
+		ath.remotePubKey,
+		remoteSubkeyCert,
+		repo.SubkeySession,
+		time.Now(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("remote session subkey is not valid: %v", err)
+	}
+
+	return subkey.PubKey, nil
+}
+
 // runAuth runs the protocol pointed out above.
 func (ath *AuthReadWriter) runAuth() error {
-	if _, err := writeSizePack(ath.rwc, []byte(ath.ownName)); err != nil {
+	if _, err := writeSizePack(ath.rwc, encodeName(ath.ownName, ath.caps)); err != nil {
 		return err
 	}
 
@@ -241,7 +377,8 @@ func (ath *AuthReadWriter) runAuth() error {
 		return err
 	}
 
-	ath.remoteName = string(remoteName)
+	var remoteCaps map[string]bool
+	ath.remoteName, remoteCaps = decodeName(remoteName)
 
 	// Read their pubkey:
 	remotePubKey, err := readSizePack(ath.rwc)
@@ -258,6 +395,25 @@ func (ath *AuthReadWriter) runAuth() error {
 
 	ath.remotePubKey = remotePubKey
 
+	// Both sides know both keys now, so they agree on the handshake:
+	if sshkey.IsPubKey(ath.ownPubKey) || sshkey.IsPubKey(remotePubKey) {
+		if !ath.hasCap(capSignature) || !remoteCaps[capSignature] {
+			return fmt.Errorf("ssh identities need a signature handshake, which is not supported by both sides")
+		}
+
+		return ath.runSignatureAuth()
+	}
+
+	// Both sides know now what the other one supports.
+	// Only exchange subkeys if both of us can handle them.
+	challengeKey := remotePubKey
+	if ath.hasCap(capSubkeys) && remoteCaps[capSubkeys] {
+		challengeKey, err = ath.exchangeSubkeyCerts()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Generate our own nonce:
 	rA := make([]byte, nonceSize)
 	if _, err := io.ReadFull(rand.Reader, rA); err != nil {
@@ -265,7 +421,7 @@ func (ath *AuthReadWriter) runAuth() error {
 	}
 
 	// Send our challenge encrypted with remote's public key.
-	chlForBob, err := encryptWithPubKey(rA, remotePubKey)
+	chlForBob, err := encryptWithPubKey(rA, challengeKey)
 	if err != nil {
 		return err
 	}
@@ -316,8 +472,97 @@ func (ath *AuthReadWriter) runAuth() error {
 		keySource[i] = nonceFromBob[i] ^ rA[i]
 	}
 
-	key := util.DeriveKey(keySource, keySource[:nonceSize/2], 32)
-	inv := util.DeriveKey(keySource, keySource[nonceSize/2:], aes.BlockSize)
+	return ath.setupEncryption(keySource)
+}
+
+// signatureTranscript returns what the sender of `signerHello` signs.
+func signatureTranscript(signerHello, otherHello []byte) []byte {
+	transcript := append([]byte(signatureAuthContext), signerHello...)
+	return append(transcript, otherHello...)
+}
+
+// runSignatureAuth authenticates both sides by signing with the identity key.
+// It is used instead of the challenge if one side has an SSH identity.
+func (ath *AuthReadWriter) runSignatureAuth() error {
+	signer, ok := ath.privKey.(IdentitySigner)
+	if !ok {
+		return fmt.Errorf("own key cannot sign")
+	}
+
+	ephKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	ownHello := append(ephKey.PublicKey().Bytes(), nonce...)
+	if _, err := writeSizePack(ath.rwc, ownHello); err != nil {
+		return err
+	}
+
+	remoteHello, err := readSizePack(ath.rwc)
+	if err != nil {
+		return err
+	}
+
+	if len(remoteHello) != len(ownHello) {
+		return fmt.Errorf("Bad handshake size from partner: %d", len(remoteHello))
+	}
+
+	ephLen := len(ownHello) - nonceSize
+	remoteEphKey, err := ecdh.X25519().NewPublicKey(remoteHello[:ephLen])
+	if err != nil {
+		return err
+	}
+
+	// The remote's nonce is fresh, so our signature is only
+	// valid for this very session and can not be replayed.
+	sig, err := signer.SignWithIdentity(signatureTranscript(ownHello, remoteHello))
+	if err != nil {
+		return err
+	}
+
+	if _, err := writeSizePack(ath.rwc, sig); err != nil {
+		return err
+	}
+
+	sigFromBob, err := readSizePack(ath.rwc)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.VerifyIdentitySignature(
+		ath.remotePubKey,
+		signatureTranscript(remoteHello, ownHello),
+		sigFromBob,
+	); err != nil {
+		return fmt.Errorf("Bad signature; might communicate with imposter")
+	}
+
+	shared, err := ephKey.ECDH(remoteEphKey)
+	if err != nil {
+		return err
+	}
+
+	keySource := make([]byte, nonceSize)
+	for i := range keySource {
+		keySource[i] = nonce[i] ^ remoteHello[ephLen+i]
+	}
+
+	sum := sha3.Sum512(append(shared, keySource...))
+	return ath.setupEncryption(sum[:])
+}
+
+// setupEncryption derives the symmetric key from `keySource`,
+// which both sides have to agree on, and starts encrypting.
+func (ath *AuthReadWriter) setupEncryption(keySource []byte) error {
+	half := len(keySource) / 2
+	key := util.DeriveKey(keySource, keySource[:half], 32)
+	inv := util.DeriveKey(keySource, keySource[half:], aes.BlockSize)
 
 	rw, err := wrapEncryptedRW(inv, key, ath.rwc)
 	if err != nil {
@@ -2,6 +2,8 @@ package net
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,6 +16,8 @@ import (
 
 	"github.com/alokmenghrajani/gpgeez"
 	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/sshkey"
 	"github.com/sahib/brig/util/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -187,3 +191,211 @@ func TestAuthProcess(t *testing.T) {
 		})
 	}
 }
+
+// subkeyPrivKey offers a session subkey and can decrypt with both keys.
+type subkeyPrivKey struct {
+	identity   DummyPrivKey
+	subkey     DummyPrivKey
+	cert       []byte
+	usedSubkey bool
+}
+
+func newSubkeyPrivKey(t *testing.T, privIdentity []byte) *subkeyPrivKey {
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(privIdentity))
+	require.Nil(t, err)
+
+	privSub, pubSub := createKeyPair(t, 1024)
+	now := time.Now()
+	cert, err := repo.CertifySubkey(ents[0], &repo.Subkey{
+		ID:      "sub",
+		Usage:   repo.SubkeySession,
+		Created: now,
+		Expires: now.Add(time.Hour),
+		PubKey:  pubSub,
+	})
+
+	require.Nil(t, err)
+	return &subkeyPrivKey{
+		identity: DummyPrivKey(privIdentity),
+		subkey:   DummyPrivKey(privSub),
+		cert:     cert,
+	}
+}
+
+func (pk *subkeyPrivKey) Decrypt(data []byte) ([]byte, error) {
+	if plain, err := pk.subkey.Decrypt(data); err == nil {
+		pk.usedSubkey = true
+		return plain, nil
+	}
+
+	return pk.identity.Decrypt(data)
+}
+
+func (pk *subkeyPrivKey) SessionSubkeyCert() ([]byte, error) {
+	return pk.cert, nil
+}
+
+func testSubkeyHandshake(t *testing.T, bobIsOld bool) {
+	privAli, pubAli := createKeyPair(t, 1024)
+	privBob, pubBob := createKeyPair(t, 1024)
+	aliKey := newSubkeyPrivKey(t, privAli)
+
+	withLoopbackConnection(t, func(a, b net.Conn) {
+		authAli := NewAuthReadWriter(a, aliKey, pubAli, "ali", func(pubKey []byte) error {
+			if !bytes.Equal(pubKey, pubBob) {
+				return fmt.Errorf("bob has wrong public key")
+			}
+
+			return nil
+		})
+
+		authBob := NewAuthReadWriter(b, DummyPrivKey(privBob), pubBob, "bob", func(pubKey []byte) error {
+			if !bytes.Equal(pubKey, pubAli) {
+				return fmt.Errorf("alice has wrong public key")
+			}
+
+			return nil
+		})
+
+		if bobIsOld {
+			// Versions without capabilities do not advertise any:
+			authBob.caps = nil
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- authAli.Trigger()
+		}()
+
+		require.Nil(t, authBob.Trigger())
+		require.Nil(t, <-errCh)
+
+		require.Equal(t, "ali", authBob.RemoteName())
+		require.Equal(t, "bob", authAli.RemoteName())
+		require.Equal(t, !bobIsOld, aliKey.usedSubkey)
+
+		go func() {
+			_, err := authAli.Write([]byte("hello"))
+			errCh <- err
+		}()
+
+		buf := make([]byte, 5)
+		_, err := io.ReadFull(authBob, buf)
+		require.Nil(t, err)
+		require.Nil(t, <-errCh)
+		require.Equal(t, []byte("hello"), buf)
+	})
+}
+
+func TestAuthSubkeyHandshake(t *testing.T) {
+	testSubkeyHandshake(t, false)
+}
+
+func TestAuthSubkeyHandshakeOldPeer(t *testing.T) {
+	testSubkeyHandshake(t, true)
+}
+
+// sshPrivKey is an SSH identity; it can only sign.
+type sshPrivKey struct {
+	signer sshkey.Signer
+}
+
+func newSSHPrivKey(t *testing.T) *sshPrivKey {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	signer, err := sshkey.ParsePrivateKey(sshkey.MarshalPrivateKey(priv, "test"))
+	require.Nil(t, err)
+	return &sshPrivKey{signer: signer}
+}
+
+func (pk *sshPrivKey) Decrypt(data []byte) ([]byte, error) {
+	return nil, repo.ErrIdentityCannotDecrypt
+}
+
+func (pk *sshPrivKey) SignWithIdentity(data []byte) ([]byte, error) {
+	return pk.signer.Sign(data)
+}
+
+// gpgSignPrivKey is a gpg identity that can also sign.
+type gpgSignPrivKey struct {
+	DummyPrivKey
+}
+
+func (pk gpgSignPrivKey) SignWithIdentity(data []byte) ([]byte, error) {
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(pk.DummyPrivKey))
+	if err != nil {
+		return nil, err
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, ents[0], bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return sigBuf.Bytes(), nil
+}
+
+func testSignatureHandshake(t *testing.T, bobKey PrivDecrypter, pubBob []byte) error {
+	aliKey := newSSHPrivKey(t)
+	pubAli := aliKey.signer.PubKey()
+
+	var bobErr error
+	withLoopbackConnection(t, func(a, b net.Conn) {
+		authAli := NewAuthReadWriter(a, aliKey, pubAli, "ali", func(pubKey []byte) error {
+			if !bytes.Equal(pubKey, pubBob) {
+				return fmt.Errorf("bob has wrong public key")
+			}
+
+			return nil
+		})
+
+		authBob := NewAuthReadWriter(b, bobKey, pubBob, "bob", func(pubKey []byte) error {
+			if !bytes.Equal(pubKey, pubAli) {
+				return fmt.Errorf("alice has wrong public key")
+			}
+
+			return nil
+		})
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- authAli.Trigger()
+		}()
+
+		bobErr = authBob.Trigger()
+		<-errCh
+		if bobErr != nil {
+			return
+		}
+
+		go func() {
+			_, err := authAli.Write([]byte("hello"))
+			errCh <- err
+		}()
+
+		buf := make([]byte, 5)
+		_, err := io.ReadFull(authBob, buf)
+		require.Nil(t, err)
+		require.Nil(t, <-errCh)
+		require.Equal(t, []byte("hello"), buf)
+	})
+
+	return bobErr
+}
+
+func TestAuthSignatureHandshake(t *testing.T) {
+	bobKey := newSSHPrivKey(t)
+	require.Nil(t, testSignatureHandshake(t, bobKey, bobKey.signer.PubKey()))
+}
+
+func TestAuthSignatureHandshakeGPGPeer(t *testing.T) {
+	privBob, pubBob := createKeyPair(t, 1024)
+	require.Nil(t, testSignatureHandshake(t, gpgSignPrivKey{DummyPrivKey(privBob)}, pubBob))
+}
+
+func TestAuthSignatureHandshakeUnsupported(t *testing.T) {
+	// Bob cannot sign, so he cannot talk to an SSH identity:
+	privBob, pubBob := createKeyPair(t, 1024)
+	require.NotNil(t, testSignatureHandshake(t, DummyPrivKey(privBob), pubBob))
+}
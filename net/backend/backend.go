@@ -62,3 +62,15 @@ type Backend interface {
 	// or receive connections from other peers.
 	IsOnline() bool
 }
+
+// HeadAdvertiser is implemented by backends that can publish a small
+// record under the key of the node, so that others can fetch it even
+// when the node itself is offline (e.g. via IPNS). It is optional;
+// use a type assertion to check for it.
+type HeadAdvertiser interface {
+	// PublishHead replaces the record published under our own key.
+	PublishHead(data []byte) error
+
+	// ResolveHead fetches the record that the node with `peerAddr` published.
+	ResolveHead(ctx context.Context, peerAddr string) ([]byte, error)
+}
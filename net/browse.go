@@ -0,0 +1,133 @@
+package net
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/repo"
+)
+
+// BrowseEntry is a single node of our tree as a remote may see it
+// before it syncs with us.
+type BrowseEntry struct {
+	Path        string    `json:"path"`
+	Size        uint64    `json:"size"`
+	IsDir       bool      `json:"is_dir"`
+	ModTime     time.Time `json:"mod_time"`
+	ContentHash []byte    `json:"content_hash,omitempty"`
+
+	// Versions is the number of commits that modified a file.
+	Versions int `json:"versions,omitempty"`
+
+	// Partial is true for directories that are only visible because
+	// a shared folder lies below them. Their size is not shown then.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// isSharedPath checks if `nodePath` is one of `folders` or lies below one.
+func isSharedPath(nodePath string, folders []string) bool {
+	for _, folder := range folders {
+		if folder == "/" || nodePath == folder || strings.HasPrefix(nodePath, folder+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leadsToShared checks if a shared folder lies below the directory `dirPath`.
+func leadsToShared(dirPath string, folders []string) bool {
+	prefix := strings.TrimSuffix(dirPath, "/") + "/"
+	for _, folder := range folders {
+		if strings.HasPrefix(folder, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func statToBrowseEntry(fs *catfs.FS, info *catfs.StatInfo) (BrowseEntry, error) {
+	entry := BrowseEntry{
+		Path:        info.Path,
+		Size:        info.Size,
+		IsDir:       info.IsDir,
+		ModTime:     info.ModTime,
+		ContentHash: info.ContentHash.Bytes(),
+	}
+
+	if !info.IsDir {
+		history, err := fs.History(info.Path)
+		if err != nil {
+			return entry, err
+		}
+
+		entry.Versions = len(history)
+	}
+
+	return entry, nil
+}
+
+// browse lists the direct children of `root` in `fs`, as far as they
+// are shared via `folders`. An empty folder list shares everything.
+func browse(fs *catfs.FS, root string, folders []repo.Folder) ([]BrowseEntry, error) {
+	root = path.Clean("/" + root)
+
+	shared := []string{}
+	if !completeExportAllowed(folders) {
+		for _, folder := range folders {
+			shared = append(shared, path.Clean("/"+folder.Folder))
+		}
+	} else {
+		shared = append(shared, "/")
+	}
+
+	// Do not tell if a path exists when it is not shared.
+	if !isSharedPath(root, shared) && !leadsToShared(root, shared) {
+		return nil, fmt.Errorf("»%s« is not shared with you", root)
+	}
+
+	rootInfo, err := fs.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rootInfo.IsDir {
+		entry, err := statToBrowseEntry(fs, rootInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		return []BrowseEntry{entry}, nil
+	}
+
+	infos, err := fs.List(root, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []BrowseEntry{}
+	for _, info := range infos {
+		switch {
+		case isSharedPath(info.Path, shared):
+			entry, err := statToBrowseEntry(fs, info)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+		case info.IsDir && leadsToShared(info.Path, shared):
+			entries = append(entries, BrowseEntry{
+				Path:    info.Path,
+				IsDir:   true,
+				ModTime: info.ModTime,
+				Partial: true,
+			})
+		}
+	}
+
+	return entries, nil
+}
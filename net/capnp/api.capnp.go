@@ -115,6 +115,126 @@ func (c Sync) Push(ctx context.Context, params func(Sync_push_Params) error, opt
 	}
 	return Sync_push_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
 }
+func (c Sync) FetchBlock(ctx context.Context, params func(Sync_fetchBlock_Params) error, opts ...capnp.CallOption) Sync_fetchBlock_Results_Promise {
+	if c.Client == nil {
+		return Sync_fetchBlock_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      5,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "fetchBlock",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_fetchBlock_Params{Struct: s}) }
+	}
+	return Sync_fetchBlock_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Sync) Gossip(ctx context.Context, params func(Sync_gossip_Params) error, opts ...capnp.CallOption) Sync_gossip_Results_Promise {
+	if c.Client == nil {
+		return Sync_gossip_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      6,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "gossip",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_gossip_Params{Struct: s}) }
+	}
+	return Sync_gossip_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Sync) MigrateIdentity(ctx context.Context, params func(Sync_migrateIdentity_Params) error, opts ...capnp.CallOption) Sync_migrateIdentity_Results_Promise {
+	if c.Client == nil {
+		return Sync_migrateIdentity_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      7,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "migrateIdentity",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_migrateIdentity_Params{Struct: s}) }
+	}
+	return Sync_migrateIdentity_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Sync) Browse(ctx context.Context, params func(Sync_browse_Params) error, opts ...capnp.CallOption) Sync_browse_Results_Promise {
+	if c.Client == nil {
+		return Sync_browse_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      8,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "browse",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_browse_Params{Struct: s}) }
+	}
+	return Sync_browse_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Sync) Replicate(ctx context.Context, params func(Sync_replicate_Params) error, opts ...capnp.CallOption) Sync_replicate_Results_Promise {
+	if c.Client == nil {
+		return Sync_replicate_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      9,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "replicate",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 8, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_replicate_Params{Struct: s}) }
+	}
+	return Sync_replicate_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Sync) PresentDelegations(ctx context.Context, params func(Sync_presentDelegations_Params) error, opts ...capnp.CallOption) Sync_presentDelegations_Results_Promise {
+	if c.Client == nil {
+		return Sync_presentDelegations_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      10,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "presentDelegations",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_presentDelegations_Params{Struct: s}) }
+	}
+	return Sync_presentDelegations_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
 
 type Sync_Server interface {
 	FetchStore(Sync_fetchStore) error
@@ -126,6 +246,18 @@ type Sync_Server interface {
 	IsPushAllowed(Sync_isPushAllowed) error
 
 	Push(Sync_push) error
+
+	FetchBlock(Sync_fetchBlock) error
+
+	Gossip(Sync_gossip) error
+
+	MigrateIdentity(Sync_migrateIdentity) error
+
+	Browse(Sync_browse) error
+
+	Replicate(Sync_replicate) error
+
+	PresentDelegations(Sync_presentDelegations) error
 }
 
 func Sync_ServerToClient(s Sync_Server) Sync {
@@ -135,7 +267,7 @@ func Sync_ServerToClient(s Sync_Server) Sync {
 
 func Sync_Methods(methods []server.Method, s Sync_Server) []server.Method {
 	if cap(methods) == 0 {
-		methods = make([]server.Method, 0, 5)
+		methods = make([]server.Method, 0, 11)
 	}
 
 	methods = append(methods, server.Method{
@@ -208,6 +340,90 @@ func Sync_Methods(methods []server.Method, s Sync_Server) []server.Method {
 		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 0},
 	})
 
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      5,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "fetchBlock",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_fetchBlock{c, opts, Sync_fetchBlock_Params{Struct: p}, Sync_fetchBlock_Results{Struct: r}}
+			return s.FetchBlock(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      6,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "gossip",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_gossip{c, opts, Sync_gossip_Params{Struct: p}, Sync_gossip_Results{Struct: r}}
+			return s.Gossip(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      7,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "migrateIdentity",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_migrateIdentity{c, opts, Sync_migrateIdentity_Params{Struct: p}, Sync_migrateIdentity_Results{Struct: r}}
+			return s.MigrateIdentity(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 0},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      8,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "browse",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_browse{c, opts, Sync_browse_Params{Struct: p}, Sync_browse_Results{Struct: r}}
+			return s.Browse(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      9,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "replicate",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_replicate{c, opts, Sync_replicate_Params{Struct: p}, Sync_replicate_Results{Struct: r}}
+			return s.Replicate(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      10,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "presentDelegations",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_presentDelegations{c, opts, Sync_presentDelegations_Params{Struct: p}, Sync_presentDelegations_Results{Struct: r}}
+			return s.PresentDelegations(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
 	return methods
 }
 
@@ -251,6 +467,54 @@ type Sync_push struct {
 	Results Sync_push_Results
 }
 
+// Sync_fetchBlock holds the arguments for a server call to Sync.fetchBlock.
+type Sync_fetchBlock struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_fetchBlock_Params
+	Results Sync_fetchBlock_Results
+}
+
+// Sync_gossip holds the arguments for a server call to Sync.gossip.
+type Sync_gossip struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_gossip_Params
+	Results Sync_gossip_Results
+}
+
+// Sync_migrateIdentity holds the arguments for a server call to Sync.migrateIdentity.
+type Sync_migrateIdentity struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_migrateIdentity_Params
+	Results Sync_migrateIdentity_Results
+}
+
+// Sync_browse holds the arguments for a server call to Sync.browse.
+type Sync_browse struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_browse_Params
+	Results Sync_browse_Results
+}
+
+// Sync_replicate holds the arguments for a server call to Sync.replicate.
+type Sync_replicate struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_replicate_Params
+	Results Sync_replicate_Results
+}
+
+// Sync_presentDelegations holds the arguments for a server call to Sync.presentDelegations.
+type Sync_presentDelegations struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Sync_presentDelegations_Params
+	Results Sync_presentDelegations_Results
+}
+
 type Sync_fetchStore_Params struct{ capnp.Struct }
 
 // Sync_fetchStore_Params_TypeID is the unique identifier for the type Sync_fetchStore_Params.
@@ -851,108 +1115,979 @@ func (p Sync_push_Results_Promise) Struct() (Sync_push_Results, error) {
 	return Sync_push_Results{s}, err
 }
 
-type Meta struct{ Client capnp.Client }
+type Sync_fetchBlock_Params struct{ capnp.Struct }
 
-// Meta_TypeID is the unique identifier for the type Meta.
-const Meta_TypeID = 0xb02d2ba0578cc7ff
+// Sync_fetchBlock_Params_TypeID is the unique identifier for the type Sync_fetchBlock_Params.
+const Sync_fetchBlock_Params_TypeID = 0x85647b71cba016e2
 
-func (c Meta) Ping(ctx context.Context, params func(Meta_ping_Params) error, opts ...capnp.CallOption) Meta_ping_Results_Promise {
-	if c.Client == nil {
-		return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
-	}
-	call := &capnp.Call{
-		Ctx: ctx,
-		Method: capnp.Method{
-			InterfaceID:   0xb02d2ba0578cc7ff,
-			MethodID:      0,
-			InterfaceName: "net/capnp/api.capnp:Meta",
-			MethodName:    "ping",
-		},
-		Options: capnp.NewCallOptions(opts),
-	}
-	if params != nil {
-		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 0}
-		call.ParamsFunc = func(s capnp.Struct) error { return params(Meta_ping_Params{Struct: s}) }
-	}
-	return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+func NewSync_fetchBlock_Params(s *capnp.Segment) (Sync_fetchBlock_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_fetchBlock_Params{st}, err
 }
 
-type Meta_Server interface {
-	Ping(Meta_ping) error
+func NewRootSync_fetchBlock_Params(s *capnp.Segment) (Sync_fetchBlock_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_fetchBlock_Params{st}, err
 }
 
-func Meta_ServerToClient(s Meta_Server) Meta {
-	c, _ := s.(server.Closer)
-	return Meta{Client: server.New(Meta_Methods(nil, s), c)}
+func ReadRootSync_fetchBlock_Params(msg *capnp.Message) (Sync_fetchBlock_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_fetchBlock_Params{root.Struct()}, err
 }
 
-func Meta_Methods(methods []server.Method, s Meta_Server) []server.Method {
-	if cap(methods) == 0 {
-		methods = make([]server.Method, 0, 1)
-	}
-
-	methods = append(methods, server.Method{
-		Method: capnp.Method{
-			InterfaceID:   0xb02d2ba0578cc7ff,
-			MethodID:      0,
-			InterfaceName: "net/capnp/api.capnp:Meta",
-			MethodName:    "ping",
-		},
-		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
-			call := Meta_ping{c, opts, Meta_ping_Params{Struct: p}, Meta_ping_Results{Struct: r}}
-			return s.Ping(call)
-		},
-		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
-	})
+func (s Sync_fetchBlock_Params) String() string {
+	str, _ := text.Marshal(0x85647b71cba016e2, s.Struct)
+	return str
+}
 
-	return methods
+func (s Sync_fetchBlock_Params) Hash() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
 }
 
-// Meta_ping holds the arguments for a server call to Meta.ping.
-type Meta_ping struct {
-	Ctx     context.Context
-	Options capnp.CallOptions
-	Params  Meta_ping_Params
-	Results Meta_ping_Results
+func (s Sync_fetchBlock_Params) HasHash() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
 }
 
-type Meta_ping_Params struct{ capnp.Struct }
+func (s Sync_fetchBlock_Params) SetHash(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
 
-// Meta_ping_Params_TypeID is the unique identifier for the type Meta_ping_Params.
-const Meta_ping_Params_TypeID = 0xe1a9fd466eca248c
+// Sync_fetchBlock_Params_List is a list of Sync_fetchBlock_Params.
+type Sync_fetchBlock_Params_List struct{ capnp.List }
 
-func NewMeta_ping_Params(s *capnp.Segment) (Meta_ping_Params, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
-	return Meta_ping_Params{st}, err
+// NewSync_fetchBlock_Params creates a new list of Sync_fetchBlock_Params.
+func NewSync_fetchBlock_Params_List(s *capnp.Segment, sz int32) (Sync_fetchBlock_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_fetchBlock_Params_List{l}, err
 }
 
-func NewRootMeta_ping_Params(s *capnp.Segment) (Meta_ping_Params, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
-	return Meta_ping_Params{st}, err
+func (s Sync_fetchBlock_Params_List) At(i int) Sync_fetchBlock_Params {
+	return Sync_fetchBlock_Params{s.List.Struct(i)}
 }
 
-func ReadRootMeta_ping_Params(msg *capnp.Message) (Meta_ping_Params, error) {
-	root, err := msg.RootPtr()
-	return Meta_ping_Params{root.Struct()}, err
+func (s Sync_fetchBlock_Params_List) Set(i int, v Sync_fetchBlock_Params) error {
+	return s.List.SetStruct(i, v.Struct)
 }
 
-func (s Meta_ping_Params) String() string {
-	str, _ := text.Marshal(0xe1a9fd466eca248c, s.Struct)
+func (s Sync_fetchBlock_Params_List) String() string {
+	str, _ := text.MarshalList(0x85647b71cba016e2, s.List)
 	return str
 }
 
-// Meta_ping_Params_List is a list of Meta_ping_Params.
-type Meta_ping_Params_List struct{ capnp.List }
+// Sync_fetchBlock_Params_Promise is a wrapper for a Sync_fetchBlock_Params promised by a client call.
+type Sync_fetchBlock_Params_Promise struct{ *capnp.Pipeline }
 
-// NewMeta_ping_Params creates a new list of Meta_ping_Params.
-func NewMeta_ping_Params_List(s *capnp.Segment, sz int32) (Meta_ping_Params_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0}, sz)
-	return Meta_ping_Params_List{l}, err
+func (p Sync_fetchBlock_Params_Promise) Struct() (Sync_fetchBlock_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_fetchBlock_Params{s}, err
 }
 
-func (s Meta_ping_Params_List) At(i int) Meta_ping_Params { return Meta_ping_Params{s.List.Struct(i)} }
+type Sync_fetchBlock_Results struct{ capnp.Struct }
 
-func (s Meta_ping_Params_List) Set(i int, v Meta_ping_Params) error {
+// Sync_fetchBlock_Results_TypeID is the unique identifier for the type Sync_fetchBlock_Results.
+const Sync_fetchBlock_Results_TypeID = 0xf9248392457904d7
+
+func NewSync_fetchBlock_Results(s *capnp.Segment) (Sync_fetchBlock_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_fetchBlock_Results{st}, err
+}
+
+func NewRootSync_fetchBlock_Results(s *capnp.Segment) (Sync_fetchBlock_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_fetchBlock_Results{st}, err
+}
+
+func ReadRootSync_fetchBlock_Results(msg *capnp.Message) (Sync_fetchBlock_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_fetchBlock_Results{root.Struct()}, err
+}
+
+func (s Sync_fetchBlock_Results) String() string {
+	str, _ := text.Marshal(0xf9248392457904d7, s.Struct)
+	return str
+}
+
+func (s Sync_fetchBlock_Results) Data() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Sync_fetchBlock_Results) HasData() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_fetchBlock_Results) SetData(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Sync_fetchBlock_Results_List is a list of Sync_fetchBlock_Results.
+type Sync_fetchBlock_Results_List struct{ capnp.List }
+
+// NewSync_fetchBlock_Results creates a new list of Sync_fetchBlock_Results.
+func NewSync_fetchBlock_Results_List(s *capnp.Segment, sz int32) (Sync_fetchBlock_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_fetchBlock_Results_List{l}, err
+}
+
+func (s Sync_fetchBlock_Results_List) At(i int) Sync_fetchBlock_Results {
+	return Sync_fetchBlock_Results{s.List.Struct(i)}
+}
+
+func (s Sync_fetchBlock_Results_List) Set(i int, v Sync_fetchBlock_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_fetchBlock_Results_List) String() string {
+	str, _ := text.MarshalList(0xf9248392457904d7, s.List)
+	return str
+}
+
+// Sync_fetchBlock_Results_Promise is a wrapper for a Sync_fetchBlock_Results promised by a client call.
+type Sync_fetchBlock_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_fetchBlock_Results_Promise) Struct() (Sync_fetchBlock_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_fetchBlock_Results{s}, err
+}
+
+type Sync_gossip_Params struct{ capnp.Struct }
+
+// Sync_gossip_Params_TypeID is the unique identifier for the type Sync_gossip_Params.
+const Sync_gossip_Params_TypeID = 0x8ca34b7330c3e9ed
+
+func NewSync_gossip_Params(s *capnp.Segment) (Sync_gossip_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_gossip_Params{st}, err
+}
+
+func NewRootSync_gossip_Params(s *capnp.Segment) (Sync_gossip_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_gossip_Params{st}, err
+}
+
+func ReadRootSync_gossip_Params(msg *capnp.Message) (Sync_gossip_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_gossip_Params{root.Struct()}, err
+}
+
+func (s Sync_gossip_Params) String() string {
+	str, _ := text.Marshal(0x8ca34b7330c3e9ed, s.Struct)
+	return str
+}
+
+func (s Sync_gossip_Params) Entries() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Sync_gossip_Params) HasEntries() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_gossip_Params) SetEntries(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Sync_gossip_Params_List is a list of Sync_gossip_Params.
+type Sync_gossip_Params_List struct{ capnp.List }
+
+// NewSync_gossip_Params creates a new list of Sync_gossip_Params.
+func NewSync_gossip_Params_List(s *capnp.Segment, sz int32) (Sync_gossip_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_gossip_Params_List{l}, err
+}
+
+func (s Sync_gossip_Params_List) At(i int) Sync_gossip_Params {
+	return Sync_gossip_Params{s.List.Struct(i)}
+}
+
+func (s Sync_gossip_Params_List) Set(i int, v Sync_gossip_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_gossip_Params_List) String() string {
+	str, _ := text.MarshalList(0x8ca34b7330c3e9ed, s.List)
+	return str
+}
+
+// Sync_gossip_Params_Promise is a wrapper for a Sync_gossip_Params promised by a client call.
+type Sync_gossip_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_gossip_Params_Promise) Struct() (Sync_gossip_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_gossip_Params{s}, err
+}
+
+type Sync_gossip_Results struct{ capnp.Struct }
+
+// Sync_gossip_Results_TypeID is the unique identifier for the type Sync_gossip_Results.
+const Sync_gossip_Results_TypeID = 0xaa32afdfcc5507cc
+
+func NewSync_gossip_Results(s *capnp.Segment) (Sync_gossip_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_gossip_Results{st}, err
+}
+
+func NewRootSync_gossip_Results(s *capnp.Segment) (Sync_gossip_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_gossip_Results{st}, err
+}
+
+func ReadRootSync_gossip_Results(msg *capnp.Message) (Sync_gossip_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_gossip_Results{root.Struct()}, err
+}
+
+func (s Sync_gossip_Results) String() string {
+	str, _ := text.Marshal(0xaa32afdfcc5507cc, s.Struct)
+	return str
+}
+
+func (s Sync_gossip_Results) Entries() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Sync_gossip_Results) HasEntries() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_gossip_Results) SetEntries(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Sync_gossip_Results_List is a list of Sync_gossip_Results.
+type Sync_gossip_Results_List struct{ capnp.List }
+
+// NewSync_gossip_Results creates a new list of Sync_gossip_Results.
+func NewSync_gossip_Results_List(s *capnp.Segment, sz int32) (Sync_gossip_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_gossip_Results_List{l}, err
+}
+
+func (s Sync_gossip_Results_List) At(i int) Sync_gossip_Results {
+	return Sync_gossip_Results{s.List.Struct(i)}
+}
+
+func (s Sync_gossip_Results_List) Set(i int, v Sync_gossip_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_gossip_Results_List) String() string {
+	str, _ := text.MarshalList(0xaa32afdfcc5507cc, s.List)
+	return str
+}
+
+// Sync_gossip_Results_Promise is a wrapper for a Sync_gossip_Results promised by a client call.
+type Sync_gossip_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_gossip_Results_Promise) Struct() (Sync_gossip_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_gossip_Results{s}, err
+}
+
+type Sync_migrateIdentity_Params struct{ capnp.Struct }
+
+// Sync_migrateIdentity_Params_TypeID is the unique identifier for the type Sync_migrateIdentity_Params.
+const Sync_migrateIdentity_Params_TypeID = 0xa523dde9eb30e8b4
+
+func NewSync_migrateIdentity_Params(s *capnp.Segment) (Sync_migrateIdentity_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_migrateIdentity_Params{st}, err
+}
+
+func NewRootSync_migrateIdentity_Params(s *capnp.Segment) (Sync_migrateIdentity_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_migrateIdentity_Params{st}, err
+}
+
+func ReadRootSync_migrateIdentity_Params(msg *capnp.Message) (Sync_migrateIdentity_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_migrateIdentity_Params{root.Struct()}, err
+}
+
+func (s Sync_migrateIdentity_Params) String() string {
+	str, _ := text.Marshal(0xa523dde9eb30e8b4, s.Struct)
+	return str
+}
+
+func (s Sync_migrateIdentity_Params) Records() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Sync_migrateIdentity_Params) HasRecords() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_migrateIdentity_Params) SetRecords(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Sync_migrateIdentity_Params_List is a list of Sync_migrateIdentity_Params.
+type Sync_migrateIdentity_Params_List struct{ capnp.List }
+
+// NewSync_migrateIdentity_Params creates a new list of Sync_migrateIdentity_Params.
+func NewSync_migrateIdentity_Params_List(s *capnp.Segment, sz int32) (Sync_migrateIdentity_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_migrateIdentity_Params_List{l}, err
+}
+
+func (s Sync_migrateIdentity_Params_List) At(i int) Sync_migrateIdentity_Params {
+	return Sync_migrateIdentity_Params{s.List.Struct(i)}
+}
+
+func (s Sync_migrateIdentity_Params_List) Set(i int, v Sync_migrateIdentity_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_migrateIdentity_Params_List) String() string {
+	str, _ := text.MarshalList(0xa523dde9eb30e8b4, s.List)
+	return str
+}
+
+// Sync_migrateIdentity_Params_Promise is a wrapper for a Sync_migrateIdentity_Params promised by a client call.
+type Sync_migrateIdentity_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_migrateIdentity_Params_Promise) Struct() (Sync_migrateIdentity_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_migrateIdentity_Params{s}, err
+}
+
+type Sync_migrateIdentity_Results struct{ capnp.Struct }
+
+// Sync_migrateIdentity_Results_TypeID is the unique identifier for the type Sync_migrateIdentity_Results.
+const Sync_migrateIdentity_Results_TypeID = 0xfe15393095732772
+
+func NewSync_migrateIdentity_Results(s *capnp.Segment) (Sync_migrateIdentity_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Sync_migrateIdentity_Results{st}, err
+}
+
+func NewRootSync_migrateIdentity_Results(s *capnp.Segment) (Sync_migrateIdentity_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Sync_migrateIdentity_Results{st}, err
+}
+
+func ReadRootSync_migrateIdentity_Results(msg *capnp.Message) (Sync_migrateIdentity_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_migrateIdentity_Results{root.Struct()}, err
+}
+
+func (s Sync_migrateIdentity_Results) String() string {
+	str, _ := text.Marshal(0xfe15393095732772, s.Struct)
+	return str
+}
+
+// Sync_migrateIdentity_Results_List is a list of Sync_migrateIdentity_Results.
+type Sync_migrateIdentity_Results_List struct{ capnp.List }
+
+// NewSync_migrateIdentity_Results creates a new list of Sync_migrateIdentity_Results.
+func NewSync_migrateIdentity_Results_List(s *capnp.Segment, sz int32) (Sync_migrateIdentity_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0}, sz)
+	return Sync_migrateIdentity_Results_List{l}, err
+}
+
+func (s Sync_migrateIdentity_Results_List) At(i int) Sync_migrateIdentity_Results {
+	return Sync_migrateIdentity_Results{s.List.Struct(i)}
+}
+
+func (s Sync_migrateIdentity_Results_List) Set(i int, v Sync_migrateIdentity_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_migrateIdentity_Results_List) String() string {
+	str, _ := text.MarshalList(0xfe15393095732772, s.List)
+	return str
+}
+
+// Sync_migrateIdentity_Results_Promise is a wrapper for a Sync_migrateIdentity_Results promised by a client call.
+type Sync_migrateIdentity_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_migrateIdentity_Results_Promise) Struct() (Sync_migrateIdentity_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_migrateIdentity_Results{s}, err
+}
+
+type Sync_browse_Params struct{ capnp.Struct }
+
+// Sync_browse_Params_TypeID is the unique identifier for the type Sync_browse_Params.
+const Sync_browse_Params_TypeID = 0xe0407c71e6f699e4
+
+func NewSync_browse_Params(s *capnp.Segment) (Sync_browse_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_browse_Params{st}, err
+}
+
+func NewRootSync_browse_Params(s *capnp.Segment) (Sync_browse_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_browse_Params{st}, err
+}
+
+func ReadRootSync_browse_Params(msg *capnp.Message) (Sync_browse_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_browse_Params{root.Struct()}, err
+}
+
+func (s Sync_browse_Params) String() string {
+	str, _ := text.Marshal(0xe0407c71e6f699e4, s.Struct)
+	return str
+}
+
+func (s Sync_browse_Params) Path() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s Sync_browse_Params) HasPath() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_browse_Params) PathBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Sync_browse_Params) SetPath(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+// Sync_browse_Params_List is a list of Sync_browse_Params.
+type Sync_browse_Params_List struct{ capnp.List }
+
+// NewSync_browse_Params creates a new list of Sync_browse_Params.
+func NewSync_browse_Params_List(s *capnp.Segment, sz int32) (Sync_browse_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_browse_Params_List{l}, err
+}
+
+func (s Sync_browse_Params_List) At(i int) Sync_browse_Params {
+	return Sync_browse_Params{s.List.Struct(i)}
+}
+
+func (s Sync_browse_Params_List) Set(i int, v Sync_browse_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_browse_Params_List) String() string {
+	str, _ := text.MarshalList(0xe0407c71e6f699e4, s.List)
+	return str
+}
+
+// Sync_browse_Params_Promise is a wrapper for a Sync_browse_Params promised by a client call.
+type Sync_browse_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_browse_Params_Promise) Struct() (Sync_browse_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_browse_Params{s}, err
+}
+
+type Sync_browse_Results struct{ capnp.Struct }
+
+// Sync_browse_Results_TypeID is the unique identifier for the type Sync_browse_Results.
+const Sync_browse_Results_TypeID = 0x9111634089ee1c4f
+
+func NewSync_browse_Results(s *capnp.Segment) (Sync_browse_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_browse_Results{st}, err
+}
+
+func NewRootSync_browse_Results(s *capnp.Segment) (Sync_browse_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_browse_Results{st}, err
+}
+
+func ReadRootSync_browse_Results(msg *capnp.Message) (Sync_browse_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_browse_Results{root.Struct()}, err
+}
+
+func (s Sync_browse_Results) String() string {
+	str, _ := text.Marshal(0x9111634089ee1c4f, s.Struct)
+	return str
+}
+
+func (s Sync_browse_Results) Entries() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Sync_browse_Results) HasEntries() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_browse_Results) SetEntries(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Sync_browse_Results_List is a list of Sync_browse_Results.
+type Sync_browse_Results_List struct{ capnp.List }
+
+// NewSync_browse_Results creates a new list of Sync_browse_Results.
+func NewSync_browse_Results_List(s *capnp.Segment, sz int32) (Sync_browse_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_browse_Results_List{l}, err
+}
+
+func (s Sync_browse_Results_List) At(i int) Sync_browse_Results {
+	return Sync_browse_Results{s.List.Struct(i)}
+}
+
+func (s Sync_browse_Results_List) Set(i int, v Sync_browse_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_browse_Results_List) String() string {
+	str, _ := text.MarshalList(0x9111634089ee1c4f, s.List)
+	return str
+}
+
+// Sync_browse_Results_Promise is a wrapper for a Sync_browse_Results promised by a client call.
+type Sync_browse_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_browse_Results_Promise) Struct() (Sync_browse_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_browse_Results{s}, err
+}
+
+type Sync_replicate_Params struct{ capnp.Struct }
+
+// Sync_replicate_Params_TypeID is the unique identifier for the type Sync_replicate_Params.
+const Sync_replicate_Params_TypeID = 0xa584f8c09920ca6c
+
+func NewSync_replicate_Params(s *capnp.Segment) (Sync_replicate_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return Sync_replicate_Params{st}, err
+}
+
+func NewRootSync_replicate_Params(s *capnp.Segment) (Sync_replicate_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return Sync_replicate_Params{st}, err
+}
+
+func ReadRootSync_replicate_Params(msg *capnp.Message) (Sync_replicate_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_replicate_Params{root.Struct()}, err
+}
+
+func (s Sync_replicate_Params) String() string {
+	str, _ := text.Marshal(0xa584f8c09920ca6c, s.Struct)
+	return str
+}
+
+func (s Sync_replicate_Params) Hashes() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Sync_replicate_Params) HasHashes() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_replicate_Params) SetHashes(v capnp.DataList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewHashes sets the hashes field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Sync_replicate_Params) NewHashes(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+func (s Sync_replicate_Params) Pin() bool {
+	return s.Struct.Bit(0)
+}
+
+func (s Sync_replicate_Params) SetPin(v bool) {
+	s.Struct.SetBit(0, v)
+}
+
+// Sync_replicate_Params_List is a list of Sync_replicate_Params.
+type Sync_replicate_Params_List struct{ capnp.List }
+
+// NewSync_replicate_Params creates a new list of Sync_replicate_Params.
+func NewSync_replicate_Params_List(s *capnp.Segment, sz int32) (Sync_replicate_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1}, sz)
+	return Sync_replicate_Params_List{l}, err
+}
+
+func (s Sync_replicate_Params_List) At(i int) Sync_replicate_Params {
+	return Sync_replicate_Params{s.List.Struct(i)}
+}
+
+func (s Sync_replicate_Params_List) Set(i int, v Sync_replicate_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_replicate_Params_List) String() string {
+	str, _ := text.MarshalList(0xa584f8c09920ca6c, s.List)
+	return str
+}
+
+// Sync_replicate_Params_Promise is a wrapper for a Sync_replicate_Params promised by a client call.
+type Sync_replicate_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_replicate_Params_Promise) Struct() (Sync_replicate_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_replicate_Params{s}, err
+}
+
+type Sync_replicate_Results struct{ capnp.Struct }
+
+// Sync_replicate_Results_TypeID is the unique identifier for the type Sync_replicate_Results.
+const Sync_replicate_Results_TypeID = 0x853f176ed2759011
+
+func NewSync_replicate_Results(s *capnp.Segment) (Sync_replicate_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_replicate_Results{st}, err
+}
+
+func NewRootSync_replicate_Results(s *capnp.Segment) (Sync_replicate_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_replicate_Results{st}, err
+}
+
+func ReadRootSync_replicate_Results(msg *capnp.Message) (Sync_replicate_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_replicate_Results{root.Struct()}, err
+}
+
+func (s Sync_replicate_Results) String() string {
+	str, _ := text.Marshal(0x853f176ed2759011, s.Struct)
+	return str
+}
+
+func (s Sync_replicate_Results) Pinned() (capnp.BitList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.BitList{List: p.List()}, err
+}
+
+func (s Sync_replicate_Results) HasPinned() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_replicate_Results) SetPinned(v capnp.BitList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewPinned sets the pinned field to a newly
+// allocated capnp.BitList, preferring placement in s's segment.
+func (s Sync_replicate_Results) NewPinned(n int32) (capnp.BitList, error) {
+	l, err := capnp.NewBitList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.BitList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Sync_replicate_Results_List is a list of Sync_replicate_Results.
+type Sync_replicate_Results_List struct{ capnp.List }
+
+// NewSync_replicate_Results creates a new list of Sync_replicate_Results.
+func NewSync_replicate_Results_List(s *capnp.Segment, sz int32) (Sync_replicate_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_replicate_Results_List{l}, err
+}
+
+func (s Sync_replicate_Results_List) At(i int) Sync_replicate_Results {
+	return Sync_replicate_Results{s.List.Struct(i)}
+}
+
+func (s Sync_replicate_Results_List) Set(i int, v Sync_replicate_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_replicate_Results_List) String() string {
+	str, _ := text.MarshalList(0x853f176ed2759011, s.List)
+	return str
+}
+
+// Sync_replicate_Results_Promise is a wrapper for a Sync_replicate_Results promised by a client call.
+type Sync_replicate_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_replicate_Results_Promise) Struct() (Sync_replicate_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_replicate_Results{s}, err
+}
+
+type Sync_presentDelegations_Params struct{ capnp.Struct }
+
+// Sync_presentDelegations_Params_TypeID is the unique identifier for the type Sync_presentDelegations_Params.
+const Sync_presentDelegations_Params_TypeID = 0xdb3f43d77dcad29d
+
+func NewSync_presentDelegations_Params(s *capnp.Segment) (Sync_presentDelegations_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_presentDelegations_Params{st}, err
+}
+
+func NewRootSync_presentDelegations_Params(s *capnp.Segment) (Sync_presentDelegations_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_presentDelegations_Params{st}, err
+}
+
+func ReadRootSync_presentDelegations_Params(msg *capnp.Message) (Sync_presentDelegations_Params, error) {
+	root, err := msg.RootPtr()
+	return Sync_presentDelegations_Params{root.Struct()}, err
+}
+
+func (s Sync_presentDelegations_Params) String() string {
+	str, _ := text.Marshal(0xdb3f43d77dcad29d, s.Struct)
+	return str
+}
+
+func (s Sync_presentDelegations_Params) Certs() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Sync_presentDelegations_Params) HasCerts() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_presentDelegations_Params) SetCerts(v capnp.DataList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewCerts sets the certs field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Sync_presentDelegations_Params) NewCerts(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Sync_presentDelegations_Params_List is a list of Sync_presentDelegations_Params.
+type Sync_presentDelegations_Params_List struct{ capnp.List }
+
+// NewSync_presentDelegations_Params creates a new list of Sync_presentDelegations_Params.
+func NewSync_presentDelegations_Params_List(s *capnp.Segment, sz int32) (Sync_presentDelegations_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_presentDelegations_Params_List{l}, err
+}
+
+func (s Sync_presentDelegations_Params_List) At(i int) Sync_presentDelegations_Params {
+	return Sync_presentDelegations_Params{s.List.Struct(i)}
+}
+
+func (s Sync_presentDelegations_Params_List) Set(i int, v Sync_presentDelegations_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_presentDelegations_Params_List) String() string {
+	str, _ := text.MarshalList(0xdb3f43d77dcad29d, s.List)
+	return str
+}
+
+// Sync_presentDelegations_Params_Promise is a wrapper for a Sync_presentDelegations_Params promised by a client call.
+type Sync_presentDelegations_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_presentDelegations_Params_Promise) Struct() (Sync_presentDelegations_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_presentDelegations_Params{s}, err
+}
+
+type Sync_presentDelegations_Results struct{ capnp.Struct }
+
+// Sync_presentDelegations_Results_TypeID is the unique identifier for the type Sync_presentDelegations_Results.
+const Sync_presentDelegations_Results_TypeID = 0xd0435866692b3a98
+
+func NewSync_presentDelegations_Results(s *capnp.Segment) (Sync_presentDelegations_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_presentDelegations_Results{st}, err
+}
+
+func NewRootSync_presentDelegations_Results(s *capnp.Segment) (Sync_presentDelegations_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Sync_presentDelegations_Results{st}, err
+}
+
+func ReadRootSync_presentDelegations_Results(msg *capnp.Message) (Sync_presentDelegations_Results, error) {
+	root, err := msg.RootPtr()
+	return Sync_presentDelegations_Results{root.Struct()}, err
+}
+
+func (s Sync_presentDelegations_Results) String() string {
+	str, _ := text.Marshal(0xd0435866692b3a98, s.Struct)
+	return str
+}
+
+func (s Sync_presentDelegations_Results) Folders() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s Sync_presentDelegations_Results) HasFolders() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Sync_presentDelegations_Results) SetFolders(v capnp.TextList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewFolders sets the folders field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s Sync_presentDelegations_Results) NewFolders(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Sync_presentDelegations_Results_List is a list of Sync_presentDelegations_Results.
+type Sync_presentDelegations_Results_List struct{ capnp.List }
+
+// NewSync_presentDelegations_Results creates a new list of Sync_presentDelegations_Results.
+func NewSync_presentDelegations_Results_List(s *capnp.Segment, sz int32) (Sync_presentDelegations_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Sync_presentDelegations_Results_List{l}, err
+}
+
+func (s Sync_presentDelegations_Results_List) At(i int) Sync_presentDelegations_Results {
+	return Sync_presentDelegations_Results{s.List.Struct(i)}
+}
+
+func (s Sync_presentDelegations_Results_List) Set(i int, v Sync_presentDelegations_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Sync_presentDelegations_Results_List) String() string {
+	str, _ := text.MarshalList(0xd0435866692b3a98, s.List)
+	return str
+}
+
+// Sync_presentDelegations_Results_Promise is a wrapper for a Sync_presentDelegations_Results promised by a client call.
+type Sync_presentDelegations_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Sync_presentDelegations_Results_Promise) Struct() (Sync_presentDelegations_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Sync_presentDelegations_Results{s}, err
+}
+
+type Meta struct{ Client capnp.Client }
+
+// Meta_TypeID is the unique identifier for the type Meta.
+const Meta_TypeID = 0xb02d2ba0578cc7ff
+
+func (c Meta) Ping(ctx context.Context, params func(Meta_ping_Params) error, opts ...capnp.CallOption) Meta_ping_Results_Promise {
+	if c.Client == nil {
+		return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xb02d2ba0578cc7ff,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Meta",
+			MethodName:    "ping",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 0}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Meta_ping_Params{Struct: s}) }
+	}
+	return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+
+type Meta_Server interface {
+	Ping(Meta_ping) error
+}
+
+func Meta_ServerToClient(s Meta_Server) Meta {
+	c, _ := s.(server.Closer)
+	return Meta{Client: server.New(Meta_Methods(nil, s), c)}
+}
+
+func Meta_Methods(methods []server.Method, s Meta_Server) []server.Method {
+	if cap(methods) == 0 {
+		methods = make([]server.Method, 0, 1)
+	}
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xb02d2ba0578cc7ff,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Meta",
+			MethodName:    "ping",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Meta_ping{c, opts, Meta_ping_Params{Struct: p}, Meta_ping_Results{Struct: r}}
+			return s.Ping(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	return methods
+}
+
+// Meta_ping holds the arguments for a server call to Meta.ping.
+type Meta_ping struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Meta_ping_Params
+	Results Meta_ping_Results
+}
+
+type Meta_ping_Params struct{ capnp.Struct }
+
+// Meta_ping_Params_TypeID is the unique identifier for the type Meta_ping_Params.
+const Meta_ping_Params_TypeID = 0xe1a9fd466eca248c
+
+func NewMeta_ping_Params(s *capnp.Segment) (Meta_ping_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Meta_ping_Params{st}, err
+}
+
+func NewRootMeta_ping_Params(s *capnp.Segment) (Meta_ping_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Meta_ping_Params{st}, err
+}
+
+func ReadRootMeta_ping_Params(msg *capnp.Message) (Meta_ping_Params, error) {
+	root, err := msg.RootPtr()
+	return Meta_ping_Params{root.Struct()}, err
+}
+
+func (s Meta_ping_Params) String() string {
+	str, _ := text.Marshal(0xe1a9fd466eca248c, s.Struct)
+	return str
+}
+
+// Meta_ping_Params_List is a list of Meta_ping_Params.
+type Meta_ping_Params_List struct{ capnp.List }
+
+// NewMeta_ping_Params creates a new list of Meta_ping_Params.
+func NewMeta_ping_Params_List(s *capnp.Segment, sz int32) (Meta_ping_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0}, sz)
+	return Meta_ping_Params_List{l}, err
+}
+
+func (s Meta_ping_Params_List) At(i int) Meta_ping_Params { return Meta_ping_Params{s.List.Struct(i)} }
+
+func (s Meta_ping_Params_List) Set(i int, v Meta_ping_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
@@ -1168,6 +2303,126 @@ func (c API) Push(ctx context.Context, params func(Sync_push_Params) error, opts
 	}
 	return Sync_push_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
 }
+func (c API) FetchBlock(ctx context.Context, params func(Sync_fetchBlock_Params) error, opts ...capnp.CallOption) Sync_fetchBlock_Results_Promise {
+	if c.Client == nil {
+		return Sync_fetchBlock_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      5,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "fetchBlock",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_fetchBlock_Params{Struct: s}) }
+	}
+	return Sync_fetchBlock_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) Gossip(ctx context.Context, params func(Sync_gossip_Params) error, opts ...capnp.CallOption) Sync_gossip_Results_Promise {
+	if c.Client == nil {
+		return Sync_gossip_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      6,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "gossip",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_gossip_Params{Struct: s}) }
+	}
+	return Sync_gossip_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) MigrateIdentity(ctx context.Context, params func(Sync_migrateIdentity_Params) error, opts ...capnp.CallOption) Sync_migrateIdentity_Results_Promise {
+	if c.Client == nil {
+		return Sync_migrateIdentity_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      7,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "migrateIdentity",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_migrateIdentity_Params{Struct: s}) }
+	}
+	return Sync_migrateIdentity_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) Browse(ctx context.Context, params func(Sync_browse_Params) error, opts ...capnp.CallOption) Sync_browse_Results_Promise {
+	if c.Client == nil {
+		return Sync_browse_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      8,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "browse",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_browse_Params{Struct: s}) }
+	}
+	return Sync_browse_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) Replicate(ctx context.Context, params func(Sync_replicate_Params) error, opts ...capnp.CallOption) Sync_replicate_Results_Promise {
+	if c.Client == nil {
+		return Sync_replicate_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      9,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "replicate",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 8, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_replicate_Params{Struct: s}) }
+	}
+	return Sync_replicate_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) PresentDelegations(ctx context.Context, params func(Sync_presentDelegations_Params) error, opts ...capnp.CallOption) Sync_presentDelegations_Results_Promise {
+	if c.Client == nil {
+		return Sync_presentDelegations_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      10,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "presentDelegations",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Sync_presentDelegations_Params{Struct: s}) }
+	}
+	return Sync_presentDelegations_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
 func (c API) Ping(ctx context.Context, params func(Meta_ping_Params) error, opts ...capnp.CallOption) Meta_ping_Results_Promise {
 	if c.Client == nil {
 		return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
@@ -1202,6 +2457,18 @@ type API_Server interface {
 
 	Push(Sync_push) error
 
+	FetchBlock(Sync_fetchBlock) error
+
+	Gossip(Sync_gossip) error
+
+	MigrateIdentity(Sync_migrateIdentity) error
+
+	Browse(Sync_browse) error
+
+	Replicate(Sync_replicate) error
+
+	PresentDelegations(Sync_presentDelegations) error
+
 	Ping(Meta_ping) error
 }
 
@@ -1212,7 +2479,7 @@ func API_ServerToClient(s API_Server) API {
 
 func API_Methods(methods []server.Method, s API_Server) []server.Method {
 	if cap(methods) == 0 {
-		methods = make([]server.Method, 0, 7)
+		methods = make([]server.Method, 0, 13)
 	}
 
 	methods = append(methods, server.Method{
@@ -1299,6 +2566,90 @@ func API_Methods(methods []server.Method, s API_Server) []server.Method {
 		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 0},
 	})
 
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      5,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "fetchBlock",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_fetchBlock{c, opts, Sync_fetchBlock_Params{Struct: p}, Sync_fetchBlock_Results{Struct: r}}
+			return s.FetchBlock(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      6,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "gossip",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_gossip{c, opts, Sync_gossip_Params{Struct: p}, Sync_gossip_Results{Struct: r}}
+			return s.Gossip(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      7,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "migrateIdentity",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_migrateIdentity{c, opts, Sync_migrateIdentity_Params{Struct: p}, Sync_migrateIdentity_Results{Struct: r}}
+			return s.MigrateIdentity(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 0},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      8,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "browse",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_browse{c, opts, Sync_browse_Params{Struct: p}, Sync_browse_Results{Struct: r}}
+			return s.Browse(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      9,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "replicate",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_replicate{c, opts, Sync_replicate_Params{Struct: p}, Sync_replicate_Results{Struct: r}}
+			return s.Replicate(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xf5692a07c5cf7872,
+			MethodID:      10,
+			InterfaceName: "net/capnp/api.capnp:Sync",
+			MethodName:    "presentDelegations",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Sync_presentDelegations{c, opts, Sync_presentDelegations_Params{Struct: p}, Sync_presentDelegations_Results{Struct: r}}
+			return s.PresentDelegations(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
 	methods = append(methods, server.Method{
 		Method: capnp.Method{
 			InterfaceID:   0xb02d2ba0578cc7ff,
@@ -1442,79 +2793,127 @@ func (p API_version_Results_Promise) Struct() (API_version_Results, error) {
 	return API_version_Results{s}, err
 }
 
-const schema_9bcb07fb35756ee6 = "x\xda\xacU]h\x1cU\x14>\xe7\xde;\x9d\x82\x89" +
-	"\xcbe\"d\x85\x9a>\xac-D\xdc\xa4\xd1\"\xe9\x83" +
-	"\xbb\x1bm\xe3>Df\xb6\xe2O\x9e\x1cwo\xbb\x83" +
-	"\xbb\xb3\xd3\x99Ym\x84 V\x0aU\xd2\xe2\x1f>\xb4" +
-	"U\xfc\xc1\x87V\x04)\x88\xd0\xc7\x8a\x04\"\xfe\xbc\xf8" +
-	" *X\x8a? \x08\x0a\xc1@\xd8\x8e\xdc\x99\x9d\xc9" +
-	"t7\xab\x8b\xf4\xed\xb2\xdf\xb9\xdf~\xe7\x9c\xef~3" +
-	"\xcdI\x91\xecS\x0a*\x80\xf1\x88\xb2#\xd8{\xedd" +
-	"\xe5j\xe7\x95\xb3\xc0\xb3\x08\xa0\xa0\x0ap\xcfI:\x83" +
-	"\x80\xdaiZ\x00\x0c^\xff`3\xfb\xc9\xcb\xe7\xde\x8b" +
-	"\x0a\x98\xc4?\xa2\x97\x11X\xf0\xd0\xc6\x89\x95\xbfN\xec" +
-	"\xbb\x08F\x16c\xe8-\xfa\x9c\xbcz!\xbc\x1a\xac\xae" +
-	"<\xf6\xce]w\x7f\x0c|\x8c\x06\xbf\xd8\xed\xfd\x9b\xea" +
-	"\x17\xe7\x00P\xfb\x92\xaei\xdfQ\x15@\xfb\x96\xcek" +
-	"\x1dy\x0a\xd6?{\xf2\xcc\x197s)\xcd\xf63]" +
-	"\x94l\x7f\x86l\x9d\xeb\xafM\xe9\x8f\x97?\xedc\xbb" +
-	"\x8d]\xd1v\xc9z-\xcb\xe6\xb5\x12\xdb\x0b\x10,\xe5" +
-	"\xd6o=KN\xad\xa6\xdb\x9aeOI\xb6\x83L\xb2" +
-	"\xbd\xb9\xe7\xefK\xbbw_\xfc*\xd5\x96`3\xb2-" +
-	"\xfeF\xf9\xe8\xc3\xac\xfaC\x0aY`\x8b\x12yq\xcf" +
-	"\xa9;n\xcf\xfc\x91Ff\x99+\x91\x95\xdc\x9a}\xa8" +
-	"s\xe1j\x0a\xb9\x93MJ\xe4~\xfe _\xfe\xe9\xdd" +
-	"_\xd3m\x8d\xb2+R\xc8\xaeP\xc8-\xf7\xbd\xff\xfd" +
-	"\xb5\xec\x8f\xbf\x831\x9e\x14\x94\xd8\x9c,(\x87\x05\xee" +
-	"\xf1\xaf?W'\xad\xf5\xbe\xbe-\xb6\xa6\xb5\xc3\xfac" +
-	"l\x1e\xb5\x05E\x05\xe8\x9c\xffm\xfa\xed\xe2\xbd\x1b\xa9" +
-	"\xb6\xf7+a\xdb%E\x92\xad.?\xfd\xc2\xa3\xe6\xf5" +
-	"\x8d\x94PS\x09\x85\xb2\xfa\xb1oNW>\xdc\x04>" +
-	"\x1e#e\xe5\x00\xc2t`\x0b\x7f\xaaj:6u\xa6" +
-	"L\xc7\xca\xcb\xa3s`A\xf8f\xde\xb1\xec\xa3\xb9\x8a" +
-	"\x98\xf0\xda\x0d\xdf3\x18e\x00\x0c\x01\xf8\xe8\x0c\x80\xb1" +
-	"\x93\xa21Fp\xc2\x15Nc\x09G\x80\xe0\x08`B" +
-	"\xa6\xa4\xc9\x0e/\xd9\xd5\xbc\xe5=\xd0j:\x0d\xe1\x8b" +
-	"C\xc2\xaf\xd6K\x8dF\xebYQ\xcb\x15t\xd35\x9b" +
-	"^r\x91msQo{I}\xa5 \xfa\xe4T\x00" +
-	"\x8c\x11\x8a\xc68\xc1\xc0\xf2\xa2J\xc0\x1a\"\x10\xc4\x94" +
-	"(\xd2\xdb!\x80\x8eh0\xaa\x00$;\xc6\xf8\xc9p" +
-	">\x09\x84+jF\x8e\xa1\x88:\xe2\xbf\x88<\"{" +
-	"\xd2M\xbfZ\xcf\xc9\x86hs\xa0\xc0#n\xabY\xb6" +
-	"k\x02\xf08*@P\x19$\xb0\xa4\x97S\xf2\xe2\xfd" +
-	"al(\xce\xe7By\xcf?#\\\xcfj\xd9E4" +
-	"vb\xcaN\x00[\x0f\x14`8\xe9\x15\xe1\xb5\xd5\x9e" +
-	"\xe1Nn\xed:S3}\x13G\x81\xe0hJ4\xed" +
-	"ct\xda^=\xf1\xcd\x7f\xfd\xf3a\xbf\xe5\x8axh" +
-	"C\xdb@\x9f\xb8\xd16\x03\xcc\xab\x9b\x99\x1b\xcav\x0c" +
-	"k\xcbJ\xe42\xf8?6c=[\xccw7\xb4-" +
-	"\xe9\xdc\xd6x\xe3M\"\x03\x82l\x90-\xa4\xea\xc8\xb7" +
-	"c\xa11\xe2<\xc3\xf3\xd0\xcd\x86W\x17\x81\xf0\x97T" +
-	"\xc4$t1\xceK\xbe,\xb1\xb6\x8a$\x09~\x8c#" +
-	"\x8c[\x97\x81p\xa1\"M\x92\x10\xe3o\x00\x7f\xc2\x05" +
-	"\xc2\x0d\x15Y\x121\x18G,?(\xdf\xc9\xac\x1a\xc4" +
-	"\xeb\x04\xea\x8a\"\x06\xb1\xaf\x80V\xebE9\xb1h\xd0" +
-	"\x18O\xba\x10\x8d:\x84\xa2\xd5\xc2D\xf7\x97\x8ct\xd0" +
-	"PO.r\xcf\xcd\xf4m\xafe\xe8\xa0mv\x93\xeb" +
-	"\x9f\x00\x00\x00\xff\xff\x05x4\x05"
+const schema_9bcb07fb35756ee6 = "x\xda\xacVQl\x14U\x17>gfw\xefRZ" +
+	"\x9a\xc9\xc0\xff\xd3?\x7f,\xea\x02\xa1H)U\xa24" +
+	"\xc1\xdd\x16\x047\x06\xddY\x82b_t\xd8\xbd\xedN" +
+	"\xd8\xcengf\x85\xaa\x0d\xa1\xd0\x04H!\x80\xd6H" +
+	"\x01C!}\x00C\x14\"1\xf2\xa4\x10\xd3\xa4\x80\"" +
+	"\x89!\x04\x89 \x8a\x10\x84D\x13\xb4\x84\x941wv" +
+	"g:\xdbm\xcb\x82\xbe\xcd\xee\xfd\xcew\xcf=\xf7;" +
+	"\xe7~5\xbf\xf1!n\x9e\xf7\x8d\x89\x00\xd2F\xaf\xcf" +
+	"\x14\xb6g\xce\xa9\xff\x0dv\x82P\x81\x00^$\x00O" +
+	"w{\x1b\x11P\xec\xf5\x06\x01\xcd\x9f\xfe\xb3\xefT\xeb" +
+	";\xf1<\xc0wY\xc0%\x0bp\xeb\xc6\xc9\x1a\xfd\xa5" +
+	"\x03]n\xc0\x90\xb7\x8e\x01\xbc>\x06x\xe5\xff\xb77" +
+	"\x87b\xc2\x0e7`\xba\xaf\x81\x01\xe6X\x80\x99W;" +
+	"\xa3W\x86\xb6\xf7\xb8\x01\xcb|\xb5\x0c\xb0\xc2\x02\xbc\xd7" +
+	"w\xaf\xe2\xd8\x96\xdd\xfb\xb3\x00\x0f[\xcf\xf8\x8e#x" +
+	"\xcc\xcf\xae\xd7\xdc\xbcq\xe9\xc9>w(\xf5u\xb0\xd0" +
+	"V+490m\xd7\x97\x83\x1b\xfb@\xaa@\x07\xb1" +
+	"\xc3\x17e\x88\xbd\xbe5\x80\xe6\x8b\x83\x1d]\x7ft\xcc" +
+	";\x94CX\xec\x13\xc8\xdb\x0c0\x850\x8a\xd3d\xc5" +
+	"\xe9\x1f?\xa9=\xe4\xdec\x01\xb1\xf2\xaf\xb7\x00f\x7f" +
+	"\xd7k\xfbf\xcf\xf9\x14\x84\xc9\xbcyM\xcd\xcc\xbfG" +
+	"N\xed\x06@Q&\x03b\x0b!\x00\xa2B\x96\x8a;" +
+	"\xd8\x97y\xe7\xe4\x9b\xdb\xb6i\xe5G\xdd\xdb\xb5\x11\xab" +
+	"\x9e\x9d\x16\xdb\xd0\xfd\x9ds#+\xc3\x9f\x17\xb0\x1d$" +
+	"'\xc4#\x16\xdba\xb2T\xfc\x9e\xcc\x040\xdb\x02w" +
+	"&\xf5p\x9b\xfa\xdd\xb9\x9d!\xab\x18\xdb\x05\x8b\xed\x83" +
+	"\x19\x7f\x1d\x9d6\xed\xd07\xae\xd2\xdd%\xb5\xact\x1f" +
+	"\xd6\xcdV\x9aV.:\xeb\x0e\xbdB\xf6\xb3\xd0\xdf\xad" +
+	"\xd0\xbd\xe7\x06\xda\xcf/\x0a^t\x03\x1e\xf7\xf70\xc0" +
+	"<?\x03\x08\xef\x87\x9b_\xf6\xc4~pq\xcb\xfeF" +
+	"\xc6\xbda\xc6\xa6\xc7\xfeW~\xdb\xbd\xb2\xcc\xaf\xb1\x95" +
+	"\x9fw\xfdy\xad\xf5\xdd\xd0\xe5\xbcb\xfa-\xb5\xd4[" +
+	"\xa4]\x81\x01u\xc9\xd0\xc1+y\xa4U,\xf4ya" +
+	"\xb1\xd0~\xb9\xf7Ww\xe5\xc2\xfe\x13,\xf4u+t" +
+	"\xe2\xb3\x07.^\xad\xb8t\x13\xa4\xa9\x0e\xa0\xddo]" +
+	"T\xa7\x05\xd0\xd6~\xfb5\xa9R\xee\x14\x94\xb6\xcf?" +
+	" \x1e\xf13\xfca\x7f?'n-!\x00C{\xae" +
+	"\xd7|\x14zf\xd0\x95h\xa6\xc4\xaa\xec\xfa\x12F\xd6" +
+	"\xdf\xbez\xfd\xab\xf2\xfdAW\xa2\xbd%V\xa2\xe7=" +
+	"m/\xec\xdc\x10\xb8\xeb>\xe3\xe6lh\xb7\x15\xeaI" +
+	"\xb4\x9e\xdd\x1a\xfd\xf8\x1e\x08S\xed\xd0c%u,T" +
+	"\x9b\xa9w\xd7,\x98r\xdfE\xba\xb7d\x0bB\x8d\xa9" +
+	"RcnLN\xab\x9e\xf4\\9\xadT\xb3\xcft\xdd" +
+	"\xf265V\xad\xd1tR\x89\xc9\x06\x0dD\xa9\x9eI" +
+	"\xf2\x86.yx\x0f\x80\x07\x01\x84\xb2:\x00\xc9\xcf\xa3" +
+	"\x14\xe00\x98VT\x95\xc6q\x12`\x84GD\xe0\xd8" +
+	"\xe78\xccM\xd4\x88%\x1a\x92\xa9\xd8\xea@D\xd6d" +
+	"\xbe%\x8f\xb9*\xc7<\x99\xc3\xf2\x84\xac'\xb0\x0c8" +
+	",s\x11\xf2\x05\x84\xcd)]W\xd2\x81 c\xcb'" +
+	"k\x18&[GUCS\xa8^\xc0W\x98\xe0*-" +
+	"\xb5F\xcf\x9d\xdb\xd0\xe1a\x09\xf3\x12\\F\x0d\xb9:" +
+	"\xad\xa8\xcd\x81(\xad\xb4\xf8\xdct\xb5\xc3t\x95\xac\xe0" +
+	"mX\x0a\x1c\x96\xba\xc8\xbc\x05\xd9)\xfa\xa2TK:" +
+	"I\x0d\xba\x84\x15\xb2>\x99L\xad\xa1q\xfb\xf4\xe3\x04" +
+	"\xb6(\xcd\x9al\xd0p\x9c\xaa\x86b\xb4\x05\xb2\x01c" +
+	"\x1eO\xa3\xb1\x94\x16/\xa6^\xc3R\xb1(Q\x97\xfc" +
+	"\x0e\xe5,\xa6\x94\x00\x8fR\x88C\xc4\xc9\xc8\xfe[\xf8" +
+	"\x04\x80\xf4\x1c\x8f\xd2b\x0e\x83\xec\x8e\xa9n\xab\xa7," +
+	"\xab\x1e\x92VTKI8\xee\xc6\x8a\x1e\xc9\xe8N\x05" +
+	"\xa2AZP\xe0(\x80T\xca\xa34\x95CS\xd1\xb3" +
+	"H\xc0x\x11\xdc9Q=\xaa\x08\xb8\x91\"\x00\x88 " +
+	"J\x1e\xde\x0b\xe0L#\xb4\xdf(A\xa8\x02N\xf0\x92" +
+	"r\xa6\x94\x10F\xf0\x81\xfd\x13\x91\x8dXb\xb4\xfeq" +
+	"\x9f\xb8IK\xb5\x84\xd58\x05\\\x8b^\xe0\xd0;V" +
+	"\x82\xf5\x91\xb0+={\x90\xa0=\xfa\x04\xa1\xc1Jo" +
+	"\xdd[T\xd3\x95\x94\x1aB\xc9\x8f\xae\xc1\x070\xfcZ" +
+	"\x01\x14\x97:+,I\x1ac\xf6~\\6\xe4\"z" +
+	"?\x9d\xd1\x13Nk\x8d#\xfe\xb4Fu\xaa\x1a\x8bi" +
+	"\x926\xcb\x86\x92R\xf5Q3h\x18\x9ek\xeb\x9aR" +
+	"\xc98\xd5\x1ci\x96\x8e\x18lE\xed1\xca\x05\xd5\x0e" +
+	"oQ\x19\xa3\x9a1R\xfb\x0f*\xdfr#\xa5Q\x9b" +
+	"\xb8\xe8\xe6\x88T\xe6\x8f\x07~\xac\xa97\xca\x14u_" +
+	"KZ6\x12\x05Cj\x8c\x89\x17\x91\xcb\xf3\xf6\xf4\x15" +
+	";\xcbFk\xbaG\xea\xe4\xfaH\xb8:\xa7\xd9\x07v" +
+	"r\x0e\x87\x1e\xe0\xd03V\xa3\xb0\xac\xb3\x9d\xfc\x94\xd5" +
+	"*\xb6Y\xc1=\x90}\xd7E\x09\x1b\x81\x13\xc3H\x10" +
+	"\x1dW\x86\xb6\xa1\x12\x17Z\xab\xf3\x91 \xe7\xf8O\xb4" +
+	"-\x888\x0b\x8f\x03'NG\x82\xbccv\xd0v\x92" +
+	"b\x05j\xc0\x89\x02\x12\xf486\x01m'&z\x91" +
+	"\x8d\x90\xbb\x04\xbd\x8e\xb9F\xdb2\x08\xb7\x1a\x81\x13~" +
+	"!\xe8s|5\xda\xfeS\xb8P\x07\x9cp\x86 q" +
+	"l/\xda~A\xf8\xaa\x038\xe1\x0b\x82~\xc7a\xa1" +
+	"\xed\xbb\x85\xc3,\xae\x97\xe0\x04\xc7\x0d\xa3m\xfb\x85\xee" +
+	"(p\xc2V\x82%\x8e\xddC\xdb\x18\x0a\xeb{\x80\x13" +
+	"\xda\x89i\x8b\x19x\x8d\x86\xd0\xb4G\x03\xf0\xb1D\x08" +
+	"M[\x19hK#\x98\xd5\x86\xb5\x94\x156T\xe6\xfe" +
+	")gC\xc0\xa6hH\xa6\x80\x8f\xad\x0ea0;\xc1" +
+	"Ch\xda\x0f\x1f\xe6^>\x08a0\xab\xf6\x10\x9a\xf6" +
+	"\xe3\x05\xc8~\xd9-\x8cv\x0f\xf3\xaa^\xd4<\xcev" +
+	"\xe5\xbf9\xd4Fv\xcf\xb8F\xea\x9fo\xecn\x95\x87" +
+	"\xf7\x12vs\xc1\xdf\x03\x00\xf0\xd2\xfb\x00"
 
 func init() {
 	schemas.Register(schema_9bcb07fb35756ee6,
+		0x853f176ed2759011,
+		0x85647b71cba016e2,
+		0x8ca34b7330c3e9ed,
+		0x9111634089ee1c4f,
 		0x9a90fde15285e327,
 		0xa29b8ab519fba593,
+		0xa523dde9eb30e8b4,
+		0xa584f8c09920ca6c,
 		0xaa3182f28c82f848,
+		0xaa32afdfcc5507cc,
 		0xb02d2ba0578cc7ff,
 		0xb20f728e8e60c3f5,
 		0xb74958502f92fefd,
 		0xc788029a0ef52479,
 		0xceaa2020b2f72696,
+		0xd0435866692b3a98,
+		0xdb3f43d77dcad29d,
 		0xdc63044e67499411,
 		0xdcee0f1a1e882683,
+		0xe0407c71e6f699e4,
 		0xe1a9fd466eca248c,
 		0xe7a1e07d1144113e,
 		0xebdd19e3dba3370b,
 		0xf5692a07c5cf7872,
 		0xf834409e30e8009c,
 		0xf8fe6156816b7dc7,
-		0xfbab528dd0716804)
+		0xf9248392457904d7,
+		0xfbab528dd0716804,
+		0xfe15393095732772)
 }
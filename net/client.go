@@ -3,6 +3,7 @@ package net
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -12,7 +13,10 @@ import (
 	"github.com/sahib/brig/net/capnp"
 	"github.com/sahib/brig/net/peer"
 	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/errkind"
+	h "github.com/sahib/brig/util/hashlib"
 	log "github.com/sirupsen/logrus"
+	capnplib "zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/rpc"
 )
 
@@ -59,6 +63,13 @@ func DialByAddr(
 		return nil, err
 	}
 
+	// The remote list has the final word on how much we trust `addr`:
+	remote, err := rp.Remotes.RemoteByAddr(addr)
+	isKnown := err == nil
+	if isKnown && remote.IsBlocked() {
+		return nil, errkind.Wrap(errkind.Unauthorized, fmt.Errorf("remote `%s` is blocked", remote.Name))
+	}
+
 	// Low level by addr, not by brig's remote name:
 	log.Debugf("raw dial to %s:%s", addr, fingerprint.PubKeyID())
 	rawConn, err := bk.Dial(addr, fingerprint.PubKeyID(), "brig/caprpc")
@@ -72,7 +83,16 @@ func DialByAddr(
 		return nil, fmt.Errorf("rejecting own, empty fingerprint... bug?")
 	}
 
+	// A remote added by addr only is pinned to the key it shows us first:
+	pinOnFirstUse := !fingerprint.IsPinned() && isKnown && remote.TrustLevel() == repo.TrustTOFU
+
+	var seenPubKey []byte
 	authConn := NewAuthReadWriter(rawConn, kr, ownPubKey, ownName, func(pubKey []byte) error {
+		if pinOnFirstUse {
+			seenPubKey = pubKey
+			return nil
+		}
+
 		if !fingerprint.PubKeyMatches(pubKey) {
 			pingMap.hintNetAttempt(addr, false)
 			return fmt.Errorf("remote pubkey does not match fingerprint")
@@ -88,6 +108,15 @@ func DialByAddr(
 		return nil, e.Wrapf(err, "auth")
 	}
 
+	if pinOnFirstUse {
+		remote.Fingerprint = peer.BuildFingerprint(addr, seenPubKey)
+		log.Infof("pinning key of `%s` on first use: %s", remote.Name, remote.Fingerprint)
+		if err := rp.Remotes.AddOrUpdateRemote(remote); err != nil {
+			rawConn.Close()
+			return nil, e.Wrapf(err, "pin")
+		}
+	}
+
 	pingMap.hintNetAttempt(addr, true)
 
 	// Setup capnp-rpc:
@@ -95,13 +124,29 @@ func DialByAddr(
 	clientConn := rpc.NewConn(transport, rpc.ConnLog(nil))
 	api := capnp.API{Client: clientConn.Bootstrap(ctx)}
 
-	return &Client{
+	cl := &Client{
 		ctx:      ctx,
 		authConn: authConn,
 		conn:     clientConn,
 		rawConn:  rawConn,
 		api:      api,
-	}, nil
+	}
+
+	// Show our delegations, so we can read what others shared with us
+	// via them. The other side will ignore what it cannot verify.
+	certs, err := rp.Delegations()
+	if err != nil {
+		log.Warningf("failed to load delegations: %v", err)
+	} else if len(certs) > 0 {
+		folders, err := cl.PresentDelegations(certs)
+		if err != nil {
+			log.Debugf("%s did not accept delegations: %v", addr, err)
+		} else {
+			log.Debugf("%s accepted delegations for %v", addr, folders)
+		}
+	}
+
+	return cl, nil
 }
 
 // PeekRemotePubkey connects to `addr` and tries to read the public key they claim.
@@ -236,3 +281,170 @@ func (cl *Client) Push() error {
 	_, err := call.Struct()
 	return err
 }
+
+// Gossip sends the head records we know to the remote
+// and returns the ones it knows in exchange.
+func (cl *Client) Gossip(entries []GossipEntry) ([]GossipEntry, error) {
+	data, err := EncodeGossip(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	call := cl.api.Gossip(cl.ctx, func(p capnp.Sync_gossip_Params) error {
+		return p.SetEntries(data)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := result.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeGossip(reply)
+}
+
+// FetchBlock asks the remote for a single block of content it stores.
+func (cl *Client) FetchBlock(ctx context.Context, hash h.Hash) ([]byte, error) {
+	call := cl.api.FetchBlock(ctx, func(p capnp.Sync_fetchBlock_Params) error {
+		return p.SetHash(hash.Bytes())
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data()
+}
+
+// MigrateIdentity sends our signed renames to the remote,
+// so it knows us under our new name afterwards.
+func (cl *Client) MigrateIdentity(records [][]byte) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	call := cl.api.MigrateIdentity(cl.ctx, func(p capnp.Sync_migrateIdentity_Params) error {
+		return p.SetRecords(data)
+	})
+
+	_, err = call.Struct()
+	return err
+}
+
+// Browse lists the directory `root` of the remote. Only the parts
+// of its tree that the remote shares with us are returned.
+func (cl *Client) Browse(root string) ([]BrowseEntry, error) {
+	call := cl.api.Browse(cl.ctx, func(p capnp.Sync_browse_Params) error {
+		return p.SetPath(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := result.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []BrowseEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Replicate asks the remote which content of `hashes` it stores.
+// If `pin` is true, it is asked to store all of them that it knows.
+// The returned slice tells for each hash if the remote stores it.
+func (cl *Client) Replicate(hashes []h.Hash, pin bool) ([]bool, error) {
+	call := cl.api.Replicate(cl.ctx, func(p capnp.Sync_replicate_Params) error {
+		capHashes, err := capnplib.NewDataList(p.Segment(), int32(len(hashes)))
+		if err != nil {
+			return err
+		}
+
+		for idx, hash := range hashes {
+			if err := capHashes.Set(idx, hash.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		p.SetPin(pin)
+		return p.SetHashes(capHashes)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capPinned, err := result.Pinned()
+	if err != nil {
+		return nil, err
+	}
+
+	if capPinned.Len() != len(hashes) {
+		return nil, fmt.Errorf("remote answered for %d of %d hashes", capPinned.Len(), len(hashes))
+	}
+
+	pinned := make([]bool, capPinned.Len())
+	for idx := range pinned {
+		pinned[idx] = capPinned.At(idx)
+	}
+
+	return pinned, nil
+}
+
+// PresentDelegations shows `certs` (see repo.Delegation) to the remote.
+// It returns the folders of the certificates it accepted.
+func (cl *Client) PresentDelegations(certs [][]byte) ([]string, error) {
+	if len(certs) > MaxDelegations {
+		certs = certs[:MaxDelegations]
+	}
+
+	call := cl.api.PresentDelegations(cl.ctx, func(p capnp.Sync_presentDelegations_Params) error {
+		capCerts, err := capnplib.NewDataList(p.Segment(), int32(len(certs)))
+		if err != nil {
+			return err
+		}
+
+		for idx, cert := range certs {
+			if err := capCerts.Set(idx, cert); err != nil {
+				return err
+			}
+		}
+
+		return p.SetCerts(capCerts)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capFolders, err := result.Folders()
+	if err != nil {
+		return nil, err
+	}
+
+	folders := []string{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		folder, err := capFolders.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, folder)
+	}
+
+	return folders, nil
+}
@@ -13,6 +13,7 @@ import (
 	ie "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/net/peer"
 	"github.com/sahib/brig/repo"
+	h "github.com/sahib/brig/util/hashlib"
 	"github.com/stretchr/testify/require"
 )
 
@@ -283,3 +284,125 @@ func TestClientCompleteFetchAllowed(t *testing.T) {
 		require.True(t, isAllowed)
 	})
 }
+
+func TestClientFetchBlock(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		require.Nil(t, a.fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+
+		info, err := a.fs.Stat("/x")
+		require.Nil(t, err)
+
+		// b.ctl talks to alice:
+		data, err := b.ctl.FetchBlock(context.Background(), info.BackendHash)
+		require.Nil(t, err)
+		require.Equal(t, h.SumWithBackendHash(data), info.BackendHash)
+
+		_, err = b.ctl.FetchBlock(context.Background(), h.TestDummy(t, 42))
+		require.NotNil(t, err)
+	})
+}
+
+func TestClientTrust(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		ctx := context.Background()
+
+		// Alice re-adds bob by his address only and pins his key on first use:
+		fp := buildFingerprint(t, b)
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(repo.Remote{
+			Name:        "bob",
+			Fingerprint: peer.Fingerprint(fp.Addr() + ":"),
+			Trust:       repo.TrustTOFU,
+		}))
+
+		bobCtl, err := Dial(ctx, "bob", a.rp, a.bk, nil)
+		require.Nil(t, err)
+		require.Nil(t, bobCtl.Ping())
+		require.Nil(t, bobCtl.Close())
+
+		rmt, err := a.rp.Remotes.Remote("bob")
+		require.Nil(t, err)
+		require.Equal(t, fp, rmt.Fingerprint)
+
+		// Once blocked, nobody may talk to the other side:
+		rmt.Trust = repo.TrustBlocked
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(rmt))
+
+		_, err = Dial(ctx, "bob", a.rp, a.bk, nil)
+		require.NotNil(t, err)
+
+		_, err = Dial(ctx, "alice", b.rp, b.bk, nil)
+		require.NotNil(t, err)
+	})
+}
+
+func TestClientBrowse(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		require.Nil(t, a.fs.Stage("/photos/summer/beach.png", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, a.fs.Stage("/docs/taxes.pdf", bytes.NewReader([]byte{4, 5})))
+		require.Nil(t, a.fs.Stage("/secret.txt", bytes.NewReader([]byte{6})))
+
+		entries, err := b.ctl.Browse("/")
+		require.Nil(t, err)
+		require.Len(t, entries, 3)
+
+		rmt, err := a.rp.Remotes.Remote("bob")
+		require.Nil(t, err)
+
+		rmt.Folders = []repo.Folder{{Folder: "/photos/summer"}}
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(rmt))
+
+		// Only the way to the shared folder is visible:
+		entries, err = b.ctl.Browse("/")
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "/photos", entries[0].Path)
+		require.True(t, entries[0].Partial)
+		require.Equal(t, uint64(0), entries[0].Size)
+
+		entries, err = b.ctl.Browse("/photos/summer")
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "/photos/summer/beach.png", entries[0].Path)
+		require.Equal(t, uint64(3), entries[0].Size)
+		require.Equal(t, 1, entries[0].Versions)
+		require.False(t, entries[0].Partial)
+
+		_, err = b.ctl.Browse("/docs")
+		require.NotNil(t, err)
+
+		_, err = b.ctl.Browse("/secret.txt")
+		require.NotNil(t, err)
+	})
+}
+
+func TestClientReplicate(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		require.Nil(t, a.fs.Stage("/photos/beach.png", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, a.fs.MakeCommit("add"))
+		require.Nil(t, a.fs.Unpin("/photos/beach.png", "curr", true))
+
+		info, err := a.fs.Stat("/photos/beach.png")
+		require.Nil(t, err)
+
+		hashes := []h.Hash{info.BackendHash, h.TestDummy(t, 23)}
+
+		// bob is in none of alice's groups yet:
+		_, err = b.ctl.Replicate(hashes, true)
+		require.NotNil(t, err)
+
+		require.Nil(t, a.rp.Config.SetStrings("net.replication.groups", []string{"backup=bob"}))
+
+		pinned, err := b.ctl.Replicate(hashes, false)
+		require.Nil(t, err)
+		require.Equal(t, []bool{false, false}, pinned)
+
+		// Content that alice does not know is never pinned:
+		pinned, err = b.ctl.Replicate(hashes, true)
+		require.Nil(t, err)
+		require.Equal(t, []bool{true, false}, pinned)
+
+		pinned, err = b.ctl.Replicate(hashes, false)
+		require.Nil(t, err)
+		require.Equal(t, []bool{true, false}, pinned)
+	})
+}
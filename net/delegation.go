@@ -0,0 +1,273 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/net/capnp"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/errkind"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+	capnplib "zombiezen.com/go/capnproto2"
+)
+
+// Peers that are not one of our remotes may connect if
+// net.delegation.accept is enabled. They can not do anything until
+// they showed a delegation (see repo.Delegation) for a folder and
+// can only read the folders of the delegations we accepted then.
+// Remotes may show delegations too, to read more than we share with them.
+
+// MaxDelegations limits how many certificates one call may carry.
+const MaxDelegations = 64
+
+var (
+	// ErrNoDelegation is returned to peers that are not a remote of
+	// ours and did not show a valid delegation (yet).
+	ErrNoDelegation = errors.New("you are not a remote and showed no valid delegation")
+)
+
+// isDelegate tells if the peer is no remote of ours.
+func (hdl *requestHandler) isDelegate() bool {
+	return hdl.currRemoteName == ""
+}
+
+// peerName returns how the peer should be called in logs and messages.
+func (hdl *requestHandler) peerName() string {
+	if hdl.isDelegate() {
+		return fmt.Sprintf("%s (delegate)", hdl.delegateName)
+	}
+
+	return hdl.currRemoteName
+}
+
+// delegatedFolders returns the folders of all accepted delegations
+// that did not expire yet.
+func (hdl *requestHandler) delegatedFolders() []repo.Folder {
+	hdl.dgMu.Lock()
+	defer hdl.dgMu.Unlock()
+
+	now := time.Now()
+	folders := []repo.Folder{}
+	for _, dg := range hdl.delegations {
+		if !dg.IsExpired(now) {
+			folders = append(folders, repo.Folder{Folder: dg.Folder})
+		}
+	}
+
+	return folders
+}
+
+// readFolders returns the folders the peer may read.
+// Like for repo.Remote, an empty list means everything.
+func (hdl *requestHandler) readFolders() ([]repo.Folder, error) {
+	delegated := hdl.delegatedFolders()
+	if hdl.isDelegate() {
+		if len(delegated) == 0 {
+			return nil, ErrNoDelegation
+		}
+
+		return delegated, nil
+	}
+
+	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	if completeExportAllowed(currRemote.Folders) {
+		return currRemote.Folders, nil
+	}
+
+	return append(currRemote.Folders, delegated...), nil
+}
+
+// issuerPubKey returns the key of `issuer`, if it may delegate `folder`.
+func (hdl *requestHandler) issuerPubKey(issuer, folder string) ([]byte, error) {
+	if issuer == hdl.rp.Owner {
+		return hdl.rp.Keyring().OwnPubKey()
+	}
+
+	issuerRemote, err := hdl.rp.Remotes.Remote(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("we do not know the issuer »%s«", issuer)
+	}
+
+	if issuerRemote.IsBlocked() {
+		return nil, errkind.Wrap(errkind.Unauthorized, fmt.Errorf("the issuer »%s« is blocked", issuer))
+	}
+
+	// Nobody may pass on more than we share with them:
+	if !completeExportAllowed(issuerRemote.Folders) {
+		shared := []string{}
+		for _, sharedFolder := range issuerRemote.Folders {
+			shared = append(shared, path.Clean("/"+sharedFolder.Folder))
+		}
+
+		if !isSharedPath(folder, shared) {
+			return nil, fmt.Errorf("»%s« may not read %s itself", issuer, folder)
+		}
+	}
+
+	return hdl.rp.Keyring().PubKeyFor(issuer)
+}
+
+// checkDelegation verifies the certificate `data` for the connected peer.
+func (hdl *requestHandler) checkDelegation(data []byte) (*repo.Delegation, error) {
+	dg, err := repo.ParseDelegation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if dg.Holder != hdl.peerKeyID {
+		return nil, errors.New("delegation was issued for somebody else")
+	}
+
+	if dg.IsExpired(time.Now()) {
+		return nil, fmt.Errorf("delegation expired at %s", dg.Expires)
+	}
+
+	pubKey, err := hdl.issuerPubKey(dg.Issuer, dg.Folder)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.VerifyDelegation(data, pubKey)
+}
+
+// PresentDelegations is called by peers to show us their delegations.
+// Bad certificates are skipped; the folders of the accepted ones are returned.
+func (hdl *requestHandler) PresentDelegations(call capnp.Sync_presentDelegations) error {
+	if !hdl.rp.Config.Bool("net.delegation.accept") {
+		return errors.New("delegations are not accepted")
+	}
+
+	capCerts, err := call.Params.Certs()
+	if err != nil {
+		return err
+	}
+
+	if capCerts.Len() > MaxDelegations {
+		return fmt.Errorf("too many delegations: %d (max %d)", capCerts.Len(), MaxDelegations)
+	}
+
+	accepted := []string{}
+	for idx := 0; idx < capCerts.Len(); idx++ {
+		data, err := capCerts.At(idx)
+		if err != nil {
+			return err
+		}
+
+		dg, err := hdl.checkDelegation(data)
+		if err != nil {
+			log.Warningf("%s showed a bad delegation: %v", hdl.peerName(), err)
+			continue
+		}
+
+		log.Infof(
+			"%s may read %s until %s (delegated by »%s«)",
+			hdl.peerName(), dg.Folder, dg.Expires.Format(time.Stamp), dg.Issuer,
+		)
+
+		hdl.dgMu.Lock()
+		hdl.delegations = append(hdl.delegations, dg)
+		hdl.delegatedBlocks = nil
+		hdl.dgMu.Unlock()
+
+		accepted = append(accepted, dg.Folder)
+	}
+
+	capFolders, err := capnplib.NewTextList(call.Results.Segment(), int32(len(accepted)))
+	if err != nil {
+		return err
+	}
+
+	for idx, folder := range accepted {
+		if err := capFolders.Set(idx, folder); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetFolders(capFolders)
+}
+
+// blockLinks adds `hash` and all blocks below it that we store to `blocks`.
+func blockLinks(bbk catfs.BlockBackend, hash h.Hash, blocks map[string]bool) error {
+	queue := []h.Hash{hash}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		key := curr.B58String()
+		if blocks[key] {
+			continue
+		}
+
+		blocks[key] = true
+
+		has, err := bbk.HasBlock(curr)
+		if err != nil {
+			return err
+		}
+
+		if !has {
+			continue
+		}
+
+		links, err := bbk.BlockLinks(curr)
+		if err != nil {
+			return err
+		}
+
+		queue = append(queue, links...)
+	}
+
+	return nil
+}
+
+// isDelegatedBlock tells if `hash` is part of a file in one of the
+// delegated folders. The blocks are collected once per connection
+// and collected again when new delegations were shown.
+func (hdl *requestHandler) isDelegatedBlock(bbk catfs.BlockBackend, hash h.Hash) (bool, error) {
+	folders := hdl.delegatedFolders()
+
+	hdl.dgMu.Lock()
+	defer hdl.dgMu.Unlock()
+
+	if hdl.delegatedBlocks == nil {
+		fs, err := hdl.rp.FS(hdl.rp.Owner, hdl.bk)
+		if err != nil {
+			return false, err
+		}
+
+		blocks := make(map[string]bool)
+		for _, folder := range folders {
+			infos, err := fs.List(folder.Folder, -1)
+			if ie.IsNoSuchFileError(err) {
+				continue
+			}
+
+			if err != nil {
+				return false, err
+			}
+
+			for _, info := range infos {
+				if info.IsDir {
+					continue
+				}
+
+				if err := blockLinks(bbk, info.BackendHash, blocks); err != nil {
+					return false, err
+				}
+			}
+		}
+
+		hdl.delegatedBlocks = blocks
+	}
+
+	return hdl.delegatedBlocks[hash.B58String()], nil
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDelegation(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		// Bob stores more than he shares with alice:
+		require.Nil(t, b.fs.Stage("/photos/beach.png", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, b.fs.Stage("/docs/taxes.pdf", bytes.NewReader([]byte{4, 5})))
+
+		rmt, err := b.rp.Remotes.Remote("alice")
+		require.Nil(t, err)
+
+		rmt.Folders = []repo.Folder{{Folder: "/photos"}}
+		require.Nil(t, b.rp.Remotes.AddOrUpdateRemote(rmt))
+
+		aliceKey, err := a.rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+		require.Nil(t, b.rp.Keyring().SavePubKey("alice", aliceKey))
+
+		withNetServer(t, "carol", "", func(c testUnit) {
+			ctx := context.Background()
+			carolKey, err := c.rp.Keyring().OwnPubKey()
+			require.Nil(t, err)
+
+			issue := func(folder string) []byte {
+				cert, err := repo.SignDelegation(repo.Delegation{
+					Issuer:  "alice",
+					Holder:  peer.BuildFingerprint("", carolKey).PubKeyID(),
+					Folder:  folder,
+					Issued:  time.Now(),
+					Expires: time.Now().Add(time.Hour),
+				}, a.rp.Keyring().SignWithIdentity)
+				require.Nil(t, err)
+				return cert
+			}
+
+			photosCert := issue("/photos")
+			_, err = c.rp.AddDelegation(photosCert)
+			require.Nil(t, err)
+
+			// Bob does not know carol and does not accept delegations yet:
+			bobFp := buildFingerprint(t, b)
+			_, err = DialByAddr(ctx, bobFp.Addr(), bobFp, c.rp, c.bk, nil)
+			require.NotNil(t, err)
+
+			require.Nil(t, b.rp.Config.SetBool("net.delegation.accept", true))
+			bobCtl, err := DialByAddr(ctx, bobFp.Addr(), bobFp, c.rp, c.bk, nil)
+			require.Nil(t, err)
+			defer bobCtl.Close()
+
+			entries, err := bobCtl.Browse("/")
+			require.Nil(t, err)
+			require.Len(t, entries, 1)
+			require.Equal(t, "/photos", entries[0].Path)
+
+			photo, err := b.fs.Stat("/photos/beach.png")
+			require.Nil(t, err)
+
+			_, err = bobCtl.FetchBlock(ctx, photo.BackendHash)
+			require.Nil(t, err)
+
+			doc, err := b.fs.Stat("/docs/taxes.pdf")
+			require.Nil(t, err)
+
+			_, err = bobCtl.FetchBlock(ctx, doc.BackendHash)
+			require.NotNil(t, err)
+
+			isAllowed, err := bobCtl.IsPushAllowed()
+			require.Nil(t, err)
+			require.False(t, isAllowed)
+
+			// Alice may not pass on what she can not read herself:
+			folders, err := bobCtl.PresentDelegations([][]byte{issue("/docs")})
+			require.Nil(t, err)
+			require.Empty(t, folders)
+
+			// Certificates for somebody else are not accepted either:
+			otherCert, err := repo.SignDelegation(repo.Delegation{
+				Issuer:  "alice",
+				Holder:  "somebody",
+				Folder:  "/photos",
+				Expires: time.Now().Add(time.Hour),
+			}, a.rp.Keyring().SignWithIdentity)
+			require.Nil(t, err)
+
+			folders, err = bobCtl.PresentDelegations([][]byte{otherCert, photosCert})
+			require.Nil(t, err)
+			require.Equal(t, []string{"/photos"}, folders)
+		})
+	})
+}
@@ -0,0 +1,434 @@
+// Package discovery finds other brig daemons on the local network.
+//
+// Every daemon announces itself via multicast DNS (RFC 6762) under the
+// service name "_brig._udp.local.". The announcement contains the owner
+// name, the address and a prefix of the fingerprint, so a user can check it
+// before adding the peer as remote. Since mDNS is not authenticated, the
+// discovered data is only a hint and never trusted without confirmation.
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// ServiceName is the DNS-SD service we announce under.
+	ServiceName = "_brig._udp.local."
+
+	// FingerprintPrefixLen is how many characters of the fingerprint
+	// are announced. The full fingerprint is only exchanged on pairing.
+	FingerprintPrefixLen = 16
+
+	mdnsAddr  = "224.0.0.251:5353"
+	mdnsAddr6 = "[ff02::fb]:5353"
+
+	// Records are only valid a short time, peers come and go quickly.
+	recordTTL = 120
+)
+
+// Info is what a daemon announces about itself.
+type Info struct {
+	// Owner is the name of the repository owner.
+	Owner string
+	// Fingerprint is the (possibly shortened) fingerprint.
+	Fingerprint string
+	// Addr is the backend address of the daemon.
+	Addr string
+}
+
+// Peer is a daemon that answered a discovery query.
+type Peer struct {
+	Info
+
+	// IP is where the answer came from.
+	// If the peer answered over several addresses, this is the first one.
+	IP net.IP
+	// IPs are all addresses the peer answered from (IPv6 and IPv4).
+	IPs []net.IP
+	// LastSeen is the time of the latest answer.
+	LastSeen time.Time
+}
+
+// shortFingerprint cuts the pubkey part of `fingerprint` to
+// FingerprintPrefixLen characters, but keeps the addr intact.
+func shortFingerprint(fingerprint string) string {
+	split := strings.SplitN(fingerprint, ":", 2)
+	if len(split) < 2 || len(split[1]) <= FingerprintPrefixLen {
+		return fingerprint
+	}
+
+	return split[0] + ":" + split[1][:FingerprintPrefixLen]
+}
+
+// instanceName returns a valid DNS label for `info`.
+// Owner names may contain dots and slashes, so we use the address.
+func instanceName(info Info) (dnsmessage.Name, error) {
+	label := info.Addr
+	if len(label) > 63 {
+		label = label[:63]
+	}
+
+	return dnsmessage.NewName(label + "." + ServiceName)
+}
+
+func buildQuery() ([]byte, error) {
+	name, err := dnsmessage.NewName(ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+
+	return msg.Pack()
+}
+
+func buildAnswer(info Info) ([]byte, error) {
+	service, err := dnsmessage.NewName(ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := instanceName(info)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{
+				Name:  service,
+				Type:  dnsmessage.TypePTR,
+				Class: dnsmessage.ClassINET,
+				TTL:   recordTTL,
+			},
+			Body: &dnsmessage.PTRResource{PTR: instance},
+		}, {
+			Header: dnsmessage.ResourceHeader{
+				Name:  instance,
+				Type:  dnsmessage.TypeTXT,
+				Class: dnsmessage.ClassINET,
+				TTL:   recordTTL,
+			},
+			Body: &dnsmessage.TXTResource{TXT: []string{
+				"owner=" + info.Owner,
+				"fp=" + shortFingerprint(info.Fingerprint),
+				"addr=" + info.Addr,
+			}},
+		}},
+	}
+
+	return msg.Pack()
+}
+
+// isQuery checks if `data` asks for our service.
+func isQuery(data []byte) bool {
+	var parser dnsmessage.Parser
+	hdr, err := parser.Start(data)
+	if err != nil || hdr.Response {
+		return false
+	}
+
+	questions, err := parser.AllQuestions()
+	if err != nil {
+		return false
+	}
+
+	for _, question := range questions {
+		if question.Type != dnsmessage.TypePTR && question.Type != dnsmessage.TypeALL {
+			continue
+		}
+
+		if strings.EqualFold(question.Name.String(), ServiceName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAnswer extracts the announced info from an answer.
+// It returns false if `data` does not stem from a brig daemon.
+func parseAnswer(data []byte) (Info, bool) {
+	info := Info{}
+
+	var parser dnsmessage.Parser
+	hdr, err := parser.Start(data)
+	if err != nil || !hdr.Response {
+		return info, false
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return info, false
+	}
+
+	for {
+		rh, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+
+		if err != nil {
+			return info, false
+		}
+
+		if rh.Type != dnsmessage.TypeTXT || !strings.HasSuffix(rh.Name.String(), "."+ServiceName) {
+			if err := parser.SkipAnswer(); err != nil {
+				return info, false
+			}
+
+			continue
+		}
+
+		txt, err := parser.TXTResource()
+		if err != nil {
+			return info, false
+		}
+
+		for _, field := range txt.TXT {
+			split := strings.SplitN(field, "=", 2)
+			if len(split) < 2 {
+				continue
+			}
+
+			switch split[0] {
+			case "owner":
+				info.Owner = split[1]
+			case "fp":
+				info.Fingerprint = split[1]
+			case "addr":
+				info.Addr = split[1]
+			}
+		}
+	}
+
+	return info, info.Addr != ""
+}
+
+// mdnsFamilies are the networks and groups we use for mDNS.
+// Each of them is optional, so we also work on IPv6-only (or IPv4-only) networks.
+var mdnsFamilies = []struct {
+	network string
+	group   string
+}{
+	{"udp4", mdnsAddr},
+	{"udp6", mdnsAddr6},
+}
+
+// Announcer answers discovery queries on the local network.
+type Announcer struct {
+	info   Info
+	conns  []*net.UDPConn
+	answer []byte
+}
+
+// NewAnnouncer starts answering queries for `info` in the background.
+// It listens for IPv4 and IPv6 queries, as far as the host supports them.
+func NewAnnouncer(info Info) (*Announcer, error) {
+	answer, err := buildAnswer(info)
+	if err != nil {
+		return nil, err
+	}
+
+	ancr := &Announcer{info: info, answer: answer}
+	var joinErr error
+
+	for _, family := range mdnsFamilies {
+		group, err := net.ResolveUDPAddr(family.network, family.group)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := net.ListenMulticastUDP(family.network, nil, group)
+		if err != nil {
+			log.Debugf("discovery: failed to join %s: %v", family.group, err)
+			joinErr = err
+			continue
+		}
+
+		ancr.conns = append(ancr.conns, conn)
+	}
+
+	if len(ancr.conns) == 0 {
+		return nil, e.Wrap(joinErr, "failed to join mdns group")
+	}
+
+	for _, conn := range ancr.conns {
+		go ancr.serve(conn)
+	}
+
+	return ancr, nil
+}
+
+func (ancr *Announcer) serve(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// Happens on Close(), no need to complain.
+			return
+		}
+
+		if !isQuery(buf[:n]) {
+			continue
+		}
+
+		// Answer directly to the querier, so it does not need
+		// to listen on the mdns port (which might be taken):
+		if _, err := conn.WriteToUDP(ancr.answer, from); err != nil {
+			log.Debugf("discovery: failed to answer %s: %v", from, err)
+		}
+	}
+}
+
+// Close stops answering queries.
+func (ancr *Announcer) Close() error {
+	var closeErr error
+	for _, conn := range ancr.conns {
+		if err := conn.Close(); err != nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+type answer struct {
+	info Info
+	ip   net.IP
+}
+
+// query sends a query to `group` and passes all answers to `answerCh`
+// until `ctx` is done.
+func query(ctx context.Context, network, group string, answerCh chan<- answer) error {
+	groupAddr, err := net.ResolveUDPAddr(network, group)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	msg, err := buildQuery()
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.WriteToUDP(msg, groupAddr); err != nil {
+		return e.Wrap(err, "failed to send mdns query")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				return nil
+			}
+
+			return err
+		}
+
+		if info, ok := parseAnswer(buf[:n]); ok {
+			answerCh <- answer{info: info, ip: from.IP}
+		}
+	}
+}
+
+// addIP adds `ip` to the addresses of `peer`, unless it is known already.
+func addIP(peer *Peer, ip net.IP) {
+	for _, known := range peer.IPs {
+		if known.Equal(ip) {
+			return
+		}
+	}
+
+	peer.IPs = append(peer.IPs, ip)
+	if peer.IP == nil {
+		peer.IP = ip
+	}
+}
+
+// Browse sends a query to the local network and collects all answers
+// until `ctx` is done. Queries go out over IPv4 and IPv6; it is only an
+// error if neither works. Peers are sorted by owner name.
+func Browse(ctx context.Context) ([]Peer, error) {
+	answerCh := make(chan answer)
+	errCh := make(chan error, len(mdnsFamilies))
+	for _, family := range mdnsFamilies {
+		go func(network, group string) {
+			errCh <- query(ctx, network, group, answerCh)
+		}(family.network, family.group)
+	}
+
+	peers := make(map[string]*Peer)
+	errs := []error{}
+	for done := 0; done < len(mdnsFamilies); {
+		select {
+		case ans := <-answerCh:
+			peer, ok := peers[ans.info.Addr]
+			if !ok {
+				peer = &Peer{}
+				peers[ans.info.Addr] = peer
+			}
+
+			peer.Info = ans.info
+			peer.LastSeen = time.Now()
+			addIP(peer, ans.ip)
+		case err := <-errCh:
+			done++
+			if err != nil {
+				log.Debugf("discovery: query failed: %v", err)
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) == len(mdnsFamilies) {
+		return nil, errs[0]
+	}
+
+	result := []Peer{}
+	for _, peer := range peers {
+		result = append(result, *peer)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+
+		return result[i].Addr < result[j].Addr
+	})
+
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAnswerRoundtrip(t *testing.T) {
+	query, err := buildQuery()
+	require.Nil(t, err)
+	require.True(t, isQuery(query))
+
+	info := Info{
+		Owner:       "alice@wonderland.org/laptop",
+		Fingerprint: "QmVA5j2JHPkDTHgZ:SEfXUDeJA1toVnPxxxxxxxxxxxxxxxxxx",
+		Addr:        "QmVA5j2JHPkDTHgZ",
+	}
+
+	answer, err := buildAnswer(info)
+	require.Nil(t, err)
+	require.False(t, isQuery(answer))
+
+	parsed, ok := parseAnswer(answer)
+	require.True(t, ok)
+	require.Equal(t, info.Owner, parsed.Owner)
+	require.Equal(t, info.Addr, parsed.Addr)
+	require.Equal(t, "QmVA5j2JHPkDTHgZ:SEfXUDeJA1toVnPx", parsed.Fingerprint)
+
+	_, ok = parseAnswer(query)
+	require.False(t, ok)
+
+	_, ok = parseAnswer([]byte("garbage"))
+	require.False(t, ok)
+}
+
+func TestAddIP(t *testing.T) {
+	peer := &Peer{}
+	addIP(peer, net.ParseIP("fe80::1"))
+	addIP(peer, net.ParseIP("192.168.1.2"))
+	addIP(peer, net.ParseIP("fe80::1"))
+
+	require.Equal(t, net.ParseIP("fe80::1"), peer.IP)
+	require.Len(t, peer.IPs, 2)
+}
@@ -0,0 +1,458 @@
+// Package fetch implements fetching content from several peers in parallel.
+//
+// Content in the backend is stored as a tree of blocks. When several peers
+// have the same content, different blocks are requested from different
+// peers at the same time. Peers pull work from a shared queue, so faster
+// peers naturally end up doing more. When the queue runs dry, idle peers
+// that proved to be faster also request blocks that are still in flight
+// at slower peers ("endgame"), so one slow peer can not hold up the rest.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoPeers is returned when blocks are missing but no peer is left.
+	ErrNoPeers = errors.New("no peer left to fetch blocks from")
+)
+
+// Store is where fetched blocks are put (usually the local backend).
+type Store interface {
+	// Has tells if the block `hash` is available locally.
+	Has(hash h.Hash) (bool, error)
+
+	// Links returns the hashes of the blocks the local block `hash` links to.
+	Links(hash h.Hash) ([]h.Hash, error)
+
+	// Put stores a single block and returns the hash it is stored under.
+	Put(data []byte) (h.Hash, error)
+}
+
+// Peer is a source of blocks.
+type Peer interface {
+	// Name identifies the peer in statistics and logs.
+	Name() string
+
+	// FetchBlock returns the data of the block `hash`.
+	FetchBlock(ctx context.Context, hash h.Hash) ([]byte, error)
+}
+
+// Options change how blocks are fetched.
+type Options struct {
+	// InFlight is the number of requests sent to a single peer at once.
+	InFlight int
+
+	// MaxFailures is the number of failed requests in a row
+	// after which a peer is not asked anymore.
+	MaxFailures int
+}
+
+// DefaultOptions are used for zero values in Options.
+var DefaultOptions = Options{
+	InFlight:    4,
+	MaxFailures: 3,
+}
+
+// PeerStats tells how much a single peer contributed.
+type PeerStats struct {
+	Name     string
+	Blocks   int
+	Bytes    int64
+	Failures int
+
+	// Throughput is the measured speed in bytes per second.
+	Throughput float64
+}
+
+// Stats is returned by Fetch.
+type Stats struct {
+	// Blocks and Bytes count what was fetched (not what was there already).
+	Blocks int
+	Bytes  int64
+	Took   time.Duration
+	Peers  []PeerStats
+}
+
+// weight of the last measurement in the throughput average.
+const throughputAlpha = 0.3
+
+type peerState struct {
+	peer        Peer
+	stats       PeerStats
+	failsInARow int
+	disabled    bool
+}
+
+func (ps *peerState) measure(size int, took time.Duration) {
+	if took <= 0 {
+		took = time.Microsecond
+	}
+
+	speed := float64(size) / took.Seconds()
+	if ps.stats.Throughput == 0 {
+		ps.stats.Throughput = speed
+		return
+	}
+
+	ps.stats.Throughput = throughputAlpha*speed + (1-throughputAlpha)*ps.stats.Throughput
+}
+
+type request struct {
+	started time.Time
+	owners  map[*peerState]bool
+}
+
+type fetcher struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	store Store
+	opts  Options
+	peers []*peerState
+
+	queue    []h.Hash
+	queued   map[string]bool
+	inFlight map[string]*request
+	done     map[string]bool
+	failedOn map[string]map[*peerState]bool
+
+	err   error
+	stats Stats
+}
+
+// Fetch makes sure all blocks of `roots` are in `store`,
+// fetching the missing ones from `peers` in parallel.
+func Fetch(ctx context.Context, store Store, peers []Peer, roots []h.Hash, opts Options) (*Stats, error) {
+	if opts.InFlight <= 0 {
+		opts.InFlight = DefaultOptions.InFlight
+	}
+
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = DefaultOptions.MaxFailures
+	}
+
+	ft := &fetcher{
+		store:    store,
+		opts:     opts,
+		queued:   make(map[string]bool),
+		inFlight: make(map[string]*request),
+		done:     make(map[string]bool),
+		failedOn: make(map[string]map[*peerState]bool),
+	}
+
+	ft.cond = sync.NewCond(&ft.mu)
+	for _, peer := range peers {
+		ft.peers = append(ft.peers, &peerState{
+			peer:  peer,
+			stats: PeerStats{Name: peer.Name()},
+		})
+	}
+
+	start := time.Now()
+
+	ft.mu.Lock()
+	for _, root := range roots {
+		if err := ft.add(root); err != nil {
+			ft.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	if len(ft.queue) > 0 && len(ft.peers) == 0 {
+		ft.mu.Unlock()
+		return nil, ErrNoPeers
+	}
+	ft.mu.Unlock()
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Wake up the workers when the context is done:
+	go func() {
+		<-subCtx.Done()
+		ft.mu.Lock()
+		ft.cond.Broadcast()
+		ft.mu.Unlock()
+	}()
+
+	wg := &sync.WaitGroup{}
+	for _, ps := range ft.peers {
+		for idx := 0; idx < opts.InFlight; idx++ {
+			wg.Add(1)
+			go func(ps *peerState) {
+				defer wg.Done()
+				ft.work(subCtx, ps)
+			}(ps)
+		}
+	}
+
+	wg.Wait()
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	ft.stats.Took = time.Since(start)
+	for _, ps := range ft.peers {
+		ft.stats.Peers = append(ft.stats.Peers, ps.stats)
+	}
+
+	sort.Slice(ft.stats.Peers, func(i, j int) bool {
+		return ft.stats.Peers[i].Bytes > ft.stats.Peers[j].Bytes
+	})
+
+	if ft.err == nil && ctx.Err() != nil {
+		ft.err = ctx.Err()
+	}
+
+	return &ft.stats, ft.err
+}
+
+// add queues `hash` if it is missing. If it is there already,
+// its children are checked instead. Must be called with ft.mu held.
+func (ft *fetcher) add(hash h.Hash) error {
+	b58 := hash.B58String()
+	if ft.done[b58] || ft.queued[b58] || ft.inFlight[b58] != nil {
+		return nil
+	}
+
+	has, err := ft.store.Has(hash)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		ft.queued[b58] = true
+		ft.queue = append(ft.queue, hash)
+		return nil
+	}
+
+	ft.done[b58] = true
+	return ft.addLinks(hash)
+}
+
+func (ft *fetcher) addLinks(hash h.Hash) error {
+	links, err := ft.store.Links(hash)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		if err := ft.add(link); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finished tells if there is nothing left to do (or we gave up).
+func (ft *fetcher) finished() bool {
+	return ft.err != nil || (len(ft.queue) == 0 && len(ft.inFlight) == 0)
+}
+
+// next picks the next block for `ps`. Must be called with ft.mu held.
+func (ft *fetcher) next(ps *peerState) h.Hash {
+	for idx, hash := range ft.queue {
+		if ft.failedOn[hash.B58String()][ps] {
+			continue
+		}
+
+		ft.queue = append(ft.queue[:idx], ft.queue[idx+1:]...)
+		delete(ft.queued, hash.B58String())
+		ft.inFlight[hash.B58String()] = &request{
+			started: time.Now(),
+			owners:  map[*peerState]bool{ps: true},
+		}
+
+		return hash
+	}
+
+	if len(ft.queue) > 0 || ps.stats.Throughput == 0 {
+		// Do not race others before we know how fast we are.
+		return nil
+	}
+
+	// Endgame: help out with the oldest block that only slower peers work on.
+	var best h.Hash
+	var bestStart time.Time
+	for b58, req := range ft.inFlight {
+		if len(req.owners) > 1 || req.owners[ps] || ft.failedOn[b58][ps] {
+			continue
+		}
+
+		isFaster := true
+		for owner := range req.owners {
+			if owner.stats.Throughput >= ps.stats.Throughput {
+				isFaster = false
+			}
+		}
+
+		if !isFaster {
+			continue
+		}
+
+		if best == nil || req.started.Before(bestStart) {
+			hash, err := h.FromB58String(b58)
+			if err != nil {
+				continue
+			}
+
+			best, bestStart = hash, req.started
+		}
+	}
+
+	if best != nil {
+		ft.inFlight[best.B58String()].owners[ps] = true
+	}
+
+	return best
+}
+
+func (ft *fetcher) work(ctx context.Context, ps *peerState) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	for {
+		var hash h.Hash
+		for {
+			if ft.finished() || ps.disabled || ctx.Err() != nil {
+				return
+			}
+
+			if hash = ft.next(ps); hash != nil {
+				break
+			}
+
+			if ft.stuck() {
+				ft.err = ErrNoPeers
+				ft.cond.Broadcast()
+				return
+			}
+
+			ft.cond.Wait()
+		}
+
+		ft.mu.Unlock()
+		start := time.Now()
+		data, err := ps.peer.FetchBlock(ctx, hash)
+		took := time.Since(start)
+		if err == nil {
+			err = ft.put(hash, data)
+		}
+		ft.mu.Lock()
+
+		ft.handleResult(ps, hash, data, took, err)
+		ft.cond.Broadcast()
+	}
+}
+
+// put stores `data` and checks that it really is the block we asked for.
+func (ft *fetcher) put(hash h.Hash, data []byte) error {
+	stored, err := ft.store.Put(data)
+	if err != nil {
+		return err
+	}
+
+	if !stored.Equal(hash) {
+		return fmt.Errorf("got block %s instead of %s", stored.B58String(), hash.B58String())
+	}
+
+	return nil
+}
+
+// handleResult updates the state after a request. Must be called with ft.mu held.
+func (ft *fetcher) handleResult(ps *peerState, hash h.Hash, data []byte, took time.Duration, err error) {
+	b58 := hash.B58String()
+	req := ft.inFlight[b58]
+
+	if err != nil {
+		ps.stats.Failures++
+		ps.failsInARow++
+		if ps.failsInARow >= ft.opts.MaxFailures {
+			log.Warningf("fetch: giving up on %s after %d failures: %v", ps.stats.Name, ps.failsInARow, err)
+			ps.disabled = true
+		} else {
+			log.Debugf("fetch: %s failed to deliver %s: %v", ps.stats.Name, b58, err)
+		}
+
+		if ft.failedOn[b58] == nil {
+			ft.failedOn[b58] = make(map[*peerState]bool)
+		}
+
+		ft.failedOn[b58][ps] = true
+
+		if req == nil {
+			// Somebody else delivered it in the meantime.
+			return
+		}
+
+		delete(req.owners, ps)
+		if len(req.owners) > 0 {
+			return
+		}
+
+		delete(ft.inFlight, b58)
+		if !ft.canFetch(b58) {
+			ft.err = fmt.Errorf("no peer could deliver block %s: %v", b58, err)
+			return
+		}
+
+		ft.queued[b58] = true
+		ft.queue = append(ft.queue, hash)
+		return
+	}
+
+	ps.failsInARow = 0
+	if req == nil || ft.done[b58] {
+		// Another peer was faster in the endgame.
+		return
+	}
+
+	delete(ft.inFlight, b58)
+	ft.done[b58] = true
+
+	ps.measure(len(data), took)
+	ps.stats.Blocks++
+	ps.stats.Bytes += int64(len(data))
+	ft.stats.Blocks++
+	ft.stats.Bytes += int64(len(data))
+
+	if err := ft.addLinks(hash); err != nil {
+		ft.err = err
+	}
+}
+
+// stuck tells if blocks are missing that nobody will fetch anymore.
+func (ft *fetcher) stuck() bool {
+	if len(ft.inFlight) > 0 {
+		return false
+	}
+
+	for _, hash := range ft.queue {
+		if ft.canFetch(hash.B58String()) {
+			return false
+		}
+	}
+
+	return len(ft.queue) > 0
+}
+
+// canFetch tells if any usable peer did not fail on `b58` yet.
+func (ft *fetcher) canFetch(b58 string) bool {
+	for _, ps := range ft.peers {
+		if !ps.disabled && !ft.failedOn[b58][ps] {
+			return true
+		}
+	}
+
+	return false
+}
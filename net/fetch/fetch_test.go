@@ -0,0 +1,181 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is a store where a block is its links (one hash per line)
+// followed by some payload, so blocks can be built without a real dag.
+type memStore struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		blocks: make(map[string][]byte),
+	}
+}
+
+func (ms *memStore) Has(hash h.Hash) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, ok := ms.blocks[hash.B58String()]
+	return ok, nil
+}
+
+func (ms *memStore) Links(hash h.Hash) ([]h.Hash, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	data, ok := ms.blocks[hash.B58String()]
+	if !ok {
+		return nil, fmt.Errorf("no such block: %s", hash.B58String())
+	}
+
+	links := []h.Hash{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if link, err := h.FromB58String(string(line)); err == nil {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+func (ms *memStore) Put(data []byte) (h.Hash, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	hash := h.SumWithBackendHash(data)
+	ms.blocks[hash.B58String()] = data
+	return hash, nil
+}
+
+// buildTree creates a root with `leaves` children in `ms` and returns the root.
+func buildTree(t *testing.T, ms *memStore, leaves int) h.Hash {
+	rootData := &bytes.Buffer{}
+	for idx := 0; idx < leaves; idx++ {
+		leaf, err := ms.Put([]byte(fmt.Sprintf("leaf-%d", idx)))
+		require.Nil(t, err)
+		rootData.WriteString(leaf.B58String() + "\n")
+	}
+
+	rootData.WriteString("payload")
+	root, err := ms.Put(rootData.Bytes())
+	require.Nil(t, err)
+	return root
+}
+
+type testPeer struct {
+	name  string
+	src   *memStore
+	delay time.Duration
+	fail  bool
+	lie   bool
+}
+
+func (tp *testPeer) Name() string {
+	return tp.name
+}
+
+func (tp *testPeer) FetchBlock(ctx context.Context, hash h.Hash) ([]byte, error) {
+	select {
+	case <-time.After(tp.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if tp.fail {
+		return nil, errors.New("peer is broken")
+	}
+
+	if tp.lie {
+		return []byte("garbage"), nil
+	}
+
+	tp.src.mu.Lock()
+	defer tp.src.mu.Unlock()
+
+	data, ok := tp.src.blocks[hash.B58String()]
+	if !ok {
+		return nil, fmt.Errorf("do not have %s", hash.B58String())
+	}
+
+	return data, nil
+}
+
+func peerStats(stats *Stats, name string) PeerStats {
+	for _, ps := range stats.Peers {
+		if ps.Name == name {
+			return ps
+		}
+	}
+
+	return PeerStats{}
+}
+
+func TestFetchFromSeveralPeers(t *testing.T) {
+	src := newMemStore()
+	root := buildTree(t, src, 60)
+
+	fast := &testPeer{name: "fast", src: src, delay: time.Millisecond}
+	slow := &testPeer{name: "slow", src: src, delay: 20 * time.Millisecond}
+
+	dst := newMemStore()
+	stats, err := Fetch(context.Background(), dst, []Peer{fast, slow}, []h.Hash{root}, Options{InFlight: 2})
+	require.Nil(t, err)
+	require.Equal(t, 61, stats.Blocks)
+	require.Len(t, dst.blocks, 61)
+
+	// The faster peer should have done most of the work:
+	require.True(t, peerStats(stats, "fast").Blocks > peerStats(stats, "slow").Blocks)
+
+	// Fetching again should not transfer anything:
+	stats, err = Fetch(context.Background(), dst, []Peer{fast, slow}, []h.Hash{root}, Options{})
+	require.Nil(t, err)
+	require.Equal(t, 0, stats.Blocks)
+}
+
+func TestFetchSkipsBadPeers(t *testing.T) {
+	src := newMemStore()
+	root := buildTree(t, src, 20)
+
+	good := &testPeer{name: "good", src: src}
+	broken := &testPeer{name: "broken", src: src, fail: true}
+	liar := &testPeer{name: "liar", src: src, lie: true}
+
+	dst := newMemStore()
+	stats, err := Fetch(context.Background(), dst, []Peer{broken, liar, good}, []h.Hash{root}, Options{})
+	require.Nil(t, err)
+	require.Equal(t, 21, peerStats(stats, "good").Blocks)
+	require.Equal(t, 0, peerStats(stats, "broken").Blocks)
+	require.Equal(t, 0, peerStats(stats, "liar").Blocks)
+
+	for b58 := range src.blocks {
+		_, ok := dst.blocks[b58]
+		require.True(t, ok)
+	}
+}
+
+func TestFetchNoUsablePeers(t *testing.T) {
+	src := newMemStore()
+	root := buildTree(t, src, 5)
+
+	broken := &testPeer{name: "broken", src: src, fail: true}
+	_, err := Fetch(context.Background(), newMemStore(), []Peer{broken}, []h.Hash{root}, Options{})
+	require.NotNil(t, err)
+
+	_, err = Fetch(context.Background(), newMemStore(), nil, []h.Hash{root}, Options{})
+	require.Equal(t, ErrNoPeers, err)
+}
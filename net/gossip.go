@@ -0,0 +1,234 @@
+package net
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	e "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Gossip passes head records of remotes on to other remotes. A device that
+// is always online learns the head of a device that is rarely online and
+// tells the others, even if those two are never online at the same time.
+// Records are signed by their owner, so relays can not forge them; they
+// are only accepted if we have the owner as remote ourselves. Every relay
+// lowers the TTL of a record by one and records with no TTL left are kept,
+// but not passed on anymore.
+
+const (
+	// maxGossipEntries limits how many records one exchange may carry.
+	maxGossipEntries = 1000
+)
+
+// GossipEntry is a signed head record and how often it may still be passed on.
+type GossipEntry struct {
+	Record []byte `json:"record"`
+	TTL    int    `json:"ttl"`
+}
+
+// GossipNews is reported when gossip told us about a newer head.
+type GossipNews struct {
+	HeadRecord
+
+	// Via is the remote that told us. It is the owner itself
+	// if the record was not relayed.
+	Via string
+}
+
+type gossipItem struct {
+	rec HeadRecord
+	raw []byte
+	ttl int
+	via string
+}
+
+// Gossip remembers the newest head record of every remote.
+// It is safe to use from several goroutines.
+type Gossip struct {
+	mu     sync.Mutex
+	items  map[string]gossipItem
+	pubKey func(owner string) ([]byte, error)
+	onNews func(news GossipNews)
+}
+
+// NewGossip returns an empty Gossip. `pubKey` returns the key records of
+// `owner` have to be signed with; owners without a key are ignored.
+func NewGossip(pubKey func(owner string) ([]byte, error)) *Gossip {
+	return &Gossip{
+		items:  make(map[string]gossipItem),
+		pubKey: pubKey,
+	}
+}
+
+// SetOnNews sets a function that is called for every record
+// that is newer than what we knew about its owner.
+func (g *Gossip) SetOnNews(fn func(news GossipNews)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.onNews = fn
+}
+
+func isNewerHead(rec, than HeadRecord) bool {
+	if rec.Index != than.Index {
+		return rec.Index > than.Index
+	}
+
+	return rec.Published.After(than.Published)
+}
+
+// peekHeadRecordOwner returns who a signed record claims to be from.
+// The claim is checked by VerifyHeadRecord afterwards.
+func peekHeadRecordOwner(data []byte) (string, error) {
+	if len(data) > maxHeadRecordSize {
+		return "", e.Errorf("head record is too big (%d bytes)", len(data))
+	}
+
+	signed := signedHeadRecord{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return "", e.Wrap(err, "bad head record")
+	}
+
+	rec := HeadRecord{}
+	if err := json.Unmarshal(signed.Payload, &rec); err != nil {
+		return "", e.Wrap(err, "bad head record payload")
+	}
+
+	return rec.Owner, nil
+}
+
+// AddOwn remembers our own signed head `data`, which is passed on `ttl` times.
+func (g *Gossip) AddOwn(owner string, data []byte, ttl int) error {
+	rec := HeadRecord{}
+	signed := signedHeadRecord{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(signed.Payload, &rec); err != nil {
+		return err
+	}
+
+	if rec.Owner != owner {
+		return e.Errorf("head record is from `%s`, not `%s`", rec.Owner, owner)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.items[owner] = gossipItem{rec: rec, raw: data, ttl: ttl, via: owner}
+	return nil
+}
+
+// Offer hands the entries `via` sent us to the gossip. TTLs above `maxTTL`
+// are lowered to it. It returns the records that were news to us.
+func (g *Gossip) Offer(entries []GossipEntry, via string, maxTTL int) []GossipNews {
+	if len(entries) > maxGossipEntries {
+		entries = entries[:maxGossipEntries]
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	news := []GossipNews{}
+	for _, entry := range entries {
+		owner, err := peekHeadRecordOwner(entry.Record)
+		if err != nil {
+			log.Debugf("gossip: dropping record from %s: %v", via, err)
+			continue
+		}
+
+		pubKey, err := g.pubKey(owner)
+		if err != nil {
+			// Not a remote of ours; we can not check it.
+			continue
+		}
+
+		rec, err := VerifyHeadRecord(entry.Record, owner, pubKey)
+		if err != nil {
+			log.Warningf("gossip: %s sent a bad record of %s: %v", via, owner, err)
+			continue
+		}
+
+		if known, ok := g.items[owner]; ok && !isNewerHead(*rec, known.rec) {
+			continue
+		}
+
+		ttl := entry.TTL
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+
+		g.items[owner] = gossipItem{rec: *rec, raw: entry.Record, ttl: ttl - 1, via: via}
+		news = append(news, GossipNews{HeadRecord: *rec, Via: via})
+	}
+
+	if g.onNews != nil {
+		for _, item := range news {
+			go g.onNews(item)
+		}
+	}
+
+	return news
+}
+
+// Entries returns the records that should be sent to `to`.
+// Records of `to` itself are left out, since they know better.
+func (g *Gossip) Entries(to string) []GossipEntry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	owners := []string{}
+	for owner := range g.items {
+		owners = append(owners, owner)
+	}
+
+	sort.Strings(owners)
+
+	entries := []GossipEntry{}
+	for _, owner := range owners {
+		item := g.items[owner]
+		if owner == to || item.ttl <= 0 {
+			continue
+		}
+
+		entries = append(entries, GossipEntry{Record: item.raw, TTL: item.ttl})
+	}
+
+	return entries
+}
+
+// Head returns the newest record we know of `owner` and who told us.
+func (g *Gossip) Head(owner string) (*HeadRecord, string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	item, ok := g.items[owner]
+	if !ok {
+		return nil, "", false
+	}
+
+	rec := item.rec
+	return &rec, item.via, true
+}
+
+// EncodeGossip encodes `entries` for sending them over the network.
+func EncodeGossip(entries []GossipEntry) ([]byte, error) {
+	return json.Marshal(entries)
+}
+
+// DecodeGossip is the inverse of EncodeGossip.
+func DecodeGossip(data []byte) ([]GossipEntry, error) {
+	entries := []GossipEntry{}
+	if len(data) == 0 {
+		return entries, nil
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, e.Wrap(err, "bad gossip")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,99 @@
+package net
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGossip(t *testing.T) {
+	alice, alicePubKey := newTestEntity(t, "alice")
+	mallory, _ := newTestEntity(t, "mallory")
+
+	keys := map[string][]byte{"alice": alicePubKey}
+	newGossip := func() *Gossip {
+		return NewGossip(func(owner string) ([]byte, error) {
+			if key, ok := keys[owner]; ok {
+				return key, nil
+			}
+
+			return nil, errors.New("no such remote")
+		})
+	}
+
+	sign := func(index int64) []byte {
+		data, err := SignHeadRecord(HeadRecord{
+			Owner:     "alice",
+			Index:     index,
+			Hash:      "W1abc",
+			Published: time.Now(),
+		}, signerFor(alice))
+		require.Nil(t, err)
+		return data
+	}
+
+	// alice -> charlie -> bob -> dave:
+	charlie := newGossip()
+	news := charlie.Offer([]GossipEntry{{Record: sign(2), TTL: 10}}, "alice", 2)
+	require.Len(t, news, 1)
+	require.Equal(t, int64(2), news[0].Index)
+	require.Equal(t, "alice", news[0].Via)
+
+	// The ttl was capped and lowered by one:
+	entries := charlie.Entries("bob")
+	require.Len(t, entries, 1)
+	require.Equal(t, 1, entries[0].TTL)
+
+	// alice knows her own head best:
+	require.Len(t, charlie.Entries("alice"), 0)
+
+	bob := newGossip()
+	news = bob.Offer(entries, "charlie", 2)
+	require.Len(t, news, 1)
+	require.Equal(t, "charlie", news[0].Via)
+
+	rec, via, ok := bob.Head("alice")
+	require.True(t, ok)
+	require.Equal(t, int64(2), rec.Index)
+	require.Equal(t, "charlie", via)
+
+	// No ttl left; bob keeps it, but does not pass it on:
+	require.Len(t, bob.Entries("dave"), 0)
+
+	// Old news are no news:
+	require.Len(t, bob.Offer([]GossipEntry{{Record: sign(1), TTL: 2}}, "charlie", 2), 0)
+	require.Len(t, bob.Offer(entries, "charlie", 2), 0)
+
+	// Forged records and records of strangers are dropped:
+	forged, err := SignHeadRecord(HeadRecord{Owner: "alice", Index: 5}, signerFor(mallory))
+	require.Nil(t, err)
+	stranger, err := SignHeadRecord(HeadRecord{Owner: "mallory", Index: 5}, signerFor(mallory))
+	require.Nil(t, err)
+
+	news = bob.Offer([]GossipEntry{
+		{Record: forged, TTL: 2},
+		{Record: stranger, TTL: 2},
+		{Record: []byte("garbage"), TTL: 2},
+	}, "charlie", 2)
+	require.Len(t, news, 0)
+
+	rec, _, _ = bob.Head("alice")
+	require.Equal(t, int64(2), rec.Index)
+
+	// Own records are passed on with the full ttl:
+	own := newGossip()
+	require.Nil(t, own.AddOwn("alice", sign(3), 2))
+	require.NotNil(t, own.AddOwn("bob", sign(3), 2))
+
+	entries = own.Entries("bob")
+	require.Len(t, entries, 1)
+	require.Equal(t, 2, entries[0].TTL)
+
+	data, err := EncodeGossip(entries)
+	require.Nil(t, err)
+	decoded, err := DecodeGossip(data)
+	require.Nil(t, err)
+	require.Equal(t, entries, decoded)
+}
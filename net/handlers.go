@@ -3,22 +3,40 @@ package net
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/sahib/brig/backend"
+	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/gateway/remotesapi"
 	"github.com/sahib/brig/net/capnp"
 	"github.com/sahib/brig/repo"
+	h "github.com/sahib/brig/util/hashlib"
 	log "github.com/sirupsen/logrus"
 )
 
+// maxIdentityMigrations limits how many renames one call may carry.
+const maxIdentityMigrations = 100
+
 type requestHandler struct {
 	bk             backend.Backend
 	rp             *repo.Repository
 	ctx            context.Context
 	rapi           remotesapi.RemotesAPI
+	gossip         *Gossip
 	currRemoteName string
+	onBlockServed  func(remote string, size int)
+
+	// The key id of the peer and the name it claims to have.
+	// The name is only used for peers that are no remote of ours.
+	peerKeyID    string
+	delegateName string
+
+	dgMu            sync.Mutex
+	delegations     []*repo.Delegation
+	delegatedBlocks map[string]bool
 }
 
 func completeExportAllowed(folders []repo.Folder) bool {
@@ -38,13 +56,13 @@ func completeExportAllowed(folders []repo.Folder) bool {
 func (hdl *requestHandler) FetchStore(call capnp.Sync_fetchStore) error {
 	// We should only export our complete metadata, when the root directory
 	// was enabled or no folders were configured.
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	folders, err := hdl.readFolders()
 	if err != nil {
 		return err
 	}
 
-	if !completeExportAllowed(currRemote.Folders) {
-		log.Warningf("Attempt to read complete store from `%v`", hdl.currRemoteName)
+	if !completeExportAllowed(folders) {
+		log.Warningf("Attempt to read complete store from `%v`", hdl.peerName())
 		return errors.New("refusing export")
 	}
 
@@ -62,7 +80,7 @@ func (hdl *requestHandler) FetchStore(call capnp.Sync_fetchStore) error {
 }
 
 func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	folders, err := hdl.readFolders()
 	if err != nil {
 		return err
 	}
@@ -74,7 +92,7 @@ func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
 
 	// Apply the respective folder filter for this remote.
 	prefixes := []string{}
-	for _, folder := range currRemote.Folders {
+	for _, folder := range folders {
 		prefixes = append(prefixes, folder.Folder)
 	}
 
@@ -82,7 +100,7 @@ func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
 	fromRev := fmt.Sprintf("commit[%d]", fromIndex)
 
 	log.Debugf("Bundling up all changes starting from: %s", fromRev)
-	patchData, err := fs.MakePatch(fromRev, prefixes, currRemote.Name)
+	patchData, err := fs.MakePatch(fromRev, prefixes, hdl.peerName())
 	if err != nil {
 		return err
 	}
@@ -92,12 +110,12 @@ func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
 }
 
 func (hdl *requestHandler) IsCompleteFetchAllowed(call capnp.Sync_isCompleteFetchAllowed) error {
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	folders, err := hdl.readFolders()
 	if err != nil {
 		return err
 	}
 
-	isAllowed := completeExportAllowed(currRemote.Folders)
+	isAllowed := completeExportAllowed(folders)
 	call.Results.SetIsAllowed(isAllowed)
 	return nil
 }
@@ -112,6 +130,11 @@ func (hdl *requestHandler) Version(call capnp.API_version) error {
 }
 
 func (hdl *requestHandler) IsPushAllowed(call capnp.Sync_isPushAllowed) error {
+	if hdl.isDelegate() {
+		call.Results.SetIsAllowed(false)
+		return nil
+	}
+
 	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
 	if err != nil {
 		return err
@@ -124,6 +147,10 @@ func (hdl *requestHandler) IsPushAllowed(call capnp.Sync_isPushAllowed) error {
 func (hdl *requestHandler) Push(call capnp.Sync_push) error {
 	// NOTE: You might be confused by the name "Push".
 	// This is the RECEIVING side of the push.
+	if hdl.isDelegate() {
+		return fmt.Errorf("pushing is not allowed for you")
+	}
+
 	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
 	if err != nil {
 		return err
@@ -134,5 +161,176 @@ func (hdl *requestHandler) Push(call capnp.Sync_push) error {
 	}
 
 	log.Infof("Syncing with »%s« because he asked us to via a push.", currRemote.Name)
-	return hdl.rapi.Sync(currRemote.Name)
+	err = hdl.rapi.TrySync(currRemote.Name)
+	if err == remotesapi.ErrSyncInProgress {
+		// Both sides syncing at the same time would produce two merge commits.
+		log.Infof("Declining push of »%s«: already syncing with them.", currRemote.Name)
+	}
+
+	return err
+}
+
+func (hdl *requestHandler) FetchBlock(call capnp.Sync_fetchBlock) error {
+	bbk, ok := hdl.bk.(catfs.BlockBackend)
+	if !ok {
+		return errors.New("backend does not support fetching blocks")
+	}
+
+	rawHash, err := call.Params.Hash()
+	if err != nil {
+		return err
+	}
+
+	hash, err := h.Cast(rawHash)
+	if err != nil {
+		return err
+	}
+
+	// Only hand out what we have; we should not fetch things on their behalf.
+	has, err := bbk.HasBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return fmt.Errorf("block %s is not stored here", hash.B58String())
+	}
+
+	if hdl.isDelegate() {
+		isDelegated, err := hdl.isDelegatedBlock(bbk, hash)
+		if err != nil {
+			return err
+		}
+
+		if !isDelegated {
+			return fmt.Errorf("block %s is not part of a delegated folder", hash.B58String())
+		}
+	}
+
+	data, err := bbk.GetBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	if hdl.onBlockServed != nil {
+		hdl.onBlockServed(hdl.peerName(), len(data))
+	}
+
+	return call.Results.SetData(data)
+}
+
+func (hdl *requestHandler) Gossip(call capnp.Sync_gossip) error {
+	if !hdl.rp.Config.Bool("net.gossip.enabled") {
+		return errors.New("gossip is disabled")
+	}
+
+	if hdl.isDelegate() {
+		return ErrNoDelegation
+	}
+
+	data, err := call.Params.Entries()
+	if err != nil {
+		return err
+	}
+
+	entries, err := DecodeGossip(data)
+	if err != nil {
+		return err
+	}
+
+	maxTTL := int(hdl.rp.Config.Int("net.gossip.max_ttl"))
+	hdl.gossip.Offer(entries, hdl.currRemoteName, maxTTL)
+
+	// Tell them what we know in return:
+	reply, err := EncodeGossip(hdl.gossip.Entries(hdl.currRemoteName))
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetEntries(reply)
+}
+
+// MigrateIdentity is called by a remote that renamed its owner.
+// Every record is signed with the key we stored for the remote,
+// so only the remote itself can change its name here.
+func (hdl *requestHandler) MigrateIdentity(call capnp.Sync_migrateIdentity) error {
+	if !hdl.rp.Config.Bool("net.identity_migration.accept") {
+		return errors.New("identity migrations are not accepted")
+	}
+
+	if hdl.isDelegate() {
+		return ErrNoDelegation
+	}
+
+	data, err := call.Params.Records()
+	if err != nil {
+		return err
+	}
+
+	records := [][]byte{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	if len(records) > maxIdentityMigrations {
+		return fmt.Errorf("too many identity migrations: %d", len(records))
+	}
+
+	pubKey, err := hdl.rp.Keyring().PubKeyFor(hdl.currRemoteName)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		mig, err := repo.VerifyIdentityMigration(record, pubKey)
+		if err != nil {
+			log.Warningf("%s sent a bad identity migration: %v", hdl.currRemoteName, err)
+			return err
+		}
+
+		if mig.Old != hdl.currRemoteName {
+			// We know them under the new name already.
+			continue
+		}
+
+		if err := hdl.rp.RenameRemote(mig.Old, mig.New); err != nil {
+			return err
+		}
+
+		log.Infof("remote »%s« is now called »%s«", mig.Old, mig.New)
+		hdl.currRemoteName = mig.New
+	}
+
+	return nil
+}
+
+// Browse lists a directory of our tree for the remote,
+// filtered by the folders we share with it.
+func (hdl *requestHandler) Browse(call capnp.Sync_browse) error {
+	folders, err := hdl.readFolders()
+	if err != nil {
+		return err
+	}
+
+	root, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	fs, err := hdl.rp.FS(hdl.rp.Owner, hdl.bk)
+	if err != nil {
+		return err
+	}
+
+	entries, err := browse(fs, root, folders)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetEntries(data)
 }
@@ -0,0 +1,92 @@
+package net
+
+import (
+	"encoding/json"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/repo"
+)
+
+// The head record is what a daemon advertises about its latest commit
+// under its backend key. The record is signed with the identity key, since
+// the published data passes through nodes we do not trust. Peers verify it
+// with the public key they stored when adding us as remote.
+
+const (
+	headRecordVersion = 1
+
+	// maxHeadRecordSize limits what we read from the network.
+	maxHeadRecordSize = 64 * 1024
+)
+
+var (
+	// ErrBadHeadSignature is returned for head records that were
+	// not signed by the key of the expected remote.
+	ErrBadHeadSignature = e.New("head record is not signed by the remote")
+)
+
+// HeadRecord tells which commit was the latest one of `Owner`.
+type HeadRecord struct {
+	Version   int       `json:"version"`
+	Owner     string    `json:"owner"`
+	Index     int64     `json:"index"`
+	Hash      string    `json:"hash"`
+	Published time.Time `json:"published"`
+}
+
+type signedHeadRecord struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// SignHeadRecord encodes `rec` and signs it with `sign`, which should
+// create a detached signature with our identity key.
+func SignHeadRecord(rec HeadRecord, sign func(data []byte) ([]byte, error)) ([]byte, error) {
+	rec.Version = headRecordVersion
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(payload)
+	if err != nil {
+		return nil, e.Wrap(err, "failed to sign head record")
+	}
+
+	return json.Marshal(signedHeadRecord{Payload: payload, Signature: sig})
+}
+
+// VerifyHeadRecord decodes a record made by SignHeadRecord and checks
+// that it was signed by `pubKey` and claims to be from `owner`.
+func VerifyHeadRecord(data []byte, owner string, pubKey []byte) (*HeadRecord, error) {
+	if len(data) > maxHeadRecordSize {
+		return nil, e.Errorf("head record is too big (%d bytes)", len(data))
+	}
+
+	signed := signedHeadRecord{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, e.Wrap(err, "bad head record")
+	}
+
+	if err := repo.VerifyIdentitySignature(pubKey, signed.Payload, signed.Signature); err != nil {
+		return nil, ErrBadHeadSignature
+	}
+
+	rec := &HeadRecord{}
+	if err := json.Unmarshal(signed.Payload, rec); err != nil {
+		return nil, e.Wrap(err, "bad head record payload")
+	}
+
+	if rec.Version != headRecordVersion {
+		return nil, e.Errorf("unsupported head record version: %d", rec.Version)
+	}
+
+	// The signature only proves the key; make sure nobody re-published
+	// a record of another remote that uses the same key:
+	if rec.Owner != owner {
+		return nil, e.Errorf("head record is from `%s`, not `%s`", rec.Owner, owner)
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,68 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func newTestEntity(t *testing.T, name string) (*openpgp.Entity, []byte) {
+	ent, err := openpgp.NewEntity(name, "", "", &packet.Config{RSABits: 1024})
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, ent.Serialize(buf))
+	return ent, buf.Bytes()
+}
+
+func signerFor(ent *openpgp.Entity) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := openpgp.DetachSign(buf, ent, bytes.NewReader(data), nil)
+		return buf.Bytes(), err
+	}
+}
+
+func TestHeadRecord(t *testing.T) {
+	alice, alicePubKey := newTestEntity(t, "alice")
+	_, bobPubKey := newTestEntity(t, "bob")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	data, err := SignHeadRecord(HeadRecord{
+		Owner:     "alice",
+		Index:     3,
+		Hash:      "W1abc",
+		Published: now,
+	}, signerFor(alice))
+	require.Nil(t, err)
+
+	rec, err := VerifyHeadRecord(data, "alice", alicePubKey)
+	require.Nil(t, err)
+	require.Equal(t, "alice", rec.Owner)
+	require.Equal(t, int64(3), rec.Index)
+	require.Equal(t, "W1abc", rec.Hash)
+	require.True(t, now.Equal(rec.Published))
+
+	// Signed by somebody else:
+	_, err = VerifyHeadRecord(data, "alice", bobPubKey)
+	require.Equal(t, ErrBadHeadSignature, err)
+
+	// Right key, but it claims to be somebody else:
+	_, err = VerifyHeadRecord(data, "bob", alicePubKey)
+	require.NotNil(t, err)
+
+	// Tampered payload:
+	signed := signedHeadRecord{}
+	require.Nil(t, json.Unmarshal(data, &signed))
+	signed.Payload = bytes.Replace(signed.Payload, []byte("W1abc"), []byte("W1abd"), 1)
+	tampered, err := json.Marshal(signed)
+	require.Nil(t, err)
+
+	_, err = VerifyHeadRecord(tampered, "alice", alicePubKey)
+	require.Equal(t, ErrBadHeadSignature, err)
+}
@@ -158,3 +158,24 @@ func (nb *NetBackend) Listen(protocol string) (net.Listener, error) {
 	log.Debugf("Mock listening on %s", addr)
 	return net.Listen("tcp", addr)
 }
+
+// PublishHead is a fake implementation.
+func (nb *NetBackend) PublishHead(data []byte) error {
+	self, err := nb.Identity()
+	if err != nil {
+		return err
+	}
+
+	headPath := filepath.Join(nb.path, "heads", self.Addr)
+	if err := os.MkdirAll(filepath.Dir(headPath), 0744); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(headPath, data, 0644)
+}
+
+// ResolveHead is a fake implementation.
+func (nb *NetBackend) ResolveHead(ctx context.Context, peerAddr string) ([]byte, error) {
+	headPath := filepath.Join(nb.path, "heads", filepath.Base(peerAddr))
+	return ioutil.ReadFile(headPath) // #nosec
+}
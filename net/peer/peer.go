@@ -160,6 +160,28 @@ func CastFingerprint(s string) (Fingerprint, error) {
 	return fp, nil
 }
 
+// CastUnpinnedFingerprint is like CastFingerprint, but also accepts a
+// fingerprint that consists only of an addr (»addr:«). The public key of
+// such a fingerprint is pinned on the first connection.
+func CastUnpinnedFingerprint(s string) (Fingerprint, error) {
+	if !strings.HasSuffix(s, ":") || strings.Count(s, ":") != 1 {
+		return CastFingerprint(s)
+	}
+
+	if len(s) == 1 {
+		return Fingerprint(""), fmt.Errorf(
+			"bad fingerprint: addr could not be read",
+		)
+	}
+
+	return Fingerprint(s), nil
+}
+
+// IsPinned returns true if the fingerprint contains a public key id.
+func (fp Fingerprint) IsPinned() bool {
+	return fp.PubKeyID() != ""
+}
+
 // BuildFingerprint builds a fingerprint from `addr` and a public key.
 func BuildFingerprint(addr string, pubKeyData []byte) Fingerprint {
 	s := fmt.Sprintf("%s:%s", addr, h.Sum(pubKeyData).B58String())
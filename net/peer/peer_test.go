@@ -64,3 +64,23 @@ func TestValidity(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpinnedFingerprint(t *testing.T) {
+	fp, err := CastUnpinnedFingerprint("QmAddr:")
+	if err != nil {
+		t.Fatalf("failed to cast addr-only fingerprint: %v", err)
+	}
+
+	if fp.IsPinned() || fp.Addr() != "QmAddr" {
+		t.Fatalf("bad addr-only fingerprint: %v", fp)
+	}
+
+	if _, err := CastUnpinnedFingerprint(":"); err == nil {
+		t.Fatalf("fingerprint without addr was accepted")
+	}
+
+	fp, err = CastUnpinnedFingerprint("QmAddr:QmKey")
+	if err != nil || !fp.IsPinned() {
+		t.Fatalf("full fingerprint was not accepted: %v", err)
+	}
+}
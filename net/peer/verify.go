@@ -0,0 +1,373 @@
+package peer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sahib/brig/util/sshkey"
+	"golang.org/x/crypto/openpgp"
+)
+
+//////////////////////////////
+// OUT-OF-BAND VERIFICATION //
+//////////////////////////////
+
+// A fingerprint can be published by the owner of a domain, so that others
+// do not need to compare it manually. The record is a single line:
+//
+//     v=brig1 name=alice@wonderland.org/laptop fp=<fingerprint> key=<pubkey> sig=<signature>
+//
+// It is looked up as TXT record of _brig.<domain> and in the file
+// https://<domain>/.well-known/brig/fingerprints (one record per line).
+//
+// The record carries the public key of the fingerprint and a signature of
+// everything before »sig=« made with it. This proves that the owner of the
+// key claimed the name, but not that the name belongs to them. That comes
+// from the control over the domain: only records fetched via https (i.e.
+// checked with the certificate of the domain) are authenticated. DNS
+// records can be forged on the way and are therefore only used to check a
+// fingerprint the user already gave us, never to adopt a new one.
+
+const (
+	recordVersion = "brig1"
+
+	// DNSRecordPrefix is prepended to the domain for the TXT lookup.
+	DNSRecordPrefix = "_brig."
+
+	// WellKnownPath is the path of the fingerprint list on the domain.
+	WellKnownPath = "/.well-known/brig/fingerprints"
+)
+
+var (
+	// ErrNoDomain is returned when the peer name has no domain part.
+	ErrNoDomain = errors.New("name has no domain; cannot verify")
+
+	// ErrNoRecord is returned when no record was published for a name.
+	ErrNoRecord = errors.New("no fingerprint record found for this name")
+
+	// ErrBadRecordSignature is returned for records that are not signed
+	// by the key of the fingerprint in it.
+	ErrBadRecordSignature = errors.New("record is not signed by the key of its fingerprint")
+
+	// ErrUnauthenticatedRecord is returned when a fingerprint would be
+	// adopted only based on records that could have been forged.
+	ErrUnauthenticatedRecord = errors.New(
+		"record was only found in DNS, which is not authenticated; " +
+			"please pass the fingerprint explicitly",
+	)
+)
+
+// ErrFingerprintMismatch is returned when the published fingerprint
+// differs from the one we expected.
+type ErrFingerprintMismatch struct {
+	Expected  Fingerprint
+	Published Fingerprint
+	Source    string
+}
+
+func (err ErrFingerprintMismatch) Error() string {
+	return fmt.Sprintf(
+		"fingerprint mismatch: expected `%s`, but %s published `%s`",
+		err.Expected,
+		err.Source,
+		err.Published,
+	)
+}
+
+// Record is a single published fingerprint.
+type Record struct {
+	Name        Name
+	Fingerprint Fingerprint
+
+	// Source is where the record was found (the TXT name or the URL).
+	Source string
+
+	// Authenticated is true if the source of the record was
+	// authenticated (i.e. it was fetched via https).
+	Authenticated bool
+}
+
+// RecordPayload returns the part of a record that needs to be signed.
+func RecordPayload(name Name, fp Fingerprint, pubKey []byte) string {
+	return fmt.Sprintf(
+		"v=%s name=%s fp=%s key=%s",
+		recordVersion,
+		name,
+		fp,
+		base64.StdEncoding.EncodeToString(pubKey),
+	)
+}
+
+// FormatRecord returns the line that needs to be published.
+// `sig` is the detached signature of `payload` (see RecordPayload).
+func FormatRecord(payload string, sig []byte) string {
+	return fmt.Sprintf("%s sig=%s", payload, base64.StdEncoding.EncodeToString(sig))
+}
+
+func checkRecordSignature(pubKey []byte, payload string, sig []byte) error {
+	if sshkey.IsPubKey(pubKey) {
+		if err := sshkey.Verify(pubKey, []byte(payload), sig); err != nil {
+			return ErrBadRecordSignature
+		}
+
+		return nil
+	}
+
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(pubKey))
+	if err != nil {
+		return ErrBadRecordSignature
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		ents,
+		strings.NewReader(payload),
+		bytes.NewReader(sig),
+	); err != nil {
+		return ErrBadRecordSignature
+	}
+
+	return nil
+}
+
+// ParseRecord parses a line produced by FormatRecord.
+// Records that are not properly signed are rejected.
+func ParseRecord(line string) (*Record, error) {
+	line = strings.TrimSpace(line)
+	sigIdx := strings.LastIndex(line, " sig=")
+	if sigIdx < 0 {
+		return nil, ErrBadRecordSignature
+	}
+
+	payload := line[:sigIdx]
+	sig, err := base64.StdEncoding.DecodeString(line[sigIdx+len(" sig="):])
+	if err != nil {
+		return nil, ErrBadRecordSignature
+	}
+
+	var version, name, fp, key string
+	for _, field := range strings.Fields(payload) {
+		split := strings.SplitN(field, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		switch split[0] {
+		case "v":
+			version = split[1]
+		case "name":
+			name = split[1]
+		case "fp":
+			fp = split[1]
+		case "key":
+			key = split[1]
+		}
+	}
+
+	if version != recordVersion {
+		return nil, fmt.Errorf("unsupported record version: `%s`", version)
+	}
+
+	castName, err := CastName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	castFp, err := CastFingerprint(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || !castFp.PubKeyMatches(pubKey) {
+		return nil, ErrBadRecordSignature
+	}
+
+	if err := checkRecordSignature(pubKey, payload, sig); err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Name:        castName,
+		Fingerprint: castFp,
+	}, nil
+}
+
+// Verifier looks up published fingerprints.
+// The lookup functions can be replaced for testing.
+type Verifier struct {
+	// LookupTXT returns all TXT records of `name`.
+	LookupTXT func(name string) ([]string, error)
+
+	// FetchWellKnown returns the content of `url`.
+	FetchWellKnown func(url string) ([]byte, error)
+}
+
+// NewVerifier returns a verifier using the system resolver and https.
+func NewVerifier() *Verifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &Verifier{
+		LookupTXT: net.LookupTXT,
+		FetchWellKnown: func(url string) ([]byte, error) {
+			resp, err := client.Get(url)
+			if err != nil {
+				return nil, err
+			}
+
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("%s: %s", url, resp.Status)
+			}
+
+			// A list of fingerprints should never be that big.
+			return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		},
+	}
+}
+
+func parseRecords(lines []string, source string, authenticated bool) []Record {
+	records := []Record{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		record, err := ParseRecord(line)
+		if err != nil {
+			// Other TXT records might be set on the same name.
+			continue
+		}
+
+		record.Source = source
+		record.Authenticated = authenticated
+		records = append(records, *record)
+	}
+
+	return records
+}
+
+// Lookup returns all records for `name` that were published under the
+// domain of `name`. Both DNS and https are tried; errors are only
+// returned if both failed.
+func (v *Verifier) Lookup(name Name) ([]Record, error) {
+	domain := name.Domain()
+	if domain == "" {
+		return nil, ErrNoDomain
+	}
+
+	records := []Record{}
+	errs := []string{}
+
+	txtName := DNSRecordPrefix + domain
+	if txts, err := v.LookupTXT(txtName); err != nil {
+		errs = append(errs, fmt.Sprintf("dns: %v", err))
+	} else {
+		records = append(records, parseRecords(txts, "dns:"+txtName, false)...)
+	}
+
+	url := "https://" + domain + WellKnownPath
+	if data, err := v.FetchWellKnown(url); err != nil {
+		errs = append(errs, fmt.Sprintf("https: %v", err))
+	} else {
+		lines := []string{}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		records = append(records, parseRecords(lines, url, true)...)
+	}
+
+	if len(errs) == 2 {
+		return nil, fmt.Errorf("lookup failed: %s", strings.Join(errs, "; "))
+	}
+
+	matching := []Record{}
+	for _, record := range records {
+		if record.Name == name {
+			matching = append(matching, record)
+		}
+	}
+
+	return matching, nil
+}
+
+// Resolve returns the fingerprint published for `name`.
+// If the sources disagree, an ErrFingerprintMismatch is returned.
+// Since the result is meant to be trusted without further checks,
+// at least one record has to come from an authenticated source.
+func (v *Verifier) Resolve(name Name) (*Record, error) {
+	records, err := v.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	var trusted *Record
+	for idx, record := range records {
+		if record.Fingerprint != records[0].Fingerprint {
+			return nil, ErrFingerprintMismatch{
+				Expected:  records[0].Fingerprint,
+				Published: record.Fingerprint,
+				Source:    record.Source,
+			}
+		}
+
+		if record.Authenticated && trusted == nil {
+			trusted = &records[idx]
+		}
+	}
+
+	if trusted == nil {
+		return nil, ErrUnauthenticatedRecord
+	}
+
+	return trusted, nil
+}
+
+// Verify checks that `fp` is the fingerprint published for `name`.
+// Since the user already knows `fp`, unauthenticated records are good
+// enough to confirm it. Every record found has to agree though.
+// On success the record that matched is returned.
+func (v *Verifier) Verify(name Name, fp Fingerprint) (*Record, error) {
+	records, err := v.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	for _, record := range records {
+		if record.Fingerprint != fp {
+			return nil, ErrFingerprintMismatch{
+				Expected:  fp,
+				Published: record.Fingerprint,
+				Source:    record.Source,
+			}
+		}
+	}
+
+	// Prefer to report the authenticated source:
+	for idx, record := range records {
+		if record.Authenticated {
+			return &records[idx], nil
+		}
+	}
+
+	return &records[0], nil
+}
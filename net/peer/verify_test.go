@@ -0,0 +1,141 @@
+package peer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alokmenghrajani/gpgeez"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+type testKey struct {
+	pubKey []byte
+	entity *openpgp.Entity
+}
+
+func newTestKey(t *testing.T) *testKey {
+	cfg := gpgeez.Config{}
+	cfg.RSABits = 1024
+
+	key, err := gpgeez.CreateKey("test", "test key", "", &cfg)
+	require.Nil(t, err)
+
+	return &testKey{pubKey: key.Keyring(), entity: &key.Entity}
+}
+
+func (tk *testKey) fp(addr string) Fingerprint {
+	return BuildFingerprint(addr, tk.pubKey)
+}
+
+func (tk *testKey) record(t *testing.T, name Name, fp Fingerprint) string {
+	payload := RecordPayload(name, fp, tk.pubKey)
+	sigBuf := &bytes.Buffer{}
+	require.Nil(t, openpgp.DetachSign(sigBuf, tk.entity, strings.NewReader(payload), nil))
+	return FormatRecord(payload, sigBuf.Bytes())
+}
+
+func stubVerifier(txts []string, wellKnown string) *Verifier {
+	return &Verifier{
+		LookupTXT: func(name string) ([]string, error) {
+			if name != "_brig.wonderland.org" {
+				return nil, errors.New("no such host")
+			}
+
+			return txts, nil
+		},
+		FetchWellKnown: func(url string) ([]byte, error) {
+			if url != "https://wonderland.org/.well-known/brig/fingerprints" || wellKnown == "" {
+				return nil, errors.New("404 Not Found")
+			}
+
+			return []byte(wellKnown), nil
+		},
+	}
+}
+
+func TestRecordRoundtrip(t *testing.T) {
+	alice := newTestKey(t)
+	fp := alice.fp("QmAlice")
+
+	line := alice.record(t, "alice@wonderland.org/laptop", fp)
+	record, err := ParseRecord(line)
+	require.Nil(t, err)
+	require.Equal(t, Name("alice@wonderland.org/laptop"), record.Name)
+	require.Equal(t, fp, record.Fingerprint)
+
+	_, err = ParseRecord("v=spf1 include:wonderland.org -all")
+	require.NotNil(t, err)
+
+	// Changing the name invalidates the signature:
+	forged := strings.Replace(line, "laptop", "phone", 1)
+	_, err = ParseRecord(forged)
+	require.Equal(t, ErrBadRecordSignature, err)
+
+	// Signing someone else's fingerprint does not work either:
+	mallory := newTestKey(t)
+	_, err = ParseRecord(mallory.record(t, "alice@wonderland.org", fp))
+	require.Equal(t, ErrBadRecordSignature, err)
+}
+
+func TestVerifyDNS(t *testing.T) {
+	alice, bob := newTestKey(t), newTestKey(t)
+	aliceFp, bobFp := alice.fp("QmAlice"), bob.fp("QmBob")
+
+	vf := stubVerifier([]string{
+		"v=spf1 -all",
+		bob.record(t, "bob@wonderland.org", bobFp),
+		alice.record(t, "alice@wonderland.org", aliceFp),
+	}, "")
+
+	record, err := vf.Verify("alice@wonderland.org", aliceFp)
+	require.Nil(t, err)
+	require.Equal(t, "dns:_brig.wonderland.org", record.Source)
+	require.False(t, record.Authenticated)
+
+	_, err = vf.Verify("alice@wonderland.org", bobFp)
+	require.IsType(t, ErrFingerprintMismatch{}, err)
+
+	_, err = vf.Verify("alice@wonderland.org/laptop", aliceFp)
+	require.Equal(t, ErrNoRecord, err)
+
+	// DNS alone is not good enough to adopt a fingerprint:
+	_, err = vf.Resolve("alice@wonderland.org")
+	require.Equal(t, ErrUnauthenticatedRecord, err)
+}
+
+func TestVerifyWellKnown(t *testing.T) {
+	alice := newTestKey(t)
+	fp := alice.fp("QmAlice")
+
+	vf := stubVerifier(nil, "# comment\n"+alice.record(t, "alice@wonderland.org", fp)+"\n")
+	record, err := vf.Resolve("alice@wonderland.org")
+	require.Nil(t, err)
+	require.Equal(t, fp, record.Fingerprint)
+	require.True(t, record.Authenticated)
+}
+
+func TestVerifyConflictingSources(t *testing.T) {
+	alice, mallory := newTestKey(t), newTestKey(t)
+	vf := stubVerifier(
+		[]string{mallory.record(t, "alice@wonderland.org", mallory.fp("QmMallory"))},
+		alice.record(t, "alice@wonderland.org", alice.fp("QmAlice")),
+	)
+
+	_, err := vf.Resolve("alice@wonderland.org")
+	require.IsType(t, ErrFingerprintMismatch{}, err)
+
+	_, err = vf.Verify("alice@wonderland.org", alice.fp("QmAlice"))
+	require.IsType(t, ErrFingerprintMismatch{}, err)
+}
+
+func TestVerifyNoDomain(t *testing.T) {
+	vf := stubVerifier(nil, "")
+	_, err := vf.Resolve("alice")
+	require.Equal(t, ErrNoDomain, err)
+
+	_, err = vf.Resolve("alice@elsewhere.org")
+	require.NotNil(t, err)
+}
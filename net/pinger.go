@@ -29,6 +29,7 @@ type PingMap struct {
 	authenticated map[string]bool
 	netBk         backend.Backend
 	rp            *repo.Repository
+	onSeen        func(addr string)
 }
 
 // NewPingMap returns a new PingMap.
@@ -95,8 +96,13 @@ func (pm *PingMap) doUpdateSingle(addr string, checkAuthentication bool) {
 	// this method is called in parallel:
 	pm.mu.Lock()
 	pm.peers[addr] = pinger
+	onSeen := pm.onSeen
 	pm.mu.Unlock()
 
+	if onSeen != nil {
+		onSeen(addr)
+	}
+
 	if !checkAuthentication {
 		return
 	}
@@ -134,6 +140,15 @@ func (pm *PingMap) doUpdateSingle(addr string, checkAuthentication bool) {
 	isAuthenticated = true
 }
 
+// SetOnSeen sets a function that is called whenever a remote
+// with `addr` started responding to pings.
+func (pm *PingMap) SetOnSeen(fn func(addr string)) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.onSeen = fn
+}
+
 // Sync makes sure all addresses in `addrs` are being watched.
 // All currently watched addrs that are not in `addrs` are removed.
 // This method does not block until all pingers have been updated.
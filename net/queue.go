@@ -0,0 +1,218 @@
+package net
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of operations that can be queued.
+const (
+	QueueOpSync  = "sync"
+	QueueOpFetch = "fetch"
+	QueueOpPush  = "push"
+)
+
+const (
+	// queueMinBackoff is the wait time after the first failed retry.
+	queueMinBackoff = 30 * time.Second
+)
+
+// QueuedOp is an operation for a remote that could not be reached.
+type QueuedOp struct {
+	ID     int64  `json:"id"`
+	Remote string `json:"remote"`
+	Kind   string `json:"kind"`
+	// Message is passed along for syncs.
+	Message string `json:"message,omitempty"`
+
+	Added     time.Time `json:"added"`
+	Attempts  int       `json:"attempts"`
+	NextTry   time.Time `json:"next_try"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Queue remembers operations for remotes that were offline,
+// so they can be done once the remote is back. It is persisted
+// as json, so nothing is lost when the daemon restarts.
+//
+// There is at most one operation of each kind per remote;
+// adding another one replaces the older one, since the newer
+// one would do the same work anyway.
+type Queue struct {
+	mu         sync.Mutex
+	path       string
+	maxBackoff time.Duration
+	nextID     int64
+	ops        []*QueuedOp
+}
+
+// NewQueue loads the queue stored at `path` or creates an empty one.
+// Retries of failed operations are at most `maxBackoff` apart.
+func NewQueue(path string, maxBackoff time.Duration) (*Queue, error) {
+	q := &Queue{path: path, maxBackoff: maxBackoff, nextID: 1}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &q.ops); err != nil {
+		return nil, err
+	}
+
+	for _, op := range q.ops {
+		if op.ID >= q.nextID {
+			q.nextID = op.ID + 1
+		}
+	}
+
+	return q, nil
+}
+
+// NOTE: This method assumes that q.mu is locked.
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q.ops, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first, so we never leave a half written queue.
+	tmpPath := q.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, q.path)
+}
+
+// Add queues an operation of `kind` for `remote`.
+// It can be tried as soon as the remote is seen again.
+func (q *Queue) Add(remote, kind, msg string) (QueuedOp, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	op := &QueuedOp{
+		ID:      q.nextID,
+		Remote:  remote,
+		Kind:    kind,
+		Message: msg,
+		Added:   time.Now(),
+	}
+
+	q.nextID++
+
+	kept := []*QueuedOp{}
+	for _, other := range q.ops {
+		if other.Remote != remote || other.Kind != kind {
+			kept = append(kept, other)
+		}
+	}
+
+	q.ops = append(kept, op)
+	return *op, q.save()
+}
+
+// List returns the queued operations of `remote` (or of all remotes
+// if `remote` is empty), oldest first.
+func (q *Queue) List(remote string) []QueuedOp {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ops := []QueuedOp{}
+	for _, op := range q.ops {
+		if remote == "" || op.Remote == remote {
+			ops = append(ops, *op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].ID < ops[j].ID
+	})
+
+	return ops
+}
+
+// Due returns the operations of `remote` that should be tried now.
+// If `force` is true, the backoff is ignored; that's useful when the
+// remote just came back online.
+func (q *Queue) Due(remote string, now time.Time, force bool) []QueuedOp {
+	due := []QueuedOp{}
+	for _, op := range q.List(remote) {
+		if force || !now.Before(op.NextTry) {
+			due = append(due, op)
+		}
+	}
+
+	return due
+}
+
+// Remotes returns the names of all remotes with queued operations.
+func (q *Queue) Remotes() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, op := range q.List("") {
+		if !seen[op.Remote] {
+			seen[op.Remote] = true
+			names = append(names, op.Remote)
+		}
+	}
+
+	return names
+}
+
+// Done removes the operation with `id` from the queue.
+func (q *Queue) Done(id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for idx, op := range q.ops {
+		if op.ID == id {
+			q.ops = append(q.ops[:idx], q.ops[idx+1:]...)
+			return q.save()
+		}
+	}
+
+	return nil
+}
+
+// Failed records that trying the operation with `id` failed with `err`.
+// The next try is delayed exponentially, up to the max backoff.
+func (q *Queue) Failed(id int64, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, op := range q.ops {
+		if op.ID != id {
+			continue
+		}
+
+		op.Attempts++
+		op.LastError = err.Error()
+		op.NextTry = time.Now().Add(queueBackoff(op.Attempts, q.maxBackoff))
+		return q.save()
+	}
+
+	return nil
+}
+
+// queueBackoff returns how long to wait after `attempts` failed tries.
+func queueBackoff(attempts int, maxBackoff time.Duration) time.Duration {
+	backoff := queueMinBackoff
+	for idx := 1; idx < attempts && backoff < maxBackoff; idx++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
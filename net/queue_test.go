@@ -0,0 +1,77 @@
+package net
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-queue-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "queue.json")
+	q, err := NewQueue(path, time.Hour)
+	require.Nil(t, err)
+
+	_, err = q.Add("bob", QueueOpSync, "first")
+	require.Nil(t, err)
+
+	_, err = q.Add("bob", QueueOpPush, "")
+	require.Nil(t, err)
+
+	// Replaces the first sync:
+	_, err = q.Add("bob", QueueOpSync, "second")
+	require.Nil(t, err)
+
+	_, err = q.Add("alice", QueueOpFetch, "")
+	require.Nil(t, err)
+
+	ops := q.List("bob")
+	require.Len(t, ops, 2)
+	require.Equal(t, QueueOpPush, ops[0].Kind)
+	require.Equal(t, "second", ops[1].Message)
+	require.Equal(t, []string{"bob", "alice"}, q.Remotes())
+
+	// Load it again; ids should continue where they stopped.
+	q, err = NewQueue(path, time.Hour)
+	require.Nil(t, err)
+	require.Len(t, q.List(""), 3)
+
+	op, err := q.Add("alice", QueueOpPush, "")
+	require.Nil(t, err)
+	require.Equal(t, int64(5), op.ID)
+
+	require.Nil(t, q.Done(op.ID))
+	require.Len(t, q.List("alice"), 1)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-queue-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := NewQueue(filepath.Join(dir, "queue.json"), 5*time.Minute)
+	require.Nil(t, err)
+
+	op, err := q.Add("bob", QueueOpFetch, "")
+	require.Nil(t, err)
+
+	now := time.Now()
+	require.Len(t, q.Due("bob", now, false), 1)
+
+	require.Nil(t, q.Failed(op.ID, errors.New("still offline")))
+	require.Len(t, q.Due("bob", now, false), 0)
+	require.Len(t, q.Due("bob", now, true), 1)
+	require.Equal(t, "still offline", q.List("bob")[0].LastError)
+
+	require.Equal(t, 30*time.Second, queueBackoff(1, 5*time.Minute))
+	require.Equal(t, 60*time.Second, queueBackoff(2, 5*time.Minute))
+	require.Equal(t, 5*time.Minute, queueBackoff(10, 5*time.Minute))
+}
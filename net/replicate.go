@@ -0,0 +1,100 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/net/capnp"
+	"github.com/sahib/brig/net/replication"
+	h "github.com/sahib/brig/util/hashlib"
+	capnplib "zombiezen.com/go/capnproto2"
+)
+
+// MaxReplicateHashes limits how many hashes one replicate call may carry.
+const MaxReplicateHashes = 1024
+
+// replicate pins (if `pin` is true) the content of `hashes` and tells
+// which of them is pinned by us afterwards.
+func replicate(fs *catfs.FS, hashes []h.Hash, pin bool) ([]bool, error) {
+	pinned := make([]bool, len(hashes))
+	for idx, hash := range hashes {
+		isPinned, err := fs.IsContentPinned(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isPinned && pin {
+			found, err := fs.PinContent(hash)
+			if err != nil {
+				return nil, err
+			}
+
+			isPinned = found
+		}
+
+		pinned[idx] = isPinned
+	}
+
+	return pinned, nil
+}
+
+// Replicate is called by remotes of our replication groups to find out
+// which of their files we store and to ask us to store more of them.
+// Only content that is part of our own history is pinned, so remotes
+// need to have synced with us before.
+func (hdl *requestHandler) Replicate(call capnp.Sync_replicate) error {
+	groups, err := replication.ParseGroups(hdl.rp.Config.Strings("net.replication.groups"))
+	if err != nil {
+		return err
+	}
+
+	if !replication.IsMember(groups, hdl.currRemoteName) {
+		return fmt.Errorf("»%s« is not in any of our replication groups", hdl.currRemoteName)
+	}
+
+	capHashes, err := call.Params.Hashes()
+	if err != nil {
+		return err
+	}
+
+	if capHashes.Len() > MaxReplicateHashes {
+		return fmt.Errorf("too many hashes: %d (max %d)", capHashes.Len(), MaxReplicateHashes)
+	}
+
+	hashes := []h.Hash{}
+	for idx := 0; idx < capHashes.Len(); idx++ {
+		data, err := capHashes.At(idx)
+		if err != nil {
+			return err
+		}
+
+		hash, err := h.Cast(data)
+		if err != nil {
+			return err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	fs, err := hdl.rp.FS(hdl.rp.Owner, hdl.bk)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := replicate(fs, hashes, call.Params.Pin())
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capPinned, err := capnplib.NewBitList(seg, int32(len(pinned)))
+	if err != nil {
+		return err
+	}
+
+	for idx, isPinned := range pinned {
+		capPinned.Set(idx, isPinned)
+	}
+
+	return call.Results.SetPinned(capPinned)
+}
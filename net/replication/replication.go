@@ -0,0 +1,155 @@
+// Package replication describes how many remotes should store our files.
+// Remotes are put into groups that store files for each other and targets
+// say how many members of a group should have pinned each file below
+// a folder. The daemon asks members to pin files that have too few
+// copies; »brig repl status« lists them.
+//
+// Groups are configured in »net.replication.groups« like »backup=bob charlie«,
+// targets in »net.replication.targets« like »/photos=2@backup«.
+package replication
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Group is a set of remotes that store files for each other.
+type Group struct {
+	Name    string
+	Members []string
+}
+
+// Has checks if `remoteName` is a member of `grp`.
+func (grp Group) Has(remoteName string) bool {
+	for _, member := range grp.Members {
+		if member == remoteName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Target says that every file below Folder should be
+// pinned by at least Copies members of Group.
+type Target struct {
+	Folder string
+	Copies int
+	Group  string
+}
+
+func (tgt Target) String() string {
+	return fmt.Sprintf("%s=%d@%s", tgt.Folder, tgt.Copies, tgt.Group)
+}
+
+// ParseGroups parses entries like »backup=bob charlie«.
+func ParseGroups(entries []string) ([]Group, error) {
+	groups := []Group{}
+	for _, entry := range entries {
+		name, members, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("not of the form »name=remote...«: %s", entry)
+		}
+
+		groups = append(groups, Group{
+			Name:    name,
+			Members: strings.Fields(members),
+		})
+	}
+
+	return groups, nil
+}
+
+// ParseTargets parses entries like »/photos=2@backup«.
+func ParseTargets(entries []string) ([]Target, error) {
+	targets := []Target{}
+	for _, entry := range entries {
+		folder, rest, found := strings.Cut(entry, "=")
+		folder = strings.TrimSpace(folder)
+		if !found || !strings.HasPrefix(folder, "/") {
+			return nil, fmt.Errorf("not of the form »/folder=copies@group«: %s", entry)
+		}
+
+		copiesStr, group, found := strings.Cut(rest, "@")
+		group = strings.TrimSpace(group)
+		if !found || group == "" {
+			return nil, fmt.Errorf("no group in »%s«", entry)
+		}
+
+		copies, err := strconv.Atoi(strings.TrimSpace(copiesStr))
+		if err != nil || copies < 1 {
+			return nil, fmt.Errorf("copies must be a positive number in »%s«", entry)
+		}
+
+		targets = append(targets, Target{
+			Folder: path.Clean(folder),
+			Copies: copies,
+			Group:  group,
+		})
+	}
+
+	return targets, nil
+}
+
+// FindGroup returns the group called `name` or nil.
+func FindGroup(groups []Group, name string) *Group {
+	for idx := range groups {
+		if groups[idx].Name == name {
+			return &groups[idx]
+		}
+	}
+
+	return nil
+}
+
+// IsMember checks if `remoteName` is in any of `groups`.
+func IsMember(groups []Group, remoteName string) bool {
+	for _, grp := range groups {
+		if grp.Has(remoteName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBelow(nodePath, folder string) bool {
+	return folder == "/" || nodePath == folder || strings.HasPrefix(nodePath, folder+"/")
+}
+
+// TargetFor returns the target that applies to `nodePath` or nil.
+// If several targets match, the one with the deepest folder wins.
+func TargetFor(targets []Target, nodePath string) *Target {
+	var best *Target
+	for idx := range targets {
+		tgt := &targets[idx]
+		if !isBelow(nodePath, tgt.Folder) {
+			continue
+		}
+
+		if best == nil || len(tgt.Folder) > len(best.Folder) {
+			best = tgt
+		}
+	}
+
+	return best
+}
+
+// Pick returns which of `candidates` should be asked to pin a file
+// that is pinned by `have` members but should be by `want`.
+// Candidates are taken in their order.
+func Pick(candidates []string, have, want int) []string {
+	missing := want - have
+	if missing <= 0 {
+		return nil
+	}
+
+	if missing > len(candidates) {
+		missing = len(candidates)
+	}
+
+	return candidates[:missing]
+}
@@ -0,0 +1,49 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups([]string{"backup=bob  charlie", "off="})
+	require.Nil(t, err)
+	require.Len(t, groups, 2)
+	require.Equal(t, "backup", groups[0].Name)
+	require.Equal(t, []string{"bob", "charlie"}, groups[0].Members)
+	require.Len(t, groups[1].Members, 0)
+
+	require.True(t, IsMember(groups, "charlie"))
+	require.False(t, IsMember(groups, "dave"))
+	require.NotNil(t, FindGroup(groups, "off"))
+	require.Nil(t, FindGroup(groups, "nope"))
+
+	_, err = ParseGroups([]string{"bob charlie"})
+	require.NotNil(t, err)
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets([]string{"/=1@all", "/photos/=2@backup", "/photos/raw=3 @ backup"})
+	require.Nil(t, err)
+	require.Len(t, targets, 3)
+	require.Equal(t, Target{Folder: "/photos", Copies: 2, Group: "backup"}, targets[1])
+	require.Equal(t, "/photos/raw=3@backup", targets[2].String())
+
+	require.Equal(t, "/", TargetFor(targets, "/docs/x").Folder)
+	require.Equal(t, "/photos", TargetFor(targets, "/photos/a.png").Folder)
+	require.Equal(t, "/photos/raw", TargetFor(targets, "/photos/raw/a.cr2").Folder)
+	require.Equal(t, "/", TargetFor(targets, "/photosx").Folder)
+	require.Nil(t, TargetFor(targets[1:], "/docs/x"))
+
+	for _, bad := range []string{"photos=2@backup", "/photos=2", "/photos=0@backup", "/photos=x@backup"} {
+		_, err := ParseTargets([]string{bad})
+		require.NotNil(t, err, bad)
+	}
+}
+
+func TestPick(t *testing.T) {
+	require.Nil(t, Pick([]string{"a", "b"}, 2, 2))
+	require.Equal(t, []string{"a"}, Pick([]string{"a", "b"}, 1, 2))
+	require.Equal(t, []string{"a", "b"}, Pick([]string{"a", "b"}, 0, 3))
+}
@@ -0,0 +1,158 @@
+package schedule
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Detector tells if the current network connection is metered,
+// i.e. if the user pays for traffic or has a limited volume.
+type Detector interface {
+	Metered() (bool, error)
+}
+
+// Factory creates a detector; `command` is only used by detectors that
+// run an external program.
+type Factory func(command string) (Detector, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{
+		"none":           newNoneDetector,
+		"command":        newCommandDetector,
+		"networkmanager": newNetworkManagerDetector,
+	}
+)
+
+// Register makes a new detector available under `name`.
+// It overwrites any existing detector with the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	factories[name] = factory
+}
+
+// Names returns the names of all registered detectors.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := []string{}
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// NewDetector returns the detector registered under `name`.
+func NewDetector(name, command string) (Detector, error) {
+	mu.Lock()
+	factory, ok := factories[name]
+	mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no such metered detector: %s", name)
+	}
+
+	return factory(command)
+}
+
+/////////
+
+// noneDetector assumes that no network is ever metered.
+type noneDetector struct{}
+
+func newNoneDetector(command string) (Detector, error) {
+	return noneDetector{}, nil
+}
+
+func (nd noneDetector) Metered() (bool, error) {
+	return false, nil
+}
+
+/////////
+
+// commandDetector runs a shell command that exits with 0
+// if the network is metered and with 1 if it is not.
+type commandDetector struct {
+	command string
+}
+
+func newCommandDetector(command string) (Detector, error) {
+	if command == "" {
+		return nil, fmt.Errorf("metered detector command is empty")
+	}
+
+	return &commandDetector{command: command}, nil
+}
+
+func (cd *commandDetector) Metered() (bool, error) {
+	err := exec.Command("/bin/sh", "-c", cd.command).Run()
+	if err == nil {
+		return true, nil
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("metered detector command failed: %v", err)
+}
+
+/////////
+
+// networkManagerDetector asks NetworkManager over D-Bus. It uses the
+// busctl tool that ships with systemd, like the notifier uses gdbus.
+type networkManagerDetector struct {
+	busctl string
+}
+
+func newNetworkManagerDetector(command string) (Detector, error) {
+	busctl, err := exec.LookPath("busctl")
+	if err != nil {
+		return nil, fmt.Errorf("networkmanager detector needs `busctl`: %v", err)
+	}
+
+	return &networkManagerDetector{busctl: busctl}, nil
+}
+
+// parseNMMetered parses the output of busctl for the Metered property,
+// which looks like "u 4". The values are NM_METERED_*:
+// 0 unknown, 1 yes, 2 no, 3 guess yes, 4 guess no.
+func parseNMMetered(out string) (bool, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 || fields[0] != "u" {
+		return false, fmt.Errorf("unexpected answer from NetworkManager: %q", out)
+	}
+
+	switch fields[1] {
+	case "1", "3":
+		return true, nil
+	case "0", "2", "4":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown metered state: %s", fields[1])
+	}
+}
+
+func (nmd *networkManagerDetector) Metered() (bool, error) {
+	out, err := exec.Command(
+		nmd.busctl, "get-property",
+		"org.freedesktop.NetworkManager",
+		"/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager",
+		"Metered",
+	).CombinedOutput()
+
+	if err != nil {
+		return false, fmt.Errorf("asking NetworkManager failed: %v: %s", err, out)
+	}
+
+	return parseNMMetered(string(out))
+}
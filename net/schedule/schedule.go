@@ -0,0 +1,264 @@
+// Package schedule decides when heavy transfers (fetching file content from
+// remotes) may run. Users on laptops or mobile hotspots often do not want
+// to pull gigabytes over a metered connection or during work hours.
+// Metadata syncs are small and are never restricted by this package.
+//
+// Two things are checked: whether the current time is in one of the
+// configured windows and, optionally, whether the network is metered.
+// The latter is asked from a pluggable Detector.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a time range on some days of the week.
+// If the end is before the start, the window wraps past midnight
+// and belongs to the day it started on.
+type Window struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+func parseClock(s string) (time.Duration, error) {
+	split := strings.SplitN(s, ":", 2)
+	if len(split) != 2 {
+		return 0, fmt.Errorf("bad time `%s` (example: 22:30)", s)
+	}
+
+	hours, err := strconv.Atoi(split[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("bad hour in `%s`", s)
+	}
+
+	minutes, err := strconv.Atoi(split[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("bad minute in `%s`", s)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func parseDays(s string) ([7]bool, error) {
+	days := [7]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "*" || part == "daily" {
+			for idx := range days {
+				days[idx] = true
+			}
+
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := dayNames[bounds[0]]
+		if !ok {
+			return days, fmt.Errorf("bad day `%s` (use mon, tue, ...)", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = dayNames[bounds[1]]; !ok {
+				return days, fmt.Errorf("bad day `%s` (use mon, tue, ...)", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// ParseWindow parses a single window like "mon-fri 22:00-07:00".
+// The days may be left out, which means every day.
+func ParseWindow(spec string) (Window, error) {
+	fields := strings.Fields(spec)
+	win := Window{}
+
+	var err error
+	switch len(fields) {
+	case 1:
+		win.days, _ = parseDays("*")
+	case 2:
+		if win.days, err = parseDays(fields[0]); err != nil {
+			return win, err
+		}
+	default:
+		return win, fmt.Errorf("bad window `%s` (example: mon-fri 22:00-07:00)", spec)
+	}
+
+	clocks := strings.SplitN(fields[len(fields)-1], "-", 2)
+	if len(clocks) != 2 {
+		return win, fmt.Errorf("bad time range in `%s` (example: 22:00-07:00)", spec)
+	}
+
+	if win.start, err = parseClock(clocks[0]); err != nil {
+		return win, err
+	}
+
+	if win.end, err = parseClock(clocks[1]); err != nil {
+		return win, err
+	}
+
+	if win.start == win.end {
+		return win, fmt.Errorf("window `%s` is empty", spec)
+	}
+
+	return win, nil
+}
+
+// ParseWindows parses a list of windows separated by ";".
+// An empty spec gives no windows, which means "always".
+func ParseWindows(spec string) ([]Window, error) {
+	wins := []Window{}
+	for _, part := range strings.Split(spec, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		win, err := ParseWindow(part)
+		if err != nil {
+			return nil, err
+		}
+
+		wins = append(wins, win)
+	}
+
+	return wins, nil
+}
+
+// Contains checks if `t` is inside of the window.
+func (win Window) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	clock := t.Sub(midnight)
+
+	if win.start < win.end {
+		return win.days[t.Weekday()] && clock >= win.start && clock < win.end
+	}
+
+	// Wrapping window: the part before midnight belongs to today,
+	// the part after midnight to the day before.
+	if clock >= win.start {
+		return win.days[t.Weekday()]
+	}
+
+	yesterday := (t.Weekday() + 6) % 7
+	return clock < win.end && win.days[yesterday]
+}
+
+// Config is what the scheduler needs to know.
+// It is read again on every check, so changes apply without restart.
+type Config struct {
+	// Windows is a spec for ParseWindows. Empty means always.
+	Windows string
+	// UnmeteredOnly only allows transfers on unmetered networks.
+	UnmeteredOnly bool
+	// Detector is the name of the detector for metered networks.
+	Detector string
+	// Command is passed to the detector, if it needs one.
+	Command string
+}
+
+// Scheduler checks if heavy transfers are currently allowed.
+type Scheduler struct {
+	mu       sync.Mutex
+	config   func() Config
+	now      func() time.Time
+	detector Detector
+	detName  string
+	detCmd   string
+}
+
+// NewScheduler returns a scheduler that reads its config from `config`.
+func NewScheduler(config func() Config) *Scheduler {
+	return &Scheduler{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+func (sc *Scheduler) detectorFor(cfg Config) (Detector, error) {
+	if sc.detector != nil && sc.detName == cfg.Detector && sc.detCmd == cfg.Command {
+		return sc.detector, nil
+	}
+
+	det, err := NewDetector(cfg.Detector, cfg.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.detector, sc.detName, sc.detCmd = det, cfg.Detector, cfg.Command
+	return det, nil
+}
+
+// HeavyAllowed returns true if heavy transfers may run right now.
+// If not, the reason is returned as second value.
+func (sc *Scheduler) HeavyAllowed() (bool, string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	cfg := sc.config()
+	wins, err := ParseWindows(cfg.Windows)
+	if err != nil {
+		// Should not happen, since the config is validated.
+		// Better transfer too much than never.
+		return true, ""
+	}
+
+	if len(wins) > 0 {
+		now := sc.now()
+		inWindow := false
+		for _, win := range wins {
+			if win.Contains(now) {
+				inWindow = true
+				break
+			}
+		}
+
+		if !inWindow {
+			return false, "outside of transfer window"
+		}
+	}
+
+	if !cfg.UnmeteredOnly {
+		return true, ""
+	}
+
+	det, err := sc.detectorFor(cfg)
+	if err != nil {
+		return false, fmt.Sprintf("no metered detector: %v", err)
+	}
+
+	metered, err := det.Metered()
+	if err != nil {
+		// Assume the worst; a user that asked for this rather waits.
+		return false, fmt.Sprintf("could not detect metered network: %v", err)
+	}
+
+	if metered {
+		return false, "network is metered"
+	}
+
+	return true, ""
+}
@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 2019-03-04 is a monday.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2019, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseWindowErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"22:00",
+		"mon-fri",
+		"foo 10:00-12:00",
+		"mon 10:00-10:00",
+		"mon 25:00-10:00",
+		"mon 10:60-11:00",
+		"mon fri 10:00-11:00",
+	} {
+		_, err := ParseWindow(spec)
+		require.NotNil(t, err, spec)
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	wins, err := ParseWindows("mon-fri 22:00-07:00; sat,sun 00:00-24:00")
+	require.Nil(t, err)
+	require.Len(t, wins, 2)
+
+	contains := func(t time.Time) bool {
+		for _, win := range wins {
+			if win.Contains(t) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	require.False(t, contains(at(4, 12, 0)))
+	require.True(t, contains(at(4, 22, 0)))
+	require.True(t, contains(at(5, 6, 59)))
+	require.False(t, contains(at(5, 7, 0)))
+
+	// Monday early morning belongs to sunday, which ends at midnight:
+	require.False(t, contains(at(4, 3, 0)))
+
+	// Saturday early morning belongs to the friday night:
+	require.True(t, contains(at(9, 3, 0)))
+	require.True(t, contains(at(10, 15, 0)))
+
+	// Wrapping day ranges:
+	win, err := ParseWindow("sat-mon 10:00-11:00")
+	require.Nil(t, err)
+	require.True(t, win.Contains(at(4, 10, 30)))
+	require.False(t, win.Contains(at(5, 10, 30)))
+
+	empty, err := ParseWindows(" ; ")
+	require.Nil(t, err)
+	require.Len(t, empty, 0)
+}
+
+type fakeDetector struct {
+	metered bool
+}
+
+func (fd *fakeDetector) Metered() (bool, error) {
+	return fd.metered, nil
+}
+
+func TestSchedulerHeavyAllowed(t *testing.T) {
+	det := &fakeDetector{}
+	Register("fake", func(command string) (Detector, error) {
+		return det, nil
+	})
+
+	cfg := Config{Detector: "fake"}
+	sc := NewScheduler(func() Config { return cfg })
+	sc.now = func() time.Time { return at(4, 12, 0) }
+
+	ok, _ := sc.HeavyAllowed()
+	require.True(t, ok)
+
+	cfg.Windows = "22:00-06:00"
+	ok, reason := sc.HeavyAllowed()
+	require.False(t, ok)
+	require.Equal(t, "outside of transfer window", reason)
+
+	cfg.Windows = "mon 12:00-13:00"
+	cfg.UnmeteredOnly = true
+	ok, _ = sc.HeavyAllowed()
+	require.True(t, ok)
+
+	det.metered = true
+	ok, reason = sc.HeavyAllowed()
+	require.False(t, ok)
+	require.Equal(t, "network is metered", reason)
+
+	cfg.Detector = "none"
+	ok, _ = sc.HeavyAllowed()
+	require.True(t, ok)
+}
+
+func TestParseNMMetered(t *testing.T) {
+	for out, expect := range map[string]bool{
+		"u 1\n": true,
+		"u 2\n": false,
+		"u 3\n": true,
+		"u 4\n": false,
+	} {
+		metered, err := parseNMMetered(out)
+		require.Nil(t, err)
+		require.Equal(t, expect, metered, out)
+	}
+
+	_, err := parseNMMetered("s \"yes\"")
+	require.NotNil(t, err)
+}
+
+func TestCommandDetector(t *testing.T) {
+	det, err := NewDetector("command", "exit 0")
+	require.Nil(t, err)
+	metered, err := det.Metered()
+	require.Nil(t, err)
+	require.True(t, metered)
+
+	det, err = NewDetector("command", "exit 1")
+	require.Nil(t, err)
+	metered, err = det.Metered()
+	require.Nil(t, err)
+	require.False(t, metered)
+
+	det, err = NewDetector("command", "exit 2")
+	require.Nil(t, err)
+	_, err = det.Metered()
+	require.NotNil(t, err)
+}
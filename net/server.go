@@ -25,6 +25,7 @@ type Server struct {
 	baseServer *server.Server
 	hdl        *connHandler
 	pingMap    *PingMap
+	gossip     *Gossip
 }
 
 // Serve blocks and serves request until quit was called.
@@ -37,6 +38,27 @@ func (sv *Server) Close() error {
 	return sv.baseServer.Close()
 }
 
+// SetOnUnknownPeer sets a function that is called whenever a peer tried
+// to connect with a key that does not belong to any of our remotes.
+// `name` is the name the peer claims to have; the fingerprint only
+// contains the key part, since we do not know its addr.
+func (sv *Server) SetOnUnknownPeer(fn func(name string, fingerprint peer.Fingerprint)) {
+	sv.hdl.mu.Lock()
+	defer sv.hdl.mu.Unlock()
+
+	sv.hdl.onUnknown = fn
+}
+
+// SetOnBlockServed sets a function that is called whenever a block
+// of content was sent to `remote`. It is only used for statistics.
+// It applies to connections that are accepted afterwards.
+func (sv *Server) SetOnBlockServed(fn func(remote string, size int)) {
+	sv.hdl.mu.Lock()
+	defer sv.hdl.mu.Unlock()
+
+	sv.hdl.onBlockServed = fn
+}
+
 // Quit will shut down the server and unblock Serve()
 func (sv *Server) Quit() {
 	sv.baseServer.Quit()
@@ -78,12 +100,26 @@ func publishSelf(bk backend.Backend, owner string) error {
 // NewServer returns a new inter-remote server.
 func NewServer(rp *repo.Repository, bk backend.Backend, rapi remotesapi.RemotesAPI) (*Server, error) {
 	pingMap := NewPingMap(rp, bk)
+	gossip := NewGossip(func(owner string) ([]byte, error) {
+		// Only trust keys of remotes we added ourselves:
+		rmt, err := rp.Remotes.Remote(owner)
+		if err != nil {
+			return nil, err
+		}
+
+		if rmt.IsBlocked() {
+			return nil, fmt.Errorf("remote `%s` is blocked", owner)
+		}
+
+		return rp.Keyring().PubKeyFor(owner)
+	})
 
 	hdl := &connHandler{
 		rp:      rp,
 		bk:      bk,
 		rapi:    rapi,
 		pingMap: pingMap,
+		gossip:  gossip,
 	}
 
 	lst, err := bk.Listen("brig/caprpc")
@@ -108,6 +144,7 @@ func NewServer(rp *repo.Repository, bk backend.Backend, rapi remotesapi.RemotesA
 		bk:         bk,
 		hdl:        hdl,
 		pingMap:    pingMap,
+		gossip:     gossip,
 	}, nil
 }
 
@@ -268,6 +305,11 @@ func (sv *Server) Identity() (peer.Info, error) {
 	return sv.bk.Identity()
 }
 
+// Gossip returns the head records we learned from other remotes.
+func (sv *Server) Gossip() *Gossip {
+	return sv.gossip
+}
+
 // PingMap returns the ping map associated with this server.
 func (sv *Server) PingMap() *PingMap {
 	return sv.pingMap
@@ -297,6 +339,11 @@ type connHandler struct {
 	rp      *repo.Repository
 	rapi    remotesapi.RemotesAPI
 	pingMap *PingMap
+	gossip  *Gossip
+
+	mu            sync.Mutex
+	onUnknown     func(name string, fingerprint peer.Fingerprint)
+	onBlockServed func(remote string, size int)
 }
 
 // Handle is called whenever we receive a new connection from another brig peer.
@@ -316,13 +363,21 @@ func (hdl *connHandler) Handle(ctx context.Context, conn net.Conn) {
 
 	// The respective handler should get its own context it can listen to.
 	reqCtx, reqCancel := context.WithCancel(ctx)
+	hdl.mu.Lock()
+	onBlockServed := hdl.onBlockServed
+	hdl.mu.Unlock()
+
 	reqHdl := &requestHandler{
-		bk:   hdl.bk,
-		rp:   hdl.rp,
-		ctx:  reqCtx,
-		rapi: hdl.rapi,
+		bk:            hdl.bk,
+		rp:            hdl.rp,
+		ctx:           reqCtx,
+		rapi:          hdl.rapi,
+		gossip:        hdl.gossip,
+		onBlockServed: onBlockServed,
 	}
 
+	var authConn *AuthReadWriter
+
 	// This func will be called during the authentication process.
 	// It checks if the pub key the other side send us can be
 	// related to one of the allowed remotes. If not, the connection
@@ -339,11 +394,18 @@ func (hdl *connHandler) Handle(ctx context.Context, conn net.Conn) {
 			return fmt.Errorf("cannot dial self")
 		}
 
+		reqHdl.peerKeyID = remoteFp.PubKeyID()
+
 		// Linear scan over all remotes.
 		// If this proves to be a performance problem, we can fix it later.
 		for _, remote := range remotes {
 			if remote.Fingerprint.PubKeyID() == remoteFp.PubKeyID() {
 				addr := remote.Fingerprint.Addr()
+				if remote.IsBlocked() {
+					hdl.pingMap.hintNetAttempt(addr, false)
+					return fmt.Errorf("remote `%s` is blocked", remote.Name)
+				}
+
 				log.Infof("starting connection with addr `%s`", addr)
 				hdl.pingMap.hintNetAttempt(addr, true)
 				reqHdl.currRemoteName = remote.Name
@@ -351,16 +413,32 @@ func (hdl *connHandler) Handle(ctx context.Context, conn net.Conn) {
 			}
 		}
 
+		// They might have a delegation to show us:
+		if hdl.rp.Config.Bool("net.delegation.accept") {
+			log.Infof("accepting unknown peer `%s` as possible delegate", authConn.RemoteName())
+			reqHdl.delegateName = authConn.RemoteName()
+			return nil
+		}
+
 		netAddr := conn.RemoteAddr()
 		if netAddr != nil {
 			hdl.pingMap.hintNetAttempt(netAddr.String(), false)
 		}
 
+		hdl.mu.Lock()
+		onUnknown := hdl.onUnknown
+		hdl.mu.Unlock()
+
+		if onUnknown != nil {
+			// The name is only what the other side claims to be.
+			onUnknown(authConn.RemoteName(), remoteFp)
+		}
+
 		return fmt.Errorf("remote uses no public key known to us")
 	}
 
 	// Take the raw connection we get and add an authentication layer on top of it.
-	authConn := NewAuthReadWriter(conn, keyring, ownPubKey, hdl.rp.Owner, authChecker)
+	authConn = NewAuthReadWriter(conn, keyring, ownPubKey, hdl.rp.Owner, authChecker)
 
 	// Trigger the authentication. This is not strictly necessary and would
 	// happen anyways on the first read/write on the connection. But doing it
@@ -0,0 +1,194 @@
+package net
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncRecord describes a single sync with a remote.
+type SyncRecord struct {
+	Remote   string        `json:"remote"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Files is the number of files that changed on our side.
+	Files int `json:"files"`
+	// Conflicts is the number of conflicts that had to be resolved.
+	Conflicts int `json:"conflicts"`
+	// Bytes is the size of the content that was new to us.
+	Bytes uint64 `json:"bytes"`
+	// Err is set if the sync failed.
+	Err string `json:"error,omitempty"`
+}
+
+// SyncSummary sums up the syncs with a single remote.
+type SyncSummary struct {
+	Remote    string
+	Syncs     int
+	Failed    int
+	Files     int
+	Conflicts int
+	Bytes     uint64
+	Duration  time.Duration
+	LastSync  time.Time
+}
+
+// ConflictRate is the share of changed files that were conflicts.
+func (ss SyncSummary) ConflictRate() float64 {
+	if ss.Files == 0 {
+		return 0
+	}
+
+	return float64(ss.Conflicts) / float64(ss.Files)
+}
+
+// AvgDuration is the average time a sync took.
+func (ss SyncSummary) AvgDuration() time.Duration {
+	if ss.Syncs == 0 {
+		return 0
+	}
+
+	return ss.Duration / time.Duration(ss.Syncs)
+}
+
+// SyncLog remembers the last syncs with every remote. Like the Queue, it is
+// persisted as json. Only the newest `maxRecords` syncs per remote are kept.
+type SyncLog struct {
+	mu         sync.Mutex
+	path       string
+	maxRecords int
+	records    []SyncRecord
+}
+
+// NewSyncLog loads the log stored at `path` or creates an empty one.
+func NewSyncLog(path string, maxRecords int) (*SyncLog, error) {
+	sl := &SyncLog{path: path, maxRecords: maxRecords}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sl, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &sl.records); err != nil {
+		return nil, err
+	}
+
+	return sl, nil
+}
+
+// NOTE: This method assumes that sl.mu is locked.
+func (sl *SyncLog) save() error {
+	data, err := json.MarshalIndent(sl.records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := sl.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, sl.path)
+}
+
+// Add records a sync and drops the oldest syncs of the same remote
+// if there are more than allowed.
+func (sl *SyncLog) Add(record SyncRecord) error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.records = append(sl.records, record)
+
+	count := 0
+	for _, other := range sl.records {
+		if other.Remote == record.Remote {
+			count++
+		}
+	}
+
+	kept := []SyncRecord{}
+	for _, other := range sl.records {
+		if other.Remote == record.Remote && count > sl.maxRecords {
+			count--
+			continue
+		}
+
+		kept = append(kept, other)
+	}
+
+	sl.records = kept
+	return sl.save()
+}
+
+// Remove forgets all syncs with `remote`.
+func (sl *SyncLog) Remove(remote string) error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	kept := []SyncRecord{}
+	for _, record := range sl.records {
+		if record.Remote != remote {
+			kept = append(kept, record)
+		}
+	}
+
+	sl.records = kept
+	return sl.save()
+}
+
+// Summary sums up the syncs started after `since`, per remote.
+// If `remote` is not empty, only this remote is considered.
+func (sl *SyncLog) Summary(remote string, since time.Time) []SyncSummary {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	byRemote := make(map[string]*SyncSummary)
+	for _, record := range sl.records {
+		if remote != "" && record.Remote != remote {
+			continue
+		}
+
+		if record.Started.Before(since) {
+			continue
+		}
+
+		sum, ok := byRemote[record.Remote]
+		if !ok {
+			sum = &SyncSummary{Remote: record.Remote}
+			byRemote[record.Remote] = sum
+		}
+
+		sum.Syncs++
+		sum.Duration += record.Duration
+		if record.Started.After(sum.LastSync) {
+			sum.LastSync = record.Started
+		}
+
+		if record.Err != "" {
+			sum.Failed++
+			continue
+		}
+
+		sum.Files += record.Files
+		sum.Conflicts += record.Conflicts
+		sum.Bytes += record.Bytes
+	}
+
+	sums := []SyncSummary{}
+	for _, sum := range byRemote {
+		sums = append(sums, *sum)
+	}
+
+	sort.Slice(sums, func(i, j int) bool {
+		return sums[i].Remote < sums[j].Remote
+	})
+
+	return sums
+}
@@ -0,0 +1,70 @@
+package net
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-synclog-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sync-stats.json")
+	sl, err := NewSyncLog(path, 3)
+	require.Nil(t, err)
+
+	now := time.Now()
+	for idx := 0; idx < 4; idx++ {
+		require.Nil(t, sl.Add(SyncRecord{
+			Remote:    "bob",
+			Started:   now.Add(time.Duration(idx-4) * 24 * time.Hour),
+			Duration:  time.Second,
+			Files:     10,
+			Conflicts: idx,
+			Bytes:     100,
+		}))
+	}
+
+	require.Nil(t, sl.Add(SyncRecord{
+		Remote:   "alice",
+		Started:  now,
+		Duration: 3 * time.Second,
+		Err:      "dial: timeout",
+	}))
+
+	// The oldest sync with bob was dropped:
+	sums := sl.Summary("", time.Time{})
+	require.Len(t, sums, 2)
+	require.Equal(t, "alice", sums[0].Remote)
+	require.Equal(t, 1, sums[0].Syncs)
+	require.Equal(t, 1, sums[0].Failed)
+	require.Equal(t, 0, sums[0].Files)
+
+	require.Equal(t, "bob", sums[1].Remote)
+	require.Equal(t, 3, sums[1].Syncs)
+	require.Equal(t, 30, sums[1].Files)
+	require.Equal(t, 6, sums[1].Conflicts)
+	require.Equal(t, uint64(300), sums[1].Bytes)
+	require.Equal(t, time.Second, sums[1].AvgDuration())
+	require.InDelta(t, 0.2, sums[1].ConflictRate(), 0.001)
+
+	// Only the last two days:
+	sums = sl.Summary("bob", now.Add(-2*24*time.Hour-time.Minute))
+	require.Len(t, sums, 1)
+	require.Equal(t, 2, sums[0].Syncs)
+	require.Equal(t, 5, sums[0].Conflicts)
+
+	// Everything is still there after loading it again:
+	sl, err = NewSyncLog(path, 3)
+	require.Nil(t, err)
+	require.Len(t, sl.Summary("", time.Time{}), 2)
+
+	require.Nil(t, sl.Remove("bob"))
+	require.Len(t, sl.Summary("", time.Time{}), 1)
+}
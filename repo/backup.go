@@ -0,0 +1,372 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs/mio/encrypt"
+	"github.com/sahib/brig/util"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// backupMagic is written at the start of every backup bundle.
+	backupMagic = []byte("BRIGBKP1")
+
+	// backupEntries are the files and directories that are part of a backup.
+	// The content in data/ is not included; it can be fetched from peers.
+	backupEntries = []string{
+		"OWNER",
+		lockSaltName,
+		"BACKEND",
+		"REPO_ID",
+		"VERSION",
+		featuresName,
+		"config.yml",
+		"remotes.yml",
+		"gpg.pub",
+		"gpg.prv",
+		"pubkeys",
+		"subkeys",
+		"metadata",
+	}
+
+	// ErrBadBackup is returned when the bundle is not a backup
+	// or when the passphrase is wrong.
+	ErrBadBackup = errors.New("not a valid backup or wrong passphrase")
+)
+
+const backupSaltSize = 32
+
+type backupWriter struct {
+	tw *tar.Writer
+}
+
+func (bw *backupWriter) addStream(name string, r io.Reader, size int64) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+
+	if err := bw.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(bw.tw, r)
+	return err
+}
+
+func (bw *backupWriter) addPlainFile(name, path string, info os.FileInfo) error {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+	return bw.addStream(name, fd, info.Size())
+}
+
+func (bw *backupWriter) addPlainDir(name, path string) error {
+	return filepath.Walk(path, func(subPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return bw.addPlainFile(name+subPath[len(path):], subPath, info)
+	})
+}
+
+func (bw *backupWriter) addLockedFile(name, path string, key []byte) error {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+
+	encR, err := encrypt.NewReader(fd, key)
+	if err != nil {
+		return err
+	}
+
+	// The size has to be known in advance for tar.
+	// Locked files are small, so just buffer them.
+	data, err := ioutil.ReadAll(encR)
+	if err != nil {
+		return err
+	}
+
+	return bw.addStream(name, bytes.NewReader(data), int64(len(data)))
+}
+
+func (bw *backupWriter) addLockedDir(name, path string, key []byte) error {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+
+	encR, err := encrypt.NewReader(fd, key)
+	if err != nil {
+		return err
+	}
+
+	// Locked dirs are archives created by util.Tar().
+	// Copy their entries over with the right prefix.
+	gzr, err := gzip.NewReader(encR)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		subName := filepath.Join(name, filepath.Clean("/"+hdr.Name))
+		if err := bw.addStream(subName, tr, hdr.Size); err != nil {
+			return err
+		}
+	}
+}
+
+func (bw *backupWriter) addEntry(baseFolder, name string, key []byte) error {
+	path := filepath.Join(baseFolder, name)
+
+	// The repository might be locked or not;
+	// we have to deal with both possibilities.
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return bw.addPlainDir(name, path)
+		}
+
+		return bw.addPlainFile(name, path, info)
+	}
+
+	if _, err := os.Stat(path + LockDirSuffix); err == nil {
+		return bw.addLockedDir(name, path+LockDirSuffix, key)
+	}
+
+	if _, err := os.Stat(path + LockPathSuffix); err == nil {
+		return bw.addLockedFile(name, path+LockPathSuffix, key)
+	}
+
+	log.Debugf("backup: %s does not exist; skipping", name)
+	return nil
+}
+
+// CreateBackup writes a backup of the repository at `baseFolder` to `w`.
+// The backup contains the keyring, the remotes, the config and the metadata,
+// but no file content. `password` is the password of the repository,
+// the backup itself is encrypted with a key derived from `passphrase`.
+//
+// The daemon should not run while creating a backup, since the metadata
+// might change while we're reading it.
+func CreateBackup(baseFolder, password, passphrase string, w io.Writer) error {
+	if err := CheckPassword(baseFolder, password); err != nil {
+		return err
+	}
+
+	lockSalt, err := readLockSalt(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, backupSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(backupMagic); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(salt); err != nil {
+		return err
+	}
+
+	encW, err := encrypt.NewWriter(w, util.DeriveKey([]byte(passphrase), salt, 32))
+	if err != nil {
+		return err
+	}
+
+	gzw := gzip.NewWriter(encW)
+	bw := &backupWriter{tw: tar.NewWriter(gzw)}
+
+	key := keyFromPassword(lockSalt, password)
+	for _, name := range backupEntries {
+		if err := bw.addEntry(baseFolder, name, key); err != nil {
+			return e.Wrapf(err, "backup of %s failed", name)
+		}
+	}
+
+	if err := bw.tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+
+	return encW.Close()
+}
+
+func isBackupEntry(name string) bool {
+	for _, entry := range backupEntries {
+		if name == entry || strings.HasPrefix(name, entry+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func restoreFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fd, r); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+// RestoreBackup restores a backup created by CreateBackup from `r` into
+// `baseFolder`, which may not exist yet or has to be empty. The restored
+// repository will be locked with `newPassword` once it is opened.
+// The content has to be fetched again from other peers.
+func RestoreBackup(r io.Reader, baseFolder, passphrase, newPassword string) error {
+	children, err := ioutil.ReadDir(baseFolder)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(children) > 0 {
+		return fmt.Errorf("`%s` is not empty; refusing to restore", baseFolder)
+	}
+
+	header := make([]byte, len(backupMagic)+backupSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return ErrBadBackup
+	}
+
+	if !bytes.Equal(header[:len(backupMagic)], backupMagic) {
+		return ErrBadBackup
+	}
+
+	salt := header[len(backupMagic):]
+	encR, err := encrypt.NewReader(r, util.DeriveKey([]byte(passphrase), salt, 32))
+	if err != nil {
+		return ErrBadBackup
+	}
+
+	gzr, err := gzip.NewReader(encR)
+	if err != nil {
+		return ErrBadBackup
+	}
+
+	if err := os.MkdirAll(baseFolder, 0700); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(hdr.Name)
+		if !isBackupEntry(name) {
+			log.Warningf("backup: ignoring unexpected entry %s", hdr.Name)
+			continue
+		}
+
+		if err := restoreFile(filepath.Join(baseFolder, name), tr); err != nil {
+			return e.Wrapf(err, "failed to restore %s", name)
+		}
+	}
+
+	owner, err := ioutil.ReadFile(filepath.Join(baseFolder, "OWNER")) // #nosec
+	if err != nil {
+		return e.Wrap(err, "backup has no OWNER")
+	}
+
+	backendName, err := ioutil.ReadFile(filepath.Join(baseFolder, "BACKEND")) // #nosec
+	if err != nil {
+		return e.Wrap(err, "backup has no BACKEND")
+	}
+
+	// Content is not part of the backup, so start with an empty data dir.
+	dataFolder := filepath.Join(baseFolder, "data", string(backendName))
+	if err := os.MkdirAll(dataFolder, 0700); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"metadata", "pubkeys"} {
+		if err := os.MkdirAll(filepath.Join(baseFolder, name), 0700); err != nil {
+			return err
+		}
+	}
+
+	if err := touch(filepath.Join(baseFolder, "remotes.yml")); err != nil {
+		return err
+	}
+
+	// Like after Init: nothing is locked yet, so do not warn about it.
+	if err := touch(filepath.Join(baseFolder, "INIT_TAG")); err != nil {
+		return err
+	}
+
+	passwdFile := filepath.Join(baseFolder, "passwd")
+	if err := ioutil.WriteFile(passwdFile, owner, 0644); err != nil {
+		return err
+	}
+
+	lockSalt, err := readLockSalt(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	if err := lockFile(passwdFile, keyFromPassword(lockSalt, newPassword)); err != nil {
+		return e.Wrapf(err, "passwd-lock")
+	}
+
+	return os.Remove(passwdFile)
+}
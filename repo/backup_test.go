@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/sahib/brig/backend/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRoundtrip(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		srcDir := filepath.Join(dir, "src")
+		dstDir := filepath.Join(dir, "dst")
+
+		require.Nil(t, Init(srcDir, "alice", "klaus", "mock", 6666))
+		rp, err := Open(srcDir, "klaus")
+		require.Nil(t, err)
+
+		bk := mock.NewMockBackend("", "")
+		fs, err := rp.FS(rp.CurrentUser(), bk)
+		require.Nil(t, err)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.MakeCommit("add x"))
+		require.Nil(t, fs.Close())
+
+		srcPubKey, err := rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+		require.Nil(t, rp.Close("klaus"))
+
+		// The backup should work on a locked repository:
+		buf := &bytes.Buffer{}
+		require.Nil(t, CreateBackup(srcDir, "klaus", "secret", buf))
+
+		// Wrong passphrase should not restore anything:
+		err = RestoreBackup(bytes.NewReader(buf.Bytes()), dstDir, "wrong", "karl")
+		require.NotNil(t, err)
+
+		require.Nil(t, RestoreBackup(buf, dstDir, "secret", "karl"))
+		require.Equal(t, ErrBadPassword, CheckPassword(dstDir, "klaus"))
+
+		rp, err = Open(dstDir, "karl")
+		require.Nil(t, err)
+
+		dstPubKey, err := rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+		require.Equal(t, srcPubKey, dstPubKey)
+
+		fs, err = rp.FS(rp.CurrentUser(), bk)
+		require.Nil(t, err)
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(3), info.Size)
+
+		require.Nil(t, fs.Close())
+		require.Nil(t, rp.Close("karl"))
+	})
+}
+
+func TestRestoreBackupBadInput(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		buf := bytes.NewReader([]byte("this is not a backup at all, sorry"))
+		err := RestoreBackup(buf, filepath.Join(dir, "dst"), "secret", "karl")
+		require.Equal(t, ErrBadBackup, err)
+	})
+}
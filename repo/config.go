@@ -0,0 +1,109 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sahib/brig/defaults"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// configWatchInterval is the interval in which config.yml is checked for changes.
+	configWatchInterval = 2 * time.Second
+
+	// excludedFromReload are keys that are managed by the daemon itself
+	// and should not be overwritten by what's on disk.
+	excludedFromReload = map[string]bool{
+		"repo.current_user": true,
+	}
+)
+
+// ReloadConfig reads config.yml again and applies all keys that changed.
+// Keys that need a restart to take effect are not applied, but returned
+// so the caller can warn about them. If the config on disk is not valid,
+// nothing is applied.
+func (rp *Repository) ReloadConfig() ([]string, error) {
+	configPath := filepath.Join(rp.BaseFolder, "config.yml")
+	diskCfg, err := defaults.OpenMigratedConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	needRestart := []string{}
+	for _, key := range diskCfg.Keys() {
+		if excludedFromReload[key] || !rp.Config.IsValidKey(key) {
+			continue
+		}
+
+		if diskCfg.Uncast(key) == rp.Config.Uncast(key) {
+			continue
+		}
+
+		if rp.Config.GetDefault(key).NeedsRestart {
+			needRestart = append(needRestart, key)
+			continue
+		}
+
+		log.Infof("config: reloading `%s` with `%v`", key, diskCfg.Get(key))
+		if err := rp.Config.Set(key, diskCfg.Get(key)); err != nil {
+			log.Warningf("config: failed to reload `%s`: %v", key, err)
+		}
+	}
+
+	return needRestart, nil
+}
+
+// StartConfigWatcher checks config.yml for changes periodically
+// and reloads it when it was modified.
+func (rp *Repository) StartConfigWatcher() {
+	go rp.configWatchLoop()
+}
+
+func (rp *Repository) stopConfigWatcher() {
+	go func() {
+		rp.configWatchControl <- true
+	}()
+}
+
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+func (rp *Repository) configWatchLoop() {
+	configPath := filepath.Join(rp.BaseFolder, "config.yml")
+	lastModTime := configModTime(configPath)
+
+	checkTicker := time.NewTicker(configWatchInterval)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-rp.configWatchControl:
+			log.Debugf("quitting the config watch loop")
+			return
+		case <-checkTicker.C:
+			modTime := configModTime(configPath)
+			if !modTime.After(lastModTime) {
+				continue
+			}
+
+			lastModTime = modTime
+			needRestart, err := rp.ReloadConfig()
+			if err != nil {
+				log.Warningf("config: not reloading invalid config: %v", err)
+				continue
+			}
+
+			for _, key := range needRestart {
+				log.Warningf("config: `%s` was changed, but needs a restart", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfig(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		require.Nil(t, Init(dir, "alice", "klaus", "mock", 6666))
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		require.False(t, rp.Config.Bool("fs.sync.ignore_removed"))
+
+		require.Nil(t, OverwriteConfigKey(dir, "fs.sync.ignore_removed", true))
+		require.Nil(t, OverwriteConfigKey(dir, "daemon.port", int64(7777)))
+		require.Nil(t, OverwriteConfigKey(dir, "repo.current_user", "bob"))
+
+		needRestart, err := rp.ReloadConfig()
+		require.Nil(t, err)
+		require.Equal(t, []string{"daemon.port"}, needRestart)
+
+		require.True(t, rp.Config.Bool("fs.sync.ignore_removed"))
+		require.Equal(t, int64(6666), rp.Config.Int("daemon.port"))
+		require.Equal(t, "alice", rp.Config.String("repo.current_user"))
+
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
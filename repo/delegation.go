@@ -0,0 +1,259 @@
+package repo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+)
+
+// A delegation lets a peer read a folder of a remote that does not know it
+// (friend-of-friend sharing): the issuer signs a certificate that names the
+// folder and the key of the holder. The holder shows the certificate to any
+// peer that stores the folder. That peer accepts it if it knows the issuer
+// and the issuer may read the folder there itself.
+
+const (
+	delegationsName = "delegations.json"
+
+	delegationVersion = 1
+
+	// MaxDelegationSize limits what we read from the network.
+	MaxDelegationSize = 64 * 1024
+)
+
+var (
+	// ErrBadDelegationSignature is returned for certificates
+	// that were not signed by the key of the issuer.
+	ErrBadDelegationSignature = e.New("delegation is not signed by its issuer")
+)
+
+// Delegation allows the peer with the key `Holder` to read `Folder`
+// of everyone that trusts `Issuer` with this folder, until `Expires`.
+type Delegation struct {
+	Version int    `json:"version"`
+	Issuer  string `json:"issuer"`
+	// Holder is the key id of the fingerprint of the peer.
+	Holder  string    `json:"holder"`
+	Folder  string    `json:"folder"`
+	Issued  time.Time `json:"issued"`
+	Expires time.Time `json:"expires"`
+}
+
+type signedDelegation struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// delegations is the content of delegations.json.
+type delegations struct {
+	// Received are the certificates that were issued to us.
+	Received [][]byte `json:"received"`
+}
+
+// IsExpired checks if the delegation is not valid anymore at `now`.
+func (dg *Delegation) IsExpired(now time.Time) bool {
+	return !now.Before(dg.Expires)
+}
+
+// Covers checks if `nodePath` is the delegated folder or lies below it.
+func (dg *Delegation) Covers(nodePath string) bool {
+	nodePath = path.Clean("/" + nodePath)
+	return dg.Folder == "/" || nodePath == dg.Folder || strings.HasPrefix(nodePath, dg.Folder+"/")
+}
+
+// SignDelegation encodes `dg` and signs it with `sign`,
+// which should create a detached signature with our identity key.
+func SignDelegation(dg Delegation, sign func(data []byte) ([]byte, error)) ([]byte, error) {
+	if !strings.HasPrefix(dg.Folder, "/") {
+		return nil, e.Errorf("delegated folder must be absolute: %s", dg.Folder)
+	}
+
+	if dg.Holder == "" {
+		return nil, e.New("delegation has no holder")
+	}
+
+	dg.Version = delegationVersion
+	dg.Folder = path.Clean(dg.Folder)
+	payload, err := json.Marshal(dg)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(payload)
+	if err != nil {
+		return nil, e.Wrap(err, "failed to sign delegation")
+	}
+
+	return json.Marshal(signedDelegation{Payload: payload, Signature: sig})
+}
+
+// ParseDelegation decodes a certificate made by SignDelegation
+// without checking its signature. Use it to find out the issuer.
+func ParseDelegation(data []byte) (*Delegation, error) {
+	if len(data) > MaxDelegationSize {
+		return nil, e.Errorf("delegation is too big (%d bytes)", len(data))
+	}
+
+	signed := signedDelegation{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, e.Wrap(err, "bad delegation")
+	}
+
+	dg := &Delegation{}
+	if err := json.Unmarshal(signed.Payload, dg); err != nil {
+		return nil, e.Wrap(err, "bad delegation payload")
+	}
+
+	if dg.Version != delegationVersion {
+		return nil, e.Errorf("unsupported delegation version: %d", dg.Version)
+	}
+
+	if !strings.HasPrefix(dg.Folder, "/") || path.Clean(dg.Folder) != dg.Folder {
+		return nil, e.Errorf("bad delegated folder: %s", dg.Folder)
+	}
+
+	return dg, nil
+}
+
+// VerifyDelegation decodes a certificate made by SignDelegation
+// and checks that it was signed by `pubKey`.
+func VerifyDelegation(data []byte, pubKey []byte) (*Delegation, error) {
+	dg, err := ParseDelegation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := signedDelegation{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, e.Wrap(err, "bad delegation")
+	}
+
+	if err := VerifyIdentitySignature(pubKey, signed.Payload, signed.Signature); err != nil {
+		return nil, ErrBadDelegationSignature
+	}
+
+	return dg, nil
+}
+
+func (rp *Repository) loadDelegations() (*delegations, error) {
+	dgs := &delegations{}
+	data, err := ioutil.ReadFile(filepath.Join(rp.BaseFolder, delegationsName)) // #nosec
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, dgs); err != nil {
+			return nil, e.Wrapf(err, "bad %s", delegationsName)
+		}
+	}
+
+	return dgs, nil
+}
+
+func (rp *Repository) saveDelegations(dgs *delegations) error {
+	data, err := json.MarshalIndent(dgs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(rp.BaseFolder, delegationsName), data, 0600)
+}
+
+// AddDelegation stores a certificate that was issued to us.
+// It is shown to the remotes we connect to from then on.
+// The signature can only be checked by them.
+func (rp *Repository) AddDelegation(data []byte) (*Delegation, error) {
+	dg, err := ParseDelegation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if dg.IsExpired(time.Now()) {
+		return nil, e.Errorf("delegation expired at %s", dg.Expires)
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	dgs, err := rp.loadDelegations()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, other := range dgs.Received {
+		if string(other) == string(data) {
+			return dg, nil
+		}
+	}
+
+	dgs.Received = append(dgs.Received, data)
+	return dg, rp.saveDelegations(dgs)
+}
+
+// Delegations returns all certificates that were issued to us
+// and did not expire yet. Expired ones are forgotten.
+func (rp *Repository) Delegations() ([][]byte, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	dgs, err := rp.loadDelegations()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	valid := [][]byte{}
+	for _, data := range dgs.Received {
+		dg, err := ParseDelegation(data)
+		if err != nil || dg.IsExpired(now) {
+			continue
+		}
+
+		valid = append(valid, data)
+	}
+
+	if len(valid) != len(dgs.Received) {
+		dgs.Received = valid
+		if err := rp.saveDelegations(dgs); err != nil {
+			return nil, err
+		}
+	}
+
+	return valid, nil
+}
+
+// RemoveDelegation forgets all certificates of `issuer` for `folder`.
+func (rp *Repository) RemoveDelegation(issuer, folder string) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	dgs, err := rp.loadDelegations()
+	if err != nil {
+		return err
+	}
+
+	folder = path.Clean("/" + folder)
+	kept := [][]byte{}
+	for _, data := range dgs.Received {
+		dg, err := ParseDelegation(data)
+		if err == nil && dg.Issuer == issuer && dg.Folder == folder {
+			continue
+		}
+
+		kept = append(kept, data)
+	}
+
+	if len(kept) == len(dgs.Received) {
+		return e.Errorf("no delegation of %s for %s", issuer, folder)
+	}
+
+	dgs.Received = kept
+	return rp.saveDelegations(dgs)
+}
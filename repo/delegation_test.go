@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegation(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		pubKey, err := rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+
+		data, err := SignDelegation(Delegation{
+			Issuer:  rp.Owner,
+			Holder:  "carolskey",
+			Folder:  "/photos/",
+			Issued:  time.Now(),
+			Expires: time.Now().Add(time.Hour),
+		}, rp.Keyring().SignWithIdentity)
+		require.Nil(t, err)
+
+		dg, err := VerifyDelegation(data, pubKey)
+		require.Nil(t, err)
+		require.Equal(t, "/photos", dg.Folder)
+		require.Equal(t, "carolskey", dg.Holder)
+		require.True(t, dg.Covers("/photos/2019/me.png"))
+		require.False(t, dg.Covers("/photos2"))
+		require.False(t, dg.IsExpired(time.Now()))
+		require.True(t, dg.IsExpired(time.Now().Add(2*time.Hour)))
+
+		// Nobody else signed it:
+		_, err = VerifyDelegation(data, []byte("otherkey"))
+		require.NotNil(t, err)
+
+		_, err = SignDelegation(Delegation{Holder: "carolskey", Folder: "photos"}, rp.Keyring().SignWithIdentity)
+		require.NotNil(t, err)
+
+		_, err = rp.AddDelegation(data)
+		require.Nil(t, err)
+		_, err = rp.AddDelegation(data)
+		require.Nil(t, err)
+
+		expired, err := SignDelegation(Delegation{
+			Issuer:  rp.Owner,
+			Holder:  "carolskey",
+			Folder:  "/old",
+			Expires: time.Now().Add(-time.Hour),
+		}, rp.Keyring().SignWithIdentity)
+		require.Nil(t, err)
+
+		_, err = rp.AddDelegation(expired)
+		require.NotNil(t, err)
+
+		received, err := rp.Delegations()
+		require.Nil(t, err)
+		require.Equal(t, [][]byte{data}, received)
+
+		require.NotNil(t, rp.RemoveDelegation(rp.Owner, "/other"))
+		require.Nil(t, rp.RemoveDelegation(rp.Owner, "/photos"))
+
+		received, err = rp.Delegations()
+		require.Nil(t, err)
+		require.Empty(t, received)
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
@@ -0,0 +1,230 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// featuresName is the file where the feature flags are stored.
+	// It is never locked, so it can be checked before asking for a password.
+	featuresName = "FEATURES"
+
+	// FeaturesVersion is the version of the feature record this brig writes.
+	// Increment it and add a migration when adding or changing a flag.
+	FeaturesVersion = 1
+)
+
+// Names of the known feature flags:
+const (
+	// FeatureHashAlgo lists the content hash algorithms the repo may use.
+	FeatureHashAlgo = "hash_algo"
+	// FeatureChunker is how content is split into blocks before encryption.
+	FeatureChunker = "chunker"
+	// FeatureCipher lists the ciphers encrypted content may use.
+	FeatureCipher = "cipher"
+	// FeatureDirSharding tells how large directories are stored.
+	FeatureDirSharding = "dir_sharding"
+)
+
+// knownFeatures maps every flag this version knows to its possible values.
+// Flags with several values (like hash_algo) are separated by commas.
+var knownFeatures = map[string][]string{
+	FeatureHashAlgo:    {"blake2s-256", "blake3"},
+	FeatureChunker:     {"fixed-64k"},
+	FeatureCipher:      {"aes-gcm", "chacha20-poly1305"},
+	FeatureDirSharding: {"off", "v1"},
+}
+
+// Features is the versioned record of the formats a repository uses.
+// It is written on init and changed only by »brig repo upgrade«.
+type Features struct {
+	Version int               `json:"version"`
+	Flags   map[string]string `json:"flags"`
+}
+
+// defaultFeatures are the features of a repository created by this version.
+func defaultFeatures() *Features {
+	return &Features{
+		Version: FeaturesVersion,
+		Flags: map[string]string{
+			FeatureHashAlgo:    "blake2s-256,blake3",
+			FeatureChunker:     "fixed-64k",
+			FeatureCipher:      "aes-gcm,chacha20-poly1305",
+			FeatureDirSharding: "v1",
+		},
+	}
+}
+
+// featureMigrations upgrade a record from version idx to version idx+1.
+var featureMigrations = []func(ft *Features){
+	// Repositories before version 1 had no record at all.
+	// They use the very same formats as the first version.
+	func(ft *Features) {
+		ft.Flags = defaultFeatures().Flags
+	},
+}
+
+// ErrUnsupportedFeatures is returned when a repository was created or
+// upgraded by a newer brig that uses features this version does not know.
+type ErrUnsupportedFeatures struct {
+	Version int
+	Unknown []string
+}
+
+func (err ErrUnsupportedFeatures) Error() string {
+	if len(err.Unknown) == 0 {
+		return fmt.Sprintf(
+			"repository has feature version %d, but this brig only knows up to %d; please update brig",
+			err.Version,
+			FeaturesVersion,
+		)
+	}
+
+	return fmt.Sprintf(
+		"repository uses features this brig does not know (%s); please update brig",
+		strings.Join(err.Unknown, ", "),
+	)
+}
+
+// IsErrUnsupportedFeatures checks if `err` is a ErrUnsupportedFeatures.
+func IsErrUnsupportedFeatures(err error) bool {
+	_, ok := err.(ErrUnsupportedFeatures)
+	return ok
+}
+
+// check returns an ErrUnsupportedFeatures if `ft` uses something unknown.
+func (ft *Features) check() error {
+	unknown := []string{}
+	for name, value := range ft.Flags {
+		allowed, ok := knownFeatures[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		for _, part := range strings.Split(value, ",") {
+			if !containsString(allowed, part) {
+				unknown = append(unknown, fmt.Sprintf("%s=%s", name, part))
+			}
+		}
+	}
+
+	if len(unknown) == 0 && ft.Version <= FeaturesVersion {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	return ErrUnsupportedFeatures{Version: ft.Version, Unknown: unknown}
+}
+
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadFeatures reads the feature record of the repository at `baseFolder`.
+// Repositories created before the record existed get version 0 and no flags.
+func ReadFeatures(baseFolder string) (*Features, error) {
+	data, err := ioutil.ReadFile(filepath.Join(baseFolder, featuresName)) // #nosec
+	if os.IsNotExist(err) {
+		return &Features{Flags: map[string]string{}}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ft := &Features{}
+	if err := json.Unmarshal(data, ft); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", featuresName, err)
+	}
+
+	if ft.Flags == nil {
+		ft.Flags = map[string]string{}
+	}
+
+	return ft, nil
+}
+
+func writeFeatures(baseFolder string, ft *Features) error {
+	data, err := json.MarshalIndent(ft, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write it next to the old one first, so we never leave a half one behind.
+	path := filepath.Join(baseFolder, featuresName)
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// CheckFeatures makes sure that this brig can handle the repository
+// at `baseFolder`. Old repositories without a record are accepted.
+func CheckFeatures(baseFolder string) error {
+	ft, err := ReadFeatures(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	if err := ft.check(); err != nil {
+		return err
+	}
+
+	if ft.Version < FeaturesVersion {
+		log.Warningf(
+			"repository has feature version %d (current is %d); please run »brig repo upgrade«",
+			ft.Version,
+			FeaturesVersion,
+		)
+	}
+
+	return nil
+}
+
+// UpgradeFeatures migrates the feature record at `baseFolder` to the
+// current version. It returns the version before the upgrade.
+// Records of newer versions or with unknown flags are left untouched.
+func UpgradeFeatures(baseFolder string) (int, error) {
+	ft, err := ReadFeatures(baseFolder)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := ft.check(); err != nil {
+		return ft.Version, err
+	}
+
+	oldVersion := ft.Version
+	if oldVersion == FeaturesVersion {
+		return oldVersion, nil
+	}
+
+	for ft.Version < FeaturesVersion {
+		featureMigrations[ft.Version](ft)
+		ft.Version++
+	}
+
+	// A migration that produces something we cannot read is a bug:
+	if err := ft.check(); err != nil {
+		return oldVersion, err
+	}
+
+	return oldVersion, writeFeatures(baseFolder, ft)
+}
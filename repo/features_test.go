@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeaturesUpgrade(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		ft, err := ReadFeatures(dir)
+		require.Nil(t, err)
+		require.Equal(t, defaultFeatures(), ft)
+
+		// Pretend it is a repository from before the record existed:
+		require.Nil(t, os.Remove(filepath.Join(dir, featuresName)))
+		require.Nil(t, CheckFeatures(dir))
+
+		oldVersion, err := UpgradeFeatures(dir)
+		require.Nil(t, err)
+		require.Equal(t, 0, oldVersion)
+
+		ft, err = ReadFeatures(dir)
+		require.Nil(t, err)
+		require.Equal(t, defaultFeatures(), ft)
+
+		// Upgrading twice does nothing:
+		oldVersion, err = UpgradeFeatures(dir)
+		require.Nil(t, err)
+		require.Equal(t, FeaturesVersion, oldVersion)
+
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
+
+func TestFeaturesUnknown(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		path := filepath.Join(dir, featuresName)
+		data := []byte(`{"version": 1, "flags": {"hash_algo": "blake2s-256,sha4", "chunker": "fixed-64k", "teleport": "on"}}`)
+		require.Nil(t, ioutil.WriteFile(path, data, 0644))
+
+		err := CheckFeatures(dir)
+		require.True(t, IsErrUnsupportedFeatures(err))
+		require.Equal(t, []string{"hash_algo=sha4", "teleport"}, err.(ErrUnsupportedFeatures).Unknown)
+
+		_, err = Open(dir, "klaus")
+		require.True(t, IsErrUnsupportedFeatures(err))
+
+		// The record of a newer brig is not touched:
+		_, err = UpgradeFeatures(dir)
+		require.True(t, IsErrUnsupportedFeatures(err))
+
+		stored, err := ioutil.ReadFile(path)
+		require.Nil(t, err)
+		require.Equal(t, data, stored)
+
+		data = []byte(`{"version": 2, "flags": {}}`)
+		require.Nil(t, ioutil.WriteFile(path, data, 0644))
+		require.True(t, IsErrUnsupportedFeatures(CheckFeatures(dir)))
+	})
+}
@@ -26,9 +26,6 @@ func (rp *Repository) GC(backend Backend, aggressive bool) (map[string]map[strin
 
 	result := make(map[string]map[string]h.Hash)
 	if len(killed) == 0 {
-		// Shortcut, since running the loop below
-		// is currently rather expensive due to FilesByContents.
-		// We can optimize that if it turns out to be a problem.
 		return result, nil
 	}
 
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/util/sshkey"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Possible values of repo.identity.source.
+const (
+	// IdentityGPG uses the gpg key pair created by "brig init".
+	IdentityGPG = "gpg"
+	// IdentitySSHAgent signs with an ed25519 key of a running ssh-agent.
+	IdentitySSHAgent = "ssh-agent"
+	// IdentitySSHKey signs with an unencrypted ed25519 OpenSSH key file.
+	IdentitySSHKey = "ssh-key"
+)
+
+// ErrIdentityCannotDecrypt is returned by Keyring.Decrypt when the identity
+// is an SSH key. Those can only sign, so remotes have to authenticate us
+// by signature instead of sending an encrypted challenge.
+var ErrIdentityCannotDecrypt = errors.New("ssh identities can only sign, not decrypt")
+
+// HasSSHIdentity returns true if the identity is an SSH key.
+// The private key is not stored in the keyring then.
+func (kp *Keyring) HasSSHIdentity() bool {
+	return kp.identitySource == IdentitySSHAgent || kp.identitySource == IdentitySSHKey
+}
+
+// sshSigner returns the signer for our SSH identity.
+func (kp *Keyring) sshSigner() (sshkey.Signer, error) {
+	switch kp.identitySource {
+	case IdentitySSHKey:
+		if kp.sshKeyPath == "" {
+			return nil, e.New("repo.identity.ssh_key needs to be set for ssh-key identities")
+		}
+
+		data, err := ioutil.ReadFile(kp.sshKeyPath) // #nosec
+		if err != nil {
+			return nil, err
+		}
+
+		return sshkey.ParsePrivateKey(data)
+	case IdentitySSHAgent:
+		var pubBlob []byte
+		if kp.sshKeyPath != "" {
+			line, err := ioutil.ReadFile(kp.sshKeyPath) // #nosec
+			if err != nil {
+				return nil, err
+			}
+
+			if pubBlob, err = sshkey.ParseAuthorizedKey(line); err != nil {
+				return nil, e.Wrapf(err, "bad public key in %s", kp.sshKeyPath)
+			}
+		}
+
+		return sshkey.NewAgentSigner(pubBlob)
+	default:
+		return nil, e.Errorf("not an ssh identity: %s", kp.identitySource)
+	}
+}
+
+// VerifyIdentitySignature checks that `sig` is a signature of `data`
+// made by SignWithIdentity of the owner of `pubKey`.
+// Both gpg and SSH identities are supported.
+func VerifyIdentitySignature(pubKey, data, sig []byte) error {
+	if sshkey.IsPubKey(pubKey) {
+		return sshkey.Verify(pubKey, data, sig)
+	}
+
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(pubKey))
+	if err != nil {
+		return err
+	}
+
+	_, err = openpgp.CheckDetachedSignature(ents, bytes.NewReader(data), bytes.NewReader(sig))
+	return err
+}
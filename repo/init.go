@@ -78,6 +78,11 @@ func Init(baseFolder, owner, password, backendName string, daemonPort int64) err
 		return err
 	}
 
+	// Remember which formats this repository uses:
+	if err := writeFeatures(baseFolder, defaultFeatures()); err != nil {
+		return e.Wrapf(err, "Failed to write %s", featuresName)
+	}
+
 	// Create a default config, only with the default keys applied:
 	cfg, err := config.Open(nil, defaults.Defaults, config.StrictnessPanic)
 	if err != nil {
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sahib/brig/util/keychain"
+)
+
+// keychainAccount returns the account the password of the repository at
+// `basePath` is stored under. Nothing inside the repository can be read
+// before it is unlocked, so its absolute path is used. A moved repository
+// needs to store its password again.
+func keychainAccount(basePath string) (string, error) {
+	return filepath.Abs(basePath)
+}
+
+func openKeychain(basePath, provider string) (keychain.Provider, string, error) {
+	account, err := keychainAccount(basePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kc, err := keychain.New(provider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return kc, account, nil
+}
+
+// StorePasswordInKeychain remembers the password of the repository at
+// `basePath` in the OS keychain `provider`. This allows the daemon to
+// start without asking for the password ("machine mode").
+// The password is checked before it is stored.
+func StorePasswordInKeychain(basePath, provider, password string) error {
+	if err := CheckPassword(basePath, password); err != nil {
+		return err
+	}
+
+	kc, account, err := openKeychain(basePath, provider)
+	if err != nil {
+		return err
+	}
+
+	label := fmt.Sprintf("brig repository at %s", account)
+	return kc.Store(account, label, password)
+}
+
+// ReadPasswordFromKeychain returns the password of the repository at
+// `basePath` that was stored by StorePasswordInKeychain.
+func ReadPasswordFromKeychain(basePath, provider string) (string, error) {
+	kc, account, err := openKeychain(basePath, provider)
+	if err != nil {
+		return "", err
+	}
+
+	return kc.Load(account)
+}
+
+// RemovePasswordFromKeychain forgets the password stored by StorePasswordInKeychain.
+func RemovePasswordFromKeychain(basePath, provider string) error {
+	kc, account, err := openKeychain(basePath, provider)
+	if err != nil {
+		return err
+	}
+
+	return kc.Delete(account)
+}
@@ -64,9 +64,10 @@ func encryptAsymmetric(data, pubKey []byte) ([]byte, error) {
 }
 
 // decryptAsymetric uses the private key from `folder` to decrypt `data`.
+// `extra` are additional private keys that may be used (e.g. subkeys).
 // This is not an efficient method and is not supposed to be used for large
 // amounts of data.
-func decryptAsymetric(folder string, data []byte) ([]byte, error) {
+func decryptAsymetric(folder string, data []byte, extra openpgp.EntityList) ([]byte, error) {
 	prvPath := filepath.Join(folder, "gpg.prv")
 	fd, err := os.Open(prvPath) // #nosec
 	if err != nil {
@@ -80,6 +81,7 @@ func decryptAsymetric(folder string, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	ents = append(ents, extra...)
 	md, err := openpgp.ReadMessage(bytes.NewReader(data), ents, nil, nil)
 	if err != nil {
 		return nil, err
@@ -92,10 +94,19 @@ func decryptAsymetric(folder string, data []byte) ([]byte, error) {
 // pubkeys of other remotes.
 type Keyring struct {
 	folder string
+
+	// noSubkeys is set when subkeys should not be offered or used.
+	noSubkeys bool
+
+	// identitySource is one of the Identity* constants.
+	identitySource string
+
+	// sshKeyPath is the key file used by SSH identities.
+	sshKeyPath string
 }
 
 func newKeyringHandle(folder string) *Keyring {
-	return &Keyring{folder: folder}
+	return &Keyring{folder: folder, identitySource: IdentityGPG}
 }
 
 // Encrypt `data` with `pubKey`.
@@ -107,15 +118,35 @@ func (kp *Keyring) Encrypt(data, pubKey []byte) ([]byte, error) {
 	return encryptAsymmetric(data, pubKey)
 }
 
-// Decrypt decrypts a message encrypted with our public key.
+// Decrypt decrypts a message encrypted with our public key
+// or with the public key of one of our valid session subkeys.
 // This is not an efficient method and is not supposed to be used for large
 // amounts of data.
 func (kp *Keyring) Decrypt(data []byte) ([]byte, error) {
-	return decryptAsymetric(kp.folder, data)
+	if kp.HasSSHIdentity() {
+		return nil, ErrIdentityCannotDecrypt
+	}
+
+	sessionEnts, err := kp.sessionEntities()
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAsymetric(kp.folder, data, sessionEnts)
 }
 
 // OwnPubKey returns an exported version of our own public key.
+// For SSH identities this is the key in SSH wire format.
 func (kp *Keyring) OwnPubKey() ([]byte, error) {
+	if kp.HasSSHIdentity() {
+		signer, err := kp.sshSigner()
+		if err != nil {
+			return nil, err
+		}
+
+		return signer.PubKey(), nil
+	}
+
 	pubPath := filepath.Join(kp.folder, "gpg.pub")
 	return ioutil.ReadFile(pubPath) // #nosec
 }
@@ -136,3 +167,31 @@ func (kp *Keyring) SavePubKey(name string, pubKey []byte) error {
 	pubKeyPath := filepath.Join(base, filepath.Clean(name))
 	return ioutil.WriteFile(pubKeyPath, pubKey, 0600)
 }
+
+// SignWithIdentity creates a detached signature of `data` with our identity
+// key. Other than Sign() this does not need a subkey; it is meant for the
+// few things that need to be checked against the fingerprint directly.
+// SSH identities sign via the ssh-agent or the key file; use
+// VerifyIdentitySignature to check the result in both cases.
+func (kp *Keyring) SignWithIdentity(data []byte) ([]byte, error) {
+	if kp.HasSSHIdentity() {
+		signer, err := kp.sshSigner()
+		if err != nil {
+			return nil, err
+		}
+
+		return signer.Sign(data)
+	}
+
+	identity, err := readEntity(filepath.Join(kp.folder, "gpg.prv"))
+	if err != nil {
+		return nil, err
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, identity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return sigBuf.Bytes(), nil
+}
@@ -1,10 +1,14 @@
 package repo
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/sahib/brig/util/sshkey"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,3 +39,32 @@ func TestKeyring(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, remotePubKey, []byte{1})
 }
+
+func TestKeyringSSHIdentity(t *testing.T) {
+	testDir := filepath.Join(os.TempDir(), "brig-repo-ssh-key-test")
+	require.Nil(t, os.MkdirAll(testDir, 0755))
+	defer os.RemoveAll(testDir)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	keyPath := filepath.Join(testDir, "id_ed25519")
+	require.Nil(t, ioutil.WriteFile(keyPath, sshkey.MarshalPrivateKey(priv, "alice"), 0600))
+
+	kr := newKeyringHandle(testDir)
+	kr.identitySource = IdentitySSHKey
+	kr.sshKeyPath = keyPath
+	require.True(t, kr.HasSSHIdentity())
+
+	ownPubKey, err := kr.OwnPubKey()
+	require.Nil(t, err)
+	require.Equal(t, sshkey.MarshalPubKey(priv.Public().(ed25519.PublicKey)), ownPubKey)
+
+	sig, err := kr.SignWithIdentity([]byte("hello"))
+	require.Nil(t, err)
+	require.Nil(t, VerifyIdentitySignature(ownPubKey, []byte("hello"), sig))
+	require.NotNil(t, VerifyIdentitySignature(ownPubKey, []byte("hellO"), sig))
+
+	_, err = kr.Decrypt([]byte("whatever"))
+	require.Equal(t, ErrIdentityCannotDecrypt, err)
+}
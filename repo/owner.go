@@ -0,0 +1,344 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/net/peer"
+	log "github.com/sirupsen/logrus"
+)
+
+// The owner of a repository may be renamed, e.g. after a domain change.
+// Remotes only know us by name, so the rename is announced to them with a
+// migration record that is signed by our identity key. They check it with
+// the key they stored when adding us and rename their remote entry.
+// The keys of the locked files are derived from the name the repository
+// was created with, which is kept in LOCK_SALT once the owner was renamed.
+
+const (
+	lockSaltName   = "LOCK_SALT"
+	migrationsName = "migrations.json"
+
+	identityMigrationVersion = 1
+
+	// maxIdentityMigrationSize limits what we read from the network.
+	maxIdentityMigrationSize = 64 * 1024
+)
+
+var (
+	// ErrBadMigrationSignature is returned for migration records
+	// that were not signed by the key of the renamed remote.
+	ErrBadMigrationSignature = e.New("identity migration is not signed by the remote")
+)
+
+// IdentityMigration states that the owner `Old` is called `New` since `Time`.
+type IdentityMigration struct {
+	Version int       `json:"version"`
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+	Time    time.Time `json:"time"`
+}
+
+type signedIdentityMigration struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// identityMigrations is the content of migrations.json.
+type identityMigrations struct {
+	// Records are all signed renames of the owner, oldest first.
+	Records [][]byte `json:"records"`
+
+	// Notified is the number of records each remote received.
+	Notified map[string]int `json:"notified"`
+}
+
+// readLockSalt returns what the keys of the locked files are derived from.
+func readLockSalt(baseFolder string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(baseFolder, lockSaltName)) // #nosec
+	if err == nil {
+		return string(data), nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// Never renamed; the owner is the salt.
+	data, err = ioutil.ReadFile(filepath.Join(baseFolder, "OWNER")) // #nosec
+	if err != nil {
+		return "", e.Wrap(err, "failed to read OWNER")
+	}
+
+	return string(data), nil
+}
+
+// SignIdentityMigration encodes `mig` and signs it with `sign`,
+// which should create a detached signature with our identity key.
+func SignIdentityMigration(mig IdentityMigration, sign func(data []byte) ([]byte, error)) ([]byte, error) {
+	mig.Version = identityMigrationVersion
+	payload, err := json.Marshal(mig)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(payload)
+	if err != nil {
+		return nil, e.Wrap(err, "failed to sign identity migration")
+	}
+
+	return json.Marshal(signedIdentityMigration{Payload: payload, Signature: sig})
+}
+
+// VerifyIdentityMigration decodes a record made by SignIdentityMigration
+// and checks that it was signed by `pubKey`.
+func VerifyIdentityMigration(data []byte, pubKey []byte) (*IdentityMigration, error) {
+	if len(data) > maxIdentityMigrationSize {
+		return nil, e.Errorf("identity migration is too big (%d bytes)", len(data))
+	}
+
+	signed := signedIdentityMigration{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, e.Wrap(err, "bad identity migration")
+	}
+
+	if err := VerifyIdentitySignature(pubKey, signed.Payload, signed.Signature); err != nil {
+		return nil, ErrBadMigrationSignature
+	}
+
+	mig := &IdentityMigration{}
+	if err := json.Unmarshal(signed.Payload, mig); err != nil {
+		return nil, e.Wrap(err, "bad identity migration payload")
+	}
+
+	if mig.Version != identityMigrationVersion {
+		return nil, e.Errorf("unsupported identity migration version: %d", mig.Version)
+	}
+
+	if !peer.IsValid(mig.New) {
+		return nil, e.Errorf("identity migration to invalid name: %s", mig.New)
+	}
+
+	return mig, nil
+}
+
+func (rp *Repository) loadMigrations() (*identityMigrations, error) {
+	migs := &identityMigrations{}
+	data, err := ioutil.ReadFile(filepath.Join(rp.BaseFolder, migrationsName)) // #nosec
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, migs); err != nil {
+			return nil, e.Wrapf(err, "bad %s", migrationsName)
+		}
+	}
+
+	if migs.Notified == nil {
+		migs.Notified = make(map[string]int)
+	}
+
+	return migs, nil
+}
+
+func (rp *Repository) saveMigrations(migs *identityMigrations) error {
+	data, err := json.MarshalIndent(migs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(rp.BaseFolder, migrationsName), data, 0600)
+}
+
+// PendingMigrations returns the signed renames of the owner
+// that were not sent to `remote` yet, oldest first.
+func (rp *Repository) PendingMigrations(remote string) ([][]byte, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	migs, err := rp.loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	sent := migs.Notified[remote]
+	if sent >= len(migs.Records) {
+		return nil, nil
+	}
+
+	return migs.Records[sent:], nil
+}
+
+// MarkMigrationsSent remembers that `remote` received `n` more records.
+func (rp *Repository) MarkMigrationsSent(remote string, n int) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	migs, err := rp.loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	migs.Notified[remote] += n
+	if migs.Notified[remote] > len(migs.Records) {
+		migs.Notified[remote] = len(migs.Records)
+	}
+
+	return rp.saveMigrations(migs)
+}
+
+// renameFS closes the filesystem of `oldName` and moves its metadata.
+// rp.mu needs to be held.
+func (rp *Repository) renameFS(oldName, newName string) error {
+	if fs, ok := rp.fsMap[oldName]; ok {
+		if err := fs.Close(); err != nil {
+			log.Warningf("failed to close fs of %s: %v", oldName, err)
+		}
+
+		delete(rp.fsMap, oldName)
+	}
+
+	metadataDir := filepath.Join(rp.BaseFolder, "metadata")
+	err := os.Rename(filepath.Join(metadataDir, oldName), filepath.Join(metadataDir, newName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (rp *Repository) checkNewName(name string) error {
+	if !peer.IsValid(name) {
+		return fmt.Errorf("invalid name: %s", name)
+	}
+
+	if name == rp.Owner {
+		return fmt.Errorf("the owner is already called %s", name)
+	}
+
+	if _, err := rp.Remotes.Remote(name); err == nil {
+		return fmt.Errorf("there is already a remote called %s", name)
+	}
+
+	if rp.HaveFS(name) {
+		return fmt.Errorf("there is already metadata for %s", name)
+	}
+
+	return nil
+}
+
+// RenameOwner renames the owner of the repository to `newOwner`.
+// A signed migration record is stored for every remote; see PendingMigrations.
+// The new name is published once the daemon starts the next time.
+func (rp *Repository) RenameOwner(newOwner string) error {
+	if err := rp.checkNewName(newOwner); err != nil {
+		return err
+	}
+
+	oldOwner := rp.Owner
+	record, err := SignIdentityMigration(IdentityMigration{
+		Old:  oldOwner,
+		New:  newOwner,
+		Time: time.Now(),
+	}, rp.Keyring().SignWithIdentity)
+
+	if err != nil {
+		return err
+	}
+
+	// Pin the salt before OWNER changes; the locked files depend on it.
+	salt, err := readLockSalt(rp.BaseFolder)
+	if err != nil {
+		return err
+	}
+
+	saltPath := filepath.Join(rp.BaseFolder, lockSaltName)
+	if err := ioutil.WriteFile(saltPath, []byte(salt), 0644); err != nil {
+		return err
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	migs, err := rp.loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if err := rp.renameFS(oldOwner, newOwner); err != nil {
+		return err
+	}
+
+	ownerPath := filepath.Join(rp.BaseFolder, "OWNER")
+	if err := ioutil.WriteFile(ownerPath, []byte(newOwner), 0644); err != nil {
+		return err
+	}
+
+	rp.Owner = newOwner
+	if rp.CurrentUser() == oldOwner {
+		rp.SetCurrentUser(newOwner)
+	}
+
+	migs.Records = append(migs.Records, record)
+	return rp.saveMigrations(migs)
+}
+
+// RenameRemote renames the remote `oldName` to `newName`,
+// including its public key and its metadata.
+func (rp *Repository) RenameRemote(oldName, newName string) error {
+	if err := rp.checkNewName(newName); err != nil {
+		return err
+	}
+
+	if _, err := rp.Remotes.Remote(oldName); err != nil {
+		return err
+	}
+
+	kr := rp.Keyring()
+	pubKey, err := kr.PubKeyFor(oldName)
+	if err != nil {
+		return e.Wrapf(err, "no public key for %s", oldName)
+	}
+
+	if err := kr.SavePubKey(newName, pubKey); err != nil {
+		return err
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if err := rp.renameFS(oldName, newName); err != nil {
+		return err
+	}
+
+	if err := rp.Remotes.RenameRemote(oldName, newName); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(rp.BaseFolder, "pubkeys", filepath.Clean(oldName))); err != nil {
+		log.Warningf("failed to remove old public key of %s: %v", oldName, err)
+	}
+
+	if rp.CurrentUser() == oldName {
+		rp.SetCurrentUser(newName)
+	}
+
+	migs, err := rp.loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if n, ok := migs.Notified[oldName]; ok {
+		delete(migs.Notified, oldName)
+		migs.Notified[newName] = n
+		return rp.saveMigrations(migs)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sahib/brig/net/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameOwner(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		aliceDir := filepath.Join(dir, "metadata", "alice")
+		require.Nil(t, os.MkdirAll(aliceDir, 0700))
+		require.Nil(t, ioutil.WriteFile(filepath.Join(aliceDir, "db"), []byte("x"), 0600))
+
+		require.NotNil(t, rp.RenameOwner("alice"))
+		require.NotNil(t, rp.RenameOwner("not valid/"))
+		require.Nil(t, rp.RenameOwner("alice@new.org"))
+		require.Equal(t, "alice@new.org", rp.Owner)
+		require.Equal(t, "alice@new.org", rp.CurrentUser())
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "metadata", "alice@new.org", "db"))
+		require.Nil(t, err)
+		require.Equal(t, []byte("x"), data)
+
+		records, err := rp.PendingMigrations("bob")
+		require.Nil(t, err)
+		require.Len(t, records, 1)
+
+		pubKey, err := rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+
+		mig, err := VerifyIdentityMigration(records[0], pubKey)
+		require.Nil(t, err)
+		require.Equal(t, "alice", mig.Old)
+		require.Equal(t, "alice@new.org", mig.New)
+
+		require.Nil(t, rp.MarkMigrationsSent("bob", len(records)))
+		records, err = rp.PendingMigrations("bob")
+		require.Nil(t, err)
+		require.Len(t, records, 0)
+		require.Nil(t, rp.Close("klaus"))
+
+		// The password still works and so does changing it:
+		require.Nil(t, CheckPassword(dir, "klaus"))
+		require.Nil(t, ChangePassword(dir, "klaus", "karl", ""))
+
+		rp, err = Open(dir, "karl")
+		require.Nil(t, err)
+		require.Equal(t, "alice@new.org", rp.Owner)
+		require.Nil(t, rp.Close("karl"))
+	})
+}
+
+func TestRenameRemote(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		require.Nil(t, rp.Remotes.AddOrUpdateRemote(Remote{
+			Name:              "bob",
+			Fingerprint:       peer.Fingerprint("bobsfp"),
+			AcceptAutoUpdates: true,
+		}))
+		require.Nil(t, rp.Keyring().SavePubKey("bob", []byte("bobskey")))
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "metadata", "bob"), 0700))
+
+		require.NotNil(t, rp.RenameRemote("bob", "alice"))
+		require.NotNil(t, rp.RenameRemote("nobody", "dave"))
+		require.Nil(t, rp.RenameRemote("bob", "bob@new.org"))
+
+		_, err = rp.Remotes.Remote("bob")
+		require.Equal(t, ErrNoSuchRemote, err)
+
+		rmt, err := rp.Remotes.Remote("bob@new.org")
+		require.Nil(t, err)
+		require.True(t, rmt.AcceptAutoUpdates)
+
+		pubKey, err := rp.Keyring().PubKeyFor("bob@new.org")
+		require.Nil(t, err)
+		require.Equal(t, []byte("bobskey"), pubKey)
+
+		require.True(t, rp.HaveFS("bob@new.org"))
+		require.False(t, rp.HaveFS("bob"))
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
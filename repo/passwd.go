@@ -0,0 +1,323 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs/mio/encrypt"
+	"github.com/sahib/brig/util"
+	log "github.com/sirupsen/logrus"
+	yml "gopkg.in/yaml.v2"
+)
+
+const (
+	// PasswdJournalName is the default name of the recovery file that is
+	// written to the repository during a password change.
+	PasswdJournalName = "PASSWD_JOURNAL"
+
+	// rewrapSuffix is appended to the re-encrypted copy of a locked file
+	// until the password change is committed.
+	rewrapSuffix = ".rewrap"
+)
+
+var (
+	// ErrPasswordChangePending is returned when a previous password change
+	// was interrupted and needs to be recovered first.
+	ErrPasswordChangePending = errors.New(
+		"a previous password change was interrupted; please recover it first",
+	)
+
+	// ErrRepoInUse is returned when trying to change the password of a
+	// repository that is currently unlocked (i.e. a daemon is running).
+	ErrRepoInUse = errors.New(
+		"repository is unlocked; is a daemon still running?",
+	)
+)
+
+// passwdJournal is written before the first re-wrapped file is renamed into
+// its final place. If it exists, all files in `Files` are guaranteed to have
+// a completely written `<file>.rewrap` counterpart (or were already renamed).
+type passwdJournal struct {
+	BaseFolder string   `yaml:"base_folder"`
+	Files      []string `yaml:"files"`
+}
+
+func journalPathOrDefault(baseFolder, journalPath string) string {
+	if journalPath == "" {
+		return filepath.Join(baseFolder, PasswdJournalName)
+	}
+
+	return journalPath
+}
+
+// checkIsLocked makes sure that no plain-text files are lying around that
+// would be locked again with the old password by a running daemon.
+func checkIsLocked(baseFolder string) error {
+	// A freshly initialized repository was never locked.
+	// The only locked file in it is passwd.locked.
+	if _, err := os.Stat(filepath.Join(baseFolder, "INIT_TAG")); err == nil {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		path := filepath.Join(baseFolder, info.Name())
+		if strings.HasSuffix(path, LockPathSuffix) {
+			continue
+		}
+
+		if isExcluded(path, excludedFromLock) {
+			continue
+		}
+
+		return ErrRepoInUse
+	}
+
+	return nil
+}
+
+func rewrapFile(path string, oldKey, newKey []byte) error {
+	srcFd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(srcFd)
+
+	encR, err := encrypt.NewReader(srcFd, oldKey)
+	if err != nil {
+		return err
+	}
+
+	rewrapPath := path + rewrapSuffix
+	dstFd, err := os.OpenFile(rewrapPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(dstFd)
+
+	encW, err := encrypt.NewWriter(dstFd, newKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(encW, encR); err != nil {
+		return err
+	}
+
+	if err := encW.Close(); err != nil {
+		return err
+	}
+
+	// Make sure the data hit the disk before we rely on it.
+	return dstFd.Sync()
+}
+
+func writeJournal(journalPath string, journal *passwdJournal) error {
+	data, err := yml.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	// Write it to a temp file first, so the journal is either
+	// fully there or not there at all.
+	tmpPath := journalPath + ".tmp"
+	fd, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fd.Write(data); err != nil {
+		fd.Close()
+		return err
+	}
+
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		return err
+	}
+
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, journalPath)
+}
+
+func readJournal(journalPath string) (*passwdJournal, error) {
+	data, err := ioutil.ReadFile(journalPath) // #nosec
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &passwdJournal{}
+	if err := yml.Unmarshal(data, journal); err != nil {
+		return nil, e.Wrapf(err, "failed to parse journal")
+	}
+
+	return journal, nil
+}
+
+func applyJournal(journal *passwdJournal) error {
+	for _, name := range journal.Files {
+		path := filepath.Join(journal.BaseFolder, name)
+		rewrapPath := path + rewrapSuffix
+		if _, err := os.Stat(rewrapPath); os.IsNotExist(err) {
+			// Was already renamed before we were interrupted.
+			continue
+		}
+
+		if err := os.Rename(rewrapPath, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeRewrapFiles(baseFolder string) error {
+	files, err := ioutil.ReadDir(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		if !strings.HasSuffix(info.Name(), rewrapSuffix) {
+			continue
+		}
+
+		path := filepath.Join(baseFolder, info.Name())
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsPasswordChangePending returns true when a password change was interrupted
+// and the journal at `journalPath` (or the default journal, if empty) exists.
+func IsPasswordChangePending(baseFolder, journalPath string) bool {
+	journalPath = journalPathOrDefault(baseFolder, journalPath)
+	_, err := os.Stat(journalPath)
+	return err == nil
+}
+
+// ChangePassword changes the password of the repository at `baseFolder` from
+// `oldPassword` to `newPassword`. Only the locked files in the repository
+// root are re-encrypted with the new key; the content in the backend is
+// not touched, since it uses its own per-file keys.
+//
+// The change is done in two phases: first all files are re-encrypted to
+// temporary files, then a journal is written to `journalPath` (or into the
+// repository if empty) and the temporary files are renamed over the old
+// ones. If the process is interrupted, RecoverPasswordChange can be used
+// to either finish or roll back the change.
+//
+// The repository must be locked (i.e. no daemon may run on it).
+func ChangePassword(baseFolder, oldPassword, newPassword, journalPath string) error {
+	journalPath = journalPathOrDefault(baseFolder, journalPath)
+	if IsPasswordChangePending(baseFolder, journalPath) {
+		return ErrPasswordChangePending
+	}
+
+	if err := CheckPassword(baseFolder, oldPassword); err != nil {
+		return err
+	}
+
+	if err := checkIsLocked(baseFolder); err != nil {
+		return err
+	}
+
+	salt, err := readLockSalt(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	oldKey := keyFromPassword(salt, oldPassword)
+	newKey := keyFromPassword(salt, newPassword)
+
+	files, err := ioutil.ReadDir(baseFolder)
+	if err != nil {
+		return err
+	}
+
+	journal := &passwdJournal{BaseFolder: baseFolder}
+	for _, info := range files {
+		if !info.Mode().IsRegular() || !strings.HasSuffix(info.Name(), LockPathSuffix) {
+			continue
+		}
+
+		path := filepath.Join(baseFolder, info.Name())
+		log.Debugf("re-wrapping %s", path)
+		if err := rewrapFile(path, oldKey, newKey); err != nil {
+			// Leave the repository like we found it:
+			if rmErr := removeRewrapFiles(baseFolder); rmErr != nil {
+				log.Warningf("failed to clean up after failed re-wrap: %v", rmErr)
+			}
+
+			return e.Wrapf(err, "failed to re-wrap %s", path)
+		}
+
+		journal.Files = append(journal.Files, info.Name())
+	}
+
+	// From here on, the change is committed.
+	// An interruption will be rolled forward by RecoverPasswordChange.
+	if err := writeJournal(journalPath, journal); err != nil {
+		if rmErr := removeRewrapFiles(baseFolder); rmErr != nil {
+			log.Warningf("failed to clean up after failed journal write: %v", rmErr)
+		}
+
+		return e.Wrapf(err, "failed to write journal")
+	}
+
+	if err := applyJournal(journal); err != nil {
+		return e.Wrapf(err, "failed to apply journal %s", journalPath)
+	}
+
+	return os.Remove(journalPath)
+}
+
+// RecoverPasswordChange finishes a password change that was interrupted.
+// If a journal exists at `journalPath` (or the default location if empty)
+// the change is rolled forward, i.e. the new password will be valid.
+// Otherwise left-over temporary files are removed and the old password
+// stays valid.
+func RecoverPasswordChange(baseFolder, journalPath string) error {
+	journalPath = journalPathOrDefault(baseFolder, journalPath)
+	if !IsPasswordChangePending(baseFolder, journalPath) {
+		log.Infof("no journal found; rolling back to the old password")
+		return removeRewrapFiles(baseFolder)
+	}
+
+	journal, err := readJournal(journalPath)
+	if err != nil {
+		return err
+	}
+
+	if journal.BaseFolder != baseFolder {
+		return fmt.Errorf(
+			"journal belongs to »%s«, not to »%s«",
+			journal.BaseFolder,
+			baseFolder,
+		)
+	}
+
+	if err := applyJournal(journal); err != nil {
+		return err
+	}
+
+	return os.Remove(journalPath)
+}
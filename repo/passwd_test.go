@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sahib/brig/util/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func withLockedRepo(t *testing.T, fn func(dir string)) {
+	withTempDir(t, func(dir string) {
+		require.Nil(t, Init(dir, "alice", "klaus", "mock", 6666))
+
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+		require.Nil(t, rp.Close("klaus"))
+
+		fn(dir)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		require.Nil(t, ChangePassword(dir, "klaus", "karl", ""))
+		require.Equal(t, ErrBadPassword, CheckPassword(dir, "klaus"))
+		require.Nil(t, CheckPassword(dir, "karl"))
+		require.False(t, IsPasswordChangePending(dir, ""))
+
+		rp, err := Open(dir, "karl")
+		require.Nil(t, err)
+
+		pubKey, err := rp.Keyring().OwnPubKey()
+		require.Nil(t, err)
+		require.NotEmpty(t, pubKey)
+		require.Nil(t, rp.Close("karl"))
+	})
+}
+
+func TestChangePasswordBadPassword(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		require.Equal(t, ErrBadPassword, ChangePassword(dir, "wrong", "karl", ""))
+		require.Nil(t, CheckPassword(dir, "klaus"))
+	})
+}
+
+func TestChangePasswordRepoInUse(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		require.Equal(t, ErrRepoInUse, ChangePassword(dir, "klaus", "karl", ""))
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
+
+func TestRecoverPasswordChange(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		journalPath := filepath.Join(dir, "..", filepath.Base(dir)+".journal")
+		defer os.Remove(journalPath)
+
+		// Simulate a change that was interrupted after the journal was written:
+		owner, err := ioutil.ReadFile(filepath.Join(dir, "OWNER"))
+		require.Nil(t, err)
+
+		oldKey := keyFromPassword(string(owner), "klaus")
+		newKey := keyFromPassword(string(owner), "karl")
+
+		journal := &passwdJournal{BaseFolder: dir}
+		for _, name := range []string{"passwd.locked", "remotes.yml.locked"} {
+			require.Nil(t, rewrapFile(filepath.Join(dir, name), oldKey, newKey))
+			journal.Files = append(journal.Files, name)
+		}
+
+		require.Nil(t, writeJournal(journalPath, journal))
+		require.True(t, IsPasswordChangePending(dir, journalPath))
+		require.Equal(t, ErrPasswordChangePending, ChangePassword(dir, "klaus", "karl", journalPath))
+
+		require.Nil(t, RecoverPasswordChange(dir, journalPath))
+		require.False(t, IsPasswordChangePending(dir, journalPath))
+		require.Nil(t, CheckPassword(dir, "karl"))
+	})
+}
+
+func TestRecoverPasswordChangeRollback(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		owner, err := ioutil.ReadFile(filepath.Join(dir, "OWNER"))
+		require.Nil(t, err)
+
+		// Interrupted before the journal was written:
+		passwdPath := filepath.Join(dir, "passwd.locked")
+		oldKey := keyFromPassword(string(owner), "klaus")
+		newKey := keyFromPassword(string(owner), "karl")
+		require.Nil(t, rewrapFile(passwdPath, oldKey, newKey))
+
+		require.Nil(t, RecoverPasswordChange(dir, ""))
+		_, err = os.Stat(passwdPath + rewrapSuffix)
+		require.True(t, os.IsNotExist(err))
+		require.Nil(t, CheckPassword(dir, "klaus"))
+	})
+}
+
+type memoryKeychain map[string]string
+
+func (mk memoryKeychain) Store(account, label, secret string) error {
+	mk[account] = secret
+	return nil
+}
+
+func (mk memoryKeychain) Load(account string) (string, error) {
+	secret, ok := mk[account]
+	if !ok {
+		return "", keychain.ErrNotFound
+	}
+
+	return secret, nil
+}
+
+func (mk memoryKeychain) Delete(account string) error {
+	delete(mk, account)
+	return nil
+}
+
+func TestPasswordInKeychain(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		mk := memoryKeychain{}
+		keychain.Register("memory", func() (keychain.Provider, error) {
+			return mk, nil
+		})
+
+		_, err := ReadPasswordFromKeychain(dir, "memory")
+		require.Equal(t, keychain.ErrNotFound, err)
+
+		require.Equal(t, ErrBadPassword, StorePasswordInKeychain(dir, "memory", "karl"))
+		require.Nil(t, StorePasswordInKeychain(dir, "memory", "klaus"))
+		require.Len(t, mk, 1)
+
+		password, err := ReadPasswordFromKeychain(dir, "memory")
+		require.Nil(t, err)
+		require.Equal(t, "klaus", password)
+
+		rp, err := Open(dir, password)
+		require.Nil(t, err)
+		require.Nil(t, rp.Close(password))
+
+		require.Nil(t, RemovePasswordFromKeychain(dir, "memory"))
+		_, err = ReadPasswordFromKeychain(dir, "memory")
+		require.Equal(t, keychain.ErrNotFound, err)
+	})
+}
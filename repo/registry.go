@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	yml "gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrNoSuchRepo is returned when a name is not in the registry.
+	ErrNoSuchRepo = errors.New("No such repository with this name")
+)
+
+// RegistryEntry is a single named repository in the registry.
+type RegistryEntry struct {
+	// Name is the name of the repository, as used with --repo.
+	Name string `yaml:"-"`
+
+	// Path is the absolute path to the repository.
+	Path string `yaml:"path"`
+}
+
+// Registry is a user-level index of all repositories on this machine.
+// It makes it possible to refer to a repository by name instead of path.
+type Registry struct {
+	path    string
+	entries map[string]*RegistryEntry
+}
+
+// DefaultRegistryPath returns the path where the registry is usually stored:
+// $XDG_CONFIG_HOME/brig/repos.yml, which defaults to ~/.config/brig/repos.yml
+func DefaultRegistryPath() (string, error) {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		var err error
+		configDir, err = homedir.Expand("~/.config")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(configDir, "brig", "repos.yml"), nil
+}
+
+// OpenRegistry loads the registry at `path`.
+// If there is no file yet, an empty registry is returned.
+func OpenRegistry(path string) (*Registry, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries := make(map[string]*RegistryEntry)
+	if err := yml.Unmarshal(data, entries); err != nil {
+		return nil, err
+	}
+
+	for name, entry := range entries {
+		entry.Name = name
+	}
+
+	return &Registry{
+		path:    path,
+		entries: entries,
+	}, nil
+}
+
+func (reg *Registry) save() error {
+	data, err := yml.Marshal(reg.entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(reg.path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(reg.path, data, 0600)
+}
+
+// IsValidRepoName checks if `name` can be used as name in the registry.
+// Names may not look like paths, since --repo takes both.
+func IsValidRepoName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+
+	return !strings.ContainsRune(name, filepath.Separator)
+}
+
+// Add registers the repository at `path` under `name`.
+// An existing entry with the same name is overwritten.
+func (reg *Registry) Add(name, path string) error {
+	if !IsValidRepoName(name) {
+		return fmt.Errorf("invalid repository name: %s", name)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	reg.entries[name] = &RegistryEntry{
+		Name: name,
+		Path: absPath,
+	}
+
+	return reg.save()
+}
+
+// Remove removes the entry with `name` from the registry.
+// The repository itself is not touched.
+func (reg *Registry) Remove(name string) error {
+	if _, ok := reg.entries[name]; !ok {
+		return ErrNoSuchRepo
+	}
+
+	delete(reg.entries, name)
+	return reg.save()
+}
+
+// Entry returns the entry with `name`.
+func (reg *Registry) Entry(name string) (*RegistryEntry, error) {
+	entry, ok := reg.entries[name]
+	if !ok {
+		return nil, ErrNoSuchRepo
+	}
+
+	return entry, nil
+}
+
+// List returns all entries, sorted by name.
+func (reg *Registry) List() []RegistryEntry {
+	entries := []RegistryEntry{}
+	for _, entry := range reg.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
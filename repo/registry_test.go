@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	require.Nil(t, os.RemoveAll(TestRegistryPath))
+	defer os.RemoveAll(TestRegistryPath)
+
+	reg, err := OpenRegistry(TestRegistryPath)
+	require.Nil(t, err)
+	require.Empty(t, reg.List())
+
+	require.Nil(t, reg.Add("work", "/tmp/work"))
+	require.Nil(t, reg.Add("home", "/tmp/home"))
+	require.NotNil(t, reg.Add("a/b", "/tmp/ab"))
+	require.NotNil(t, reg.Add("", "/tmp/empty"))
+
+	// Load it again to see if it was saved:
+	reg, err = OpenRegistry(TestRegistryPath)
+	require.Nil(t, err)
+
+	entries := reg.List()
+	require.Len(t, entries, 2)
+	require.Equal(t, "home", entries[0].Name)
+	require.Equal(t, "/tmp/home", entries[0].Path)
+	require.Equal(t, "work", entries[1].Name)
+
+	entry, err := reg.Entry("work")
+	require.Nil(t, err)
+	require.Equal(t, "/tmp/work", entry.Path)
+
+	require.Nil(t, reg.Remove("work"))
+	require.Equal(t, ErrNoSuchRepo, reg.Remove("work"))
+
+	_, err = reg.Entry("work")
+	require.Equal(t, ErrNoSuchRepo, err)
+}
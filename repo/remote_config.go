@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/config"
+)
+
+// RemoteConfigKeys are the config keys that can be overridden per remote.
+var RemoteConfigKeys = []string{
+	"fs.sync.conflict_hook",
+	"fs.sync.conflict_hook_resolves",
+	"fs.sync.conflict_hook_timeout",
+	"fs.sync.conflict_strategy",
+	"fs.sync.ignore_moved",
+	"fs.sync.ignore_removed",
+}
+
+// NormalizeRemoteConfigKey checks if `key` can be overridden per remote
+// and returns its full name. Since most of them are in the fs section,
+// the "fs." prefix may be left out.
+func NormalizeRemoteConfigKey(key string) (string, error) {
+	for _, candidate := range []string{key, "fs." + key} {
+		for _, allowed := range RemoteConfigKeys {
+			if candidate == allowed {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf(
+		"»%s« can not be set per remote; use one of: %s",
+		key,
+		strings.Join(RemoteConfigKeys, ", "),
+	)
+}
+
+// SetConfig stores an override of the config `key` for the remote `name`.
+// An empty `val` removes the override. Values are not checked here.
+func (rl *RemoteList) SetConfig(name, key, val string) error {
+	rmt, ok := rl.remotes[name]
+	if !ok {
+		return ErrNoSuchRemote
+	}
+
+	if val == "" {
+		delete(rmt.Config, key)
+	} else {
+		if rmt.Config == nil {
+			rmt.Config = make(map[string]string)
+		}
+
+		rmt.Config[key] = val
+	}
+
+	return rl.save()
+}
+
+// copyConfig returns an independent copy of `cfg`.
+func copyConfig(cfg *config.Config) (*config.Config, error) {
+	buf := &bytes.Buffer{}
+	if err := cfg.Save(config.NewYamlEncoder(buf)); err != nil {
+		return nil, err
+	}
+
+	return config.Open(config.NewYamlDecoder(buf), defaults.Defaults, config.StrictnessPanic)
+}
+
+// applyOverrides sets all of `overrides` in `cfg`.
+func applyOverrides(cfg *config.Config, overrides map[string]string) error {
+	keys := []string{}
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val, err := cfg.Cast(key, overrides[key])
+		if err != nil {
+			return e.Wrapf(err, "»%s« is not a valid value for %s", overrides[key], key)
+		}
+
+		if err := cfg.Set(key, val); err != nil {
+			return e.Wrapf(err, "invalid value for %s", key)
+		}
+	}
+
+	return nil
+}
+
+// SetRemoteConfig overrides the config `key` with `val` when talking
+// to the remote `name`. An empty `val` removes the override again.
+func (rp *Repository) SetRemoteConfig(name, key, val string) error {
+	key, err := NormalizeRemoteConfigKey(key)
+	if err != nil {
+		return err
+	}
+
+	if val != "" {
+		// Check the value before we store it:
+		cfg, err := copyConfig(rp.Config)
+		if err != nil {
+			return err
+		}
+
+		if err := applyOverrides(cfg, map[string]string{key: val}); err != nil {
+			return err
+		}
+	}
+
+	return rp.Remotes.SetConfig(name, key, val)
+}
+
+// ConfigFor returns the config to use when talking to the remote `name`:
+// A copy of the repository config with the overrides of the remote applied.
+// Changes to it are not saved.
+func (rp *Repository) ConfigFor(name string) (*config.Config, error) {
+	rmt, err := rp.Remotes.Remote(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rmt.Config) == 0 {
+		return rp.Config, nil
+	}
+
+	cfg, err := copyConfig(rp.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, applyOverrides(cfg, rmt.Config)
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteConfig(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		require.Nil(t, Init(dir, "alice", "klaus", "mock", 6666))
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		require.Nil(t, rp.Remotes.AddOrUpdateRemote(bobRemote))
+
+		require.Nil(t, rp.SetRemoteConfig(bobRemote.Name, "sync.conflict_strategy", "embrace"))
+		require.NotNil(t, rp.SetRemoteConfig(bobRemote.Name, "sync.conflict_strategy", "maybe"))
+		require.NotNil(t, rp.SetRemoteConfig(bobRemote.Name, "daemon.port", "1234"))
+		require.Equal(t, ErrNoSuchRemote, rp.SetRemoteConfig("nobody", "sync.ignore_moved", "true"))
+
+		cfg, err := rp.ConfigFor(bobRemote.Name)
+		require.Nil(t, err)
+		require.Equal(t, "embrace", cfg.String("fs.sync.conflict_strategy"))
+		require.Equal(t, "marker", rp.Config.String("fs.sync.conflict_strategy"))
+
+		// Updating the remote from a client should keep the overrides:
+		require.Nil(t, rp.Remotes.AddOrUpdateRemote(bobRemote))
+		rmt, err := rp.Remotes.Remote(bobRemote.Name)
+		require.Nil(t, err)
+		require.Equal(t, map[string]string{"fs.sync.conflict_strategy": "embrace"}, rmt.Config)
+
+		require.Nil(t, rp.SetRemoteConfig(bobRemote.Name, "fs.sync.conflict_strategy", ""))
+		cfg, err = rp.ConfigFor(bobRemote.Name)
+		require.Nil(t, err)
+		require.Equal(t, "marker", cfg.String("fs.sync.conflict_strategy"))
+
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
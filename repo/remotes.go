@@ -11,6 +11,7 @@ import (
 
 	"github.com/sahib/brig/catfs/vcs"
 	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/util/errkind"
 
 	yml "gopkg.in/yaml.v2"
 )
@@ -21,6 +22,29 @@ var (
 	ErrNoSuchRemote = errors.New("No such remote with this name")
 )
 
+const (
+	// TrustVerified means that the fingerprint of the remote
+	// was checked by other means than just taking it.
+	TrustVerified = "verified"
+
+	// TrustTOFU (trust on first use) means that the fingerprint was taken
+	// as it was given or as it was seen on the first connection.
+	TrustTOFU = "tofu"
+
+	// TrustBlocked means that we do not talk to this remote at all.
+	TrustBlocked = "blocked"
+)
+
+// IsValidTrust returns true if `trust` is one of the trust levels.
+func IsValidTrust(trust string) bool {
+	switch trust {
+	case TrustVerified, TrustTOFU, TrustBlocked:
+		return true
+	}
+
+	return false
+}
+
 // Folder defines a folder setting of the remote.
 type Folder struct {
 	Folder string
@@ -62,6 +86,28 @@ type Remote struct {
 
 	// AcceptPush will allow this remote to push data to us if true.
 	AcceptPush bool
+
+	// Trust is one of the TrustXXX levels.
+	// Remotes from older versions have none and count as TrustTOFU.
+	Trust string
+
+	// Config overrides some of the config keys (see RemoteConfigKeys)
+	// when talking to this remote. Values are stored as strings.
+	Config map[string]string
+}
+
+// TrustLevel returns the trust level of the remote.
+func (r Remote) TrustLevel() string {
+	if r.Trust == "" {
+		return TrustTOFU
+	}
+
+	return r.Trust
+}
+
+// IsBlocked returns true if we should not talk to this remote.
+func (r Remote) IsBlocked() bool {
+	return r.TrustLevel() == TrustBlocked
 }
 
 // ReadOnlyFolders returns the folders that are set to read only
@@ -174,10 +220,19 @@ func (rl *RemoteList) AddOrUpdateRemote(remote Remote) error {
 	if remote.ConflictStrategy != "" {
 		cs := vcs.ConflictStrategyFromString(remote.ConflictStrategy)
 		if cs == vcs.ConflictStragetyUnknown {
-			return fmt.Errorf("unknown conflict strategy: %s", remote.ConflictStrategy)
+			return errkind.Wrap(errkind.Invalid, fmt.Errorf("unknown conflict strategy: %s", remote.ConflictStrategy))
 		}
 	}
 
+	if remote.Trust != "" && !IsValidTrust(remote.Trust) {
+		return fmt.Errorf("unknown trust level: %s", remote.Trust)
+	}
+
+	// Clients do not send the overrides along; keep them:
+	if old, ok := rl.remotes[remote.Name]; ok && remote.Config == nil {
+		remote.Config = old.Config
+	}
+
 	remote.Folders = dedupeFolders(remote.Folders)
 	rl.remotes[remote.Name] = &remote
 	return rl.save()
@@ -194,6 +249,24 @@ func (rl *RemoteList) RmRemote(name string) error {
 	return rl.save()
 }
 
+// RenameRemote changes the name of the remote `oldName` to `newName`.
+// If there is not such remote, ErrNoSuchRemote is returned.
+func (rl *RemoteList) RenameRemote(oldName, newName string) error {
+	remote, ok := rl.remotes[oldName]
+	if !ok {
+		return ErrNoSuchRemote
+	}
+
+	if _, ok := rl.remotes[newName]; ok {
+		return fmt.Errorf("there is already a remote called %s", newName)
+	}
+
+	delete(rl.remotes, oldName)
+	remote.Name = newName
+	rl.remotes[newName] = remote
+	return rl.save()
+}
+
 // Remote will return the remote named `name`.
 // If there is not such remote, ErrNoSuchRemote is returned.
 func (rl *RemoteList) Remote(name string) (Remote, error) {
@@ -229,13 +302,22 @@ func (rl *RemoteList) ListRemotes() ([]Remote, error) {
 // SaveList will store the contents of `remotes` to disk.
 func (rl *RemoteList) SaveList(remotes []Remote) error {
 	// Clear remotes and overwrite them.
+	oldRemotes := rl.remotes
 	rl.remotes = make(map[string]*Remote)
 	for _, remote := range remotes {
-		rl.remotes[remote.Name] = &Remote{
+		newRemote := &Remote{
 			Name:        remote.Name,
 			Fingerprint: remote.Fingerprint,
 			Folders:     remote.Folders,
+			Trust:       remote.Trust,
+			Config:      remote.Config,
+		}
+
+		if old, ok := oldRemotes[remote.Name]; ok && newRemote.Config == nil {
+			newRemote.Config = old.Config
 		}
+
+		rl.remotes[remote.Name] = newRemote
 	}
 
 	for _, remote := range remotes {
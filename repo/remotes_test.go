@@ -100,3 +100,34 @@ func TestRemoteOps(t *testing.T) {
 	require.Equal(t, remotes[0], bobRemote)
 	require.Equal(t, remotes[1], charlieRemote)
 }
+
+func TestRemoteTrust(t *testing.T) {
+	fd, err := ioutil.TempFile("", "brig-test-remotes")
+	require.Nil(t, err)
+
+	defer os.Remove(fd.Name())
+	defer fd.Close()
+
+	rl, err := NewRemotes(fd.Name())
+	require.Nil(t, err)
+
+	// Remotes without explicit trust level are taken on first use:
+	require.Nil(t, rl.AddOrUpdateRemote(bobRemote))
+	bob, err := rl.Remote(bobRemote.Name)
+	require.Nil(t, err)
+	require.Equal(t, TrustTOFU, bob.TrustLevel())
+	require.False(t, bob.IsBlocked())
+
+	bob.Trust = TrustBlocked
+	require.Nil(t, rl.AddOrUpdateRemote(bob))
+
+	reloaded, err := NewRemotes(fd.Name())
+	require.Nil(t, err)
+
+	bob, err = reloaded.Remote(bobRemote.Name)
+	require.Nil(t, err)
+	require.True(t, bob.IsBlocked())
+
+	bob.Trust = "whatever"
+	require.NotNil(t, rl.AddOrUpdateRemote(bob))
+}
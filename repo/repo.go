@@ -7,20 +7,29 @@ import (
 	"path/filepath"
 	"sync"
 
+	humanize "github.com/dustin/go-humanize"
 	e "github.com/pkg/errors"
 	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/catfs/blockcache"
 	fserr "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/defaults"
+	h "github.com/sahib/brig/util/hashlib"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	// Do not encrypt "data" (already contains encrypted streams) and
-	excludedFromLock   = []string{"data", "OWNER", "BACKEND", "REPO_ID", "config.yml"}
+	excludedFromLock = []string{
+		"data", blockCacheDir, "OWNER", lockSaltName, "BACKEND", "REPO_ID", featuresName, "config.yml",
+		PasswdJournalName, "*" + rewrapSuffix,
+	}
 	excludedFromUnlock = []string{"passwd.locked"}
 )
 
+// blockCacheDir is where the block cache lives below the repo.
+const blockCacheDir = "block-cache"
+
 var (
 	// ErrBadPassword is returned by Open() when the decyption password seems to be wrong.
 	ErrBadPassword = errors.New("Failed to open repository. Probably wrong password")
@@ -31,12 +40,16 @@ var (
 // Informal: This file structure currently looks like this:
 // config.yml
 // OWNER
+// LOCK_SALT (only after the owner was renamed)
 // BACKEND
 // REPO_ID
+// FEATURES (formats in use, see features.go)
 // remotes.yml
 // data/
 //    <backend_name>
 //        (data-backend specific)
+// block-cache/
+//    (encrypted blocks of unpinned content, see fs.block_cache)
 // metadata/
 //    <name_1>
 //        (fs-backend specific)
@@ -48,6 +61,21 @@ type Repository struct {
 	// Map between owner and related filesystem.
 	fsMap map[string]*catfs.FS
 
+	// Called after a commit in any of the filesystems.
+	commitHook func(owner, msg string)
+
+	// Used by all filesystems to get content before pre-caching it.
+	contentFetcher func(hash h.Hash) error
+
+	// Used by all filesystems to get corrupt content again.
+	contentRepairer func(hash h.Hash) error
+
+	// Asked by all filesystems before fetching content from the network.
+	transferGate func() bool
+
+	// Shared by all filesystems; nil if disabled or not opened yet.
+	blockCache *blockcache.Cache
+
 	// Name of the backend in use
 	backendName string
 
@@ -65,6 +93,12 @@ type Repository struct {
 
 	// channel to control the auto gc loop
 	autoGCControl chan bool
+
+	// channel to control the config watch loop
+	configWatchControl chan bool
+
+	// channel to control the subkey rotation loop
+	subkeyControl chan bool
 }
 
 // CheckPassword will try to validate `password` by decrypting something
@@ -72,6 +106,11 @@ type Repository struct {
 func CheckPassword(baseFolder, password string) error {
 	passwdFile := filepath.Join(baseFolder, "passwd.locked")
 
+	// passwd.locked might be encrypted with either password now.
+	if IsPasswordChangePending(baseFolder, "") {
+		return ErrPasswordChangePending
+	}
+
 	// If the file does not exist yet, it probably means
 	// that the repo was not initialized yet.
 	// Act like the password is okay and wait for the init.
@@ -81,15 +120,13 @@ func CheckPassword(baseFolder, password string) error {
 		return err
 	}
 
-	// Try to get the owner of the repo.
 	// Needed for the key derivation function.
-	ownerPath := filepath.Join(baseFolder, "OWNER")
-	owner, err := ioutil.ReadFile(ownerPath) // #nosec
+	salt, err := readLockSalt(baseFolder)
 	if err != nil {
-		return e.Wrap(err, "failed to read OWNER")
+		return err
 	}
 
-	key := keyFromPassword(string(owner), password)
+	key := keyFromPassword(salt, password)
 	if err := checkUnlockability(passwdFile, key); err != nil {
 		log.Warningf("Failed to unlock passwd file. Wrong password entered?")
 		return ErrBadPassword
@@ -100,6 +137,11 @@ func CheckPassword(baseFolder, password string) error {
 
 // Open will open the repository at `baseFolder` by using `password`.
 func Open(baseFolder, password string) (*Repository, error) {
+	// Refuse repositories of newer versions before touching anything:
+	if err := CheckFeatures(baseFolder); err != nil {
+		return nil, err
+	}
+
 	// This is only a sanity check here. If the wrong password
 	// was supplied, we won't be able to unlock the repo anyways.
 	// But try to bail out here with an meaningful error message.
@@ -113,9 +155,14 @@ func Open(baseFolder, password string) (*Repository, error) {
 		return nil, e.Wrap(err, "failed to read OWNER")
 	}
 
+	salt, err := readLockSalt(baseFolder)
+	if err != nil {
+		return nil, err
+	}
+
 	err = UnlockRepo(
 		baseFolder,
-		string(owner),
+		salt,
 		password,
 		excludedFromLock,
 		excludedFromUnlock,
@@ -147,24 +194,56 @@ func Open(baseFolder, password string) (*Repository, error) {
 	}
 
 	rp := &Repository{
-		BaseFolder:    baseFolder,
-		backendName:   string(backendName),
-		Config:        cfg,
-		Remotes:       remotes,
-		Owner:         string(owner),
-		fsMap:         make(map[string]*catfs.FS),
-		autoGCControl: make(chan bool, 1),
+		BaseFolder:         baseFolder,
+		backendName:        string(backendName),
+		Config:             cfg,
+		Remotes:            remotes,
+		Owner:              string(owner),
+		fsMap:              make(map[string]*catfs.FS),
+		autoGCControl:      make(chan bool, 1),
+		configWatchControl: make(chan bool, 1),
+		subkeyControl:      make(chan bool, 1),
 	}
 
+	cfg.AddEvent("repo.read_only", func(key string) {
+		rp.applyReadOnly()
+	})
+
 	return rp, nil
 }
 
+// applyReadOnly freezes or thaws all opened filesystems,
+// depending on the repo.read_only setting.
+func (rp *Repository) applyReadOnly() {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	readOnly := rp.Config.Bool("repo.read_only")
+	for _, fs := range rp.fsMap {
+		fs.SetFrozen(readOnly)
+	}
+}
+
+// IsReadOnly returns true if the repository is in read-only mode.
+// Neither the files nor the history of any owner may change then.
+func (rp *Repository) IsReadOnly() bool {
+	return rp.Config.Bool("repo.read_only")
+}
+
 // Close will lock the repository, making this instance unusable.
 func (rp *Repository) Close(password string) error {
 	rp.stopAutoGCLoop()
+	rp.stopConfigWatcher()
+	rp.stopSubkeyRotation()
+
+	salt, err := readLockSalt(rp.BaseFolder)
+	if err != nil {
+		return err
+	}
+
 	return LockRepo(
 		rp.BaseFolder,
-		rp.Owner,
+		salt,
 		password,
 		excludedFromLock,
 		excludedFromUnlock,
@@ -192,6 +271,45 @@ func (rp *Repository) HaveFS(owner string) bool {
 	return true
 }
 
+// MigrateMetadata converts the metadata of all filesystems to be stored
+// with the database `backend` and makes it the configured one.
+// Open filesystems are closed before; they are reopened on next use.
+func (rp *Repository) MigrateMetadata(backend string) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for owner, fs := range rp.fsMap {
+		if err := fs.Close(); err != nil {
+			log.Warningf("failed to close fs of %s: %v", owner, err)
+		}
+
+		delete(rp.fsMap, owner)
+	}
+
+	metadataDir := filepath.Join(rp.BaseFolder, "metadata")
+	infos, err := ioutil.ReadDir(metadataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		fsDbPath := filepath.Join(metadataDir, info.Name())
+		if err := catfs.MigrateMetadata(fsDbPath, backend); err != nil {
+			return e.Wrapf(err, "failed to migrate metadata of %s", info.Name())
+		}
+	}
+
+	if err := rp.Config.SetString("fs.metadata_backend", backend); err != nil {
+		return err
+	}
+
+	return rp.SaveConfig()
+}
+
 // FS returns a filesystem for `owner`. If there is none yet,
 // it will create own associated to the respective owner.
 func (rp *Repository) FS(owner string, bk catfs.FsBackend) (*catfs.FS, error) {
@@ -217,6 +335,26 @@ func (rp *Repository) FS(owner string, bk catfs.FsBackend) (*catfs.FS, error) {
 		return nil, err
 	}
 
+	if !isReadOnly {
+		fs.SetCommitSigner(rp.signCommit)
+	}
+
+	if rp.commitHook != nil {
+		fs.SetCommitHook(rp.fsCommitHook(owner))
+	}
+
+	fs.SetContentFetcher(rp.contentFetcher)
+	fs.SetContentRepairer(rp.contentRepairer)
+	fs.SetTransferGate(rp.transferGate)
+
+	blockCache, err := rp.openBlockCache()
+	if err != nil {
+		// The cache is only an optimization; work without it.
+		log.Warningf("failed to open block cache: %v", err)
+	}
+
+	fs.SetBlockCache(blockCache)
+
 	// Create an initial commit if there was none yet:
 	if _, err := fs.Head(); fserr.IsErrNoSuchRef(err) {
 		if err := fs.MakeCommit("initial commit"); err != nil {
@@ -224,11 +362,138 @@ func (rp *Repository) FS(owner string, bk catfs.FsBackend) (*catfs.FS, error) {
 		}
 	}
 
+	// Only freeze after the initial commit; without it the fs is not usable.
+	fs.SetFrozen(rp.Config.Bool("repo.read_only"))
+
 	// Store for next call:
 	rp.fsMap[owner] = fs
 	return fs, nil
 }
 
+// openBlockCache opens the block cache on first use, if enabled.
+// NOTE: This method assumes that rp.mu is locked.
+func (rp *Repository) openBlockCache() (*blockcache.Cache, error) {
+	if rp.blockCache != nil || !rp.Config.Bool("fs.block_cache.enabled") {
+		return rp.blockCache, nil
+	}
+
+	maxSize, err := humanize.ParseBytes(rp.Config.String("fs.block_cache.max_size"))
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(rp.BaseFolder, blockCacheDir)
+	cache, err := blockcache.Open(dir, int64(maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	rp.blockCache = cache
+	return cache, nil
+}
+
+// BlockCacheStats returns the statistics of the block cache.
+// If the cache is disabled, all of them are zero.
+func (rp *Repository) BlockCacheStats() blockcache.Stats {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.blockCache == nil {
+		return blockcache.Stats{}
+	}
+
+	return rp.blockCache.Stats()
+}
+
+// SetCommitHook sets a function that is called after every commit made
+// in one of the filesystems, including the ones opened later on.
+func (rp *Repository) SetCommitHook(hook func(owner, msg string)) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.commitHook = hook
+	for owner, fs := range rp.fsMap {
+		if hook == nil {
+			fs.SetCommitHook(nil)
+			continue
+		}
+
+		fs.SetCommitHook(rp.fsCommitHook(owner))
+	}
+}
+
+// SetContentFetcher sets a function that all filesystems use
+// to get content before pre-caching it (see catfs.FS.SetContentFetcher).
+func (rp *Repository) SetContentFetcher(fetcher func(hash h.Hash) error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.contentFetcher = fetcher
+	for _, fs := range rp.fsMap {
+		fs.SetContentFetcher(fetcher)
+	}
+}
+
+// SetContentRepairer sets a function that all filesystems use to get
+// content again that was found to be corrupt (see catfs.FS.SetContentRepairer).
+func (rp *Repository) SetContentRepairer(repairer func(hash h.Hash) error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.contentRepairer = repairer
+	for _, fs := range rp.fsMap {
+		fs.SetContentRepairer(repairer)
+	}
+}
+
+// SetTransferGate sets a function that all filesystems ask before
+// fetching content from the network (see catfs.FS.SetTransferGate).
+func (rp *Repository) SetTransferGate(gate func() bool) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.transferGate = gate
+	for _, fs := range rp.fsMap {
+		fs.SetTransferGate(gate)
+	}
+}
+
+// RunDeferredPreCache starts the pre-caching that all filesystems
+// deferred because of the transfer gate. It returns how many were started.
+func (rp *Repository) RunDeferredPreCache() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	started := 0
+	for _, fs := range rp.fsMap {
+		started += fs.RunDeferredPreCache()
+	}
+
+	return started
+}
+
+// PreCacheQueue returns how many pre-cachings are running right now
+// and how many are deferred, summed over all filesystems.
+func (rp *Repository) PreCacheQueue() (running, deferred int) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for _, fs := range rp.fsMap {
+		fsRunning, fsDeferred := fs.PreCacheQueue()
+		running += fsRunning
+		deferred += fsDeferred
+	}
+
+	return running, deferred
+}
+
+func (rp *Repository) fsCommitHook(owner string) func(msg string) {
+	hook := rp.commitHook
+	return func(msg string) {
+		hook(owner, msg)
+	}
+}
+
 // CurrentUser returns the current user of the repository.
 // (i.e. what FS is being shown)
 func (rp *Repository) CurrentUser() string {
@@ -243,7 +508,19 @@ func (rp *Repository) SetCurrentUser(user string) {
 
 // Keyring returns the keyring of the repository.
 func (rp *Repository) Keyring() *Keyring {
-	return newKeyringHandle(rp.BaseFolder)
+	kp := newKeyringHandle(rp.BaseFolder)
+	if rp.Config != nil {
+		kp.noSubkeys = !rp.Config.Bool("repo.subkeys.enabled")
+		kp.identitySource = rp.Config.String("repo.identity.source")
+		kp.sshKeyPath = rp.Config.String("repo.identity.ssh_key")
+
+		// Subkeys are certified with the gpg identity key:
+		if kp.HasSSHIdentity() {
+			kp.noSubkeys = true
+		}
+	}
+
+	return kp
 }
 
 // RepoID returns a unique ID specific to this repository.
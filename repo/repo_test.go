@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/sahib/brig/backend/mock"
+	"github.com/sahib/brig/catfs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,3 +64,40 @@ func dirSize(t *testing.T, path string) int64 {
 
 	return size
 }
+
+func TestRepoMigrateMetadata(t *testing.T) {
+	withLockedRepo(t, func(dir string) {
+		rp, err := Open(dir, "klaus")
+		require.Nil(t, err)
+
+		bk := catfs.NewMemFsBackend()
+		fs, err := rp.FS("alice", bk)
+		require.Nil(t, err)
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.MakeCommit("added x"))
+
+		require.NotNil(t, rp.MigrateMetadata("sqlite"))
+		require.Nil(t, rp.MigrateMetadata("disk"))
+		require.Equal(t, "disk", rp.Config.String("fs.metadata_backend"))
+
+		fs, err = rp.FS("alice", bk)
+		require.Nil(t, err)
+
+		info, err := fs.Stat("/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(5), info.Size)
+		require.Nil(t, rp.Close("klaus"))
+
+		// The new backend survives a restart:
+		rp, err = Open(dir, "klaus")
+		require.Nil(t, err)
+		require.Equal(t, "disk", rp.Config.String("fs.metadata_backend"))
+
+		fs, err = rp.FS("alice", bk)
+		require.Nil(t, err)
+
+		_, err = fs.Stat("/x")
+		require.Nil(t, err)
+		require.Nil(t, rp.Close("klaus"))
+	})
+}
@@ -0,0 +1,566 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alokmenghrajani/gpgeez"
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// SubkeyUsage describes what a subkey may be used for.
+type SubkeyUsage string
+
+const (
+	// SubkeySession is used to authenticate connections with other remotes.
+	SubkeySession = SubkeyUsage("session")
+
+	// SubkeySigning is used to sign data like commits.
+	SubkeySigning = SubkeyUsage("signing")
+
+	// SubkeyClockSkew is how far the clock of a remote may be behind ours.
+	// Without it, a freshly rotated key would not be valid yet over there.
+	SubkeyClockSkew = 5 * time.Minute
+
+	subkeyBits    = 2048
+	subkeyCertExt = ".cert"
+	subkeyPrivExt = ".prv"
+	subkeyDirName = "subkeys"
+)
+
+var (
+	// ErrNoSubkey is returned when there is no valid subkey for a usage.
+	ErrNoSubkey = errors.New("no valid subkey")
+
+	// ErrSubkeyExpired is returned when a subkey is used outside of its lifetime.
+	ErrSubkeyExpired = errors.New("subkey is expired or not yet valid")
+
+	// ErrBadSubkeyCert is returned when a subkey certificate is malformed
+	// or was not signed by the expected identity key.
+	ErrBadSubkeyCert = errors.New("subkey is not certified by the identity key")
+)
+
+// ParseSubkeyUsage converts `s` to a SubkeyUsage.
+func ParseSubkeyUsage(s string) (SubkeyUsage, error) {
+	switch usage := SubkeyUsage(s); usage {
+	case SubkeySession, SubkeySigning:
+		return usage, nil
+	default:
+		return "", fmt.Errorf("invalid subkey usage: %s", s)
+	}
+}
+
+// Subkey is a short-lived key pair that is certified by the long-term
+// identity key (the one in gpg.pub). Since the fingerprint only covers the
+// identity key, subkeys can be rotated without telling other remotes.
+type Subkey struct {
+	ID      string      `json:"id"`
+	Usage   SubkeyUsage `json:"usage"`
+	Created time.Time   `json:"created"`
+	Expires time.Time   `json:"expires"`
+	PubKey  []byte      `json:"pubkey"`
+}
+
+// IsValidAt returns true if the subkey may be used at `t`.
+// Subkeys are accepted up to SubkeyClockSkew before their creation.
+func (sk *Subkey) IsValidAt(t time.Time) bool {
+	return !t.Add(SubkeyClockSkew).Before(sk.Created) && t.Before(sk.Expires)
+}
+
+// A certificate is the json encoded subkey with a detached signature of
+// the identity key: <4 byte size of json><json><signature>
+func packSubkeyCert(data, sig []byte) []byte {
+	cert := make([]byte, 4, 4+len(data)+len(sig))
+	binary.LittleEndian.PutUint32(cert, uint32(len(data)))
+	cert = append(cert, data...)
+	return append(cert, sig...)
+}
+
+func unpackSubkeyCert(cert []byte) ([]byte, []byte, error) {
+	if len(cert) < 4 {
+		return nil, nil, ErrBadSubkeyCert
+	}
+
+	size := binary.LittleEndian.Uint32(cert)
+	if uint64(size) > uint64(len(cert)-4) {
+		return nil, nil, ErrBadSubkeyCert
+	}
+
+	return cert[4 : 4+size], cert[4+size:], nil
+}
+
+// CertifySubkey creates a certificate for `sk`, signed by `identity`.
+func CertifySubkey(identity *openpgp.Entity, sk *Subkey) ([]byte, error) {
+	data, err := json.Marshal(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, identity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return packSubkeyCert(data, sigBuf.Bytes()), nil
+}
+
+// ParseSubkeyCert checks that `cert` was signed by `identityPubKey` and
+// returns the subkey in it. The lifetime of the subkey is not checked.
+func ParseSubkeyCert(identityPubKey, cert []byte) (*Subkey, error) {
+	data, sig, err := unpackSubkeyCert(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(identityPubKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		ents,
+		bytes.NewReader(data),
+		bytes.NewReader(sig),
+	); err != nil {
+		return nil, ErrBadSubkeyCert
+	}
+
+	sk := &Subkey{}
+	if err := json.Unmarshal(data, sk); err != nil {
+		return nil, ErrBadSubkeyCert
+	}
+
+	return sk, nil
+}
+
+// VerifySubkeyCert is like ParseSubkeyCert, but also checks that the subkey
+// is valid at `now` and was made for `usage`.
+func VerifySubkeyCert(identityPubKey, cert []byte, usage SubkeyUsage, now time.Time) (*Subkey, error) {
+	sk, err := ParseSubkeyCert(identityPubKey, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if sk.Usage != usage {
+		return nil, fmt.Errorf("subkey %s is a %s key, not a %s key", sk.ID, sk.Usage, usage)
+	}
+
+	if !sk.IsValidAt(now) {
+		return nil, ErrSubkeyExpired
+	}
+
+	return sk, nil
+}
+
+func readEntity(path string) (*openpgp.Entity, error) {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return nil, err
+	}
+
+	defer util.Closer(fd)
+
+	ents, err := openpgp.ReadKeyRing(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ents) == 0 {
+		return nil, fmt.Errorf("no key in %s", path)
+	}
+
+	return ents[0], nil
+}
+
+func (kp *Keyring) subkeyDir() string {
+	return filepath.Join(kp.folder, subkeyDirName)
+}
+
+// CreateSubkey creates a new subkey for `usage` that is valid for `lifetime`.
+func (kp *Keyring) CreateSubkey(usage SubkeyUsage, lifetime time.Duration) (*Subkey, error) {
+	identity, err := readEntity(filepath.Join(kp.folder, "gpg.prv"))
+	if err != nil {
+		return nil, e.Wrap(err, "failed to load identity key")
+	}
+
+	// The expiry is also set in the key itself, so that
+	// other gpg tools will not accept it after expiry.
+	cfg := gpgeez.Config{Expiry: lifetime}
+	cfg.RSABits = subkeyBits
+
+	comment := fmt.Sprintf("brig %s subkey", usage)
+	key, err := gpgeez.CreateKey("brig", comment, "", &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sk := &Subkey{
+		ID:      key.PrimaryKey.KeyIdString(),
+		Usage:   usage,
+		Created: now,
+		Expires: now.Add(lifetime),
+		PubKey:  key.Keyring(),
+	}
+
+	cert, err := CertifySubkey(identity, sk)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(kp.subkeyDir(), 0700); err != nil {
+		return nil, err
+	}
+
+	basePath := filepath.Join(kp.subkeyDir(), sk.ID)
+	if err := ioutil.WriteFile(basePath+subkeyPrivExt, key.Secring(&cfg), 0600); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(basePath+subkeyCertExt, cert, 0600); err != nil {
+		return nil, err
+	}
+
+	return sk, nil
+}
+
+// SubkeyCert returns the certificate of the subkey with `id`.
+// It can be given to other remotes, which check it with ParseSubkeyCert.
+func (kp *Keyring) SubkeyCert(id string) ([]byte, error) {
+	path := filepath.Join(kp.subkeyDir(), filepath.Clean(id)+subkeyCertExt)
+	return ioutil.ReadFile(path) // #nosec
+}
+
+// Subkeys returns all subkeys, including expired ones, sorted by creation time.
+func (kp *Keyring) Subkeys() ([]*Subkey, error) {
+	ownPubKey, err := kp.OwnPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(kp.subkeyDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	subkeys := []*Subkey{}
+	for _, info := range infos {
+		if !strings.HasSuffix(info.Name(), subkeyCertExt) {
+			continue
+		}
+
+		cert, err := ioutil.ReadFile(filepath.Join(kp.subkeyDir(), info.Name())) // #nosec
+		if err != nil {
+			return nil, err
+		}
+
+		sk, err := ParseSubkeyCert(ownPubKey, cert)
+		if err != nil {
+			log.Warningf("ignoring invalid subkey %s: %v", info.Name(), err)
+			continue
+		}
+
+		subkeys = append(subkeys, sk)
+	}
+
+	sort.Slice(subkeys, func(i, j int) bool {
+		return subkeys[i].Created.Before(subkeys[j].Created)
+	})
+
+	return subkeys, nil
+}
+
+// CurrentSubkey returns the newest subkey for `usage` that is valid now.
+// If there is none, ErrNoSubkey is returned.
+func (kp *Keyring) CurrentSubkey(usage SubkeyUsage) (*Subkey, error) {
+	subkeys, err := kp.Subkeys()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for idx := len(subkeys) - 1; idx >= 0; idx-- {
+		if sk := subkeys[idx]; sk.Usage == usage && sk.IsValidAt(now) {
+			return sk, nil
+		}
+	}
+
+	return nil, ErrNoSubkey
+}
+
+func (kp *Keyring) removeSubkey(id string) error {
+	basePath := filepath.Join(kp.subkeyDir(), id)
+	for _, ext := range []string{subkeyCertExt, subkeyPrivExt} {
+		if err := os.Remove(basePath + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotateSubkey creates a new subkey for `usage` and deletes all expired ones.
+func (kp *Keyring) RotateSubkey(usage SubkeyUsage, lifetime time.Duration) (*Subkey, error) {
+	sk, err := kp.CreateSubkey(usage, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	subkeys, err := kp.Subkeys()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, old := range subkeys {
+		if old.Expires.Before(now) {
+			log.Debugf("removing expired subkey %s", old.ID)
+			if err := kp.removeSubkey(old.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sk, nil
+}
+
+// EnsureSubkeys makes sure that there is a subkey for every usage
+// that is valid for at least a quarter of `lifetime`.
+func (kp *Keyring) EnsureSubkeys(lifetime time.Duration) error {
+	for _, usage := range []SubkeyUsage{SubkeySession, SubkeySigning} {
+		sk, err := kp.CurrentSubkey(usage)
+		if err != nil && err != ErrNoSubkey {
+			return err
+		}
+
+		if sk != nil && time.Until(sk.Expires) > lifetime/4 {
+			continue
+		}
+
+		log.Infof("rotating %s subkey", usage)
+		if _, err := kp.RotateSubkey(usage, lifetime); err != nil {
+			return e.Wrapf(err, "failed to rotate %s subkey", usage)
+		}
+	}
+
+	return nil
+}
+
+// SessionSubkeyCert returns the certificate of the current session subkey.
+// If there is none (or subkeys are disabled), nil is returned and the
+// identity key is used instead.
+func (kp *Keyring) SessionSubkeyCert() ([]byte, error) {
+	if kp.noSubkeys {
+		return nil, nil
+	}
+
+	sk, err := kp.CurrentSubkey(SubkeySession)
+	if err == ErrNoSubkey {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return kp.SubkeyCert(sk.ID)
+}
+
+// sessionEntities returns the private keys of all valid session subkeys.
+func (kp *Keyring) sessionEntities() (openpgp.EntityList, error) {
+	subkeys, err := kp.Subkeys()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ents := openpgp.EntityList{}
+	for _, sk := range subkeys {
+		if sk.Usage != SubkeySession || !sk.IsValidAt(now) {
+			continue
+		}
+
+		ent, err := readEntity(filepath.Join(kp.subkeyDir(), sk.ID+subkeyPrivExt))
+		if err != nil {
+			return nil, err
+		}
+
+		ents = append(ents, ent)
+	}
+
+	return ents, nil
+}
+
+// Sign creates a signature of `data` with the current signing subkey.
+// The signature contains the subkey certificate, so it can be checked
+// with VerifySignature by anyone who knows our identity key.
+// If subkeys are disabled, ErrNoSubkey is returned.
+func (kp *Keyring) Sign(data []byte) ([]byte, error) {
+	if kp.noSubkeys {
+		return nil, ErrNoSubkey
+	}
+
+	sk, err := kp.CurrentSubkey(SubkeySigning)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := kp.SubkeyCert(sk.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ent, err := readEntity(filepath.Join(kp.subkeyDir(), sk.ID+subkeyPrivExt))
+	if err != nil {
+		return nil, err
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, ent, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return packSubkeyCert(cert, sigBuf.Bytes()), nil
+}
+
+// VerifySignature checks that `sig` is a signature of `data` made by
+// a signing subkey of `identityPubKey`. The subkey must have been valid
+// at the time the signature was made, but may be expired by now.
+func VerifySignature(identityPubKey, data, sig []byte) error {
+	cert, detachedSig, err := unpackSubkeyCert(sig)
+	if err != nil {
+		return err
+	}
+
+	sk, err := ParseSubkeyCert(identityPubKey, cert)
+	if err != nil {
+		return err
+	}
+
+	if sk.Usage != SubkeySigning {
+		return fmt.Errorf("subkey %s may not be used for signing", sk.ID)
+	}
+
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(sk.PubKey))
+	if err != nil {
+		return err
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		ents,
+		bytes.NewReader(data),
+		bytes.NewReader(detachedSig),
+	); err != nil {
+		return err
+	}
+
+	pkt, err := packet.Read(bytes.NewReader(detachedSig))
+	if err != nil {
+		return err
+	}
+
+	sigPkt, ok := pkt.(*packet.Signature)
+	if !ok {
+		return fmt.Errorf("bad signature packet")
+	}
+
+	// Signature times only have a resolution of one second:
+	if !sk.IsValidAt(sigPkt.CreationTime.Add(time.Second)) {
+		return ErrSubkeyExpired
+	}
+
+	return nil
+}
+
+// signCommit signs the hash of a commit with the current signing subkey.
+// Commits stay unsigned if there is no signing subkey; failing to sign
+// should never prevent the user from committing.
+func (rp *Repository) signCommit(hash []byte) ([]byte, error) {
+	sig, err := rp.Keyring().Sign(hash)
+	if err == ErrNoSubkey {
+		return nil, nil
+	}
+
+	if err != nil {
+		log.Warningf("failed to sign commit: %v", err)
+		return nil, nil
+	}
+
+	return sig, nil
+}
+
+// VerifyRemoteCommit checks that the commit `rev` in `fs` was signed by
+// the remote `name`. Unsigned commits are only accepted if
+// repo.subkeys.require_signed_commits is false.
+func (rp *Repository) VerifyRemoteCommit(name string, fs *catfs.FS, rev string) error {
+	pubKey, err := rp.Keyring().PubKeyFor(name)
+	if err != nil {
+		return e.Wrapf(err, "no public key for %s", name)
+	}
+
+	err = fs.VerifyCommit(rev, func(hash, sig []byte) error {
+		return VerifySignature(pubKey, hash, sig)
+	})
+
+	if err == catfs.ErrUnsignedCommit && !rp.Config.Bool("repo.subkeys.require_signed_commits") {
+		log.Debugf("commit %s of %s is not signed", rev, name)
+		return nil
+	}
+
+	return err
+}
+
+// StartSubkeyRotation makes sure that there are valid subkeys now
+// and rotates them in the background before they expire.
+func (rp *Repository) StartSubkeyRotation() {
+	go rp.subkeyRotationLoop()
+}
+
+func (rp *Repository) stopSubkeyRotation() {
+	go func() {
+		rp.subkeyControl <- true
+	}()
+}
+
+func (rp *Repository) rotateSubkeys() {
+	kp := rp.Keyring()
+	if kp.noSubkeys {
+		return
+	}
+
+	lifetime := rp.Config.Duration("repo.subkeys.lifetime")
+	if err := kp.EnsureSubkeys(lifetime); err != nil {
+		log.Warningf("failed to rotate subkeys: %v", err)
+	}
+}
+
+func (rp *Repository) subkeyRotationLoop() {
+	rp.rotateSubkeys()
+
+	checkTicker := time.NewTicker(10 * time.Minute)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-rp.subkeyControl:
+			log.Debugf("quitting the subkey rotation loop")
+			return
+		case <-checkTicker.C:
+			rp.rotateSubkeys()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withKeyring(t *testing.T, fn func(kr *Keyring)) {
+	withTempDir(t, func(dir string) {
+		require.Nil(t, createKeyPair("alice", dir, 1024))
+		fn(newKeyringHandle(dir))
+	})
+}
+
+func TestSubkeyRotation(t *testing.T) {
+	withKeyring(t, func(kr *Keyring) {
+		_, err := kr.CurrentSubkey(SubkeySession)
+		require.Equal(t, ErrNoSubkey, err)
+
+		cert, err := kr.SessionSubkeyCert()
+		require.Nil(t, err)
+		require.Nil(t, cert)
+
+		require.Nil(t, kr.EnsureSubkeys(time.Hour))
+		session, err := kr.CurrentSubkey(SubkeySession)
+		require.Nil(t, err)
+		signing, err := kr.CurrentSubkey(SubkeySigning)
+		require.Nil(t, err)
+		require.NotEqual(t, session.ID, signing.ID)
+
+		// Nothing to do, the keys are still fresh:
+		require.Nil(t, kr.EnsureSubkeys(time.Hour))
+		subkeys, err := kr.Subkeys()
+		require.Nil(t, err)
+		require.Len(t, subkeys, 2)
+
+		newSession, err := kr.RotateSubkey(SubkeySession, time.Hour)
+		require.Nil(t, err)
+
+		current, err := kr.CurrentSubkey(SubkeySession)
+		require.Nil(t, err)
+		require.Equal(t, newSession.ID, current.ID)
+	})
+}
+
+func TestSubkeyCert(t *testing.T) {
+	withKeyring(t, func(kr *Keyring) {
+		sk, err := kr.CreateSubkey(SubkeySession, time.Hour)
+		require.Nil(t, err)
+
+		ownPubKey, err := kr.OwnPubKey()
+		require.Nil(t, err)
+
+		cert, err := kr.SessionSubkeyCert()
+		require.Nil(t, err)
+
+		parsed, err := VerifySubkeyCert(ownPubKey, cert, SubkeySession, time.Now())
+		require.Nil(t, err)
+		require.Equal(t, sk.ID, parsed.ID)
+		require.Equal(t, sk.PubKey, parsed.PubKey)
+
+		_, err = VerifySubkeyCert(ownPubKey, cert, SubkeySigning, time.Now())
+		require.NotNil(t, err)
+
+		_, err = VerifySubkeyCert(ownPubKey, cert, SubkeySession, time.Now().Add(2*time.Hour))
+		require.Equal(t, ErrSubkeyExpired, err)
+
+		// Remotes with a clock that is slightly behind should accept it:
+		_, err = VerifySubkeyCert(ownPubKey, cert, SubkeySession, time.Now().Add(-time.Minute))
+		require.Nil(t, err)
+
+		_, err = VerifySubkeyCert(ownPubKey, cert, SubkeySession, time.Now().Add(-time.Hour))
+		require.Equal(t, ErrSubkeyExpired, err)
+
+		// Disabled subkeys are not offered to others:
+		kr.noSubkeys = true
+		cert, err = kr.SessionSubkeyCert()
+		require.Nil(t, err)
+		require.Nil(t, cert)
+
+		_, err = kr.Sign([]byte("data"))
+		require.Equal(t, ErrNoSubkey, err)
+		kr.noSubkeys = false
+
+		cert, err = kr.SessionSubkeyCert()
+		require.Nil(t, err)
+
+		// A tampered certificate should not be accepted:
+		cert[10] ^= 0xFF
+		_, err = ParseSubkeyCert(ownPubKey, cert)
+		require.Equal(t, ErrBadSubkeyCert, err)
+
+		// Messages to the subkey can be decrypted by the keyring:
+		encData, err := kr.Encrypt([]byte("hello"), sk.PubKey)
+		require.Nil(t, err)
+
+		decData, err := kr.Decrypt(encData)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), decData)
+	})
+}
+
+func TestSubkeySignature(t *testing.T) {
+	withKeyring(t, func(kr *Keyring) {
+		_, err := kr.Sign([]byte("data"))
+		require.Equal(t, ErrNoSubkey, err)
+
+		_, err = kr.CreateSubkey(SubkeySigning, time.Hour)
+		require.Nil(t, err)
+
+		sig, err := kr.Sign([]byte("data"))
+		require.Nil(t, err)
+
+		ownPubKey, err := kr.OwnPubKey()
+		require.Nil(t, err)
+
+		require.Nil(t, VerifySignature(ownPubKey, []byte("data"), sig))
+		require.NotNil(t, VerifySignature(ownPubKey, []byte("other"), sig))
+
+		withKeyring(t, func(otherKr *Keyring) {
+			otherPubKey, err := otherKr.OwnPubKey()
+			require.Nil(t, err)
+			require.Equal(t, ErrBadSubkeyCert, VerifySignature(otherPubKey, []byte("data"), sig))
+		})
+	})
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/fuse"
+	"github.com/sahib/brig/server/capnp"
+	capnplib "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+// activeTransfer is a transfer with a remote that is running right now.
+type activeTransfer struct {
+	Kind    string
+	Remote  string
+	What    string
+	Bytes   int64
+	Started time.Time
+}
+
+// remoteTraffic counts the bytes exchanged with a single remote.
+type remoteTraffic struct {
+	Name     string
+	Received int64
+	Sent     int64
+}
+
+// queueDepth is the number of items waiting in one queue.
+type queueDepth struct {
+	Name  string
+	Depth int
+}
+
+// activitySnapshot is what the daemon did at one point in time.
+type activitySnapshot struct {
+	Taken     time.Time
+	Transfers []activeTransfer
+	Calls     map[string]int
+	Mounts    []fuse.MountStats
+	Queues    []queueDepth
+	Traffic   []remoteTraffic
+}
+
+// activity keeps track of what the daemon is doing right now, unlike
+// daemonStats which counts what it did. It is shown by `brig top`.
+// The zero value is not usable, use newActivity.
+type activity struct {
+	mu sync.Mutex
+
+	nextID    uint64
+	transfers map[uint64]*activeTransfer
+	calls     map[string]int
+	traffic   map[string]*remoteTraffic
+}
+
+func newActivity() *activity {
+	return &activity{
+		transfers: make(map[uint64]*activeTransfer),
+		calls:     make(map[string]int),
+		traffic:   make(map[string]*remoteTraffic),
+	}
+}
+
+// wrapMethods keeps track of the calls to `methods` that are being served.
+func (ac *activity) wrapMethods(methods []server.Method) []server.Method {
+	for idx := range methods {
+		name := opName(methods[idx].Method)
+		impl := methods[idx].Impl
+		methods[idx].Impl = func(ctx context.Context, opts capnplib.CallOptions, p, r capnplib.Struct) error {
+			ac.mu.Lock()
+			ac.calls[name]++
+			ac.mu.Unlock()
+
+			defer func() {
+				ac.mu.Lock()
+				if ac.calls[name]--; ac.calls[name] <= 0 {
+					delete(ac.calls, name)
+				}
+				ac.mu.Unlock()
+			}()
+
+			return impl(ctx, opts, p, r)
+		}
+	}
+
+	return methods
+}
+
+// startTransfer registers a new transfer and returns its id,
+// which has to be passed to endTransfer once it is done.
+func (ac *activity) startTransfer(kind, remote, what string) uint64 {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.nextID++
+	ac.transfers[ac.nextID] = &activeTransfer{
+		Kind:    kind,
+		Remote:  remote,
+		What:    what,
+		Started: time.Now(),
+	}
+
+	return ac.nextID
+}
+
+// endTransfer forgets the transfer with `id`.
+func (ac *activity) endTransfer(id uint64) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	delete(ac.transfers, id)
+}
+
+// NOTE: This method assumes that ac.mu is locked.
+func (ac *activity) remote(name string) *remoteTraffic {
+	traffic, ok := ac.traffic[name]
+	if !ok {
+		traffic = &remoteTraffic{Name: name}
+		ac.traffic[name] = traffic
+	}
+
+	return traffic
+}
+
+// addReceived records that `size` bytes were received from `remote`
+// as part of the transfer with `id`.
+func (ac *activity) addReceived(id uint64, remote string, size int64) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if transfer, ok := ac.transfers[id]; ok {
+		transfer.Bytes += size
+	}
+
+	ac.remote(remote).Received += size
+}
+
+// addSent records that `size` bytes were sent to `remote`.
+func (ac *activity) addSent(remote string, size int) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.remote(remote).Sent += int64(size)
+}
+
+// snapshot returns the current activity. Mounts and queues
+// are not known here; the caller has to fill them in.
+func (ac *activity) snapshot() activitySnapshot {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	snap := activitySnapshot{
+		Taken:     time.Now(),
+		Transfers: []activeTransfer{},
+		Calls:     make(map[string]int),
+		Traffic:   []remoteTraffic{},
+	}
+
+	for _, transfer := range ac.transfers {
+		snap.Transfers = append(snap.Transfers, *transfer)
+	}
+
+	sort.Slice(snap.Transfers, func(i, j int) bool {
+		return snap.Transfers[i].Started.Before(snap.Transfers[j].Started)
+	})
+
+	for name, count := range ac.calls {
+		snap.Calls[name] = count
+	}
+
+	for _, traffic := range ac.traffic {
+		snap.Traffic = append(snap.Traffic, *traffic)
+	}
+
+	sort.Slice(snap.Traffic, func(i, j int) bool {
+		return snap.Traffic[i].Name < snap.Traffic[j].Name
+	})
+
+	return snap
+}
+
+// activitySnapshot returns the complete current activity of the daemon.
+func (b *base) activitySnapshot() activitySnapshot {
+	snap := b.activity.snapshot()
+
+	writebacks := 0
+	if b.mounts != nil {
+		snap.Mounts = b.mounts.Stats()
+		for _, mount := range snap.Mounts {
+			writebacks += mount.PendingWrites
+		}
+	}
+
+	offline := 0
+	if b.offlineQueue != nil {
+		offline = len(b.offlineQueue.List(""))
+	}
+
+	running, deferred := b.repo.PreCacheQueue()
+	snap.Queues = []queueDepth{
+		{Name: "offline", Depth: offline},
+		{Name: "pre-cache", Depth: running},
+		{Name: "pre-cache (deferred)", Depth: deferred},
+		{Name: "writeback", Depth: writebacks},
+	}
+
+	return snap
+}
+
+// writeActivity sends the current activity as single message to `enc`.
+func (b *base) writeActivity(enc *capnplib.Encoder) error {
+	msg, seg, err := capnplib.NewMessage(capnplib.SingleSegment(nil))
+	if err != nil {
+		return err
+	}
+
+	capAct, err := activityToCap(b.activitySnapshot(), seg)
+	if err != nil {
+		return err
+	}
+
+	if err := msg.SetRootPtr(capAct.ToPtr()); err != nil {
+		return err
+	}
+
+	return enc.Encode(msg)
+}
+
+func activityToCap(snap activitySnapshot, seg *capnplib.Segment) (*capnp.DaemonActivity, error) {
+	capAct, err := capnp.NewDaemonActivity(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capAct.SetTaken(snap.Taken.Format(time.RFC3339Nano)); err != nil {
+		return nil, err
+	}
+
+	capTransfers, err := capnp.NewActiveTransfer_List(seg, int32(len(snap.Transfers)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, transfer := range snap.Transfers {
+		capTransfer := capTransfers.At(idx)
+		if err := capTransfer.SetKind(transfer.Kind); err != nil {
+			return nil, err
+		}
+
+		if err := capTransfer.SetRemote(transfer.Remote); err != nil {
+			return nil, err
+		}
+
+		if err := capTransfer.SetWhat(transfer.What); err != nil {
+			return nil, err
+		}
+
+		if err := capTransfer.SetStarted(transfer.Started.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+
+		capTransfer.SetBytes(transfer.Bytes)
+	}
+
+	if err := capAct.SetTransfers(capTransfers); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range snap.Calls {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	capCalls, err := capnp.NewActiveCall_List(seg, int32(len(names)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, name := range names {
+		capCall := capCalls.At(idx)
+		if err := capCall.SetName(name); err != nil {
+			return nil, err
+		}
+
+		capCall.SetCount(int32(snap.Calls[name]))
+	}
+
+	if err := capAct.SetCalls(capCalls); err != nil {
+		return nil, err
+	}
+
+	capMounts, err := capnp.NewMountActivity_List(seg, int32(len(snap.Mounts)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, mount := range snap.Mounts {
+		capMount := capMounts.At(idx)
+		if err := capMount.SetDir(mount.Dir); err != nil {
+			return nil, err
+		}
+
+		capMount.SetOpenHandles(mount.OpenHandles)
+		capMount.SetPendingWrites(int32(mount.PendingWrites))
+	}
+
+	if err := capAct.SetMounts(capMounts); err != nil {
+		return nil, err
+	}
+
+	capQueues, err := capnp.NewQueueDepth_List(seg, int32(len(snap.Queues)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, queue := range snap.Queues {
+		capQueue := capQueues.At(idx)
+		if err := capQueue.SetName(queue.Name); err != nil {
+			return nil, err
+		}
+
+		capQueue.SetDepth(int32(queue.Depth))
+	}
+
+	if err := capAct.SetQueues(capQueues); err != nil {
+		return nil, err
+	}
+
+	capTraffic, err := capnp.NewRemoteTraffic_List(seg, int32(len(snap.Traffic)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, traffic := range snap.Traffic {
+		capRemote := capTraffic.At(idx)
+		if err := capRemote.SetName(traffic.Name); err != nil {
+			return nil, err
+		}
+
+		capRemote.SetReceived(traffic.Received)
+		capRemote.SetSent(traffic.Sent)
+	}
+
+	if err := capAct.SetTraffic(capTraffic); err != nil {
+		return nil, err
+	}
+
+	return &capAct, nil
+}
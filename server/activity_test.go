@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivity(t *testing.T) {
+	ac := newActivity()
+
+	id := ac.startTransfer("fetch-content", "", "QmHash")
+	ac.addReceived(id, "bob", 100)
+	ac.addReceived(id, "charlie", 50)
+	ac.addSent("bob", 10)
+
+	snap := ac.snapshot()
+	require.Len(t, snap.Transfers, 1)
+	require.Equal(t, "fetch-content", snap.Transfers[0].Kind)
+	require.Equal(t, int64(150), snap.Transfers[0].Bytes)
+	require.Equal(t, []remoteTraffic{
+		{Name: "bob", Received: 100, Sent: 10},
+		{Name: "charlie", Received: 50},
+	}, snap.Traffic)
+
+	// The traffic is kept after the transfer is done:
+	ac.endTransfer(id)
+	ac.addReceived(id, "bob", 1)
+
+	snap = ac.snapshot()
+	require.Empty(t, snap.Transfers)
+	require.Equal(t, int64(101), snap.Traffic[0].Received)
+}
@@ -13,22 +13,30 @@ import (
 	"time"
 
 	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/server"
 
 	// For loadProfileServer
 	_ "net/http/pprof"
 
+	humanize "github.com/dustin/go-humanize"
 	e "github.com/pkg/errors"
 	"github.com/sahib/brig/backend"
 	"github.com/sahib/brig/catfs"
 	fserrs "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/events"
+	"github.com/sahib/brig/events/bus"
 	"github.com/sahib/brig/fuse"
 	"github.com/sahib/brig/gateway"
+	"github.com/sahib/brig/gateway/remotesapi"
 	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/net/discovery"
 	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/net/schedule"
 	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server/capnp"
 	"github.com/sahib/brig/util/conductor"
+	"github.com/sahib/brig/util/errkind"
+	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -80,8 +88,33 @@ type base struct {
 	// evListenerCancel can be called on quitting the daemon
 	evListenerCancel context.CancelFunc
 
+	// evBus is the local event bus (commits, syncs, ...)
+	evBus *bus.Bus
+
 	// pprofPort is the port pprof can acquire profiling from
 	pprofPort int
+
+	// announcer makes us visible to `brig remote discover` on the LAN.
+	// It is nil if discovery is disabled.
+	announcer *discovery.Announcer
+
+	// offlineQueue holds operations for remotes that were not reachable.
+	offlineQueue *offlineQueue
+
+	// syncLog remembers how past syncs with each remote went.
+	syncLog *p2pnet.SyncLog
+
+	// transferSched decides when content may be fetched from remotes.
+	transferSched *schedule.Scheduler
+
+	// syncLocks prevents concurrent syncs with the same remote.
+	syncLocks syncLocks
+
+	// stats are the local usage statistics shown by `brig stats`.
+	stats *daemonStats
+
+	// activity is what the daemon is doing right now, shown by `brig top`.
+	activity *activity
 }
 
 func repoIsInitialized(path string) error {
@@ -101,7 +134,14 @@ func repoIsInitialized(path string) error {
 // for every local request that is being served to the brig daemon.
 func (b *base) Handle(ctx context.Context, conn net.Conn) {
 	transport := rpc.StreamTransport(conn)
-	srv := capnp.API_ServerToClient(newAPIHandler(b))
+
+	// Like capnp.API_ServerToClient, but count every call for the stats,
+	// keep track of the ones that are being served right now and tell
+	// the client what kind of error it got:
+	methods := b.stats.wrapMethods(capnp.API_Methods(nil, newAPIHandler(b)))
+	methods = b.activity.wrapMethods(methods)
+	methods = wrapErrorKinds(methods)
+	srv := capnp.API{Client: server.New(methods, nil)}
 	rpcConn := rpc.NewConn(
 		transport,
 		rpc.MainInterface(srv.Client),
@@ -134,6 +174,8 @@ func (b *base) loadRepo() error {
 	}
 
 	b.repo = rp
+	b.repo.StartConfigWatcher()
+	b.repo.StartSubkeyRotation()
 
 	// Adjust the backend's logging output here, since this should be done
 	// before actually loading the backend (which might produce logs already)
@@ -223,6 +265,8 @@ func (b *base) loadPeerServer() error {
 	}()
 
 	b.peerServer = srv
+	b.repo.SetContentFetcher(b.fetchContent)
+	b.repo.SetContentRepairer(b.repairContent)
 
 	// Initially sync the ping map:
 	addrs := []string{}
@@ -251,7 +295,19 @@ func (b *base) loadPeerServer() error {
 		self.Addr,
 	)
 
+	srv.SetOnUnknownPeer(b.publishUnknownPeer)
+	srv.SetOnBlockServed(b.activity.addSent)
 	b.evListener.RegisterEventHandler(events.FsEvent, false, b.handleFsEvent)
+	srv.PingMap().SetOnSeen(func(addr string) {
+		rmt, err := b.repo.Remotes.RemoteByAddr(addr)
+		if err != nil {
+			return
+		}
+
+		b.evBus.Publish(bus.Event{Kind: bus.KindRemoteSeen, Remote: rmt.Name})
+		go b.runQueued(rmt.Name, true)
+	})
+
 	if err := b.evListener.SetupListeners(b.evListenerCtx, addrs); err != nil {
 		log.Warningf("failed to setup event listeners: %v", err)
 	}
@@ -272,6 +328,40 @@ func (b *base) loadPeerServer() error {
 
 //////
 
+func (b *base) loadDiscovery() error {
+	if !b.repo.Config.Bool("net.discovery.enabled") {
+		return nil
+	}
+
+	log.Debugf("loading lan discovery")
+	self, err := b.peerServer.Identity()
+	if err != nil {
+		return err
+	}
+
+	ownPubKey, err := b.repo.Keyring().OwnPubKey()
+	if err != nil {
+		return err
+	}
+
+	announcer, err := discovery.NewAnnouncer(discovery.Info{
+		Owner:       b.repo.Owner,
+		Fingerprint: string(peer.BuildFingerprint(self.Addr, ownPubKey)),
+		Addr:        self.Addr,
+	})
+
+	if err != nil {
+		// Not fatal; some networks do not allow multicast.
+		log.Warningf("failed to announce ourselves on the local network: %v", err)
+		return nil
+	}
+
+	b.announcer = announcer
+	return nil
+}
+
+//////
+
 func (b *base) loadGateway() error {
 	log.Debugf("loading gateway")
 
@@ -290,6 +380,8 @@ func (b *base) loadGateway() error {
 		}
 
 		b.gateway = gateway
+		b.gateway.SetEventBus(b.evBus)
+		b.gateway.SetBackendCheck(b.backend.IsOnline)
 		b.gateway.Start()
 		return nil
 	})
@@ -308,12 +400,83 @@ func (mn mountNotifier) PublishEvent() {
 func (b *base) loadMounts() error {
 	return b.withCurrFs(func(fs *catfs.FS) error {
 		b.mounts = fuse.NewMountTable(fs, mountNotifier{b: b})
+
+		delayKey := "fs.fuse.writeback_delay"
+		b.mounts.SetWritebackDelay(b.repo.Config.Duration(delayKey))
+		b.repo.Config.AddEvent(delayKey, func(key string) {
+			b.mounts.SetWritebackDelay(b.repo.Config.Duration(delayKey))
+		})
+
+		ttlKey := "fs.fuse.cache_ttl"
+		b.mounts.SetCacheTTL(b.repo.Config.Duration(ttlKey))
+		b.repo.Config.AddEvent(ttlKey, func(key string) {
+			b.mounts.SetCacheTTL(b.repo.Config.Duration(ttlKey))
+		})
+
+		cacheSizeKey := "fs.fuse.read_cache_size"
+		setReadCacheSize := func(key string) {
+			size, err := humanize.ParseBytes(b.repo.Config.String(cacheSizeKey))
+			if err != nil {
+				log.Warningf("bad %s: %v", cacheSizeKey, err)
+				return
+			}
+
+			b.mounts.SetReadCacheSize(int64(size))
+		}
+
+		setReadCacheSize(cacheSizeKey)
+		b.repo.Config.AddEvent(cacheSizeKey, setReadCacheSize)
+
+		// The mounts might have cached outdated metadata after changes:
+		sub := b.evBus.Subscribe("", bus.KindFsChange, bus.KindCommit, bus.KindSync)
+		go func() {
+			for range sub.Events() {
+				b.mounts.Invalidate()
+			}
+		}()
+
 		return nil
 	})
 }
 
 /////////
 
+func (b *base) loadEventBus() error {
+	evBus, err := bus.New(
+		filepath.Join(b.repo.BaseFolder, "events.json"),
+		int(b.repo.Config.Int("events.bus_history")),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	b.evBus = evBus
+	b.repo.SetCommitHook(func(owner, msg string) {
+		ev := bus.Event{Kind: bus.KindCommit, Message: msg}
+		if owner != b.repo.Owner {
+			ev.Remote = owner
+		}
+
+		b.evBus.Publish(ev)
+	})
+
+	return nil
+}
+
+// publishTransfer tells the event bus how far a transfer from `who` is.
+func (b *base) publishTransfer(who, what string, done, total int64) {
+	b.evBus.Publish(bus.Event{
+		Kind:    bus.KindTransfer,
+		Remote:  who,
+		Message: what,
+		Done:    done,
+		Total:   total,
+	})
+}
+
+/////////
+
 func (b *base) loadAll() error {
 	if err := b.loadRepo(); err != nil {
 		return err
@@ -323,14 +486,39 @@ func (b *base) loadAll() error {
 		return err
 	}
 
+	if err := b.loadEventBus(); err != nil {
+		return err
+	}
+
+	b.loadNotifications()
+
 	if err := b.loadMounts(); err != nil {
 		return err
 	}
 
+	if err := b.loadOfflineQueue(); err != nil {
+		return err
+	}
+
+	if err := b.loadSyncLog(); err != nil {
+		return err
+	}
+
+	b.loadTransferSchedule()
+
 	if err := b.loadPeerServer(); err != nil {
 		return err
 	}
 
+	if err := b.loadDiscovery(); err != nil {
+		return err
+	}
+
+	b.loadHeadAdvertisement()
+	b.loadGossip()
+	b.loadReplication()
+	b.loadIdentityMigration()
+
 	if err := b.loadGateway(); err != nil {
 		return err
 	}
@@ -383,7 +571,7 @@ func (b *base) withNetClient(who string, fn func(ctl *p2pnet.Client) error) erro
 
 	ctl, err := p2pnet.Dial(subCtx, who, b.repo, b.backend, b.peerServer.PingMap())
 	if err != nil {
-		return e.Wrapf(err, "dial")
+		return &offlineError{remote: who, err: err}
 	}
 
 	if err := fn(ctl); err != nil {
@@ -410,6 +598,12 @@ func (b *base) Quit() (err error) {
 		log.Warningf("failed to close peer server: %v", err)
 	}
 
+	if b.announcer != nil {
+		if err := b.announcer.Close(); err != nil {
+			log.Warningf("failed to stop lan discovery: %v", err)
+		}
+	}
+
 	b.evListenerCancel()
 	log.Infof("shutting down event listener...")
 	if b.evListener != nil {
@@ -418,6 +612,11 @@ func (b *base) Quit() (err error) {
 		}
 	}
 
+	log.Infof("closing event bus...")
+	if err := b.evBus.Close(); err != nil {
+		log.Warningf("failed to close event bus: %v", err)
+	}
+
 	log.Infof("trying to lock repository...")
 
 	if err = b.repo.Close(b.password); err != nil {
@@ -451,6 +650,8 @@ func newBase(
 		quitCh:      quitCh,
 		logToStdout: logToStdout,
 		conductor:   conductor.New(5*time.Minute, 100),
+		stats:       newDaemonStats(),
+		activity:    newActivity(),
 	}
 }
 
@@ -464,14 +665,28 @@ func (b *base) doFetch(who string) error {
 		return b.withRemoteFs(who, func(remoteFs *catfs.FS) error {
 			// Not all remotes might allow doing a full fetch.
 			// This is only possible when having full access to all folders.
-			if isAllowed, err := ctl.IsCompleteFetchAllowed(); isAllowed && err != nil {
+			if isAllowed, err := ctl.IsCompleteFetchAllowed(); isAllowed && err == nil {
 				log.Debugf("fetch: doing complete fetch for %s", who)
+				b.publishTransfer(who, "fetch-store", 0, 0)
+				transferID := b.activity.startTransfer("fetch-store", who, "metadata")
+				defer b.activity.endTransfer(transferID)
+
 				storeBuf, err := ctl.FetchStore()
 				if err != nil {
 					return e.Wrapf(err, "fetch-store")
 				}
 
-				return e.Wrapf(remoteFs.Import(storeBuf), "import")
+				size := int64(storeBuf.Len())
+				b.publishTransfer(who, "fetch-store", size, size)
+				b.stats.addSynced(who, size)
+				b.activity.addReceived(transferID, who, size)
+
+				if err := remoteFs.Import(storeBuf); err != nil {
+					return e.Wrapf(err, "import")
+				}
+
+				// The store is a copy of theirs, so their signature has to match:
+				return e.Wrapf(b.repo.VerifyRemoteCommit(who, remoteFs, "HEAD"), "verify")
 			}
 
 			// Ask our local copy of the remote what the last patch index was.
@@ -482,31 +697,86 @@ func (b *base) doFetch(who string) error {
 
 			// Get the missing changes since then:
 			log.Debugf("fetch: doing partial fetch for %s starting at %d", who, fromIndex)
+			b.publishTransfer(who, "fetch-patch", 0, 0)
+			transferID := b.activity.startTransfer("fetch-patch", who, "metadata")
+			defer b.activity.endTransfer(transferID)
+
 			patch, err := ctl.FetchPatch(fromIndex)
 			if err != nil {
 				return err
 			}
 
+			size := int64(len(patch))
+			b.publishTransfer(who, "fetch-patch", size, size)
+			b.stats.addSynced(who, size)
+			b.activity.addReceived(transferID, who, size)
+
 			return remoteFs.ApplyPatch(patch)
 		})
 	})
 }
 
+func (b *base) doPush(who string) error {
+	return b.withNetClient(who, func(ctl *p2pnet.Client) error {
+		pushAllowed, err := ctl.IsPushAllowed()
+		if err != nil {
+			return err
+		}
+
+		if !pushAllowed {
+			return errkind.Wrap(errkind.Unauthorized, fmt.Errorf("cannot push: remote does not allow it"))
+		}
+
+		return ctl.Push()
+	})
+}
+
+// doSync merges the state of `withWhom` into ours. If a sync with
+// `withWhom` is already running, it waits for it to finish first.
 func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff, error) {
+	b.syncLocks.lock(withWhom)
+	defer b.syncLocks.unlock(withWhom)
+
+	return b.doSyncLocked(withWhom, needFetch, msg)
+}
+
+// tryDoSync is like doSync, but returns remotesapi.ErrSyncInProgress
+// instead of waiting for a running sync with `withWhom`.
+func (b *base) tryDoSync(withWhom string, needFetch bool, msg string) (*catfs.Diff, error) {
+	if !b.syncLocks.tryLock(withWhom) {
+		return nil, remotesapi.ErrSyncInProgress
+	}
+
+	defer b.syncLocks.unlock(withWhom)
+	return b.doSyncLocked(withWhom, needFetch, msg)
+}
+
+func (b *base) doSyncLocked(withWhom string, needFetch bool, msg string) (*catfs.Diff, error) {
+	started := time.Now()
+	diff, conflicts, err := b.doSyncMerge(withWhom, needFetch, msg)
+	b.recordSync(withWhom, started, diff, conflicts, err)
+	return diff, err
+}
+
+// doSyncMerge does the actual work of doSyncLocked. It also returns
+// the number of conflicts that had to be resolved.
+func (b *base) doSyncMerge(withWhom string, needFetch bool, msg string) (*catfs.Diff, int, error) {
 	if needFetch {
 		if err := b.doFetch(withWhom); err != nil {
-			return nil, e.Wrapf(err, "fetch")
+			return nil, 0, e.Wrapf(err, "fetch")
 		}
 	}
 
 	var diff *catfs.Diff
+	conflicts := []catfs.SyncConflict{}
 
-	return diff, b.withCurrFs(func(ownFs *catfs.FS) error {
+	err := b.withCurrFs(func(ownFs *catfs.FS) error {
 		return b.withRemoteFs(withWhom, func(remoteFs *catfs.FS) error {
 			// Automatically make a commit before merging with their state:
 			timeStamp := time.Now().UTC().Format(time.RFC3339)
 			commitMsg := fmt.Sprintf("sync with %s on %s", withWhom, timeStamp)
-			if err := ownFs.MakeCommit(commitMsg); err != nil && err != fserrs.ErrNoChange {
+			meta := catfs.CommitMeta{Origin: catfs.CommitOriginSync}
+			if err := ownFs.MakeCommitWithMeta(commitMsg, meta); err != nil && err != fserrs.ErrNoChange {
 				return e.Wrapf(err, "merge-commit")
 			}
 
@@ -517,24 +787,26 @@ func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff,
 
 			log.Debugf("Starting sync with %s", withWhom)
 
-			rmt, err := b.repo.Remotes.Remote(withWhom)
+			opts, err := b.syncOptions(withWhom)
 			if err != nil {
 				return err
 			}
 
-			err = ownFs.Sync(
-				remoteFs,
-				catfs.SyncOptMessage(msg),
-				catfs.SyncOptConflictStrategy(rmt.ConflictStrategy),
-				catfs.SyncOptReadOnlyFolders(rmt.ReadOnlyFolders()),
-				catfs.SyncOptConflictgStrategyPerFolder(rmt.ConflictStrategyPerFolder()),
-			)
-
-			if err != nil {
+			opts = append(opts, catfs.SyncOptMessage(msg))
+			opts = append(opts, b.conflictSyncOptions(withWhom, &conflicts)...)
+			if err := ownFs.Sync(remoteFs, opts...); err != nil {
 				return err
 			}
 
+			b.reportConflicts(withWhom, conflicts)
+			b.stats.countSync(withWhom)
+
 			log.Debugf("Sync with %s done", withWhom)
+			b.evBus.Publish(bus.Event{
+				Kind:    bus.KindSync,
+				Remote:  withWhom,
+				Message: msg,
+			})
 
 			cmtAfter, err := ownFs.Head()
 			if err != nil {
@@ -545,6 +817,64 @@ func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff,
 			return err
 		})
 	})
+
+	return diff, len(conflicts), err
+}
+
+// configFor returns the config with the overrides of `remote` applied.
+// If that fails, the plain config is used.
+func (b *base) configFor(remote string) *config.Config {
+	cfg, err := b.repo.ConfigFor(remote)
+	if err != nil {
+		log.Warningf("failed to apply config of remote %s: %v", remote, err)
+		return b.repo.Config
+	}
+
+	return cfg
+}
+
+// syncOptions returns the options configured for syncing with `withWhom`.
+func (b *base) syncOptions(withWhom string) ([]catfs.SyncOption, error) {
+	rmt, err := b.repo.Remotes.Remote(withWhom)
+	if err != nil {
+		return nil, err
+	}
+
+	// The remote might override some of the sync settings:
+	cfg, err := b.repo.ConfigFor(withWhom)
+	if err != nil {
+		return nil, err
+	}
+
+	return []catfs.SyncOption{
+		catfs.SyncOptIgnore(cfg.Bool("fs.sync.ignore_removed"), cfg.Bool("fs.sync.ignore_moved")),
+		catfs.SyncOptConflictStrategy(cfg.String("fs.sync.conflict_strategy")),
+		catfs.SyncOptConflictStrategy(rmt.ConflictStrategy),
+		catfs.SyncOptReadOnlyFolders(rmt.ReadOnlyFolders()),
+		catfs.SyncOptConflictgStrategyPerFolder(rmt.ConflictStrategyPerFolder()),
+	}, nil
+}
+
+// doSyncPreview tells what doSync would change, without changing anything.
+func (b *base) doSyncPreview(withWhom string, needFetch bool) (*catfs.Diff, error) {
+	if needFetch {
+		if err := b.doFetch(withWhom); err != nil {
+			return nil, e.Wrapf(err, "fetch")
+		}
+	}
+
+	opts, err := b.syncOptions(withWhom)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff *catfs.Diff
+	return diff, b.withCurrFs(func(ownFs *catfs.FS) error {
+		return b.withRemoteFs(withWhom, func(remoteFs *catfs.FS) error {
+			diff, err = ownFs.SyncPreview(remoteFs, opts...)
+			return err
+		})
+	})
 }
 
 func (b *base) handleFsEvent(ev *events.Event) {
@@ -561,12 +891,19 @@ func (b *base) handleFsEvent(ev *events.Event) {
 	log.Infof("doing sync with »%s« since we received an update notification.", rmt.Name)
 
 	msg := fmt.Sprintf("sync due to notification from »%s«", rmt.Name)
-	if _, err := b.doSync(rmt.Name, true, msg); err != nil {
+	if _, err := b.tryDoSync(rmt.Name, true, msg); err != nil {
+		if err == remotesapi.ErrSyncInProgress {
+			log.Infof("not syncing with »%s«: already syncing with them.", rmt.Name)
+			return
+		}
+
 		log.Warningf("sync failed: %v", err)
 	}
 }
 
 func (b *base) notifyFsChangeEvent() {
+	b.evBus.Publish(bus.Event{Kind: bus.KindFsChange})
+
 	if b.evListener == nil {
 		return
 	}
@@ -597,7 +934,7 @@ func (b *base) initialSyncWithAutoUpdatePeers() error {
 		}
 
 		msg := fmt.Sprintf("sync with »%s« due to initial auto-update", rmt.Name)
-		if _, err := b.doSync(rmt.Name, true, msg); err != nil {
+		if _, err := b.tryDoSync(rmt.Name, true, msg); err != nil {
 			log.Warningf("failed to sync initially with %s: %v", rmt.Name, err)
 		}
 	}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+// doCreateBundle creates a bundle with our changes between `fromRev`
+// and `toRev`, signed with our identity.
+func (b *base) doCreateBundle(fromRev, toRev string) ([]byte, error) {
+	var bundle *catfs.Bundle
+
+	err := b.withCurrFs(func(fs *catfs.FS) error {
+		var err error
+		bundle, err = fs.MakeBundle(fromRev, toRev)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bundle.Sign(b.repo.Keyring().SignWithIdentity); err != nil {
+		return nil, e.Wrapf(err, "sign")
+	}
+
+	return bundle.Marshal(), nil
+}
+
+// doApplyBundle checks that `data` was signed by the remote it claims
+// to come from, applies it to our copy of that remote and syncs with it.
+func (b *base) doApplyBundle(data []byte) (string, *catfs.Diff, error) {
+	bundle, err := catfs.UnmarshalBundle(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	owner := bundle.Owner
+	if owner == b.repo.Owner {
+		return "", nil, fmt.Errorf("bundle was created by ourselves")
+	}
+
+	if _, err := b.repo.Remotes.Remote(owner); err != nil {
+		return "", nil, e.Wrapf(err, "bundle is from unknown remote %s", owner)
+	}
+
+	pubKey, err := b.repo.Keyring().PubKeyFor(owner)
+	if err != nil {
+		return "", nil, e.Wrapf(err, "no public key for %s", owner)
+	}
+
+	err = bundle.Verify(func(data, sig []byte) error {
+		return repo.VerifyIdentitySignature(pubKey, data, sig)
+	})
+
+	if err != nil {
+		return "", nil, e.Wrapf(err, "verify")
+	}
+
+	log.Infof(
+		"applying bundle of %s (commit[%d]..commit[%d], %d blocks)",
+		owner, bundle.FromIndex, bundle.ToIndex, len(bundle.Blocks),
+	)
+
+	err = b.withRemoteFs(owner, func(remoteFs *catfs.FS) error {
+		return remoteFs.ApplyBundle(bundle)
+	})
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf("applied bundle of %s", owner)
+	diff, err := b.doSync(owner, false, msg)
+	return owner, diff, err
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/events/bus"
+	log "github.com/sirupsen/logrus"
+)
+
+// conflictHookInput is what the conflict hook gets on stdin.
+type conflictHookInput struct {
+	Remote string `json:"remote"`
+	Path   string `json:"path"`
+	bus.Conflict
+}
+
+func conflictToBus(conflict catfs.SyncConflict) *bus.Conflict {
+	return &bus.Conflict{
+		Strategy:      conflict.Strategy,
+		OwnHash:       conflict.OwnHash.B58String(),
+		RemoteHash:    conflict.RemoteHash.B58String(),
+		OwnModTime:    conflict.OwnModTime,
+		RemoteModTime: conflict.RemoteModTime,
+	}
+}
+
+// runConflictHook calls the command in fs.sync.conflict_hook for a single
+// conflict. The conflict is passed as json on stdin and in a few
+// environment variables. The first line of the output is returned.
+func (b *base) runConflictHook(remote string, conflict catfs.SyncConflict) (string, error) {
+	cfg := b.configFor(remote)
+	hook := cfg.String("fs.sync.conflict_hook")
+	if hook == "" {
+		return "", nil
+	}
+
+	input, err := json.Marshal(conflictHookInput{
+		Remote:   remote,
+		Path:     conflict.Path,
+		Conflict: *conflictToBus(conflict),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration("fs.sync.conflict_hook_timeout"))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hook)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(
+		os.Environ(),
+		"BRIG_CONFLICT_REMOTE="+remote,
+		"BRIG_CONFLICT_PATH="+conflict.Path,
+		"BRIG_CONFLICT_STRATEGY="+conflict.Strategy,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("conflict hook failed for %s: %v", conflict.Path, err)
+	}
+
+	line, _ := bufio.NewReader(bytes.NewReader(out)).ReadString('\n')
+	return strings.TrimSpace(line), nil
+}
+
+// conflictSyncOptions returns the options that report conflicts of a sync
+// with `remote`. Conflicts are collected in `conflicts`; if the hook may
+// resolve them, it is called right away.
+func (b *base) conflictSyncOptions(remote string, conflicts *[]catfs.SyncConflict) []catfs.SyncOption {
+	opts := []catfs.SyncOption{
+		catfs.SyncOptOnConflict(func(conflict catfs.SyncConflict) {
+			*conflicts = append(*conflicts, conflict)
+		}),
+	}
+
+	cfg := b.configFor(remote)
+	if cfg.String("fs.sync.conflict_hook") == "" || !cfg.Bool("fs.sync.conflict_hook_resolves") {
+		return opts
+	}
+
+	return append(opts, catfs.SyncOptConflictResolver(func(conflict catfs.SyncConflict) string {
+		strategy, err := b.runConflictHook(remote, conflict)
+		if err != nil {
+			log.Warningf("%v; using the %s strategy", err, conflict.Strategy)
+			return ""
+		}
+
+		return strategy
+	}))
+}
+
+// reportConflicts publishes an event for every conflict and calls the hook
+// for them, unless it was already called to resolve them.
+func (b *base) reportConflicts(remote string, conflicts []catfs.SyncConflict) {
+	for _, conflict := range conflicts {
+		b.evBus.Publish(bus.Event{
+			Kind:   bus.KindConflict,
+			Remote: remote,
+			Path:   conflict.Path,
+			Message: fmt.Sprintf(
+				"resolved with %s (ours: %s, theirs: %s)",
+				conflict.Strategy,
+				conflict.OwnModTime.Format(time.RFC3339),
+				conflict.RemoteModTime.Format(time.RFC3339),
+			),
+			Conflict: conflictToBus(conflict),
+		})
+	}
+
+	cfg := b.configFor(remote)
+	if len(conflicts) == 0 || cfg.Bool("fs.sync.conflict_hook_resolves") {
+		return
+	}
+
+	// The hook might take a while; the sync is done already anyways.
+	go func() {
+		for _, conflict := range conflicts {
+			if _, err := b.runConflictHook(remote, conflict); err != nil {
+				log.Warningf("%v", err)
+			}
+		}
+	}()
+}
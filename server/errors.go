@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sahib/brig/backend/httpipfs"
+	"github.com/sahib/brig/catfs"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/gateway/remotesapi"
+	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/errkind"
+	capnplib "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+// errorKind sorts `err` into one of the kinds the client understands.
+// Errors that were marked with errkind.Wrap keep their kind, the
+// well known errors of the other packages are looked up here.
+func errorKind(err error) errkind.Kind {
+	if kind := errkind.Of(err); kind != errkind.Unknown {
+		return kind
+	}
+
+	for ; err != nil; err = errkind.Next(err) {
+		switch {
+		case ie.IsNoSuchFileError(err), ie.IsErrNoSuchRef(err):
+			return errkind.NotFound
+		case isOfflineError(err):
+			return errkind.Offline
+		}
+
+		switch err {
+		case repo.ErrNoSuchRemote, repo.ErrNoSuchRepo, catfs.ErrNoSuchHold:
+			return errkind.NotFound
+		case ie.ErrExists, ie.ErrStageNotEmpty, catfs.ErrHeadMoved, catfs.ErrHeld, remotesapi.ErrSyncInProgress:
+			return errkind.Conflict
+		case httpipfs.ErrOffline:
+			return errkind.Offline
+		case p2pnet.ErrNoDelegation:
+			return errkind.Unauthorized
+		}
+	}
+
+	return errkind.Unknown
+}
+
+// wrapErrorKinds puts the kind of every error returned by `methods`
+// in front of its message, so it survives the way over the wire.
+// The client takes it off again, see client/errors.go.
+func wrapErrorKinds(methods []server.Method) []server.Method {
+	for idx := range methods {
+		impl := methods[idx].Impl
+		methods[idx].Impl = func(ctx context.Context, opts capnplib.CallOptions, p, r capnplib.Struct) error {
+			err := impl(ctx, opts, p, r)
+			if kind := errorKind(err); kind != errkind.Unknown {
+				return errors.New(errkind.Encode(kind, err.Error()))
+			}
+
+			return err
+		}
+	}
+
+	return methods
+}
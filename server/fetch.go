@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sahib/brig/catfs"
+	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/net/fetch"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// blockStore makes the backend usable as fetch.Store.
+type blockStore struct {
+	bk catfs.BlockBackend
+
+	// refetch makes the store pretend to have no blocks,
+	// so that all of them are fetched again.
+	refetch bool
+}
+
+func (bs *blockStore) Has(hash h.Hash) (bool, error) {
+	if bs.refetch {
+		return false, nil
+	}
+
+	return bs.bk.HasBlock(hash)
+}
+
+func (bs *blockStore) Links(hash h.Hash) ([]h.Hash, error) {
+	return bs.bk.BlockLinks(hash)
+}
+
+func (bs *blockStore) Put(data []byte) (h.Hash, error) {
+	return bs.bk.PutBlock(data)
+}
+
+// fetchPeer is a remote we are connected to, usable as fetch.Peer.
+type fetchPeer struct {
+	name string
+	ctl  *p2pnet.Client
+
+	// Where to report received blocks (for `brig top`).
+	activity   *activity
+	transferID uint64
+}
+
+func (fp *fetchPeer) Name() string {
+	return fp.name
+}
+
+func (fp *fetchPeer) FetchBlock(ctx context.Context, hash h.Hash) ([]byte, error) {
+	data, err := fp.ctl.FetchBlock(ctx, hash)
+	if err == nil {
+		fp.activity.addReceived(fp.transferID, fp.name, int64(len(data)))
+	}
+
+	return data, err
+}
+
+// fetchContent gets the blocks of `hash` from all online remotes
+// that have a file with this content, several blocks at once.
+// It does nothing when less than two remotes could help,
+// since the backend alone is just as fast then.
+func (b *base) fetchContent(hash h.Hash) error {
+	if !b.repo.Config.Bool("fs.pre_cache.parallel_fetch") {
+		return nil
+	}
+
+	return b.fetchFromRemotes(hash, 2, false)
+}
+
+// repairContent fetches all blocks of `hash` again from the remotes that
+// have it, even the ones we have locally; those might be the corrupt ones.
+// It is used by the scrubber.
+func (b *base) repairContent(hash h.Hash) error {
+	if b.transferSched != nil {
+		if ok, reason := b.transferSched.HeavyAllowed(); !ok {
+			return fmt.Errorf("not repairing now: %s", reason)
+		}
+	}
+
+	return b.fetchFromRemotes(hash, 1, true)
+}
+
+func (b *base) fetchFromRemotes(hash h.Hash, minPeers int, refetch bool) error {
+	bbk, ok := b.backend.(catfs.BlockBackend)
+	if !ok {
+		if refetch {
+			return fmt.Errorf("backend does not support fetching single blocks")
+		}
+
+		return nil
+	}
+
+	remotes, err := b.repo.Remotes.ListRemotes()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+
+	transferID := b.activity.startTransfer("fetch-content", "", hash.B58String())
+	defer b.activity.endTransfer(transferID)
+
+	peers := []fetch.Peer{}
+	for _, remote := range remotes {
+		if !b.peerServer.PingMap().IsAuthenticated(remote.Fingerprint.Addr()) {
+			continue
+		}
+
+		refs := 0
+		err := b.withRemoteFs(remote.Name, func(fs *catfs.FS) (err error) {
+			refs, err = fs.ContentRefCount(hash)
+			return err
+		})
+
+		if err != nil || refs == 0 {
+			continue
+		}
+
+		ctl, err := p2pnet.Dial(ctx, remote.Name, b.repo, b.backend, b.peerServer.PingMap())
+		if err != nil {
+			log.Debugf("fetch: could not dial %s: %v", remote.Name, err)
+			continue
+		}
+
+		defer ctl.Close()
+		peers = append(peers, &fetchPeer{
+			name:       remote.Name,
+			ctl:        ctl,
+			activity:   b.activity,
+			transferID: transferID,
+		})
+	}
+
+	if len(peers) < minPeers {
+		if refetch {
+			return fmt.Errorf("no online remote has this content")
+		}
+
+		return nil
+	}
+
+	store := &blockStore{bk: bbk, refetch: refetch}
+	stats, err := fetch.Fetch(ctx, store, peers, []h.Hash{hash}, fetch.Options{
+		InFlight: int(b.repo.Config.Int("fs.pre_cache.in_flight")),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range stats.Peers {
+		log.Debugf(
+			"fetch: %s delivered %d blocks (%d bytes, %.0f bytes/s)",
+			peer.Name, peer.Blocks, peer.Bytes, peer.Throughput,
+		)
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"net"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sahib/brig/catfs"
 	ie "github.com/sahib/brig/catfs/errors"
@@ -53,6 +55,14 @@ func statToCapnp(info *catfs.StatInfo, seg *capnplib.Segment) (*capnp.StatInfo,
 		return nil, err
 	}
 
+	if err := capInfo.SetMimeType(info.MimeType); err != nil {
+		return nil, err
+	}
+
+	if err := capInfo.SetKind(info.Kind); err != nil {
+		return nil, err
+	}
+
 	capInfo.SetSize(info.Size)
 	capInfo.SetInode(info.Inode)
 	capInfo.SetIsDir(info.IsDir)
@@ -145,7 +155,16 @@ func (fh *fsHandler) Cat(call capnp.FS_cat) error {
 		return err
 	}
 
+	at, err := call.Params.At()
+	if err != nil {
+		return err
+	}
+
 	return fh.base.withFsFromPath(path, func(url *URL, fs *catfs.FS) error {
+		if at != "" {
+			return fh.catAt(call, fs, url.Path, at)
+		}
+
 		if call.Params.Offline() {
 			isCached, err := fs.IsCached(url.Path)
 			if err != nil {
@@ -186,6 +205,51 @@ func (fh *fsHandler) Cat(call capnp.FS_cat) error {
 	})
 }
 
+// catAt serves `path` as it was at `at`. Directories are sent as tar archive,
+// since the client cannot know beforehand what `path` was back then.
+func (fh *fsHandler) catAt(call capnp.FS_cat, fs *catfs.FS, path, at string) error {
+	if call.Params.Offline() {
+		return fmt.Errorf("offline mode is not supported for old versions")
+	}
+
+	info, err := fs.StatAt(at, path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir {
+		port, err := bootTransferServer(fs, fh.base.bindHost, func(conn net.Conn) {
+			if err := fs.Archive(at, path, catfs.ArchiveTar, conn, nil); err != nil {
+				log.Warningf("tar failed for path %s at %s: %v", path, at, err)
+			}
+		})
+
+		call.Results.SetPort(int32(port))
+		return err
+	}
+
+	stream, err := fs.CatAt(at, path)
+	if err != nil {
+		return err
+	}
+
+	port, err := bootTransferServer(fs, fh.base.bindHost, func(conn net.Conn) {
+		defer stream.Close()
+		if _, err := io.Copy(conn, stream); err != nil {
+			log.Warningf("IO failed for path %s at %s: %v", path, at, err)
+		}
+	})
+
+	if err != nil {
+		// Close the stream, since the copy callback was likely not called.
+		stream.Close()
+		return err
+	}
+
+	call.Results.SetPort(int32(port))
+	return nil
+}
+
 func (fh *fsHandler) Tar(call capnp.FS_tar) error {
 	server.Ack(call.Options)
 
@@ -222,6 +286,41 @@ func (fh *fsHandler) Tar(call capnp.FS_tar) error {
 	})
 }
 
+func (fh *fsHandler) Archive(call capnp.FS_archive) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	rev, err := call.Params.Rev()
+	if err != nil {
+		return err
+	}
+
+	format, err := call.Params.Format()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withFsFromPath(path, func(url *URL, fs *catfs.FS) error {
+		// Check early, so the client gets a proper error:
+		if _, err := fs.StatAt(rev, url.Path); err != nil {
+			return err
+		}
+
+		port, err := bootTransferServer(fs, fh.base.bindHost, func(conn net.Conn) {
+			if err := fs.Archive(rev, url.Path, format, conn, nil); err != nil {
+				log.Warningf("archive failed for path %s at %s: %v", url.Path, rev, err)
+			}
+		})
+
+		call.Results.SetPort(int32(port))
+		return err
+	})
+}
+
 func (fh *fsHandler) Mkdir(call capnp.FS_mkdir) error {
 	server.Ack(call.Options)
 
@@ -323,6 +422,93 @@ func (fh *fsHandler) Copy(call capnp.FS_copy) error {
 	})
 }
 
+func (fh *fsHandler) Transfer(call capnp.FS_transfer) error {
+	server.Ack(call.Options)
+
+	capSources, err := call.Params.Sources()
+	if err != nil {
+		return err
+	}
+
+	dstPath, err := call.Params.DstPath()
+	if err != nil {
+		return err
+	}
+
+	commitMsg, err := call.Params.CommitMsg()
+	if err != nil {
+		return err
+	}
+
+	dstURL, err := parsePath(dstPath)
+	if err != nil {
+		return err
+	}
+
+	// A trailing slash means "put it inside", so keep it:
+	if strings.HasSuffix(dstPath, "/") && dstURL.Path != "/" {
+		dstURL.Path += "/"
+	}
+
+	sources := []string{}
+	for idx := 0; idx < capSources.Len(); idx++ {
+		source, err := capSources.At(idx)
+		if err != nil {
+			return err
+		}
+
+		srcURL, err := parsePath(source)
+		if err != nil {
+			return err
+		}
+
+		if srcURL.User != dstURL.User {
+			return fmt.Errorf("cannot transfer between users: %s <-> %s", srcURL.User, dstURL.User)
+		}
+
+		sources = append(sources, srcURL.Path)
+	}
+
+	return fh.base.withFsFromPath(dstPath, func(url *URL, fs *catfs.FS) error {
+		opts := catfs.TransferOptions{Recursive: call.Params.Recursive()}
+
+		var newPaths []string
+		var err error
+		if call.Params.Copy() {
+			newPaths, err = fs.CopyMany(sources, dstURL.Path, opts)
+		} else {
+			newPaths, err = fs.MoveMany(sources, dstURL.Path, opts)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if commitMsg != "" {
+			meta := catfs.CommitMeta{Origin: catfs.CommitOriginCLI}
+			if err := fs.MakeCommitWithMeta(commitMsg, meta); err != nil && err != ie.ErrNoChange {
+				return err
+			}
+		}
+
+		fh.base.notifyFsChangeEvent()
+
+		seg := call.Results.Segment()
+		capPaths, err := capnplib.NewTextList(seg, int32(len(newPaths)))
+		if err != nil {
+			return err
+		}
+
+		for idx, newPath := range newPaths {
+			if err := capPaths.Set(idx, newPath); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetPaths(capPaths)
+	})
+}
+
 func (fh *fsHandler) Pin(call capnp.FS_pin) error {
 	server.Ack(call.Options)
 
@@ -542,3 +728,341 @@ func (fh *fsHandler) IsCached(call capnp.FS_isCached) error {
 		return nil
 	})
 }
+
+func (fh *fsHandler) SpaceUsage(call capnp.FS_spaceUsage) error {
+	server.Ack(call.Options)
+
+	root, err := call.Params.Root()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withFsFromPath(root, func(url *URL, fs *catfs.FS) error {
+		usage, err := fs.SpaceUsage(url.Path)
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		capUsage, err := capnp.NewSpaceUsage(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capUsage.SetRoot(usage.Root); err != nil {
+			return err
+		}
+
+		capUsage.SetFiles(int64(usage.Files))
+		capUsage.SetUniqueFiles(int64(usage.UniqueFiles))
+		capUsage.SetLogicalSize(usage.LogicalSize)
+		capUsage.SetUniqueSize(usage.UniqueSize)
+		capUsage.SetStoredSize(usage.StoredSize)
+		capUsage.SetStoredLogicalSize(usage.StoredLogicalSize)
+		capUsage.SetHistoryFiles(int64(usage.HistoryFiles))
+		capUsage.SetHistorySize(usage.HistorySize)
+
+		capDirs, err := capnp.NewDirUsage_List(seg, int32(len(usage.Dirs)))
+		if err != nil {
+			return err
+		}
+
+		for idx, dir := range usage.Dirs {
+			capDir := capDirs.At(idx)
+			if err := capDir.SetPath(dir.Path); err != nil {
+				return err
+			}
+
+			capDir.SetFiles(int64(dir.Files))
+			capDir.SetLogicalSize(dir.LogicalSize)
+			capDir.SetUniqueSize(dir.UniqueSize)
+		}
+
+		if err := capUsage.SetDirs(capDirs); err != nil {
+			return err
+		}
+
+		return call.Results.SetUsage(capUsage)
+	})
+}
+
+func (fh *fsHandler) DiskUsage(call capnp.FS_diskUsage) error {
+	server.Ack(call.Options)
+
+	root, err := call.Params.Root()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withFsFromPath(root, func(url *URL, fs *catfs.FS) error {
+		usages, err := fs.DiskUsage(url.Path)
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		capChildren, err := capnp.NewChildUsage_List(seg, int32(len(usages)))
+		if err != nil {
+			return err
+		}
+
+		for idx, usage := range usages {
+			capChild := capChildren.At(idx)
+			if err := capChild.SetPath(usage.Path); err != nil {
+				return err
+			}
+
+			capChild.SetIsDir(usage.IsDir)
+			capChild.SetFiles(int64(usage.Files))
+			capChild.SetLogicalSize(usage.LogicalSize)
+			capChild.SetUniqueSize(usage.UniqueSize)
+			capChild.SetPinnedSize(usage.PinnedSize)
+		}
+
+		return call.Results.SetChildren(capChildren)
+	})
+}
+
+func capSelectorToCatfs(capSel capnp.Selector) (*catfs.Selector, error) {
+	root, err := capSel.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := capSel.Commits()
+	if err != nil {
+		return nil, err
+	}
+
+	olderThan, err := capSel.OlderThan()
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &catfs.Selector{
+		Root:       root,
+		LargerThan: capSel.LargerThan(),
+		Commits:    commits,
+	}
+
+	if olderThan != "" {
+		if sel.OlderThan, err = time.Parse(time.RFC3339, olderThan); err != nil {
+			return nil, err
+		}
+	}
+
+	capGlobs, err := capSel.Globs()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < capGlobs.Len(); idx++ {
+		glob, err := capGlobs.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		sel.Globs = append(sel.Globs, glob)
+	}
+
+	return sel, nil
+}
+
+func (fh *fsHandler) PinSelection(call capnp.FS_pinSelection) error {
+	server.Ack(call.Options)
+
+	capSel, err := call.Params.Selector()
+	if err != nil {
+		return err
+	}
+
+	sel, err := capSelectorToCatfs(capSel)
+	if err != nil {
+		return err
+	}
+
+	if sel.Root == "" {
+		sel.Root = "/"
+	}
+
+	return fh.base.withFsFromPath(sel.Root, func(url *URL, fs *catfs.FS) error {
+		sel.Root = url.Path
+		versions, err := fs.PinSelection(*sel, call.Params.Pin(), call.Params.DryRun())
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		capVersions, err := capnp.NewSelectedVersion_List(seg, int32(len(versions)))
+		if err != nil {
+			return err
+		}
+
+		for idx, version := range versions {
+			capVersion := capVersions.At(idx)
+			if err := capVersion.SetPath(version.Path); err != nil {
+				return err
+			}
+
+			if err := capVersion.SetCommit(version.Commit.Bytes()); err != nil {
+				return err
+			}
+
+			if err := capVersion.SetModTime(version.ModTime.Format(time.RFC3339)); err != nil {
+				return err
+			}
+
+			if err := capVersion.SetContent(version.ContentHash.Bytes()); err != nil {
+				return err
+			}
+
+			if err := capVersion.SetBackendHash(version.BackendHash.Bytes()); err != nil {
+				return err
+			}
+
+			capVersion.SetSize(version.Size)
+			capVersion.SetIsPinned(version.IsPinned)
+			capVersion.SetIsExplicit(version.IsExplicit)
+		}
+
+		return call.Results.SetVersions(capVersions)
+	})
+}
+
+func (fh *fsHandler) Search(call capnp.FS_search) error {
+	server.Ack(call.Options)
+
+	root, err := call.Params.Root()
+	if err != nil {
+		return err
+	}
+
+	text, err := call.Params.Query()
+	if err != nil {
+		return err
+	}
+
+	typ, err := call.Params.Type()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withFsFromPath(root, func(url *URL, fs *catfs.FS) error {
+		results, total, err := fs.Search(catfs.SearchQuery{
+			Root:   url.Path,
+			Text:   text,
+			Type:   typ,
+			Offset: int(call.Params.Offset()),
+			Limit:  int(call.Params.Limit()),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewSearchResult_List(seg, int32(len(results)))
+		if err != nil {
+			return err
+		}
+
+		for idx, result := range results {
+			capResult, err := capnp.NewSearchResult(seg)
+			if err != nil {
+				return err
+			}
+
+			capInfo, err := statToCapnp(result.Info, seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capResult.SetInfo(*capInfo); err != nil {
+				return err
+			}
+
+			capResult.SetScore(result.Score)
+			if err := lst.Set(idx, capResult); err != nil {
+				return err
+			}
+		}
+
+		call.Results.SetTotal(int32(total))
+		return call.Results.SetResults(lst)
+	})
+}
+
+func (fh *fsHandler) HoldAdd(call capnp.FS_holdAdd) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	reason, err := call.Params.Reason()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		return fs.AddHold(path, reason)
+	})
+}
+
+func (fh *fsHandler) HoldRemove(call capnp.FS_holdRemove) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		return fs.RemoveHold(path)
+	})
+}
+
+func (fh *fsHandler) HoldList(call capnp.FS_holdList) error {
+	server.Ack(call.Options)
+	seg := call.Results.Segment()
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		holds, err := fs.Holds()
+		if err != nil {
+			return err
+		}
+
+		lst, err := capnp.NewHold_List(seg, int32(len(holds)))
+		if err != nil {
+			return err
+		}
+
+		for idx, hold := range holds {
+			capHold, err := capnp.NewHold(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capHold.SetPath(hold.Path); err != nil {
+				return err
+			}
+
+			if err := capHold.SetCreated(hold.Created.Format(time.RFC3339)); err != nil {
+				return err
+			}
+
+			if err := capHold.SetOwner(hold.Owner); err != nil {
+				return err
+			}
+
+			if err := capHold.SetReason(hold.Reason); err != nil {
+				return err
+			}
+
+			lst.Set(idx, capHold)
+		}
+
+		return call.Results.SetHolds(lst)
+	})
+}
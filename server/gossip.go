@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/events/bus"
+	p2pnet "github.com/sahib/brig/net"
+	log "github.com/sirupsen/logrus"
+)
+
+// knownHeadIndex returns the index of the latest commit of `name`
+// we fetched, or -1 if we never fetched anything from them.
+func (b *base) knownHeadIndex(name string) int64 {
+	if !b.repo.HaveFS(name) {
+		return -1
+	}
+
+	index := int64(-1)
+	err := b.withRemoteFs(name, func(fs *catfs.FS) error {
+		head, err := fs.CommitInfo("head")
+		if err != nil {
+			return err
+		}
+
+		index = head.Index
+		return nil
+	})
+
+	if err != nil {
+		log.Debugf("gossip: no head for %s: %v", name, err)
+	}
+
+	return index
+}
+
+// handleGossipNews syncs with the owner of a newer head we heard about.
+// If the owner is offline, we sync with the remote that told us instead,
+// since it most likely synced with the owner already.
+func (b *base) handleGossipNews(news p2pnet.GossipNews) {
+	if !b.repo.Config.Bool("net.gossip.enabled") {
+		return
+	}
+
+	owner, err := b.repo.Remotes.Remote(news.Owner)
+	if err != nil || !owner.AcceptAutoUpdates {
+		return
+	}
+
+	if news.Index <= b.knownHeadIndex(owner.Name) {
+		return
+	}
+
+	log.Infof("gossip: »%s« has new commits (told by »%s«)", owner.Name, news.Via)
+
+	msg := fmt.Sprintf("sync due to gossip about »%s« from »%s«", owner.Name, news.Via)
+	_, err = b.doSync(owner.Name, true, msg)
+	if err == nil {
+		return
+	}
+
+	if !isOfflineError(err) || news.Via == owner.Name {
+		log.Warningf("gossip: sync with %s failed: %v", owner.Name, err)
+		return
+	}
+
+	relay, err := b.repo.Remotes.Remote(news.Via)
+	if err != nil || !relay.AcceptAutoUpdates {
+		return
+	}
+
+	log.Infof("gossip: »%s« is offline, syncing with »%s« instead", owner.Name, relay.Name)
+	if _, err := b.doSync(relay.Name, true, msg); err != nil {
+		log.Warningf("gossip: sync with %s failed: %v", relay.Name, err)
+	}
+}
+
+// gossipRound adds our own head to the gossip and exchanges
+// what we know with every remote that is online.
+func (b *base) gossipRound() {
+	cfg := b.repo.Config
+	if !cfg.Bool("net.gossip.enabled") || !b.backend.IsOnline() {
+		return
+	}
+
+	gossip := b.peerServer.Gossip()
+	maxTTL := int(cfg.Int("net.gossip.max_ttl"))
+
+	data, err := b.signOwnHead()
+	if err != nil {
+		log.Warningf("gossip: failed to sign own head: %v", err)
+	} else if err := gossip.AddOwn(b.repo.Owner, data, maxTTL); err != nil {
+		log.Warningf("gossip: failed to add own head: %v", err)
+	}
+
+	remotes, err := b.repo.Remotes.ListRemotes()
+	if err != nil {
+		log.Warningf("gossip: failed to list remotes: %v", err)
+		return
+	}
+
+	pmap := b.peerServer.PingMap()
+	for _, rmt := range remotes {
+		if rmt.IsBlocked() {
+			continue
+		}
+
+		// Do not wait for dial timeouts of remotes that are not there:
+		pinger, err := pmap.For(rmt.Fingerprint.Addr())
+		if err != nil || pinger.Err() != nil {
+			continue
+		}
+
+		err = b.withNetClient(rmt.Name, func(ctl *p2pnet.Client) error {
+			entries, err := ctl.Gossip(gossip.Entries(rmt.Name))
+			if err != nil {
+				return err
+			}
+
+			gossip.Offer(entries, rmt.Name, maxTTL)
+			return nil
+		})
+
+		if err != nil {
+			log.Debugf("gossip: exchange with %s failed: %v", rmt.Name, err)
+		}
+	}
+}
+
+// gossipLoop exchanges gossip after own commits, whenever a remote
+// comes online and regularly in between. It ends when the bus is closed.
+func (b *base) gossipLoop(sub *bus.Subscription) {
+	b.gossipRound()
+
+	for {
+		timer := time.NewTimer(b.repo.Config.Duration("net.gossip.interval"))
+
+		select {
+		case ev, ok := <-sub.Events():
+			timer.Stop()
+			if !ok {
+				return
+			}
+
+			if ev.Kind == bus.KindCommit && ev.Remote != "" {
+				// Commits of remotes do not change our head.
+				continue
+			}
+
+			b.gossipRound()
+		case <-timer.C:
+			b.gossipRound()
+		}
+	}
+}
+
+func (b *base) loadGossip() {
+	b.peerServer.Gossip().SetOnNews(b.handleGossipNews)
+
+	sub := b.evBus.Subscribe("", bus.KindCommit, bus.KindRemoteSeen)
+	go b.gossipLoop(sub)
+}
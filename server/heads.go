@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/events/bus"
+	p2pnet "github.com/sahib/brig/net"
+	netBackend "github.com/sahib/brig/net/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// RemoteHead is what a remote advertised as its latest commit,
+// together with the latest commit of it we know about.
+type RemoteHead struct {
+	p2pnet.HeadRecord
+
+	KnownHash  string
+	KnownIndex int64
+}
+
+// IsNew returns true if the remote has commits we did not fetch yet.
+func (rh *RemoteHead) IsNew() bool {
+	return rh.Index > rh.KnownIndex ||
+		(rh.Index == rh.KnownIndex && rh.Hash != rh.KnownHash)
+}
+
+func (b *base) headAdvertiser() (netBackend.HeadAdvertiser, error) {
+	adv, ok := b.backend.(netBackend.HeadAdvertiser)
+	if !ok {
+		return nil, e.Errorf("backend `%s` cannot advertise heads", b.repo.BackendName())
+	}
+
+	return adv, nil
+}
+
+// signOwnHead returns a signed head record of our current head.
+func (b *base) signOwnHead() ([]byte, error) {
+	rp := b.repo
+
+	var data []byte
+	err := b.withRemoteFs(rp.Owner, func(fs *catfs.FS) error {
+		head, err := fs.CommitInfo("head")
+		if err != nil {
+			return err
+		}
+
+		data, err = p2pnet.SignHeadRecord(p2pnet.HeadRecord{
+			Owner:     rp.Owner,
+			Index:     head.Index,
+			Hash:      head.Hash.B58String(),
+			Published: time.Now(),
+		}, rp.Keyring().SignWithIdentity)
+
+		return err
+	})
+
+	return data, err
+}
+
+// publishHead signs our current head and publishes it via the backend.
+func (b *base) publishHead(adv netBackend.HeadAdvertiser) error {
+	data, err := b.signOwnHead()
+	if err != nil {
+		return err
+	}
+
+	return adv.PublishHead(data)
+}
+
+// headAdvertisementLoop publishes our head after every own commit
+// and regularly in between, so the record does not expire.
+// It ends when the event bus is closed.
+func (b *base) headAdvertisementLoop(adv netBackend.HeadAdvertiser, sub *bus.Subscription) {
+	cfg := b.repo.Config
+	publish := func() {
+		if !cfg.Bool("net.head_advertisement.enabled") || !b.backend.IsOnline() {
+			return
+		}
+
+		if err := b.publishHead(adv); err != nil {
+			log.Warningf("failed to advertise head: %v", err)
+		}
+	}
+
+	publish()
+
+	for {
+		timer := time.NewTimer(cfg.Duration("net.head_advertisement.interval"))
+
+		select {
+		case ev, ok := <-sub.Events():
+			timer.Stop()
+			if !ok {
+				return
+			}
+
+			// Commits of remotes do not change our head:
+			if ev.Remote == "" {
+				publish()
+			}
+		case <-timer.C:
+			publish()
+		}
+	}
+}
+
+func (b *base) loadHeadAdvertisement() {
+	adv, err := b.headAdvertiser()
+	if err != nil {
+		log.Debugf("head advertisement is not available: %v", err)
+		return
+	}
+
+	// Publishing might take a long time (IPNS...), don't block the startup:
+	sub := b.evBus.Subscribe("", bus.KindCommit)
+	go b.headAdvertisementLoop(adv, sub)
+}
+
+// remoteHead fetches the head `name` advertised and checks that
+// it was signed by the key we stored for them.
+func (b *base) remoteHead(ctx context.Context, name string) (*RemoteHead, error) {
+	adv, err := b.headAdvertiser()
+	if err != nil {
+		return nil, err
+	}
+
+	rmt, err := b.repo.Remotes.Remote(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := b.repo.Keyring().PubKeyFor(name)
+	if err != nil {
+		return nil, e.Wrapf(err, "no public key for %s", name)
+	}
+
+	data, err := adv.ResolveHead(ctx, rmt.Fingerprint.Addr())
+	if err != nil {
+		return nil, e.Wrapf(err, "failed to resolve head of %s", name)
+	}
+
+	rec, err := p2pnet.VerifyHeadRecord(data, name, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rh := &RemoteHead{HeadRecord: *rec, KnownIndex: -1}
+	if !b.repo.HaveFS(name) {
+		// Never fetched anything from them.
+		return rh, nil
+	}
+
+	return rh, b.withRemoteFs(name, func(fs *catfs.FS) error {
+		known, err := fs.CommitInfo("head")
+		if err != nil {
+			return err
+		}
+
+		rh.KnownHash = known.Hash.B58String()
+		rh.KnownIndex = known.Index
+		return nil
+	})
+}
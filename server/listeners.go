@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/util/dialer"
+	"github.com/sahib/brig/util/server"
+	"github.com/sahib/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// listenControl opens the tcp port of the daemon and, if configured,
+// a unix socket and a TLS endpoint for remote administration.
+func listenControl(basePath, addr string) (net.Listener, error) {
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(basePath, "config.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := server.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	lsts := []net.Listener{lst}
+	closeAll := func() {
+		for _, lst := range lsts {
+			lst.Close()
+		}
+	}
+
+	if sockPath := cfg.String("daemon.unix_socket"); sockPath != "" {
+		if !filepath.IsAbs(sockPath) {
+			sockPath = filepath.Join(basePath, sockPath)
+		}
+
+		unixLst, err := listenUnix(sockPath)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		log.Infof("also listening on unix socket %s", sockPath)
+		lsts = append(lsts, unixLst)
+	}
+
+	if cfg.Bool("daemon.tls.enabled") {
+		tlsLst, err := listenTLS(cfg.Section("daemon.tls"))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		log.Infof("also listening for remote administration on %s", tlsLst.Addr())
+		lsts = append(lsts, tlsLst)
+	}
+
+	if len(lsts) == 1 {
+		return lst, nil
+	}
+
+	return server.NewMultiListener(lsts...), nil
+}
+
+func listenUnix(sockPath string) (net.Listener, error) {
+	// A socket left over by a crashed daemon would make listen fail.
+	// After a restart the socket is still in use by us though.
+	if info, err := os.Stat(sockPath); err == nil && info.Mode()&os.ModeSocket != 0 && !server.IsRestarted() {
+		if err := os.Remove(sockPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// server.Listen creates the socket accessible only by us:
+	return server.Listen("unix", sockPath)
+}
+
+func listenTLS(cfg *config.Config) (net.Listener, error) {
+	secret := cfg.String("secret")
+	clientCAPath := cfg.String("client_ca_file")
+	if secret == "" && clientCAPath == "" {
+		return nil, fmt.Errorf("refusing to listen with tls without secret or client_ca_file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.String("cert_file"), cfg.String("key_file"))
+	if err != nil {
+		return nil, e.Wrapf(err, "failed to load tls key pair")
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAPath != "" {
+		caData, err := ioutil.ReadFile(clientCAPath) // #nosec
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAPath)
+		}
+
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	addr := dialer.JoinHostPort(cfg.String("host"), int(cfg.Int("port")))
+	rawLst, err := server.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	lst := tls.NewListener(rawLst, tlsCfg)
+
+	if secret == "" {
+		return lst, nil
+	}
+
+	return &server.AuthListener{Listener: lst, Secret: secret}, nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"github.com/sahib/brig/events/bus"
+	p2pnet "github.com/sahib/brig/net"
+	log "github.com/sirupsen/logrus"
+)
+
+// announceMigration tells `name` about the renames of our owner it
+// did not hear about yet. Remotes that are offline are told later.
+func (b *base) announceMigration(name string) {
+	records, err := b.repo.PendingMigrations(name)
+	if err != nil {
+		log.Warningf("failed to read identity migrations: %v", err)
+		return
+	}
+
+	if len(records) == 0 {
+		return
+	}
+
+	err = b.withNetClient(name, func(ctl *p2pnet.Client) error {
+		return ctl.MigrateIdentity(records)
+	})
+
+	if err != nil {
+		log.Debugf("could not tell %s about our new name: %v", name, err)
+		return
+	}
+
+	log.Infof("told »%s« that we are called »%s« now", name, b.repo.Owner)
+	if err := b.repo.MarkMigrationsSent(name, len(records)); err != nil {
+		log.Warningf("failed to remember identity migration: %v", err)
+	}
+}
+
+// migrationLoop announces renames to every remote once
+// and again whenever a remote comes online.
+func (b *base) migrationLoop(sub *bus.Subscription) {
+	remotes, err := b.repo.Remotes.ListRemotes()
+	if err != nil {
+		log.Warningf("failed to list remotes: %v", err)
+	}
+
+	for _, rmt := range remotes {
+		if !rmt.IsBlocked() {
+			b.announceMigration(rmt.Name)
+		}
+	}
+
+	for ev := range sub.Events() {
+		rmt, err := b.repo.Remotes.Remote(ev.Remote)
+		if err != nil || rmt.IsBlocked() {
+			continue
+		}
+
+		b.announceMigration(rmt.Name)
+	}
+}
+
+func (b *base) loadIdentityMigration() {
+	sub := b.evBus.Subscribe("", bus.KindRemoteSeen)
+	go b.migrationLoop(sub)
+}
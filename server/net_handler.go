@@ -3,14 +3,17 @@ package server
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/net/discovery"
 	"github.com/sahib/brig/net/peer"
 	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server/capnp"
 	"github.com/sahib/brig/util/conductor"
+	"github.com/sahib/brig/util/errkind"
 	log "github.com/sirupsen/logrus"
 	capnplib "zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/server"
@@ -59,6 +62,153 @@ func (nh *netHandler) Whoami(call capnp.Net_whoami) error {
 	return call.Results.SetWhoami(capID)
 }
 
+func (nh *netHandler) FingerprintRecord(call capnp.Net_fingerprintRecord) error {
+	server.Ack(call.Options)
+
+	self, err := nh.base.peerServer.Identity()
+	if err != nil {
+		return err
+	}
+
+	rp := nh.base.repo
+	ownPubKey, err := rp.Keyring().OwnPubKey()
+	if err != nil {
+		return err
+	}
+
+	finger := peer.BuildFingerprint(self.Addr, ownPubKey)
+	payload := peer.RecordPayload(peer.Name(rp.Owner), finger, ownPubKey)
+	sig, err := rp.Keyring().SignWithIdentity([]byte(payload))
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetRecord(peer.FormatRecord(payload, sig))
+}
+
+func (nh *netHandler) RemoteDiscover(call capnp.Net_remoteDiscover) error {
+	server.Ack(call.Options)
+
+	timeout := time.Duration(call.Params.TimeoutMs()) * time.Millisecond
+	ctx, cancel := context.WithTimeout(nh.base.ctx, timeout)
+	defer cancel()
+
+	peers, err := discovery.Browse(ctx)
+	if err != nil {
+		return err
+	}
+
+	self, err := nh.base.peerServer.Identity()
+	if err != nil {
+		return err
+	}
+
+	// We answer our own query of course:
+	others := []discovery.Peer{}
+	for _, dpeer := range peers {
+		if dpeer.Addr != self.Addr {
+			others = append(others, dpeer)
+		}
+	}
+
+	rp := nh.base.repo
+	seg := call.Results.Segment()
+	capPeers, err := capnp.NewDiscoveredPeer_List(seg, int32(len(others)))
+	if err != nil {
+		return err
+	}
+
+	for idx, dpeer := range others {
+		capPeer, err := capnp.NewDiscoveredPeer(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capPeer.SetOwner(dpeer.Owner); err != nil {
+			return err
+		}
+
+		if err := capPeer.SetFingerprint(dpeer.Fingerprint); err != nil {
+			return err
+		}
+
+		if err := capPeer.SetAddr(dpeer.Addr); err != nil {
+			return err
+		}
+
+		ips := []string{}
+		for _, ip := range dpeer.IPs {
+			ips = append(ips, ip.String())
+		}
+
+		if err := capPeer.SetIp(strings.Join(ips, ", ")); err != nil {
+			return err
+		}
+
+		if err := capPeer.SetLastSeen(dpeer.LastSeen.Format(time.RFC3339)); err != nil {
+			return err
+		}
+
+		if rmt, err := rp.Remotes.RemoteByAddr(dpeer.Addr); err == nil {
+			if err := capPeer.SetRemoteName(rmt.Name); err != nil {
+				return err
+			}
+		}
+
+		if err := capPeers.Set(idx, capPeer); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetPeers(capPeers)
+}
+
+// remoteHeadTimeout is how long we wait for a name lookup.
+// IPNS is known to take quite some time.
+const remoteHeadTimeout = time.Minute
+
+func (nh *netHandler) RemoteHead(call capnp.Net_remoteHead) error {
+	server.Ack(call.Options)
+
+	who, err := call.Params.Who()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(nh.base.ctx, remoteHeadTimeout)
+	defer cancel()
+
+	head, err := nh.base.remoteHead(ctx, who)
+	if err != nil {
+		return err
+	}
+
+	capHead, err := capnp.NewRemoteHead(call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	if err := capHead.SetName(who); err != nil {
+		return err
+	}
+
+	if err := capHead.SetHash(head.Hash); err != nil {
+		return err
+	}
+
+	if err := capHead.SetPublished(head.Published.Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	if err := capHead.SetKnownHash(head.KnownHash); err != nil {
+		return err
+	}
+
+	capHead.SetIndex(head.Index)
+	capHead.SetKnownIndex(head.KnownIndex)
+	return call.Results.SetHead(capHead)
+}
+
 func (nh *netHandler) Connect(call capnp.Net_connect) error {
 	server.Ack(call.Options)
 	log.Infof("backend is going online...")
@@ -126,6 +276,10 @@ func (nh *netHandler) RemoteOnlineList(call capnp.Net_remoteOnlineList) error {
 			}
 		}
 
+		if err := queuedOpsToCapnp(nh.base.offlineQueue, remote.Name, seg, status); err != nil {
+			return err
+		}
+
 		if err := statuses.Set(idx, status); err != nil {
 			return err
 		}
@@ -163,8 +317,19 @@ func capRemoteToRemote(remote capnp.Remote) (*repo.Remote, error) {
 		return nil, err
 	}
 
-	// Check the fingerprint to be valid:
-	fingerprint, err := peer.CastFingerprint(capFingerprint)
+	trust, err := remote.Trust()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the fingerprint to be valid. Only remotes that we trust
+	// on first use may come without key; it is pinned on the first dial.
+	castFingerprint := peer.CastFingerprint
+	if trust == "" || trust == repo.TrustTOFU {
+		castFingerprint = peer.CastUnpinnedFingerprint
+	}
+
+	fingerprint, err := castFingerprint(capFingerprint)
 	if err != nil {
 		return nil, err
 	}
@@ -206,9 +371,46 @@ func capRemoteToRemote(remote capnp.Remote) (*repo.Remote, error) {
 		AcceptAutoUpdates: remote.AcceptAutoUpdates(),
 		AcceptPush:        remote.AcceptPush(),
 		ConflictStrategy:  conflictStrategy,
+		Trust:             trust,
 	}, nil
 }
 
+func queuedOpsToCapnp(queue *offlineQueue, remote string, seg *capnplib.Segment, status capnp.RemoteStatus) error {
+	ops := []p2pnet.QueuedOp{}
+	if queue != nil {
+		ops = queue.List(remote)
+	}
+
+	capOps, err := capnp.NewQueuedOp_List(seg, int32(len(ops)))
+	if err != nil {
+		return err
+	}
+
+	for idx, op := range ops {
+		capOp := capOps.At(idx)
+		if err := capOp.SetKind(op.Kind); err != nil {
+			return err
+		}
+
+		if err := capOp.SetAdded(op.Added.Format(time.RFC3339)); err != nil {
+			return err
+		}
+
+		capOp.SetAttempts(int32(op.Attempts))
+		if !op.NextTry.IsZero() {
+			if err := capOp.SetNextTry(op.NextTry.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+
+		if err := capOp.SetLastError(op.LastError); err != nil {
+			return err
+		}
+	}
+
+	return status.SetQueued(capOps)
+}
+
 func remoteToCapRemote(remote repo.Remote, seg *capnplib.Segment) (*capnp.Remote, error) {
 	capRemote, err := capnp.NewRemote(seg)
 	if err != nil {
@@ -227,6 +429,10 @@ func remoteToCapRemote(remote repo.Remote, seg *capnplib.Segment) (*capnp.Remote
 		return nil, err
 	}
 
+	if err := capRemote.SetTrust(remote.TrustLevel()); err != nil {
+		return nil, err
+	}
+
 	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(remote.Folders)))
 	if err != nil {
 		return nil, err
@@ -326,6 +532,12 @@ func (nh *netHandler) RemoteRm(call capnp.Net_remoteRm) error {
 		return err
 	}
 
+	if nh.base.syncLog != nil {
+		if err := nh.base.syncLog.Remove(name); err != nil {
+			log.Warningf("failed to forget sync stats of %s: %v", name, err)
+		}
+	}
+
 	return nh.base.syncRemoteStates()
 }
 
@@ -573,6 +785,11 @@ func (nh *netHandler) Push(call capnp.Net_push) error {
 		return err
 	}
 
+	if !call.Params.DryRun() {
+		err := nh.base.doPush(remoteName)
+		return nh.base.queueIfOffline(remoteName, p2pnet.QueueOpPush, "", err)
+	}
+
 	return nh.base.withNetClient(remoteName, func(ctl *p2pnet.Client) error {
 		pushAllowed, err := ctl.IsPushAllowed()
 		if err != nil {
@@ -580,13 +797,461 @@ func (nh *netHandler) Push(call capnp.Net_push) error {
 		}
 
 		if !pushAllowed {
-			return fmt.Errorf("cannot push: remote does not allow it")
+			return errkind.Wrap(errkind.Unauthorized, fmt.Errorf("cannot push: remote does not allow it"))
+		}
+
+		return nil
+	})
+}
+
+func (nh *netHandler) RemoteConfigSet(call capnp.Net_remoteConfigSet) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	key, err := call.Params.Key()
+	if err != nil {
+		return err
+	}
+
+	value, err := call.Params.Value()
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("config: set `%s` to `%s` for remote %s", key, value, name)
+	return nh.base.repo.SetRemoteConfig(name, key, value)
+}
+
+func (nh *netHandler) RemoteConfigLs(call capnp.Net_remoteConfigLs) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	rp := nh.base.repo
+	rmt, err := rp.Remotes.Remote(name)
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capEntries, err := capnp.NewRemoteConfigEntry_List(seg, int32(len(repo.RemoteConfigKeys)))
+	if err != nil {
+		return err
+	}
+
+	for idx, key := range repo.RemoteConfigKeys {
+		capEntry, err := capnp.NewRemoteConfigEntry(seg)
+		if err != nil {
+			return err
+		}
+
+		global := rp.Config.Uncast(key)
+		value, overridden := rmt.Config[key]
+		if !overridden {
+			value = global
+		}
+
+		if err := capEntry.SetKey(key); err != nil {
+			return err
+		}
+
+		if err := capEntry.SetValue(value); err != nil {
+			return err
+		}
+
+		if err := capEntry.SetGlobal(global); err != nil {
+			return err
+		}
+
+		capEntry.SetOverridden(overridden)
+		if err := capEntries.Set(idx, capEntry); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetEntries(capEntries)
+}
+
+func (nh *netHandler) RemoteBrowse(call capnp.Net_remoteBrowse) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	root, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	return nh.base.withNetClient(name, func(ctl *p2pnet.Client) error {
+		entries, err := ctl.Browse(root)
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		capEntries, err := capnp.NewRemoteBrowseEntry_List(seg, int32(len(entries)))
+		if err != nil {
+			return err
 		}
 
-		if call.Params.DryRun() {
-			return nil
+		for idx, entry := range entries {
+			capEntry, err := capnp.NewRemoteBrowseEntry(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capEntry.SetPath(entry.Path); err != nil {
+				return err
+			}
+
+			if err := capEntry.SetModTime(entry.ModTime.Format(time.RFC3339)); err != nil {
+				return err
+			}
+
+			if err := capEntry.SetContentHash(entry.ContentHash); err != nil {
+				return err
+			}
+
+			capEntry.SetSize(entry.Size)
+			capEntry.SetIsDir(entry.IsDir)
+			capEntry.SetVersions(int32(entry.Versions))
+			capEntry.SetPartial(entry.Partial)
+			if err := capEntries.Set(idx, capEntry); err != nil {
+				return err
+			}
 		}
 
-		return ctl.Push()
+		return call.Results.SetEntries(capEntries)
 	})
 }
+
+func newTextList(seg *capnplib.Segment, strs []string) (capnplib.TextList, error) {
+	capList, err := capnplib.NewTextList(seg, int32(len(strs)))
+	if err != nil {
+		return capList, err
+	}
+
+	for idx, str := range strs {
+		if err := capList.Set(idx, str); err != nil {
+			return capList, err
+		}
+	}
+
+	return capList, nil
+}
+
+func (nh *netHandler) ReplStatus(call capnp.Net_replStatus) error {
+	server.Ack(call.Options)
+
+	report, err := nh.base.replicationRound(false)
+	if err != nil {
+		return err
+	}
+
+	files := []*replFile{}
+	for _, file := range report.Files {
+		if call.Params.All() || len(file.Holders) < file.Target.Copies {
+			files = append(files, file)
+		}
+	}
+
+	seg := call.Results.Segment()
+	capStatus, err := capnp.NewReplStatus(seg)
+	if err != nil {
+		return err
+	}
+
+	capFiles, err := capnp.NewReplFile_List(seg, int32(len(files)))
+	if err != nil {
+		return err
+	}
+
+	for idx, file := range files {
+		capFile, err := capnp.NewReplFile(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capFile.SetPath(file.Path); err != nil {
+			return err
+		}
+
+		if err := capFile.SetTarget(file.Target.String()); err != nil {
+			return err
+		}
+
+		capHolders, err := newTextList(seg, file.Holders)
+		if err != nil {
+			return err
+		}
+
+		if err := capFile.SetHolders(capHolders); err != nil {
+			return err
+		}
+
+		capFile.SetWant(int32(file.Target.Copies))
+		if err := capFiles.Set(idx, capFile); err != nil {
+			return err
+		}
+	}
+
+	if err := capStatus.SetFiles(capFiles); err != nil {
+		return err
+	}
+
+	capUnreachable, err := newTextList(seg, report.Unreachable)
+	if err != nil {
+		return err
+	}
+
+	if err := capStatus.SetUnreachable(capUnreachable); err != nil {
+		return err
+	}
+
+	capStatus.SetTotal(int64(len(report.Files)))
+	return call.Results.SetStatus(capStatus)
+}
+
+func (nh *netHandler) SyncStats(call capnp.Net_syncStats) error {
+	server.Ack(call.Options)
+
+	remote, err := call.Params.Remote()
+	if err != nil {
+		return err
+	}
+
+	sinceStr, err := call.Params.Since()
+	if err != nil {
+		return err
+	}
+
+	since := time.Time{}
+	if sinceStr != "" {
+		if since, err = time.Parse(time.RFC3339, sinceStr); err != nil {
+			return err
+		}
+	}
+
+	sums := []p2pnet.SyncSummary{}
+	if nh.base.syncLog != nil {
+		sums = nh.base.syncLog.Summary(remote, since)
+	}
+
+	seg := call.Results.Segment()
+	capSums, err := capnp.NewSyncSummary_List(seg, int32(len(sums)))
+	if err != nil {
+		return err
+	}
+
+	for idx, sum := range sums {
+		capSum := capSums.At(idx)
+		if err := capSum.SetRemote(sum.Remote); err != nil {
+			return err
+		}
+
+		if err := capSum.SetLastSync(sum.LastSync.Format(time.RFC3339)); err != nil {
+			return err
+		}
+
+		capSum.SetSyncs(int64(sum.Syncs))
+		capSum.SetFailed(int64(sum.Failed))
+		capSum.SetFiles(int64(sum.Files))
+		capSum.SetConflicts(int64(sum.Conflicts))
+		capSum.SetBytes(sum.Bytes)
+		capSum.SetDurationMs(sum.Duration.Milliseconds())
+	}
+
+	return call.Results.SetStats(capSums)
+}
+
+// delegationHolder returns the key id of `holder`, which is
+// either the name of a remote or a fingerprint.
+func (nh *netHandler) delegationHolder(holder string) (string, error) {
+	if rmt, err := nh.base.repo.Remotes.Remote(holder); err == nil {
+		if !rmt.Fingerprint.IsPinned() {
+			return "", fmt.Errorf("the key of »%s« is not known yet", holder)
+		}
+
+		return rmt.Fingerprint.PubKeyID(), nil
+	}
+
+	keyID := peer.Fingerprint(holder).PubKeyID()
+	if keyID == "" {
+		return "", fmt.Errorf("»%s« is neither a remote nor a fingerprint", holder)
+	}
+
+	return keyID, nil
+}
+
+func delegationToCap(seg *capnplib.Segment, dg *repo.Delegation) (*capnp.Delegation, error) {
+	capDg, err := capnp.NewDelegation(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capDg.SetIssuer(dg.Issuer); err != nil {
+		return nil, err
+	}
+
+	if err := capDg.SetHolder(dg.Holder); err != nil {
+		return nil, err
+	}
+
+	if err := capDg.SetFolder(dg.Folder); err != nil {
+		return nil, err
+	}
+
+	if err := capDg.SetIssued(dg.Issued.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	if err := capDg.SetExpires(dg.Expires.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	return &capDg, nil
+}
+
+func (nh *netHandler) DelegationIssue(call capnp.Net_delegationIssue) error {
+	server.Ack(call.Options)
+
+	holder, err := call.Params.Holder()
+	if err != nil {
+		return err
+	}
+
+	folder, err := call.Params.Folder()
+	if err != nil {
+		return err
+	}
+
+	rawLifetime, err := call.Params.Lifetime()
+	if err != nil {
+		return err
+	}
+
+	lifetime, err := time.ParseDuration(rawLifetime)
+	if err != nil {
+		return err
+	}
+
+	if lifetime <= 0 {
+		return fmt.Errorf("lifetime must be positive")
+	}
+
+	keyID, err := nh.delegationHolder(holder)
+	if err != nil {
+		return err
+	}
+
+	rp := nh.base.repo
+	now := time.Now()
+	cert, err := repo.SignDelegation(repo.Delegation{
+		Issuer:  rp.Owner,
+		Holder:  keyID,
+		Folder:  folder,
+		Issued:  now,
+		Expires: now.Add(lifetime),
+	}, rp.Keyring().SignWithIdentity)
+
+	if err != nil {
+		return err
+	}
+
+	log.Infof("delegated %s to %s for %s", folder, holder, lifetime)
+	return call.Results.SetCert(cert)
+}
+
+func (nh *netHandler) DelegationAdd(call capnp.Net_delegationAdd) error {
+	server.Ack(call.Options)
+
+	cert, err := call.Params.Cert()
+	if err != nil {
+		return err
+	}
+
+	rp := nh.base.repo
+	dg, err := repo.ParseDelegation(cert)
+	if err != nil {
+		return err
+	}
+
+	ownPubKey, err := rp.Keyring().OwnPubKey()
+	if err != nil {
+		return err
+	}
+
+	if dg.Holder != peer.BuildFingerprint("", ownPubKey).PubKeyID() {
+		return fmt.Errorf("the delegation was issued for somebody else")
+	}
+
+	if dg, err = rp.AddDelegation(cert); err != nil {
+		return err
+	}
+
+	capDg, err := delegationToCap(call.Results.Segment(), dg)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetDelegation(*capDg)
+}
+
+func (nh *netHandler) DelegationList(call capnp.Net_delegationList) error {
+	server.Ack(call.Options)
+
+	certs, err := nh.base.repo.Delegations()
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capDgs, err := capnp.NewDelegation_List(seg, int32(len(certs)))
+	if err != nil {
+		return err
+	}
+
+	for idx, cert := range certs {
+		dg, err := repo.ParseDelegation(cert)
+		if err != nil {
+			return err
+		}
+
+		capDg, err := delegationToCap(seg, dg)
+		if err != nil {
+			return err
+		}
+
+		if err := capDgs.Set(idx, *capDg); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetDelegations(capDgs)
+}
+
+func (nh *netHandler) DelegationRemove(call capnp.Net_delegationRemove) error {
+	server.Ack(call.Options)
+
+	issuer, err := call.Params.Issuer()
+	if err != nil {
+		return err
+	}
+
+	folder, err := call.Params.Folder()
+	if err != nil {
+		return err
+	}
+
+	return nh.base.repo.RemoveDelegation(issuer, folder)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/events/bus"
+	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/util/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+// pairingQuietTime is how long we stay quiet after notifying about
+// a peer that tried to connect. Peers retry a lot.
+const pairingQuietTime = 10 * time.Minute
+
+// notificationDispatcher turns events on the bus into notifications.
+type notificationDispatcher struct {
+	b        *base
+	notifier notify.Notifier
+
+	mu          sync.Mutex
+	lastPairing map[string]time.Time
+}
+
+func (nd *notificationDispatcher) notificationFor(ev bus.Event) *notify.Notification {
+	cfg := nd.b.repo.Config
+	if !cfg.Bool("daemon.notifications.enabled") {
+		return nil
+	}
+
+	switch ev.Kind {
+	case bus.KindSync:
+		if !cfg.Bool("daemon.notifications.on_sync") {
+			return nil
+		}
+
+		return &notify.Notification{
+			Title: fmt.Sprintf("Synced with %s", ev.Remote),
+			Body:  ev.Message,
+		}
+	case bus.KindConflict:
+		if !cfg.Bool("daemon.notifications.on_conflict") {
+			return nil
+		}
+
+		return &notify.Notification{
+			Title: fmt.Sprintf("Conflict while syncing with %s", ev.Remote),
+			Body:  fmt.Sprintf("Both sides changed %s", ev.Path),
+		}
+	case bus.KindPairing:
+		if !cfg.Bool("daemon.notifications.on_pairing") {
+			return nil
+		}
+
+		nd.mu.Lock()
+		defer nd.mu.Unlock()
+
+		if last, ok := nd.lastPairing[ev.Message]; ok && time.Since(last) < pairingQuietTime {
+			return nil
+		}
+
+		nd.lastPairing[ev.Message] = time.Now()
+		return &notify.Notification{
+			Title: fmt.Sprintf("%s wants to connect", ev.Remote),
+			Body: fmt.Sprintf(
+				"Their key is %s. Use `brig remote add` if you know them.",
+				ev.Message,
+			),
+		}
+	}
+
+	return nil
+}
+
+func (nd *notificationDispatcher) run(sub *bus.Subscription) {
+	for ev := range sub.Events() {
+		nt := nd.notificationFor(ev)
+		if nt == nil {
+			continue
+		}
+
+		if err := nd.notifier.Notify(*nt); err != nil {
+			// Happens regularly on machines without desktop.
+			log.Debugf("failed to show notification: %v", err)
+		}
+	}
+}
+
+func (b *base) loadNotifications() {
+	cfg := b.repo.Config
+	name := cfg.String("daemon.notifications.notifier")
+	if name == "auto" {
+		name = notify.DefaultNotifier
+	}
+
+	notifier, err := notify.New(name, cfg.String("daemon.notifications.command"))
+	if err != nil {
+		log.Infof("desktop notifications are not available: %v", err)
+		return
+	}
+
+	nd := &notificationDispatcher{
+		b:           b,
+		notifier:    notifier,
+		lastPairing: make(map[string]time.Time),
+	}
+
+	// Only things that happen from now on are interesting:
+	sub := b.evBus.Subscribe("", bus.KindSync, bus.KindConflict, bus.KindPairing)
+	go nd.run(sub)
+}
+
+// publishUnknownPeer is called when a peer with an unknown key tried to connect.
+func (b *base) publishUnknownPeer(name string, fingerprint peer.Fingerprint) {
+	b.evBus.Publish(bus.Event{
+		Kind:    bus.KindPairing,
+		Remote:  name,
+		Message: fingerprint.PubKeyID(),
+	})
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	e "github.com/pkg/errors"
+	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+// offlineQueueInterval is how often due operations are retried,
+// even if the ping map did not notice the remote coming back.
+const offlineQueueInterval = 30 * time.Second
+
+// offlineError is returned by withNetClient if the remote could not be dialed.
+type offlineError struct {
+	remote string
+	err    error
+}
+
+func (oe *offlineError) Error() string {
+	return "dial: " + oe.err.Error()
+}
+
+func isOfflineError(err error) bool {
+	_, ok := e.Cause(err).(*offlineError)
+	return ok
+}
+
+// offlineQueue wraps the persistent queue and makes sure
+// that only one runner per remote is active.
+type offlineQueue struct {
+	*p2pnet.Queue
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func (b *base) loadOfflineQueue() error {
+	path := filepath.Join(b.repo.BaseFolder, "offline-queue.json")
+	queue, err := p2pnet.NewQueue(path, b.repo.Config.Duration("net.offline_queue.max_backoff"))
+	if err != nil {
+		return e.Wrapf(err, "offline queue")
+	}
+
+	b.offlineQueue = &offlineQueue{Queue: queue, running: make(map[string]bool)}
+	go b.offlineQueueLoop()
+	return nil
+}
+
+func (b *base) offlineQueueLoop() {
+	ticker := time.NewTicker(offlineQueueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, remote := range b.offlineQueue.Remotes() {
+				b.runQueued(remote, false)
+			}
+		}
+	}
+}
+
+// queueIfOffline queues an operation of `kind` for `who` if `err` says that
+// the remote could not be reached. The error is returned either way,
+// but tells the user about the queued operation.
+func (b *base) queueIfOffline(who, kind, msg string, err error) error {
+	if err == nil || !isOfflineError(err) || b.offlineQueue == nil {
+		return err
+	}
+
+	if !b.repo.Config.Bool("net.offline_queue.enabled") {
+		return err
+	}
+
+	if _, qerr := b.offlineQueue.Add(who, kind, msg); qerr != nil {
+		log.Warningf("failed to queue %s for %s: %v", kind, who, qerr)
+		return err
+	}
+
+	log.Infof("%s is offline; queued %s", who, kind)
+	return fmt.Errorf("%v (%s is offline; the %s was queued and will be done once it is back)", err, who, kind)
+}
+
+func (b *base) doQueuedOp(op p2pnet.QueuedOp) error {
+	switch op.Kind {
+	case p2pnet.QueueOpSync:
+		_, err := b.doSync(op.Remote, true, op.Message)
+		return err
+	case p2pnet.QueueOpFetch:
+		return b.doFetch(op.Remote)
+	case p2pnet.QueueOpPush:
+		return b.doPush(op.Remote)
+	default:
+		return fmt.Errorf("unknown queued operation: %s", op.Kind)
+	}
+}
+
+// runQueued tries all due operations of `remote`. If `force` is true,
+// the backoff is ignored, since the remote was just seen again.
+func (b *base) runQueued(remote string, force bool) {
+	if b.offlineQueue == nil {
+		return
+	}
+
+	q := b.offlineQueue
+	q.mu.Lock()
+	if q.running[remote] {
+		q.mu.Unlock()
+		return
+	}
+
+	q.running[remote] = true
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, remote)
+		q.mu.Unlock()
+	}()
+
+	for _, op := range q.Due(remote, time.Now(), force) {
+		if _, err := b.repo.Remotes.Remote(op.Remote); err == repo.ErrNoSuchRemote {
+			log.Infof("dropping queued %s for removed remote %s", op.Kind, op.Remote)
+			if err := q.Done(op.ID); err != nil {
+				log.Warningf("failed to update offline queue: %v", err)
+			}
+
+			continue
+		}
+
+		if err := b.doQueuedOp(op); err != nil {
+			log.Warningf("queued %s with %s failed: %v", op.Kind, op.Remote, err)
+			if err := q.Failed(op.ID, err); err != nil {
+				log.Warningf("failed to update offline queue: %v", err)
+			}
+
+			// No need to try the rest if it is still not reachable:
+			if isOfflineError(err) {
+				return
+			}
+
+			continue
+		}
+
+		log.Infof("queued %s with %s done", op.Kind, op.Remote)
+		if err := q.Done(op.ID); err != nil {
+			log.Warningf("failed to update offline queue: %v", err)
+		}
+	}
+}
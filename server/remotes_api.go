@@ -60,6 +60,7 @@ func (a *RemotesAPI) get(name string) (*remotesapi.Remote, error) {
 	extRmt.AcceptAutoUpdates = rmt.AcceptAutoUpdates
 	extRmt.AcceptPush = rmt.AcceptPush
 	extRmt.ConflictStrategy = rmt.ConflictStrategy
+	extRmt.Trust = rmt.TrustLevel()
 
 	for _, folder := range rmt.Folders {
 		extRmt.Folders = append(extRmt.Folders, remotesapi.Folder{
@@ -98,6 +99,12 @@ func (a *RemotesAPI) Set(rm remotesapi.Remote) error {
 		})
 	}
 
+	// Changing a remote via the gateway does not change its trust, unless asked:
+	trust := rm.Trust
+	if prev, err := a.base.repo.Remotes.Remote(rm.Name); err == nil && trust == "" {
+		trust = prev.Trust
+	}
+
 	err = a.base.repo.Remotes.AddOrUpdateRemote(repo.Remote{
 		Name:              rm.Name,
 		Fingerprint:       fp,
@@ -105,6 +112,7 @@ func (a *RemotesAPI) Set(rm remotesapi.Remote) error {
 		AcceptAutoUpdates: rm.AcceptAutoUpdates,
 		AcceptPush:        rm.AcceptPush,
 		ConflictStrategy:  rm.ConflictStrategy,
+		Trust:             trust,
 	})
 
 	if err != nil {
@@ -149,6 +157,14 @@ func (a *RemotesAPI) Sync(name string) error {
 	return err
 }
 
+// TrySync is like Sync, but returns remotesapi.ErrSyncInProgress
+// instead of waiting for a running sync with `name`.
+func (a *RemotesAPI) TrySync(name string) error {
+	msg := fmt.Sprintf("sync with »%s« from gateway", name)
+	_, err := a.base.tryDoSync(name, true, msg)
+	return err
+}
+
 // MakeDiff produces a diff to the remote with `name`.
 func (a *RemotesAPI) MakeDiff(name string) (*catfs.Diff, error) {
 	if err := a.base.doFetch(name); err != nil {
@@ -169,6 +185,11 @@ func (a *RemotesAPI) MakeDiff(name string) (*catfs.Diff, error) {
 	})
 }
 
+// SyncPreview tells what syncing with `name` would change, without syncing.
+func (a *RemotesAPI) SyncPreview(name string) (*catfs.Diff, error) {
+	return a.base.doSyncPreview(name, true)
+}
+
 // OnChange register a callback to be called once the remote list changes.
 func (a *RemotesAPI) OnChange(fn func()) {
 	a.base.repo.Remotes.OnChange(fn)
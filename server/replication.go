@@ -0,0 +1,248 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/events/bus"
+	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/net/replication"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// replFile is a single file of ours that falls under a replication target.
+type replFile struct {
+	Path    string
+	Target  replication.Target
+	Hash    h.Hash
+	Holders []string
+}
+
+// replReport is the outcome of a replication round.
+type replReport struct {
+	Files       []*replFile
+	Unreachable []string
+}
+
+// replFiles lists all files of ours that fall under one of `targets`.
+func (b *base) replFiles(targets []replication.Target) ([]*replFile, error) {
+	files := []*replFile{}
+	err := b.withCurrFs(func(fs *catfs.FS) error {
+		infos, err := fs.List("/", -1)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			if info.IsDir {
+				continue
+			}
+
+			tgt := replication.TargetFor(targets, info.Path)
+			if tgt == nil {
+				continue
+			}
+
+			files = append(files, &replFile{
+				Path:   info.Path,
+				Target: *tgt,
+				Hash:   info.BackendHash,
+			})
+		}
+
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, err
+}
+
+// askMember sends `files` to `member` in batches and records
+// which of them it has pinned afterwards.
+func (b *base) askMember(member string, files []*replFile, pin bool) error {
+	return b.withNetClient(member, func(ctl *p2pnet.Client) error {
+		for len(files) > 0 {
+			batch := files
+			if len(batch) > p2pnet.MaxReplicateHashes {
+				batch = batch[:p2pnet.MaxReplicateHashes]
+			}
+
+			hashes := []h.Hash{}
+			for _, file := range batch {
+				hashes = append(hashes, file.Hash)
+			}
+
+			pinned, err := ctl.Replicate(hashes, pin)
+			if err != nil {
+				return err
+			}
+
+			for idx, isPinned := range pinned {
+				if isPinned {
+					batch[idx].Holders = append(batch[idx].Holders, member)
+				}
+			}
+
+			files = files[len(batch):]
+		}
+
+		return nil
+	})
+}
+
+// isHolder checks if `member` already stores `file`.
+func (file *replFile) isHolder(member string) bool {
+	for _, holder := range file.Holders {
+		if holder == member {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replicationRound asks all members of the configured groups which of our
+// files they store. If `pin` is true, members that do not store a file yet
+// are asked to do so until its target is met. Members that can not be
+// reached are listed in the report and count as not storing anything.
+func (b *base) replicationRound(pin bool) (*replReport, error) {
+	cfg := b.repo.Config
+	groups, err := replication.ParseGroups(cfg.Strings("net.replication.groups"))
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := replication.ParseTargets(cfg.Strings("net.replication.targets"))
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := b.replFiles(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &replReport{Files: files}
+	reachable := map[string]bool{}
+
+	// Members that are in several groups are only asked once:
+	members := []string{}
+	seen := map[string]bool{}
+	for _, tgt := range targets {
+		grp := replication.FindGroup(groups, tgt.Group)
+		if grp == nil {
+			continue
+		}
+
+		for _, member := range grp.Members {
+			if !seen[member] {
+				seen[member] = true
+				members = append(members, member)
+			}
+		}
+	}
+
+	for _, member := range members {
+		// Only copies in the group of a file's target count:
+		memberFiles := []*replFile{}
+		for _, file := range files {
+			grp := replication.FindGroup(groups, file.Target.Group)
+			if grp != nil && grp.Has(member) {
+				memberFiles = append(memberFiles, file)
+			}
+		}
+
+		if err := b.askMember(member, memberFiles, false); err != nil {
+			log.Debugf("repl: failed to ask %s: %v", member, err)
+			report.Unreachable = append(report.Unreachable, member)
+			continue
+		}
+
+		reachable[member] = true
+	}
+
+	if !pin {
+		return report, nil
+	}
+
+	requests := map[string][]*replFile{}
+	for _, file := range files {
+		grp := replication.FindGroup(groups, file.Target.Group)
+		if grp == nil {
+			continue
+		}
+
+		candidates := []string{}
+		for _, member := range grp.Members {
+			if reachable[member] && !file.isHolder(member) {
+				candidates = append(candidates, member)
+			}
+		}
+
+		for _, member := range replication.Pick(candidates, len(file.Holders), file.Target.Copies) {
+			requests[member] = append(requests[member], file)
+		}
+	}
+
+	for member, memberFiles := range requests {
+		log.Infof("repl: asking %s to pin %d files", member, len(memberFiles))
+
+		// Only the files it did not have before are sent,
+		// so the holders are not counted twice.
+		if err := b.askMember(member, memberFiles, true); err != nil {
+			log.Warningf("repl: failed to ask %s to pin: %v", member, err)
+		}
+	}
+
+	return report, nil
+}
+
+// isReplMember checks if `name` is in one of our replication groups.
+func (b *base) isReplMember(name string) bool {
+	groups, err := replication.ParseGroups(b.repo.Config.Strings("net.replication.groups"))
+	if err != nil {
+		return false
+	}
+
+	return replication.IsMember(groups, name)
+}
+
+// replicationLoop asks members of our groups to pin under-replicated
+// files regularly and whenever one of them comes online.
+// It ends when the bus is closed.
+func (b *base) replicationLoop(sub *bus.Subscription) {
+	for {
+		timer := time.NewTimer(b.repo.Config.Duration("net.replication.interval"))
+
+		select {
+		case ev, ok := <-sub.Events():
+			timer.Stop()
+			if !ok {
+				return
+			}
+
+			if !b.isReplMember(ev.Remote) {
+				continue
+			}
+		case <-timer.C:
+		}
+
+		if len(b.repo.Config.Strings("net.replication.targets")) == 0 || !b.backend.IsOnline() {
+			continue
+		}
+
+		if _, err := b.replicationRound(true); err != nil {
+			log.Warningf("repl: round failed: %v", err)
+		}
+	}
+}
+
+func (b *base) loadReplication() {
+	sub := b.evBus.Subscribe("", bus.KindRemoteSeen)
+	go b.replicationLoop(sub)
+}
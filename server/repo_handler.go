@@ -1,14 +1,24 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
+	e "github.com/pkg/errors"
 	"github.com/sahib/brig/backend"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/events/bus"
 	"github.com/sahib/brig/fuse"
 	gwdb "github.com/sahib/brig/gateway/db"
 	gwcapnp "github.com/sahib/brig/gateway/db/capnp"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server/capnp"
+	"github.com/sahib/brig/util/profile"
 	"github.com/sahib/brig/version"
 	log "github.com/sirupsen/logrus"
 	capnplib "zombiezen.com/go/capnproto2"
@@ -240,14 +250,15 @@ func (rh *repoHandler) ConfigSet(call capnp.Repo_configSet) error {
 		return err
 	}
 
+	typeName := defaults.TypeName(rp.Config.Get(key))
 	val, err := rp.Config.Cast(key, rawVal)
 	if err != nil {
-		return err
+		return e.Wrapf(err, "»%s« is not a valid %s for %s", rawVal, typeName, key)
 	}
 
 	log.Debugf("config: set `%s` to `%v`", key, val)
 	if err := rp.Config.Set(key, val); err != nil {
-		return err
+		return e.Wrapf(err, "invalid value for %s", key)
 	}
 
 	return rp.SaveConfig()
@@ -279,6 +290,10 @@ func (rh *repoHandler) configDefaultEntryToCapnp(seg *capnplib.Segment, key stri
 		return nil, err
 	}
 
+	if err := pair.SetType(defaults.TypeName(rp.Config.Get(key))); err != nil {
+		return nil, err
+	}
+
 	pair.SetNeedsRestart(entry.NeedsRestart)
 	return &pair, nil
 }
@@ -409,8 +424,114 @@ func (rh *repoHandler) GatewayUserAdd(call capnp.Repo_gatewayUserAdd) error {
 		rights = append(rights, right)
 	}
 
+	groups, err := textList(call.Params.Groups())
+	if err != nil {
+		return err
+	}
+
 	gwDb := rh.base.gateway.UserDatabase()
-	return gwDb.Add(name, password, folders, rights)
+	if err := gwDb.Add(name, password, folders, rights); err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	return gwDb.SetUserGroups(name, groups)
+}
+
+// textList converts a capnp text list to a string slice.
+func textList(list capnplib.TextList, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for idx := 0; idx < list.Len(); idx++ {
+		item, err := list.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func (rh *repoHandler) GatewayGroupAdd(call capnp.Repo_gatewayGroupAdd) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	rights, err := textList(call.Params.Rights())
+	if err != nil {
+		return err
+	}
+
+	return rh.base.gateway.UserDatabase().AddGroup(name, rights)
+}
+
+func (rh *repoHandler) GatewayGroupRm(call capnp.Repo_gatewayGroupRm) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	return rh.base.gateway.UserDatabase().RemoveGroup(name)
+}
+
+func (rh *repoHandler) GatewayGroupList(call capnp.Repo_gatewayGroupList) error {
+	server.Ack(call.Options)
+
+	groups, err := rh.base.gateway.UserDatabase().Groups()
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capGroups, err := capnp.NewGatewayGroup_List(seg, int32(len(groups)))
+	if err != nil {
+		return err
+	}
+
+	for idx, group := range groups {
+		capGroup, err := capnp.NewGatewayGroup(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capGroup.SetName(group.Name); err != nil {
+			return err
+		}
+
+		capRights, err := capnplib.NewTextList(seg, int32(len(group.Rights)))
+		if err != nil {
+			return err
+		}
+
+		for rightIdx, right := range group.Rights {
+			if err := capRights.Set(rightIdx, right); err != nil {
+				return err
+			}
+		}
+
+		if err := capGroup.SetRights(capRights); err != nil {
+			return err
+		}
+
+		if err := capGroups.Set(idx, capGroup); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetGroups(capGroups)
 }
 
 func (rh *repoHandler) GatewayUserRm(call capnp.Repo_gatewayUserRm) error {
@@ -429,7 +550,12 @@ func (rh *repoHandler) GatewayUserList(call capnp.Repo_gatewayUserList) error {
 	server.Ack(call.Options)
 
 	gwDb := rh.base.gateway.UserDatabase()
-	users, err := gwDb.List()
+	listFn := gwDb.List
+	if call.Params.OutdatedOnly() {
+		listFn = gwDb.Outdated
+	}
+
+	users, err := listFn()
 	if err != nil {
 		return err
 	}
@@ -459,3 +585,620 @@ func (rh *repoHandler) DebugProfilePort(call capnp.Repo_debugProfilePort) error
 	call.Results.SetPort(int32(rh.base.pprofPort))
 	return nil
 }
+
+func (rh *repoHandler) DebugProfile(call capnp.Repo_debugProfile) error {
+	server.Ack(call.Options)
+
+	if !rh.base.repo.Config.Bool("daemon.enable_profiling") {
+		return fmt.Errorf("profiling is disabled; enable daemon.enable_profiling first")
+	}
+
+	kind, err := call.Params.Kind()
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(call.Params.Seconds()) * time.Second
+	log.Infof("capturing %s profile (%s)", kind, duration)
+
+	data, err := profile.Capture(rh.base.ctx, kind, duration)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetData(data)
+}
+
+func subkeyToCap(sk *repo.Subkey, isCurrent bool, seg *capnplib.Segment) (*capnp.Subkey, error) {
+	capSubkey, err := capnp.NewSubkey(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capSubkey.SetId(sk.ID); err != nil {
+		return nil, err
+	}
+
+	if err := capSubkey.SetUsage(string(sk.Usage)); err != nil {
+		return nil, err
+	}
+
+	if err := capSubkey.SetCreated(sk.Created.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	if err := capSubkey.SetExpires(sk.Expires.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	capSubkey.SetCurrent(isCurrent)
+	return &capSubkey, nil
+}
+
+func (rh *repoHandler) SubkeyList(call capnp.Repo_subkeyList) error {
+	server.Ack(call.Options)
+
+	keyring := rh.base.repo.Keyring()
+	subkeys, err := keyring.Subkeys()
+	if err != nil {
+		return err
+	}
+
+	currentIDs := make(map[string]bool)
+	for _, usage := range []repo.SubkeyUsage{repo.SubkeySession, repo.SubkeySigning} {
+		sk, err := keyring.CurrentSubkey(usage)
+		if err == repo.ErrNoSubkey {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		currentIDs[sk.ID] = true
+	}
+
+	seg := call.Results.Segment()
+	capSubkeys, err := capnp.NewSubkey_List(seg, int32(len(subkeys)))
+	if err != nil {
+		return err
+	}
+
+	for idx, sk := range subkeys {
+		capSubkey, err := subkeyToCap(sk, currentIDs[sk.ID], seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capSubkeys.Set(idx, *capSubkey); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetSubkeys(capSubkeys)
+}
+
+func (rh *repoHandler) SubkeyRotate(call capnp.Repo_subkeyRotate) error {
+	server.Ack(call.Options)
+
+	rawUsage, err := call.Params.Usage()
+	if err != nil {
+		return err
+	}
+
+	usage, err := repo.ParseSubkeyUsage(rawUsage)
+	if err != nil {
+		return err
+	}
+
+	rp := rh.base.repo
+	lifetime := rp.Config.Duration("repo.subkeys.lifetime")
+
+	rawLifetime, err := call.Params.Lifetime()
+	if err != nil {
+		return err
+	}
+
+	if rawLifetime != "" {
+		if lifetime, err = time.ParseDuration(rawLifetime); err != nil {
+			return err
+		}
+	}
+
+	if lifetime <= 0 {
+		return fmt.Errorf("lifetime must be positive: %v", lifetime)
+	}
+
+	sk, err := rp.Keyring().RotateSubkey(usage, lifetime)
+	if err != nil {
+		return err
+	}
+
+	capSubkey, err := subkeyToCap(sk, true, call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetSubkey(*capSubkey)
+}
+
+// maxEventsWait is the longest time a client may wait for new events in one call.
+const maxEventsWait = time.Minute
+
+func busEventToCap(ev bus.Event, seg *capnplib.Segment) (*capnp.BusEvent, error) {
+	capEv, err := capnp.NewBusEvent(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	capEv.SetSeq(ev.Seq)
+	capEv.SetDone(ev.Done)
+	capEv.SetTotal(ev.Total)
+
+	if err := capEv.SetKind(string(ev.Kind)); err != nil {
+		return nil, err
+	}
+
+	if err := capEv.SetTime(ev.Time.Format(time.RFC3339Nano)); err != nil {
+		return nil, err
+	}
+
+	if err := capEv.SetPath(ev.Path); err != nil {
+		return nil, err
+	}
+
+	if err := capEv.SetRemote(ev.Remote); err != nil {
+		return nil, err
+	}
+
+	if err := capEv.SetMessage(ev.Message); err != nil {
+		return nil, err
+	}
+
+	return &capEv, nil
+}
+
+func (rh *repoHandler) EventsWait(call capnp.Repo_eventsWait) error {
+	server.Ack(call.Options)
+
+	evBus := rh.base.evBus
+	consumer, err := call.Params.Consumer()
+	if err != nil {
+		return err
+	}
+
+	capKinds, err := call.Params.Kinds()
+	if err != nil {
+		return err
+	}
+
+	kinds := make(map[bus.Kind]bool)
+	for idx := 0; idx < capKinds.Len(); idx++ {
+		kind, err := capKinds.At(idx)
+		if err != nil {
+			return err
+		}
+
+		kinds[bus.Kind(kind)] = true
+	}
+
+	// A zero `since` means: continue where the consumer stopped last time.
+	// Consumers without a name (or new ones) only get new events.
+	since := call.Params.Since()
+	if since == 0 {
+		cursor, ok := evBus.Cursor(consumer)
+		if consumer != "" && ok {
+			since = cursor
+		} else {
+			since = evBus.Seq()
+			evBus.Ack(consumer, since)
+		}
+	}
+
+	timeout := time.Duration(call.Params.TimeoutMs()) * time.Millisecond
+	if timeout <= 0 || timeout > maxEventsWait {
+		timeout = maxEventsWait
+	}
+
+	ctx, cancel := context.WithTimeout(rh.base.ctx, timeout)
+	defer cancel()
+
+	evs, err := evBus.Wait(ctx, since)
+	if err != nil && err != context.DeadlineExceeded {
+		return err
+	}
+
+	filtered := []bus.Event{}
+	for _, ev := range evs {
+		since = ev.Seq
+		if len(kinds) == 0 || kinds[ev.Kind] || ev.Kind == bus.KindLost {
+			filtered = append(filtered, ev)
+		}
+	}
+
+	seg := call.Results.Segment()
+	capEvs, err := capnp.NewBusEvent_List(seg, int32(len(filtered)))
+	if err != nil {
+		return err
+	}
+
+	for idx, ev := range filtered {
+		capEv, err := busEventToCap(ev, seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capEvs.Set(idx, *capEv); err != nil {
+			return err
+		}
+	}
+
+	call.Results.SetSeq(since)
+	return call.Results.SetEvents(capEvs)
+}
+
+func (rh *repoHandler) EventsAck(call capnp.Repo_eventsAck) error {
+	server.Ack(call.Options)
+
+	consumer, err := call.Params.Consumer()
+	if err != nil {
+		return err
+	}
+
+	rh.base.evBus.Ack(consumer, call.Params.Seq())
+	return nil
+}
+
+func scrubStatusToCap(status catfs.ScrubStatus, seg *capnplib.Segment) (*capnp.ScrubStatus, error) {
+	capStatus, err := capnp.NewScrubStatus(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	capStatus.SetRunning(status.Running)
+	capStatus.SetChecked(int64(status.Checked))
+	capStatus.SetBytes(status.Bytes)
+
+	if !status.LastStart.IsZero() {
+		if err := capStatus.SetLastStart(status.LastStart.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !status.LastEnd.IsZero() {
+		if err := capStatus.SetLastEnd(status.LastEnd.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := capStatus.SetError(status.Err); err != nil {
+		return nil, err
+	}
+
+	capCorrupt, err := capnp.NewScrubCorruption_List(seg, int32(len(status.Corrupt)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, corruption := range status.Corrupt {
+		capCorruption, err := capnp.NewScrubCorruption(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := capCorruption.SetPath(corruption.Path); err != nil {
+			return nil, err
+		}
+
+		if err := capCorruption.SetBackendHash(corruption.BackendHash); err != nil {
+			return nil, err
+		}
+
+		if err := capCorruption.SetError(corruption.Err); err != nil {
+			return nil, err
+		}
+
+		capCorruption.SetRepaired(corruption.Repaired)
+		if err := capCorrupt.Set(idx, capCorruption); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := capStatus.SetCorrupt(capCorrupt); err != nil {
+		return nil, err
+	}
+
+	return &capStatus, nil
+}
+
+func (rh *repoHandler) DaemonStatus(call capnp.Repo_daemonStatus) error {
+	server.Ack(call.Options)
+
+	seg := call.Results.Segment()
+	capStatus, err := capnp.NewDaemonStatus(seg)
+	if err != nil {
+		return err
+	}
+
+	err = rh.base.withCurrFs(func(fs *catfs.FS) error {
+		capScrub, err := scrubStatusToCap(fs.ScrubStatus(), seg)
+		if err != nil {
+			return err
+		}
+
+		return capStatus.SetScrub(*capScrub)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetStatus(capStatus)
+}
+
+func fsckReportToCap(report *catfs.FsckReport, seg *capnplib.Segment) (*capnp.FsckReport, error) {
+	capReport, err := capnp.NewFsckReport(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	capReport.SetNodes(int64(report.Nodes))
+	capReport.SetChecked(int64(report.Checked))
+	capReport.SetBytes(report.Bytes)
+
+	capProblems, err := capnp.NewFsckProblem_List(seg, int32(len(report.Problems)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, problem := range report.Problems {
+		capProblem := capProblems.At(idx)
+		if err := capProblem.SetPath(problem.Path); err != nil {
+			return nil, err
+		}
+
+		if err := capProblem.SetBackendHash(problem.BackendHash); err != nil {
+			return nil, err
+		}
+
+		if err := capProblem.SetError(problem.Err); err != nil {
+			return nil, err
+		}
+
+		capProblem.SetMissing(problem.Missing)
+		capProblem.SetRepaired(problem.Repaired)
+		capProblem.SetUnpinned(problem.Unpinned)
+	}
+
+	if err := capReport.SetProblems(capProblems); err != nil {
+		return nil, err
+	}
+
+	return &capReport, nil
+}
+
+func (rh *repoHandler) Fsck(call capnp.Repo_fsck) error {
+	server.Ack(call.Options)
+
+	opts := catfs.FsckOptions{
+		Content: call.Params.Content(),
+		Refetch: call.Params.Refetch(),
+		Unpin:   call.Params.Unpin(),
+	}
+
+	return rh.base.withCurrFs(func(fs *catfs.FS) error {
+		report, err := fs.Fsck(opts)
+		if err != nil {
+			return err
+		}
+
+		capReport, err := fsckReportToCap(report, call.Results.Segment())
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetReport(*capReport)
+	})
+}
+
+func statsToCapnp(seg *capnplib.Segment, snap statsSnapshot) (*capnp.DaemonStats, error) {
+	capStats, err := capnp.NewDaemonStats(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capStats.SetStarted(snap.Started.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	if err := capStats.SetSince(snap.Since.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	capOps, err := capnp.NewOpStats_List(seg, int32(len(snap.Ops)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, op := range snap.Ops {
+		capOp := capOps.At(idx)
+		if err := capOp.SetName(op.Name); err != nil {
+			return nil, err
+		}
+
+		capOp.SetCalls(op.Calls)
+		capOp.SetErrors(op.Errors)
+	}
+
+	if err := capStats.SetOps(capOps); err != nil {
+		return nil, err
+	}
+
+	capRemotes, err := capnp.NewRemoteStats_List(seg, int32(len(snap.Remotes)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, remote := range snap.Remotes {
+		capRemote := capRemotes.At(idx)
+		if err := capRemote.SetName(remote.Name); err != nil {
+			return nil, err
+		}
+
+		capRemote.SetSyncs(remote.Syncs)
+		capRemote.SetBytes(remote.Bytes)
+	}
+
+	if err := capStats.SetRemotes(capRemotes); err != nil {
+		return nil, err
+	}
+
+	capStats.SetCacheHits(snap.CacheHits)
+	capStats.SetCacheMisses(snap.CacheMisses)
+	capStats.SetBlockCacheHits(snap.BlockCacheHits)
+	capStats.SetBlockCacheMisses(snap.BlockCacheMisses)
+	capStats.SetBlockCacheSize(snap.BlockCacheSize)
+	capStats.SetBlockCacheMaxSize(snap.BlockCacheMaxSize)
+	return &capStats, nil
+}
+
+func (rh *repoHandler) DaemonStats(call capnp.Repo_daemonStats) error {
+	server.Ack(call.Options)
+
+	var cache core.NodeCacheStats
+	err := rh.base.withCurrFs(func(fs *catfs.FS) error {
+		cache = fs.NodeCacheStats()
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Return the stats as they were before the reset,
+	// otherwise they would be lost.
+	blocks := rh.base.repo.BlockCacheStats()
+	snap := rh.base.stats.snapshot(cache, blocks)
+	if call.Params.Reset() {
+		rh.base.stats.reset(cache, blocks)
+	}
+
+	capStats, err := statsToCapnp(call.Results.Segment(), snap)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetStats(*capStats)
+}
+
+func (rh *repoHandler) ActivityStream(call capnp.Repo_activityStream) error {
+	server.Ack(call.Options)
+
+	rawInterval, err := call.Params.Interval()
+	if err != nil {
+		return err
+	}
+
+	interval := time.Second
+	if rawInterval != "" {
+		if interval, err = time.ParseDuration(rawInterval); err != nil {
+			return err
+		}
+	}
+
+	if interval < 100*time.Millisecond {
+		return fmt.Errorf("interval is too short: %s", interval)
+	}
+
+	// The activity is sent as one capnp message per interval,
+	// until the client hangs up or the daemon quits.
+	port, err := bootTransferServer(nil, rh.base.bindHost, func(conn net.Conn) {
+		enc := capnplib.NewEncoder(conn)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := rh.base.writeActivity(enc); err != nil {
+				log.Debugf("activity stream ended: %v", err)
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-rh.base.ctx.Done():
+				return
+			}
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	call.Results.SetPort(int32(port))
+	return nil
+}
+
+func compressDictsToCapnp(seg *capnplib.Segment, dicts []catfs.CompressDict) (capnp.CompressDict_List, error) {
+	capDicts, err := capnp.NewCompressDict_List(seg, int32(len(dicts)))
+	if err != nil {
+		return capDicts, err
+	}
+
+	for idx, dict := range dicts {
+		capDict, err := capnp.NewCompressDict(seg)
+		if err != nil {
+			return capDicts, err
+		}
+
+		if err := capDict.SetClass(dict.Class); err != nil {
+			return capDicts, err
+		}
+
+		capDict.SetId(dict.ID)
+		capDict.SetSize(int64(dict.Size))
+		capDict.SetSamples(int64(dict.Samples))
+		if err := capDicts.Set(idx, capDict); err != nil {
+			return capDicts, err
+		}
+	}
+
+	return capDicts, nil
+}
+
+func (rh *repoHandler) CompressTrain(call capnp.Repo_compressTrain) error {
+	server.Ack(call.Options)
+
+	size := int(rh.base.repo.Config.Int("fs.compress.dictionary_size"))
+	return rh.base.withCurrFs(func(fs *catfs.FS) error {
+		dicts, err := fs.TrainDicts(size)
+		if err != nil {
+			return err
+		}
+
+		capDicts, err := compressDictsToCapnp(call.Results.Segment(), dicts)
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetDicts(capDicts)
+	})
+}
+
+func (rh *repoHandler) CompressDicts(call capnp.Repo_compressDicts) error {
+	server.Ack(call.Options)
+
+	return rh.base.withCurrFs(func(fs *catfs.FS) error {
+		dicts, err := fs.Dicts()
+		if err != nil {
+			return err
+		}
+
+		capDicts, err := compressDictsToCapnp(call.Results.Segment(), dicts)
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetDicts(capDicts)
+	})
+}
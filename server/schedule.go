@@ -0,0 +1,58 @@
+package server
+
+import (
+	"time"
+
+	"github.com/sahib/brig/net/schedule"
+	log "github.com/sirupsen/logrus"
+)
+
+// transferScheduleInterval is how often we check if deferred
+// transfers may run now.
+const transferScheduleInterval = time.Minute
+
+func (b *base) loadTransferSchedule() {
+	cfg := b.repo.Config
+	b.transferSched = schedule.NewScheduler(func() schedule.Config {
+		return schedule.Config{
+			Windows:       cfg.String("net.schedule.windows"),
+			UnmeteredOnly: cfg.Bool("net.schedule.unmetered_only"),
+			Detector:      cfg.String("net.schedule.metered_detector"),
+			Command:       cfg.String("net.schedule.metered_command"),
+		}
+	})
+
+	b.repo.SetTransferGate(b.heavyTransferAllowed)
+	go b.transferScheduleLoop()
+}
+
+// heavyTransferAllowed is used as transfer gate for all filesystems.
+// Metadata syncs do not ask it; only fetching file content does.
+func (b *base) heavyTransferAllowed() bool {
+	if b.transferSched == nil {
+		return true
+	}
+
+	ok, reason := b.transferSched.HeavyAllowed()
+	if !ok {
+		log.Debugf("heavy transfers are paused: %s", reason)
+	}
+
+	return ok
+}
+
+func (b *base) transferScheduleLoop() {
+	ticker := time.NewTicker(transferScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if n := b.repo.RunDeferredPreCache(); n > 0 {
+				log.Infof("transfers are allowed again; pre-caching %d deferred files", n)
+			}
+		}
+	}
+}
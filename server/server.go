@@ -2,17 +2,18 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"io/ioutil"
 	"log/syslog"
-	"net"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"time"
 
+	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/defaults"
 	"github.com/sahib/brig/fuse"
 	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/dialer"
 	formatter "github.com/sahib/brig/util/log"
 	"github.com/sahib/brig/util/pwutil"
 	"github.com/sahib/brig/util/server"
@@ -44,6 +45,16 @@ func readPasswordFromHelper(basePath string, passwordFn func() (string, error))
 		return "", err
 	}
 
+	if provider := cfg.String("repo.keychain"); provider != "" {
+		password, err := repo.ReadPasswordFromKeychain(basePath, provider)
+		if err == nil {
+			log.Infof("password was read from the %s keychain", provider)
+			return password, nil
+		}
+
+		log.Warningf("failed to read password from the %s keychain: %v", provider, err)
+	}
+
 	passwordCmd := cfg.String("repo.password_command")
 	if passwordCmd == "" {
 		log.Infof("reading password via client logic")
@@ -113,12 +124,16 @@ func BootServer(
 		switchToSyslog()
 	}
 
-	addr := fmt.Sprintf("%s:%d", bindHost, port)
+	addr := dialer.JoinHostPort(bindHost, port)
 	log.Infof("starting daemon for %s on port %s", basePath, addr)
 
-	password, err := readPasswordFromHelper(basePath, passwordFn)
-	if err != nil {
-		return nil, err
+	// After a graceful restart, the old daemon passes us the password:
+	password := string(server.InheritedState())
+	if !server.IsRestarted() {
+		var err error
+		if password, err = readPasswordFromHelper(basePath, passwordFn); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := repo.CheckPassword(basePath, password); err != nil {
@@ -143,7 +158,7 @@ func BootServer(
 		logToStdout,
 	)
 
-	lst, err := net.Listen("tcp", addr)
+	lst, err := listenControl(basePath, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -162,14 +177,34 @@ func BootServer(
 		}
 	}()
 
+	// The old daemon still holds the repository until it drained its connections:
+	if err := server.WaitForParent(5 * time.Minute); err != nil {
+		return nil, err
+	}
+
 	if err := base.loadAll(); err != nil {
 		return nil, err
 	}
 
+	baseServer.EnableRestart(func() ([]byte, error) {
+		return []byte(password), nil
+	}, base.repo.Config.Duration("daemon.restart_drain_timeout"))
+
 	if err := applyFstabInitially(base); err != nil {
 		log.Warnf("could not mount fstab mounts: %v", err)
 	}
 
+	// Let systemd know we're up when started with Type=notify:
+	if err := server.Notify("READY=1"); err != nil {
+		log.Warnf("failed to notify systemd: %v", err)
+	}
+
+	server.StartWatchdog(ctx, func() error {
+		return base.withCurrFs(func(fs *catfs.FS) error {
+			return fs.Ping()
+		})
+	})
+
 	return &Server{
 		baseServer: baseServer,
 		base:       base,
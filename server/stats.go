@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/catfs/blockcache"
+	"github.com/sahib/brig/catfs/core"
+	capnplib "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+// opStats counts how often a single kind of operation was called.
+type opStats struct {
+	Name   string
+	Calls  uint64
+	Errors uint64
+}
+
+// remoteStats counts what was synced with a single remote.
+type remoteStats struct {
+	Name  string
+	Syncs uint64
+	Bytes int64
+}
+
+// statsSnapshot is a copy of the daemon statistics at one point in time.
+type statsSnapshot struct {
+	Started     time.Time
+	Since       time.Time
+	Ops         []opStats
+	Remotes     []remoteStats
+	CacheHits   uint64
+	CacheMisses uint64
+
+	BlockCacheHits    uint64
+	BlockCacheMisses  uint64
+	BlockCacheSize    int64
+	BlockCacheMaxSize int64
+}
+
+// daemonStats collects usage statistics of the daemon. They are only kept
+// in memory and are never sent anywhere; they are meant for debugging and
+// capacity planning. The zero value is not usable, use newDaemonStats.
+type daemonStats struct {
+	mu sync.Mutex
+
+	started time.Time
+	since   time.Time
+	ops     map[string]*opStats
+	remotes map[string]*remoteStats
+
+	// The node cache counts since the start of the daemon.
+	// Remember the counts at the last reset to report the difference.
+	cacheHitsBase   uint64
+	cacheMissesBase uint64
+
+	// Same for the block cache.
+	blockHitsBase   uint64
+	blockMissesBase uint64
+}
+
+func newDaemonStats() *daemonStats {
+	now := time.Now()
+	return &daemonStats{
+		started: now,
+		since:   now,
+		ops:     make(map[string]*opStats),
+		remotes: make(map[string]*remoteStats),
+	}
+}
+
+// opName converts the capnp name of a method to something
+// more readable, e.g. "fs.stat" for the stat method of FS.
+func opName(method capnplib.Method) string {
+	iface := method.InterfaceName
+	if idx := strings.LastIndex(iface, ":"); idx >= 0 {
+		iface = iface[idx+1:]
+	}
+
+	return strings.ToLower(iface) + "." + method.MethodName
+}
+
+// countOp records a call of `name` that returned `err`.
+func (ds *daemonStats) countOp(name string, err error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	op, ok := ds.ops[name]
+	if !ok {
+		op = &opStats{Name: name}
+		ds.ops[name] = op
+	}
+
+	op.Calls++
+	if err != nil {
+		op.Errors++
+	}
+}
+
+// wrapMethods counts every call to one of `methods`.
+func (ds *daemonStats) wrapMethods(methods []server.Method) []server.Method {
+	for idx := range methods {
+		name := opName(methods[idx].Method)
+		impl := methods[idx].Impl
+		methods[idx].Impl = func(ctx context.Context, opts capnplib.CallOptions, p, r capnplib.Struct) error {
+			err := impl(ctx, opts, p, r)
+			ds.countOp(name, err)
+			return err
+		}
+	}
+
+	return methods
+}
+
+func (ds *daemonStats) remote(name string) *remoteStats {
+	remote, ok := ds.remotes[name]
+	if !ok {
+		remote = &remoteStats{Name: name}
+		ds.remotes[name] = remote
+	}
+
+	return remote
+}
+
+// addSynced records that `size` bytes of metadata were fetched from `remote`.
+func (ds *daemonStats) addSynced(remote string, size int64) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.remote(remote).Bytes += size
+}
+
+// countSync records a successful sync with `remote`.
+func (ds *daemonStats) countSync(remote string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.remote(remote).Syncs++
+}
+
+// snapshot returns the statistics since the last reset.
+// `cache` and `blocks` are the current statistics of the node
+// cache and the block cache.
+func (ds *daemonStats) snapshot(cache core.NodeCacheStats, blocks blockcache.Stats) statsSnapshot {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	snap := statsSnapshot{
+		Started: ds.started,
+		Since:   ds.since,
+		Ops:     []opStats{},
+		Remotes: []remoteStats{},
+	}
+
+	snap.CacheHits, snap.CacheMisses = cache.Hits, cache.Misses
+
+	// The cache might have been recreated since the reset; then all its
+	// counts happened after the reset.
+	if cache.Hits >= ds.cacheHitsBase && cache.Misses >= ds.cacheMissesBase {
+		snap.CacheHits -= ds.cacheHitsBase
+		snap.CacheMisses -= ds.cacheMissesBase
+	}
+
+	snap.BlockCacheHits, snap.BlockCacheMisses = blocks.Hits, blocks.Misses
+	if blocks.Hits >= ds.blockHitsBase && blocks.Misses >= ds.blockMissesBase {
+		snap.BlockCacheHits -= ds.blockHitsBase
+		snap.BlockCacheMisses -= ds.blockMissesBase
+	}
+
+	snap.BlockCacheSize = blocks.Size
+	snap.BlockCacheMaxSize = blocks.MaxSize
+
+	for _, op := range ds.ops {
+		snap.Ops = append(snap.Ops, *op)
+	}
+
+	for _, remote := range ds.remotes {
+		snap.Remotes = append(snap.Remotes, *remote)
+	}
+
+	sort.Slice(snap.Ops, func(i, j int) bool {
+		return snap.Ops[i].Name < snap.Ops[j].Name
+	})
+
+	sort.Slice(snap.Remotes, func(i, j int) bool {
+		return snap.Remotes[i].Name < snap.Remotes[j].Name
+	})
+
+	return snap
+}
+
+// reset sets all counters back to zero. The uptime is not affected.
+func (ds *daemonStats) reset(cache core.NodeCacheStats, blocks blockcache.Stats) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.since = time.Now()
+	ds.ops = make(map[string]*opStats)
+	ds.remotes = make(map[string]*remoteStats)
+	ds.cacheHitsBase = cache.Hits
+	ds.cacheMissesBase = cache.Misses
+	ds.blockHitsBase = blocks.Hits
+	ds.blockMissesBase = blocks.Misses
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sahib/brig/catfs/blockcache"
+	"github.com/sahib/brig/catfs/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonStats(t *testing.T) {
+	ds := newDaemonStats()
+	ds.countOp("fs.stat", nil)
+	ds.countOp("fs.stat", errors.New("nope"))
+	ds.countOp("fs.cat", nil)
+	ds.addSynced("bob", 100)
+	ds.addSynced("bob", 20)
+	ds.countSync("bob")
+
+	snap := ds.snapshot(core.NodeCacheStats{Hits: 3, Misses: 1}, blockcache.Stats{Hits: 7, Misses: 2, Size: 10, MaxSize: 100})
+	require.Equal(t, []opStats{
+		{Name: "fs.cat", Calls: 1},
+		{Name: "fs.stat", Calls: 2, Errors: 1},
+	}, snap.Ops)
+	require.Equal(t, []remoteStats{{Name: "bob", Syncs: 1, Bytes: 120}}, snap.Remotes)
+	require.Equal(t, uint64(3), snap.CacheHits)
+	require.Equal(t, uint64(1), snap.CacheMisses)
+	require.Equal(t, uint64(7), snap.BlockCacheHits)
+	require.Equal(t, uint64(2), snap.BlockCacheMisses)
+	require.Equal(t, int64(10), snap.BlockCacheSize)
+
+	ds.reset(core.NodeCacheStats{Hits: 3, Misses: 1}, blockcache.Stats{Hits: 7, Misses: 2})
+	snap = ds.snapshot(core.NodeCacheStats{Hits: 5, Misses: 1}, blockcache.Stats{Hits: 8, Misses: 4, Size: 20, MaxSize: 100})
+	require.Empty(t, snap.Ops)
+	require.Empty(t, snap.Remotes)
+	require.Equal(t, uint64(2), snap.CacheHits)
+	require.Equal(t, uint64(0), snap.CacheMisses)
+	require.Equal(t, uint64(1), snap.BlockCacheHits)
+	require.Equal(t, uint64(2), snap.BlockCacheMisses)
+	require.Equal(t, int64(20), snap.BlockCacheSize)
+	require.Equal(t, ds.started, snap.Started)
+	require.False(t, snap.Since.Before(snap.Started))
+}
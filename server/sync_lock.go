@@ -0,0 +1,50 @@
+package server
+
+import "sync"
+
+// syncLocks makes sure that only one sync with a certain remote runs at
+// a time. Two interleaving syncs with the same remote would both create
+// a merge commit for the same changes. The zero value is ready to use.
+type syncLocks struct {
+	mu sync.Mutex
+
+	// Every remote has a channel with a capacity of one;
+	// a sync is running if it is full.
+	locks map[string]chan struct{}
+}
+
+func (sl *syncLocks) get(remote string) chan struct{} {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.locks == nil {
+		sl.locks = make(map[string]chan struct{})
+	}
+
+	lock, ok := sl.locks[remote]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		sl.locks[remote] = lock
+	}
+
+	return lock
+}
+
+// lock waits until no other sync with `remote` is running.
+func (sl *syncLocks) lock(remote string) {
+	sl.get(remote) <- struct{}{}
+}
+
+// tryLock is like lock, but returns false instead of waiting.
+func (sl *syncLocks) tryLock(remote string) bool {
+	select {
+	case sl.get(remote) <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sl *syncLocks) unlock(remote string) {
+	<-sl.get(remote)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"path/filepath"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs"
+	p2pnet "github.com/sahib/brig/net"
+	log "github.com/sirupsen/logrus"
+)
+
+func (b *base) loadSyncLog() error {
+	path := filepath.Join(b.repo.BaseFolder, "sync-stats.json")
+	syncLog, err := p2pnet.NewSyncLog(path, int(b.repo.Config.Int("net.sync_stats.max_records")))
+	if err != nil {
+		return e.Wrapf(err, "sync stats")
+	}
+
+	b.syncLog = syncLog
+	return nil
+}
+
+// recordSync remembers how the sync with `remote` that started at
+// `started` went. `diff` is what changed on our side.
+func (b *base) recordSync(remote string, started time.Time, diff *catfs.Diff, conflicts int, err error) {
+	if b.syncLog == nil {
+		return
+	}
+
+	record := p2pnet.SyncRecord{
+		Remote:    remote,
+		Started:   started,
+		Duration:  time.Since(started),
+		Conflicts: conflicts,
+	}
+
+	if err != nil {
+		record.Err = err.Error()
+	}
+
+	if diff != nil {
+		record.Files = len(diff.Added) + len(diff.Removed) + len(diff.Moved) + len(diff.Merged) + len(diff.Conflict)
+		record.Bytes = diff.TransferSize
+	}
+
+	if err := b.syncLog.Add(record); err != nil {
+		log.Warningf("failed to record sync with %s: %v", remote, err)
+	}
+}
@@ -1,10 +1,10 @@
 package server
 
 import (
-	"fmt"
 	"net"
 
 	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/util/dialer"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -29,7 +29,7 @@ func bootTransferServer(fs *catfs.FS, bindHost string, copyFn func(conn net.Conn
 		return 0, err
 	}
 
-	lst, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindHost, port))
+	lst, err := net.Listen("tcp", dialer.JoinHostPort(bindHost, port))
 	if err != nil {
 		return 0, err
 	}
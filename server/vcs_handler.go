@@ -5,6 +5,7 @@ import (
 
 	e "github.com/pkg/errors"
 	"github.com/sahib/brig/catfs"
+	p2pnet "github.com/sahib/brig/net"
 	"github.com/sahib/brig/server/capnp"
 	cplib "zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/server"
@@ -52,6 +53,29 @@ func commitToCap(entry *catfs.Commit, seg *cplib.Segment) (*capnp.Commit, error)
 		return nil, err
 	}
 
+	if err := capEntry.SetDevice(entry.Device); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetOrigin(entry.Origin); err != nil {
+		return nil, err
+	}
+
+	labelList, err := cplib.NewTextList(seg, int32(len(entry.Labels)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, label := range entry.Labels {
+		if err := labelList.Set(idx, label); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := capEntry.SetLabels(labelList); err != nil {
+		return nil, err
+	}
+
 	return &capEntry, nil
 }
 
@@ -59,12 +83,20 @@ func (vcs *vcsHandler) Log(call capnp.VCS_log) error {
 	server.Ack(call.Options)
 	seg := call.Results.Segment()
 
+	label, err := call.Params.Label()
+	if err != nil {
+		return err
+	}
+
 	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
 		// TODO: Support partial logs at some point.
 		// (like in gateway. currently everything is dumped.)
 		entries := []*catfs.Commit{}
 		err := fs.Log("", func(cmt *catfs.Commit) error {
-			entries = append(entries, cmt)
+			if label == "" || cmt.HasLabel(label) {
+				entries = append(entries, cmt)
+			}
+
 			return nil
 		})
 
@@ -98,9 +130,24 @@ func (vcs *vcsHandler) Commit(call capnp.VCS_commit) error {
 		return err
 	}
 
+	capLabels, err := call.Params.Labels()
+	if err != nil {
+		return err
+	}
+
+	meta := catfs.CommitMeta{Origin: catfs.CommitOriginCLI}
+	for idx := 0; idx < capLabels.Len(); idx++ {
+		label, err := capLabels.At(idx)
+		if err != nil {
+			return err
+		}
+
+		meta.Labels = append(meta.Labels, label)
+	}
+
 	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
 		msg = "user: " + msg
-		return fs.MakeCommit(msg)
+		return fs.MakeCommitWithMeta(msg, meta)
 	})
 }
 
@@ -370,6 +417,7 @@ func diffToCapnpDiff(seg *cplib.Segment, diff *catfs.Diff) (*capnp.Diff, error)
 		return nil, err
 	}
 
+	capDiff.SetTransferSize(diff.TransferSize)
 	return &capDiff, nil
 }
 
@@ -439,7 +487,8 @@ func (vcs *vcsHandler) Fetch(call capnp.VCS_fetch) error {
 		return err
 	}
 
-	return vcs.base.doFetch(who)
+	err = vcs.base.doFetch(who)
+	return vcs.base.queueIfOffline(who, p2pnet.QueueOpFetch, "", err)
 }
 
 func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
@@ -451,6 +500,27 @@ func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
 	}
 
 	diff, err := vcs.base.doSync(withWhom, call.Params.NeedFetch(), "")
+	if err != nil {
+		return vcs.base.queueIfOffline(withWhom, p2pnet.QueueOpSync, "", err)
+	}
+
+	capDiff, err := diffToCapnpDiff(call.Results.Segment(), diff)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetDiff(*capDiff)
+}
+
+func (vcs *vcsHandler) SyncPreview(call capnp.VCS_syncPreview) error {
+	server.Ack(call.Options)
+
+	withWhom, err := call.Params.WithWhom()
+	if err != nil {
+		return err
+	}
+
+	diff, err := vcs.base.doSyncPreview(withWhom, call.Params.NeedFetch())
 	if err != nil {
 		return err
 	}
@@ -463,6 +533,79 @@ func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
 	return call.Results.SetDiff(*capDiff)
 }
 
+func (vcs *vcsHandler) BlockDiff(call capnp.VCS_blockDiff) error {
+	server.Ack(call.Options)
+
+	oldRev, err := call.Params.OldRev()
+	if err != nil {
+		return err
+	}
+
+	oldPath, err := call.Params.OldPath()
+	if err != nil {
+		return err
+	}
+
+	newRev, err := call.Params.NewRev()
+	if err != nil {
+		return err
+	}
+
+	newPath, err := call.Params.NewPath()
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	return vcs.base.withFsFromPath(newPath, func(url *URL, fs *catfs.FS) error {
+		oldURL, err := parsePath(oldPath)
+		if err != nil {
+			return err
+		}
+
+		diff, err := fs.BlockDiff(oldRev, oldURL.Path, newRev, url.Path)
+		if err != nil {
+			return err
+		}
+
+		capDiff, err := capnp.NewBlockDiff(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capDiff.SetOldPath(diff.OldPath); err != nil {
+			return err
+		}
+
+		if err := capDiff.SetNewPath(diff.NewPath); err != nil {
+			return err
+		}
+
+		capDiff.SetOldSize(diff.OldSize)
+		capDiff.SetNewSize(diff.NewSize)
+		capDiff.SetBlockSize(diff.BlockSize)
+		capDiff.SetChangedBytes(diff.ChangedBytes)
+		capDiff.SetUnchangedBytes(diff.UnchangedBytes)
+
+		capRanges, err := capnp.NewByteRange_List(seg, int32(len(diff.Changed)))
+		if err != nil {
+			return err
+		}
+
+		for idx, rng := range diff.Changed {
+			capRange := capRanges.At(idx)
+			capRange.SetOffset(rng.Offset)
+			capRange.SetLength(rng.Length)
+		}
+
+		if err := capDiff.SetChanged(capRanges); err != nil {
+			return err
+		}
+
+		return call.Results.SetDiff(capDiff)
+	})
+}
+
 func (vcs *vcsHandler) CommitInfo(call capnp.VCS_commitInfo) error {
 	server.Ack(call.Options)
 
@@ -492,3 +635,167 @@ func (vcs *vcsHandler) CommitInfo(call capnp.VCS_commitInfo) error {
 		return nil
 	})
 }
+
+func (vcs *vcsHandler) Snapshots(call capnp.VCS_snapshots) error {
+	server.Ack(call.Options)
+	seg := call.Results.Segment()
+
+	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
+		snapshots, err := fs.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		lst, err := capnp.NewSnapshot_List(seg, int32(len(snapshots)))
+		if err != nil {
+			return err
+		}
+
+		for idx, snapshot := range snapshots {
+			capSnapshot, err := capnp.NewSnapshot(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capSnapshot.SetTier(snapshot.Tier); err != nil {
+				return err
+			}
+
+			if err := capSnapshot.SetTag(snapshot.Tag); err != nil {
+				return err
+			}
+
+			if err := capSnapshot.SetCommit(snapshot.Commit); err != nil {
+				return err
+			}
+
+			lst.Set(idx, capSnapshot)
+		}
+
+		return call.Results.SetSnapshots(lst)
+	})
+}
+
+func (vcs *vcsHandler) BundleCreate(call capnp.VCS_bundleCreate) error {
+	server.Ack(call.Options)
+
+	fromRev, err := call.Params.FromRev()
+	if err != nil {
+		return err
+	}
+
+	toRev, err := call.Params.ToRev()
+	if err != nil {
+		return err
+	}
+
+	data, err := vcs.base.doCreateBundle(fromRev, toRev)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetData(data)
+}
+
+func (vcs *vcsHandler) BundleApply(call capnp.VCS_bundleApply) error {
+	server.Ack(call.Options)
+
+	data, err := call.Params.Data()
+	if err != nil {
+		return err
+	}
+
+	owner, diff, err := vcs.base.doApplyBundle(data)
+	if err != nil {
+		return err
+	}
+
+	if err := call.Results.SetOwner(owner); err != nil {
+		return err
+	}
+
+	capDiff, err := diffToCapnpDiff(call.Results.Segment(), diff)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetDiff(*capDiff)
+}
+
+func undoChangesToCapnp(seg *cplib.Segment, changes []catfs.UndoChange) (capnp.UndoChange_List, error) {
+	lst, err := capnp.NewUndoChange_List(seg, int32(len(changes)))
+	if err != nil {
+		return lst, err
+	}
+
+	for idx, change := range changes {
+		capChange, err := capnp.NewUndoChange(seg)
+		if err != nil {
+			return lst, err
+		}
+
+		if err := capChange.SetPath(change.Path); err != nil {
+			return lst, err
+		}
+
+		if err := capChange.SetAction(string(change.Action)); err != nil {
+			return lst, err
+		}
+
+		capChange.SetIsDir(change.IsDir)
+		capChange.SetSize(change.Size)
+		lst.Set(idx, capChange)
+	}
+
+	return lst, nil
+}
+
+func (vcs *vcsHandler) UndoPreview(call capnp.VCS_undoPreview) error {
+	server.Ack(call.Options)
+	seg := call.Results.Segment()
+
+	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
+		changes, head, err := fs.UndoPreview(int(call.Params.Count()))
+		if err != nil {
+			return err
+		}
+
+		lst, err := undoChangesToCapnp(seg, changes)
+		if err != nil {
+			return err
+		}
+
+		if err := call.Results.SetHead(head); err != nil {
+			return err
+		}
+
+		return call.Results.SetChanges(lst)
+	})
+}
+
+func (vcs *vcsHandler) Undo(call capnp.VCS_undo) error {
+	server.Ack(call.Options)
+	seg := call.Results.Segment()
+
+	expectHead, err := call.Params.ExpectHead()
+	if err != nil {
+		return err
+	}
+
+	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
+		meta := catfs.CommitMeta{Origin: catfs.CommitOriginCLI}
+		changes, err := fs.Undo(int(call.Params.Count()), expectHead, meta)
+		if err != nil {
+			return err
+		}
+
+		vcs.base.notifyFsChangeEvent()
+
+		lst, err := undoChangesToCapnp(seg, changes)
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetChanges(lst)
+	})
+}
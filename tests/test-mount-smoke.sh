@@ -0,0 +1,74 @@
+#!/bin/bash
+# Smoke test for the FUSE mount: do the usual file operations through
+# a real mount and check that brig sees them. Needs fuse to be installed.
+
+set -e
+
+export BRIG_PATH=/tmp/brig-mount-smoke
+MOUNT_PATH=/tmp/brig-mount-smoke-mnt
+
+brig daemon quit 2> /dev/null || true
+rm -rf $BRIG_PATH $MOUNT_PATH
+
+brig --verbose init smoke -w "echo smoke"
+brig mount $MOUNT_PATH
+
+cleanup() {
+    brig unmount $MOUNT_PATH || true
+    brig daemon quit || true
+}
+
+trap cleanup EXIT
+
+# Write and read back:
+echo "hello world" > $MOUNT_PATH/hello.txt
+if [[ "$(cat $MOUNT_PATH/hello.txt)" != "hello world" ]]; then
+    echo "!! mount does not return written content"
+    exit 1
+fi
+
+# Directories, renames and permissions:
+mkdir -p $MOUNT_PATH/sub/dir
+mv $MOUNT_PATH/hello.txt $MOUNT_PATH/sub/dir/hello.txt
+chmod 600 $MOUNT_PATH/sub/dir/hello.txt
+ln -s dir/hello.txt $MOUNT_PATH/sub/link
+
+if [[ "$(stat -c %a $MOUNT_PATH/sub/dir/hello.txt 2> /dev/null || stat -f %Lp $MOUNT_PATH/sub/dir/hello.txt)" != "600" ]]; then
+    echo "!! chmod was not applied"
+    exit 2
+fi
+
+if [[ "$(cat $MOUNT_PATH/sub/link)" != "hello world" ]]; then
+    echo "!! symlink does not resolve"
+    exit 3
+fi
+
+# Wait for the write-back cache and check that brig sees the file:
+sync
+sleep 2
+if [[ "$(brig cat /sub/dir/hello.txt)" != "hello world" ]]; then
+    echo "!! written file is not visible in brig"
+    exit 4
+fi
+
+# Changes done by brig have to show up in the mount:
+echo "from brig" | brig stage --stdin /from-brig.txt
+sleep 1
+if [[ "$(cat $MOUNT_PATH/from-brig.txt)" != "from brig" ]]; then
+    echo "!! staged file is not visible in the mount"
+    exit 5
+fi
+
+# The history dir must not show up in listings:
+if ls -a $MOUNT_PATH | grep -q '^\.brig$'; then
+    echo "!! .brig is listed in the mount root"
+    exit 6
+fi
+
+rm -rf $MOUNT_PATH/sub
+if [[ -e $MOUNT_PATH/sub ]]; then
+    echo "!! removed directory still exists"
+    exit 7
+fi
+
+echo "-- mount smoke test passed"
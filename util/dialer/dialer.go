@@ -0,0 +1,147 @@
+// Package dialer connects to hosts that can be reached over more than
+// one address, e.g. over IPv6 and IPv4 or over several interfaces.
+//
+// It implements the "happy eyeballs" algorithm (RFC 8305): the addresses
+// are sorted so that the address families alternate, then a connection
+// attempt is started for each of them after a short delay, or right away
+// when the previous attempt failed. The first connection that succeeds is
+// used, all others are closed. A broken IPv6 (or IPv4) setup thereby only
+// costs the delay instead of a full timeout.
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDelay is the time to wait before starting the next attempt.
+// This is the "Connection Attempt Delay" recommended by RFC 8305.
+const DefaultDelay = 250 * time.Millisecond
+
+// JoinHostPort is like net.JoinHostPort, but takes the port as number
+// and accepts IPv6 hosts that are already in brackets (»[::1]«).
+func JoinHostPort(host string, port int) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func isIPv6(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// SortAddrs orders `addrs` like RFC 8305 suggests: IPv6 first, then the
+// families alternate. The order within a family is kept. Host names count
+// as IPv4, since they are resolved (and raced) by the dialer itself.
+func SortAddrs(addrs []string) []string {
+	v6, v4 := []string{}, []string{}
+	for _, addr := range addrs {
+		if isIPv6(addr) {
+			v6 = append(v6, addr)
+		} else {
+			v4 = append(v4, addr)
+		}
+	}
+
+	sorted := make([]string, 0, len(addrs))
+	for idx := 0; idx < len(v6) || idx < len(v4); idx++ {
+		if idx < len(v6) {
+			sorted = append(sorted, v6[idx])
+		}
+
+		if idx < len(v4) {
+			sorted = append(sorted, v4[idx])
+		}
+	}
+
+	return sorted
+}
+
+type dialResult struct {
+	conn net.Conn
+	addr string
+	err  error
+}
+
+// DialParallel connects to the first of `addrs` that answers.
+// The addresses are tried in the order of SortAddrs; every attempt gets a
+// head start of `delay` before the next one is started. It returns the
+// connection and the address it was made to.
+func DialParallel(ctx context.Context, network string, addrs []string, delay time.Duration) (net.Conn, string, error) {
+	if len(addrs) == 0 {
+		return nil, "", fmt.Errorf("no address to dial")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sorted := SortAddrs(addrs)
+	resultCh := make(chan dialResult, len(sorted))
+	startAttempt := func(addr string) {
+		go func() {
+			dialer := net.Dialer{}
+			conn, err := dialer.DialContext(ctx, network, addr)
+			resultCh <- dialResult{conn: conn, addr: addr, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	next, pending := 0, 0
+	errs := []string{}
+	for {
+		if next < len(sorted) && pending == 0 {
+			// Nothing is running; no reason to wait.
+			startAttempt(sorted[next])
+			next++
+			pending++
+			timer.Reset(delay)
+		}
+
+		if pending == 0 {
+			return nil, "", fmt.Errorf("all dial attempts failed: %s", strings.Join(errs, "; "))
+		}
+
+		select {
+		case res := <-resultCh:
+			pending--
+			if res.err != nil {
+				errs = append(errs, res.err.Error())
+				continue
+			}
+
+			// The other attempts are cancelled by the deferred cancel();
+			// close what they might have connected in the meantime.
+			go closeLosers(resultCh, pending)
+			return res.conn, res.addr, nil
+		case <-timer.C:
+			if next < len(sorted) {
+				startAttempt(sorted[next])
+				next++
+				pending++
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			go closeLosers(resultCh, pending)
+			return nil, "", ctx.Err()
+		}
+	}
+}
+
+func closeLosers(resultCh chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-resultCh; res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinHostPort(t *testing.T) {
+	require.Equal(t, "127.0.0.1:6666", JoinHostPort("127.0.0.1", 6666))
+	require.Equal(t, "[::1]:6666", JoinHostPort("::1", 6666))
+	require.Equal(t, "[::1]:6666", JoinHostPort("[::1]", 6666))
+	require.Equal(t, "localhost:6666", JoinHostPort("localhost", 6666))
+}
+
+func TestSortAddrs(t *testing.T) {
+	require.Equal(t, []string{
+		"[2001:db8::1]:1",
+		"192.0.2.1:1",
+		"[2001:db8::2]:1",
+		"example.org:1",
+		"192.0.2.2:1",
+	}, SortAddrs([]string{
+		"192.0.2.1:1",
+		"example.org:1",
+		"[2001:db8::1]:1",
+		"192.0.2.2:1",
+		"[2001:db8::2]:1",
+	}))
+
+	require.Equal(t, []string{}, SortAddrs(nil))
+}
+
+// closedAddr returns an address where nobody listens.
+func closedAddr(t *testing.T) string {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	addr := lst.Addr().String()
+	require.Nil(t, lst.Close())
+	return addr
+}
+
+func TestDialParallel(t *testing.T) {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer lst.Close()
+
+	go func() {
+		for {
+			conn, err := lst.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Write([]byte("x"))
+			conn.Close()
+		}
+	}()
+
+	// The working address is tried after the broken one;
+	// it should not need to wait for the full delay.
+	start := time.Now()
+	addrs := []string{closedAddr(t), lst.Addr().String()}
+	conn, addr, err := DialParallel(context.Background(), "tcp", addrs, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, lst.Addr().String(), addr)
+	require.True(t, time.Since(start) < 10*time.Second)
+
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, []byte("x"), buf)
+	require.Nil(t, conn.Close())
+}
+
+func TestDialParallelAllFail(t *testing.T) {
+	addrs := []string{closedAddr(t), closedAddr(t)}
+	_, _, err := DialParallel(context.Background(), "tcp", addrs, 10*time.Millisecond)
+	require.NotNil(t, err)
+
+	_, _, err = DialParallel(context.Background(), "tcp", nil, 10*time.Millisecond)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,131 @@
+// Package errkind sorts errors into a few broad kinds, so that callers
+// (mostly the command line) can react on them without looking at the
+// error text. Since errors of the daemon only survive the way over the
+// wire as text, the kind can be encoded in front of the message and
+// decoded again on the other side.
+package errkind
+
+import (
+	"strings"
+)
+
+// Kind is the broad category of an error.
+type Kind int
+
+const (
+	// Unknown is any error that was not sorted into one of the other kinds.
+	Unknown = Kind(iota)
+
+	// Invalid means that the input of the caller was not acceptable.
+	Invalid
+
+	// NotFound means that a path, remote, commit or repository does not exist.
+	NotFound
+
+	// Conflict means that something exists already or changed in the meantime.
+	Conflict
+
+	// Offline means that we or a remote could not be reached over the network.
+	Offline
+
+	// Unauthorized means that a remote refused us or is blocked.
+	Unauthorized
+)
+
+var kindToString = map[Kind]string{
+	Invalid:      "invalid",
+	NotFound:     "not-found",
+	Conflict:     "conflict",
+	Offline:      "offline",
+	Unauthorized: "unauthorized",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindToString[k]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Error is an error that knows its kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the underlying error, like github.com/pkg/errors expects.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Wrap marks `err` to be of `kind`. A nil `err` stays nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Kind: kind, Err: err}
+}
+
+// Next returns the error that `err` wraps, or nil if it wraps none.
+// Both the standard library's Unwrap and github.com/pkg/errors' Cause
+// are understood, since the code base uses both.
+func Next(err error) error {
+	switch werr := err.(type) {
+	case interface{ Unwrap() error }:
+		return werr.Unwrap()
+	case interface{ Cause() error }:
+		return werr.Cause()
+	default:
+		return nil
+	}
+}
+
+// Of returns the kind of the outermost error in the chain of `err`
+// that was marked with Wrap, or Unknown if there is none.
+func Of(err error) Kind {
+	for ; err != nil; err = Next(err) {
+		if kerr, ok := err.(*Error); ok {
+			return kerr.Kind
+		}
+	}
+
+	return Unknown
+}
+
+// Encode puts the kind in front of `msg`, so it can be decoded again
+// after the message was sent somewhere as plain text.
+func Encode(kind Kind, msg string) string {
+	if kind == Unknown {
+		return msg
+	}
+
+	return "[" + kind.String() + "] " + msg
+}
+
+// Decode is the reverse of Encode. If `msg` does not start with a kind,
+// Unknown and `msg` itself are returned.
+func Decode(msg string) (Kind, string) {
+	if !strings.HasPrefix(msg, "[") {
+		return Unknown, msg
+	}
+
+	for kind, name := range kindToString {
+		prefix := "[" + name + "] "
+		if strings.HasPrefix(msg, prefix) {
+			return kind, msg[len(prefix):]
+		}
+	}
+
+	return Unknown, msg
+}
@@ -0,0 +1,53 @@
+package errkind
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	e "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	base := errors.New("no such thing")
+	require.Equal(t, Unknown, Of(nil))
+	require.Equal(t, Unknown, Of(base))
+
+	err := Wrap(NotFound, base)
+	require.Equal(t, NotFound, Of(err))
+	require.Equal(t, base.Error(), err.Error())
+	require.True(t, errors.Is(err, base))
+
+	// Both ways of wrapping used in the code base have to be followed:
+	require.Equal(t, NotFound, Of(fmt.Errorf("cat: %w", err)))
+	require.Equal(t, NotFound, Of(e.Wrapf(err, "cat")))
+	require.Equal(t, NotFound, Of(e.Wrapf(fmt.Errorf("cat: %w", err), "x")))
+
+	// The outermost kind wins:
+	require.Equal(t, Conflict, Of(Wrap(Conflict, err)))
+
+	// Wrapping with %v loses the kind on purpose:
+	require.Equal(t, Unknown, Of(fmt.Errorf("cat: %v", err)))
+	require.Nil(t, Wrap(Offline, nil))
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for kind := range kindToString {
+		msg := Encode(kind, "something went wrong")
+		decKind, decMsg := Decode(msg)
+		require.Equal(t, kind, decKind)
+		require.Equal(t, "something went wrong", decMsg)
+	}
+
+	require.Equal(t, "as is", Encode(Unknown, "as is"))
+
+	kind, msg := Decode("[whatever] stays")
+	require.Equal(t, Unknown, kind)
+	require.Equal(t, "[whatever] stays", msg)
+
+	// Only the start of the message counts:
+	kind, msg = Decode("cat: [offline] not really")
+	require.Equal(t, Unknown, kind)
+	require.Equal(t, "cat: [offline] not really", msg)
+}
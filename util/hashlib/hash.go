@@ -10,6 +10,7 @@ import (
 	goipfsutil "github.com/ipfs/go-ipfs-util"
 	"github.com/multiformats/go-multihash"
 	"golang.org/x/crypto/blake2s"
+	"lukechampine.com/blake3"
 )
 
 const (
@@ -17,6 +18,16 @@ const (
 	// This is a weird convention by multihash apparently.
 	internalHashAlgo   = multihash.BLAKE2S_MIN + 4
 	internalHashLength = 32
+
+	// blake3Code is the multihash code of blake3.
+	// It is not known to the multihash version we use yet.
+	blake3Code = 0x1e
+
+	// AlgoBlake2s is the algorithm brig always used for content hashes.
+	AlgoBlake2s = "blake2s-256"
+
+	// AlgoBlake3 is a lot faster than AlgoBlake2s on large files.
+	AlgoBlake3 = "blake3"
 )
 
 var (
@@ -28,6 +39,10 @@ var (
 )
 
 func init() {
+	multihash.Codes[blake3Code] = AlgoBlake3
+	multihash.Names[AlgoBlake3] = blake3Code
+	multihash.DefaultLengths[blake3Code] = internalHashLength
+
 	data := make([]byte, multihash.DefaultLengths[goipfsutil.DefaultIpfsHash])
 	hash, err := multihash.Encode(data, goipfsutil.DefaultIpfsHash)
 	if err != nil {
@@ -140,6 +155,30 @@ func (h Hash) Equal(other Hash) bool {
 	return bytes.Equal(h, other)
 }
 
+// Algorithm returns the name of the algorithm `h` was made with
+// (e.g. AlgoBlake2s or AlgoBlake3) or an empty string if unknown.
+func (h Hash) Algorithm() string {
+	decoded, err := multihash.Decode(h)
+	if err != nil {
+		return ""
+	}
+
+	switch decoded.Code {
+	case internalHashAlgo:
+		// For historic reasons blake2s-256 is stored with a blake2s-40 code.
+		return AlgoBlake2s
+	case blake3Code:
+		return AlgoBlake3
+	default:
+		return ""
+	}
+}
+
+// IsValidAlgorithm returns true if `algo` can be used for NewHashWriterWithAlgo.
+func IsValidAlgorithm(algo string) bool {
+	return algo == AlgoBlake2s || algo == AlgoBlake3
+}
+
 // Mix produces a hash of both passed hashes.
 func (h Hash) Mix(o Hash) Hash {
 	buf := make([]byte, len(h)+len(o))
@@ -207,19 +246,34 @@ func TestDummy(t *testing.T, seed byte) Hash {
 // HashWriter is a io.Writer that supports being written to.
 type HashWriter struct {
 	hash hash.Hash
+	code uint64
 }
 
 // NewHashWriter returns a new HashWriter.
-// Currently it is always sha3-256.
+// Currently it is always blake2s-256.
 func NewHashWriter() *HashWriter {
 	b, _ := blake2s.New256(nil)
-	return &HashWriter{hash: b}
+	return &HashWriter{hash: b, code: internalHashAlgo}
+}
+
+// NewHashWriterWithAlgo returns a new HashWriter using `algo`.
+// The algorithm is part of the resulting hash, so hashes
+// of different algorithms can be used side by side.
+func NewHashWriterWithAlgo(algo string) (*HashWriter, error) {
+	switch algo {
+	case AlgoBlake2s:
+		return NewHashWriter(), nil
+	case AlgoBlake3:
+		return &HashWriter{hash: blake3.New(internalHashLength, nil), code: blake3Code}, nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm: %s", algo)
+	}
 }
 
 // Finalize returns the final hash of the written data.
 func (hw *HashWriter) Finalize() Hash {
 	sum := hw.hash.Sum(nil)
-	hash, err := multihash.Encode(sum, internalHashAlgo)
+	hash, err := multihash.Encode(sum, hw.code)
 	if err != nil {
 		// If this does not work, there's something serious wrong.
 		panic(fmt.Sprintf("failed to encode final hash: %v", err))
@@ -22,3 +22,45 @@ func TestHashWriter(t *testing.T) {
 		t.Fatalf("hashes differ due to different feed order")
 	}
 }
+
+func TestHashWriterAlgorithms(t *testing.T) {
+	data := []byte{1, 2, 3, 4}
+
+	hashes := []Hash{}
+	for _, algo := range []string{AlgoBlake2s, AlgoBlake3} {
+		hw, err := NewHashWriterWithAlgo(algo)
+		if err != nil {
+			t.Fatalf("failed to create writer for %s: %v", algo, err)
+		}
+
+		hw.Write(data)
+		hash := hw.Finalize()
+		if hash.Algorithm() != algo {
+			t.Fatalf("hash has wrong algorithm: %s != %s", hash.Algorithm(), algo)
+		}
+
+		// The algorithm has to survive a round trip through b58:
+		decoded, err := FromB58String(hash.B58String())
+		if err != nil {
+			t.Fatalf("failed to decode %s hash: %v", algo, err)
+		}
+
+		if !decoded.Equal(hash) || decoded.Algorithm() != algo {
+			t.Fatalf("%s hash changed after decoding", algo)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	if hashes[0].Equal(hashes[1]) {
+		t.Fatalf("hashes of different algorithms should differ")
+	}
+
+	if NewHashWriter().Finalize().Algorithm() != AlgoBlake2s {
+		t.Fatalf("default algorithm changed")
+	}
+
+	if _, err := NewHashWriterWithAlgo("md5"); err == nil {
+		t.Fatalf("unknown algorithm was accepted")
+	}
+}
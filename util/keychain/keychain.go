@@ -0,0 +1,79 @@
+// Package keychain stores secrets in the keychain of the operating system.
+//
+// It is used to keep the repository password around, so the daemon can
+// start unattended (e.g. on boot) without asking for it. Like in package
+// notify, the actual mechanism is pluggable: on Linux the freedesktop
+// Secret Service is used, on macOS the login keychain. Other platforms
+// have no native implementation yet.
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Service is the name the secrets are filed under.
+const Service = "brig"
+
+// ErrNotFound is returned by Load when there is no secret for an account.
+var ErrNotFound = errors.New("no such secret in keychain")
+
+// Provider is a keychain that can store secrets per account.
+type Provider interface {
+	// Store saves `secret` for `account`, replacing any previous one.
+	// `label` is shown to the user by keychain managers.
+	Store(account, label, secret string) error
+
+	// Load returns the secret of `account` or ErrNotFound.
+	Load(account string) (string, error)
+
+	// Delete removes the secret of `account`.
+	// It is not an error if there was none.
+	Delete(account string) error
+}
+
+// Factory creates a provider.
+type Factory func() (Provider, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a new provider available under `name`.
+// It overwrites any existing provider with the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	factories[name] = factory
+}
+
+// Names returns the names of all registered providers.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := []string{}
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// New returns the provider registered under `name`.
+func New(name string) (Provider, error) {
+	mu.Lock()
+	factory, ok := factories[name]
+	mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no such keychain provider on this platform: %s", name)
+	}
+
+	return factory()
+}
@@ -0,0 +1,74 @@
+// +build darwin
+
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultProvider is the provider used when none was given.
+const DefaultProvider = "macos-keychain"
+
+func init() {
+	Register("macos-keychain", newMacOSKeychain)
+}
+
+// macOSKeychain stores secrets as generic passwords in the login keychain
+// by using the security program that comes with every macOS.
+type macOSKeychain struct {
+	tool string
+}
+
+func newMacOSKeychain() (Provider, error) {
+	tool, err := exec.LookPath("security")
+	if err != nil {
+		return nil, fmt.Errorf("the macOS keychain needs `security`: %v", err)
+	}
+
+	return &macOSKeychain{tool: tool}, nil
+}
+
+func (mk *macOSKeychain) Store(account, label, secret string) error {
+	// -U updates an existing item instead of failing.
+	// NOTE: security only takes the secret as argument.
+	cmd := exec.Command(
+		mk.tool, "add-generic-password", "-U",
+		"-s", Service, "-a", account, "-l", label, "-w", secret,
+	) // #nosec
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("security add-generic-password failed: %v: %s", err, out)
+	}
+
+	return nil
+}
+
+func (mk *macOSKeychain) Load(account string) (string, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(mk.tool, "find-generic-password", "-s", Service, "-a", account, "-w") // #nosec
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "could not be found") {
+			return "", ErrNotFound
+		}
+
+		return "", fmt.Errorf("security find-generic-password failed: %v: %s", err, stderr.Bytes())
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (mk *macOSKeychain) Delete(account string) error {
+	cmd := exec.Command(mk.tool, "delete-generic-password", "-s", Service, "-a", account) // #nosec
+	out, err := cmd.CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "could not be found") {
+		return fmt.Errorf("security delete-generic-password failed: %v: %s", err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// +build linux
+
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultProvider is the provider used when none was given.
+const DefaultProvider = "secret-service"
+
+func init() {
+	Register("secret-service", newSecretService)
+}
+
+// secretService talks to the freedesktop Secret Service (GNOME Keyring,
+// KWallet, KeePassXC, ...). It uses the secret-tool program of libsecret,
+// instead of implementing the D-Bus wire protocol ourselves.
+type secretService struct {
+	tool string
+}
+
+func newSecretService() (Provider, error) {
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, fmt.Errorf("the secret service needs `secret-tool` (libsecret): %v", err)
+	}
+
+	return &secretService{tool: tool}, nil
+}
+
+func (ss *secretService) attributes(account string) []string {
+	return []string{"service", Service, "account", account}
+}
+
+func (ss *secretService) Store(account, label, secret string) error {
+	args := append([]string{"store", "--label", label}, ss.attributes(account)...)
+	cmd := exec.Command(ss.tool, args...) // #nosec
+
+	// Pass the secret via stdin, so it does not show up in the process list.
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %v: %s", err, out)
+	}
+
+	return nil
+}
+
+func (ss *secretService) Load(account string) (string, error) {
+	args := append([]string{"lookup"}, ss.attributes(account)...)
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(ss.tool, args...) // #nosec
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits with 1 and says nothing if there is no such secret.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+
+		return "", fmt.Errorf("secret-tool lookup failed: %v: %s", err, stderr.Bytes())
+	}
+
+	return string(out), nil
+}
+
+func (ss *secretService) Delete(account string) error {
+	args := append([]string{"clear"}, ss.attributes(account)...)
+	cmd := exec.Command(ss.tool, args...) // #nosec
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool clear failed: %v: %s", err, out)
+	}
+
+	return nil
+}
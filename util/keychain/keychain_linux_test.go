@@ -0,0 +1,49 @@
+// +build linux
+
+package keychain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretTool mimics secret-tool by storing every secret
+// in a file named after the last attribute (the account).
+const fakeSecretTool = `#!/bin/sh
+op="$1"; shift
+for last; do :; done
+case "$op" in
+	store) cat > "$DIR/$last" ;;
+	lookup) [ -f "$DIR/$last" ] || exit 1; cat "$DIR/$last" ;;
+	clear) rm -f "$DIR/$last" ;;
+esac
+`
+
+func TestSecretService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-keychain-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	toolPath := filepath.Join(dir, "secret-tool")
+	require.Nil(t, ioutil.WriteFile(toolPath, []byte(fakeSecretTool), 0700))
+	require.Nil(t, os.Setenv("DIR", dir))
+	defer os.Unsetenv("DIR")
+
+	ss := &secretService{tool: toolPath}
+
+	_, err = ss.Load("repo-id")
+	require.Equal(t, ErrNotFound, err)
+
+	require.Nil(t, ss.Store("repo-id", "brig repository", "pass word\n"))
+	secret, err := ss.Load("repo-id")
+	require.Nil(t, err)
+	require.Equal(t, "pass word\n", secret)
+
+	require.Nil(t, ss.Delete("repo-id"))
+	_, err = ss.Load("repo-id")
+	require.Equal(t, ErrNotFound, err)
+}
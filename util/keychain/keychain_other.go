@@ -0,0 +1,7 @@
+// +build !linux,!darwin
+
+package keychain
+
+// DefaultProvider is the provider used when none was given.
+// There is no native implementation for this platform yet.
+const DefaultProvider = ""
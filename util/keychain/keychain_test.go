@@ -0,0 +1,50 @@
+package keychain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryProvider struct {
+	secrets map[string]string
+}
+
+func (mp *memoryProvider) Store(account, label, secret string) error {
+	mp.secrets[account] = secret
+	return nil
+}
+
+func (mp *memoryProvider) Load(account string) (string, error) {
+	secret, ok := mp.secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return secret, nil
+}
+
+func (mp *memoryProvider) Delete(account string) error {
+	delete(mp.secrets, account)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	mp := &memoryProvider{secrets: make(map[string]string)}
+	Register("memory", func() (Provider, error) {
+		return mp, nil
+	})
+
+	require.Contains(t, Names(), "memory")
+
+	prov, err := New("memory")
+	require.Nil(t, err)
+	require.Nil(t, prov.Store("repo-id", "brig", "secret"))
+
+	secret, err := prov.Load("repo-id")
+	require.Nil(t, err)
+	require.Equal(t, "secret", secret)
+
+	_, err = New("no-such-provider")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+// Package notify shows desktop notifications.
+//
+// The daemon uses it to tell the user about things that happened in the
+// background, like finished syncs or new conflicts. The actual mechanism
+// is pluggable: on Linux notifications go over D-Bus by default, but they
+// can also be handed to an arbitrary command or just be logged.
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Notification is a single message for the user.
+type Notification struct {
+	// Title is a short summary.
+	Title string
+	// Body gives more details. May be empty.
+	Body string
+}
+
+// Notifier is something that can show notifications.
+type Notifier interface {
+	Notify(nt Notification) error
+}
+
+// Factory creates a notifier; `command` is only used by notifiers that
+// run an external program.
+type Factory func(command string) (Notifier, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{
+		"log":     newLogNotifier,
+		"command": newCommandNotifier,
+	}
+)
+
+// Register makes a new notifier available under `name`.
+// It overwrites any existing notifier with the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	factories[name] = factory
+}
+
+// Names returns the names of all registered notifiers.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := []string{}
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// New returns the notifier registered under `name`.
+func New(name, command string) (Notifier, error) {
+	mu.Lock()
+	factory, ok := factories[name]
+	mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no such notifier: %s", name)
+	}
+
+	return factory(command)
+}
+
+/////////
+
+type logNotifier struct{}
+
+func newLogNotifier(command string) (Notifier, error) {
+	return logNotifier{}, nil
+}
+
+func (ln logNotifier) Notify(nt Notification) error {
+	log.Infof("notification: %s: %s", nt.Title, nt.Body)
+	return nil
+}
+
+/////////
+
+// commandNotifier runs a command for every notification.
+// Title and body are passed as environment variables,
+// so they need no escaping.
+type commandNotifier struct {
+	command string
+}
+
+func newCommandNotifier(command string) (Notifier, error) {
+	if command == "" {
+		return nil, fmt.Errorf("notification command is empty")
+	}
+
+	return &commandNotifier{command: command}, nil
+}
+
+func (cn *commandNotifier) Notify(nt Notification) error {
+	cmd := exec.Command("/bin/sh", "-c", cn.command)
+	cmd.Env = append(
+		os.Environ(),
+		"BRIG_NOTIFY_TITLE="+nt.Title,
+		"BRIG_NOTIFY_BODY="+nt.Body,
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification command failed: %v: %s", err, out)
+	}
+
+	return nil
+}
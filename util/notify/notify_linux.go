@@ -0,0 +1,49 @@
+// +build linux
+
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// DefaultNotifier is the notifier used when none was configured.
+const DefaultNotifier = "dbus"
+
+func init() {
+	Register("dbus", newDBusNotifier)
+}
+
+// dbusNotifier talks to org.freedesktop.Notifications on the session bus.
+// It uses the gdbus tool that ships with every glib based desktop,
+// instead of implementing the D-Bus wire protocol ourselves.
+type dbusNotifier struct {
+	gdbus string
+}
+
+func newDBusNotifier(command string) (Notifier, error) {
+	gdbus, err := exec.LookPath("gdbus")
+	if err != nil {
+		return nil, fmt.Errorf("dbus notifications need `gdbus`: %v", err)
+	}
+
+	return &dbusNotifier{gdbus: gdbus}, nil
+}
+
+func (dn *dbusNotifier) Notify(nt Notification) error {
+	// Signature: Notify(app_name, replaces_id, app_icon, summary,
+	//                   body, actions, hints, expire_timeout)
+	cmd := exec.Command(
+		dn.gdbus, "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"brig", "0", "", nt.Title, nt.Body, "[]", "{}", "-1",
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("dbus notification failed: %v: %s", err, out)
+	}
+
+	return nil
+}
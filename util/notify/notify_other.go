@@ -0,0 +1,7 @@
+// +build !linux
+
+package notify
+
+// DefaultNotifier is the notifier used when none was configured.
+// There is no native implementation for this platform yet.
+const DefaultNotifier = "log"
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	seen []Notification
+}
+
+func (rn *recordingNotifier) Notify(nt Notification) error {
+	rn.seen = append(rn.seen, nt)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	rn := &recordingNotifier{}
+	Register("recording", func(command string) (Notifier, error) {
+		return rn, nil
+	})
+
+	require.Contains(t, Names(), "recording")
+	require.Contains(t, Names(), DefaultNotifier)
+
+	ntf, err := New("recording", "")
+	require.Nil(t, err)
+	require.Nil(t, ntf.Notify(Notification{Title: "hello"}))
+	require.Equal(t, []Notification{{Title: "hello"}}, rn.seen)
+
+	_, err = New("no-such-notifier", "")
+	require.NotNil(t, err)
+}
+
+func TestCommandNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-notify-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = New("command", "")
+	require.NotNil(t, err)
+
+	outPath := filepath.Join(dir, "out")
+	ntf, err := New("command", `echo "$BRIG_NOTIFY_TITLE|$BRIG_NOTIFY_BODY" > `+outPath)
+	require.Nil(t, err)
+	require.Nil(t, ntf.Notify(Notification{Title: "sync done", Body: "with 'bob'"}))
+
+	data, err := ioutil.ReadFile(outPath)
+	require.Nil(t, err)
+	require.Equal(t, "sync done|with 'bob'\n", string(data))
+}
@@ -0,0 +1,152 @@
+// Package profile captures profiles and execution traces of the running
+// process, so users can attach them to performance bug reports without
+// having to know about net/http/pprof.
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxDuration is the longest a single capture may run.
+const MaxDuration = 5 * time.Minute
+
+var (
+	// ErrBusy is returned when another capture is running.
+	// The runtime can only do one cpu profile or trace at a time.
+	ErrBusy = errors.New("another profile is being captured right now")
+
+	// ErrBadDuration is returned for durations outside (0, MaxDuration].
+	ErrBadDuration = fmt.Errorf("duration must be between 1s and %s", MaxDuration)
+)
+
+// Kind describes how a certain profile is captured.
+type Kind struct {
+	// Name is what the user asks for, like "cpu".
+	Name string
+	// Timed kinds record for a while; others are a snapshot.
+	Timed bool
+	// Ext is the file extension used for the result.
+	Ext string
+	// Tool is the command used to look at the result.
+	Tool string
+}
+
+var kinds = map[string]Kind{
+	"cpu":          {Name: "cpu", Timed: true, Ext: "pprof", Tool: "go tool pprof"},
+	"trace":        {Name: "trace", Timed: true, Ext: "trace", Tool: "go tool trace"},
+	"block":        {Name: "block", Timed: true, Ext: "pprof", Tool: "go tool pprof"},
+	"mutex":        {Name: "mutex", Timed: true, Ext: "pprof", Tool: "go tool pprof"},
+	"heap":         {Name: "heap", Ext: "pprof", Tool: "go tool pprof"},
+	"allocs":       {Name: "allocs", Ext: "pprof", Tool: "go tool pprof"},
+	"goroutine":    {Name: "goroutine", Ext: "pprof", Tool: "go tool pprof"},
+	"threadcreate": {Name: "threadcreate", Ext: "pprof", Tool: "go tool pprof"},
+}
+
+// KindByName returns the kind called `name`.
+func KindByName(name string) (Kind, error) {
+	kind, ok := kinds[name]
+	if !ok {
+		return Kind{}, fmt.Errorf("unknown profile: %s (one of %v)", name, Names())
+	}
+
+	return kind, nil
+}
+
+// Names returns the names of all kinds, sorted.
+func Names() []string {
+	names := []string{}
+	for name := range kinds {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+var captureMu sync.Mutex
+
+// wait blocks for `duration` or until `ctx` is done.
+func wait(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Capture records the profile called `name` and returns it in the format
+// of the respective go tool. Timed profiles run for `duration`, which is
+// ignored for snapshots. Only one capture may run at a time.
+func Capture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	kind, err := KindByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind.Timed && (duration < time.Second || duration > MaxDuration) {
+		return nil, ErrBadDuration
+	}
+
+	// Do not queue up; profiles taken in parallel would disturb each other.
+	if !captureMu.TryLock() {
+		return nil, ErrBusy
+	}
+
+	defer captureMu.Unlock()
+
+	buf := &bytes.Buffer{}
+	switch kind.Name {
+	case "cpu":
+		if err := pprof.StartCPUProfile(buf); err != nil {
+			// Might be running already via the pprof server.
+			return nil, ErrBusy
+		}
+
+		err = wait(ctx, duration)
+		pprof.StopCPUProfile()
+	case "trace":
+		if err := trace.Start(buf); err != nil {
+			return nil, ErrBusy
+		}
+
+		err = wait(ctx, duration)
+		trace.Stop()
+	case "block":
+		// The rates are off by default, since they cost performance.
+		runtime.SetBlockProfileRate(1)
+		err = wait(ctx, duration)
+		runtime.SetBlockProfileRate(0)
+	case "mutex":
+		old := runtime.SetMutexProfileFraction(1)
+		err = wait(ctx, duration)
+		runtime.SetMutexProfileFraction(old)
+	case "heap", "allocs":
+		// Make the numbers include everything up to now:
+		runtime.GC()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if kind.Name != "cpu" && kind.Name != "trace" {
+		if err := pprof.Lookup(kind.Name).WriteTo(buf, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
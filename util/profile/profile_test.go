@@ -0,0 +1,61 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureSnapshot(t *testing.T) {
+	data, err := Capture(context.Background(), "goroutine", 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, data)
+
+	// pprof files are gzip compressed protobufs:
+	require.True(t, bytes.HasPrefix(data, []byte{0x1f, 0x8b}))
+}
+
+func TestCaptureTimed(t *testing.T) {
+	data, err := Capture(context.Background(), "cpu", time.Second)
+	require.Nil(t, err)
+	require.NotEmpty(t, data)
+
+	_, err = Capture(context.Background(), "cpu", 0)
+	require.Equal(t, ErrBadDuration, err)
+
+	_, err = Capture(context.Background(), "trace", time.Hour)
+	require.Equal(t, ErrBadDuration, err)
+}
+
+func TestCaptureBusyAndCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := Capture(ctx, "block", time.Minute)
+		done <- err
+	}()
+
+	// Wait until the first capture holds the lock:
+	for captureMu.TryLock() {
+		captureMu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := Capture(context.Background(), "heap", 0)
+	require.Equal(t, ErrBusy, err)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+}
+
+func TestKindByName(t *testing.T) {
+	kind, err := KindByName("trace")
+	require.Nil(t, err)
+	require.Equal(t, "go tool trace", kind.Tool)
+
+	_, err = KindByName("nope")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	authPrefix  = "BRIG-AUTH "
+	authOk      = "OK"
+	authMaxLine = 4096
+	authTimeout = 5 * time.Second
+)
+
+var (
+	// ErrAuthFailed is returned when the other side did not accept our secret.
+	ErrAuthFailed = errors.New("authentication with the daemon failed")
+)
+
+// ClientHandshake sends `secret` over `conn` and waits for the server to
+// accept it. It has to be called before anything else is sent over `conn`.
+func ClientHandshake(conn net.Conn, secret string) error {
+	if err := conn.SetDeadline(time.Now().Add(authTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write([]byte(authPrefix + secret + "\n")); err != nil {
+		return err
+	}
+
+	line, err := readAuthLine(conn)
+	if err != nil {
+		return err
+	}
+
+	if line != authOk {
+		return ErrAuthFailed
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// ServerHandshake is the counterpart of ClientHandshake.
+// It returns nil if the client sent the same `secret`.
+func ServerHandshake(conn net.Conn, secret string) error {
+	if err := conn.SetDeadline(time.Now().Add(authTimeout)); err != nil {
+		return err
+	}
+
+	line, err := readAuthLine(conn)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(line, authPrefix) {
+		return ErrAuthFailed
+	}
+
+	sent := []byte(strings.TrimPrefix(line, authPrefix))
+	if subtle.ConstantTimeCompare(sent, []byte(secret)) != 1 {
+		return ErrAuthFailed
+	}
+
+	if _, err := conn.Write([]byte(authOk + "\n")); err != nil {
+		return err
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// readAuthLine reads a single line byte by byte,
+// so nothing that follows the handshake is consumed.
+func readAuthLine(conn net.Conn) (string, error) {
+	buf := make([]byte, 1)
+	line := []byte{}
+	for len(line) < authMaxLine {
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return "", err
+		}
+
+		if buf[0] == '\n' {
+			return string(line), nil
+		}
+
+		line = append(line, buf[0])
+	}
+
+	return "", errors.New("auth line is too long")
+}
+
+// AuthListener only hands out connections that pass ServerHandshake.
+// The handshake is done on the first Read or Write of a connection, so
+// a client that connects and sends nothing only blocks itself, not the
+// connections accepted after it. A failed handshake closes the connection.
+type AuthListener struct {
+	net.Listener
+
+	Secret string
+}
+
+// Accept waits for the next connection.
+func (al *AuthListener) Accept() (net.Conn, error) {
+	conn, err := al.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &authConn{Conn: conn, secret: al.Secret}, nil
+}
+
+type authConn struct {
+	net.Conn
+
+	secret string
+	once   sync.Once
+	err    error
+}
+
+func (ac *authConn) handshake() error {
+	ac.once.Do(func() {
+		if ac.err = ServerHandshake(ac.Conn, ac.secret); ac.err != nil {
+			log.Warningf("rejecting connection from %s: %v", ac.RemoteAddr(), ac.err)
+			ac.Conn.Close()
+		}
+	})
+
+	return ac.err
+}
+
+func (ac *authConn) Read(buf []byte) (int, error) {
+	if err := ac.handshake(); err != nil {
+		return 0, err
+	}
+
+	return ac.Conn.Read(buf)
+}
+
+func (ac *authConn) Write(buf []byte) (int, error) {
+	if err := ac.handshake(); err != nil {
+		return 0, err
+	}
+
+	return ac.Conn.Write(buf)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dialAndHandshake(t *testing.T, network, addr, secret string) (net.Conn, error) {
+	conn, err := net.Dial(network, addr)
+	require.Nil(t, err)
+
+	if err := ClientHandshake(conn, secret); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func TestAuthListener(t *testing.T) {
+	tcpLst, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+
+	lst := &AuthListener{Listener: tcpLst, Secret: "s3cret"}
+	defer lst.Close()
+
+	received := make(chan string, 3)
+	go func() {
+		for {
+			conn, err := lst.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				buf := make([]byte, 5)
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					received <- "error"
+					return
+				}
+
+				received <- string(buf)
+			}()
+		}
+	}()
+
+	// A client that connects but sends nothing should not block others:
+	idle, err := net.Dial("tcp", tcpLst.Addr().String())
+	require.Nil(t, err)
+	defer idle.Close()
+
+	_, err = dialAndHandshake(t, "tcp", tcpLst.Addr().String(), "wrong")
+	require.NotNil(t, err)
+	require.Equal(t, "error", <-received)
+
+	start := time.Now()
+	conn, err := dialAndHandshake(t, "tcp", tcpLst.Addr().String(), "s3cret")
+	require.Nil(t, err)
+	defer conn.Close()
+	require.True(t, time.Since(start) < authTimeout)
+
+	// Data after the handshake should arrive untouched:
+	_, err = conn.Write([]byte("hello"))
+	require.Nil(t, err)
+	require.Equal(t, "hello", <-received)
+}
+
+func TestMultiListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-multi-lst")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tcpLst, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+
+	sockPath := filepath.Join(dir, "sock")
+	unixLst, err := net.Listen("unix", sockPath)
+	require.Nil(t, err)
+
+	lst := NewMultiListener(tcpLst, unixLst)
+
+	// Nothing is dialing yet, so we should run into the deadline:
+	require.Nil(t, lst.SetDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = lst.Accept()
+	require.NotNil(t, err)
+	require.True(t, err.(timeoutErr).Timeout())
+	require.Nil(t, lst.SetDeadline(time.Time{}))
+
+	for _, addr := range []net.Addr{tcpLst.Addr(), unixLst.Addr()} {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		require.Nil(t, err)
+
+		srvConn, err := lst.Accept()
+		require.Nil(t, err)
+		require.Equal(t, addr.Network(), srvConn.LocalAddr().Network())
+
+		conn.Close()
+		srvConn.Close()
+	}
+
+	require.Nil(t, lst.Close())
+	_, err = lst.Accept()
+	require.Equal(t, net.ErrClosed, err)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type acceptTimeoutErr struct{}
+
+func (acceptTimeoutErr) Error() string   { return "accept timed out" }
+func (acceptTimeoutErr) Timeout() bool   { return true }
+func (acceptTimeoutErr) Temporary() bool { return true }
+
+// MultiListener accepts connections from several listeners at once,
+// so a single Server can serve e.g. a tcp port and a unix socket.
+type MultiListener struct {
+	lsts    []net.Listener
+	connCh  chan net.Conn
+	closeCh chan struct{}
+	once    sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// NewMultiListener starts accepting on all of `lsts`.
+func NewMultiListener(lsts ...net.Listener) *MultiListener {
+	ml := &MultiListener{
+		lsts:    lsts,
+		connCh:  make(chan net.Conn),
+		closeCh: make(chan struct{}),
+	}
+
+	for _, lst := range lsts {
+		go ml.acceptLoop(lst)
+	}
+
+	return ml
+}
+
+func (ml *MultiListener) acceptLoop(lst net.Listener) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			select {
+			case <-ml.closeCh:
+				return
+			default:
+			}
+
+			log.Warningf("stopped accepting on %s: %v", lst.Addr(), err)
+			return
+		}
+
+		select {
+		case ml.connCh <- conn:
+		case <-ml.closeCh:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Accept returns the next connection of any of the listeners.
+func (ml *MultiListener) Accept() (net.Conn, error) {
+	ml.mu.Lock()
+	deadline := ml.deadline
+	ml.mu.Unlock()
+
+	var timeoutCh <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case conn := <-ml.connCh:
+		return conn, nil
+	case <-ml.closeCh:
+		return nil, net.ErrClosed
+	case <-timeoutCh:
+		return nil, acceptTimeoutErr{}
+	}
+}
+
+// SetDeadline implements DeadlineListener.
+func (ml *MultiListener) SetDeadline(deadline time.Time) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.deadline = deadline
+	return nil
+}
+
+// Close closes all listeners.
+func (ml *MultiListener) Close() error {
+	var firstErr error
+	ml.once.Do(func() {
+		close(ml.closeCh)
+		for _, lst := range ml.lsts {
+			if err := lst.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+
+	return firstErr
+}
+
+// Addr returns the address of the first listener.
+func (ml *MultiListener) Addr() net.Addr {
+	return ml.lsts[0].Addr()
+}
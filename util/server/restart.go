@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	e "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A restarted process gets the state of its parent as fd 3
+// and the inherited listeners starting at fd 4.
+const (
+	envRestartFDs    = "BRIG_RESTART_FDS"
+	envRestartParent = "BRIG_RESTART_PARENT"
+	firstRestartFD   = 3
+)
+
+// filer is implemented by all listeners that can be passed to a child.
+type filer interface {
+	File() (*os.File, error)
+}
+
+var (
+	// listeners opened by Listen, so Restart can pass them on.
+	listenersMu sync.Mutex
+	listeners   []net.Listener
+
+	inheritOnce sync.Once
+	inherited   []net.Listener
+	inheritedSt []byte
+)
+
+func inherit() {
+	nfds, err := strconv.Atoi(os.Getenv(envRestartFDs))
+	if err != nil || nfds <= 0 {
+		inherited = activatedListeners()
+		return
+	}
+
+	// Our own children should not think they were restarted:
+	os.Unsetenv(envRestartFDs)
+
+	stateFd := os.NewFile(uintptr(firstRestartFD), "restart-state")
+	inheritedSt, err = ioutil.ReadAll(stateFd)
+	if err != nil {
+		log.Warningf("failed to read state of parent: %v", err)
+	}
+
+	stateFd.Close()
+
+	for idx := 0; idx < nfds; idx++ {
+		fd := os.NewFile(uintptr(firstRestartFD+1+idx), "restart-listener")
+		lst, err := net.FileListener(fd)
+		fd.Close()
+
+		if err != nil {
+			log.Warningf("failed to inherit listener %d: %v", idx, err)
+			continue
+		}
+
+		inherited = append(inherited, lst)
+	}
+}
+
+// IsRestarted returns true if this process was started by Restart.
+func IsRestarted() bool {
+	inheritOnce.Do(inherit)
+	return os.Getenv(envRestartParent) != ""
+}
+
+// InheritedState returns the state the parent passed to Restart.
+func InheritedState() []byte {
+	inheritOnce.Do(inherit)
+	return inheritedSt
+}
+
+// sameAddr checks if a listener on `have` can be used for `want`.
+// Hosts are not compared, since "localhost" and "127.0.0.1" are the same.
+func sameAddr(network, want string, have net.Addr) bool {
+	if have.Network() != network {
+		return false
+	}
+
+	if network == "unix" {
+		return want == have.String()
+	}
+
+	_, wantPort, err := net.SplitHostPort(want)
+	if err != nil {
+		return false
+	}
+
+	_, havePort, err := net.SplitHostPort(have.String())
+	return err == nil && wantPort == havePort && wantPort != "0"
+}
+
+// takeInherited returns the inherited listener for `addr` or nil.
+// Every listener is handed out only once.
+func takeInherited(network, addr string) net.Listener {
+	inheritOnce.Do(inherit)
+
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	for idx, lst := range inherited {
+		if sameAddr(network, addr, lst.Addr()) {
+			inherited = append(inherited[:idx], inherited[idx+1:]...)
+			return lst
+		}
+	}
+
+	return nil
+}
+
+// Listen works like net.Listen, but returns the listener of the parent
+// process if this one was started by Restart or the socket passed by
+// systemd if the address matches. TCP ports are opened with
+// SO_REUSEPORT where available, so a new process can bind them
+// while the old one is still draining. Unix sockets are created
+// accessible only by the current user.
+func Listen(network, addr string) (net.Listener, error) {
+	lst := takeInherited(network, addr)
+	if lst == nil {
+		// Unix sockets are created with the permissions of the umask.
+		// Changing them afterwards would leave a moment in which
+		// everyone could connect.
+		if network == "unix" {
+			defer restrictUmask()()
+		}
+
+		var err error
+		lc := net.ListenConfig{Control: reusePortControl}
+		if lst, err = lc.Listen(context.Background(), network, addr); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Infof("took over listener on %s", lst.Addr())
+	}
+
+	listenersMu.Lock()
+	listeners = append(listeners, lst)
+	listenersMu.Unlock()
+	return lst, nil
+}
+
+// Restart starts a new instance of this program with the same arguments,
+// which takes over all listeners opened via Listen. `state` is passed
+// to the new process (see InheritedState) without touching the disk.
+// The caller should stop accepting connections afterwards and quit once
+// all running requests are done.
+func Restart(state []byte) (*os.Process, error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	files := []*os.File{}
+	defer func() {
+		for _, fd := range files {
+			fd.Close()
+		}
+	}()
+
+	for _, lst := range listeners {
+		flr, ok := lst.(filer)
+		if !ok {
+			continue
+		}
+
+		// Closing the listener in the parent should not remove the socket:
+		if unixLst, ok := lst.(*net.UnixListener); ok {
+			unixLst.SetUnlinkOnClose(false)
+		}
+
+		fd, err := flr.File()
+		if err != nil {
+			// Probably closed already.
+			log.Debugf("not passing listener %s: %v", lst.Addr(), err)
+			continue
+		}
+
+		files = append(files, fd)
+	}
+
+	stateRead, stateWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	defer stateRead.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		stateWrite.Close()
+		return nil, err
+	}
+
+	// The watchdog belongs to the new process once it took over:
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envWatchdogPID+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	env = append(
+		env,
+		fmt.Sprintf("%s=%d", envRestartFDs, len(files)),
+		fmt.Sprintf("%s=%d", envRestartParent, os.Getpid()),
+	)
+
+	proc, err := os.StartProcess(exe, os.Args, &os.ProcAttr{
+		Env:   env,
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr, stateRead}, files...),
+	})
+
+	if err != nil {
+		stateWrite.Close()
+		return nil, e.Wrap(err, "failed to start new process")
+	}
+
+	notifyMainPID(proc.Pid)
+
+	// The pipe buffer might be too small for the state,
+	// so do not block until the child reads it.
+	go func() {
+		defer stateWrite.Close()
+		if _, err := stateWrite.Write(state); err != nil {
+			log.Warningf("failed to pass state to new process: %v", err)
+		}
+	}()
+
+	return proc, nil
+}
+
+// WaitForParent blocks until the process that called Restart exited.
+// It returns immediately if this process was not restarted.
+func WaitForParent(timeout time.Duration) error {
+	pid, err := strconv.Atoi(os.Getenv(envRestartParent))
+	if err != nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		// Once the parent exited we got reparented:
+		if os.Getppid() != pid {
+			return nil
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return fmt.Errorf("old process %d did not exit within %v", pid, timeout)
+}
+
+// isRestartSignal checks if `sig` asks for a graceful restart.
+func isRestartSignal(sig os.Signal) bool {
+	for _, restartSig := range restartSignals {
+		if sig == restartSig {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,19 @@
+// +build !linux,!darwin
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// Graceful restarts are not supported here.
+var restartSignals = []os.Signal{}
+
+func restrictUmask() func() {
+	return func() {}
+}
+
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameAddr(t *testing.T) {
+	tcpAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6666}
+	require.True(t, sameAddr("tcp", "localhost:6666", tcpAddr))
+	require.True(t, sameAddr("tcp", ":6666", tcpAddr))
+	require.False(t, sameAddr("tcp", "localhost:6667", tcpAddr))
+	require.False(t, sameAddr("unix", "localhost:6666", tcpAddr))
+
+	// Random ports can never be inherited:
+	require.False(t, sameAddr("tcp", ":0", &net.TCPAddr{Port: 0}))
+
+	unixAddr := &net.UnixAddr{Name: "/tmp/brig.sock", Net: "unix"}
+	require.True(t, sameAddr("unix", "/tmp/brig.sock", unixAddr))
+	require.False(t, sameAddr("unix", "/tmp/other.sock", unixAddr))
+}
+
+func TestListenReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT is only tested on linux")
+	}
+
+	lst1, err := Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer lst1.Close()
+
+	// A second process (like a restarted daemon) may bind the same port:
+	lst2, err := Listen("tcp", lst1.Addr().String())
+	require.Nil(t, err)
+	defer lst2.Close()
+
+	require.False(t, IsRestarted())
+	require.Nil(t, WaitForParent(0))
+}
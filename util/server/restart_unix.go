@@ -0,0 +1,40 @@
+// +build linux darwin
+
+package server
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// restartSignals are the signals that trigger a graceful restart.
+var restartSignals = []os.Signal{syscall.SIGUSR2}
+
+// restrictUmask makes files created until the returned function is called
+// accessible only by us. The umask is process wide, so this should only
+// be held for a short moment.
+func restrictUmask() func() {
+	old := unix.Umask(0077)
+	return func() {
+		unix.Umask(old)
+	}
+}
+
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil
+	}
+
+	var sockErr error
+	err := conn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return sockErr
+}
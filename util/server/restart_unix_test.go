@@ -0,0 +1,34 @@
+// +build linux darwin
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnixPrivate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brig-unix-lst")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	oldMask := syscall.Umask(0)
+	defer syscall.Umask(oldMask)
+
+	sockPath := filepath.Join(dir, "sock")
+	lst, err := Listen("unix", sockPath)
+	require.Nil(t, err)
+	defer lst.Close()
+
+	info, err := os.Stat(sockPath)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0), info.Mode().Perm()&0077)
+
+	// The umask of the process is restored afterwards:
+	require.Equal(t, 0, syscall.Umask(0))
+}
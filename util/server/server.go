@@ -35,6 +35,9 @@ type Server struct {
 	ctx     context.Context
 	handler Handler
 	quitCh  chan bool
+
+	restartState func() ([]byte, error)
+	drainTimeout time.Duration
 }
 
 // DeadlineListener is a listener that allows to set a deadline
@@ -89,11 +92,58 @@ func (sv *Server) Close() error {
 	return sv.lst.Close()
 }
 
+// EnableRestart makes Serve restart the program on SIGUSR2 without
+// dropping connections: A new process takes over the listeners (see
+// Restart) and gets the state returned by `stateFn`. The old one stops
+// accepting and waits up to `drainTimeout` for running requests before
+// it quits.
+func (sv *Server) EnableRestart(stateFn func() ([]byte, error), drainTimeout time.Duration) {
+	sv.restartState = stateFn
+	sv.drainTimeout = drainTimeout
+}
+
+func (sv *Server) restart() error {
+	state, err := sv.restartState()
+	if err != nil {
+		return err
+	}
+
+	proc, err := Restart(state)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("started new process with pid %d; draining connections", proc.Pid)
+	return proc.Release()
+}
+
+// drain waits until all running connections are done.
+func (sv *Server) drain(rateCh chan struct{}) {
+	timeout := time.NewTimer(sv.drainTimeout)
+	defer timeout.Stop()
+
+	// Every running connection holds one slot of rateCh:
+	for acquired := 0; acquired < cap(rateCh); acquired++ {
+		select {
+		case <-rateCh:
+		case <-timeout.C:
+			log.Warningf("%d connections still running after %v", cap(rateCh)-acquired, sv.drainTimeout)
+			return
+		}
+	}
+}
+
 // Serve blocks to serve requests to the client.
 // It can be stopped by calling Quit.
 func (sv *Server) Serve() error {
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	if sv.restartState != nil {
+		signals = append(signals, restartSignals...)
+	}
+
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signalCh, signals...)
+	defer signal.Stop(signalCh)
 
 	// Reserve a pool of connections:
 	rateCh := make(chan struct{}, maxConnections)
@@ -101,12 +151,22 @@ func (sv *Server) Serve() error {
 		rateCh <- struct{}{}
 	}
 
-	doServe := true
+	doServe, doDrain := true, false
 
 	for doServe {
 		select {
 		case sig := <-signalCh:
-			log.Warnf("Received %s signal, quitting.", sig)
+			if isRestartSignal(sig) {
+				if err := sv.restart(); err != nil {
+					log.Errorf("Failed to restart: %v", err)
+					break
+				}
+
+				doDrain = true
+			} else {
+				log.Warnf("Received %s signal, quitting.", sig)
+			}
+
 			doServe = false
 		case <-rateCh:
 			// If this signal can receive something, we have a free connection.
@@ -129,6 +189,14 @@ func (sv *Server) Serve() error {
 		}
 	}
 
+	// After a restart, the new process is the one systemd watches,
+	// so only tell it that we're stopping if we really quit.
+	if doDrain {
+		sv.drain(rateCh)
+	} else if err := Notify("STOPPING=1"); err != nil {
+		log.Warnf("failed to notify systemd: %v", err)
+	}
+
 	return sv.handler.Quit()
 }
 
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Environment variables set by systemd, see sd_listen_fds(3),
+// sd_notify(3) and sd_watchdog_enabled(3).
+const (
+	envListenPID    = "LISTEN_PID"
+	envListenFDs    = "LISTEN_FDS"
+	envNotifySocket = "NOTIFY_SOCKET"
+	envWatchdogUsec = "WATCHDOG_USEC"
+	envWatchdogPID  = "WATCHDOG_PID"
+	firstListenFD   = 3
+)
+
+// activatedListeners returns the sockets passed by systemd's socket
+// activation. They end up in the same pool as the listeners inherited
+// by Restart, so Listen will use them if the address matches.
+func activatedListeners() []net.Listener {
+	pid, err := strconv.Atoi(os.Getenv(envListenPID))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv(envListenFDs))
+	if err != nil || nfds <= 0 {
+		return nil
+	}
+
+	// Those are meant for us only, not for our children:
+	os.Unsetenv(envListenPID)
+	os.Unsetenv(envListenFDs)
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	lsts := []net.Listener{}
+	for idx := 0; idx < nfds; idx++ {
+		fd := os.NewFile(uintptr(firstListenFD+idx), "systemd-listener")
+		lst, err := net.FileListener(fd)
+		fd.Close()
+
+		if err != nil {
+			log.Warningf("failed to use socket %d from systemd: %v", idx, err)
+			continue
+		}
+
+		log.Infof("got socket %s from systemd", lst.Addr())
+		lsts = append(lsts, lst)
+	}
+
+	return lsts
+}
+
+// Notify sends `state` (e.g. "READY=1") to the service manager.
+// It does nothing if we were not started by systemd with Type=notify.
+func Notify(state string) error {
+	sockPath := os.Getenv(envNotifySocket)
+	if sockPath == "" {
+		return nil
+	}
+
+	// Abstract sockets are prefixed with a null byte:
+	if sockPath[0] == '@' {
+		sockPath = "\x00" + sockPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns how often systemd expects a watchdog ping
+// from this process or 0 if the watchdog is disabled.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv(envWatchdogUsec), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pidStr := os.Getenv(envWatchdogPID); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return 0
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog pings the systemd watchdog until `ctx` is done.
+// A ping is only sent if `check` returns no error, so systemd
+// restarts the daemon if it hangs instead of just being alive.
+// It does nothing if the watchdog is disabled.
+func StartWatchdog(ctx context.Context, check func() error) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	log.Infof("sending watchdog pings to systemd every %v", interval/2)
+
+	go func() {
+		// Ping twice per interval, as recommended by sd_watchdog_enabled(3):
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := check(); err != nil {
+					log.Warningf("not sending watchdog ping: %v", err)
+					continue
+				}
+
+				if err := Notify("WATCHDOG=1"); err != nil {
+					log.Warningf("failed to send watchdog ping: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// notifyMainPID tells systemd that `pid` is the main process now.
+// This needs NotifyAccess=all in the unit file.
+func notifyMainPID(pid int) {
+	if err := Notify(fmt.Sprintf("MAINPID=%d", pid)); err != nil {
+		log.Warningf("failed to tell systemd about new main pid: %v", err)
+	}
+}
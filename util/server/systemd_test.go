@@ -0,0 +1,68 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	// Not started by systemd, so nothing should happen:
+	os.Unsetenv(envNotifySocket)
+	require.Nil(t, Notify("READY=1"))
+
+	dir, err := ioutil.TempDir("", "brig-notify-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sockPath := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	os.Setenv(envNotifySocket, sockPath)
+	defer os.Unsetenv(envNotifySocket)
+
+	require.Nil(t, Notify("READY=1"))
+
+	buf := make([]byte, 128)
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, "READY=1", string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv(envWatchdogUsec)
+	defer os.Unsetenv(envWatchdogPID)
+
+	os.Unsetenv(envWatchdogUsec)
+	require.Equal(t, time.Duration(0), WatchdogInterval())
+
+	os.Setenv(envWatchdogUsec, "30000000")
+	require.Equal(t, 30*time.Second, WatchdogInterval())
+
+	// The watchdog is meant for another process:
+	os.Setenv(envWatchdogPID, strconv.Itoa(os.Getpid()+1))
+	require.Equal(t, time.Duration(0), WatchdogInterval())
+
+	os.Setenv(envWatchdogPID, strconv.Itoa(os.Getpid()))
+	require.Equal(t, 30*time.Second, WatchdogInterval())
+}
+
+func TestActivatedListenersOtherPid(t *testing.T) {
+	defer os.Unsetenv(envListenPID)
+	defer os.Unsetenv(envListenFDs)
+
+	// Sockets for another process must not be touched:
+	os.Setenv(envListenPID, strconv.Itoa(os.Getpid()+1))
+	os.Setenv(envListenFDs, "2")
+	require.Empty(t, activatedListeners())
+	require.Equal(t, "2", os.Getenv(envListenFDs))
+}
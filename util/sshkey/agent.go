@@ -0,0 +1,183 @@
+package sshkey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Message numbers of the ssh-agent protocol
+// (see draft-miller-ssh-agent, section 5.1).
+const (
+	agentFailure          = 5
+	agentRequestIdentites = 11
+	agentIdentitiesAnswer = 12
+	agentSignRequest      = 13
+	agentSignResponse     = 14
+
+	// maxAgentReply protects against a misbehaving agent.
+	maxAgentReply = 256 * 1024
+
+	agentTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNoAgent is returned when SSH_AUTH_SOCK is not set.
+	ErrNoAgent = errors.New("no ssh-agent is running (SSH_AUTH_SOCK is not set)")
+
+	// ErrKeyNotInAgent is returned when the agent does not hold the wanted key.
+	ErrKeyNotInAgent = errors.New("the ssh-agent does not hold a matching ed25519 key")
+)
+
+// AgentSigner signs via a running ssh-agent.
+// The private key never leaves the agent (or the hardware token behind it).
+type AgentSigner struct {
+	sockPath string
+	pubBlob  []byte
+}
+
+// agentRequest sends a single request to the agent and returns its reply.
+// A new connection is used for every request, so the agent may be
+// restarted in between.
+func agentRequest(sockPath string, req []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", sockPath, agentTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(agentTimeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(appendString(nil, req)); err != nil {
+		return nil, err
+	}
+
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, sizeBuf); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(sizeBuf)
+	if size == 0 || size > maxAgentReply {
+		return nil, fmt.Errorf("ssh-agent: bad reply size %d", size)
+	}
+
+	reply := make([]byte, size)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+
+	if reply[0] == agentFailure {
+		return nil, errors.New("ssh-agent: request failed")
+	}
+
+	return reply, nil
+}
+
+// AgentKeys returns the ed25519 keys held by the agent at `sockPath`.
+// Keys of other types are skipped.
+func AgentKeys(sockPath string) ([][]byte, error) {
+	reply, err := agentRequest(sockPath, []byte{agentRequestIdentites})
+	if err != nil {
+		return nil, err
+	}
+
+	if reply[0] != agentIdentitiesAnswer {
+		return nil, fmt.Errorf("ssh-agent: unexpected reply %d", reply[0])
+	}
+
+	nkeys, rest, err := readUint32(reply[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	keys := [][]byte{}
+	for idx := uint32(0); idx < nkeys; idx++ {
+		var blob []byte
+		if blob, rest, err = readString(rest); err != nil {
+			return nil, err
+		}
+
+		// Skip the comment:
+		if _, rest, err = readString(rest); err != nil {
+			return nil, err
+		}
+
+		if IsPubKey(blob) {
+			keys = append(keys, blob)
+		}
+	}
+
+	return keys, nil
+}
+
+// NewAgentSigner returns a signer that uses the key `pubBlob` of the agent
+// at SSH_AUTH_SOCK. If `pubBlob` is nil, the first ed25519 key is used.
+func NewAgentSigner(pubBlob []byte) (*AgentSigner, error) {
+	sockPath := os.Getenv("SSH_AUTH_SOCK")
+	if sockPath == "" {
+		return nil, ErrNoAgent
+	}
+
+	return NewAgentSignerAt(sockPath, pubBlob)
+}
+
+// NewAgentSignerAt is like NewAgentSigner, but uses the agent at `sockPath`.
+func NewAgentSignerAt(sockPath string, pubBlob []byte) (*AgentSigner, error) {
+	keys, err := AgentKeys(sockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if pubBlob == nil || bytes.Equal(key, pubBlob) {
+			return &AgentSigner{sockPath: sockPath, pubBlob: key}, nil
+		}
+	}
+
+	return nil, ErrKeyNotInAgent
+}
+
+// PubKey returns the public key of the signer in SSH wire format.
+func (as *AgentSigner) PubKey() []byte {
+	return as.pubBlob
+}
+
+// Sign asks the agent to sign `data`.
+// Depending on the agent, this might need a touch of the hardware token.
+func (as *AgentSigner) Sign(data []byte) ([]byte, error) {
+	req := []byte{agentSignRequest}
+	req = appendString(req, as.pubBlob)
+	req = appendString(req, data)
+	req = append(req, 0, 0, 0, 0) // flags
+
+	reply, err := agentRequest(as.sockPath, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply[0] != agentSignResponse {
+		return nil, fmt.Errorf("ssh-agent: unexpected reply %d", reply[0])
+	}
+
+	sig, _, err := readString(reply[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Do not trust the agent blindly; a wrong signature would only
+	// make the remote side fail with a much less helpful error.
+	if err := Verify(as.pubBlob, data, sig); err != nil {
+		return nil, fmt.Errorf("ssh-agent returned an invalid signature: %v", err)
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,156 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	opensshMagic   = "openssh-key-v1\x00"
+	opensshPEMType = "OPENSSH PRIVATE KEY"
+)
+
+// ErrEncryptedKey is returned for passphrase protected key files.
+var ErrEncryptedKey = errors.New("encrypted ssh keys are not supported; load it into ssh-agent instead")
+
+// FileSigner signs with an unencrypted ed25519 key from an OpenSSH key file.
+type FileSigner struct {
+	priv ed25519.PrivateKey
+}
+
+// ParsePrivateKey parses an unencrypted ed25519 key in the
+// "openssh-key-v1" format (what ssh-keygen writes by default).
+func ParsePrivateKey(data []byte) (*FileSigner, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != opensshPEMType {
+		return nil, errors.New("ssh: not an openssh private key")
+	}
+
+	buf := block.Bytes
+	if !bytes.HasPrefix(buf, []byte(opensshMagic)) {
+		return nil, errors.New("ssh: bad openssh key magic")
+	}
+
+	buf = buf[len(opensshMagic):]
+
+	var cipherName, kdfName, privSection []byte
+	var err error
+
+	if cipherName, buf, err = readString(buf); err != nil {
+		return nil, err
+	}
+
+	if kdfName, buf, err = readString(buf); err != nil {
+		return nil, err
+	}
+
+	if string(cipherName) != "none" || string(kdfName) != "none" {
+		return nil, ErrEncryptedKey
+	}
+
+	// kdf options (empty without kdf):
+	if _, buf, err = readString(buf); err != nil {
+		return nil, err
+	}
+
+	nkeys, buf, err := readUint32(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if nkeys != 1 {
+		return nil, fmt.Errorf("ssh: expected one key in file, got %d", nkeys)
+	}
+
+	// public key (repeated in the private section):
+	if _, buf, err = readString(buf); err != nil {
+		return nil, err
+	}
+
+	if privSection, _, err = readString(buf); err != nil {
+		return nil, err
+	}
+
+	check1, privSection, err := readUint32(privSection)
+	if err != nil {
+		return nil, err
+	}
+
+	check2, privSection, err := readUint32(privSection)
+	if err != nil {
+		return nil, err
+	}
+
+	if check1 != check2 {
+		return nil, errors.New("ssh: corrupt private key (check bytes differ)")
+	}
+
+	keyType, privSection, err := readString(privSection)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(keyType) != KeyTypeEd25519 {
+		return nil, ErrUnsupportedKey
+	}
+
+	pub, privSection, err := readString(privSection)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, _, err := readString(privSection)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pub) != ed25519.PublicKeySize || len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("ssh: bad ed25519 key size")
+	}
+
+	// The private key is seed + public key; both halves have to agree:
+	key := ed25519.PrivateKey(priv)
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), pub) {
+		return nil, errors.New("ssh: ed25519 private key does not match public key")
+	}
+
+	return &FileSigner{priv: key}, nil
+}
+
+// PubKey returns the public key of the signer in SSH wire format.
+func (fs *FileSigner) PubKey() []byte {
+	return MarshalPubKey(fs.priv.Public().(ed25519.PublicKey))
+}
+
+// Sign signs `data` with the private key.
+func (fs *FileSigner) Sign(data []byte) ([]byte, error) {
+	return marshalSignature(ed25519.Sign(fs.priv, data)), nil
+}
+
+// MarshalPrivateKey encodes `priv` as unencrypted OpenSSH key file.
+// It is the opposite of ParsePrivateKey.
+func MarshalPrivateKey(priv ed25519.PrivateKey, comment string) []byte {
+	pubBlob := MarshalPubKey(priv.Public().(ed25519.PublicKey))
+
+	privSection := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	privSection = appendString(privSection, []byte(KeyTypeEd25519))
+	privSection = appendString(privSection, priv.Public().(ed25519.PublicKey))
+	privSection = appendString(privSection, priv)
+	privSection = appendString(privSection, []byte(comment))
+	for pad := byte(1); len(privSection)%8 != 0; pad++ {
+		privSection = append(privSection, pad)
+	}
+
+	buf := []byte(opensshMagic)
+	buf = appendString(buf, []byte("none"))
+	buf = appendString(buf, []byte("none"))
+	buf = appendString(buf, nil)
+	buf = append(buf, 0, 0, 0, 1)
+	buf = appendString(buf, pubBlob)
+	buf = appendString(buf, privSection)
+
+	return pem.EncodeToMemory(&pem.Block{Type: opensshPEMType, Bytes: buf})
+}
@@ -0,0 +1,167 @@
+// Package sshkey implements the small subset of the SSH key formats and of
+// the ssh-agent protocol that is needed to use an ed25519 SSH key as brig
+// identity. Only ed25519 keys are supported.
+package sshkey
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// KeyTypeEd25519 is the SSH name of ed25519 keys and signatures.
+	KeyTypeEd25519 = "ssh-ed25519"
+)
+
+var (
+	// ErrUnsupportedKey is returned for keys that are not ed25519 keys.
+	ErrUnsupportedKey = errors.New("only ssh-ed25519 keys are supported")
+
+	// ErrBadSignature is returned by Verify if the signature does not match.
+	ErrBadSignature = errors.New("bad ssh signature")
+)
+
+// Signer can create signatures with an SSH identity key
+// without necessarily having access to the private key.
+type Signer interface {
+	// PubKey returns the public key in SSH wire format.
+	PubKey() []byte
+
+	// Sign returns a signature of `data` in SSH wire format.
+	Sign(data []byte) ([]byte, error)
+}
+
+// appendString appends `s` as SSH string (uint32 length + data).
+func appendString(buf, s []byte) []byte {
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(s)))
+	return append(append(buf, size...), s...)
+}
+
+// readString reads a SSH string from `buf` and returns it and the rest.
+func readString(buf []byte) ([]byte, []byte, error) {
+	if len(buf) < 4 {
+		return nil, nil, errors.New("ssh: short buffer")
+	}
+
+	size := binary.BigEndian.Uint32(buf)
+	if uint64(size) > uint64(len(buf)-4) {
+		return nil, nil, errors.New("ssh: string exceeds buffer")
+	}
+
+	return buf[4 : 4+size], buf[4+size:], nil
+}
+
+// readUint32 reads a big endian uint32 from `buf` and returns it and the rest.
+func readUint32(buf []byte) (uint32, []byte, error) {
+	if len(buf) < 4 {
+		return 0, nil, errors.New("ssh: short buffer")
+	}
+
+	return binary.BigEndian.Uint32(buf), buf[4:], nil
+}
+
+// MarshalPubKey encodes `pub` in the SSH wire format.
+func MarshalPubKey(pub ed25519.PublicKey) []byte {
+	blob := appendString(nil, []byte(KeyTypeEd25519))
+	return appendString(blob, pub)
+}
+
+// ParsePubKey decodes a public key in SSH wire format.
+func ParsePubKey(blob []byte) (ed25519.PublicKey, error) {
+	keyType, rest, err := readString(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(keyType) != KeyTypeEd25519 {
+		return nil, ErrUnsupportedKey
+	}
+
+	key, rest, err := readString(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != ed25519.PublicKeySize || len(rest) != 0 {
+		return nil, fmt.Errorf("ssh: bad ed25519 public key")
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// IsPubKey returns true if `data` looks like a public key in SSH wire format.
+// This is used to tell them apart from gpg keys, which never start with a
+// zero byte.
+func IsPubKey(data []byte) bool {
+	_, err := ParsePubKey(data)
+	return err == nil
+}
+
+// ParseAuthorizedKey parses a line like in ~/.ssh/id_ed25519.pub
+// (or authorized_keys) and returns the key in SSH wire format.
+func ParseAuthorizedKey(line []byte) ([]byte, error) {
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 {
+		return nil, errors.New("ssh: bad public key line")
+	}
+
+	if fields[0] != KeyTypeEd25519 {
+		return nil, ErrUnsupportedKey
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ParsePubKey(blob); err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// FormatAuthorizedKey is the opposite of ParseAuthorizedKey.
+func FormatAuthorizedKey(blob []byte) string {
+	return KeyTypeEd25519 + " " + base64.StdEncoding.EncodeToString(blob)
+}
+
+// marshalSignature encodes an ed25519 signature in SSH wire format.
+func marshalSignature(sig []byte) []byte {
+	blob := appendString(nil, []byte(KeyTypeEd25519))
+	return appendString(blob, sig)
+}
+
+// Verify checks that `sig` (in SSH wire format) is a signature
+// of `data` made by the key `pubBlob` (also in SSH wire format).
+func Verify(pubBlob, data, sig []byte) error {
+	pub, err := ParsePubKey(pubBlob)
+	if err != nil {
+		return err
+	}
+
+	sigType, rest, err := readString(sig)
+	if err != nil {
+		return ErrBadSignature
+	}
+
+	if string(sigType) != KeyTypeEd25519 {
+		return ErrBadSignature
+	}
+
+	rawSig, _, err := readString(rest)
+	if err != nil || len(rawSig) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+
+	if !ed25519.Verify(pub, data, rawSig) {
+		return ErrBadSignature
+	}
+
+	return nil
+}